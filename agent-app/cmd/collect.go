@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"machine-monitor-agent/internal/collector"
+	"machine-monitor-agent/internal/config"
+	"machine-monitor-agent/internal/types"
+)
+
+// Seções aceitas em -sections
+const (
+	collectSectionSystem   = "system"
+	collectSectionHardware = "hardware"
+)
+
+// collectOptions são as flags do -collect
+type collectOptions struct {
+	output   string
+	pretty   bool
+	sections string
+	timeout  time.Duration
+}
+
+// runCollect coleta um inventário uma única vez, sem serviço instalado nem
+// backend, e grava o JSON em stdout ou no arquivo de -output. Usa o arquivo
+// de configuração quando ele existe; um arquivo ausente ou inválido não
+// impede a coleta. Retorna o código de saída: 1 se alguma seção pedida
+// falhar, 2 com -sections inválido.
+func runCollect(configPath string, opts collectOptions) int {
+	system, hardware, err := parseCollectSections(opts.sections)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	cfg := collectConfig(configPath)
+	c := collector.NewCollector(cfg.Agent.DataCacheTTL.Duration())
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	inventory, err := collectInventorySections(ctx, c, cfg.Agent.MachineID, system, hardware)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao coletar inventário: %v\n", err)
+		return 1
+	}
+
+	var data []byte
+	if opts.pretty {
+		data, err = json.MarshalIndent(inventory, "", "  ")
+	} else {
+		data, err = json.Marshal(inventory)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao serializar inventário: %v\n", err)
+		return 1
+	}
+	data = append(data, '\n')
+
+	if opts.output == "" || opts.output == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(opts.output, data, 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao gravar inventário: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// collectConfig carrega a configuração, se o arquivo existir; sem ele, ou
+// com um arquivo inválido, valem os padrões (com um machine_id novo)
+func collectConfig(configPath string) *types.Config {
+	cfg, err := config.LoadConfig(configPath)
+	if err == nil {
+		return cfg
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "aviso: ignorando a configuração: %v\n", err)
+	}
+
+	cfg, err = config.DefaultConfig()
+	if err != nil {
+		// Só falha sem fonte de aleatoriedade para o machine_id
+		cfg = &types.Config{}
+	}
+	return cfg
+}
+
+// parseCollectSections interpreta -sections; vazio coleta todas
+func parseCollectSections(list string) (system, hardware bool, err error) {
+	if strings.TrimSpace(list) == "" {
+		return true, true, nil
+	}
+	for _, section := range strings.Split(list, ",") {
+		switch strings.TrimSpace(section) {
+		case collectSectionSystem:
+			system = true
+		case collectSectionHardware:
+			hardware = true
+		case "":
+		default:
+			return false, false, fmt.Errorf("-sections: seção desconhecida %q (use system e/ou hardware)", section)
+		}
+	}
+	return system, hardware, nil
+}
+
+// collectInventorySections coleta as seções pedidas; as demais saem vazias.
+// Qualquer seção pedida que falhe falha a coleta.
+func collectInventorySections(ctx context.Context, c *collector.Collector, machineID string, system, hardware bool) (*types.Inventory, error) {
+	if system && hardware {
+		return c.CollectInventory(ctx, machineID)
+	}
+
+	inventory := &types.Inventory{MachineID: machineID, Timestamp: time.Now()}
+	if system {
+		info, err := c.CollectSystemInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao coletar informações do sistema: %w", err)
+		}
+		inventory.System = *info
+	}
+	if hardware {
+		info, err := c.CollectHardwareInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao coletar informações de hardware: %w", err)
+		}
+		inventory.Hardware = *info
+	}
+	return inventory, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestRunCollectSections(t *testing.T) {
+	dir := t.TempDir()
+	missingConfig := filepath.Join(dir, "missing.json")
+
+	// Só system, sem arquivo de configuração: hardware sai vazio
+	output := filepath.Join(dir, "inventory.json")
+	if code := runCollect(missingConfig, collectOptions{output: output, sections: "system", timeout: time.Minute}); code != 0 {
+		t.Fatalf("-collect -sections system: exit %d", code)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inventory types.Inventory
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		t.Fatalf("output is not an inventory: %v", err)
+	}
+	if inventory.MachineID == "" || inventory.System.Hostname == "" {
+		t.Fatalf("inventory = %+v", inventory)
+	}
+	if inventory.Hardware.CPU.Cores != 0 || inventory.Hardware.Memory.Total != 0 {
+		t.Fatalf("hardware collected although not requested: %+v", inventory.Hardware)
+	}
+
+	// Seção desconhecida é erro de uso e não grava nada
+	bad := filepath.Join(dir, "bad.json")
+	if code := runCollect(missingConfig, collectOptions{output: bad, sections: "system,software", timeout: time.Minute}); code != 2 {
+		t.Fatalf("unknown section: exit %d", code)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Fatalf("output written on a usage error: %v", err)
+	}
+}
+
+func TestParseCollectSections(t *testing.T) {
+	tests := []struct {
+		list             string
+		system, hardware bool
+	}{
+		{list: "", system: true, hardware: true},
+		{list: "system", system: true},
+		{list: " hardware ,", hardware: true},
+		{list: "hardware,system", system: true, hardware: true},
+	}
+	for _, tt := range tests {
+		system, hardware, err := parseCollectSections(tt.list)
+		if err != nil || system != tt.system || hardware != tt.hardware {
+			t.Errorf("parseCollectSections(%q) = %v, %v, %v", tt.list, system, hardware, err)
+		}
+	}
+	if _, _, err := parseCollectSections("network"); err == nil {
+		t.Fatal("unknown section accepted")
+	}
+}
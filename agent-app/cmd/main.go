@@ -1,15 +1,18 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
+	"time"
 
 	"machine-monitor-agent/internal/agent"
 	"machine-monitor-agent/internal/config"
+	"machine-monitor-agent/internal/logging"
 	"machine-monitor-agent/internal/types"
 
 	"github.com/kardianos/service"
@@ -27,6 +30,8 @@ const (
 type Program struct {
 	agent      *agent.Agent
 	configPath string
+	// shipper envia os logs ao backend (nil com logging.shipping desligado)
+	shipper *logging.Shipper
 }
 
 // Start inicia o serviço
@@ -49,6 +54,9 @@ func (p *Program) Start(s service.Service) error {
 	if err := p.agent.Start(); err != nil {
 		return fmt.Errorf("erro ao iniciar agente: %w", err)
 	}
+	if p.shipper != nil {
+		p.shipper.Start(p.agent.SendLogs)
+	}
 
 	// Inicia em goroutine para não bloquear
 	go p.agent.Wait()
@@ -67,6 +75,11 @@ func (p *Program) Stop(s service.Service) error {
 		}
 	}
 
+	// Últimos lotes de log, inclusive os da parada
+	if p.shipper != nil {
+		p.shipper.Close()
+	}
+
 	log.Info().Msg("Serviço parado com sucesso")
 	return nil
 }
@@ -90,24 +103,36 @@ func (p *Program) setupLogging(cfg *types.Config) error {
 
 	zerolog.SetGlobalLevel(level)
 
-	// Configura saída para arquivo se especificado
+	// Configura saída para arquivo se especificado, rotacionado ao passar
+	// de max_size MB
+	var logFile *logging.RotatingFile
 	if cfg.Logging.File != "" {
-		// Cria diretório se não existir
-		logDir := filepath.Dir(cfg.Logging.File)
-		if err := os.MkdirAll(logDir, 0755); err != nil {
-			return fmt.Errorf("erro ao criar diretório de log: %w", err)
-		}
-
-		// Abre arquivo de log
-		logFile, err := os.OpenFile(cfg.Logging.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		var err error
+		logFile, err = logging.OpenRotatingFile(cfg.Logging.File, logging.RotateOptions{
+			MaxBytes:   int64(cfg.Logging.MaxSize) * 1024 * 1024,
+			MaxBackups: cfg.Logging.MaxBackups,
+			MaxAge:     time.Duration(cfg.Logging.MaxAge) * 24 * time.Hour,
+			Compress:   cfg.Logging.Compress,
+		})
 		if err != nil {
-			return fmt.Errorf("erro ao abrir arquivo de log: %w", err)
+			return err
 		}
 
 		// Configura logger para escrever no arquivo
 		log.Logger = log.Output(logFile)
 	}
 
+	// Envio ao backend junto com a saída local; o shipper só começa a
+	// enviar quando o agente existe (Start)
+	if cfg.Logging.Shipping.Enabled {
+		var output io.Writer = zerolog.ConsoleWriter{Out: os.Stderr}
+		if logFile != nil {
+			output = logFile
+		}
+		p.shipper = logging.NewShipper(cfg.Logging.Shipping)
+		log.Logger = log.Output(zerolog.MultiLevelWriter(output, p.shipper))
+	}
+
 	// Adiciona timestamp e caller info
 	log.Logger = log.Logger.With().
 		Timestamp().
@@ -129,6 +154,15 @@ func main() {
 		restart    = flag.Bool("restart", false, "Reinicia o serviço")
 		console    = flag.Bool("console", false, "Executa em modo console (não como serviço)")
 		version    = flag.Bool("version", false, "Mostra a versão")
+		validate   = flag.Bool("validate-config", false, "Valida o arquivo de configuração e sai")
+		sample     = flag.String("print-default-config", "", "Imprime uma configuração de exemplo no formato (json, yaml ou toml) e sai")
+
+		// Coleta avulsa (-collect), sem serviço nem backend
+		collect        = flag.Bool("collect", false, "Coleta um inventário, grava o JSON e sai")
+		collectOutput  = flag.String("output", "", "Arquivo do inventário do -collect (vazio ou - = stdout)")
+		collectPretty  = flag.Bool("pretty", false, "JSON indentado no -collect")
+		collectSection = flag.String("sections", "", "Seções do -collect separadas por vírgula: system, hardware (vazio = todas)")
+		collectTimeout = flag.Duration("timeout", 2*time.Minute, "Tempo máximo do -collect")
 	)
 	flag.Parse()
 
@@ -139,9 +173,35 @@ func main() {
 		return
 	}
 
+	// Imprime a configuração de exemplo
+	if *sample != "" {
+		data, err := config.SampleConfig(*sample)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+
+	// Só valida a configuração, sem iniciar o agente
+	if *validate {
+		os.Exit(validateConfig(*configPath))
+	}
+
 	// Configura logging básico
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
+	// Coleta avulsa; o JSON vai para stdout, os logs para stderr
+	if *collect {
+		os.Exit(runCollect(*configPath, collectOptions{
+			output:   *collectOutput,
+			pretty:   *collectPretty,
+			sections: *collectSection,
+			timeout:  *collectTimeout,
+		}))
+	}
+
 	// Cria programa
 	prg := &Program{
 		configPath: *configPath,
@@ -262,3 +322,29 @@ func main() {
 		}
 	}
 }
+
+// validateConfig carrega e valida o arquivo de configuração, imprime um
+// problema por linha (cada um com o caminho JSON do campo) e retorna o
+// código de saída: 0 se a configuração é válida
+func validateConfig(configPath string) int {
+	name := configPath
+	if name == "" {
+		name = "configuração padrão"
+	}
+
+	if _, err := config.LoadConfig(configPath); err != nil {
+		var validation *config.ValidationError
+		if !errors.As(err, &validation) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d problema(s) encontrado(s)\n", name, len(validation.Problems))
+		for _, problem := range validation.Problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		return 1
+	}
+
+	fmt.Printf("%s: configuração válida\n", name)
+	return 0
+}
@@ -5,11 +5,14 @@ go 1.23.0
 toolchain go1.24.5
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/getlantern/systray v1.2.2
 	github.com/gorilla/websocket v1.5.3
 	github.com/kardianos/service v1.2.2
 	github.com/rs/zerolog v1.34.0
 	github.com/shirou/gopsutil/v3 v3.23.12
+	go.uber.org/goleak v1.3.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
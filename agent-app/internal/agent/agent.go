@@ -2,9 +2,12 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,6 +15,8 @@ import (
 	"machine-monitor-agent/internal/communications"
 	"machine-monitor-agent/internal/config"
 	"machine-monitor-agent/internal/executor"
+	"machine-monitor-agent/internal/i18n"
+	"machine-monitor-agent/internal/timeutil"
 	"machine-monitor-agent/internal/types"
 	"machine-monitor-agent/internal/ui"
 
@@ -33,13 +38,22 @@ type Agent struct {
 	statusMu  sync.RWMutex
 	startTime time.Time
 
+	// Histórico recente (estado, conexão, comandos, inventário)
+	events *EventLog
+
+	// Cópia local dos inventários coletados (nil se desabilitada)
+	history *InventoryHistory
+
 	// Controle
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	// Canais
-	restartChan chan struct{}
+	// Canais (o valor é o ID do comando que pediu o restart, se houver)
+	restartChan chan string
+
+	// Busca e executa o programa que abre o navegador
+	opener execRunner
 }
 
 // NewAgent cria uma nova instância do agente
@@ -51,7 +65,9 @@ func NewAgent(cfg *types.Config) *Agent {
 		startTime:   time.Now(),
 		ctx:         ctx,
 		cancel:      cancel,
-		restartChan: make(chan struct{}, 1),
+		restartChan: make(chan string, 1),
+		opener:      osExecRunner{},
+		events:      NewEventLog(cfg.Agent.EventLogSize),
 		status: &types.AgentStatus{
 			State:         types.StateStarting,
 			LastHeartbeat: time.Time{},
@@ -112,12 +128,23 @@ func (a *Agent) Stop() error {
 	// Aguarda goroutines terminarem
 	a.wg.Wait()
 
+	// Encerra o coletor depois das goroutines que o usam
+	if a.collector != nil {
+		a.collector.Close()
+	}
+
 	a.updateStatus(types.StateStopped)
 
 	log.Info().Msg("Agent parado com sucesso")
 	return nil
 }
 
+// SendLogs envia um lote de logs do agente ao backend (ver logging.Shipper)
+func (a *Agent) SendLogs(ctx context.Context, batch *types.LogBatch) error {
+	batch.MachineID = a.config.Agent.MachineID
+	return a.httpClient.SendLogs(ctx, batch)
+}
+
 // Wait aguarda o agente terminar
 func (a *Agent) Wait() {
 	<-a.ctx.Done()
@@ -125,11 +152,16 @@ func (a *Agent) Wait() {
 
 // Restart reinicia o agente
 func (a *Agent) Restart() error {
-	log.Info().Msg("Reiniciando agente...")
+	return a.requestRestart("")
+}
+
+// requestRestart sinaliza o loop principal para reiniciar o agente
+func (a *Agent) requestRestart(commandID string) error {
+	log.Info().Str("command_id", commandID).Msg("Reiniciando agente...")
 
 	// Sinaliza restart
 	select {
-	case a.restartChan <- struct{}{}:
+	case a.restartChan <- commandID:
 	default:
 	}
 
@@ -139,11 +171,14 @@ func (a *Agent) Restart() error {
 // initializeComponents inicializa todos os componentes
 func (a *Agent) initializeComponents() error {
 	// Inicializa collector
-	cacheTTL := time.Duration(a.config.Agent.DataCacheTTL) * time.Second
+	cacheTTL := a.config.Agent.DataCacheTTL.Duration()
 	a.collector = collector.NewCollector(cacheTTL)
+	if err := a.collector.Start(a.ctx); err != nil {
+		return fmt.Errorf("erro ao iniciar coletor: %w", err)
+	}
 
 	// Inicializa HTTP client
-	timeout := time.Duration(a.config.Server.Timeout) * time.Second
+	timeout := a.config.Server.Timeout.Duration()
 	a.httpClient = communications.NewHTTPClient(
 		a.config.Server.BaseURL,
 		a.config.Security.APIKey,
@@ -161,7 +196,24 @@ func (a *Agent) initializeComponents() error {
 	a.executor = executor.NewExecutor(
 		a.config.Security.AllowedCommands,
 		a.config.Agent.MaxConcurrency,
+		a.config.Security.MaxOutputBytes,
 	)
+	a.executor.SetEventSource(a.GetEvents)
+	a.executor.SetInventoryHistorySource(a)
+
+	// Inicializa histórico de inventários; sem ele o agente segue sem cópia local
+	if cfg := a.config.Agent.InventoryHistory; cfg.Enabled {
+		history, err := NewInventoryHistory(filepath.Join(config.GetDataDirectory(), "inventory_history"), cfg.MaxEntries, cfg.MaxAge.Duration())
+		if err != nil {
+			log.Warn().Err(err).Msg("Histórico de inventário desabilitado")
+		} else {
+			a.history = history
+			a.pruneHistory()
+		}
+	}
+
+	// Idioma do tray e da interface web
+	catalog := i18n.New(a.config.UI.Language)
 
 	// Inicializa tray icon se habilitado
 	if a.config.UI.ShowTrayIcon {
@@ -169,12 +221,22 @@ func (a *Agent) initializeComponents() error {
 			a.showUI,
 			func() { a.Restart() },
 			func() { a.cancel() },
+			catalog,
 		)
 		a.trayIcon.Start()
+
+		// Sem como abrir o navegador, o endereço fica visível desde o início
+		if !hasOpener(a.opener, runtime.GOOS) {
+			a.trayIcon.ShowURL(a.webUIURL())
+		}
 	}
 
 	// Inicializa interface web
-	a.webUI = ui.NewWebUI(a, a.config.UI.WebUIPort)
+	webUI, err := ui.NewWebUI(a, a.config.UI, catalog)
+	if err != nil {
+		return fmt.Errorf("erro ao criar interface web: %w", err)
+	}
+	a.webUI = webUI
 	if err := a.webUI.Start(); err != nil {
 		return fmt.Errorf("erro ao iniciar interface web: %w", err)
 	}
@@ -203,6 +265,12 @@ func (a *Agent) startMainLoops() {
 	// Loop de status
 	a.wg.Add(1)
 	go a.statusLoop()
+
+	// Limpeza do histórico de inventários
+	if a.history != nil {
+		a.wg.Add(1)
+		go a.historyLoop()
+	}
 }
 
 // mainLoop loop principal do agente
@@ -212,6 +280,9 @@ func (a *Agent) mainLoop() {
 	// Conecta WebSocket
 	if err := a.wsClient.Connect(a.ctx); err != nil {
 		log.Error().Err(err).Msg("Erro ao conectar WebSocket")
+		a.events.Record(types.EventConnectionFailed, map[string]interface{}{"error": err.Error()})
+	} else {
+		a.events.Record(types.EventConnected, nil)
 	}
 
 	// Registra máquina
@@ -224,8 +295,9 @@ func (a *Agent) mainLoop() {
 		select {
 		case <-a.ctx.Done():
 			return
-		case <-a.restartChan:
-			a.handleRestart()
+		case commandID := <-a.restartChan:
+			// Executa fora do loop para que Stop possa aguardar esta goroutine
+			go a.handleRestart(commandID)
 			return
 		case <-time.After(time.Minute):
 			// Verifica conexões e estado geral
@@ -238,7 +310,7 @@ func (a *Agent) mainLoop() {
 func (a *Agent) heartbeatLoop() {
 	defer a.wg.Done()
 
-	interval := time.Duration(a.config.Agent.HeartbeatInterval) * time.Second
+	interval := a.config.Agent.HeartbeatInterval.Duration()
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -256,7 +328,7 @@ func (a *Agent) heartbeatLoop() {
 func (a *Agent) inventoryLoop() {
 	defer a.wg.Done()
 
-	interval := time.Duration(a.config.Agent.InventoryInterval) * time.Second
+	interval := a.config.Agent.InventoryInterval.Duration()
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -309,6 +381,35 @@ func (a *Agent) statusLoop() {
 	}
 }
 
+// historyLoop apaga periodicamente os inventários além dos limites do histórico
+func (a *Agent) historyLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.pruneHistory()
+		}
+	}
+}
+
+// pruneHistory aplica os limites de quantidade e idade do histórico
+func (a *Agent) pruneHistory() {
+	removed, err := a.history.Prune(time.Now())
+	if err != nil {
+		log.Warn().Err(err).Msg("Erro ao limpar histórico de inventário")
+		return
+	}
+	if removed > 0 {
+		log.Debug().Int("removed", removed).Msg("Inventários antigos removidos do histórico")
+	}
+}
+
 // registerMachine registra a máquina no backend
 func (a *Agent) registerMachine() error {
 	ctx, cancel := context.WithTimeout(a.ctx, 30*time.Second)
@@ -369,23 +470,37 @@ func (a *Agent) sendInventory() {
 	if err != nil {
 		log.Error().Err(err).Msg("Erro ao coletar inventário")
 		a.incrementErrors()
+		a.events.Record(types.EventInventoryFailed, map[string]interface{}{"stage": "collect", "error": err.Error()})
 		return
 	}
 
+	// A cópia local vale mesmo se o envio falhar
+	if a.history != nil {
+		if _, err := a.history.Record(inventory); err != nil {
+			log.Warn().Err(err).Msg("Erro ao gravar inventário no histórico")
+		}
+	}
+
 	if err := a.httpClient.SendInventory(ctx, inventory); err != nil {
 		log.Error().Err(err).Msg("Erro ao enviar inventário")
 		a.incrementErrors()
+		a.events.Record(types.EventInventoryFailed, map[string]interface{}{"stage": "send", "error": err.Error()})
 	} else {
 		a.statusMu.Lock()
 		a.status.LastInventory = time.Now()
 		a.statusMu.Unlock()
 		log.Info().Msg("Inventário enviado com sucesso")
+		a.events.Record(types.EventInventorySent, nil)
 	}
 }
 
 // processCommand processa um comando recebido
 func (a *Agent) processCommand(command types.Command) {
 	log.Info().Str("command_id", command.ID).Str("type", command.Type).Msg("Processando comando")
+	a.events.Record(types.EventCommandReceived, map[string]interface{}{
+		"command_id": command.ID,
+		"type":       command.Type,
+	})
 
 	ctx, cancel := context.WithTimeout(a.ctx, time.Duration(command.Timeout)*time.Second)
 	defer cancel()
@@ -399,8 +514,19 @@ func (a *Agent) processCommand(command types.Command) {
 	if !result.Success {
 		a.status.Errors++
 	}
+	a.status.LastCommand = &types.CommandSummary{
+		ID:                  command.ID,
+		Type:                command.Type,
+		Success:             result.Success,
+		OutputBytes:         len(result.Output),
+		OutputTruncated:     result.OutputTruncated,
+		OriginalOutputBytes: result.OriginalOutputBytes,
+		Timestamp:           result.Timestamp,
+	}
 	a.statusMu.Unlock()
 
+	a.recordCommandResult(command, result)
+
 	// Envia resultado via WebSocket
 	if err := a.wsClient.SendResult(result); err != nil {
 		log.Error().Err(err).Str("command_id", command.ID).Msg("Erro ao enviar resultado via WebSocket")
@@ -415,9 +541,9 @@ func (a *Agent) processCommand(command types.Command) {
 		}
 	}
 
-	// Trata comando especial de restart
-	if command.Type == types.CommandTypeRestart && result.Success {
-		a.Restart()
+	// Trata comando especial de restart (após o envio do resultado)
+	if (command.Type == types.CommandTypeRestart || command.Type == types.CommandTypeRestartAgent) && result.Success {
+		a.requestRestart(command.ID)
 	}
 }
 
@@ -426,8 +552,12 @@ func (a *Agent) healthCheck() {
 	// Verifica conexão WebSocket
 	if !a.wsClient.IsConnected() {
 		log.Warn().Msg("WebSocket desconectado, tentando reconectar...")
+		a.events.Record(types.EventDisconnected, nil)
 		if err := a.wsClient.Connect(a.ctx); err != nil {
 			log.Error().Err(err).Msg("Erro ao reconectar WebSocket")
+			a.events.Record(types.EventConnectionFailed, map[string]interface{}{"error": err.Error(), "reconnect": true})
+		} else {
+			a.events.Record(types.EventConnected, map[string]interface{}{"reconnect": true})
 		}
 	}
 
@@ -441,59 +571,84 @@ func (a *Agent) healthCheck() {
 	}
 }
 
-// handleRestart trata o restart do agente
-func (a *Agent) handleRestart() {
-	log.Info().Msg("Executando restart do agente...")
-
-	// Para o agente atual
-	a.Stop()
+// showUI abre a interface web no navegador padrão, com um ticket de uso
+// único para não pedir credenciais; sem um programa para isso (servidores
+// sem interface gráfica) o endereço, sem ticket, vai para o log e para o
+// tooltip do tray
+func (a *Agent) showUI() {
+	url := a.webUIURL()
 
-	// Reinicia o processo
-	executable, err := os.Executable()
-	if err != nil {
-		log.Error().Err(err).Msg("Erro ao obter caminho do executável")
-		return
+	target := url
+	if a.webUI != nil {
+		target = a.webUI.LoginURL(url)
 	}
 
-	cmd := exec.Command(executable, os.Args[1:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
-		log.Error().Err(err).Msg("Erro ao reiniciar processo")
-		return
+	err := openURL(a.opener, runtime.GOOS, target)
+	switch {
+	case errors.Is(err, errNoOpener):
+		a.announceWebUI(url)
+	case err != nil:
+		log.Error().Err(err).Str("url", url).Msg("Erro ao abrir interface web")
 	}
-
-	log.Info().Msg("Novo processo iniciado, finalizando atual...")
-	os.Exit(0)
 }
 
-// showUI abre a interface web
-func (a *Agent) showUI() {
-	url := fmt.Sprintf("http://localhost:%d", a.config.UI.WebUIPort)
-
-	var cmd *exec.Cmd
-	switch {
-	case os.Getenv("DISPLAY") != "":
-		cmd = exec.Command("xdg-open", url)
-	case os.Getenv("TERM_PROGRAM") == "Apple_Terminal":
-		cmd = exec.Command("open", url)
-	default:
-		cmd = exec.Command("cmd", "/c", "start", url)
+// webUIURL retorna o endereço da interface web local; escutando em todas as
+// interfaces, usa localhost
+func (a *Agent) webUIURL() string {
+	host := a.config.UI.BindAddress
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
 	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(a.config.UI.WebUIPort))
+}
 
-	if err := cmd.Start(); err != nil {
-		log.Error().Err(err).Msg("Erro ao abrir interface web")
+// announceWebUI divulga o endereço da interface web quando não é possível
+// abrir o navegador
+func (a *Agent) announceWebUI(url string) {
+	log.Warn().Str("url", url).Msgf("Nenhum navegador disponível; acesse a interface web em %s", url)
+	if a.trayIcon != nil {
+		a.trayIcon.ShowURL(url)
 	}
 }
 
 // updateStatus atualiza o status do agente
 func (a *Agent) updateStatus(state string) {
 	a.statusMu.Lock()
-	defer a.statusMu.Unlock()
-
+	previous := a.status.State
 	a.status.State = state
-	a.status.Uptime = time.Since(a.startTime)
+	a.status.Uptime = timeutil.Seconds(time.Since(a.startTime).Truncate(time.Second))
+	a.statusMu.Unlock()
+
+	if previous != state {
+		a.events.Record(types.EventStateChanged, map[string]interface{}{
+			"from": previous,
+			"to":   state,
+		})
+	}
+}
+
+// recordCommandResult registra o resultado no histórico; recusas antes da
+// execução (tipo não permitido ou desconhecido, comando vazio ou perigoso)
+// viram command_rejected
+func (a *Agent) recordCommandResult(command types.Command, result types.CommandResult) {
+	data := map[string]interface{}{
+		"command_id":  command.ID,
+		"type":        command.Type,
+		"success":     result.Success,
+		"exit_code":   result.ExitCode,
+		"duration_ms": result.Duration,
+	}
+	if result.ErrorCode != "" {
+		data["error_code"] = string(result.ErrorCode)
+	}
+
+	switch result.ErrorCode {
+	case types.ErrCodeCommandNotAllowed, types.ErrCodeUnsupportedCommandType,
+		types.ErrCodeEmptyCommand, types.ErrCodeUnsafeCommand, types.ErrCodeInvalidArgument:
+		a.events.Record(types.EventCommandRejected, data)
+	default:
+		a.events.Record(types.EventCommandExecuted, data)
+	}
 }
 
 // updateUptime atualiza o uptime
@@ -501,7 +656,7 @@ func (a *Agent) updateUptime() {
 	a.statusMu.Lock()
 	defer a.statusMu.Unlock()
 
-	a.status.Uptime = time.Since(a.startTime)
+	a.status.Uptime = timeutil.Seconds(time.Since(a.startTime).Truncate(time.Second))
 }
 
 // incrementErrors incrementa contador de erros
@@ -526,11 +681,57 @@ func (a *Agent) GetConfig() *types.Config {
 	return a.config
 }
 
+// GetEvents retorna o histórico recente posterior a since (zero retorna
+// tudo), do mais antigo para o mais novo; com limit > 0, apenas os limit
+// mais recentes
+func (a *Agent) GetEvents(since time.Time, limit int) []types.Event {
+	return a.events.Since(since, limit)
+}
+
+// GetInventoryHistory lista os limit inventários mais recentes do histórico
+// local (todos com limit <= 0), do mais novo para o mais antigo, sem o conteúdo
+func (a *Agent) GetInventoryHistory(limit int) ([]types.InventoryHistoryEntry, error) {
+	if a.history == nil {
+		return nil, types.NewCodedError(types.ErrCodeHistoryDisabled)
+	}
+	return a.history.List(limit)
+}
+
+// GetInventoryAt retorna o inventário mais recente do histórico local
+// coletado até at
+func (a *Agent) GetInventoryAt(at time.Time) (*types.InventoryHistoryEntry, error) {
+	if a.history == nil {
+		return nil, types.NewCodedError(types.ErrCodeHistoryDisabled)
+	}
+	entry, err := a.history.At(at)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, types.NewCodedError(types.ErrCodeInventoryNotFound, at.Format(time.RFC3339))
+	}
+	return entry, nil
+}
+
 // GetStatus retorna o status atual (método público para interface)
 func (a *Agent) GetStatus() *types.AgentStatus {
 	return a.getStatus()
 }
 
+// GetExecutionMetrics retorna as métricas do executor (método público para interface)
+func (a *Agent) GetExecutionMetrics() types.ExecutionMetrics {
+	return a.executor.GetMetrics()
+}
+
+// GetConnectionMetrics retorna as métricas do HTTP e do WebSocket com o
+// backend (método público para interface)
+func (a *Agent) GetConnectionMetrics() types.ConnectionMetrics {
+	return types.ConnectionMetrics{
+		HTTP:      a.httpClient.GetMetrics(),
+		WebSocket: a.wsClient.GetMetrics(),
+	}
+}
+
 // CollectSystemInfo coleta informações do sistema (método público para interface)
 func (a *Agent) CollectSystemInfo(ctx context.Context) (*types.SystemInfo, error) {
 	return a.collector.CollectSystemInfo(ctx)
@@ -541,14 +742,32 @@ func (a *Agent) CollectHardwareInfo(ctx context.Context) (*types.HardwareInfo, e
 	return a.collector.CollectHardwareInfo(ctx)
 }
 
-// CollectSystemInfoFresh coleta informações do sistema sem cache
+// CollectSecurityPosture verifica firewall, bloqueio de tela, SIP/Gatekeeper
+// e acesso remoto (método público para interface)
+func (a *Agent) CollectSecurityPosture(ctx context.Context) (*types.SecurityPosture, error) {
+	return a.collector.CollectSecurityPosture(ctx)
+}
+
+// SampleUsage lê o uso atual de CPU e memória (método público para interface)
+func (a *Agent) SampleUsage(ctx context.Context) (*types.UsageSample, error) {
+	return a.collector.SampleUsage(ctx)
+}
+
+// InvalidateCache descarta apenas as seções informadas do cache do coletor
+func (a *Agent) InvalidateCache(keys ...string) {
+	a.collector.InvalidateCache(keys...)
+}
+
+// CollectSystemInfoFresh coleta informações do sistema sem ler o cache; o
+// resultado atualiza apenas a entrada system_info, e uma falha mantém a
+// anterior
 func (a *Agent) CollectSystemInfoFresh(ctx context.Context) (*types.SystemInfo, error) {
-	a.collector.ClearCache()
-	return a.collector.CollectSystemInfo(ctx)
+	return a.collector.CollectSystemInfoWith(ctx, collector.CollectOptions{Fresh: true})
 }
 
-// CollectHardwareInfoFresh coleta informações de hardware sem cache
+// CollectHardwareInfoFresh coleta informações de hardware sem ler o cache;
+// o resultado atualiza apenas a entrada hardware_info (as GPUs continuam
+// vindo de gpu_info)
 func (a *Agent) CollectHardwareInfoFresh(ctx context.Context) (*types.HardwareInfo, error) {
-	a.collector.ClearCache()
-	return a.collector.CollectHardwareInfo(ctx)
+	return a.collector.CollectHardwareInfoWith(ctx, collector.CollectOptions{Fresh: true})
 }
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// defaultEventLogSize capacidade do histórico quando a configuração não define outra
+const defaultEventLogSize = 1000
+
+// EventLog histórico recente do agente em buffer circular: cheio, o evento
+// mais antigo dá lugar ao novo. Seguro para várias goroutines escrevendo.
+type EventLog struct {
+	mu     sync.Mutex
+	buf    []types.Event
+	next   int
+	filled bool
+}
+
+// NewEventLog cria um histórico com a capacidade informada (zero ou
+// negativa usa defaultEventLogSize)
+func NewEventLog(capacity int) *EventLog {
+	if capacity <= 0 {
+		capacity = defaultEventLogSize
+	}
+	return &EventLog{buf: make([]types.Event, capacity)}
+}
+
+// Record acrescenta um evento com o horário atual e a descrição do catálogo
+func (l *EventLog) Record(code types.EventCode, data map[string]interface{}) {
+	event := types.Event{
+		Timestamp: time.Now(),
+		Type:      string(code),
+		Message:   code.Message(),
+		Data:      data,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf[l.next] = event
+	l.next++
+	if l.next == len(l.buf) {
+		l.next = 0
+		l.filled = true
+	}
+}
+
+// Since retorna, do mais antigo para o mais novo, os eventos posteriores a
+// since (zero retorna todos); com limit > 0, apenas os limit mais recentes
+func (l *EventLog) Since(since time.Time, limit int) []types.Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ordered := l.buf[:l.next]
+	if l.filled {
+		ordered = append(append(make([]types.Event, 0, len(l.buf)), l.buf[l.next:]...), l.buf[:l.next]...)
+	}
+
+	// Um ajuste de relógio pode deixar timestamps fora de ordem, por isso o
+	// filtro percorre todos os eventos
+	events := make([]types.Event, 0, len(ordered))
+	for _, event := range ordered {
+		if event.Timestamp.After(since) {
+			events = append(events, event)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestEventLogRecordUsesCatalog(t *testing.T) {
+	log := NewEventLog(2)
+	log.Record(types.EventConnected, nil)
+	log.Record(types.EventInventoryFailed, map[string]interface{}{"stage": "send"})
+	log.Record(types.EventCommandRejected, nil)
+
+	events := log.Since(time.Time{}, 0)
+	if len(events) != 2 {
+		t.Fatalf("ring kept %d events, want 2", len(events))
+	}
+	for i, code := range []types.EventCode{types.EventInventoryFailed, types.EventCommandRejected} {
+		if events[i].Type != string(code) || events[i].Message != code.Message() {
+			t.Errorf("event %d = %q %q, want %q %q", i, events[i].Type, events[i].Message, code, code.Message())
+		}
+	}
+	if events[0].Data["stage"] != "send" {
+		t.Errorf("event data lost: %v", events[0].Data)
+	}
+	if limited := log.Since(time.Time{}, 1); len(limited) != 1 || limited[0].Type != string(types.EventCommandRejected) {
+		t.Errorf("limit 1 = %v", limited)
+	}
+}
+
+func TestEventLogOrderAndEviction(t *testing.T) {
+	log := NewEventLog(3)
+	if events := log.Since(time.Time{}, 0); len(events) != 0 {
+		t.Fatalf("empty log = %v", events)
+	}
+
+	codes := []types.EventCode{
+		types.EventConnected, types.EventDisconnected, types.EventInventorySent,
+		types.EventInventoryFailed, types.EventCommandRejected,
+	}
+	for _, code := range codes {
+		log.Record(code, nil)
+	}
+
+	// Cheio, os mais antigos saem; a ordem continua do mais antigo ao mais novo
+	events := log.Since(time.Time{}, 0)
+	if len(events) != 3 {
+		t.Fatalf("log kept %d events, want 3", len(events))
+	}
+	for i, code := range codes[2:] {
+		if events[i].Type != string(code) {
+			t.Errorf("event %d = %q, want %q", i, events[i].Type, code)
+		}
+	}
+	if events[0].Timestamp.After(events[2].Timestamp) {
+		t.Errorf("timestamps out of order: %s after %s", events[0].Timestamp, events[2].Timestamp)
+	}
+}
+
+func TestEventLogSince(t *testing.T) {
+	log := NewEventLog(10)
+	for _, code := range []types.EventCode{types.EventConnected, types.EventInventorySent, types.EventDisconnected} {
+		log.Record(code, nil)
+		time.Sleep(time.Millisecond)
+	}
+	all := log.Since(time.Time{}, 0)
+
+	// Estritamente depois de since
+	after := log.Since(all[0].Timestamp, 0)
+	if len(after) != 2 || after[0].Type != string(types.EventInventorySent) || after[1].Type != string(types.EventDisconnected) {
+		t.Fatalf("since the first event = %v", after)
+	}
+	if events := log.Since(all[2].Timestamp, 0); len(events) != 0 {
+		t.Fatalf("since the last event = %v", events)
+	}
+	if events := log.Since(all[0].Timestamp, 1); len(events) != 1 || events[0].Type != string(types.EventDisconnected) {
+		t.Fatalf("since with limit 1 = %v", events)
+	}
+}
+
+func TestEventLogDefaultCapacity(t *testing.T) {
+	log := NewEventLog(0)
+	for i := 0; i < defaultEventLogSize+5; i++ {
+		log.Record(types.EventConnected, map[string]interface{}{"n": i})
+	}
+	events := log.Since(time.Time{}, 0)
+	if len(events) != defaultEventLogSize || events[0].Data["n"] != 5 {
+		t.Fatalf("%d events, oldest %v", len(events), events[0].Data)
+	}
+}
+
+func TestEventLogConcurrentWriters(t *testing.T) {
+	const writers, perWriter = 8, 100
+	log := NewEventLog(writers * perWriter)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				log.Record(types.EventCommandExecuted, map[string]interface{}{"writer": w, "n": i})
+				log.Since(time.Time{}, 5)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Todos chegaram, e cada escritor na ordem em que escreveu
+	events := log.Since(time.Time{}, 0)
+	if len(events) != writers*perWriter {
+		t.Fatalf("%d events, want %d", len(events), writers*perWriter)
+	}
+	last := make(map[string]int)
+	for _, event := range events {
+		writer, n := fmt.Sprint(event.Data["writer"]), event.Data["n"].(int)
+		if previous, ok := last[writer]; ok && n <= previous {
+			t.Fatalf("writer %s: %d after %d", writer, n, previous)
+		}
+		last[writer] = n
+	}
+}
+
+func TestRecordCommandResultRejections(t *testing.T) {
+	a := &Agent{events: NewEventLog(10)}
+
+	rejected := types.CommandResult{ID: "1"}
+	rejected.SetError(types.NewCodedError(types.ErrCodeUnsafeCommand))
+	a.recordCommandResult(types.Command{ID: "1", Type: types.CommandTypeShell}, rejected)
+
+	failed := types.CommandResult{ID: "2", ExitCode: 1}
+	failed.SetError(types.NewCodedError(types.ErrCodeExecutionFailed, "exit status 1"))
+	a.recordCommandResult(types.Command{ID: "2", Type: types.CommandTypeShell}, failed)
+
+	events := a.events.Since(time.Time{}, 0)
+	if len(events) != 2 {
+		t.Fatalf("recorded %d events", len(events))
+	}
+	if events[0].Type != string(types.EventCommandRejected) || events[0].Data["error_code"] != string(types.ErrCodeUnsafeCommand) {
+		t.Errorf("rejection recorded as %q %v", events[0].Type, events[0].Data)
+	}
+	if events[1].Type != string(types.EventCommandExecuted) {
+		t.Errorf("failed execution recorded as %q", events[1].Type)
+	}
+	for _, event := range events {
+		if !types.EventCode(event.Type).IsRegistered() {
+			t.Errorf("event type %q is not in the catalog", event.Type)
+		}
+	}
+}
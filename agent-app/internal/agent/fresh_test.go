@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/collector"
+	"machine-monitor-agent/internal/types"
+)
+
+func TestCollectHardwareInfoFreshKeepsOtherSections(t *testing.T) {
+	a := NewAgent(&types.Config{})
+	a.collector = collector.NewCollector(time.Hour)
+	defer a.collector.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	system, err := a.collector.CollectSystemInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := a.collector.CollectHardwareInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// O refresh da WebUI recoleta só o hardware
+	hardware, err := a.CollectHardwareInfoFresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hardware == previous {
+		t.Fatal("fresh hardware served from the cache")
+	}
+	if cached, _ := a.collector.CollectHardwareInfo(ctx); cached != hardware {
+		t.Error("fresh hardware not stored in the cache")
+	}
+	if cached, _ := a.collector.CollectSystemInfo(ctx); cached != system {
+		t.Error("system_info recollected by a fresh hardware fetch")
+	}
+}
@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Nome dos arquivos do histórico: inventory-<horário UTC>.json.gz. O horário
+// tem largura fixa, então a ordem alfabética é a cronológica.
+const (
+	historyFilePrefix = "inventory-"
+	historyFileSuffix = ".json.gz"
+	historyTimeLayout = "20060102T150405.000000000Z"
+)
+
+// InventoryHistory histórico local dos inventários coletados, um arquivo
+// JSON com gzip por inventário. Guarda os maxEntries mais recentes e descarta
+// os mais antigos que maxAge. Arquivos corrompidos ficam de fora das
+// leituras sem derrubar as demais. Seguro para várias goroutines.
+type InventoryHistory struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int
+	maxAge     time.Duration
+}
+
+// NewInventoryHistory cria o histórico em dir, criando o diretório se preciso
+func NewInventoryHistory(dir string, maxEntries int, maxAge time.Duration) (*InventoryHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório do histórico: %w", err)
+	}
+	return &InventoryHistory{dir: dir, maxEntries: maxEntries, maxAge: maxAge}, nil
+}
+
+// Record grava o inventário com o checksum do seu JSON. O arquivo é escrito
+// em um temporário e renomeado, para uma queda no meio não deixar um
+// inventário pela metade no histórico.
+func (h *InventoryHistory) Record(inventory *types.Inventory) (types.InventoryHistoryEntry, error) {
+	data, err := json.Marshal(inventory)
+	if err != nil {
+		return types.InventoryHistoryEntry{}, fmt.Errorf("erro ao serializar inventário: %w", err)
+	}
+
+	timestamp := inventory.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	sum := sha256.Sum256(data)
+	entry := types.InventoryHistoryEntry{
+		Timestamp: timestamp.UTC(),
+		Checksum:  hex.EncodeToString(sum[:]),
+		SizeBytes: int64(len(data)),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	tmp, err := os.CreateTemp(h.dir, ".inventory-*.tmp")
+	if err != nil {
+		return types.InventoryHistoryEntry{}, fmt.Errorf("erro ao criar arquivo do histórico: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	err = json.NewEncoder(zw).Encode(historyFile{Checksum: entry.Checksum, Inventory: json.RawMessage(data)})
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return types.InventoryHistoryEntry{}, fmt.Errorf("erro ao gravar arquivo do histórico: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(h.dir, historyFileName(entry.Timestamp))); err != nil {
+		return types.InventoryHistoryEntry{}, fmt.Errorf("erro ao gravar arquivo do histórico: %w", err)
+	}
+	return entry, nil
+}
+
+// historyFile é o conteúdo de cada arquivo do histórico
+type historyFile struct {
+	Checksum  string          `json:"checksum"`
+	Inventory json.RawMessage `json:"inventory"`
+}
+
+// historyFileName monta o nome do arquivo de um inventário
+func historyFileName(timestamp time.Time) string {
+	return historyFilePrefix + timestamp.UTC().Format(historyTimeLayout) + historyFileSuffix
+}
+
+// historyFileTime lê o horário do nome do arquivo
+func historyFileTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, historyFilePrefix) || !strings.HasSuffix(name, historyFileSuffix) {
+		return time.Time{}, false
+	}
+	raw := strings.TrimSuffix(strings.TrimPrefix(name, historyFilePrefix), historyFileSuffix)
+	timestamp, err := time.Parse(historyTimeLayout, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return timestamp, true
+}
+
+// historyFileRef é um arquivo do histórico encontrado no diretório
+type historyFileRef struct {
+	name      string
+	timestamp time.Time
+}
+
+// files lista os arquivos do histórico, do mais novo para o mais antigo;
+// chamado com o mutex adquirido
+func (h *InventoryHistory) files() ([]historyFileRef, error) {
+	dirEntries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler diretório do histórico: %w", err)
+	}
+
+	files := make([]historyFileRef, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if timestamp, ok := historyFileTime(dirEntry.Name()); ok {
+			files = append(files, historyFileRef{name: dirEntry.Name(), timestamp: timestamp})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name > files[j].name })
+	return files, nil
+}
+
+// read abre um arquivo do histórico e confere o checksum do inventário
+func (h *InventoryHistory) read(file historyFileRef) (*types.InventoryHistoryEntry, error) {
+	f, err := os.Open(filepath.Join(h.dir, file.name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var content historyFile
+	if err := json.NewDecoder(zr).Decode(&content); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content.Inventory)
+	if checksum := hex.EncodeToString(sum[:]); checksum != content.Checksum {
+		return nil, fmt.Errorf("checksum %s não confere com o gravado %s", checksum, content.Checksum)
+	}
+
+	var inventory types.Inventory
+	if err := json.Unmarshal(content.Inventory, &inventory); err != nil {
+		return nil, err
+	}
+	return &types.InventoryHistoryEntry{
+		Timestamp: file.timestamp,
+		Checksum:  content.Checksum,
+		SizeBytes: int64(len(content.Inventory)),
+		Inventory: &inventory,
+	}, nil
+}
+
+// readValid lê os arquivos na ordem dada até keep retornar false; os
+// corrompidos são registrados no log e pulados
+func (h *InventoryHistory) readValid(files []historyFileRef, keep func(*types.InventoryHistoryEntry) bool) {
+	for _, file := range files {
+		entry, err := h.read(file)
+		if err != nil {
+			log.Warn().Err(err).Str("file", file.name).Msg("Arquivo do histórico de inventário corrompido, ignorando")
+			continue
+		}
+		if !keep(entry) {
+			return
+		}
+	}
+}
+
+// List retorna os limit inventários mais recentes (todos com limit <= 0),
+// do mais novo para o mais antigo, sem o conteúdo
+func (h *InventoryHistory) List(limit int) ([]types.InventoryHistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []types.InventoryHistoryEntry{}
+	h.readValid(files, func(entry *types.InventoryHistoryEntry) bool {
+		entry.Inventory = nil
+		entries = append(entries, *entry)
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries, nil
+}
+
+// At retorna o inventário mais recente coletado até at, ou nil se não houver
+func (h *InventoryHistory) At(at time.Time) (*types.InventoryHistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *types.InventoryHistoryEntry
+	for i, file := range files {
+		if !file.timestamp.After(at) {
+			h.readValid(files[i:], func(entry *types.InventoryHistoryEntry) bool {
+				found = entry
+				return false
+			})
+			break
+		}
+	}
+	return found, nil
+}
+
+// Prune apaga os arquivos além dos maxEntries mais recentes e os mais
+// antigos que maxAge em relação a now; retorna quantos foram apagados
+func (h *InventoryHistory) Prune(now time.Time) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	files, err := h.files()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := now.Add(-h.maxAge)
+	removed := 0
+	for i, file := range files {
+		if i < h.maxEntries && !file.timestamp.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.dir, file.name)); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("erro ao apagar arquivo do histórico: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
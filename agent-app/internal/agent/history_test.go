@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// historyInventory monta um inventário coletado em at
+func historyInventory(at time.Time, hostname string) *types.Inventory {
+	return &types.Inventory{
+		MachineID: "machine-1",
+		System:    types.SystemInfo{Hostname: hostname},
+		Timestamp: at,
+	}
+}
+
+// gzipHistoryFile comprime um conteúdo de arquivo do histórico escrito à mão
+func gzipHistoryFile(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestHistory(t *testing.T, maxEntries int, maxAge time.Duration) (*InventoryHistory, string) {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "inventory_history")
+	history, err := NewInventoryHistory(dir, maxEntries, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return history, dir
+}
+
+func TestInventoryHistoryRecordAndRead(t *testing.T) {
+	history, _ := newTestHistory(t, 10, 24*time.Hour)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	var recorded []types.InventoryHistoryEntry
+	for i, hostname := range []string{"host-a", "host-b", "host-c"} {
+		entry, err := history.Record(historyInventory(start.Add(time.Duration(i)*time.Hour), hostname))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entry.Checksum) != 64 || entry.SizeBytes == 0 {
+			t.Fatalf("entry = %+v", entry)
+		}
+		recorded = append(recorded, entry)
+	}
+
+	// Do mais novo para o mais antigo, sem o conteúdo
+	entries, err := history.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("list = %+v", entries)
+	}
+	for i, entry := range entries {
+		want := recorded[len(recorded)-1-i]
+		if !entry.Timestamp.Equal(want.Timestamp) || entry.Checksum != want.Checksum || entry.SizeBytes != want.SizeBytes || entry.Inventory != nil {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want)
+		}
+	}
+	if limited, err := history.List(2); err != nil || len(limited) != 2 || !limited[0].Timestamp.Equal(recorded[2].Timestamp) {
+		t.Fatalf("list(2) = %+v, %v", limited, err)
+	}
+
+	// At devolve o mais recente coletado até o horário pedido
+	entry, err := history.At(start.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil || entry.Inventory == nil || entry.Inventory.System.Hostname != "host-b" || entry.Checksum != recorded[1].Checksum {
+		t.Fatalf("at 10:30 = %+v", entry)
+	}
+	if entry, err := history.At(start.Add(2 * time.Hour)); err != nil || entry.Inventory.System.Hostname != "host-c" {
+		t.Fatalf("at 11:00 = %+v, %v", entry, err)
+	}
+	if entry, err := history.At(start.Add(-time.Second)); err != nil || entry != nil {
+		t.Fatalf("before the first inventory = %+v, %v", entry, err)
+	}
+}
+
+func TestInventoryHistoryPrune(t *testing.T) {
+	history, dir := newTestHistory(t, 3, 24*time.Hour)
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// Dois além do limite de idade e cinco recentes, dos quais só três ficam
+	for _, age := range []time.Duration{72 * time.Hour, 25 * time.Hour, 5 * time.Hour, 4 * time.Hour, 3 * time.Hour, 2 * time.Hour, time.Hour} {
+		if _, err := history.Record(historyInventory(now.Add(-age), "host")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Arquivos fora do padrão de nome não são tocados
+	other := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(other, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := history.Prune(now)
+	if err != nil || removed != 4 {
+		t.Fatalf("Prune() = %d, %v, want 4 removed", removed, err)
+	}
+	entries, err := history.List(0)
+	if err != nil || len(entries) != 3 || !entries[2].Timestamp.Equal(now.Add(-3*time.Hour)) {
+		t.Fatalf("after prune = %+v, %v", entries, err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Fatalf("unrelated file removed: %v", err)
+	}
+
+	// Só a idade: um dia depois nada é recente
+	removed, err = history.Prune(now.Add(48 * time.Hour))
+	if err != nil || removed != 3 {
+		t.Fatalf("Prune() a day later = %d, %v", removed, err)
+	}
+	if entries, _ := history.List(0); len(entries) != 0 {
+		t.Fatalf("history after the age cutoff = %+v", entries)
+	}
+}
+
+func TestInventoryHistorySkipsCorruptedFile(t *testing.T) {
+	history, dir := newTestHistory(t, 10, 24*time.Hour)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	for i, hostname := range []string{"host-a", "host-b", "host-c"} {
+		if _, err := history.Record(historyInventory(start.Add(time.Duration(i)*time.Hour), hostname)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// O arquivo do meio deixa de ser gzip válido
+	corrupted := filepath.Join(dir, historyFileName(start.Add(time.Hour)))
+	if err := os.WriteFile(corrupted, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := history.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !entries[0].Timestamp.Equal(start.Add(2*time.Hour)) || !entries[1].Timestamp.Equal(start) {
+		t.Fatalf("list with a corrupted file = %+v", entries)
+	}
+	// O limite conta só os arquivos válidos
+	if limited, err := history.List(2); err != nil || len(limited) != 2 {
+		t.Fatalf("list(2) = %+v, %v", limited, err)
+	}
+
+	// At cai para o anterior válido
+	entry, err := history.At(start.Add(90 * time.Minute))
+	if err != nil || entry == nil || entry.Inventory.System.Hostname != "host-a" {
+		t.Fatalf("at the corrupted inventory = %+v, %v", entry, err)
+	}
+
+	// Conteúdo adulterado não passa na conferência do checksum
+	if _, err := history.Record(historyInventory(start.Add(3*time.Hour), "host-d")); err != nil {
+		t.Fatal(err)
+	}
+	tampered := historyFileRef{name: historyFileName(start.Add(3 * time.Hour)), timestamp: start.Add(3 * time.Hour)}
+	if _, err := history.read(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tampered.name), gzipHistoryFile(t, `{"checksum":"00","inventory":{"machine_id":"x"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := history.read(tampered); err == nil {
+		t.Fatal("tampered inventory passed the checksum")
+	}
+	if entries, err := history.List(0); err != nil || len(entries) != 2 {
+		t.Fatalf("list with a tampered file = %+v, %v", entries, err)
+	}
+}
+
+func TestAgentInventoryHistoryDisabled(t *testing.T) {
+	a := &Agent{}
+	var coded *types.CodedError
+	if _, err := a.GetInventoryHistory(10); !errors.As(err, &coded) || coded.Code != types.ErrCodeHistoryDisabled {
+		t.Fatalf("GetInventoryHistory() = %v", err)
+	}
+
+	history, _ := newTestHistory(t, 10, 24*time.Hour)
+	a.history = history
+	_, err := a.GetInventoryAt(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	if !errors.As(err, &coded) || coded.Code != types.ErrCodeInventoryNotFound {
+		t.Fatalf("GetInventoryAt() on an empty history = %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// errNoOpener indica que nenhum programa para abrir o navegador foi
+// encontrado (servidores sem interface gráfica)
+var errNoOpener = errors.New("nenhum programa para abrir o navegador encontrado")
+
+// execRunner abstrai a busca e a execução do programa que abre o navegador
+type execRunner interface {
+	LookPath(file string) (string, error)
+	Start(path string, args ...string) error
+}
+
+// osExecRunner executa os programas de verdade
+type osExecRunner struct{}
+
+func (osExecRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (osExecRunner) Start(path string, args ...string) error {
+	cmd := exec.Command(path, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Recolhe o processo quando terminar
+	go cmd.Wait()
+	return nil
+}
+
+// openerCommands retorna, em ordem de preferência, os comandos que abrem url
+// no navegador padrão do sistema goos
+func openerCommands(goos, url string) [][]string {
+	switch goos {
+	case "darwin":
+		return [][]string{{"open", url}}
+	case "windows":
+		// O título vazio impede o start de tratar a URL como título da janela
+		return [][]string{
+			{"rundll32", "url.dll,FileProtocolHandler", url},
+			{"cmd", "/c", "start", "", url},
+		}
+	default:
+		// Linux e BSDs, em X11 ou Wayland
+		return [][]string{{"xdg-open", url}}
+	}
+}
+
+// openURL abre url com o primeiro opener de goos presente no sistema;
+// retorna errNoOpener se nenhum existir
+func openURL(runner execRunner, goos, url string) error {
+	for _, command := range openerCommands(goos, url) {
+		path, err := runner.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		if err := runner.Start(path, command[1:]...); err != nil {
+			return fmt.Errorf("erro ao executar %s: %w", command[0], err)
+		}
+		return nil
+	}
+	return errNoOpener
+}
+
+// hasOpener indica se há um opener para goos no sistema
+func hasOpener(runner execRunner, goos string) bool {
+	for _, command := range openerCommands(goos, "") {
+		if _, err := runner.LookPath(command[0]); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// fakeRunner simula o PATH com os programas em available e registra os
+// comandos iniciados
+type fakeRunner struct {
+	available map[string]bool
+	startErr  error
+	started   [][]string
+}
+
+func (r *fakeRunner) LookPath(file string) (string, error) {
+	if !r.available[file] {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	return "/usr/bin/" + file, nil
+}
+
+func (r *fakeRunner) Start(path string, args ...string) error {
+	r.started = append(r.started, append([]string{path}, args...))
+	return r.startErr
+}
+
+func TestOpenURLSelectsOpenerByOS(t *testing.T) {
+	const url = "http://localhost:8080"
+	tests := []struct {
+		name      string
+		goos      string
+		available []string
+		want      []string
+	}{
+		// launchd não define TERM_PROGRAM; ainda assim o macOS usa open
+		{"macOS", "darwin", []string{"open", "cmd"}, []string{"/usr/bin/open", url}},
+		{"linux on Wayland", "linux", []string{"xdg-open"}, []string{"/usr/bin/xdg-open", url}},
+		{"freebsd", "freebsd", []string{"xdg-open"}, []string{"/usr/bin/xdg-open", url}},
+		{"windows", "windows", []string{"rundll32", "cmd"}, []string{"/usr/bin/rundll32", "url.dll,FileProtocolHandler", url}},
+		{"windows without rundll32", "windows", []string{"cmd"}, []string{"/usr/bin/cmd", "/c", "start", "", url}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{available: map[string]bool{}}
+			for _, name := range tt.available {
+				runner.available[name] = true
+			}
+			if err := openURL(runner, tt.goos, url); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(runner.started, [][]string{tt.want}) {
+				t.Fatalf("started %v, want %v", runner.started, tt.want)
+			}
+			if !hasOpener(runner, tt.goos) {
+				t.Fatal("hasOpener = false with an opener on PATH")
+			}
+		})
+	}
+}
+
+func TestOpenURLWithoutOpener(t *testing.T) {
+	for _, goos := range []string{"darwin", "linux", "windows"} {
+		// Sem opener, nada é executado; o cmd de outra plataforma não conta
+		runner := &fakeRunner{available: map[string]bool{"cmd": goos != "windows", "open": goos == "linux"}}
+		if err := openURL(runner, goos, "http://localhost:8080"); !errors.Is(err, errNoOpener) {
+			t.Errorf("%s: error = %v", goos, err)
+		}
+		if len(runner.started) != 0 || hasOpener(runner, goos) {
+			t.Errorf("%s: started %v", goos, runner.started)
+		}
+	}
+
+	runner := &fakeRunner{available: map[string]bool{"xdg-open": true}, startErr: errors.New("exec format error")}
+	if err := openURL(runner, "linux", "http://localhost:8080"); err == nil || errors.Is(err, errNoOpener) {
+		t.Fatalf("start failure = %v", err)
+	}
+}
+
+func TestShowUI(t *testing.T) {
+	a := NewAgent(&types.Config{UI: types.UIConfig{WebUIPort: 8080, BindAddress: "0.0.0.0"}})
+	defer a.cancel()
+	runner := &fakeRunner{available: map[string]bool{"open": true, "xdg-open": true, "rundll32": true}}
+	a.opener = runner
+
+	a.showUI()
+	if len(runner.started) != 1 || runner.started[0][len(runner.started[0])-1] != "http://localhost:8080" {
+		t.Fatalf("started %v", runner.started)
+	}
+
+	// Sem opener só o log (e o tray, quando existe) recebem o endereço
+	headless := &fakeRunner{}
+	a.opener = headless
+	a.showUI()
+	if len(headless.started) != 0 {
+		t.Fatalf("headless started %v", headless.started)
+	}
+
+	for bind, want := range map[string]string{
+		"":          "http://localhost:8080",
+		"::":        "http://localhost:8080",
+		"127.0.0.1": "http://127.0.0.1:8080",
+		"::1":       "http://[::1]:8080",
+	} {
+		a.config.UI.BindAddress = bind
+		if got := a.webUIURL(); got != want {
+			t.Errorf("webUIURL with bind %q = %s, want %s", bind, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"machine-monitor-agent/internal/config"
+
+	"github.com/kardianos/service"
+	"github.com/rs/zerolog/log"
+)
+
+// RestartExitCode é o código de saída usado para pedir ao gerenciador de
+// serviços (launchd/systemd/SCM) que reinicie o agente (EX_TEMPFAIL)
+const RestartExitCode = 75
+
+// supervisedEnv força a detecção de supervisor ("1"/"0"), usado em testes e
+// em instalações com supervisores não detectados automaticamente
+const supervisedEnv = "MACHINE_MONITOR_SUPERVISED"
+
+// restartResultDelay é o tempo máximo aguardado para o resultado do comando
+// de restart ser enviado antes de encerrar o processo
+const restartResultDelay = 3 * time.Second
+
+// shutdownReason é gravado no diretório de dados antes de reiniciar
+type shutdownReason struct {
+	Reason     string    `json:"reason"`
+	CommandID  string    `json:"command_id,omitempty"`
+	Supervised bool      `json:"supervised"`
+	Supervisor string    `json:"supervisor,omitempty"`
+	PID        int       `json:"pid"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Gerenciadores de serviço reconhecidos por detectSupervisor
+const (
+	supervisorSystemd = "systemd"
+	supervisorLaunchd = "launchd"
+	supervisorSCM     = "scm"
+	// supervisorForced indica MACHINE_MONITOR_SUPERVISED=1 sem outro sinal
+	supervisorForced = "forced"
+)
+
+// currentSupervisor retorna o gerenciador de serviços que controla o processo
+// ("" em modo standalone)
+func currentSupervisor() string {
+	return detectSupervisor(os.Getenv, func() bool {
+		// Fora do Windows, service.Interactive só compara o PID do pai com 1
+		return runtime.GOOS == "windows" && !service.Interactive()
+	})
+}
+
+// detectSupervisor procura sinais explícitos de cada gerenciador; ter o PID 1
+// como pai não basta, pois processos órfãos também são adotados por ele
+func detectSupervisor(getenv func(string) string, windowsService func() bool) string {
+	override := getenv(supervisedEnv)
+	if override == "0" || override == "false" {
+		return ""
+	}
+
+	switch {
+	// systemd define INVOCATION_ID para unidades gerenciadas
+	case getenv("INVOCATION_ID") != "":
+		return supervisorSystemd
+	// launchd exporta o label do job em XPC_SERVICE_NAME; sessões do Terminal
+	// usam "0" ou o prefixo "application."
+	case launchdJob(getenv("XPC_SERVICE_NAME")):
+		return supervisorLaunchd
+	case windowsService():
+		return supervisorSCM
+	case override == "1" || override == "true":
+		return supervisorForced
+	}
+	return ""
+}
+
+// launchdJob indica se XPC_SERVICE_NAME é o label de um job do launchd
+func launchdJob(name string) bool {
+	return name != "" && name != "0" && !strings.HasPrefix(name, "application.")
+}
+
+// writeShutdownReason registra o motivo do encerramento para o próximo start
+func writeShutdownReason(dir string, reason shutdownReason) error {
+	data, err := json.MarshalIndent(reason, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar motivo do shutdown: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("erro ao criar diretório %s: %w", dir, err)
+	}
+	return os.WriteFile(filepath.Join(dir, "last_shutdown.json"), data, 0600)
+}
+
+// handleRestart trata o restart do agente.
+// Sob um supervisor, encerra com RestartExitCode e deixa o supervisor
+// reiniciar; em modo standalone, inicia uma nova instância e encerra a atual.
+func (a *Agent) handleRestart(commandID string) {
+	log.Info().Msg("Executando restart do agente...")
+
+	supervisor := currentSupervisor()
+
+	// Dá uma chance para o resultado do comando sair antes de desconectar
+	if a.wsClient != nil && !a.wsClient.FlushResults(restartResultDelay) {
+		log.Warn().Msg("Resultados pendentes não foram enviados antes do restart")
+	}
+
+	// Para o agente atual
+	a.Stop()
+
+	dataDir := config.GetDataDirectory()
+	if err := writeShutdownReason(dataDir, shutdownReason{
+		Reason:     "restart_agent",
+		CommandID:  commandID,
+		Supervised: supervisor != "",
+		Supervisor: supervisor,
+		PID:        os.Getpid(),
+		Timestamp:  time.Now(),
+	}); err != nil {
+		log.Warn().Err(err).Str("dir", dataDir).Msg("Erro ao gravar motivo do shutdown")
+	}
+
+	if supervisor != "" {
+		log.Info().Int("exit_code", RestartExitCode).Str("supervisor", supervisor).Msg("Encerrando para restart pelo gerenciador de serviços")
+		os.Exit(RestartExitCode)
+	}
+
+	// Reinicia o processo
+	executable, err := os.Executable()
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao obter caminho do executável")
+		return
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		log.Error().Err(err).Msg("Erro ao reiniciar processo")
+		return
+	}
+
+	log.Info().Msg("Novo processo iniciado, finalizando atual...")
+	os.Exit(0)
+}
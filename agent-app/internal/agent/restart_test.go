@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeEnv simula o ambiente de um supervisor
+func fakeEnv(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestDetectSupervisor(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		windowsService bool
+		want           string
+	}{
+		{name: "standalone terminal", want: ""},
+		{name: "systemd unit", env: map[string]string{"INVOCATION_ID": "0f1e2d"}, want: supervisorSystemd},
+		{name: "launchd job", env: map[string]string{"XPC_SERVICE_NAME": "com.machinemonitor.agent"}, want: supervisorLaunchd},
+		{name: "macOS terminal session", env: map[string]string{"XPC_SERVICE_NAME": "0"}, want: ""},
+		{name: "macOS app session", env: map[string]string{"XPC_SERVICE_NAME": "application.com.apple.Terminal.1234"}, want: ""},
+		{name: "windows service", windowsService: true, want: supervisorSCM},
+		{name: "forced on", env: map[string]string{supervisedEnv: "1"}, want: supervisorForced},
+		{name: "forced on keeps the detected manager", env: map[string]string{supervisedEnv: "true", "XPC_SERVICE_NAME": "com.machinemonitor.agent"}, want: supervisorLaunchd},
+		{name: "forced off under systemd", env: map[string]string{supervisedEnv: "0", "INVOCATION_ID": "x"}, want: ""},
+		{name: "forced off under the SCM", env: map[string]string{supervisedEnv: "false"}, windowsService: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectSupervisor(fakeEnv(tt.env), func() bool { return tt.windowsService })
+			if got != tt.want {
+				t.Fatalf("detectSupervisor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentSupervisorOverride(t *testing.T) {
+	t.Setenv("INVOCATION_ID", "")
+	t.Setenv("XPC_SERVICE_NAME", "")
+
+	t.Setenv(supervisedEnv, "0")
+	if supervisor := currentSupervisor(); supervisor != "" {
+		t.Fatalf("forced standalone detected %q", supervisor)
+	}
+	t.Setenv(supervisedEnv, "1")
+	if supervisor := currentSupervisor(); supervisor == "" {
+		t.Fatal("forced supervisor not detected")
+	}
+}
+
+func TestWriteShutdownReason(t *testing.T) {
+	for _, supervisor := range []string{supervisorLaunchd, ""} {
+		dir := filepath.Join(t.TempDir(), "data")
+		reason := shutdownReason{
+			Reason:     "restart_agent",
+			CommandID:  "cmd-1",
+			Supervised: supervisor != "",
+			Supervisor: supervisor,
+			PID:        os.Getpid(),
+			Timestamp:  time.Now(),
+		}
+		if err := writeShutdownReason(dir, reason); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, "last_shutdown.json")
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("last_shutdown.json mode = %o, want 600", info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored shutdownReason
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.CommandID != "cmd-1" || stored.Supervisor != supervisor || stored.Supervised != (supervisor != "") {
+			t.Errorf("shutdown reason = %+v", stored)
+		}
+	}
+}
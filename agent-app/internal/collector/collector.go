@@ -17,12 +17,22 @@ import (
 	"github.com/shirou/gopsutil/v3/process"
 )
 
+// Chaves do cache do coletor, usadas em InvalidateCache
+const (
+	CacheKeySystemInfo   = "system_info"
+	CacheKeyHardwareInfo = "hardware_info"
+	CacheKeyGPUInfo      = "gpu_info"
+)
+
 // Collector responsável por coletar informações do sistema
 type Collector struct {
 	mu          sync.RWMutex
 	cache       map[string]interface{}
 	cacheTTL    time.Duration
 	cacheExpiry map[string]time.Time
+	life        *lifecycle
+	// Amostra anterior dos contadores de I/O de disco, para as taxas
+	diskIO diskIOSample
 }
 
 // NewCollector cria uma nova instância do coletor
@@ -31,15 +41,38 @@ func NewCollector(cacheTTL time.Duration) *Collector {
 		cache:       make(map[string]interface{}),
 		cacheTTL:    cacheTTL,
 		cacheExpiry: make(map[string]time.Time),
+		life:        newLifecycle(),
 	}
 }
 
+// CollectOptions ajusta uma coleta
+type CollectOptions struct {
+	// Fresh ignora a entrada em cache da seção pedida, mas grava o resultado
+	// nela; as demais chaves (inclusive gpu_info dentro do hardware) não são
+	// tocadas
+	Fresh bool
+}
+
 // CollectSystemInfo coleta informações do sistema operacional
 func (c *Collector) CollectSystemInfo(ctx context.Context) (*types.SystemInfo, error) {
+	return c.CollectSystemInfoWith(ctx, CollectOptions{})
+}
+
+// CollectSystemInfoWith coleta informações do sistema operacional com as
+// opções informadas
+func (c *Collector) CollectSystemInfoWith(ctx context.Context, opts CollectOptions) (*types.SystemInfo, error) {
+	ctx, end, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
 	// Verifica cache
-	if cached := c.getFromCache("system_info"); cached != nil {
-		if sysInfo, ok := cached.(*types.SystemInfo); ok {
-			return sysInfo, nil
+	if !opts.Fresh {
+		if cached := c.getFromCache(CacheKeySystemInfo); cached != nil {
+			if sysInfo, ok := cached.(*types.SystemInfo); ok {
+				return sysInfo, nil
+			}
 		}
 	}
 
@@ -86,17 +119,31 @@ func (c *Collector) CollectSystemInfo(ctx context.Context) (*types.SystemInfo, e
 	}
 
 	// Armazena no cache
-	c.setCache("system_info", sysInfo)
+	c.setCache(CacheKeySystemInfo, sysInfo)
 
 	return sysInfo, nil
 }
 
 // CollectHardwareInfo coleta informações de hardware
 func (c *Collector) CollectHardwareInfo(ctx context.Context) (*types.HardwareInfo, error) {
+	return c.CollectHardwareInfoWith(ctx, CollectOptions{})
+}
+
+// CollectHardwareInfoWith coleta informações de hardware com as opções
+// informadas
+func (c *Collector) CollectHardwareInfoWith(ctx context.Context, opts CollectOptions) (*types.HardwareInfo, error) {
+	ctx, end, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
 	// Verifica cache
-	if cached := c.getFromCache("hardware_info"); cached != nil {
-		if hwInfo, ok := cached.(*types.HardwareInfo); ok {
-			return hwInfo, nil
+	if !opts.Fresh {
+		if cached := c.getFromCache(CacheKeyHardwareInfo); cached != nil {
+			if hwInfo, ok := cached.(*types.HardwareInfo); ok {
+				return hwInfo, nil
+			}
 		}
 	}
 
@@ -143,6 +190,18 @@ func (c *Collector) CollectHardwareInfo(ctx context.Context) (*types.HardwareInf
 		}
 	}()
 
+	// Coleta I/O por dispositivo; a falha não impede o restante do hardware
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		diskIO, err := c.collectDiskIO(ctx)
+		if err == nil {
+			mu.Lock()
+			hwInfo.DiskIO = diskIO
+			mu.Unlock()
+		}
+	}()
+
 	// Coleta informações de rede
 	wg.Add(1)
 	go func() {
@@ -155,11 +214,23 @@ func (c *Collector) CollectHardwareInfo(ctx context.Context) (*types.HardwareInf
 		}
 	}()
 
+	// Coleta GPUs; a falha não impede o restante do hardware
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gpus, err := c.collectGPUInfo(ctx)
+		if err == nil {
+			mu.Lock()
+			hwInfo.GPUs = gpus
+			mu.Unlock()
+		}
+	}()
+
 	// Aguarda todas as goroutines terminarem
 	wg.Wait()
 
 	// Armazena no cache
-	c.setCache("hardware_info", hwInfo)
+	c.setCache(CacheKeyHardwareInfo, hwInfo)
 
 	return hwInfo, nil
 }
@@ -212,6 +283,33 @@ func (c *Collector) collectCPUInfo(ctx context.Context) (*types.CPUInfo, error)
 	}, nil
 }
 
+// SampleUsage lê o uso de CPU (desde a amostra anterior, sem esperar) e de
+// memória, sem passar pelo cache
+func (c *Collector) SampleUsage(ctx context.Context) (*types.UsageSample, error) {
+	ctx, end, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	vmStat, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter informações de memória: %w", err)
+	}
+
+	sample := &types.UsageSample{
+		MemoryTotal:     vmStat.Total,
+		MemoryUsed:      vmStat.Used,
+		MemoryAvailable: vmStat.Available,
+		MemoryPercent:   vmStat.UsedPercent,
+		Timestamp:       time.Now(),
+	}
+	if cpuPercent, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(cpuPercent) > 0 {
+		sample.CPUPercent = cpuPercent[0]
+	}
+	return sample, nil
+}
+
 // collectMemoryInfo coleta informações de memória
 func (c *Collector) collectMemoryInfo(ctx context.Context) (*types.MemoryInfo, error) {
 	vmStat, err := mem.VirtualMemoryWithContext(ctx)
@@ -317,6 +415,12 @@ func (c *Collector) collectNetworkInfo(ctx context.Context) ([]types.NetworkInfo
 
 // CollectInventory coleta inventário completo
 func (c *Collector) CollectInventory(ctx context.Context, machineID string) (*types.Inventory, error) {
+	ctx, end, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var systemInfo *types.SystemInfo
@@ -415,7 +519,8 @@ func (c *Collector) setCache(key string, value interface{}) {
 	c.cacheExpiry[key] = time.Now().Add(c.cacheTTL)
 }
 
-// ClearCache limpa o cache
+// ClearCache limpa o cache inteiro.
+// Prefira InvalidateCache para atualizar apenas as seções necessárias.
 func (c *Collector) ClearCache() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -423,3 +528,43 @@ func (c *Collector) ClearCache() {
 	c.cache = make(map[string]interface{})
 	c.cacheExpiry = make(map[string]time.Time)
 }
+
+// InvalidateCache remove apenas as chaves informadas do cache
+func (c *Collector) InvalidateCache(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.cache, key)
+		delete(c.cacheExpiry, key)
+	}
+}
+
+// GetCacheStats retorna estatísticas do cache, com a idade de cada chave
+func (c *Collector) GetCacheStats() map[string]interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	keys := make(map[string]interface{}, len(c.cacheExpiry))
+	var expired int
+	for key, expiry := range c.cacheExpiry {
+		// O item foi armazenado um TTL antes da expiração
+		age := now.Sub(expiry.Add(-c.cacheTTL))
+		isExpired := now.After(expiry)
+		if isExpired {
+			expired++
+		}
+		keys[key] = map[string]interface{}{
+			"age_seconds": age.Seconds(),
+			"ttl_seconds": c.cacheTTL.Seconds(),
+			"expired":     isExpired,
+		}
+	}
+
+	return map[string]interface{}{
+		"items":   len(c.cache),
+		"expired": expired,
+		"keys":    keys,
+	}
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestInvalidateCacheKeepsOtherSections(t *testing.T) {
+	c := NewCollector(time.Hour)
+	defer c.Close()
+
+	system := &types.SystemInfo{Hostname: "cached"}
+	c.setCache(CacheKeySystemInfo, system)
+	c.setCache(CacheKeyHardwareInfo, &types.HardwareInfo{})
+	c.setCache(CacheKeyGPUInfo, []types.GPUInfo{{Model: "cached"}})
+
+	c.InvalidateCache(CacheKeyHardwareInfo)
+
+	if c.getFromCache(CacheKeyHardwareInfo) != nil {
+		t.Error("hardware_info still cached after invalidation")
+	}
+	if c.getFromCache(CacheKeySystemInfo) != system {
+		t.Error("system_info evicted by a hardware invalidation")
+	}
+	if c.getFromCache(CacheKeyGPUInfo) == nil {
+		t.Error("gpu_info evicted by a hardware invalidation")
+	}
+}
+
+func TestFreshHardwareKeepsOtherSections(t *testing.T) {
+	c := NewCollector(time.Hour)
+	defer c.Close()
+
+	system := &types.SystemInfo{Hostname: "cached"}
+	gpus := []types.GPUInfo{{Model: "Cached GPU", Vendor: "Example"}}
+	c.setCache(CacheKeySystemInfo, system)
+	c.setCache(CacheKeyGPUInfo, gpus)
+	stale := &types.HardwareInfo{}
+	c.setCache(CacheKeyHardwareInfo, stale)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hardware, err := c.CollectHardwareInfoWith(ctx, CollectOptions{Fresh: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hardware == stale {
+		t.Fatal("fresh collection served the cached hardware")
+	}
+	if cached := c.getFromCache(CacheKeyHardwareInfo); cached != hardware {
+		t.Error("fresh collection not stored in the cache")
+	}
+	if c.getFromCache(CacheKeySystemInfo) != system {
+		t.Error("system_info evicted by a fresh hardware collection")
+	}
+	if !reflect.DeepEqual(c.getFromCache(CacheKeyGPUInfo), gpus) || !reflect.DeepEqual(hardware.GPUs, gpus) {
+		t.Errorf("gpu_info re-collected by a fresh hardware collection: %+v", hardware.GPUs)
+	}
+}
+
+func TestFreshSystemInfoKeepsOtherSections(t *testing.T) {
+	c := NewCollector(time.Hour)
+	defer c.Close()
+
+	hardware := &types.HardwareInfo{}
+	posture := &types.SecurityPosture{}
+	stale := &types.SystemInfo{Hostname: "cached"}
+	c.setCache(CacheKeyHardwareInfo, hardware)
+	c.setCache(CacheKeySecurityPosture, posture)
+	c.setCache(CacheKeySystemInfo, stale)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Sem Fresh, a entrada em cache é servida
+	if system, err := c.CollectSystemInfo(ctx); err != nil || system != stale {
+		t.Fatalf("cached collection = %+v, %v", system, err)
+	}
+
+	system, err := c.CollectSystemInfoWith(ctx, CollectOptions{Fresh: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system == stale || system.Hostname == "" {
+		t.Fatalf("fresh collection = %+v", system)
+	}
+	if c.getFromCache(CacheKeySystemInfo) != system {
+		t.Error("fresh collection not stored in the cache")
+	}
+	if c.getFromCache(CacheKeyHardwareInfo) != hardware || c.getFromCache(CacheKeySecurityPosture) != posture {
+		t.Error("other sections evicted by a fresh system collection")
+	}
+
+	// A próxima coleta comum usa o resultado novo
+	if cached, err := c.CollectSystemInfo(ctx); err != nil || cached != system {
+		t.Fatalf("collection after the refresh = %+v, %v", cached, err)
+	}
+}
+
+func TestGetCacheStatsPerKeyAge(t *testing.T) {
+	c := NewCollector(time.Minute)
+	defer c.Close()
+
+	c.setCache(CacheKeySystemInfo, &types.SystemInfo{})
+	c.mu.Lock()
+	// Armazenado há 90 segundos, além do TTL de um minuto
+	c.cacheExpiry[CacheKeyHardwareInfo] = time.Now().Add(-30 * time.Second)
+	c.cache[CacheKeyHardwareInfo] = &types.HardwareInfo{}
+	c.mu.Unlock()
+
+	stats := c.GetCacheStats()
+	keys, ok := stats["keys"].(map[string]interface{})
+	if !ok || len(keys) != 2 {
+		t.Fatalf("per-key stats missing: %v", stats)
+	}
+
+	system, _ := keys[CacheKeySystemInfo].(map[string]interface{})
+	if age, _ := system["age_seconds"].(float64); age < 0 || age > 5 || system["expired"] != false {
+		t.Errorf("system_info stats = %v", system)
+	}
+	hardware, _ := keys[CacheKeyHardwareInfo].(map[string]interface{})
+	if age, _ := hardware["age_seconds"].(float64); age < 89 || age > 95 || hardware["expired"] != true {
+		t.Errorf("hardware_info stats = %v", hardware)
+	}
+	if stats["expired"] != 1 {
+		t.Errorf("expired count %v, want 1", stats["expired"])
+	}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// pseudoDiskPrefixes são dispositivos sem disco por trás (Linux): loop de
+// imagens, RAM disks, zram e disquete
+var pseudoDiskPrefixes = []string{"loop", "ram", "zram", "fd"}
+
+// isPseudoDisk indica se o dispositivo deve ficar fora das estatísticas de I/O
+func isPseudoDisk(name string) bool {
+	for _, prefix := range pseudoDiskPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// diskIOSample é a amostra anterior dos contadores de I/O, para as taxas
+type diskIOSample struct {
+	mu      sync.Mutex
+	at      time.Time
+	devices map[string]disk.IOCountersStat
+}
+
+// rates troca a amostra e monta as estatísticas de cada dispositivo com as
+// taxas desde a anterior. Dispositivos novos (ex.: pendrive conectado) ou com
+// contadores que voltaram (reboot, dispositivo reconectado) ficam sem taxa
+// nesta amostra; os que sumiram saem da amostra e, se voltarem, recomeçam.
+func (s *diskIOSample) rates(now time.Time, counters map[string]disk.IOCountersStat) []types.DiskIOInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := now.Sub(s.at).Seconds()
+	if s.at.IsZero() {
+		elapsed = 0
+	}
+
+	infos := make([]types.DiskIOInfo, 0, len(counters))
+	for name, stat := range counters {
+		info := types.DiskIOInfo{
+			Device:     name,
+			ReadBytes:  stat.ReadBytes,
+			WriteBytes: stat.WriteBytes,
+			ReadCount:  stat.ReadCount,
+			WriteCount: stat.WriteCount,
+		}
+		if previous, ok := s.devices[name]; ok && elapsed > 0 && !counterWentBack(previous, stat) {
+			info.ReadBytesPerSec = perSecond(stat.ReadBytes-previous.ReadBytes, elapsed)
+			info.WriteBytesPerSec = perSecond(stat.WriteBytes-previous.WriteBytes, elapsed)
+			info.ReadOpsPerSec = perSecond(stat.ReadCount-previous.ReadCount, elapsed)
+			info.WriteOpsPerSec = perSecond(stat.WriteCount-previous.WriteCount, elapsed)
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Device < infos[j].Device })
+
+	s.at = now
+	s.devices = counters
+	return infos
+}
+
+// counterWentBack indica se algum contador é menor que o da amostra anterior
+func counterWentBack(previous, current disk.IOCountersStat) bool {
+	return current.ReadBytes < previous.ReadBytes || current.WriteBytes < previous.WriteBytes ||
+		current.ReadCount < previous.ReadCount || current.WriteCount < previous.WriteCount
+}
+
+func perSecond(delta uint64, elapsed float64) *float64 {
+	rate := math.Round(float64(delta)/elapsed*100) / 100
+	return &rate
+}
+
+// collectDiskIO lê os contadores de I/O por dispositivo, sem os
+// pseudo-dispositivos, e calcula as taxas desde a coleta anterior
+func (c *Collector) collectDiskIO(ctx context.Context) ([]types.DiskIOInfo, error) {
+	stats, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter estatísticas de I/O de disco: %w", err)
+	}
+
+	counters := make(map[string]disk.IOCountersStat, len(stats))
+	for name, stat := range stats {
+		if !isPseudoDisk(name) {
+			counters[name] = stat
+		}
+	}
+	return c.diskIO.rates(time.Now(), counters), nil
+}
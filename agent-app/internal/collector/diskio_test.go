@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// ioSample monta os contadores do gopsutil por dispositivo:
+// bytes lidos, bytes escritos, leituras e escritas
+func ioSample(samples map[string][4]uint64) map[string]disk.IOCountersStat {
+	counters := make(map[string]disk.IOCountersStat, len(samples))
+	for name, sample := range samples {
+		counters[name] = disk.IOCountersStat{
+			Name:       name,
+			ReadBytes:  sample[0],
+			WriteBytes: sample[1],
+			ReadCount:  sample[2],
+			WriteCount: sample[3],
+		}
+	}
+	return counters
+}
+
+// hasRates indica se o dispositivo saiu com alguma taxa
+func hasRates(info types.DiskIOInfo) bool {
+	return info.ReadBytesPerSec != nil || info.WriteBytesPerSec != nil ||
+		info.ReadOpsPerSec != nil || info.WriteOpsPerSec != nil
+}
+
+func TestDiskIORates(t *testing.T) {
+	var sample diskIOSample
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	// Primeira amostra: só o ponto de partida
+	first := sample.rates(start, ioSample(map[string][4]uint64{
+		"sda":     {1_000, 2_000, 10, 20},
+		"nvme0n1": {0, 0, 0, 0},
+		"sdb":     {500_000, 0, 50, 0},
+	}))
+	if len(first) != 3 || first[0].Device != "nvme0n1" || first[1].Device != "sda" || first[2].Device != "sdb" {
+		t.Fatalf("first sample = %+v", first)
+	}
+	for _, info := range first {
+		if hasRates(info) {
+			t.Fatalf("%s has rates on the first sample: %+v", info.Device, info)
+		}
+	}
+
+	// Segunda amostra 10 s depois: o pendrive sdb foi removido, nvme0n1
+	// teve os contadores zerados e sdc apareceu
+	second := sample.rates(start.Add(10*time.Second), ioSample(map[string][4]uint64{
+		"sda":     {1_001_000, 52_000, 210, 45},
+		"nvme0n1": {0, 0, 0, 0},
+		"sdc":     {4_096, 0, 1, 0},
+	}))
+	if len(second) != 3 || second[0].Device != "nvme0n1" || second[1].Device != "sda" || second[2].Device != "sdc" {
+		t.Fatalf("second sample = %+v", second)
+	}
+	sda := second[1]
+	if !hasRates(sda) || *sda.ReadBytesPerSec != 100_000 || *sda.WriteBytesPerSec != 5_000 ||
+		*sda.ReadOpsPerSec != 20 || *sda.WriteOpsPerSec != 2.5 {
+		t.Fatalf("sda = %+v", sda)
+	}
+	if sda.ReadBytes != 1_001_000 || sda.WriteCount != 45 {
+		t.Fatalf("sda totals = %+v", sda)
+	}
+	if nvme := second[0]; !hasRates(nvme) || *nvme.ReadBytesPerSec != 0 || *nvme.WriteOpsPerSec != 0 {
+		t.Fatalf("idle nvme0n1 = %+v", nvme)
+	}
+	if hasRates(second[2]) {
+		t.Fatalf("new device sdc = %+v", second[2])
+	}
+
+	// sdb volta e sda tem os contadores zerados (reboot): nenhum dos dois tem
+	// taxa nesta amostra
+	third := sample.rates(start.Add(13*time.Second), ioSample(map[string][4]uint64{
+		"sda": {100, 0, 1, 0},
+		"sdb": {600_000, 0, 60, 0},
+		"sdc": {8_192, 1_000, 2, 3},
+	}))
+	if len(third) != 3 {
+		t.Fatalf("third sample = %+v", third)
+	}
+	if hasRates(third[0]) || hasRates(third[1]) {
+		t.Fatalf("reset sda or reconnected sdb has rates: %+v", third)
+	}
+	if sdc := third[2]; !hasRates(sdc) || *sdc.ReadBytesPerSec != 1_365.33 || *sdc.WriteOpsPerSec != 1 {
+		t.Fatalf("sdc = %+v", sdc)
+	}
+
+	// A amostra do reset é o novo ponto de partida
+	fourth := sample.rates(start.Add(15*time.Second), ioSample(map[string][4]uint64{
+		"sda": {2_100, 0, 3, 0},
+		"sdb": {600_000, 0, 60, 0},
+	}))
+	if len(fourth) != 2 || *fourth[0].ReadBytesPerSec != 1_000 || *fourth[1].ReadBytesPerSec != 0 {
+		t.Fatalf("fourth sample = %+v", fourth)
+	}
+
+	// Duas coletas no mesmo instante não geram taxa
+	if same := sample.rates(start.Add(15*time.Second), ioSample(map[string][4]uint64{"sda": {3_000, 0, 4, 0}})); hasRates(same[0]) {
+		t.Fatalf("zero interval sample = %+v", same[0])
+	}
+}
+
+func TestIsPseudoDisk(t *testing.T) {
+	for _, name := range []string{"loop0", "ram1", "zram0", "fd0"} {
+		if !isPseudoDisk(name) {
+			t.Errorf("%s not treated as a pseudo disk", name)
+		}
+	}
+	for _, name := range []string{"sda", "nvme0n1", "mmcblk0", "disk0", "C:"} {
+		if isPseudoDisk(name) {
+			t.Errorf("%s treated as a pseudo disk", name)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// commandOutput executa um comando externo e retorna a saída padrão
+func commandOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// collectGPUInfo lista as GPUs pela ferramenta da plataforma. Máquinas sem
+// GPU ou sem a ferramenta retornam lista vazia. O resultado fica em cache
+// separado, para que a atualização forçada do hardware não repita os
+// comandos, que são lentos.
+func (c *Collector) collectGPUInfo(ctx context.Context) ([]types.GPUInfo, error) {
+	if cached, ok := c.getFromCache(CacheKeyGPUInfo).([]types.GPUInfo); ok {
+		return cached, nil
+	}
+
+	var gpus []types.GPUInfo
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		gpus, err = collectDarwinGPUs(ctx)
+	case "linux":
+		gpus, err = collectLinuxGPUs(ctx)
+	case "windows":
+		gpus, err = collectWindowsGPUs(ctx)
+	default:
+		gpus = []types.GPUInfo{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.setCache(CacheKeyGPUInfo, gpus)
+	return gpus, nil
+}
+
+// collectDarwinGPUs consulta o system_profiler
+func collectDarwinGPUs(ctx context.Context) ([]types.GPUInfo, error) {
+	output, err := commandOutput(ctx, "system_profiler", "SPDisplaysDataType", "-json")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar system_profiler: %w", err)
+	}
+	return parseSPDisplays(output)
+}
+
+// collectLinuxGPUs consulta o lspci e, havendo placa NVIDIA (ou sem lspci),
+// o nvidia-smi; ferramentas ausentes não são erro
+func collectLinuxGPUs(ctx context.Context) ([]types.GPUInfo, error) {
+	gpus := []types.GPUInfo{}
+	queryNvidia := true
+
+	output, err := commandOutput(ctx, "lspci", "-vmmk")
+	switch {
+	case err == nil:
+		gpus = parseLspci(output)
+		queryNvidia = false
+		for _, gpu := range gpus {
+			if strings.Contains(strings.ToLower(gpu.Vendor), "nvidia") {
+				queryNvidia = true
+				break
+			}
+		}
+	case !errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("erro ao executar lspci: %w", err)
+	}
+
+	if queryNvidia {
+		if output, err := commandOutput(ctx, "nvidia-smi", nvidiaSMIArgs...); err == nil {
+			nvidia, err := parseNvidiaSMI(output)
+			if err != nil {
+				return nil, err
+			}
+			gpus = mergeNvidiaSMI(gpus, nvidia)
+		}
+	}
+	return gpus, nil
+}
+
+// collectWindowsGPUs consulta o Win32_VideoController pelo wmic
+func collectWindowsGPUs(ctx context.Context) ([]types.GPUInfo, error) {
+	output, err := commandOutput(ctx, "wmic", wmicVideoControllerArgs...)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return []types.GPUInfo{}, nil
+		}
+		return nil, fmt.Errorf("erro ao executar wmic: %w", err)
+	}
+	return parseWmicVideoController(output)
+}
+
+// spDisplaysVendor casa o fabricante em "sppci_vendor_Apple" ou
+// "NVIDIA (0x10de)"
+var spDisplaysVendor = regexp.MustCompile(`^(?:sppci_vendor_)?(.*?)(?:\s*\(0x[0-9a-fA-F]+\))?$`)
+
+// parseSPDisplays interpreta `system_profiler SPDisplaysDataType -json`
+func parseSPDisplays(data []byte) ([]types.GPUInfo, error) {
+	var result struct {
+		SPDisplaysDataType []map[string]interface{} `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("erro ao interpretar saída do system_profiler: %w", err)
+	}
+
+	gpus := []types.GPUInfo{}
+	for _, item := range result.SPDisplaysDataType {
+		field := func(key string) string {
+			value, _ := item[key].(string)
+			return strings.TrimSpace(value)
+		}
+
+		gpu := types.GPUInfo{Model: field("sppci_model")}
+		if gpu.Model == "" {
+			gpu.Model = field("_name")
+		}
+		if gpu.Model == "" {
+			continue
+		}
+		if match := spDisplaysVendor.FindStringSubmatch(field("sppci_vendor")); match != nil {
+			gpu.Vendor = match[1]
+		}
+		// spdisplays_vram só existe em GPU dedicada; a memória compartilhada
+		// (spdisplays_vram_shared) não é memória própria
+		gpu.VRAM = parseMemorySize(field("spdisplays_vram"))
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// parseMemorySize converte "1536 MB" ou "8 GB" em bytes; zero se não entender
+func parseMemorySize(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0
+	}
+	amount, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.ToUpper(fields[1]) {
+	case "MB":
+		return amount << 20
+	case "GB":
+		return amount << 30
+	}
+	return 0
+}
+
+// lspciDisplayClasses são as classes PCI de adaptadores de vídeo
+var lspciDisplayClasses = map[string]bool{
+	"VGA compatible controller": true,
+	"3D controller":             true,
+	"Display controller":        true,
+}
+
+// parseLspci interpreta `lspci -vmmk`: um registro "Chave:\tvalor" por
+// dispositivo, separados por linha em branco. Só adaptadores de vídeo entram.
+func parseLspci(output []byte) []types.GPUInfo {
+	gpus := []types.GPUInfo{}
+	record := map[string]string{}
+	flush := func() {
+		if lspciDisplayClasses[record["Class"]] && record["Device"] != "" {
+			gpus = append(gpus, types.GPUInfo{
+				Model:  record["Device"],
+				Vendor: record["Vendor"],
+				Driver: record["Driver"],
+				BusID:  record["Slot"],
+			})
+		}
+		record = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Chaves repetidas (ex.: Module) mantêm a primeira ocorrência
+		if _, seen := record[key]; !seen {
+			record[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return gpus
+}
+
+// nvidiaSMIArgs consulta as GPUs NVIDIA; memory.total vem em MiB
+var nvidiaSMIArgs = []string{
+	"--query-gpu=name,memory.total,driver_version,pci.bus_id",
+	"--format=csv,noheader,nounits",
+}
+
+// parseNvidiaSMI interpreta a saída CSV do nvidia-smi
+func parseNvidiaSMI(output []byte) ([]types.GPUInfo, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	gpus := []types.GPUInfo{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("erro ao interpretar saída do nvidia-smi: %w", err)
+		}
+		if len(row) < 4 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		gpu := types.GPUInfo{
+			Model:         strings.TrimSpace(row[0]),
+			Vendor:        "NVIDIA Corporation",
+			DriverVersion: strings.TrimSpace(row[2]),
+			BusID:         normalizePCIAddress(row[3]),
+		}
+		if mib, err := strconv.ParseUint(strings.TrimSpace(row[1]), 10, 64); err == nil {
+			gpu.VRAM = mib << 20
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// normalizePCIAddress reduz "00000000:01:00.0" ao formato do lspci
+// ("01:00.0"); o domínio só é mantido quando não é zero
+func normalizePCIAddress(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	parts := strings.Split(address, ":")
+	if len(parts) == 3 && strings.Trim(parts[0], "0") == "" {
+		return parts[1] + ":" + parts[2]
+	}
+	return address
+}
+
+// mergeNvidiaSMI completa as GPUs do lspci com VRAM e versão do driver do
+// nvidia-smi, casando pelo endereço PCI; as que não casam são acrescentadas
+func mergeNvidiaSMI(gpus, nvidia []types.GPUInfo) []types.GPUInfo {
+	for _, extra := range nvidia {
+		merged := false
+		for i := range gpus {
+			if extra.BusID != "" && normalizePCIAddress(gpus[i].BusID) == extra.BusID {
+				gpus[i].Model = extra.Model
+				gpus[i].VRAM = extra.VRAM
+				gpus[i].DriverVersion = extra.DriverVersion
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			gpus = append(gpus, extra)
+		}
+	}
+	return gpus
+}
+
+// wmicVideoControllerArgs lista os adaptadores do Windows em CSV
+var wmicVideoControllerArgs = []string{
+	"path", "win32_VideoController",
+	"get", "Name,AdapterCompatibility,AdapterRAM,DriverVersion",
+	"/format:csv",
+}
+
+// parseWmicVideoController interpreta `wmic ... /format:csv`. As colunas
+// vêm em ordem alfabética depois de Node e são localizadas pelo cabeçalho;
+// o AdapterRAM é de 32 bits e satura em 4 GiB.
+func parseWmicVideoController(output []byte) ([]types.GPUInfo, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+
+	var header map[string]int
+	gpus := []types.GPUInfo{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("erro ao interpretar saída do wmic: %w", err)
+		}
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		if len(row) == 1 && row[0] == "" {
+			continue
+		}
+		if header == nil {
+			header = make(map[string]int, len(row))
+			for i, name := range row {
+				header[name] = i
+			}
+			continue
+		}
+		column := func(name string) string {
+			if i, ok := header[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		gpu := types.GPUInfo{
+			Model:         column("Name"),
+			Vendor:        column("AdapterCompatibility"),
+			DriverVersion: column("DriverVersion"),
+		}
+		if gpu.Model == "" {
+			continue
+		}
+		if ram, err := strconv.ParseUint(column("AdapterRAM"), 10, 64); err == nil {
+			gpu.VRAM = ram
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
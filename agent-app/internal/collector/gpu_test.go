@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// readFixture lê uma saída de comando capturada em testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseGPUFixtures(t *testing.T) {
+	intel := types.GPUInfo{Model: "CoffeeLake-H GT2 [UHD Graphics 630]", Vendor: "Intel Corporation", Driver: "i915", BusID: "00:02.0"}
+	tests := []struct {
+		fixture string
+		parse   func([]byte) ([]types.GPUInfo, error)
+		want    []types.GPUInfo
+	}{
+		{
+			fixture: "spdisplays_apple_silicon.json",
+			parse:   parseSPDisplays,
+			want:    []types.GPUInfo{{Model: "Apple M2 Pro", Vendor: "Apple"}},
+		},
+		{
+			fixture: "spdisplays_intel_dual.json",
+			parse:   parseSPDisplays,
+			want: []types.GPUInfo{
+				{Model: "Intel UHD Graphics 630", Vendor: "Intel"},
+				{Model: "AMD Radeon Pro 5500M", Vendor: "AMD", VRAM: 8 << 30},
+			},
+		},
+		{
+			fixture: "lspci_vmmk.txt",
+			parse:   func(output []byte) ([]types.GPUInfo, error) { return parseLspci(output), nil },
+			want: []types.GPUInfo{
+				intel,
+				{Model: "TU117M [GeForce GTX 1650 Mobile / Max-Q]", Vendor: "NVIDIA Corporation", Driver: "nvidia", BusID: "01:00.0"},
+			},
+		},
+		{
+			fixture: "nvidia_smi.csv",
+			parse:   parseNvidiaSMI,
+			want: []types.GPUInfo{{
+				Model: "NVIDIA GeForce GTX 1650 with Max-Q Design", Vendor: "NVIDIA Corporation",
+				VRAM: 4096 << 20, DriverVersion: "535.129.03", BusID: "01:00.0",
+			}},
+		},
+		{
+			fixture: "wmic_videocontroller.csv",
+			parse:   parseWmicVideoController,
+			want: []types.GPUInfo{
+				{Model: "Intel(R) UHD Graphics 630", Vendor: "Intel Corporation", VRAM: 1 << 30, DriverVersion: "31.0.101.2125"},
+				{Model: "NVIDIA GeForce GTX 1650", Vendor: "NVIDIA", VRAM: 4293918720, DriverVersion: "31.0.15.3713"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			gpus, err := tt.parse(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gpus, tt.want) {
+				t.Fatalf("parsed %+v, want %+v", gpus, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeNvidiaSMI(t *testing.T) {
+	nvidia, err := parseNvidiaSMI(readFixture(t, "nvidia_smi.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpus := mergeNvidiaSMI(parseLspci(readFixture(t, "lspci_vmmk.txt")), nvidia)
+
+	// A placa casada pelo endereço mantém o driver do lspci e ganha VRAM e versão
+	if len(gpus) != 2 || gpus[1].Driver != "nvidia" || gpus[1].VRAM != 4096<<20 || gpus[1].DriverVersion != "535.129.03" {
+		t.Fatalf("merged GPUs = %+v", gpus)
+	}
+	if gpus := parseLspci(nil); gpus == nil || len(gpus) != 0 {
+		t.Fatalf("no adapters = %v; want an empty list", gpus)
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed é retornado pelas coletas depois de Close
+var ErrClosed = errors.New("coletor encerrado")
+
+// lifecycle controla o tempo de vida do Collector: Close cancela e aguarda as
+// coletas em andamento e descarta o cache
+type lifecycle struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stop    func() bool // desfaz o vínculo com o contexto de Start
+	started bool
+	closed  bool
+
+	inflight sync.WaitGroup
+}
+
+// newLifecycle cria o controle com um contexto próprio, cancelado em Close
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Start vincula o coletor a ctx: quando ctx termina, as coletas em andamento
+// são canceladas. Chamadas repetidas não têm efeito; depois de Close retorna
+// ErrClosed.
+func (c *Collector) Start(ctx context.Context) error {
+	l := c.life
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if l.started {
+		return nil
+	}
+	l.started = true
+	l.stop = context.AfterFunc(ctx, l.cancel)
+	return nil
+}
+
+// begin registra uma coleta em andamento e retorna ctx cancelado também em
+// Close; end deve ser chamado ao terminar
+func (c *Collector) begin(ctx context.Context) (context.Context, func(), error) {
+	l := c.life
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, nil, ErrClosed
+	}
+	l.inflight.Add(1)
+	l.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(l.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		l.inflight.Done()
+	}, nil
+}
+
+// Close cancela e aguarda as coletas em andamento e descarta o cache. Pode
+// ser chamado mais de uma vez; coletas posteriores retornam ErrClosed.
+func (c *Collector) Close() error {
+	l := c.life
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	if l.stop != nil {
+		l.stop()
+	}
+	l.mu.Unlock()
+
+	l.cancel()
+	l.inflight.Wait()
+	c.ClearCache()
+	return nil
+}
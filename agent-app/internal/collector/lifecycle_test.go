@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"go.uber.org/goleak"
+)
+
+func TestCollectorCloseLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c := NewCollector(time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("second Start: %v", err)
+	}
+	if _, err := c.SampleUsage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	c.setCache(CacheKeySystemInfo, &types.SystemInfo{Hostname: "cached"})
+
+	for i := 0; i < 2; i++ {
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close %d: %v", i+1, err)
+		}
+	}
+	if c.getFromCache(CacheKeySystemInfo) != nil {
+		t.Fatal("cache kept after Close")
+	}
+	if _, err := c.SampleUsage(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("collection after Close: %v", err)
+	}
+	if err := c.Start(ctx); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Start after Close: %v", err)
+	}
+}
+
+func TestCollectorCloseWaitsForCollections(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c := NewCollector(time.Hour)
+	ctx, end, err := c.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		_ = c.Close()
+		close(closed)
+	}()
+
+	// Close cancela a coleta em andamento, mas só retorna quando ela termina
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight collection not cancelled by Close")
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the in-flight collection ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+	end()
+	<-closed
+}
+
+func TestCollectorStartContextCancelsCollections(t *testing.T) {
+	c := NewCollector(time.Hour)
+	defer c.Close()
+
+	parent, cancel := context.WithCancel(context.Background())
+	if err := c.Start(parent); err != nil {
+		t.Fatal(err)
+	}
+	ctx, end, err := c.begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer end()
+
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("collection not cancelled when the Start context ended")
+	}
+}
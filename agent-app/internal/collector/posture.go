@@ -0,0 +1,356 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// CacheKeySecurityPosture é a chave de cache da postura de segurança
+const CacheKeySecurityPosture = "security_posture"
+
+// postureCheck é uma verificação independente: platforms vazio roda em
+// todas as plataformas
+type postureCheck struct {
+	platforms []string
+	run       func(ctx context.Context) (*types.PostureCheck, error)
+	field     func(p *types.SecurityPosture) **types.PostureCheck
+}
+
+var postureChecks = []postureCheck{
+	{
+		run:   checkFirewall,
+		field: func(p *types.SecurityPosture) **types.PostureCheck { return &p.Firewall },
+	},
+	{
+		run:   checkScreenLock,
+		field: func(p *types.SecurityPosture) **types.PostureCheck { return &p.ScreenLock },
+	},
+	{
+		platforms: []string{"darwin"},
+		run:       checkSIP,
+		field:     func(p *types.SecurityPosture) **types.PostureCheck { return &p.SIP },
+	},
+	{
+		platforms: []string{"darwin"},
+		run:       checkGatekeeper,
+		field:     func(p *types.SecurityPosture) **types.PostureCheck { return &p.Gatekeeper },
+	},
+	{
+		run:   checkRemoteLogin,
+		field: func(p *types.SecurityPosture) **types.PostureCheck { return &p.RemoteLogin },
+	},
+}
+
+// CollectSecurityPosture verifica firewall, bloqueio de tela, SIP,
+// Gatekeeper e acesso remoto em paralelo; a falha de uma verificação fica
+// no Error dela e não afeta as demais
+func (c *Collector) CollectSecurityPosture(ctx context.Context) (*types.SecurityPosture, error) {
+	ctx, end, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if cached, ok := c.getFromCache(CacheKeySecurityPosture).(*types.SecurityPosture); ok {
+		return cached, nil
+	}
+
+	posture := &types.SecurityPosture{Timestamp: time.Now()}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, check := range postureChecks {
+		if !postureCheckApplies(check) {
+			continue
+		}
+		wg.Add(1)
+		go func(check postureCheck) {
+			defer wg.Done()
+			result, err := check.run(ctx)
+			if err != nil {
+				result = &types.PostureCheck{Error: err.Error()}
+			}
+			mu.Lock()
+			*check.field(posture) = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	c.setCache(CacheKeySecurityPosture, posture)
+	return posture, nil
+}
+
+// postureCheckApplies indica se a verificação existe nesta plataforma
+func postureCheckApplies(check postureCheck) bool {
+	if len(check.platforms) == 0 {
+		return true
+	}
+	for _, platform := range check.platforms {
+		if platform == runtime.GOOS {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode retorna o código de saída de um comando que terminou com erro
+func exitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// checkFirewall verifica o firewall: socketfilterfw no macOS, ufw ou
+// firewalld no Linux e os perfis do netsh advfirewall no Windows
+func checkFirewall(ctx context.Context) (*types.PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := commandOutput(ctx, "/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar socketfilterfw: %w", err)
+		}
+		return parseSocketFilterFW(output), nil
+	case "linux":
+		output, ufwErr := commandOutput(ctx, "ufw", "status")
+		if ufwErr == nil {
+			return parseUFWStatus(output), nil
+		}
+		_, err := commandOutput(ctx, "firewall-cmd", "--state")
+		if err == nil {
+			return &types.PostureCheck{Enabled: true, Detail: "firewalld"}, nil
+		}
+		// firewall-cmd --state sai com 252 e "not running" com o serviço parado
+		if code, ok := exitCode(err); ok && code == 252 {
+			return &types.PostureCheck{Enabled: false, Detail: "firewalld"}, nil
+		}
+		return nil, fmt.Errorf("nenhum firewall disponível (ufw: %v; firewall-cmd: %v)", ufwErr, err)
+	case "windows":
+		output, err := commandOutput(ctx, "netsh", "advfirewall", "show", "allprofiles", "state")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar netsh: %w", err)
+		}
+		return parseNetshFirewallState(output)
+	default:
+		return nil, fmt.Errorf("verificação de firewall não suportada em %s", runtime.GOOS)
+	}
+}
+
+// parseSocketFilterFW interpreta "Firewall is enabled. (State = 1)"; o
+// estado 2 (bloquear tudo) também conta como ligado
+func parseSocketFilterFW(output []byte) *types.PostureCheck {
+	text := string(output)
+	return &types.PostureCheck{
+		Enabled: strings.Contains(text, "enabled") || strings.Contains(text, "blocking all"),
+		Detail:  strings.TrimSpace(text),
+	}
+}
+
+// parseUFWStatus interpreta a linha "Status: active" do ufw status
+func parseUFWStatus(output []byte) *types.PostureCheck {
+	for _, line := range strings.Split(string(output), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Status:"); ok {
+			return &types.PostureCheck{Enabled: strings.TrimSpace(value) == "active", Detail: "ufw"}
+		}
+	}
+	return &types.PostureCheck{Detail: "ufw"}
+}
+
+// parseNetshFirewallState interpreta o netsh advfirewall show allprofiles
+// state: ligado só com todos os perfis em ON (o nome do perfil é traduzido
+// no Windows localizado, ON/OFF não)
+func parseNetshFirewallState(output []byte) (*types.PostureCheck, error) {
+	var profile string
+	var off []string
+	profiles := 0
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "-") {
+			profile = strings.TrimSuffix(line, ":")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[len(fields)-1] {
+		case "ON":
+			profiles++
+		case "OFF":
+			profiles++
+			off = append(off, profile)
+		}
+	}
+	if profiles == 0 {
+		return nil, fmt.Errorf("saída do netsh sem estado dos perfis")
+	}
+	check := &types.PostureCheck{Enabled: len(off) == 0}
+	if len(off) > 0 {
+		check.Detail = "off: " + strings.Join(off, ", ")
+	}
+	return check, nil
+}
+
+// Chaves do registro consultadas no Windows
+const (
+	windowsPoliciesSystemKey = `HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`
+	windowsTerminalServerKey = `HKLM\SYSTEM\CurrentControlSet\Control\Terminal Server`
+)
+
+// checkScreenLock verifica se a tela bloqueia sozinha: sysadminctl no
+// macOS, a política InactivityTimeoutSecs no Windows e o gsettings do GNOME
+// no Linux
+func checkScreenLock(ctx context.Context) (*types.PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// sysadminctl escreve o estado no stderr
+		output, err := commandOutput(ctx, "sh", "-c", "sysadminctl -screenLock status 2>&1")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar sysadminctl: %w", err)
+		}
+		return parseSysadminctlScreenLock(output), nil
+	case "windows":
+		output, err := commandOutput(ctx, "reg", "query", windowsPoliciesSystemKey, "/v", "InactivityTimeoutSecs")
+		if err != nil {
+			// reg query sai com 1 quando o valor não existe
+			if code, ok := exitCode(err); ok && code == 1 {
+				return &types.PostureCheck{Enabled: false, Detail: "not configured"}, nil
+			}
+			return nil, fmt.Errorf("erro ao executar reg: %w", err)
+		}
+		seconds, err := parseRegDWORD(output, "InactivityTimeoutSecs")
+		if err != nil {
+			return nil, err
+		}
+		return &types.PostureCheck{Enabled: seconds > 0, Detail: fmt.Sprintf("%d seconds", seconds)}, nil
+	case "linux":
+		output, err := commandOutput(ctx, "gsettings", "get", "org.gnome.desktop.screensaver", "lock-enabled")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar gsettings: %w", err)
+		}
+		return &types.PostureCheck{Enabled: strings.TrimSpace(string(output)) == "true", Detail: "gnome"}, nil
+	default:
+		return nil, fmt.Errorf("verificação de bloqueio de tela não suportada em %s", runtime.GOOS)
+	}
+}
+
+// parseSysadminctlScreenLock interpreta "screenLock delay is 300 seconds",
+// "screenLock is immediate" ou "screenLock is off"
+func parseSysadminctlScreenLock(output []byte) *types.PostureCheck {
+	text := strings.TrimSpace(string(output))
+	idx := strings.Index(text, "screenLock")
+	if idx < 0 {
+		return &types.PostureCheck{Detail: text}
+	}
+	detail := text[idx:]
+	return &types.PostureCheck{Enabled: !strings.Contains(detail, "is off"), Detail: detail}
+}
+
+// parseRegDWORD lê um valor REG_DWORD do reg query
+func parseRegDWORD(output []byte, name string) (uint64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && strings.EqualFold(fields[0], name) && fields[1] == "REG_DWORD" {
+			return strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		}
+	}
+	return 0, fmt.Errorf("%s não encontrado na saída do reg", name)
+}
+
+// checkSIP verifica o System Integrity Protection pelo csrutil status
+func checkSIP(ctx context.Context) (*types.PostureCheck, error) {
+	output, err := commandOutput(ctx, "csrutil", "status")
+	if err != nil {
+		return nil, fmt.Errorf("erro ao executar csrutil: %w", err)
+	}
+	return parseCsrutilStatus(output), nil
+}
+
+// parseCsrutilStatus interpreta "System Integrity Protection status:
+// enabled."; a "Custom Configuration" não conta como ligado
+func parseCsrutilStatus(output []byte) *types.PostureCheck {
+	text := strings.TrimSpace(string(output))
+	_, status, _ := strings.Cut(text, "status:")
+	status = strings.TrimSpace(status)
+	return &types.PostureCheck{
+		Enabled: strings.HasPrefix(status, "enabled") && !strings.Contains(text, "Custom Configuration"),
+		Detail:  strings.TrimSuffix(strings.SplitN(status, "\n", 2)[0], "."),
+	}
+}
+
+// checkGatekeeper verifica o Gatekeeper pelo spctl --status
+func checkGatekeeper(ctx context.Context) (*types.PostureCheck, error) {
+	output, err := commandOutput(ctx, "spctl", "--status")
+	if err != nil {
+		// spctl --status sai com 1 quando as avaliações estão desligadas
+		if code, ok := exitCode(err); ok && code == 1 {
+			return &types.PostureCheck{Enabled: false, Detail: "assessments disabled"}, nil
+		}
+		return nil, fmt.Errorf("erro ao executar spctl: %w", err)
+	}
+	return parseSpctlStatus(output), nil
+}
+
+// parseSpctlStatus interpreta "assessments enabled" / "assessments disabled"
+func parseSpctlStatus(output []byte) *types.PostureCheck {
+	text := strings.TrimSpace(string(output))
+	return &types.PostureCheck{Enabled: text == "assessments enabled", Detail: text}
+}
+
+// checkRemoteLogin verifica o acesso remoto: Remote Login (SSH) no macOS,
+// o serviço do OpenSSH no Linux e o RDP no Windows
+func checkRemoteLogin(ctx context.Context) (*types.PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := commandOutput(ctx, "systemsetup", "-getremotelogin")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar systemsetup: %w", err)
+		}
+		return parseSystemsetupRemoteLogin(output), nil
+	case "linux":
+		// is-active sai com 0 só quando a unit está ativa; ssh no Debian,
+		// sshd nas demais
+		for _, unit := range []string{"ssh", "sshd"} {
+			_, err := commandOutput(ctx, "systemctl", "is-active", "--quiet", unit)
+			if err == nil {
+				return &types.PostureCheck{Enabled: true, Detail: unit}, nil
+			}
+			if _, ok := exitCode(err); !ok {
+				return nil, fmt.Errorf("erro ao executar systemctl: %w", err)
+			}
+		}
+		return &types.PostureCheck{Enabled: false, Detail: "ssh"}, nil
+	case "windows":
+		output, err := commandOutput(ctx, "reg", "query", windowsTerminalServerKey, "/v", "fDenyTSConnections")
+		if err != nil {
+			return nil, fmt.Errorf("erro ao executar reg: %w", err)
+		}
+		deny, err := parseRegDWORD(output, "fDenyTSConnections")
+		if err != nil {
+			return nil, err
+		}
+		return &types.PostureCheck{Enabled: deny == 0, Detail: "rdp"}, nil
+	default:
+		return nil, fmt.Errorf("verificação de acesso remoto não suportada em %s", runtime.GOOS)
+	}
+}
+
+// parseSystemsetupRemoteLogin interpreta "Remote Login: On" / "Remote Login: Off"
+func parseSystemsetupRemoteLogin(output []byte) *types.PostureCheck {
+	_, value, _ := strings.Cut(strings.TrimSpace(string(output)), ":")
+	return &types.PostureCheck{Enabled: strings.TrimSpace(value) == "On", Detail: "ssh"}
+}
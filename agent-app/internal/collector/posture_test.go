@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"testing"
+
+	"machine-monitor-agent/internal/types"
+)
+
+const (
+	netshAllProfilesOn = "\r\nDomain Profile Settings:\r\n" +
+		"----------------------------------------------------------------------\r\n" +
+		"State                                 ON\r\n\r\n" +
+		"Private Profile Settings:\r\n" +
+		"----------------------------------------------------------------------\r\n" +
+		"State                                 ON\r\n\r\n" +
+		"Public Profile Settings:\r\n" +
+		"----------------------------------------------------------------------\r\n" +
+		"State                                 ON\r\n" +
+		"Ok.\r\n\r\n"
+	netshPublicOff = "\r\nPerfil de Domínio Configurações:\r\n" +
+		"----------------------------------------------------------------------\r\n" +
+		"Estado                                ON\r\n\r\n" +
+		"Perfil Público Configurações:\r\n" +
+		"----------------------------------------------------------------------\r\n" +
+		"Estado                                OFF\r\n" +
+		"Ok.\r\n\r\n"
+	regInactivityTimeout = "\r\nHKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\Policies\\System\r\n" +
+		"    InactivityTimeoutSecs    REG_DWORD    0x384\r\n\r\n"
+)
+
+func TestPostureParsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func([]byte) *types.PostureCheck
+		output string
+		want   types.PostureCheck
+	}{
+		{"socketfilterfw on", parseSocketFilterFW, "Firewall is enabled. (State = 1)\n", types.PostureCheck{Enabled: true, Detail: "Firewall is enabled. (State = 1)"}},
+		{"socketfilterfw block all", parseSocketFilterFW, "Firewall is blocking all non-essential incoming connections. (State = 2)\n", types.PostureCheck{Enabled: true, Detail: "Firewall is blocking all non-essential incoming connections. (State = 2)"}},
+		{"socketfilterfw off", parseSocketFilterFW, "Firewall is disabled. (State = 0)\n", types.PostureCheck{Detail: "Firewall is disabled. (State = 0)"}},
+
+		{"ufw active", parseUFWStatus, "Status: active\n\nTo                         Action      From\n--                         ------      ----\n22/tcp                     ALLOW       Anywhere\n", types.PostureCheck{Enabled: true, Detail: "ufw"}},
+		{"ufw inactive", parseUFWStatus, "Status: inactive\n", types.PostureCheck{Detail: "ufw"}},
+		{"ufw unexpected", parseUFWStatus, "ERROR: You need to be root to run this script\n", types.PostureCheck{Detail: "ufw"}},
+
+		{"screen lock delay", parseSysadminctlScreenLock, "2026-01-05 09:00:00.123 sysadminctl[812:10231] screenLock delay is 300 seconds\n", types.PostureCheck{Enabled: true, Detail: "screenLock delay is 300 seconds"}},
+		{"screen lock immediate", parseSysadminctlScreenLock, "2026-01-05 09:00:00.123 sysadminctl[812:10231] screenLock is immediate\n", types.PostureCheck{Enabled: true, Detail: "screenLock is immediate"}},
+		{"screen lock off", parseSysadminctlScreenLock, "2026-01-05 09:00:00.123 sysadminctl[812:10231] screenLock is off\n", types.PostureCheck{Detail: "screenLock is off"}},
+
+		{"sip enabled", parseCsrutilStatus, "System Integrity Protection status: enabled.\n", types.PostureCheck{Enabled: true, Detail: "enabled"}},
+		{"sip disabled", parseCsrutilStatus, "System Integrity Protection status: disabled.\n", types.PostureCheck{Detail: "disabled"}},
+		{"sip custom", parseCsrutilStatus, "System Integrity Protection status: enabled (Custom Configuration).\n\nConfiguration:\n\tApple Internal: disabled\n\tKext Signing: disabled\n", types.PostureCheck{Detail: "enabled (Custom Configuration)"}},
+
+		{"gatekeeper enabled", parseSpctlStatus, "assessments enabled\n", types.PostureCheck{Enabled: true, Detail: "assessments enabled"}},
+		{"gatekeeper disabled", parseSpctlStatus, "assessments disabled\n", types.PostureCheck{Detail: "assessments disabled"}},
+
+		{"remote login on", parseSystemsetupRemoteLogin, "Remote Login: On\n", types.PostureCheck{Enabled: true, Detail: "ssh"}},
+		{"remote login off", parseSystemsetupRemoteLogin, "Remote Login: Off\n", types.PostureCheck{Detail: "ssh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parse([]byte(tt.output)); *got != tt.want {
+				t.Fatalf("%+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNetshFirewallState(t *testing.T) {
+	check, err := parseNetshFirewallState([]byte(netshAllProfilesOn))
+	if err != nil || !check.Enabled || check.Detail != "" {
+		t.Fatalf("all profiles on: %+v, %v", check, err)
+	}
+
+	// Um perfil desligado basta, mesmo no Windows localizado
+	check, err = parseNetshFirewallState([]byte(netshPublicOff))
+	if err != nil || check.Enabled || check.Detail != "off: Perfil Público Configurações" {
+		t.Fatalf("public profile off: %+v, %v", check, err)
+	}
+
+	if _, err := parseNetshFirewallState([]byte("The requested operation requires elevation (Run as administrator).\r\n")); err == nil {
+		t.Fatal("no profile parsed as a firewall state")
+	}
+}
+
+func TestParseRegDWORD(t *testing.T) {
+	value, err := parseRegDWORD([]byte(regInactivityTimeout), "InactivityTimeoutSecs")
+	if err != nil || value != 900 {
+		t.Fatalf("value %d, %v", value, err)
+	}
+
+	deny, err := parseRegDWORD([]byte("\r\nHKEY_LOCAL_MACHINE\\SYSTEM\\CurrentControlSet\\Control\\Terminal Server\r\n    fDenyTSConnections    REG_DWORD    0x1\r\n"), "fDenyTSConnections")
+	if err != nil || deny != 1 {
+		t.Fatalf("fDenyTSConnections %d, %v", deny, err)
+	}
+
+	if _, err := parseRegDWORD([]byte(regInactivityTimeout), "fDenyTSConnections"); err == nil {
+		t.Fatal("missing value parsed")
+	}
+}
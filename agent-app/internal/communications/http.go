@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"machine-monitor-agent/internal/types"
@@ -17,6 +18,10 @@ type HTTPClient struct {
 	client  *http.Client
 	baseURL string
 	apiKey  string
+
+	metricsMu    sync.Mutex
+	metrics      types.HTTPMetrics
+	totalLatency time.Duration
 }
 
 // NewHTTPClient cria um novo cliente HTTP
@@ -60,6 +65,12 @@ func (h *HTTPClient) SendCommandResult(ctx context.Context, machineID string, re
 	return h.makeRequest(ctx, "POST", url, result, nil)
 }
 
+// SendLogs envia um lote de logs para o backend
+func (h *HTTPClient) SendLogs(ctx context.Context, batch *types.LogBatch) error {
+	url := fmt.Sprintf("%s/api/agentes/%s/logs", h.baseURL, batch.MachineID)
+	return h.makeRequest(ctx, "POST", url, batch, nil)
+}
+
 // GetCommands obtém comandos pendentes do backend
 func (h *HTTPClient) GetCommands(ctx context.Context, machineID string) ([]types.Command, error) {
 	url := fmt.Sprintf("%s/api/agentes/%s/commands", h.baseURL, machineID)
@@ -73,8 +84,44 @@ func (h *HTTPClient) GetCommands(ctx context.Context, machineID string) ([]types
 	return commands, nil
 }
 
-// makeRequest faz uma requisição HTTP
+// GetMetrics retorna os contadores das requisições ao backend
+func (h *HTTPClient) GetMetrics() types.HTTPMetrics {
+	h.metricsMu.Lock()
+	defer h.metricsMu.Unlock()
+
+	metrics := h.metrics
+	if metrics.Requests > 0 {
+		metrics.AverageLatency = (h.totalLatency / time.Duration(metrics.Requests)).Milliseconds()
+	}
+	return metrics
+}
+
+// record registra uma requisição concluída nas métricas
+func (h *HTTPClient) record(latency time.Duration, err error) {
+	h.metricsMu.Lock()
+	defer h.metricsMu.Unlock()
+
+	h.metrics.Requests++
+	h.totalLatency += latency
+	if err != nil {
+		h.metrics.Failures++
+		h.metrics.LastError = err.Error()
+		h.metrics.LastErrorAt = time.Now()
+		return
+	}
+	h.metrics.LastSuccess = time.Now()
+}
+
+// makeRequest faz uma requisição HTTP e a registra nas métricas
 func (h *HTTPClient) makeRequest(ctx context.Context, method, url string, payload interface{}, result interface{}) error {
+	start := time.Now()
+	err := h.doRequest(ctx, method, url, payload, result)
+	h.record(time.Since(start), err)
+	return err
+}
+
+// doRequest faz uma requisição HTTP
+func (h *HTTPClient) doRequest(ctx context.Context, method, url string, payload interface{}, result interface{}) error {
 	var body io.Reader
 
 	if payload != nil {
@@ -34,6 +34,10 @@ type WSClient struct {
 	pingInterval      time.Duration
 	writeTimeout      time.Duration
 	readTimeout       time.Duration
+
+	// Métricas; metricsMu é independente de mu para poder ser usado com ele travado
+	metricsMu sync.Mutex
+	metrics   types.WSMetrics
 }
 
 // NewWSClient cria um novo cliente WebSocket
@@ -81,12 +85,20 @@ func (w *WSClient) Connect(ctx context.Context) error {
 
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), headers)
 	if err != nil {
+		w.recordMetrics(func(m *types.WSMetrics) {
+			m.ConnectFailures++
+			setWSError(m, err)
+		})
 		return fmt.Errorf("erro ao conectar WebSocket: %w", err)
 	}
 
 	w.conn = conn
 	w.connected = true
 	w.reconnect = true
+	w.recordMetrics(func(m *types.WSMetrics) {
+		m.Connects++
+		m.LastConnected = time.Now()
+	})
 
 	// Inicia goroutines para leitura e escrita
 	go w.readLoop()
@@ -129,6 +141,34 @@ func (w *WSClient) IsConnected() bool {
 	return w.connected
 }
 
+// GetMetrics retorna o estado da conexão, os contadores e a ocupação das
+// filas de comandos e de resultados
+func (w *WSClient) GetMetrics() types.WSMetrics {
+	w.metricsMu.Lock()
+	metrics := w.metrics
+	w.metricsMu.Unlock()
+
+	metrics.Connected = w.IsConnected()
+	metrics.CommandQueueDepth = len(w.commandChan)
+	metrics.CommandQueueCapacity = cap(w.commandChan)
+	metrics.ResultQueueDepth = len(w.resultChan)
+	metrics.ResultQueueCapacity = cap(w.resultChan)
+	return metrics
+}
+
+// recordMetrics atualiza as métricas sob metricsMu
+func (w *WSClient) recordMetrics(update func(m *types.WSMetrics)) {
+	w.metricsMu.Lock()
+	defer w.metricsMu.Unlock()
+	update(&w.metrics)
+}
+
+// setWSError guarda o último erro da conexão
+func setWSError(m *types.WSMetrics, err error) {
+	m.LastError = err.Error()
+	m.LastErrorAt = time.Now()
+}
+
 // GetCommandChannel retorna o canal de comandos
 func (w *WSClient) GetCommandChannel() <-chan types.Command {
 	return w.commandChan
@@ -144,6 +184,19 @@ func (w *WSClient) SendResult(result types.CommandResult) error {
 	}
 }
 
+// FlushResults aguarda até que os resultados pendentes sejam escritos ou o timeout expire.
+// Retorna false se ainda havia resultados na fila ao expirar.
+func (w *WSClient) FlushResults(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for len(w.resultChan) > 0 {
+		if !w.IsConnected() || time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
 // readLoop loop de leitura do WebSocket
 func (w *WSClient) readLoop() {
 	defer func() {
@@ -169,8 +222,13 @@ func (w *WSClient) readLoop() {
 		messageType, data, err := w.conn.ReadMessage()
 		if err != nil {
 			log.Error().Err(err).Msg("Erro ao ler mensagem WebSocket")
+			w.recordMetrics(func(m *types.WSMetrics) {
+				m.Disconnects++
+				setWSError(m, err)
+			})
 			return
 		}
+		w.recordMetrics(func(m *types.WSMetrics) { m.MessagesReceived++ })
 
 		if messageType == websocket.TextMessage {
 			w.handleMessage(data)
@@ -250,6 +308,7 @@ func (w *WSClient) handleMessage(data []byte) {
 		case w.commandChan <- command:
 			log.Info().Str("command_id", command.ID).Str("type", command.Type).Msg("Comando recebido")
 		default:
+			w.recordMetrics(func(m *types.WSMetrics) { m.CommandsDropped++ })
 			log.Warn().Str("command_id", command.ID).Msg("Canal de comandos cheio, comando ignorado")
 		}
 
@@ -284,7 +343,12 @@ func (w *WSClient) sendMessage(msgType string, data interface{}) error {
 	}
 
 	w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
-	return w.conn.WriteMessage(websocket.TextMessage, jsonData)
+	if err := w.conn.WriteMessage(websocket.TextMessage, jsonData); err != nil {
+		w.recordMetrics(func(m *types.WSMetrics) { setWSError(m, err) })
+		return err
+	}
+	w.recordMetrics(func(m *types.WSMetrics) { m.MessagesSent++ })
+	return nil
 }
 
 // reconnectLoop loop de reconexão
@@ -7,11 +7,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"machine-monitor-agent/internal/timeutil"
 	"machine-monitor-agent/internal/types"
 )
 
-// LoadConfig carrega a configuração do arquivo JSON
+// LoadConfig carrega a configuração do arquivo JSON, YAML ou TOML, conforme
+// a extensão (ver ConfigFormat)
 func LoadConfig(configPath string) (*types.Config, error) {
 	// Se o caminho não for fornecido, usa o padrão
 	if configPath == "" {
@@ -24,17 +27,44 @@ func LoadConfig(configPath string) (*types.Config, error) {
 		return nil, fmt.Errorf("erro ao ler arquivo de configuração: %w", err)
 	}
 
+	// YAML e TOML viram JSON com os mesmos nomes de campo
+	format := ConfigFormat(configPath)
+	document, err := configDocumentJSON(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer parse da configuração %s: %w", configPath, err)
+	}
+
+	// Substitui ${VAR} e procura chaves desconhecidas antes do parse
+	document, problems, err := prepareDocument(document)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer parse da configuração: %w", err)
+	}
+
 	// Faz o parse do JSON
 	var config types.Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("erro ao fazer parse da configuração: %w", err)
+	if err := json.Unmarshal(document, &config); err != nil {
+		return nil, fmt.Errorf("erro ao fazer parse da configuração %s: %w", configPath, decodeError(err, data, format))
 	}
 
-	// Valida e completa a configuração
+	// Completa e valida a configuração, reunindo todos os problemas
 	if err := validateAndCompleteConfig(&config); err != nil {
 		return nil, fmt.Errorf("erro na validação da configuração: %w", err)
 	}
+	problems = append(problems, validateConfig(&config)...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("erro na validação da configuração: %w", &ValidationError{Problems: problems})
+	}
+
+	return &config, nil
+}
 
+// DefaultConfig retorna a configuração padrão, sem arquivo, com um
+// machine_id novo
+func DefaultConfig() (*types.Config, error) {
+	var config types.Config
+	if err := validateAndCompleteConfig(&config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
@@ -64,18 +94,28 @@ func SaveConfig(config *types.Config, configPath string) error {
 	return nil
 }
 
-// getDefaultConfigPath retorna o caminho padrão do arquivo de configuração
+// getDefaultConfigPath retorna o caminho padrão do arquivo de configuração:
+// config.json ou, se só ele existir, config.yaml, config.yml ou config.toml
+// no mesmo diretório
 func getDefaultConfigPath() string {
+	var dir string
 	switch runtime.GOOS {
 	case "windows":
-		return filepath.Join(os.Getenv("APPDATA"), "MachineMonitor", "config.json")
+		dir = filepath.Join(os.Getenv("APPDATA"), "MachineMonitor")
 	case "darwin":
 		homeDir, _ := os.UserHomeDir()
-		return filepath.Join(homeDir, "Library", "Application Support", "MachineMonitor", "config.json")
+		dir = filepath.Join(homeDir, "Library", "Application Support", "MachineMonitor")
 	default: // Linux e outros
 		homeDir, _ := os.UserHomeDir()
-		return filepath.Join(homeDir, ".config", "machine-monitor", "config.json")
+		dir = filepath.Join(homeDir, ".config", "machine-monitor")
 	}
+
+	for _, name := range []string{"config.json", "config.yaml", "config.yml", "config.toml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return filepath.Join(dir, "config.json")
 }
 
 // validateAndCompleteConfig valida e completa a configuração com valores padrão
@@ -100,13 +140,13 @@ func validateAndCompleteConfig(config *types.Config) error {
 		config.Server.WSPort = 3001
 	}
 	if config.Server.Timeout == 0 {
-		config.Server.Timeout = 30
+		config.Server.Timeout = timeutil.Seconds(30 * time.Second)
 	}
 	if config.Server.MaxRetries == 0 {
 		config.Server.MaxRetries = 3
 	}
 	if config.Server.RetryDelay == 0 {
-		config.Server.RetryDelay = 5
+		config.Server.RetryDelay = timeutil.Seconds(5 * time.Second)
 	}
 
 	// Valida configurações do agente
@@ -117,16 +157,25 @@ func validateAndCompleteConfig(config *types.Config) error {
 		config.Agent.Version = "1.0.0"
 	}
 	if config.Agent.HeartbeatInterval == 0 {
-		config.Agent.HeartbeatInterval = 30
+		config.Agent.HeartbeatInterval = timeutil.Seconds(30 * time.Second)
 	}
 	if config.Agent.InventoryInterval == 0 {
-		config.Agent.InventoryInterval = 300
+		config.Agent.InventoryInterval = timeutil.Seconds(5 * time.Minute)
 	}
 	if config.Agent.MaxConcurrency == 0 {
 		config.Agent.MaxConcurrency = 5
 	}
 	if config.Agent.DataCacheTTL == 0 {
-		config.Agent.DataCacheTTL = 300
+		config.Agent.DataCacheTTL = timeutil.Seconds(5 * time.Minute)
+	}
+	if config.Agent.EventLogSize == 0 {
+		config.Agent.EventLogSize = 1000
+	}
+	if config.Agent.InventoryHistory.MaxEntries <= 0 {
+		config.Agent.InventoryHistory.MaxEntries = 100
+	}
+	if config.Agent.InventoryHistory.MaxAge <= 0 {
+		config.Agent.InventoryHistory.MaxAge = timeutil.Seconds(7 * 24 * time.Hour)
 	}
 
 	// Valida configurações de logging
@@ -139,9 +188,24 @@ func validateAndCompleteConfig(config *types.Config) error {
 	if config.Logging.MaxSize == 0 {
 		config.Logging.MaxSize = 100
 	}
+	if config.Logging.MaxBackups == 0 {
+		config.Logging.MaxBackups = 5
+	}
 	if config.Logging.MaxAge == 0 {
 		config.Logging.MaxAge = 7
 	}
+	if config.Logging.Shipping.Level == "" {
+		config.Logging.Shipping.Level = types.LogLevelWarn
+	}
+	if config.Logging.Shipping.BatchSize <= 0 {
+		config.Logging.Shipping.BatchSize = 100
+	}
+	if config.Logging.Shipping.FlushInterval <= 0 {
+		config.Logging.Shipping.FlushInterval = timeutil.Seconds(30 * time.Second)
+	}
+	if config.Logging.Shipping.BufferSize <= 0 {
+		config.Logging.Shipping.BufferSize = 1000
+	}
 
 	// Valida configurações da UI
 	if config.UI.WebUIPort == 0 {
@@ -150,10 +214,22 @@ func validateAndCompleteConfig(config *types.Config) error {
 	if config.UI.Theme == "" {
 		config.UI.Theme = "dark"
 	}
+	if config.UI.Language == "" {
+		config.UI.Language = "auto"
+	}
+	if config.UI.PushInterval <= 0 {
+		config.UI.PushInterval = timeutil.Seconds(2 * time.Second)
+	}
+	if config.UI.BindAddress == "" {
+		config.UI.BindAddress = "127.0.0.1"
+	}
 
 	// Valida configurações de segurança
 	if len(config.Security.AllowedCommands) == 0 {
-		config.Security.AllowedCommands = []string{"ping", "info", "restart"}
+		config.Security.AllowedCommands = []string{"ping", "info", "get_events", "get_inventory_history", "restart", "restart_agent"}
+	}
+	if config.Security.MaxOutputBytes == 0 {
+		config.Security.MaxOutputBytes = 1024 * 1024
 	}
 
 	return nil
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// A mesma configuração nos três formatos
+const (
+	roundTripJSON = `{
+  "server": {"base_url": "https://backend.example.com", "timeout": "90s", "max_retries": 5},
+  "agent": {"machine_id": "mac-dev-001", "heartbeat_interval": 15, "inventory_history": {"enabled": true, "max_entries": 20}},
+  "logging": {"level": "debug", "shipping": {"enabled": true, "level": "error"}},
+  "ui": {"webui_port": 8090, "basic_auth": {"user": "admin", "password": "secret"}},
+  "security": {"allowed_commands": ["ping", "uptime"], "validate_certs": true}
+}
+`
+	roundTripYAML = `# Comentários são o motivo do YAML
+server:
+  base_url: https://backend.example.com
+  timeout: 90s
+  max_retries: 5
+agent:
+  machine_id: mac-dev-001
+  heartbeat_interval: 15
+  inventory_history:
+    enabled: true
+    max_entries: 20
+logging:
+  level: debug
+  shipping: {enabled: true, level: error}
+ui:
+  webui_port: 8090
+  basic_auth:
+    user: admin
+    password: secret
+security:
+  allowed_commands: [ping, uptime]
+  validate_certs: true
+`
+	roundTripTOML = `# Comentários também valem em TOML
+[server]
+base_url = "https://backend.example.com"
+timeout = "90s"
+max_retries = 5
+
+[agent]
+machine_id = "mac-dev-001"
+heartbeat_interval = 15
+
+[agent.inventory_history]
+enabled = true
+max_entries = 20
+
+[logging]
+level = "debug"
+
+[logging.shipping]
+enabled = true
+level = "error"
+
+[ui]
+webui_port = 8090
+basic_auth = { user = "admin", password = "secret" }
+
+[security]
+allowed_commands = ["ping", "uptime"]
+validate_certs = true
+`
+)
+
+func TestLoadConfigFormatsRoundTrip(t *testing.T) {
+	configs := make(map[string]*types.Config)
+	for name, document := range map[string]string{
+		"config.json": roundTripJSON,
+		"config.yaml": roundTripYAML,
+		"config.yml":  roundTripYAML,
+		"config.toml": roundTripTOML,
+	} {
+		config, err := LoadConfig(writeConfigFile(t, name, document))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// HOME muda a cada arquivo; o log padrão fica fora da comparação
+		config.Logging.File = ""
+		configs[name] = config
+	}
+
+	want := configs["config.json"]
+	if want.Server.Timeout.Duration().Seconds() != 90 || want.Agent.HeartbeatInterval.Duration().Seconds() != 15 || !want.Logging.Shipping.Enabled || want.UI.BasicAuth.User != "admin" {
+		t.Fatalf("json config = %+v", want)
+	}
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml"} {
+		if !reflect.DeepEqual(configs[name], want) {
+			t.Errorf("%s differs from json:\n%+v\n%+v", name, configs[name], want)
+		}
+	}
+}
+
+func TestLoadConfigFormatErrorPositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{"config.json", "{\n  \"agent\": {\"machine_id\": \"mac-dev-001\"},\n  \"ui\": {\"theme\": tru}\n}\n", "linha 3, coluna 22"},
+		{"config.yaml", "agent:\n  machine_id: [mac-dev-001\n", "yaml: line"},
+		{"config.toml", "[agent]\nmachine_id = \"mac-dev-001\n", "linha 2, coluna"},
+		// Tipo errado no YAML aponta a linha e a coluna do valor
+		{"config.yaml", strings.Replace(roundTripYAML, "max_retries: 5", "max_retries: five", 1), "linha 5, coluna 16: server.max_retries: valor string onde se espera int"},
+	}
+	for _, tt := range tests {
+		_, err := LoadConfig(writeConfigFile(t, tt.name, tt.document))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSampleConfigFormats(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	want, err := sampleDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "yaml", "toml"} {
+		sample, err := SampleConfig(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "json" && !strings.Contains(string(sample), "# Comandos remotos aceitos") {
+			t.Errorf("%s sample without comments", format)
+		}
+
+		// A amostra carregada volta aos mesmos padrões, exceto o machine_id gerado
+		path := writeConfigFile(t, "config."+format, string(sample))
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s sample: %v", format, err)
+		}
+		if config.Agent.MachineID == "" {
+			t.Fatalf("%s sample: no machine_id generated", format)
+		}
+		config.Agent.MachineID = ""
+		if !reflect.DeepEqual(*config, want) {
+			t.Errorf("%s sample loads as\n%+v\nwant\n%+v", format, *config, want)
+		}
+	}
+
+	if _, err := SampleConfig("ini"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
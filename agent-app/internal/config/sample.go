@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"machine-monitor-agent/internal/timeutil"
+	"machine-monitor-agent/internal/types"
+)
+
+// sampleComments são os comentários da configuração de exemplo em YAML e
+// TOML, pelo caminho JSON do campo
+var sampleComments = map[string]string{
+	"server":                    "Backend que recebe heartbeats, inventários e resultados de comandos",
+	"server.timeout":            "Intervalos em segundos ou durações como \"90s\" e \"2h30m\"",
+	"agent.machine_id":          "Identificador da máquina; vazio gera um automaticamente",
+	"agent.max_concurrency":     "Comandos executados ao mesmo tempo",
+	"agent.inventory_history":   "Cópia local dos inventários: os max_entries mais recentes, até max_age",
+	"logging.level":             "debug, info, warn ou error",
+	"logging.max_size":          "Rotação: tamanho em MB, backups mantidos e idade máxima em dias",
+	"logging.shipping":          "Envio das linhas de log a partir de level ao backend",
+	"ui.bind_address":           "\"0.0.0.0\" expõe a interface web na rede",
+	"ui.auth_token":             "Token exigido pela API e pelo /ws; ${VAR} lê uma variável de ambiente",
+	"security.allowed_commands": "Comandos remotos aceitos",
+}
+
+// sampleDefaults é a configuração vazia completada com os valores padrão,
+// sem gerar um machine_id
+func sampleDefaults() (types.Config, error) {
+	config := types.Config{Agent: types.AgentConfig{MachineID: "-"}}
+	if err := validateAndCompleteConfig(&config); err != nil {
+		return types.Config{}, err
+	}
+	config.Agent.MachineID = ""
+	return config, nil
+}
+
+// SampleConfig gera uma configuração de exemplo no formato dado (json, yaml
+// ou toml), com os valores padrão e, em YAML e TOML, comentários
+func SampleConfig(format string) ([]byte, error) {
+	format, err := ParseConfigFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	config, err := sampleDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON não tem comentários
+	if format == ConfigFormatJSON {
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Configuração do Machine Monitor Agent (gerada com -print-default-config)\n")
+	if format == ConfigFormatTOML {
+		writeSampleTOML(&buf, reflect.ValueOf(config), "")
+	} else {
+		writeSampleYAML(&buf, reflect.ValueOf(config), "", 0)
+	}
+	return buf.Bytes(), nil
+}
+
+// sampleField é um campo de struct com o nome JSON e o caminho completo
+type sampleField struct {
+	name  string
+	path  string
+	value reflect.Value
+}
+
+// sampleFields lista os campos de value na ordem da struct
+func sampleFields(value reflect.Value, path string) []sampleField {
+	fields := make([]sampleField, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, sampleField{name: name, path: joinPath(path, name), value: value.Field(i)})
+	}
+	return fields
+}
+
+// isSampleSection indica se o campo vira uma seção (objeto ou tabela)
+func isSampleSection(value reflect.Value) bool {
+	return value.Kind() == reflect.Struct
+}
+
+// writeSampleYAML escreve os campos de value em YAML, indentados por depth
+func writeSampleYAML(buf *bytes.Buffer, value reflect.Value, path string, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, field := range sampleFields(value, path) {
+		if depth == 0 {
+			buf.WriteString("\n")
+		}
+		if comment, ok := sampleComments[field.path]; ok {
+			fmt.Fprintf(buf, "%s# %s\n", indent, comment)
+		}
+		if isSampleSection(field.value) {
+			fmt.Fprintf(buf, "%s%s:\n", indent, field.name)
+			writeSampleYAML(buf, field.value, field.path, depth+1)
+			continue
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", indent, field.name, formatSampleValue(field.value))
+	}
+}
+
+// writeSampleTOML escreve os campos simples de value e depois as tabelas
+// (TOML exige as chaves de uma tabela antes das subtabelas)
+func writeSampleTOML(buf *bytes.Buffer, value reflect.Value, path string) {
+	fields := sampleFields(value, path)
+	for _, field := range fields {
+		if isSampleSection(field.value) {
+			continue
+		}
+		if comment, ok := sampleComments[field.path]; ok {
+			fmt.Fprintf(buf, "# %s\n", comment)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", field.name, formatSampleValue(field.value))
+	}
+	for _, field := range fields {
+		if !isSampleSection(field.value) {
+			continue
+		}
+		buf.WriteString("\n")
+		if comment, ok := sampleComments[field.path]; ok {
+			fmt.Fprintf(buf, "# %s\n", comment)
+		}
+		fmt.Fprintf(buf, "[%s]\n", field.path)
+		writeSampleTOML(buf, field.value, field.path)
+	}
+}
+
+// formatSampleValue escreve um valor simples ou uma lista de strings com a
+// sintaxe comum a YAML e TOML; intervalos saem em segundos
+func formatSampleValue(value reflect.Value) string {
+	if seconds, ok := value.Interface().(timeutil.Seconds); ok {
+		return strconv.FormatInt(int64(seconds.Duration()/time.Second), 10)
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return strconv.Quote(value.String())
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Slice:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = formatSampleValue(value.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
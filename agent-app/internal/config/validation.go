@@ -0,0 +1,304 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// ValidationError reúne todos os problemas encontrados na configuração; cada
+// um começa pelo caminho JSON do campo (ex.: "server.http_port ...")
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "erros de validação: " + strings.Join(e.Problems, ", ")
+}
+
+// envReference é uma referência ${VAR} dentro de um valor string
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// prepareDocument substitui ${VAR} pelas variáveis de ambiente em todos os
+// valores string e procura chaves desconhecidas comparando o documento com
+// types.Config. Retorna o JSON já substituído e os problemas encontrados;
+// err só para JSON malformado.
+func prepareDocument(data []byte) ([]byte, []string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	var problems []string
+	doc = expandEnv(doc, "", &problems)
+	unknownKeys(doc, reflect.TypeOf(types.Config{}), "", &problems)
+
+	expanded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expanded, problems, nil
+}
+
+// expandEnv troca ${VAR} nos valores string de value; variáveis não
+// definidas viram problema no caminho do valor
+func expandEnv(value interface{}, path string, problems *[]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandEnv(item, joinPath(path, key), problems)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandEnv(item, path+"["+strconv.Itoa(i)+"]", problems)
+		}
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: variável de ambiente %s não definida", path, name))
+			}
+			return env
+		})
+	}
+	return value
+}
+
+// jsonUnmarshaler identifica tipos com decodificação própria (como
+// timeutil.Seconds), cujo conteúdo não é comparado campo a campo
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownKeys compara as chaves dos objetos de value com os campos JSON de
+// t, descendo em structs, slices, ponteiros e valores de mapas
+func unknownKeys(value interface{}, t reflect.Type, path string, problems *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(jsonUnmarshaler) || reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, known := fields[key]
+			if !known {
+				message := fmt.Sprintf("%s: campo desconhecido", joinPath(path, key))
+				if suggestion := closestKey(key, fields); suggestion != "" {
+					message += fmt.Sprintf(" (quis dizer %s?)", suggestion)
+				}
+				*problems = append(*problems, message)
+				continue
+			}
+			unknownKeys(object[key], field, joinPath(path, key), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			unknownKeys(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", problems)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range object {
+			unknownKeys(item, t.Elem(), joinPath(path, key), problems)
+		}
+	}
+}
+
+// jsonFields mapeia os nomes JSON dos campos de t aos tipos
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closestKey sugere o campo conhecido mais parecido com key (no máximo duas
+// edições de distância), para erros de digitação
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance é a distância de Levenshtein entre a e b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// joinPath monta o caminho JSON de key dentro de path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validateConfig confere a configuração já com os padrões aplicados:
+// formato da URL do backend, faixas de portas, intervalos, nível de log e
+// diretórios graváveis para log e dados
+func validateConfig(config *types.Config) []string {
+	var problems []string
+
+	if parsed, err := url.Parse(config.Server.BaseURL); err != nil {
+		problems = append(problems, fmt.Sprintf("server.base_url inválido: %v", err))
+	} else {
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			problems = append(problems, "server.base_url deve usar http ou https")
+		}
+		if parsed.Hostname() == "" {
+			problems = append(problems, "server.base_url deve ter um host")
+		}
+		if port := parsed.Port(); port != "" {
+			if n, err := strconv.Atoi(port); err != nil || !validPort(n) {
+				problems = append(problems, fmt.Sprintf("server.base_url: porta %s fora da faixa 1-65535", port))
+			}
+		}
+	}
+
+	for _, port := range []struct {
+		field string
+		value int
+	}{
+		{"server.http_port", config.Server.HTTPPort},
+		{"server.ws_port", config.Server.WSPort},
+		{"ui.webui_port", config.UI.WebUIPort},
+	} {
+		if !validPort(port.value) {
+			problems = append(problems, fmt.Sprintf("%s deve estar entre 1 e 65535", port.field))
+		}
+	}
+
+	for _, interval := range []struct {
+		field string
+		value int64
+	}{
+		{"server.timeout", int64(config.Server.Timeout)},
+		{"server.retry_delay", int64(config.Server.RetryDelay)},
+		{"agent.heartbeat_interval", int64(config.Agent.HeartbeatInterval)},
+		{"agent.inventory_interval", int64(config.Agent.InventoryInterval)},
+		{"agent.data_cache_ttl", int64(config.Agent.DataCacheTTL)},
+		{"ui.push_interval", int64(config.UI.PushInterval)},
+	} {
+		if interval.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s deve ser maior que 0", interval.field))
+		}
+	}
+
+	if config.Agent.MaxConcurrency < 0 || config.Server.MaxRetries < 0 {
+		problems = append(problems, "agent.max_concurrency e server.max_retries não podem ser negativos")
+	}
+
+	switch config.Logging.Level {
+	case types.LogLevelDebug, types.LogLevelInfo, types.LogLevelWarn, types.LogLevelError:
+	default:
+		problems = append(problems, "logging.level deve ser debug, info, warn ou error")
+	}
+
+	if config.UI.BasicAuth.User != "" && config.UI.BasicAuth.Password == "" {
+		problems = append(problems, "ui.basic_auth.password é obrigatório quando ui.basic_auth.user é definido")
+	}
+
+	if config.Logging.File != "" {
+		if err := checkWritableDir(filepath.Dir(config.Logging.File)); err != nil {
+			problems = append(problems, fmt.Sprintf("logging.file: %s não é gravável: %v", filepath.Dir(config.Logging.File), err))
+		}
+	}
+	if dir := GetDataDirectory(); dir != "" {
+		if err := checkWritableDir(dir); err != nil {
+			problems = append(problems, fmt.Sprintf("diretório de dados %s não é gravável: %v", dir, err))
+		}
+	}
+
+	return problems
+}
+
+// validPort indica se port está na faixa de portas TCP
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+// checkWritableDir cria e remove um arquivo temporário em dir ou, se dir
+// não existe, no ancestral existente mais próximo (o agente cria o resto)
+func checkWritableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("não é um diretório")
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	file, err := os.CreateTemp(dir, ".machine-monitor-write-check-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
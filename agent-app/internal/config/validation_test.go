@@ -0,0 +1,140 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile grava document em um diretório temporário, com HOME
+// apontando para ele para que os diretórios padrão sejam graváveis
+func writeConfigFile(t *testing.T, name, document string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadProblems carrega document e retorna os problemas da validação
+func loadProblems(t *testing.T, document string) []string {
+	t.Helper()
+	_, err := LoadConfig(writeConfigFile(t, "config.json", document))
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	return validation.Problems
+}
+
+// hasProblem indica se algum problema contém text
+func hasProblem(problems []string, text string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	problems := loadProblems(t, `{
+		"agent": {"heartbeat_intervall": 10},
+		"ui": {"basic_auth": {"usr": "admin"}},
+		"colour_scheme": "dark"
+	}`)
+	for _, want := range []string{
+		"agent.heartbeat_intervall: campo desconhecido (quis dizer heartbeat_interval?)",
+		"ui.basic_auth.usr: campo desconhecido (quis dizer user?)",
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("missing %q in %v", want, problems)
+		}
+	}
+	// Sem nada parecido, não há sugestão
+	if !hasProblem(problems, "colour_scheme: campo desconhecido") || hasProblem(problems, "colour_scheme: campo desconhecido (") {
+		t.Errorf("colour_scheme problem in %v", problems)
+	}
+}
+
+func TestLoadConfigBadURLs(t *testing.T) {
+	tests := map[string]string{
+		"ftp://backend.example.com":        "server.base_url deve usar http ou https",
+		"https://":                         "server.base_url deve ter um host",
+		"https://backend.example.com:0":    "server.base_url: porta 0 fora da faixa 1-65535",
+		"https://backend.example.com:8443": "",
+	}
+	for baseURL, want := range tests {
+		path := writeConfigFile(t, "config.json", `{"server": {"base_url": "`+baseURL+`"}}`)
+		_, err := LoadConfig(path)
+		if want == "" {
+			if err != nil {
+				t.Errorf("%s: %v", baseURL, err)
+			}
+			continue
+		}
+		var validation *ValidationError
+		if !errors.As(err, &validation) || !hasProblem(validation.Problems, want) {
+			t.Errorf("%s: %v, want %q", baseURL, err, want)
+		}
+	}
+}
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	problems := loadProblems(t, `{
+		"server": {"base_url": "backend.example.com", "http_port": 70000},
+		"agent": {"heartbeat_interval": -5, "inventory_intervall": 60},
+		"logging": {"level": "verbose"}
+	}`)
+	for _, want := range []string{
+		"agent.inventory_intervall",
+		"server.base_url",
+		"server.http_port deve estar entre 1 e 65535",
+		"agent.heartbeat_interval deve ser maior que 0",
+		"logging.level",
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("missing %q in %v", want, problems)
+		}
+	}
+}
+
+func TestLoadConfigEnvSubstitution(t *testing.T) {
+	t.Setenv("MM_TEST_API_KEY", "s3cr3t")
+	t.Setenv("MM_TEST_HOST", "backend.example.com")
+
+	config, err := LoadConfig(writeConfigFile(t, "config.json", `{
+		"server": {"base_url": "https://${MM_TEST_HOST}:8443"},
+		"security": {"api_key": "${MM_TEST_API_KEY}"},
+		"agent": {"name": "agent $MM_TEST_HOST"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.BaseURL != "https://backend.example.com:8443" || config.Security.APIKey != "s3cr3t" {
+		t.Fatalf("base_url %q, api_key %q", config.Server.BaseURL, config.Security.APIKey)
+	}
+	// Só ${VAR} é expandido
+	if config.Agent.Name != "agent $MM_TEST_HOST" {
+		t.Fatalf("name = %q", config.Agent.Name)
+	}
+
+	// Vale também para YAML, já convertido para JSON
+	config, err = LoadConfig(writeConfigFile(t, "config.yaml", "security:\n  api_key: ${MM_TEST_API_KEY}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Security.APIKey != "s3cr3t" {
+		t.Fatalf("yaml api_key = %q", config.Security.APIKey)
+	}
+
+	problems := loadProblems(t, `{"security": {"api_key": "${MM_TEST_UNSET}"}}`)
+	if !hasProblem(problems, "security.api_key: variável de ambiente MM_TEST_UNSET não definida") {
+		t.Fatalf("problems = %v", problems)
+	}
+}
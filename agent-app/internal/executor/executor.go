@@ -3,9 +3,9 @@ package executor
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,20 +18,67 @@ import (
 type Executor struct {
 	allowedCommands []string
 	maxConcurrency  int
+	maxOutputBytes  int
 	semaphore       chan struct{}
+
+	// events fornece o histórico do agente ao comando get_events
+	events func(since time.Time, limit int) []types.Event
+	// history fornece o histórico local de inventários ao get_inventory_history
+	history InventoryHistorySource
+
+	metrics executionMetrics
 }
 
-// NewExecutor cria uma nova instância do executor
-func NewExecutor(allowedCommands []string, maxConcurrency int) *Executor {
+// defaultGetEventsLimit limita a resposta do get_events sem limite explícito
+const defaultGetEventsLimit = 100
+
+// defaultInventoryHistoryLimit limita a listagem do get_inventory_history
+// sem limite explícito
+const defaultInventoryHistoryLimit = 100
+
+// InventoryHistorySource é o histórico local de inventários lido pelo
+// comando get_inventory_history
+type InventoryHistorySource interface {
+	GetInventoryHistory(limit int) ([]types.InventoryHistoryEntry, error)
+	GetInventoryAt(at time.Time) (*types.InventoryHistoryEntry, error)
+}
+
+// NewExecutor cria uma nova instância do executor. maxOutputBytes limita a
+// saída de cada comando (zero usa DefaultMaxOutputBytes).
+func NewExecutor(allowedCommands []string, maxConcurrency, maxOutputBytes int) *Executor {
+	if maxOutputBytes <= 0 {
+		maxOutputBytes = DefaultMaxOutputBytes
+	}
+
 	return &Executor{
 		allowedCommands: allowedCommands,
 		maxConcurrency:  maxConcurrency,
+		maxOutputBytes:  maxOutputBytes,
 		semaphore:       make(chan struct{}, maxConcurrency),
 	}
 }
 
-// ExecuteCommand executa um comando
+// SetEventSource define de onde o comando get_events lê o histórico;
+// deve ser chamado antes do primeiro comando
+func (e *Executor) SetEventSource(source func(since time.Time, limit int) []types.Event) {
+	e.events = source
+}
+
+// SetInventoryHistorySource define de onde o comando get_inventory_history
+// lê o histórico; deve ser chamado antes do primeiro comando
+func (e *Executor) SetInventoryHistorySource(source InventoryHistorySource) {
+	e.history = source
+}
+
+// ExecuteCommand executa um comando e o registra nas métricas
 func (e *Executor) ExecuteCommand(ctx context.Context, command types.Command) types.CommandResult {
+	result := e.execute(ctx, command)
+	e.metrics.record(command.Type, result)
+	return result
+}
+
+// execute executa um comando
+func (e *Executor) execute(ctx context.Context, command types.Command) types.CommandResult {
 	startTime := time.Now()
 
 	result := types.CommandResult{
@@ -42,7 +89,7 @@ func (e *Executor) ExecuteCommand(ctx context.Context, command types.Command) ty
 	// Verifica se o comando é permitido
 	if !e.isCommandAllowed(command.Type) {
 		result.Success = false
-		result.Error = fmt.Sprintf("comando não permitido: %s", command.Type)
+		result.SetError(types.NewCodedError(types.ErrCodeCommandNotAllowed, command.Type))
 		result.Duration = time.Since(startTime).Milliseconds()
 		return result
 	}
@@ -53,7 +100,7 @@ func (e *Executor) ExecuteCommand(ctx context.Context, command types.Command) ty
 		defer func() { <-e.semaphore }()
 	case <-ctx.Done():
 		result.Success = false
-		result.Error = "timeout ao aguardar slot de execução"
+		result.SetError(types.NewCodedError(types.ErrCodeExecutorQueueTimeout))
 		result.Duration = time.Since(startTime).Milliseconds()
 		return result
 	}
@@ -66,11 +113,15 @@ func (e *Executor) ExecuteCommand(ctx context.Context, command types.Command) ty
 		result = e.executeInfoCommand(ctx, command)
 	case types.CommandTypePing:
 		result = e.executePingCommand(ctx, command)
-	case types.CommandTypeRestart:
+	case types.CommandTypeRestart, types.CommandTypeRestartAgent:
 		result = e.executeRestartCommand(ctx, command)
+	case types.CommandTypeGetEvents:
+		result = e.executeGetEventsCommand(ctx, command)
+	case types.CommandTypeGetInventoryHistory:
+		result = e.executeGetInventoryHistoryCommand(ctx, command)
 	default:
 		result.Success = false
-		result.Error = fmt.Sprintf("tipo de comando desconhecido: %s", command.Type)
+		result.SetError(types.NewCodedError(types.ErrCodeUnsupportedCommandType, command.Type))
 	}
 
 	result.Duration = time.Since(startTime).Milliseconds()
@@ -95,7 +146,7 @@ func (e *Executor) executeShellCommand(ctx context.Context, command types.Comman
 	// Valida o comando
 	if command.Command == "" {
 		result.Success = false
-		result.Error = "comando vazio"
+		result.SetError(types.NewCodedError(types.ErrCodeEmptyCommand))
 		return result
 	}
 
@@ -103,7 +154,7 @@ func (e *Executor) executeShellCommand(ctx context.Context, command types.Comman
 	sanitizedCmd := e.sanitizeCommand(command.Command)
 	if sanitizedCmd == "" {
 		result.Success = false
-		result.Error = "comando contém caracteres perigosos"
+		result.SetError(types.NewCodedError(types.ErrCodeUnsafeCommand))
 		return result
 	}
 
@@ -125,10 +176,13 @@ func (e *Executor) executeShellCommand(ctx context.Context, command types.Comman
 		cmd = exec.CommandContext(ctx, "sh", "-c", sanitizedCmd)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output := newOutputBuffer(e.maxOutputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
 	if err != nil {
 		result.Success = false
-		result.Error = err.Error()
+		result.SetError(err)
 		if exitError, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitError.ExitCode()
 		}
@@ -137,7 +191,7 @@ func (e *Executor) executeShellCommand(ctx context.Context, command types.Comman
 		result.ExitCode = 0
 	}
 
-	result.Output = string(output)
+	setOutput(&result, output, false)
 	return result
 }
 
@@ -184,11 +238,13 @@ func (e *Executor) executeInfoCommand(ctx context.Context, command types.Command
 	output, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
 		result.Success = false
-		result.Error = fmt.Sprintf("erro ao serializar informações: %v", err)
+		result.SetError(types.NewCodedError(types.ErrCodeSerializationFailed, err))
 		return result
 	}
 
-	result.Output = string(output)
+	buffer := newOutputBuffer(e.maxOutputBytes)
+	buffer.Write(output)
+	setOutput(&result, buffer, true)
 	return result
 }
 
@@ -210,7 +266,7 @@ func (e *Executor) executePingCommand(ctx context.Context, command types.Command
 	target = strings.TrimSpace(target)
 	if target == "" {
 		result.Success = false
-		result.Error = "target de ping vazio"
+		result.SetError(types.NewCodedError(types.ErrCodeEmptyPingTarget))
 		return result
 	}
 
@@ -232,10 +288,13 @@ func (e *Executor) executePingCommand(ctx context.Context, command types.Command
 		cmd = exec.CommandContext(ctx, "ping", "-c", "4", target)
 	}
 
-	output, err := cmd.CombinedOutput()
+	output := newOutputBuffer(e.maxOutputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
 	if err != nil {
 		result.Success = false
-		result.Error = err.Error()
+		result.SetError(err)
 		if exitError, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitError.ExitCode()
 		}
@@ -244,7 +303,7 @@ func (e *Executor) executePingCommand(ctx context.Context, command types.Command
 		result.ExitCode = 0
 	}
 
-	result.Output = string(output)
+	setOutput(&result, output, false)
 	return result
 }
 
@@ -255,7 +314,7 @@ func (e *Executor) executeRestartCommand(ctx context.Context, command types.Comm
 		Timestamp: time.Now(),
 		Success:   true,
 		ExitCode:  0,
-		Output:    "Comando de restart recebido. O agente será reiniciado.",
+		Output:    "Restart command received. The agent will restart.",
 	}
 
 	// Nota: O restart será tratado pelo agente principal
@@ -264,12 +323,120 @@ func (e *Executor) executeRestartCommand(ctx context.Context, command types.Comm
 	return result
 }
 
+// executeGetEventsCommand retorna o histórico recente do agente em JSON.
+// Args opcionais: desde quando (RFC 3339; vazio retorna tudo) e quantos dos
+// mais recentes (padrão defaultGetEventsLimit).
+func (e *Executor) executeGetEventsCommand(ctx context.Context, command types.Command) types.CommandResult {
+	result := types.CommandResult{
+		ID:        command.ID,
+		Timestamp: time.Now(),
+		Success:   true,
+		ExitCode:  0,
+	}
+
+	var since time.Time
+	if len(command.Args) > 0 && command.Args[0] != "" {
+		parsed, err := time.Parse(time.RFC3339, command.Args[0])
+		if err != nil {
+			result.Success = false
+			result.SetError(types.NewCodedError(types.ErrCodeInvalidArgument, command.Args[0]))
+			return result
+		}
+		since = parsed
+	}
+
+	limit := defaultGetEventsLimit
+	if len(command.Args) > 1 {
+		parsed, err := strconv.Atoi(command.Args[1])
+		if err != nil || parsed <= 0 {
+			result.Success = false
+			result.SetError(types.NewCodedError(types.ErrCodeInvalidArgument, command.Args[1]))
+			return result
+		}
+		limit = parsed
+	}
+
+	events := []types.Event{}
+	if e.events != nil {
+		events = e.events(since, limit)
+	}
+
+	output, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		result.Success = false
+		result.SetError(types.NewCodedError(types.ErrCodeSerializationFailed, err))
+		return result
+	}
+
+	buffer := newOutputBuffer(e.maxOutputBytes)
+	buffer.Write(output)
+	setOutput(&result, buffer, true)
+	return result
+}
+
+// executeGetInventoryHistoryCommand consulta o histórico local de
+// inventários, para o backend preencher lacunas. Sem args ou com um número,
+// lista os mais recentes sem o conteúdo (padrão defaultInventoryHistoryLimit);
+// com um horário (RFC 3339), retorna o inventário mais recente até ele.
+func (e *Executor) executeGetInventoryHistoryCommand(ctx context.Context, command types.Command) types.CommandResult {
+	result := types.CommandResult{
+		ID:        command.ID,
+		Timestamp: time.Now(),
+		Success:   true,
+		ExitCode:  0,
+	}
+
+	if e.history == nil {
+		result.Success = false
+		result.SetError(types.NewCodedError(types.ErrCodeHistoryDisabled))
+		return result
+	}
+
+	arg := ""
+	if len(command.Args) > 0 {
+		arg = command.Args[0]
+	}
+
+	var response interface{}
+	var err error
+	if arg == "" {
+		response, err = e.history.GetInventoryHistory(defaultInventoryHistoryLimit)
+	} else if limit, convErr := strconv.Atoi(arg); convErr == nil && limit > 0 {
+		response, err = e.history.GetInventoryHistory(limit)
+	} else if at, parseErr := time.Parse(time.RFC3339, arg); parseErr == nil {
+		response, err = e.history.GetInventoryAt(at)
+	} else {
+		err = types.NewCodedError(types.ErrCodeInvalidArgument, arg)
+	}
+	if err != nil {
+		result.Success = false
+		result.SetError(err)
+		return result
+	}
+
+	output, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		result.Success = false
+		result.SetError(types.NewCodedError(types.ErrCodeSerializationFailed, err))
+		return result
+	}
+
+	buffer := newOutputBuffer(e.maxOutputBytes)
+	buffer.Write(output)
+	setOutput(&result, buffer, true)
+	return result
+}
+
 // isCommandAllowed verifica se o comando é permitido
 func (e *Executor) isCommandAllowed(commandType string) bool {
 	for _, allowed := range e.allowedCommands {
 		if allowed == commandType {
 			return true
 		}
+		// restart_agent herda a permissão de "restart" para configs existentes
+		if commandType == types.CommandTypeRestartAgent && allowed == types.CommandTypeRestart {
+			return true
+		}
 	}
 	return false
 }
@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// fakeHistory responde ao get_inventory_history com erros do catálogo
+type fakeHistory struct{}
+
+func (fakeHistory) GetInventoryHistory(limit int) ([]types.InventoryHistoryEntry, error) {
+	return nil, nil
+}
+
+func (fakeHistory) GetInventoryAt(at time.Time) (*types.InventoryHistoryEntry, error) {
+	return nil, types.NewCodedError(types.ErrCodeInventoryNotFound, at.Format(time.RFC3339))
+}
+
+func TestExecuteCommandRejectionsUseRegisteredCodes(t *testing.T) {
+	allowed := []string{
+		types.CommandTypeShell, types.CommandTypePing, types.CommandTypeGetEvents,
+		types.CommandTypeGetInventoryHistory, "bogus",
+	}
+
+	tests := []struct {
+		name    string
+		command types.Command
+		setup   func(*Executor) context.Context
+		want    types.ErrorCode
+	}{
+		{
+			name:    "not allowed",
+			command: types.Command{Type: types.CommandTypeInfo},
+			want:    types.ErrCodeCommandNotAllowed,
+		},
+		{
+			name:    "queue timeout",
+			command: types.Command{Type: types.CommandTypeShell, Command: "echo ok"},
+			setup: func(e *Executor) context.Context {
+				e.semaphore <- struct{}{}
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			want: types.ErrCodeExecutorQueueTimeout,
+		},
+		{
+			name:    "unsupported type",
+			command: types.Command{Type: "bogus"},
+			want:    types.ErrCodeUnsupportedCommandType,
+		},
+		{
+			name:    "empty command",
+			command: types.Command{Type: types.CommandTypeShell},
+			want:    types.ErrCodeEmptyCommand,
+		},
+		{
+			name:    "unsafe command",
+			command: types.Command{Type: types.CommandTypeShell, Command: "echo a; rm -rf /"},
+			want:    types.ErrCodeUnsafeCommand,
+		},
+		{
+			name:    "empty ping target",
+			command: types.Command{Type: types.CommandTypePing, Args: []string{"  "}},
+			want:    types.ErrCodeEmptyPingTarget,
+		},
+		{
+			name:    "get_events invalid since",
+			command: types.Command{Type: types.CommandTypeGetEvents, Args: []string{"yesterday"}},
+			want:    types.ErrCodeInvalidArgument,
+		},
+		{
+			name:    "get_events invalid limit",
+			command: types.Command{Type: types.CommandTypeGetEvents, Args: []string{"", "-1"}},
+			want:    types.ErrCodeInvalidArgument,
+		},
+		{
+			name:    "get_events serialization",
+			command: types.Command{Type: types.CommandTypeGetEvents},
+			setup: func(e *Executor) context.Context {
+				e.SetEventSource(func(time.Time, int) []types.Event {
+					return []types.Event{{Type: "broken", Data: map[string]interface{}{"ch": make(chan int)}}}
+				})
+				return context.Background()
+			},
+			want: types.ErrCodeSerializationFailed,
+		},
+		{
+			name:    "history disabled",
+			command: types.Command{Type: types.CommandTypeGetInventoryHistory},
+			want:    types.ErrCodeHistoryDisabled,
+		},
+		{
+			name:    "history invalid argument",
+			command: types.Command{Type: types.CommandTypeGetInventoryHistory, Args: []string{"last week"}},
+			setup: func(e *Executor) context.Context {
+				e.SetInventoryHistorySource(fakeHistory{})
+				return context.Background()
+			},
+			want: types.ErrCodeInvalidArgument,
+		},
+		{
+			name:    "history not found",
+			command: types.Command{Type: types.CommandTypeGetInventoryHistory, Args: []string{"2020-01-01T00:00:00Z"}},
+			setup: func(e *Executor) context.Context {
+				e.SetInventoryHistorySource(fakeHistory{})
+				return context.Background()
+			},
+			want: types.ErrCodeInventoryNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewExecutor(allowed, 1, 0)
+			ctx := context.Background()
+			if tt.setup != nil {
+				ctx = tt.setup(executor)
+			}
+
+			result := executor.ExecuteCommand(ctx, tt.command)
+			if result.Success {
+				t.Fatal("rejected command reported success")
+			}
+			if result.ErrorCode != tt.want {
+				t.Fatalf("error code = %q, want %q", result.ErrorCode, tt.want)
+			}
+			if !result.ErrorCode.IsRegistered() {
+				t.Fatalf("error code %q is not in the catalog", result.ErrorCode)
+			}
+			if result.Error == "" || result.LegacyError == "" {
+				t.Fatalf("error = %q, legacy = %q", result.Error, result.LegacyError)
+			}
+		})
+	}
+}
+
+func TestExecuteGetEventsCommand(t *testing.T) {
+	since := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	var gotSince time.Time
+	var gotLimit int
+	executor := NewExecutor([]string{types.CommandTypeGetEvents}, 1, 0)
+	executor.SetEventSource(func(since time.Time, limit int) []types.Event {
+		gotSince, gotLimit = since, limit
+		return []types.Event{{Timestamp: since.Add(time.Minute), Type: string(types.EventConnected), Message: types.EventConnected.Message()}}
+	})
+
+	result := executor.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeGetEvents, Args: []string{since.Format(time.RFC3339), "5"}})
+	if !result.Success {
+		t.Fatalf("result = %+v", result)
+	}
+	if !gotSince.Equal(since) || gotLimit != 5 {
+		t.Fatalf("source called with %s, %d", gotSince, gotLimit)
+	}
+	var events []types.Event
+	if err := json.Unmarshal([]byte(result.Output), &events); err != nil {
+		t.Fatalf("output %q: %v", result.Output, err)
+	}
+	if len(events) != 1 || events[0].Type != string(types.EventConnected) || !events[0].Timestamp.Equal(since.Add(time.Minute)) {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// Sem args: todo o histórico, até o limite padrão
+	executor.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeGetEvents})
+	if !gotSince.IsZero() || gotLimit != defaultGetEventsLimit {
+		t.Fatalf("defaults = %s, %d", gotSince, gotLimit)
+	}
+
+	// Sem fonte, lista vazia em vez de null
+	empty := NewExecutor([]string{types.CommandTypeGetEvents}, 1, 0)
+	if result := empty.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeGetEvents}); !result.Success || result.Output != "[]" {
+		t.Fatalf("without a source = %+v", result)
+	}
+}
+
+func TestExecuteCommandFailureWithoutCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh exit status")
+	}
+
+	executor := NewExecutor([]string{types.CommandTypeShell}, 1, 0)
+	result := executor.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeShell, Command: "exit 3"})
+	if result.Success || result.ExitCode != 3 {
+		t.Fatalf("success = %t, exit code = %d", result.Success, result.ExitCode)
+	}
+	if result.ErrorCode != types.ErrCodeExecutionFailed {
+		t.Fatalf("error code = %q, want %q", result.ErrorCode, types.ErrCodeExecutionFailed)
+	}
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"sync"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// executionMetrics acumula as execuções para GetMetrics
+type executionMetrics struct {
+	mu            sync.Mutex
+	totals        types.ExecutionMetrics
+	totalDuration int64
+	byType        map[string]*commandTypeTotals
+}
+
+// commandTypeTotals acumulado de um tipo de comando
+type commandTypeTotals struct {
+	stats         types.CommandTypeStats
+	totalDuration int64
+}
+
+// record registra um resultado; comandos recusados antes de executar
+// contam também como rejeitados
+func (m *executionMetrics) record(commandType string, result types.CommandResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.totals.TotalCommands++
+	if result.Success {
+		m.totals.SuccessfulCommands++
+	} else {
+		m.totals.FailedCommands++
+	}
+	switch result.ErrorCode {
+	case types.ErrCodeCommandNotAllowed, types.ErrCodeExecutorQueueTimeout:
+		m.totals.RejectedCommands++
+	}
+	m.totalDuration += result.Duration
+	m.totals.LastCommandAt = result.Timestamp
+
+	if m.byType == nil {
+		m.byType = make(map[string]*commandTypeTotals)
+	}
+	totals, ok := m.byType[commandType]
+	if !ok {
+		totals = &commandTypeTotals{}
+		m.byType[commandType] = totals
+	}
+	totals.stats.Count++
+	if !result.Success {
+		totals.stats.Failures++
+	}
+	totals.totalDuration += result.Duration
+	if result.Duration > totals.stats.MaxDuration {
+		totals.stats.MaxDuration = result.Duration
+	}
+	totals.stats.LastRun = result.Timestamp
+}
+
+// snapshot retorna uma cópia com as médias calculadas
+func (m *executionMetrics) snapshot() types.ExecutionMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := m.totals
+	if metrics.TotalCommands > 0 {
+		metrics.AverageDuration = m.totalDuration / metrics.TotalCommands
+	}
+	metrics.ByType = make(map[string]types.CommandTypeStats, len(m.byType))
+	for commandType, totals := range m.byType {
+		stats := totals.stats
+		stats.AverageDuration = totals.totalDuration / stats.Count
+		metrics.ByType[commandType] = stats
+	}
+	return metrics
+}
+
+// GetMetrics retorna as métricas de execução, com a ocupação atual do
+// executor
+func (e *Executor) GetMetrics() types.ExecutionMetrics {
+	metrics := e.metrics.snapshot()
+	metrics.RunningCommands = len(e.semaphore)
+	metrics.MaxConcurrency = e.maxConcurrency
+	return metrics
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"unicode/utf8"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMaxOutputBytes limite padrão da saída de um comando
+const DefaultMaxOutputBytes = 1024 * 1024 // 1MB
+
+// outputBuffer guarda até max bytes da saída e descarta o restante,
+// contando o total produzido pelo processo
+type outputBuffer struct {
+	max   int
+	data  []byte
+	total int
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{max: max}
+}
+
+// Write nunca falha, para não interromper o processo ao atingir o limite
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := b.max - len(b.data); room > 0 {
+		if len(p) > room {
+			b.data = append(b.data, p[:room]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+	return len(p), nil
+}
+
+// trimPartialRune remove um caractere UTF-8 incompleto no fim dos dados
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// setOutput preenche Output respeitando o limite, sem texto de marcação.
+// Saídas JSON que excedem o limite são omitidas em vez de enviadas quebradas.
+func setOutput(result *types.CommandResult, buffer *outputBuffer, structured bool) {
+	if buffer.total <= len(buffer.data) {
+		result.Output = string(buffer.data)
+		return
+	}
+
+	result.OutputTruncated = true
+	result.OriginalOutputBytes = buffer.total
+
+	if structured {
+		log.Warn().
+			Str("command_id", result.ID).
+			Int("output_bytes", buffer.total).
+			Int("max_output_bytes", buffer.max).
+			Msg("Saída JSON excede o limite e foi omitida")
+		return
+	}
+
+	result.Output = string(trimPartialRune(buffer.data))
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"unicode/utf8"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestOutputBufferLimit(t *testing.T) {
+	buffer := newOutputBuffer(8)
+	for _, chunk := range []string{"abc", "defgh", "ijk", "lmnop"} {
+		if n, err := buffer.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if string(buffer.data) != "abcdefgh" || buffer.total != 16 {
+		t.Fatalf("buffer kept %q of %d bytes", buffer.data, buffer.total)
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := map[string]string{
+		"abc":           "abc",
+		"aé":            "aé",
+		"a\xc3":         "a",
+		"a😀":            "a😀",
+		"a\xf0\x9f\x98": "a",
+		"":              "",
+	}
+	for data, want := range tests {
+		if got := string(trimPartialRune([]byte(data))); got != want {
+			t.Errorf("trimPartialRune(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	write := func(max int, data string) *outputBuffer {
+		buffer := newOutputBuffer(max)
+		buffer.Write([]byte(data))
+		return buffer
+	}
+
+	var result types.CommandResult
+	setOutput(&result, write(64, "ok\n"), false)
+	if result.Output != "ok\n" || result.OutputTruncated || result.OriginalOutputBytes != 0 {
+		t.Fatalf("untruncated output changed: %+v", result)
+	}
+
+	// "ação" ocupa 6 bytes; o limite de 4 corta o "ã" ao meio
+	result = types.CommandResult{}
+	setOutput(&result, write(4, "ação ok"), false)
+	if result.Output != "aç" || !utf8.ValidString(result.Output) {
+		t.Fatalf("truncated output %q, want %q", result.Output, "aç")
+	}
+	if !result.OutputTruncated || result.OriginalOutputBytes != len("ação ok") {
+		t.Fatalf("truncation not flagged: %+v", result)
+	}
+
+	result = types.CommandResult{}
+	setOutput(&result, write(8, `{"items":[1,2,3,4,5]}`), true)
+	if result.Output != "" {
+		t.Fatalf("partial JSON sent: %q", result.Output)
+	}
+	if !result.OutputTruncated || result.OriginalOutputBytes != 21 {
+		t.Fatalf("JSON truncation not flagged: %+v", result)
+	}
+}
+
+func TestExecuteCommandTruncatesOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh echo")
+	}
+
+	executor := NewExecutor([]string{types.CommandTypeShell, types.CommandTypeInfo}, 1, 5)
+
+	result := executor.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeShell, Command: "echo olá mundo"})
+	if !result.Success {
+		t.Fatalf("shell command failed: %s", result.Error)
+	}
+	// "olá mundo\n" tem 11 bytes; o corte em 5 fica depois do "á" completo
+	if result.Output != "olá " || !result.OutputTruncated || result.OriginalOutputBytes != 11 {
+		t.Fatalf("output %q, truncated %t, original %d", result.Output, result.OutputTruncated, result.OriginalOutputBytes)
+	}
+
+	info := executor.ExecuteCommand(context.Background(), types.Command{Type: types.CommandTypeInfo})
+	if !info.Success || info.Output != "" || !info.OutputTruncated || info.OriginalOutputBytes <= 5 {
+		t.Fatalf("oversized info output: success %t, output %q, truncated %t, original %d",
+			info.Success, info.Output, info.OutputTruncated, info.OriginalOutputBytes)
+	}
+
+	if NewExecutor(nil, 1, 0).maxOutputBytes != DefaultMaxOutputBytes {
+		t.Fatal("zero limit does not fall back to DefaultMaxOutputBytes")
+	}
+}
@@ -0,0 +1,259 @@
+package i18n
+
+// catalogs contém as mensagens por idioma. O inglês é a referência: toda
+// chave nova deve existir nele; os demais idiomas podem omitir chaves.
+var catalogs = map[string]map[string]string{
+	LangEnglish: {
+		// Estados do agente
+		"state.starting": "Starting",
+		"state.running":  "Running",
+		"state.stopping": "Stopping",
+		"state.stopped":  "Stopped",
+		"state.error":    "Error",
+		"state.unknown":  "Unknown",
+
+		// Tray
+		"tray.title":           "Machine Monitor",
+		"tray.tooltip":         "Machine Monitor Agent",
+		"tray.tooltip.details": "Machine Monitor Agent\nStatus: %s\nUptime: %s\nCommands: %d\nErrors: %d",
+		"tray.tooltip.url":     "Interface: %s",
+		"tray.status":          "Status: %s",
+		"tray.status.initial":  "Status: Starting...",
+		"tray.status.tooltip":  "Current agent status",
+		"tray.open_ui":         "Open Interface",
+		"tray.open_ui.tooltip": "Opens the agent web interface",
+		"tray.restart":         "Restart Agent",
+		"tray.restart.tooltip": "Restarts the agent",
+		"tray.exit":            "Quit",
+		"tray.exit.tooltip":    "Closes the agent",
+
+		// Interface web
+		"webui.loading":             "Loading...",
+		"webui.refresh":             "Refresh",
+		"webui.never":               "Never",
+		"webui.not_available":       "N/A",
+		"webui.card.agent":          "Agent Status",
+		"webui.card.system":         "System",
+		"webui.card.cpu":            "CPU",
+		"webui.card.memory":         "Memory",
+		"webui.card.disk":           "Disk",
+		"webui.card.network":        "Network",
+		"webui.card.events":         "Recent Events",
+		"webui.card.diagnostics":    "Diagnostics",
+		"webui.diag.commands":       "Commands (ok / failed / rejected)",
+		"webui.diag.running":        "Running",
+		"webui.diag.avg_duration":   "Average Duration",
+		"webui.diag.http":           "HTTP Requests (failed)",
+		"webui.diag.http_latency":   "HTTP Latency",
+		"webui.diag.websocket":      "WebSocket",
+		"webui.diag.connected":      "Connected",
+		"webui.diag.disconnected":   "Disconnected",
+		"webui.diag.connections":    "Connections (failed)",
+		"webui.diag.command_queue":  "Command Queue",
+		"webui.diag.result_queue":   "Result Queue",
+		"webui.diag.dropped":        "Dropped Commands",
+		"webui.diag.last_error":     "Last Error",
+		"webui.live":                "Live",
+		"webui.polling":             "Polling",
+		"webui.no_events":           "No events",
+		"event.connected":           "WebSocket connected",
+		"event.connection_failed":   "WebSocket connection failed",
+		"event.disconnected":        "WebSocket disconnected",
+		"event.state_changed":       "Agent state changed",
+		"event.inventory_sent":      "Inventory sent",
+		"event.inventory_failed":    "Inventory failed",
+		"event.command_received":    "Command received",
+		"event.command_executed":    "Command executed",
+		"event.command_rejected":    "Command rejected",
+		"webui.state":               "State",
+		"webui.uptime":              "Uptime",
+		"webui.commands_run":        "Commands Run",
+		"webui.errors":              "Errors",
+		"webui.last_heartbeat":      "Last Heartbeat",
+		"webui.last_inventory":      "Last Inventory",
+		"webui.last_command":        "Last Command",
+		"webui.last_output":         "Last Output",
+		"webui.output_truncated":    "truncated",
+		"webui.success":             "Success",
+		"webui.failed":              "Failed",
+		"webui.os":                  "Operating System",
+		"webui.platform":            "Platform",
+		"webui.hostname":            "Hostname",
+		"webui.processes":           "Processes",
+		"webui.logged_users":        "Logged-in Users",
+		"webui.model":               "Model",
+		"webui.cores":               "Cores",
+		"webui.threads":             "Threads",
+		"webui.frequency":           "Frequency",
+		"webui.usage":               "Usage",
+		"webui.total":               "Total",
+		"webui.used":                "Used",
+		"webui.available":           "Available",
+		"webui.free":                "Free",
+		"webui.device":              "Device",
+		"webui.mountpoint":          "Mount Point",
+		"webui.fstype":              "Type",
+		"webui.disk_io":             "Disk I/O",
+		"webui.read_rate":           "Read",
+		"webui.write_rate":          "Write",
+		"webui.interface":           "Interface",
+		"webui.mac":                 "MAC",
+		"webui.addresses":           "Addresses",
+		"webui.bytes_sent":          "Bytes Sent",
+		"webui.bytes_recv":          "Bytes Received",
+		"webui.error.template":      "Template error",
+		"webui.error.system_info":   "Failed to collect system information",
+		"webui.error.hardware_info": "Failed to collect hardware information",
+		"webui.error.unauthorized":  "Unauthorized",
+		"webui.error.rate_limited":  "Too many failed attempts; try again later",
+		"webui.login.title":         "Sign in",
+		"webui.login.token":         "Access token",
+		"webui.login.user":          "User",
+		"webui.login.password":      "Password",
+		"webui.login.submit":        "Sign in",
+		"webui.login.failed":        "Invalid credentials",
+
+		"webui.card.security":         "Security",
+		"webui.security.firewall":     "Firewall",
+		"webui.security.screen_lock":  "Screen Lock",
+		"webui.security.sip":          "System Integrity Protection",
+		"webui.security.gatekeeper":   "Gatekeeper",
+		"webui.security.remote_login": "Remote Login",
+		"webui.security.on":           "On",
+		"webui.security.off":          "Off",
+		"webui.security.unknown":      "Unknown",
+		"webui.error.security":        "Failed to collect security posture",
+		"webui.error.history":         "Failed to read the inventory history",
+
+		// Códigos de erro de CommandResult (types.ErrorCode)
+		"error.command_not_allowed":      "Command not allowed",
+		"error.executor_queue_timeout":   "Timed out waiting for an execution slot",
+		"error.unsupported_command_type": "Unsupported command type",
+		"error.empty_command":            "Empty command",
+		"error.unsafe_command":           "Command contains dangerous characters",
+		"error.empty_ping_target":        "Empty ping target",
+		"error.serialization_failed":     "Failed to serialize information",
+		"error.execution_failed":         "Command execution failed",
+		"error.invalid_argument":         "Invalid argument",
+		"error.history_disabled":         "Inventory history is disabled",
+		"error.inventory_not_found":      "No inventory recorded for that time",
+	},
+
+	LangPortuguese: {
+		"state.starting": "Iniciando",
+		"state.running":  "Executando",
+		"state.stopping": "Parando",
+		"state.stopped":  "Parado",
+		"state.error":    "Erro",
+		"state.unknown":  "Desconhecido",
+
+		"tray.tooltip.details": "Machine Monitor Agent\nStatus: %s\nUptime: %s\nComandos: %d\nErros: %d",
+		"tray.status.initial":  "Status: Iniciando...",
+		"tray.status.tooltip":  "Status atual do agente",
+		"tray.open_ui":         "Abrir Interface",
+		"tray.open_ui.tooltip": "Abre a interface web do agente",
+		"tray.restart":         "Reiniciar Agente",
+		"tray.restart.tooltip": "Reinicia o agente",
+		"tray.exit":            "Sair",
+		"tray.exit.tooltip":    "Fecha o agente",
+
+		"webui.loading":             "Carregando...",
+		"webui.refresh":             "Atualizar",
+		"webui.never":               "Nunca",
+		"webui.card.agent":          "Status do Agente",
+		"webui.card.system":         "Sistema",
+		"webui.card.memory":         "Memória",
+		"webui.card.disk":           "Disco",
+		"webui.card.network":        "Rede",
+		"webui.card.events":         "Eventos Recentes",
+		"webui.card.diagnostics":    "Diagnóstico",
+		"webui.diag.commands":       "Comandos (ok / falha / recusados)",
+		"webui.diag.running":        "Em Execução",
+		"webui.diag.avg_duration":   "Duração Média",
+		"webui.diag.http":           "Requisições HTTP (falhas)",
+		"webui.diag.http_latency":   "Latência HTTP",
+		"webui.diag.connected":      "Conectado",
+		"webui.diag.disconnected":   "Desconectado",
+		"webui.diag.connections":    "Conexões (falhas)",
+		"webui.diag.command_queue":  "Fila de Comandos",
+		"webui.diag.result_queue":   "Fila de Resultados",
+		"webui.diag.dropped":        "Comandos Descartados",
+		"webui.diag.last_error":     "Último Erro",
+		"webui.live":                "Ao vivo",
+		"webui.polling":             "Polling",
+		"webui.no_events":           "Nenhum evento",
+		"event.connected":           "WebSocket conectado",
+		"event.connection_failed":   "Falha ao conectar WebSocket",
+		"event.disconnected":        "WebSocket desconectado",
+		"event.state_changed":       "Estado do agente alterado",
+		"event.inventory_sent":      "Inventário enviado",
+		"event.inventory_failed":    "Falha no inventário",
+		"event.command_received":    "Comando recebido",
+		"event.command_executed":    "Comando executado",
+		"event.command_rejected":    "Comando recusado",
+		"webui.state":               "Estado",
+		"webui.commands_run":        "Comandos Executados",
+		"webui.errors":              "Erros",
+		"webui.last_heartbeat":      "Último Heartbeat",
+		"webui.last_inventory":      "Último Inventário",
+		"webui.last_command":        "Último Comando",
+		"webui.last_output":         "Última Saída",
+		"webui.output_truncated":    "truncada",
+		"webui.success":             "Sucesso",
+		"webui.failed":              "Falhou",
+		"webui.os":                  "Sistema Operacional",
+		"webui.platform":            "Plataforma",
+		"webui.processes":           "Processos",
+		"webui.logged_users":        "Usuários Logados",
+		"webui.model":               "Modelo",
+		"webui.frequency":           "Frequência",
+		"webui.usage":               "Uso",
+		"webui.used":                "Usado",
+		"webui.available":           "Disponível",
+		"webui.free":                "Livre",
+		"webui.device":              "Dispositivo",
+		"webui.mountpoint":          "Ponto de Montagem",
+		"webui.fstype":              "Tipo",
+		"webui.disk_io":             "E/S de disco",
+		"webui.read_rate":           "Leitura",
+		"webui.write_rate":          "Escrita",
+		"webui.addresses":           "Endereços",
+		"webui.bytes_sent":          "Bytes Enviados",
+		"webui.bytes_recv":          "Bytes Recebidos",
+		"webui.error.template":      "Erro no template",
+		"webui.error.system_info":   "Erro ao coletar informações do sistema",
+		"webui.error.hardware_info": "Erro ao coletar informações de hardware",
+		"webui.error.unauthorized":  "Não autorizado",
+		"webui.error.rate_limited":  "Muitas tentativas inválidas; tente novamente mais tarde",
+		"webui.login.title":         "Entrar",
+		"webui.login.token":         "Token de acesso",
+		"webui.login.user":          "Usuário",
+		"webui.login.password":      "Senha",
+		"webui.login.submit":        "Entrar",
+		"webui.login.failed":        "Credenciais inválidas",
+
+		"webui.card.security":         "Segurança",
+		"webui.security.firewall":     "Firewall",
+		"webui.security.screen_lock":  "Bloqueio de Tela",
+		"webui.security.sip":          "Proteção da Integridade do Sistema",
+		"webui.security.gatekeeper":   "Gatekeeper",
+		"webui.security.remote_login": "Acesso Remoto",
+		"webui.security.on":           "Ligado",
+		"webui.security.off":          "Desligado",
+		"webui.security.unknown":      "Desconhecido",
+		"webui.error.security":        "Erro ao coletar a postura de segurança",
+		"webui.error.history":         "Erro ao ler o histórico de inventário",
+
+		"error.command_not_allowed":      "Comando não permitido",
+		"error.executor_queue_timeout":   "Timeout ao aguardar slot de execução",
+		"error.unsupported_command_type": "Tipo de comando desconhecido",
+		"error.empty_command":            "Comando vazio",
+		"error.unsafe_command":           "Comando contém caracteres perigosos",
+		"error.empty_ping_target":        "Target de ping vazio",
+		"error.serialization_failed":     "Erro ao serializar informações",
+		"error.execution_failed":         "Falha na execução do comando",
+		"error.invalid_argument":         "Argumento inválido",
+		"error.history_disabled":         "Histórico de inventário desabilitado",
+		"error.inventory_not_found":      "Nenhum inventário registrado para esse horário",
+	},
+}
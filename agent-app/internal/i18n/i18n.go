@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Idiomas suportados pela UI
+const (
+	LangEnglish    = "en"
+	LangPortuguese = "pt-BR"
+)
+
+// Catalog traduz as mensagens exibidas no tray e na interface web.
+// Strings enviadas ao backend não passam por aqui: são sempre inglês.
+type Catalog struct {
+	lang     string
+	messages map[string]string
+}
+
+// New cria o catálogo do idioma informado; vazio ou "auto" usa o idioma do sistema
+func New(lang string) *Catalog {
+	if lang == "" || strings.EqualFold(lang, "auto") {
+		lang = DetectLocale()
+	}
+	lang = Normalize(lang)
+
+	messages := make(map[string]string, len(catalogs[LangEnglish]))
+	for key, value := range catalogs[LangEnglish] {
+		messages[key] = value
+	}
+	for key, value := range catalogs[lang] {
+		messages[key] = value
+	}
+
+	return &Catalog{lang: lang, messages: messages}
+}
+
+// Lang retorna o idioma efetivo do catálogo
+func (c *Catalog) Lang() string {
+	return c.lang
+}
+
+// T traduz a chave, formatando com os argumentos quando informados.
+// Chaves ausentes retornam a própria chave.
+func (c *Catalog) T(key string, args ...interface{}) string {
+	message, ok := c.messages[key]
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Messages retorna todas as mensagens do idioma (com fallback para inglês),
+// para uso no JavaScript da interface web
+func (c *Catalog) Messages() map[string]string {
+	messages := make(map[string]string, len(c.messages))
+	for key, value := range c.messages {
+		messages[key] = value
+	}
+	return messages
+}
+
+// Normalize converte identificadores de locale (pt_BR.UTF-8, pt-PT, en_US)
+// para um idioma suportado; desconhecidos caem para inglês
+func Normalize(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if strings.HasPrefix(locale, "pt") {
+		return LangPortuguese
+	}
+	return LangEnglish
+}
+
+// DetectLocale obtém o idioma do sistema: variáveis LC_ALL, LC_MESSAGES e LANG,
+// depois as preferências da plataforma
+func DetectLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" && value != "C" && value != "POSIX" {
+			return value
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		if output, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output(); err == nil {
+			return strings.TrimSpace(string(output))
+		}
+	case "windows":
+		output, err := exec.Command("reg", "query", `HKCU\Control Panel\International`, "/v", "LocaleName").Output()
+		if err == nil {
+			// Saída: "    LocaleName    REG_SZ    pt-BR"
+			fields := strings.Fields(string(output))
+			if len(fields) > 0 {
+				return fields[len(fields)-1]
+			}
+		}
+	}
+
+	return LangEnglish
+}
@@ -0,0 +1,277 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// backupTimeFormat sufixo dos backups: agent.log → agent-2006-01-02T15-04-05.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateOptions controla a rotação de RotatingFile
+type RotateOptions struct {
+	// MaxBytes tamanho a partir do qual o arquivo é rotacionado; <= 0 desativa
+	MaxBytes int64
+	// MaxBackups backups mantidos; <= 0 mantém todos
+	MaxBackups int
+	// MaxAge idade máxima dos backups; <= 0 não remove por idade
+	MaxAge time.Duration
+	// Compress compacta os backups com gzip
+	Compress bool
+}
+
+// RotatingFile é um arquivo de log rotacionado por tamanho. Ao passar de
+// MaxBytes, o arquivo ativo é renomeado com a hora da rotação e um novo é
+// aberto no mesmo caminho; compactação e limpeza dos backups rodam em
+// segundo plano, fora do lock, para não atrasar quem está logando. Write é
+// seguro entre goroutines e nenhuma linha se perde na troca de arquivo.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	lastBackup time.Time
+
+	// millMu serializa compactação e limpeza; mills acompanha as pendentes
+	millMu sync.Mutex
+	mills  sync.WaitGroup
+}
+
+// OpenRotatingFile abre (ou cria) o arquivo de log em path
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("erro ao criar diretório de log: %w", err)
+	}
+
+	r := &RotatingFile{path: path, opts: opts, now: time.Now}
+	if err := r.openLocked(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write grava p no arquivo ativo, rotacionando antes se p o faria passar de
+// MaxBytes. Uma escrita maior que MaxBytes vai inteira para um arquivo novo.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.openLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.opts.MaxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxBytes {
+		if err := r.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate força uma rotação (por exemplo, a pedido do operador)
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotateLocked()
+}
+
+// Close fecha o arquivo ativo e espera a compactação e a limpeza pendentes
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.mills.Wait()
+	return err
+}
+
+// openLocked abre o caminho configurado para acrescentar linhas
+func (r *RotatingFile) openLocked() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir arquivo de log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("erro ao ler arquivo de log: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotateLocked renomeia o arquivo ativo para um backup com a hora atual,
+// abre um novo e agenda a compactação e a limpeza
+func (r *RotatingFile) rotateLocked() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	// O nome tem resolução de milissegundo; duas rotações no mesmo
+	// milissegundo sobrescreveriam o backup anterior
+	stamp := r.now().Truncate(time.Millisecond)
+	if !stamp.After(r.lastBackup) {
+		stamp = r.lastBackup.Add(time.Millisecond)
+	}
+	r.lastBackup = stamp
+
+	if err := os.Rename(r.path, r.backupName(stamp)); err != nil && !os.IsNotExist(err) {
+		// Sem renomear, continua no mesmo arquivo em vez de perder linhas
+		if openErr := r.openLocked(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("erro ao rotacionar arquivo de log: %w", err)
+	}
+	if err := r.openLocked(); err != nil {
+		return err
+	}
+
+	r.mills.Add(1)
+	go r.mill()
+	return nil
+}
+
+// backupName nome do backup criado em t
+func (r *RotatingFile) backupName(t time.Time) string {
+	dir, prefix, ext := r.nameParts()
+	return filepath.Join(dir, prefix+t.Format(backupTimeFormat)+ext)
+}
+
+// nameParts separa diretório, prefixo dos backups ("agent-") e extensão
+func (r *RotatingFile) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(r.path)
+	base := filepath.Base(r.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// logBackup um backup encontrado no diretório
+type logBackup struct {
+	path      string
+	createdAt time.Time
+	gzipped   bool
+}
+
+// listBackups retorna os backups do arquivo, do mais novo para o mais antigo
+func (r *RotatingFile) listBackups() ([]logBackup, error) {
+	dir, prefix, ext := r.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []logBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		gzipped := strings.HasSuffix(stamp, ext+".gz")
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+
+		createdAt, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue // outro arquivo com o mesmo prefixo
+		}
+		backups = append(backups, logBackup{
+			path:      filepath.Join(dir, name),
+			createdAt: createdAt,
+			gzipped:   gzipped,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].createdAt.After(backups[j].createdAt) })
+	return backups, nil
+}
+
+// mill remove os backups além de MaxBackups ou mais velhos que MaxAge e
+// compacta os restantes quando Compress está ativo
+func (r *RotatingFile) mill() {
+	defer r.mills.Done()
+
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	backups, err := r.listBackups()
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao listar backups do log")
+		return
+	}
+
+	cutoff := time.Time{}
+	if r.opts.MaxAge > 0 {
+		cutoff = r.now().Add(-r.opts.MaxAge)
+	}
+
+	for i, backup := range backups {
+		tooMany := r.opts.MaxBackups > 0 && i >= r.opts.MaxBackups
+		tooOld := !cutoff.IsZero() && backup.createdAt.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(backup.path)
+			continue
+		}
+		if r.opts.Compress && !backup.gzipped {
+			if err := compressFile(backup.path); err != nil {
+				log.Error().Err(err).Str("file", backup.path).Msg("Erro ao compactar backup do log")
+			}
+		}
+	}
+}
+
+// compressFile grava path.gz e remove path; uma falha não deixa um .gz
+// incompleto para trás
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
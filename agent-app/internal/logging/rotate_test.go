@@ -0,0 +1,236 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestRotatingFile abre agent.log num diretório temporário, com um
+// relógio que avança um segundo a cada rotação
+func openTestRotatingFile(t *testing.T, opts RotateOptions) (*RotatingFile, string) {
+	t.Helper()
+	dir := t.TempDir()
+	r, err := OpenRotatingFile(filepath.Join(dir, "agent.log"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.Local)
+	r.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Second)
+		return now
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, dir
+}
+
+// logFiles lista os arquivos do diretório, em ordem de nome
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readLogFile lê um arquivo de log, descompactando os .gz
+func readLogFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return string(data)
+}
+
+func TestRotatingFileRotatesPastMaxBytes(t *testing.T) {
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 100})
+
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(r, "line %02d %s\n", i, strings.Repeat("x", 20))
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 30 bytes por linha: três por arquivo, nenhum acima de MaxBytes
+	files := logFiles(t, dir)
+	if len(files) != 4 || files[len(files)-1] != "agent.log" {
+		t.Fatalf("files %v", files)
+	}
+	var all strings.Builder
+	for _, name := range files {
+		content := readLogFile(t, filepath.Join(dir, name))
+		if len(content) > 100 {
+			t.Errorf("%s has %d bytes", name, len(content))
+		}
+		if name != "agent.log" && !strings.HasPrefix(name, "agent-2026-01-05T09-00-") {
+			t.Errorf("backup name %s", name)
+		}
+		all.WriteString(content)
+	}
+
+	// Backups em ordem cronológica seguidos do ativo: nenhuma linha perdida
+	for i := 0; i < 10; i++ {
+		if !strings.Contains(all.String(), fmt.Sprintf("line %02d ", i)) {
+			t.Fatalf("line %d lost", i)
+		}
+	}
+	if !strings.HasPrefix(all.String(), "line 00 ") || strings.Count(all.String(), "\n") != 10 {
+		t.Fatalf("content out of order:\n%s", all.String())
+	}
+}
+
+func TestRotatingFileOversizedWrite(t *testing.T) {
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 10})
+
+	// Maior que MaxBytes: vai inteira para um arquivo novo
+	fmt.Fprintln(r, "short")
+	fmt.Fprintln(r, strings.Repeat("y", 50))
+	r.Close()
+
+	if files := logFiles(t, dir); len(files) != 2 {
+		t.Fatalf("files %v", files)
+	}
+	if content := readLogFile(t, filepath.Join(dir, "agent.log")); content != strings.Repeat("y", 50)+"\n" {
+		t.Fatalf("active file %q", content)
+	}
+}
+
+func TestRotatingFilePrunesBackups(t *testing.T) {
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 10, MaxBackups: 2})
+
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(r, "line %d....\n", i)
+	}
+	r.Close()
+
+	// Ficam os dois backups mais novos
+	files := logFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("files %v", files)
+	}
+	if got := readLogFile(t, filepath.Join(dir, files[0])); got != "line 3....\n" {
+		t.Fatalf("oldest kept backup %q", got)
+	}
+	if got := readLogFile(t, filepath.Join(dir, files[1])); got != "line 4....\n" {
+		t.Fatalf("newest backup %q", got)
+	}
+}
+
+func TestRotatingFilePrunesOldBackups(t *testing.T) {
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 10, MaxAge: 24 * time.Hour})
+
+	// Backups de execuções anteriores: um com mais de um dia, outro recente,
+	// e um arquivo que só compartilha o prefixo
+	old := time.Date(2026, 1, 3, 9, 0, 0, 0, time.Local).Format(backupTimeFormat)
+	recent := time.Date(2026, 1, 5, 8, 0, 0, 0, time.Local).Format(backupTimeFormat)
+	for _, name := range []string{"agent-" + old + ".log.gz", "agent-" + recent + ".log", "agent-notes.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fmt.Fprintln(r, "first.....")
+	fmt.Fprintln(r, "second....")
+	r.Close()
+
+	files := logFiles(t, dir)
+	for _, name := range files {
+		if strings.Contains(name, old) {
+			t.Fatalf("backup older than MaxAge kept: %v", files)
+		}
+	}
+	if len(files) != 4 {
+		t.Fatalf("files %v", files)
+	}
+}
+
+func TestRotatingFileCompressesBackups(t *testing.T) {
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 10, Compress: true})
+
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(r, "line %d....\n", i)
+	}
+	r.Close()
+
+	files := logFiles(t, dir)
+	if len(files) != 3 {
+		t.Fatalf("files %v", files)
+	}
+	for i, name := range files[:2] {
+		if !strings.HasSuffix(name, ".log.gz") {
+			t.Fatalf("backup %s not compressed", name)
+		}
+		if got := readLogFile(t, filepath.Join(dir, name)); got != fmt.Sprintf("line %d....\n", i) {
+			t.Fatalf("%s holds %q", name, got)
+		}
+	}
+}
+
+func TestRotatingFileConcurrentWriters(t *testing.T) {
+	const writers, lines = 8, 200
+	r, dir := openTestRotatingFile(t, RotateOptions{MaxBytes: 1024, MaxBackups: 1000})
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				fmt.Fprintf(r, "writer %d line %03d\n", w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	r.Close()
+
+	// Cada linha aparece inteira e uma única vez, em algum dos arquivos
+	seen := make(map[string]int)
+	for _, name := range logFiles(t, dir) {
+		content := readLogFile(t, filepath.Join(dir, name))
+		if len(content) > 1024 {
+			t.Errorf("%s has %d bytes", name, len(content))
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+			seen[line]++
+		}
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < lines; i++ {
+			line := fmt.Sprintf("writer %d line %03d", w, i)
+			if seen[line] != 1 {
+				t.Fatalf("%q written %d times", line, seen[line])
+			}
+		}
+	}
+	if len(seen) != writers*lines {
+		t.Fatalf("%d distinct lines, want %d", len(seen), writers*lines)
+	}
+}
@@ -0,0 +1,193 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/rs/zerolog"
+)
+
+// shipSendTimeout limita cada envio de lote ao backend
+const shipSendTimeout = 30 * time.Second
+
+// ShipFunc entrega um lote de logs ao backend
+type ShipFunc func(ctx context.Context, batch *types.LogBatch) error
+
+// Shipper é um zerolog.LevelWriter que junta as linhas a partir de um nível
+// e as envia em lotes ao backend. A escrita nunca bloqueia quem loga: com o
+// buffer cheio a linha é descartada e contada. Lotes recusados voltam para
+// o próximo envio enquanto couberem no buffer, cobrindo quedas curtas do
+// backend.
+type Shipper struct {
+	level         zerolog.Level
+	batchSize     int
+	bufferSize    int
+	flushInterval time.Duration
+	lines         chan []byte
+
+	send    ShipFunc
+	started atomic.Bool
+	once    sync.Once
+	done    chan struct{}
+	stopped chan struct{}
+
+	shipped atomic.Int64
+	dropped atomic.Int64
+	// unreported são as descartadas ainda não informadas em LogBatch.Dropped
+	unreported atomic.Int64
+}
+
+// NewShipper cria o shipper; as linhas ficam no buffer até Start
+func NewShipper(cfg types.LogShippingConfig) *Shipper {
+	level, err := zerolog.ParseLevel(cfg.Level)
+	if err != nil || level == zerolog.NoLevel {
+		level = zerolog.WarnLevel
+	}
+	return &Shipper{
+		level:         level,
+		batchSize:     cfg.BatchSize,
+		bufferSize:    cfg.BufferSize,
+		flushInterval: cfg.FlushInterval.Duration(),
+		lines:         make(chan []byte, cfg.BufferSize),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Write implementa io.Writer para escritas sem nível (ex.: log.Print)
+func (s *Shipper) Write(p []byte) (int, error) {
+	return s.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implementa zerolog.LevelWriter
+func (s *Shipper) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < s.level || level == zerolog.NoLevel || level == zerolog.Disabled {
+		return len(p), nil
+	}
+	// O zerolog reaproveita o buffer depois do Write
+	line := append([]byte(nil), p...)
+	select {
+	case s.lines <- line:
+	default:
+		s.dropped.Add(1)
+		s.unreported.Add(1)
+	}
+	return len(p), nil
+}
+
+// Start inicia o envio periódico pelo send informado
+func (s *Shipper) Start(send ShipFunc) {
+	if s.started.CompareAndSwap(false, true) {
+		s.send = send
+		go s.run()
+	}
+}
+
+// Close envia o que está no buffer e encerra o envio
+func (s *Shipper) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		if s.started.Load() {
+			<-s.stopped
+		}
+	})
+}
+
+// Stats retorna as linhas enviadas e as descartadas
+func (s *Shipper) Stats() (shipped, dropped int64) {
+	return s.shipped.Load(), s.dropped.Load()
+}
+
+// run junta as linhas em lotes até Close
+func (s *Shipper) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	var pending []types.LogRecord
+	for {
+		select {
+		case line := <-s.lines:
+			pending = append(pending, decodeLogLine(line))
+			if len(pending) >= s.batchSize {
+				pending = s.flush(pending)
+			}
+		case <-ticker.C:
+			pending = s.flush(pending)
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.lines:
+					pending = append(pending, decodeLogLine(line))
+				default:
+					s.flush(pending)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush envia pending em lotes de batchSize e retorna o que falhou, sem
+// passar de bufferSize linhas (as mais antigas são descartadas)
+func (s *Shipper) flush(pending []types.LogRecord) []types.LogRecord {
+	for len(pending) > 0 {
+		n := min(len(pending), s.batchSize)
+		batch := &types.LogBatch{
+			Timestamp: time.Now(),
+			Dropped:   s.unreported.Swap(0),
+			Entries:   pending[:n],
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), shipSendTimeout)
+		err := s.send(ctx, batch)
+		cancel()
+		if err != nil {
+			s.unreported.Add(batch.Dropped)
+			if excess := len(pending) - s.bufferSize; excess > 0 {
+				s.dropped.Add(int64(excess))
+				s.unreported.Add(int64(excess))
+				pending = pending[excess:]
+			}
+			return pending
+		}
+		s.shipped.Add(int64(n))
+		pending = pending[n:]
+	}
+	return nil
+}
+
+// decodeLogLine converte a linha JSON do zerolog; campos além de nível,
+// mensagem e hora vão em Fields
+func decodeLogLine(line []byte) types.LogRecord {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return types.LogRecord{Timestamp: time.Now(), Level: zerolog.NoLevel.String(), Message: string(line)}
+	}
+
+	record := types.LogRecord{Timestamp: time.Now()}
+	if level, ok := fields[zerolog.LevelFieldName].(string); ok {
+		record.Level = level
+	}
+	if message, ok := fields[zerolog.MessageFieldName].(string); ok {
+		record.Message = message
+	}
+	if raw, ok := fields[zerolog.TimestampFieldName].(string); ok {
+		if ts, err := time.Parse(zerolog.TimeFieldFormat, raw); err == nil {
+			record.Timestamp = ts
+		}
+	}
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	delete(fields, zerolog.TimestampFieldName)
+	if len(fields) > 0 {
+		record.Fields = fields
+	}
+	return record
+}
@@ -0,0 +1,200 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/timeutil"
+	"machine-monitor-agent/internal/types"
+
+	"github.com/rs/zerolog"
+)
+
+// shipAttempt é um envio recebido pelo backend de teste
+type shipAttempt struct {
+	batch *types.LogBatch
+	ok    bool
+}
+
+// shipBackend recebe os lotes do Shipper; offline recusa os envios
+type shipBackend struct {
+	attempts chan shipAttempt
+	offline  atomic.Bool
+}
+
+func newShipBackend() *shipBackend {
+	return &shipBackend{attempts: make(chan shipAttempt, 1024)}
+}
+
+func (b *shipBackend) send(ctx context.Context, batch *types.LogBatch) error {
+	ok := !b.offline.Load()
+	// O Shipper reaproveita as entradas pendentes entre tentativas
+	copied := *batch
+	copied.Entries = append([]types.LogRecord(nil), batch.Entries...)
+	b.attempts <- shipAttempt{batch: &copied, ok: ok}
+	if !ok {
+		return errors.New("backend unreachable")
+	}
+	return nil
+}
+
+// next espera o próximo envio
+func (b *shipBackend) next(t *testing.T) shipAttempt {
+	t.Helper()
+	select {
+	case attempt := <-b.attempts:
+		return attempt
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch shipped")
+		return shipAttempt{}
+	}
+}
+
+// newTestShipper cria o shipper e um logger zerolog que escreve nele
+func newTestShipper(t *testing.T, cfg types.LogShippingConfig) (*Shipper, zerolog.Logger) {
+	t.Helper()
+	s := NewShipper(cfg)
+	t.Cleanup(s.Close)
+	return s, zerolog.New(s).With().Timestamp().Logger()
+}
+
+func messages(batch *types.LogBatch) []string {
+	var out []string
+	for _, entry := range batch.Entries {
+		out = append(out, entry.Message)
+	}
+	return out
+}
+
+func TestShipperBatchesBySize(t *testing.T) {
+	s, logger := newTestShipper(t, types.LogShippingConfig{
+		Level: "warn", BatchSize: 3, BufferSize: 100, FlushInterval: timeutil.Seconds(time.Hour),
+	})
+	backend := newShipBackend()
+
+	for i := 0; i < 7; i++ {
+		logger.Error().Msgf("line %d", i)
+	}
+	s.Start(backend.send)
+
+	// Lotes cheios saem sem esperar o intervalo; o resto sai no Close
+	for i, want := range []int{3, 3} {
+		if attempt := backend.next(t); len(attempt.batch.Entries) != want {
+			t.Fatalf("batch %d = %v", i, messages(attempt.batch))
+		}
+	}
+	s.Close()
+	if attempt := backend.next(t); fmt.Sprint(messages(attempt.batch)) != "[line 6]" {
+		t.Fatalf("final batch = %v", messages(attempt.batch))
+	}
+	if shipped, dropped := s.Stats(); shipped != 7 || dropped != 0 {
+		t.Fatalf("shipped %d, dropped %d", shipped, dropped)
+	}
+}
+
+func TestShipperLevelFiltering(t *testing.T) {
+	s, logger := newTestShipper(t, types.LogShippingConfig{
+		Level: "warn", BatchSize: 10, BufferSize: 100, FlushInterval: timeutil.Seconds(time.Hour),
+	})
+	backend := newShipBackend()
+
+	logger.Debug().Msg("debug line")
+	logger.Info().Msg("info line")
+	logger.Warn().Str("component", "executor").Msg("warn line")
+	logger.Error().Err(errors.New("boom")).Int("attempt", 2).Msg("error line")
+	// Escritas sem nível (log.Print) não vão ao backend
+	logger.Log().Msg("no level")
+
+	s.Start(backend.send)
+	s.Close()
+
+	batch := backend.next(t).batch
+	if len(batch.Entries) != 2 {
+		t.Fatalf("entries = %v", messages(batch))
+	}
+	warn, failure := batch.Entries[0], batch.Entries[1]
+	if warn.Level != "warn" || warn.Message != "warn line" || warn.Fields["component"] != "executor" {
+		t.Fatalf("warn entry = %+v", warn)
+	}
+	if failure.Level != "error" || failure.Fields["error"] != "boom" || failure.Fields["attempt"] != float64(2) {
+		t.Fatalf("error entry = %+v", failure)
+	}
+	// Nível, mensagem e hora não se repetem em Fields
+	for _, key := range []string{"level", "message", "time"} {
+		if _, ok := failure.Fields[key]; ok {
+			t.Fatalf("%s left in fields: %v", key, failure.Fields)
+		}
+	}
+	if time.Since(failure.Timestamp) > time.Minute {
+		t.Fatalf("timestamp %v", failure.Timestamp)
+	}
+
+	// Nível inválido cai para warn
+	if invalid := NewShipper(types.LogShippingConfig{Level: "loud", BufferSize: 1}); invalid.level != zerolog.WarnLevel {
+		t.Fatalf("level %v for an invalid setting", invalid.level)
+	}
+}
+
+func TestShipperDropsWhenBufferFull(t *testing.T) {
+	s, logger := newTestShipper(t, types.LogShippingConfig{
+		Level: "warn", BatchSize: 10, BufferSize: 2, FlushInterval: timeutil.Seconds(time.Hour),
+	})
+	backend := newShipBackend()
+
+	for i := 0; i < 5; i++ {
+		logger.Error().Msgf("line %d", i)
+	}
+	if _, dropped := s.Stats(); dropped != 3 {
+		t.Fatalf("dropped %d, want 3", dropped)
+	}
+
+	// O lote informa as descartadas
+	s.Start(backend.send)
+	s.Close()
+	batch := backend.next(t).batch
+	if fmt.Sprint(messages(batch)) != "[line 0 line 1]" || batch.Dropped != 3 {
+		t.Fatalf("batch %v, dropped %d", messages(batch), batch.Dropped)
+	}
+}
+
+func TestShipperKeepsBatchesWhileOffline(t *testing.T) {
+	s, logger := newTestShipper(t, types.LogShippingConfig{
+		Level: "warn", BatchSize: 10, BufferSize: 3, FlushInterval: timeutil.Seconds(10 * time.Millisecond),
+	})
+	backend := newShipBackend()
+	backend.offline.Store(true)
+
+	for i := 0; i < 3; i++ {
+		logger.Error().Msgf("line %d", i)
+	}
+	s.Start(backend.send)
+
+	// O lote recusado volta no próximo envio
+	for attempt := backend.next(t); len(attempt.batch.Entries) != 3; attempt = backend.next(t) {
+	}
+	if attempt := backend.next(t); attempt.ok || fmt.Sprint(messages(attempt.batch)) != "[line 0 line 1 line 2]" {
+		t.Fatalf("retry = %v", messages(attempt.batch))
+	}
+
+	// Acima de BufferSize, as mais antigas são descartadas
+	logger.Error().Msg("line 3")
+	logger.Error().Msg("line 4")
+	for _, dropped := s.Stats(); dropped != 2; _, dropped = s.Stats() {
+		backend.next(t)
+	}
+	backend.offline.Store(false)
+
+	var delivered shipAttempt
+	for delivered = backend.next(t); !delivered.ok; delivered = backend.next(t) {
+	}
+	if fmt.Sprint(messages(delivered.batch)) != "[line 2 line 3 line 4]" || delivered.batch.Dropped != 2 {
+		t.Fatalf("delivered %v, dropped %d", messages(delivered.batch), delivered.batch.Dropped)
+	}
+	if shipped, dropped := s.Stats(); shipped != 3 || dropped != 2 {
+		t.Fatalf("shipped %d, dropped %d", shipped, dropped)
+	}
+}
@@ -0,0 +1,137 @@
+package timeutil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseFlexibleDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		unit  time.Duration
+		want  time.Duration
+	}{
+		{"90", time.Second, 90 * time.Second},
+		{"1.5", time.Minute, 90 * time.Second},
+		{"250", time.Millisecond, 250 * time.Millisecond},
+		{" 30 ", time.Second, 30 * time.Second},
+		{"0", time.Second, 0},
+		{"90s", time.Minute, 90 * time.Second},
+		{"1.5m", time.Second, 90 * time.Second},
+		{"2h30m", time.Second, 2*time.Hour + 30*time.Minute},
+		{"500ms", time.Second, 500 * time.Millisecond},
+		{"-5s", time.Second, -5 * time.Second},
+	}
+	for _, tt := range tests {
+		got, err := ParseFlexibleDuration(tt.value, tt.unit)
+		if err != nil {
+			t.Errorf("ParseFlexibleDuration(%q, %s): %v", tt.value, tt.unit, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseFlexibleDuration(%q, %s) = %s, want %s", tt.value, tt.unit, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "  ", "abc", "5 minutes", "1d", "h"} {
+		if _, err := ParseFlexibleDuration(value, time.Second); err == nil {
+			t.Errorf("ParseFlexibleDuration(%q) accepted", value)
+		}
+	}
+}
+
+func TestFormatDurationHuman(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{250 * time.Millisecond, "250ms"},
+		{45 * time.Second, "45s"},
+		{90 * time.Second, "1m 30s"},
+		{2*time.Hour + 13*time.Minute + 20*time.Second, "2h 13m"},
+		{2 * time.Hour, "2h"},
+		{2*time.Hour + 20*time.Second, "2h"},
+		{3*24*time.Hour + 4*time.Hour + 5*time.Minute, "3d 4h"},
+		{-90 * time.Second, "-1m 30s"},
+	}
+	for _, tt := range tests {
+		if got := FormatDurationHuman(tt.d); got != tt.want {
+			t.Errorf("FormatDurationHuman(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestSecondsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		// Formas numéricas antigas: segundos inteiros ou fracionários
+		{`30`, 30 * time.Second},
+		{`0`, 0},
+		{`1.5`, 1500 * time.Millisecond},
+		// Strings numéricas e durações Go
+		{`"30"`, 30 * time.Second},
+		{`"90s"`, 90 * time.Second},
+		{`"1.5m"`, 90 * time.Second},
+		{`"2h30m"`, 2*time.Hour + 30*time.Minute},
+		{`null`, 0},
+	}
+	for _, tt := range tests {
+		var s Seconds
+		if err := json.Unmarshal([]byte(tt.json), &s); err != nil {
+			t.Errorf("unmarshal %s: %v", tt.json, err)
+			continue
+		}
+		if s.Duration() != tt.want {
+			t.Errorf("unmarshal %s = %s, want %s", tt.json, s.Duration(), tt.want)
+		}
+	}
+
+	for _, invalid := range []string{`"soon"`, `""`, `true`, `[30]`} {
+		var s Seconds
+		if err := json.Unmarshal([]byte(invalid), &s); err == nil {
+			t.Errorf("unmarshal %s accepted as %s", invalid, s.Duration())
+		}
+	}
+}
+
+func TestSecondsMarshalJSON(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, `30`},
+		{0, `0`},
+		{2 * time.Hour, `7200`},
+		{1500 * time.Millisecond, `"1.5s"`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(Seconds(tt.d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("marshal %s = %s, want %s", tt.d, data, tt.want)
+		}
+
+		var back Seconds
+		if err := json.Unmarshal(data, &back); err != nil || back.Duration() != tt.d {
+			t.Errorf("round trip of %s = %s, %v", tt.d, back.Duration(), err)
+		}
+	}
+
+	// Dentro de uma struct, ausente e null mantêm o zero
+	var payload struct {
+		Interval Seconds `json:"interval"`
+		Timeout  Seconds `json:"timeout"`
+	}
+	if err := json.Unmarshal([]byte(`{"timeout":null}`), &payload); err != nil || payload.Interval != 0 || payload.Timeout != 0 {
+		t.Fatalf("missing and null fields: %+v, %v", payload, err)
+	}
+	if s := Seconds(90 * time.Second).String(); s != "1m 30s" {
+		t.Fatalf("String() = %q", s)
+	}
+}
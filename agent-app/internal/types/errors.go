@@ -0,0 +1,166 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrorCode identifica de forma estável o motivo de falha de um CommandResult.
+// CommandResult.Error é sempre inglês; a UI traduz a partir do código.
+type ErrorCode string
+
+// Códigos de erro enviados em CommandResult.ErrorCode
+const (
+	ErrCodeCommandNotAllowed      ErrorCode = "command_not_allowed"
+	ErrCodeExecutorQueueTimeout   ErrorCode = "executor_queue_timeout"
+	ErrCodeUnsupportedCommandType ErrorCode = "unsupported_command_type"
+	ErrCodeEmptyCommand           ErrorCode = "empty_command"
+	ErrCodeUnsafeCommand          ErrorCode = "unsafe_command"
+	ErrCodeEmptyPingTarget        ErrorCode = "empty_ping_target"
+	ErrCodeSerializationFailed    ErrorCode = "serialization_failed"
+	ErrCodeExecutionFailed        ErrorCode = "execution_failed"
+	ErrCodeInvalidArgument        ErrorCode = "invalid_argument"
+	ErrCodeHistoryDisabled        ErrorCode = "history_disabled"
+	ErrCodeInventoryNotFound      ErrorCode = "inventory_not_found"
+)
+
+// errorSpec é a entrada do catálogo: mensagem em inglês e o texto antigo em
+// português, com os mesmos argumentos
+type errorSpec struct {
+	message string
+	legacy  string
+}
+
+// errorCatalog mapeia códigos para a mensagem atual e o texto antigo enviado
+// em CommandResult.Error, para o backend que ainda compara strings
+var errorCatalog = map[ErrorCode]errorSpec{
+	ErrCodeCommandNotAllowed:      {"command not allowed: %s", "comando não permitido: %s"},
+	ErrCodeExecutorQueueTimeout:   {"timed out waiting for an execution slot", "timeout ao aguardar slot de execução"},
+	ErrCodeUnsupportedCommandType: {"unsupported command type: %s", "tipo de comando desconhecido: %s"},
+	ErrCodeEmptyCommand:           {"empty command", "comando vazio"},
+	ErrCodeUnsafeCommand:          {"command contains dangerous characters", "comando contém caracteres perigosos"},
+	ErrCodeEmptyPingTarget:        {"empty ping target", "target de ping vazio"},
+	ErrCodeSerializationFailed:    {"failed to serialize information: %v", "erro ao serializar informações: %v"},
+	ErrCodeExecutionFailed:        {"%s", "%s"},
+	ErrCodeInvalidArgument:        {"invalid argument: %s", "argumento inválido: %s"},
+	ErrCodeHistoryDisabled:        {"inventory history is disabled", "histórico de inventário desabilitado"},
+	ErrCodeInventoryNotFound:      {"no inventory recorded at or before %s", "nenhum inventário registrado até %s"},
+}
+
+// CodedError é um erro com código do catálogo
+type CodedError struct {
+	Code    ErrorCode
+	Message string
+	Legacy  string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+// NewCodedError formata a mensagem e o texto antigo do código com os argumentos
+func NewCodedError(code ErrorCode, args ...interface{}) *CodedError {
+	spec, ok := errorCatalog[code]
+	if !ok {
+		args = []interface{}{string(code)}
+		code = ErrCodeExecutionFailed
+		spec = errorCatalog[code]
+	}
+
+	return &CodedError{
+		Code:    code,
+		Message: fmt.Sprintf(spec.message, args...),
+		Legacy:  fmt.Sprintf(spec.legacy, args...),
+	}
+}
+
+// ErrorCodes retorna os códigos registrados, em ordem alfabética
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// IsRegistered verifica se o código pertence ao catálogo
+func (c ErrorCode) IsRegistered() bool {
+	_, ok := errorCatalog[c]
+	return ok
+}
+
+// SetError preenche Error, ErrorCode e LegacyError a partir do erro.
+// Erros sem código recebem execution_failed.
+func (r *CommandResult) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	var coded *CodedError
+	if !errors.As(err, &coded) {
+		coded = NewCodedError(ErrCodeExecutionFailed, err.Error())
+	}
+
+	r.Error = coded.Message
+	r.ErrorCode = coded.Code
+	r.LegacyError = coded.Legacy
+}
+
+// EventCode identifica o tipo de um evento do histórico (Event.Type). A
+// descrição em Event.Message é sempre a mensagem inglesa do catálogo; a UI
+// traduz a partir do código.
+type EventCode string
+
+// Códigos dos eventos do histórico recente do agente
+const (
+	EventConnected        EventCode = "connected"
+	EventConnectionFailed EventCode = "connection_failed"
+	EventDisconnected     EventCode = "disconnected"
+	EventStateChanged     EventCode = "state_changed"
+	EventInventorySent    EventCode = "inventory_sent"
+	EventInventoryFailed  EventCode = "inventory_failed"
+	EventCommandReceived  EventCode = "command_received"
+	EventCommandExecuted  EventCode = "command_executed"
+	EventCommandRejected  EventCode = "command_rejected"
+)
+
+// eventCatalog mapeia códigos de evento para a descrição em inglês; detalhes
+// (etapa, erro, reconexão) vão em Event.Data
+var eventCatalog = map[EventCode]string{
+	EventConnected:        "WebSocket connected",
+	EventConnectionFailed: "WebSocket connection failed",
+	EventDisconnected:     "WebSocket disconnected",
+	EventStateChanged:     "Agent state changed",
+	EventInventorySent:    "Inventory sent",
+	EventInventoryFailed:  "Inventory failed",
+	EventCommandReceived:  "Command received",
+	EventCommandExecuted:  "Command executed",
+	EventCommandRejected:  "Command rejected",
+}
+
+// Message retorna a descrição do evento; códigos fora do catálogo usam o
+// próprio código
+func (c EventCode) Message() string {
+	if message, ok := eventCatalog[c]; ok {
+		return message
+	}
+	return string(c)
+}
+
+// IsRegistered verifica se o código de evento pertence ao catálogo
+func (c EventCode) IsRegistered() bool {
+	_, ok := eventCatalog[c]
+	return ok
+}
+
+// EventCodes retorna os códigos de evento registrados, em ordem alfabética
+func EventCodes() []EventCode {
+	codes := make([]EventCode, 0, len(eventCatalog))
+	for code := range eventCatalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
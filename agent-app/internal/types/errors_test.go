@@ -0,0 +1,80 @@
+package types
+
+import (
+	"errors"
+	"testing"
+	"unicode"
+)
+
+// isASCII verifica que a mensagem é inglês sem acentos
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+func TestErrorCatalog(t *testing.T) {
+	for _, code := range ErrorCodes() {
+		if !code.IsRegistered() {
+			t.Errorf("listed code %q is not registered", code)
+		}
+		spec := errorCatalog[code]
+		if spec.message == "" || spec.legacy == "" {
+			t.Errorf("code %q has an empty message", code)
+		}
+		if !isASCII(spec.message) {
+			t.Errorf("code %q message %q is not English", code, spec.message)
+		}
+	}
+	if ErrorCode("bogus").IsRegistered() {
+		t.Error("unknown error code registered")
+	}
+}
+
+func TestNewCodedErrorUnknownCode(t *testing.T) {
+	err := NewCodedError("bogus")
+	if err.Code != ErrCodeExecutionFailed || err.Message != "bogus" {
+		t.Fatalf("unknown code became %q: %q", err.Code, err.Message)
+	}
+}
+
+func TestCommandResultSetError(t *testing.T) {
+	var result CommandResult
+	result.SetError(NewCodedError(ErrCodeCommandNotAllowed, "shell"))
+	if result.ErrorCode != ErrCodeCommandNotAllowed || result.Error != "command not allowed: shell" || result.LegacyError != "comando não permitido: shell" {
+		t.Fatalf("coded error = %+v", result)
+	}
+
+	result = CommandResult{}
+	result.SetError(errors.New("exit status 1"))
+	if result.ErrorCode != ErrCodeExecutionFailed || result.Error != "exit status 1" {
+		t.Fatalf("plain error = %+v", result)
+	}
+
+	result = CommandResult{}
+	result.SetError(nil)
+	if result.ErrorCode != "" || result.Error != "" {
+		t.Fatalf("nil error = %+v", result)
+	}
+}
+
+func TestEventCatalog(t *testing.T) {
+	codes := EventCodes()
+	if len(codes) == 0 {
+		t.Fatal("empty event catalog")
+	}
+	for _, code := range codes {
+		if !code.IsRegistered() {
+			t.Errorf("listed event %q is not registered", code)
+		}
+		if message := code.Message(); message == "" || message == string(code) || !isASCII(message) {
+			t.Errorf("event %q message %q is not an English description", code, message)
+		}
+	}
+	if EventCode("bogus").IsRegistered() || EventCode("bogus").Message() != "bogus" {
+		t.Error("unknown event code handled as registered")
+	}
+}
@@ -2,9 +2,12 @@ package types
 
 import (
 	"time"
+
+	"machine-monitor-agent/internal/timeutil"
 )
 
-// Config representa a configuração do agente
+// Config representa a configuração do agente. Intervalos (timeutil.Seconds)
+// aceitam segundos (formato antigo) ou durações como "90s" e "2h30m".
 type Config struct {
 	Server   ServerConfig   `json:"server"`
 	Agent    AgentConfig    `json:"agent"`
@@ -15,33 +18,62 @@ type Config struct {
 
 // ServerConfig configurações do servidor backend
 type ServerConfig struct {
-	BaseURL    string `json:"base_url"`
-	HTTPPort   int    `json:"http_port"`
-	WSPort     int    `json:"ws_port"`
-	UseHTTPS   bool   `json:"use_https"`
-	Timeout    int    `json:"timeout"`
-	MaxRetries int    `json:"max_retries"`
-	RetryDelay int    `json:"retry_delay"`
+	BaseURL    string           `json:"base_url"`
+	HTTPPort   int              `json:"http_port"`
+	WSPort     int              `json:"ws_port"`
+	UseHTTPS   bool             `json:"use_https"`
+	Timeout    timeutil.Seconds `json:"timeout"`
+	MaxRetries int              `json:"max_retries"`
+	RetryDelay timeutil.Seconds `json:"retry_delay"`
 }
 
 // AgentConfig configurações do agente
 type AgentConfig struct {
-	MachineID         string `json:"machine_id"`
-	Name              string `json:"name"`
-	Version           string `json:"version"`
-	HeartbeatInterval int    `json:"heartbeat_interval"`
-	InventoryInterval int    `json:"inventory_interval"`
-	MaxConcurrency    int    `json:"max_concurrency"`
-	DataCacheTTL      int    `json:"data_cache_ttl"`
+	MachineID         string           `json:"machine_id"`
+	Name              string           `json:"name"`
+	Version           string           `json:"version"`
+	HeartbeatInterval timeutil.Seconds `json:"heartbeat_interval"`
+	InventoryInterval timeutil.Seconds `json:"inventory_interval"`
+	MaxConcurrency    int              `json:"max_concurrency"`
+	DataCacheTTL      timeutil.Seconds `json:"data_cache_ttl"`
+	// EventLogSize eventos recentes mantidos em memória (/api/events, get_events)
+	EventLogSize int `json:"event_log_size"`
+	// InventoryHistory cópia local dos inventários coletados
+	InventoryHistory InventoryHistoryConfig `json:"inventory_history"`
+}
+
+// InventoryHistoryConfig histórico local dos inventários coletados, em
+// arquivos JSON com gzip no diretório de dados. Mantém os MaxEntries mais
+// recentes e descarta os mais antigos que MaxAge.
+type InventoryHistoryConfig struct {
+	Enabled    bool             `json:"enabled"`
+	MaxEntries int              `json:"max_entries"`
+	MaxAge     timeutil.Seconds `json:"max_age"`
 }
 
 // LoggingConfig configurações de logging
 type LoggingConfig struct {
-	Level    string `json:"level"`
-	File     string `json:"file"`
-	MaxSize  int    `json:"max_size"`
-	MaxAge   int    `json:"max_age"`
-	Compress bool   `json:"compress"`
+	Level string `json:"level"`
+	File  string `json:"file"`
+	// MaxSize tamanho em MB a partir do qual o arquivo é rotacionado
+	MaxSize int `json:"max_size"`
+	// MaxBackups backups mantidos e MaxAge idade máxima deles em dias
+	MaxBackups int  `json:"max_backups"`
+	MaxAge     int  `json:"max_age"`
+	Compress   bool `json:"compress"`
+	// Shipping envia as linhas a partir de um nível ao backend
+	Shipping LogShippingConfig `json:"shipping"`
+}
+
+// LogShippingConfig envio dos logs ao backend em lotes de até BatchSize
+// linhas a cada FlushInterval; com o buffer de BufferSize linhas cheio, as
+// novas são descartadas
+type LogShippingConfig struct {
+	Enabled       bool             `json:"enabled"`
+	Level         string           `json:"level"`
+	BatchSize     int              `json:"batch_size"`
+	FlushInterval timeutil.Seconds `json:"flush_interval"`
+	BufferSize    int              `json:"buffer_size"`
 }
 
 // UIConfig configurações da interface
@@ -50,6 +82,24 @@ type UIConfig struct {
 	WebUIPort    int    `json:"webui_port"`
 	Theme        string `json:"theme"`
 	AutoStart    bool   `json:"auto_start"`
+	// Language idioma do tray e da interface web ("en", "pt-BR"); vazio usa o do sistema
+	Language string `json:"language"`
+	// PushInterval intervalo das atualizações enviadas à interface web pelo
+	// WebSocket /ws
+	PushInterval timeutil.Seconds `json:"push_interval"`
+	// BindAddress endereço em que a interface web escuta; "0.0.0.0" expõe
+	// a interface na rede
+	BindAddress string `json:"bind_address"`
+	// AuthToken token exigido pela API e pelo /ws (Authorization: Bearer ou
+	// login em /login); vazio, sem BasicAuth, deixa a interface sem autenticação
+	AuthToken string      `json:"auth_token"`
+	BasicAuth UIBasicAuth `json:"basic_auth"`
+}
+
+// UIBasicAuth usuário e senha aceitos pela interface web além do AuthToken
+type UIBasicAuth struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
 }
 
 // SecurityConfig configurações de segurança
@@ -60,6 +110,7 @@ type SecurityConfig struct {
 	KeyFile         string   `json:"key_file"`
 	ValidateCerts   bool     `json:"validate_certs"`
 	AllowedCommands []string `json:"allowed_commands"`
+	MaxOutputBytes  int      `json:"max_output_bytes"`
 }
 
 // SystemInfo informações do sistema
@@ -79,10 +130,23 @@ type HardwareInfo struct {
 	CPU       CPUInfo       `json:"cpu"`
 	Memory    MemoryInfo    `json:"memory"`
 	Disk      []DiskInfo    `json:"disk"`
+	DiskIO    []DiskIOInfo  `json:"disk_io"`
 	Network   []NetworkInfo `json:"network"`
+	GPUs      []GPUInfo     `json:"gpus"`
 	Timestamp time.Time     `json:"timestamp"`
 }
 
+// GPUInfo informações de um adaptador de vídeo; campos que a plataforma não
+// informa ficam vazios
+type GPUInfo struct {
+	Model         string `json:"model"`
+	Vendor        string `json:"vendor,omitempty"`
+	VRAM          uint64 `json:"vram_bytes,omitempty"`
+	Driver        string `json:"driver,omitempty"`
+	DriverVersion string `json:"driver_version,omitempty"`
+	BusID         string `json:"bus_id,omitempty"`
+}
+
 // CPUInfo informações da CPU
 type CPUInfo struct {
 	ModelName   string    `json:"model_name"`
@@ -104,6 +168,17 @@ type MemoryInfo struct {
 	Timestamp   time.Time `json:"timestamp"`
 }
 
+// UsageSample amostra leve de uso de CPU e memória, sem o restante do
+// hardware (atualizações ao vivo da interface web)
+type UsageSample struct {
+	CPUPercent      float64   `json:"cpu_percent"`
+	MemoryTotal     uint64    `json:"memory_total"`
+	MemoryUsed      uint64    `json:"memory_used"`
+	MemoryAvailable uint64    `json:"memory_available"`
+	MemoryPercent   float64   `json:"memory_percent"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
 // DiskInfo informações de disco
 type DiskInfo struct {
 	Device      string    `json:"device"`
@@ -116,6 +191,22 @@ type DiskInfo struct {
 	Timestamp   time.Time `json:"timestamp"`
 }
 
+// DiskIOInfo estatísticas de I/O de um dispositivo de disco. Os contadores
+// são acumulados desde o boot; as taxas são desde a coleta anterior e ficam
+// ausentes na primeira coleta, em dispositivos recém-conectados e quando os
+// contadores voltaram (reboot, dispositivo reconectado)
+type DiskIOInfo struct {
+	Device           string   `json:"device"`
+	ReadBytes        uint64   `json:"read_bytes"`
+	WriteBytes       uint64   `json:"write_bytes"`
+	ReadCount        uint64   `json:"read_count"`
+	WriteCount       uint64   `json:"write_count"`
+	ReadBytesPerSec  *float64 `json:"read_bytes_per_sec,omitempty"`
+	WriteBytesPerSec *float64 `json:"write_bytes_per_sec,omitempty"`
+	ReadOpsPerSec    *float64 `json:"read_ops_per_sec,omitempty"`
+	WriteOpsPerSec   *float64 `json:"write_ops_per_sec,omitempty"`
+}
+
 // NetworkInfo informações de rede
 type NetworkInfo struct {
 	Name         string    `json:"name"`
@@ -138,6 +229,26 @@ type User struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// PostureCheck resultado de uma verificação de segurança; com Error
+// preenchido a verificação falhou e Enabled não tem significado
+type PostureCheck struct {
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SecurityPosture higiene básica da máquina; verificações que não existem
+// na plataforma ficam nil (SIP e Gatekeeper só no macOS). RemoteLogin é SSH
+// no macOS e no Linux e RDP no Windows; ligado é o estado menos seguro.
+type SecurityPosture struct {
+	Firewall    *PostureCheck `json:"firewall,omitempty"`
+	ScreenLock  *PostureCheck `json:"screen_lock,omitempty"`
+	SIP         *PostureCheck `json:"sip,omitempty"`
+	Gatekeeper  *PostureCheck `json:"gatekeeper,omitempty"`
+	RemoteLogin *PostureCheck `json:"remote_login,omitempty"`
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
 // Inventory inventário completo da máquina
 type Inventory struct {
 	MachineID string       `json:"machine_id"`
@@ -146,6 +257,15 @@ type Inventory struct {
 	Timestamp time.Time    `json:"timestamp"`
 }
 
+// InventoryHistoryEntry inventário guardado no histórico local. Checksum é o
+// SHA-256 do JSON do inventário; nas listagens Inventory fica vazio.
+type InventoryHistoryEntry struct {
+	Timestamp time.Time  `json:"timestamp"`
+	Checksum  string     `json:"checksum"`
+	SizeBytes int64      `json:"size_bytes"`
+	Inventory *Inventory `json:"inventory,omitempty"`
+}
+
 // Command comando recebido do servidor
 type Command struct {
 	ID        string            `json:"id"`
@@ -159,13 +279,20 @@ type Command struct {
 
 // CommandResult resultado da execução do comando
 type CommandResult struct {
-	ID        string    `json:"id"`
-	Success   bool      `json:"success"`
-	Output    string    `json:"output"`
-	Error     string    `json:"error"`
-	ExitCode  int       `json:"exit_code"`
-	Duration  int64     `json:"duration"`
-	Timestamp time.Time `json:"timestamp"`
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Output  string `json:"output"`
+	// OutputTruncated indica que Output foi cortado no limite;
+	// OriginalOutputBytes é o tamanho total produzido
+	OutputTruncated     bool      `json:"output_truncated,omitempty"`
+	OriginalOutputBytes int       `json:"original_output_bytes,omitempty"`
+	Error               string    `json:"error"`
+	ErrorCode           ErrorCode `json:"error_code,omitempty"`
+	// Deprecated: texto antigo em português, mantido por uma versão; use ErrorCode
+	LegacyError string    `json:"legacy_error,omitempty"`
+	ExitCode    int       `json:"exit_code"`
+	Duration    int64     `json:"duration"`
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 // HeartbeatData dados do heartbeat
@@ -180,12 +307,110 @@ type HeartbeatData struct {
 
 // AgentStatus status do agente
 type AgentStatus struct {
-	State         string        `json:"state"`
-	LastHeartbeat time.Time     `json:"last_heartbeat"`
-	LastInventory time.Time     `json:"last_inventory"`
-	CommandsRun   int64         `json:"commands_run"`
-	Errors        int64         `json:"errors"`
-	Uptime        time.Duration `json:"uptime"`
+	State         string           `json:"state"`
+	LastHeartbeat time.Time        `json:"last_heartbeat"`
+	LastInventory time.Time        `json:"last_inventory"`
+	CommandsRun   int64            `json:"commands_run"`
+	Errors        int64            `json:"errors"`
+	Uptime        timeutil.Seconds `json:"uptime"`
+	LastCommand   *CommandSummary  `json:"last_command,omitempty"`
+}
+
+// CommandSummary resumo do último comando executado, exibido na interface web
+type CommandSummary struct {
+	ID                  string    `json:"id"`
+	Type                string    `json:"type"`
+	Success             bool      `json:"success"`
+	OutputBytes         int       `json:"output_bytes"`
+	OutputTruncated     bool      `json:"output_truncated"`
+	OriginalOutputBytes int       `json:"original_output_bytes,omitempty"`
+	Timestamp           time.Time `json:"timestamp"`
+}
+
+// ExecutionMetrics contadores do executor desde o início do agente;
+// durações em milissegundos
+type ExecutionMetrics struct {
+	TotalCommands      int64 `json:"total_commands"`
+	SuccessfulCommands int64 `json:"successful_commands"`
+	FailedCommands     int64 `json:"failed_commands"`
+	// RejectedCommands recusados sem executar (não permitidos ou sem vaga
+	// no executor); também contam em FailedCommands
+	RejectedCommands int64                       `json:"rejected_commands"`
+	RunningCommands  int                         `json:"running_commands"`
+	MaxConcurrency   int                         `json:"max_concurrency"`
+	AverageDuration  int64                       `json:"average_duration"`
+	LastCommandAt    time.Time                   `json:"last_command_at"`
+	ByType           map[string]CommandTypeStats `json:"by_type"`
+}
+
+// CommandTypeStats estatísticas de um tipo de comando
+type CommandTypeStats struct {
+	Count           int64     `json:"count"`
+	Failures        int64     `json:"failures"`
+	AverageDuration int64     `json:"average_duration"`
+	MaxDuration     int64     `json:"max_duration"`
+	LastRun         time.Time `json:"last_run"`
+}
+
+// ConnectionMetrics saúde da comunicação com o backend
+type ConnectionMetrics struct {
+	HTTP      HTTPMetrics `json:"http"`
+	WebSocket WSMetrics   `json:"websocket"`
+}
+
+// HTTPMetrics contadores das requisições ao backend; latência em milissegundos
+type HTTPMetrics struct {
+	Requests       int64     `json:"requests"`
+	Failures       int64     `json:"failures"`
+	AverageLatency int64     `json:"average_latency"`
+	LastSuccess    time.Time `json:"last_success"`
+	LastError      string    `json:"last_error,omitempty"`
+	LastErrorAt    time.Time `json:"last_error_at"`
+}
+
+// WSMetrics estado e contadores do WebSocket com o backend, com a ocupação
+// das filas de comandos recebidos e de resultados a enviar
+type WSMetrics struct {
+	Connected            bool      `json:"connected"`
+	Connects             int64     `json:"connects"`
+	ConnectFailures      int64     `json:"connect_failures"`
+	Disconnects          int64     `json:"disconnects"`
+	MessagesReceived     int64     `json:"messages_received"`
+	MessagesSent         int64     `json:"messages_sent"`
+	CommandsDropped      int64     `json:"commands_dropped"`
+	CommandQueueDepth    int       `json:"command_queue_depth"`
+	CommandQueueCapacity int       `json:"command_queue_capacity"`
+	ResultQueueDepth     int       `json:"result_queue_depth"`
+	ResultQueueCapacity  int       `json:"result_queue_capacity"`
+	LastConnected        time.Time `json:"last_connected"`
+	LastError            string    `json:"last_error,omitempty"`
+	LastErrorAt          time.Time `json:"last_error_at"`
+}
+
+// Event registro do histórico recente do agente (transições de estado,
+// conexão, comandos e inventário)
+type Event struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Type      string                 `json:"type"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// LogRecord linha de log enviada ao backend
+type LogRecord struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// LogBatch lote de logs enviado ao backend; Dropped conta as linhas
+// descartadas desde o lote anterior
+type LogBatch struct {
+	MachineID string      `json:"machine_id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Dropped   int64       `json:"dropped,omitempty"`
+	Entries   []LogRecord `json:"entries"`
 }
 
 // Estados possíveis do agente
@@ -203,6 +428,13 @@ const (
 	CommandTypeInfo    = "info"
 	CommandTypePing    = "ping"
 	CommandTypeRestart = "restart"
+	// CommandTypeRestartAgent é o restart supervisionado; "restart" é mantido como alias
+	CommandTypeRestartAgent = "restart_agent"
+	// CommandTypeGetEvents retorna o histórico recente do agente
+	CommandTypeGetEvents = "get_events"
+	// CommandTypeGetInventoryHistory lista o histórico local de inventários
+	// ou retorna o inventário de um horário
+	CommandTypeGetInventoryHistory = "get_inventory_history"
 )
 
 // Níveis de log
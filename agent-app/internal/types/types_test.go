@@ -0,0 +1,57 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/timeutil"
+)
+
+func TestAgentStatusUptimeInSeconds(t *testing.T) {
+	status := AgentStatus{Uptime: timeutil.Seconds(2*time.Hour + 13*time.Minute)}
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wire map[string]interface{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire["uptime"] != float64(7980) {
+		t.Fatalf("uptime serialized as %v, want 7980 seconds", wire["uptime"])
+	}
+}
+
+func TestConfigLegacyIntervals(t *testing.T) {
+	var config Config
+	document := `{
+		"server": {"timeout": 30, "retry_delay": "1.5m"},
+		"agent": {"heartbeat_interval": "90s", "inventory_interval": 300, "data_cache_ttl": "2h30m"}
+	}`
+	if err := json.Unmarshal([]byte(document), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Timeout.Duration() != 30*time.Second || config.Server.RetryDelay.Duration() != 90*time.Second {
+		t.Fatalf("server durations: %s, %s", config.Server.Timeout, config.Server.RetryDelay)
+	}
+	if config.Agent.HeartbeatInterval.Duration() != 90*time.Second ||
+		config.Agent.InventoryInterval.Duration() != 5*time.Minute ||
+		config.Agent.DataCacheTTL.Duration() != 2*time.Hour+30*time.Minute {
+		t.Fatalf("agent durations: %s, %s, %s", config.Agent.HeartbeatInterval, config.Agent.InventoryInterval, config.Agent.DataCacheTTL)
+	}
+
+	// Gravado de volta como segundos inteiros, legível por versões antigas
+	data, err := json.Marshal(config.Agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire map[string]interface{}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire["heartbeat_interval"] != float64(90) || wire["data_cache_ttl"] != float64(9000) {
+		t.Fatalf("intervals written as %v and %v", wire["heartbeat_interval"], wire["data_cache_ttl"])
+	}
+}
@@ -0,0 +1,428 @@
+package ui
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sessionCookie cookie de sessão criado pelo /login
+	sessionCookie = "mm_session"
+	// sessionTTL validade da sessão do navegador
+	sessionTTL = 12 * time.Hour
+	// ticketTTL validade do link de uso único aberto pelo tray
+	ticketTTL = time.Minute
+	// authFailureWindow e authMaxFailures limitam as tentativas não
+	// autorizadas por IP: ao atingir o máximo na janela, o IP fica bloqueado
+	// por uma janela inteira
+	authFailureWindow = time.Minute
+	authMaxFailures   = 20
+)
+
+// webAuth guarda as credenciais da interface web e valida as requisições.
+// Cookies de sessão e tickets são assinados com uma chave gerada a cada
+// execução, então reiniciar o agente encerra as sessões abertas.
+type webAuth struct {
+	token    string
+	user     string
+	password string
+	key      []byte
+
+	mu          sync.Mutex
+	usedTickets map[string]time.Time
+
+	limiter *failureLimiter
+	now     func() time.Time
+}
+
+// newWebAuth cria a autenticação a partir da configuração da interface
+func newWebAuth(config types.UIConfig) (*webAuth, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("erro ao gerar chave da interface web: %w", err)
+	}
+
+	return &webAuth{
+		token:       config.AuthToken,
+		user:        config.BasicAuth.User,
+		password:    config.BasicAuth.Password,
+		key:         key,
+		usedTickets: make(map[string]time.Time),
+		limiter:     newFailureLimiter(authFailureWindow, authMaxFailures),
+		now:         time.Now,
+	}, nil
+}
+
+// enabled indica se há credenciais configuradas
+func (a *webAuth) enabled() bool {
+	return a.token != "" || a.user != ""
+}
+
+// authorized aceita Authorization: Bearer com o token, Basic com o usuário e
+// a senha ou um cookie de sessão válido
+func (a *webAuth) authorized(r *http.Request) bool {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if bearer, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return a.checkToken(bearer)
+		}
+		if user, password, ok := r.BasicAuth(); ok {
+			return a.checkBasic(user, password)
+		}
+		return false
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	return err == nil && a.validSession(cookie.Value)
+}
+
+// checkToken compara o token em tempo constante
+func (a *webAuth) checkToken(token string) bool {
+	return a.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// checkBasic compara usuário e senha em tempo constante
+func (a *webAuth) checkBasic(user, password string) bool {
+	if a.user == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.user))
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(a.password))
+	return userOK&passwordOK == 1
+}
+
+// sign assina parts com a chave da execução
+func (a *webAuth) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newSession retorna o valor de um cookie de sessão ("expiração.assinatura")
+func (a *webAuth) newSession() (string, time.Time) {
+	expires := a.now().Add(sessionTTL)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + a.sign("session", exp), expires
+}
+
+// validSession confere a assinatura e a expiração de um cookie de sessão
+func (a *webAuth) validSession(value string) bool {
+	exp, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(a.sign("session", exp))) {
+		return false
+	}
+	return !a.expired(exp)
+}
+
+// newTicket retorna um ticket de uso único ("nonce.expiração.assinatura"),
+// trocado por uma sessão em /login
+func (a *webAuth) newTicket() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("erro ao gerar ticket da interface web: %w", err)
+	}
+	n := hex.EncodeToString(nonce)
+	exp := strconv.FormatInt(a.now().Add(ticketTTL).Unix(), 10)
+	return n + "." + exp + "." + a.sign("ticket", n, exp), nil
+}
+
+// redeemTicket valida um ticket e o marca como usado
+func (a *webAuth) redeemTicket(ticket string) bool {
+	parts := strings.Split(ticket, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	nonce, exp, sig := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(sig), []byte(a.sign("ticket", nonce, exp))) || a.expired(exp) {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for used, expires := range a.usedTickets {
+		if now.After(expires) {
+			delete(a.usedTickets, used)
+		}
+	}
+	if _, used := a.usedTickets[nonce]; used {
+		return false
+	}
+	a.usedTickets[nonce] = now.Add(ticketTTL)
+	return true
+}
+
+// expired indica se o timestamp Unix exp já passou (ou é inválido)
+func (a *webAuth) expired(exp string) bool {
+	seconds, err := strconv.ParseInt(exp, 10, 64)
+	return err != nil || !a.now().Before(time.Unix(seconds, 0))
+}
+
+// failureLimiter conta as requisições não autorizadas por IP numa janela
+// deslizante, como o RateLimiter do comms
+type failureLimiter struct {
+	mu          sync.Mutex
+	failures    map[string]*failureTracker
+	window      time.Duration
+	maxFailures int
+}
+
+// failureTracker falhas recentes de um IP
+type failureTracker struct {
+	attempts  []time.Time
+	blockedAt time.Time
+}
+
+// newFailureLimiter cria um limitador com a janela e o máximo informados
+func newFailureLimiter(window time.Duration, maxFailures int) *failureLimiter {
+	return &failureLimiter{
+		failures:    make(map[string]*failureTracker),
+		window:      window,
+		maxFailures: maxFailures,
+	}
+}
+
+// blocked indica se o IP atingiu o máximo de falhas e ainda está bloqueado
+func (l *failureLimiter) blocked(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tracker, ok := l.failures[ip]
+	return ok && !tracker.blockedAt.IsZero() && now.Before(tracker.blockedAt.Add(l.window))
+}
+
+// fail registra uma falha do IP, bloqueando-o ao atingir o máximo
+func (l *failureLimiter) fail(ip string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pruneLocked(now)
+
+	tracker, ok := l.failures[ip]
+	if !ok {
+		tracker = &failureTracker{}
+		l.failures[ip] = tracker
+	}
+	tracker.attempts = append(recentAttempts(tracker.attempts, now, l.window), now)
+	if len(tracker.attempts) >= l.maxFailures {
+		tracker.blockedAt = now
+		tracker.attempts = nil
+		log.Warn().Str("remote", ip).Msg("Muitas tentativas não autorizadas na interface web; IP bloqueado temporariamente")
+	}
+}
+
+// pruneLocked descarta os IPs sem falhas recentes nem bloqueio; exige mu
+func (l *failureLimiter) pruneLocked(now time.Time) {
+	for ip, tracker := range l.failures {
+		tracker.attempts = recentAttempts(tracker.attempts, now, l.window)
+		if len(tracker.attempts) == 0 && !now.Before(tracker.blockedAt.Add(l.window)) {
+			delete(l.failures, ip)
+		}
+	}
+}
+
+// recentAttempts mantém as tentativas dentro da janela
+func recentAttempts(attempts []time.Time, now time.Time, window time.Duration) []time.Time {
+	start := now.Add(-window)
+	recent := attempts[:0]
+	for _, at := range attempts {
+		if at.After(start) && !at.After(now) {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// remoteIP extrai o IP do RemoteAddr da requisição
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requireAuth protege um handler: IPs bloqueados recebem 429 e requisições
+// sem credenciais válidas recebem 401 (a página inicial redireciona para o
+// /login) e contam para o bloqueio
+func (w *WebUI) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !w.auth.enabled() {
+			next(rw, r)
+			return
+		}
+
+		ip := remoteIP(r)
+		if w.rejectBlocked(rw, ip) {
+			return
+		}
+		if w.auth.authorized(r) {
+			next(rw, r)
+			return
+		}
+
+		w.auth.limiter.fail(ip, w.auth.now())
+		if r.URL.Path == "/" {
+			http.Redirect(rw, r, "/login", http.StatusFound)
+			return
+		}
+		rw.Header().Set("WWW-Authenticate", `Bearer realm="machine-monitor"`)
+		http.Error(rw, w.catalog.T("webui.error.unauthorized"), http.StatusUnauthorized)
+	}
+}
+
+// rejectBlocked responde 429 se o IP estiver bloqueado
+func (w *WebUI) rejectBlocked(rw http.ResponseWriter, ip string) bool {
+	if !w.auth.limiter.blocked(ip, w.auth.now()) {
+		return false
+	}
+	rw.Header().Set("Retry-After", strconv.Itoa(int(authFailureWindow.Seconds())))
+	http.Error(rw, w.catalog.T("webui.error.rate_limited"), http.StatusTooManyRequests)
+	return true
+}
+
+// LoginURL retorna base com um ticket de uso único para o /login, para que
+// o navegador aberto pelo tray entre sem pedir credenciais; sem autenticação
+// configurada (ou sem ticket) retorna base
+func (w *WebUI) LoginURL(base string) string {
+	if !w.auth.enabled() {
+		return base
+	}
+	ticket, err := w.auth.newTicket()
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao gerar link de acesso à interface web")
+		return base
+	}
+	return base + "/login?ticket=" + url.QueryEscape(ticket)
+}
+
+// handleLogin trata o /login: GET com ?ticket= troca o ticket do tray por
+// uma sessão; GET sem ticket mostra o formulário e POST o valida
+func (w *WebUI) handleLogin(rw http.ResponseWriter, r *http.Request) {
+	if !w.auth.enabled() {
+		http.Redirect(rw, r, "/", http.StatusFound)
+		return
+	}
+
+	ip := remoteIP(r)
+	if w.rejectBlocked(rw, ip) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			w.renderLogin(rw, http.StatusOK, false)
+			return
+		}
+		if w.auth.redeemTicket(ticket) {
+			w.startSession(rw, r)
+			return
+		}
+	case http.MethodPost:
+		if w.checkLoginForm(r) {
+			w.startSession(rw, r)
+			return
+		}
+	default:
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.auth.limiter.fail(ip, w.auth.now())
+	w.renderLogin(rw, http.StatusUnauthorized, true)
+}
+
+// checkLoginForm valida o token ou o usuário e a senha enviados pelo formulário
+func (w *WebUI) checkLoginForm(r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		return false
+	}
+	if token := r.PostForm.Get("token"); token != "" {
+		return w.auth.checkToken(token)
+	}
+	return w.auth.checkBasic(r.PostForm.Get("user"), r.PostForm.Get("password"))
+}
+
+// startSession grava o cookie de sessão e volta para a página inicial
+func (w *WebUI) startSession(rw http.ResponseWriter, r *http.Request) {
+	value, expires := w.auth.newSession()
+	http.SetCookie(rw, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
+
+// loginTemplate formulário mínimo do /login
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Machine Monitor Agent</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background-color: #f5f5f5; color: #333; }
+        form { max-width: 320px; margin: 80px auto; background: white; border-radius: 10px; padding: 20px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+        h1 { font-size: 20px; color: #2c3e50; margin-top: 0; }
+        input { display: block; width: 100%; box-sizing: border-box; margin: 5px 0 15px; padding: 8px; }
+        button { background: #3498db; color: white; border: none; padding: 10px 20px; border-radius: 5px; cursor: pointer; }
+        .error { color: #e74c3c; }
+    </style>
+</head>
+<body>
+    <form method="post" action="/login">
+        <h1>{{.Title}}</h1>
+        {{if .Failed}}<p class="error">{{.FailedText}}</p>{{end}}
+        {{if .Token}}<label>{{.TokenLabel}}<input type="password" name="token" autofocus></label>{{end}}
+        {{if .Basic}}<label>{{.UserLabel}}<input type="text" name="user"></label>
+        <label>{{.PasswordLabel}}<input type="password" name="password"></label>{{end}}
+        <button type="submit">{{.Submit}}</button>
+    </form>
+</body>
+</html>
+`))
+
+// renderLogin mostra o formulário com os campos das credenciais configuradas
+func (w *WebUI) renderLogin(rw http.ResponseWriter, status int, failed bool) {
+	data := map[string]interface{}{
+		"Lang":          w.catalog.Lang(),
+		"Title":         w.catalog.T("webui.login.title"),
+		"Failed":        failed,
+		"FailedText":    w.catalog.T("webui.login.failed"),
+		"Token":         w.auth.token != "",
+		"TokenLabel":    w.catalog.T("webui.login.token"),
+		"Basic":         w.auth.user != "",
+		"UserLabel":     w.catalog.T("webui.login.user"),
+		"PasswordLabel": w.catalog.T("webui.login.password"),
+		"Submit":        w.catalog.T("webui.login.submit"),
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	if err := loginTemplate.Execute(rw, data); err != nil {
+		log.Error().Err(err).Msg("Erro ao executar template de login")
+	}
+}
@@ -0,0 +1,233 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// newAuthTestWebUI cria a interface com token e Basic configurados e um
+// relógio ajustável
+func newAuthTestWebUI(t *testing.T) (*WebUI, *time.Time) {
+	t.Helper()
+	w := newTestWebUI(t, newFakeAgent(), types.UIConfig{
+		AuthToken: "s3cret-token",
+		BasicAuth: types.UIBasicAuth{User: "admin", Password: "hunter2"},
+	})
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	w.auth.now = func() time.Time { return now }
+	return w, &now
+}
+
+// apiRequest chama /api/status protegido, do IP informado
+func apiRequest(w *WebUI, ip string, configure func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+	r.RemoteAddr = ip + ":50000"
+	if configure != nil {
+		configure(r)
+	}
+	rec := httptest.NewRecorder()
+	w.requireAuth(w.handleAPIStatus)(rec, r)
+	return rec
+}
+
+// loginCookie devolve o cookie de sessão gravado pela resposta do /login
+func loginCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("login answered %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+				t.Fatalf("session cookie attributes: %+v", cookie)
+			}
+			return cookie
+		}
+	}
+	t.Fatal("login set no session cookie")
+	return nil
+}
+
+func TestRequireAuth(t *testing.T) {
+	w, _ := newAuthTestWebUI(t)
+
+	tests := []struct {
+		name      string
+		configure func(r *http.Request)
+		want      int
+	}{
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-token") }, http.StatusOK},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") }, http.StatusOK},
+		{"no credentials", nil, http.StatusUnauthorized},
+		{"wrong bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "nope") }, http.StatusUnauthorized},
+		// Um cabeçalho inválido não cai para o cookie
+		{"unknown scheme", func(r *http.Request) { r.Header.Set("Authorization", "Token s3cret-token") }, http.StatusUnauthorized},
+		{"forged cookie", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "99999999999.deadbeef"})
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apiRequest(w, "192.0.2.10", tt.configure)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without WWW-Authenticate")
+			}
+		})
+	}
+
+	// A página inicial manda para o /login em vez de responder 401
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	w.requireAuth(w.handleHome)(rec, r)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/login" {
+		t.Fatalf("home answered %d, Location %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Sem credenciais configuradas, nada é exigido
+	open := newTestWebUI(t, newFakeAgent(), types.UIConfig{})
+	if rec := apiRequest(open, "192.0.2.10", nil); rec.Code != http.StatusOK {
+		t.Fatalf("unauthenticated UI answered %d", rec.Code)
+	}
+}
+
+func TestRequireAuthRateLimited(t *testing.T) {
+	w, now := newAuthTestWebUI(t)
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-token") }
+
+	for i := 0; i < authMaxFailures; i++ {
+		if rec := apiRequest(w, "192.0.2.10", nil); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d answered %d", i, rec.Code)
+		}
+	}
+
+	// Bloqueado, nem o token correto passa; o /login também recusa
+	rec := apiRequest(w, "192.0.2.10", bearer)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Fatalf("blocked IP answered %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	login := httptest.NewRequest(http.MethodGet, "/login", nil)
+	login.RemoteAddr = "192.0.2.10:50000"
+	rec = httptest.NewRecorder()
+	w.handleLogin(rec, login)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("login from a blocked IP answered %d", rec.Code)
+	}
+
+	// Outro IP não é afetado
+	if rec := apiRequest(w, "192.0.2.20", bearer); rec.Code != http.StatusOK {
+		t.Fatalf("other IP answered %d", rec.Code)
+	}
+
+	// Passada a janela, o IP volta a ser atendido
+	*now = now.Add(authFailureWindow)
+	if rec := apiRequest(w, "192.0.2.10", bearer); rec.Code != http.StatusOK {
+		t.Fatalf("after the block answered %d", rec.Code)
+	}
+}
+
+func TestRequireAuthFailuresSlideOut(t *testing.T) {
+	w, now := newAuthTestWebUI(t)
+
+	// Falhas espalhadas além da janela não bloqueiam
+	for i := 0; i < 2*authMaxFailures; i++ {
+		if rec := apiRequest(w, "192.0.2.10", nil); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failure %d answered %d", i, rec.Code)
+		}
+		*now = now.Add(authFailureWindow / authMaxFailures * 2)
+	}
+}
+
+func TestLoginForm(t *testing.T) {
+	w, now := newAuthTestWebUI(t)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		w.handleLogin(rec, r)
+		return rec
+	}
+
+	// O formulário mostra os campos das credenciais configuradas
+	rec := httptest.NewRecorder()
+	w.handleLogin(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `name="token"`) || !strings.Contains(rec.Body.String(), `name="password"`) {
+		t.Fatalf("login form %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := post(url.Values{"token": {"nope"}}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token answered %d", rec.Code)
+	}
+	for _, form := range []url.Values{
+		{"token": {"s3cret-token"}},
+		{"user": {"admin"}, "password": {"hunter2"}},
+	} {
+		cookie := loginCookie(t, post(form))
+		if rec := apiRequest(w, "192.0.2.10", func(r *http.Request) { r.AddCookie(cookie) }); rec.Code != http.StatusOK {
+			t.Fatalf("session from %v answered %d", form, rec.Code)
+		}
+	}
+
+	// A sessão expira
+	cookie := loginCookie(t, post(url.Values{"token": {"s3cret-token"}}))
+	*now = now.Add(sessionTTL)
+	if rec := apiRequest(w, "192.0.2.10", func(r *http.Request) { r.AddCookie(cookie) }); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expired session answered %d", rec.Code)
+	}
+}
+
+func TestLoginTicket(t *testing.T) {
+	w, now := newAuthTestWebUI(t)
+
+	link := w.LoginURL("http://127.0.0.1:8080")
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Path != "/login" || parsed.Query().Get("ticket") == "" {
+		t.Fatalf("login URL %q", link)
+	}
+	redeem := func(ticket string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		w.handleLogin(rec, httptest.NewRequest(http.MethodGet, "/login?ticket="+url.QueryEscape(ticket), nil))
+		return rec
+	}
+
+	// O link do tray entra sem credenciais, uma única vez
+	ticket := parsed.Query().Get("ticket")
+	cookie := loginCookie(t, redeem(ticket))
+	if rec := apiRequest(w, "192.0.2.10", func(r *http.Request) { r.AddCookie(cookie) }); rec.Code != http.StatusOK {
+		t.Fatalf("ticket session answered %d", rec.Code)
+	}
+	if rec := redeem(ticket); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused ticket answered %d", rec.Code)
+	}
+
+	// Tickets adulterados ou vencidos são recusados
+	fresh, err := w.auth.newTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(fresh)
+	tampered[len(tampered)-1] ^= 1
+	if rec := redeem(string(tampered)); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("tampered ticket answered %d", rec.Code)
+	}
+	*now = now.Add(ticketTTL)
+	if rec := redeem(fresh); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expired ticket answered %d", rec.Code)
+	}
+
+	// Sem autenticação configurada, o link é a própria base
+	open := newTestWebUI(t, newFakeAgent(), types.UIConfig{})
+	if link := open.LoginURL("http://127.0.0.1:8080"); link != "http://127.0.0.1:8080" {
+		t.Fatalf("unauthenticated login URL %q", link)
+	}
+}
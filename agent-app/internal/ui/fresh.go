@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// freshCollectTimeout limita cada coleta disparada pelos endpoints /fresh
+const freshCollectTimeout = 10 * time.Second
+
+// freshCall é uma coleta em andamento; quem chega durante ela espera o
+// mesmo resultado em vez de disparar outra
+type freshCall struct {
+	done        chan struct{}
+	value       interface{}
+	collectedAt time.Time
+	err         error
+}
+
+// freshSource agrupa as coletas sem cache de uma seção (singleflight) e
+// guarda o último resultado para o parâmetro max_age
+type freshSource struct {
+	mu          sync.Mutex
+	value       interface{}
+	collectedAt time.Time
+	call        *freshCall
+}
+
+// get retorna o último resultado se tiver no máximo maxAge; senão entra na
+// coleta em andamento ou inicia uma. A coleta usa parent, não o contexto da
+// requisição, para que um cliente que desiste não cancele a dos demais.
+func (s *freshSource) get(ctx, parent context.Context, maxAge time.Duration,
+	collect func(ctx context.Context) (interface{}, time.Time, error)) (interface{}, time.Time, error) {
+	s.mu.Lock()
+	if maxAge > 0 && s.value != nil && time.Since(s.collectedAt) <= maxAge {
+		value, collectedAt := s.value, s.collectedAt
+		s.mu.Unlock()
+		return value, collectedAt, nil
+	}
+
+	call := s.call
+	if call == nil {
+		call = &freshCall{done: make(chan struct{})}
+		s.call = call
+		go s.run(parent, call, collect)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.collectedAt, call.err
+	case <-ctx.Done():
+		return nil, time.Time{}, ctx.Err()
+	}
+}
+
+// run executa a coleta e publica o resultado para quem estiver esperando
+func (s *freshSource) run(parent context.Context, call *freshCall,
+	collect func(ctx context.Context) (interface{}, time.Time, error)) {
+	ctx, cancel := context.WithTimeout(parent, freshCollectTimeout)
+	defer cancel()
+
+	call.value, call.collectedAt, call.err = collect(ctx)
+
+	s.mu.Lock()
+	s.call = nil
+	if call.err == nil {
+		s.value, s.collectedAt = call.value, call.collectedAt
+	}
+	s.mu.Unlock()
+	close(call.done)
+}
+
+// parseMaxAge lê ?max_age= em segundos; ausente equivale a 0 (sempre coletar)
+func parseMaxAge(r *http.Request) (time.Duration, error) {
+	raw := r.URL.Query().Get("max_age")
+	if raw == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("max_age inválido: %q", raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// writeCollectedJSON escreve value com ETag e Last-Modified derivados do
+// horário da coleta e responde 304 se o cliente já tiver essa versão
+func writeCollectedJSON(rw http.ResponseWriter, r *http.Request, value interface{}, collectedAt time.Time) {
+	if !collectedAt.IsZero() {
+		etag := fmt.Sprintf(`"%x"`, collectedAt.UnixNano())
+		rw.Header().Set("ETag", etag)
+		rw.Header().Set("Last-Modified", collectedAt.UTC().Format(http.TimeFormat))
+		rw.Header().Set("Cache-Control", "no-cache")
+
+		if notModified(r, etag, collectedAt) {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(value)
+}
+
+// notModified avalia If-None-Match e, na ausência dele, If-Modified-Since
+func notModified(r *http.Request, etag string, collectedAt time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		if err == nil && !collectedAt.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestFreshCoalescesConcurrentRequests(t *testing.T) {
+	agent := newFakeAgent()
+	agent.block = make(chan struct{})
+	w := newTestWebUI(t, agent, types.UIConfig{})
+
+	const clients = 5
+	codes := make([]int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			w.handleAPIHardwareFresh(rec, httptest.NewRequest(http.MethodGet, "/api/hardware/fresh", nil))
+			codes[i] = rec.Code
+		}(i)
+	}
+
+	// Todos entram na coleta em andamento antes de ela terminar
+	deadline := time.Now().Add(2 * time.Second)
+	for agent.hardwareFresh.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(agent.block)
+	wg.Wait()
+
+	if n := agent.hardwareFresh.Load(); n != 1 {
+		t.Fatalf("%d collections for %d concurrent requests, want 1", n, clients)
+	}
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("client %d got %d", i, code)
+		}
+	}
+
+	// Terminada a coleta, a próxima requisição coleta de novo
+	rec := httptest.NewRecorder()
+	w.handleAPIHardwareFresh(rec, httptest.NewRequest(http.MethodGet, "/api/hardware/fresh", nil))
+	if n := agent.hardwareFresh.Load(); n != 2 {
+		t.Fatalf("%d collections after the shared one, want 2", n)
+	}
+}
+
+func TestFreshClientCancelDoesNotCancelCollection(t *testing.T) {
+	agent := newFakeAgent()
+	agent.setCollectedAt(time.Now())
+	agent.block = make(chan struct{})
+	w := newTestWebUI(t, agent, types.UIConfig{})
+
+	// O cliente desiste antes do fim da coleta
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	w.handleAPISystemFresh(rec, httptest.NewRequest(http.MethodGet, "/api/system/fresh", nil).WithContext(ctx))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("cancelled client got %d", rec.Code)
+	}
+
+	// A coleta segue e o resultado fica guardado para max_age
+	close(agent.block)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w.systemFresh.mu.Lock()
+		pending := w.systemFresh.call != nil
+		w.systemFresh.mu.Unlock()
+		if !pending || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	rec = httptest.NewRecorder()
+	w.handleAPISystemFresh(rec, httptest.NewRequest(http.MethodGet, "/api/system/fresh?max_age=60", nil))
+	if rec.Code != http.StatusOK || agent.systemFresh.Load() != 1 {
+		t.Fatalf("max_age after the cancelled client answered %d after %d collections", rec.Code, agent.systemFresh.Load())
+	}
+}
+
+func TestFreshMaxAge(t *testing.T) {
+	agent := newFakeAgent()
+	agent.setCollectedAt(time.Now())
+	w := newTestWebUI(t, agent, types.UIConfig{})
+
+	get := func(query string) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		w.handleAPISystemFresh(rec, httptest.NewRequest(http.MethodGet, "/api/system/fresh"+query, nil))
+		return rec.Code
+	}
+
+	get("")
+	if code := get("?max_age=60"); code != http.StatusOK || agent.systemFresh.Load() != 1 {
+		t.Fatalf("max_age=60 answered %d after %d collections", code, agent.systemFresh.Load())
+	}
+	// Sem max_age (ou com 0) sempre coleta
+	get("")
+	get("?max_age=0")
+	if n := agent.systemFresh.Load(); n != 3 {
+		t.Fatalf("%d collections, want 3", n)
+	}
+
+	// Resultado mais velho que max_age é coletado de novo
+	agent.setCollectedAt(time.Now().Add(-time.Minute))
+	get("")
+	get("?max_age=30")
+	if n := agent.systemFresh.Load(); n != 5 {
+		t.Fatalf("%d collections with a stale result, want 5", n)
+	}
+
+	for _, query := range []string{"?max_age=-1", "?max_age=abc"} {
+		if code := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s answered %d", query, code)
+		}
+	}
+}
+
+func TestCollectedJSONConditionalRequests(t *testing.T) {
+	agent := newFakeAgent()
+	w := newTestWebUI(t, agent, types.UIConfig{})
+
+	rec := httptest.NewRecorder()
+	w.handleAPISystem(rec, httptest.NewRequest(http.MethodGet, "/api/system", nil))
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	if rec.Code != http.StatusOK || etag == "" || lastModified != "Mon, 05 Jan 2026 09:00:00 GMT" {
+		t.Fatalf("first response %d, ETag %q, Last-Modified %q", rec.Code, etag, lastModified)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"matching ETag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"weak ETag in a list", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other ETag wins over date", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		{"same date", map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"older date", map[string]string{"If-Modified-Since": "Mon, 05 Jan 2026 08:59:59 GMT"}, http.StatusOK},
+		{"no condition", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/system", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		w.handleAPISystem(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: 304 with a body", tt.name)
+		}
+	}
+
+	// Uma coleta nova muda o ETag
+	agent.setCollectedAt(agent.timestamp().Add(time.Second))
+	req := httptest.NewRequest(http.MethodGet, "/api/hardware/fresh", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	w.handleAPIHardwareFresh(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("new collection answered %d with ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
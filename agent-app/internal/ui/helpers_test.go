@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/i18n"
+	"machine-monitor-agent/internal/types"
+)
+
+// fakeAgent implementa AgentInterface com dados fixos. As coletas sem cache
+// contam as chamadas e, com block preenchido, esperam até ele ser fechado.
+type fakeAgent struct {
+	mu          sync.Mutex
+	collectedAt time.Time
+	block       chan struct{}
+
+	systemFresh   atomic.Int64
+	hardwareFresh atomic.Int64
+
+	config     types.Config
+	status     types.AgentStatus
+	events     []types.Event
+	usage      types.UsageSample
+	security   types.SecurityPosture
+	execution  types.ExecutionMetrics
+	connection types.ConnectionMetrics
+}
+
+func newFakeAgent() *fakeAgent {
+	return &fakeAgent{collectedAt: time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)}
+}
+
+// setCollectedAt muda o horário devolvido pelas próximas coletas
+func (a *fakeAgent) setCollectedAt(at time.Time) {
+	a.mu.Lock()
+	a.collectedAt = at
+	a.mu.Unlock()
+}
+
+func (a *fakeAgent) timestamp() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.collectedAt
+}
+
+// wait bloqueia a coleta enquanto block estiver aberto
+func (a *fakeAgent) wait(ctx context.Context) error {
+	if a.block == nil {
+		return nil
+	}
+	select {
+	case <-a.block:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *fakeAgent) GetConfig() *types.Config {
+	config := a.config
+	return &config
+}
+
+func (a *fakeAgent) GetStatus() *types.AgentStatus {
+	status := a.status
+	return &status
+}
+
+func (a *fakeAgent) CollectSystemInfo(ctx context.Context) (*types.SystemInfo, error) {
+	return &types.SystemInfo{Hostname: "test-host", Timestamp: a.timestamp()}, nil
+}
+
+func (a *fakeAgent) CollectHardwareInfo(ctx context.Context) (*types.HardwareInfo, error) {
+	return &types.HardwareInfo{Timestamp: a.timestamp()}, nil
+}
+
+func (a *fakeAgent) CollectSystemInfoFresh(ctx context.Context) (*types.SystemInfo, error) {
+	a.systemFresh.Add(1)
+	if err := a.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.CollectSystemInfo(ctx)
+}
+
+func (a *fakeAgent) CollectHardwareInfoFresh(ctx context.Context) (*types.HardwareInfo, error) {
+	a.hardwareFresh.Add(1)
+	if err := a.wait(ctx); err != nil {
+		return nil, err
+	}
+	return a.CollectHardwareInfo(ctx)
+}
+
+func (a *fakeAgent) CollectSecurityPosture(ctx context.Context) (*types.SecurityPosture, error) {
+	posture := a.security
+	return &posture, nil
+}
+
+func (a *fakeAgent) InvalidateCache(keys ...string) {}
+
+func (a *fakeAgent) GetEvents(since time.Time, limit int) []types.Event {
+	var events []types.Event
+	for _, event := range a.events {
+		if event.Timestamp.After(since) {
+			events = append(events, event)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+func (a *fakeAgent) GetInventoryHistory(limit int) ([]types.InventoryHistoryEntry, error) {
+	return nil, nil
+}
+
+func (a *fakeAgent) GetInventoryAt(at time.Time) (*types.InventoryHistoryEntry, error) {
+	return nil, nil
+}
+
+func (a *fakeAgent) SampleUsage(ctx context.Context) (*types.UsageSample, error) {
+	usage := a.usage
+	return &usage, nil
+}
+
+func (a *fakeAgent) GetExecutionMetrics() types.ExecutionMetrics { return a.execution }
+
+func (a *fakeAgent) GetConnectionMetrics() types.ConnectionMetrics { return a.connection }
+
+// newTestWebUI cria a interface web sem iniciar o servidor; os handlers são
+// chamados diretamente
+func newTestWebUI(t *testing.T, agent AgentInterface, config types.UIConfig) *WebUI {
+	t.Helper()
+	w, err := NewWebUI(agent, config, i18n.New("en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.cancel)
+	return w
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+// redactedValue substitui tokens, senhas e endereços do backend no /api/metrics
+const redactedValue = "[redacted]"
+
+// metricsDocument é a resposta do /api/metrics
+type metricsDocument struct {
+	Timestamp  time.Time               `json:"timestamp"`
+	Agent      *types.AgentStatus      `json:"agent"`
+	Executor   types.ExecutionMetrics  `json:"executor"`
+	Connection types.ConnectionMetrics `json:"connection"`
+	Backend    backendSummary          `json:"backend"`
+	WebUI      webUISummary            `json:"webui"`
+}
+
+// backendSummary descreve a configuração do backend sem expor o endereço
+// nem a chave
+type backendSummary struct {
+	URL              string `json:"url"`
+	UseHTTPS         bool   `json:"use_https"`
+	APIKeyConfigured bool   `json:"api_key_configured"`
+	MaxRetries       int    `json:"max_retries"`
+}
+
+// webUISummary estado da própria interface web
+type webUISummary struct {
+	LiveClients int  `json:"live_clients"`
+	AuthEnabled bool `json:"auth_enabled"`
+}
+
+var (
+	// urlPattern encontra URLs em mensagens de erro (ex.: Post "http://...")
+	urlPattern = regexp.MustCompile(`(?i)\b(?:https?|wss?)://[^\s"']+`)
+	// bearerPattern encontra credenciais em cabeçalhos citados em erros
+	bearerPattern = regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+\S+`)
+)
+
+// secretRedactor troca por redactedValue os segredos da configuração, o
+// endereço do backend e qualquer URL ou credencial que apareça num texto
+type secretRedactor struct {
+	secrets []string
+}
+
+// newSecretRedactor reúne os valores sensíveis de config
+func newSecretRedactor(config *types.Config) *secretRedactor {
+	secrets := []string{
+		config.Security.APIKey,
+		config.UI.AuthToken,
+		config.UI.BasicAuth.Password,
+		config.Server.BaseURL,
+	}
+	if u, err := url.Parse(config.Server.BaseURL); err == nil {
+		secrets = append(secrets, u.Host, u.Hostname())
+	}
+
+	r := &secretRedactor{}
+	for _, secret := range secrets {
+		if secret != "" {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Os mais longos primeiro, para a URL inteira sair antes do host
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r
+}
+
+// redact retorna text sem segredos, URLs e credenciais
+func (r *secretRedactor) redact(text string) string {
+	if text == "" {
+		return text
+	}
+	text = urlPattern.ReplaceAllString(text, redactedValue)
+	text = bearerPattern.ReplaceAllString(text, "$1 "+redactedValue)
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	return text
+}
+
+// handleAPIMetrics trata a API de diagnóstico: estado do agente, métricas
+// do executor (com as estatísticas por tipo de comando), HTTP, WebSocket e
+// filas. Erros e configuração saem sem tokens nem endereços do backend.
+func (w *WebUI) handleAPIMetrics(rw http.ResponseWriter, r *http.Request) {
+	config := w.agent.GetConfig()
+	redactor := newSecretRedactor(config)
+
+	connection := w.agent.GetConnectionMetrics()
+	connection.HTTP.LastError = redactor.redact(connection.HTTP.LastError)
+	connection.WebSocket.LastError = redactor.redact(connection.WebSocket.LastError)
+
+	doc := metricsDocument{
+		Timestamp:  time.Now(),
+		Agent:      w.agent.GetStatus(),
+		Executor:   w.agent.GetExecutionMetrics(),
+		Connection: connection,
+		Backend: backendSummary{
+			URL:              redactedValue,
+			UseHTTPS:         config.Server.UseHTTPS,
+			APIKeyConfigured: config.Security.APIKey != "",
+			MaxRetries:       config.Server.MaxRetries,
+		},
+		WebUI: webUISummary{
+			LiveClients: w.hub.count(),
+			AuthEnabled: w.auth.enabled(),
+		},
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(rw).Encode(doc)
+}
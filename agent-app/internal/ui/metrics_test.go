@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestAPIMetrics(t *testing.T) {
+	agent := newFakeAgent()
+	agent.config.Server.BaseURL = "https://backend.internal.example:8443"
+	agent.config.Server.UseHTTPS = true
+	agent.config.Server.MaxRetries = 3
+	agent.config.Security.APIKey = "api-key-123"
+	agent.status = types.AgentStatus{State: types.StateRunning, CommandsRun: 4}
+	agent.execution = types.ExecutionMetrics{
+		TotalCommands:      4,
+		SuccessfulCommands: 3,
+		FailedCommands:     1,
+		MaxConcurrency:     2,
+		ByType:             map[string]types.CommandTypeStats{"shell": {Count: 4, Failures: 1}},
+	}
+	agent.connection = types.ConnectionMetrics{
+		HTTP: types.HTTPMetrics{
+			Requests:  10,
+			Failures:  2,
+			LastError: `Post "https://backend.internal.example:8443/api/heartbeat": dial tcp backend.internal.example:8443: connection refused`,
+		},
+		WebSocket: types.WSMetrics{
+			Connected:            true,
+			CommandQueueDepth:    1,
+			CommandQueueCapacity: 100,
+			LastError:            "handshake failed: Authorization: Bearer api-key-123 rejected",
+		},
+	}
+	config := types.UIConfig{AuthToken: "ui-token-456", BasicAuth: types.UIBasicAuth{User: "admin", Password: "hunter2"}}
+	agent.config.UI = config
+	w := newTestWebUI(t, agent, config)
+
+	rec := httptest.NewRecorder()
+	w.handleAPIMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("status %d, headers %v", rec.Code, rec.Header())
+	}
+	body := rec.Body.String()
+
+	// Nenhum segredo nem endereço do backend sai no documento
+	for _, secret := range []string{"backend.internal.example", "api-key-123", "ui-token-456", "hunter2"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("%q leaked: %s", secret, body)
+		}
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, section := range []string{"timestamp", "agent", "executor", "connection", "backend", "webui"} {
+		if _, ok := doc[section]; !ok {
+			t.Errorf("section %s missing: %s", section, body)
+		}
+	}
+
+	var got struct {
+		Agent      types.AgentStatus       `json:"agent"`
+		Executor   types.ExecutionMetrics  `json:"executor"`
+		Connection types.ConnectionMetrics `json:"connection"`
+		Backend    map[string]interface{}  `json:"backend"`
+		WebUI      map[string]interface{}  `json:"webui"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Agent.State != types.StateRunning || got.Executor.TotalCommands != 4 || got.Executor.ByType["shell"].Failures != 1 {
+		t.Errorf("agent %+v, executor %+v", got.Agent, got.Executor)
+	}
+	if got.Connection.HTTP.Requests != 10 || !got.Connection.WebSocket.Connected || got.Connection.WebSocket.CommandQueueCapacity != 100 {
+		t.Errorf("connection %+v", got.Connection)
+	}
+	wantBackend := map[string]interface{}{"url": redactedValue, "use_https": true, "api_key_configured": true, "max_retries": float64(3)}
+	for key, want := range wantBackend {
+		if got.Backend[key] != want {
+			t.Errorf("backend.%s = %v, want %v", key, got.Backend[key], want)
+		}
+	}
+	if got.WebUI["auth_enabled"] != true || got.WebUI["live_clients"] != float64(0) {
+		t.Errorf("webui %v", got.WebUI)
+	}
+
+	// Os erros continuam legíveis, só sem os segredos
+	if !strings.Contains(got.Connection.HTTP.LastError, "connection refused") || !strings.Contains(got.Connection.HTTP.LastError, redactedValue) {
+		t.Errorf("HTTP last error %q", got.Connection.HTTP.LastError)
+	}
+	if got.Connection.WebSocket.LastError != "handshake failed: Authorization: Bearer [redacted] rejected" {
+		t.Errorf("WebSocket last error %q", got.Connection.WebSocket.LastError)
+	}
+}
+
+func TestSecretRedactor(t *testing.T) {
+	config := &types.Config{}
+	config.Server.BaseURL = "http://10.0.0.5:8080"
+	config.Security.APIKey = "k3y"
+	r := newSecretRedactor(config)
+
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"timeout", "timeout"},
+		{`Get "http://10.0.0.5:8080/api": EOF`, `Get "[redacted]": EOF`},
+		{"wss://other.example/ws closed", "[redacted] closed"},
+		{"dial tcp 10.0.0.5:8080: refused", "dial tcp [redacted]: refused"},
+		{"dial tcp 10.0.0.5:9090: refused", "dial tcp [redacted]:9090: refused"},
+		{"basic dXNlcjpwYXNz sent", "basic [redacted] sent"},
+		{"key k3y invalid", "key [redacted] invalid"},
+	}
+	for _, tt := range tests {
+		if got := r.redact(tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultPushInterval intervalo padrão das atualizações do /ws
+	DefaultPushInterval = 2 * time.Second
+	// pushWriteWait prazo de escrita de cada mensagem para um navegador
+	pushWriteWait = 5 * time.Second
+	// pushClientBuffer mensagens aguardando envio por navegador; um cliente
+	// que acumula mais que isso é lento e é desconectado
+	pushClientBuffer = 8
+	// pushRecentEvents eventos enviados no snapshot inicial
+	pushRecentEvents = 20
+)
+
+// pushMessage é a mensagem enviada aos navegadores: "snapshot" ao conectar
+// (com os eventos recentes) e "update" a cada intervalo (com os eventos
+// novos desde o anterior)
+type pushMessage struct {
+	Type      string             `json:"type"`
+	Status    *types.AgentStatus `json:"status"`
+	Usage     *types.UsageSample `json:"usage,omitempty"`
+	Events    []types.Event      `json:"events"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// pushClient é um navegador conectado ao /ws. Só writePump escreve
+// mensagens de dados na conexão; readPump só lê para processar os frames de
+// controle e detectar o fechamento.
+type pushClient struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// close encerra a conexão; só a primeira chamada vale
+func (c *pushClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// pushHub mantém os navegadores conectados e distribui as mensagens
+type pushHub struct {
+	mu      sync.Mutex
+	clients map[*pushClient]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+
+	writeWait time.Duration
+}
+
+// newPushHub cria um hub vazio
+func newPushHub() *pushHub {
+	return &pushHub{
+		clients:   make(map[*pushClient]struct{}),
+		writeWait: pushWriteWait,
+	}
+}
+
+// register passa a distribuir mensagens para conn, já com first na fila
+// (nil para nenhuma); retorna false se o hub já foi fechado
+func (h *pushHub) register(conn *websocket.Conn, first []byte) (*pushClient, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+
+	c := &pushClient{
+		conn: conn,
+		send: make(chan []byte, pushClientBuffer),
+		done: make(chan struct{}),
+	}
+	if first != nil {
+		c.send <- first
+	}
+	h.clients[c] = struct{}{}
+
+	h.wg.Add(2)
+	go h.writePump(c)
+	go h.readPump(c)
+	return c, true
+}
+
+// unregister remove e fecha um cliente
+func (h *pushHub) unregister(c *pushClient) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// broadcast enfileira msg para todos os clientes; um cliente com a fila
+// cheia não acompanha o intervalo e é desconectado (o navegador volta ao
+// polling e reconecta)
+func (h *pushHub) broadcast(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			delete(h.clients, c)
+			c.close()
+			log.Warn().Str("remote", c.conn.RemoteAddr().String()).Msg("Cliente lento desconectado do /ws")
+		}
+	}
+}
+
+// count retorna quantos clientes estão conectados
+func (h *pushHub) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// close avisa os clientes (close frame), fecha as conexões e espera os
+// pumps terminarem; depois disso register recusa novos clientes
+func (h *pushHub) close() {
+	h.mu.Lock()
+	h.closed = true
+	clients := h.clients
+	h.clients = make(map[*pushClient]struct{})
+	h.mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	goingAway := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+	for c := range clients {
+		_ = c.conn.WriteControl(websocket.CloseMessage, goingAway, deadline)
+		c.close()
+	}
+	h.wg.Wait()
+}
+
+// writePump envia as mensagens do cliente, com prazo por escrita
+func (h *pushHub) writePump(c *pushClient) {
+	defer h.wg.Done()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(h.writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				h.unregister(c)
+				return
+			}
+		}
+	}
+}
+
+// readPump descarta o que o navegador enviar e desconecta quando ele fecha
+func (h *pushHub) readPump(c *pushClient) {
+	defer h.wg.Done()
+
+	c.conn.SetReadLimit(512)
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			h.unregister(c)
+			return
+		}
+	}
+}
+
+// pushUpgrader aceita apenas páginas servidas pela própria interface (o
+// CheckOrigin padrão exige a mesma origem)
+var pushUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// handlePush trata o /ws: envia um snapshot ao conectar e, depois, as
+// atualizações de runPush
+func (w *WebUI) handlePush(rw http.ResponseWriter, r *http.Request) {
+	conn, err := pushUpgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		return // Upgrade já respondeu com o erro
+	}
+
+	snapshot, err := json.Marshal(w.pushSnapshot(r.Context(), "snapshot", w.agent.GetEvents(time.Time{}, pushRecentEvents)))
+	if err != nil {
+		log.Error().Err(err).Msg("Erro ao serializar snapshot do /ws")
+		_ = conn.Close()
+		return
+	}
+	if _, ok := w.hub.register(conn, snapshot); !ok {
+		_ = conn.Close()
+	}
+}
+
+// runPush envia uma atualização a cada pushInterval enquanto houver
+// navegadores conectados
+func (w *WebUI) runPush() {
+	ticker := time.NewTicker(w.pushInterval)
+	defer ticker.Stop()
+
+	cursor := time.Now()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if w.hub.count() == 0 {
+			cursor = time.Now()
+			continue
+		}
+
+		events := w.agent.GetEvents(cursor, 0)
+		if len(events) > 0 {
+			cursor = events[len(events)-1].Timestamp
+		}
+		msg, err := json.Marshal(w.pushSnapshot(w.ctx, "update", events))
+		if err != nil {
+			log.Error().Err(err).Msg("Erro ao serializar atualização do /ws")
+			continue
+		}
+		w.hub.broadcast(msg)
+	}
+}
+
+// pushSnapshot monta uma mensagem com o status e uma amostra de uso; sem a
+// amostra (falha ou timeout), a mensagem segue sem usage
+func (w *WebUI) pushSnapshot(ctx context.Context, kind string, events []types.Event) pushMessage {
+	msg := pushMessage{
+		Type:      kind,
+		Status:    w.agent.GetStatus(),
+		Events:    events,
+		Timestamp: time.Now(),
+	}
+	if msg.Events == nil {
+		msg.Events = []types.Event{}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.pushInterval)
+	defer cancel()
+	if usage, err := w.agent.SampleUsage(ctx); err == nil {
+		msg.Usage = usage
+	}
+	return msg
+}
@@ -0,0 +1,218 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/timeutil"
+	"machine-monitor-agent/internal/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// newPushServer serve o /ws da interface em um servidor de teste
+func newPushServer(t *testing.T, w *WebUI) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(w.handlePush))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// dialPush conecta um navegador de teste e lê o snapshot inicial
+func dialPush(t *testing.T, server *httptest.Server) (*websocket.Conn, pushMessage) {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, readPush(t, conn)
+}
+
+// readPush lê a próxima mensagem do /ws
+func readPush(t *testing.T, conn *websocket.Conn) pushMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg pushMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// waitClients espera o hub chegar a n clientes
+func waitClients(t *testing.T, hub *pushHub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.count() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d clients, want %d", hub.count(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPushSnapshotAndBroadcast(t *testing.T) {
+	agent := newFakeAgent()
+	agent.status = types.AgentStatus{State: types.StateRunning}
+	agent.usage = types.UsageSample{CPUPercent: 12.5}
+	past := time.Now().Add(-time.Minute)
+	agent.events = []types.Event{{Timestamp: past, Type: "agent_started"}}
+	w := newTestWebUI(t, agent, types.UIConfig{})
+	server := newPushServer(t, w)
+
+	first, snapshot := dialPush(t, server)
+	if snapshot.Type != "snapshot" || snapshot.Status.State != types.StateRunning || snapshot.Usage == nil || snapshot.Usage.CPUPercent != 12.5 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+	if len(snapshot.Events) != 1 || snapshot.Events[0].Type != "agent_started" {
+		t.Fatalf("snapshot events = %+v", snapshot.Events)
+	}
+	second, _ := dialPush(t, server)
+	waitClients(t, w.hub, 2)
+
+	// Cada navegador recebe cada mensagem
+	for i := 0; i < 3; i++ {
+		w.hub.broadcast([]byte(`{"type":"update","events":[]}`))
+	}
+	for _, conn := range []*websocket.Conn{first, second} {
+		for i := 0; i < 3; i++ {
+			if msg := readPush(t, conn); msg.Type != "update" {
+				t.Fatalf("message %d = %+v", i, msg)
+			}
+		}
+	}
+
+	// Um navegador que fecha sai do hub sem afetar o outro
+	_ = first.Close()
+	waitClients(t, w.hub, 1)
+	w.hub.broadcast([]byte(`{"type":"update","events":[]}`))
+	if msg := readPush(t, second); msg.Type != "update" {
+		t.Fatalf("after a client left: %+v", msg)
+	}
+}
+
+func TestPushUpdatesCarryNewEvents(t *testing.T) {
+	agent := newFakeAgent()
+	// Depois do cursor inicial do runPush, entregue uma única vez
+	agent.events = []types.Event{{Timestamp: time.Now().Add(time.Hour), Type: "inventory_sent"}}
+	w := newTestWebUI(t, agent, types.UIConfig{PushInterval: timeutil.Seconds(20 * time.Millisecond)})
+	server := newPushServer(t, w)
+
+	conn, _ := dialPush(t, server)
+	waitClients(t, w.hub, 1)
+	go w.runPush()
+
+	var delivered int
+	for i := 0; i < 5; i++ {
+		msg := readPush(t, conn)
+		if msg.Type != "update" || msg.Status == nil || msg.Events == nil {
+			t.Fatalf("update %d = %+v", i, msg)
+		}
+		delivered += len(msg.Events)
+	}
+	if delivered != 1 {
+		t.Fatalf("event delivered %d times over 5 updates, want 1", delivered)
+	}
+}
+
+func TestPushSlowClientDisconnected(t *testing.T) {
+	w := newTestWebUI(t, newFakeAgent(), types.UIConfig{})
+	w.hub.writeWait = 50 * time.Millisecond
+	server := newPushServer(t, w)
+
+	// O lento nunca lê depois do snapshot; o rápido lê tudo
+	slow, _ := dialPush(t, server)
+	fast, _ := dialPush(t, server)
+	waitClients(t, w.hub, 2)
+	readErr := make(chan error, 1)
+	go func() {
+		_ = fast.SetReadDeadline(time.Time{})
+		for {
+			if _, _, err := fast.ReadMessage(); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	msg := bytes.Repeat([]byte("x"), 256<<10)
+	deadline := time.Now().Add(5 * time.Second)
+	for w.hub.count() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("slow client never disconnected")
+		}
+		w.hub.broadcast(msg)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// O lento recebe o fechamento em vez de segurar o hub
+	_ = slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := slow.ReadMessage(); err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Fatal("slow client connection left open")
+			}
+			break
+		}
+	}
+	select {
+	case err := <-readErr:
+		t.Fatalf("fast client disconnected: %v", err)
+	default:
+	}
+}
+
+func TestPushStopClosesConnections(t *testing.T) {
+	w := newTestWebUI(t, newFakeAgent(), types.UIConfig{})
+	server := newPushServer(t, w)
+
+	var conns []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		conn, _ := dialPush(t, server)
+		conns = append(conns, conn)
+	}
+	waitClients(t, w.hub, 3)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- w.Stop() }()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked with open connections")
+	}
+
+	// Cada navegador recebe o going away e a conexão fecha
+	for i, conn := range conns {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := conn.ReadMessage()
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Fatalf("client %d: %v", i, err)
+		}
+	}
+	if n := w.hub.count(); n != 0 {
+		t.Fatalf("%d clients after Stop", n)
+	}
+
+	// Depois do Stop, novas conexões são fechadas sem snapshot
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg json.RawMessage
+	if err := conn.ReadJSON(&msg); err == nil {
+		t.Fatalf("connection after Stop got %s", msg)
+	}
+}
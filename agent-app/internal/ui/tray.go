@@ -4,11 +4,11 @@ package ui
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
+	"machine-monitor-agent/internal/i18n"
+	"machine-monitor-agent/internal/timeutil"
 	"machine-monitor-agent/internal/types"
 
 	"github.com/getlantern/systray"
@@ -21,6 +21,7 @@ type TrayIcon struct {
 	onShowUI  func()
 	onRestart func()
 	onExit    func()
+	catalog   *i18n.Catalog
 
 	// Menu items
 	statusItem  *systray.MenuItem
@@ -28,6 +29,10 @@ type TrayIcon struct {
 	restartItem *systray.MenuItem
 	exitItem    *systray.MenuItem
 
+	// Endereço da interface web exibido no tooltip quando não há navegador
+	url     string
+	urlChan chan string
+
 	// Controle
 	updateChan chan *types.AgentStatus
 	ctx        context.Context
@@ -35,14 +40,16 @@ type TrayIcon struct {
 }
 
 // NewTrayIcon cria uma nova instância do ícone na bandeja
-func NewTrayIcon(onShowUI, onRestart, onExit func()) *TrayIcon {
+func NewTrayIcon(onShowUI, onRestart, onExit func(), catalog *i18n.Catalog) *TrayIcon {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &TrayIcon{
 		onShowUI:   onShowUI,
 		onRestart:  onRestart,
 		onExit:     onExit,
+		catalog:    catalog,
 		updateChan: make(chan *types.AgentStatus, 10),
+		urlChan:    make(chan string, 1),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -70,6 +77,16 @@ func (t *TrayIcon) UpdateStatus(status *types.AgentStatus) {
 	}
 }
 
+// ShowURL passa a exibir o endereço da interface web no tooltip, para
+// máquinas em que não é possível abrir o navegador
+func (t *TrayIcon) ShowURL(url string) {
+	select {
+	case t.urlChan <- url:
+	default:
+		// Já há um endereço pendente
+	}
+}
+
 // onReady callback chamado quando o systray está pronto
 func (t *TrayIcon) onReady() {
 	// Define o ícone inicial
@@ -77,21 +94,21 @@ func (t *TrayIcon) onReady() {
 	if len(iconData) > 0 {
 		systray.SetIcon(iconData)
 	}
-	systray.SetTitle("Machine Monitor")
-	systray.SetTooltip("Machine Monitor Agent")
+	systray.SetTitle(t.catalog.T("tray.title"))
+	systray.SetTooltip(t.catalog.T("tray.tooltip"))
 
 	// Cria os itens do menu
-	t.statusItem = systray.AddMenuItem("Status: Iniciando...", "Status atual do agente")
+	t.statusItem = systray.AddMenuItem(t.catalog.T("tray.status.initial"), t.catalog.T("tray.status.tooltip"))
 	t.statusItem.Disable()
 
 	systray.AddSeparator()
 
-	t.showUIItem = systray.AddMenuItem("Abrir Interface", "Abre a interface web do agente")
-	t.restartItem = systray.AddMenuItem("Reiniciar Agente", "Reinicia o agente")
+	t.showUIItem = systray.AddMenuItem(t.catalog.T("tray.open_ui"), t.catalog.T("tray.open_ui.tooltip"))
+	t.restartItem = systray.AddMenuItem(t.catalog.T("tray.restart"), t.catalog.T("tray.restart.tooltip"))
 
 	systray.AddSeparator()
 
-	t.exitItem = systray.AddMenuItem("Sair", "Fecha o agente")
+	t.exitItem = systray.AddMenuItem(t.catalog.T("tray.exit"), t.catalog.T("tray.exit.tooltip"))
 
 	// Inicia o loop de eventos
 	go t.eventLoop()
@@ -108,6 +125,10 @@ func (t *TrayIcon) eventLoop() {
 			t.status = status
 			t.updateStatusDisplay()
 
+		case url := <-t.urlChan:
+			t.url = url
+			systray.SetTooltip(t.tooltip())
+
 		case <-t.showUIItem.ClickedCh:
 			log.Info().Msg("Menu: Abrir Interface clicado")
 			if t.onShowUI != nil {
@@ -136,17 +157,11 @@ func (t *TrayIcon) updateStatusDisplay() {
 		return
 	}
 
-	statusText := fmt.Sprintf("Status: %s", t.getStatusText(t.status.State))
+	statusText := t.catalog.T("tray.status", t.getStatusText(t.status.State))
 	t.statusItem.SetTitle(statusText)
 
 	// Atualiza tooltip com informações detalhadas
-	tooltip := fmt.Sprintf("Machine Monitor Agent\nStatus: %s\nUptime: %s\nComandos: %d\nErros: %d",
-		t.getStatusText(t.status.State),
-		t.formatDuration(t.status.Uptime),
-		t.status.CommandsRun,
-		t.status.Errors,
-	)
-	systray.SetTooltip(tooltip)
+	systray.SetTooltip(t.tooltip())
 
 	// Atualiza ícone baseado no status - com tratamento de erro
 	iconData := t.getStatusIcon(t.status.State)
@@ -155,36 +170,32 @@ func (t *TrayIcon) updateStatusDisplay() {
 	}
 }
 
-// getStatusText retorna texto amigável para o status
-func (t *TrayIcon) getStatusText(state string) string {
-	switch state {
-	case types.StateStarting:
-		return "Iniciando"
-	case types.StateRunning:
-		return "Executando"
-	case types.StateStopping:
-		return "Parando"
-	case types.StateStopped:
-		return "Parado"
-	case types.StateError:
-		return "Erro"
-	default:
-		return "Desconhecido"
+// tooltip monta o texto do tooltip: status detalhado, quando conhecido, e o
+// endereço da interface web, quando não há navegador
+func (t *TrayIcon) tooltip() string {
+	text := t.catalog.T("tray.tooltip")
+	if t.status != nil {
+		text = t.catalog.T("tray.tooltip.details",
+			t.getStatusText(t.status.State),
+			timeutil.FormatDurationHuman(t.status.Uptime.Duration()),
+			t.status.CommandsRun,
+			t.status.Errors,
+		)
 	}
+	if t.url != "" {
+		text += "\n" + t.catalog.T("tray.tooltip.url", t.url)
+	}
+	return text
 }
 
-// formatDuration formata duração de forma amigável
-func (t *TrayIcon) formatDuration(d time.Duration) string {
-	if d < time.Minute {
-		return fmt.Sprintf("%.0fs", d.Seconds())
-	}
-	if d < time.Hour {
-		return fmt.Sprintf("%.0fm", d.Minutes())
-	}
-	if d < 24*time.Hour {
-		return fmt.Sprintf("%.1fh", d.Hours())
+// getStatusText retorna texto amigável para o status, no idioma da UI
+func (t *TrayIcon) getStatusText(state string) string {
+	switch state {
+	case types.StateStarting, types.StateRunning, types.StateStopping, types.StateStopped, types.StateError:
+		return t.catalog.T("state." + state)
+	default:
+		return t.catalog.T("state.unknown")
 	}
-	return fmt.Sprintf("%.1fd", d.Hours()/24)
 }
 
 // getStatusIcon retorna ícone baseado no status
@@ -4,6 +4,7 @@ package ui
 
 import (
 	"context"
+	"machine-monitor-agent/internal/i18n"
 	"machine-monitor-agent/internal/types"
 
 	"github.com/rs/zerolog/log"
@@ -16,7 +17,7 @@ type TrayIcon struct {
 }
 
 // NewTrayIcon cria uma nova instância do ícone na bandeja (versão disabled)
-func NewTrayIcon(onShowUI, onRestart, onExit func()) *TrayIcon {
+func NewTrayIcon(onShowUI, onRestart, onExit func(), catalog *i18n.Catalog) *TrayIcon {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	log.Info().Msg("Tray icon desabilitado para esta plataforma")
@@ -41,3 +42,8 @@ func (t *TrayIcon) Stop() {
 func (t *TrayIcon) UpdateStatus(status *types.AgentStatus) {
 	// Nada a fazer na versão disabled
 }
+
+// ShowURL exibe o endereço da interface web (versão disabled)
+func (t *TrayIcon) ShowURL(url string) {
+	// Nada a fazer na versão disabled; o endereço já foi registrado no log
+}
@@ -3,11 +3,15 @@ package ui
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"machine-monitor-agent/internal/i18n"
 	"machine-monitor-agent/internal/types"
 
 	"github.com/rs/zerolog/log"
@@ -15,11 +19,24 @@ import (
 
 // WebUI representa a interface web
 type WebUI struct {
-	server *http.Server
-	agent  AgentInterface
-	port   int
-	ctx    context.Context
-	cancel context.CancelFunc
+	server  *http.Server
+	agent   AgentInterface
+	port    int
+	catalog *i18n.Catalog
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// Coletas sem cache agrupadas por seção (endpoints /fresh)
+	systemFresh   freshSource
+	hardwareFresh freshSource
+
+	// Atualizações ao vivo pelo /ws
+	hub          *pushHub
+	pushInterval time.Duration
+
+	// Endereço de escuta e autenticação da API, do /ws e da página inicial
+	bindAddress string
+	auth        *webAuth
 }
 
 // AgentInterface interface para acessar dados do agente
@@ -30,18 +47,49 @@ type AgentInterface interface {
 	CollectHardwareInfo(ctx context.Context) (*types.HardwareInfo, error)
 	CollectSystemInfoFresh(ctx context.Context) (*types.SystemInfo, error)
 	CollectHardwareInfoFresh(ctx context.Context) (*types.HardwareInfo, error)
+	// CollectSecurityPosture alimenta o card de segurança (/api/security)
+	CollectSecurityPosture(ctx context.Context) (*types.SecurityPosture, error)
+	// InvalidateCache descarta apenas as seções informadas, preservando o restante do cache
+	InvalidateCache(keys ...string)
+	// GetEvents retorna o histórico recente posterior a since, limitado aos limit mais recentes
+	GetEvents(since time.Time, limit int) []types.Event
+	// GetInventoryHistory e GetInventoryAt consultam o histórico local de
+	// inventários (/api/inventory/history)
+	GetInventoryHistory(limit int) ([]types.InventoryHistoryEntry, error)
+	GetInventoryAt(at time.Time) (*types.InventoryHistoryEntry, error)
+	// SampleUsage lê o uso atual de CPU e memória, sem o restante do hardware
+	SampleUsage(ctx context.Context) (*types.UsageSample, error)
+	// GetExecutionMetrics e GetConnectionMetrics alimentam o /api/metrics
+	GetExecutionMetrics() types.ExecutionMetrics
+	GetConnectionMetrics() types.ConnectionMetrics
 }
 
-// NewWebUI cria uma nova instância da interface web
-func NewWebUI(agent AgentInterface, port int) *WebUI {
+// NewWebUI cria uma nova instância da interface web a partir da configuração
+// da UI (porta, endereço, intervalo do /ws e credenciais)
+func NewWebUI(agent AgentInterface, config types.UIConfig, catalog *i18n.Catalog) (*WebUI, error) {
+	auth, err := newWebAuth(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pushInterval := config.PushInterval.Duration()
+	if pushInterval <= 0 {
+		pushInterval = DefaultPushInterval
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &WebUI{
-		agent:  agent,
-		port:   port,
-		ctx:    ctx,
-		cancel: cancel,
-	}
+		agent:        agent,
+		port:         config.WebUIPort,
+		catalog:      catalog,
+		ctx:          ctx,
+		cancel:       cancel,
+		hub:          newPushHub(),
+		pushInterval: pushInterval,
+		bindAddress:  config.BindAddress,
+		auth:         auth,
+	}, nil
 }
 
 // Start inicia o servidor web
@@ -49,23 +97,35 @@ func (w *WebUI) Start() error {
 	mux := http.NewServeMux()
 
 	// Rotas
-	mux.HandleFunc("/", w.handleHome)
-	mux.HandleFunc("/api/status", w.handleAPIStatus)
-	mux.HandleFunc("/api/system", w.handleAPISystem)
-	mux.HandleFunc("/api/system/fresh", w.handleAPISystemFresh)
-	mux.HandleFunc("/api/hardware", w.handleAPIHardware)
-	mux.HandleFunc("/api/hardware/fresh", w.handleAPIHardwareFresh)
+	mux.HandleFunc("/", w.requireAuth(w.handleHome))
+	mux.HandleFunc("/login", w.handleLogin)
+	mux.HandleFunc("/api/status", w.requireAuth(w.handleAPIStatus))
+	mux.HandleFunc("/api/system", w.requireAuth(w.handleAPISystem))
+	mux.HandleFunc("/api/system/fresh", w.requireAuth(w.handleAPISystemFresh))
+	mux.HandleFunc("/api/hardware", w.requireAuth(w.handleAPIHardware))
+	mux.HandleFunc("/api/hardware/fresh", w.requireAuth(w.handleAPIHardwareFresh))
+	mux.HandleFunc("/api/security", w.requireAuth(w.handleAPISecurity))
+	mux.HandleFunc("/api/events", w.requireAuth(w.handleAPIEvents))
+	mux.HandleFunc("/api/inventory/history", w.requireAuth(w.handleAPIInventoryHistory))
+	mux.HandleFunc("/api/metrics", w.requireAuth(w.handleAPIMetrics))
+	mux.HandleFunc("/ws", w.requireAuth(w.handlePush))
 	mux.HandleFunc("/static/", w.handleStatic)
 
 	// Configura servidor
 	w.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", w.port),
+		Addr:    net.JoinHostPort(w.bindAddress, strconv.Itoa(w.port)),
 		Handler: mux,
 	}
 
+	if !w.auth.enabled() && !isLoopback(w.bindAddress) {
+		log.Warn().Str("bind_address", w.bindAddress).Msg("Interface web exposta na rede sem autenticação; defina ui.auth_token ou ui.basic_auth")
+	}
+
+	go w.runPush()
+
 	// Inicia servidor em goroutine
 	go func() {
-		log.Info().Int("port", w.port).Msg("Iniciando servidor web")
+		log.Info().Str("addr", w.server.Addr).Bool("auth", w.auth.enabled()).Msg("Iniciando servidor web")
 		if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Error().Err(err).Msg("Erro no servidor web")
 		}
@@ -74,9 +134,11 @@ func (w *WebUI) Start() error {
 	return nil
 }
 
-// Stop para o servidor web
+// Stop para o servidor web. As conexões do /ws não são acompanhadas pelo
+// Shutdown do http.Server e são fechadas antes, pelo hub.
 func (w *WebUI) Stop() error {
 	w.cancel()
+	w.hub.close()
 
 	if w.server != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -91,6 +153,15 @@ func (w *WebUI) Stop() error {
 	return nil
 }
 
+// isLoopback indica se o endereço de escuta só aceita conexões locais
+func isLoopback(address string) bool {
+	if address == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.IsLoopback()
+}
+
 // handleHome trata a página inicial
 func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -100,7 +171,7 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
 
 	tmpl := `
 <!DOCTYPE html>
-<html lang="pt-BR">
+<html lang="{{.Lang}}">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -201,50 +272,90 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
             color: #7f8c8d;
             font-style: italic;
         }
+        .live {
+            display: inline-block;
+            margin-left: 10px;
+            font-size: 12px;
+            color: #7f8c8d;
+        }
+        .live.on { color: #27ae60; }
+        .badge {
+            display: inline-block;
+            padding: 2px 10px;
+            border-radius: 10px;
+            font-size: 12px;
+            font-weight: bold;
+            color: white;
+        }
+        .badge.pass { background-color: #27ae60; }
+        .badge.fail { background-color: #e74c3c; }
+        .badge.unknown { background-color: #95a5a6; }
     </style>
 </head>
 <body>
     <div class="container">
         <div class="header">
             <h1>Machine Monitor Agent</h1>
-            <div id="status" class="status">Carregando...</div>
-            <button class="refresh-btn" onclick="refreshData()">Atualizar</button>
+            <div id="status" class="status">{{t "webui.loading"}}</div>
+            <button class="refresh-btn" onclick="refreshData()">{{t "webui.refresh"}}</button>
+            <span id="live" class="live">{{t "webui.polling"}}</span>
         </div>
         
         <div class="grid">
             <div class="card">
-                <h3>Status do Agente</h3>
-                <div id="agent-status" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.agent"}}</h3>
+                <div id="agent-status" class="loading">{{t "webui.loading"}}</div>
+            </div>
+            
+            <div class="card">
+                <h3>{{t "webui.card.system"}}</h3>
+                <div id="system-info" class="loading">{{t "webui.loading"}}</div>
             </div>
             
             <div class="card">
-                <h3>Sistema</h3>
-                <div id="system-info" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.cpu"}}</h3>
+                <div id="cpu-info" class="loading">{{t "webui.loading"}}</div>
             </div>
             
             <div class="card">
-                <h3>CPU</h3>
-                <div id="cpu-info" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.memory"}}</h3>
+                <div id="memory-info" class="loading">{{t "webui.loading"}}</div>
             </div>
             
             <div class="card">
-                <h3>Memória</h3>
-                <div id="memory-info" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.disk"}}</h3>
+                <div id="disk-info" class="loading">{{t "webui.loading"}}</div>
             </div>
             
             <div class="card">
-                <h3>Disco</h3>
-                <div id="disk-info" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.network"}}</h3>
+                <div id="network-info" class="loading">{{t "webui.loading"}}</div>
             </div>
             
             <div class="card">
-                <h3>Rede</h3>
-                <div id="network-info" class="loading">Carregando...</div>
+                <h3>{{t "webui.card.security"}}</h3>
+                <div id="security" class="loading">{{t "webui.loading"}}</div>
+            </div>
+            
+            <div class="card">
+                <h3>{{t "webui.card.diagnostics"}}</h3>
+                <div id="diagnostics" class="loading">{{t "webui.loading"}}</div>
+            </div>
+            
+            <div class="card">
+                <h3>{{t "webui.card.events"}}</h3>
+                <div id="events" class="loading">{{t "webui.loading"}}</div>
             </div>
         </div>
     </div>
 
     <script>
+        const messages = {{.Messages}};
+
+        function t(key) {
+            return messages[key] || key;
+        }
+
         function formatBytes(bytes) {
             if (bytes === 0) return '0 B';
             const k = 1024;
@@ -253,6 +364,20 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
             return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
         }
 
+        function formatLastCommand(command) {
+            if (!command) {
+                return createMetric(t('webui.last_command'), t('webui.never'));
+            }
+
+            let output = formatBytes(command.output_bytes);
+            if (command.output_truncated) {
+                output += ' / ' + formatBytes(command.original_output_bytes) + ' (' + t('webui.output_truncated') + ')';
+            }
+
+            return createMetric(t('webui.last_command'), command.type + ' - ' + t(command.success ? 'webui.success' : 'webui.failed')) +
+                createMetric(t('webui.last_output'), output);
+        }
+
         function formatDuration(seconds) {
             const days = Math.floor(seconds / 86400);
             const hours = Math.floor((seconds % 86400) / 3600);
@@ -263,6 +388,11 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
             return minutes + 'm';
         }
 
+        function formatRate(bytesPerSec) {
+            if (bytesPerSec === undefined || bytesPerSec === null) return t('webui.not_available');
+            return formatBytes(Math.round(bytesPerSec)) + '/s';
+        }
+
         function createMetric(label, value) {
             return '<div class="metric"><span class="metric-label">' + label + '</span><span class="metric-value">' + value + '</span></div>';
         }
@@ -271,85 +401,127 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
             return '<div class="progress-bar"><div class="progress-fill" style="width: ' + percentage + '%"></div></div>';
         }
 
+        // Sem sessão (expirada ou agente reiniciado) volta para o login
+        async function fetchJSON(url) {
+            const response = await fetch(url);
+            if (response.status === 401) {
+                location.href = '/login';
+                throw new Error('unauthorized');
+            }
+            return response.json();
+        }
+
+        function escapeHTML(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML;
+        }
+
+        function renderStatus(data) {
+            const statusEl = document.getElementById('status');
+            statusEl.textContent = t('state.' + data.state);
+            statusEl.className = 'status ' + data.state.toLowerCase();
+            
+            const agentStatusEl = document.getElementById('agent-status');
+            agentStatusEl.innerHTML = 
+                createMetric(t('webui.state'), t('state.' + data.state)) +
+                createMetric(t('webui.uptime'), formatDuration(data.uptime)) +
+                createMetric(t('webui.commands_run'), data.commands_run) +
+                createMetric(t('webui.errors'), data.errors) +
+                createMetric(t('webui.last_heartbeat'), data.last_heartbeat ? new Date(data.last_heartbeat).toLocaleString() : t('webui.never')) +
+                createMetric(t('webui.last_inventory'), data.last_inventory ? new Date(data.last_inventory).toLocaleString() : t('webui.never')) +
+                formatLastCommand(data.last_command);
+        }
+
         async function loadStatus() {
             try {
-                const response = await fetch('/api/status');
-                const data = await response.json();
-                
-                const statusEl = document.getElementById('status');
-                statusEl.textContent = data.state;
-                statusEl.className = 'status ' + data.state.toLowerCase();
-                
-                const agentStatusEl = document.getElementById('agent-status');
-                agentStatusEl.innerHTML = 
-                    createMetric('Estado', data.state) +
-                    createMetric('Uptime', formatDuration(data.uptime / 1000000000)) +
-                    createMetric('Comandos Executados', data.commands_run) +
-                    createMetric('Erros', data.errors) +
-                    createMetric('Último Heartbeat', data.last_heartbeat ? new Date(data.last_heartbeat).toLocaleString() : 'Nunca') +
-                    createMetric('Último Inventário', data.last_inventory ? new Date(data.last_inventory).toLocaleString() : 'Nunca');
+                renderStatus(await fetchJSON('/api/status'));
             } catch (error) {
                 console.error('Erro ao carregar status:', error);
             }
         }
 
-        async function loadSystemInfo() {
+        async function loadSystemInfo(query) {
             try {
-                const response = await fetch('/api/system/fresh');
-                const data = await response.json();
+                const data = await fetchJSON('/api/system/fresh' + query);
                 
                 const systemInfoEl = document.getElementById('system-info');
                 systemInfoEl.innerHTML = 
-                    createMetric('Sistema Operacional', data.os) +
-                    createMetric('Plataforma', data.platform) +
-                    createMetric('Hostname', data.hostname) +
-                    createMetric('Uptime', formatDuration(data.uptime)) +
-                    createMetric('Processos', data.procs) +
-                    createMetric('Usuários Logados', data.users ? data.users.length : 0);
+                    createMetric(t('webui.os'), data.os) +
+                    createMetric(t('webui.platform'), data.platform) +
+                    createMetric(t('webui.hostname'), data.hostname) +
+                    createMetric(t('webui.uptime'), formatDuration(data.uptime)) +
+                    createMetric(t('webui.processes'), data.procs) +
+                    createMetric(t('webui.logged_users'), data.users ? data.users.length : 0);
             } catch (error) {
                 console.error('Erro ao carregar info do sistema:', error);
             }
         }
 
-        async function loadHardwareInfo() {
+        function renderCPU(cpu) {
+            document.getElementById('cpu-info').innerHTML = 
+                createMetric(t('webui.model'), cpu.model_name || t('webui.not_available')) +
+                createMetric(t('webui.cores'), cpu.cores) +
+                createMetric(t('webui.threads'), cpu.threads) +
+                createMetric(t('webui.frequency'), cpu.frequency.toFixed(2) + ' MHz') +
+                createMetric(t('webui.usage'), cpu.usage.toFixed(1) + '%') +
+                createProgressBar(cpu.usage);
+        }
+
+        function renderMemory(memory) {
+            document.getElementById('memory-info').innerHTML = 
+                createMetric(t('webui.total'), formatBytes(memory.total)) +
+                createMetric(t('webui.used'), formatBytes(memory.used)) +
+                createMetric(t('webui.available'), formatBytes(memory.available)) +
+                createMetric(t('webui.usage'), memory.used_percent.toFixed(1) + '%') +
+                createProgressBar(memory.used_percent);
+        }
+
+        // Último hardware completo; as amostras do /ws atualizam só CPU e memória
+        let hardware = null;
+
+        function applyUsage(usage) {
+            if (!hardware) return;
+            hardware.cpu.usage = usage.cpu_percent;
+            hardware.memory.total = usage.memory_total;
+            hardware.memory.used = usage.memory_used;
+            hardware.memory.available = usage.memory_available;
+            hardware.memory.used_percent = usage.memory_percent;
+            renderCPU(hardware.cpu);
+            renderMemory(hardware.memory);
+        }
+
+        async function loadHardwareInfo(query) {
             try {
-                const response = await fetch('/api/hardware/fresh');
-                const data = await response.json();
-                
-                // CPU
-                const cpuInfoEl = document.getElementById('cpu-info');
-                cpuInfoEl.innerHTML = 
-                    createMetric('Modelo', data.cpu.model_name || 'N/A') +
-                    createMetric('Cores', data.cpu.cores) +
-                    createMetric('Threads', data.cpu.threads) +
-                    createMetric('Frequência', data.cpu.frequency.toFixed(2) + ' MHz') +
-                    createMetric('Uso', data.cpu.usage.toFixed(1) + '%') +
-                    createProgressBar(data.cpu.usage);
+                const data = await fetchJSON('/api/hardware/fresh' + query);
+                hardware = data;
                 
-                // Memória
-                const memoryInfoEl = document.getElementById('memory-info');
-                memoryInfoEl.innerHTML = 
-                    createMetric('Total', formatBytes(data.memory.total)) +
-                    createMetric('Usado', formatBytes(data.memory.used)) +
-                    createMetric('Disponível', formatBytes(data.memory.available)) +
-                    createMetric('Uso', data.memory.used_percent.toFixed(1) + '%') +
-                    createProgressBar(data.memory.used_percent);
+                renderCPU(data.cpu);
+                renderMemory(data.memory);
                 
                 // Disco
                 const diskInfoEl = document.getElementById('disk-info');
                 let diskHtml = '';
                 data.disk.forEach(disk => {
                     diskHtml += '<div style="margin-bottom: 15px; padding-bottom: 15px; border-bottom: 1px solid #eee;">';
-                    diskHtml += createMetric('Dispositivo', disk.device);
-                    diskHtml += createMetric('Ponto de Montagem', disk.mountpoint);
-                    diskHtml += createMetric('Tipo', disk.fstype);
-                    diskHtml += createMetric('Total', formatBytes(disk.total));
-                    diskHtml += createMetric('Usado', formatBytes(disk.used));
-                    diskHtml += createMetric('Livre', formatBytes(disk.free));
-                    diskHtml += createMetric('Uso', disk.used_percent.toFixed(1) + '%');
+                    diskHtml += createMetric(t('webui.device'), disk.device);
+                    diskHtml += createMetric(t('webui.mountpoint'), disk.mountpoint);
+                    diskHtml += createMetric(t('webui.fstype'), disk.fstype);
+                    diskHtml += createMetric(t('webui.total'), formatBytes(disk.total));
+                    diskHtml += createMetric(t('webui.used'), formatBytes(disk.used));
+                    diskHtml += createMetric(t('webui.free'), formatBytes(disk.free));
+                    diskHtml += createMetric(t('webui.usage'), disk.used_percent.toFixed(1) + '%');
                     diskHtml += createProgressBar(disk.used_percent);
                     diskHtml += '</div>';
                 });
+                // Taxas de I/O por dispositivo; ausentes na primeira coleta
+                (data.disk_io || []).forEach(io => {
+                    diskHtml += '<div style="margin-bottom: 15px; padding-bottom: 15px; border-bottom: 1px solid #eee;">';
+                    diskHtml += createMetric(t('webui.disk_io'), io.device);
+                    diskHtml += createMetric(t('webui.read_rate'), formatRate(io.read_bytes_per_sec));
+                    diskHtml += createMetric(t('webui.write_rate'), formatRate(io.write_bytes_per_sec));
+                    diskHtml += '</div>';
+                });
                 diskInfoEl.innerHTML = diskHtml;
                 
                 // Rede
@@ -357,11 +529,11 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
                 let networkHtml = '';
                 data.network.forEach(net => {
                     networkHtml += '<div style="margin-bottom: 15px; padding-bottom: 15px; border-bottom: 1px solid #eee;">';
-                    networkHtml += createMetric('Interface', net.name);
-                    networkHtml += createMetric('MAC', net.hardware_addr || 'N/A');
-                    networkHtml += createMetric('Endereços', net.addrs ? net.addrs.join(', ') : 'N/A');
-                    networkHtml += createMetric('Bytes Enviados', formatBytes(net.bytes_sent));
-                    networkHtml += createMetric('Bytes Recebidos', formatBytes(net.bytes_recv));
+                    networkHtml += createMetric(t('webui.interface'), net.name);
+                    networkHtml += createMetric(t('webui.mac'), net.hardware_addr || t('webui.not_available'));
+                    networkHtml += createMetric(t('webui.addresses'), net.addrs ? net.addrs.join(', ') : t('webui.not_available'));
+                    networkHtml += createMetric(t('webui.bytes_sent'), formatBytes(net.bytes_sent));
+                    networkHtml += createMetric(t('webui.bytes_recv'), formatBytes(net.bytes_recv));
                     networkHtml += '</div>';
                 });
                 networkInfoEl.innerHTML = networkHtml;
@@ -371,30 +543,207 @@ func (w *WebUI) handleHome(rw http.ResponseWriter, r *http.Request) {
             }
         }
 
-        function refreshData() {
+        // Eventos exibidos, do mais novo para o mais antigo
+        const maxEvents = 20;
+        let events = [];
+
+        function renderEvents() {
+            const eventsEl = document.getElementById('events');
+            eventsEl.className = events.length ? '' : 'loading';
+            if (!events.length) {
+                eventsEl.textContent = t('webui.no_events');
+                return;
+            }
+            eventsEl.innerHTML = events.map(event =>
+                createMetric(escapeHTML(event.type), escapeHTML(new Date(event.timestamp).toLocaleTimeString() + ' - ' + (messages['event.' + event.type] || event.message)))
+            ).join('');
+        }
+
+        // replace troca a lista (snapshot e polling); senão acrescenta os novos
+        function showEvents(list, replace) {
+            const newest = list.slice().reverse();
+            events = replace ? newest : newest.concat(events);
+            events = events.slice(0, maxEvents);
+            renderEvents();
+        }
+
+        async function loadEvents() {
+            try {
+                showEvents(await fetchJSON('/api/events?limit=' + maxEvents), true);
+            } catch (error) {
+                console.error('Erro ao carregar eventos:', error);
+            }
+        }
+
+        function renderDiagnostics(data) {
+            const exec = data.executor;
+            const http = data.connection.http;
+            const ws = data.connection.websocket;
+
+            let html =
+                createMetric(t('webui.diag.commands'), exec.successful_commands + ' / ' + exec.failed_commands + ' / ' + exec.rejected_commands) +
+                createMetric(t('webui.diag.running'), exec.running_commands + ' / ' + exec.max_concurrency) +
+                createMetric(t('webui.diag.avg_duration'), exec.average_duration + ' ms');
+            Object.keys(exec.by_type).sort().forEach(type => {
+                const stats = exec.by_type[type];
+                html += createMetric('&nbsp;&nbsp;' + escapeHTML(type), stats.count + ' (' + stats.failures + ') - ' + stats.average_duration + ' ms');
+            });
+            html +=
+                createMetric(t('webui.diag.http'), http.requests + ' (' + http.failures + ')') +
+                createMetric(t('webui.diag.http_latency'), http.average_latency + ' ms') +
+                createMetric(t('webui.diag.websocket'), t(ws.connected ? 'webui.diag.connected' : 'webui.diag.disconnected')) +
+                createMetric(t('webui.diag.connections'), ws.connects + ' (' + ws.connect_failures + ')') +
+                createMetric(t('webui.diag.command_queue'), ws.command_queue_depth + ' / ' + ws.command_queue_capacity) +
+                createMetric(t('webui.diag.result_queue'), ws.result_queue_depth + ' / ' + ws.result_queue_capacity) +
+                createMetric(t('webui.diag.dropped'), ws.commands_dropped);
+            const lastError = new Date(ws.last_error_at) > new Date(http.last_error_at) ? ws.last_error : http.last_error;
+            if (lastError) {
+                html += createMetric(t('webui.diag.last_error'), escapeHTML(lastError));
+            }
+
+            const diagnosticsEl = document.getElementById('diagnostics');
+            diagnosticsEl.className = '';
+            diagnosticsEl.innerHTML = html;
+        }
+
+        async function loadDiagnostics() {
+            try {
+                renderDiagnostics(await fetchJSON('/api/metrics'));
+            } catch (error) {
+                console.error('Erro ao carregar diagnóstico:', error);
+            }
+        }
+
+        // Verificações na ordem do card; inverted marca as que passam desligadas
+        const securityChecks = [
+            { key: 'firewall', inverted: false },
+            { key: 'screen_lock', inverted: false },
+            { key: 'sip', inverted: false },
+            { key: 'gatekeeper', inverted: false },
+            { key: 'remote_login', inverted: true },
+        ];
+
+        function securityBadge(check, inverted) {
+            if (check.error) {
+                return '<span class="badge unknown" title="' + escapeHTML(check.error) + '">' + t('webui.security.unknown') + '</span>';
+            }
+            const pass = check.enabled !== inverted;
+            const label = t(check.enabled ? 'webui.security.on' : 'webui.security.off');
+            return '<span class="badge ' + (pass ? 'pass' : 'fail') + '">' + label + '</span>';
+        }
+
+        function renderSecurity(data) {
+            let html = '';
+            securityChecks.forEach(item => {
+                const check = data[item.key];
+                if (check) {
+                    html += createMetric(t('webui.security.' + item.key), securityBadge(check, item.inverted));
+                }
+            });
+
+            const securityEl = document.getElementById('security');
+            securityEl.className = '';
+            securityEl.innerHTML = html;
+        }
+
+        async function loadSecurity() {
+            try {
+                renderSecurity(await fetchJSON('/api/security'));
+            } catch (error) {
+                console.error('Erro ao carregar segurança:', error);
+            }
+        }
+
+        // maxAge (segundos) aceita um resultado recente em vez de coletar de novo;
+        // o botão Atualizar sempre coleta
+        function refreshData(maxAge) {
+            const query = maxAge ? '?max_age=' + maxAge : '';
             loadStatus();
-            loadSystemInfo();
-            loadHardwareInfo();
+            loadSystemInfo(query);
+            loadHardwareInfo(query);
+            loadEvents();
+            // Com maxAge é o polling; o diagnóstico tem o próprio intervalo e a
+            // segurança muda raramente (cache do coletor)
+            if (!maxAge) {
+                loadDiagnostics();
+                loadSecurity();
+            }
+        }
+
+        // Polling a cada 10 segundos enquanto o /ws não estiver conectado
+        let pollTimer = null;
+
+        function setLive(live) {
+            const liveEl = document.getElementById('live');
+            liveEl.textContent = t(live ? 'webui.live' : 'webui.polling');
+            liveEl.className = 'live' + (live ? ' on' : '');
+        }
+
+        function startPolling() {
+            setLive(false);
+            if (!pollTimer) {
+                pollTimer = setInterval(() => refreshData(5), 10000);
+            }
+        }
+
+        function stopPolling() {
+            setLive(true);
+            if (pollTimer) {
+                clearInterval(pollTimer);
+                pollTimer = null;
+            }
+        }
+
+        // Status, CPU, memória e eventos chegam pelo /ws; sistema, disco e
+        // rede ficam com a última coleta até o botão Atualizar. Se a conexão
+        // cair, volta ao polling e tenta de novo em 5 segundos.
+        function connectLive() {
+            if (!window.WebSocket) return;
+
+            const scheme = location.protocol === 'https:' ? 'wss://' : 'ws://';
+            const ws = new WebSocket(scheme + location.host + '/ws');
+
+            ws.onmessage = (message) => {
+                const data = JSON.parse(message.data);
+                stopPolling();
+                renderStatus(data.status);
+                if (data.usage) applyUsage(data.usage);
+                showEvents(data.events, data.type === 'snapshot');
+            };
+            ws.onclose = () => {
+                startPolling();
+                setTimeout(connectLive, 5000);
+            };
         }
 
         // Carrega dados iniciais
         refreshData();
-        
-        // Atualiza automaticamente a cada 10 segundos
-        setInterval(refreshData, 10000);
+        startPolling();
+        connectLive();
+
+        // O diagnóstico não vem pelo /ws; atualiza a cada 10 segundos
+        setInterval(loadDiagnostics, 10000);
     </script>
 </body>
 </html>
 `
 
-	t, err := template.New("home").Parse(tmpl)
+	t, err := template.New("home").Funcs(template.FuncMap{"t": w.catalog.T}).Parse(tmpl)
 	if err != nil {
-		http.Error(rw, "Erro no template", http.StatusInternalServerError)
+		http.Error(rw, w.catalog.T("webui.error.template"), http.StatusInternalServerError)
 		return
 	}
 
+	data := struct {
+		Lang     string
+		Messages map[string]string
+	}{
+		Lang:     w.catalog.Lang(),
+		Messages: w.catalog.Messages(),
+	}
+
 	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := t.Execute(rw, nil); err != nil {
+	if err := t.Execute(rw, data); err != nil {
 		log.Error().Err(err).Msg("Erro ao executar template")
 	}
 }
@@ -414,27 +763,37 @@ func (w *WebUI) handleAPISystem(rw http.ResponseWriter, r *http.Request) {
 
 	info, err := w.agent.CollectSystemInfo(ctx)
 	if err != nil {
-		http.Error(rw, "Erro ao coletar informações do sistema", http.StatusInternalServerError)
+		http.Error(rw, w.catalog.T("webui.error.system_info"), http.StatusInternalServerError)
 		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(info)
+	writeCollectedJSON(rw, r, info, info.Timestamp)
 }
 
-// handleAPISystemFresh trata a API de informações do sistema sem cache
+// handleAPISystemFresh trata a API de informações do sistema sem cache.
+// Requisições simultâneas compartilham uma coleta; ?max_age=N aceita o
+// último resultado se tiver no máximo N segundos.
 func (w *WebUI) handleAPISystemFresh(rw http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	maxAge, err := parseMaxAge(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	info, err := w.agent.CollectSystemInfoFresh(ctx)
+	info, collectedAt, err := w.systemFresh.get(r.Context(), w.ctx, maxAge,
+		func(ctx context.Context) (interface{}, time.Time, error) {
+			info, err := w.agent.CollectSystemInfoFresh(ctx)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			return info, info.Timestamp, nil
+		})
 	if err != nil {
-		http.Error(rw, "Erro ao coletar informações do sistema", http.StatusInternalServerError)
+		http.Error(rw, w.catalog.T("webui.error.system_info"), http.StatusInternalServerError)
 		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(info)
+	writeCollectedJSON(rw, r, info, collectedAt)
 }
 
 // handleAPIHardware trata a API de informações de hardware
@@ -444,27 +803,126 @@ func (w *WebUI) handleAPIHardware(rw http.ResponseWriter, r *http.Request) {
 
 	info, err := w.agent.CollectHardwareInfo(ctx)
 	if err != nil {
-		http.Error(rw, "Erro ao coletar informações de hardware", http.StatusInternalServerError)
+		http.Error(rw, w.catalog.T("webui.error.hardware_info"), http.StatusInternalServerError)
 		return
 	}
 
-	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(info)
+	writeCollectedJSON(rw, r, info, info.Timestamp)
 }
 
-// handleAPIHardwareFresh trata a API de informações de hardware sem cache
+// handleAPIHardwareFresh trata a API de informações de hardware sem cache,
+// com o mesmo agrupamento e max_age de handleAPISystemFresh
 func (w *WebUI) handleAPIHardwareFresh(rw http.ResponseWriter, r *http.Request) {
+	maxAge, err := parseMaxAge(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, collectedAt, err := w.hardwareFresh.get(r.Context(), w.ctx, maxAge,
+		func(ctx context.Context) (interface{}, time.Time, error) {
+			info, err := w.agent.CollectHardwareInfoFresh(ctx)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			return info, info.Timestamp, nil
+		})
+	if err != nil {
+		http.Error(rw, w.catalog.T("webui.error.hardware_info"), http.StatusInternalServerError)
+		return
+	}
+
+	writeCollectedJSON(rw, r, info, collectedAt)
+}
+
+// handleAPISecurity trata a API da postura de segurança (firewall, bloqueio
+// de tela, SIP/Gatekeeper e acesso remoto), em cache pelo data_cache_ttl
+func (w *WebUI) handleAPISecurity(rw http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	info, err := w.agent.CollectHardwareInfoFresh(ctx)
+	posture, err := w.agent.CollectSecurityPosture(ctx)
 	if err != nil {
-		http.Error(rw, "Erro ao coletar informações de hardware", http.StatusInternalServerError)
+		http.Error(rw, w.catalog.T("webui.error.security"), http.StatusInternalServerError)
+		return
+	}
+
+	writeCollectedJSON(rw, r, posture, posture.Timestamp)
+}
+
+// handleAPIEvents trata a API do histórico recente do agente.
+// ?since= (RFC 3339) retorna só os posteriores; ?limit=N os N mais recentes.
+func (w *WebUI) handleAPIEvents(rw http.ResponseWriter, r *http.Request) {
+	since, limit, err := parseEventsQuery(r)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.agent.GetEvents(since, limit))
+}
+
+// parseEventsQuery lê ?since= e ?limit=; ausentes retornam todo o histórico
+func parseEventsQuery(r *http.Request) (time.Time, int, error) {
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("since inválido: %q", raw)
+		}
+		since = parsed
+	}
+
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			return time.Time{}, 0, fmt.Errorf("limit inválido: %q", raw)
+		}
+		limit = parsed
+	}
+	return since, limit, nil
+}
+
+// handleAPIInventoryHistory trata a API do histórico local de inventários.
+// ?limit=N lista os N mais recentes, sem o conteúdo; ?at= (RFC 3339)
+// retorna o inventário mais recente coletado até esse horário.
+func (w *WebUI) handleAPIInventoryHistory(rw http.ResponseWriter, r *http.Request) {
+	var response interface{}
+	var err error
+	if raw := r.URL.Query().Get("at"); raw != "" {
+		at, parseErr := time.Parse(time.RFC3339, raw)
+		if parseErr != nil {
+			http.Error(rw, fmt.Sprintf("at inválido: %q", raw), http.StatusBadRequest)
+			return
+		}
+		response, err = w.agent.GetInventoryAt(at)
+	} else {
+		limit := 0
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, convErr := strconv.Atoi(raw)
+			if convErr != nil || parsed <= 0 {
+				http.Error(rw, fmt.Sprintf("limit inválido: %q", raw), http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+		response, err = w.agent.GetInventoryHistory(limit)
+	}
+
+	if err != nil {
+		var coded *types.CodedError
+		if errors.As(err, &coded) && (coded.Code == types.ErrCodeHistoryDisabled || coded.Code == types.ErrCodeInventoryNotFound) {
+			http.Error(rw, w.catalog.T("error."+string(coded.Code)), http.StatusNotFound)
+			return
+		}
+		http.Error(rw, w.catalog.T("webui.error.history"), http.StatusInternalServerError)
 		return
 	}
 
 	rw.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(rw).Encode(info)
+	json.NewEncoder(rw).Encode(response)
 }
 
 // handleStatic trata arquivos estáticos
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"machine-monitor-agent/internal/types"
+)
+
+func TestAPIEvents(t *testing.T) {
+	agent := newFakeAgent()
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	for i, code := range []types.EventCode{types.EventConnected, types.EventInventorySent, types.EventDisconnected} {
+		agent.events = append(agent.events, types.Event{Timestamp: start.Add(time.Duration(i) * time.Minute), Type: string(code)})
+	}
+	w := newTestWebUI(t, agent, types.UIConfig{})
+
+	tests := []struct {
+		query string
+		want  []types.EventCode
+	}{
+		{"", []types.EventCode{types.EventConnected, types.EventInventorySent, types.EventDisconnected}},
+		{"?since=" + start.Format(time.RFC3339), []types.EventCode{types.EventInventorySent, types.EventDisconnected}},
+		{"?limit=1", []types.EventCode{types.EventDisconnected}},
+		{"?since=" + start.Format(time.RFC3339) + "&limit=5", []types.EventCode{types.EventInventorySent, types.EventDisconnected}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		w.handleAPIEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events"+tt.query, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%q: status %d, content type %q", tt.query, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		var events []types.Event
+		if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(events) != len(tt.want) {
+			t.Fatalf("%q: %d events, want %d", tt.query, len(events), len(tt.want))
+		}
+		for i, code := range tt.want {
+			if events[i].Type != string(code) {
+				t.Errorf("%q: event %d = %q, want %q", tt.query, i, events[i].Type, code)
+			}
+		}
+	}
+}
+
+func TestAPIEventsInvalidQuery(t *testing.T) {
+	w := newTestWebUI(t, newFakeAgent(), types.UIConfig{})
+	for _, query := range []string{"?since=yesterday", "?limit=0", "?limit=-1", "?limit=ten"} {
+		rec := httptest.NewRecorder()
+		w.handleAPIEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"agente-poc/internal/audit"
+)
+
+// Códigos de saída do subcomando audit
+const (
+	auditOK     = 0
+	auditBroken = 1
+	auditUsage  = 2
+)
+
+// runAudit trata os subcomandos de audit; por enquanto só verify, que
+// confere a cadeia de hashes do log de auditoria dos comandos. Sai com
+// código 1 se alguma entrada não conferir.
+func runAudit(configPath string, args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "uso: audit verify [--dir DIR] [--json]")
+		return auditUsage
+	}
+
+	flags := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Diretório do log de auditoria (padrão: data_dir/audit da configuração)")
+	jsonOutput := flags.Bool("json", false, "Imprime o resultado em JSON")
+	if err := flags.Parse(args[1:]); err != nil {
+		return auditUsage
+	}
+	if *dir == "" {
+		*dir = localConfig(configPath).AuditLogDir()
+	}
+
+	result, err := audit.Verify(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao verificar o log de auditoria: %v\n", err)
+		return auditBroken
+	}
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Printf("%s\n", data)
+	} else if result.OK {
+		fmt.Printf("Log de auditoria íntegro: %d entradas em %d arquivo(s)\n", result.Entries, result.Files)
+		if result.Anchor != nil {
+			fmt.Printf("Conferido a partir do âncora: seq %d, %s (%s)\n", result.Anchor.Seq, result.Anchor.Hash, result.Anchor.File)
+		}
+		if result.Head != nil {
+			fmt.Printf("Head: seq %d, %s (%s)\n", result.Head.Seq, result.Head.Hash, result.Head.File)
+		}
+	} else {
+		fmt.Printf("Cadeia quebrada em %s, linha %d (seq %d): %s\n", result.File, result.Line, result.Seq, result.Reason)
+		fmt.Printf("%d entradas conferidas antes da falha\n", result.Entries)
+	}
+
+	if !result.OK {
+		return auditBroken
+	}
+	return auditOK
+}
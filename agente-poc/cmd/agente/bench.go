@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"agente-poc/internal/agent"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/logging"
+)
+
+// benchResult é o resultado de uma codificação no bench-compression
+type benchResult struct {
+	Encoding   string
+	Size       int
+	EncodeTime time.Duration // média das iterações
+}
+
+// runBenchCompression coleta o inventário atual e o codifica com cada
+// codificação suportada, imprimindo tamanho, razão e tempo de cada uma
+func runBenchCompression(config *agent.Config, logger logging.Logger, args []string) int {
+	flags := flag.NewFlagSet("bench-compression", flag.ContinueOnError)
+	iterations := flags.Int("iterations", 5, "Codificações por codificação (o tempo é a média)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *iterations <= 0 {
+		*iterations = 1
+	}
+
+	systemCollector := collector.New(config.CollectionInterval, logger)
+	inventory, err := systemCollector.CollectInventory()
+	systemCollector.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao coletar inventário: %v\n", err)
+		return 1
+	}
+	payload, err := json.Marshal(inventory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao serializar inventário: %v\n", err)
+		return 1
+	}
+
+	var results []benchResult
+	for _, encoding := range comms.SupportedEncodings {
+		var encoded []byte
+		var total time.Duration
+		for i := 0; i < *iterations; i++ {
+			start := time.Now()
+			encoded, err = comms.EncodeBody(encoding, payload)
+			total += time.Since(start)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro ao codificar com %s: %v\n", encoding, err)
+				return 1
+			}
+		}
+		results = append(results, benchResult{
+			Encoding:   encoding,
+			Size:       len(encoded),
+			EncodeTime: total / time.Duration(*iterations),
+		})
+	}
+
+	renderBenchCompression(os.Stdout, len(payload), results)
+	return 0
+}
+
+// renderBenchCompression imprime a tabela comparativa
+func renderBenchCompression(w io.Writer, rawSize int, results []benchResult) {
+	fmt.Fprintf(w, "Inventário: %d bytes\n\n", rawSize)
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(table, "Encoding\tBytes\tRatio\tSaved\tEncode time\t\n")
+	for _, result := range results {
+		ratio := 1.0
+		if result.Size > 0 {
+			ratio = float64(rawSize) / float64(result.Size)
+		}
+		saved := 0.0
+		if rawSize > 0 {
+			saved = 100 * (1 - float64(result.Size)/float64(rawSize))
+		}
+		fmt.Fprintf(table, "%s\t%d\t%.2fx\t%.1f%%\t%s\t\n",
+			result.Encoding, result.Size, ratio, saved, result.EncodeTime.Round(time.Microsecond))
+	}
+	table.Flush()
+}
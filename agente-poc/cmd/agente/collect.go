@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"agente-poc/internal/agent"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/logging"
+)
+
+// Códigos de saída do subcomando collect
+const (
+	collectOK     = 0
+	collectFailed = 1
+	collectUsage  = 2
+)
+
+// runCollect coleta um inventário uma única vez, sem backend nem serviço
+// instalado, e grava o JSON em stdout ou no arquivo de --output. As opções
+// do collector vêm do arquivo de configuração quando ele existe; um arquivo
+// ausente ou inválido não impede a coleta. Sai com código 1 se alguma seção
+// crítica falhar ou o tempo esgotar.
+func runCollect(configPath string, logger logging.Logger, args []string) int {
+	flags := flag.NewFlagSet("collect", flag.ContinueOnError)
+	output := flags.String("output", "", "Arquivo de saída (vazio ou - = stdout)")
+	pretty := flags.Bool("pretty", false, "JSON indentado")
+	sections := flags.String("sections", "", "Seções coletadas, separadas por vírgula (system e hardware sempre entram; vazio = todas)")
+	timeout := flags.Duration("timeout", 2*time.Minute, "Tempo máximo da coleta")
+	if err := flags.Parse(args); err != nil {
+		return collectUsage
+	}
+
+	disabled, err := collectDisabledSections(*sections)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return collectUsage
+	}
+
+	config := localConfig(configPath)
+	systemCollector := collector.New(config.CollectionInterval, logger)
+	defer systemCollector.Close()
+	if err := config.ConfigureCollector(systemCollector); err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao configurar o collector: %v\n", err)
+		return collectFailed
+	}
+	if len(disabled) > 0 {
+		settings := systemCollector.Settings()
+		settings.DisabledSections = append(settings.DisabledSections, disabled...)
+		if _, _, err := systemCollector.ApplySettings(settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Erro ao configurar o collector: %v\n", err)
+			return collectFailed
+		}
+	}
+
+	inventory, err := collectWithTimeout(systemCollector, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao coletar inventário: %v\n", err)
+		return collectFailed
+	}
+
+	var data []byte
+	if *pretty {
+		data, err = json.MarshalIndent(inventory, "", "  ")
+	} else {
+		data, err = json.Marshal(inventory)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao serializar inventário: %v\n", err)
+		return collectFailed
+	}
+	data = append(data, '\n')
+
+	if *output == "" || *output == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*output, data, 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao gravar inventário: %v\n", err)
+		return collectFailed
+	}
+	return collectOK
+}
+
+// localConfig carrega o arquivo de configuração, se houver; sem ele, ou
+// com um arquivo inválido (ex.: sem token), vale a configuração padrão. Usado
+// pelos subcomandos que rodam sem o agente (collect e exec).
+func localConfig(configPath string) *agent.Config {
+	if _, err := os.Stat(configPath); err == nil {
+		config, err := agent.LoadConfig(configPath)
+		if err == nil {
+			return config
+		}
+		fmt.Fprintf(os.Stderr, "aviso: ignorando %s: %v\n", configPath, err)
+	}
+
+	config := &agent.Config{}
+	config.ApplyDefaults()
+	return config
+}
+
+// collectDisabledSections converte a lista de --sections nas seções a
+// desligar; vazio coleta todas
+func collectDisabledSections(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, section := range strings.Split(list, ",") {
+		section = strings.TrimSpace(section)
+		if section == "" {
+			continue
+		}
+		if err := collector.ValidateSections(map[string]bool{section: true}); err != nil {
+			return nil, fmt.Errorf("--sections: %w", err)
+		}
+		wanted[section] = true
+	}
+
+	var disabled []string
+	for _, section := range collector.DisableableSections() {
+		if !wanted[section] {
+			disabled = append(disabled, section)
+		}
+	}
+	return disabled, nil
+}
+
+// collectWithTimeout roda CollectInventory e, passado timeout, encerra o
+// collector, o que cancela a coleta em andamento
+func collectWithTimeout(systemCollector *collector.SystemCollector, timeout time.Duration) (*collector.InventoryData, error) {
+	type result struct {
+		inventory *collector.InventoryData
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		inventory, err := systemCollector.CollectInventory()
+		done <- result{inventory, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.inventory, r.err
+	case <-time.After(timeout):
+		systemCollector.Close()
+		return nil, fmt.Errorf("timeout after %s", timeout)
+	}
+}
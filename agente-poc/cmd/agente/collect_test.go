@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/logging"
+)
+
+func newCollectTestLogger(t *testing.T) logging.Logger {
+	t.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+// readCollectOutput lê o arquivo gravado pelo collect como InventoryData
+func readCollectOutput(t *testing.T, path string) (*collector.InventoryData, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inventory collector.InventoryData
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		t.Fatalf("output is not an inventory: %v", err)
+	}
+	return &inventory, data
+}
+
+func TestRunCollectSections(t *testing.T) {
+	logger := newCollectTestLogger(t)
+	dir := t.TempDir()
+	missingConfig := filepath.Join(dir, "missing.json")
+
+	// Só software além de system e hardware, sem arquivo de configuração
+	output := filepath.Join(dir, "inventory.json")
+	if code := runCollect(missingConfig, logger, []string{"--output", output, "--sections", "software", "--timeout", "1m"}); code != collectOK {
+		t.Fatalf("collect --sections software: exit %d", code)
+	}
+	inventory, data := readCollectOutput(t, output)
+	if inventory.System.Hostname == "" || inventory.Hardware.CPU.Threads == 0 || inventory.Hardware.Memory.Total == 0 {
+		t.Fatalf("system and hardware missing: %+v %+v", inventory.System, inventory.Hardware)
+	}
+	if inventory.Software.Skipped {
+		t.Fatal("software skipped although requested")
+	}
+	if !inventory.Network.Skipped {
+		t.Fatal("network collected although not requested")
+	}
+	if bytes.Contains(data, []byte("\n  ")) {
+		t.Fatal("output indented without --pretty")
+	}
+
+	// --pretty indenta; com config.json, o arquivo vale para as opções
+	pretty := filepath.Join(dir, "pretty.json")
+	if code := runCollect(writeConfig(t, ""), logger, []string{"--output", pretty, "--pretty", "--sections", "network"}); code != collectOK {
+		t.Fatalf("collect --pretty: exit %d", code)
+	}
+	inventory, data = readCollectOutput(t, pretty)
+	if !bytes.Contains(data, []byte("\n  \"system\"")) {
+		t.Fatal("--pretty output not indented")
+	}
+	if !inventory.Software.Skipped || inventory.Network.Skipped {
+		t.Fatalf("software skipped = %v, network skipped = %v", inventory.Software.Skipped, inventory.Network.Skipped)
+	}
+
+	// Seção desconhecida é erro de uso e não grava nada
+	bad := filepath.Join(dir, "bad.json")
+	if code := runCollect(missingConfig, logger, []string{"--output", bad, "--sections", "software,drivers"}); code != collectUsage {
+		t.Fatalf("unknown section: exit %d", code)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Fatalf("output written on a usage error: %v", err)
+	}
+}
+
+func TestCollectDisabledSections(t *testing.T) {
+	all := collector.DisableableSections()
+
+	tests := []struct {
+		list     string
+		disabled []string
+	}{
+		{list: "", disabled: nil},
+		{list: "system,hardware", disabled: all},
+		{list: " software , network,", disabled: []string{"accounts", "certificates", "custom", "group_policies", "security_posture", "virtualization"}},
+		{list: "hardware,software,network,group_policies,accounts,certificates,security_posture,virtualization,custom", disabled: nil},
+	}
+	for _, tt := range tests {
+		disabled, err := collectDisabledSections(tt.list)
+		if err != nil {
+			t.Fatalf("collectDisabledSections(%q): %v", tt.list, err)
+		}
+		if !reflect.DeepEqual(disabled, tt.disabled) {
+			t.Errorf("collectDisabledSections(%q) = %v, want %v", tt.list, disabled, tt.disabled)
+		}
+	}
+
+	if _, err := collectDisabledSections("software,printers"); err == nil {
+		t.Fatal("unknown section accepted")
+	}
+}
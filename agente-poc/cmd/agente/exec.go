@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/executor"
+	"agente-poc/internal/logging"
+)
+
+// Códigos de saída do subcomando exec
+const (
+	execOK       = 0
+	execRejected = 1
+	execUsage    = 2
+)
+
+// execOutput é o documento de --json: a validação e, quando o comando
+// rodou, o resultado
+type execOutput struct {
+	Validation *executor.ValidationReport `json:"validation"`
+	Result     *executor.ExecutionResult  `json:"result,omitempty"`
+}
+
+// runExec monta um comando shell local e o passa pelo executor real, com a
+// whitelist vigente (a embutida mais as atualizações do backend guardadas no
+// data_dir), a sanitização e os diretórios da configuração, para depurar
+// recusas sem ler os logs da máquina. Com --explain só valida.
+// Sai com código 1 se o comando for recusado ou falhar.
+func runExec(configPath string, logger logging.Logger, args []string) int {
+	flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+	explain := flags.Bool("explain", false, "Mostra o spec e cada etapa da validação, sem executar")
+	jsonOutput := flags.Bool("json", false, "Imprime a validação e o resultado em JSON")
+	timeout := flags.Int("timeout", 0, "Timeout do comando em segundos (0 = o do spec ou command_timeout)")
+	cwd := flags.String("cwd", "", "Diretório de trabalho (options.cwd)")
+	if err := flags.Parse(args); err != nil {
+		return execUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "uso: exec [--explain] [--json] [--timeout N] [--cwd DIR] -- COMANDO [ARGS...]")
+		return execUsage
+	}
+
+	config := localConfig(configPath)
+	execConfig := config.ExecutorConfig(logger)
+	// O log de auditoria é do agente em execução: um segundo processo
+	// gravando no mesmo diretório bifurcaria a cadeia de hashes
+	execConfig.AuditLogDir = ""
+	exec, err := executor.New(execConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao iniciar o executor: %v\n", err)
+		return execRejected
+	}
+
+	command := &comms.Command{
+		ID:        fmt.Sprintf("local-%d", time.Now().UnixNano()),
+		Type:      "shell",
+		Command:   flags.Arg(0),
+		Args:      flags.Args()[1:],
+		Timeout:   *timeout,
+		Timestamp: time.Now(),
+	}
+	if *cwd != "" {
+		command.Options = map[string]interface{}{"cwd": *cwd}
+	}
+
+	report, err := exec.Validate(command)
+	if err != nil || *explain {
+		printExec(os.Stdout, *jsonOutput, &execOutput{Validation: report})
+		if err != nil {
+			return execRejected
+		}
+		return execOK
+	}
+
+	commandResult, err := exec.Execute(context.Background(), command)
+	if commandResult == nil {
+		fmt.Fprintf(os.Stderr, "Erro ao executar comando: %v\n", err)
+		return execRejected
+	}
+	result := &executor.ExecutionResult{
+		Success:       commandResult.Status == comms.StatusSuccess,
+		Output:        commandResult.Output,
+		Error:         commandResult.Error,
+		ExitCode:      commandResult.ExitCode,
+		ExecutionTime: time.Duration(commandResult.ExecutionTime) * time.Millisecond,
+		CommandSpec:   *report.Spec,
+		Sanitized:     report.Sanitized,
+		WorkingDir:    commandResult.WorkingDir,
+		Env:           commandResult.Env,
+	}
+	printExec(os.Stdout, *jsonOutput, &execOutput{Validation: report, Result: result})
+	if !result.Success {
+		return execRejected
+	}
+	return execOK
+}
+
+// printExec imprime a validação (e o resultado, se houver) em JSON ou texto
+func printExec(w io.Writer, asJSON bool, out *execOutput) {
+	if asJSON {
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Fprintf(w, "%s\n", data)
+		return
+	}
+
+	report := out.Validation
+	fmt.Fprintf(w, "Comando:    %s\n", strings.Join(append([]string{report.Command}, report.Args...), " "))
+	if report.Spec != nil {
+		fmt.Fprintf(w, "Spec:       %s (%s)\n", report.Spec.Name, report.Spec.Description)
+		if report.Spec.MaxArgs > 0 {
+			fmt.Fprintf(w, "            max_args %d\n", report.Spec.MaxArgs)
+		}
+		if len(report.Spec.AllowedArgs) > 0 {
+			fmt.Fprintf(w, "            allowed_args %s\n", strings.Join(report.Spec.AllowedArgs, " "))
+		}
+		if len(report.Spec.ForbiddenArgs) > 0 {
+			fmt.Fprintf(w, "            forbidden_args %s\n", strings.Join(report.Spec.ForbiddenArgs, " "))
+		}
+	} else {
+		fmt.Fprintf(w, "Spec:       nenhum\n")
+	}
+
+	fmt.Fprintf(w, "Validação:\n")
+	for _, step := range report.Steps {
+		mark := "ok"
+		if !step.Passed {
+			mark = "FALHOU"
+		}
+		fmt.Fprintf(w, "  %-6s %-15s %s\n", mark, step.Name, step.Detail)
+	}
+	if !report.Allowed {
+		fmt.Fprintf(w, "Rejeitado:  %s (%s)\n", report.Reason, report.ErrorCode)
+		return
+	}
+	if report.Sanitized {
+		fmt.Fprintf(w, "Sanitizado: sim, executa %s\n", strings.Join(report.SanitizedArgs, " "))
+	} else {
+		fmt.Fprintf(w, "Sanitizado: não\n")
+	}
+	fmt.Fprintf(w, "Timeout:    %s\n", report.Timeout)
+
+	result := out.Result
+	if result == nil {
+		return
+	}
+	fmt.Fprintf(w, "Sucesso:    %t (exit %d, %s)\n", result.Success, result.ExitCode, result.ExecutionTime)
+	if result.Error != "" {
+		fmt.Fprintf(w, "Erro:       %s\n", result.Error)
+	}
+	if result.Output != "" {
+		fmt.Fprintf(w, "Saída:\n%s", result.Output)
+		if !strings.HasSuffix(result.Output, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+}
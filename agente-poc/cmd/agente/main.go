@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -11,22 +13,25 @@ import (
 	"time"
 
 	"agente-poc/internal/agent"
+	"agente-poc/internal/comms"
 	"agente-poc/internal/logging"
+	"agente-poc/internal/version"
 )
 
-// Versão do agente
-const (
-	Version = "1.0.0"
-	AppName = "agente-poc"
-)
+// AppName é o nome do agente; a versão vem de version.Version (ldflags)
+const AppName = "agente-poc"
 
 // Flags de linha de comando
 var (
-	configFile = flag.String("config", "configs/config.json", "Caminho para o arquivo de configuração")
-	logLevel   = flag.String("log-level", "", "Nível de log (debug, info, warning, error)")
-	verbose    = flag.Bool("verbose", false, "Modo verboso (equivalente a -log-level=debug)")
-	version    = flag.Bool("version", false, "Mostrar versão e sair")
-	help       = flag.Bool("help", false, "Mostrar ajuda e sair")
+	configFile  = flag.String("config", "configs/config.json", "Caminho para o arquivo de configuração")
+	logLevel    = flag.String("log-level", "", "Nível de log (debug, info, warning, error)")
+	verbose     = flag.Bool("verbose", false, "Modo verboso (equivalente a -log-level=debug)")
+	showVersion = flag.Bool("version", false, "Mostrar versão e sair")
+	help        = flag.Bool("help", false, "Mostrar ajuda e sair")
+
+	validateConfig     = flag.Bool("validate-config", false, "Validar o arquivo de configuração e sair")
+	printDefaultConfig = flag.String("print-default-config", "", "Imprimir uma configuração de exemplo no formato (json, yaml ou toml) e sair")
+	enrollmentKey      = flag.String("enrollment-key", "", "Chave de enrollment do primeiro início (troca pelo token da máquina)")
 )
 
 func main() {
@@ -42,8 +47,8 @@ func main() {
 	flag.Parse()
 
 	// Mostrar versão
-	if *version {
-		fmt.Printf("%s versão %s\n", AppName, Version)
+	if *showVersion {
+		fmt.Printf("%s versão %s\n", AppName, version.Version)
 		os.Exit(0)
 	}
 
@@ -53,8 +58,25 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Imprimir configuração de exemplo
+	if *printDefaultConfig != "" {
+		sample, err := agent.SampleConfig(*printDefaultConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(sample)
+		os.Exit(0)
+	}
+
+	// Subcomandos escrevem o resultado em stdout, então os logs vão para stderr
+	logConfig := logging.DefaultConfig()
+	if flag.NArg() > 0 {
+		logConfig.Output = "stderr"
+	}
+
 	// Configurar logging inicial
-	initialLogger, err := logging.NewLogger(nil)
+	initialLogger, err := logging.NewLogger(logConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Erro ao criar logger inicial: %v\n", err)
 		os.Exit(1)
@@ -69,7 +91,7 @@ func main() {
 
 	// Log inicial
 	initialLogger.Info("Iniciando agente...")
-	initialLogger.WithField("version", Version).Info("Versão do agente")
+	initialLogger.WithField("version", version.Version).Info("Versão do agente")
 
 	// Determinar caminho do arquivo de configuração
 	configPath := *configFile
@@ -83,6 +105,32 @@ func main() {
 		configPath = filepath.Join(filepath.Dir(exePath), configPath)
 	}
 
+	// A flag vale como AGENTE_ENROLLMENT_KEY, lida por LoadConfig antes da
+	// validação (que exige token ou chave de enrollment)
+	if *enrollmentKey != "" {
+		os.Setenv(agent.EnrollmentKeyEnv, *enrollmentKey)
+	}
+
+	// Só validar a configuração, sem iniciar o agente
+	if *validateConfig {
+		os.Exit(runValidateConfig(configPath))
+	}
+
+	// collect não exige configuração nem backend
+	if flag.Arg(0) == "collect" {
+		os.Exit(runCollect(configPath, initialLogger, flag.Args()[1:]))
+	}
+
+	// exec testa a whitelist localmente, também sem exigir configuração
+	if flag.Arg(0) == "exec" {
+		os.Exit(runExec(configPath, initialLogger, flag.Args()[1:]))
+	}
+
+	// audit verify lê só o log de auditoria no data_dir
+	if flag.Arg(0) == "audit" {
+		os.Exit(runAudit(configPath, flag.Args()[1:]))
+	}
+
 	// Carregar configuração
 	initialLogger.WithField("config_path", configPath).Info("Carregando configuração")
 	config, err := agent.LoadConfig(configPath)
@@ -92,16 +140,10 @@ func main() {
 	}
 
 	// Override de configuração com flags
-	if *logLevel != "" {
-		config.LogLevel = *logLevel
-	}
-	if *verbose {
-		config.Debug = true
-		config.LogLevel = "debug"
-	}
+	applyFlagOverrides(config)
 
 	// Configurar logger final
-	logger, err := logging.NewLogger(nil)
+	logger, err := logging.NewLogger(logConfig)
 	if err != nil {
 		initialLogger.WithField("error", err).Error("Erro ao criar logger final")
 		os.Exit(1)
@@ -114,6 +156,20 @@ func main() {
 		logger.WithField("config", config.String()).Debug("Configuração carregada")
 	}
 
+	// Subcomandos que não iniciam o agente
+	switch flag.Arg(0) {
+	case "":
+	case "diagnose":
+		os.Exit(runDiagnose(config))
+	case "status":
+		os.Exit(runStatus(config, flag.Args()[1:]))
+	case "bench-compression":
+		os.Exit(runBenchCompression(config, logger, flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando desconhecido: %s\n", flag.Arg(0))
+		os.Exit(2)
+	}
+
 	// Criar contexto com cancelamento
 	_, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -129,29 +185,54 @@ func main() {
 	// Canal para controlar o shutdown
 	shutdownChan := make(chan struct{})
 
-	// Goroutine para tratamento de sinais
+	// Goroutine para tratamento de sinais: SIGHUP recarrega a configuração,
+	// os demais encerram o agente
 	go func() {
-		sig := <-signalChan
-		logger.WithField("signal", sig.String()).Info("Sinal recebido, iniciando shutdown...")
-
-		// Cancelar contexto (usado no agente)
-		cancel()
-
-		// Sinalizar shutdown
-		close(shutdownChan)
+		for sig := range signalChan {
+			if sig == syscall.SIGHUP {
+				reloadConfig(agentInstance, configPath, logger)
+				continue
+			}
+			logger.WithField("signal", sig.String()).Info("Sinal recebido, iniciando shutdown...")
+
+			// Cancelar contexto (usado no agente)
+			cancel()
+
+			// Sinalizar shutdown
+			close(shutdownChan)
+			return
+		}
 	}()
 
 	// Iniciar agente
 	logger.Info("Iniciando agente...")
 	if err := agentInstance.Start(); err != nil {
+		// instance_lock_policy=exit: outra instância já atende esta máquina
+		if errors.Is(err, agent.ErrInstanceLocked) {
+			logger.WithField("error", err).Info("Outra instância do agente está ativa, encerrando")
+			os.Exit(0)
+		}
 		logger.WithField("error", err).Error("Erro ao iniciar agente")
+		// Binário recém-atualizado que não inicia: voltar ao anterior
+		if rolledBack, rollbackErr := agent.RollbackUpdate(config.DataDir, err); rollbackErr != nil {
+			logger.WithField("error", rollbackErr).Error("Erro ao reverter atualização")
+		} else if rolledBack {
+			logger.Warning("Atualização revertida, reiniciando com o binário anterior")
+			restart(logger, config, agent.RestartRequest{Reason: "update_rollback", Timestamp: time.Now()})
+		}
 		os.Exit(1)
 	}
 
 	logger.Info("Agente iniciado com sucesso - aguardando sinal de parada...")
 
-	// Aguardar shutdown
-	<-shutdownChan
+	// Aguardar shutdown ou pedido de restart
+	var restartRequest *agent.RestartRequest
+	select {
+	case <-shutdownChan:
+	case req := <-agentInstance.RestartRequests():
+		logger.WithField("command_id", req.CommandID).Info("Restart solicitado")
+		restartRequest = &req
+	}
 
 	// Shutdown graceful com timeout
 	logger.Info("Iniciando shutdown graceful...")
@@ -175,19 +256,116 @@ func main() {
 		os.Exit(1)
 	}
 
+	if restartRequest != nil {
+		restart(logger, config, *restartRequest)
+	}
+
 	logger.Info("Agente finalizado")
 }
 
+// applyFlagOverrides aplica à configuração os overrides de linha de comando
+// (também após uma recarga, para que continuem valendo)
+func applyFlagOverrides(config *agent.Config) {
+	if *logLevel != "" {
+		config.LogLevel = *logLevel
+	}
+	if *verbose {
+		config.Debug = true
+		config.LogLevel = "debug"
+	}
+}
+
+// reloadConfig relê o arquivo de configuração e aplica no agente em execução
+func reloadConfig(agentInstance *agent.Agent, configPath string, logger logging.Logger) {
+	logger.WithField("config_path", configPath).Info("SIGHUP recebido, recarregando configuração")
+
+	config, err := agent.LoadConfig(configPath)
+	if err != nil {
+		logger.WithField("error", err).Error("Erro ao recarregar configuração, mantendo a atual")
+		return
+	}
+	applyFlagOverrides(config)
+
+	if err := agentInstance.Reload(config); err != nil {
+		logger.WithField("error", err).Error("Erro ao aplicar configuração recarregada")
+	}
+}
+
 // printHelp exibe informações de ajuda
 func printHelp() {
 	fmt.Printf(`%s - Agente de Monitoramento de Sistema
 
 USAGE:
-    %s [FLAGS]
+    %s [FLAGS] [SUBCOMANDO]
+
+SUBCOMANDOS:
+    diagnose
+        Executa o diagnóstico de conectividade com o backend (DNS, TCP, TLS,
+        HTTP, WebSocket, proxy, MTU e diferença de relógio) e imprime um
+        relatório JSON. Sai com código 1 se alguma etapa falhar.
+
+    status [--watch] [--json]
+        Mostra o estado do agente em execução (conectividade, último
+        heartbeat/inventário, fila de comandos e erros recentes) consultando
+        o socket de controle. --watch atualiza a cada 2 segundos e --json
+        imprime o documento Health() completo. Códigos de saída: 0 saudável,
+        1 degradado, 2 offline.
+
+    collect [--output ARQUIVO] [--pretty] [--sections LISTA] [--timeout DURAÇÃO]
+        Coleta um inventário uma única vez, sem serviço instalado nem backend,
+        e grava o JSON em stdout ou em --output. --sections limita a coleta
+        (ex.: hardware,software; system e hardware sempre entram) e --timeout
+        limita o tempo total (padrão 2m). Usa as opções do collector do
+        arquivo de configuração quando ele existe, sem exigi-lo. Sai com
+        código 1 se uma seção crítica falhar.
+
+    exec [--explain] [--json] [--timeout N] [--cwd DIR] -- COMANDO [ARGS...]
+        Passa um comando shell local pelo executor real (whitelist vigente,
+        com as atualizações do backend, verificação de segurança, sanitização
+        e command_working_dirs da configuração) e o executa, mostrando o spec aceito, cada etapa da
+        validação, se os argumentos foram sanitizados e o resultado, ou o
+        motivo da recusa. --explain só valida, sem executar. Sai com código 1
+        se o comando for recusado ou falhar.
+
+    audit verify [--dir DIR] [--json]
+        Confere a cadeia de hashes do log de auditoria dos comandos
+        (data_dir/audit, ou --dir), incluindo a ligação entre os arquivos
+        rotacionados, e mostra o head (última entrada). Sai com código 1 e
+        aponta o arquivo, a linha e a seq da primeira entrada que não confere.
+
+    bench-compression [--iterations N]
+        Coleta o inventário atual e o codifica com cada codificação suportada
+        (identity, gzip, zstd), imprimindo tamanho, razão de compressão e
+        tempo médio de codificação. O agente usa a codificação preferida
+        entre as anunciadas pelo backend em accepted_encodings.
+
+INSTÂNCIAS LADO A LADO:
+    Só uma instância por machine_id coleta e envia (lock em instance_lock_dir,
+    padrão data_dir). Uma segunda instância, como a nova versão durante um
+    upgrade, fica em modo observador até o lock ser liberado, sem coletar nem
+    enviar; com instance_lock_policy "exit" ela encerra com código 0.
+
+MODO OFFLINE:
+    Com "offline": true o agente roda sem backend: não registra a máquina nem
+    envia heartbeats, e backend_url, websocket_url e token são opcionais.
+    Coleta, comandos agendados, log de eventos e o socket de controle
+    continuam; cada inventário vira um arquivo JSON em offline_archive_dir
+    (padrão data_dir/inventory_archive), que guarda os offline_archive_size
+    mais recentes. Comandos que dependem do backend são recusados com o
+    código offline_mode. Mudar "offline" exige reiniciar o agente.
+
+RECARGA DA CONFIGURAÇÃO:
+    SIGHUP relê o arquivo de configuração sem reiniciar o processo. Nível de
+    log, heartbeat_interval, collection_interval, command_timeout,
+    max_concurrent_commands, upload_rate_limit e inventory_send_window valem
+    na hora; backend_url, websocket_url e token
+    reconectam ao backend; os demais campos exigem reiniciar o agente. O
+    backend pode enviar os mesmos campos no bloco "agent" de um config_update.
 
 FLAGS:
     -config string
-        Caminho para o arquivo de configuração (default: "configs/config.json")
+        Caminho para o arquivo de configuração (default: "configs/config.json");
+        a extensão escolhe o formato: .json, .yaml/.yml ou .toml
     
     -log-level string
         Nível de log (debug, info, warning, error)
@@ -198,6 +376,22 @@ FLAGS:
     -version
         Mostrar versão e sair
     
+    -validate-config
+        Valida o arquivo de configuração e sai: campos desconhecidos (com
+        sugestão para erros de digitação), variáveis ${VAR} não definidas,
+        URLs e intervalos inválidos e diretórios sem permissão de escrita,
+        todos de uma vez, com o caminho JSON de cada campo. Sai com código 1
+        se houver problemas; avisos (como URLs sem TLS) não falham.
+    
+    -print-default-config string
+        Imprime uma configuração de exemplo com os valores padrão no formato
+        dado (json, yaml ou toml; YAML e TOML vêm comentados) e sai
+    
+    -enrollment-key string
+        Chave de enrollment de curta duração (o mesmo que enrollment_key).
+        No primeiro início o agente a troca pelo token da máquina, guardado
+        cifrado em data_dir; nos inícios seguintes a chave é ignorada
+    
     -help
         Mostrar esta ajuda e sair
 
@@ -211,6 +405,14 @@ VARIABLES DE AMBIENTE:
     AGENTE_DEBUG
         Ativar modo debug (sobrescreve -verbose)
 
+    AGENTE_CHAOS
+        Com valor 1, aplica o bloco "chaos" da configuração (injeção de
+        falhas para testes em staging; ver docs/CHAOS.md)
+
+    AGENTE_ENROLLMENT_KEY
+        Chave de enrollment, quando enrollment_key não está no arquivo (ver
+        docs/ENROLLMENT.md)
+
 EXEMPLOS:
     # Executar com configuração padrão
     %s
@@ -218,18 +420,97 @@ EXEMPLOS:
     # Executar com arquivo de configuração específico
     %s -config /path/to/config.json
 
+    # Gerar uma configuração inicial em YAML
+    %s -print-default-config yaml > configs/config.yaml
+
     # Executar em modo debug
     %s -verbose
 
     # Executar com nível de log específico
     %s -log-level warning
 
+    # Gerar relatório de conectividade para anexar a um chamado
+    %s -config /path/to/config.json diagnose > diagnostico.json
+
+    # Acompanhar o estado do agente pelo terminal
+    %s status --watch
+
 ARQUIVOS:
     configs/config.json     Arquivo de configuração padrão
     logs/                   Diretório de logs (se configurado)
 
 Para mais informações, consulte a documentação.
-`, AppName, AppName, AppName, AppName, AppName, AppName)
+`, AppName, AppName, AppName, AppName, AppName, AppName, AppName, AppName, AppName)
+}
+
+// restart reinicia o agente: sob launchd/systemd/SCM sai com RestartExitCode
+// para o supervisor reiniciar; em modo standalone inicia uma nova instância
+func restart(logger logging.Logger, config *agent.Config, request agent.RestartRequest) {
+	supervisor := agent.DetectSupervisor()
+
+	if err := agent.WriteShutdownReason(config.DataDir, request, supervisor); err != nil {
+		logger.WithField("error", err).Warning("Erro ao gravar motivo do shutdown")
+	}
+
+	if supervisor != "" {
+		logger.WithFields(map[string]interface{}{
+			"exit_code":  agent.RestartExitCode,
+			"supervisor": supervisor,
+		}).Info("Saindo para restart pelo supervisor")
+		os.Exit(agent.RestartExitCode)
+	}
+
+	if err := agent.ExecSelf(); err != nil {
+		logger.WithField("error", err).Error("Erro ao iniciar nova instância")
+		// O binário novo de um update não executou: voltar ao anterior
+		if request.Reason != "update" {
+			os.Exit(1)
+		}
+		if rolledBack, rollbackErr := agent.RollbackUpdate(config.DataDir, err); !rolledBack || rollbackErr != nil {
+			os.Exit(1)
+		}
+		if err := agent.ExecSelf(); err != nil {
+			logger.WithField("error", err).Error("Erro ao iniciar o binário restaurado")
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("Nova instância iniciada, finalizando processo atual")
+	os.Exit(0)
+}
+
+// runDiagnose executa o diagnóstico de conectividade e imprime o relatório JSON
+func runDiagnose(config *agent.Config) int {
+	if config.Offline {
+		fmt.Fprintln(os.Stderr, "Modo offline: não há backend para diagnosticar")
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report := comms.RunDiagnostics(ctx, comms.DiagnosticsConfig{
+		BackendURL:   config.BackendURL,
+		WebSocketURL: config.WebSocketURL,
+		Token:        agent.AuthToken(config),
+		TLSFiles:     config.TLSFiles(),
+		ProxyURL:     config.ProxyURL,
+
+		TLSSkipVerify:      config.TLSSkipVerify,
+		PinnedCertificates: config.PinnedCertificates,
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "Erro ao serializar relatório: %v\n", err)
+		return 1
+	}
+
+	if !report.OK() {
+		return 1
+	}
+	return 0
 }
 
 // init configura variáveis de ambiente
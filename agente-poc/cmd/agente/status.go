@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"agente-poc/internal/agent"
+)
+
+// Códigos de saída do subcomando status
+const (
+	statusHealthy  = 0
+	statusDegraded = 1
+	statusOffline  = 2
+)
+
+const (
+	statusWatchInterval = 2 * time.Second
+	statusQueryTimeout  = 5 * time.Second
+	// Erros mais antigos que isso não degradam o status
+	statusRecentErrorWindow = 5 * time.Minute
+)
+
+// runStatus consulta o agente em execução pelo socket de controle
+func runStatus(config *agent.Config, args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	watch := flags.Bool("watch", false, "Atualizar a cada 2 segundos")
+	jsonOutput := flags.Bool("json", false, "Imprimir o documento Health() em JSON")
+	if err := flags.Parse(args); err != nil {
+		return statusOffline
+	}
+
+	query := func() (map[string]interface{}, error) {
+		return agent.QueryHealth(config.ControlSocket, statusQueryTimeout)
+	}
+
+	if !*watch {
+		return printStatus(os.Stdout, query, *jsonOutput, time.Now())
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+
+	ticker := time.NewTicker(statusWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		if !*jsonOutput {
+			// Limpar a tela e voltar o cursor ao topo
+			fmt.Fprint(os.Stdout, "\033[H\033[2J")
+		}
+		code := printStatus(os.Stdout, query, *jsonOutput, time.Now())
+
+		select {
+		case <-signalChan:
+			return code
+		case <-ticker.C:
+		}
+	}
+}
+
+// printStatus imprime um snapshot e retorna o código de saída correspondente
+func printStatus(w io.Writer, query func() (map[string]interface{}, error), jsonOutput bool, now time.Time) int {
+	health, err := query()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: offline (%v)\n", AppName, err)
+		return statusOffline
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(health)
+	} else {
+		renderStatus(w, health, now)
+	}
+
+	code, _ := evaluateStatus(health, now)
+	return code
+}
+
+// evaluateStatus classifica o documento Health() em saudável, degradado ou offline
+func evaluateStatus(health map[string]interface{}, now time.Time) (int, []string) {
+	if state := healthString(health, "state"); state != "running" {
+		return statusOffline, []string{"agent state is " + state}
+	}
+	// No modo offline não há backend: conexão e heartbeat não contam
+	offline := healthString(health, "mode") == "offline"
+	if connected, _ := health["connected"].(bool); !connected && !offline {
+		return statusOffline, []string{"backend not reachable"}
+	}
+
+	var reasons []string
+
+	if breaker := healthString(health, "circuit_breaker"); breaker != "" && breaker != "closed" {
+		reasons = append(reasons, "circuit breaker "+breaker)
+	}
+
+	if !offline {
+		interval := time.Duration(healthFloat(health, "heartbeat_interval") * float64(time.Second))
+		lastHeartbeat := healthTime(health, "last_heartbeat")
+		if lastHeartbeat.IsZero() {
+			reasons = append(reasons, "no heartbeat sent yet")
+		} else if interval > 0 && now.Sub(lastHeartbeat) > 3*interval {
+			reasons = append(reasons, "heartbeat overdue")
+		}
+	}
+
+	for _, recent := range healthErrors(health) {
+		if now.Sub(recent.Timestamp) <= statusRecentErrorWindow {
+			reasons = append(reasons, "recent errors")
+			break
+		}
+	}
+
+	if lag, ok := health["backend_lag"].(map[string]interface{}); ok {
+		if lagging, _ := lag["lagging"].(bool); lagging {
+			reasons = append(reasons, "backend lag")
+		}
+	}
+
+	if registration, ok := health["registration"].(map[string]interface{}); ok {
+		switch state, _ := registration["state"].(string); state {
+		case agent.RegistrationConflict:
+			reasons = append(reasons, "machine ID conflict")
+		case agent.RegistrationUnauthorized:
+			reasons = append(reasons, "registration unauthorized")
+		case agent.RegistrationFailed:
+			reasons = append(reasons, "registration failed")
+		}
+	}
+
+	if identity, ok := health["identity"].(map[string]interface{}); ok {
+		if migration, _ := identity["migration"].(string); migration == agent.IdentityMigrationAborted {
+			reasons = append(reasons, "machine ID migration aborted")
+		}
+	}
+
+	if system, ok := health["system_health"].(map[string]interface{}); ok {
+		if status, _ := system["status"].(string); status != "" && status != "healthy" {
+			reasons = append(reasons, "system health "+status)
+		}
+	}
+
+	if len(reasons) > 0 {
+		return statusDegraded, reasons
+	}
+	return statusHealthy, nil
+}
+
+// renderStatus imprime o resumo do Health() em formato de tabela
+func renderStatus(w io.Writer, health map[string]interface{}, now time.Time) {
+	code, reasons := evaluateStatus(health, now)
+	label := map[int]string{
+		statusHealthy:  "healthy",
+		statusDegraded: "degraded",
+		statusOffline:  "offline",
+	}[code]
+
+	connectivity := "disconnected"
+	if connected, _ := health["connected"].(bool); connected {
+		connectivity = "connected"
+	}
+
+	fmt.Fprintf(w, "%s status  %s\n\n", AppName, now.Format("2006-01-02 15:04:05"))
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "  Status\t%s\n", label)
+	if len(reasons) > 0 {
+		fmt.Fprintf(table, "  Reasons\t%s\n", strings.Join(reasons, ", "))
+	}
+	fmt.Fprintf(table, "  State\t%s\n", healthString(health, "state"))
+	fmt.Fprintf(table, "  Machine ID\t%s\n", healthString(health, "machine_id"))
+	if archive, ok := health["offline_archive"].(map[string]interface{}); ok {
+		fmt.Fprintf(table, "  Mode\toffline (archive %s, %d files)\n", healthString(archive, "dir"), int(healthFloat(archive, "files")))
+	} else {
+		fmt.Fprintf(table, "  Connectivity\t%s (%s)\n", connectivity, healthString(health, "backend_url"))
+		fmt.Fprintf(table, "  Last heartbeat\t%s\n", formatAge(healthTime(health, "last_heartbeat"), now))
+	}
+	fmt.Fprintf(table, "  Last inventory\t%s\n", formatAge(healthTime(health, "last_inventory"), now))
+	fmt.Fprintf(table, "  Queue depth\t%d\n", int(healthFloat(health, "queue_depth")))
+	fmt.Fprintf(table, "  Circuit breaker\t%s\n", formatBreaker(health, now))
+	fmt.Fprintf(table, "  Uptime\t%s\n", healthString(health, "uptime"))
+	if instance, ok := health["instance"].(map[string]interface{}); ok {
+		fmt.Fprintf(table, "  Instance\t%s (%s)\n", healthString(instance, "instance_id"), healthString(instance, "role"))
+	}
+	registration, _ := health["registration"].(map[string]interface{})
+	if state, _ := registration["state"].(string); state != "" {
+		fmt.Fprintf(table, "  Registration\t%s\n", state)
+	}
+	identity, _ := health["identity"].(map[string]interface{})
+	switch migration, _ := identity["migration"].(string); migration {
+	case agent.IdentityMigrationPending:
+		fmt.Fprintf(table, "  ID migration\tpending -> %s (until %s)\n",
+			healthString(identity, "new_machine_id"), healthTime(identity, "deadline").Format("2006-01-02 15:04"))
+	case agent.IdentityMigrationAborted:
+		fmt.Fprintf(table, "  ID migration\taborted (%s)\n", healthString(identity, "new_machine_id"))
+	}
+	table.Flush()
+
+	if remediation, _ := registration["remediation"].(string); remediation != "" {
+		issue, _ := registration["issue"].(string)
+		fmt.Fprintf(w, "\nRegistration: %s\n  %s\n", issue, remediation)
+	}
+
+	errors := healthErrors(health)
+	if len(errors) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nRecent errors:\n")
+	// Mais recentes primeiro, no máximo 5
+	for i := len(errors) - 1; i >= 0 && i >= len(errors)-5; i-- {
+		fmt.Fprintf(w, "  %s ago  %s\n", formatDuration(now.Sub(errors[i].Timestamp)), errors[i].Message)
+	}
+}
+
+// formatAge formata há quanto tempo algo aconteceu
+func formatAge(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return formatDuration(now.Sub(t)) + " ago"
+}
+
+// formatDuration arredonda a duração para leitura rápida
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return d.Round(time.Second).String()
+}
+
+func healthString(health map[string]interface{}, key string) string {
+	value, _ := health[key].(string)
+	return value
+}
+
+func healthFloat(health map[string]interface{}, key string) float64 {
+	value, _ := health[key].(float64)
+	return value
+}
+
+// formatBreaker mostra o pior estado entre os circuit breakers e, para cada
+// endpoint que já abriu, o estado, as aberturas e, com o circuito aberto, o
+// próximo probe do backend
+func formatBreaker(health map[string]interface{}, now time.Time) string {
+	text := healthString(health, "circuit_breaker")
+	breakers, _ := health["circuit_breakers"].(map[string]interface{})
+
+	endpoints := make([]string, 0, len(breakers))
+	for endpoint := range breakers {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	for _, endpoint := range endpoints {
+		status, _ := breakers[endpoint].(map[string]interface{})
+		trips := int(healthFloat(status, "trips"))
+		if trips == 0 {
+			continue
+		}
+		text += fmt.Sprintf("; %s %s, %d trips", endpoint, healthString(status, "state"), trips)
+		if next := healthTime(status, "next_probe"); !next.IsZero() {
+			text += ", next probe in " + next.Sub(now).Round(time.Second).String()
+		}
+	}
+	return text
+}
+
+// healthTime interpreta timestamps RFC3339; o valor zero do Go vira time.Time{}
+func healthTime(health map[string]interface{}, key string) time.Time {
+	t, err := time.Parse(time.RFC3339, healthString(health, key))
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
+
+// healthErrors extrai recent_errors do documento
+func healthErrors(health map[string]interface{}) []agent.RecentError {
+	raw, err := json.Marshal(health["recent_errors"])
+	if err != nil {
+		return nil
+	}
+	var errors []agent.RecentError
+	_ = json.Unmarshal(raw, &errors)
+	return errors
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/agent"
+)
+
+// statusNow é o instante fixo dos snapshots de teste
+var statusNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+// healthSnapshot monta um documento Health() saudável, como chega pelo
+// socket de controle (JSON decodificado), com os campos de override
+func healthSnapshot(t *testing.T, override map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	health := map[string]interface{}{
+		"state":              "running",
+		"mode":               "online",
+		"connected":          true,
+		"machine_id":         "mac-dev-001",
+		"backend_url":        "https://backend.example.com",
+		"heartbeat_interval": 30,
+		"last_heartbeat":     statusNow.Add(-10 * time.Second),
+		"last_inventory":     statusNow.Add(-5 * time.Minute),
+		"queue_depth":        3,
+		"circuit_breaker":    "closed",
+		"uptime":             "2h0m0s",
+	}
+	for key, value := range override {
+		if value == nil {
+			delete(health, key)
+			continue
+		}
+		health[key] = value
+	}
+
+	data, err := json.Marshal(health)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestEvaluateStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		override map[string]interface{}
+		code     int
+		reason   string
+	}{
+		{name: "healthy", code: statusHealthy},
+		{name: "stopped", override: map[string]interface{}{"state": "stopping"}, code: statusOffline, reason: "agent state is stopping"},
+		{name: "disconnected", override: map[string]interface{}{"connected": false}, code: statusOffline, reason: "backend not reachable"},
+		{
+			name:     "offline mode ignores the backend",
+			override: map[string]interface{}{"mode": "offline", "connected": false, "last_heartbeat": nil},
+			code:     statusHealthy,
+		},
+		{name: "breaker open", override: map[string]interface{}{"circuit_breaker": "open"}, code: statusDegraded, reason: "circuit breaker open"},
+		{name: "no heartbeat", override: map[string]interface{}{"last_heartbeat": nil}, code: statusDegraded, reason: "no heartbeat sent yet"},
+		{
+			name:     "heartbeat overdue",
+			override: map[string]interface{}{"last_heartbeat": statusNow.Add(-91 * time.Second)},
+			code:     statusDegraded,
+			reason:   "heartbeat overdue",
+		},
+		{
+			name: "recent error",
+			override: map[string]interface{}{"recent_errors": []agent.RecentError{
+				{Timestamp: statusNow.Add(-time.Minute), Message: "send failed"},
+			}},
+			code:   statusDegraded,
+			reason: "recent errors",
+		},
+		{
+			name: "old error",
+			override: map[string]interface{}{"recent_errors": []agent.RecentError{
+				{Timestamp: statusNow.Add(-time.Hour), Message: "send failed"},
+			}},
+			code: statusHealthy,
+		},
+		{
+			name:     "backend lag",
+			override: map[string]interface{}{"backend_lag": map[string]interface{}{"lagging": true}},
+			code:     statusDegraded,
+			reason:   "backend lag",
+		},
+		{
+			name:     "registration conflict",
+			override: map[string]interface{}{"registration": map[string]interface{}{"state": agent.RegistrationConflict}},
+			code:     statusDegraded,
+			reason:   "machine ID conflict",
+		},
+		{
+			name:     "migration aborted",
+			override: map[string]interface{}{"identity": map[string]interface{}{"migration": agent.IdentityMigrationAborted}},
+			code:     statusDegraded,
+			reason:   "machine ID migration aborted",
+		},
+		{
+			name:     "system health critical",
+			override: map[string]interface{}{"system_health": map[string]interface{}{"status": "critical"}},
+			code:     statusDegraded,
+			reason:   "system health critical",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reasons := evaluateStatus(healthSnapshot(t, tt.override), statusNow)
+			if code != tt.code {
+				t.Fatalf("code %d, want %d (reasons %v)", code, tt.code, reasons)
+			}
+			if tt.reason == "" && len(reasons) > 0 {
+				t.Fatalf("unexpected reasons %v", reasons)
+			}
+			if tt.reason != "" && (len(reasons) == 0 || reasons[0] != tt.reason) {
+				t.Fatalf("reasons %v, want %q", reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	health := healthSnapshot(t, map[string]interface{}{
+		"circuit_breaker": "open",
+		"circuit_breakers": map[string]interface{}{
+			"/inventory": map[string]interface{}{"state": "open", "trips": 2, "next_probe": statusNow.Add(20 * time.Second)},
+			"/heartbeat": map[string]interface{}{"state": "closed", "trips": 0},
+		},
+		"recent_errors": []agent.RecentError{
+			{Timestamp: statusNow.Add(-3 * time.Minute), Message: "first"},
+			{Timestamp: statusNow.Add(-2 * time.Minute), Message: "second"},
+		},
+	})
+
+	var out bytes.Buffer
+	renderStatus(&out, health, statusNow)
+	text := out.String()
+
+	for _, want := range []string{
+		"agente-poc status  2026-10-16 12:00:00",
+		"Status           degraded",
+		"Reasons          circuit breaker open, recent errors",
+		"Machine ID       mac-dev-001",
+		"Connectivity     connected (https://backend.example.com)",
+		"Last heartbeat   10s ago",
+		"Last inventory   5m0s ago",
+		"Queue depth      3",
+		"Circuit breaker  open; /inventory open, 2 trips, next probe in 20s",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "/heartbeat") {
+		t.Error("breaker that never opened listed")
+	}
+	// Erros mais recentes primeiro
+	if second, first := strings.Index(text, "second"), strings.Index(text, "first"); second < 0 || first < second {
+		t.Errorf("recent errors out of order:\n%s", text)
+	}
+}
+
+func TestRenderStatusOfflineMode(t *testing.T) {
+	health := healthSnapshot(t, map[string]interface{}{
+		"mode":            "offline",
+		"connected":       false,
+		"last_inventory":  nil,
+		"offline_archive": map[string]interface{}{"dir": "/var/lib/agente-poc/archive", "files": 4},
+	})
+
+	var out bytes.Buffer
+	renderStatus(&out, health, statusNow)
+	text := out.String()
+
+	if !strings.Contains(text, "Mode             offline (archive /var/lib/agente-poc/archive, 4 files)") {
+		t.Errorf("offline archive not shown:\n%s", text)
+	}
+	if !strings.Contains(text, "Last inventory   never") {
+		t.Errorf("missing inventory not shown as never:\n%s", text)
+	}
+	if strings.Contains(text, "Connectivity") || strings.Contains(text, "Last heartbeat") {
+		t.Errorf("backend fields shown in offline mode:\n%s", text)
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	var out bytes.Buffer
+	code := printStatus(&out, func() (map[string]interface{}, error) {
+		return nil, errors.New("connection refused")
+	}, false, statusNow)
+	if code != statusOffline || out.Len() != 0 {
+		t.Fatalf("unreachable agent: code %d, output %q", code, out.String())
+	}
+
+	health := healthSnapshot(t, nil)
+	out.Reset()
+	code = printStatus(&out, func() (map[string]interface{}, error) { return health, nil }, true, statusNow)
+	if code != statusHealthy {
+		t.Fatalf("healthy agent: code %d", code)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded["machine_id"] != "mac-dev-001" {
+		t.Fatalf("--json output not the Health() document: %v\n%s", err, out.String())
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"agente-poc/internal/agent"
+)
+
+// runValidateConfig carrega e valida o arquivo de configuração, imprime um
+// problema por linha (cada um com o caminho JSON do campo) e os avisos, e
+// retorna 0 se a configuração é válida
+func runValidateConfig(configPath string) int {
+	config, err := agent.LoadConfig(configPath)
+	if err != nil {
+		var validation *agent.ValidationError
+		if !errors.As(err, &validation) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", configPath, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d problema(s) encontrado(s)\n", configPath, len(validation.Problems))
+		for _, problem := range validation.Problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+		}
+		return 1
+	}
+
+	for _, warning := range config.Warnings() {
+		fmt.Fprintf(os.Stderr, "aviso: %s\n", warning)
+	}
+	fmt.Printf("%s: configuração válida\n", configPath)
+	return 0
+}
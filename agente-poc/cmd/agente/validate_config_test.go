@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig grava um config.json com data_dir em um diretório temporário
+func writeConfig(t *testing.T, extra string) string {
+	t.Helper()
+	dir := t.TempDir()
+	document := `{
+		"machine_id": "test-machine",
+		"backend_url": "https://backend.example.com",
+		"websocket_url": "wss://backend.example.com/ws",
+		"heartbeat_interval": 30,
+		"token": "test-token",
+		"data_dir": "` + filepath.ToSlash(filepath.Join(dir, "data")) + `"` + extra + `
+	}`
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunValidateConfig(t *testing.T) {
+	if code := runValidateConfig(writeConfig(t, "")); code != 0 {
+		t.Fatalf("valid config: exit %d", code)
+	}
+	if code := runValidateConfig(writeConfig(t, `, "heartbeat_intervall": 10`)); code != 1 {
+		t.Fatalf("unknown key: exit %d", code)
+	}
+	if code := runValidateConfig(filepath.Join(t.TempDir(), "missing.json")); code != 1 {
+		t.Fatalf("missing file: exit %d", code)
+	}
+}
@@ -2,7 +2,14 @@ module agente-poc
 
 go 1.24.5
 
-require github.com/shirou/gopsutil/v3 v3.24.5
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/klauspost/compress v1.17.11
+	github.com/shirou/gopsutil/v3 v3.24.5
+	go.uber.org/goleak v1.3.0
+	golang.org/x/crypto v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/go-ole/go-ole v1.2.6 // indirect
@@ -13,5 +20,5 @@ require (
 	github.com/tklauser/go-sysconf v0.3.12 // indirect
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/sys v0.28.0
 )
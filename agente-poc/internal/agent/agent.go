@@ -2,15 +2,24 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/clock"
 	"agente-poc/internal/collector"
 	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
 	"agente-poc/internal/executor"
 	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
 )
 
 // AgentState representa o estado do agente
@@ -22,6 +31,7 @@ const (
 	StateStopping
 	StateStopped
 	StateError
+	StateObserver
 )
 
 // String retorna a representação string do estado
@@ -37,6 +47,8 @@ func (s AgentState) String() string {
 		return "stopped"
 	case StateError:
 		return "error"
+	case StateObserver:
+		return "observer"
 	default:
 		return "unknown"
 	}
@@ -57,9 +69,34 @@ type AgentMetrics struct {
 	RetryCount         int64
 	ConnectionAttempts int64
 	ConnectionFailures int64
-	mu                 sync.RWMutex
+	RecentErrors       []RecentError
+
+	// Fila de comandos: profundidade no momento da leitura, comandos
+	// recusados com a fila cheia e retirados por um de prioridade maior
+	CommandQueueDepth    int
+	CommandsRejectedFull int64
+	CommandsEvicted      int64
+
+	// Circuit breaker do backend: aberturas e tempo total aberto
+	CircuitBreakerTrips    int64
+	CircuitBreakerOpenTime time.Duration
+
+	mu sync.RWMutex
 }
 
+// RecentError é um erro recente exibido pelo comando status
+type RecentError struct {
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// maxRecentErrors limita os erros mantidos em memória para o status
+const maxRecentErrors = 10
+
+// commandCancelGrace limita a espera, no Stop, pelos resultados dos comandos
+// cancelados
+const commandCancelGrace = 5 * time.Second
+
 // RetryConfig contém configurações de retry
 type RetryConfig struct {
 	MaxRetries        int
@@ -69,42 +106,112 @@ type RetryConfig struct {
 	JitterEnabled     bool
 }
 
-// CircuitBreakerConfig contém configurações do circuit breaker
-type CircuitBreakerConfig struct {
-	FailureThreshold int
-	ResetTimeout     time.Duration
-	HalfOpenMaxCalls int
-}
-
-// CircuitBreaker implementa um circuit breaker básico
-type CircuitBreaker struct {
-	config          CircuitBreakerConfig
-	failures        int
-	lastFailureTime time.Time
-	state           string // "closed", "open", "half-open"
-	halfOpenCalls   int
-	mu              sync.RWMutex
-}
-
 // Agent representa a instância principal do agente
 type Agent struct {
-	config         *Config
-	logger         logging.Logger
-	collector      *collector.SystemCollector
-	comms          *comms.Manager
-	executor       *executor.Executor
-	ctx            context.Context
-	cancel         context.CancelFunc
-	wg             sync.WaitGroup
-	mu             sync.RWMutex
-	state          AgentState
-	metrics        *AgentMetrics
-	retryConfig    *RetryConfig
-	circuitBreaker *CircuitBreaker
-	commandChan    chan *comms.Command
-	errorChan      chan error
-	shutdownChan   chan struct{}
-	healthStatus   *comms.SystemHealthStatus
+	config    *Config
+	logger    logging.Logger
+	collector *collector.SystemCollector
+	// commsManager é trocado quando uma recarga muda backend ou token;
+	// acessar por comms()
+	commsManager atomic.Pointer[comms.Manager]
+	executor     *executor.Executor
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	mu           sync.RWMutex
+	state        AgentState
+	metrics      *AgentMetrics
+	retryConfig  *RetryConfig
+	// breakers são os circuit breakers por endpoint; ficam no agente para
+	// o estado sobreviver à recriação do communications manager
+	breakers     *comms.EndpointBreakers
+	clock        clock.Clock
+	commandQueue *commandQueue
+	errorChan    chan error
+	shutdownChan chan struct{}
+	restartChan  chan RestartRequest
+	// updating impede dois comandos update simultâneos
+	updating atomic.Bool
+	// collectionReset leva ao runCollector um novo intervalo de coleta
+	collectionReset chan time.Duration
+	healthStatus    *comms.SystemHealthStatus
+	health          *healthSampler
+	events          *events.Pipeline
+	// recentEvents guarda os últimos eventos para GetEvents e get_events
+	recentEvents *events.Ring
+	// Envio das linhas de log ao backend (ver log_shipping.go)
+	logShipper       *comms.LogShipper
+	detachLogShipper func()
+	// tlsDigests são os digests dos arquivos de mTLS carregados pelo
+	// communications manager vigente (ver tlsFileDigests)
+	tlsDigests map[string]string
+
+	// Comandos recorrentes definidos no arquivo e pelo backend (ver scheduler.go)
+	scheduler *Scheduler
+
+	// commandMu fica travado enquanto um comando é processado, para o Stop
+	// aguardar o envio dos resultados cancelados
+	commandMu sync.Mutex
+
+	// Identificador desta execução e lock por máquina (ver instance_lock.go)
+	instanceID   string
+	instanceLock *instanceLock
+
+	// Injeção de falhas em staging (nil = desativada, ver chaos.go)
+	chaos *chaos.Injector
+
+	// Retenção local de inventários para o caso de backend indisponível
+	snapshots            *SnapshotRing
+	snapshotOfferPending bool
+
+	// Regras de alerta locais (ver alerts.go)
+	alerts *comms.Monitor
+
+	// Último inventário coletado, antes do planner, base do diff do próximo
+	lastInventory *collector.InventoryData
+
+	// Inventários gravados em disco no modo offline (nil no modo online)
+	archive *InventoryArchive
+
+	// Transições de sleep/wake
+	power *PowerTracker
+
+	// Adiamento de trabalho não urgente enquanto o usuário apresenta
+	presence *DeferralGate
+
+	// Número de políticas MDM/GPO do último inventário (-1 = desconhecido)
+	policyCount atomic.Int64
+
+	// Capacidades anunciadas ao backend (ver buildCapabilities)
+	capabilities *comms.Capabilities
+
+	// Socket local consultado pelo subcomando status
+	controlServer *http.Server
+
+	// Avisos de decodificação por command_id, anexados ao resultado enviado
+	commandWarnings sync.Map
+	// Tipo de cada comando em andamento, para o registro de execução
+	commandTypes sync.Map
+
+	// Estado do registro e machine_id em uso (pode ser regenerado após 409)
+	registration registration
+
+	// Token da máquina obtido no enrollment e sua renovação
+	credential machineCredential
+
+	// machine_id persistido e migração para um novo ID
+	identity identity
+
+	// Sequência de inventário enviada vs. processada pelo backend
+	inventorySeq *InventorySequence
+	backendLag   BackendLag
+	lagMu        sync.Mutex
+
+	// Decide quais seções do inventário sobrevivem às restrições vigentes
+	planner *collector.Planner
+
+	// Recargas da configuração (SIGHUP ou config_update, ver reload.go)
+	reloads reloadState
 }
 
 // New cria uma nova instância do agente
@@ -120,36 +227,50 @@ func New(config *Config, logger logging.Logger) *Agent {
 		JitterEnabled:     true,
 	}
 
-	// Configuração do circuit breaker
-	circuitBreakerConfig := CircuitBreakerConfig{
-		FailureThreshold: 5,
-		ResetTimeout:     30 * time.Second,
-		HalfOpenMaxCalls: 3,
-	}
-
-	return &Agent{
-		config:      config,
-		logger:      logger,
-		ctx:         ctx,
-		cancel:      cancel,
-		state:       StateStarting,
-		metrics:     &AgentMetrics{StartTime: time.Now()},
-		retryConfig: retryConfig,
-		circuitBreaker: &CircuitBreaker{
-			config: circuitBreakerConfig,
-			state:  "closed",
-		},
-		commandChan:  make(chan *comms.Command, 100),
+	agent := &Agent{
+		config:       config,
+		logger:       logger,
+		ctx:          ctx,
+		cancel:       cancel,
+		state:        StateStarting,
+		metrics:      &AgentMetrics{StartTime: time.Now()},
+		retryConfig:  retryConfig,
+		clock:        clock.Real,
+		instanceID:   newInstanceID(),
+		commandQueue: newCommandQueue(commandQueueSize),
 		errorChan:    make(chan error, 100),
 		shutdownChan: make(chan struct{}),
+		restartChan:  make(chan RestartRequest, 1),
+		recentEvents: events.NewRing(config.EventBufferSize),
+
+		collectionReset: make(chan time.Duration, 1),
+		power:           NewPowerTracker(NewSystemPowerSource()),
+		presence:        NewDeferralGate(NewSystemPresenceProvider(), config.PresencePolicy, config.MaxPresenceDeferral),
 		healthStatus: &comms.SystemHealthStatus{
 			Status: "healthy",
 		},
 	}
+	agent.policyCount.Store(-1)
+	agent.breakers = agent.newEndpointBreakers()
+
+	return agent
+}
+
+// SetClock substitui a fonte de tempo do agente, do circuit breaker, do
+// adiamento por presença, do collector e do communications manager; deve ser
+// chamado antes de Start
+func (a *Agent) SetClock(clk clock.Clock) {
+	clk = clock.OrReal(clk)
+	a.clock = clk
+	a.breakers.SetClock(clk)
+	a.presence.SetClock(clk)
+	a.metrics.StartTime = clk.Now()
 }
 
-// Start inicia o agente e todos os seus componentes
-func (a *Agent) Start() error {
+// Start inicia o agente e todos os seus componentes. Se outra instância
+// detém o lock desta máquina, o agente fica em modo observador (ou retorna
+// ErrInstanceLocked, conforme instance_lock_policy).
+func (a *Agent) Start() (err error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -160,78 +281,218 @@ func (a *Agent) Start() error {
 	a.logger.Info("Starting agent...")
 	a.setState(StateStarting)
 
+	// Pipeline de eventos (backend e log local); entrega começa após o comms
+	a.initEvents()
+
 	// Inicializar collector
 	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	a.collector.SetClock(a.clock)
+	a.health = newHealthSampler(a.collector, a.config.HealthThresholds, a.logger, a.clock)
+	defer func() {
+		// Sem Stop pela frente, o collector é encerrado aqui
+		if err != nil {
+			a.collector.Close()
+		}
+	}()
 
-	// Gerar machine_id automaticamente se não fornecido na configuração
-	if a.config.MachineID == "" {
-		a.logger.Info("Machine ID not provided in config, generating automatically...")
+	a.planner, err = collector.NewPlanner(a.config.InventoryPlan)
+	if err != nil {
+		a.setState(StateError)
+		return fmt.Errorf("invalid inventory plan: %w", err)
+	}
+	if err = a.config.ConfigureCollector(a.collector); err != nil {
+		a.setState(StateError)
+		return err
+	}
+
+	// Lock por máquina: só uma instância envia. O machine_id do lock vem da
+	// configuração ou da identidade persistida; só uma instalação nova
+	// precisa coletar para gerá-lo.
+	var generated string
+	var reliable bool
+	lockMachineID := a.lockMachineID()
+	if lockMachineID == "" {
+		generated, reliable, err = a.generateMachineID()
+		if err != nil {
+			a.setState(StateError)
+			return err
+		}
+		lockMachineID = generated
+	}
+
+	a.instanceLock = newInstanceLock(a.config.InstanceLockDir, lockMachineID, a.instanceID)
+	holder, err := a.instanceLock.Acquire()
+	switch {
+	case err != nil:
+		// Sem o lock o agente ainda funciona, apenas sem proteção de coexistência
+		a.logger.WithField("error", err).Warning("Instance lock unavailable, running without coexistence protection")
+		a.instanceLock = nil
+	case holder != nil:
+		a.logger.WithFields(map[string]interface{}{
+			"holder_pid":         holder.PID,
+			"holder_instance_id": holder.InstanceID,
+			"instance_id":        a.instanceID,
+			"lock":               a.instanceLock.Path(),
+			"policy":             a.config.InstanceLockPolicy,
+		}).Warning("Another agent instance holds the lock for this machine, not collecting or sending")
+
+		if a.config.InstanceLockPolicy == InstanceLockExit {
+			a.setState(StateStopped)
+			return fmt.Errorf("%w: held by pid %d (instance %s)", ErrInstanceLocked, holder.PID, holder.InstanceID)
+		}
+		a.setState(StateObserver)
+		go a.waitForInstanceLock()
+		return nil
+	}
+
+	return a.startOwned(generated, reliable)
+}
+
+// generateMachineID gera o machine_id a partir do inventário; reliable=false
+// indica o fallback pelo hostname quando a coleta falha
+func (a *Agent) generateMachineID() (generated string, reliable bool, err error) {
+	a.logger.Info("Machine ID not provided in config, generating automatically...")
 
-		// Coletar dados básicos para gerar machine_id
-		inventory, err := a.collector.CollectInventory()
+	// Coletar dados básicos para gerar machine_id
+	inventory, err := a.collector.CollectInventory()
+	if err != nil {
+		a.logger.Warning("Failed to collect inventory for machine ID generation, using fallback: %v", err)
+
+		// Fallback: usar informações básicas do sistema
+		basicInfo, err := a.collector.CollectBasicInfo()
 		if err != nil {
-			a.logger.Warning("Failed to collect inventory for machine ID generation, using fallback: %v", err)
+			return "", false, fmt.Errorf("failed to generate machine ID: %w", err)
+		}
 
-			// Fallback: usar informações básicas do sistema
-			basicInfo, err := a.collector.CollectBasicInfo()
-			if err != nil {
+		// Usar hostname como machine_id de fallback
+		return fmt.Sprintf("auto-%s", basicInfo.Hostname), false, nil
+	}
+
+	// Usar machine_id gerado pelo collector
+	return inventory.MachineID, true, nil
+}
+
+// startOwned inicia os componentes que coletam e enviam, depois de obtido o
+// lock da instância. generated é o machine_id já gerado no Start (vazio se
+// ainda não foi necessário). Chamado com a.mu travado.
+func (a *Agent) startOwned(generated string, reliable bool) error {
+	if a.config.MachineID == "" {
+		if generated == "" {
+			var err error
+			if generated, reliable, err = a.generateMachineID(); err != nil {
 				a.setState(StateError)
-				return fmt.Errorf("failed to generate machine ID: %w", err)
+				return err
 			}
-
-			// Usar hostname como machine_id de fallback
-			a.config.MachineID = fmt.Sprintf("auto-%s", basicInfo.Hostname)
-		} else {
-			// Usar machine_id gerado pelo collector
-			a.config.MachineID = inventory.MachineID
 		}
 
+		// O persistido prevalece; um gerado diferente entra em migração
+		a.config.MachineID = a.resolveIdentity(generated, reliable)
 		a.logger.Info("Generated machine ID: %s", a.config.MachineID)
 	} else {
 		a.logger.Info("Using configured machine ID: %s", a.config.MachineID)
 	}
 
-	// Inicializar executor
-	execConfig := &executor.Config{
-		DefaultTimeout: a.config.CommandTimeout,
-		MaxConcurrent:  10,
-		Logger:         a.logger,
+	// Machine_id regenerado após um conflito de registro anterior
+	a.initRegistration()
+	a.events.SetMachineID(a.currentMachineID())
+
+	if a.config.Offline {
+		// Sem backend os inventários vão para o arquivo local
+		archive, err := NewInventoryArchive(a.config.OfflineArchiveDir, a.config.OfflineArchiveSize)
+		if err != nil {
+			a.setState(StateError)
+			return err
+		}
+		a.archive = archive
+	} else {
+		// Token da máquina guardado ou obtido no enrollment (ver enrollment.go)
+		if err := a.initMachineCredential(); err != nil {
+			a.setState(StateError)
+			return err
+		}
+
+		// Inicializar ring de snapshots (falha não impede o agente de rodar)
+		snapshotDir := filepath.Join(a.config.DataDir, "snapshots")
+		if ring, err := NewSnapshotRing(snapshotDir, a.config.SnapshotRingSize, a.config.SnapshotCompressionLevel, a.config.SnapshotMaxBytes); err != nil {
+			a.logger.WithField("error", err).Warning("Inventory snapshot retention disabled")
+		} else {
+			a.snapshots = ring
+			a.snapshotOfferPending = true
+		}
+	}
+
+	// Ajustes do collector recebidos do backend em execuções anteriores
+	a.loadCollectorSettings()
+
+	// Agendamentos do backend persistidos e os do arquivo de configuração
+	a.scheduler = NewScheduler(filepath.Join(a.config.DataDir, schedulesFile), a.clock, a.logger, a.SubmitCommand)
+	if err := a.scheduler.SetSchedules(scheduleSourceConfig, a.config.Schedules); err != nil {
+		a.logger.WithField("error", err).Warning("Ignoring configured schedules")
 	}
+
+	// Sequência de inventários (falha apenas desativa o acompanhamento de
+	// atraso); sem backend não há atraso a acompanhar
+	if !a.config.Offline {
+		if seq, err := NewInventorySequence(a.config.DataDir, a.config.MachineID); err != nil {
+			a.logger.WithField("error", err).Warning("Inventory sequence tracking disabled")
+		} else {
+			a.inventorySeq = seq
+			a.checkBackendLag()
+		}
+	}
+
+	// Inicializar executor
+	execConfig := a.config.ExecutorConfig(a.logger)
+	execConfig.OnProgress = a.sendCommandProgress
+	execConfig.OnSecurityEvent = a.recordSecurityEvent
 	var err error
 	a.executor, err = executor.New(execConfig)
 	if err != nil {
 		a.setState(StateError)
 		return fmt.Errorf("failed to initialize executor: %w", err)
 	}
+	a.applyCustomCollectors()
+	a.capabilities = a.buildCapabilities()
+	a.alerts = a.newAlertMonitor()
 
-	// Inicializar communications manager
-	commConfig := &comms.Config{
-		BackendURL:        a.config.BackendURL,
-		WebSocketURL:      a.config.WebSocketURL,
-		Token:             a.config.Token,
-		MachineID:         a.config.MachineID,
-		RetryInterval:     a.config.RetryInterval,
-		HeartbeatInterval: a.config.HeartbeatInterval,
-		Logger:            a.logger,
-	}
-
-	a.comms, err = comms.New(commConfig)
-	if err != nil {
-		a.setState(StateError)
-		return fmt.Errorf("failed to initialize communications: %w", err)
+	// Inicializar communications manager; no modo offline a.comms() fica nil
+	if a.config.Offline {
+		a.logger.WithField("archive_dir", a.config.OfflineArchiveDir).Info("Running in offline mode, backend communication disabled")
+	} else {
+		a.initChaos()
+		manager, err := a.newComms(nil)
+		if err != nil {
+			a.setState(StateError)
+			return err
+		}
+		a.commsManager.Store(manager)
+		a.startTokenRefresh()
+		a.startLogShipping()
 	}
+	a.events.Start()
+	a.startAlerts()
 
 	// Marcar como running
 	a.setState(StateRunning)
 
+	// Sampler de CPU por processo e demais ajudantes do collector, iniciados
+	// no primeiro uso e encerrados em Stop
+	if err := a.collector.Start(a.ctx); err != nil {
+		a.setState(StateError)
+		return err
+	}
+
 	// Iniciar goroutines
-	a.wg.Add(5)
+	a.wg.Add(7)
 
 	// Goroutine para coleta de dados
-	go a.runCollector()
+	go a.runCollector(a.config.CollectionInterval)
 
 	// Goroutine para comunicações
-	go a.runCommunications()
+	if a.comms() != nil {
+		a.wg.Add(1)
+		go a.runCommunications()
+	}
 
 	// Goroutine para loop principal
 	go a.runMainLoop()
@@ -242,10 +503,113 @@ func (a *Agent) Start() error {
 	// Goroutine para tratamento de erros
 	go a.runErrorHandler()
 
-	a.logger.Info("Agent started successfully")
+	// Goroutine para transições de energia
+	go a.runPowerTracker()
+
+	// Goroutine que confirma a posse do lock da instância
+	go a.runInstanceLockGuard()
+
+	// Goroutine para comandos agendados
+	go a.runScheduler()
+
+	// Socket de controle local (falha não impede o agente de rodar)
+	if err := a.startControlServer(); err != nil {
+		a.logger.WithField("error", err).Warning("Control socket disabled")
+	}
+
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "agent_started", "Agent started successfully", map[string]interface{}{
+		"mode": a.mode(),
+	})
+	a.confirmPendingUpdate()
 	return nil
 }
 
+// comms retorna o communications manager vigente (nil antes do Start e no
+// modo observador)
+func (a *Agent) comms() *comms.Manager {
+	return a.commsManager.Load()
+}
+
+// newComms cria o communications manager a partir da configuração vigente;
+// usado no início e quando uma recarga troca backend ou token. queue é a
+// fila offline do manager substituído (nil carrega a do disco).
+func (a *Agent) newComms(queue *comms.MessageQueue) (*comms.Manager, error) {
+	envelope, err := a.newEnvelopeSealer()
+	if err != nil {
+		return nil, err
+	}
+	commConfig := &comms.Config{
+		BackendURL:        a.config.BackendURL,
+		WebSocketURL:      a.config.WebSocketURL,
+		Token:             a.config.Token,
+		Tokens:            a.config.Tokens,
+		BackendURLs:       a.config.BackendURLs,
+		WebSocketURLs:     a.config.WebSocketURLs,
+		FailoverThreshold: a.config.FailoverThreshold,
+		TLSFiles:          a.config.TLSFiles(),
+		ProxyURL:          a.config.ProxyURL,
+		MachineID:         a.config.MachineID,
+		InstanceID:        a.instanceID,
+		RetryInterval:     a.config.RetryInterval,
+		HeartbeatInterval: a.config.HeartbeatInterval,
+		Logger:            a.logger,
+		HeartbeatExtras:   a.heartbeatExtras,
+
+		TLSSkipVerify:          a.config.TLSSkipVerify,
+		PinnedCertificates:     a.config.PinnedCertificates,
+		LenientCommandDecoding: a.config.LenientCommandDecoding,
+		CommandLimits:          a.config.CommandLimits(),
+		SleepCovered:           a.power.CoveredBySleep,
+		SystemHealth:           a.health.Sample,
+		OnHeartbeatResponse:    a.handleHeartbeatResponse,
+		OnHeartbeatFailure:     a.handleHeartbeatFailure,
+		Breakers:               a.breakers,
+		OnCommand:              a.SubmitCommand,
+		OnRegistration:         a.handleRegistration,
+		Capabilities:           a.capabilities,
+		OnIdentityLinked:       a.completeIdentityMigration,
+		OnConfigUpdate:         a.handleConfigUpdate,
+		OnCommandCancel:        a.handleCommandCancel,
+		OnScheduleUpdate:       a.handleScheduleUpdate,
+		OnWhitelistUpdate:      a.handleWhitelistUpdate,
+		OnConnectionChange:     a.handleConnectionChange,
+		OnEndpointFailover:     a.handleEndpointFailover,
+		Clock:                  a.chaos.Clock(a.clock),
+		Chaos:                  a.chaos,
+		Envelope:               envelope,
+		EnableCompression:      a.config.HTTPCompression,
+		CompressionThreshold:   a.config.HTTPCompressionThreshold,
+		UploadRateLimit:        a.config.UploadRateLimit,
+		InventoryWindow:        a.inventoryWindow(),
+		QueuePath:              filepath.Join(a.config.DataDir, "offline_queue.json"),
+		Queue:                  queue,
+		WSMaxReconnects:        a.config.WSMaxReconnects,
+		WSMaxBackoff:           a.config.WSMaxBackoff,
+		WSMessageAcks:          a.config.WSMessageAcks,
+		WSMaxUnacked:           a.config.WSMaxUnacked,
+	}
+
+	// Depois do enrollment, só o token da máquina autentica
+	if token := a.machineTokenValue(); token != "" {
+		commConfig.Token, commConfig.Tokens = token, nil
+	}
+
+	manager, err := comms.New(commConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize communications: %w", err)
+	}
+	manager.SetNewMachineID(a.pendingNewMachineID())
+	a.tlsDigests = tlsFileDigests(a.config)
+	return manager, nil
+}
+
+// inventoryWindow retorna a janela de envio configurada (nil = sempre)
+func (a *Agent) inventoryWindow() *comms.SendWindow {
+	// Já validada em Validate
+	window, _ := comms.ParseSendWindow(a.config.InventorySendWindow)
+	return window
+}
+
 // Stop para o agente gracefully
 func (a *Agent) Stop() error {
 	a.mu.Lock()
@@ -256,7 +620,22 @@ func (a *Agent) Stop() error {
 	}
 
 	a.logger.Info("Stopping agent...")
+	observer := a.state == StateObserver
 	a.setState(StateStopping)
+	if !observer {
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, "agent_stopping", "Agent stopping", nil)
+	}
+
+	a.stopControlServer()
+	a.stopRegistrationRetry()
+	a.stopTokenRefresh()
+
+	// Comandos em execução saem como cancelados antes de a conexão cair
+	a.cancelInFlightCommands()
+
+	// Últimos lotes de log, enquanto a conexão existe (ou para a fila offline)
+	a.stopLogShipping()
+	a.stopAlerts()
 
 	// Cancelar contexto
 	a.cancel()
@@ -275,10 +654,24 @@ func (a *Agent) Stop() error {
 	select {
 	case <-done:
 		a.logger.Info("Agent stopped successfully")
-	case <-time.After(30 * time.Second):
+	case <-a.clock.After(30 * time.Second):
 		a.logger.Warning("Agent shutdown timeout - forcing stop")
 	}
 
+	// Para o sampler de CPU e aguarda coletas ainda em andamento
+	if a.collector != nil {
+		a.collector.Close()
+	}
+
+	// Entrega os eventos pendentes (o log local recebe todos)
+	a.events.Close()
+
+	if a.instanceLock != nil {
+		if err := a.instanceLock.Release(); err != nil {
+			a.logger.WithField("error", err).Warning("Failed to release instance lock")
+		}
+	}
+
 	a.setState(StateStopped)
 	return nil
 }
@@ -302,45 +695,78 @@ func (a *Agent) GetMetrics() *AgentMetrics {
 	a.metrics.mu.RLock()
 	defer a.metrics.mu.RUnlock()
 
+	depth, rejected, evicted := a.commandQueue.Stats()
+	trips, timeOpen := a.breakerTotals()
+
 	// Retornar cópia das métricas
 	return &AgentMetrics{
-		StartTime:          a.metrics.StartTime,
-		HeartbeatCount:     a.metrics.HeartbeatCount,
-		InventoryCount:     a.metrics.InventoryCount,
-		CommandsExecuted:   a.metrics.CommandsExecuted,
-		CommandsSuccessful: a.metrics.CommandsSuccessful,
-		CommandsFailed:     a.metrics.CommandsFailed,
-		LastHeartbeat:      a.metrics.LastHeartbeat,
-		LastInventory:      a.metrics.LastInventory,
-		LastCommand:        a.metrics.LastCommand,
-		ErrorCount:         a.metrics.ErrorCount,
-		RetryCount:         a.metrics.RetryCount,
-		ConnectionAttempts: a.metrics.ConnectionAttempts,
-		ConnectionFailures: a.metrics.ConnectionFailures,
+		StartTime:            a.metrics.StartTime,
+		HeartbeatCount:       a.metrics.HeartbeatCount,
+		InventoryCount:       a.metrics.InventoryCount,
+		CommandsExecuted:     a.metrics.CommandsExecuted,
+		CommandsSuccessful:   a.metrics.CommandsSuccessful,
+		CommandsFailed:       a.metrics.CommandsFailed,
+		LastHeartbeat:        a.metrics.LastHeartbeat,
+		LastInventory:        a.metrics.LastInventory,
+		LastCommand:          a.metrics.LastCommand,
+		ErrorCount:           a.metrics.ErrorCount,
+		RetryCount:           a.metrics.RetryCount,
+		ConnectionAttempts:   a.metrics.ConnectionAttempts,
+		ConnectionFailures:   a.metrics.ConnectionFailures,
+		RecentErrors:         append([]RecentError(nil), a.metrics.RecentErrors...),
+		CommandQueueDepth:    depth,
+		CommandsRejectedFull: rejected,
+		CommandsEvicted:      evicted,
+
+		CircuitBreakerTrips:    trips,
+		CircuitBreakerOpenTime: timeOpen,
 	}
 }
 
 // setState define o estado do agente
 func (a *Agent) setState(state AgentState) {
+	previous := a.state
 	a.state = state
 	a.logger.WithField("state", state.String()).Debug("Agent state changed")
+
+	if previous == state {
+		return
+	}
+	severity := events.SeverityInfo
+	if state == StateError {
+		severity = events.SeverityError
+	}
+	a.recordEvent(events.CategoryAgent, severity, "agent_state_changed", "Agent state changed", map[string]interface{}{
+		"from": previous.String(),
+		"to":   state.String(),
+	})
 }
 
-// runCollector executa o loop de coleta de dados
-func (a *Agent) runCollector() {
+// runCollector executa o loop de coleta de dados a cada interval (trocado
+// por collectionReset)
+func (a *Agent) runCollector(interval time.Duration) {
 	defer a.wg.Done()
 
 	a.logger.Info("Starting data collector...")
 
-	ticker := time.NewTicker(a.config.CollectionInterval)
-	defer ticker.Stop()
+	ticker := a.clock.NewTicker(interval)
+	defer func() { ticker.Stop() }()
 
 	for {
 		select {
 		case <-a.ctx.Done():
 			a.logger.Info("Collector stopped")
 			return
-		case <-ticker.C:
+		case interval := <-a.collectionReset:
+			// Intervalo trocado por recarga da configuração
+			ticker.Stop()
+			ticker = a.clock.NewTicker(interval)
+			a.logger.Debug("Collection interval changed to %v", interval)
+		case <-ticker.C():
+			if a.presence.Defer(a.ctx, deferTaskInventory) {
+				a.logger.Debug("Full inventory deferred while user is presenting")
+				continue
+			}
 			a.collectAndSendInventory()
 		}
 	}
@@ -352,7 +778,7 @@ func (a *Agent) runCommunications() {
 
 	a.logger.Info("Starting communications...")
 
-	if err := a.comms.Start(a.ctx); err != nil {
+	if err := a.comms().Start(a.ctx); err != nil {
 		a.logger.WithField("error", err).Error("Failed to start communications")
 		a.errorChan <- err
 		return
@@ -371,7 +797,7 @@ func (a *Agent) runMainLoop() {
 	// heartbeatTicker := time.NewTicker(a.config.HeartbeatInterval)
 	// defer heartbeatTicker.Stop()
 
-	healthCheckTicker := time.NewTicker(10 * time.Second)
+	healthCheckTicker := a.clock.NewTicker(10 * time.Second)
 	defer healthCheckTicker.Stop()
 
 	for {
@@ -381,7 +807,7 @@ func (a *Agent) runMainLoop() {
 			return
 		// case <-heartbeatTicker.C:
 		// 	a.sendHeartbeatWithRetry()
-		case <-healthCheckTicker.C:
+		case <-healthCheckTicker.C():
 			a.updateHealthStatus()
 		}
 	}
@@ -398,8 +824,14 @@ func (a *Agent) runCommandProcessor() {
 		case <-a.ctx.Done():
 			a.logger.Info("Command processor stopped")
 			return
-		case command := <-a.commandChan:
+		case <-a.commandQueue.Ready():
+			command, ok := a.commandQueue.pop()
+			if !ok {
+				continue
+			}
+			a.commandMu.Lock()
 			a.handleCommand(command)
+			a.commandMu.Unlock()
 		}
 	}
 }
@@ -423,54 +855,125 @@ func (a *Agent) runErrorHandler() {
 
 // collectAndSendInventory coleta e envia dados de inventário
 func (a *Agent) collectAndSendInventory() {
+	// Conflito de machine_id ou token recusado: os dados iriam para a máquina
+	// errada ou seriam rejeitados até o registro ser resolvido
+	if a.inventoryBlocked() {
+		a.logger.Debug("Inventory skipped: machine registration is blocked")
+		return
+	}
+
 	a.logger.Debug("Collecting and sending inventory...")
 
 	// Coletar dados do sistema
 	data, err := a.collector.CollectInventory()
 	if err != nil {
 		a.logger.WithField("error", err).Error("Failed to collect inventory data")
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "inventory_failed", "Inventory collection failed", map[string]interface{}{
+			"stage": "collect",
+			"error": err,
+		})
 		a.errorChan <- err
 		return
 	}
 
-	// Usar machine_id da configuração (que já foi resolvido no Start)
-	// Se o inventory não tiver machine_id, usar o da configuração
-	if data.MachineID == "" {
-		data.MachineID = a.config.MachineID
+	// Usar machine_id resolvido no Start se o inventory não tiver um; um
+	// machine_id regenerado após conflito ou persistido pelo agente (que pode
+	// estar em migração) sempre substitui o do collector
+	if machineID := a.currentMachineID(); data.MachineID == "" || a.registrationStatus().OriginalMachineID != "" || a.identity.managed {
+		data.MachineID = machineID
+	}
+
+	a.policyCount.Store(int64(data.PolicyCount()))
+
+	// Mudanças desde o inventário anterior, com as seções ainda completas
+	a.diffInventory(data)
+
+	// Cortes por tamanho/orçamento antes do snapshot, que guarda o que é enviado
+	a.planInventory(data)
+
+	if a.archive != nil {
+		a.archiveInventory(data)
+		return
+	}
+
+	// Guardar snapshot localmente independentemente do sucesso do envio
+	var snapshot SnapshotEntry
+	if a.snapshots != nil {
+		entry, err := a.snapshots.Add(data)
+		if err != nil {
+			a.logger.WithField("error", err).Warning("Failed to retain inventory snapshot")
+		}
+		snapshot = entry
 	}
 
 	// Enviar dados via communications
-	if err := a.sendInventoryWithRetry(data); err != nil {
+	err = a.sendInventoryWithRetry(data)
+	if errors.Is(err, comms.ErrDeferred) {
+		// Fora da janela de envio: o comms envia da fila quando ela abrir
+		a.logger.WithField("send_window", a.config.InventorySendWindow).Info("Inventory deferred until the send window opens")
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, "inventory_deferred", "Inventory deferred to the send window", map[string]interface{}{
+			"send_window": a.config.InventorySendWindow,
+		})
+		a.snapshotOfferPending = true
+		return
+	}
+	if err != nil {
 		a.logger.WithField("error", err).Error("Failed to send inventory data")
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "inventory_failed", "Inventory delivery failed", map[string]interface{}{
+			"stage": "send",
+			"error": err,
+		})
+		a.snapshotOfferPending = true
 		a.errorChan <- err
 		return
 	}
 
+	if a.snapshots != nil {
+		if snapshot.ID != "" {
+			a.snapshots.MarkSent(snapshot.ID)
+		}
+		a.offerSnapshotManifest()
+	}
+
 	// Atualizar métricas
 	a.metrics.mu.Lock()
 	a.metrics.InventoryCount++
-	a.metrics.LastInventory = time.Now()
+	a.metrics.LastInventory = a.clock.Now()
 	a.metrics.mu.Unlock()
 
 	a.logger.Debug("Inventory sent successfully")
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "inventory_sent", "Inventory sent", nil)
 }
 
 // sendInventoryWithRetry envia inventário com retry
 func (a *Agent) sendInventoryWithRetry(data *collector.InventoryData) error {
-	if !a.circuitBreaker.canExecute() {
-		return fmt.Errorf("circuit breaker is open")
+	// A mesma sequência e a mesma chave de idempotência são usadas em todas
+	// as tentativas deste inventário
+	idempotencyKey := comms.NewIdempotencyKey()
+	var sequence int64
+	if a.inventorySeq != nil {
+		next, err := a.inventorySeq.Next()
+		if err != nil {
+			a.logger.WithField("error", err).Warning("Failed to persist inventory sequence state")
+		}
+		sequence = next
 	}
 
 	err := a.retryWithBackoff(func() error {
-		return a.comms.SendInventory(data)
+		return a.comms().SendInventoryWithKey(data, sequence, idempotencyKey)
 	})
 
+	// O circuit breaker do inventário é atualizado pelo comms a cada envio
 	if err != nil {
-		a.circuitBreaker.recordFailure()
 		return err
 	}
 
-	a.circuitBreaker.recordSuccess()
+	if a.inventorySeq != nil {
+		if err := a.inventorySeq.MarkSent(sequence, a.clock.Now()); err != nil {
+			a.recordSequencePersistFailure(err)
+		}
+		a.checkBackendLag()
+	}
 	return nil
 }
 
@@ -479,17 +982,74 @@ func (a *Agent) handleCommand(command *comms.Command) {
 	a.logger.WithFields(map[string]interface{}{
 		"command_id":   command.ID,
 		"command_type": command.Type,
-		"command":      command.Command,
+		"command":      comms.Preview(command.Command),
 	}).Info("Processing command")
+	a.commandTypes.Store(command.ID, command.Type)
+	a.recordEvent(events.CategoryCommand, events.SeverityInfo, "command_received", "Command received", map[string]interface{}{
+		"command_id":   command.ID,
+		"command_type": command.Type,
+	})
+
+	// Campos com tipo incorreto: rejeitar em vez de executar com valores zerados
+	if command.DecodeError != nil {
+		status := comms.StatusRejected
+		var oversized *comms.OversizedCommandError
+		if errors.As(command.DecodeError, &oversized) {
+			status = comms.StatusRejectedOversized
+		}
+
+		result := &comms.CommandResult{
+			ID:        command.ID,
+			CommandID: command.ID,
+			Status:    status,
+			ExitCode:  -1,
+			Timestamp: a.clock.Now(),
+			Warnings:  command.DecodeWarnings,
+		}
+		result.SetError(command.DecodeError)
+		a.executor.RecordAudit(command, result)
+		a.sendCommandResult(result)
+		return
+	}
+
+	// Comandos marcados como adiáveis esperam o fim da apresentação
+	if deferrable, _ := command.Options["deferrable"].(bool); deferrable {
+		task := deferTaskCommandPrefix + command.ID
+		first := !a.presence.Deferring(task)
+		if a.presence.Defer(a.ctx, task) {
+			a.deferCommand(command, first)
+			return
+		}
+	}
+
+	if len(command.DecodeWarnings) > 0 {
+		a.commandWarnings.Store(command.ID, command.DecodeWarnings)
+	}
+
+	// Comandos que dependem do comms manager, não do executor
+	if backendCommandTypes[command.Type] && a.comms() == nil {
+		a.rejectOfflineCommand(command)
+		return
+	}
+	if handler, ok := agentCommandHandlers[command.Type]; ok {
+		handler(a, command)
+		return
+	}
 
 	// Verificar se o comando é suportado
 	if !a.executor.IsSupported(command) {
 		a.logger.WithField("command_type", command.Type).Warning("Unsupported command type")
 		result := &comms.CommandResult{
+			ID:        command.ID,
 			CommandID: command.ID,
-			Status:    "error",
-			Error:     fmt.Sprintf("Unsupported command type: %s", command.Type),
+			Status:    comms.StatusRejected,
 		}
+		result.SetError(comms.NewCodedError(comms.ErrCodeUnsupportedCommandType, command.Type))
+		// Este caminho já enviava o texto em inglês
+		result.LegacyError = fmt.Sprintf("Unsupported command type: %s", command.Type)
+		// Reenviar as capacidades para o backend corrigir o que envia
+		result.Capabilities = a.capabilities
+		a.executor.RecordAudit(command, result)
 		a.sendCommandResult(result)
 		return
 	}
@@ -503,7 +1063,7 @@ func (a *Agent) handleCommand(command *comms.Command) {
 	// Atualizar métricas
 	a.metrics.mu.Lock()
 	a.metrics.CommandsExecuted++
-	a.metrics.LastCommand = time.Now()
+	a.metrics.LastCommand = a.clock.Now()
 	if err != nil {
 		a.metrics.CommandsFailed++
 	} else {
@@ -525,9 +1085,220 @@ func (a *Agent) handleCommand(command *comms.Command) {
 	}
 }
 
+// handleDiagnoseCommand executa o diagnóstico de conectividade e envia o relatório JSON
+func (a *Agent) handleDiagnoseCommand(command *comms.Command) {
+	startTime := a.clock.Now()
+
+	stepTimeout := 5 * time.Second
+	if command.Timeout > 0 {
+		stepTimeout = time.Duration(command.Timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, a.executor.GetTimeout()+stepTimeout)
+	defer cancel()
+
+	report := a.comms().Diagnose(ctx, stepTimeout)
+
+	result := &comms.CommandResult{
+		ID:            command.ID,
+		CommandID:     command.ID,
+		Status:        comms.StatusRunning,
+		ExecutionTime: a.clock.Since(startTime).Milliseconds(),
+		Timestamp:     a.clock.Now(),
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		_ = result.SetStatus(comms.StatusError)
+		result.SetError(err)
+	} else {
+		_ = result.SetStatus(comms.StatusSuccess)
+		result.Output = string(output)
+	}
+	if !report.OK() {
+		result.ExitCode = 1
+	}
+
+	// Relatório com falha ou que não serializou conta como comando falho,
+	// igual ao resultado enviado
+	a.metrics.mu.Lock()
+	a.metrics.CommandsExecuted++
+	a.metrics.LastCommand = a.clock.Now()
+	if err != nil || !report.OK() {
+		a.metrics.CommandsFailed++
+	} else {
+		a.metrics.CommandsSuccessful++
+	}
+	a.metrics.mu.Unlock()
+
+	a.sendCommandResult(result)
+}
+
+// offerSnapshotManifest envia o manifesto de snapshots após uma reconexão
+// (ou reinício), para que o backend possa pedir os períodos perdidos
+func (a *Agent) offerSnapshotManifest() {
+	if !a.snapshotOfferPending || !a.snapshots.HasUnsent() {
+		a.snapshotOfferPending = false
+		return
+	}
+
+	if err := a.comms().SendSnapshotManifest(a.snapshots.Manifest()); err != nil {
+		a.logger.WithField("error", err).Warning("Failed to offer snapshot manifest")
+		return
+	}
+
+	a.snapshotOfferPending = false
+	a.logger.Info("Snapshot manifest offered to backend")
+}
+
+// handleRequestSnapshot envia um snapshot retido pelo caminho de upload de artefatos.
+// O ID vem de Args[0] ou Options["snapshot_id"].
+func (a *Agent) handleRequestSnapshot(command *comms.Command) {
+	startTime := a.clock.Now()
+
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRunning,
+	}
+
+	finish := func(status comms.CommandStatus, output string, err error) {
+		_ = result.SetStatus(status)
+		result.Output = output
+		result.SetError(err)
+		result.ExecutionTime = a.clock.Since(startTime).Milliseconds()
+		result.Timestamp = a.clock.Now()
+		a.sendCommandResult(result)
+	}
+
+	snapshotID := ""
+	if len(command.Args) > 0 {
+		snapshotID = command.Args[0]
+	} else if id, ok := command.Options["snapshot_id"].(string); ok {
+		snapshotID = id
+	}
+
+	if a.snapshots == nil {
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeSnapshotsDisabled))
+		return
+	}
+	if snapshotID == "" {
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeSnapshotIDRequired))
+		return
+	}
+
+	entry, data, err := a.snapshots.Get(snapshotID)
+	if err != nil {
+		finish(comms.StatusError, "", err)
+		return
+	}
+
+	artifact := &comms.Artifact{
+		Kind:        "inventory_snapshot",
+		Name:        entry.ID,
+		ContentType: "application/json",
+		Encoding:    "gzip",
+		Checksum:    entry.Checksum,
+		Data:        data,
+		Metadata: map[string]interface{}{
+			"snapshot_timestamp": entry.Timestamp,
+			"command_id":         command.ID,
+		},
+	}
+
+	if err := a.comms().UploadArtifact(artifact); err != nil {
+		finish(comms.StatusError, "", err)
+		return
+	}
+
+	a.snapshots.MarkSent(entry.ID)
+	finish(comms.StatusSuccess, fmt.Sprintf("snapshot %s uploaded (%d bytes)", entry.ID, len(data)), nil)
+}
+
+// handleCommandCancel atende um command_cancel do backend; o resultado
+// cancelado sai pelo fluxo normal do comando
+func (a *Agent) handleCommandCancel(cancel comms.CommandCancel) {
+	reason := cancel.Reason
+	if reason == "" {
+		reason = "cancelled by backend"
+	}
+
+	logger := a.logger.WithFields(map[string]interface{}{
+		"command_id": cancel.CommandID,
+		"reason":     reason,
+	})
+	if a.executor == nil || !a.executor.Cancel(cancel.CommandID, reason) {
+		logger.Warning("Cancel requested for a command that is not running")
+		return
+	}
+	logger.Info("Command cancelled by backend")
+}
+
+// cancelInFlightCommands cancela os comandos em execução e aguarda, por até
+// commandCancelGrace, o processador enviar os resultados
+func (a *Agent) cancelInFlightCommands() {
+	if a.executor == nil {
+		return
+	}
+
+	cancelled := a.executor.CancelAll("agent shutting down")
+	if len(cancelled) == 0 {
+		return
+	}
+	a.logger.WithField("commands", cancelled).Info("Cancelling in-flight commands")
+
+	done := make(chan struct{})
+	go func() {
+		a.commandMu.Lock()
+		a.commandMu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-a.clock.After(commandCancelGrace):
+		a.logger.Warning("Timed out waiting for cancelled command results")
+	}
+}
+
+// runningCommands lista os command_ids em execução para o health
+func (a *Agent) runningCommands() []string {
+	if a.executor == nil {
+		return []string{}
+	}
+	return a.executor.Running()
+}
+
+// sendCommandProgress repassa ao backend um trecho parcial da saída de um
+// comando com options.stream; frames perdidos não são reenviados
+func (a *Agent) sendCommandProgress(progress *comms.CommandResult) {
+	manager := a.comms()
+	if manager == nil {
+		return
+	}
+	if err := manager.SendCommandProgress(progress); err != nil {
+		a.logger.WithFields(map[string]interface{}{
+			"command_id": progress.CommandID,
+			"offset":     progress.Offset,
+			"error":      err,
+		}).Debug("Command progress not sent")
+	}
+}
+
 // sendCommandResult envia resultado do comando
 func (a *Agent) sendCommandResult(result *comms.CommandResult) {
-	if err := a.comms.SendCommandResult(result); err != nil {
+	if warnings, ok := a.commandWarnings.LoadAndDelete(result.CommandID); ok {
+		result.Warnings = append(result.Warnings, warnings.([]string)...)
+	}
+	a.tagScheduledResult(result)
+
+	a.recordCommandResult(result)
+
+	// No modo offline o resultado fica apenas no pipeline de eventos
+	if a.comms() == nil {
+		return
+	}
+	if err := a.comms().SendCommandResult(result); err != nil {
 		a.logger.WithFields(map[string]interface{}{
 			"command_id": result.CommandID,
 			"error":      err,
@@ -536,6 +1307,50 @@ func (a *Agent) sendCommandResult(result *comms.CommandResult) {
 	}
 }
 
+// recordCommandResult publica o registro de execução do comando no pipeline
+// de eventos (sem a saída, que vai apenas no resultado ao backend)
+func (a *Agent) recordCommandResult(result *comms.CommandResult) {
+	fields := map[string]interface{}{
+		"command_id":        result.CommandID,
+		"status":            string(result.Status),
+		"exit_code":         result.ExitCode,
+		"execution_time_ms": result.ExecutionTime,
+		"output_bytes":      len(result.Output),
+	}
+
+	var commandType interface{}
+	var ok bool
+	switch result.Status {
+	case comms.StatusScheduled, comms.StatusRunning:
+		commandType, ok = a.commandTypes.Load(result.CommandID)
+	default:
+		commandType, ok = a.commandTypes.LoadAndDelete(result.CommandID)
+	}
+	if ok {
+		fields["command_type"] = commandType
+	}
+	if result.ErrorCode != "" {
+		fields["error_code"] = string(result.ErrorCode)
+	}
+	if result.ScheduleID != "" {
+		fields["schedule_id"] = result.ScheduleID
+	}
+	if result.Error != "" {
+		fields["error"] = result.Error
+	}
+	if len(result.Warnings) > 0 {
+		fields["warnings"] = result.Warnings
+	}
+
+	severity := events.SeverityInfo
+	switch result.Status {
+	case comms.StatusSuccess, comms.StatusScheduled, comms.StatusRunning, comms.StatusCancelled:
+	default:
+		severity = events.SeverityWarning
+	}
+	a.recordEvent(events.CategoryCommand, severity, "command_executed", "Command result recorded", fields)
+}
+
 // handleError trata erros do agente
 func (a *Agent) handleError(err error) {
 	a.logger.WithField("error", err).Error("Handling agent error")
@@ -543,6 +1358,10 @@ func (a *Agent) handleError(err error) {
 	// Atualizar métricas
 	a.metrics.mu.Lock()
 	a.metrics.ErrorCount++
+	a.metrics.RecentErrors = append(a.metrics.RecentErrors, RecentError{Timestamp: a.clock.Now(), Message: err.Error()})
+	if len(a.metrics.RecentErrors) > maxRecentErrors {
+		a.metrics.RecentErrors = a.metrics.RecentErrors[len(a.metrics.RecentErrors)-maxRecentErrors:]
+	}
 	a.metrics.mu.Unlock()
 
 	// Aqui pode implementar lógica específica de tratamento de erro
@@ -551,19 +1370,7 @@ func (a *Agent) handleError(err error) {
 
 // updateHealthStatus atualiza o status de saúde do sistema
 func (a *Agent) updateHealthStatus() {
-	// TODO: Implementar coleta real de métricas de saúde
-	a.healthStatus.CPUUsage = 25.0    // Simulado
-	a.healthStatus.MemoryUsage = 60.0 // Simulado
-	a.healthStatus.DiskUsage = 45.0   // Simulado
-
-	// Determinar status geral
-	if a.healthStatus.CPUUsage > 80 || a.healthStatus.MemoryUsage > 90 || a.healthStatus.DiskUsage > 95 {
-		a.healthStatus.Status = "critical"
-	} else if a.healthStatus.CPUUsage > 60 || a.healthStatus.MemoryUsage > 80 || a.healthStatus.DiskUsage > 85 {
-		a.healthStatus.Status = "warning"
-	} else {
-		a.healthStatus.Status = "healthy"
-	}
+	*a.healthStatus = a.health.Sample()
 }
 
 // retryWithBackoff executa uma função com retry e backoff exponencial
@@ -590,7 +1397,7 @@ func (a *Agent) retryWithBackoff(fn func() error) error {
 			a.metrics.mu.Unlock()
 
 			select {
-			case <-time.After(backoff):
+			case <-a.clock.After(backoff):
 			case <-a.ctx.Done():
 				return a.ctx.Err()
 			}
@@ -606,6 +1413,11 @@ func (a *Agent) retryWithBackoff(fn func() error) error {
 		if lastErr == nil {
 			return nil
 		}
+		// A mensagem ficou na fila offline do comms, que cuida do reenvio;
+		// com o circuito aberto, só o probe do comms pode liberar o envio
+		if errors.Is(lastErr, comms.ErrSpooled) || errors.Is(lastErr, comms.ErrCircuitOpen) {
+			return lastErr
+		}
 
 		a.logger.WithFields(map[string]interface{}{
 			"attempt": attempt,
@@ -616,56 +1428,6 @@ func (a *Agent) retryWithBackoff(fn func() error) error {
 	return fmt.Errorf("operation failed after %d attempts: %w", a.retryConfig.MaxRetries, lastErr)
 }
 
-// canExecute verifica se o circuit breaker permite execução
-func (cb *CircuitBreaker) canExecute() bool {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	now := time.Now()
-
-	switch cb.state {
-	case "closed":
-		return true
-	case "open":
-		if now.Sub(cb.lastFailureTime) > cb.config.ResetTimeout {
-			cb.state = "half-open"
-			cb.halfOpenCalls = 0
-			return true
-		}
-		return false
-	case "half-open":
-		return cb.halfOpenCalls < cb.config.HalfOpenMaxCalls
-	default:
-		return false
-	}
-}
-
-// recordSuccess registra um sucesso no circuit breaker
-func (cb *CircuitBreaker) recordSuccess() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	cb.failures = 0
-	if cb.state == "half-open" {
-		cb.state = "closed"
-	}
-}
-
-// recordFailure registra uma falha no circuit breaker
-func (cb *CircuitBreaker) recordFailure() {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
-	cb.failures++
-	cb.lastFailureTime = time.Now()
-
-	if cb.state == "half-open" {
-		cb.state = "open"
-	} else if cb.failures >= cb.config.FailureThreshold {
-		cb.state = "open"
-	}
-}
-
 // Health retorna informações de saúde do agente
 func (a *Agent) Health() map[string]interface{} {
 	a.mu.RLock()
@@ -673,11 +1435,22 @@ func (a *Agent) Health() map[string]interface{} {
 
 	metrics := a.GetMetrics()
 
-	return map[string]interface{}{
+	// O heartbeat é enviado pelo communications manager
+	lastHeartbeat := metrics.LastHeartbeat
+	connected := false
+	if a.comms() != nil {
+		if sent := a.comms().GetMetrics().LastHeartbeatTime; sent.After(lastHeartbeat) {
+			lastHeartbeat = sent
+		}
+		connected = a.comms().IsConnected()
+	}
+
+	health := map[string]interface{}{
 		"state":               a.state.String(),
-		"machine_id":          a.config.MachineID,
+		"mode":                a.mode(),
+		"machine_id":          a.currentMachineID(),
 		"backend_url":         a.config.BackendURL,
-		"uptime":              time.Since(metrics.StartTime).String(),
+		"uptime":              timeutil.FormatDurationHuman(a.clock.Since(metrics.StartTime)),
 		"heartbeat_count":     metrics.HeartbeatCount,
 		"inventory_count":     metrics.InventoryCount,
 		"commands_executed":   metrics.CommandsExecuted,
@@ -685,19 +1458,144 @@ func (a *Agent) Health() map[string]interface{} {
 		"commands_failed":     metrics.CommandsFailed,
 		"error_count":         metrics.ErrorCount,
 		"retry_count":         metrics.RetryCount,
-		"last_heartbeat":      metrics.LastHeartbeat.Format(time.RFC3339),
+		"last_heartbeat":      lastHeartbeat.Format(time.RFC3339),
 		"last_inventory":      metrics.LastInventory.Format(time.RFC3339),
 		"system_health":       a.healthStatus,
-		"circuit_breaker":     a.circuitBreaker.state,
+		"snapshot_disk_bytes": a.snapshotDiskUsage(),
+		"power_events":        a.power.Events(),
+		"circuit_breaker":     a.breakers.State(),
+		"circuit_breakers":    a.breakers.Statuses(),
+		"connected":           connected,
+		"queue_depth":         a.commandQueue.Depth(),
+		"command_queue":       a.commandQueue.Status(),
+		"running_commands":    a.runningCommands(),
+		"schedules":           a.schedulesStatus(),
+		"whitelist":           a.whitelistStatus(),
+		"heartbeat_interval":  a.config.HeartbeatInterval.Seconds(),
+		"recent_errors":       metrics.RecentErrors,
+		"backend_lag":         a.backendLagStatus(),
+		"presence":            a.presence.Status(),
+		"registration":        a.registrationStatus(),
+		"identity":            a.identityStatus(),
+		"tokens":              a.tokenStatus(),
+		"endpoints":           a.endpointStatus(),
+		"machine_credential":  a.machineCredentialStatus(),
+		"event_sinks":         a.events.Stats(),
+		"log_shipping":        a.logShippingStatus(),
+		"instance":            a.instanceStatus(),
+		"compression":         a.compressionStatus(),
+		"chaos":               a.chaos.Status(),
+		"envelope":            a.envelopeStatus(),
+		"collector_settings":  a.collectorSettingsStatus(),
+		"applied_config":      a.appliedConfig(),
+		"config_reloads":      a.reloads.Status(),
+	}
+	if a.config.Offline {
+		for _, key := range offlineOmittedHealthKeys {
+			delete(health, key)
+		}
+		health["offline_archive"] = a.archiveStatus()
 	}
+	return health
 }
 
-// SubmitCommand submete um comando para execução
-func (a *Agent) SubmitCommand(command *comms.Command) error {
-	select {
-	case a.commandChan <- command:
+// endpointStatus descreve os endpoints do backend e os ativos
+func (a *Agent) endpointStatus() map[string]comms.EndpointStatus {
+	if a.comms() == nil {
 		return nil
-	default:
-		return fmt.Errorf("command queue is full")
 	}
+	return a.comms().EndpointStatus()
+}
+
+// compressionStatus descreve a codificação negociada e as estatísticas de
+// compressão por codificação
+func (a *Agent) compressionStatus() map[string]interface{} {
+	if a.comms() == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"encoding": a.comms().Encoding(),
+		"stats":    a.comms().CompressionStats(),
+	}
+}
+
+// heartbeatExtras monta os campos adicionais do heartbeat
+func (a *Agent) heartbeatExtras() map[string]interface{} {
+	extras := a.powerHeartbeatExtras()
+
+	if count := a.policyCount.Load(); count >= 0 {
+		if extras == nil {
+			extras = make(map[string]interface{})
+		}
+		extras["policy_count"] = count
+	}
+
+	if a.config.PresencePolicy != PresencePolicyOff {
+		if extras == nil {
+			extras = make(map[string]interface{})
+		}
+		extras["presence_deferral"] = a.presence.Status()
+	}
+
+	if top, ok := a.collector.CPUSampler().Top(); ok {
+		if extras == nil {
+			extras = make(map[string]interface{})
+		}
+		extras["top_sustained_process"] = top
+	}
+
+	// Versão da whitelist vigente (0 = só a embutida), para o backend saber
+	// quais máquinas já aplicaram a última atualização
+	if status := a.whitelistStatus(); status != nil {
+		if extras == nil {
+			extras = make(map[string]interface{})
+		}
+		extras["whitelist_version"] = status.Version
+	}
+
+	return extras
+}
+
+// SubmitCommand submete um comando para execução. Os limites de entrada são
+// verificados aqui também, pois comandos reenfileirados não passam pela
+// decodificação; o comando acima do limite segue com DecodeError para ser
+// reportado como rejected_oversized. Com a fila cheia, o comando entra no
+// lugar do mais antigo de prioridade menor ou é recusado
+// (ErrCommandQueueFull); o descartado recebe um resultado rejected com
+// código queue_full.
+func (a *Agent) SubmitCommand(command *comms.Command) error {
+	if command.DecodeError == nil {
+		if err := comms.CheckCommandLimits(command, a.config.CommandLimits()); err != nil {
+			fields := map[string]interface{}{
+				"command_id": command.ID,
+				"error":      err,
+			}
+			var oversized *comms.OversizedCommandError
+			if errors.As(err, &oversized) && oversized.Preview != "" {
+				fields["preview"] = oversized.Preview
+			}
+			a.logger.WithFields(fields).Warning("Oversized command submitted")
+			command.Args = nil
+			command.Options = nil
+			command.DecodeError = err
+		}
+	}
+
+	evicted, err := a.commandQueue.push(command)
+	if err != nil {
+		a.rejectQueueFull(command, "rejected")
+		return err
+	}
+	if evicted != nil {
+		a.rejectQueueFull(evicted, "evicted")
+	}
+	return nil
+}
+
+// snapshotDiskUsage retorna o espaço em disco ocupado pelos snapshots retidos
+func (a *Agent) snapshotDiskUsage() int64 {
+	if a.snapshots == nil {
+		return 0
+	}
+	return a.snapshots.DiskUsage()
 }
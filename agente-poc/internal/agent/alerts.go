@@ -0,0 +1,322 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/timeutil"
+)
+
+// defaultAlertDebounce é o intervalo entre disparos de regras sem debounce
+const defaultAlertDebounce = 30 * time.Minute
+
+// alertWebhookTimeout limita cada POST de uma ação webhook
+const alertWebhookTimeout = 10 * time.Second
+
+// AlertRuleConfig é um item de alert_rules: uma condição local avaliada a
+// cada 15s e as ações executadas quando ela dispara. CPU, memória e disco
+// usam percentuais (0 a 100); memory_pressure o nível mínimo (1 warning, 2
+// critical); heartbeat_failures o número de heartbeats seguidos com falha.
+type AlertRuleConfig struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name,omitempty"`
+	Condition string  `json:"condition"`
+	Threshold float64 `json:"threshold"`
+	// Sustain é por quanto tempo a condição precisa se manter antes do
+	// disparo; Debounce é o intervalo mínimo entre dois disparos (zero =
+	// defaultAlertDebounce)
+	Sustain  timeutil.Seconds `json:"sustain,omitempty"`
+	Debounce timeutil.Seconds `json:"debounce,omitempty"`
+	// Severity é info, warning (padrão), error ou critical
+	Severity string              `json:"severity,omitempty"`
+	Disabled bool                `json:"disabled,omitempty"`
+	Actions  []comms.AlertAction `json:"actions,omitempty"`
+}
+
+// alertPercentConditions são as condições configuradas em percentual; o
+// Monitor trabalha com frações
+var alertPercentConditions = map[string]bool{
+	comms.AlertConditionCPUUsage:    true,
+	comms.AlertConditionMemoryUsage: true,
+	comms.AlertConditionDiskUsage:   true,
+}
+
+// DefaultAlertRules são as regras usadas quando alert_rules não aparece na
+// configuração; sem ações, os disparos ficam no log de eventos
+func DefaultAlertRules() []AlertRuleConfig {
+	return []AlertRuleConfig{
+		{
+			ID: "disk_full", Name: "Disk almost full",
+			Condition: comms.AlertConditionDiskUsage, Threshold: 95,
+			Severity: events.SeverityCritical, Debounce: timeutil.Seconds(time.Hour),
+		},
+		{
+			ID: "memory_high", Name: "High memory usage",
+			Condition: comms.AlertConditionMemoryUsage, Threshold: 90,
+			Sustain: timeutil.Seconds(5 * time.Minute), Severity: events.SeverityWarning,
+		},
+		{
+			ID: "cpu_sustained", Name: "Sustained high CPU usage",
+			Condition: comms.AlertConditionCPUUsage, Threshold: 90,
+			Sustain: timeutil.Seconds(10 * time.Minute), Severity: events.SeverityWarning,
+		},
+		{
+			ID: "heartbeat_failures", Name: "Heartbeats failing",
+			Condition: comms.AlertConditionHeartbeatFailures, Threshold: 5,
+			Severity: events.SeverityError,
+		},
+	}
+}
+
+// Validate verifica a regra e suas ações
+func (r *AlertRuleConfig) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("alert rule without id")
+	}
+	switch r.Condition {
+	case comms.AlertConditionCPUUsage, comms.AlertConditionMemoryUsage, comms.AlertConditionDiskUsage:
+		if r.Threshold <= 0 || r.Threshold >= 100 {
+			return fmt.Errorf("alert rule %s: threshold must be a percentage between 0 and 100", r.ID)
+		}
+	case comms.AlertConditionMemoryPressure:
+		if r.Threshold != 1 && r.Threshold != 2 {
+			return fmt.Errorf("alert rule %s: memory_pressure threshold must be 1 (warning) or 2 (critical)", r.ID)
+		}
+	case comms.AlertConditionHeartbeatFailures:
+		if r.Threshold < 1 {
+			return fmt.Errorf("alert rule %s: heartbeat_failures threshold must be at least 1", r.ID)
+		}
+	default:
+		return fmt.Errorf("alert rule %s: condition must be cpu_usage, memory_usage, memory_pressure, disk_usage or heartbeat_failures", r.ID)
+	}
+	if r.Sustain < 0 || r.Debounce < 0 {
+		return fmt.Errorf("alert rule %s: sustain and debounce must not be negative", r.ID)
+	}
+	switch r.Severity {
+	case "", events.SeverityInfo, events.SeverityWarning, events.SeverityError, events.SeverityCritical:
+	default:
+		return fmt.Errorf("alert rule %s: severity must be info, warning, error or critical", r.ID)
+	}
+
+	for _, action := range r.Actions {
+		switch action.Type {
+		case comms.AlertActionBackend:
+		case comms.AlertActionWebhook:
+			parsed, err := url.Parse(action.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("alert rule %s: webhook action needs an http or https url", r.ID)
+			}
+		case comms.AlertActionCommand:
+			if action.Command == "" {
+				return fmt.Errorf("alert rule %s: command action needs a command", r.ID)
+			}
+		default:
+			return fmt.Errorf("alert rule %s: action type must be backend, webhook or command", r.ID)
+		}
+	}
+	return nil
+}
+
+// ValidateAlertRules valida a lista e recusa IDs repetidos
+func ValidateAlertRules(rules []AlertRuleConfig) error {
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return err
+		}
+		if seen[rules[i].ID] {
+			return fmt.Errorf("duplicate alert rule id: %s", rules[i].ID)
+		}
+		seen[rules[i].ID] = true
+	}
+	return nil
+}
+
+// monitorAlertRules converte as regras da configuração (nil = padrão) para o
+// Monitor
+func monitorAlertRules(configured []AlertRuleConfig) []comms.AlertRule {
+	if configured == nil {
+		configured = DefaultAlertRules()
+	}
+
+	rules := make([]comms.AlertRule, 0, len(configured))
+	for _, r := range configured {
+		rule := comms.AlertRule{
+			ID:        r.ID,
+			Name:      r.Name,
+			Condition: r.Condition,
+			Threshold: r.Threshold,
+			Duration:  r.Debounce.Duration(),
+			Sustain:   r.Sustain.Duration(),
+			Severity:  r.Severity,
+			Enabled:   !r.Disabled,
+			Actions:   r.Actions,
+		}
+		if rule.Name == "" {
+			rule.Name = rule.ID
+		}
+		if rule.Duration == 0 {
+			rule.Duration = defaultAlertDebounce
+		}
+		if rule.Severity == "" {
+			rule.Severity = events.SeverityWarning
+		}
+		if alertPercentConditions[rule.Condition] {
+			rule.Threshold /= 100
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// newAlertMonitor cria o monitor das regras de alerta, alimentado pela mesma
+// amostra de saúde dos heartbeats
+func (a *Agent) newAlertMonitor() *comms.Monitor {
+	return comms.NewMonitor(comms.MonitorConfig{
+		Logger:        a.logger,
+		AlertRules:    monitorAlertRules(a.config.AlertRules),
+		SystemMetrics: a.health.Sample,
+		OnAlert:       a.handleAlert,
+		Endpoints:     a.breakers.Statuses,
+		Clock:         a.clock,
+	})
+}
+
+// startAlerts inicia a avaliação das regras (falha não impede o agente de rodar)
+func (a *Agent) startAlerts() {
+	if a.alerts == nil {
+		return
+	}
+	if err := a.alerts.Start(); err != nil {
+		a.logger.WithField("error", err).Warning("Local alerting disabled")
+	}
+}
+
+// stopAlerts encerra a avaliação das regras
+func (a *Agent) stopAlerts() {
+	if a.alerts != nil {
+		_ = a.alerts.Stop()
+	}
+}
+
+// recordHeartbeat alimenta a regra heartbeat_failures
+func (a *Agent) recordHeartbeat(success bool) {
+	if a.alerts != nil {
+		a.alerts.RecordHeartbeat(success)
+	}
+}
+
+// handleHeartbeatFailure recebe os heartbeats que falharam
+func (a *Agent) handleHeartbeatFailure(err error) {
+	a.recordHeartbeat(false)
+}
+
+// handleAlert registra o disparo no log de eventos e executa as ações da
+// regra, em sequência, no loop dos alertas
+func (a *Agent) handleAlert(alert comms.Alert, actions []comms.AlertAction) {
+	alert.MachineID = a.currentMachineID()
+	if alertPercentConditions[alert.Condition] {
+		alert.Value = math.Round(alert.Value*10000) / 100
+		alert.Threshold = math.Round(alert.Threshold*10000) / 100
+	}
+
+	a.recordEvent(events.CategoryAlert, alert.Severity, "alert_triggered", "Alert rule triggered", map[string]interface{}{
+		"rule_id":   alert.RuleID,
+		"rule_name": alert.RuleName,
+		"condition": alert.Condition,
+		"value":     alert.Value,
+		"threshold": alert.Threshold,
+		"actions":   len(actions),
+	})
+
+	for _, action := range actions {
+		if err := a.runAlertAction(alert, action); err != nil {
+			a.logger.WithFields(map[string]interface{}{
+				"rule_id": alert.RuleID,
+				"action":  action.Type,
+				"error":   err.Error(),
+			}).Warning("Alert action failed")
+			a.recordEvent(events.CategoryAlert, events.SeverityWarning, "alert_action_failed", "Alert action failed", map[string]interface{}{
+				"rule_id": alert.RuleID,
+				"action":  action.Type,
+				"error":   err,
+			})
+		}
+	}
+}
+
+// runAlertAction executa uma ação de um disparo
+func (a *Agent) runAlertAction(alert comms.Alert, action comms.AlertAction) error {
+	switch action.Type {
+	case comms.AlertActionBackend:
+		manager := a.comms()
+		if manager == nil {
+			return fmt.Errorf("no backend connection")
+		}
+		return manager.SendAlert(alert)
+	case comms.AlertActionWebhook:
+		return postAlertWebhook(a.ctx, action.URL, alert)
+	case comms.AlertActionCommand:
+		return a.runAlertCommand(alert, action)
+	}
+	return fmt.Errorf("unknown alert action type: %s", action.Type)
+}
+
+// postAlertWebhook envia o alerta em JSON para url; respostas fora de 2xx
+// contam como falha
+func postAlertWebhook(ctx context.Context, url string, alert comms.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, alertWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// runAlertCommand roda o comando da ação pelo executor, sujeito à whitelist
+// como os comandos do backend
+func (a *Agent) runAlertCommand(alert comms.Alert, action comms.AlertAction) error {
+	if a.executor == nil {
+		return fmt.Errorf("executor not initialized")
+	}
+
+	command := &comms.Command{
+		ID:        fmt.Sprintf("alert-%s-%d", alert.RuleID, alert.Timestamp.UnixNano()),
+		Type:      "shell",
+		Command:   action.Command,
+		Args:      action.Args,
+		Timestamp: alert.Timestamp,
+	}
+	result, err := a.executor.Execute(a.ctx, command)
+	if err != nil {
+		return err
+	}
+	if result.Status != comms.StatusSuccess {
+		return fmt.Errorf("command finished with status %s: %s", result.Status, result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/executor"
+)
+
+// alertReceiver conta os alertas recebidos por regra (backend ou webhook)
+type alertReceiver struct {
+	server *httptest.Server
+
+	mu     sync.Mutex
+	alerts map[string][]comms.Alert
+}
+
+func newAlertReceiver(t *testing.T, path string) *alertReceiver {
+	t.Helper()
+	receiver := &alertReceiver{alerts: make(map[string][]comms.Alert)}
+	receiver.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == path {
+			var alert comms.Alert
+			if json.NewDecoder(r.Body).Decode(&alert) == nil {
+				receiver.mu.Lock()
+				receiver.alerts[alert.RuleID] = append(receiver.alerts[alert.RuleID], alert)
+				receiver.mu.Unlock()
+			}
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(receiver.server.Close)
+	return receiver
+}
+
+// counts retorna quantos alertas chegaram de cada regra
+func (r *alertReceiver) counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int, len(r.alerts))
+	for rule, alerts := range r.alerts {
+		counts[rule] = len(alerts)
+	}
+	return counts
+}
+
+// first retorna o primeiro alerta recebido da regra
+func (r *alertReceiver) first(rule string) comms.Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.alerts[rule]) == 0 {
+		return comms.Alert{}
+	}
+	return r.alerts[rule][0]
+}
+
+func TestAlertActionsFireOncePerDebounceWindow(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	backend := newAlertReceiver(t, "/alerts")
+	webhook := newAlertReceiver(t, "/hook")
+
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"backend_url": backend.server.URL,
+		"alert_rules": []map[string]interface{}{
+			{
+				"id": "disk_full", "condition": "disk_usage", "threshold": 90, "debounce": 3600,
+				"actions": []map[string]interface{}{
+					{"type": "backend"},
+					{"type": "webhook", "url": webhook.server.URL + "/hook"},
+					{"type": "command", "command": "whoami"},
+				},
+			},
+			{
+				"id": "cpu_sustained", "condition": "cpu_usage", "threshold": 80, "sustain": 60, "debounce": 600,
+				"actions": []map[string]interface{}{{"type": "webhook", "url": webhook.server.URL + "/hook"}},
+			},
+			{
+				"id": "heartbeat_failures", "condition": "heartbeat_failures", "threshold": 3, "debounce": 600,
+				"actions": []map[string]interface{}{{"type": "backend"}},
+			},
+		},
+	})
+	// Com métricas, o executor conta as execuções de cada comando
+	executorConfig := a.config.ExecutorConfig(a.logger)
+	executorConfig.EnableMetrics = true
+	e, err := executor.New(executorConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.executor = e
+	a.initRegistration()
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+
+	// Cada verificação lê a amostra do canal: o envio só volta quando a
+	// verificação começou, e a seguinte só começa depois das ações da
+	// anterior
+	samples := make(chan comms.SystemHealthStatus)
+	a.alerts = comms.NewMonitor(comms.MonitorConfig{
+		Logger:        a.logger,
+		AlertRules:    monitorAlertRules(a.config.AlertRules),
+		SystemMetrics: func() comms.SystemHealthStatus { return <-samples },
+		OnAlert:       a.handleAlert,
+		Clock:         a.clock,
+	})
+	pending := fake.Pending()
+	a.startAlerts()
+	t.Cleanup(a.stopAlerts)
+	for fake.Pending() == pending {
+		time.Sleep(time.Millisecond)
+	}
+	check := func(d time.Duration, cpu, disk float64) {
+		t.Helper()
+		fake.Advance(d)
+		select {
+		case samples <- comms.SystemHealthStatus{CPUUsage: cpu, DiskUsage: disk}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("alert check did not run")
+		}
+	}
+
+	// Disco cheio dispara na hora; a CPU precisa se manter por 60s
+	check(15*time.Second, 95, 95)
+	check(30*time.Second, 95, 95)
+	check(30*time.Second, 95, 95)
+	// Dentro das janelas de debounce nada se repete; aos 10 minutos a CPU
+	// ainda alta dispara de novo
+	check(5*time.Minute, 95, 95)
+	check(5*time.Minute, 95, 95)
+
+	// Três heartbeats seguidos com falha; a CPU e o disco voltam ao normal
+	for i := 0; i < 3; i++ {
+		a.recordHeartbeat(false)
+	}
+	check(15*time.Second, 10, 50)
+	a.recordHeartbeat(true)
+
+	// Uma hora depois o disco enche de novo: nova janela, novo disparo
+	check(time.Hour, 10, 95)
+	check(15*time.Second, 10, 50)
+
+	if got := backend.counts(); len(got) != 2 || got["disk_full"] != 2 || got["heartbeat_failures"] != 1 {
+		t.Fatalf("backend alerts = %v", got)
+	}
+	if got := webhook.counts(); len(got) != 2 || got["disk_full"] != 2 || got["cpu_sustained"] != 2 {
+		t.Fatalf("webhook alerts = %v", got)
+	}
+	if stats := a.executor.GetMetrics().CommandStats["whoami"]; stats.Count != 2 {
+		t.Fatalf("whoami ran %d times, want 2", stats.Count)
+	}
+	if n := countEvents(t, a, "alert_triggered"); n != 5 {
+		t.Fatalf("%d alert_triggered events, want 5", n)
+	}
+
+	// O payload leva máquina, regra, valor atual e limite em percentual
+	disk := webhook.first("disk_full")
+	if disk.MachineID != "test-machine" || disk.Condition != comms.AlertConditionDiskUsage || disk.Value != 95 || disk.Threshold != 90 {
+		t.Fatalf("disk_full alert = %+v", disk)
+	}
+	if cpu := webhook.first("cpu_sustained"); cpu.Value != 95 || cpu.Threshold != 80 || cpu.Severity != "warning" {
+		t.Fatalf("cpu_sustained alert = %+v", cpu)
+	}
+	if heartbeat := backend.first("heartbeat_failures"); heartbeat.Value != 3 || heartbeat.Threshold != 3 || heartbeat.MachineID != "test-machine" {
+		t.Fatalf("heartbeat_failures alert = %+v", heartbeat)
+	}
+}
+
+func TestLoadConfigAlertRules(t *testing.T) {
+	// Sem alert_rules valem as regras padrão, em frações para o Monitor
+	a, _ := newTestAgent(t, nil)
+	rules := monitorAlertRules(a.config.AlertRules)
+	if len(rules) != len(DefaultAlertRules()) || rules[0].ID != "disk_full" || rules[0].Threshold != 0.95 || rules[0].Duration != time.Hour {
+		t.Fatalf("default rules = %+v", rules)
+	}
+	if rules[3].Duration != defaultAlertDebounce || rules[3].Threshold != 5 {
+		t.Fatalf("heartbeat_failures rule = %+v", rules[3])
+	}
+
+	// Lista vazia desliga os alertas
+	empty, _ := newTestAgent(t, map[string]interface{}{"alert_rules": []interface{}{}})
+	if rules := monitorAlertRules(empty.config.AlertRules); len(rules) != 0 {
+		t.Fatalf("empty alert_rules = %+v", rules)
+	}
+
+	for rule, want := range map[string]map[string]interface{}{
+		"threshold must be a percentage": {"id": "cpu", "condition": "cpu_usage", "threshold": 150},
+		"condition must be":              {"id": "x", "condition": "load_average", "threshold": 1},
+		"webhook action needs":           {"id": "hook", "condition": "disk_usage", "threshold": 90, "actions": []map[string]interface{}{{"type": "webhook", "url": "file:///tmp/x"}}},
+		"command action needs":           {"id": "cmd", "condition": "disk_usage", "threshold": 90, "actions": []map[string]interface{}{{"type": "command"}}},
+		"action type must be":            {"id": "mail", "condition": "disk_usage", "threshold": 90, "actions": []map[string]interface{}{{"type": "email"}}},
+	} {
+		problems := loadProblems(t, map[string]interface{}{"alert_rules": []map[string]interface{}{want}})
+		if !hasProblem(problems, rule) {
+			t.Errorf("missing %q in %v", rule, problems)
+		}
+	}
+	problems := loadProblems(t, map[string]interface{}{"alert_rules": []map[string]interface{}{
+		{"id": "disk", "condition": "disk_usage", "threshold": 90},
+		{"id": "disk", "condition": "disk_usage", "threshold": 95},
+	}})
+	if !hasProblem(problems, "duplicate alert rule id: disk") {
+		t.Fatalf("problems = %v", problems)
+	}
+}
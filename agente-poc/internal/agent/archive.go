@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agente-poc/internal/collector"
+)
+
+const (
+	archiveFilePrefix = "inventory-"
+	archiveFileSuffix = ".json"
+	// archiveTimeLayout ordena os arquivos por nome na ordem de coleta
+	archiveTimeLayout = "20060102T150405.000000000Z"
+)
+
+// InventoryArchive guarda os inventários do modo offline como JSON legível em
+// um diretório, mantendo apenas os size mais recentes
+type InventoryArchive struct {
+	dir    string
+	size   int
+	files  []string
+	latest time.Time
+	mu     sync.Mutex
+}
+
+// ArchiveStatus é o estado do arquivo de inventários exposto no health
+type ArchiveStatus struct {
+	Dir        string    `json:"dir"`
+	Files      int       `json:"files"`
+	MaxFiles   int       `json:"max_files"`
+	LastWrite  time.Time `json:"last_write,omitempty"`
+	TotalBytes int64     `json:"total_bytes"`
+}
+
+// NewInventoryArchive cria (ou reabre) o arquivo no diretório informado
+func NewInventoryArchive(dir string, size int) (*InventoryArchive, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("inventory archive size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create inventory archive directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory archive directory: %w", err)
+	}
+
+	archive := &InventoryArchive{dir: dir, size: size}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archiveFilePrefix) || !strings.HasSuffix(name, archiveFileSuffix) {
+			continue
+		}
+		archive.files = append(archive.files, name)
+	}
+	sort.Strings(archive.files)
+	archive.prune()
+
+	return archive, nil
+}
+
+// Write grava o inventário em um novo arquivo e descarta os mais antigos
+func (a *InventoryArchive) Write(data *collector.InventoryData) (string, error) {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal inventory: %w", err)
+	}
+
+	timestamp := data.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	name := archiveFilePrefix + timestamp.UTC().Format(archiveTimeLayout) + archiveFileSuffix
+	path := filepath.Join(a.dir, name)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return "", fmt.Errorf("failed to write inventory archive: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to write inventory archive: %w", err)
+	}
+
+	// Duas coletas no mesmo instante sobrescrevem o mesmo arquivo
+	if n := len(a.files); n == 0 || a.files[n-1] != name {
+		a.files = append(a.files, name)
+		sort.Strings(a.files)
+	}
+	a.latest = timestamp
+	a.prune()
+
+	return path, nil
+}
+
+// Status retorna o estado atual do arquivo
+func (a *InventoryArchive) Status() ArchiveStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := ArchiveStatus{
+		Dir:       a.dir,
+		Files:     len(a.files),
+		MaxFiles:  a.size,
+		LastWrite: a.latest,
+	}
+	for _, name := range a.files {
+		if info, err := os.Stat(filepath.Join(a.dir, name)); err == nil {
+			status.TotalBytes += info.Size()
+		}
+	}
+	return status
+}
+
+// prune remove os arquivos além do limite, dos mais antigos aos mais novos
+func (a *InventoryArchive) prune() {
+	for len(a.files) > a.size {
+		_ = os.Remove(filepath.Join(a.dir, a.files[0]))
+		a.files = a.files[1:]
+	}
+}
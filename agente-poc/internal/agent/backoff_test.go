@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	a, fake := newTestAgent(t, nil)
+	a.retryConfig = &RetryConfig{
+		MaxRetries:        3,
+		InitialBackoff:    10 * time.Second,
+		MaxBackoff:        30 * time.Second,
+		BackoffMultiplier: 2,
+	}
+
+	calls := make(chan time.Time, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- a.retryWithBackoff(func() error {
+			calls <- fake.Now()
+			return errors.New("backend unavailable")
+		})
+	}()
+
+	// Esperas de 10s, 20s e 30s (teto), sem dormir de verdade
+	previous := <-calls
+	for _, wait := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second} {
+		waitPending(t, fake)
+		fake.Advance(wait - time.Second)
+		select {
+		case <-calls:
+			t.Fatalf("retry before the %s backoff", wait)
+		case <-time.After(10 * time.Millisecond):
+		}
+		fake.Advance(time.Second)
+		at := <-calls
+		if at.Sub(previous) != wait {
+			t.Fatalf("retry after %s, want %s", at.Sub(previous), wait)
+		}
+		previous = at
+	}
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("retryWithBackoff succeeded with a failing operation")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("retryWithBackoff did not return after MaxRetries")
+	}
+	if a.metrics.RetryCount != 3 {
+		t.Fatalf("retry count = %d, want 3", a.metrics.RetryCount)
+	}
+}
+
+func TestRetryWithBackoffStopsOnShutdown(t *testing.T) {
+	a, fake := newTestAgent(t, nil)
+	a.retryConfig = &RetryConfig{MaxRetries: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour, BackoffMultiplier: 2}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.retryWithBackoff(func() error { return errors.New("backend unavailable") })
+	}()
+	waitPending(t, fake)
+	a.cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("cancelled retry reported success")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("retryWithBackoff kept waiting after shutdown")
+	}
+}
+
+// waitPending aguarda a goroutine sob teste chegar a um After do relógio falso
+func waitPending(t *testing.T, fake *clock.Fake) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Pending() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no timer pending on the fake clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/executor"
+)
+
+// newCancelTestAgent cria o agente de teste, sem comms (os resultados ficam
+// no histórico de eventos), com sleep liberado na whitelist
+func newCancelTestAgent(t *testing.T) *Agent {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sleep executable")
+	}
+	a, _ := newTestAgent(t, nil)
+	config := a.config.ExecutorConfig(a.logger)
+	config.CustomWhitelist = map[string]executor.CommandSpec{"sleep": {Name: "sleep"}}
+	e, err := executor.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.executor = e
+	return a
+}
+
+// runSleep processa um sleep longo como o processador de comandos faz e
+// espera ele começar a rodar
+func runSleep(t *testing.T, a *Agent, id string) {
+	t.Helper()
+	go func() {
+		a.commandMu.Lock()
+		defer a.commandMu.Unlock()
+		a.handleCommand(&comms.Command{ID: id, Type: "shell", Command: "sleep", Args: []string{"30"}})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.runningCommands()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("command %s never started", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// commandResultEvent espera o registro do resultado do comando
+func commandResultEvent(t *testing.T, a *Agent) events.Event {
+	t.Helper()
+	event := waitForEvent(t, a, "command_executed")
+	if len(a.runningCommands()) != 0 {
+		t.Fatalf("still running after the result: %v", a.runningCommands())
+	}
+	return event
+}
+
+func TestBackendCancelsRunningCommand(t *testing.T) {
+	a := newCancelTestAgent(t)
+
+	// Cancelamento de um comando que não está rodando só é registrado
+	a.handleCommandCancel(comms.CommandCancel{CommandID: "cmd-unknown"})
+
+	runSleep(t, a, "cmd-sleep")
+	a.handleCommandCancel(comms.CommandCancel{CommandID: "cmd-sleep", Reason: "operator request"})
+
+	event := commandResultEvent(t, a)
+	if event.Data["command_id"] != "cmd-sleep" || event.Data["status"] != string(comms.StatusCancelled) {
+		t.Fatalf("result event = %v", event.Data)
+	}
+	if event.Data["error_code"] != string(comms.ErrCodeCommandCancelled) || !strings.Contains(event.Data["error"].(string), "operator request") {
+		t.Fatalf("result error = %v / %v", event.Data["error_code"], event.Data["error"])
+	}
+}
+
+func TestShutdownCancelsInFlightCommands(t *testing.T) {
+	a := newCancelTestAgent(t)
+	runSleep(t, a, "cmd-shutdown")
+
+	start := time.Now()
+	a.cancelInFlightCommands()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("cancelInFlightCommands took %s", elapsed)
+	}
+
+	// O resultado já foi registrado quando cancelInFlightCommands retorna
+	event := commandResultEvent(t, a)
+	if event.Data["status"] != string(comms.StatusCancelled) || !strings.Contains(event.Data["error"].(string), "agent shutting down") {
+		t.Fatalf("result event = %v", event.Data)
+	}
+}
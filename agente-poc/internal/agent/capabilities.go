@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"runtime"
+	"sort"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/executor"
+)
+
+// agentCommandHandlers registra os comandos tratados pelo próprio agente,
+// que dependem do comms manager e não do executor. Junto com
+// executor.SupportedTypes, define os tipos anunciados em Capabilities.
+var agentCommandHandlers = map[string]func(*Agent, *comms.Command){
+	"chaos_status":          (*Agent).handleChaosStatusCommand,
+	"diagnose_connectivity": (*Agent).handleDiagnoseCommand,
+	"get_events":            (*Agent).handleGetEventsCommand,
+	"list_certificates":     (*Agent).handleListCertificatesCommand,
+	"request_snapshot":      (*Agent).handleRequestSnapshot,
+	"restart_agent":         (*Agent).handleRestartCommand,
+	"rotate_token":          (*Agent).handleRotateTokenCommand,
+	"update":                (*Agent).handleUpdateCommand,
+}
+
+// backendCommandTypes são os comandos de agentCommandHandlers que falam com
+// o backend; no modo offline são recusados com o código offline_mode
+var backendCommandTypes = map[string]bool{
+	"diagnose_connectivity": true,
+	"request_snapshot":      true,
+	"rotate_token":          true,
+	"update":                true,
+}
+
+// buildCapabilities gera as capacidades a partir dos registros de comandos,
+// dos recursos de transporte e das seções do collector; chamado no Start,
+// depois de o collector ser criado
+func (a *Agent) buildCapabilities() *comms.Capabilities {
+	types := executor.SupportedTypes()
+	for commandType := range agentCommandHandlers {
+		types = append(types, commandType)
+	}
+	sort.Strings(types)
+
+	transport := comms.TransportFeatures()
+	transport[comms.TransportMessageAcks] = a.config.WSMessageAcks
+
+	return &comms.Capabilities{
+		SchemaVersion: comms.SchemaVersion,
+		Platform:      runtime.GOOS,
+		CommandTypes:  types,
+		Transport:     transport,
+		Collectors:    a.collector.Availability(),
+	}
+}
@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/executor"
+)
+
+// newCapabilitiesTestAgent cria o agente de teste com collector e executor,
+// como o Start os deixa antes de gerar as capacidades
+func newCapabilitiesTestAgent(t *testing.T, extra map[string]interface{}) *Agent {
+	t.Helper()
+	a, _ := newTestAgent(t, extra)
+	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	t.Cleanup(func() { _ = a.collector.Close() })
+	e, err := executor.New(a.config.ExecutorConfig(a.logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.executor = e
+	a.capabilities = a.buildCapabilities()
+	return a
+}
+
+func TestCapabilitiesMatchHandlers(t *testing.T) {
+	a := newCapabilitiesTestAgent(t, nil)
+	capabilities := a.capabilities
+
+	if capabilities.SchemaVersion != comms.SchemaVersion || capabilities.Platform == "" {
+		t.Fatalf("capabilities header = %+v", capabilities)
+	}
+	types := capabilities.CommandTypes
+	if !sort.StringsAreSorted(types) {
+		t.Fatalf("command types not sorted: %v", types)
+	}
+	if len(types) != len(executor.SupportedTypes())+len(agentCommandHandlers) {
+		t.Fatalf("%d advertised types, want every executor and agent handler: %v", len(types), types)
+	}
+
+	// Todo tipo anunciado é tratado pelo agente ou aceito pelo executor
+	var shellCommand string
+	for name := range a.executor.GetWhitelist().Commands {
+		if a.executor.GetWhitelist().ValidateCommand(name, nil) == nil {
+			shellCommand = name
+			break
+		}
+	}
+	for i, commandType := range types {
+		if i > 0 && types[i-1] == commandType {
+			t.Errorf("type %q advertised twice", commandType)
+		}
+		if _, ok := agentCommandHandlers[commandType]; ok {
+			continue
+		}
+		command := &comms.Command{Type: commandType}
+		if commandType == "shell" {
+			command.Command = shellCommand
+		}
+		if !a.executor.IsSupported(command) {
+			t.Errorf("advertised type %q rejected by the executor", commandType)
+		}
+		if !capabilities.Supports(commandType) {
+			t.Errorf("Supports(%q) = false", commandType)
+		}
+	}
+	if capabilities.Supports("bogus") {
+		t.Error("unknown type reported as supported")
+	}
+
+	for _, feature := range []string{comms.TransportCompression, comms.TransportStreaming, comms.TransportMessageAcks} {
+		if _, ok := capabilities.Transport[feature]; !ok {
+			t.Errorf("transport feature %q missing", feature)
+		}
+	}
+	if capabilities.Transport[comms.TransportMessageAcks] {
+		t.Error("message acks advertised while ws_message_acks is off")
+	}
+	if !capabilities.Collectors[collector.SectionSystem] || !capabilities.Collectors[collector.SectionHardware] {
+		t.Errorf("collectors = %v", capabilities.Collectors)
+	}
+
+	withAcks := newCapabilitiesTestAgent(t, map[string]interface{}{"ws_message_acks": true})
+	if !withAcks.capabilities.Transport[comms.TransportMessageAcks] {
+		t.Error("message acks not advertised with ws_message_acks on")
+	}
+}
+
+func TestUnsupportedCommandResultCarriesCapabilities(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		results []comms.CommandResult
+	)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/commands/result") {
+			body, _ := io.ReadAll(r.Body)
+			var result comms.CommandResult
+			if err := json.Unmarshal(body, &result); err == nil {
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	a := newCapabilitiesTestAgent(t, map[string]interface{}{
+		"backend_url":   backend.URL,
+		"websocket_url": "ws" + strings.TrimPrefix(backend.URL, "http") + "/ws",
+	})
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+
+	a.handleCommand(&comms.Command{ID: "cmd-legacy", Type: "bogus"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		received := len(results)
+		mu.Unlock()
+		if received > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(results) != 1 {
+		t.Fatalf("%d results sent for an unsupported command", len(results))
+	}
+	result := results[0]
+	if result.Status != comms.StatusRejected || result.ErrorCode != comms.ErrCodeUnsupportedCommandType {
+		t.Fatalf("result = %s / %s", result.Status, result.ErrorCode)
+	}
+	if result.Capabilities == nil || strings.Join(result.Capabilities.CommandTypes, ",") != strings.Join(a.capabilities.CommandTypes, ",") {
+		t.Fatalf("result capabilities = %+v", result.Capabilities)
+	}
+}
+
+func TestDiagnoseFailureCountsAsFailedCommand(t *testing.T) {
+	// O backend HTTP responde, mas o WebSocket é inalcançável: o relatório
+	// sai com um passo falho
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	a := newCapabilitiesTestAgent(t, map[string]interface{}{"backend_url": backend.URL})
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+
+	a.handleDiagnoseCommand(&comms.Command{ID: "cmd-diagnose", Type: "diagnose", Timeout: 1})
+
+	metrics := a.GetMetrics()
+	if metrics.CommandsExecuted != 1 || metrics.CommandsFailed != 1 || metrics.CommandsSuccessful != 0 {
+		t.Fatalf("metrics after a failed diagnose = executed %d, failed %d, successful %d",
+			metrics.CommandsExecuted, metrics.CommandsFailed, metrics.CommandsSuccessful)
+	}
+}
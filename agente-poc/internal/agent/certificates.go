@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"agente-poc/internal/comms"
+)
+
+// handleListCertificatesCommand responde ao list_certificates com a lista
+// completa do trust store; o inventário traz só o resumo e os certificados
+// fora de certificate_allowlist
+func (a *Agent) handleListCertificatesCommand(command *comms.Command) {
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRunning,
+		Timestamp: a.clock.Now(),
+	}
+
+	certificates, err := a.collector.CollectCertificates()
+	if err != nil {
+		_ = result.SetStatus(comms.StatusError)
+		result.SetError(err)
+		a.sendCommandResult(result)
+		return
+	}
+
+	output, err := json.MarshalIndent(certificates, "", "  ")
+	if err != nil {
+		_ = result.SetStatus(comms.StatusError)
+		result.SetError(err)
+	} else {
+		_ = result.SetStatus(comms.StatusSuccess)
+		result.Output = string(output)
+	}
+	a.sendCommandResult(result)
+}
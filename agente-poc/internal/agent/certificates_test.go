@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"testing"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+)
+
+func TestListCertificatesCommandError(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	// Collector encerrado: a coleta falha e o resultado precisa sair como erro
+	if err := a.collector.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a.handleListCertificatesCommand(&comms.Command{ID: "cmd-certs", Type: "list_certificates"})
+
+	event := waitForEvent(t, a, "command_executed")
+	if event.Data["command_id"] != "cmd-certs" || event.Data["status"] != string(comms.StatusError) {
+		t.Fatalf("result %v", event.Data)
+	}
+}
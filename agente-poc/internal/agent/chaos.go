@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// initChaos cria o injetor de falhas se o bloco chaos estiver habilitado e
+// AGENTE_CHAOS=1; chamado antes da criação do communications manager
+func (a *Agent) initChaos() {
+	if a.config.Chaos == nil || !a.config.Chaos.Enabled {
+		return
+	}
+	if !chaos.EnabledByEnv() {
+		a.logger.WithField("env", chaos.EnvVar).Warning("Chaos config is enabled but the environment variable is not set to 1, failure injection disabled")
+		return
+	}
+
+	a.chaos = chaos.New(*a.config.Chaos, a.clock, a.logger)
+	a.recordEvent(events.CategoryAgent, events.SeverityWarning, "chaos_enabled",
+		"Failure injection enabled, do not run this configuration in production",
+		map[string]interface{}{"rules": len(a.config.Chaos.Rules)})
+}
+
+// handleChaosStatusCommand responde ao comando chaos_status com as regras de
+// injeção, se estão ativas e quantas vezes injetaram
+func (a *Agent) handleChaosStatusCommand(command *comms.Command) {
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRunning,
+		Timestamp: a.clock.Now(),
+	}
+
+	output, err := json.MarshalIndent(a.chaos.Status(), "", "  ")
+	if err != nil {
+		_ = result.SetStatus(comms.StatusError)
+		result.SetError(err)
+	} else {
+		_ = result.SetStatus(comms.StatusSuccess)
+		result.Output = string(output)
+	}
+	a.sendCommandResult(result)
+}
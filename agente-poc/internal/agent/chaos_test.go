@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"testing"
+
+	"agente-poc/internal/chaos"
+)
+
+func TestInitChaosRequiresConfigAndEnv(t *testing.T) {
+	block := map[string]interface{}{
+		"enabled": true,
+		"rules":   []map[string]interface{}{{"kind": "http_error", "status_code": 503, "duration": "2m"}},
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		env    string
+		want   bool
+	}{
+		{"config and env", map[string]interface{}{"chaos": block}, "1", true},
+		{"config without env", map[string]interface{}{"chaos": block}, "", false},
+		{"env without config", nil, "1", false},
+		{"disabled block", map[string]interface{}{"chaos": map[string]interface{}{"enabled": false, "rules": block["rules"]}}, "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(chaos.EnvVar, tt.env)
+			a, _ := newTestAgent(t, tt.config)
+			a.initChaos()
+
+			if enabled := a.chaos.Status().Enabled; enabled != tt.want {
+				t.Fatalf("chaos enabled = %t, want %t", enabled, tt.want)
+			}
+			if tt.want {
+				event := waitForEvent(t, a, "chaos_enabled")
+				if event.Data["rules"] != 1 {
+					t.Fatalf("chaos_enabled data = %v", event.Data)
+				}
+			} else if n := countEvents(t, a, "chaos_enabled"); n != 0 {
+				t.Fatalf("chaos_enabled recorded %d times", n)
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalidChaosRule(t *testing.T) {
+	_, err := LoadConfig(writeTestConfig(t, map[string]interface{}{
+		"chaos": map[string]interface{}{"enabled": true, "rules": []map[string]interface{}{{"kind": "latency"}}},
+	}))
+	if err == nil {
+		t.Fatal("latency rule without latency accepted")
+	}
+}
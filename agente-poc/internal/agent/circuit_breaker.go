@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// newEndpointBreakers cria os circuit breakers por endpoint do backend; os
+// limites bulk sem configuração seguem os gerais
+func (a *Agent) newEndpointBreakers() *comms.EndpointBreakers {
+	critical := comms.CircuitBreakerConfig{
+		FailureThreshold: a.config.CircuitBreakerFailureThreshold,
+		ResetTimeout:     a.config.CircuitBreakerResetTimeout,
+	}
+	bulk := comms.CircuitBreakerConfig{
+		FailureThreshold: a.config.CircuitBreakerBulkFailureThreshold,
+		ResetTimeout:     a.config.CircuitBreakerBulkResetTimeout,
+	}
+	if bulk.FailureThreshold == 0 {
+		bulk.FailureThreshold = critical.FailureThreshold
+	}
+	if bulk.ResetTimeout == 0 {
+		bulk.ResetTimeout = critical.ResetTimeout
+	}
+
+	return comms.NewEndpointBreakers(comms.EndpointBreakersConfig{
+		Critical:     critical,
+		Bulk:         bulk,
+		OnTransition: a.handleCircuitBreakerTransition,
+		Clock:        a.clock,
+	})
+}
+
+// breakerTotals soma as aberturas e o tempo aberto de todos os endpoints
+func (a *Agent) breakerTotals() (trips int64, timeOpen time.Duration) {
+	for _, status := range a.breakers.Statuses() {
+		trips += status.Trips
+		timeOpen += time.Duration(status.TimeOpenSeconds * float64(time.Second))
+	}
+	return trips, timeOpen
+}
+
+// handleCircuitBreakerTransition registra cada troca de estado de um
+// endpoint no log de eventos
+func (a *Agent) handleCircuitBreakerTransition(endpoint, from, to string, status comms.CircuitBreakerStatus) {
+	a.logger.WithFields(map[string]interface{}{
+		"endpoint": endpoint,
+		"from":     from,
+		"to":       to,
+		"failures": status.Failures,
+	}).Info("Circuit breaker state changed")
+
+	switch to {
+	case comms.BreakerOpen:
+		a.recordEvent(events.CategoryAlert, events.SeverityWarning, "circuit_breaker_opened", "Backend circuit breaker opened", map[string]interface{}{
+			"endpoint":      endpoint,
+			"from":          from,
+			"failures":      status.Failures,
+			"reset_timeout": time.Duration(status.ResetTimeout * float64(time.Second)).String(),
+			"trips":         status.Trips,
+			"error":         status.LastError,
+		})
+	case comms.BreakerHalfOpen:
+		a.recordEvent(events.CategoryAlert, events.SeverityInfo, "circuit_breaker_half_open", "Backend circuit breaker half-open", map[string]interface{}{
+			"endpoint": endpoint,
+			"probes":   status.Probes,
+		})
+	case comms.BreakerClosed:
+		a.recordEvent(events.CategoryAlert, events.SeverityInfo, "circuit_breaker_closed", "Backend circuit breaker closed", map[string]interface{}{
+			"endpoint":     endpoint,
+			"open_seconds": status.LastOpenSeconds,
+			"trips":        status.Trips,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+func TestCircuitBreakerConfigAndEvents(t *testing.T) {
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"circuit_breaker_failure_threshold": 2,
+		"circuit_breaker_reset_timeout":     10,
+	})
+	heartbeat := a.breakers.For(comms.EndpointHeartbeat)
+	if status := heartbeat.Status(); status.FailureThreshold != 2 || status.ResetTimeout != 10 {
+		t.Fatalf("heartbeat breaker = %+v", status)
+	}
+
+	// Abre no limite configurado, não no padrão
+	heartbeat.RecordFailure(errors.New("503 Service Unavailable"))
+	if heartbeat.State() != comms.BreakerClosed {
+		t.Fatal("breaker opened before the configured threshold")
+	}
+	heartbeat.RecordFailure(errors.New("503 Service Unavailable"))
+	if heartbeat.State() != comms.BreakerOpen {
+		t.Fatalf("breaker %s after 2 failures", heartbeat.State())
+	}
+	opened := waitForEvent(t, a, "circuit_breaker_opened")
+	if opened.Data["endpoint"] != comms.EndpointHeartbeat || opened.Data["reset_timeout"] != "10s" ||
+		opened.Data["failures"] != 2 || opened.Data["error"] != "503 Service Unavailable" {
+		t.Fatalf("circuit_breaker_opened data = %v", opened.Data)
+	}
+
+	// Após o reset_timeout, half-open; o sucesso fecha e registra o tempo aberto
+	fake.Advance(9 * time.Second)
+	if heartbeat.Allow() {
+		t.Fatal("breaker allowed a call before the configured reset timeout")
+	}
+	fake.Advance(2 * time.Second)
+	if !heartbeat.Allow() {
+		t.Fatal("breaker still open after the configured reset timeout")
+	}
+	waitForEvent(t, a, "circuit_breaker_half_open")
+	heartbeat.RecordSuccess()
+	closed := waitForEvent(t, a, "circuit_breaker_closed")
+	if closed.Data["open_seconds"] != float64(11) || closed.Data["trips"] != int64(1) {
+		t.Fatalf("circuit_breaker_closed data = %v", closed.Data)
+	}
+
+	if trips, timeOpen := a.breakerTotals(); trips != 1 || timeOpen != 11*time.Second {
+		t.Fatalf("breaker totals = %d trips, %s open", trips, timeOpen)
+	}
+}
+
+func TestBulkBreakerLimits(t *testing.T) {
+	// Sem limites bulk, o inventário segue os gerais
+	a, _ := newTestAgent(t, map[string]interface{}{
+		"circuit_breaker_failure_threshold": 4,
+		"circuit_breaker_reset_timeout":     20,
+	})
+	statuses := a.breakers.Statuses()
+	if inventory := statuses[comms.EndpointInventory]; inventory.Class != comms.EndpointClassBulk ||
+		inventory.FailureThreshold != 4 || inventory.ResetTimeout != 20 {
+		t.Fatalf("inventory breaker = %+v", inventory)
+	}
+
+	// Com limites bulk, cada classe fica com os seus
+	a, _ = newTestAgent(t, map[string]interface{}{
+		"circuit_breaker_failure_threshold":      4,
+		"circuit_breaker_reset_timeout":          20,
+		"circuit_breaker_bulk_failure_threshold": 2,
+		"circuit_breaker_bulk_reset_timeout":     300,
+	})
+	statuses = a.breakers.Statuses()
+	if heartbeat := statuses[comms.EndpointHeartbeat]; heartbeat.Class != comms.EndpointClassCritical ||
+		heartbeat.FailureThreshold != 4 || heartbeat.ResetTimeout != 20 {
+		t.Fatalf("heartbeat breaker = %+v", heartbeat)
+	}
+	if inventory := statuses[comms.EndpointInventory]; inventory.FailureThreshold != 2 || inventory.ResetTimeout != 300 {
+		t.Fatalf("inventory breaker = %+v", inventory)
+	}
+
+	// Uma falha no inventário não mexe no heartbeat
+	a.breakers.For(comms.EndpointInventory).RecordFailure(errors.New("500 Internal Server Error"))
+	a.breakers.For(comms.EndpointInventory).RecordFailure(errors.New("500 Internal Server Error"))
+	if a.breakers.For(comms.EndpointInventory).State() != comms.BreakerOpen || a.breakers.For(comms.EndpointHeartbeat).State() != comms.BreakerClosed {
+		t.Fatalf("breakers = %+v", a.breakers.Statuses())
+	}
+	if a.breakers.State() != comms.BreakerOpen {
+		t.Fatalf("overall breaker state = %s", a.breakers.State())
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// collectorSettingsFile guarda os ajustes do collector recebidos do backend
+// entre reinícios
+const collectorSettingsFile = "collector_settings.json"
+
+// configUpdateCollectorKey é o bloco de config_update com os ajustes do
+// collector; campos ausentes mantêm o valor vigente
+const configUpdateCollectorKey = "collector"
+
+// loadCollectorSettings reaplica os ajustes persistidos; falhas mantêm os
+// padrões do collector
+func (a *Agent) loadCollectorSettings() {
+	data, err := os.ReadFile(filepath.Join(a.config.DataDir, collectorSettingsFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			a.logger.WithField("error", err).Warning("Failed to read collector settings")
+		}
+		return
+	}
+
+	settings := a.collector.Settings()
+	if err := json.Unmarshal(data, &settings); err != nil {
+		a.logger.WithField("error", err).Warning("Ignoring invalid collector settings state")
+		return
+	}
+	if _, err := a.applyCollectorSettings(settings); err != nil {
+		a.logger.WithField("error", err).Warning("Ignoring invalid collector settings state")
+	}
+}
+
+// handleConfigUpdate aplica um config_update do backend: o bloco "agent"
+// (ver reload.go) e o do collector. A troca é atômica no collector: a coleta
+// em andamento termina com os valores antigos e a próxima usa os novos.
+func (a *Agent) handleConfigUpdate(update *comms.ConfigUpdate) {
+	agentBlock, hasAgent := update.Config[configUpdateAgentKey]
+	if hasAgent {
+		a.applyAgentConfigUpdate(agentBlock)
+	}
+
+	block, ok := update.Config[configUpdateCollectorKey]
+	if !ok {
+		if !hasAgent {
+			a.logger.Debug("Configuration update has no supported settings")
+		}
+		return
+	}
+
+	settings := a.collector.Settings()
+	raw, err := json.Marshal(block)
+	if err == nil {
+		err = json.Unmarshal(raw, &settings)
+	}
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Invalid collector settings in configuration update")
+		return
+	}
+
+	applied, err := a.applyCollectorSettings(settings)
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Collector settings update rejected")
+		return
+	}
+	if err := a.saveCollectorSettings(applied); err != nil {
+		a.logger.WithField("error", err).Warning("Failed to persist collector settings")
+	}
+}
+
+// applyCollectorSettings troca os ajustes do collector; valores fora da
+// faixa aceita são ajustados e geram o evento collector_settings_clamped
+func (a *Agent) applyCollectorSettings(settings collector.Settings) (collector.Settings, error) {
+	applied, clamps, err := a.collector.ApplySettings(settings)
+	if err != nil {
+		return collector.Settings{}, err
+	}
+	if len(clamps) > 0 {
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "collector_settings_clamped",
+			"Collector settings out of range were clamped",
+			map[string]interface{}{"clamps": clamps})
+	}
+	return applied, nil
+}
+
+// saveCollectorSettings persiste os ajustes aplicados
+func (a *Agent) saveCollectorSettings(settings collector.Settings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal collector settings: %w", err)
+	}
+
+	if err := os.MkdirAll(a.config.DataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	path := filepath.Join(a.config.DataDir, collectorSettingsFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write collector settings: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// collectorSettingsStatus descreve os ajustes vigentes do collector para o health
+func (a *Agent) collectorSettingsStatus() *collector.Settings {
+	if a.collector == nil {
+		return nil
+	}
+	settings := a.collector.Settings()
+	return &settings
+}
@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+)
+
+// newCollectorSettingsTestAgent cria o agente de teste com collector, como o
+// Start o deixa antes de reaplicar os ajustes persistidos
+func newCollectorSettingsTestAgent(t *testing.T, dataDir string) *Agent {
+	t.Helper()
+	var extra map[string]interface{}
+	if dataDir != "" {
+		extra = map[string]interface{}{"data_dir": dataDir}
+	}
+	a, _ := newTestAgent(t, extra)
+	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	t.Cleanup(func() { _ = a.collector.Close() })
+	a.loadCollectorSettings()
+	return a
+}
+
+// collectorUpdate monta um config_update com o bloco do collector
+func collectorUpdate(block map[string]interface{}) *comms.ConfigUpdate {
+	return &comms.ConfigUpdate{
+		MachineID: "test-machine",
+		Config:    map[string]interface{}{configUpdateCollectorKey: block},
+	}
+}
+
+func TestConfigUpdateAppliesCollectorSettings(t *testing.T) {
+	a := newCollectorSettingsTestAgent(t, "")
+	before := a.collector.Settings()
+
+	a.handleConfigUpdate(collectorUpdate(map[string]interface{}{
+		"max_processes":     25,
+		"disabled_sections": []string{"network"},
+	}))
+
+	// Campos ausentes mantêm o valor vigente
+	settings := a.collector.Settings()
+	if settings.MaxProcesses != 25 || settings.MaxApplications != before.MaxApplications || !reflect.DeepEqual(settings.DisabledSections, []string{"network"}) {
+		t.Fatalf("settings after the update = %+v", settings)
+	}
+
+	// A próxima coleta respeita a seção desabilitada
+	network, err := a.collector.CollectNetworkInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !network.Skipped {
+		t.Fatal("network collected after the section was disabled")
+	}
+
+	var persisted collector.Settings
+	data, err := os.ReadFile(filepath.Join(a.config.DataDir, collectorSettingsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &persisted); err != nil || !reflect.DeepEqual(persisted, settings) {
+		t.Fatalf("persisted settings = %+v (%v), want %+v", persisted, err, settings)
+	}
+
+	if status := a.Health()["collector_settings"].(*collector.Settings); !reflect.DeepEqual(*status, settings) {
+		t.Fatalf("Health() collector_settings = %+v", *status)
+	}
+
+	// Um agente novo no mesmo data_dir volta com os ajustes
+	restarted := newCollectorSettingsTestAgent(t, a.config.DataDir)
+	if got := restarted.collector.Settings(); !reflect.DeepEqual(got, settings) {
+		t.Fatalf("settings after restart = %+v, want %+v", got, settings)
+	}
+}
+
+func TestConfigUpdateClampsCollectorSettings(t *testing.T) {
+	a := newCollectorSettingsTestAgent(t, "")
+
+	a.handleConfigUpdate(collectorUpdate(map[string]interface{}{"max_processes": 100000}))
+
+	if got := a.collector.Settings().MaxProcesses; got != 1000 {
+		t.Fatalf("max_processes = %d, want the 1000 cap", got)
+	}
+	event := waitForEvent(t, a, "collector_settings_clamped")
+	clamps, ok := event.Data["clamps"].([]collector.SettingClamp)
+	if !ok || len(clamps) != 1 || clamps[0].Setting != "max_processes" || clamps[0].Requested != 100000 {
+		t.Fatalf("clamped event data = %#v", event.Data)
+	}
+}
+
+func TestConfigUpdateRejectsCollectorSettings(t *testing.T) {
+	a := newCollectorSettingsTestAgent(t, "")
+	before := a.collector.Settings()
+
+	for name, block := range map[string]map[string]interface{}{
+		"system section":  {"max_processes": 10, "disabled_sections": []string{"system"}},
+		"unknown section": {"max_processes": 10, "disabled_sections": []string{"drivers"}},
+		"wrong type":      {"max_processes": "ten"},
+	} {
+		a.handleConfigUpdate(collectorUpdate(block))
+		if got := a.collector.Settings(); !reflect.DeepEqual(got, before) {
+			t.Errorf("%s: settings changed to %+v", name, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(a.config.DataDir, collectorSettingsFile)); !os.IsNotExist(err) {
+		t.Fatalf("rejected update persisted: %v", err)
+	}
+
+	// Estado persistido inválido é ignorado no carregamento
+	if err := os.MkdirAll(a.config.DataDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.config.DataDir, collectorSettingsFile), []byte(`{"disabled_sections":["hardware"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	restarted := newCollectorSettingsTestAgent(t, a.config.DataDir)
+	if got := restarted.collector.Settings(); !reflect.DeepEqual(got, before) {
+		t.Fatalf("invalid persisted settings applied: %+v", got)
+	}
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"agente-poc/internal/comms"
+)
+
+// commandQueueSize é a capacidade da fila de comandos do agente
+const commandQueueSize = 100
+
+// ErrCommandQueueFull indica que o comando foi recusado com a fila cheia
+var ErrCommandQueueFull = errors.New("command queue is full")
+
+// CommandPriority ordena a fila de comandos: os de prioridade maior saem
+// primeiro e, com a fila cheia, tomam o lugar do mais antigo de prioridade
+// menor
+type CommandPriority int
+
+const (
+	PriorityLow CommandPriority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// String retorna o nome usado em options.priority e no health
+func (p CommandPriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// highPriorityCommandTypes são os comandos de controle do agente, que não
+// podem ficar presos atrás de uma rajada de comandos comuns
+var highPriorityCommandTypes = map[string]bool{
+	"restart_agent": true,
+	"rotate_token":  true,
+	"update":        true,
+}
+
+// commandPriority deriva a prioridade de options.priority ("low", "normal"
+// ou "high") ou, sem ela, do tipo do comando
+func commandPriority(command *comms.Command) CommandPriority {
+	if value, ok := command.Options["priority"].(string); ok {
+		switch strings.ToLower(value) {
+		case "low":
+			return PriorityLow
+		case "normal":
+			return PriorityNormal
+		case "high":
+			return PriorityHigh
+		}
+	}
+	if highPriorityCommandTypes[command.Type] {
+		return PriorityHigh
+	}
+	return PriorityNormal
+}
+
+// commandQueue é a fila de comandos aguardando o processador, uma sub-fila
+// FIFO por prioridade. ready recebe um sinal sempre que há comandos.
+type commandQueue struct {
+	mu       sync.Mutex
+	queues   [PriorityHigh + 1][]*comms.Command
+	capacity int
+	ready    chan struct{}
+
+	rejected int64
+	evicted  int64
+}
+
+// newCommandQueue cria a fila com a capacidade informada
+func newCommandQueue(capacity int) *commandQueue {
+	return &commandQueue{
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// push admite um comando. Com a fila cheia, o mais antigo da menor
+// prioridade abaixo da do comando sai para dar lugar a ele e é retornado;
+// sem um de prioridade menor, o próprio comando é recusado com
+// ErrCommandQueueFull.
+func (q *commandQueue) push(command *comms.Command) (evicted *comms.Command, err error) {
+	priority := commandPriority(command)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.depthLocked() >= q.capacity {
+		for p := PriorityLow; p < priority; p++ {
+			if len(q.queues[p]) > 0 {
+				evicted = q.queues[p][0]
+				q.queues[p] = q.queues[p][1:]
+				break
+			}
+		}
+		if evicted == nil {
+			q.rejected++
+			return nil, ErrCommandQueueFull
+		}
+		q.evicted++
+	}
+
+	q.queues[priority] = append(q.queues[priority], command)
+	q.signal()
+	return evicted, nil
+}
+
+// pop retira o próximo comando (o mais antigo da maior prioridade)
+func (q *commandQueue) pop() (*comms.Command, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		if len(q.queues[p]) == 0 {
+			continue
+		}
+		command := q.queues[p][0]
+		q.queues[p][0] = nil
+		q.queues[p] = q.queues[p][1:]
+		if q.depthLocked() > 0 {
+			q.signal()
+		}
+		return command, true
+	}
+	return nil, false
+}
+
+// signal avisa o processador sem bloquear (um sinal pendente basta)
+func (q *commandQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Ready recebe um sinal quando há comandos na fila
+func (q *commandQueue) Ready() <-chan struct{} {
+	return q.ready
+}
+
+// depthLocked soma as sub-filas; exige mu
+func (q *commandQueue) depthLocked() int {
+	depth := 0
+	for _, queue := range q.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// Depth retorna quantos comandos aguardam execução
+func (q *commandQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depthLocked()
+}
+
+// Stats retorna a profundidade e os descartes: recusados com a fila cheia e
+// retirados por um comando de prioridade maior
+func (q *commandQueue) Stats() (depth int, rejected, evicted int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depthLocked(), q.rejected, q.evicted
+}
+
+// Status descreve a fila para o health
+func (q *commandQueue) Status() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	byPriority := make(map[string]int, len(q.queues))
+	for p, queue := range q.queues {
+		byPriority[CommandPriority(p).String()] = len(queue)
+	}
+	return map[string]interface{}{
+		"depth":       q.depthLocked(),
+		"capacity":    q.capacity,
+		"by_priority": byPriority,
+		"rejected":    q.rejected,
+		"evicted":     q.evicted,
+	}
+}
+
+// rejectQueueFull responde ao backend um comando descartado pela fila
+// cheia (recusado ou retirado por um de prioridade maior) com status
+// rejected e código queue_full. O resultado é marcado com o agendamento na
+// hora e enviado em segundo plano, para não prender quem submeteu; no modo
+// offline fica no pipeline de eventos, como os demais resultados.
+func (a *Agent) rejectQueueFull(command *comms.Command, reason string) {
+	a.logger.WithFields(map[string]interface{}{
+		"command_id":   command.ID,
+		"command_type": command.Type,
+		"priority":     commandPriority(command).String(),
+		"reason":       reason,
+	}).Warning("Command dropped: command queue is full")
+
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRejected,
+		ExitCode:  -1,
+		Timestamp: a.clock.Now(),
+	}
+	result.SetError(comms.NewCodedError(comms.ErrCodeCommandQueueFull))
+	a.tagScheduledResult(result)
+
+	go a.sendCommandResult(result)
+}
@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// queuedCommand cria um comando com a prioridade em options.priority
+// (vazia usa a do tipo)
+func queuedCommand(id, priority string) *comms.Command {
+	command := &comms.Command{ID: id, Type: "shell", Command: "uptime"}
+	if priority != "" {
+		command.Options = map[string]interface{}{"priority": priority}
+	}
+	return command
+}
+
+// drainQueue retira todos os comandos, na ordem em que seriam executados
+func drainQueue(q *commandQueue) []string {
+	var ids []string
+	for {
+		command, ok := q.pop()
+		if !ok {
+			return ids
+		}
+		ids = append(ids, command.ID)
+	}
+}
+
+func TestCommandPriority(t *testing.T) {
+	tests := []struct {
+		command *comms.Command
+		want    CommandPriority
+	}{
+		{queuedCommand("c", ""), PriorityNormal},
+		{queuedCommand("c", "low"), PriorityLow},
+		{queuedCommand("c", "HIGH"), PriorityHigh},
+		{queuedCommand("c", "urgent"), PriorityNormal},
+		{&comms.Command{Type: "restart_agent"}, PriorityHigh},
+		{&comms.Command{Type: "update"}, PriorityHigh},
+		{&comms.Command{Type: "rotate_token"}, PriorityHigh},
+		// A opção explícita vale mais que o tipo
+		{&comms.Command{Type: "update", Options: map[string]interface{}{"priority": "low"}}, PriorityLow},
+	}
+	for _, tt := range tests {
+		if got := commandPriority(tt.command); got != tt.want {
+			t.Errorf("commandPriority(%s %v) = %s, want %s", tt.command.Type, tt.command.Options, got, tt.want)
+		}
+	}
+}
+
+func TestCommandQueueOrder(t *testing.T) {
+	q := newCommandQueue(10)
+	for _, command := range []*comms.Command{
+		queuedCommand("low-1", "low"),
+		queuedCommand("normal-1", ""),
+		queuedCommand("high-1", "high"),
+		queuedCommand("normal-2", ""),
+		queuedCommand("high-2", "high"),
+		queuedCommand("low-2", "low"),
+	} {
+		if _, err := q.push(command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Maior prioridade primeiro, FIFO dentro de cada uma
+	want := "high-1,high-2,normal-1,normal-2,low-1,low-2"
+	if got := strings.Join(drainQueue(q), ","); got != want {
+		t.Fatalf("order %s, want %s", got, want)
+	}
+	if q.Depth() != 0 {
+		t.Fatalf("depth %d after draining", q.Depth())
+	}
+}
+
+func TestCommandQueueEviction(t *testing.T) {
+	q := newCommandQueue(3)
+	for _, command := range []*comms.Command{
+		queuedCommand("low-1", "low"),
+		queuedCommand("normal-1", ""),
+		queuedCommand("low-2", "low"),
+	} {
+		if _, err := q.push(command); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Cheia: cada comando tira o mais antigo da menor prioridade abaixo dele
+	steps := []struct {
+		command *comms.Command
+		evicted string
+		err     error
+	}{
+		{queuedCommand("high-1", "high"), "low-1", nil},
+		{queuedCommand("normal-2", ""), "low-2", nil},
+		// Sem prioridade menor na fila, o próprio comando é recusado
+		{queuedCommand("normal-3", ""), "", ErrCommandQueueFull},
+		{queuedCommand("low-3", "low"), "", ErrCommandQueueFull},
+		{queuedCommand("high-2", "high"), "normal-1", nil},
+		{queuedCommand("high-3", "high"), "normal-2", nil},
+		{queuedCommand("high-4", "high"), "", ErrCommandQueueFull},
+	}
+	for _, step := range steps {
+		evicted, err := q.push(step.command)
+		if !errors.Is(err, step.err) {
+			t.Fatalf("push %s: error %v, want %v", step.command.ID, err, step.err)
+		}
+		evictedID := ""
+		if evicted != nil {
+			evictedID = evicted.ID
+		}
+		if evictedID != step.evicted {
+			t.Fatalf("push %s evicted %q, want %q", step.command.ID, evictedID, step.evicted)
+		}
+	}
+
+	depth, rejected, evicted := q.Stats()
+	if depth != 3 || rejected != 3 || evicted != 4 {
+		t.Fatalf("depth %d, rejected %d, evicted %d", depth, rejected, evicted)
+	}
+	if got := strings.Join(drainQueue(q), ","); got != "high-1,high-2,high-3" {
+		t.Fatalf("left in the queue: %s", got)
+	}
+}
+
+func TestSubmitCommandQueueFull(t *testing.T) {
+	// Sem Start o processador não consome: a fila enche de verdade. Sem
+	// comms, os resultados ficam no histórico de eventos.
+	a, _ := newTestAgent(t, nil)
+	a.commandQueue = newCommandQueue(2)
+
+	for _, id := range []string{"low-1", "low-2"} {
+		if err := a.SubmitCommand(queuedCommand(id, "low")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// O normal entra no lugar do low-1, o update (alta pelo tipo) no do
+	// low-2, e um low é recusado
+	if err := a.SubmitCommand(queuedCommand("normal-1", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SubmitCommand(&comms.Command{ID: "update-1", Type: "update"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SubmitCommand(queuedCommand("low-3", "low")); !errors.Is(err, ErrCommandQueueFull) {
+		t.Fatalf("low command on a full queue: %v", err)
+	}
+
+	// Cada descartado recebe na hora um rejected com queue_full
+	dropped := map[string]bool{"low-1": true, "low-2": true, "low-3": true}
+	deadline := time.Now().Add(2 * time.Second)
+	for countEvents(t, a, "command_executed") < len(dropped) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, event := range a.recentEvents.Since(time.Time{}, 0) {
+		if event.Type != "command_executed" {
+			continue
+		}
+		id, _ := event.Data["command_id"].(string)
+		if !dropped[id] || event.Data["status"] != string(comms.StatusRejected) || event.Data["error_code"] != string(comms.ErrCodeCommandQueueFull) {
+			t.Fatalf("result %v", event.Data)
+		}
+		delete(dropped, id)
+	}
+	if len(dropped) != 0 {
+		t.Fatalf("no result for %v", dropped)
+	}
+
+	metrics := a.GetMetrics()
+	if metrics.CommandQueueDepth != 2 || metrics.CommandsRejectedFull != 1 || metrics.CommandsEvicted != 2 {
+		t.Fatalf("metrics: depth %d, rejected %d, evicted %d", metrics.CommandQueueDepth, metrics.CommandsRejectedFull, metrics.CommandsEvicted)
+	}
+	status, ok := a.Health()["command_queue"].(map[string]interface{})
+	if !ok || status["depth"] != 2 || status["rejected"] != int64(1) || status["evicted"] != int64(2) {
+		t.Fatalf("health command_queue = %v", status)
+	}
+	if got := strings.Join(drainQueue(a.commandQueue), ","); got != "update-1,normal-1" {
+		t.Fatalf("left in the queue: %s", got)
+	}
+}
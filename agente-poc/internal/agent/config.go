@@ -1,11 +1,26 @@
 package agent
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"time"
+
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/credentials"
+	"agente-poc/internal/events"
+	"agente-poc/internal/executor"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
 )
 
 // Config representa a configuração do agente
@@ -23,26 +38,389 @@ type Config struct {
 	MaxRetries         int           `json:"max_retries"`
 	LogLevel           string        `json:"log_level"`
 	Debug              bool          `json:"debug"`
+	DataDir            string        `json:"data_dir"`
+	ControlSocket      string        `json:"control_socket"`
+
+	// Limite de comandos executados simultaneamente
+	MaxConcurrentCommands int `json:"max_concurrent_commands"`
+
+	// Circuit breakers por endpoint do backend: falhas seguidas que abrem o
+	// circuito e espera até o probe do backend (GET /health). Os valores
+	// gerais valem para heartbeat e demais endpoints críticos; os bulk para
+	// o inventário (zero = mesmo valor dos gerais)
+	CircuitBreakerFailureThreshold     int           `json:"circuit_breaker_failure_threshold"`
+	CircuitBreakerResetTimeout         time.Duration `json:"circuit_breaker_reset_timeout"`
+	CircuitBreakerBulkFailureThreshold int           `json:"circuit_breaker_bulk_failure_threshold"`
+	CircuitBreakerBulkResetTimeout     time.Duration `json:"circuit_breaker_bulk_reset_timeout"`
+
+	// Tokens adicionais para rotação sem downtime: tentados em ordem quando o
+	// token ativo recebe 401; o aceito pelo backend passa a ser o ativo
+	Tokens []string `json:"tokens,omitempty"`
+
+	// Endpoints de failover (ex.: a outra região), tentados depois de
+	// backend_url e websocket_url, em ordem; o ativo troca depois de
+	// failover_threshold falhas consecutivas (padrão 3) e continua preferido
+	// nas reconexões
+	BackendURLs       []string `json:"backend_urls,omitempty"`
+	WebSocketURLs     []string `json:"websocket_urls,omitempty"`
+	FailoverThreshold int      `json:"failover_threshold,omitempty"`
+
+	// Modo offline: o agente não fala com backend algum (sem registro,
+	// heartbeats nem WebSocket); coleta, comandos agendados e o socket de
+	// controle continuam, e os inventários vão para offline_archive_dir
+	// (padrão data_dir/inventory_archive), que guarda os offline_archive_size
+	// mais recentes (padrão 96)
+	Offline            bool   `json:"offline,omitempty"`
+	OfflineArchiveDir  string `json:"offline_archive_dir,omitempty"`
+	OfflineArchiveSize int    `json:"offline_archive_size,omitempty"`
+
+	// Chave de enrollment de curta duração: no primeiro início o agente a
+	// troca pelo token da máquina (/machines/enroll), guardado cifrado em
+	// data_dir, e passa a usar só esse token; a chave é descartada. Também
+	// lida de AGENTE_ENROLLMENT_KEY (ou -enrollment-key).
+	EnrollmentKey string `json:"enrollment_key,omitempty"`
+	// CredentialPassphrase cifra o token da máquina no arquivo (Linux e
+	// demais sistemas sem keychain ou DPAPI); vazia, o arquivo fica só 0600
+	CredentialPassphrase string `json:"credential_passphrase,omitempty"`
+
+	// Autenticação mútua com o backend (arquivos PEM): certificado e chave do
+	// cliente e a CA que assina o servidor (substitui as raízes do sistema).
+	// Certificados trocados no mesmo caminho valem após SIGHUP.
+	TLSClientCertFile string `json:"tls_client_cert_file,omitempty"`
+	TLSClientKeyFile  string `json:"tls_client_key_file,omitempty"`
+	TLSCACertFile     string `json:"tls_ca_cert_file,omitempty"`
+	// TLSSkipVerify desliga a verificação do certificado do backend (só para
+	// testes; exclusivo com tls_ca_cert_file). PinnedCertificates são os
+	// SHA-256 (hex) de certificados dos quais um precisa estar na cadeia do
+	// backend; o agente diagnose informa se algum confere.
+	TLSSkipVerify      bool     `json:"tls_skip_verify,omitempty"`
+	PinnedCertificates []string `json:"pinned_certificates,omitempty"`
+
+	// Proxy de saída para o backend (http:// ou socks5://, credenciais no
+	// userinfo); vazio segue HTTPS_PROXY/HTTP_PROXY do ambiente. Destinos em
+	// NO_PROXY saem direto nos dois casos.
+	ProxyURL string `json:"proxy_url,omitempty"`
+
+	// Retenção local de snapshots de inventário
+	SnapshotRingSize         int `json:"snapshot_ring_size"`
+	SnapshotCompressionLevel int `json:"snapshot_compression_level"`
+	// SnapshotMaxBytes limita o disco ocupado pelo ring; os snapshots mais
+	// antigos saem primeiro, mas o mais recente é sempre mantido
+	SnapshotMaxBytes int64 `json:"snapshot_max_bytes"`
+
+	// Hosts internos acessíveis pelo comando http_probe (localhost é sempre permitido)
+	HTTPProbeAllowedHosts []string `json:"http_probe_allowed_hosts,omitempty"`
+
+	// Diretórios aceitos em options.cwd dos comandos shell (vazio = diretórios
+	// de usuário e temporários da plataforma)
+	CommandWorkingDirs []string `json:"command_working_dirs,omitempty"`
+
+	// Diretórios e tamanho máximo do comando fetch_file (vazio = logs do
+	// sistema e o diretório do event_log_path; 0 = 5 MB)
+	FetchFileDirs     []string `json:"fetch_file_dirs,omitempty"`
+	FetchFileMaxBytes int64    `json:"fetch_file_max_bytes,omitempty"`
+
+	// Tamanho a partir do qual o log de auditoria dos comandos (data_dir/audit)
+	// é rotacionado (0 = 10 MB) e quantos arquivos rotacionados são mantidos
+	// (0 = 10)
+	AuditLogMaxBytes int64 `json:"audit_log_max_bytes,omitempty"`
+	AuditLogMaxFiles int   `json:"audit_log_max_files,omitempty"`
+
+	// Chaves públicas Ed25519 (base64) que assinam o comando script; vazio
+	// desativa o comando. Recarregáveis por SIGHUP, nunca pelo backend.
+	ScriptPublicKeys []string `json:"script_public_keys,omitempty"`
+
+	// Chaves públicas Ed25519 (base64) que assinam as atualizações da
+	// whitelist (whitelist_update); vazio recusa as atualizações.
+	// Recarregáveis por SIGHUP, nunca pelo backend.
+	WhitelistPublicKeys []string `json:"whitelist_public_keys,omitempty"`
+
+	// Comandos recorrentes executados localmente (ver scheduler.go); o
+	// backend pode acrescentar outros com schedule_update
+	Schedules []Schedule `json:"schedules,omitempty"`
+
+	// Aceita comandos com campos de tipo incorreto (conversão permissiva antiga).
+	// Temporário, enquanto o backend migra para os tipos corretos.
+	LenientCommandDecoding bool `json:"lenient_command_decoding"`
+
+	// Limites de entrada dos comandos, verificados antes da whitelist e nos
+	// comandos reenfileirados; acima deles o comando é rejected_oversized
+	MaxCommandArgs         int `json:"max_command_args"`
+	MaxCommandArgsBytes    int `json:"max_command_args_bytes"`
+	MaxCommandOptions      int `json:"max_command_options"`
+	MaxCommandOptionsDepth int `json:"max_command_options_depth"`
+
+	// Inclui o JSON bruto do system_profiler no inventário (apenas para depuração)
+	IncludeRawSystemProfiler bool `json:"include_raw_system_profiler"`
+
+	// Coleta a saúde SMART dos discos (smartctl costuma exigir root)
+	EnableSmart bool `json:"enable_smart"`
+
+	// Varredura das aplicações instaladas: workers em paralelo e prazo total
+	// (0 = padrão do collector; ao estourar, a lista sai com partial=true);
+	// compute_app_sizes soma o tamanho de cada bundle, bem mais lento
+	AppScanWorkers  int           `json:"app_scan_workers,omitempty"`
+	AppScanTimeout  time.Duration `json:"app_scan_timeout,omitempty"`
+	ComputeAppSizes bool          `json:"compute_app_sizes,omitempty"`
+
+	// Coleta as contas locais e os administradores (seção accounts)
+	EnableAccounts bool `json:"enable_accounts"`
+
+	// Processos com mais tráfego de rede desde a coleta anterior incluídos
+	// em network.top_talkers (0 = desligado; só no Windows)
+	NetworkTopTalkers int `json:"network_top_talkers,omitempty"`
+
+	// Impressões SHA-256 dos certificados conhecidos; no inventário só entram
+	// os certificados do trust store fora desta lista
+	CertificateAllowlist []string `json:"certificate_allowlist,omitempty"`
+
+	// Scripts locais cuja saída entra no inventário em custom.<name> (ver
+	// CustomCollector); só rodam scripts em custom_collector_dirs (vazio =
+	// diretório da plataforma) com dono root/administradores
+	CustomCollectors    []CustomCollector `json:"custom_collectors,omitempty"`
+	CustomCollectorDirs []string          `json:"custom_collector_dirs,omitempty"`
+
+	// Limites para o alerta backend_lag: inventórios enviados ainda não
+	// processados pelo backend, em quantidade ou em tempo
+	BackendLagMaxSequences int           `json:"backend_lag_max_sequences"`
+	BackendLagMaxAge       time.Duration `json:"backend_lag_max_age"`
+
+	// Adiamento de inventários completos e comandos adiáveis enquanto o
+	// usuário apresenta ("off", "presentation" ou "focus"), até o limite
+	PresencePolicy      string        `json:"presence_policy"`
+	MaxPresenceDeferral time.Duration `json:"max_presence_deferral"`
+
+	// Conflito de machine_id no registro (409): "regenerate" gera um novo
+	// machine_id com sufixo, "halt" suspende os envios até intervenção.
+	// Registros bloqueados (409/401) são tentados de novo no intervalo.
+	RegistrationConflictPolicy string        `json:"registration_conflict_policy"`
+	RegistrationRetryInterval  time.Duration `json:"registration_retry_interval"`
+
+	// Janela de migração de machine_id: enquanto o backend não confirma o
+	// vínculo, o ID antigo continua em uso e o novo vai em new_machine_id;
+	// ao fim da janela sem confirmação a migração é abandonada
+	MachineIDMigrationWindow time.Duration `json:"machine_id_migration_window"`
+
+	// Log local de eventos em JSON Lines para coletores SIEM (vazio desativa),
+	// independente da conexão com o backend; ver docs/EVENT_LOG.md
+	EventLogPath       string `json:"event_log_path,omitempty"`
+	EventLogMaxBytes   int64  `json:"event_log_max_bytes"`
+	EventLogMaxBackups int    `json:"event_log_max_backups"`
+	// Eventos recentes mantidos em memória para Agent.GetEvents e o comando
+	// get_events (padrão 1000)
+	EventBufferSize int `json:"event_buffer_size"`
+
+	// Envio das linhas de log a partir de um nível ao backend, para
+	// diagnóstico remoto (ver LogShippingConfig); nil desativa
+	LogShipping *LogShippingConfig `json:"log_shipping,omitempty"`
+
+	// Lock por machine_id para duas instâncias lado a lado (ex.: upgrade):
+	// a que não detém o lock fica em modo observador (wait) ou encerra (exit)
+	InstanceLockPolicy string `json:"instance_lock_policy"`
+	InstanceLockDir    string `json:"instance_lock_dir"`
+
+	// Injeção de falhas para testes de resiliência em staging; só vale com
+	// enabled=true e AGENTE_CHAOS=1 no ambiente (ver docs/CHAOS.md)
+	Chaos *chaos.Config `json:"chaos,omitempty"`
+
+	// Cifra ponta a ponta dos corpos para o backend, para relays sem
+	// confiança total (ver docs/ENVELOPE.md)
+	Envelope *EnvelopeConfig `json:"envelope,omitempty"`
+
+	// Comprime em gzip os corpos HTTP acima de http_compression_threshold
+	// bytes mesmo antes de o backend anunciar accepted_encodings
+	HTTPCompression          bool `json:"http_compression"`
+	HTTPCompressionThreshold int  `json:"http_compression_threshold,omitempty"`
+
+	// Limite de upload dos corpos HTTP em bytes/s (0 = sem limite) e janela
+	// diária, em hora local, para enviar inventários ("01:00-05:00"; vazia =
+	// a qualquer hora). Fora da janela o inventário espera na fila offline;
+	// heartbeats não esperam. Os dois valem na hora em uma recarga.
+	UploadRateLimit     int64  `json:"upload_rate_limit,omitempty"`
+	InventorySendWindow string `json:"inventory_send_window,omitempty"`
+
+	// Reconexão do WebSocket com backoff exponencial até ws_max_backoff;
+	// ws_max_reconnects -1 nunca desiste (0 = 10 tentativas)
+	WSMaxReconnects int           `json:"ws_max_reconnects,omitempty"`
+	WSMaxBackoff    time.Duration `json:"ws_max_backoff,omitempty"`
+
+	// Confirmação de mensagens no WebSocket (o backend precisa suportar):
+	// acks dos comandos recebidos e retransmissão dos resultados sem ack
+	// após reconectar; acima de ws_max_unacked pendentes (0 = 1000), os mais
+	// antigos vão para a fila offline e seguem por HTTP
+	WSMessageAcks bool `json:"ws_message_acks"`
+	WSMaxUnacked  int  `json:"ws_max_unacked,omitempty"`
+
+	// Percentuais de CPU, memória e disco que tornam a saúde do heartbeat
+	// warning ou critical (zeros valem DefaultHealthThresholds)
+	HealthThresholds HealthThresholds `json:"health_thresholds"`
+
+	// Regras de alerta locais (ver AlertRuleConfig); ausente usa
+	// DefaultAlertRules e uma lista vazia desliga os alertas
+	AlertRules []AlertRuleConfig `json:"alert_rules"`
+
+	// Prioridade e custo das seções do inventário e limite de tamanho; o
+	// planner decide o que descartar (ver docs/INVENTORY_PLAN.md)
+	InventoryPlan *collector.PlanConfig `json:"inventory_plan,omitempty"`
+
+	// Seções do inventário ligadas ou desligadas nesta máquina (ex.:
+	// {"software": false}); o backend não religa o que foi desligado aqui
+	CollectorSections map[string]bool `json:"collector_sections,omitempty"`
+
+	// Processos do inventário: os max_processes maiores por
+	// process_sort_key ("cpu" ou "memory"); processos abaixo dos dois
+	// mínimos são descartados (zero desliga o mínimo)
+	ProcessSortKey        string  `json:"process_sort_key,omitempty"`
+	MinProcessCPUPercent  float64 `json:"min_process_cpu_percent,omitempty"`
+	MinProcessMemoryBytes uint64  `json:"min_process_memory_bytes,omitempty"`
 }
 
-// configJSON é usado para deserialização JSON com segundos
+// EnvelopeConfig é o bloco "envelope" da configuração
+type EnvelopeConfig struct {
+	Enabled bool `json:"enabled"`
+	// BackendPublicKey é a chave X25519 do backend em base64; vazia, a chave
+	// vem na resposta do registro
+	BackendPublicKey string `json:"backend_public_key,omitempty"`
+	// BackendKeyFingerprint ("sha256:<hex>") pina a chave do backend, venha
+	// ela da configuração ou do registro
+	BackendKeyFingerprint string `json:"backend_key_fingerprint"`
+}
+
+// configJSON é usado para deserialização JSON; intervalos aceitam segundos
+// (formato antigo) ou durações como "90s" e "2h30m"
 type configJSON struct {
-	MachineID          string `json:"machine_id"`
-	BackendURL         string `json:"backend_url"`
-	WebSocketURL       string `json:"websocket_url"`
-	Token              string `json:"token"`
-	HeartbeatInterval  int    `json:"heartbeat_interval"`
-	CollectionInterval int    `json:"collection_interval"`
-	InventoryInterval  int    `json:"inventory_interval"`
-	CommandTimeout     int    `json:"command_timeout"`
-	RetryInterval      int    `json:"retry_interval"`
-	ReconnectInterval  int    `json:"reconnect_interval"`
-	MaxRetries         int    `json:"max_retries"`
-	LogLevel           string `json:"log_level"`
-	Debug              bool   `json:"debug"`
+	MachineID          string           `json:"machine_id"`
+	BackendURL         string           `json:"backend_url"`
+	WebSocketURL       string           `json:"websocket_url"`
+	Token              string           `json:"token"`
+	HeartbeatInterval  timeutil.Seconds `json:"heartbeat_interval"`
+	CollectionInterval timeutil.Seconds `json:"collection_interval"`
+	InventoryInterval  timeutil.Seconds `json:"inventory_interval"`
+	CommandTimeout     timeutil.Seconds `json:"command_timeout"`
+	RetryInterval      timeutil.Seconds `json:"retry_interval"`
+	ReconnectInterval  timeutil.Seconds `json:"reconnect_interval"`
+	MaxRetries         int              `json:"max_retries"`
+	LogLevel           string           `json:"log_level"`
+	Debug              bool             `json:"debug"`
+	DataDir            string           `json:"data_dir"`
+	ControlSocket      string           `json:"control_socket"`
+
+	MaxConcurrentCommands int `json:"max_concurrent_commands"`
+
+	CircuitBreakerFailureThreshold     int              `json:"circuit_breaker_failure_threshold"`
+	CircuitBreakerResetTimeout         timeutil.Seconds `json:"circuit_breaker_reset_timeout"`
+	CircuitBreakerBulkFailureThreshold int              `json:"circuit_breaker_bulk_failure_threshold"`
+	CircuitBreakerBulkResetTimeout     timeutil.Seconds `json:"circuit_breaker_bulk_reset_timeout"`
+
+	Tokens []string `json:"tokens"`
+
+	BackendURLs       []string `json:"backend_urls"`
+	WebSocketURLs     []string `json:"websocket_urls"`
+	FailoverThreshold int      `json:"failover_threshold"`
+
+	Offline            bool   `json:"offline"`
+	OfflineArchiveDir  string `json:"offline_archive_dir"`
+	OfflineArchiveSize int    `json:"offline_archive_size"`
+
+	EnrollmentKey        string `json:"enrollment_key"`
+	CredentialPassphrase string `json:"credential_passphrase"`
+
+	TLSClientCertFile string `json:"tls_client_cert_file"`
+	TLSClientKeyFile  string `json:"tls_client_key_file"`
+	TLSCACertFile     string `json:"tls_ca_cert_file"`
+
+	TLSSkipVerify      bool     `json:"tls_skip_verify"`
+	PinnedCertificates []string `json:"pinned_certificates"`
+
+	ProxyURL string `json:"proxy_url"`
+
+	SnapshotRingSize         int   `json:"snapshot_ring_size"`
+	SnapshotCompressionLevel *int  `json:"snapshot_compression_level"`
+	SnapshotMaxBytes         int64 `json:"snapshot_max_bytes"`
+
+	HTTPProbeAllowedHosts []string `json:"http_probe_allowed_hosts"`
+	CommandWorkingDirs    []string `json:"command_working_dirs"`
+	FetchFileDirs         []string `json:"fetch_file_dirs"`
+	FetchFileMaxBytes     int64    `json:"fetch_file_max_bytes"`
+	AuditLogMaxBytes      int64    `json:"audit_log_max_bytes"`
+	AuditLogMaxFiles      int      `json:"audit_log_max_files"`
+	ScriptPublicKeys      []string `json:"script_public_keys"`
+	WhitelistPublicKeys   []string `json:"whitelist_public_keys"`
+
+	Schedules []Schedule `json:"schedules"`
+
+	LenientCommandDecoding   bool `json:"lenient_command_decoding"`
+	IncludeRawSystemProfiler bool `json:"include_raw_system_profiler"`
+	EnableSmart              bool `json:"enable_smart"`
+	EnableAccounts           bool `json:"enable_accounts"`
+	NetworkTopTalkers        int  `json:"network_top_talkers"`
+
+	AppScanWorkers  int              `json:"app_scan_workers"`
+	AppScanTimeout  timeutil.Seconds `json:"app_scan_timeout"`
+	ComputeAppSizes bool             `json:"compute_app_sizes"`
+
+	CertificateAllowlist []string `json:"certificate_allowlist"`
+
+	CustomCollectors    []CustomCollector `json:"custom_collectors"`
+	CustomCollectorDirs []string          `json:"custom_collector_dirs"`
+
+	MaxCommandArgs         int `json:"max_command_args"`
+	MaxCommandArgsBytes    int `json:"max_command_args_bytes"`
+	MaxCommandOptions      int `json:"max_command_options"`
+	MaxCommandOptionsDepth int `json:"max_command_options_depth"`
+
+	BackendLagMaxSequences int              `json:"backend_lag_max_sequences"`
+	BackendLagMaxAge       timeutil.Seconds `json:"backend_lag_max_age"`
+
+	PresencePolicy      string           `json:"presence_policy"`
+	MaxPresenceDeferral timeutil.Seconds `json:"max_presence_deferral"`
+
+	RegistrationConflictPolicy string           `json:"registration_conflict_policy"`
+	RegistrationRetryInterval  timeutil.Seconds `json:"registration_retry_interval"`
+
+	MachineIDMigrationWindow timeutil.Seconds `json:"machine_id_migration_window"`
+
+	EventLogPath       string `json:"event_log_path"`
+	EventLogMaxBytes   int64  `json:"event_log_max_bytes"`
+	EventLogMaxBackups int    `json:"event_log_max_backups"`
+	EventBufferSize    int    `json:"event_buffer_size"`
+
+	LogShipping *LogShippingConfig `json:"log_shipping"`
+
+	InstanceLockPolicy string `json:"instance_lock_policy"`
+	InstanceLockDir    string `json:"instance_lock_dir"`
+
+	Chaos *chaos.Config `json:"chaos"`
+
+	Envelope *EnvelopeConfig `json:"envelope"`
+
+	HTTPCompression          bool `json:"http_compression"`
+	HTTPCompressionThreshold int  `json:"http_compression_threshold"`
+
+	UploadRateLimit     int64  `json:"upload_rate_limit"`
+	InventorySendWindow string `json:"inventory_send_window"`
+
+	WSMaxReconnects int              `json:"ws_max_reconnects"`
+	WSMaxBackoff    timeutil.Seconds `json:"ws_max_backoff"`
+
+	WSMessageAcks bool `json:"ws_message_acks"`
+	WSMaxUnacked  int  `json:"ws_max_unacked"`
+
+	HealthThresholds HealthThresholds `json:"health_thresholds"`
+
+	AlertRules []AlertRuleConfig `json:"alert_rules"`
+
+	InventoryPlan *collector.PlanConfig `json:"inventory_plan"`
+
+	CollectorSections map[string]bool `json:"collector_sections"`
+
+	ProcessSortKey        string  `json:"process_sort_key"`
+	MinProcessCPUPercent  float64 `json:"min_process_cpu_percent"`
+	MinProcessMemoryBytes uint64  `json:"min_process_memory_bytes"`
 }
 
-// LoadConfig carrega a configuração de um arquivo JSON
+// LoadConfig carrega a configuração de um arquivo JSON, YAML ou TOML,
+// conforme a extensão (ver ConfigFormat)
 func LoadConfig(path string) (*Config, error) {
 	// Ler arquivo de configuração
 	data, err := os.ReadFile(path)
@@ -50,10 +428,23 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("erro ao ler arquivo de configuração %s: %w", path, err)
 	}
 
+	// YAML e TOML viram JSON com os mesmos nomes de campo
+	format := ConfigFormat(path)
+	document, err := configDocumentJSON(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao deserializar configuração %s: %w", path, err)
+	}
+
+	// Substituir ${VAR} e procurar campos desconhecidos (erros de digitação)
+	document, problems, err := prepareConfigDocument(document)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao deserializar configuração: %w", err)
+	}
+
 	// Deserializar JSON em struct temporária
 	var tempConfig configJSON
-	if err := json.Unmarshal(data, &tempConfig); err != nil {
-		return nil, fmt.Errorf("erro ao deserializar configuração: %w", err)
+	if err := json.Unmarshal(document, &tempConfig); err != nil {
+		return nil, fmt.Errorf("erro ao deserializar configuração %s: %w", path, decodeError(err, data, format))
 	}
 
 	// Converter para Config com time.Duration
@@ -62,28 +453,235 @@ func LoadConfig(path string) (*Config, error) {
 		BackendURL:         tempConfig.BackendURL,
 		WebSocketURL:       tempConfig.WebSocketURL,
 		Token:              tempConfig.Token,
-		HeartbeatInterval:  time.Duration(tempConfig.HeartbeatInterval) * time.Second,
-		CollectionInterval: time.Duration(tempConfig.CollectionInterval) * time.Second,
-		InventoryInterval:  time.Duration(tempConfig.InventoryInterval) * time.Second,
-		CommandTimeout:     time.Duration(tempConfig.CommandTimeout) * time.Second,
-		RetryInterval:      time.Duration(tempConfig.RetryInterval) * time.Second,
-		ReconnectInterval:  time.Duration(tempConfig.ReconnectInterval) * time.Second,
+		HeartbeatInterval:  tempConfig.HeartbeatInterval.Duration(),
+		CollectionInterval: tempConfig.CollectionInterval.Duration(),
+		InventoryInterval:  tempConfig.InventoryInterval.Duration(),
+		CommandTimeout:     tempConfig.CommandTimeout.Duration(),
+		RetryInterval:      tempConfig.RetryInterval.Duration(),
+		ReconnectInterval:  tempConfig.ReconnectInterval.Duration(),
 		MaxRetries:         tempConfig.MaxRetries,
 		LogLevel:           tempConfig.LogLevel,
 		Debug:              tempConfig.Debug,
+		DataDir:            tempConfig.DataDir,
+		ControlSocket:      tempConfig.ControlSocket,
+		SnapshotRingSize:   tempConfig.SnapshotRingSize,
+		SnapshotMaxBytes:   tempConfig.SnapshotMaxBytes,
+
+		MaxConcurrentCommands: tempConfig.MaxConcurrentCommands,
+
+		CircuitBreakerFailureThreshold:     tempConfig.CircuitBreakerFailureThreshold,
+		CircuitBreakerResetTimeout:         tempConfig.CircuitBreakerResetTimeout.Duration(),
+		CircuitBreakerBulkFailureThreshold: tempConfig.CircuitBreakerBulkFailureThreshold,
+		CircuitBreakerBulkResetTimeout:     tempConfig.CircuitBreakerBulkResetTimeout.Duration(),
+
+		Tokens: tempConfig.Tokens,
+
+		BackendURLs:       tempConfig.BackendURLs,
+		WebSocketURLs:     tempConfig.WebSocketURLs,
+		FailoverThreshold: tempConfig.FailoverThreshold,
+
+		Offline:            tempConfig.Offline,
+		OfflineArchiveDir:  tempConfig.OfflineArchiveDir,
+		OfflineArchiveSize: tempConfig.OfflineArchiveSize,
+
+		EnrollmentKey:        tempConfig.EnrollmentKey,
+		CredentialPassphrase: tempConfig.CredentialPassphrase,
+
+		TLSClientCertFile: tempConfig.TLSClientCertFile,
+		TLSClientKeyFile:  tempConfig.TLSClientKeyFile,
+		TLSCACertFile:     tempConfig.TLSCACertFile,
+
+		TLSSkipVerify:      tempConfig.TLSSkipVerify,
+		PinnedCertificates: tempConfig.PinnedCertificates,
+
+		ProxyURL: tempConfig.ProxyURL,
+
+		HTTPProbeAllowedHosts:  tempConfig.HTTPProbeAllowedHosts,
+		CommandWorkingDirs:     tempConfig.CommandWorkingDirs,
+		FetchFileDirs:          tempConfig.FetchFileDirs,
+		FetchFileMaxBytes:      tempConfig.FetchFileMaxBytes,
+		AuditLogMaxBytes:       tempConfig.AuditLogMaxBytes,
+		AuditLogMaxFiles:       tempConfig.AuditLogMaxFiles,
+		ScriptPublicKeys:       tempConfig.ScriptPublicKeys,
+		WhitelistPublicKeys:    tempConfig.WhitelistPublicKeys,
+		Schedules:              tempConfig.Schedules,
+		LenientCommandDecoding: tempConfig.LenientCommandDecoding,
+
+		IncludeRawSystemProfiler: tempConfig.IncludeRawSystemProfiler,
+		EnableSmart:              tempConfig.EnableSmart,
+		EnableAccounts:           tempConfig.EnableAccounts,
+		NetworkTopTalkers:        tempConfig.NetworkTopTalkers,
+		CertificateAllowlist:     tempConfig.CertificateAllowlist,
+
+		AppScanWorkers:  tempConfig.AppScanWorkers,
+		AppScanTimeout:  tempConfig.AppScanTimeout.Duration(),
+		ComputeAppSizes: tempConfig.ComputeAppSizes,
+
+		CustomCollectors:    tempConfig.CustomCollectors,
+		CustomCollectorDirs: tempConfig.CustomCollectorDirs,
+
+		MaxCommandArgs:         tempConfig.MaxCommandArgs,
+		MaxCommandArgsBytes:    tempConfig.MaxCommandArgsBytes,
+		MaxCommandOptions:      tempConfig.MaxCommandOptions,
+		MaxCommandOptionsDepth: tempConfig.MaxCommandOptionsDepth,
+
+		BackendLagMaxSequences: tempConfig.BackendLagMaxSequences,
+		BackendLagMaxAge:       tempConfig.BackendLagMaxAge.Duration(),
+
+		PresencePolicy:      tempConfig.PresencePolicy,
+		MaxPresenceDeferral: tempConfig.MaxPresenceDeferral.Duration(),
+
+		RegistrationConflictPolicy: tempConfig.RegistrationConflictPolicy,
+		RegistrationRetryInterval:  tempConfig.RegistrationRetryInterval.Duration(),
+
+		MachineIDMigrationWindow: tempConfig.MachineIDMigrationWindow.Duration(),
+
+		EventLogPath:       tempConfig.EventLogPath,
+		EventLogMaxBytes:   tempConfig.EventLogMaxBytes,
+		EventLogMaxBackups: tempConfig.EventLogMaxBackups,
+		EventBufferSize:    tempConfig.EventBufferSize,
+
+		LogShipping: tempConfig.LogShipping,
+
+		InstanceLockPolicy: tempConfig.InstanceLockPolicy,
+		InstanceLockDir:    tempConfig.InstanceLockDir,
+
+		Chaos: tempConfig.Chaos,
+
+		Envelope: tempConfig.Envelope,
+
+		HTTPCompression:          tempConfig.HTTPCompression,
+		HTTPCompressionThreshold: tempConfig.HTTPCompressionThreshold,
+
+		UploadRateLimit:     tempConfig.UploadRateLimit,
+		InventorySendWindow: tempConfig.InventorySendWindow,
+
+		WSMaxReconnects: tempConfig.WSMaxReconnects,
+		WSMaxBackoff:    tempConfig.WSMaxBackoff.Duration(),
+
+		WSMessageAcks: tempConfig.WSMessageAcks,
+		WSMaxUnacked:  tempConfig.WSMaxUnacked,
+
+		HealthThresholds: tempConfig.HealthThresholds,
+
+		AlertRules: tempConfig.AlertRules,
+
+		InventoryPlan: tempConfig.InventoryPlan,
+
+		CollectorSections: tempConfig.CollectorSections,
+
+		ProcessSortKey:        tempConfig.ProcessSortKey,
+		MinProcessCPUPercent:  tempConfig.MinProcessCPUPercent,
+		MinProcessMemoryBytes: tempConfig.MinProcessMemoryBytes,
+	}
+
+	// Chave de enrollment passada pelo instalador ou por -enrollment-key
+	if config.EnrollmentKey == "" {
+		config.EnrollmentKey = os.Getenv(EnrollmentKeyEnv)
+	}
+
+	// Nível 0 (sem compressão) é válido, por isso o ponteiro
+	config.SnapshotCompressionLevel = gzip.DefaultCompression
+	if tempConfig.SnapshotCompressionLevel != nil {
+		config.SnapshotCompressionLevel = *tempConfig.SnapshotCompressionLevel
 	}
 
-	// Validar configuração
+	// Validar configuração; todos os problemas saem juntos
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("configuração inválida: %w", err)
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			return nil, fmt.Errorf("configuração inválida: %w", err)
+		}
+		problems = append(problems, validation.Problems...)
 	}
 
 	// Aplicar valores padrão
 	config.ApplyDefaults()
 
+	// Diretórios de dados e logs, já com os padrões
+	problems = append(problems, config.pathProblems()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("configuração inválida: %w", &ValidationError{Problems: problems})
+	}
+
 	return &config, nil
 }
 
+// ConfigureCollector aplica ao collector as opções de coleta da
+// configuração; usado pelo agente e pelo subcomando collect
+func (c *Config) ConfigureCollector(sc *collector.SystemCollector) error {
+	sc.SetIncludeRawSystemProfiler(c.IncludeRawSystemProfiler)
+	sc.SetEnableSmart(c.EnableSmart)
+	sc.SetEnableAccounts(c.EnableAccounts)
+	sc.SetNetworkTopTalkers(c.NetworkTopTalkers)
+	sc.SetAppScan(c.AppScanWorkers, c.AppScanTimeout, c.ComputeAppSizes)
+	sc.SetCertificateAllowlist(c.CertificateAllowlist)
+	if err := sc.SetSections(c.CollectorSections); err != nil {
+		return fmt.Errorf("invalid collector sections: %w", err)
+	}
+	if err := sc.SetProcessSelection(c.ProcessSortKey, c.MinProcessCPUPercent, c.MinProcessMemoryBytes); err != nil {
+		return fmt.Errorf("invalid process selection: %w", err)
+	}
+	return nil
+}
+
+// ExecutorConfig monta a configuração do executor (limites, diretórios e
+// chaves de script); os callbacks ficam por conta de quem chama. Usado pelo
+// agente e pelo subcomando exec.
+func (c *Config) ExecutorConfig(logger logging.Logger) *executor.Config {
+	return &executor.Config{
+		DefaultTimeout: c.CommandTimeout,
+		MaxConcurrent:  c.MaxConcurrentCommands,
+		Logger:         logger,
+
+		WorkingDirPrefixes:    c.CommandWorkingDirs,
+		FetchFileDirs:         c.fetchFileDirs(),
+		FetchFileMaxBytes:     c.FetchFileMaxBytes,
+		HTTPProbeAllowedHosts: c.HTTPProbeAllowedHosts,
+
+		ScriptPublicKeys: c.ScriptPublicKeys,
+
+		WhitelistPublicKeys: c.WhitelistPublicKeys,
+		WhitelistStatePath:  filepath.Join(c.DataDir, whitelistStateFile),
+
+		AuditLogDir:      c.AuditLogDir(),
+		AuditLogMaxBytes: c.AuditLogMaxBytes,
+		AuditLogMaxFiles: c.AuditLogMaxFiles,
+
+		CollectorScriptDirs: c.CustomCollectorDirs,
+	}
+}
+
+// auditLogDir guarda, no data_dir, o log de auditoria dos comandos
+const auditLogDir = "audit"
+
+// AuditLogDir é o diretório do log de auditoria dos comandos
+func (c *Config) AuditLogDir() string {
+	return filepath.Join(c.DataDir, auditLogDir)
+}
+
+// fetchFileDirs retorna os diretórios do fetch_file: os configurados ou,
+// sem configuração, os logs do sistema e o diretório do log de eventos. O
+// data_dir (tokens, fila, estado) nunca entra por padrão.
+func (c *Config) fetchFileDirs() []string {
+	if len(c.FetchFileDirs) > 0 {
+		return c.FetchFileDirs
+	}
+
+	dirs := executor.DefaultFetchFileDirs()
+	if c.EventLogPath == "" {
+		return dirs
+	}
+	eventDir, err := filepath.Abs(filepath.Dir(c.EventLogPath))
+	if err != nil {
+		return dirs
+	}
+	if dataDir, err := filepath.Abs(c.DataDir); err == nil && dataDir == eventDir {
+		return dirs
+	}
+	return append(dirs, eventDir)
+}
+
 // Validate valida os campos obrigatórios da configuração
 func (c *Config) Validate() error {
 	var errors []string
@@ -93,24 +691,257 @@ func (c *Config) Validate() error {
 	// 	errors = append(errors, "machine_id é obrigatório")
 	// }
 
-	if c.BackendURL == "" {
+	// No modo offline backend e token são opcionais (e ignorados)
+	if c.BackendURL == "" && !c.Offline {
 		errors = append(errors, "backend_url é obrigatório")
 	}
+	errors = append(errors, validateEndpoint("backend_url", c.BackendURL, "http", "https")...)
 
-	if c.WebSocketURL == "" {
+	if c.WebSocketURL == "" && !c.Offline {
 		errors = append(errors, "websocket_url é obrigatório")
 	}
+	errors = append(errors, validateEndpoint("websocket_url", c.WebSocketURL, "ws", "wss")...)
+
+	for i, backendURL := range c.BackendURLs {
+		errors = append(errors, validateEndpoint(fmt.Sprintf("backend_urls[%d]", i), backendURL, "http", "https")...)
+	}
+	for i, webSocketURL := range c.WebSocketURLs {
+		errors = append(errors, validateEndpoint(fmt.Sprintf("websocket_urls[%d]", i), webSocketURL, "ws", "wss")...)
+	}
+	if c.FailoverThreshold < 0 {
+		errors = append(errors, "failover_threshold não pode ser negativo")
+	}
 
-	if c.Token == "" {
-		errors = append(errors, "token é obrigatório")
+	// Depois do enrollment o token da máquina fica em data_dir
+	if c.Token == "" && len(c.Tokens) == 0 && c.EnrollmentKey == "" && !c.Offline && !credentials.Stored(c.dataDir()) {
+		errors = append(errors, "token (ou tokens, ou enrollment_key) é obrigatório")
 	}
 
-	if c.HeartbeatInterval <= 0 {
+	if c.OfflineArchiveSize < 0 {
+		errors = append(errors, "offline_archive_size não pode ser negativo")
+	}
+
+	if c.HeartbeatInterval <= 0 && !c.Offline {
 		errors = append(errors, "heartbeat_interval deve ser maior que 0")
 	}
 
+	// Zero usa o padrão; negativos são sempre engano
+	for _, interval := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"collection_interval", c.CollectionInterval},
+		{"inventory_interval", c.InventoryInterval},
+		{"command_timeout", c.CommandTimeout},
+		{"retry_interval", c.RetryInterval},
+		{"reconnect_interval", c.ReconnectInterval},
+		{"backend_lag_max_age", c.BackendLagMaxAge},
+		{"max_presence_deferral", c.MaxPresenceDeferral},
+		{"registration_retry_interval", c.RegistrationRetryInterval},
+		{"circuit_breaker_reset_timeout", c.CircuitBreakerResetTimeout},
+		{"circuit_breaker_bulk_reset_timeout", c.CircuitBreakerBulkResetTimeout},
+	} {
+		if interval.value < 0 {
+			errors = append(errors, fmt.Sprintf("%s não pode ser negativo", interval.field))
+		}
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "", "debug", "info", "warning", "warn", "error", "fatal":
+	default:
+		errors = append(errors, "log_level deve ser debug, info, warning, error ou fatal")
+	}
+
+	if c.MaxConcurrentCommands < 0 {
+		errors = append(errors, "max_concurrent_commands não pode ser negativo")
+	}
+
+	if c.CircuitBreakerFailureThreshold < 0 {
+		errors = append(errors, "circuit_breaker_failure_threshold não pode ser negativo")
+	}
+
+	if c.CircuitBreakerBulkFailureThreshold < 0 {
+		errors = append(errors, "circuit_breaker_bulk_failure_threshold não pode ser negativo")
+	}
+
+	if c.SnapshotCompressionLevel < gzip.HuffmanOnly || c.SnapshotCompressionLevel > gzip.BestCompression {
+		errors = append(errors, "snapshot_compression_level deve estar entre -2 e 9")
+	}
+
+	if c.SnapshotMaxBytes < 0 {
+		errors = append(errors, "snapshot_max_bytes não pode ser negativo")
+	}
+
+	switch c.PresencePolicy {
+	case "", PresencePolicyOff, PresencePolicyPresentation, PresencePolicyFocus:
+	default:
+		errors = append(errors, "presence_policy deve ser off, presentation ou focus")
+	}
+
+	switch c.RegistrationConflictPolicy {
+	case "", RegistrationConflictRegenerate, RegistrationConflictHalt:
+	default:
+		errors = append(errors, "registration_conflict_policy deve ser regenerate ou halt")
+	}
+
+	if c.MachineIDMigrationWindow < 0 {
+		errors = append(errors, "machine_id_migration_window não pode ser negativo")
+	}
+
+	if c.EventLogMaxBytes < 0 || c.EventLogMaxBackups < 0 {
+		errors = append(errors, "event_log_max_bytes e event_log_max_backups não podem ser negativos")
+	}
+
+	if c.EventBufferSize < 0 {
+		errors = append(errors, "event_buffer_size não pode ser negativo")
+	}
+
+	if c.LogShipping != nil {
+		errors = append(errors, c.LogShipping.Validate()...)
+	}
+
+	switch c.InstanceLockPolicy {
+	case "", InstanceLockWait, InstanceLockExit:
+	default:
+		errors = append(errors, "instance_lock_policy deve ser wait ou exit")
+	}
+
+	if c.Chaos != nil {
+		errors = append(errors, c.Chaos.Validate()...)
+	}
+
+	if c.HTTPCompressionThreshold < 0 {
+		errors = append(errors, "http_compression_threshold não pode ser negativo")
+	}
+
+	if c.NetworkTopTalkers < 0 {
+		errors = append(errors, "network_top_talkers não pode ser negativo")
+	}
+
+	if c.UploadRateLimit < 0 {
+		errors = append(errors, "upload_rate_limit não pode ser negativo")
+	}
+
+	if _, err := comms.ParseSendWindow(c.InventorySendWindow); err != nil {
+		errors = append(errors, fmt.Sprintf("inventory_send_window inválida: %v", err))
+	}
+
+	if c.WSMaxReconnects < -1 {
+		errors = append(errors, "ws_max_reconnects deve ser -1 (sem limite) ou maior ou igual a 0")
+	}
+
+	if c.WSMaxBackoff < 0 {
+		errors = append(errors, "ws_max_backoff não pode ser negativo")
+	}
+
+	if c.WSMaxUnacked < 0 {
+		errors = append(errors, "ws_max_unacked não pode ser negativo")
+	}
+
+	errors = append(errors, c.HealthThresholds.Validate()...)
+
+	// Com o envelope ligado, o agente não inicia sem a chave do backend pinada
+	if c.Envelope != nil && c.Envelope.Enabled {
+		if c.Envelope.BackendKeyFingerprint == "" {
+			errors = append(errors, "envelope.backend_key_fingerprint é obrigatório com envelope.enabled")
+		} else if c.Envelope.BackendPublicKey != "" {
+			if _, err := comms.ParseEnvelopePublicKey(c.Envelope.BackendPublicKey, c.Envelope.BackendKeyFingerprint); err != nil {
+				errors = append(errors, fmt.Sprintf("envelope.backend_public_key inválida: %v", err))
+			}
+		}
+	}
+
+	if c.InventoryPlan != nil {
+		if _, err := collector.NewPlanner(c.InventoryPlan); err != nil {
+			errors = append(errors, fmt.Sprintf("inventory_plan inválido: %v", err))
+		}
+	}
+
+	for _, fingerprint := range c.CertificateAllowlist {
+		if !collector.ValidFingerprint(fingerprint) {
+			errors = append(errors, fmt.Sprintf("certificate_allowlist: %q não é uma impressão SHA-256", fingerprint))
+		}
+	}
+
+	if err := ValidateCustomCollectors(c.CustomCollectors); err != nil {
+		errors = append(errors, fmt.Sprintf("custom_collectors inválido: %v", err))
+	}
+	for _, dir := range c.CustomCollectorDirs {
+		if !filepath.IsAbs(dir) {
+			errors = append(errors, fmt.Sprintf("custom_collector_dirs deve conter apenas caminhos absolutos: %s", dir))
+		}
+	}
+
+	if err := collector.ValidateSections(c.CollectorSections); err != nil {
+		errors = append(errors, fmt.Sprintf("collector_sections inválido: %v", err))
+	}
+
+	if err := collector.ValidateProcessSortKey(c.ProcessSortKey); err != nil {
+		errors = append(errors, fmt.Sprintf("process_sort_key inválido: %v", err))
+	}
+	if c.AppScanWorkers < 0 {
+		errors = append(errors, "app_scan_workers não pode ser negativo")
+	}
+	if c.AppScanTimeout < 0 {
+		errors = append(errors, "app_scan_timeout não pode ser negativo")
+	}
+
+	if c.MinProcessCPUPercent < 0 {
+		errors = append(errors, "min_process_cpu_percent não pode ser negativo")
+	}
+
+	if err := ValidateAlertRules(c.AlertRules); err != nil {
+		errors = append(errors, fmt.Sprintf("alert_rules inválido: %v", err))
+	}
+
+	if err := ValidateSchedules(c.Schedules); err != nil {
+		errors = append(errors, fmt.Sprintf("schedules inválido: %v", err))
+	}
+
+	for _, dir := range c.CommandWorkingDirs {
+		if !filepath.IsAbs(dir) {
+			errors = append(errors, fmt.Sprintf("command_working_dirs deve conter apenas caminhos absolutos: %s", dir))
+		}
+	}
+
+	for _, dir := range c.FetchFileDirs {
+		if !filepath.IsAbs(dir) {
+			errors = append(errors, fmt.Sprintf("fetch_file_dirs deve conter apenas caminhos absolutos: %s", dir))
+		}
+	}
+
+	if c.FetchFileMaxBytes < 0 {
+		errors = append(errors, "fetch_file_max_bytes não pode ser negativo")
+	}
+
+	if c.AuditLogMaxBytes < 0 || c.AuditLogMaxFiles < 0 {
+		errors = append(errors, "audit_log_max_bytes e audit_log_max_files não podem ser negativos")
+	}
+
+	if _, err := executor.ParseScriptPublicKeys(c.ScriptPublicKeys); err != nil {
+		errors = append(errors, fmt.Sprintf("script_public_keys inválido: %v", err))
+	}
+	if _, err := executor.ParseWhitelistPublicKeys(c.WhitelistPublicKeys); err != nil {
+		errors = append(errors, fmt.Sprintf("whitelist_public_keys inválido: %v", err))
+	}
+
+	// Carrega os arquivos para que certificado, chave ou CA inválidos sejam
+	// recusados aqui, também na recarga, e não na próxima conexão
+	if _, err := comms.BuildTLSConfig(c.TLSFiles(), c.TLSSkipVerify); err != nil {
+		errors = append(errors, fmt.Sprintf("configuração TLS inválida: %v", err))
+	}
+	if err := comms.ValidateCertificatePins(c.PinnedCertificates); err != nil {
+		errors = append(errors, fmt.Sprintf("pinned_certificates inválido: %v", err))
+	}
+
+	if c.ProxyURL != "" {
+		if _, err := comms.ParseProxyURL(c.ProxyURL); err != nil {
+			errors = append(errors, fmt.Sprintf("proxy_url inválido: %v", err))
+		}
+	}
+
 	if len(errors) > 0 {
-		return fmt.Errorf("erros de validação: %s", strings.Join(errors, ", "))
+		return &ValidationError{Problems: errors}
 	}
 
 	return nil
@@ -130,6 +961,10 @@ func (c *Config) ApplyDefaults() {
 		c.CommandTimeout = 30 * time.Second // 30 segundos
 	}
 
+	if c.MaxConcurrentCommands <= 0 {
+		c.MaxConcurrentCommands = 10
+	}
+
 	if c.RetryInterval <= 0 {
 		c.RetryInterval = 5 * time.Second // 5 segundos
 	}
@@ -142,16 +977,180 @@ func (c *Config) ApplyDefaults() {
 		c.MaxRetries = 3
 	}
 
+	if c.CircuitBreakerFailureThreshold <= 0 {
+		c.CircuitBreakerFailureThreshold = comms.DefaultBreakerFailureThreshold
+	}
+
+	if c.CircuitBreakerResetTimeout <= 0 {
+		c.CircuitBreakerResetTimeout = comms.DefaultBreakerResetTimeout
+	}
+
 	if c.LogLevel == "" {
 		c.LogLevel = "info"
 	}
+
+	c.DataDir = c.dataDir()
+
+	if c.OfflineArchiveDir == "" {
+		c.OfflineArchiveDir = filepath.Join(c.DataDir, "inventory_archive")
+	}
+	if c.OfflineArchiveSize == 0 {
+		c.OfflineArchiveSize = 96
+	}
+
+	if c.ControlSocket == "" {
+		c.ControlSocket = filepath.Join(c.DataDir, "agent.sock")
+	}
+
+	if c.SnapshotRingSize <= 0 {
+		c.SnapshotRingSize = 24
+	}
+
+	if c.SnapshotMaxBytes == 0 {
+		c.SnapshotMaxBytes = defaultSnapshotMaxBytes
+	}
+
+	if c.MaxCommandArgs <= 0 {
+		c.MaxCommandArgs = comms.DefaultMaxCommandArgs
+	}
+
+	if c.MaxCommandArgsBytes <= 0 {
+		c.MaxCommandArgsBytes = comms.DefaultMaxCommandArgsBytes
+	}
+
+	if c.MaxCommandOptions <= 0 {
+		c.MaxCommandOptions = comms.DefaultMaxCommandOptions
+	}
+
+	if c.MaxCommandOptionsDepth <= 0 {
+		c.MaxCommandOptionsDepth = comms.DefaultMaxCommandOptionsDepth
+	}
+
+	if c.BackendLagMaxSequences <= 0 {
+		c.BackendLagMaxSequences = 3
+	}
+
+	if c.BackendLagMaxAge <= 0 {
+		c.BackendLagMaxAge = 15 * time.Minute
+	}
+
+	if c.PresencePolicy == "" {
+		c.PresencePolicy = PresencePolicyPresentation
+	}
+
+	if c.MaxPresenceDeferral <= 0 {
+		c.MaxPresenceDeferral = 2 * time.Hour
+	}
+
+	if c.RegistrationConflictPolicy == "" {
+		c.RegistrationConflictPolicy = RegistrationConflictRegenerate
+	}
+
+	if c.RegistrationRetryInterval <= 0 {
+		c.RegistrationRetryInterval = 15 * time.Minute
+	}
+
+	if c.MachineIDMigrationWindow <= 0 {
+		c.MachineIDMigrationWindow = 7 * 24 * time.Hour
+	}
+
+	if c.EventLogMaxBytes <= 0 {
+		c.EventLogMaxBytes = 10 * 1024 * 1024 // 10 MB
+	}
+
+	if c.EventLogMaxBackups <= 0 {
+		c.EventLogMaxBackups = 5
+	}
+
+	if c.EventBufferSize <= 0 {
+		c.EventBufferSize = events.DefaultRingCapacity
+	}
+
+	if c.InstanceLockPolicy == "" {
+		c.InstanceLockPolicy = InstanceLockWait
+	}
+
+	if c.InstanceLockDir == "" {
+		c.InstanceLockDir = c.DataDir
+	}
+}
+
+// CommandLimits retorna os limites de entrada de comandos configurados
+func (c *Config) CommandLimits() comms.CommandLimits {
+	return comms.CommandLimits{
+		MaxArgs:      c.MaxCommandArgs,
+		MaxArgsBytes: c.MaxCommandArgsBytes,
+		MaxOptions:   c.MaxCommandOptions,
+		MaxDepth:     c.MaxCommandOptionsDepth,
+	}
+}
+
+// redactProxyURL oculta a senha do proxy para logs e health
+func redactProxyURL(raw string) string {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return "***"
+	}
+	return proxyURL.Redacted()
+}
+
+// dataDir retorna data_dir ou o diretório padrão, antes de ApplyDefaults
+func (c *Config) dataDir() string {
+	if c.DataDir == "" {
+		return defaultDataDir(runtime.GOOS)
+	}
+	return c.DataDir
+}
+
+// defaultDataDir é o diretório de estado persistente de cada sistema; o
+// diretório temporário é limpo em reboots e por limpadores de tmp, levando
+// junto a fila offline, os snapshots e a identidade
+func defaultDataDir(goos string) string {
+	switch goos {
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "agente-poc")
+	case "darwin":
+		return "/Library/Application Support/agente-poc"
+	default:
+		return "/var/lib/agente-poc"
+	}
 }
 
 // String retorna uma representação string da configuração (sem token)
 func (c *Config) String() string {
 	safeConfig := *c
 	safeConfig.Token = "***" // Ocultar token nos logs
+	if c.EnrollmentKey != "" {
+		safeConfig.EnrollmentKey = "***"
+	}
+	if c.CredentialPassphrase != "" {
+		safeConfig.CredentialPassphrase = "***"
+	}
+	safeConfig.Tokens = make([]string, len(c.Tokens))
+	for i := range safeConfig.Tokens {
+		safeConfig.Tokens[i] = "***"
+	}
+	safeConfig.ProxyURL = redactProxyURL(c.ProxyURL)
+
+	// Durações saem como "2h 13m" em vez de nanossegundos
+	fields := make(map[string]interface{})
+	raw, _ := json.Marshal(safeConfig)
+	_ = json.Unmarshal(raw, &fields)
+
+	value := reflect.ValueOf(safeConfig)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Type != reflect.TypeOf(time.Duration(0)) {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		fields[name] = timeutil.FormatDurationHuman(time.Duration(value.Field(i).Int()))
+	}
 
-	data, _ := json.MarshalIndent(safeConfig, "", "  ")
+	data, _ := json.MarshalIndent(fields, "", "  ")
 	return string(data)
 }
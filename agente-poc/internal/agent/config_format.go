@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Formatos do arquivo de configuração. Todos usam os mesmos nomes de campo
+// do JSON; YAML e TOML são convertidos para JSON antes da validação.
+const (
+	ConfigFormatJSON = "json"
+	ConfigFormatYAML = "yaml"
+	ConfigFormatTOML = "toml"
+)
+
+// ConfigFormat escolhe o formato pela extensão de path (.json, .yaml, .yml
+// ou .toml); outras extensões são lidas como JSON, como antes
+func ConfigFormat(path string) string {
+	format, err := ParseConfigFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return ConfigFormatJSON
+	}
+	return format
+}
+
+// ParseConfigFormat normaliza o nome de um formato ("yml" vira "yaml")
+func ParseConfigFormat(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case "json":
+		return ConfigFormatJSON, nil
+	case "yaml", "yml":
+		return ConfigFormatYAML, nil
+	case "toml":
+		return ConfigFormatTOML, nil
+	default:
+		return "", fmt.Errorf("formato de configuração desconhecido %q (use json, yaml ou toml)", name)
+	}
+}
+
+// configDocumentJSON converte o conteúdo do arquivo no formato dado para
+// JSON; erros de sintaxe trazem linha e coluna
+func configDocumentJSON(data []byte, format string) ([]byte, error) {
+	switch format {
+	case ConfigFormatYAML:
+		var doc interface{}
+		// As mensagens do yaml.v3 já trazem a linha ("yaml: line 3: ...")
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return json.Marshal(normalizeYAML(doc))
+
+	case ConfigFormatTOML:
+		var doc map[string]interface{}
+		if _, err := toml.Decode(string(data), &doc); err != nil {
+			var parseErr toml.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			message := parseErr.Message
+			if message == "" {
+				message = err.Error()
+			}
+			line := parseErr.Position.Line
+			return nil, fmt.Errorf("linha %d, coluna %d: %s", line, lineColumn(data, line, parseErr.Position.Start), message)
+		}
+		return json.Marshal(doc)
+
+	default:
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				// Offset conta o byte inválido; a posição é a dele
+				line, column := offsetPosition(data, int(syntaxErr.Offset)-1)
+				return nil, fmt.Errorf("linha %d, coluna %d: %w", line, column, err)
+			}
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// normalizeYAML troca os mapas com chaves não string do yaml.v3 por
+// map[string]interface{}, que o JSON aceita
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			object[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+	}
+	return value
+}
+
+// decodeError descreve um erro de tipo do JSON pelo caminho do campo (sem o
+// nome da struct interna) e, em YAML, pela linha e coluna do valor
+func decodeError(err error, original []byte, format string) error {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return err
+	}
+	message := fmt.Sprintf("%s: valor %s onde se espera %s", typeErr.Field, typeErr.Value, typeErr.Type)
+	if format == ConfigFormatYAML {
+		if line, column, ok := yamlPosition(original, typeErr.Field); ok {
+			return fmt.Errorf("linha %d, coluna %d: %s", line, column, message)
+		}
+	}
+	return errors.New(message)
+}
+
+// yamlPosition procura a linha e a coluna do valor de field ("a.b.c") no
+// documento YAML; caminhos que passam por listas não são localizados
+func yamlPosition(data []byte, field string) (int, int, bool) {
+	var root yaml.Node
+	if yaml.Unmarshal(data, &root) != nil || len(root.Content) == 0 {
+		return 0, 0, false
+	}
+	node := root.Content[0]
+	for _, key := range strings.Split(field, ".") {
+		if node.Kind != yaml.MappingNode {
+			return 0, 0, false
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				value = node.Content[i+1]
+				break
+			}
+		}
+		if value == nil {
+			return 0, 0, false
+		}
+		node = value
+	}
+	return node.Line, node.Column, true
+}
+
+// lineColumn calcula a coluna (a partir de 1) do deslocamento offset na
+// linha line de data; fora da linha, a coluna é 1
+func lineColumn(data []byte, line, offset int) int {
+	start := 0
+	for n := 1; n < line; n++ {
+		next := bytes.IndexByte(data[start:], '\n')
+		if next < 0 {
+			return 1
+		}
+		start += next + 1
+	}
+	return max(offset-start+1, 1)
+}
+
+// offsetPosition converte um deslocamento em bytes de data em linha e
+// coluna, ambas a partir de 1
+func offsetPosition(data []byte, offset int) (int, int) {
+	offset = min(max(offset, 0), len(data))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A mesma configuração nos três formatos; DATA_DIR é trocado pelo
+// diretório do teste
+const (
+	roundTripJSON = `{
+  "machine_id": "mac-dev-001",
+  "backend_url": "https://backend.example.com",
+  "websocket_url": "wss://backend.example.com/ws",
+  "backend_urls": ["https://backup.example.com"],
+  "token": "test-token",
+  "heartbeat_interval": "90s",
+  "collection_interval": 60,
+  "debug": true,
+  "data_dir": "DATA_DIR",
+  "collector_sections": {"network": false},
+  "custom_collectors": [
+    {"name": "asset", "path": "/usr/local/lib/agente/collectors/asset.sh", "args": ["CC-1234"], "format": "kv", "timeout": 5}
+  ]
+}
+`
+	roundTripYAML = `# Comentários são o motivo do YAML
+machine_id: mac-dev-001
+backend_url: https://backend.example.com
+websocket_url: "wss://backend.example.com/ws"
+backend_urls:
+  - https://backup.example.com
+token: test-token
+heartbeat_interval: 90s
+collection_interval: 60
+debug: true
+data_dir: DATA_DIR
+collector_sections:
+  network: false
+custom_collectors:
+  - name: asset
+    path: /usr/local/lib/agente/collectors/asset.sh
+    args: [CC-1234]
+    format: kv
+    timeout: 5
+`
+	roundTripTOML = `# Comentários também valem em TOML
+machine_id = "mac-dev-001"
+backend_url = "https://backend.example.com"
+websocket_url = "wss://backend.example.com/ws"
+backend_urls = ["https://backup.example.com"]
+token = "test-token"
+heartbeat_interval = "90s"
+collection_interval = 60
+debug = true
+data_dir = "DATA_DIR"
+
+[collector_sections]
+network = false
+
+[[custom_collectors]]
+name = "asset"
+path = "/usr/local/lib/agente/collectors/asset.sh"
+args = ["CC-1234"]
+format = "kv"
+timeout = 5
+`
+)
+
+// writeFormatConfig grava document em config.<ext>, com data_dir dentro de
+// dataDir
+func writeFormatConfig(t *testing.T, dataDir, ext, document string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config."+ext)
+	document = strings.ReplaceAll(document, "DATA_DIR", filepath.ToSlash(dataDir))
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFormatsRoundTrip(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	configs := make(map[string]*Config)
+	for ext, document := range map[string]string{
+		"json": roundTripJSON,
+		"yaml": roundTripYAML,
+		"yml":  roundTripYAML,
+		"toml": roundTripTOML,
+	} {
+		config, err := LoadConfig(writeFormatConfig(t, dataDir, ext, document))
+		if err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		configs[ext] = config
+	}
+
+	want := configs["json"]
+	if want.HeartbeatInterval.Seconds() != 90 || !want.Debug || len(want.CustomCollectors) != 1 || want.CustomCollectors[0].Args[0] != "CC-1234" {
+		t.Fatalf("json config = %+v", want)
+	}
+	for _, ext := range []string{"yaml", "yml", "toml"} {
+		if !reflect.DeepEqual(configs[ext], want) {
+			t.Errorf("%s config differs from json:\n%+v\n%+v", ext, configs[ext], want)
+		}
+	}
+}
+
+func TestLoadConfigFormatErrorPositions(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "data")
+	tests := []struct {
+		ext      string
+		document string
+		want     string
+	}{
+		{"json", "{\n  \"machine_id\": \"mac-dev-001\",\n  \"debug\": tru\n}\n", "linha 3, coluna"},
+		{"yaml", "machine_id: mac-dev-001\ndebug: [true\n", "yaml: line"},
+		{"toml", "machine_id = \"mac-dev-001\"\ndebug = tru\n", "linha 2, coluna"},
+		// Tipo errado no YAML aponta a linha e a coluna do valor
+		{"yaml", strings.Replace(roundTripYAML, "debug: true", "debug: \"yes\"", 1), "linha 10, coluna 8: debug: valor string onde se espera bool"},
+	}
+	for _, tt := range tests {
+		_, err := LoadConfig(writeFormatConfig(t, dataDir, tt.ext, tt.document))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want %q", tt.ext, err, tt.want)
+		}
+	}
+}
+
+func TestSampleConfigFormats(t *testing.T) {
+	t.Setenv("AGENTE_TOKEN", "sample-token")
+
+	var documents []configJSON
+	for _, format := range []string{"json", "yaml", "toml"} {
+		sample, err := SampleConfig(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "json" && !strings.Contains(string(sample), "# URL HTTP(S) do backend") {
+			t.Errorf("%s sample without comments", format)
+		}
+
+		document, err := configDocumentJSON(sample, format)
+		if err != nil {
+			t.Fatalf("%s sample: %v", format, err)
+		}
+		document, problems, err := prepareConfigDocument(document)
+		if err != nil || len(problems) != 0 {
+			t.Fatalf("%s sample: %v %v", format, err, problems)
+		}
+		var parsed configJSON
+		if err := json.Unmarshal(document, &parsed); err != nil {
+			t.Fatalf("%s sample: %v", format, err)
+		}
+		documents = append(documents, parsed)
+	}
+	for i, format := range []string{"yaml", "toml"} {
+		if !reflect.DeepEqual(documents[i+1], documents[0]) {
+			t.Errorf("%s sample differs from json", format)
+		}
+	}
+	if documents[0].Token != "sample-token" {
+		t.Fatalf("token = %q", documents[0].Token)
+	}
+
+	if _, err := SampleConfig("ini"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+	// Extensão desconhecida continua sendo lida como JSON
+	for path, want := range map[string]string{"/etc/agente/config.yml": ConfigFormatYAML, "config.TOML": ConfigFormatTOML, "agente.conf": ConfigFormatJSON} {
+		if got := ConfigFormat(path); got != want {
+			t.Errorf("ConfigFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// sampleEntry é um campo da configuração de exemplo, com o comentário que o
+// acompanha nos formatos que aceitam comentários
+type sampleEntry struct {
+	key     string
+	comment string
+	value   interface{}
+}
+
+// sampleConfigEntries lista os campos principais com os valores padrão de
+// ApplyDefaults; os obrigatórios recebem valores ilustrativos
+func sampleConfigEntries() []sampleEntry {
+	var defaults Config
+	defaults.ApplyDefaults()
+
+	return []sampleEntry{
+		{"machine_id", "Identificador da máquina; vazio gera um automaticamente", ""},
+		{"backend_url", "URL HTTP(S) do backend", "https://backend.example.com"},
+		{"websocket_url", "URL do WebSocket do backend (ws:// ou wss://)", "wss://backend.example.com/ws"},
+		{"backend_urls", "Endpoints de failover (outras regiões), tentados depois de backend_url; websocket_urls idem", []string{}},
+		{"token", "Token de autenticação; ${VAR} lê uma variável de ambiente", "${AGENTE_TOKEN}"},
+		{"offline", "true roda sem backend e guarda os inventários em offline_archive_dir", false},
+		{"enrollment_key", "Alternativa ao token: chave de enrollment trocada pelo token da máquina no primeiro início", ""},
+		{"heartbeat_interval", "Intervalos em segundos ou durações como \"90s\" e \"2h30m\"", 30 * time.Second},
+		{"collection_interval", "", defaults.CollectionInterval},
+		{"inventory_interval", "", defaults.InventoryInterval},
+		{"command_timeout", "", defaults.CommandTimeout},
+		{"retry_interval", "", defaults.RetryInterval},
+		{"upload_rate_limit", "Limite de upload em bytes/s (0 = sem limite)", 0},
+		{"inventory_send_window", "Janela diária, em hora local, para enviar inventários (ex.: \"01:00-05:00\"); vazia = a qualquer hora", ""},
+		{"reconnect_interval", "", defaults.ReconnectInterval},
+		{"max_retries", "", defaults.MaxRetries},
+		{"circuit_breaker_failure_threshold", "Falhas seguidas de um endpoint (heartbeat e demais críticos) que abrem o circuit breaker dele", defaults.CircuitBreakerFailureThreshold},
+		{"circuit_breaker_reset_timeout", "Espera com o circuito aberto até testar o backend com GET /health", defaults.CircuitBreakerResetTimeout},
+		{"circuit_breaker_bulk_failure_threshold", "O mesmo para o envio de inventários (0 = circuit_breaker_failure_threshold)", 0},
+		{"circuit_breaker_bulk_reset_timeout", "0 = circuit_breaker_reset_timeout", 0},
+		{"max_concurrent_commands", "Comandos executados ao mesmo tempo", defaults.MaxConcurrentCommands},
+		{"log_level", "debug, info, warning, error ou fatal", defaults.LogLevel},
+		{"debug", "", false},
+		{"data_dir", "Estado local (fila offline, snapshots, lock e socket de controle)", defaults.DataDir},
+		{"snapshot_ring_size", "Snapshots de inventário mantidos em data_dir", defaults.SnapshotRingSize},
+		{"snapshot_max_bytes", "Disco máximo ocupado pelos snapshots; os mais antigos saem primeiro", defaults.SnapshotMaxBytes},
+		{"event_log_path", "Log de eventos em JSON Lines para SIEM; vazio desativa", ""},
+		{"presence_policy", "Adiamento durante apresentações: off, presentation ou focus", defaults.PresencePolicy},
+		{"registration_conflict_policy", "machine_id já registrado: regenerate ou halt", defaults.RegistrationConflictPolicy},
+		{"instance_lock_policy", "Segunda instância com o mesmo machine_id: wait ou exit", defaults.InstanceLockPolicy},
+	}
+}
+
+// SampleConfig gera uma configuração de exemplo no formato dado (json, yaml
+// ou toml), com os valores padrão e, em YAML e TOML, comentários
+func SampleConfig(format string) ([]byte, error) {
+	format, err := ParseConfigFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := sampleConfigEntries()
+	var buf bytes.Buffer
+	switch format {
+	case ConfigFormatJSON:
+		// JSON não tem comentários
+		buf.WriteString("{\n")
+		for i, entry := range entries {
+			value, err := json.Marshal(sampleValue(entry.value))
+			if err != nil {
+				return nil, err
+			}
+			separator := ","
+			if i == len(entries)-1 {
+				separator = ""
+			}
+			fmt.Fprintf(&buf, "  %q: %s%s\n", entry.key, value, separator)
+		}
+		buf.WriteString("}\n")
+
+	default:
+		assign := ": "
+		if format == ConfigFormatTOML {
+			assign = " = "
+		}
+		buf.WriteString("# Configuração do agente (gerada com -print-default-config)\n")
+		for _, entry := range entries {
+			if entry.comment != "" {
+				fmt.Fprintf(&buf, "\n# %s\n", entry.comment)
+			}
+			buf.WriteString(entry.key + assign + formatSampleScalar(sampleValue(entry.value)) + "\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// sampleValue grava durações em segundos, o formato aceito por todos os
+// intervalos
+func sampleValue(value interface{}) interface{} {
+	if d, ok := value.(time.Duration); ok {
+		return int64(d / time.Second)
+	}
+	return value
+}
+
+// formatSampleScalar escreve um valor simples em YAML ou TOML; strings vão
+// entre aspas duplas, com os escapes comuns aos dois formatos
+func formatSampleScalar(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,323 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"agente-poc/internal/comms"
+)
+
+// ValidationError reúne todos os problemas encontrados na configuração; cada
+// um começa pelo caminho JSON do campo (ex.: "schedules[0].interval ...")
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "erros de validação: " + strings.Join(e.Problems, ", ")
+}
+
+// envReference é uma referência ${VAR} dentro de um valor string
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// prepareConfigDocument substitui ${VAR} pelas variáveis de ambiente em
+// todos os valores string e procura chaves desconhecidas comparando o
+// documento com configJSON. Retorna o JSON já substituído e os problemas
+// encontrados; err só para JSON malformado.
+func prepareConfigDocument(data []byte) ([]byte, []string, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	var problems []string
+	doc = expandEnv(doc, "", &problems)
+	unknownKeys(doc, reflect.TypeOf(configJSON{}), "", &problems)
+
+	expanded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expanded, problems, nil
+}
+
+// expandEnv troca ${VAR} nos valores string de value; variáveis não
+// definidas viram problema no caminho do valor
+func expandEnv(value interface{}, path string, problems *[]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandEnv(item, joinConfigPath(path, key), problems)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandEnv(item, path+"["+strconv.Itoa(i)+"]", problems)
+		}
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			name := envReference.FindStringSubmatch(ref)[1]
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: variável de ambiente %s não definida", path, name))
+			}
+			return env
+		})
+	}
+	return value
+}
+
+// jsonUnmarshaler identifica tipos com decodificação própria, cujo conteúdo
+// não é comparado campo a campo
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownKeys compara as chaves dos objetos de value com os campos JSON de
+// t, descendo em structs, slices, ponteiros e valores de mapas
+func unknownKeys(value interface{}, t reflect.Type, path string, problems *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(jsonUnmarshaler) || reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			field, known := fields[key]
+			if !known {
+				message := fmt.Sprintf("%s: campo desconhecido", joinConfigPath(path, key))
+				if suggestion := closestKey(key, fields); suggestion != "" {
+					message += fmt.Sprintf(" (quis dizer %s?)", suggestion)
+				}
+				*problems = append(*problems, message)
+				continue
+			}
+			unknownKeys(object[key], field, joinConfigPath(path, key), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			unknownKeys(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", problems)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range object {
+			unknownKeys(item, t.Elem(), joinConfigPath(path, key), problems)
+		}
+	}
+}
+
+// jsonFields mapeia os nomes JSON dos campos de t (incluindo os de structs
+// embutidas) aos tipos
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, typ := range jsonFields(embedded) {
+					fields[key] = typ
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// closestKey sugere o campo conhecido mais parecido com key (no máximo duas
+// edições de distância), para erros de digitação
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance é a distância de Levenshtein entre a e b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// joinConfigPath monta o caminho JSON de key dentro de path
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validateEndpoint confere o formato de uma URL do backend: esquema entre
+// schemes, host presente e porta entre 1 e 65535
+func validateEndpoint(field, raw string, schemes ...string) []string {
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return []string{fmt.Sprintf("%s inválido: %v", field, err)}
+	}
+	var problems []string
+	if !slices.Contains(schemes, parsed.Scheme) {
+		problems = append(problems, fmt.Sprintf("%s deve usar %s", field, strings.Join(schemes, " ou ")))
+	}
+	if parsed.Hostname() == "" {
+		problems = append(problems, fmt.Sprintf("%s deve ter um host", field))
+	}
+	if port := parsed.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			problems = append(problems, fmt.Sprintf("%s: porta %s fora da faixa 1-65535", field, port))
+		}
+	}
+	return problems
+}
+
+// pathProblems confere se os diretórios onde o agente grava (data_dir, log
+// de eventos, lock de instância e socket de controle) são graváveis; um
+// diretório ainda inexistente vale pelo ancestral mais próximo, já que o
+// agente o cria. Chamado com os padrões aplicados.
+func (c *Config) pathProblems() []string {
+	dirs := []struct {
+		field string
+		dir   string
+	}{
+		{"data_dir", c.DataDir},
+		{"instance_lock_dir", c.InstanceLockDir},
+		{"control_socket", filepath.Dir(c.ControlSocket)},
+	}
+	if c.Offline {
+		dirs = append(dirs, struct {
+			field string
+			dir   string
+		}{"offline_archive_dir", c.OfflineArchiveDir})
+	}
+	if c.EventLogPath != "" {
+		dirs = append(dirs, struct {
+			field string
+			dir   string
+		}{"event_log_path", filepath.Dir(c.EventLogPath)})
+	}
+
+	var problems []string
+	checked := make(map[string]bool, len(dirs))
+	for _, entry := range dirs {
+		// instance_lock_dir e control_socket ficam no data_dir por padrão
+		if entry.dir == "" || checked[entry.dir] {
+			continue
+		}
+		checked[entry.dir] = true
+		if err := checkWritableDir(entry.dir); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s não é gravável: %v", entry.field, entry.dir, err))
+		}
+	}
+	return problems
+}
+
+// checkWritableDir cria e remove um arquivo temporário em dir ou, se dir
+// não existe, no ancestral existente mais próximo
+func checkWritableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("não é um diretório")
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	file, err := os.CreateTemp(dir, ".agente-write-check-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// Warnings retorna avisos que não impedem o agente de iniciar, como URLs do
+// backend recusadas pela política estrita do SecurityManager (HTTPS/WSS
+// fora de localhost)
+func (c *Config) Warnings() []string {
+	security := comms.NewSecurityManager(comms.SecurityConfig{})
+	var warnings []string
+	for _, endpoint := range []struct {
+		field string
+		value string
+	}{
+		{"backend_url", c.BackendURL},
+		{"websocket_url", c.WebSocketURL},
+	} {
+		if endpoint.value == "" {
+			continue
+		}
+		if _, err := security.ValidateURL(endpoint.value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", endpoint.field, err))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadProblems carrega a configuração com extra e retorna os problemas da
+// validação
+func loadProblems(t *testing.T, extra map[string]interface{}) []string {
+	t.Helper()
+	_, err := LoadConfig(writeTestConfig(t, extra))
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	return validation.Problems
+}
+
+// hasProblem indica se algum problema contém text
+func hasProblem(problems []string, text string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadConfigUnknownKeys(t *testing.T) {
+	problems := loadProblems(t, map[string]interface{}{
+		"heartbeat_intervall": 10,
+		"custom_collectors": []map[string]interface{}{
+			{"name": "asset", "path": "/usr/local/lib/agente/collectors/asset.sh", "fromat": "kv"},
+		},
+	})
+	for _, want := range []string{
+		"heartbeat_intervall: campo desconhecido (quis dizer heartbeat_interval?)",
+		"custom_collectors[0].fromat: campo desconhecido (quis dizer format?)",
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("missing %q in %v", want, problems)
+		}
+	}
+
+	// Sem nada parecido, não há sugestão
+	problems = loadProblems(t, map[string]interface{}{"colour_scheme": "dark"})
+	if len(problems) != 1 || problems[0] != "colour_scheme: campo desconhecido" {
+		t.Fatalf("problems = %v", problems)
+	}
+}
+
+func TestLoadConfigBadURLs(t *testing.T) {
+	problems := loadProblems(t, map[string]interface{}{
+		"backend_url":    "ftp://backend.example.com",
+		"websocket_url":  "wss://backend.example.com:70000/ws",
+		"backend_urls":   []string{"https://", "https://backup.example.com"},
+		"websocket_urls": []string{"https://backup.example.com/ws"},
+	})
+	for _, want := range []string{
+		"backend_url deve usar http ou https",
+		"websocket_url: porta 70000 fora da faixa 1-65535",
+		"backend_urls[0] deve ter um host",
+		"websocket_urls[0] deve usar ws ou wss",
+	} {
+		if !hasProblem(problems, want) {
+			t.Errorf("missing %q in %v", want, problems)
+		}
+	}
+	if hasProblem(problems, "backend_urls[1]") {
+		t.Errorf("valid fallback URL reported: %v", problems)
+	}
+}
+
+func TestLoadConfigReportsAllProblems(t *testing.T) {
+	// Chave desconhecida, URL, intervalo e diretório: tudo em um erro só
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	problems := loadProblems(t, map[string]interface{}{
+		"heartbeat_intervall": 10,
+		"backend_url":         "backend.example.com",
+		"heartbeat_interval":  -5,
+		"data_dir":            filepath.Join(blocker, "data"),
+	})
+	for _, want := range []string{"heartbeat_intervall", "backend_url", "heartbeat_interval", "data_dir"} {
+		if !hasProblem(problems, want) {
+			t.Errorf("missing %s in %v", want, problems)
+		}
+	}
+}
+
+func TestLoadConfigEnvSubstitution(t *testing.T) {
+	t.Setenv("AGENTE_TEST_TOKEN", "s3cr3t")
+	t.Setenv("AGENTE_TEST_HOST", "backend.example.com")
+
+	config, err := LoadConfig(writeTestConfig(t, map[string]interface{}{
+		"token":        "${AGENTE_TEST_TOKEN}",
+		"backend_url":  "https://${AGENTE_TEST_HOST}:8443",
+		"backend_urls": []string{"https://backup.${AGENTE_TEST_HOST}"},
+		// Só ${VAR} é expandido; $VAR fica como está
+		"machine_id": "host-$AGENTE_TEST_HOST",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Token != "s3cr3t" || config.BackendURL != "https://backend.example.com:8443" {
+		t.Fatalf("token %q, backend_url %q", config.Token, config.BackendURL)
+	}
+	if len(config.BackendURLs) != 1 || config.BackendURLs[0] != "https://backup.backend.example.com" {
+		t.Fatalf("backend_urls = %v", config.BackendURLs)
+	}
+	if config.MachineID != "host-$AGENTE_TEST_HOST" {
+		t.Fatalf("machine_id = %q", config.MachineID)
+	}
+
+	// Variável não definida é problema no caminho do valor
+	problems := loadProblems(t, map[string]interface{}{"backend_urls": []string{"https://${AGENTE_TEST_UNSET}"}})
+	if !hasProblem(problems, "backend_urls[0]: variável de ambiente AGENTE_TEST_UNSET não definida") {
+		t.Fatalf("problems = %v", problems)
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// startControlServer expõe o estado do agente em um socket Unix local, usado
+// pelo subcomando status. O socket só é acessível pelo dono do processo.
+func (a *Agent) startControlServer() error {
+	path := a.config.ControlSocket
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	// Socket órfão de uma execução anterior
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket already in use: %s", path)
+		}
+		_ = os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(a.Health())
+	})
+
+	a.controlServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := a.controlServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			a.logger.WithField("error", err).Warning("Control socket server stopped")
+		}
+	}()
+
+	a.logger.WithField("path", path).Debug("Control socket listening")
+	return nil
+}
+
+// stopControlServer fecha o socket de controle. Close (e não Shutdown) evita
+// esperar por requisições bloqueadas em Health durante o Stop.
+func (a *Agent) stopControlServer() {
+	if a.controlServer == nil {
+		return
+	}
+	_ = a.controlServer.Close()
+	_ = os.Remove(a.config.ControlSocket)
+	a.controlServer = nil
+}
+
+// QueryHealth consulta o documento Health() de um agente em execução pelo socket de controle
+func QueryHealth(socketPath string, timeout time.Duration) (map[string]interface{}, error) {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("http://agent/health")
+	if err != nil {
+		return nil, fmt.Errorf("agent not reachable on %s: %w", socketPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected control socket status: %s", resp.Status)
+	}
+
+	var health map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, fmt.Errorf("failed to decode health: %w", err)
+	}
+	return health, nil
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-x * * * *",
+		"@yearly",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) accepted", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Segunda-feira, 5 de janeiro de 2026, 09:07:30
+	from := time.Date(2026, 1, 5, 9, 7, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", at(1, 5, 9, 8)},
+		{"*/10 * * * *", at(1, 5, 9, 10)},
+		{"5/15 * * * *", at(1, 5, 9, 20)},
+		{"0,30 9-17 * * *", at(1, 5, 9, 30)},
+		{"0 8 * * *", at(1, 6, 8, 0)},
+		{"@hourly", at(1, 5, 10, 0)},
+		{"@daily", at(1, 6, 0, 0)},
+		{"@weekly", at(1, 11, 0, 0)},
+		{"@monthly", at(2, 1, 0, 0)},
+		// Domingo como 0 ou 7
+		{"0 12 * * 7", at(1, 11, 12, 0)},
+		{"0 12 * * 1-5", at(1, 5, 12, 0)},
+		// Dia do mês e dia da semana restritos: basta um coincidir
+		{"0 0 20 * 3", at(1, 7, 0, 0)},
+		{"0 0 1 3 *", at(3, 1, 0, 0)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Nunca ocorre
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := spec.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	// Exatamente no horário, o próximo é o seguinte
+	spec, _ := parseCron("*/10 * * * *")
+	if got := spec.Next(at(1, 5, 9, 10)); !got.Equal(at(1, 5, 9, 20)) {
+		t.Fatalf("Next on a matching minute = %s", got)
+	}
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/executor"
+	"agente-poc/internal/timeutil"
+)
+
+// Formatos de saída dos coletores customizados
+const (
+	CustomCollectorFormatJSON     = "json"
+	CustomCollectorFormatKeyValue = "kv"
+)
+
+// defaultCustomCollectorTimeout vale para coletores sem timeout
+const defaultCustomCollectorTimeout = 10 * time.Second
+
+// maxCustomCollectorTimeout impede um script de segurar o inventário inteiro
+const maxCustomCollectorTimeout = 5 * time.Minute
+
+// CustomCollector é um item de custom_collectors: um script local cuja saída
+// entra no inventário em custom.<name>
+type CustomCollector struct {
+	Name string   `json:"name"`
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+	// Format é "json" (um valor JSON) ou "kv" (linhas chave=valor)
+	Format  string           `json:"format"`
+	Timeout timeutil.Seconds `json:"timeout,omitempty"`
+	// Interval é o tempo mínimo entre execuções; zero roda a cada
+	// inventário
+	Interval timeutil.Seconds `json:"interval,omitempty"`
+}
+
+// Validate verifica a definição do coletor; dono e diretório do script são
+// conferidos a cada execução
+func (c *CustomCollector) Validate() error {
+	if err := collector.ValidatePluginName(c.Name); err != nil {
+		return err
+	}
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("custom collector %s: path must be absolute", c.Name)
+	}
+	switch c.Format {
+	case CustomCollectorFormatJSON, CustomCollectorFormatKeyValue:
+	default:
+		return fmt.Errorf("custom collector %s: format must be json or kv", c.Name)
+	}
+	if c.Timeout < 0 || c.Timeout.Duration() > maxCustomCollectorTimeout {
+		return fmt.Errorf("custom collector %s: timeout must be between 0 and %s", c.Name, maxCustomCollectorTimeout)
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("custom collector %s: interval must not be negative", c.Name)
+	}
+	if err := executor.ValidateCollectorScriptArgs(c.Args); err != nil {
+		return fmt.Errorf("custom collector %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// ValidateCustomCollectors valida a lista e recusa nomes repetidos
+func ValidateCustomCollectors(collectors []CustomCollector) error {
+	seen := make(map[string]bool, len(collectors))
+	for i := range collectors {
+		if err := collectors[i].Validate(); err != nil {
+			return err
+		}
+		if seen[collectors[i].Name] {
+			return fmt.Errorf("duplicate custom collector name: %s", collectors[i].Name)
+		}
+		seen[collectors[i].Name] = true
+	}
+	return nil
+}
+
+// scriptCollector adapta um CustomCollector ao collector.CollectorPlugin,
+// executando o script pelo executor
+type scriptCollector struct {
+	def      CustomCollector
+	executor *executor.Executor
+}
+
+func (s *scriptCollector) Name() string { return s.def.Name }
+
+func (s *scriptCollector) Interval() time.Duration { return s.def.Interval.Duration() }
+
+func (s *scriptCollector) Timeout() time.Duration {
+	if s.def.Timeout > 0 {
+		return s.def.Timeout.Duration()
+	}
+	return defaultCustomCollectorTimeout
+}
+
+// Collect roda o script e converte a saída conforme o formato
+func (s *scriptCollector) Collect(ctx context.Context) (json.RawMessage, error) {
+	output, err := s.executor.RunCollectorScript(ctx, s.def.Path, s.def.Args)
+	if err != nil {
+		return nil, err
+	}
+	if s.def.Format == CustomCollectorFormatKeyValue {
+		return parseKeyValueOutput(output)
+	}
+	if !json.Valid(output) {
+		return nil, fmt.Errorf("script output is not valid JSON")
+	}
+	return output, nil
+}
+
+// parseKeyValueOutput converte linhas chave=valor em um objeto JSON de
+// strings; linhas vazias e iniciadas por # são ignoradas
+func parseKeyValueOutput(output []byte) (json.RawMessage, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d is not key=value", line)
+		}
+		values[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
+
+// applyCustomCollectors registra no collector os coletores de
+// custom_collectors; precisa do executor já criado
+func (a *Agent) applyCustomCollectors() {
+	plugins := make([]collector.CollectorPlugin, 0, len(a.config.CustomCollectors))
+	for _, def := range a.config.CustomCollectors {
+		plugins = append(plugins, &scriptCollector{def: def, executor: a.executor})
+	}
+	if err := a.collector.SetPlugins(plugins); err != nil {
+		a.logger.WithField("error", err).Warning("Ignoring custom collectors")
+	}
+}
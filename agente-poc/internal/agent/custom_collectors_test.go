@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/timeutil"
+)
+
+// writeCollectorScript grava um script de coletor com dono root e 0755
+func writeCollectorScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCustomCollectorsMergeIntoInventory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("collector scripts are shell scripts")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("collector scripts must be owned by root")
+	}
+	dir := filepath.Join(t.TempDir(), "collectors")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	assetTag := writeCollectorScript(t, dir, "asset_tag.sh", "echo 'asset_tag=PAT-00042'\necho \"cost_center=$1\"\n")
+	compliance := writeCollectorScript(t, dir, "compliance.sh", `echo '{"disk_wipe_policy": true, "checks": 3}'`+"\n")
+	hang := writeCollectorScript(t, dir, "hang.sh", "sleep 30\n")
+	broken := writeCollectorScript(t, dir, "broken.sh", "echo 'not json'\n")
+
+	a := newCapabilitiesTestAgent(t, map[string]interface{}{
+		"custom_collector_dirs": []string{dir},
+		"custom_collectors": []map[string]interface{}{
+			{"name": "asset", "path": assetTag, "args": []string{"CC-1234"}, "format": "kv"},
+			{"name": "compliance", "path": compliance, "format": "json"},
+			{"name": "slow", "path": hang, "format": "json", "timeout": 1},
+			{"name": "broken", "path": broken, "format": "json"},
+		},
+	})
+	a.applyCustomCollectors()
+
+	inventory, err := a.collector.CollectInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(inventory.Custom["asset"]); got != `{"asset_tag":"PAT-00042","cost_center":"CC-1234"}` {
+		t.Fatalf("asset = %s", got)
+	}
+	if got := string(inventory.Custom["compliance"]); got != `{"disk_wipe_policy": true, "checks": 3}` {
+		t.Fatalf("compliance = %s", got)
+	}
+	// Falhas viram objeto de erro na chave do coletor, sem derrubar o inventário
+	for name, want := range map[string]string{
+		"slow":   context.DeadlineExceeded.Error(),
+		"broken": "not valid JSON",
+	} {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(inventory.Custom[name], &failure); err != nil || !strings.Contains(failure.Error, want) {
+			t.Errorf("%s = %s, want error %q", name, inventory.Custom[name], want)
+		}
+	}
+	if inventory.System.Hostname == "" {
+		t.Fatal("built-in sections missing")
+	}
+}
+
+func TestParseKeyValueOutput(t *testing.T) {
+	output, err := parseKeyValueOutput([]byte("# gerado pelo inventário local\nasset_tag = PAT-00042\n\ncost_center=CC=1234\nempty=\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != `{"asset_tag":"PAT-00042","cost_center":"CC=1234","empty":""}` {
+		t.Fatalf("output = %s", output)
+	}
+
+	for _, bad := range []string{"asset_tag PAT-00042\n", "=value\n"} {
+		if _, err := parseKeyValueOutput([]byte(bad)); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestValidateCustomCollectors(t *testing.T) {
+	valid := CustomCollector{Name: "asset", Path: "/usr/local/lib/agente/collectors/asset.sh", Format: CustomCollectorFormatKeyValue}
+	if err := ValidateCustomCollectors([]CustomCollector{valid}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(c *CustomCollector){
+		"relative path":   func(c *CustomCollector) { c.Path = "collectors/asset.sh" },
+		"unknown format":  func(c *CustomCollector) { c.Format = "yaml" },
+		"builtin name":    func(c *CustomCollector) { c.Name = "hardware" },
+		"invalid name":    func(c *CustomCollector) { c.Name = "Asset Tag" },
+		"negative":        func(c *CustomCollector) { c.Interval = -1 },
+		"long timeout":    func(c *CustomCollector) { c.Timeout = timeutil.Seconds(maxCustomCollectorTimeout + time.Second) },
+		"shell meta args": func(c *CustomCollector) { c.Args = []string{"$(id)"} },
+	}
+	for name, mutate := range tests {
+		def := valid
+		mutate(&def)
+		if err := ValidateCustomCollectors([]CustomCollector{def}); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	if err := ValidateCustomCollectors([]CustomCollector{valid, valid}); err == nil {
+		t.Fatal("duplicate names accepted")
+	}
+}
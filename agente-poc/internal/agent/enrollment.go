@@ -0,0 +1,370 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/credentials"
+	"agente-poc/internal/events"
+)
+
+// EnrollmentKeyEnv é a variável de ambiente lida quando enrollment_key não
+// está no arquivo (instaladores e -enrollment-key)
+const EnrollmentKeyEnv = "AGENTE_ENROLLMENT_KEY"
+
+const (
+	// machineTokenMaxRefresh é o limite de renovações sem novo enrollment,
+	// o mesmo padrão do comms.SecurityManager
+	machineTokenMaxRefresh = 10
+	// machineTokenRefreshAt é a fração da validade após a qual o token é
+	// renovado
+	machineTokenRefreshAt = 0.8
+	// machineTokenRetryInterval espaça as tentativas após uma renovação falha
+	machineTokenRetryInterval = 5 * time.Minute
+	// enrollmentTimeout limita a chamada a /machines/enroll no início
+	enrollmentTimeout = time.Minute
+)
+
+// newCredentialStore abre o store do token da máquina; os testes trocam pelo
+// cofre de arquivo para não tocar no keychain ou no DPAPI
+var newCredentialStore = credentials.NewStore
+
+// MachineCredentialStatus descreve o token da máquina em Health() (nunca o
+// valor)
+type MachineCredentialStatus struct {
+	Enrolled     bool      `json:"enrolled"`
+	Storage      string    `json:"storage,omitempty"`
+	TokenID      string    `json:"token_id,omitempty"`
+	IssuedAt     time.Time `json:"issued_at,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	RefreshCount int       `json:"refresh_count"`
+	NextRefresh  time.Time `json:"next_refresh,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// machineCredential guarda o token da máquina em uso e a renovação agendada
+type machineCredential struct {
+	mu           sync.Mutex
+	store        *credentials.Store
+	token        *comms.Token // nil = autenticação pelo token da configuração
+	refreshTimer *time.Timer
+	nextRefresh  time.Time
+	lastError    string
+}
+
+// AuthToken retorna o token que o agente usa com a configuração dada: o da
+// máquina, se houver um guardado em data_dir, ou o da configuração
+func AuthToken(config *Config) string {
+	stored, err := newCredentialStore(config.DataDir, config.CredentialPassphrase).Load()
+	if err != nil {
+		return config.Token
+	}
+	return stored.Token
+}
+
+// initMachineCredential carrega o token da máquina guardado ou, sem ele e
+// com uma chave de enrollment, faz o enrollment. Sem os dois, o agente segue
+// com o token da configuração. Chamado com a.mu travado, depois de o
+// machine_id ser resolvido.
+func (a *Agent) initMachineCredential() error {
+	store := newCredentialStore(a.config.DataDir, a.config.CredentialPassphrase)
+	a.credential.mu.Lock()
+	a.credential.store = store
+	a.credential.mu.Unlock()
+
+	stored, err := store.Load()
+	if err == nil {
+		if a.config.EnrollmentKey != "" {
+			a.logger.Info("Machine already enrolled, ignoring enrollment key (it can be removed from the configuration)")
+		}
+		// data_dir copiado de outra máquina (ex.: imagem clonada) traz o
+		// token dela; o backend decide se aceita
+		if stored.MachineID != "" && stored.MachineID != a.currentMachineID() {
+			a.logger.WithFields(map[string]interface{}{
+				"machine_id":  a.currentMachineID(),
+				"enrolled_as": stored.MachineID,
+			}).Warning("Stored machine token was issued to a different machine ID")
+		}
+		a.discardEnrollmentKey()
+		a.useMachineToken(machineToken(stored))
+		return nil
+	}
+	if !errors.Is(err, credentials.ErrNotFound) {
+		// Sem o token guardado, um novo enrollment só é feito depois de o
+		// operador apagar a credencial: a chave pode já ter sido usada
+		if !a.hasConfiguredToken() {
+			return fmt.Errorf("failed to load stored machine token: %w", err)
+		}
+		a.logger.WithField("error", err).Error("Failed to load stored machine token, using the configured token")
+		return nil
+	}
+
+	if a.config.EnrollmentKey == "" {
+		return nil
+	}
+
+	token, err := a.enroll()
+	if err != nil {
+		a.recordEvent(events.CategoryAlert, events.SeverityError, "enrollment_failed",
+			"Machine enrollment failed", map[string]interface{}{"error": err})
+		if !a.hasConfiguredToken() {
+			return fmt.Errorf("machine enrollment failed: %w", err)
+		}
+		a.logger.Warning("Using the configured token after the enrollment failure")
+		return nil
+	}
+
+	// Sem gravar, o token vale só nesta execução; a chave já foi usada
+	if err := store.Save(credentialFromToken(token)); err != nil {
+		a.logger.WithField("error", err).Error("Failed to store machine token, it will be lost on restart")
+	}
+	a.discardEnrollmentKey()
+	a.useMachineToken(token)
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "machine_enrolled",
+		"Machine enrolled, the enrollment key can be removed from the configuration",
+		map[string]interface{}{
+			"token_id":   comms.TokenFingerprint(token.Value),
+			"storage":    store.Storage(),
+			"expires_at": formatOptionalTime(token.ExpiresAt),
+		})
+	return nil
+}
+
+// enroll troca a chave de enrollment pelo token da máquina
+func (a *Agent) enroll() (*comms.Token, error) {
+	request := comms.EnrollmentRequest{
+		MachineID:  a.currentMachineID(),
+		InstanceID: a.instanceID,
+	}
+	if info, err := a.collector.CollectBasicInfo(); err == nil {
+		request.SystemInfo = *info
+	} else {
+		a.logger.WithField("error", err).Warning("Enrolling without system information")
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, enrollmentTimeout)
+	defer cancel()
+
+	a.logger.WithField("machine_id", request.MachineID).Info("Enrolling machine with the backend...")
+	return comms.Enroll(ctx, comms.EnrollConfig{
+		BackendURL:    a.config.BackendURL,
+		EnrollmentKey: a.config.EnrollmentKey,
+		TLSFiles:      a.config.TLSFiles(),
+		ProxyURL:      a.config.ProxyURL,
+		InstanceID:    a.instanceID,
+		Logger:        a.logger,
+		Clock:         a.clock,
+
+		TLSSkipVerify:      a.config.TLSSkipVerify,
+		PinnedCertificates: a.config.PinnedCertificates,
+	}, request)
+}
+
+// hasConfiguredToken indica se a configuração traz um token próprio
+func (a *Agent) hasConfiguredToken() bool {
+	return a.config.Token != "" || len(a.config.Tokens) > 0
+}
+
+// discardEnrollmentKey esquece a chave de enrollment, inclusive no ambiente
+// (uma recarga não a lê de novo)
+func (a *Agent) discardEnrollmentKey() {
+	a.config.EnrollmentKey = ""
+	os.Unsetenv(EnrollmentKeyEnv)
+}
+
+// useMachineToken passa a autenticar com token; a renovação é agendada por
+// startTokenRefresh, depois de criado o communications manager
+func (a *Agent) useMachineToken(token *comms.Token) {
+	a.credential.mu.Lock()
+	defer a.credential.mu.Unlock()
+	a.credential.token = token
+}
+
+// startTokenRefresh agenda a renovação do token da máquina em uso, se houver
+func (a *Agent) startTokenRefresh() {
+	a.credential.mu.Lock()
+	defer a.credential.mu.Unlock()
+
+	if a.credential.token != nil {
+		a.scheduleTokenRefreshLocked(a.tokenRefreshDelay(a.credential.token))
+	}
+}
+
+// machineTokenValue retorna o token da máquina em uso (vazio sem enrollment)
+func (a *Agent) machineTokenValue() string {
+	a.credential.mu.Lock()
+	defer a.credential.mu.Unlock()
+
+	if a.credential.token == nil {
+		return ""
+	}
+	return a.credential.token.Value
+}
+
+// tokenRefreshDelay é a espera até renovar token: machineTokenRefreshAt da
+// validade. Token sem expiração não é renovado (-1).
+func (a *Agent) tokenRefreshDelay(token *comms.Token) time.Duration {
+	if token.ExpiresAt.IsZero() {
+		return -1
+	}
+	lifetime := token.ExpiresAt.Sub(token.IssuedAt)
+	refreshAt := token.IssuedAt.Add(time.Duration(float64(lifetime) * machineTokenRefreshAt))
+	return max(refreshAt.Sub(a.clock.Now()), 0)
+}
+
+// scheduleTokenRefreshLocked agenda a renovação em delay (negativo cancela).
+// Chamado com credential.mu travado.
+func (a *Agent) scheduleTokenRefreshLocked(delay time.Duration) {
+	if a.credential.refreshTimer != nil {
+		a.credential.refreshTimer.Stop()
+		a.credential.refreshTimer = nil
+	}
+	a.credential.nextRefresh = time.Time{}
+	if delay < 0 {
+		return
+	}
+	a.credential.nextRefresh = a.clock.Now().Add(delay)
+	a.credential.refreshTimer = time.AfterFunc(delay, a.refreshMachineToken)
+}
+
+// stopTokenRefresh cancela a renovação agendada
+func (a *Agent) stopTokenRefresh() {
+	a.credential.mu.Lock()
+	defer a.credential.mu.Unlock()
+	a.scheduleTokenRefreshLocked(-1)
+}
+
+// refreshMachineToken renova o token da máquina no backend e grava o novo.
+// Falhas transitórias são retentadas; token expirado, limite de renovações
+// ou recusa do backend exigem novo enrollment.
+func (a *Agent) refreshMachineToken() {
+	manager := a.comms()
+	if a.ctx.Err() != nil || manager == nil {
+		return
+	}
+	a.credential.mu.Lock()
+	current, store := a.credential.token, a.credential.store
+	a.credential.mu.Unlock()
+	if current == nil {
+		return
+	}
+
+	refreshed, err := manager.RefreshMachineToken(a.ctx, current, machineTokenMaxRefresh)
+	if err != nil {
+		if a.ctx.Err() != nil {
+			return
+		}
+		a.handleTokenRefreshFailure(current, err)
+		return
+	}
+
+	if err := store.Save(credentialFromToken(refreshed)); err != nil {
+		a.logger.WithField("error", err).Error("Failed to store refreshed machine token")
+	}
+
+	a.credential.mu.Lock()
+	a.credential.token = refreshed
+	a.credential.lastError = ""
+	a.scheduleTokenRefreshLocked(a.tokenRefreshDelay(refreshed))
+	a.credential.mu.Unlock()
+
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "machine_token_refreshed", "Machine token refreshed",
+		map[string]interface{}{
+			"token_id":      comms.TokenFingerprint(refreshed.Value),
+			"refresh_count": refreshed.RefreshCount,
+			"expires_at":    formatOptionalTime(refreshed.ExpiresAt),
+		})
+}
+
+// handleTokenRefreshFailure agenda nova tentativa ou, quando o token não pode
+// mais ser renovado, alerta que a máquina precisa de novo enrollment
+func (a *Agent) handleTokenRefreshFailure(current *comms.Token, err error) {
+	a.credential.mu.Lock()
+	a.credential.lastError = err.Error()
+
+	status := comms.HTTPStatusCode(err)
+	if errors.Is(err, comms.ErrTokenExpired) || errors.Is(err, comms.ErrTokenRefreshLimit) ||
+		status == http.StatusUnauthorized || status == http.StatusForbidden {
+		a.scheduleTokenRefreshLocked(-1)
+		a.credential.mu.Unlock()
+		a.recordEvent(events.CategoryAlert, events.SeverityCritical, "machine_token_reenrollment_required",
+			"Machine token can no longer be refreshed, a new enrollment is required",
+			map[string]interface{}{
+				"token_id":    comms.TokenFingerprint(current.Value),
+				"error":       err,
+				"remediation": "remove machine_credential.json from data_dir and restart the agent with a new enrollment key",
+			})
+		return
+	}
+
+	// Tenta de novo antes de expirar, sem esperar mais que o intervalo padrão
+	delay := machineTokenRetryInterval
+	if remaining := current.ExpiresAt.Sub(a.clock.Now()); !current.ExpiresAt.IsZero() && remaining/2 < delay {
+		delay = max(remaining/2, time.Second)
+	}
+	a.scheduleTokenRefreshLocked(delay)
+	next := a.credential.nextRefresh
+	a.credential.mu.Unlock()
+
+	a.recordEvent(events.CategoryAlert, events.SeverityWarning, "machine_token_refresh_failed",
+		"Machine token refresh failed", map[string]interface{}{
+			"error":        err,
+			"next_attempt": next.Format(time.RFC3339),
+		})
+}
+
+// machineCredentialStatus descreve o token da máquina em uso
+func (a *Agent) machineCredentialStatus() MachineCredentialStatus {
+	a.credential.mu.Lock()
+	defer a.credential.mu.Unlock()
+
+	status := MachineCredentialStatus{LastError: a.credential.lastError}
+	if token := a.credential.token; token != nil {
+		status.Enrolled = true
+		status.TokenID = comms.TokenFingerprint(token.Value)
+		status.IssuedAt = token.IssuedAt
+		status.ExpiresAt = token.ExpiresAt
+		status.RefreshCount = token.RefreshCount
+		status.NextRefresh = a.credential.nextRefresh
+	}
+	if a.credential.store != nil && status.Enrolled {
+		status.Storage = a.credential.store.Storage()
+	}
+	return status
+}
+
+// machineToken converte a credencial guardada no token usado pelo comms
+func machineToken(credential *credentials.Credential) *comms.Token {
+	return &comms.Token{
+		Value:        credential.Token,
+		IssuedAt:     credential.IssuedAt,
+		ExpiresAt:    credential.ExpiresAt,
+		RefreshCount: credential.RefreshCount,
+		MachineID:    credential.MachineID,
+	}
+}
+
+// credentialFromToken converte o token do comms na credencial guardada
+func credentialFromToken(token *comms.Token) *credentials.Credential {
+	return &credentials.Credential{
+		MachineID:    token.MachineID,
+		Token:        token.Value,
+		IssuedAt:     token.IssuedAt,
+		ExpiresAt:    token.ExpiresAt,
+		RefreshCount: token.RefreshCount,
+	}
+}
+
+// formatOptionalTime formata t em RFC 3339; zero vira vazio
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
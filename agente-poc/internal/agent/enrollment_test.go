@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/credentials"
+)
+
+// enrollmentBackend simula /machines/enroll: responde status e conta as
+// chamadas, guardando o Authorization de cada uma
+type enrollmentBackend struct {
+	server *httptest.Server
+	status int
+
+	mu    sync.Mutex
+	auths []string
+}
+
+func newEnrollmentBackend(t *testing.T, status int) *enrollmentBackend {
+	t.Helper()
+	backend := &enrollmentBackend{status: status}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/machines/enroll" {
+			http.NotFound(w, r)
+			return
+		}
+		backend.mu.Lock()
+		backend.auths = append(backend.auths, r.Header.Get("Authorization"))
+		backend.mu.Unlock()
+		w.WriteHeader(backend.status)
+		if backend.status == http.StatusOK {
+			_, _ = w.Write([]byte(`{"token": "machine-token", "issued_at": "2026-01-05T09:00:00Z", "expires_at": "2026-02-04T09:00:00Z"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error": "invalid enrollment key"}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	return backend
+}
+
+func (b *enrollmentBackend) calls() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.auths...)
+}
+
+// useFileCredentialStore troca o store da plataforma pelo cofre de arquivo
+func useFileCredentialStore(t *testing.T) {
+	t.Helper()
+	previous := newCredentialStore
+	newCredentialStore = credentials.NewFileStore
+	t.Cleanup(func() { newCredentialStore = previous })
+}
+
+// newEnrollmentTestAgent cria o agente com o machine_id já resolvido, como
+// em Start antes de initMachineCredential
+func newEnrollmentTestAgent(t *testing.T, extra map[string]interface{}) *Agent {
+	t.Helper()
+	a := newCapabilitiesTestAgent(t, extra)
+	a.initRegistration()
+	return a
+}
+
+func TestInitMachineCredentialEnrollsOnce(t *testing.T) {
+	useFileCredentialStore(t)
+	backend := newEnrollmentBackend(t, http.StatusOK)
+	t.Setenv(EnrollmentKeyEnv, "enroll-key")
+
+	a := newEnrollmentTestAgent(t, map[string]interface{}{
+		"backend_url": backend.server.URL,
+		"token":       "",
+	})
+	if a.config.EnrollmentKey != "enroll-key" {
+		t.Fatalf("enrollment key = %q", a.config.EnrollmentKey)
+	}
+	if err := a.initMachineCredential(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.stopTokenRefresh)
+
+	if calls := backend.calls(); len(calls) != 1 || calls[0] != "Bearer enroll-key" {
+		t.Fatalf("enrollment calls = %v", calls)
+	}
+	if token := a.machineTokenValue(); token != "machine-token" {
+		t.Fatalf("machine token = %q", token)
+	}
+	// A chave é de uso único: sai da configuração e do ambiente
+	if a.config.EnrollmentKey != "" || os.Getenv(EnrollmentKeyEnv) != "" {
+		t.Fatalf("enrollment key kept: %q, env %q", a.config.EnrollmentKey, os.Getenv(EnrollmentKeyEnv))
+	}
+	event := waitForEvent(t, a, "machine_enrolled")
+	if event.Data["storage"] != "file" {
+		t.Fatalf("machine_enrolled data = %v", event.Data)
+	}
+
+	stored, err := credentials.NewFileStore(a.config.DataDir, "").Load()
+	if err != nil || stored.Token != "machine-token" || stored.MachineID != "test-machine" {
+		t.Fatalf("stored credential = %+v, %v", stored, err)
+	}
+	if token := AuthToken(a.config); token != "machine-token" {
+		t.Fatalf("AuthToken = %q", token)
+	}
+
+	// Renovação agendada em 80% da validade de 30 dias
+	a.startTokenRefresh()
+	status := a.machineCredentialStatus()
+	if !status.Enrolled || status.Storage != "file" || !status.NextRefresh.Equal(a.clock.Now().Add(24*24*time.Hour)) {
+		t.Fatalf("credential status = %+v", status)
+	}
+
+	// Reinício com o mesmo data_dir: o token guardado é usado sem novo
+	// enrollment, mesmo com a chave ainda configurada
+	restarted := newEnrollmentTestAgent(t, map[string]interface{}{
+		"backend_url":    backend.server.URL,
+		"token":          "",
+		"enrollment_key": "enroll-key",
+		"data_dir":       a.config.DataDir,
+	})
+	if err := restarted.initMachineCredential(); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.calls()) != 1 {
+		t.Fatalf("enrollment repeated on restart: %v", backend.calls())
+	}
+	if token := restarted.machineTokenValue(); token != "machine-token" || restarted.config.EnrollmentKey != "" {
+		t.Fatalf("restart: token %q, enrollment key %q", token, restarted.config.EnrollmentKey)
+	}
+}
+
+func TestInitMachineCredentialRejectedKey(t *testing.T) {
+	useFileCredentialStore(t)
+	backend := newEnrollmentBackend(t, http.StatusUnauthorized)
+
+	// Com token na configuração, o agente segue com ele
+	a := newEnrollmentTestAgent(t, map[string]interface{}{
+		"backend_url":    backend.server.URL,
+		"enrollment_key": "bad-key",
+	})
+	if err := a.initMachineCredential(); err != nil {
+		t.Fatal(err)
+	}
+	event := waitForEvent(t, a, "enrollment_failed")
+	if !strings.Contains(event.Data["error"].(string), "rejected") {
+		t.Fatalf("enrollment_failed data = %v", event.Data)
+	}
+	if token := a.machineTokenValue(); token != "" || AuthToken(a.config) != "test-token" {
+		t.Fatalf("machine token %q, AuthToken %q", token, AuthToken(a.config))
+	}
+	if credentials.Stored(a.config.DataDir) {
+		t.Fatal("credential stored after a rejected enrollment")
+	}
+
+	// Sem token, o início falha
+	a = newEnrollmentTestAgent(t, map[string]interface{}{
+		"backend_url":    backend.server.URL,
+		"token":          "",
+		"enrollment_key": "bad-key",
+	})
+	if err := a.initMachineCredential(); err == nil || !strings.Contains(err.Error(), "enrollment key rejected") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"fmt"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// newEnvelopeSealer cria o sealer do modo envelope; nil se desativado. A
+// validação da configuração já garante a impressão digital pinada.
+func (a *Agent) newEnvelopeSealer() (*comms.EnvelopeSealer, error) {
+	config := a.config.Envelope
+	if config == nil || !config.Enabled {
+		return nil, nil
+	}
+
+	sealer, err := comms.NewEnvelopeSealer(config.BackendPublicKey, config.BackendKeyFingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize envelope encryption: %w", err)
+	}
+
+	source := "config"
+	if !sealer.HasKey() {
+		source = "registration"
+	}
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "envelope_enabled",
+		"Envelope encryption enabled for outbound payloads",
+		map[string]interface{}{"fingerprint": sealer.Fingerprint(), "key_source": source})
+	return sealer, nil
+}
+
+// envelopeStatus descreve o modo envelope para o health
+func (a *Agent) envelopeStatus() map[string]interface{} {
+	if a.comms() == nil {
+		return nil
+	}
+	return a.comms().EnvelopeStatus()
+}
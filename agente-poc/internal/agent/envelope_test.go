@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"agente-poc/internal/comms"
+)
+
+// newEnvelopeKey gera uma chave X25519 de backend em base64 e a impressão
+// digital correspondente
+func newEnvelopeKey(t *testing.T) (string, string) {
+	t.Helper()
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := private.PublicKey().Bytes()
+	return base64.StdEncoding.EncodeToString(raw), comms.EnvelopeKeyFingerprint(raw)
+}
+
+func TestLoadConfigEnvelope(t *testing.T) {
+	publicKey, fingerprint := newEnvelopeKey(t)
+	_, otherFingerprint := newEnvelopeKey(t)
+
+	tests := []struct {
+		name     string
+		envelope map[string]interface{}
+		wantErr  string
+	}{
+		{"disabled without key", map[string]interface{}{"enabled": false}, ""},
+		{"key from registration", map[string]interface{}{"enabled": true, "backend_key_fingerprint": fingerprint}, ""},
+		{"configured key", map[string]interface{}{"enabled": true, "backend_public_key": publicKey, "backend_key_fingerprint": fingerprint}, ""},
+		{"no fingerprint", map[string]interface{}{"enabled": true, "backend_public_key": publicKey}, "envelope.backend_key_fingerprint"},
+		{"key does not match the pin", map[string]interface{}{"enabled": true, "backend_public_key": publicKey, "backend_key_fingerprint": otherFingerprint}, "envelope.backend_public_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeTestConfig(t, map[string]interface{}{"envelope": tt.envelope}))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewEnvelopeSealer(t *testing.T) {
+	publicKey, fingerprint := newEnvelopeKey(t)
+
+	a, _ := newTestAgent(t, nil)
+	if sealer, err := a.newEnvelopeSealer(); sealer != nil || err != nil {
+		t.Fatalf("sealer without envelope config = %v, %v", sealer, err)
+	}
+
+	a, _ = newTestAgent(t, map[string]interface{}{
+		"envelope": map[string]interface{}{"enabled": true, "backend_key_fingerprint": fingerprint},
+	})
+	sealer, err := a.newEnvelopeSealer()
+	if err != nil || sealer.HasKey() {
+		t.Fatalf("sealer = %v, %v", sealer, err)
+	}
+	if event := waitForEvent(t, a, "envelope_enabled"); event.Data["key_source"] != "registration" || event.Data["fingerprint"] != fingerprint {
+		t.Fatalf("envelope_enabled data = %v", event.Data)
+	}
+
+	a, _ = newTestAgent(t, map[string]interface{}{
+		"envelope": map[string]interface{}{"enabled": true, "backend_public_key": publicKey, "backend_key_fingerprint": fingerprint},
+	})
+	if sealer, err := a.newEnvelopeSealer(); err != nil || !sealer.HasKey() {
+		t.Fatalf("sealer with the configured key = %v, %v", sealer, err)
+	}
+	if event := waitForEvent(t, a, "envelope_enabled"); event.Data["key_source"] != "config" {
+		t.Fatalf("envelope_enabled data = %v", event.Data)
+	}
+}
@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// defaultGetEventsLimit limita a resposta do get_events sem options.limit
+const defaultGetEventsLimit = 100
+
+// initEvents cria o pipeline de eventos com o sink do backend (exceto no
+// modo offline) e, se configurado, o log local em JSON Lines. Os eventos
+// ficam na fila até startEvents, chamado quando o communications manager
+// existe.
+func (a *Agent) initEvents() {
+	a.events = events.NewPipeline(a.clock.Now)
+	if !a.config.Offline {
+		a.events.AddSink("backend", events.SinkFunc(a.sendEventToBackend))
+	}
+	// O histórico em memória é criado no New e sobrevive a um novo Start
+	a.events.AddSink("memory", a.recentEvents)
+
+	if a.config.EventLogPath == "" {
+		return
+	}
+	sink, err := events.NewFileSink(a.config.EventLogPath, a.config.EventLogMaxBytes, a.config.EventLogMaxBackups)
+	if err != nil {
+		// O agente segue sem o log local; o backend ainda recebe os eventos
+		a.logger.WithFields(map[string]interface{}{
+			"path":  a.config.EventLogPath,
+			"error": err,
+		}).Error("Event log disabled")
+		return
+	}
+	a.events.AddSink("file", sink)
+	a.logger.WithField("path", a.config.EventLogPath).Info("Writing events to local event log")
+}
+
+// sendEventToBackend entrega um evento ao backend
+func (a *Agent) sendEventToBackend(event events.Event) error {
+	if a.comms() == nil {
+		return fmt.Errorf("communications not initialized")
+	}
+	return a.comms().SendEvent(event)
+}
+
+// recordEvent registra o evento no log do agente e o publica no pipeline,
+// para o backend e o log local receberem o mesmo conteúdo. fields vira
+// Event.Data; um "machine_id" em fields é usado como machine_id do evento.
+func (a *Agent) recordEvent(category, severity, eventType, message string, fields map[string]interface{}) {
+	data := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		// error não serializa em JSON; nil é omitido
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		} else if value == nil {
+			continue
+		}
+		data[key] = value
+	}
+
+	logFields := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		logFields[key] = value
+	}
+	logFields["event"] = eventType
+	logger := a.logger.WithFields(logFields)
+
+	switch severity {
+	case events.SeverityError, events.SeverityCritical:
+		logger.Error(message)
+	case events.SeverityWarning:
+		logger.Warning(message)
+	default:
+		logger.Info(message)
+	}
+
+	event := events.Event{
+		InstanceID: a.instanceID,
+		Category:   category,
+		Type:       eventType,
+		Severity:   severity,
+		Message:    message,
+		Data:       data,
+	}
+	if machineID, ok := data["machine_id"].(string); ok {
+		event.MachineID = machineID
+	}
+	a.events.Emit(event)
+}
+
+// recordSecurityEvent publica as recusas de segurança do executor (ver
+// executor.Config.OnSecurityEvent)
+func (a *Agent) recordSecurityEvent(eventType, message string, fields map[string]interface{}) {
+	a.recordEvent(events.CategorySecurity, events.SeverityWarning, eventType, message, fields)
+}
+
+// handleConnectionChange registra a conexão e a queda do WebSocket com o backend
+func (a *Agent) handleConnectionChange(connected bool) {
+	fields := map[string]interface{}{"transport": "websocket"}
+	if connected {
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, "backend_connected", "Connected to backend", fields)
+		return
+	}
+	a.recordEvent(events.CategoryAgent, events.SeverityWarning, "backend_disconnected", "Disconnected from backend", fields)
+}
+
+// handleEndpointFailover registra a troca de endpoint do backend. Os
+// circuit breakers abertos pelas falhas do endpoint antigo passam a
+// half-open, para o próximo envio testar o novo sem esperar o reset_timeout.
+func (a *Agent) handleEndpointFailover(transport, from, to string) {
+	if transport == "http" && a.breakers.HalfOpen() {
+		a.logger.Info("Circuit breakers half-open after endpoint failover")
+	}
+	a.recordEvent(events.CategoryAgent, events.SeverityWarning, "backend_failover", "Switched to another backend endpoint", map[string]interface{}{
+		"transport": transport,
+		"from":      from,
+		"to":        to,
+	})
+}
+
+// GetEvents retorna os eventos recentes do agente com timestamp posterior a
+// since (zero retorna todos), do mais antigo para o mais novo; com limit > 0,
+// apenas os limit mais recentes. O histórico guarda até event_buffer_size
+// eventos e recebe cada um quando o pipeline o entrega.
+func (a *Agent) GetEvents(since time.Time, limit int) []events.Event {
+	return a.recentEvents.Since(since, limit)
+}
+
+// handleGetEventsCommand responde com os eventos recentes em JSON. Options:
+// "since" (RFC 3339) e "limit" (padrão defaultGetEventsLimit).
+func (a *Agent) handleGetEventsCommand(command *comms.Command) {
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRunning,
+		Timestamp: a.clock.Now(),
+	}
+
+	var since time.Time
+	if value, _ := command.Options["since"].(string); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			_ = result.SetStatus(comms.StatusRejected)
+			result.SetError(comms.NewCodedError(comms.ErrCodeInvalidCommandField, "options.since: expected RFC 3339 timestamp"))
+			a.sendCommandResult(result)
+			return
+		}
+		since = parsed
+	}
+
+	limit := defaultGetEventsLimit
+	if value, ok := command.Options["limit"].(float64); ok {
+		if value < 1 || value != float64(int(value)) {
+			_ = result.SetStatus(comms.StatusRejected)
+			result.SetError(comms.NewCodedError(comms.ErrCodeInvalidCommandField, "options.limit: expected positive integer"))
+			a.sendCommandResult(result)
+			return
+		}
+		limit = int(value)
+	}
+
+	output, err := json.MarshalIndent(a.GetEvents(since, limit), "", "  ")
+	if err != nil {
+		_ = result.SetStatus(comms.StatusError)
+		result.SetError(err)
+	} else {
+		_ = result.SetStatus(comms.StatusSuccess)
+		result.Output = string(output)
+	}
+	a.sendCommandResult(result)
+}
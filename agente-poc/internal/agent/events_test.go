@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+func TestEventLogMatchesPublishedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "agent.jsonl")
+	a, _ := newTestAgent(t, map[string]interface{}{
+		"offline":        true,
+		"event_log_path": path,
+	})
+	a.events.Close()
+	a.initEvents()
+	a.events.SetMachineID(a.config.MachineID)
+	a.events.Start()
+
+	a.handleConnectionChange(false)
+	a.recordSecurityEvent("command_rejected", "Command rejected by whitelist", map[string]interface{}{"command": "rm"})
+	a.recordEvent(events.CategoryIdentity, events.SeverityInfo, "identity_migrated", "Switched identity",
+		map[string]interface{}{"machine_id": "new-id", "previous_machine_id": "test-machine"})
+	a.events.Close()
+
+	if _, ok := a.events.Stats()["backend"]; ok {
+		t.Fatal("backend sink registered in offline mode")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var written []events.Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event events.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %s: %v", scanner.Bytes(), err)
+		}
+		written = append(written, event)
+	}
+
+	published := a.GetEvents(time.Time{}, 0)
+	if len(written) != 3 || len(published) != 3 {
+		t.Fatalf("%d events in the file, %d published", len(written), len(published))
+	}
+	for i := range written {
+		// O arquivo passa por JSON; compara pela mesma serialização
+		want, _ := json.Marshal(published[i])
+		got, _ := json.Marshal(written[i])
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("event %d in the file:\n%s\npublished:\n%s", i, got, want)
+		}
+	}
+	if written[0].MachineID != "test-machine" || written[2].MachineID != "new-id" {
+		t.Fatalf("machine IDs %q and %q", written[0].MachineID, written[2].MachineID)
+	}
+}
+
+func TestGetEventsCommand(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		results []comms.CommandResult
+	)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/commands/result") {
+			var result comms.CommandResult
+			if json.NewDecoder(r.Body).Decode(&result) == nil {
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	a, fake := newTestAgent(t, map[string]interface{}{"backend_url": backend.URL})
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+
+	start := fake.Now()
+	for _, eventType := range []string{"first", "second", "third"} {
+		fake.Advance(time.Minute)
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, eventType, eventType, nil)
+	}
+	waitForEvent(t, a, "third")
+
+	// result envia o get_events e devolve o resultado recebido pelo backend
+	result := func(options map[string]interface{}) comms.CommandResult {
+		t.Helper()
+		mu.Lock()
+		results = nil
+		mu.Unlock()
+		a.handleGetEventsCommand(&comms.Command{ID: "cmd-events", Type: "get_events", Options: options})
+		mu.Lock()
+		defer mu.Unlock()
+		if len(results) != 1 {
+			t.Fatalf("%d results", len(results))
+		}
+		return results[0]
+	}
+
+	// limit fica com os mais recentes
+	got := result(map[string]interface{}{"limit": float64(1)})
+	var listed []events.Event
+	if err := json.Unmarshal([]byte(got.Output), &listed); err != nil {
+		t.Fatalf("output %q: %v", got.Output, err)
+	}
+	if got.Status != comms.StatusSuccess || len(listed) != 1 || listed[0].Type != "third" {
+		t.Fatalf("limit 1: %s %v", got.Status, listed)
+	}
+
+	// Depois de since; o resultado anterior pode já ter virado evento
+	got = result(map[string]interface{}{"since": start.Add(time.Minute).Format(time.RFC3339)})
+	if err := json.Unmarshal([]byte(got.Output), &listed); err != nil || len(listed) < 2 || listed[0].Type != "second" || listed[1].Type != "third" {
+		t.Fatalf("since the first event: %v, %v", listed, err)
+	}
+
+	for _, options := range []map[string]interface{}{
+		{"since": "yesterday"},
+		{"limit": float64(0)},
+		{"limit": 1.5},
+	} {
+		if got := result(options); got.Status != comms.StatusRejected || got.ErrorCode != comms.ErrCodeInvalidCommandField {
+			t.Errorf("options %v: %s / %s", options, got.Status, got.ErrorCode)
+		}
+	}
+}
+
+func TestEndpointFailoverHalfOpensBreakers(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]interface{}{
+		"backend_urls": []string{"https://backup.example.com"},
+	})
+	inventory := a.breakers.For(comms.EndpointInventory)
+	for inventory.State() != comms.BreakerOpen {
+		inventory.RecordFailure(errors.New("503 Service Unavailable"))
+	}
+
+	// Troca do WebSocket não diz nada sobre os envios HTTP
+	a.handleEndpointFailover("websocket", "wss://primary.example.com/ws", "wss://backup.example.com/ws")
+	if state := inventory.State(); state != comms.BreakerOpen {
+		t.Fatalf("inventory breaker after websocket failover = %s", state)
+	}
+
+	// No HTTP, o próximo inventário testa o novo endpoint sem esperar o
+	// reset_timeout
+	a.handleEndpointFailover("http", "http://127.0.0.1:1", "https://backup.example.com")
+	if state := inventory.State(); state != comms.BreakerHalfOpen {
+		t.Fatalf("inventory breaker after http failover = %s", state)
+	}
+
+	event := waitForEvent(t, a, "backend_failover")
+	if event.Data["transport"] != "websocket" || event.Data["to"] != "wss://backup.example.com/ws" {
+		t.Fatalf("first backend_failover data = %v", event.Data)
+	}
+	if n := countEvents(t, a, "backend_failover"); n != 2 {
+		t.Fatalf("%d backend_failover events, want 2", n)
+	}
+}
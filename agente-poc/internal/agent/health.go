@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/logging"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// Estados de saúde do sistema (SystemHealthStatus.Status)
+const (
+	HealthHealthy  = "healthy"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+)
+
+// healthSampleTTL é por quanto tempo uma amostra de saúde é reaproveitada
+// (heartbeats, pings e status_request leem a mesma)
+const healthSampleTTL = 10 * time.Second
+
+// healthSampleTimeout limita a leitura de CPU e disco de uma amostra
+const healthSampleTimeout = 2 * time.Second
+
+// HealthThresholds são os percentuais acima dos quais CPU, memória e disco
+// passam a warning e a critical (bloco "health_thresholds")
+type HealthThresholds struct {
+	CPUWarning     float64 `json:"cpu_warning"`
+	CPUCritical    float64 `json:"cpu_critical"`
+	MemoryWarning  float64 `json:"memory_warning"`
+	MemoryCritical float64 `json:"memory_critical"`
+	DiskWarning    float64 `json:"disk_warning"`
+	DiskCritical   float64 `json:"disk_critical"`
+}
+
+// DefaultHealthThresholds são os limites usados sem configuração
+func DefaultHealthThresholds() HealthThresholds {
+	return HealthThresholds{
+		CPUWarning:     60,
+		CPUCritical:    80,
+		MemoryWarning:  80,
+		MemoryCritical: 90,
+		DiskWarning:    85,
+		DiskCritical:   95,
+	}
+}
+
+// withDefaults preenche com o padrão os limites não configurados (zero)
+func (t HealthThresholds) withDefaults() HealthThresholds {
+	defaults := DefaultHealthThresholds()
+	fill := func(value *float64, fallback float64) {
+		if *value == 0 {
+			*value = fallback
+		}
+	}
+	fill(&t.CPUWarning, defaults.CPUWarning)
+	fill(&t.CPUCritical, defaults.CPUCritical)
+	fill(&t.MemoryWarning, defaults.MemoryWarning)
+	fill(&t.MemoryCritical, defaults.MemoryCritical)
+	fill(&t.DiskWarning, defaults.DiskWarning)
+	fill(&t.DiskCritical, defaults.DiskCritical)
+	return t
+}
+
+// Validate confere cada par de limites: entre 0 e 100 e warning abaixo de
+// critical. Limites zerados valem o padrão.
+func (t HealthThresholds) Validate() []string {
+	t = t.withDefaults()
+	var errors []string
+	check := func(name string, warning, critical float64) {
+		switch {
+		case warning < 0 || critical > 100:
+			errors = append(errors, fmt.Sprintf("health_thresholds.%s_warning e %s_critical devem estar entre 0 e 100", name, name))
+		case warning >= critical:
+			errors = append(errors, fmt.Sprintf("health_thresholds.%s_warning deve ser menor que %s_critical", name, name))
+		}
+	}
+	check("cpu", t.CPUWarning, t.CPUCritical)
+	check("memory", t.MemoryWarning, t.MemoryCritical)
+	check("disk", t.DiskWarning, t.DiskCritical)
+	return errors
+}
+
+// level classifica um percentual pelos limites warning e critical
+func level(value, warning, critical float64) string {
+	switch {
+	case value > critical:
+		return HealthCritical
+	case value > warning:
+		return HealthWarning
+	default:
+		return HealthHealthy
+	}
+}
+
+// classifyHealth retorna o pior estado entre CPU, memória e disco
+func classifyHealth(status *comms.SystemHealthStatus, thresholds HealthThresholds) string {
+	levels := []string{
+		level(status.CPUUsage, thresholds.CPUWarning, thresholds.CPUCritical),
+		memoryHealth(status.MemoryUsage, status.MemoryPressure, thresholds),
+		level(status.DiskUsage, thresholds.DiskWarning, thresholds.DiskCritical),
+	}
+
+	worst := HealthHealthy
+	for _, l := range levels {
+		if l == HealthCritical {
+			return HealthCritical
+		}
+		if l == HealthWarning {
+			worst = HealthWarning
+		}
+	}
+	return worst
+}
+
+// memoryHealth classifica a memória em healthy, warning ou critical. Com o
+// nível de pressão do macOS disponível, ele decide: o percentual usado do
+// macOS inclui cache e páginas comprimidas e fica alto em Macs saudáveis.
+func memoryHealth(usedPercent float64, pressure string, thresholds HealthThresholds) string {
+	switch collector.MemoryPressureRank(pressure) {
+	case 0:
+		return HealthHealthy
+	case 1:
+		return HealthWarning
+	case 2:
+		return HealthCritical
+	}
+	return level(usedPercent, thresholds.MemoryWarning, thresholds.MemoryCritical)
+}
+
+// healthSampler lê CPU, memória e uso do sistema de arquivos raiz e guarda a
+// amostra por healthSampleTTL, para que cada heartbeat não pague a coleta
+type healthSampler struct {
+	collector  *collector.SystemCollector
+	thresholds HealthThresholds
+	logger     logging.Logger
+	clock      clock.Clock
+
+	mu      sync.Mutex
+	sampled time.Time
+	last    comms.SystemHealthStatus
+}
+
+// newHealthSampler cria o sampler com os limites informados (zeros valem o
+// padrão)
+func newHealthSampler(c *collector.SystemCollector, thresholds HealthThresholds, logger logging.Logger, clk clock.Clock) *healthSampler {
+	return &healthSampler{
+		collector:  c,
+		thresholds: thresholds.withDefaults(),
+		logger:     logger,
+		clock:      clock.OrReal(clk),
+	}
+}
+
+// Sample retorna a amostra em cache ou, vencida, uma nova. Falhas de leitura
+// deixam a métrica zerada, sem impedir as demais.
+func (s *healthSampler) Sample() comms.SystemHealthStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if !s.sampled.IsZero() && !clock.Expired(s.clock, s.sampled, healthSampleTTL) {
+		return s.last
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthSampleTimeout)
+	defer cancel()
+
+	var status comms.SystemHealthStatus
+
+	// Intervalo zero: uso desde a leitura anterior, sem bloquear
+	if percent, err := cpu.PercentWithContext(ctx, 0, false); err == nil && len(percent) > 0 {
+		status.CPUUsage = percent[0]
+	} else if err != nil {
+		s.logger.WithField("error", err).Debug("Failed to read CPU usage for health status")
+	}
+
+	if memory, err := s.collector.CollectMemoryInfo(); err == nil {
+		status.MemoryUsage = memory.UsedPercent
+		if memory.Darwin != nil {
+			status.MemoryPressure = memory.Darwin.PressureLevel
+		}
+	} else {
+		s.logger.WithField("error", err).Debug("Failed to collect memory info for health status")
+	}
+
+	if usage, err := disk.UsageWithContext(ctx, rootFilesystem()); err == nil {
+		status.DiskUsage = usage.UsedPercent
+	} else {
+		s.logger.WithField("error", err).Debug("Failed to read root filesystem usage for health status")
+	}
+
+	status.Status = classifyHealth(&status, s.thresholds)
+
+	s.last = status
+	s.sampled = now
+	return status
+}
+
+// rootFilesystem é o sistema de arquivos do sistema operacional: "/" ou,
+// no Windows, a unidade do sistema
+func rootFilesystem() string {
+	if runtime.GOOS == "windows" {
+		if drive := os.Getenv("SystemDrive"); drive != "" {
+			return drive + string(filepath.Separator)
+		}
+		return `C:\`
+	}
+	return "/"
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+)
+
+func TestMemoryHealthPrefersPressure(t *testing.T) {
+	thresholds := DefaultHealthThresholds()
+	tests := []struct {
+		used     float64
+		pressure string
+		want     string
+	}{
+		// Mac saudável com cache e páginas comprimidas: percentual alto, pressão normal
+		{95, collector.MemoryPressureNormal, HealthHealthy},
+		{40, collector.MemoryPressureWarning, HealthWarning},
+		{40, collector.MemoryPressureCritical, HealthCritical},
+		// Sem pressão conhecida, valem os limites de percentual
+		{95, "", HealthCritical},
+		{85, "", HealthWarning},
+		{50, "", HealthHealthy},
+		{95, "unknown", HealthCritical},
+	}
+	for _, tt := range tests {
+		if got := memoryHealth(tt.used, tt.pressure, thresholds); got != tt.want {
+			t.Errorf("memoryHealth(%.0f, %q) = %s, want %s", tt.used, tt.pressure, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyHealthUsesMemoryPressure(t *testing.T) {
+	thresholds := DefaultHealthThresholds()
+	status := &comms.SystemHealthStatus{CPUUsage: 10, MemoryUsage: 97, DiskUsage: 40, MemoryPressure: collector.MemoryPressureNormal}
+	if got := classifyHealth(status, thresholds); got != HealthHealthy {
+		t.Fatalf("health with normal pressure = %s, want healthy", got)
+	}
+	status.MemoryPressure = ""
+	if got := classifyHealth(status, thresholds); got != HealthCritical {
+		t.Fatalf("health without pressure = %s, want critical", got)
+	}
+}
+
+func TestClassifyHealthThresholds(t *testing.T) {
+	custom := HealthThresholds{CPUWarning: 30, CPUCritical: 50, DiskWarning: 50, DiskCritical: 70}.withDefaults()
+	tests := []struct {
+		name       string
+		status     comms.SystemHealthStatus
+		thresholds HealthThresholds
+		want       string
+	}{
+		{"idle", comms.SystemHealthStatus{CPUUsage: 10, MemoryUsage: 40, DiskUsage: 40}, DefaultHealthThresholds(), HealthHealthy},
+		// O limite é exclusivo: só acima dele o estado muda
+		{"at the cpu warning", comms.SystemHealthStatus{CPUUsage: 60}, DefaultHealthThresholds(), HealthHealthy},
+		{"above the cpu warning", comms.SystemHealthStatus{CPUUsage: 60.1}, DefaultHealthThresholds(), HealthWarning},
+		{"cpu critical", comms.SystemHealthStatus{CPUUsage: 85}, DefaultHealthThresholds(), HealthCritical},
+		{"disk warning", comms.SystemHealthStatus{DiskUsage: 90}, DefaultHealthThresholds(), HealthWarning},
+		{"disk critical", comms.SystemHealthStatus{DiskUsage: 96}, DefaultHealthThresholds(), HealthCritical},
+		// O pior estado vence
+		{"warning and critical", comms.SystemHealthStatus{CPUUsage: 70, DiskUsage: 96}, DefaultHealthThresholds(), HealthCritical},
+		{"custom cpu warning", comms.SystemHealthStatus{CPUUsage: 40}, custom, HealthWarning},
+		{"custom disk critical", comms.SystemHealthStatus{DiskUsage: 75}, custom, HealthCritical},
+		{"custom keeps memory default", comms.SystemHealthStatus{MemoryUsage: 85}, custom, HealthWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyHealth(&tt.status, tt.thresholds); got != tt.want {
+				t.Fatalf("classifyHealth(%+v) = %s, want %s", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthThresholdsValidate(t *testing.T) {
+	if errs := (HealthThresholds{}).Validate(); len(errs) != 0 {
+		t.Fatalf("zero thresholds (defaults) rejected: %v", errs)
+	}
+	if got := (HealthThresholds{DiskWarning: 70}).withDefaults(); got.DiskWarning != 70 || got.DiskCritical != 95 || got.CPUWarning != 60 {
+		t.Fatalf("withDefaults = %+v", got)
+	}
+
+	tests := []struct {
+		thresholds HealthThresholds
+		key        string
+	}{
+		{HealthThresholds{CPUWarning: 90, CPUCritical: 80}, "health_thresholds.cpu_warning"},
+		{HealthThresholds{MemoryWarning: 50, MemoryCritical: 50}, "health_thresholds.memory_warning"},
+		{HealthThresholds{DiskCritical: 120}, "health_thresholds.disk_warning"},
+		{HealthThresholds{CPUWarning: -1}, "health_thresholds.cpu_warning"},
+		// Só o warning configurado, acima do critical padrão
+		{HealthThresholds{MemoryWarning: 95}, "health_thresholds.memory_warning"},
+	}
+	for _, tt := range tests {
+		errs := tt.thresholds.Validate()
+		if len(errs) != 1 || !strings.Contains(errs[0], tt.key) {
+			t.Errorf("Validate(%+v) = %v, want one error about %s", tt.thresholds, errs, tt.key)
+		}
+	}
+}
+
+func TestHealthSamplerCachesSample(t *testing.T) {
+	a, fake := newTestAgent(t, nil)
+	c := collector.New(time.Minute, a.logger)
+	t.Cleanup(func() { _ = c.Close() })
+	// Limites de disco mínimos: qualquer sistema de arquivos em uso é critical
+	sampler := newHealthSampler(c, HealthThresholds{DiskWarning: 0.001, DiskCritical: 0.002}, a.logger, fake)
+
+	first := sampler.Sample()
+	if first.DiskUsage <= 0 || first.MemoryUsage <= 0 || first.Status != HealthCritical {
+		t.Fatalf("first sample = %+v", first)
+	}
+	sampled := sampler.sampled
+
+	fake.Advance(healthSampleTTL)
+	if second := sampler.Sample(); second != first || !sampler.sampled.Equal(sampled) {
+		t.Fatalf("sample within the TTL was taken again: %+v", second)
+	}
+
+	fake.Advance(time.Millisecond)
+	sampler.Sample()
+	if !sampler.sampled.Equal(fake.Now()) {
+		t.Fatalf("sample after the TTL taken at %s, want %s", sampler.sampled, fake.Now())
+	}
+}
+
+func TestHeartbeatCarriesSampledHealth(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		healths []map[string]interface{}
+	)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == comms.EndpointHeartbeat {
+			var body struct {
+				SystemHealth map[string]interface{} `json:"system_health"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+				mu.Lock()
+				healths = append(healths, body.SystemHealth)
+				mu.Unlock()
+			}
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	a, _ := newTestAgent(t, map[string]interface{}{
+		"backend_url":       backend.URL,
+		"health_thresholds": map[string]interface{}{"disk_warning": 0.001, "disk_critical": 0.002},
+	})
+	// Como no Start: o sampler usa o collector e os limites configurados
+	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	t.Cleanup(func() { _ = a.collector.Close() })
+	a.health = newHealthSampler(a.collector, a.config.HealthThresholds, a.logger, a.clock)
+
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := manager.SendHeartbeat(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(healths) != 2 {
+		t.Fatalf("%d heartbeats received", len(healths))
+	}
+	health := healths[0]
+	if health["status"] != HealthCritical || health["disk_usage_percent"].(float64) <= 0 {
+		t.Fatalf("heartbeat system_health = %v", health)
+	}
+	for _, key := range []string{"cpu_usage_percent", "memory_usage_percent"} {
+		if _, ok := health[key].(float64); !ok {
+			t.Errorf("system_health without %s: %v", key, health)
+		}
+	}
+	// O segundo heartbeat, dentro do TTL, reaproveita a amostra
+	if !reflect.DeepEqual(healths[1], health) {
+		t.Fatalf("second heartbeat health %v differs from %v", healths[1], health)
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/events"
+	"agente-poc/internal/logging"
+)
+
+// testLogger descarta tudo abaixo de FATAL, para não poluir a saída dos testes
+func testLogger(tb testing.TB) logging.Logger {
+	tb.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return logger
+}
+
+// writeTestConfig grava um config.json mínimo, com data_dir temporário,
+// acrescido de extra
+func writeTestConfig(t *testing.T, extra map[string]interface{}) string {
+	t.Helper()
+	dir := t.TempDir()
+	document := map[string]interface{}{
+		"machine_id":          "test-machine",
+		"backend_url":         "http://127.0.0.1:1",
+		"websocket_url":       "ws://127.0.0.1:1",
+		"token":               "test-token",
+		"heartbeat_interval":  30,
+		"collection_interval": 60,
+		"command_timeout":     30,
+		"data_dir":            filepath.Join(dir, "data"),
+	}
+	for key, value := range extra {
+		document[key] = value
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestAgent cria um agente sem Start, com relógio falso e pipeline de
+// eventos entregando apenas ao histórico em memória
+func newTestAgent(t *testing.T, extra map[string]interface{}) (*Agent, *clock.Fake) {
+	t.Helper()
+	config, err := LoadConfig(writeTestConfig(t, extra))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(config, testLogger(t))
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	a.SetClock(fake)
+	a.events = events.NewPipeline(fake.Now)
+	a.events.AddSink("memory", a.recentEvents)
+	a.events.Start()
+	t.Cleanup(func() {
+		a.cancel()
+		a.events.Close()
+	})
+	return a, fake
+}
+
+// waitForEvent aguarda um evento do tipo informado no histórico do agente
+func waitForEvent(t *testing.T, a *Agent, eventType string) events.Event {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, event := range a.recentEvents.Since(time.Time{}, 0) {
+			if event.Type == eventType {
+				return event
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("event %s not recorded; got %v", eventType, recordedEventTypes(a))
+	return events.Event{}
+}
+
+// recordedEventTypes lista os tipos já entregues ao histórico
+func recordedEventTypes(a *Agent) []string {
+	var types []string
+	for _, event := range a.recentEvents.Since(time.Time{}, 0) {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// countEvents conta os eventos do tipo informado, depois de esvaziar o
+// pipeline com um evento marcador
+func countEvents(t *testing.T, a *Agent, eventType string) int {
+	t.Helper()
+	marker := "test_marker_" + time.Now().Format("150405.000000000")
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, marker, "marker", nil)
+	waitForEvent(t, a, marker)
+
+	count := 0
+	for _, recorded := range recordedEventTypes(a) {
+		if recorded == eventType {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,300 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agente-poc/internal/events"
+	"agente-poc/internal/timeutil"
+)
+
+// identityFile guarda o machine_id gerado pelo agente e a migração em andamento
+const identityFile = "identity.json"
+
+// Estados de uma migração de machine_id
+const (
+	IdentityMigrationPending = "pending"
+	IdentityMigrationAborted = "aborted"
+)
+
+// identityMigration é uma troca de machine_id aguardando o backend vincular
+// o novo ID ao atual
+type identityMigration struct {
+	NewMachineID string    `json:"new_machine_id"`
+	StartedAt    time.Time `json:"started_at"`
+	State        string    `json:"state"`
+	AbortedAt    time.Time `json:"aborted_at,omitempty"`
+}
+
+// identityState é a forma persistida da identidade da máquina
+type identityState struct {
+	MachineID string             `json:"machine_id"`
+	Migration *identityMigration `json:"migration,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// IdentityStatus descreve a identidade em uso e a migração, exposto em Health()
+type IdentityStatus struct {
+	MachineID    string    `json:"machine_id"`
+	NewMachineID string    `json:"new_machine_id,omitempty"`
+	Migration    string    `json:"migration,omitempty"`
+	StartedAt    time.Time `json:"started_at,omitempty"`
+	Deadline     time.Time `json:"deadline,omitempty"`
+}
+
+// identity guarda a identidade persistida. managed indica que o machine_id
+// foi gerado pelo agente (não configurado) e portanto é persistido e migrado.
+type identity struct {
+	mu      sync.Mutex
+	managed bool
+	state   identityState
+}
+
+// loadIdentity lê a identidade persistida; ausente retorna estado vazio
+func loadIdentity(dataDir string) (identityState, error) {
+	var state identityState
+
+	data, err := os.ReadFile(filepath.Join(dataDir, identityFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read identity: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return identityState{}, fmt.Errorf("failed to parse identity: %w", err)
+	}
+	return state, nil
+}
+
+// saveIdentity persiste a identidade de forma atômica (arquivo temporário + rename)
+func saveIdentity(dataDir string, state identityState) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal identity: %w", err)
+	}
+
+	path := filepath.Join(dataDir, identityFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// previousMachineID recupera o machine_id usado antes de a identidade ser
+// persistida: o original de um override de conflito ou o da sequência de
+// inventário. Vazio em uma instalação nova.
+func previousMachineID(dataDir string) string {
+	if data, err := os.ReadFile(filepath.Join(dataDir, machineIDOverrideFile)); err == nil {
+		var override machineIDOverride
+		if json.Unmarshal(data, &override) == nil && override.OriginalMachineID != "" {
+			return override.OriginalMachineID
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dataDir, inventorySequenceFile)); err == nil {
+		var state sequenceState
+		if json.Unmarshal(data, &state) == nil {
+			return state.MachineID
+		}
+	}
+	return ""
+}
+
+// resolveIdentity decide o machine_id em uso a partir do gerado pelo
+// collector e do persistido. Se forem diferentes, o persistido continua em
+// uso e o gerado entra em migração até o backend confirmar o vínculo.
+// reliable=false (ID de fallback por falha na coleta) nunca inicia migração.
+func (a *Agent) resolveIdentity(generated string, reliable bool) string {
+	now := a.clock.Now()
+
+	a.identity.mu.Lock()
+	defer a.identity.mu.Unlock()
+
+	a.identity.managed = true
+
+	state, err := loadIdentity(a.config.DataDir)
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Ignoring unreadable machine identity, starting over")
+	}
+
+	dirty := false
+	if state.MachineID == "" {
+		state.MachineID = previousMachineID(a.config.DataDir)
+		if state.MachineID == "" || !reliable {
+			state.MachineID = generated
+		}
+		dirty = true
+	}
+
+	switch migration := state.Migration; {
+	case !reliable:
+		// Mantém o persistido e qualquer migração como está
+
+	case generated == state.MachineID:
+		if migration != nil {
+			// A estratégia voltou a produzir o ID atual: nada a migrar
+			state.Migration = nil
+			dirty = true
+		}
+
+	case migration != nil && migration.NewMachineID == generated:
+		// Reinício no meio da migração (ou já abandonada para este ID)
+		if migration.State == IdentityMigrationPending {
+			a.logger.WithFields(map[string]interface{}{
+				"machine_id":     state.MachineID,
+				"new_machine_id": generated,
+				"started_at":     migration.StartedAt.Format(time.RFC3339),
+			}).Info("Resuming machine ID migration")
+		}
+
+	default:
+		state.Migration = &identityMigration{
+			NewMachineID: generated,
+			StartedAt:    now,
+			State:        IdentityMigrationPending,
+		}
+		dirty = true
+		a.recordEvent(events.CategoryIdentity, events.SeverityWarning, "identity_migration_started",
+			"Machine ID strategy produced a new ID, reporting both until the backend links them",
+			map[string]interface{}{
+				"machine_id":     state.MachineID,
+				"new_machine_id": generated,
+				"window":         timeutil.FormatDurationHuman(a.config.MachineIDMigrationWindow),
+			})
+	}
+
+	if dirty {
+		state.UpdatedAt = now
+		if err := saveIdentity(a.config.DataDir, state); err != nil {
+			a.logger.WithField("error", err).Warning("Failed to persist machine identity")
+		}
+	}
+	a.identity.state = state
+
+	a.expireIdentityMigrationLocked(now)
+	return state.MachineID
+}
+
+// pendingNewMachineID retorna o machine_id em migração (vazio se nenhum)
+func (a *Agent) pendingNewMachineID() string {
+	a.identity.mu.Lock()
+	defer a.identity.mu.Unlock()
+
+	if m := a.identity.state.Migration; m != nil && m.State == IdentityMigrationPending {
+		return m.NewMachineID
+	}
+	return ""
+}
+
+// identityStatus retorna a identidade e a migração para Health()
+func (a *Agent) identityStatus() IdentityStatus {
+	a.identity.mu.Lock()
+	defer a.identity.mu.Unlock()
+
+	status := IdentityStatus{MachineID: a.identity.state.MachineID}
+	if m := a.identity.state.Migration; m != nil {
+		status.NewMachineID = m.NewMachineID
+		status.Migration = m.State
+		status.StartedAt = m.StartedAt
+		if m.State == IdentityMigrationPending {
+			status.Deadline = m.StartedAt.Add(a.config.MachineIDMigrationWindow)
+		}
+	}
+	return status
+}
+
+// checkIdentityMigration abandona a migração cuja janela expirou
+func (a *Agent) checkIdentityMigration() {
+	a.identity.mu.Lock()
+	defer a.identity.mu.Unlock()
+	a.expireIdentityMigrationLocked(a.clock.Now())
+}
+
+// expireIdentityMigrationLocked abandona a migração pendente se a janela
+// passou sem confirmação do backend: o ID antigo continua em uso e o novo
+// não é mais anunciado. Chamado com identity.mu travado.
+func (a *Agent) expireIdentityMigrationLocked(now time.Time) {
+	m := a.identity.state.Migration
+	if m == nil || m.State != IdentityMigrationPending {
+		return
+	}
+	deadline := m.StartedAt.Add(a.config.MachineIDMigrationWindow)
+	if now.Before(deadline) {
+		return
+	}
+
+	m.State = IdentityMigrationAborted
+	m.AbortedAt = now
+	a.identity.state.UpdatedAt = now
+	if err := saveIdentity(a.config.DataDir, a.identity.state); err != nil {
+		a.logger.WithField("error", err).Warning("Failed to persist machine identity")
+	}
+
+	if a.comms() != nil {
+		a.comms().SetNewMachineID("")
+	}
+
+	a.recordEvent(events.CategoryIdentity, events.SeverityError, "identity_migration_aborted",
+		"Machine ID migration aborted: backend did not link the new ID within the migration window",
+		map[string]interface{}{
+			"machine_id":     a.identity.state.MachineID,
+			"new_machine_id": m.NewMachineID,
+			"started_at":     m.StartedAt.Format(time.RFC3339),
+			"remediation": fmt.Sprintf("The backend never confirmed the link. Link the IDs in the backend and delete %s "+
+				"from the data directory to retry the migration.", identityFile),
+		})
+}
+
+// completeIdentityMigration troca o machine_id persistido pelo novo após o
+// backend confirmar o vínculo (identity_linked na resposta do registro)
+func (a *Agent) completeIdentityMigration(newMachineID string) {
+	a.identity.mu.Lock()
+	m := a.identity.state.Migration
+	if m == nil || m.State != IdentityMigrationPending || m.NewMachineID != newMachineID {
+		a.identity.mu.Unlock()
+		return
+	}
+
+	oldMachineID := a.identity.state.MachineID
+	next := identityState{MachineID: newMachineID, UpdatedAt: a.clock.Now()}
+	if err := saveIdentity(a.config.DataDir, next); err != nil {
+		// Continua migrando; a próxima confirmação tenta de novo
+		a.identity.mu.Unlock()
+		a.logger.WithField("error", err).Error("Failed to persist migrated machine ID")
+		return
+	}
+	a.identity.state = next
+	a.identity.mu.Unlock()
+
+	a.registration.mu.Lock()
+	a.registration.originalID = newMachineID
+	a.registration.status.MachineID = newMachineID
+	a.registration.status.OriginalMachineID = ""
+	a.registration.mu.Unlock()
+
+	if a.inventorySeq != nil {
+		if err := a.inventorySeq.Reset(newMachineID); err != nil {
+			a.logger.WithField("error", err).Warning("Failed to persist inventory sequence state")
+		}
+	}
+	a.comms().SetNewMachineID("")
+	a.comms().UpdateSystemData(newMachineID, "")
+	a.events.SetMachineID(newMachineID)
+
+	a.recordEvent(events.CategoryIdentity, events.SeverityInfo, "identity_migrated",
+		"Backend linked the new machine ID, switched identity",
+		map[string]interface{}{
+			"previous_machine_id": oldMachineID,
+			"machine_id":          newMachineID,
+		})
+}
@@ -0,0 +1,266 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// identityBackend é um backend falso que confirma o vínculo do
+// new_machine_id quando link está ligado e registra os pares recebidos
+type identityBackend struct {
+	mu       sync.Mutex
+	link     bool
+	requests [][2]string
+}
+
+func (b *identityBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/machines/register") {
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+
+	var request struct {
+		MachineID    string `json:"machine_id"`
+		NewMachineID string `json:"new_machine_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&request)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, [2]string{request.MachineID, request.NewMachineID})
+	if b.link && request.NewMachineID != "" {
+		_, _ = w.Write([]byte(`{"success":true,"identity_linked":true}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"success":true}`))
+}
+
+func (b *identityBackend) setLink(link bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.link = link
+}
+
+// lastRequest retorna o último par (machine_id, new_machine_id) recebido
+func (b *identityBackend) lastRequest() [2]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.requests) == 0 {
+		return [2]string{}
+	}
+	return b.requests[len(b.requests)-1]
+}
+
+// newIdentityTestAgent cria um agente sem machine_id configurado em dataDir e
+// resolve a identidade a partir de generated, como no Start
+func newIdentityTestAgent(t *testing.T, dataDir, generated string, extra map[string]interface{}) *Agent {
+	t.Helper()
+	config := map[string]interface{}{"machine_id": "", "data_dir": dataDir}
+	for key, value := range extra {
+		config[key] = value
+	}
+	a, _ := newTestAgent(t, config)
+	a.config.MachineID = a.resolveIdentity(generated, true)
+	return a
+}
+
+// readIdentity lê a identidade persistida em dataDir
+func readIdentity(t *testing.T, dataDir string) identityState {
+	t.Helper()
+	state, err := loadIdentity(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestIdentityMigrationStarts(t *testing.T) {
+	dataDir := t.TempDir()
+	if a := newIdentityTestAgent(t, dataDir, "old-id", nil); a.config.MachineID != "old-id" || a.pendingNewMachineID() != "" {
+		t.Fatalf("fresh install resolved %q, pending %q", a.config.MachineID, a.pendingNewMachineID())
+	}
+
+	// A estratégia passa a gerar outro ID: o antigo continua em uso
+	a := newIdentityTestAgent(t, dataDir, "new-id", nil)
+	if a.config.MachineID != "old-id" || a.pendingNewMachineID() != "new-id" {
+		t.Fatalf("resolved %q, pending %q", a.config.MachineID, a.pendingNewMachineID())
+	}
+	state := readIdentity(t, dataDir)
+	if state.MachineID != "old-id" || state.Migration == nil || state.Migration.State != IdentityMigrationPending {
+		t.Fatalf("persisted identity: %+v", state)
+	}
+	event := waitForEvent(t, a, "identity_migration_started")
+	if event.Data["machine_id"] != "old-id" || event.Data["new_machine_id"] != "new-id" {
+		t.Fatalf("identity_migration_started data = %v", event.Data)
+	}
+
+	status := a.identityStatus()
+	if status.Migration != IdentityMigrationPending || !status.Deadline.Equal(state.Migration.StartedAt.Add(a.config.MachineIDMigrationWindow)) {
+		t.Fatalf("identity status: %+v", status)
+	}
+}
+
+func TestIdentityMigrationResumesAfterRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	newIdentityTestAgent(t, dataDir, "old-id", nil)
+	first := newIdentityTestAgent(t, dataDir, "new-id", nil)
+	startedAt := readIdentity(t, dataDir).Migration.StartedAt
+	waitForEvent(t, first, "identity_migration_started")
+
+	restarted, fake := newTestAgent(t, map[string]interface{}{"machine_id": "", "data_dir": dataDir})
+	fake.Advance(time.Hour)
+	if id := restarted.resolveIdentity("new-id", true); id != "old-id" {
+		t.Fatalf("machine ID after restart = %q, want old-id", id)
+	}
+	if restarted.pendingNewMachineID() != "new-id" {
+		t.Fatal("migration not resumed after restart")
+	}
+	if state := readIdentity(t, dataDir); !state.Migration.StartedAt.Equal(startedAt) {
+		t.Fatalf("migration restarted at %s, want %s", state.Migration.StartedAt, startedAt)
+	}
+	if n := countEvents(t, restarted, "identity_migration_started"); n != 0 {
+		t.Fatalf("identity_migration_started recorded %d times after restart", n)
+	}
+
+	// A estratégia voltou a gerar o ID atual: a migração é descartada
+	back := newIdentityTestAgent(t, dataDir, "old-id", nil)
+	if back.config.MachineID != "old-id" || back.pendingNewMachineID() != "" || readIdentity(t, dataDir).Migration != nil {
+		t.Fatal("migration kept after the strategy produced the current ID again")
+	}
+}
+
+func TestIdentityMigrationHandshake(t *testing.T) {
+	backend := &identityBackend{}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+
+	dataDir := t.TempDir()
+	urls := map[string]interface{}{
+		"backend_url":   server.URL,
+		"websocket_url": "ws" + strings.TrimPrefix(server.URL, "http") + "/ws",
+	}
+	newIdentityTestAgent(t, dataDir, "old-id", urls)
+	a := newIdentityTestAgent(t, dataDir, "new-id", urls)
+	a.initRegistration()
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+	t.Cleanup(a.stopRegistrationRetry)
+
+	// Sem confirmação, os dois IDs são enviados e o registro é repetido
+	a.handleRegistration(a.comms().RegisterMachine())
+	if got := backend.lastRequest(); got != [2]string{"old-id", "new-id"} {
+		t.Fatalf("registration sent %v", got)
+	}
+	if status := a.registrationStatus(); status.MachineID != "old-id" || status.NextAttempt.IsZero() {
+		t.Fatalf("registration while migrating: %+v", status)
+	}
+
+	// O backend vincula os IDs: a identidade passa a ser a nova
+	backend.setLink(true)
+	a.handleRegistration(a.comms().RegisterMachine())
+	if a.pendingNewMachineID() != "" || a.currentMachineID() != "new-id" {
+		t.Fatalf("after the link: pending %q, current %q", a.pendingNewMachineID(), a.currentMachineID())
+	}
+	if state := readIdentity(t, dataDir); state.MachineID != "new-id" || state.Migration != nil {
+		t.Fatalf("persisted identity after the link: %+v", state)
+	}
+	event := waitForEvent(t, a, "identity_migrated")
+	if event.Data["previous_machine_id"] != "old-id" || event.Data["machine_id"] != "new-id" {
+		t.Fatalf("identity_migrated data = %v", event.Data)
+	}
+
+	a.handleRegistration(a.comms().RegisterMachine())
+	if got := backend.lastRequest(); got != [2]string{"new-id", ""} {
+		t.Fatalf("registration after the migration sent %v", got)
+	}
+
+	// Um novo processo usa o ID migrado sem iniciar outra migração
+	restarted := newIdentityTestAgent(t, dataDir, "new-id", urls)
+	if restarted.config.MachineID != "new-id" || restarted.pendingNewMachineID() != "" {
+		t.Fatalf("after restart: %q, pending %q", restarted.config.MachineID, restarted.pendingNewMachineID())
+	}
+}
+
+func TestIdentityMigrationAborts(t *testing.T) {
+	dataDir := t.TempDir()
+	newIdentityTestAgent(t, dataDir, "old-id", nil)
+
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"machine_id":                  "",
+		"data_dir":                    dataDir,
+		"machine_id_migration_window": 3600,
+	})
+	a.config.MachineID = a.resolveIdentity("new-id", true)
+
+	fake.Advance(time.Hour - time.Second)
+	a.checkIdentityMigration()
+	if a.pendingNewMachineID() != "new-id" {
+		t.Fatal("migration aborted before the window")
+	}
+
+	fake.Advance(time.Second)
+	a.checkIdentityMigration()
+	if a.pendingNewMachineID() != "" {
+		t.Fatal("migration still pending after the window")
+	}
+	state := readIdentity(t, dataDir)
+	if state.MachineID != "old-id" || state.Migration == nil || state.Migration.State != IdentityMigrationAborted ||
+		!state.Migration.AbortedAt.Equal(fake.Now()) {
+		t.Fatalf("persisted identity after abort: %+v", state)
+	}
+	event := waitForEvent(t, a, "identity_migration_aborted")
+	if remediation, _ := event.Data["remediation"].(string); !strings.Contains(remediation, identityFile) {
+		t.Fatalf("identity_migration_aborted data = %v", event.Data)
+	}
+
+	// Reinício com o mesmo ID novo não reabre a migração abandonada
+	restarted := newIdentityTestAgent(t, dataDir, "new-id", nil)
+	if restarted.config.MachineID != "old-id" || restarted.pendingNewMachineID() != "" {
+		t.Fatalf("aborted migration reopened: %q, pending %q", restarted.config.MachineID, restarted.pendingNewMachineID())
+	}
+	if n := countEvents(t, restarted, "identity_migration_started"); n != 0 {
+		t.Fatalf("identity_migration_started recorded %d times", n)
+	}
+	if status := restarted.identityStatus(); status.Migration != IdentityMigrationAborted || !status.Deadline.IsZero() {
+		t.Fatalf("identity status after abort: %+v", status)
+	}
+}
+
+func TestIdentityUnreliableIDNeverMigrates(t *testing.T) {
+	dataDir := t.TempDir()
+	newIdentityTestAgent(t, dataDir, "old-id", nil)
+
+	a, _ := newTestAgent(t, map[string]interface{}{"machine_id": "", "data_dir": dataDir})
+	if id := a.resolveIdentity("auto-host", false); id != "old-id" || a.pendingNewMachineID() != "" {
+		t.Fatalf("fallback ID resolved %q, pending %q", id, a.pendingNewMachineID())
+	}
+	if state := readIdentity(t, dataDir); state.MachineID != "old-id" || state.Migration != nil {
+		t.Fatalf("persisted identity: %+v", state)
+	}
+}
+
+func TestIdentityBootstrapsFromPreviousID(t *testing.T) {
+	// Instalação anterior à identidade persistida: o ID da sequência de
+	// inventário é mantido e o gerado entra em migração
+	dataDir := t.TempDir()
+	data, _ := json.Marshal(sequenceState{MachineID: "legacy-id"})
+	if err := os.WriteFile(filepath.Join(dataDir, inventorySequenceFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newIdentityTestAgent(t, dataDir, "new-id", nil)
+	if a.config.MachineID != "legacy-id" || a.pendingNewMachineID() != "new-id" {
+		t.Fatalf("resolved %q, pending %q", a.config.MachineID, a.pendingNewMachineID())
+	}
+}
@@ -0,0 +1,376 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"agente-poc/internal/events"
+)
+
+// Políticas quando outra instância detém o lock da máquina
+const (
+	InstanceLockWait = "wait" // fica em modo observador até o lock ser liberado
+	InstanceLockExit = "exit" // encerra com ErrInstanceLocked
+)
+
+// ErrInstanceLocked indica que outra instância do agente detém o lock desta
+// máquina e a política é encerrar
+var ErrInstanceLocked = errors.New("another agent instance holds the instance lock")
+
+const (
+	// instanceLockPollInterval é o intervalo em que o observador tenta o lock
+	instanceLockPollInterval = 15 * time.Second
+	// instanceLockCheckInterval é o intervalo em que o dono confirma o lock
+	instanceLockCheckInterval = 30 * time.Second
+)
+
+// InstanceLockHolder é o conteúdo do arquivo de lock. ProcessStart (ms desde
+// a época) distingue o dono de outro processo que reutilizou o PID.
+type InstanceLockHolder struct {
+	PID          int32     `json:"pid"`
+	ProcessStart int64     `json:"process_start"`
+	InstanceID   string    `json:"instance_id"`
+	MachineID    string    `json:"machine_id"`
+	AcquiredAt   time.Time `json:"acquired_at"`
+}
+
+// instanceLock é o lock de uma instância por machine_id, para duas versões
+// do agente rodarem lado a lado durante um upgrade sem enviar em duplicidade
+type instanceLock struct {
+	path       string
+	machineID  string
+	instanceID string
+}
+
+// newInstanceLock cria o lock de machineID em dir
+func newInstanceLock(dir, machineID, instanceID string) *instanceLock {
+	sum := sha256.Sum256([]byte(machineID))
+	return &instanceLock{
+		path:       filepath.Join(dir, "instance-"+hex.EncodeToString(sum[:8])+".lock"),
+		machineID:  machineID,
+		instanceID: instanceID,
+	}
+}
+
+// Path retorna o caminho do arquivo de lock
+func (l *instanceLock) Path() string {
+	return l.path
+}
+
+// Acquire tenta obter o lock. Retorna o dono atual se outra instância viva o
+// detém, nil se o lock foi obtido, ou erro se não foi possível verificar.
+// Um lock de processo morto (ou de PID reutilizado) é removido e retomado.
+func (l *instanceLock) Acquire() (*InstanceLockHolder, error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	self, err := currentProcessHolder()
+	if err != nil {
+		return nil, err
+	}
+	self.InstanceID = l.instanceID
+	self.MachineID = l.machineID
+	self.AcquiredAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(self, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode instance lock: %w", err)
+	}
+
+	// Arquivo completo + link: o lock nunca é visto pela metade
+	tmpPath := fmt.Sprintf("%s.%d.tmp", l.path, self.PID)
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write instance lock: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		err := os.Link(tmpPath, l.path)
+		if err == nil {
+			return nil, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create instance lock: %w", err)
+		}
+
+		current, err := os.ReadFile(l.path)
+		if os.IsNotExist(err) {
+			// Liberado entre o link e a leitura: tentar de novo
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read instance lock: %w", err)
+		}
+		holder, err := l.parse(current)
+		if err == nil && holder.InstanceID == l.instanceID {
+			return nil, nil
+		}
+		if err == nil && holderAlive(holder) {
+			return holder, nil
+		}
+
+		// Dono morto ou arquivo ilegível: remover e tentar de novo
+		if err := l.removeStale(current); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("instance lock contended: %s", l.path)
+}
+
+// Verify confirma que o lock ainda pertence a esta instância
+func (l *instanceLock) Verify() (bool, *InstanceLockHolder) {
+	holder, err := l.read()
+	if err != nil {
+		return false, nil
+	}
+	return holder.InstanceID == l.instanceID, holder
+}
+
+// Holder lê o dono atual do lock; nil se não há lock legível
+func (l *instanceLock) Holder() *InstanceLockHolder {
+	holder, err := l.read()
+	if err != nil {
+		return nil
+	}
+	return holder
+}
+
+// Release remove o lock se ele pertence a esta instância
+func (l *instanceLock) Release() error {
+	if owned, _ := l.Verify(); !owned {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release instance lock: %w", err)
+	}
+	return nil
+}
+
+// removeStale remove o lock cujo conteúdo é stale. Outra instância pode ter
+// removido o mesmo lock e criado o seu depois da leitura, então o arquivo é
+// primeiro tirado do lugar com um nome só desta instância e conferido: se
+// não é mais o lido, é o lock novo da outra instância e volta para o lugar.
+func (l *instanceLock) removeStale(stale []byte) error {
+	stalePath := fmt.Sprintf("%s.%s.stale", l.path, l.instanceID)
+	if err := os.Rename(l.path, stalePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove stale instance lock: %w", err)
+	}
+	defer os.Remove(stalePath)
+
+	current, err := os.ReadFile(stalePath)
+	if err != nil {
+		return fmt.Errorf("failed to read stale instance lock: %w", err)
+	}
+	if bytes.Equal(current, stale) {
+		return nil
+	}
+	// Se uma terceira instância criou outro lock nesse intervalo, o dela
+	// fica; o guarda da instância deslocada percebe e volta a observar
+	if err := os.Link(stalePath, l.path); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to restore instance lock: %w", err)
+	}
+	return nil
+}
+
+// read lê e decodifica o arquivo de lock
+func (l *instanceLock) read() (*InstanceLockHolder, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	return l.parse(data)
+}
+
+// parse decodifica o conteúdo do arquivo de lock
+func (l *instanceLock) parse(data []byte) (*InstanceLockHolder, error) {
+	var holder InstanceLockHolder
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return nil, fmt.Errorf("failed to parse instance lock: %w", err)
+	}
+	if holder.PID <= 0 || holder.InstanceID == "" {
+		return nil, fmt.Errorf("invalid instance lock: %s", l.path)
+	}
+	return &holder, nil
+}
+
+// currentProcessHolder descreve o processo atual (PID e início)
+func currentProcessHolder() (*InstanceLockHolder, error) {
+	pid := int32(os.Getpid())
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect current process: %w", err)
+	}
+	start, err := proc.CreateTime()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process start time: %w", err)
+	}
+	return &InstanceLockHolder{PID: pid, ProcessStart: start}, nil
+}
+
+// holderAlive indica se o processo dono do lock ainda existe e é o mesmo
+// (mesmo PID e mesmo horário de início)
+func holderAlive(holder *InstanceLockHolder) bool {
+	proc, err := process.NewProcess(holder.PID)
+	if err != nil {
+		return false
+	}
+	start, err := proc.CreateTime()
+	if err != nil {
+		// Processo existe mas o início não pôde ser lido: assumir vivo
+		return true
+	}
+	return start == holder.ProcessStart
+}
+
+// newInstanceID gera o identificador desta execução do agente, enviado em
+// todos os payloads para o backend distinguir instâncias lado a lado
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// lockMachineID retorna o machine_id do lock sem coletar: o configurado, o
+// persistido ou o anterior à identidade persistida. Vazio em instalação nova.
+func (a *Agent) lockMachineID() string {
+	if a.config.MachineID != "" {
+		return a.config.MachineID
+	}
+	if state, err := loadIdentity(a.config.DataDir); err == nil && state.MachineID != "" {
+		return state.MachineID
+	}
+	return previousMachineID(a.config.DataDir)
+}
+
+// waitForInstanceLock roda no modo observador: tenta o lock periodicamente e,
+// ao obtê-lo, inicia o agente normalmente
+func (a *Agent) waitForInstanceLock() {
+	ticker := a.clock.NewTicker(instanceLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		holder, err := a.instanceLock.Acquire()
+		if err != nil {
+			a.logger.WithField("error", err).Warning("Failed to acquire instance lock")
+			continue
+		}
+		if holder != nil {
+			a.logger.WithFields(map[string]interface{}{
+				"holder_pid":         holder.PID,
+				"holder_instance_id": holder.InstanceID,
+			}).Debug("Instance lock still held by another instance")
+			continue
+		}
+
+		a.mu.Lock()
+		if a.state != StateObserver {
+			a.mu.Unlock()
+			a.instanceLock.Release()
+			return
+		}
+		a.logger.WithField("lock", a.instanceLock.Path()).Info("Instance lock acquired, leaving observer mode")
+		a.setState(StateStarting)
+		err = a.startOwned("", false)
+		a.mu.Unlock()
+
+		if err != nil {
+			a.logger.WithField("error", err).Error("Failed to start after acquiring instance lock")
+		}
+		return
+	}
+}
+
+// runInstanceLockGuard confirma periodicamente que o lock ainda é desta
+// instância; se outra o tomou (ex.: lock apagado manualmente), pede restart
+// para voltar ao modo observador em vez de enviar em duplicidade
+func (a *Agent) runInstanceLockGuard() {
+	defer a.wg.Done()
+
+	if a.instanceLock == nil {
+		return
+	}
+
+	ticker := a.clock.NewTicker(instanceLockCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C():
+		}
+
+		owned, holder := a.instanceLock.Verify()
+		if owned {
+			continue
+		}
+		if holder == nil {
+			// Lock apagado sem outro dono: recriar
+			if other, err := a.instanceLock.Acquire(); err == nil && other == nil {
+				a.logger.WithField("lock", a.instanceLock.Path()).Warning("Instance lock file was removed, recreated")
+				continue
+			} else if other != nil {
+				holder = other
+			}
+		}
+
+		fields := map[string]interface{}{"lock": a.instanceLock.Path()}
+		if holder != nil {
+			fields["holder_pid"] = holder.PID
+			fields["holder_instance_id"] = holder.InstanceID
+		}
+		a.recordEvent(events.CategoryAlert, events.SeverityCritical, "instance_lock_lost",
+			"Instance lock lost to another instance, restarting", fields)
+
+		select {
+		case a.restartChan <- RestartRequest{Reason: "instance_lock_lost", Timestamp: time.Now()}:
+		default:
+		}
+		return
+	}
+}
+
+// InstanceStatus descreve esta instância e o lock, exposto em Health()
+type InstanceStatus struct {
+	InstanceID string              `json:"instance_id"`
+	Role       string              `json:"role"`
+	Lock       string              `json:"lock,omitempty"`
+	Holder     *InstanceLockHolder `json:"holder,omitempty"`
+}
+
+// instanceStatus monta o InstanceStatus; chamado com a.mu travado
+func (a *Agent) instanceStatus() InstanceStatus {
+	status := InstanceStatus{InstanceID: a.instanceID, Role: "owner"}
+	if a.state == StateObserver {
+		status.Role = "observer"
+	}
+	if a.instanceLock != nil {
+		status.Lock = a.instanceLock.Path()
+		status.Holder = a.instanceLock.Holder()
+	} else if a.state != StateStarting {
+		status.Role = "unlocked"
+	}
+	return status
+}
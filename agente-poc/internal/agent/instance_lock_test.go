@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// instanceBackend é um backend falso que conta as requisições por
+// X-Agent-Instance-ID
+type instanceBackend struct {
+	mu       sync.Mutex
+	requests map[string][]string
+}
+
+func (b *instanceBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	if b.requests == nil {
+		b.requests = make(map[string][]string)
+	}
+	instanceID := r.Header.Get("X-Agent-Instance-ID")
+	b.requests[instanceID] = append(b.requests[instanceID], r.URL.Path)
+	b.mu.Unlock()
+
+	if strings.HasSuffix(r.URL.Path, "/machines/register") {
+		_, _ = w.Write([]byte(`{"success":true}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// senders retorna as instâncias que enviaram algo ao backend
+func (b *instanceBackend) senders() map[string][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	senders := make(map[string][]string, len(b.requests))
+	for id, paths := range b.requests {
+		senders[id] = append([]string(nil), paths...)
+	}
+	return senders
+}
+
+// newInstanceTestAgent cria um agente com data_dir próprio e o diretório de
+// lock compartilhado, apontando para o backend falso
+func newInstanceTestAgent(t *testing.T, serverURL, lockDir string, extra map[string]interface{}) (*Agent, *clock.Fake) {
+	t.Helper()
+	config := map[string]interface{}{
+		"backend_url":       serverURL,
+		"websocket_url":     "ws" + strings.TrimPrefix(serverURL, "http") + "/ws",
+		"instance_lock_dir": lockDir,
+		"data_dir":          t.TempDir(),
+	}
+	for key, value := range extra {
+		config[key] = value
+	}
+	a, fake := newTestAgent(t, config)
+	// O Start cria o próprio pipeline de eventos
+	a.events.Close()
+	t.Cleanup(func() { _ = a.Stop() })
+	return a, fake
+}
+
+func TestInstanceLockSingleSender(t *testing.T) {
+	backend := &instanceBackend{}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	lockDir := t.TempDir()
+
+	owner, _ := newInstanceTestAgent(t, server.URL, lockDir, nil)
+	standby, standbyClock := newInstanceTestAgent(t, server.URL, lockDir, nil)
+
+	if err := owner.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := standby.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if state := standby.GetState(); state != StateObserver {
+		t.Fatalf("second instance state = %s, want observer", state)
+	}
+	if standby.comms() != nil {
+		t.Fatal("observer created a communications manager")
+	}
+
+	// Só o dono do lock fala com o backend
+	deadline := time.Now().Add(5 * time.Second)
+	for len(backend.senders()[owner.instanceID]) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	senders := backend.senders()
+	if len(senders[owner.instanceID]) == 0 {
+		t.Fatalf("owner sent nothing; requests by instance: %v", senders)
+	}
+	if len(senders) != 1 {
+		t.Fatalf("%d instances sent to the backend: %v", len(senders), senders)
+	}
+
+	health := standby.Health()["instance"].(InstanceStatus)
+	if health.Role != "observer" || health.Holder == nil || health.Holder.InstanceID != owner.instanceID {
+		t.Fatalf("observer instance status: %+v", health)
+	}
+
+	// Ao parar, o dono libera o lock
+	if err := owner.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(owner.instanceLock.Path()); !os.IsNotExist(err) {
+		t.Fatalf("lock not released on stop: %v", err)
+	}
+
+	// O observador obtém o lock na próxima tentativa e passa a enviar
+	waitPending(t, standbyClock)
+	standbyClock.Advance(instanceLockPollInterval)
+	deadline = time.Now().Add(5 * time.Second)
+	for len(backend.senders()[standby.instanceID]) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := standby.GetState(); state != StateRunning {
+		t.Fatalf("standby state after the owner stopped = %s", state)
+	}
+	if len(backend.senders()[standby.instanceID]) == 0 {
+		t.Fatal("standby sent nothing after taking the lock")
+	}
+}
+
+func TestInstanceLockExitPolicy(t *testing.T) {
+	backend := &instanceBackend{}
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	lockDir := t.TempDir()
+
+	owner, _ := newInstanceTestAgent(t, server.URL, lockDir, nil)
+	if err := owner.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := newInstanceTestAgent(t, server.URL, lockDir, map[string]interface{}{"instance_lock_policy": InstanceLockExit})
+	if err := second.Start(); !errors.Is(err, ErrInstanceLocked) {
+		t.Fatalf("Start() = %v, want ErrInstanceLocked", err)
+	}
+	if _, ok := backend.senders()[second.instanceID]; ok {
+		t.Fatal("instance that exited sent to the backend")
+	}
+}
+
+func TestInstanceLockStale(t *testing.T) {
+	dir := t.TempDir()
+	lock := newInstanceLock(dir, "test-machine", "current")
+	self, err := currentProcessHolder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		holder InstanceLockHolder
+		stale  bool
+	}{
+		// Mesmo processo (mesmo PID e início): outra instância viva
+		{"live holder", InstanceLockHolder{PID: self.PID, ProcessStart: self.ProcessStart, InstanceID: "other"}, false},
+		// PID reutilizado por outro processo: início diferente
+		{"reused PID", InstanceLockHolder{PID: self.PID, ProcessStart: self.ProcessStart - 60000, InstanceID: "other"}, true},
+		{"dead process", InstanceLockHolder{PID: 1 << 30, ProcessStart: 1, InstanceID: "other"}, true},
+	}
+	for _, tt := range tests {
+		data, _ := json.Marshal(tt.holder)
+		if err := os.WriteFile(lock.Path(), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		holder, err := lock.Acquire()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.stale {
+			if holder != nil {
+				t.Errorf("%s: stale lock kept by %+v", tt.name, holder)
+			}
+			if owned, _ := lock.Verify(); !owned {
+				t.Errorf("%s: stale lock not taken over", tt.name)
+			}
+		} else if holder == nil || holder.InstanceID != "other" {
+			t.Errorf("%s: live lock taken over (holder %+v)", tt.name, holder)
+		}
+		_ = os.Remove(lock.Path())
+	}
+
+	// Arquivo ilegível também é retomado
+	if err := os.WriteFile(lock.Path(), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := lock.Acquire(); err != nil || holder != nil {
+		t.Fatalf("unreadable lock: holder %+v, %v", holder, err)
+	}
+
+	// Release só remove o lock desta instância
+	other := newInstanceLock(dir, "test-machine", "other")
+	if err := other.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lock.Path()); err != nil {
+		t.Fatal("lock of another instance released")
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lock.Path()); !os.IsNotExist(err) {
+		t.Fatal("lock not released")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(matches) != 0 {
+		t.Fatalf("temporary lock files left: %v", matches)
+	}
+}
+
+func TestInstanceLockStaleRemovalKeepsNewLock(t *testing.T) {
+	dir := t.TempDir()
+	first := newInstanceLock(dir, "test-machine", "first")
+	second := newInstanceLock(dir, "test-machine", "second")
+
+	// As duas instâncias leram o mesmo lock de um processo morto
+	stale, _ := json.Marshal(InstanceLockHolder{PID: 1 << 30, ProcessStart: 1, InstanceID: "dead"})
+	if err := os.WriteFile(first.Path(), stale, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A primeira remove o stale e cria o seu lock
+	if err := first.removeStale(stale); err != nil {
+		t.Fatal(err)
+	}
+	if holder, err := first.Acquire(); err != nil || holder != nil {
+		t.Fatalf("first Acquire() = %+v, %v", holder, err)
+	}
+
+	// A segunda, atrasada, tenta remover o mesmo stale: o lock novo fica
+	if err := second.removeStale(stale); err != nil {
+		t.Fatal(err)
+	}
+	if owned, holder := first.Verify(); !owned {
+		t.Fatalf("live lock removed as stale; holder %+v", holder)
+	}
+	if holder, err := second.Acquire(); err != nil || holder == nil || holder.InstanceID != "first" {
+		t.Fatalf("second Acquire() = %+v, %v", holder, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.stale")); len(matches) != 0 {
+		t.Fatalf("stale lock files left: %v", matches)
+	}
+}
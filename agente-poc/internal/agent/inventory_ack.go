@@ -0,0 +1,315 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// inventorySequenceFile guarda o estado de sequência entre reinícios
+const inventorySequenceFile = "inventory_sequence.json"
+
+// maxPendingSequences limita os horários de envio mantidos para calcular a idade do atraso
+const maxPendingSequences = 256
+
+// pendingSequence é um inventário enviado e ainda não processado pelo backend
+type pendingSequence struct {
+	Sequence int64     `json:"sequence"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// sequenceState é a forma persistida do InventorySequence
+type sequenceState struct {
+	MachineID     string            `json:"machine_id"`
+	LastAssigned  int64             `json:"last_assigned"`
+	LastSent      int64             `json:"last_sent"`
+	LastSentAt    time.Time         `json:"last_sent_at,omitempty"`
+	LastProcessed int64             `json:"last_processed"`
+	ProcessedAt   time.Time         `json:"processed_at,omitempty"`
+	AckSupported  bool              `json:"ack_supported"`
+	Pending       []pendingSequence `json:"pending,omitempty"`
+	AckIssue      string            `json:"ack_issue,omitempty"`
+	AckIssueAt    time.Time         `json:"ack_issue_at,omitempty"`
+}
+
+// BackendLag descreve o atraso entre inventários enviados e processados
+type BackendLag struct {
+	Lagging           bool      `json:"lagging"`
+	Reason            string    `json:"reason,omitempty"`
+	SentSequence      int64     `json:"sent_sequence"`
+	ProcessedSequence int64     `json:"processed_sequence"`
+	Behind            int64     `json:"behind"`
+	OldestPendingAt   time.Time `json:"oldest_pending_at,omitempty"`
+	AckSupported      bool      `json:"ack_supported"`
+	// AckIssue é a última confirmação incoerente do backend (AckRegressed ou
+	// AckAhead), mantida até a próxima confirmação normal
+	AckIssue   string    `json:"ack_issue,omitempty"`
+	AckIssueAt time.Time `json:"ack_issue_at,omitempty"`
+}
+
+// Confirmações incoerentes com as sequências enviadas
+const (
+	// AckRegressed: a sequência processada voltou, o backend perdeu inventários
+	AckRegressed = "regressed"
+	// AckAhead: o backend confirmou uma sequência que o agente nunca enviou
+	AckAhead = "ahead"
+)
+
+// AckResult descreve uma confirmação recebida do backend
+type AckResult struct {
+	Previous  int64
+	Processed int64
+	Sent      int64
+	// Issue é AckRegressed, AckAhead ou vazio
+	Issue string
+}
+
+// InventorySequence numera os inventários de uma máquina de forma monotônica
+// e acompanha a maior sequência que o backend confirmou como processada
+type InventorySequence struct {
+	path  string
+	state sequenceState
+	mu    sync.Mutex
+}
+
+// NewInventorySequence carrega (ou cria) o estado de sequência em dataDir.
+// Um estado gravado para outro machine_id é descartado.
+func NewInventorySequence(dataDir, machineID string) (*InventorySequence, error) {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	seq := &InventorySequence{path: filepath.Join(dataDir, inventorySequenceFile)}
+
+	data, err := os.ReadFile(seq.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &seq.state); err != nil {
+			return nil, fmt.Errorf("failed to parse inventory sequence state: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to read inventory sequence state: %w", err)
+	}
+
+	if seq.state.MachineID != machineID {
+		seq.state = sequenceState{MachineID: machineID}
+		if err := seq.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return seq, nil
+}
+
+// Reset descarta o estado e recomeça a numeração para outro machine_id
+func (s *InventorySequence) Reset(machineID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = sequenceState{MachineID: machineID}
+	return s.save()
+}
+
+// Next reserva a próxima sequência. É persistida antes do envio para que um
+// reinício nunca reutilize um número já visto pelo backend.
+func (s *InventorySequence) Next() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.LastAssigned++
+	return s.state.LastAssigned, s.save()
+}
+
+// MarkSent registra que o inventário com a sequência foi aceito pelo backend
+func (s *InventorySequence) MarkSent(sequence int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sequence <= s.state.LastSent {
+		return nil
+	}
+
+	s.state.LastSent = sequence
+	s.state.LastSentAt = now
+	if sequence > s.state.LastProcessed {
+		s.state.Pending = append(s.state.Pending, pendingSequence{Sequence: sequence, SentAt: now})
+		if len(s.state.Pending) > maxPendingSequences {
+			s.state.Pending = s.state.Pending[len(s.state.Pending)-maxPendingSequences:]
+		}
+	}
+	return s.save()
+}
+
+// Ack registra a maior sequência processada informada pelo backend.
+// Um valor menor que o anterior é aceito, mas sai como AckRegressed: indica
+// que o backend perdeu dados. Um valor acima da última enviada sai como AckAhead.
+func (s *InventorySequence) Ack(processed int64, now time.Time) (AckResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := AckResult{Previous: s.state.LastProcessed, Processed: processed, Sent: s.state.LastSent}
+	if s.state.AckSupported && processed == s.state.LastProcessed {
+		return result, nil
+	}
+
+	switch {
+	case s.state.AckSupported && processed < s.state.LastProcessed:
+		result.Issue = AckRegressed
+	case processed > s.state.LastSent:
+		result.Issue = AckAhead
+	}
+	s.state.AckIssue = result.Issue
+	s.state.AckIssueAt = time.Time{}
+	if result.Issue != "" {
+		s.state.AckIssueAt = now
+	}
+
+	s.state.AckSupported = true
+	s.state.LastProcessed = processed
+	s.state.ProcessedAt = now
+
+	pending := s.state.Pending[:0]
+	for _, item := range s.state.Pending {
+		if item.Sequence > processed {
+			pending = append(pending, item)
+		}
+	}
+	s.state.Pending = pending
+
+	return result, s.save()
+}
+
+// Lag compara as sequências enviada e processada com os limites informados
+func (s *InventorySequence) Lag(now time.Time, maxSequences int64, maxAge time.Duration) BackendLag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lag := BackendLag{
+		SentSequence:      s.state.LastSent,
+		ProcessedSequence: s.state.LastProcessed,
+		AckSupported:      s.state.AckSupported,
+		AckIssue:          s.state.AckIssue,
+		AckIssueAt:        s.state.AckIssueAt,
+	}
+	if s.state.LastSent > s.state.LastProcessed {
+		lag.Behind = s.state.LastSent - s.state.LastProcessed
+	}
+	if len(s.state.Pending) > 0 {
+		lag.OldestPendingAt = s.state.Pending[0].SentAt
+	}
+
+	// Backends que nunca ecoaram a sequência não são considerados atrasados
+	if !s.state.AckSupported || lag.Behind == 0 {
+		return lag
+	}
+
+	switch {
+	case maxSequences > 0 && lag.Behind > maxSequences:
+		lag.Lagging = true
+		lag.Reason = fmt.Sprintf("backend is %d inventories behind", lag.Behind)
+	case maxAge > 0 && !lag.OldestPendingAt.IsZero() && now.Sub(lag.OldestPendingAt) > maxAge:
+		lag.Lagging = true
+		lag.Reason = fmt.Sprintf("oldest unprocessed inventory sent %s ago", now.Sub(lag.OldestPendingAt).Round(time.Second))
+	}
+
+	return lag
+}
+
+// save persiste o estado; deve ser chamado com o lock adquirido
+func (s *InventorySequence) save() error {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory sequence state: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write inventory sequence state: %w", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// handleHeartbeatResponse registra a sequência processada ecoada pelo backend.
+// Sem a sequência na resposta, o atraso ainda é reavaliado: um backend que
+// parou de confirmar dispara backend_lag pela idade do inventário pendente.
+func (a *Agent) handleHeartbeatResponse(response *comms.HeartbeatResponse) {
+	a.recordHeartbeat(true)
+	if a.inventorySeq == nil {
+		return
+	}
+	if response.ProcessedInventorySequence == nil {
+		a.checkBackendLag()
+		return
+	}
+
+	result, err := a.inventorySeq.Ack(*response.ProcessedInventorySequence, a.clock.Now())
+	if err != nil {
+		a.recordSequencePersistFailure(err)
+	}
+
+	fields := map[string]interface{}{
+		"previous_processed": result.Previous,
+		"processed_sequence": result.Processed,
+		"sent_sequence":      result.Sent,
+	}
+	switch result.Issue {
+	case AckRegressed:
+		a.recordEvent(events.CategoryAlert, events.SeverityWarning, "backend_ack_regressed",
+			"Backend processed sequence went backwards: processed inventories were lost", fields)
+	case AckAhead:
+		a.recordEvent(events.CategoryAlert, events.SeverityWarning, "backend_ack_mismatch",
+			"Backend acknowledged an inventory sequence that was never sent", fields)
+	}
+	a.checkBackendLag()
+}
+
+// recordSequencePersistFailure registra a falha ao gravar o estado de
+// sequência: após um reinício, as confirmações seriam comparadas com um
+// estado antigo
+func (a *Agent) recordSequencePersistFailure(err error) {
+	a.recordEvent(events.CategoryAgent, events.SeverityError, "inventory_sequence_persist_failed",
+		"Failed to persist inventory sequence state", map[string]interface{}{"error": err})
+}
+
+// checkBackendLag avalia o atraso do backend e registra o evento quando o
+// estado muda
+func (a *Agent) checkBackendLag() {
+	if a.inventorySeq == nil {
+		return
+	}
+
+	lag := a.inventorySeq.Lag(a.clock.Now(), int64(a.config.BackendLagMaxSequences), a.config.BackendLagMaxAge)
+
+	a.lagMu.Lock()
+	wasLagging := a.backendLag.Lagging
+	a.backendLag = lag
+	a.lagMu.Unlock()
+
+	fields := map[string]interface{}{
+		"sent_sequence":      lag.SentSequence,
+		"processed_sequence": lag.ProcessedSequence,
+		"behind":             lag.Behind,
+	}
+
+	switch {
+	case lag.Lagging && !wasLagging:
+		fields["reason"] = lag.Reason
+		a.recordEvent(events.CategoryAlert, events.SeverityWarning, "backend_lag_detected",
+			"Backend lag detected: inventories sent but not processed", fields)
+	case !lag.Lagging && wasLagging:
+		a.recordEvent(events.CategoryAlert, events.SeverityInfo, "backend_lag_cleared", "Backend lag cleared", fields)
+	}
+}
+
+// backendLagStatus retorna o último estado de atraso avaliado
+func (a *Agent) backendLagStatus() BackendLag {
+	a.lagMu.Lock()
+	defer a.lagMu.Unlock()
+	return a.backendLag
+}
@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// stallingBackend simula um backend que aceita inventários mas processa
+// apenas até a sequência informada em processed
+type stallingBackend struct {
+	t         *testing.T
+	agent     *Agent
+	processed int64
+	echo      bool
+}
+
+func newStallingBackend(t *testing.T, a *Agent) *stallingBackend {
+	t.Helper()
+	seq, err := NewInventorySequence(a.config.DataDir, a.config.MachineID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.inventorySeq = seq
+	return &stallingBackend{t: t, agent: a, echo: true}
+}
+
+// sendInventory reproduz o envio de um inventário aceito com 200
+func (b *stallingBackend) sendInventory() int64 {
+	b.t.Helper()
+	sequence, err := b.agent.inventorySeq.Next()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	if err := b.agent.inventorySeq.MarkSent(sequence, b.agent.clock.Now()); err != nil {
+		b.t.Fatal(err)
+	}
+	b.agent.checkBackendLag()
+	return sequence
+}
+
+// heartbeat entrega a resposta do heartbeat, com ou sem a sequência processada
+func (b *stallingBackend) heartbeat() {
+	response := &comms.HeartbeatResponse{}
+	if b.echo {
+		processed := b.processed
+		response.ProcessedInventorySequence = &processed
+	}
+	b.agent.handleHeartbeatResponse(response)
+}
+
+func TestBackendLagStallAndClearance(t *testing.T) {
+	a, _ := newTestAgent(t, map[string]interface{}{
+		"backend_lag_max_sequences": 2,
+		"backend_lag_max_age":       3600,
+	})
+	backend := newStallingBackend(t, a)
+
+	backend.processed = backend.sendInventory()
+	backend.heartbeat()
+	if lag := a.backendLagStatus(); lag.Lagging || !lag.AckSupported {
+		t.Fatalf("healthy backend reported as %+v", lag)
+	}
+
+	// O pipeline do backend para: os inventários seguem aceitos
+	for i := 0; i < 3; i++ {
+		backend.sendInventory()
+		backend.heartbeat()
+	}
+	lag := a.backendLagStatus()
+	if !lag.Lagging || lag.Behind != 3 || lag.Reason == "" {
+		t.Fatalf("stalled backend reported as %+v", lag)
+	}
+	detected := waitForEvent(t, a, "backend_lag_detected")
+	if detected.Data["behind"] != int64(3) {
+		t.Fatalf("backend_lag_detected data = %v", detected.Data)
+	}
+
+	// Continuar atrasado não repete o evento
+	backend.sendInventory()
+	backend.heartbeat()
+	if n := countEvents(t, a, "backend_lag_detected"); n != 1 {
+		t.Fatalf("backend_lag_detected recorded %d times", n)
+	}
+
+	// O backend alcança as sequências enviadas
+	backend.processed = 5
+	backend.heartbeat()
+	waitForEvent(t, a, "backend_lag_cleared")
+	if lag := a.backendLagStatus(); lag.Lagging || lag.Behind != 0 {
+		t.Fatalf("lag after catching up = %+v", lag)
+	}
+}
+
+func TestBackendLagAckTimeout(t *testing.T) {
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"backend_lag_max_sequences": 100,
+		"backend_lag_max_age":       600,
+	})
+	backend := newStallingBackend(t, a)
+
+	backend.processed = backend.sendInventory()
+	backend.heartbeat()
+	backend.sendInventory()
+
+	// O backend para de ecoar a sequência; o atraso vale pela idade
+	backend.echo = false
+	fake.Advance(5 * time.Minute)
+	backend.heartbeat()
+	if a.backendLagStatus().Lagging {
+		t.Fatal("lag reported before backend_lag_max_age")
+	}
+
+	fake.Advance(6 * time.Minute)
+	backend.heartbeat()
+	if lag := a.backendLagStatus(); !lag.Lagging {
+		t.Fatalf("unacknowledged inventory older than max age: %+v", lag)
+	}
+	waitForEvent(t, a, "backend_lag_detected")
+}
+
+func TestBackendAckRegressed(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	backend := newStallingBackend(t, a)
+
+	for i := 0; i < 3; i++ {
+		backend.sendInventory()
+	}
+	backend.processed = 3
+	backend.heartbeat()
+
+	// O backend perdeu inventários já processados
+	backend.processed = 1
+	backend.heartbeat()
+	event := waitForEvent(t, a, "backend_ack_regressed")
+	if event.Data["previous_processed"] != int64(3) || event.Data["processed_sequence"] != int64(1) {
+		t.Fatalf("backend_ack_regressed data = %v", event.Data)
+	}
+	if lag := a.backendLagStatus(); lag.AckIssue != AckRegressed || lag.AckIssueAt.IsZero() {
+		t.Fatalf("health lag = %+v", lag)
+	}
+
+	// Uma confirmação normal limpa o problema no health
+	backend.processed = 3
+	backend.heartbeat()
+	if lag := a.backendLagStatus(); lag.AckIssue != "" {
+		t.Fatalf("ack issue kept after a normal ack: %+v", lag)
+	}
+}
+
+func TestBackendAckAhead(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	backend := newStallingBackend(t, a)
+
+	backend.sendInventory()
+	backend.processed = 10
+	backend.heartbeat()
+
+	event := waitForEvent(t, a, "backend_ack_mismatch")
+	if event.Data["sent_sequence"] != int64(1) || event.Data["processed_sequence"] != int64(10) {
+		t.Fatalf("backend_ack_mismatch data = %v", event.Data)
+	}
+	if a.backendLagStatus().AckIssue != AckAhead {
+		t.Fatalf("health lag = %+v", a.backendLagStatus())
+	}
+}
+
+func TestInventorySequencePersistence(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	seq, err := NewInventorySequence(dir, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		next, err := seq.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := seq.MarkSent(next, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := seq.Ack(1, now); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewInventorySequence(dir, "machine-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := reopened.Next(); next != 4 {
+		t.Fatalf("sequence after restart = %d, want 4", next)
+	}
+	lag := reopened.Lag(now, 1, 0)
+	if lag.SentSequence != 3 || lag.ProcessedSequence != 1 || !lag.Lagging || !lag.OldestPendingAt.Equal(now) {
+		t.Fatalf("lag after restart = %+v", lag)
+	}
+
+	other, err := NewInventorySequence(dir, "machine-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := other.Next(); next != 1 {
+		t.Fatalf("sequence for another machine_id = %d, want 1", next)
+	}
+}
+
+func TestInventorySequencePersistFailure(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	backend := newStallingBackend(t, a)
+	backend.sendInventory()
+
+	// Um diretório no lugar do arquivo temporário faz a gravação falhar
+	if err := os.MkdirAll(filepath.Join(a.config.DataDir, inventorySequenceFile+".tmp", "busy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	backend.processed = 1
+	backend.heartbeat()
+	waitForEvent(t, a, "inventory_sequence_persist_failed")
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"agente-poc/internal/collector"
+	"agente-poc/internal/events"
+)
+
+// diffInventory compara o inventário com o anterior e anexa as mudanças em
+// data.Changes, registrando-as no log e no histórico de eventos. Logo após
+// iniciar, a base é o snapshot mais recente do ring, quando houver.
+func (a *Agent) diffInventory(data *collector.InventoryData) {
+	previous := a.lastInventory
+	if previous == nil && a.snapshots != nil {
+		latest, err := a.snapshots.Latest()
+		if err != nil {
+			a.logger.WithField("error", err).Warning("Failed to load the latest snapshot for the inventory diff")
+		}
+		previous = latest
+	}
+
+	// Cópia rasa: o planner troca seções inteiras de data, não o conteúdo
+	current := *data
+	a.lastInventory = &current
+
+	// Sem base, ou com outro machine_id, não há com o que comparar
+	if previous == nil || previous.MachineID != data.MachineID {
+		return
+	}
+
+	diff := collector.DiffInventories(previous, data)
+	if diff.Empty() {
+		return
+	}
+	data.Changes = diff
+
+	summary := inventoryDiffSummary(diff)
+	a.logger.WithFields(summary).Info("Inventory changed since the previous collection")
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "inventory_changed", "Inventory changed", summary)
+}
+
+// inventoryDiffSummary resume o diff em contagens por coleção, para o log e
+// o evento (o diff completo segue no inventário)
+func inventoryDiffSummary(diff *collector.InventoryDiff) map[string]interface{} {
+	summary := map[string]interface{}{"changes": diff.Count()}
+	if diff.OSVersion != nil {
+		summary["os_version"] = diff.OSVersion.New
+	}
+	for name, changes := range map[string]*collector.ItemChanges{
+		"applications":       diff.Applications,
+		"services":           diff.Services,
+		"network_interfaces": diff.NetworkInterfaces,
+		"disks":              diff.Disks,
+	} {
+		if changes == nil {
+			continue
+		}
+		summary[name] = map[string]int{
+			"added":   len(changes.Added),
+			"removed": len(changes.Removed),
+			"changed": len(changes.Changed),
+		}
+	}
+	return summary
+}
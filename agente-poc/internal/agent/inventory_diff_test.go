@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"testing"
+
+	"agente-poc/internal/collector"
+)
+
+// diffTestInventory monta um inventário com os aplicativos informados
+func diffTestInventory(machineID string, apps ...string) *collector.InventoryData {
+	data := &collector.InventoryData{MachineID: machineID}
+	data.System.OSVersion = "14.4"
+	for _, name := range apps {
+		data.Software.InstalledApplications = append(data.Software.InstalledApplications, collector.Application{Name: name, Version: "1.0"})
+	}
+	return data
+}
+
+func TestDiffInventoryAttachesChanges(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+
+	// Sem base não há diff
+	first := diffTestInventory("machine-1", "Slack")
+	a.diffInventory(first)
+	if first.Changes != nil {
+		t.Fatalf("first inventory has changes: %+v", first.Changes)
+	}
+
+	// Nada mudou: o inventário segue sem Changes
+	same := diffTestInventory("machine-1", "Slack")
+	a.diffInventory(same)
+	if same.Changes != nil {
+		t.Fatalf("unchanged inventory has changes: %+v", same.Changes)
+	}
+
+	second := diffTestInventory("machine-1", "Slack", "Docker")
+	second.System.OSVersion = "14.5"
+	a.diffInventory(second)
+	if second.Changes == nil || second.Changes.Count() != 2 || second.Changes.Applications.Added[0] != "Docker" {
+		t.Fatalf("changes = %+v", second.Changes)
+	}
+	event := waitForEvent(t, a, "inventory_changed")
+	if event.Data["changes"] != 2 || event.Data["os_version"] != "14.5" {
+		t.Fatalf("inventory_changed data = %v", event.Data)
+	}
+	if apps, ok := event.Data["applications"].(map[string]int); !ok || apps["added"] != 1 || apps["removed"] != 0 {
+		t.Fatalf("applications summary = %v", event.Data["applications"])
+	}
+
+	// Outro machine_id (máquina reinstalada) recomeça a base
+	other := diffTestInventory("machine-2")
+	a.diffInventory(other)
+	if other.Changes != nil {
+		t.Fatalf("inventory of another machine has changes: %+v", other.Changes)
+	}
+}
@@ -0,0 +1,37 @@
+package agent
+
+import "agente-poc/internal/collector"
+
+// planInventory passa o inventário pelo planner com as restrições vigentes;
+// a decisão fica registrada em data.Plan
+func (a *Agent) planInventory(data *collector.InventoryData) {
+	if a.planner == nil {
+		return
+	}
+
+	plan := a.planner.Apply(data, a.inventoryConstraints())
+	if len(plan.Dropped) == 0 {
+		return
+	}
+
+	dropped := make([]string, 0, len(plan.Dropped))
+	for _, drop := range plan.Dropped {
+		dropped = append(dropped, drop.Section+" ("+drop.Constraint+")")
+	}
+	a.logger.WithFields(map[string]interface{}{
+		"dropped":    dropped,
+		"bytes":      plan.Bytes,
+		"over_limit": plan.OverLimit,
+	}).Warning("Inventory sections dropped by planner")
+}
+
+// inventoryConstraints reúne as restrições para o próximo inventário. Novos
+// mecanismos de corte (orçamento de banda, intervalo adaptativo) entram aqui
+// em vez de remover seções por conta própria.
+func (a *Agent) inventoryConstraints() collector.PlanConstraints {
+	var constraints collector.PlanConstraints
+	if a.config.InventoryPlan != nil {
+		constraints.MaxBytes = a.config.InventoryPlan.MaxBytes
+	}
+	return constraints
+}
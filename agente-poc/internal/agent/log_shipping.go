@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
+)
+
+// LogShippingConfig é o bloco "log_shipping" da configuração: envio das
+// linhas de log a partir de level ao backend (POST /logs), em lotes de até
+// batch_size a cada flush_interval. Sem conexão, os lotes vão para a fila
+// offline; com o buffer de buffer_size linhas cheio, as novas são descartadas.
+type LogShippingConfig struct {
+	Enabled       bool             `json:"enabled"`
+	Level         string           `json:"level,omitempty"`
+	BatchSize     int              `json:"batch_size,omitempty"`
+	FlushInterval timeutil.Seconds `json:"flush_interval,omitempty"`
+	BufferSize    int              `json:"buffer_size,omitempty"`
+}
+
+// Validate retorna os erros do bloco
+func (c *LogShippingConfig) Validate() []string {
+	var errors []string
+	switch strings.ToLower(c.Level) {
+	case "", "debug", "info", "warning", "warn", "error", "fatal":
+	default:
+		errors = append(errors, "log_shipping.level deve ser debug, info, warning, error ou fatal")
+	}
+	if c.BatchSize < 0 || c.BufferSize < 0 || c.FlushInterval < 0 {
+		errors = append(errors, "log_shipping.batch_size, buffer_size e flush_interval não podem ser negativos")
+	}
+	return errors
+}
+
+// minLevel é o nível mínimo enviado (padrão warning)
+func (c *LogShippingConfig) minLevel() logging.LogLevel {
+	if c.Level == "" {
+		return logging.WARNING
+	}
+	return logging.ParseLogLevel(c.Level)
+}
+
+// startLogShipping liga o envio de logs ao backend, se configurado. O
+// shipper é ligado ao logger do agente, e portanto a todos os loggers
+// derivados dele; os lotes seguem pelo communications manager vigente.
+func (a *Agent) startLogShipping() {
+	cfg := a.config.LogShipping
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	shipper := comms.NewLogShipper(comms.LogShipperConfig{
+		MinLevel:      cfg.minLevel(),
+		BatchSize:     cfg.BatchSize,
+		FlushInterval: cfg.FlushInterval.Duration(),
+		BufferSize:    cfg.BufferSize,
+		Send:          a.sendLogsToBackend,
+		Clock:         a.clock,
+	})
+	detach, err := logging.AttachSink(a.logger, shipper)
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Log shipping disabled")
+		return
+	}
+	shipper.Start()
+
+	a.logShipper = shipper
+	a.detachLogShipper = detach
+	a.logger.WithField("level", cfg.minLevel().String()).Info("Shipping log entries to backend")
+}
+
+// stopLogShipping desliga o shipper do logger e envia o que restou no buffer;
+// chamado antes de o communications manager parar
+func (a *Agent) stopLogShipping() {
+	if a.logShipper == nil {
+		return
+	}
+	a.detachLogShipper()
+	a.logShipper.Close()
+}
+
+// sendLogsToBackend entrega um lote de logs ao backend
+func (a *Agent) sendLogsToBackend(batch *comms.LogBatch) error {
+	if a.comms() == nil {
+		return fmt.Errorf("communications not initialized")
+	}
+	return a.comms().SendLogs(batch)
+}
+
+// logShippingStatus retorna os contadores do envio de logs (nil desligado)
+func (a *Agent) logShippingStatus() *comms.LogShipperStats {
+	if a.logShipper == nil {
+		return nil
+	}
+	stats := a.logShipper.Stats()
+	return &stats
+}
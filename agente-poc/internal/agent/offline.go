@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+const (
+	modeOnline  = "online"
+	modeOffline = "offline"
+)
+
+// offlineOmittedHealthKeys são os campos do health que descrevem a conexão
+// com o backend e não existem no modo offline
+var offlineOmittedHealthKeys = []string{
+	"backend_url",
+	"endpoints",
+	"connected",
+	"heartbeat_count",
+	"heartbeat_interval",
+	"last_heartbeat",
+	"backend_lag",
+	"registration",
+	"tokens",
+	"machine_credential",
+	"compression",
+	"envelope",
+	"log_shipping",
+}
+
+// mode retorna o modo de operação exposto no health
+func (a *Agent) mode() string {
+	if a.config.Offline {
+		return modeOffline
+	}
+	return modeOnline
+}
+
+// archiveInventory grava no arquivo local o inventário que no modo online
+// seria enviado ao backend
+func (a *Agent) archiveInventory(data *collector.InventoryData) {
+	path, err := a.archive.Write(data)
+	if err != nil {
+		a.logger.WithField("error", err).Error("Failed to archive inventory data")
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "inventory_failed", "Inventory archival failed", map[string]interface{}{
+			"stage": "archive",
+			"error": err,
+		})
+		a.errorChan <- err
+		return
+	}
+
+	a.metrics.mu.Lock()
+	a.metrics.InventoryCount++
+	a.metrics.LastInventory = a.clock.Now()
+	a.metrics.mu.Unlock()
+
+	a.logger.WithField("path", path).Debug("Inventory archived")
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "inventory_archived", "Inventory archived", map[string]interface{}{
+		"path": path,
+	})
+}
+
+// archiveStatus descreve o arquivo de inventários do modo offline
+func (a *Agent) archiveStatus() interface{} {
+	if a.archive == nil {
+		return nil
+	}
+	return a.archive.Status()
+}
+
+// rejectOfflineCommand recusa um comando que só faz sentido com backend
+// (ex.: agendado no arquivo de configuração do modo offline)
+func (a *Agent) rejectOfflineCommand(command *comms.Command) {
+	a.logger.WithFields(map[string]interface{}{
+		"command_id":   command.ID,
+		"command_type": command.Type,
+	}).Warning("Command rejected: agent is in offline mode")
+
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusRejected,
+		ExitCode:  -1,
+		Timestamp: a.clock.Now(),
+	}
+	result.SetError(comms.NewCodedError(comms.ErrCodeOfflineMode, command.Type))
+	a.executor.RecordAudit(command, result)
+	a.sendCommandResult(result)
+}
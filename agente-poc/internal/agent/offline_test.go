@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// listeningTCPSockets retorna os endereços locais dos sockets TCP em LISTEN
+// abertos por este processo (só Linux, via /proc)
+func listeningTCPSockets(t *testing.T) map[string]bool {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("listening sockets are read from /proc")
+	}
+
+	inodes := make(map[string]bool)
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot list file descriptors: %v", err)
+	}
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && strings.HasPrefix(target, "socket:[") {
+			inodes[strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")] = true
+		}
+	}
+
+	listening := make(map[string]bool)
+	for _, table := range []string{"/proc/self/net/tcp", "/proc/self/net/tcp6"} {
+		file, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// sl local_address rem_address st ... inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 9 && fields[3] == "0A" && inodes[fields[9]] {
+				listening[fields[1]] = true
+			}
+		}
+		file.Close()
+	}
+	return listening
+}
+
+func TestOfflineModeRunsWithoutBackend(t *testing.T) {
+	// Backend configurado mas ignorado: nenhuma requisição deve chegar
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+
+	before := listeningTCPSockets(t)
+	archiveDir := filepath.Join(t.TempDir(), "archive")
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"offline":              true,
+		"backend_url":          server.URL,
+		"websocket_url":        "",
+		"token":                "",
+		"heartbeat_interval":   0,
+		"offline_archive_dir":  archiveDir,
+		"offline_archive_size": 2,
+		"instance_lock_dir":    t.TempDir(),
+	})
+	// O Start cria o próprio pipeline de eventos
+	a.events.Close()
+	t.Cleanup(func() { _ = a.Stop() })
+
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if a.GetState() != StateRunning || a.comms() != nil {
+		t.Fatalf("state %s, comms manager %v", a.GetState(), a.comms())
+	}
+
+	// O socket de controle é Unix; nenhum socket TCP novo fica escutando
+	for address := range listeningTCPSockets(t) {
+		if !before[address] {
+			t.Errorf("offline agent listens on %s", address)
+		}
+	}
+
+	// Cada coleta vira um arquivo; só os offline_archive_size mais novos ficam
+	for i := 0; i < 3; i++ {
+		waitPending(t, fake)
+		fake.Advance(a.config.CollectionInterval)
+		waitForArchivedInventories(t, a, i+1)
+	}
+	files, err := filepath.Glob(filepath.Join(archiveDir, archiveFilePrefix+"*"+archiveFileSuffix))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("archive files = %v, %v", files, err)
+	}
+
+	health := a.Health()
+	if health["mode"] != modeOffline {
+		t.Fatalf("health mode = %v", health["mode"])
+	}
+	for _, key := range offlineOmittedHealthKeys {
+		if _, ok := health[key]; ok {
+			t.Errorf("offline health has %s", key)
+		}
+	}
+	if status, ok := health["offline_archive"].(ArchiveStatus); !ok || status.Files != 2 || status.Dir != archiveDir {
+		t.Fatalf("offline_archive = %+v", health["offline_archive"])
+	}
+
+	// Comandos que dependem do backend são recusados
+	a.handleCommand(&comms.Command{ID: "cmd-1", Type: "request_snapshot"})
+	event := waitForEvent(t, a, "command_executed")
+	if event.Data["status"] != string(comms.StatusRejected) || event.Data["error_code"] != string(comms.ErrCodeOfflineMode) {
+		t.Fatalf("command_executed data = %v", event.Data)
+	}
+
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("offline agent made %d backend requests", n)
+	}
+}
+
+// waitForArchivedInventories aguarda n eventos inventory_archived; a coleta
+// completa pode levar alguns segundos
+func waitForArchivedInventories(t *testing.T, a *Agent, n int) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		archived := 0
+		for _, event := range a.recentEvents.Since(time.Time{}, 0) {
+			if event.Type == "inventory_archived" {
+				archived++
+			}
+		}
+		if archived >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d inventories archived, want %d (events %v)", archived, n, recordedEventTypes(a))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
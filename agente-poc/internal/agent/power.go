@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"agente-poc/internal/events"
+)
+
+// Parâmetros da fonte de reserva: um salto de relógio acima do limite indica suspensão
+const (
+	powerPollInterval   = 10 * time.Second
+	powerSleepThreshold = 30 * time.Second
+)
+
+// PowerEventType identifica uma transição de energia
+type PowerEventType string
+
+const (
+	PowerEventSleep PowerEventType = "sleep"
+	PowerEventWake  PowerEventType = "wake"
+)
+
+// PowerEvent representa uma transição de energia da máquina
+type PowerEvent struct {
+	Type      PowerEventType `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	// SleptFor é preenchido nos eventos de wake
+	SleptFor time.Duration `json:"slept_for,omitempty"`
+}
+
+// PowerEventSource produz transições de energia. O padrão é a fonte da
+// plataforma (log do pmset, logind, suspend/resume do Windows, ver
+// power_sources.go) com o detector por salto de relógio como reserva; outras
+// fontes podem ser injetadas com SetPowerEventSource.
+type PowerEventSource interface {
+	Events(ctx context.Context) <-chan PowerEvent
+}
+
+// PowerEventPoller é implementado por fontes que conseguem verificar
+// transições sob demanda, evitando que o primeiro heartbeat após o wake
+// saia antes do evento ser notado pelo loop da fonte
+type PowerEventPoller interface {
+	Poll() []PowerEvent
+}
+
+// clockGapSource detecta suspensão comparando o relógio de parede com o
+// monotônico: no macOS e no Linux o relógio monotônico do Go não avança
+// durante a suspensão. Um ticker não impede a máquina de dormir.
+type clockGapSource struct {
+	interval  time.Duration
+	threshold time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewClockGapSource cria o detector por salto de relógio, usado quando a
+// plataforma não oferece notificações
+func NewClockGapSource(interval, threshold time.Duration) PowerEventSource {
+	return &clockGapSource{interval: interval, threshold: threshold, last: time.Now()}
+}
+
+// Events emite um par sleep/wake quando o relógio de parede salta à frente do monotônico
+func (s *clockGapSource) Events(ctx context.Context) <-chan PowerEvent {
+	events := make(chan PowerEvent, 4)
+
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, event := range s.Poll() {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// Poll compara os relógios desde a última verificação
+func (s *clockGapSource) Poll() []PowerEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Round(0) remove a leitura monotônica, forçando a diferença pelo relógio de parede
+	wallElapsed := now.Round(0).Sub(s.last.Round(0))
+	monoElapsed := now.Sub(s.last)
+	gap := wallElapsed - monoElapsed
+	sleptAt := now.Round(0).Add(-gap)
+	s.last = now
+
+	if gap <= s.threshold {
+		return nil
+	}
+
+	return []PowerEvent{
+		{Type: PowerEventSleep, Timestamp: sleptAt},
+		{Type: PowerEventWake, Timestamp: now.Round(0), SleptFor: gap},
+	}
+}
+
+// sleepPeriod é um intervalo em que a máquina esteve suspensa
+type sleepPeriod struct {
+	start time.Time
+	end   time.Time
+}
+
+// PowerTracker acompanha ciclos de sleep/wake para distinguir lacunas de
+// inventário causadas por suspensão de falhas reais
+type PowerTracker struct {
+	source     PowerEventSource
+	maxHistory int
+
+	mu          sync.RWMutex
+	onEvent     func(PowerEvent)
+	events      []PowerEvent
+	periods     []sleepPeriod
+	sleepingAt  time.Time
+	pendingWake *PowerEvent
+}
+
+// NewPowerTracker cria um tracker com a fonte de eventos informada
+func NewPowerTracker(source PowerEventSource) *PowerTracker {
+	return &PowerTracker{
+		source:     source,
+		maxHistory: 50,
+	}
+}
+
+// Run consome eventos até o contexto ser cancelado
+func (t *PowerTracker) Run(ctx context.Context, onEvent func(PowerEvent)) {
+	t.mu.Lock()
+	t.onEvent = onEvent
+	t.mu.Unlock()
+
+	for event := range t.source.Events(ctx) {
+		t.handle(event)
+	}
+}
+
+// Poll verifica transições sob demanda quando a fonte suporta
+func (t *PowerTracker) Poll() {
+	poller, ok := t.source.(PowerEventPoller)
+	if !ok {
+		return
+	}
+	for _, event := range poller.Poll() {
+		t.handle(event)
+	}
+}
+
+// handle registra o evento e notifica o callback
+func (t *PowerTracker) handle(event PowerEvent) {
+	t.record(event)
+
+	t.mu.RLock()
+	onEvent := t.onEvent
+	t.mu.RUnlock()
+
+	if onEvent != nil {
+		onEvent(event)
+	}
+}
+
+// record registra um evento e atualiza os períodos de suspensão
+func (t *PowerTracker) record(event PowerEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event.Type {
+	case PowerEventSleep:
+		t.sleepingAt = event.Timestamp
+	case PowerEventWake:
+		start := t.sleepingAt
+		if start.IsZero() || event.SleptFor > 0 {
+			start = event.Timestamp.Add(-event.SleptFor)
+		}
+		if event.SleptFor == 0 && !start.IsZero() {
+			event.SleptFor = event.Timestamp.Sub(start)
+		}
+		t.periods = append(t.periods, sleepPeriod{start: start, end: event.Timestamp})
+		t.sleepingAt = time.Time{}
+
+		wake := event
+		t.pendingWake = &wake
+	}
+
+	t.events = append(t.events, event)
+	if len(t.events) > t.maxHistory {
+		t.events = t.events[len(t.events)-t.maxHistory:]
+	}
+	if len(t.periods) > t.maxHistory {
+		t.periods = t.periods[len(t.periods)-t.maxHistory:]
+	}
+}
+
+// ConsumeWake retorna o último wake ainda não reportado (uma única vez),
+// para anotar o primeiro heartbeat após acordar
+func (t *PowerTracker) ConsumeWake() (PowerEvent, bool) {
+	t.Poll()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pendingWake == nil {
+		return PowerEvent{}, false
+	}
+	wake := *t.pendingWake
+	t.pendingWake = nil
+	return wake, true
+}
+
+// CoveredBySleep indica se a maior parte do intervalo [from, to] foi passada em suspensão
+func (t *PowerTracker) CoveredBySleep(from, to time.Time) bool {
+	if !to.After(from) {
+		return false
+	}
+
+	t.Poll()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var slept time.Duration
+	for _, period := range t.periods {
+		start, end := period.start, period.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			slept += end.Sub(start)
+		}
+	}
+
+	// A parte acordada do intervalo deve caber em uma janela normal
+	return slept > 0 && to.Sub(from)-slept < to.Sub(from)/2
+}
+
+// Events retorna o histórico recente de transições
+func (t *PowerTracker) Events() []PowerEvent {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	events := make([]PowerEvent, len(t.events))
+	copy(events, t.events)
+	return events
+}
+
+// SetPowerEventSource substitui a fonte de eventos de energia; deve ser
+// chamado antes de Start
+func (a *Agent) SetPowerEventSource(source PowerEventSource) {
+	a.power = NewPowerTracker(source)
+}
+
+// runPowerTracker registra transições de energia até o agente parar
+func (a *Agent) runPowerTracker() {
+	defer a.wg.Done()
+
+	a.power.Run(a.ctx, func(event PowerEvent) {
+		fields := map[string]interface{}{
+			"type":      string(event.Type),
+			"timestamp": event.Timestamp.Format(time.RFC3339),
+		}
+		if event.Type == PowerEventWake {
+			fields["slept_for"] = event.SleptFor.Round(time.Second).String()
+		}
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, "power_"+string(event.Type), "Power state transition", fields)
+	})
+}
+
+// powerHeartbeatExtras anota o primeiro heartbeat após um wake
+func (a *Agent) powerHeartbeatExtras() map[string]interface{} {
+	wake, ok := a.power.ConsumeWake()
+	if !ok {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"woke_from_sleep":        true,
+		"sleep_duration_seconds": int64(wake.SleptFor.Seconds()),
+		"woke_at":                wake.Timestamp,
+	}
+}
@@ -0,0 +1,6 @@
+package agent
+
+// newPlatformPowerSource usa o log de energia do pmset
+func newPlatformPowerSource() PowerEventSource {
+	return newPmsetSource()
+}
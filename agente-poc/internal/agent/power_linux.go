@@ -0,0 +1,6 @@
+package agent
+
+// newPlatformPowerSource usa os sinais do systemd-logind
+func newPlatformPowerSource() PowerEventSource {
+	return newLogindSource()
+}
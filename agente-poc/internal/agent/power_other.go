@@ -0,0 +1,8 @@
+//go:build !darwin && !linux && !windows
+
+package agent
+
+// newPlatformPowerSource não tem fonte nativa nesta plataforma
+func newPlatformPowerSource() PowerEventSource {
+	return nil
+}
@@ -0,0 +1,344 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pmsetPollInterval espaça as leituras do log do pmset, que cresce com o uso
+const pmsetPollInterval = time.Minute
+
+// NewSystemPowerSource cria a fonte de eventos de energia da plataforma
+// (notificações do sistema), com o detector por salto de relógio como
+// reserva quando ela não está disponível ou para de funcionar
+func NewSystemPowerSource() PowerEventSource {
+	fallback := NewClockGapSource(powerPollInterval, powerSleepThreshold)
+	if primary := newPlatformPowerSource(); primary != nil {
+		return newFallbackPowerSource(primary, fallback)
+	}
+	return fallback
+}
+
+// fallbackPowerSource consome a fonte primária e passa para a reserva quando o
+// canal da primária fecha antes do contexto ser cancelado
+type fallbackPowerSource struct {
+	primary  PowerEventSource
+	fallback PowerEventSource
+
+	mu     sync.Mutex
+	active PowerEventSource
+}
+
+func newFallbackPowerSource(primary, fallback PowerEventSource) *fallbackPowerSource {
+	return &fallbackPowerSource{primary: primary, fallback: fallback}
+}
+
+// Events repassa os eventos da fonte ativa
+func (s *fallbackPowerSource) Events(ctx context.Context) <-chan PowerEvent {
+	events := make(chan PowerEvent, 4)
+	s.setActive(s.primary)
+
+	go func() {
+		defer close(events)
+
+		forward := func(source <-chan PowerEvent) bool {
+			for event := range source {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return ctx.Err() == nil
+		}
+
+		if !forward(s.primary.Events(ctx)) {
+			return
+		}
+		s.setActive(s.fallback)
+		forward(s.fallback.Events(ctx))
+	}()
+
+	return events
+}
+
+// Poll delega à fonte ativa quando ela verifica transições sob demanda
+func (s *fallbackPowerSource) Poll() []PowerEvent {
+	s.mu.Lock()
+	active := s.active
+	s.mu.Unlock()
+
+	if poller, ok := active.(PowerEventPoller); ok {
+		return poller.Poll()
+	}
+	return nil
+}
+
+// Active retorna a fonte em uso (nil antes de Events)
+func (s *fallbackPowerSource) Active() PowerEventSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+func (s *fallbackPowerSource) setActive(source PowerEventSource) {
+	s.mu.Lock()
+	s.active = source
+	s.mu.Unlock()
+}
+
+// powerSignals converte os sinais de suspensão e retomada da plataforma em
+// eventos, descartando retomadas repetidas (o Windows avisa o resume
+// automático e depois o do usuário) e calculando o tempo suspenso
+type powerSignals struct {
+	sleepingAt time.Time
+}
+
+// sleep registra o início de uma suspensão
+func (p *powerSignals) sleep(at time.Time) (PowerEvent, bool) {
+	if !p.sleepingAt.IsZero() {
+		return PowerEvent{}, false
+	}
+	p.sleepingAt = at.Round(0)
+	return PowerEvent{Type: PowerEventSleep, Timestamp: p.sleepingAt}, true
+}
+
+// wake registra a retomada; sem suspensão anterior não há evento
+func (p *powerSignals) wake(at time.Time) (PowerEvent, bool) {
+	if p.sleepingAt.IsZero() {
+		return PowerEvent{}, false
+	}
+	// Pelo relógio de parede: o monotônico não avança durante a suspensão
+	at = at.Round(0)
+	sleptFor := at.Sub(p.sleepingAt)
+	p.sleepingAt = time.Time{}
+	if sleptFor < 0 {
+		sleptFor = 0
+	}
+	return PowerEvent{Type: PowerEventWake, Timestamp: at, SleptFor: sleptFor}, true
+}
+
+// logindSource acompanha o sinal PrepareForSleep do systemd-logind pelo
+// dbus-monitor. Apenas escuta: sem inhibitor lock, não atrasa nem impede a
+// suspensão.
+type logindSource struct {
+	start func(ctx context.Context) (io.ReadCloser, error)
+	now   func() time.Time
+}
+
+// logindMatch filtra o sinal emitido antes de suspender (true) e ao retomar (false)
+const logindMatch = "type='signal',sender='org.freedesktop.login1',interface='org.freedesktop.login1.Manager',member='PrepareForSleep'"
+
+func newLogindSource() *logindSource {
+	return &logindSource{start: startDBusMonitor, now: time.Now}
+}
+
+// startDBusMonitor inicia o dbus-monitor no barramento do sistema
+func startDBusMonitor(ctx context.Context) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "dbus-monitor", "--system", logindMatch)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start dbus-monitor: %w", err)
+	}
+	return &commandOutput{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// commandOutput aguarda o processo ao fechar a saída
+type commandOutput struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (c *commandOutput) Close() error {
+	err := c.ReadCloser.Close()
+	_ = c.cmd.Wait()
+	return err
+}
+
+// Events emite os sinais do logind até o monitor terminar ou o contexto acabar
+func (s *logindSource) Events(ctx context.Context) <-chan PowerEvent {
+	events := make(chan PowerEvent, 4)
+
+	go func() {
+		defer close(events)
+
+		output, err := s.start(ctx)
+		if err != nil {
+			return
+		}
+		defer output.Close()
+
+		var signals powerSignals
+		pending := false
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "signal ") {
+				pending = strings.Contains(line, "member=PrepareForSleep")
+				continue
+			}
+			if !pending || !strings.HasPrefix(line, "boolean ") {
+				continue
+			}
+			pending = false
+
+			var event PowerEvent
+			var ok bool
+			if strings.TrimPrefix(line, "boolean ") == "true" {
+				event, ok = signals.sleep(s.now())
+			} else {
+				event, ok = signals.wake(s.now())
+			}
+			if !ok {
+				continue
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// pmsetSource lê as transições do `pmset -g log` do macOS a cada
+// pmsetPollInterval, emitindo só as entradas novas. Um ticker não impede a
+// máquina de dormir.
+type pmsetSource struct {
+	run func(ctx context.Context) ([]byte, error)
+
+	mu       sync.Mutex
+	since    time.Time
+	signals  powerSignals
+	lastPoll time.Time
+}
+
+func newPmsetSource() *pmsetSource {
+	return &pmsetSource{
+		run: func(ctx context.Context) ([]byte, error) {
+			return exec.CommandContext(ctx, "pmset", "-g", "log").Output()
+		},
+		since: time.Now().Round(0),
+	}
+}
+
+// Events verifica o log periodicamente; o canal fecha se o pmset falhar na
+// primeira leitura, para que a fonte de reserva assuma
+func (s *pmsetSource) Events(ctx context.Context) <-chan PowerEvent {
+	events := make(chan PowerEvent, 4)
+
+	go func() {
+		defer close(events)
+
+		if _, err := s.poll(ctx); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(pmsetPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				found, _ := s.poll(ctx)
+				for _, event := range found {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// Poll lê o log sob demanda; leituras a menos de powerPollInterval da
+// anterior (pelo relógio de parede, que avança durante a suspensão) são
+// ignoradas
+func (s *pmsetSource) Poll() []PowerEvent {
+	s.mu.Lock()
+	recent := !s.lastPoll.IsZero() && time.Now().Round(0).Sub(s.lastPoll) < powerPollInterval
+	s.mu.Unlock()
+	if recent {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, _ := s.poll(ctx)
+	return events
+}
+
+// poll executa o pmset e converte as entradas posteriores à última lida
+func (s *pmsetSource) poll(ctx context.Context) ([]PowerEvent, error) {
+	output, err := s.run(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastPoll = time.Now().Round(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []PowerEvent
+	for _, entry := range parsePmsetLog(string(output)) {
+		if !entry.at.After(s.since) {
+			continue
+		}
+		s.since = entry.at
+
+		var event PowerEvent
+		var ok bool
+		if entry.sleep {
+			event, ok = s.signals.sleep(entry.at)
+		} else {
+			event, ok = s.signals.wake(entry.at)
+		}
+		if ok {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// pmsetEntry é uma transição lida do log do pmset
+type pmsetEntry struct {
+	at    time.Time
+	sleep bool
+}
+
+// parsePmsetLog extrai as linhas Sleep e Wake do `pmset -g log`. DarkWake
+// (manutenção com a tela apagada) não conta como retomada: a máquina volta
+// a dormir em seguida.
+func parsePmsetLog(output string) []pmsetEntry {
+	var entries []pmsetEntry
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[3] != "Sleep" && fields[3] != "Wake") {
+			continue
+		}
+		at, err := time.Parse("2006-01-02 15:04:05 -0700", strings.Join(fields[:3], " "))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, pmsetEntry{at: at, sleep: fields[3] == "Sleep"})
+	}
+	return entries
+}
@@ -0,0 +1,306 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePowerSource é uma fonte de notificações controlada pelo teste
+type fakePowerSource struct {
+	events chan PowerEvent
+	polled []PowerEvent
+	mu     sync.Mutex
+}
+
+func newFakePowerSource() *fakePowerSource {
+	return &fakePowerSource{events: make(chan PowerEvent, 16)}
+}
+
+func (s *fakePowerSource) Events(ctx context.Context) <-chan PowerEvent {
+	out := make(chan PowerEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-s.events:
+				if !ok {
+					return
+				}
+				out <- event
+			}
+		}
+	}()
+	return out
+}
+
+func (s *fakePowerSource) Poll() []PowerEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	polled := s.polled
+	s.polled = nil
+	return polled
+}
+
+// collectPower roda o tracker até receber n eventos no callback
+func collectPower(t *testing.T, tracker *PowerTracker, n int) []PowerEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	received := make(chan PowerEvent, n)
+	go tracker.Run(ctx, func(event PowerEvent) { received <- event })
+
+	var got []PowerEvent
+	for len(got) < n {
+		select {
+		case event := <-received:
+			got = append(got, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d power events, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestPowerTrackerInjectedSource(t *testing.T) {
+	source := newFakePowerSource()
+	tracker := NewPowerTracker(source)
+
+	sleptAt := time.Date(2026, 3, 2, 22, 0, 0, 0, time.UTC)
+	wokeAt := sleptAt.Add(8 * time.Hour)
+	source.events <- PowerEvent{Type: PowerEventSleep, Timestamp: sleptAt}
+	source.events <- PowerEvent{Type: PowerEventWake, Timestamp: wokeAt}
+
+	got := collectPower(t, tracker, 2)
+	if got[0].Type != PowerEventSleep || got[1].Type != PowerEventWake {
+		t.Fatalf("events = %+v", got)
+	}
+
+	wake, ok := tracker.ConsumeWake()
+	if !ok || wake.SleptFor != 8*time.Hour || !wake.Timestamp.Equal(wokeAt) {
+		t.Fatalf("ConsumeWake = %+v, %t", wake, ok)
+	}
+	if _, ok := tracker.ConsumeWake(); ok {
+		t.Fatal("wake reported twice")
+	}
+
+	// Heartbeats perdidos durante a noite são cobertos pela suspensão
+	if !tracker.CoveredBySleep(sleptAt.Add(-time.Minute), wokeAt) {
+		t.Fatal("night gap not covered by sleep")
+	}
+	if tracker.CoveredBySleep(wokeAt, wokeAt.Add(time.Hour)) {
+		t.Fatal("awake hour covered by sleep")
+	}
+	if len(tracker.Events()) != 2 {
+		t.Fatalf("history = %+v", tracker.Events())
+	}
+}
+
+func TestPowerTrackerPollsSource(t *testing.T) {
+	source := newFakePowerSource()
+	tracker := NewPowerTracker(source)
+
+	wokeAt := time.Now().Round(0)
+	source.polled = []PowerEvent{{Type: PowerEventWake, Timestamp: wokeAt, SleptFor: time.Hour}}
+
+	// O heartbeat logo após o wake vê o evento antes do loop da fonte
+	wake, ok := tracker.ConsumeWake()
+	if !ok || wake.SleptFor != time.Hour {
+		t.Fatalf("ConsumeWake after poll = %+v, %t", wake, ok)
+	}
+	if !tracker.CoveredBySleep(wokeAt.Add(-time.Hour), wokeAt) {
+		t.Fatal("polled sleep period not recorded")
+	}
+}
+
+func TestPowerSignals(t *testing.T) {
+	var signals powerSignals
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := signals.wake(base); ok {
+		t.Fatal("wake without a previous sleep emitted")
+	}
+	if event, ok := signals.sleep(base); !ok || event.Type != PowerEventSleep {
+		t.Fatalf("sleep = %+v, %t", event, ok)
+	}
+	if _, ok := signals.sleep(base.Add(time.Second)); ok {
+		t.Fatal("repeated sleep emitted")
+	}
+	event, ok := signals.wake(base.Add(90 * time.Minute))
+	if !ok || event.SleptFor != 90*time.Minute {
+		t.Fatalf("wake = %+v, %t", event, ok)
+	}
+	// Resume automático seguido do resume do usuário
+	if _, ok := signals.wake(base.Add(91 * time.Minute)); ok {
+		t.Fatal("second resume emitted")
+	}
+}
+
+// dbusMonitorOutput imita a saída do dbus-monitor com o filtro do logind
+const dbusMonitorOutput = `signal time=1767261600.000000 sender=org.freedesktop.DBus -> destination=:1.90 serial=2 path=/org/freedesktop/DBus; interface=org.freedesktop.DBus; member=NameAcquired
+   string ":1.90"
+signal time=1767261700.000000 sender=:1.3 -> destination=(null destination) serial=812 path=/org/freedesktop/login1; interface=org.freedesktop.login1.Manager; member=PrepareForSleep
+   boolean true
+signal time=1767265300.000000 sender=:1.3 -> destination=(null destination) serial=813 path=/org/freedesktop/login1; interface=org.freedesktop.login1.Manager; member=PrepareForSleep
+   boolean false
+signal time=1767265400.000000 sender=:1.3 -> destination=(null destination) serial=814 path=/org/freedesktop/login1; interface=org.freedesktop.login1.Manager; member=PrepareForSleep
+   boolean false
+`
+
+func TestLogindSource(t *testing.T) {
+	clock := []time.Time{
+		time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 11, 1, 0, 0, time.UTC),
+	}
+	source := &logindSource{
+		start: func(context.Context) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(dbusMonitorOutput)), nil
+		},
+		now: func() time.Time {
+			now := clock[0]
+			clock = clock[1:]
+			return now
+		},
+	}
+
+	var got []PowerEvent
+	for event := range source.Events(context.Background()) {
+		got = append(got, event)
+	}
+	if len(got) != 2 || got[0].Type != PowerEventSleep || got[1].Type != PowerEventWake {
+		t.Fatalf("logind events = %+v", got)
+	}
+	if got[1].SleptFor != time.Hour {
+		t.Fatalf("slept for %s, want 1h", got[1].SleptFor)
+	}
+}
+
+func TestLogindSourceUnavailable(t *testing.T) {
+	source := &logindSource{
+		start: func(context.Context) (io.ReadCloser, error) {
+			return nil, errors.New("dbus-monitor: executable file not found")
+		},
+		now: time.Now,
+	}
+	select {
+	case _, ok := <-source.Events(context.Background()):
+		if ok {
+			t.Fatal("event from an unavailable logind source")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unavailable logind source did not close its channel")
+	}
+}
+
+// pmsetLog imita o `pmset -g log`, com linhas de outras categorias
+const pmsetLog = `Time stamp                Domain              	Message                                                                    	Duration  	Delay
+2026-01-01 09:00:00 -0300 Assertions          	PID 120(WindowServer) Created UserIsActive "com.apple.iohideventsystem.queue.tickle" 00:00:00  id:0x0 Aggregate:0x0
+2026-01-01 09:30:00 -0300 Sleep               	Entering Sleep state due to 'Clamshell Sleep':TCPKeepAlive=active Using Batt (Charge:80%) 7200 secs
+2026-01-01 10:15:00 -0300 DarkWake            	DarkWake from Deep Idle [CDN] : due to RTC/Maintenance Using BATT (Charge:79%) 45 secs
+2026-01-01 11:30:00 -0300 Wake                	Wake from Deep Idle [CDNVA] : due to EC.LidOpen/Lid Open Using BATT (Charge:78%)
+`
+
+func TestPmsetSource(t *testing.T) {
+	output := pmsetLog
+	source := &pmsetSource{
+		run: func(context.Context) ([]byte, error) { return []byte(output), nil },
+		// Entradas anteriores ao início do agente são ignoradas
+		since: time.Date(2026, 1, 1, 9, 15, 0, 0, time.FixedZone("", -3*3600)),
+	}
+
+	events, err := source.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Type != PowerEventSleep || events[1].Type != PowerEventWake {
+		t.Fatalf("pmset events = %+v", events)
+	}
+	if events[1].SleptFor != 2*time.Hour {
+		t.Fatalf("slept for %s, want 2h (DarkWake is not a wake)", events[1].SleptFor)
+	}
+
+	// Só as entradas novas saem na leitura seguinte
+	output += "2026-01-01 18:00:00 -0300 Sleep               \tEntering Sleep state due to 'Idle Sleep' Using AC 30 secs\n"
+	events, err = source.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != PowerEventSleep {
+		t.Fatalf("pmset delta = %+v", events)
+	}
+
+	// Poll sob demanda respeita o intervalo mínimo
+	if events := source.Poll(); events != nil {
+		t.Fatalf("Poll right after a read = %+v", events)
+	}
+}
+
+func TestPmsetSourceUnavailable(t *testing.T) {
+	source := &pmsetSource{
+		run: func(context.Context) ([]byte, error) { return nil, errors.New("pmset: not found") },
+	}
+	select {
+	case _, ok := <-source.Events(context.Background()):
+		if ok {
+			t.Fatal("event from an unavailable pmset source")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unavailable pmset source did not close its channel")
+	}
+}
+
+func TestFallbackPowerSource(t *testing.T) {
+	failed := &logindSource{
+		start: func(context.Context) (io.ReadCloser, error) { return nil, errors.New("no system bus") },
+		now:   time.Now,
+	}
+	fallback := newFakePowerSource()
+	source := newFallbackPowerSource(failed, fallback)
+	tracker := NewPowerTracker(source)
+
+	wokeAt := time.Now().Round(0)
+	fallback.events <- PowerEvent{Type: PowerEventWake, Timestamp: wokeAt, SleptFor: time.Minute}
+	got := collectPower(t, tracker, 1)
+	if got[0].Type != PowerEventWake {
+		t.Fatalf("fallback events = %+v", got)
+	}
+	if source.Active() != PowerEventSource(fallback) {
+		t.Fatal("fallback source not active after the primary failed")
+	}
+
+	// Poll passa a consultar a reserva
+	fallback.mu.Lock()
+	fallback.polled = []PowerEvent{{Type: PowerEventSleep, Timestamp: wokeAt.Add(time.Minute)}}
+	fallback.mu.Unlock()
+	if polled := source.Poll(); len(polled) != 1 {
+		t.Fatalf("Poll through the fallback = %+v", polled)
+	}
+}
+
+func TestFallbackPowerSourceKeepsPrimary(t *testing.T) {
+	primary := newFakePowerSource()
+	fallback := newFakePowerSource()
+	source := newFallbackPowerSource(primary, fallback)
+	tracker := NewPowerTracker(source)
+
+	primary.events <- PowerEvent{Type: PowerEventSleep, Timestamp: time.Now()}
+	collectPower(t, tracker, 1)
+	if source.Active() != PowerEventSource(primary) {
+		t.Fatal("working primary source replaced by the fallback")
+	}
+}
+
+func TestClockGapSourceNoSleep(t *testing.T) {
+	source := NewClockGapSource(time.Hour, powerSleepThreshold).(*clockGapSource)
+	if events := source.Poll(); events != nil {
+		t.Fatalf("clock gap without suspension = %+v", events)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Tipos de notificação de PowerRegisterSuspendResumeNotification (winuser.h)
+const (
+	deviceNotifyCallback  = 2
+	pbtAPMSuspend         = 0x4
+	pbtAPMResumeSuspend   = 0x7
+	pbtAPMResumeAutomatic = 0x12
+)
+
+var (
+	powrprof = syscall.NewLazyDLL("powrprof.dll")
+
+	procPowerRegisterSuspendResumeNotification   = powrprof.NewProc("PowerRegisterSuspendResumeNotification")
+	procPowerUnregisterSuspendResumeNotification = powrprof.NewProc("PowerUnregisterSuspendResumeNotification")
+
+	// O callback é criado uma vez: o runtime limita a quantidade deles
+	powerNotifyCallback = syscall.NewCallback(powerNotify)
+
+	powerNotifyMu   sync.Mutex
+	powerNotifySink func(eventType uint32)
+)
+
+// deviceNotifySubscribeParameters espelha DEVICE_NOTIFY_SUBSCRIBE_PARAMETERS
+type deviceNotifySubscribeParameters struct {
+	callback uintptr
+	context  uintptr
+}
+
+// powerNotify recebe as notificações de suspensão e retomada do sistema
+func powerNotify(_, eventType, _ uintptr) uintptr {
+	powerNotifyMu.Lock()
+	sink := powerNotifySink
+	powerNotifyMu.Unlock()
+
+	if sink != nil {
+		sink(uint32(eventType))
+	}
+	return 0
+}
+
+// windowsPowerSource recebe suspend/resume do gerenciador de energia, o mesmo
+// aviso que o SCM entrega a serviços como SERVICE_CONTROL_POWEREVENT, sem
+// exigir que o agente rode como serviço
+type windowsPowerSource struct {
+	params *deviceNotifySubscribeParameters
+}
+
+// newPlatformPowerSource usa as notificações de suspend/resume do Windows
+func newPlatformPowerSource() PowerEventSource {
+	return &windowsPowerSource{}
+}
+
+// Events registra a notificação até o contexto acabar; sem a API (anterior ao
+// Windows 8) o canal fecha em seguida
+func (s *windowsPowerSource) Events(ctx context.Context) <-chan PowerEvent {
+	events := make(chan PowerEvent, 4)
+
+	if err := procPowerRegisterSuspendResumeNotification.Find(); err != nil {
+		close(events)
+		return events
+	}
+
+	var mu sync.Mutex
+	var signals powerSignals
+	closed := false
+	powerNotifyMu.Lock()
+	powerNotifySink = func(eventType uint32) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+
+		var event PowerEvent
+		var ok bool
+		switch eventType {
+		case pbtAPMSuspend:
+			event, ok = signals.sleep(time.Now())
+		case pbtAPMResumeAutomatic, pbtAPMResumeSuspend:
+			event, ok = signals.wake(time.Now())
+		}
+		if !ok {
+			return
+		}
+		// O callback roda em uma thread do sistema e não pode bloquear
+		select {
+		case events <- event:
+		default:
+		}
+	}
+	powerNotifyMu.Unlock()
+
+	s.params = &deviceNotifySubscribeParameters{callback: powerNotifyCallback}
+	var handle uintptr
+	ret, _, _ := procPowerRegisterSuspendResumeNotification.Call(
+		deviceNotifyCallback,
+		uintptr(unsafe.Pointer(s.params)),
+		uintptr(unsafe.Pointer(&handle)),
+	)
+	if ret != 0 {
+		s.clearSink()
+		close(events)
+		return events
+	}
+
+	go func() {
+		<-ctx.Done()
+		_, _, _ = procPowerUnregisterSuspendResumeNotification.Call(handle)
+		s.clearSink()
+
+		mu.Lock()
+		closed = true
+		close(events)
+		mu.Unlock()
+	}()
+
+	return events
+}
+
+func (s *windowsPowerSource) clearSink() {
+	powerNotifyMu.Lock()
+	powerNotifySink = nil
+	powerNotifyMu.Unlock()
+}
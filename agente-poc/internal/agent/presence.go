@@ -0,0 +1,405 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/comms"
+)
+
+// Políticas de adiamento por presença do usuário (presence_policy)
+const (
+	PresencePolicyOff          = "off"          // nunca adia
+	PresencePolicyPresentation = "presentation" // adia enquanto o usuário apresenta
+	PresencePolicyFocus        = "focus"        // adia também em modos de foco/não perturbe
+)
+
+// Tarefas adiáveis
+const (
+	deferTaskInventory     = "full_inventory"
+	deferTaskCommandPrefix = "command:"
+)
+
+// Intervalos do controle de adiamento
+const (
+	presenceCacheTTL        = 15 * time.Second
+	presenceRecheckInterval = 30 * time.Second
+)
+
+// PresenceState descreve a atenção do usuário em um momento
+type PresenceState struct {
+	Presenting bool   `json:"presenting"`
+	FocusMode  bool   `json:"focus_mode"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// PresenceProvider detecta apresentação e modos de foco. A implementação
+// padrão depende da plataforma; testes podem injetar uma falsa com
+// SetPresenceProvider.
+type PresenceProvider interface {
+	Presence(ctx context.Context) (PresenceState, error)
+}
+
+// systemPresenceProvider é o PresenceProvider padrão
+type systemPresenceProvider struct{}
+
+// NewSystemPresenceProvider cria o detector de presença da plataforma atual
+func NewSystemPresenceProvider() PresenceProvider {
+	return systemPresenceProvider{}
+}
+
+// Presence consulta o estado de apresentação/foco da plataforma
+func (systemPresenceProvider) Presence(ctx context.Context) (PresenceState, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return macOSPresence(ctx)
+	case "windows":
+		return windowsPresence()
+	default:
+		return PresenceState{}, nil
+	}
+}
+
+// macPresentationApps são processos que, ao impedir o descanso de tela,
+// indicam apresentação ou reunião com compartilhamento
+var macPresentationApps = []string{"Keynote", "Microsoft PowerPoint", "zoom.us", "Microsoft Teams", "MSTeams", "Webex"}
+
+// macScreenCaptureProcesses existem apenas durante captura/compartilhamento de tela
+var macScreenCaptureProcesses = []string{"CptHost", "screensharingd"}
+
+// macOSPresence combina heurísticas: asserções de energia de apps de
+// apresentação, processos de compartilhamento de tela e o banco de asserções
+// do Focus (macOS 12+)
+func macOSPresence(ctx context.Context) (PresenceState, error) {
+	var state PresenceState
+
+	output, err := exec.CommandContext(ctx, "pmset", "-g", "assertions").Output()
+	if err != nil {
+		return state, fmt.Errorf("failed to execute pmset: %w", err)
+	}
+	if app := presentationAssertion(output); app != "" {
+		state.Presenting = true
+		state.Reason = app + " is preventing display sleep"
+	}
+
+	if !state.Presenting {
+		if output, err := exec.CommandContext(ctx, "ps", "-axco", "comm").Output(); err == nil {
+			if process := matchProcess(output, macScreenCaptureProcesses); process != "" {
+				state.Presenting = true
+				state.Reason = "screen capture active (" + process + ")"
+			}
+		}
+	}
+
+	if focusActive(ctx) {
+		state.FocusMode = true
+		if state.Reason == "" {
+			state.Reason = "focus mode active"
+		}
+	}
+
+	return state, nil
+}
+
+// presentationAssertion procura PreventUserIdleDisplaySleep mantida por um app
+// de apresentação na saída de `pmset -g assertions`, ex.:
+//
+//	pid 512(Keynote): [0x0001] 00:05:10 PreventUserIdleDisplaySleep named: "Presenting"
+func presentationAssertion(output []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "PreventUserIdleDisplaySleep") {
+			continue
+		}
+		open, end := strings.Index(line, "("), strings.Index(line, ")")
+		if open < 0 || end <= open {
+			continue
+		}
+		process := line[open+1 : end]
+		for _, app := range macPresentationApps {
+			if process == app {
+				return app
+			}
+		}
+	}
+	return ""
+}
+
+// matchProcess retorna o primeiro processo da lista presente na saída do ps
+func matchProcess(output []byte, names []string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		process := strings.TrimSpace(scanner.Text())
+		for _, name := range names {
+			if process == name {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// focusActive lê o Assertions.json do usuário do console; um registro de
+// asserção indica um Focus ativado manualmente. Focos agendados não aparecem
+// nesse arquivo.
+func focusActive(ctx context.Context) bool {
+	owner, err := exec.CommandContext(ctx, "stat", "-f", "%Su", "/dev/console").Output()
+	if err != nil {
+		return false
+	}
+	consoleUser, err := user.Lookup(strings.TrimSpace(string(owner)))
+	if err != nil {
+		return false
+	}
+
+	data, err := os.ReadFile(filepath.Join(consoleUser.HomeDir, "Library", "DoNotDisturb", "DB", "Assertions.json"))
+	if err != nil {
+		return false
+	}
+
+	var assertions struct {
+		Data []struct {
+			StoreAssertionRecords []json.RawMessage `json:"storeAssertionRecords"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &assertions); err != nil {
+		return false
+	}
+	for _, entry := range assertions.Data {
+		if len(entry.StoreAssertionRecords) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Valores de QUERY_USER_NOTIFICATION_STATE
+const (
+	qunsNotPresent           = 1
+	qunsBusy                 = 2
+	qunsRunningD3DFullScreen = 3
+	qunsPresentationMode     = 4
+	qunsAcceptsNotification  = 5
+	qunsQuietTime            = 6
+	qunsApp                  = 7
+)
+
+// windowsPresence usa SHQueryUserNotificationState. Como serviço (sessão 0)
+// o resultado reflete a sessão do serviço, não a do usuário interativo.
+func windowsPresence() (PresenceState, error) {
+	value, err := queryUserNotificationState()
+	if err != nil {
+		return PresenceState{}, err
+	}
+
+	var state PresenceState
+	switch value {
+	case qunsPresentationMode:
+		state.Presenting = true
+		state.Reason = "presentation mode"
+	case qunsBusy, qunsRunningD3DFullScreen, qunsApp:
+		state.Presenting = true
+		state.Reason = "full-screen application"
+	case qunsQuietTime:
+		state.FocusMode = true
+		state.Reason = "quiet time"
+	}
+	return state, nil
+}
+
+// DeferralGate decide se trabalho não urgente deve ser adiado pela presença
+// do usuário, até o limite maxDeferral por tarefa
+type DeferralGate struct {
+	provider    PresenceProvider
+	policy      string
+	maxDeferral time.Duration
+	clock       clock.Clock
+
+	mu        sync.Mutex
+	state     PresenceState
+	stateErr  error
+	checkedAt time.Time
+	since     map[string]time.Time // início do adiamento de cada tarefa
+	deferred  int64
+	forced    int64
+}
+
+// NewDeferralGate cria o controle de adiamento com a política informada
+func NewDeferralGate(provider PresenceProvider, policy string, maxDeferral time.Duration) *DeferralGate {
+	return &DeferralGate{
+		provider:    provider,
+		policy:      policy,
+		maxDeferral: maxDeferral,
+		clock:       clock.Real,
+		since:       make(map[string]time.Time),
+	}
+}
+
+// SetClock substitui a fonte de tempo do adiamento e do cache de presença;
+// deve ser chamado antes de Start
+func (g *DeferralGate) SetClock(clk clock.Clock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.clock = clock.OrReal(clk)
+}
+
+// Defer indica se a tarefa deve ser adiada agora. Quando a tarefa já está
+// adiada há maxDeferral, retorna false para que ela execute mesmo assim.
+func (g *DeferralGate) Defer(ctx context.Context, task string) bool {
+	if g.policy == PresencePolicyOff {
+		return false
+	}
+
+	state := g.current(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	if !g.blocks(state) {
+		delete(g.since, task)
+		return false
+	}
+
+	start, ok := g.since[task]
+	if !ok {
+		start = now
+		g.since[task] = now
+	}
+
+	if g.maxDeferral > 0 && now.Sub(start) >= g.maxDeferral {
+		delete(g.since, task)
+		g.forced++
+		return false
+	}
+
+	g.deferred++
+	return true
+}
+
+// Deferring indica se a tarefa está adiada no momento
+func (g *DeferralGate) Deferring(task string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.since[task]
+	return ok
+}
+
+// blocks aplica a política ao estado; deve ser chamado com o lock adquirido
+func (g *DeferralGate) blocks(state PresenceState) bool {
+	if state.Presenting {
+		return true
+	}
+	return g.policy == PresencePolicyFocus && state.FocusMode
+}
+
+// current retorna o estado de presença, consultando o provider no máximo a
+// cada presenceCacheTTL. Erros do provider equivalem a "não apresentando".
+func (g *DeferralGate) current(ctx context.Context) PresenceState {
+	g.mu.Lock()
+	if !g.checkedAt.IsZero() && !clock.Expired(g.clock, g.checkedAt, presenceCacheTTL) {
+		state := g.state
+		g.mu.Unlock()
+		return state
+	}
+	g.mu.Unlock()
+
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	state, err := g.provider.Presence(queryCtx)
+	if err != nil {
+		state = PresenceState{}
+	}
+
+	g.mu.Lock()
+	g.state = state
+	g.stateErr = err
+	g.checkedAt = g.clock.Now()
+	g.mu.Unlock()
+
+	return state
+}
+
+// Status resume o estado de adiamento para heartbeats e Health()
+func (g *DeferralGate) Status() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := map[string]interface{}{
+		"policy":         g.policy,
+		"presenting":     g.state.Presenting,
+		"focus_mode":     g.state.FocusMode,
+		"deferring":      len(g.since) > 0,
+		"deferred_tasks": len(g.since),
+		"deferred_count": g.deferred,
+		"forced_count":   g.forced,
+	}
+	if g.state.Reason != "" {
+		status["reason"] = g.state.Reason
+	}
+	if g.stateErr != nil {
+		status["error"] = g.stateErr.Error()
+	}
+
+	var oldest time.Time
+	for _, start := range g.since {
+		if oldest.IsZero() || start.Before(oldest) {
+			oldest = start
+		}
+	}
+	if !oldest.IsZero() {
+		status["deferred_since"] = oldest
+	}
+
+	return status
+}
+
+// SetPresenceProvider substitui o detector de presença; deve ser chamado
+// antes de Start
+func (a *Agent) SetPresenceProvider(provider PresenceProvider) {
+	a.presence = NewDeferralGate(provider, a.config.PresencePolicy, a.config.MaxPresenceDeferral)
+	a.presence.SetClock(a.clock)
+}
+
+// deferCommand devolve um comando adiável à fila depois de presenceRecheckInterval.
+// No primeiro adiamento o backend recebe o status scheduled.
+func (a *Agent) deferCommand(command *comms.Command, first bool) {
+	if first {
+		a.logger.WithField("command_id", command.ID).Info("Deferring command while user is presenting")
+		a.sendCommandResult(&comms.CommandResult{
+			ID:        command.ID,
+			CommandID: command.ID,
+			Status:    comms.StatusScheduled,
+			Output:    "deferred while the user is presenting",
+			Timestamp: a.clock.Now(),
+		})
+	}
+
+	go func() {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-a.clock.After(presenceRecheckInterval):
+		}
+
+		if err := a.SubmitCommand(command); err != nil {
+			a.logger.WithFields(map[string]interface{}{
+				"command_id": command.ID,
+				"error":      err,
+			}).Error("Failed to requeue deferred command")
+		}
+	}()
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package agent
+
+import "fmt"
+
+// queryUserNotificationState só existe no Windows
+func queryUserNotificationState() (int, error) {
+	return 0, fmt.Errorf("SHQueryUserNotificationState is only available on Windows")
+}
@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/comms"
+)
+
+// fakePresence é um PresenceProvider controlado pelo teste
+type fakePresence struct {
+	mu    sync.Mutex
+	state PresenceState
+	err   error
+	calls int
+}
+
+func (f *fakePresence) Presence(ctx context.Context) (PresenceState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.state, f.err
+}
+
+func (f *fakePresence) set(state PresenceState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state, f.err = state, err
+}
+
+func (f *fakePresence) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// newTestGate cria um DeferralGate com provider falso e relógio falso
+func newTestGate(policy string, maxDeferral time.Duration, state PresenceState) (*DeferralGate, *fakePresence, *clock.Fake) {
+	provider := &fakePresence{state: state}
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	gate := NewDeferralGate(provider, policy, maxDeferral)
+	gate.SetClock(fake)
+	return gate, provider, fake
+}
+
+func TestDeferralGateDefersWhilePresenting(t *testing.T) {
+	ctx := context.Background()
+	gate, provider, fake := newTestGate(PresencePolicyPresentation, time.Hour, PresenceState{Presenting: true, Reason: "Keynote is preventing display sleep"})
+
+	if !gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory not deferred while presenting")
+	}
+	if !gate.Deferring(deferTaskInventory) {
+		t.Fatal("task not reported as deferring")
+	}
+	if gate.Deferring(deferTaskCommandPrefix + "cmd-1") {
+		t.Fatal("unrelated task reported as deferring")
+	}
+	fake.Advance(time.Minute)
+	if !gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory released while still presenting")
+	}
+
+	provider.set(PresenceState{}, nil)
+	fake.Advance(presenceCacheTTL + time.Second)
+	if gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory still deferred after the presentation ended")
+	}
+	if gate.Deferring(deferTaskInventory) {
+		t.Fatal("task still reported as deferring after release")
+	}
+
+	status := gate.Status()
+	if status["deferred_count"] != int64(2) || status["forced_count"] != int64(0) || status["deferring"] != false {
+		t.Fatalf("status after release: %v", status)
+	}
+}
+
+func TestDeferralGateCap(t *testing.T) {
+	ctx := context.Background()
+	maxDeferral := 10 * time.Minute
+	gate, _, fake := newTestGate(PresencePolicyPresentation, maxDeferral, PresenceState{Presenting: true})
+
+	start := fake.Now()
+	if !gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory not deferred while presenting")
+	}
+	fake.Advance(maxDeferral - time.Second)
+	if !gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory released before the cap")
+	}
+	if since := gate.Status()["deferred_since"]; since != start {
+		t.Fatalf("deferred_since = %v, want %v", since, start)
+	}
+
+	fake.Advance(time.Second)
+	if gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("inventory still deferred after max deferral")
+	}
+	if gate.Deferring(deferTaskInventory) {
+		t.Fatal("forced task still reported as deferring")
+	}
+	status := gate.Status()
+	if status["forced_count"] != int64(1) || status["deferred_count"] != int64(2) {
+		t.Fatalf("status after the cap: %v", status)
+	}
+	if _, ok := status["deferred_since"]; ok {
+		t.Fatalf("deferred_since kept after the forced run: %v", status)
+	}
+
+	// Depois da execução forçada, a próxima ocorrência começa um novo período
+	if !gate.Defer(ctx, deferTaskInventory) {
+		t.Fatal("next run not deferred after the forced one")
+	}
+	if since := gate.Status()["deferred_since"]; since != fake.Now() {
+		t.Fatalf("new deferral started at %v, want %v", since, fake.Now())
+	}
+}
+
+func TestDeferralGateWithoutCap(t *testing.T) {
+	gate, _, fake := newTestGate(PresencePolicyPresentation, 0, PresenceState{Presenting: true})
+
+	for i := 0; i < 5; i++ {
+		if !gate.Defer(context.Background(), deferTaskInventory) {
+			t.Fatalf("inventory released after %d hours without a cap", i)
+		}
+		fake.Advance(time.Hour)
+	}
+}
+
+func TestDeferralGatePolicies(t *testing.T) {
+	presenting := PresenceState{Presenting: true}
+	focus := PresenceState{FocusMode: true}
+
+	tests := []struct {
+		policy string
+		state  PresenceState
+		want   bool
+	}{
+		{PresencePolicyOff, presenting, false},
+		{PresencePolicyOff, focus, false},
+		{PresencePolicyPresentation, presenting, true},
+		{PresencePolicyPresentation, focus, false},
+		{PresencePolicyPresentation, PresenceState{}, false},
+		{PresencePolicyFocus, presenting, true},
+		{PresencePolicyFocus, focus, true},
+		{PresencePolicyFocus, PresenceState{}, false},
+	}
+	for _, tt := range tests {
+		gate, provider, _ := newTestGate(tt.policy, time.Hour, tt.state)
+		if got := gate.Defer(context.Background(), deferTaskInventory); got != tt.want {
+			t.Errorf("policy %s with %+v: Defer = %t, want %t", tt.policy, tt.state, got, tt.want)
+		}
+		if tt.policy == PresencePolicyOff && provider.callCount() != 0 {
+			t.Errorf("policy off queried the provider %d time(s)", provider.callCount())
+		}
+	}
+}
+
+func TestDeferralGateCachesPresence(t *testing.T) {
+	ctx := context.Background()
+	gate, provider, fake := newTestGate(PresencePolicyPresentation, time.Hour, PresenceState{Presenting: true})
+
+	gate.Defer(ctx, deferTaskInventory)
+	fake.Advance(presenceCacheTTL / 2)
+	gate.Defer(ctx, deferTaskCommandPrefix+"cmd-1")
+	if calls := provider.callCount(); calls != 1 {
+		t.Fatalf("provider queried %d times within the cache TTL", calls)
+	}
+
+	fake.Advance(presenceCacheTTL)
+	gate.Defer(ctx, deferTaskInventory)
+	if calls := provider.callCount(); calls != 2 {
+		t.Fatalf("provider queried %d times after the cache TTL, want 2", calls)
+	}
+}
+
+func TestDeferralGateProviderError(t *testing.T) {
+	gate, provider, _ := newTestGate(PresencePolicyFocus, time.Hour, PresenceState{})
+	provider.set(PresenceState{Presenting: true}, errors.New("pmset unavailable"))
+
+	if gate.Defer(context.Background(), deferTaskInventory) {
+		t.Fatal("provider error treated as presenting")
+	}
+	status := gate.Status()
+	if status["error"] != "pmset unavailable" || status["presenting"] != false {
+		t.Fatalf("status after provider error: %v", status)
+	}
+}
+
+func TestDeferredCommandRequeued(t *testing.T) {
+	a, fake := newTestAgent(t, map[string]interface{}{
+		"presence_policy":       PresencePolicyPresentation,
+		"max_presence_deferral": 600,
+	})
+	a.SetPresenceProvider(&fakePresence{state: PresenceState{Presenting: true}})
+
+	command := &comms.Command{
+		ID:      "cmd-deferred",
+		Type:    "info",
+		Command: "uptime",
+		Options: map[string]interface{}{"deferrable": true},
+	}
+	a.handleCommand(command)
+
+	event := waitForEvent(t, a, "command_executed")
+	if event.Data["command_id"] != command.ID || event.Data["status"] != string(comms.StatusScheduled) {
+		t.Fatalf("first deferral reported %v", event.Data)
+	}
+	if !a.presence.Deferring(deferTaskCommandPrefix + command.ID) {
+		t.Fatal("command not tracked as deferred")
+	}
+
+	// O comando volta à fila depois do intervalo de nova verificação
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if a.commandQueue.Depth() != 0 {
+		t.Fatal("command requeued before the recheck interval")
+	}
+	fake.Advance(presenceRecheckInterval)
+	for a.commandQueue.Depth() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	requeued, ok := a.commandQueue.pop()
+	if !ok || requeued.ID != command.ID {
+		t.Fatalf("deferred command not requeued: %v", requeued)
+	}
+
+	// Ainda apresentando: o segundo adiamento não repete o status scheduled
+	a.handleCommand(requeued)
+	if count := countEvents(t, a, "command_executed"); count != 1 {
+		t.Fatalf("%d command results for a command deferred twice, want 1", count)
+	}
+}
+
+func TestPresentationAssertion(t *testing.T) {
+	output := []byte(`Assertion status system-wide:
+   PreventUserIdleDisplaySleep    1
+   PreventSystemSleep             0
+Listed by owning process:
+   pid 88(coreaudiod): [0x0002] 00:10:02 PreventUserIdleSystemSleep named: "com.apple.audio.context"
+   pid 301(Safari): [0x0003] 00:01:00 PreventUserIdleDisplaySleep named: "Video Wake Lock"
+   pid 512(Keynote): [0x0001] 00:05:10 PreventUserIdleDisplaySleep named: "Presenting"
+`)
+	if app := presentationAssertion(output); app != "Keynote" {
+		t.Fatalf("presentationAssertion = %q, want Keynote", app)
+	}
+
+	// Apps fora da lista e asserções de sistema não contam
+	other := []byte(`   pid 301(Safari): [0x0003] 00:01:00 PreventUserIdleDisplaySleep named: "Video Wake Lock"
+   pid 512(Keynote): [0x0001] 00:05:10 PreventUserIdleSystemSleep named: "Exporting"
+   PreventUserIdleDisplaySleep    1
+`)
+	if app := presentationAssertion(other); app != "" {
+		t.Fatalf("presentationAssertion = %q, want none", app)
+	}
+}
+
+func TestMatchProcess(t *testing.T) {
+	output := []byte("COMM\nlaunchd\n  screensharingd\nFinder\n")
+	if process := matchProcess(output, macScreenCaptureProcesses); process != "screensharingd" {
+		t.Fatalf("matchProcess = %q, want screensharingd", process)
+	}
+	if process := matchProcess([]byte("COMM\nCptHostHelper\n"), macScreenCaptureProcesses); process != "" {
+		t.Fatalf("partial name matched as %q", process)
+	}
+}
@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var procSHQueryUserNotificationState = syscall.NewLazyDLL("shell32.dll").NewProc("SHQueryUserNotificationState")
+
+// queryUserNotificationState chama SHQueryUserNotificationState (shell32)
+func queryUserNotificationState() (int, error) {
+	if err := procSHQueryUserNotificationState.Find(); err != nil {
+		return 0, fmt.Errorf("SHQueryUserNotificationState unavailable: %w", err)
+	}
+
+	var state int32
+	hr, _, _ := procSHQueryUserNotificationState.Call(uintptr(unsafe.Pointer(&state)))
+	if hr != 0 {
+		return 0, fmt.Errorf("SHQueryUserNotificationState failed: HRESULT 0x%08x", uint32(hr))
+	}
+	return int(state), nil
+}
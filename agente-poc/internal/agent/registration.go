@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/timeutil"
+)
+
+// Políticas para um machine_id já registrado por outra máquina (409)
+const (
+	// RegistrationConflictRegenerate gera um novo machine_id (original + sufixo)
+	RegistrationConflictRegenerate = "regenerate"
+	// RegistrationConflictHalt para de enviar dados até intervenção do operador
+	RegistrationConflictHalt = "halt"
+)
+
+// Estados do registro expostos em Health()
+const (
+	RegistrationPending      = "pending"
+	RegistrationRegistered   = "registered"
+	RegistrationConflict     = "conflict"
+	RegistrationUnauthorized = "unauthorized"
+	RegistrationFailed       = "failed"
+)
+
+const (
+	// machineIDOverrideFile guarda o machine_id regenerado após um conflito
+	machineIDOverrideFile = "machine_id_override.json"
+	// maxMachineIDRegenerations limita as regenerações por execução; um
+	// conflito que persiste depois disso é tratado como halt
+	maxMachineIDRegenerations = 3
+)
+
+// machineIDOverride é a forma persistida do machine_id regenerado.
+// Só vale enquanto o machine_id original for o mesmo.
+type machineIDOverride struct {
+	OriginalMachineID string    `json:"original_machine_id"`
+	MachineID         string    `json:"machine_id"`
+	Reason            string    `json:"reason"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// RegistrationStatus descreve o último resultado do registro no backend
+type RegistrationStatus struct {
+	State             string    `json:"state"`
+	MachineID         string    `json:"machine_id"`
+	OriginalMachineID string    `json:"original_machine_id,omitempty"`
+	Severity          string    `json:"severity,omitempty"`
+	Issue             string    `json:"issue,omitempty"`
+	Remediation       string    `json:"remediation,omitempty"`
+	Attempts          int       `json:"attempts"`
+	Regenerations     int       `json:"regenerations,omitempty"`
+	LastAttempt       time.Time `json:"last_attempt,omitempty"`
+	NextAttempt       time.Time `json:"next_attempt,omitempty"`
+}
+
+// Blocking indica se o envio de inventários está suspenso
+func (s RegistrationStatus) Blocking() bool {
+	return s.State == RegistrationConflict || s.State == RegistrationUnauthorized
+}
+
+// registration guarda o estado do registro e o machine_id em uso, que pode
+// mudar em execução após um conflito
+type registration struct {
+	mu         sync.Mutex
+	status     RegistrationStatus
+	originalID string
+	retryTimer *time.Timer
+}
+
+// loadMachineIDOverride retorna o machine_id regenerado para originalID, se houver
+func loadMachineIDOverride(dataDir, originalID string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(dataDir, machineIDOverrideFile))
+	if err != nil {
+		return "", false
+	}
+
+	var override machineIDOverride
+	if err := json.Unmarshal(data, &override); err != nil {
+		return "", false
+	}
+	if override.OriginalMachineID != originalID || override.MachineID == "" {
+		return "", false
+	}
+	return override.MachineID, true
+}
+
+// saveMachineIDOverride persiste o machine_id regenerado
+func saveMachineIDOverride(dataDir string, override machineIDOverride) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal machine ID override: %w", err)
+	}
+
+	path := filepath.Join(dataDir, machineIDOverrideFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write machine ID override: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// regeneratedMachineID deriva um novo machine_id do original com sufixo aleatório
+func regeneratedMachineID(originalID string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return originalID + "-" + hex.EncodeToString(suffix), nil
+}
+
+// initRegistration resolve o machine_id em uso: o regenerado persistido para
+// o machine_id original, se existir
+func (a *Agent) initRegistration() {
+	original := a.config.MachineID
+
+	a.registration.mu.Lock()
+	defer a.registration.mu.Unlock()
+
+	a.registration.originalID = original
+	a.registration.status = RegistrationStatus{State: RegistrationPending, MachineID: original}
+
+	if id, ok := loadMachineIDOverride(a.config.DataDir, original); ok {
+		a.config.MachineID = id
+		a.registration.status.MachineID = id
+		a.registration.status.OriginalMachineID = original
+		a.logger.WithFields(map[string]interface{}{
+			"machine_id":          id,
+			"original_machine_id": original,
+		}).Info("Using machine ID regenerated after a previous registration conflict")
+	}
+}
+
+// currentMachineID retorna o machine_id em uso
+func (a *Agent) currentMachineID() string {
+	a.registration.mu.Lock()
+	defer a.registration.mu.Unlock()
+	return a.registration.status.MachineID
+}
+
+// registrationStatus retorna o último estado do registro
+func (a *Agent) registrationStatus() RegistrationStatus {
+	a.registration.mu.Lock()
+	defer a.registration.mu.Unlock()
+	return a.registration.status
+}
+
+// inventoryBlocked indica se inventórios não devem ser enviados: os dados
+// seriam atribuídos à máquina errada ou recusados pelo backend
+func (a *Agent) inventoryBlocked() bool {
+	return a.registrationStatus().Blocking()
+}
+
+// handleRegistration trata o resultado de uma tentativa de registro.
+// 409 regenera o machine_id (ou para, conforme a política); 401 suspende os
+// inventários. Nos dois casos o registro é tentado de novo em
+// RegistrationRetryInterval, assim como durante uma migração de machine_id
+// ainda não confirmada pelo backend.
+func (a *Agent) handleRegistration(err error) {
+	now := a.clock.Now()
+	migrating := a.pendingNewMachineID() != ""
+
+	a.registration.mu.Lock()
+	defer a.registration.mu.Unlock()
+
+	status := &a.registration.status
+	status.Attempts++
+	status.LastAttempt = now
+	status.NextAttempt = time.Time{}
+	previous := status.State
+
+	code := comms.HTTPStatusCode(err)
+	switch {
+	case err == nil:
+		status.State = RegistrationRegistered
+		status.Severity, status.Issue, status.Remediation = "", "", ""
+		if previous == RegistrationConflict || previous == RegistrationUnauthorized {
+			a.recordEvent(events.CategoryAlert, events.SeverityInfo, "registration_recovered",
+				"Machine registration recovered, resuming inventories",
+				map[string]interface{}{"machine_id": status.MachineID})
+		}
+		if migrating {
+			// O vínculo do novo machine_id vem na resposta do registro
+			status.NextAttempt = now.Add(a.config.RegistrationRetryInterval)
+			a.scheduleRegistrationRetryLocked()
+		}
+		return
+
+	case code == http.StatusConflict:
+		if a.config.RegistrationConflictPolicy == RegistrationConflictRegenerate &&
+			status.Regenerations < maxMachineIDRegenerations {
+			regenErr := a.regenerateMachineIDLocked()
+			if regenErr == nil {
+				go a.retryRegistration()
+				return
+			}
+			a.logger.WithField("error", regenErr).Error("Failed to regenerate machine ID")
+		}
+
+		status.State = RegistrationConflict
+		status.Severity = "critical"
+		status.Issue = fmt.Sprintf("machine ID %s is already registered by another machine", status.MachineID)
+		status.Remediation = fmt.Sprintf(
+			"This usually means the disk was cloned from another machine. Set a unique machine_id in the agent config "+
+				"(or registration_conflict_policy to %q) and restart the agent; registration is retried every %s.",
+			RegistrationConflictRegenerate, timeutil.FormatDurationHuman(a.config.RegistrationRetryInterval))
+
+	case code == http.StatusUnauthorized:
+		status.State = RegistrationUnauthorized
+		status.Severity = "critical"
+		status.Issue = "the backend rejected the agent token during registration"
+		status.Remediation = fmt.Sprintf(
+			"Check that the token in the agent config is valid and has not been revoked; "+
+				"issue a new token in the backend if needed. Registration is retried every %s.",
+			timeutil.FormatDurationHuman(a.config.RegistrationRetryInterval))
+
+	default:
+		// Falhas transitórias (rede, 5xx) não suspendem os inventários
+		status.State = RegistrationFailed
+		status.Severity = "warning"
+		status.Issue = err.Error()
+		status.Remediation = ""
+	}
+
+	status.NextAttempt = now.Add(a.config.RegistrationRetryInterval)
+	a.scheduleRegistrationRetryLocked()
+
+	if status.State != previous {
+		fields := map[string]interface{}{
+			"machine_id":   status.MachineID,
+			"error":        err,
+			"next_attempt": status.NextAttempt.Format(time.RFC3339),
+		}
+		if status.Remediation != "" {
+			fields["remediation"] = status.Remediation
+		}
+		if status.Blocking() {
+			a.recordEvent(events.CategoryAlert, events.SeverityCritical, "registration_"+status.State,
+				"Machine registration blocked, inventories suspended", fields)
+		} else {
+			a.recordEvent(events.CategoryAlert, events.SeverityWarning, "registration_"+status.State,
+				"Machine registration failed", fields)
+		}
+	}
+}
+
+// regenerateMachineIDLocked gera, persiste e aplica um novo machine_id após
+// um conflito. Chamado com registration.mu travado.
+func (a *Agent) regenerateMachineIDLocked() error {
+	status := &a.registration.status
+	original := a.registration.originalID
+
+	id, err := regeneratedMachineID(original)
+	if err != nil {
+		return err
+	}
+
+	override := machineIDOverride{
+		OriginalMachineID: original,
+		MachineID:         id,
+		Reason:            "registration conflict (409)",
+		CreatedAt:         a.clock.Now(),
+	}
+	if err := saveMachineIDOverride(a.config.DataDir, override); err != nil {
+		return err
+	}
+
+	a.events.SetMachineID(id)
+	a.recordEvent(events.CategoryIdentity, events.SeverityWarning, "machine_id_regenerated",
+		"Machine ID conflict: regenerated machine ID and re-registering",
+		map[string]interface{}{
+			"previous_machine_id": status.MachineID,
+			"machine_id":          id,
+			"original_machine_id": original,
+		})
+
+	status.MachineID = id
+	status.OriginalMachineID = original
+	status.Regenerations++
+
+	if a.inventorySeq != nil {
+		if err := a.inventorySeq.Reset(id); err != nil {
+			a.logger.WithField("error", err).Warning("Failed to persist inventory sequence state")
+		}
+	}
+	if a.comms() != nil {
+		a.comms().UpdateSystemData(id, "")
+	}
+	return nil
+}
+
+// scheduleRegistrationRetryLocked agenda a próxima tentativa de registro
+func (a *Agent) scheduleRegistrationRetryLocked() {
+	if a.registration.retryTimer != nil {
+		a.registration.retryTimer.Stop()
+	}
+	a.registration.retryTimer = time.AfterFunc(a.config.RegistrationRetryInterval, a.retryRegistration)
+}
+
+// stopRegistrationRetry cancela a tentativa agendada
+func (a *Agent) stopRegistrationRetry() {
+	a.registration.mu.Lock()
+	defer a.registration.mu.Unlock()
+
+	if a.registration.retryTimer != nil {
+		a.registration.retryTimer.Stop()
+		a.registration.retryTimer = nil
+	}
+}
+
+// retryRegistration tenta registrar a máquina novamente
+func (a *Agent) retryRegistration() {
+	if a.ctx.Err() != nil || a.comms() == nil {
+		return
+	}
+	a.checkIdentityMigration()
+	a.handleRegistration(a.comms().RegisterMachine())
+}
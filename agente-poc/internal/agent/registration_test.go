@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// registrationBackend é um backend falso que recusa com 409 os machine_ids
+// já registrados por outra máquina (todos, com claimAll) e com 401 quando o
+// token foi revogado
+type registrationBackend struct {
+	mu       sync.Mutex
+	claimed  map[string]bool
+	claimAll bool
+	revoked  bool
+	requests []string
+}
+
+func (b *registrationBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/machines/register") {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+
+	var request struct {
+		MachineID string `json:"machine_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&request)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, request.MachineID)
+	switch {
+	case b.revoked:
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"success":false,"message":"token revoked"}`))
+	case b.claimAll || b.claimed[request.MachineID]:
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"success":false,"message":"machine already registered"}`))
+	default:
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}
+}
+
+func (b *registrationBackend) setRevoked(revoked bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.revoked = revoked
+}
+
+func (b *registrationBackend) registered() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.requests...)
+}
+
+// newRegistrationTestAgent liga um agente de teste ao backend falso, com o
+// machine_id resolvido como no Start
+func newRegistrationTestAgent(t *testing.T, backend *registrationBackend, extra map[string]interface{}) *Agent {
+	t.Helper()
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+
+	config := map[string]interface{}{
+		"backend_url":   server.URL,
+		"websocket_url": "ws" + strings.TrimPrefix(server.URL, "http") + "/ws",
+	}
+	for key, value := range extra {
+		config[key] = value
+	}
+	a, _ := newTestAgent(t, config)
+	a.initRegistration()
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+	t.Cleanup(a.stopRegistrationRetry)
+	return a
+}
+
+// waitForRegistration aguarda o registro chegar ao estado informado
+func waitForRegistration(t *testing.T, a *Agent, state string) RegistrationStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := a.registrationStatus(); status.State == state {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("registration state %q, want %q", a.registrationStatus().State, state)
+	return RegistrationStatus{}
+}
+
+func TestRegistrationClonedMachineRegenerates(t *testing.T) {
+	backend := &registrationBackend{claimed: map[string]bool{"test-machine": true}}
+	dataDir := t.TempDir()
+	a := newRegistrationTestAgent(t, backend, map[string]interface{}{"data_dir": dataDir})
+
+	a.handleRegistration(a.comms().RegisterMachine())
+	status := waitForRegistration(t, a, RegistrationRegistered)
+
+	if !strings.HasPrefix(status.MachineID, "test-machine-") || status.OriginalMachineID != "test-machine" {
+		t.Fatalf("registered as %q (original %q)", status.MachineID, status.OriginalMachineID)
+	}
+	if status.Regenerations != 1 || a.inventoryBlocked() {
+		t.Fatalf("status after regeneration: %+v", status)
+	}
+	if requests := backend.registered(); len(requests) != 2 || requests[1] != status.MachineID {
+		t.Fatalf("registration requests %v", requests)
+	}
+	event := waitForEvent(t, a, "machine_id_regenerated")
+	if event.Data["previous_machine_id"] != "test-machine" || event.Data["machine_id"] != status.MachineID {
+		t.Fatalf("machine_id_regenerated data = %v", event.Data)
+	}
+
+	// Um novo processo reutiliza o machine_id regenerado
+	restarted := newRegistrationTestAgent(t, backend, map[string]interface{}{"data_dir": dataDir})
+	if id := restarted.currentMachineID(); id != status.MachineID {
+		t.Fatalf("machine ID after restart = %q, want %q", id, status.MachineID)
+	}
+	restarted.handleRegistration(restarted.comms().RegisterMachine())
+	if state := restarted.registrationStatus().State; state != RegistrationRegistered {
+		t.Fatalf("registration after restart = %s", state)
+	}
+}
+
+func TestRegistrationConflictHalt(t *testing.T) {
+	backend := &registrationBackend{claimed: map[string]bool{"test-machine": true}}
+	a := newRegistrationTestAgent(t, backend, map[string]interface{}{
+		"registration_conflict_policy": RegistrationConflictHalt,
+	})
+
+	a.handleRegistration(a.comms().RegisterMachine())
+	status := a.registrationStatus()
+	if status.State != RegistrationConflict || status.MachineID != "test-machine" || status.Severity != "critical" {
+		t.Fatalf("status after conflict with halt policy: %+v", status)
+	}
+	if !a.inventoryBlocked() {
+		t.Fatal("inventories not suspended on a machine ID conflict")
+	}
+	if !strings.Contains(status.Remediation, "cloned") || status.NextAttempt.IsZero() {
+		t.Fatalf("remediation %q, next attempt %s", status.Remediation, status.NextAttempt)
+	}
+	event := waitForEvent(t, a, "registration_conflict")
+	if event.Data["remediation"] != status.Remediation {
+		t.Fatalf("registration_conflict data = %v", event.Data)
+	}
+
+	health := a.Health()["registration"].(RegistrationStatus)
+	if health.State != RegistrationConflict || health.Issue == "" {
+		t.Fatalf("Health() registration = %+v", health)
+	}
+
+	// Nova tentativa no mesmo estado não repete o alerta
+	a.handleRegistration(a.comms().RegisterMachine())
+	if n := countEvents(t, a, "registration_conflict"); n != 1 {
+		t.Fatalf("registration_conflict recorded %d times", n)
+	}
+}
+
+func TestRegistrationRegenerationCap(t *testing.T) {
+	// Todo machine_id já está registrado: após o limite, o agente para
+	backend := &registrationBackend{claimAll: true}
+	a := newRegistrationTestAgent(t, backend, nil)
+
+	a.handleRegistration(a.comms().RegisterMachine())
+	status := waitForRegistration(t, a, RegistrationConflict)
+	if status.Regenerations != maxMachineIDRegenerations {
+		t.Fatalf("%d regenerations before halting, want %d", status.Regenerations, maxMachineIDRegenerations)
+	}
+	if n := countEvents(t, a, "machine_id_regenerated"); n != maxMachineIDRegenerations {
+		t.Fatalf("machine_id_regenerated recorded %d times", n)
+	}
+	if requests := backend.registered(); len(requests) != maxMachineIDRegenerations+1 {
+		t.Fatalf("%d registration attempts, want %d", len(requests), maxMachineIDRegenerations+1)
+	}
+}
+
+func TestRegistrationRevokedToken(t *testing.T) {
+	backend := &registrationBackend{revoked: true}
+	a := newRegistrationTestAgent(t, backend, nil)
+
+	a.handleRegistration(a.comms().RegisterMachine())
+	status := a.registrationStatus()
+	if status.State != RegistrationUnauthorized || status.Severity != "critical" || !a.inventoryBlocked() {
+		t.Fatalf("status after 401: %+v", status)
+	}
+	if status.MachineID != "test-machine" || status.Regenerations != 0 {
+		t.Fatalf("401 changed the machine ID: %+v", status)
+	}
+	if !strings.Contains(status.Remediation, "revoked") {
+		t.Fatalf("remediation %q", status.Remediation)
+	}
+	waitForEvent(t, a, "registration_unauthorized")
+
+	// Token reemitido: a próxima tentativa retoma os inventários
+	backend.setRevoked(false)
+	a.retryRegistration()
+	if status := a.registrationStatus(); status.State != RegistrationRegistered || a.inventoryBlocked() {
+		t.Fatalf("status after the token was restored: %+v", status)
+	}
+	waitForEvent(t, a, "registration_recovered")
+}
+
+func TestRegistrationTransientFailureDoesNotBlock(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	a.initRegistration()
+	t.Cleanup(a.stopRegistrationRetry)
+
+	a.handleRegistration(errors.New("failed to register machine: dial tcp 127.0.0.1:1: connection refused"))
+	status := a.registrationStatus()
+	if status.State != RegistrationFailed || status.Severity != "warning" || a.inventoryBlocked() {
+		t.Fatalf("status after a network failure: %+v", status)
+	}
+	waitForEvent(t, a, "registration_failed")
+}
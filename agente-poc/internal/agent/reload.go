@@ -0,0 +1,456 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"agente-poc/internal/events"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
+)
+
+// Origem de uma recarga da configuração
+const (
+	reloadSourceFile    = "file"    // SIGHUP: arquivo relido
+	reloadSourceBackend = "backend" // config_update com o bloco "agent"
+)
+
+// configUpdateAgentKey é o bloco de config_update com ajustes da
+// configuração do agente; campos ausentes mantêm o valor vigente
+const configUpdateAgentKey = "agent"
+
+// runtimeConfigKeys são os campos aplicados em execução, sem reiniciar
+var runtimeConfigKeys = map[string]bool{
+	"log_level":               true,
+	"heartbeat_interval":      true,
+	"collection_interval":     true,
+	"command_timeout":         true,
+	"max_concurrent_commands": true,
+	"schedules":               true,
+	"alert_rules":             true,
+	"script_public_keys":      true,
+	"whitelist_public_keys":   true,
+	"upload_rate_limit":       true,
+	"inventory_send_window":   true,
+}
+
+// connectionConfigKeys são os campos que recriam o communications manager
+var connectionConfigKeys = map[string]bool{
+	"backend_url":          true,
+	"websocket_url":        true,
+	"backend_urls":         true,
+	"websocket_urls":       true,
+	"failover_threshold":   true,
+	"token":                true,
+	"tokens":               true,
+	"tls_client_cert_file": true,
+	"tls_client_key_file":  true,
+	"tls_ca_cert_file":     true,
+	"tls_skip_verify":      true,
+	"pinned_certificates":  true,
+	"proxy_url":            true,
+}
+
+// agentConfigDelta é o bloco "agent" de um config_update: só os campos
+// recarregáveis, com os mesmos nomes e formatos do arquivo
+type agentConfigDelta struct {
+	LogLevel              *string           `json:"log_level"`
+	HeartbeatInterval     *timeutil.Seconds `json:"heartbeat_interval"`
+	CollectionInterval    *timeutil.Seconds `json:"collection_interval"`
+	CommandTimeout        *timeutil.Seconds `json:"command_timeout"`
+	MaxConcurrentCommands *int              `json:"max_concurrent_commands"`
+	BackendURL            *string           `json:"backend_url"`
+	WebSocketURL          *string           `json:"websocket_url"`
+	Token                 *string           `json:"token"`
+	Tokens                []string          `json:"tokens"`
+	UploadRateLimit       *int64            `json:"upload_rate_limit"`
+	InventorySendWindow   *string           `json:"inventory_send_window"`
+}
+
+// apply copia para c os campos presentes no delta
+func (d *agentConfigDelta) apply(c *Config) {
+	if d.LogLevel != nil {
+		c.LogLevel = *d.LogLevel
+	}
+	if d.HeartbeatInterval != nil {
+		c.HeartbeatInterval = d.HeartbeatInterval.Duration()
+	}
+	if d.CollectionInterval != nil {
+		c.CollectionInterval = d.CollectionInterval.Duration()
+	}
+	if d.CommandTimeout != nil {
+		c.CommandTimeout = d.CommandTimeout.Duration()
+	}
+	if d.MaxConcurrentCommands != nil {
+		c.MaxConcurrentCommands = *d.MaxConcurrentCommands
+	}
+	if d.BackendURL != nil {
+		c.BackendURL = *d.BackendURL
+	}
+	if d.WebSocketURL != nil {
+		c.WebSocketURL = *d.WebSocketURL
+	}
+	if d.Token != nil {
+		c.Token = *d.Token
+	}
+	if d.Tokens != nil {
+		c.Tokens = d.Tokens
+	}
+	if d.UploadRateLimit != nil {
+		c.UploadRateLimit = *d.UploadRateLimit
+	}
+	if d.InventorySendWindow != nil {
+		c.InventorySendWindow = *d.InventorySendWindow
+	}
+}
+
+// ReloadStatus descreve as recargas da configuração para o health
+type ReloadStatus struct {
+	Count       int64     `json:"count"`
+	Failures    int64     `json:"failures"`
+	LastReload  time.Time `json:"last_reload"`
+	LastSource  string    `json:"last_source,omitempty"`
+	LastChanged []string  `json:"last_changed,omitempty"`
+	// RestartRequired são campos alterados na última recarga que só valem
+	// após reiniciar o agente
+	RestartRequired []string `json:"restart_required,omitempty"`
+	LastError       string   `json:"last_error,omitempty"`
+}
+
+// reloadState acumula o resultado das recargas
+type reloadState struct {
+	mu     sync.Mutex
+	status ReloadStatus
+}
+
+// record registra uma recarga aplicada (err nil) ou recusada
+func (r *reloadState) record(now time.Time, source string, changed, restart []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.LastReload = now
+	r.status.LastSource = source
+	if err != nil {
+		r.status.Failures++
+		r.status.LastError = err.Error()
+		return
+	}
+	r.status.Count++
+	r.status.LastChanged = changed
+	r.status.RestartRequired = restart
+	r.status.LastError = ""
+}
+
+// Status retorna uma cópia do estado das recargas
+func (r *reloadState) Status() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Reload aplica a configuração relida do arquivo (SIGHUP). Nível de log,
+// intervalos, timeout e limite de comandos, limite de upload, janela de
+// envio e regras de alerta valem na hora; troca de backend
+// ou token recria o communications manager; os demais campos só valem
+// após reiniciar o agente.
+func (a *Agent) Reload(next *Config) error {
+	return a.applyConfig(next, reloadSourceFile)
+}
+
+// applyAgentConfigUpdate aplica o bloco "agent" de um config_update sobre a
+// configuração vigente
+func (a *Agent) applyAgentConfigUpdate(block interface{}) {
+	var delta agentConfigDelta
+	raw, err := json.Marshal(block)
+	if err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&delta)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid agent settings in configuration update: %w", err)
+		a.reloads.record(a.clock.Now(), reloadSourceBackend, nil, nil, err)
+		a.logger.WithField("error", err).Warning("Configuration update rejected")
+		return
+	}
+
+	a.mu.RLock()
+	next := *a.config
+	a.mu.RUnlock()
+
+	delta.apply(&next)
+	// Recusas ficam no evento config_reload_failed e no health
+	_ = a.applyConfig(&next, reloadSourceBackend)
+}
+
+// applyConfig valida next e aplica as diferenças em relação à configuração
+// vigente. Uma falha ao recriar o communications manager mantém a
+// configuração anterior inteira.
+func (a *Agent) applyConfig(next *Config, source string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.applyConfigLocked(next, source)
+	if err != nil {
+		a.reloads.record(a.clock.Now(), source, nil, nil, err)
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "config_reload_failed",
+			"Configuration reload rejected",
+			map[string]interface{}{"source": source, "error": err})
+	}
+	return err
+}
+
+// applyConfigLocked faz o trabalho de applyConfig; chamado com a.mu travado
+func (a *Agent) applyConfigLocked(next *Config, source string) error {
+	switch a.state {
+	case StateRunning, StateObserver:
+	default:
+		return fmt.Errorf("agent is not running: %s", a.state)
+	}
+
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("configuração inválida: %w", err)
+	}
+	next.ApplyDefaults()
+
+	var changed, restart []string
+	reconnect := false
+	for _, key := range changedConfigKeys(a.config, next) {
+		switch {
+		case runtimeConfigKeys[key]:
+			changed = append(changed, key)
+		case connectionConfigKeys[key]:
+			changed = append(changed, key)
+			reconnect = true
+		case key == "machine_id" && next.MachineID == "":
+			// machine_id gerado em execução; o arquivo continua sem ele
+		case key == "enrollment_key":
+			// usada só no primeiro início e descartada depois do enrollment
+		default:
+			restart = append(restart, key)
+		}
+	}
+
+	// Certificados renovados no mesmo caminho também recriam a conexão
+	if !reconnect {
+		rotated := changedTLSFiles(a.tlsDigests, tlsFileDigests(next))
+		if len(rotated) > 0 {
+			changed = append(changed, rotated...)
+			sort.Strings(changed)
+			reconnect = true
+		}
+	}
+
+	if reconnect {
+		previous := *a.config
+		previousDigests := a.tlsDigests
+		a.config.BackendURL = next.BackendURL
+		a.config.WebSocketURL = next.WebSocketURL
+		a.config.BackendURLs = next.BackendURLs
+		a.config.WebSocketURLs = next.WebSocketURLs
+		a.config.FailoverThreshold = next.FailoverThreshold
+		a.config.Token = next.Token
+		a.config.Tokens = next.Tokens
+		a.config.TLSClientCertFile = next.TLSClientCertFile
+		a.config.TLSClientKeyFile = next.TLSClientKeyFile
+		a.config.TLSCACertFile = next.TLSCACertFile
+		a.config.TLSSkipVerify = next.TLSSkipVerify
+		a.config.PinnedCertificates = next.PinnedCertificates
+		a.config.ProxyURL = next.ProxyURL
+		if err := a.rebuildComms(); err != nil {
+			a.config.BackendURL = previous.BackendURL
+			a.config.WebSocketURL = previous.WebSocketURL
+			a.config.BackendURLs = previous.BackendURLs
+			a.config.WebSocketURLs = previous.WebSocketURLs
+			a.config.FailoverThreshold = previous.FailoverThreshold
+			a.config.Token = previous.Token
+			a.config.Tokens = previous.Tokens
+			a.config.TLSClientCertFile = previous.TLSClientCertFile
+			a.config.TLSClientKeyFile = previous.TLSClientKeyFile
+			a.config.TLSCACertFile = previous.TLSCACertFile
+			a.config.TLSSkipVerify = previous.TLSSkipVerify
+			a.config.PinnedCertificates = previous.PinnedCertificates
+			a.config.ProxyURL = previous.ProxyURL
+			a.tlsDigests = previousDigests
+			return fmt.Errorf("failed to reconnect with new configuration: %w", err)
+		}
+	}
+
+	if next.LogLevel != a.config.LogLevel {
+		a.config.LogLevel = next.LogLevel
+		a.logger.SetLevel(logging.ParseLogLevel(next.LogLevel))
+	}
+
+	if next.HeartbeatInterval != a.config.HeartbeatInterval {
+		a.config.HeartbeatInterval = next.HeartbeatInterval
+		if a.comms() != nil {
+			a.comms().SetHeartbeatInterval(next.HeartbeatInterval)
+		}
+	}
+
+	if next.CollectionInterval != a.config.CollectionInterval {
+		a.config.CollectionInterval = next.CollectionInterval
+		// Só o valor mais recente interessa ao runCollector
+		select {
+		case <-a.collectionReset:
+		default:
+		}
+		a.collectionReset <- next.CollectionInterval
+	}
+
+	if next.CommandTimeout != a.config.CommandTimeout || next.MaxConcurrentCommands != a.config.MaxConcurrentCommands {
+		a.config.CommandTimeout = next.CommandTimeout
+		a.config.MaxConcurrentCommands = next.MaxConcurrentCommands
+		if a.executor != nil {
+			a.executor.SetLimits(next.CommandTimeout, next.MaxConcurrentCommands)
+		}
+	}
+
+	if !reflect.DeepEqual(next.Schedules, a.config.Schedules) {
+		a.config.Schedules = next.Schedules
+		if a.scheduler != nil {
+			// Já validados em next.Validate
+			_ = a.scheduler.SetSchedules(scheduleSourceConfig, next.Schedules)
+		}
+	}
+
+	if !reflect.DeepEqual(next.AlertRules, a.config.AlertRules) {
+		a.config.AlertRules = next.AlertRules
+		if a.alerts != nil {
+			a.alerts.SetAlertRules(monitorAlertRules(next.AlertRules))
+		}
+	}
+
+	if next.UploadRateLimit != a.config.UploadRateLimit {
+		a.config.UploadRateLimit = next.UploadRateLimit
+		if a.comms() != nil {
+			a.comms().SetUploadRate(next.UploadRateLimit)
+		}
+	}
+
+	if next.InventorySendWindow != a.config.InventorySendWindow {
+		a.config.InventorySendWindow = next.InventorySendWindow
+		if a.comms() != nil {
+			a.comms().SetSendWindow(a.inventoryWindow())
+		}
+	}
+
+	if !reflect.DeepEqual(next.ScriptPublicKeys, a.config.ScriptPublicKeys) {
+		a.config.ScriptPublicKeys = next.ScriptPublicKeys
+		if a.executor != nil {
+			// Já validadas em next.Validate
+			_ = a.executor.SetScriptKeys(next.ScriptPublicKeys)
+		}
+	}
+
+	if !reflect.DeepEqual(next.WhitelistPublicKeys, a.config.WhitelistPublicKeys) {
+		a.config.WhitelistPublicKeys = next.WhitelistPublicKeys
+		if a.executor != nil {
+			// Já validadas em next.Validate
+			_ = a.executor.SetWhitelistKeys(next.WhitelistPublicKeys)
+		}
+	}
+
+	a.reloads.record(a.clock.Now(), source, changed, restart, nil)
+	fields := map[string]interface{}{"source": source}
+	if len(changed) > 0 {
+		fields["changed"] = changed
+	}
+	if len(restart) > 0 {
+		fields["restart_required"] = restart
+	}
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "config_reloaded", "Configuration reloaded", fields)
+	return nil
+}
+
+// rebuildComms troca o communications manager por um criado com a
+// configuração vigente. O novo é criado antes de parar o antigo, para que
+// uma falha mantenha a conexão atual, e herda a fila offline em memória do
+// antigo: o arquivo da fila não é relido enquanto o antigo ainda grava nele,
+// e o que o antigo devolve à fila ao parar (resultados sem ack, reenvios
+// interrompidos) chega ao novo. Sem manager (modo observador) não há o que
+// recriar.
+func (a *Agent) rebuildComms() error {
+	previous := a.comms()
+	if previous == nil {
+		return nil
+	}
+
+	manager, err := a.newComms(previous.OfflineQueue())
+	if err != nil {
+		return err
+	}
+	if machineID := a.currentMachineID(); machineID != "" && machineID != a.config.MachineID {
+		manager.UpdateSystemData(machineID, "")
+	}
+
+	// O antigo para antes de o novo começar a esvaziar a fila
+	if err := previous.Stop(); err != nil {
+		a.logger.WithField("error", err).Warning("Failed to stop communications manager")
+	}
+	a.commsManager.Store(manager)
+	a.logger.WithField("backend_url", a.config.BackendURL).Info("Reconnecting to backend with new configuration")
+	return manager.Start(a.ctx)
+}
+
+// changedTLSFiles retorna, em ordem alfabética, os campos de mTLS cujo
+// arquivo mudou de conteúdo
+func changedTLSFiles(current, next map[string]string) []string {
+	var changed []string
+	for key, digest := range next {
+		if current[key] != digest {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// changedConfigKeys retorna, em ordem alfabética, os campos (nomes JSON)
+// com valores diferentes entre as duas configurações
+func changedConfigKeys(current, next *Config) []string {
+	currentFields := configFields(current)
+	nextFields := configFields(next)
+
+	var changed []string
+	for key, value := range nextFields {
+		if !reflect.DeepEqual(currentFields[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// configFields serializa a configuração como mapa campo -> valor JSON
+func configFields(c *Config) map[string]interface{} {
+	fields := make(map[string]interface{})
+	raw, _ := json.Marshal(c)
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+// appliedConfig descreve os campos recarregáveis vigentes para o health
+// (intervalos em segundos; tokens omitidos)
+func (a *Agent) appliedConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"log_level":               a.config.LogLevel,
+		"heartbeat_interval":      a.config.HeartbeatInterval.Seconds(),
+		"collection_interval":     a.config.CollectionInterval.Seconds(),
+		"command_timeout":         a.config.CommandTimeout.Seconds(),
+		"max_concurrent_commands": a.config.MaxConcurrentCommands,
+		"upload_rate_limit":       a.config.UploadRateLimit,
+		"inventory_send_window":   a.config.InventorySendWindow,
+		"script_public_keys":      len(a.config.ScriptPublicKeys),
+		"whitelist_public_keys":   len(a.config.WhitelistPublicKeys),
+		"tls_client_certificate":  a.config.TLSClientCertFile != "",
+		"proxy_url":               redactProxyURL(a.config.ProxyURL),
+		"backend_url":             a.config.BackendURL,
+		"websocket_url":           a.config.WebSocketURL,
+	}
+}
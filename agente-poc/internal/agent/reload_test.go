@@ -0,0 +1,381 @@
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/executor"
+)
+
+// recordingClock é o relógio falso que registra o período de cada ticker
+// criado, para o teste saber quando um loop trocou de intervalo
+type recordingClock struct {
+	*clock.Fake
+
+	mu      sync.Mutex
+	periods []time.Duration
+}
+
+func (c *recordingClock) NewTicker(d time.Duration) clock.Ticker {
+	c.mu.Lock()
+	c.periods = append(c.periods, d)
+	c.mu.Unlock()
+	return c.Fake.NewTicker(d)
+}
+
+// waitTicker aguarda a criação de um ticker com o período informado
+func (c *recordingClock) waitTicker(t *testing.T, period time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		found := slices.Contains(c.periods, period)
+		c.mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.Fatalf("no ticker with period %s; created %v", period, c.periods)
+}
+
+// newRunningTestAgent é o newTestAgent com relógio que registra tickers, já
+// no estado em que aceita recargas
+func newRunningTestAgent(t *testing.T, extra map[string]interface{}) (*Agent, *recordingClock) {
+	t.Helper()
+	a, fake := newTestAgent(t, extra)
+	clk := &recordingClock{Fake: fake}
+	a.SetClock(clk)
+	a.state = StateRunning
+	return a, clk
+}
+
+func TestReloadCollectionIntervalMidRun(t *testing.T) {
+	a, clk := newRunningTestAgent(t, nil)
+
+	a.wg.Add(1)
+	go a.runCollector(a.config.CollectionInterval)
+	t.Cleanup(func() {
+		a.cancel()
+		a.wg.Wait()
+	})
+	clk.waitTicker(t, time.Minute)
+
+	a.applyAgentConfigUpdate(map[string]interface{}{"collection_interval": 5})
+	clk.waitTicker(t, 5*time.Second)
+
+	if a.config.CollectionInterval != 5*time.Second {
+		t.Fatalf("collection interval = %s", a.config.CollectionInterval)
+	}
+	// O ticker antigo foi parado: só o novo continua ativo
+	if pending := clk.Pending(); pending != 1 {
+		t.Fatalf("%d tickers active after the interval change, want 1", pending)
+	}
+	status := a.reloads.Status()
+	if status.Count != 1 || status.LastSource != reloadSourceBackend || !slices.Contains(status.LastChanged, "collection_interval") {
+		t.Fatalf("reload status = %+v", status)
+	}
+}
+
+func TestReloadHeartbeatIntervalMidRun(t *testing.T) {
+	var heartbeats atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, comms.EndpointHeartbeat) {
+			heartbeats.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer backend.Close()
+	t.Setenv("HTTP_PROXY", "")
+
+	a, clk := newRunningTestAgent(t, map[string]interface{}{
+		"backend_url":   backend.URL,
+		"websocket_url": "ws" + strings.TrimPrefix(backend.URL, "http") + "/ws",
+	})
+	// O heartbeat inclui a amostra de saúde, criada no Start
+	a.collector = collector.New(a.config.CollectionInterval, a.logger)
+	t.Cleanup(func() { _ = a.collector.Close() })
+	a.health = newHealthSampler(a.collector, a.config.HealthThresholds, a.logger, clk)
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+	if err := manager.Start(a.ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = manager.Stop() }()
+	clk.waitTicker(t, 30*time.Second)
+
+	a.applyAgentConfigUpdate(map[string]interface{}{"heartbeat_interval": 10})
+	clk.waitTicker(t, 10*time.Second)
+	if manager.HeartbeatInterval() != 10*time.Second {
+		t.Fatalf("manager heartbeat interval = %s", manager.HeartbeatInterval())
+	}
+
+	// 10s bastam com o novo intervalo; com o antigo (30s) nada seria enviado
+	before := heartbeats.Load()
+	clk.Advance(10 * time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for heartbeats.Load() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat sent 10s after switching to a 10s interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRebuildCommsHandsOverOfflineQueue(t *testing.T) {
+	a, _ := newRunningTestAgent(t, nil)
+	previous, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(previous)
+
+	queue := previous.OfflineQueue()
+	if err := queue.Enqueue(comms.QueuedMessage{
+		ID:       "queued-1",
+		Type:     "heartbeat",
+		Method:   http.MethodPost,
+		Endpoint: comms.EndpointHeartbeat,
+		Data:     map[string]interface{}{"machine_id": "test-machine"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Um novo manager que relesse o arquivo veria esta fila vazia
+	queuePath := filepath.Join(a.config.DataDir, "offline_queue.json")
+	if err := os.WriteFile(queuePath, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a.applyAgentConfigUpdate(map[string]interface{}{"backend_url": "http://127.0.0.1:2"})
+	current := a.comms()
+	t.Cleanup(func() { _ = current.Stop() })
+
+	if current == previous {
+		t.Fatalf("communications manager not rebuilt: %+v", a.reloads.Status())
+	}
+	if current.OfflineQueue() != queue {
+		t.Fatal("new manager did not take over the offline queue")
+	}
+	if size := queue.Size(); size != 1 {
+		t.Fatalf("offline queue has %d messages after the rebuild, want 1", size)
+	}
+}
+
+func TestReloadInvalidBackendKeepsManager(t *testing.T) {
+	a, _ := newRunningTestAgent(t, nil)
+	previous, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(previous)
+
+	a.applyAgentConfigUpdate(map[string]interface{}{"backend_url": "not a url"})
+	if a.comms() != previous {
+		t.Fatal("invalid backend_url replaced the communications manager")
+	}
+	if status := a.reloads.Status(); status.Failures != 1 || status.LastError == "" {
+		t.Fatalf("reload status = %+v", status)
+	}
+}
+
+func TestReloadUploadRateAndSendWindow(t *testing.T) {
+	a, _ := newRunningTestAgent(t, nil)
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(manager)
+	t.Cleanup(func() { _ = manager.Stop() })
+
+	// Limite e janela valem no manager em uso, sem recriá-lo
+	next := *a.config
+	next.UploadRateLimit = 64 * 1024
+	next.InventorySendWindow = "01:00-05:00"
+	if err := a.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if a.comms() != manager {
+		t.Fatal("communications manager rebuilt for a runtime setting")
+	}
+	if rate, window := manager.UploadRate(), manager.SendWindow().String(); rate != 64*1024 || window != "01:00-05:00" {
+		t.Fatalf("upload rate %d, send window %q", rate, window)
+	}
+	applied := a.appliedConfig()
+	if applied["upload_rate_limit"] != int64(64*1024) || applied["inventory_send_window"] != "01:00-05:00" {
+		t.Fatalf("applied config = %v", applied)
+	}
+
+	// Zerar remove o limite e a janela
+	next.UploadRateLimit = 0
+	next.InventorySendWindow = ""
+	if err := a.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if manager.UploadRate() != 0 || manager.SendWindow() != nil {
+		t.Fatalf("upload rate %d, send window %v after clearing", manager.UploadRate(), manager.SendWindow())
+	}
+}
+
+func TestReloadScriptKeys(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("scripts run under /bin/sh")
+	}
+	a, _ := newRunningTestAgent(t, nil)
+	execConfig := a.config.ExecutorConfig(a.logger)
+	execConfig.OnSecurityEvent = a.recordSecurityEvent
+	e, err := executor.New(execConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.executor = e
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const script = "echo ok\n"
+	expiresAt := time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+	command := &comms.Command{ID: "cmd-script", Type: "script", Options: map[string]interface{}{
+		"script":     script,
+		"expires_at": expiresAt,
+		"signature":  base64.StdEncoding.EncodeToString(ed25519.Sign(private, executor.ScriptSigningPayload("cmd-script", expiresAt, "/bin/sh", script))),
+	}}
+
+	// Sem chaves: recusado e reportado como evento de segurança
+	if result, _ := a.executor.Execute(context.Background(), command); result.Status != comms.StatusRejected {
+		t.Fatalf("script without keys: %s", result.Status)
+	}
+	event := waitForEvent(t, a, "script_rejected")
+	if event.Category != events.CategorySecurity || event.Data["error_code"] != string(comms.ErrCodeScriptsDisabled) {
+		t.Fatalf("security event = %+v", event)
+	}
+
+	// A chave nova vale no reload, sem reiniciar o executor
+	next := *a.config
+	next.ScriptPublicKeys = []string{base64.StdEncoding.EncodeToString(public)}
+	if err := a.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := a.executor.Execute(context.Background(), command); err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("script after the reload: %+v, %v", result, err)
+	}
+}
+
+// writeClientCertificate grava em dir um certificado autoassinado e a chave
+// em PEM, nos nomes fixos client.pem e client-key.pem
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "test-machine"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestReloadRotatesTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCertificate(t, dir)
+	a, _ := newRunningTestAgent(t, map[string]interface{}{
+		"backend_url":          "https://127.0.0.1:1",
+		"websocket_url":        "wss://127.0.0.1:1",
+		"tls_client_cert_file": certFile,
+		"tls_client_key_file":  keyFile,
+	})
+	previous, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.commsManager.Store(previous)
+
+	// Mesmos arquivos, mesmo conteúdo: nada a recriar
+	next := *a.config
+	if err := a.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	if a.comms() != previous {
+		t.Fatal("reload without changes rebuilt the communications manager")
+	}
+
+	// Certificado renovado no mesmo caminho recria a conexão
+	writeClientCertificate(t, dir)
+	next = *a.config
+	if err := a.Reload(&next); err != nil {
+		t.Fatal(err)
+	}
+	current := a.comms()
+	t.Cleanup(func() { _ = current.Stop() })
+	if current == previous {
+		t.Fatalf("rotated certificate did not rebuild the manager: %+v", a.reloads.Status())
+	}
+	if status := a.reloads.Status(); !slices.Contains(status.LastChanged, "tls_client_cert_file") || !slices.Contains(status.LastChanged, "tls_client_key_file") {
+		t.Fatalf("reload status = %+v", status)
+	}
+
+	// Chave que não corresponde ao certificado: recusado, manager mantido
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	next = *a.config
+	if err := a.Reload(&next); err == nil {
+		t.Fatal("invalid key accepted on reload")
+	}
+	if a.comms() != current {
+		t.Fatal("invalid key replaced the communications manager")
+	}
+}
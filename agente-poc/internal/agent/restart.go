@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// RestartExitCode é o código de saída que pede ao supervisor (launchd/systemd)
+// para reiniciar o agente (EX_TEMPFAIL)
+const RestartExitCode = 75
+
+// restartResultDelay dá tempo para o resultado do comando sair antes do restart
+const restartResultDelay = 2 * time.Second
+
+// RestartRequest representa um pedido de restart do agente
+type RestartRequest struct {
+	Reason    string    `json:"reason"`
+	CommandID string    `json:"command_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// shutdownRecord é gravado no diretório de dados antes de reiniciar
+type shutdownRecord struct {
+	RestartRequest
+	Supervised bool   `json:"supervised"`
+	Supervisor string `json:"supervisor,omitempty"`
+	PID        int    `json:"pid"`
+}
+
+// Gerenciadores de serviço reconhecidos por DetectSupervisor
+const (
+	SupervisorSystemd = "systemd"
+	SupervisorLaunchd = "launchd"
+	SupervisorSCM     = "scm"
+	// SupervisorForced indica AGENTE_SUPERVISED=1 sem outro sinal
+	SupervisorForced = "forced"
+)
+
+// DetectSupervisor retorna o gerenciador de serviços que controla o processo
+// ("" em modo standalone). AGENTE_SUPERVISED=1/0 força o resultado.
+func DetectSupervisor() string {
+	return detectSupervisor(os.Getenv, isWindowsService)
+}
+
+// RunningUnderSupervisor indica se o processo é gerenciado por
+// systemd, launchd ou pelo SCM do Windows
+func RunningUnderSupervisor() bool {
+	return DetectSupervisor() != ""
+}
+
+// detectSupervisor procura sinais explícitos de cada gerenciador; ter o PID 1
+// como pai não basta, pois processos órfãos também são adotados por ele
+func detectSupervisor(getenv func(string) string, windowsService func() bool) string {
+	override := getenv("AGENTE_SUPERVISED")
+	if override == "0" || override == "false" {
+		return ""
+	}
+
+	switch {
+	// systemd define INVOCATION_ID para unidades gerenciadas
+	case getenv("INVOCATION_ID") != "":
+		return SupervisorSystemd
+	// launchd exporta o label do job em XPC_SERVICE_NAME; sessões do Terminal
+	// usam "0" ou o prefixo "application."
+	case launchdJob(getenv("XPC_SERVICE_NAME")):
+		return SupervisorLaunchd
+	case windowsService():
+		return SupervisorSCM
+	case override == "1" || override == "true":
+		return SupervisorForced
+	}
+	return ""
+}
+
+// launchdJob indica se XPC_SERVICE_NAME é o label de um job do launchd
+func launchdJob(name string) bool {
+	return name != "" && name != "0" && !strings.HasPrefix(name, "application.")
+}
+
+// RestartRequests retorna o canal onde pedidos de restart são publicados
+func (a *Agent) RestartRequests() <-chan RestartRequest {
+	return a.restartChan
+}
+
+// handleRestartCommand responde ao comando restart_agent e agenda o restart.
+// O resultado é enviado antes do pedido de restart (best-effort).
+func (a *Agent) handleRestartCommand(command *comms.Command) {
+	mode := "exec"
+	if supervisor := DetectSupervisor(); supervisor != "" {
+		mode = "supervisor:" + supervisor
+	}
+
+	result := &comms.CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    comms.StatusSuccess,
+		Output:    fmt.Sprintf("restart scheduled (mode: %s)", mode),
+		Timestamp: time.Now(),
+	}
+	a.sendCommandResult(result)
+
+	go func() {
+		select {
+		case <-time.After(restartResultDelay):
+		case <-a.ctx.Done():
+			return
+		}
+
+		select {
+		case a.restartChan <- RestartRequest{Reason: "restart_agent", CommandID: command.ID, Timestamp: time.Now()}:
+		default:
+			a.logger.Warning("Restart already pending, ignoring request")
+		}
+	}()
+}
+
+// WriteShutdownReason registra o motivo do encerramento em dataDir/last_shutdown.json
+func WriteShutdownReason(dataDir string, request RestartRequest, supervisor string) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(shutdownRecord{
+		RestartRequest: request,
+		Supervised:     supervisor != "",
+		Supervisor:     supervisor,
+		PID:            os.Getpid(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal shutdown reason: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(dataDir, "last_shutdown.json"), data, 0600)
+}
+
+// ExecSelf inicia uma nova instância do agente com os mesmos argumentos
+func ExecSelf() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to resolve executable: %w", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start new process: %w", err)
+	}
+	return nil
+}
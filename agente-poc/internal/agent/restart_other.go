@@ -0,0 +1,8 @@
+//go:build !windows
+
+package agent
+
+// isWindowsService só existe no Windows
+func isWindowsService() bool {
+	return false
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// fakeEnv simula o ambiente de um supervisor
+func fakeEnv(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestDetectSupervisor(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		windowsService bool
+		want           string
+	}{
+		{name: "standalone terminal", want: ""},
+		{name: "systemd unit", env: map[string]string{"INVOCATION_ID": "0f1e2d"}, want: SupervisorSystemd},
+		{name: "launchd job", env: map[string]string{"XPC_SERVICE_NAME": "com.example.agente"}, want: SupervisorLaunchd},
+		{name: "macOS terminal session", env: map[string]string{"XPC_SERVICE_NAME": "0"}, want: ""},
+		{name: "macOS app session", env: map[string]string{"XPC_SERVICE_NAME": "application.com.apple.Terminal.1234"}, want: ""},
+		{name: "windows service", windowsService: true, want: SupervisorSCM},
+		{name: "forced on", env: map[string]string{"AGENTE_SUPERVISED": "1"}, want: SupervisorForced},
+		{name: "forced on keeps the detected manager", env: map[string]string{"AGENTE_SUPERVISED": "true", "INVOCATION_ID": "x"}, want: SupervisorSystemd},
+		{name: "forced off under systemd", env: map[string]string{"AGENTE_SUPERVISED": "0", "INVOCATION_ID": "x"}, want: ""},
+		{name: "forced off under the SCM", env: map[string]string{"AGENTE_SUPERVISED": "false"}, windowsService: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectSupervisor(fakeEnv(tt.env), func() bool { return tt.windowsService })
+			if got != tt.want {
+				t.Fatalf("detectSupervisor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteShutdownReason(t *testing.T) {
+	for _, supervisor := range []string{SupervisorSystemd, ""} {
+		dir := filepath.Join(t.TempDir(), "data")
+		request := RestartRequest{Reason: "restart_agent", CommandID: "cmd-1", Timestamp: time.Now()}
+		if err := WriteShutdownReason(dir, request, supervisor); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, "last_shutdown.json")
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+			t.Errorf("last_shutdown.json mode = %o, want 600", info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record shutdownRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Reason != "restart_agent" || record.CommandID != "cmd-1" || record.PID != os.Getpid() {
+			t.Errorf("shutdown record = %+v", record)
+		}
+		if record.Supervised != (supervisor != "") || record.Supervisor != supervisor {
+			t.Errorf("supervisor %q recorded as %t/%q", supervisor, record.Supervised, record.Supervisor)
+		}
+	}
+}
+
+func TestHandleRestartCommand(t *testing.T) {
+	for _, supervised := range []string{"1", "0"} {
+		t.Run("AGENTE_SUPERVISED="+supervised, func(t *testing.T) {
+			t.Setenv("AGENTE_SUPERVISED", supervised)
+			if RunningUnderSupervisor() != (supervised == "1") {
+				t.Fatal("supervisor override ignored")
+			}
+
+			config, err := LoadConfig(writeTestConfig(t, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := New(config, testLogger(t))
+			defer a.cancel()
+
+			start := time.Now()
+			a.handleRestartCommand(&comms.Command{ID: "cmd-restart", Type: "restart_agent"})
+
+			select {
+			case request := <-a.RestartRequests():
+				if request.Reason != "restart_agent" || request.CommandID != "cmd-restart" {
+					t.Fatalf("restart request = %+v", request)
+				}
+				// O pedido só sai depois da janela para o resultado chegar ao backend
+				if elapsed := time.Since(start); elapsed < restartResultDelay {
+					t.Fatalf("restart requested after %s, before the result delay", elapsed)
+				}
+			case <-time.After(restartResultDelay + 5*time.Second):
+				t.Fatal("restart never requested")
+			}
+		})
+	}
+}
+
+func TestHandleRestartCommandCancelled(t *testing.T) {
+	config, err := LoadConfig(writeTestConfig(t, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(config, testLogger(t))
+	a.handleRestartCommand(&comms.Command{ID: "cmd-restart", Type: "restart_agent"})
+	a.cancel()
+
+	select {
+	case request := <-a.RestartRequests():
+		t.Fatalf("restart requested after shutdown: %+v", request)
+	case <-time.After(restartResultDelay + time.Second):
+	}
+}
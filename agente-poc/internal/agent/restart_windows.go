@@ -0,0 +1,11 @@
+//go:build windows
+
+package agent
+
+import "golang.org/x/sys/windows/svc"
+
+// isWindowsService indica se o processo foi iniciado pelo SCM
+func isWindowsService() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
@@ -0,0 +1,540 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
+)
+
+// schedulesFile guarda os agendamentos do backend e a última execução de
+// cada agendamento entre reinícios
+const schedulesFile = "schedules.json"
+
+// Origem de uma definição de agendamento
+const (
+	scheduleSourceConfig  = "config"  // campo schedules do arquivo
+	scheduleSourceBackend = "backend" // mensagem schedule_update
+)
+
+// minScheduleInterval é o menor intervalo aceito em agendamentos por intervalo
+const minScheduleInterval = 10 * time.Second
+
+// schedulerMaxWait limita a espera entre verificações, para que ajustes do
+// relógio de parede sejam notados
+const schedulerMaxWait = time.Minute
+
+// ScheduledCommand é o comando submetido a cada execução do agendamento
+type ScheduledCommand struct {
+	Type    string                 `json:"type"`
+	Command string                 `json:"command,omitempty"`
+	Args    []string               `json:"args,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	Timeout int                    `json:"timeout,omitempty"`
+}
+
+// Schedule é um comando recorrente, por expressão cron (horário local) ou
+// por intervalo fixo
+type Schedule struct {
+	ID       string           `json:"id"`
+	Cron     string           `json:"cron,omitempty"`
+	Interval timeutil.Seconds `json:"interval,omitempty"`
+	Command  ScheduledCommand `json:"command"`
+}
+
+// Validate verifica a definição do agendamento
+func (s *Schedule) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("schedule id is required")
+	}
+	if (s.Cron == "") == (s.Interval == 0) {
+		return fmt.Errorf("schedule %s: exactly one of cron or interval is required", s.ID)
+	}
+	if s.Cron != "" {
+		if _, err := parseCron(s.Cron); err != nil {
+			return fmt.Errorf("schedule %s: %w", s.ID, err)
+		}
+	}
+	if s.Interval != 0 && s.Interval.Duration() < minScheduleInterval {
+		return fmt.Errorf("schedule %s: interval must be at least %s", s.ID, minScheduleInterval)
+	}
+	if s.Command.Type == "" {
+		return fmt.Errorf("schedule %s: command type is required", s.ID)
+	}
+	return nil
+}
+
+// ValidateSchedules verifica cada definição e a unicidade dos IDs
+func ValidateSchedules(schedules []Schedule) error {
+	seen := make(map[string]bool, len(schedules))
+	for i := range schedules {
+		if err := schedules[i].Validate(); err != nil {
+			return err
+		}
+		if seen[schedules[i].ID] {
+			return fmt.Errorf("duplicate schedule id: %s", schedules[i].ID)
+		}
+		seen[schedules[i].ID] = true
+	}
+	return nil
+}
+
+// ScheduleStatus descreve um agendamento para o health
+type ScheduleStatus struct {
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Cron            string    `json:"cron,omitempty"`
+	IntervalSeconds float64   `json:"interval_seconds,omitempty"`
+	NextRun         time.Time `json:"next_run"`
+	LastRun         time.Time `json:"last_run"`
+	LastCommandID   string    `json:"last_command_id,omitempty"`
+	// RunningCommandID é a execução ainda sem resultado final
+	RunningCommandID string `json:"running_command_id,omitempty"`
+	Runs             int64  `json:"runs"`
+	SkippedOverlaps  int64  `json:"skipped_overlaps"`
+}
+
+// scheduleEntry é um agendamento ativo e o estado das suas execuções
+type scheduleEntry struct {
+	schedule Schedule
+	source   string
+	cron     *cronSpec
+	next     time.Time
+
+	lastRun       time.Time
+	lastCommandID string
+	running       string // command_id sem resultado final
+	runs          int64
+	skipped       int64
+}
+
+// schedulerState é o conteúdo de schedulesFile
+type schedulerState struct {
+	Schedules []Schedule           `json:"schedules"`
+	LastRuns  map[string]time.Time `json:"last_runs,omitempty"`
+}
+
+// Scheduler submete comandos recorrentes pelo mesmo caminho dos comandos
+// recebidos (SubmitCommand). Cada execução recebe um command_id próprio,
+// associado ao agendamento até o resultado final; enquanto ela não termina,
+// as execuções seguintes do mesmo agendamento são puladas.
+type Scheduler struct {
+	path   string
+	clock  clock.Clock
+	logger logging.Logger
+	submit func(command *comms.Command) error
+
+	mu       sync.Mutex
+	sources  map[string][]Schedule
+	entries  map[string]*scheduleEntry
+	commands map[string]string // command_id -> schedule_id
+	lastRuns map[string]time.Time
+
+	// wake acorda Run quando as definições mudam
+	wake chan struct{}
+}
+
+// NewScheduler cria o scheduler e relê de path os agendamentos do backend e
+// as últimas execuções; path vazio desativa a persistência. Os agendamentos
+// do arquivo de configuração entram depois, por SetSchedules.
+func NewScheduler(path string, clk clock.Clock, logger logging.Logger, submit func(command *comms.Command) error) *Scheduler {
+	s := &Scheduler{
+		path:     path,
+		clock:    clock.OrReal(clk),
+		logger:   logger,
+		submit:   submit,
+		sources:  make(map[string][]Schedule),
+		entries:  make(map[string]*scheduleEntry),
+		commands: make(map[string]string),
+		lastRuns: make(map[string]time.Time),
+		wake:     make(chan struct{}, 1),
+	}
+	s.load()
+	return s
+}
+
+// load reaplica o estado persistido; falhas mantêm o scheduler vazio
+func (s *Scheduler) load() {
+	if s.path == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			s.logger.WithField("error", err).Warning("Failed to read schedules")
+		}
+		return
+	}
+
+	var state schedulerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		s.logger.WithField("error", err).Warning("Ignoring invalid schedules state")
+		return
+	}
+	if err := ValidateSchedules(state.Schedules); err != nil {
+		s.logger.WithField("error", err).Warning("Ignoring invalid persisted schedules")
+		state.Schedules = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, lastRun := range state.LastRuns {
+		s.lastRuns[id] = lastRun
+	}
+	s.sources[scheduleSourceBackend] = state.Schedules
+	s.rebuildLocked()
+}
+
+// SetSchedules substitui as definições de uma origem. Um ID definido pelo
+// backend e no arquivo fica com a definição do backend. Agendamentos que não
+// mudaram mantêm o próximo horário e as execuções em andamento.
+func (s *Scheduler) SetSchedules(source string, schedules []Schedule) error {
+	if err := ValidateSchedules(schedules); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sources[source] = append([]Schedule(nil), schedules...)
+	s.rebuildLocked()
+	s.mu.Unlock()
+
+	if source == scheduleSourceBackend {
+		s.save()
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rebuildLocked recalcula os agendamentos ativos; chamado com s.mu travado
+func (s *Scheduler) rebuildLocked() {
+	now := s.clock.Now()
+	merged := make(map[string]*scheduleEntry)
+
+	for _, source := range []string{scheduleSourceConfig, scheduleSourceBackend} {
+		for _, schedule := range s.sources[source] {
+			if previous, ok := merged[schedule.ID]; ok {
+				s.logger.WithFields(map[string]interface{}{
+					"schedule_id": schedule.ID,
+					"replaced":    previous.source,
+				}).Info("Backend schedule overrides configured schedule")
+			}
+
+			entry, ok := s.entries[schedule.ID]
+			if !ok || entry.source != source || !reflect.DeepEqual(entry.schedule, schedule) {
+				running := ""
+				if ok {
+					running = entry.running
+				}
+				entry = &scheduleEntry{
+					schedule: schedule,
+					source:   source,
+					lastRun:  s.lastRuns[schedule.ID],
+					running:  running,
+				}
+				if schedule.Cron != "" {
+					entry.cron, _ = parseCron(schedule.Cron)
+				}
+				entry.next = entry.firstRun(now)
+			}
+			merged[schedule.ID] = entry
+		}
+	}
+
+	s.entries = merged
+}
+
+// firstRun calcula a primeira execução de um agendamento novo ou relido do
+// disco. Um intervalo já vencido desde a última execução roda uma vez
+// imediatamente; horários de cron perdidos com o agente parado não.
+func (e *scheduleEntry) firstRun(now time.Time) time.Time {
+	if e.cron != nil {
+		return e.cron.Next(now)
+	}
+
+	interval := e.schedule.Interval.Duration()
+	if e.lastRun.IsZero() {
+		return now.Add(interval)
+	}
+	next := e.lastRun.Add(interval)
+	if next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// nextRun calcula a execução seguinte a now
+func (e *scheduleEntry) nextRun(now time.Time) time.Time {
+	if e.cron != nil {
+		return e.cron.Next(now)
+	}
+	return now.Add(e.schedule.Interval.Duration())
+}
+
+// Run submete os agendamentos vencidos até ctx ser cancelado
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		wait := s.runDue()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// runDue submete os agendamentos vencidos e retorna quanto esperar até o
+// próximo
+func (s *Scheduler) runDue() time.Duration {
+	s.mu.Lock()
+	now := s.clock.Now()
+
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var due []*comms.Command
+	for _, id := range ids {
+		entry := s.entries[id]
+		if entry.next.IsZero() || now.Before(entry.next) {
+			continue
+		}
+		entry.next = entry.nextRun(now)
+
+		if entry.running != "" {
+			entry.skipped++
+			s.logger.WithFields(map[string]interface{}{
+				"schedule_id": id,
+				"command_id":  entry.running,
+			}).Warning("Skipping scheduled run: previous run still in progress")
+			continue
+		}
+
+		command := entry.command(now)
+		entry.running = command.ID
+		entry.lastRun = now
+		entry.lastCommandID = command.ID
+		entry.runs++
+		s.commands[command.ID] = id
+		s.lastRuns[id] = now
+		due = append(due, command)
+	}
+
+	wait := schedulerMaxWait
+	for _, entry := range s.entries {
+		if !entry.next.IsZero() && entry.next.Sub(now) < wait {
+			wait = entry.next.Sub(now)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, command := range due {
+		s.logger.WithFields(map[string]interface{}{
+			"schedule_id": s.scheduleOf(command.ID),
+			"command_id":  command.ID,
+		}).Debug("Submitting scheduled command")
+
+		if err := s.submit(command); err != nil {
+			s.logger.WithFields(map[string]interface{}{
+				"command_id": command.ID,
+				"error":      err,
+			}).Warning("Failed to submit scheduled command")
+			s.Complete(command.ID)
+		}
+	}
+	if len(due) > 0 {
+		s.save()
+	}
+	return wait
+}
+
+// command monta o comando de uma execução do agendamento
+func (e *scheduleEntry) command(now time.Time) *comms.Command {
+	spec := e.schedule.Command
+
+	var options map[string]interface{}
+	if spec.Options != nil {
+		options = make(map[string]interface{}, len(spec.Options))
+		for key, value := range spec.Options {
+			options[key] = value
+		}
+	}
+
+	return &comms.Command{
+		ID:        fmt.Sprintf("schedule-%s-%d", e.schedule.ID, now.Unix()),
+		Type:      spec.Type,
+		Command:   spec.Command,
+		Args:      append([]string(nil), spec.Args...),
+		Options:   options,
+		Timeout:   spec.Timeout,
+		Timestamp: now,
+	}
+}
+
+// Complete encerra a execução agendada com o resultado final e retorna o
+// agendamento a que ela pertence
+func (s *Scheduler) Complete(commandID string) (scheduleID string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scheduleID, ok = s.commands[commandID]
+	if !ok {
+		return "", false
+	}
+	delete(s.commands, commandID)
+	if entry := s.entries[scheduleID]; entry != nil && entry.running == commandID {
+		entry.running = ""
+	}
+	return scheduleID, true
+}
+
+// ScheduleOf retorna o agendamento de uma execução ainda em andamento
+func (s *Scheduler) ScheduleOf(commandID string) (scheduleID string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduleID, ok = s.commands[commandID]
+	return scheduleID, ok
+}
+
+// scheduleOf é ScheduleOf sem o indicador, para logs
+func (s *Scheduler) scheduleOf(commandID string) string {
+	scheduleID, _ := s.ScheduleOf(commandID)
+	return scheduleID
+}
+
+// Status retorna os agendamentos ativos em ordem de ID
+func (s *Scheduler) Status() []ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]ScheduleStatus, 0, len(s.entries))
+	for id, entry := range s.entries {
+		statuses = append(statuses, ScheduleStatus{
+			ID:               id,
+			Source:           entry.source,
+			Cron:             entry.schedule.Cron,
+			IntervalSeconds:  entry.schedule.Interval.Duration().Seconds(),
+			NextRun:          entry.next,
+			LastRun:          entry.lastRun,
+			LastCommandID:    entry.lastCommandID,
+			RunningCommandID: entry.running,
+			Runs:             entry.runs,
+			SkippedOverlaps:  entry.skipped,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// save persiste os agendamentos do backend e as últimas execuções
+func (s *Scheduler) save() {
+	if s.path == "" {
+		return
+	}
+
+	s.mu.Lock()
+	state := schedulerState{
+		Schedules: s.sources[scheduleSourceBackend],
+		LastRuns:  make(map[string]time.Time, len(s.lastRuns)),
+	}
+	for id, lastRun := range s.lastRuns {
+		// Agendamentos removidos deixam de ser lembrados
+		if _, ok := s.entries[id]; !ok {
+			delete(s.lastRuns, id)
+			continue
+		}
+		state.LastRuns[id] = lastRun
+	}
+	s.mu.Unlock()
+
+	if state.Schedules == nil {
+		state.Schedules = []Schedule{}
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(s.path), 0700)
+	}
+	if err == nil {
+		tmpPath := s.path + ".tmp"
+		if err = os.WriteFile(tmpPath, data, 0600); err == nil {
+			err = os.Rename(tmpPath, s.path)
+		}
+	}
+	if err != nil {
+		s.logger.WithField("error", err).Warning("Failed to persist schedules")
+	}
+}
+
+// runScheduler executa o scheduler até o agente parar
+func (a *Agent) runScheduler() {
+	defer a.wg.Done()
+	a.scheduler.Run(a.ctx)
+}
+
+// handleScheduleUpdate substitui os agendamentos do backend (mensagem
+// schedule_update); uma definição inválida recusa a atualização inteira
+func (a *Agent) handleScheduleUpdate(update *comms.ScheduleUpdate) {
+	var schedules []Schedule
+	err := json.Unmarshal(update.Schedules, &schedules)
+	if err == nil {
+		err = a.scheduler.SetSchedules(scheduleSourceBackend, schedules)
+	}
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Schedule update rejected")
+		a.recordEvent(events.CategoryAgent, events.SeverityWarning, "schedule_update_rejected",
+			"Schedule update rejected", map[string]interface{}{"error": err})
+		return
+	}
+
+	ids := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		ids = append(ids, schedule.ID)
+	}
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "schedules_updated",
+		"Scheduled commands updated", map[string]interface{}{"schedules": ids})
+}
+
+// tagScheduledResult associa o resultado de uma execução agendada ao seu
+// agendamento; o resultado final libera a próxima execução
+func (a *Agent) tagScheduledResult(result *comms.CommandResult) {
+	if a.scheduler == nil {
+		return
+	}
+
+	var scheduleID string
+	var ok bool
+	if result.Status.IsTerminal() {
+		scheduleID, ok = a.scheduler.Complete(result.CommandID)
+	} else {
+		scheduleID, ok = a.scheduler.ScheduleOf(result.CommandID)
+	}
+	if ok {
+		result.ScheduleID = scheduleID
+	}
+}
+
+// schedulesStatus descreve os agendamentos para o health
+func (a *Agent) schedulesStatus() []ScheduleStatus {
+	if a.scheduler == nil {
+		return []ScheduleStatus{}
+	}
+	return a.scheduler.Status()
+}
@@ -0,0 +1,334 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/comms"
+	"agente-poc/internal/timeutil"
+)
+
+// submissions registra os comandos submetidos pelo scheduler
+type submissions struct {
+	mu       sync.Mutex
+	commands []*comms.Command
+	err      error
+}
+
+func (s *submissions) submit(command *comms.Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, command)
+	return s.err
+}
+
+// take retorna e esquece os comandos submetidos até agora
+func (s *submissions) take() []*comms.Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	commands := s.commands
+	s.commands = nil
+	return commands
+}
+
+func newTestScheduler(t *testing.T, path string, fake *clock.Fake) (*Scheduler, *submissions) {
+	t.Helper()
+	submitted := &submissions{}
+	return NewScheduler(path, fake, testLogger(t), submitted.submit), submitted
+}
+
+func intervalSchedule(id string, interval time.Duration) Schedule {
+	return Schedule{
+		ID:       id,
+		Interval: timeutil.Seconds(interval),
+		Command:  ScheduledCommand{Type: "shell", Command: "df", Args: []string{"-h"}},
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	valid := []Schedule{
+		intervalSchedule("disk", time.Minute),
+		{ID: "uptime", Cron: "@hourly", Command: ScheduledCommand{Type: "info"}},
+	}
+	if err := ValidateSchedules(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, schedules := range map[string][]Schedule{
+		"no id":          {{Interval: timeutil.Seconds(time.Minute), Command: ScheduledCommand{Type: "info"}}},
+		"no trigger":     {{ID: "a", Command: ScheduledCommand{Type: "info"}}},
+		"both triggers":  {{ID: "a", Cron: "@daily", Interval: timeutil.Seconds(time.Minute), Command: ScheduledCommand{Type: "info"}}},
+		"invalid cron":   {{ID: "a", Cron: "* * *", Command: ScheduledCommand{Type: "info"}}},
+		"short interval": {intervalSchedule("a", 5*time.Second)},
+		"no type":        {{ID: "a", Cron: "@daily"}},
+		"duplicate id":   {intervalSchedule("a", time.Minute), intervalSchedule("a", time.Hour)},
+	} {
+		if err := ValidateSchedules(schedules); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestSchedulerIntervalRuns(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	s, submitted := newTestScheduler(t, "", fake)
+	if err := s.SetSchedules(scheduleSourceConfig, []Schedule{intervalSchedule("disk", time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := s.runDue(); wait != time.Minute || len(submitted.take()) != 0 {
+		t.Fatalf("ran before the interval (wait %s)", wait)
+	}
+
+	fake.Advance(time.Minute)
+	s.runDue()
+	commands := submitted.take()
+	if len(commands) != 1 {
+		t.Fatalf("%d commands submitted after the interval", len(commands))
+	}
+	command := commands[0]
+	want := &comms.Command{
+		ID:        fmt.Sprintf("schedule-disk-%d", fake.Now().Unix()),
+		Type:      "shell",
+		Command:   "df",
+		Args:      []string{"-h"},
+		Timestamp: fake.Now(),
+	}
+	if !reflect.DeepEqual(command, want) {
+		t.Fatalf("command = %+v, want %+v", command, want)
+	}
+	if id, ok := s.ScheduleOf(command.ID); !ok || id != "disk" {
+		t.Fatalf("ScheduleOf = %q, %t", id, ok)
+	}
+
+	// Com o resultado final, a execução seguinte acontece normalmente
+	if id, ok := s.Complete(command.ID); !ok || id != "disk" {
+		t.Fatalf("Complete = %q, %t", id, ok)
+	}
+	if _, ok := s.Complete(command.ID); ok {
+		t.Fatal("Complete twice")
+	}
+	fake.Advance(time.Minute)
+	s.runDue()
+	if commands := submitted.take(); len(commands) != 1 || commands[0].ID == command.ID {
+		t.Fatalf("second run = %v", commands)
+	}
+	if status := s.Status()[0]; status.Runs != 2 || status.SkippedOverlaps != 0 || !status.NextRun.Equal(fake.Now().Add(time.Minute)) {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	s, submitted := newTestScheduler(t, "", fake)
+	if err := s.SetSchedules(scheduleSourceConfig, []Schedule{intervalSchedule("disk", time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Advance(time.Minute)
+	s.runDue()
+	first := submitted.take()[0]
+
+	// A primeira execução não terminou: as duas seguintes são puladas
+	for i := 0; i < 2; i++ {
+		fake.Advance(time.Minute)
+		s.runDue()
+	}
+	if commands := submitted.take(); len(commands) != 0 {
+		t.Fatalf("overlapping runs submitted: %v", commands)
+	}
+	status := s.Status()[0]
+	if status.SkippedOverlaps != 2 || status.RunningCommandID != first.ID || status.Runs != 1 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	s.Complete(first.ID)
+	fake.Advance(time.Minute)
+	s.runDue()
+	if commands := submitted.take(); len(commands) != 1 {
+		t.Fatalf("%d commands after the overlap ended", len(commands))
+	}
+}
+
+func TestSchedulerSubmitFailureReleasesSchedule(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	s, submitted := newTestScheduler(t, "", fake)
+	submitted.err = errors.New("queue full")
+	if err := s.SetSchedules(scheduleSourceConfig, []Schedule{intervalSchedule("disk", time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Advance(time.Minute)
+	s.runDue()
+	if status := s.Status()[0]; status.RunningCommandID != "" {
+		t.Fatalf("failed submission still running: %+v", status)
+	}
+	submitted.err = nil
+	fake.Advance(time.Minute)
+	s.runDue()
+	if commands := submitted.take(); len(commands) != 2 {
+		t.Fatalf("%d submissions, want the failed one and the next", len(commands))
+	}
+}
+
+func TestSchedulerBackendOverridesConfig(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	s, _ := newTestScheduler(t, "", fake)
+	if err := s.SetSchedules(scheduleSourceConfig, []Schedule{intervalSchedule("disk", time.Minute), intervalSchedule("mem", time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSchedules(scheduleSourceBackend, []Schedule{intervalSchedule("disk", time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := s.Status()
+	if len(statuses) != 2 || statuses[0].Source != scheduleSourceBackend || statuses[0].IntervalSeconds != 3600 || statuses[1].Source != scheduleSourceConfig {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+
+	// Uma atualização inválida não muda nada
+	if err := s.SetSchedules(scheduleSourceBackend, []Schedule{intervalSchedule("", time.Minute)}); err == nil {
+		t.Fatal("invalid update accepted")
+	}
+	if !reflect.DeepEqual(s.Status(), statuses) {
+		t.Fatalf("statuses after an invalid update = %+v", s.Status())
+	}
+}
+
+func TestSchedulerSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), schedulesFile)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	s, submitted := newTestScheduler(t, path, fake)
+	backend := []Schedule{
+		intervalSchedule("disk", 10*time.Minute),
+		{ID: "report", Cron: "*/5 * * * *", Command: ScheduledCommand{Type: "info"}},
+	}
+	if err := s.SetSchedules(scheduleSourceBackend, backend); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(10 * time.Minute)
+	s.runDue()
+	if commands := submitted.take(); len(commands) != 2 {
+		t.Fatalf("%d commands before the restart", len(commands))
+	}
+
+	// Agente parado por 15 minutos: os agendamentos do backend são relidos;
+	// o intervalo vencido roda uma vez, os horários de cron perdidos não
+	fake.Advance(15 * time.Minute)
+	restarted, resubmitted := newTestScheduler(t, path, fake)
+	statuses := restarted.Status()
+	if len(statuses) != 2 || !statuses[0].LastRun.Equal(start.Add(10*time.Minute)) {
+		t.Fatalf("statuses after restart = %+v", statuses)
+	}
+	restarted.runDue()
+	commands := resubmitted.take()
+	if len(commands) != 1 || commands[0].Type != "shell" {
+		t.Fatalf("commands right after restart = %v", commands)
+	}
+	if next := restarted.Status()[1].NextRun; !next.Equal(start.Add(30 * time.Minute)) {
+		t.Fatalf("cron next run after restart = %s", next)
+	}
+}
+
+func TestSchedulerRunWithFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	s, submitted := newTestScheduler(t, "", fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Sem agendamentos, Run espera schedulerMaxWait; SetSchedules acorda o
+	// loop, que registra a espera até o próximo minuto
+	waitTimers(t, fake, 1)
+	if err := s.SetSchedules(scheduleSourceConfig, []Schedule{{ID: "tick", Cron: "* * * * *", Command: ScheduledCommand{Type: "info"}}}); err != nil {
+		t.Fatal(err)
+	}
+	waitTimers(t, fake, 2)
+
+	for minute := 1; minute <= 3; minute++ {
+		fake.Advance(time.Minute)
+		deadline := time.Now().Add(2 * time.Second)
+		var commands []*comms.Command
+		for len(commands) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("minute %d: nothing submitted", minute)
+			}
+			time.Sleep(time.Millisecond)
+			commands = submitted.take()
+		}
+		if len(commands) != 1 || !commands[0].Timestamp.Equal(fake.Now()) {
+			t.Fatalf("minute %d: commands = %v", minute, commands)
+		}
+		s.Complete(commands[0].ID)
+		waitTimers(t, fake, 1)
+	}
+	if status := s.Status()[0]; status.Runs != 3 || status.SkippedOverlaps != 0 {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+// waitTimers espera o loop registrar n timers no relógio falso
+func waitTimers(t *testing.T, fake *clock.Fake, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Pending() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d timers pending, want %d", fake.Pending(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduleUpdateAndResultTagging(t *testing.T) {
+	a, fake := newTestAgent(t, nil)
+	submitted := &submissions{}
+	a.scheduler = NewScheduler(filepath.Join(a.config.DataDir, schedulesFile), fake, a.logger, submitted.submit)
+
+	raw, _ := json.Marshal([]Schedule{intervalSchedule("disk", time.Minute)})
+	a.handleScheduleUpdate(&comms.ScheduleUpdate{Schedules: raw})
+	waitForEvent(t, a, "schedules_updated")
+
+	fake.Advance(time.Minute)
+	a.scheduler.runDue()
+	command := submitted.take()[0]
+
+	// Resultados intermediários e o final levam o schedule_id; o final libera
+	// a próxima execução
+	running := &comms.CommandResult{CommandID: command.ID, Status: comms.StatusRunning}
+	a.tagScheduledResult(running)
+	final := &comms.CommandResult{CommandID: command.ID, Status: comms.StatusSuccess}
+	a.tagScheduledResult(final)
+	if running.ScheduleID != "disk" || final.ScheduleID != "disk" {
+		t.Fatalf("schedule ids = %q, %q", running.ScheduleID, final.ScheduleID)
+	}
+	if status := a.schedulesStatus()[0]; status.RunningCommandID != "" {
+		t.Fatalf("final result did not release the schedule: %+v", status)
+	}
+	other := &comms.CommandResult{CommandID: "cmd-backend", Status: comms.StatusSuccess}
+	a.tagScheduledResult(other)
+	if other.ScheduleID != "" {
+		t.Fatalf("backend command tagged with %q", other.ScheduleID)
+	}
+
+	a.handleScheduleUpdate(&comms.ScheduleUpdate{Schedules: json.RawMessage(`[{"id":"bad","interval":1,"command":{"type":"info"}}]`)})
+	waitForEvent(t, a, "schedule_update_rejected")
+	if statuses := a.schedulesStatus(); len(statuses) != 1 || statuses[0].ID != "disk" {
+		t.Fatalf("schedules after a rejected update = %+v", statuses)
+	}
+}
@@ -0,0 +1,279 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agente-poc/internal/collector"
+	"agente-poc/internal/comms"
+)
+
+// SnapshotEntry descreve um snapshot de inventário guardado localmente
+type SnapshotEntry struct {
+	comms.SnapshotInfo
+	fileName string
+}
+
+// snapshotManifestFile é o índice persistido junto com os snapshots
+const snapshotManifestFile = "manifest.json"
+
+// defaultSnapshotMaxBytes é o orçamento de disco padrão do ring (64 MB)
+const defaultSnapshotMaxBytes = 64 << 20
+
+// manifestEntry é a forma persistida de SnapshotEntry
+type manifestEntry struct {
+	comms.SnapshotInfo
+	FileName string `json:"file_name"`
+}
+
+// SnapshotRing mantém os últimos K snapshots de inventário comprimidos em disco,
+// independentemente do sucesso do envio, para que o backend possa solicitar
+// períodos perdidos depois de uma indisponibilidade
+type SnapshotRing struct {
+	dir      string
+	size     int
+	level    int
+	maxBytes int64
+	entries  []SnapshotEntry
+	mu       sync.RWMutex
+}
+
+// NewSnapshotRing cria (ou reabre) o ring no diretório informado; maxBytes
+// limita o disco ocupado pelos snapshots (0 = sem limite)
+func NewSnapshotRing(dir string, size, level int, maxBytes int64) (*SnapshotRing, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("snapshot ring size must be positive")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	ring := &SnapshotRing{
+		dir:      dir,
+		size:     size,
+		level:    level,
+		maxBytes: maxBytes,
+		entries:  make([]SnapshotEntry, 0, size),
+	}
+
+	if err := ring.load(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// Add comprime e guarda um snapshot, descartando o mais antigo quando cheio
+func (r *SnapshotRing) Add(data *collector.InventoryData) (SnapshotEntry, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return SnapshotEntry{}, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, r.level)
+	if err != nil {
+		return SnapshotEntry{}, fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return SnapshotEntry{}, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return SnapshotEntry{}, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+
+	hash := sha256.Sum256(raw)
+	timestamp := data.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	entry := SnapshotEntry{SnapshotInfo: comms.SnapshotInfo{
+		ID:        fmt.Sprintf("snap_%d", timestamp.UnixNano()),
+		Timestamp: timestamp,
+		Checksum:  hex.EncodeToString(hash[:]),
+		SizeBytes: int64(buf.Len()),
+	}}
+	entry.fileName = entry.ID + ".json.gz"
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(r.dir, entry.fileName), buf.Bytes(), 0600); err != nil {
+		return SnapshotEntry{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	r.entries = append(r.entries, entry)
+	r.prune()
+
+	return entry, r.saveManifest()
+}
+
+// MarkSent marca um snapshot como entregue ao backend
+func (r *SnapshotRing) MarkSent(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.entries {
+		if r.entries[i].ID == id {
+			r.entries[i].Sent = true
+			_ = r.saveManifest()
+			return
+		}
+	}
+}
+
+// Manifest retorna a lista de snapshots disponíveis, do mais antigo ao mais novo
+func (r *SnapshotRing) Manifest() []comms.SnapshotInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	manifest := make([]comms.SnapshotInfo, 0, len(r.entries))
+	for _, entry := range r.entries {
+		manifest = append(manifest, entry.SnapshotInfo)
+	}
+	return manifest
+}
+
+// HasUnsent indica se algum snapshot do ring não chegou ao backend
+func (r *SnapshotRing) HasUnsent() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.entries {
+		if !entry.Sent {
+			return true
+		}
+	}
+	return false
+}
+
+// DiskUsage retorna o total de bytes ocupados pelos snapshots
+func (r *SnapshotRing) DiskUsage() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int64
+	for _, entry := range r.entries {
+		total += entry.SizeBytes
+	}
+	return total
+}
+
+// Get retorna o conteúdo comprimido (gzip) de um snapshot
+func (r *SnapshotRing) Get(id string) (SnapshotEntry, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.entries {
+		if entry.ID != id {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.dir, entry.fileName))
+		if err != nil {
+			return entry, nil, fmt.Errorf("failed to read snapshot %s: %w", id, err)
+		}
+		return entry, data, nil
+	}
+
+	return SnapshotEntry{}, nil, fmt.Errorf("snapshot not found: %s", id)
+}
+
+// Latest retorna o conteúdo do snapshot mais recente (nil com o ring vazio)
+func (r *SnapshotRing) Latest() (*collector.InventoryData, error) {
+	r.mu.RLock()
+	if len(r.entries) == 0 {
+		r.mu.RUnlock()
+		return nil, nil
+	}
+	id := r.entries[len(r.entries)-1].ID
+	r.mu.RUnlock()
+
+	_, compressed, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress snapshot %s: %w", id, err)
+	}
+	defer reader.Close()
+
+	var data collector.InventoryData
+	if err := json.NewDecoder(reader).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %s: %w", id, err)
+	}
+	return &data, nil
+}
+
+// load lê o manifesto existente, ignorando entradas cujo arquivo sumiu
+func (r *SnapshotRing) load() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotManifestFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot manifest: %w", err)
+	}
+
+	var persisted []manifestEntry
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return fmt.Errorf("failed to parse snapshot manifest: %w", err)
+	}
+
+	for _, item := range persisted {
+		if _, err := os.Stat(filepath.Join(r.dir, item.FileName)); err != nil {
+			continue
+		}
+		r.entries = append(r.entries, SnapshotEntry{SnapshotInfo: item.SnapshotInfo, fileName: item.FileName})
+	}
+
+	// O tamanho ou o orçamento configurado pode ter diminuído desde a última execução
+	r.prune()
+
+	return nil
+}
+
+// prune remove os snapshots mais antigos além do tamanho do ring ou do
+// orçamento de disco, mantendo sempre o mais recente; deve ser chamado com o
+// lock adquirido
+func (r *SnapshotRing) prune() {
+	var total int64
+	for _, entry := range r.entries {
+		total += entry.SizeBytes
+	}
+
+	for len(r.entries) > 1 && (len(r.entries) > r.size || (r.maxBytes > 0 && total > r.maxBytes)) {
+		total -= r.entries[0].SizeBytes
+		_ = os.Remove(filepath.Join(r.dir, r.entries[0].fileName))
+		r.entries = r.entries[1:]
+	}
+}
+
+// saveManifest persiste o índice; deve ser chamado com o lock adquirido
+func (r *SnapshotRing) saveManifest() error {
+	persisted := make([]manifestEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		persisted = append(persisted, manifestEntry{SnapshotInfo: entry.SnapshotInfo, FileName: entry.fileName})
+	}
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot manifest: %w", err)
+	}
+
+	tmpPath := filepath.Join(r.dir, snapshotManifestFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot manifest: %w", err)
+	}
+	return os.Rename(tmpPath, filepath.Join(r.dir, snapshotManifestFile))
+}
@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/collector"
+)
+
+// testInventory gera um inventário distinguível pelo índice
+func testInventory(i int) *collector.InventoryData {
+	return &collector.InventoryData{
+		MachineID: "test-machine",
+		Timestamp: time.Date(2026, 1, 1, i, 0, 0, 0, time.UTC),
+		System:    collector.SystemInfo{Hostname: strings.Repeat("h", i+1)},
+	}
+}
+
+func openTestRing(t *testing.T, dir string, size int, maxBytes int64) *SnapshotRing {
+	t.Helper()
+	ring, err := NewSnapshotRing(dir, size, gzip.DefaultCompression, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+// decompress devolve o inventário de um snapshot comprimido
+func decompress(t *testing.T, data []byte) ([]byte, *collector.InventoryData) {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inventory collector.InventoryData
+	if err := json.Unmarshal(raw, &inventory); err != nil {
+		t.Fatal(err)
+	}
+	return raw, &inventory
+}
+
+func TestSnapshotRingManifest(t *testing.T) {
+	dir := t.TempDir()
+	ring := openTestRing(t, dir, 3, 0)
+
+	var added []SnapshotEntry
+	for i := 0; i < 5; i++ {
+		entry, err := ring.Add(testInventory(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		added = append(added, entry)
+	}
+
+	manifest := ring.Manifest()
+	if len(manifest) != 3 {
+		t.Fatalf("manifest has %d entries, want 3", len(manifest))
+	}
+	for i, info := range manifest {
+		want := added[i+2]
+		if info.ID != want.ID || !info.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("manifest[%d] = %s at %s, want %s", i, info.ID, info.Timestamp, want.ID)
+		}
+		entry, data, err := ring.Get(info.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.SizeBytes != int64(len(data)) {
+			t.Errorf("%s: size %d, file has %d bytes", info.ID, entry.SizeBytes, len(data))
+		}
+		raw, _ := decompress(t, data)
+		sum := sha256.Sum256(raw)
+		if info.Checksum != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: checksum does not match the snapshot content", info.ID)
+		}
+	}
+
+	// Os evictados saem do disco e do índice persistido
+	for _, entry := range added[:2] {
+		if _, err := os.Stat(filepath.Join(dir, entry.fileName)); !os.IsNotExist(err) {
+			t.Errorf("evicted snapshot %s still on disk", entry.ID)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, snapshotManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var persisted []manifestEntry
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		t.Fatal(err)
+	}
+	if len(persisted) != 3 || persisted[0].ID != added[2].ID || persisted[0].FileName != added[2].fileName {
+		t.Fatalf("persisted manifest = %+v", persisted)
+	}
+
+	if !ring.HasUnsent() {
+		t.Fatal("new snapshots reported as sent")
+	}
+	for _, info := range manifest {
+		ring.MarkSent(info.ID)
+	}
+	if ring.HasUnsent() {
+		t.Fatal("snapshots still unsent after MarkSent")
+	}
+}
+
+func TestSnapshotRingServesOldSnapshotAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	ring := openTestRing(t, dir, 24, 0)
+
+	oldest, err := ring.Add(testInventory(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 4; i++ {
+		if _, err := ring.Add(testInventory(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ring.MarkSent(oldest.ID)
+
+	// Um arquivo sumido no meio do caminho some do manifesto, sem derrubar o ring
+	lost := ring.Manifest()[2]
+	if err := os.Remove(filepath.Join(dir, lost.ID+".json.gz")); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openTestRing(t, dir, 24, 0)
+	manifest := reopened.Manifest()
+	if len(manifest) != 3 {
+		t.Fatalf("reopened ring has %d snapshots, want 3", len(manifest))
+	}
+	for _, info := range manifest {
+		if info.ID == lost.ID {
+			t.Fatal("snapshot with a missing file kept after restart")
+		}
+	}
+	if !manifest[0].Sent {
+		t.Fatal("sent flag lost across restart")
+	}
+
+	entry, data, err := reopened.Get(oldest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, inventory := decompress(t, data)
+	sum := sha256.Sum256(raw)
+	if entry.Checksum != oldest.Checksum || hex.EncodeToString(sum[:]) != oldest.Checksum {
+		t.Fatal("old snapshot content changed across restart")
+	}
+	if inventory.System.Hostname != testInventory(0).System.Hostname {
+		t.Fatalf("served snapshot hostname = %q", inventory.System.Hostname)
+	}
+
+	latest, err := reopened.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.System.Hostname != testInventory(3).System.Hostname {
+		t.Fatalf("latest after restart = %q", latest.System.Hostname)
+	}
+
+	if _, _, err := reopened.Get("snap_missing"); err == nil {
+		t.Fatal("unknown snapshot served")
+	}
+}
+
+func TestSnapshotRingDiskBudget(t *testing.T) {
+	dir := t.TempDir()
+	ring := openTestRing(t, dir, 24, 0)
+	for i := 0; i < 6; i++ {
+		if _, err := ring.Add(testInventory(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := ring.Manifest()
+	last := manifest[len(manifest)-1]
+	budget := last.SizeBytes + manifest[len(manifest)-2].SizeBytes
+
+	// Reabrir com um orçamento menor poda os mais antigos
+	reopened := openTestRing(t, dir, 24, budget)
+	if got := len(reopened.Manifest()); got != 2 {
+		t.Fatalf("ring within a two-snapshot budget kept %d", got)
+	}
+	if reopened.DiskUsage() > budget {
+		t.Fatalf("disk usage %d over budget %d", reopened.DiskUsage(), budget)
+	}
+	entries, err := filepath.Glob(filepath.Join(dir, "snap_*.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d snapshot files left on disk, want 2", len(entries))
+	}
+
+	// Um único snapshot maior que o orçamento continua disponível
+	tiny := openTestRing(t, t.TempDir(), 24, 1)
+	for i := 0; i < 3; i++ {
+		if _, err := tiny.Add(testInventory(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest = tiny.Manifest()
+	if len(manifest) != 1 || manifest[0].Timestamp != testInventory(2).Timestamp {
+		t.Fatalf("ring over budget kept %+v, want only the newest", manifest)
+	}
+}
+
+func TestNewSnapshotRingInvalidSize(t *testing.T) {
+	if _, err := NewSnapshotRing(t.TempDir(), 0, gzip.DefaultCompression, 0); err == nil {
+		t.Fatal("empty ring accepted")
+	}
+}
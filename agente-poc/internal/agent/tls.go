@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+
+	"agente-poc/internal/comms"
+)
+
+// TLSFiles retorna os arquivos de mTLS no formato do comms
+func (c *Config) TLSFiles() comms.TLSFiles {
+	return comms.TLSFiles{
+		ClientCertFile: c.TLSClientCertFile,
+		ClientKeyFile:  c.TLSClientKeyFile,
+		CACertFile:     c.TLSCACertFile,
+	}
+}
+
+// tlsFileDigests retorna o SHA-256 do conteúdo de cada arquivo de mTLS
+// configurado, pelo nome do campo; a recarga compara com os do
+// communications manager vigente para detectar certificados trocados no
+// mesmo caminho. Arquivo ilegível fica com digest vazio (Validate o recusa).
+func tlsFileDigests(c *Config) map[string]string {
+	files := map[string]string{
+		"tls_client_cert_file": c.TLSClientCertFile,
+		"tls_client_key_file":  c.TLSClientKeyFile,
+		"tls_ca_cert_file":     c.TLSCACertFile,
+	}
+
+	digests := make(map[string]string, len(files))
+	for key, path := range files {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			digests[key] = ""
+			continue
+		}
+		sum := sha256.Sum256(data)
+		digests[key] = hex.EncodeToString(sum[:])
+	}
+	return digests
+}
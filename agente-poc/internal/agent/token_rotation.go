@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+)
+
+// handleRotateTokenCommand instala um novo token secundário em tempo de
+// execução. Options: "token" (novo token) e "signature", o HMAC calculado com
+// o token ativo (ver comms.TokenRotationSignature). O novo token só passa a
+// ser o ativo quando o backend recusar o atual com 401.
+//
+// O token instalado vale até o agente reiniciar; a configuração deve receber
+// a nova lista de tokens antes disso.
+func (a *Agent) handleRotateTokenCommand(command *comms.Command) {
+	startTime := time.Now()
+
+	finish := func(status comms.CommandStatus, output string, err error) {
+		result := &comms.CommandResult{
+			ID:            command.ID,
+			CommandID:     command.ID,
+			Status:        status,
+			Output:        output,
+			ExecutionTime: time.Since(startTime).Milliseconds(),
+			Timestamp:     time.Now(),
+		}
+		if err != nil {
+			result.ExitCode = -1
+			result.SetError(err)
+		}
+		a.sendCommandResult(result)
+	}
+
+	token, _ := command.Options["token"].(string)
+	if token == "" {
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeTokenRequired))
+		return
+	}
+
+	signature, _ := command.Options["signature"].(string)
+	if !comms.VerifyTokenRotation(a.comms().ActiveToken(), command.ID, token, signature) {
+		a.logger.WithField("command_id", command.ID).Warning("rotate_token rejected: invalid signature")
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeInvalidSignature))
+		return
+	}
+
+	tokenID := comms.TokenFingerprint(token)
+	installed := a.comms().InstallToken(token)
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "token_installed", "Secondary backend token installed",
+		map[string]interface{}{
+			"command_id": command.ID,
+			"token_id":   tokenID,
+			"installed":  installed,
+		})
+
+	output := fmt.Sprintf("token %s installed as secondary", tokenID)
+	if !installed {
+		output = fmt.Sprintf("token %s already configured", tokenID)
+	}
+	finish(comms.StatusSuccess, output, nil)
+}
+
+// tokenStatus retorna os IDs dos tokens do backend (nunca os valores)
+func (a *Agent) tokenStatus() comms.TokenStatus {
+	if a.comms() == nil {
+		return comms.TokenStatus{}
+	}
+	return a.comms().TokenStatus()
+}
@@ -0,0 +1,404 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/version"
+)
+
+// Limites do comando update
+const (
+	updateMaxBytes        = 256 * 1024 * 1024
+	updateDownloadTimeout = 10 * time.Minute
+	// updateSmokeTimeout é quanto o binário novo tem para responder a -version
+	updateSmokeTimeout = 10 * time.Second
+)
+
+// Sufixos dos arquivos ao lado do executável: o binário baixado (trocado por
+// rename, que só é atômico no mesmo diretório) e a cópia do anterior,
+// mantida até o novo iniciar para permitir o rollback
+const (
+	updateStagingSuffix = ".new"
+	updateBackupSuffix  = ".previous"
+)
+
+// pendingUpdateFile fica no DataDir entre a troca do binário e a primeira
+// inicialização do novo
+const pendingUpdateFile = "update_pending.json"
+
+// sha256Pattern valida options.sha256
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// PendingUpdate é o registro de uma atualização aplicada e ainda não
+// confirmada. O binário novo o confirma no Start; se o Start falhar,
+// RollbackUpdate devolve o anterior e marca RolledBack para o próximo
+// processo reportar.
+type PendingUpdate struct {
+	CommandID       string    `json:"command_id"`
+	Version         string    `json:"version"`
+	PreviousVersion string    `json:"previous_version"`
+	Executable      string    `json:"executable"`
+	Backup          string    `json:"backup"`
+	Timestamp       time.Time `json:"timestamp"`
+	RolledBack      bool      `json:"rolled_back,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// updateRequest são as options do comando update
+type updateRequest struct {
+	URL     string
+	SHA256  string
+	Version string
+}
+
+// handleUpdateCommand atualiza o binário do agente. Options: "url" (absoluta
+// ou caminho no backend), "sha256" (hex do binário) e "version" (a versão que
+// o binário novo informa em -version). O download, a verificação e a troca
+// rodam fora do processamento de comandos; o resultado é enviado antes do
+// restart.
+func (a *Agent) handleUpdateCommand(command *comms.Command) {
+	startTime := time.Now()
+
+	finish := func(status comms.CommandStatus, output string, err error) {
+		result := &comms.CommandResult{
+			ID:            command.ID,
+			CommandID:     command.ID,
+			Status:        status,
+			Output:        output,
+			ExecutionTime: time.Since(startTime).Milliseconds(),
+			Timestamp:     time.Now(),
+		}
+		if err != nil {
+			result.ExitCode = -1
+			result.SetError(err)
+		}
+		a.sendCommandResult(result)
+	}
+
+	request := updateRequest{}
+	request.URL, _ = command.Options["url"].(string)
+	request.SHA256, _ = command.Options["sha256"].(string)
+	request.Version, _ = command.Options["version"].(string)
+
+	switch {
+	case request.URL == "":
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeInvalidUpdate, "url option is required"))
+		return
+	case !sha256Pattern.MatchString(request.SHA256):
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeInvalidUpdate, "sha256 option must be 64 hex characters"))
+		return
+	case request.Version == "":
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeInvalidUpdate, "version option is required"))
+		return
+	}
+
+	if request.Version == version.Version {
+		finish(comms.StatusSuccess, fmt.Sprintf("already running version %s", version.Version), nil)
+		return
+	}
+	if a.comms() == nil {
+		finish(comms.StatusRejected, "", comms.NewCodedError(comms.ErrCodeInvalidUpdate, "communications manager not running"))
+		return
+	}
+	if !a.updating.CompareAndSwap(false, true) {
+		finish(comms.StatusRejectedBusy, "", comms.NewCodedError(comms.ErrCodeUpdateInProgress))
+		return
+	}
+
+	go func() {
+		if err := a.applyUpdate(command.ID, request); err != nil {
+			a.updating.Store(false)
+			a.logger.WithFields(map[string]interface{}{
+				"command_id": command.ID,
+				"version":    request.Version,
+				"error":      err.Error(),
+			}).Error("Agent update failed")
+			finish(comms.StatusError, "", err)
+			return
+		}
+
+		mode := "exec"
+		if RunningUnderSupervisor() {
+			mode = "supervisor"
+		}
+		finish(comms.StatusSuccess, fmt.Sprintf("updated from %s to %s; restarting (mode: %s)", version.Version, request.Version, mode), nil)
+
+		select {
+		case <-time.After(restartResultDelay):
+		case <-a.ctx.Done():
+			return
+		}
+		select {
+		case a.restartChan <- RestartRequest{Reason: "update", CommandID: command.ID, Timestamp: time.Now()}:
+		default:
+			a.logger.Warning("Restart already pending, ignoring request")
+		}
+	}()
+}
+
+// applyUpdate baixa, verifica e instala o binário novo, deixando o registro
+// de atualização pendente. Qualquer falha mantém o binário atual.
+func (a *Agent) applyUpdate(commandID string, request updateRequest) error {
+	executable, err := currentExecutable()
+	if err != nil {
+		return err
+	}
+	staged := executable + updateStagingSuffix
+	backup := executable + updateBackupSuffix
+
+	ctx, cancel := context.WithTimeout(a.ctx, updateDownloadTimeout)
+	defer cancel()
+
+	a.logger.WithFields(map[string]interface{}{
+		"command_id": commandID,
+		"version":    request.Version,
+		"url":        request.URL,
+	}).Info("Downloading agent update")
+
+	if err := downloadUpdate(ctx, a.comms().Download, request, staged); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	if err := smokeTestBinary(ctx, staged, request.Version); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	if err := swapExecutable(executable, staged, backup); err != nil {
+		os.Remove(staged)
+		return err
+	}
+
+	pending := PendingUpdate{
+		CommandID:       commandID,
+		Version:         request.Version,
+		PreviousVersion: version.Version,
+		Executable:      executable,
+		Backup:          backup,
+		Timestamp:       time.Now(),
+	}
+	if err := writePendingUpdate(a.config.DataDir, pending); err != nil {
+		// Sem o registro não haveria rollback: voltar ao binário atual
+		if rollbackErr := restoreExecutable(executable, backup); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "agent_update_installed", "Agent update installed, restarting",
+		map[string]interface{}{
+			"command_id":       commandID,
+			"version":          request.Version,
+			"previous_version": version.Version,
+		})
+	return nil
+}
+
+// downloadUpdate grava o download em staged (0755) conferindo o SHA-256
+func downloadUpdate(ctx context.Context, download func(context.Context, string, io.Writer, int64) (int64, error), request updateRequest, staged string) error {
+	file, err := os.OpenFile(staged, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create staging file: %w", err)
+	}
+
+	hash := sha256.New()
+	if _, err := download(ctx, request.URL, io.MultiWriter(file, hash), updateMaxBytes); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write staging file: %w", err)
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, request.SHA256) {
+		return comms.NewCodedError(comms.ErrCodeChecksumMismatch, strings.ToLower(request.SHA256), got)
+	}
+	return nil
+}
+
+// smokeTestBinary executa o binário novo com -version e confere a versão
+// informada; pega binários de outra plataforma ou corrompidos antes da troca
+func smokeTestBinary(ctx context.Context, path, expected string) error {
+	ctx, cancel := context.WithTimeout(ctx, updateSmokeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("new binary failed to run: %w", err)
+	}
+	if !containsField(string(output), expected) {
+		return comms.NewCodedError(comms.ErrCodeInvalidUpdate,
+			fmt.Sprintf("new binary reports %q, expected version %s", strings.TrimSpace(string(output)), expected))
+	}
+	return nil
+}
+
+// containsField indica se value aparece como palavra inteira em text
+func containsField(text, value string) bool {
+	for _, field := range strings.Fields(text) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
+
+// swapExecutable move o executável atual para backup e o binário novo para
+// o lugar dele. Os dois renames são no mesmo diretório; o executável em uso
+// pode ser renomeado também no Windows, onde não pode ser sobrescrito. Se o
+// segundo rename falhar, o anterior volta.
+func swapExecutable(executable, staged, backup string) error {
+	if err := os.Remove(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove old backup: %w", err)
+	}
+	if err := os.Rename(executable, backup); err != nil {
+		return fmt.Errorf("failed to back up current binary: %w", err)
+	}
+	if err := os.Rename(staged, executable); err != nil {
+		if rollbackErr := os.Rename(backup, executable); rollbackErr != nil {
+			return fmt.Errorf("failed to install new binary: %w (rollback failed: %v)", err, rollbackErr)
+		}
+		return fmt.Errorf("failed to install new binary: %w", err)
+	}
+	return nil
+}
+
+// restoreExecutable devolve o backup ao lugar do executável. No Windows o
+// binário novo pode estar em uso; ele é renomeado antes de ser substituído.
+func restoreExecutable(executable, backup string) error {
+	failed := executable + updateStagingSuffix
+	os.Remove(failed)
+	if err := os.Rename(executable, failed); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to move new binary aside: %w", err)
+	}
+	if err := os.Rename(backup, executable); err != nil {
+		return fmt.Errorf("failed to restore previous binary: %w", err)
+	}
+	os.Remove(failed)
+	return nil
+}
+
+// currentExecutable é o caminho real do binário em execução
+func currentExecutable() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(executable)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable: %w", err)
+	}
+	return resolved, nil
+}
+
+// writePendingUpdate grava o registro em dataDir, via arquivo temporário
+func writePendingUpdate(dataDir string, pending PendingUpdate) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pending update: %w", err)
+	}
+
+	path := filepath.Join(dataDir, pendingUpdateFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write pending update: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write pending update: %w", err)
+	}
+	return nil
+}
+
+// readPendingUpdate lê o registro; nil sem atualização pendente
+func readPendingUpdate(dataDir string) (*PendingUpdate, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, pendingUpdateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pending PendingUpdate
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("invalid pending update record: %w", err)
+	}
+	return &pending, nil
+}
+
+// RollbackUpdate devolve o binário anterior quando o novo não inicia: o
+// processo novo chama após um Start com erro, e o anterior quando não
+// consegue executar o novo no restart. Retorna true se houve rollback; o
+// processo deve então reiniciar para rodar o binário restaurado. Sem
+// atualização pendente (ainda não confirmada), não faz nada.
+func RollbackUpdate(dataDir string, cause error) (bool, error) {
+	pending, err := readPendingUpdate(dataDir)
+	if err != nil || pending == nil || pending.RolledBack {
+		return false, err
+	}
+
+	if err := restoreExecutable(pending.Executable, pending.Backup); err != nil {
+		return false, err
+	}
+
+	pending.RolledBack = true
+	if cause != nil {
+		pending.Error = cause.Error()
+	}
+	return true, writePendingUpdate(dataDir, *pending)
+}
+
+// confirmPendingUpdate reporta, no Start, o desfecho de uma atualização: o
+// binário novo iniciou (remove o backup) ou foi revertido por RollbackUpdate
+func (a *Agent) confirmPendingUpdate() {
+	pending, err := readPendingUpdate(a.config.DataDir)
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Failed to read pending update record")
+		return
+	}
+	if pending == nil {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"command_id":       pending.CommandID,
+		"version":          pending.Version,
+		"previous_version": pending.PreviousVersion,
+	}
+	switch {
+	case pending.RolledBack:
+		fields["error"] = pending.Error
+		a.recordEvent(events.CategoryAlert, events.SeverityError, "agent_update_rolled_back",
+			"Agent update failed to start and was rolled back", fields)
+	case pending.Version == version.Version:
+		if err := os.Remove(pending.Backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+			a.logger.WithField("error", err).Warning("Failed to remove previous agent binary")
+		}
+		a.recordEvent(events.CategoryAgent, events.SeverityInfo, "agent_updated", "Agent updated", fields)
+	default:
+		// Outro binário foi instalado por fora; o backup fica para inspeção
+		fields["running_version"] = version.Version
+		a.recordEvent(events.CategoryAlert, events.SeverityWarning, "agent_update_not_applied",
+			"Agent restarted without the updated binary", fields)
+	}
+
+	if err := os.Remove(filepath.Join(a.config.DataDir, pendingUpdateFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		a.logger.WithField("error", err).Warning("Failed to remove pending update record")
+	}
+}
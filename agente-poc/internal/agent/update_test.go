@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/version"
+)
+
+// fakeAgentBinary é um "binário" que responde a -version como o agente
+func fakeAgentBinary(reported string) []byte {
+	return []byte("#!/bin/sh\necho \"agente " + reported + "\"\n")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// updateServer serve o binário novo em /updates/agente
+func updateServer(t *testing.T, binary []byte) *httptest.Server {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/agente" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(binary)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// installFixture cria o executável "atual" em um diretório temporário
+func installFixture(t *testing.T) (executable, staged, backup string) {
+	t.Helper()
+	executable = filepath.Join(t.TempDir(), "agente")
+	if err := os.WriteFile(executable, fakeAgentBinary("1.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return executable, executable + updateStagingSuffix, executable + updateBackupSuffix
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDownloadUpdateVerifiesChecksum(t *testing.T) {
+	binary := fakeAgentBinary("2.0.0")
+	server := updateServer(t, binary)
+	a, _ := newTestAgent(t, map[string]interface{}{"backend_url": server.URL})
+	manager, err := a.newComms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, staged, _ := installFixture(t)
+
+	// Caminho relativo ao backend, checksum em maiúsculas
+	request := updateRequest{URL: "/updates/agente", SHA256: strings.ToUpper(sha256Hex(binary)), Version: "2.0.0"}
+	if err := downloadUpdate(context.Background(), manager.Download, request, staged); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, staged); got != string(binary) {
+		t.Fatalf("staged binary = %q", got)
+	}
+	if info, _ := os.Stat(staged); runtime.GOOS != "windows" && info.Mode().Perm() != 0o755 {
+		t.Fatalf("staged mode = %s", info.Mode().Perm())
+	}
+
+	request.SHA256 = sha256Hex([]byte("something else"))
+	err = downloadUpdate(context.Background(), manager.Download, request, staged)
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeChecksumMismatch {
+		t.Fatalf("wrong checksum: %v", err)
+	}
+
+	request.URL = "/updates/missing"
+	var status *comms.HTTPStatusError
+	if err := downloadUpdate(context.Background(), manager.Download, request, staged); !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing binary: %v", err)
+	}
+}
+
+func TestSmokeTestBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the binary")
+	}
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := smokeTestBinary(context.Background(), write("good", fakeAgentBinary("2.0.0")), "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Versão como palavra inteira: 2.0.0 não aceita 2.0.0-rc1
+	err := smokeTestBinary(context.Background(), write("rc", fakeAgentBinary("2.0.0-rc1")), "2.0.0")
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeInvalidUpdate {
+		t.Fatalf("wrong version: %v", err)
+	}
+
+	if err := smokeTestBinary(context.Background(), write("broken", []byte("#!/bin/sh\nexit 3\n")), "2.0.0"); err == nil {
+		t.Fatal("failing binary accepted")
+	}
+	if err := smokeTestBinary(context.Background(), write("garbage", []byte{0x00, 0x01, 0x02}), "2.0.0"); err == nil {
+		t.Fatal("non-executable binary accepted")
+	}
+}
+
+func TestSwapExecutable(t *testing.T) {
+	executable, staged, backup := installFixture(t)
+	if err := os.WriteFile(staged, fakeAgentBinary("2.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Um backup antigo é substituído
+	if err := os.WriteFile(backup, []byte("stale"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := swapExecutable(executable, staged, backup); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, executable) != string(fakeAgentBinary("2.0.0")) || readFile(t, backup) != string(fakeAgentBinary("1.0.0")) {
+		t.Fatal("binaries not swapped")
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Fatalf("staging file left behind: %v", err)
+	}
+
+	if err := restoreExecutable(executable, backup); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, executable) != string(fakeAgentBinary("1.0.0")) {
+		t.Fatal("previous binary not restored")
+	}
+	for _, path := range []string{staged, backup} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s left behind after restore: %v", path, err)
+		}
+	}
+}
+
+func TestSwapExecutableWithoutStagedKeepsCurrent(t *testing.T) {
+	executable, staged, backup := installFixture(t)
+
+	if err := swapExecutable(executable, staged, backup); err == nil {
+		t.Fatal("swap without a staged binary succeeded")
+	}
+	if readFile(t, executable) != string(fakeAgentBinary("1.0.0")) {
+		t.Fatal("current binary lost")
+	}
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Fatalf("backup left behind: %v", err)
+	}
+}
+
+func TestRollbackUpdate(t *testing.T) {
+	dataDir := t.TempDir()
+	if rolledBack, err := RollbackUpdate(dataDir, errors.New("start failed")); rolledBack || err != nil {
+		t.Fatalf("rollback without a pending update: %t, %v", rolledBack, err)
+	}
+
+	executable, staged, backup := installFixture(t)
+	if err := os.WriteFile(staged, fakeAgentBinary("2.0.0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := swapExecutable(executable, staged, backup); err != nil {
+		t.Fatal(err)
+	}
+	pending := PendingUpdate{CommandID: "cmd-update", Version: "2.0.0", PreviousVersion: "1.0.0", Executable: executable, Backup: backup}
+	if err := writePendingUpdate(dataDir, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	// O binário novo não iniciou: o anterior volta e o registro guarda a causa
+	rolledBack, err := RollbackUpdate(dataDir, errors.New("start failed"))
+	if !rolledBack || err != nil {
+		t.Fatalf("rollback = %t, %v", rolledBack, err)
+	}
+	if readFile(t, executable) != string(fakeAgentBinary("1.0.0")) {
+		t.Fatal("previous binary not restored")
+	}
+	record, err := readPendingUpdate(dataDir)
+	if err != nil || !record.RolledBack || record.Error != "start failed" {
+		t.Fatalf("pending record = %+v, %v", record, err)
+	}
+
+	// Só uma vez: o processo restaurado não reverte de novo
+	if rolledBack, err := RollbackUpdate(dataDir, errors.New("again")); rolledBack || err != nil {
+		t.Fatalf("second rollback = %t, %v", rolledBack, err)
+	}
+}
+
+func TestConfirmPendingUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    string
+		rolledBack bool
+		wantEvent  string
+		keepBackup bool
+	}{
+		{name: "new binary started", version: version.Version, wantEvent: "agent_updated"},
+		{name: "rolled back", version: "2.0.0", rolledBack: true, wantEvent: "agent_update_rolled_back", keepBackup: true},
+		{name: "other binary", version: "2.0.0", wantEvent: "agent_update_not_applied", keepBackup: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestAgent(t, nil)
+			backup := filepath.Join(t.TempDir(), "agente"+updateBackupSuffix)
+			if err := os.WriteFile(backup, []byte("old"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			pending := PendingUpdate{CommandID: "cmd-update", Version: tt.version, PreviousVersion: "1.0.0", Backup: backup, RolledBack: tt.rolledBack}
+			if err := writePendingUpdate(a.config.DataDir, pending); err != nil {
+				t.Fatal(err)
+			}
+
+			a.confirmPendingUpdate()
+			event := waitForEvent(t, a, tt.wantEvent)
+			if event.Data["command_id"] != "cmd-update" {
+				t.Fatalf("event data = %v", event.Data)
+			}
+			if _, err := os.Stat(backup); (err == nil) != tt.keepBackup {
+				t.Fatalf("backup kept = %t, want %t", err == nil, tt.keepBackup)
+			}
+			if record, _ := readPendingUpdate(a.config.DataDir); record != nil {
+				t.Fatalf("pending record not removed: %+v", record)
+			}
+		})
+	}
+}
+
+func TestUpdateCommandValidation(t *testing.T) {
+	a, _ := newTestAgent(t, nil)
+	valid := sha256Hex([]byte("binary"))
+
+	tests := []struct {
+		name       string
+		options    map[string]interface{}
+		wantStatus comms.CommandStatus
+	}{
+		{name: "no url", options: map[string]interface{}{"sha256": valid, "version": "2.0.0"}, wantStatus: comms.StatusRejected},
+		{name: "bad sha256", options: map[string]interface{}{"url": "/u", "sha256": "abc", "version": "2.0.0"}, wantStatus: comms.StatusRejected},
+		{name: "no version", options: map[string]interface{}{"url": "/u", "sha256": valid}, wantStatus: comms.StatusRejected},
+		{name: "same version", options: map[string]interface{}{"url": "/u", "sha256": valid, "version": version.Version}, wantStatus: comms.StatusSuccess},
+		{name: "no communications manager", options: map[string]interface{}{"url": "/u", "sha256": valid, "version": "2.0.0"}, wantStatus: comms.StatusRejected},
+	}
+	for i, tt := range tests {
+		a.handleUpdateCommand(&comms.Command{ID: "cmd-update", Type: "update", Options: tt.options})
+		statuses := executedStatuses(t, a)
+		if len(statuses) != i+1 || statuses[i] != string(tt.wantStatus) {
+			t.Fatalf("%s: statuses = %v, want %s last", tt.name, statuses, tt.wantStatus)
+		}
+	}
+	if a.updating.Load() {
+		t.Fatal("rejected update left the agent updating")
+	}
+}
+
+// executedStatuses lista, em ordem, o status de cada command_executed
+func executedStatuses(t *testing.T, a *Agent) []string {
+	t.Helper()
+	countEvents(t, a, "command_executed")
+	var statuses []string
+	for _, event := range a.recentEvents.Since(time.Time{}, 0) {
+		if event.Type == "command_executed" {
+			statuses = append(statuses, fmt.Sprint(event.Data["status"]))
+		}
+	}
+	return statuses
+}
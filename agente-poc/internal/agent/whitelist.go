@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/executor"
+)
+
+// whitelistStateFile guarda no data_dir as atualizações assinadas da
+// whitelist, reaplicadas pelo executor ao iniciar
+const whitelistStateFile = "whitelist.json"
+
+// handleWhitelistUpdate aplica uma atualização assinada da whitelist
+// (mensagem whitelist_update). Qualquer recusa, inclusive por versão
+// antiga, vira evento de segurança: só o backend com a chave privada
+// consegue produzir uma atualização válida.
+func (a *Agent) handleWhitelistUpdate(update *comms.WhitelistUpdate) {
+	if a.executor == nil {
+		return
+	}
+
+	status, err := a.executor.ApplyWhitelistUpdate(executor.SignedWhitelistUpdate{
+		Payload:   update.Payload,
+		Signature: update.Signature,
+	})
+	if err != nil {
+		a.logger.WithField("error", err).Warning("Whitelist update rejected")
+		a.recordSecurityEvent("whitelist_update_rejected", "Whitelist update rejected", map[string]interface{}{
+			"error":          err,
+			"active_version": a.executor.WhitelistStatus().Version,
+		})
+		return
+	}
+
+	a.recordEvent(events.CategoryAgent, events.SeverityInfo, "whitelist_updated", "Command whitelist updated", map[string]interface{}{
+		"version":  status.Version,
+		"commands": status.Commands,
+	})
+}
+
+// whitelistStatus descreve a whitelist vigente para o health
+func (a *Agent) whitelistStatus() *executor.WhitelistStatus {
+	if a.executor == nil {
+		return nil
+	}
+	status := a.executor.WhitelistStatus()
+	return &status
+}
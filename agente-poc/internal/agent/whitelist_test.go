@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/events"
+	"agente-poc/internal/executor"
+)
+
+func TestWhitelistUpdateVersionInHeartbeat(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newCapabilitiesTestAgent(t, map[string]interface{}{
+		"whitelist_public_keys": []string{base64.StdEncoding.EncodeToString(public)},
+	})
+	sign := func(update executor.WhitelistUpdate) *comms.WhitelistUpdate {
+		update.ExpiresAt = time.Now().Add(time.Hour)
+		payload, err := json.Marshal(update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &comms.WhitelistUpdate{
+			Payload:   string(payload),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(private, payload)),
+		}
+	}
+
+	// Só a embutida: versão 0 no heartbeat
+	if version := a.heartbeatExtras()["whitelist_version"]; version != int64(0) {
+		t.Fatalf("whitelist_version before any update = %v", version)
+	}
+
+	a.handleWhitelistUpdate(sign(executor.WhitelistUpdate{Version: 7, Commands: map[string]executor.CommandSpec{"lsblk": {}}}))
+	event := waitForEvent(t, a, "whitelist_updated")
+	if event.Data["version"] != int64(7) {
+		t.Fatalf("whitelist_updated event = %+v", event)
+	}
+	if version := a.heartbeatExtras()["whitelist_version"]; version != int64(7) {
+		t.Fatalf("whitelist_version after the update = %v", version)
+	}
+
+	// Assinatura inválida: evento de segurança e versão mantida
+	forged := sign(executor.WhitelistUpdate{Version: 8, Commands: map[string]executor.CommandSpec{"lsusb": {}}})
+	forged.Payload = `{"version":9,"commands":{"lsusb":{}}}`
+	a.handleWhitelistUpdate(forged)
+	rejected := waitForEvent(t, a, "whitelist_update_rejected")
+	if rejected.Category != events.CategorySecurity {
+		t.Fatalf("whitelist_update_rejected event = %+v", rejected)
+	}
+	if version := a.heartbeatExtras()["whitelist_version"]; version != int64(7) {
+		t.Fatalf("whitelist_version after a forged update = %v", version)
+	}
+
+	// O data_dir guarda a versão para o próximo início
+	restarted, err := executor.New(a.config.ExecutorConfig(a.logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := restarted.WhitelistStatus(); status.Version != 7 {
+		t.Fatalf("whitelist restored from the data_dir = %+v", status)
+	}
+}
@@ -0,0 +1,700 @@
+// Package audit mantém o log de auditoria dos comandos: um arquivo JSON
+// Lines só de acréscimo em que cada entrada leva o hash SHA-256 da anterior,
+// de modo que editar, remover ou reordenar entradas quebra a cadeia.
+//
+// Os arquivos rotacionados além do limite são apagados do mais antigo para
+// o mais novo. O fim da cadeia apagada fica no âncora (audit-anchor.json) e
+// numa entrada de retenção gravada na própria cadeia, de onde Verify passa
+// a conferir.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de entrada
+const (
+	KindCommand   = "command"
+	KindRotation  = "rotation"  // primeira entrada de um arquivo novo
+	KindRetention = "retention" // arquivos antigos apagados; ver Entry.Anchor
+)
+
+// currentFile é o arquivo em uso; os rotacionados são audit-000001.jsonl,
+// audit-000002.jsonl... em ordem de criação
+const (
+	currentFile     = "audit.jsonl"
+	rotatedPrefix   = "audit-"
+	rotatedSuffix   = ".jsonl"
+	rotatedNameSize = 6
+	anchorFile      = "audit-anchor.json"
+)
+
+// DefaultMaxBytes é o tamanho a partir do qual o arquivo é rotacionado
+const DefaultMaxBytes = 10 * 1024 * 1024
+
+// DefaultMaxFiles é quantos arquivos rotacionados são mantidos
+const DefaultMaxFiles = 10
+
+// GenesisHash é o prev_hash da primeira entrada do log
+var GenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// Entry é uma linha do log. Hash é o SHA-256 do JSON da própria entrada sem
+// o campo hash, o que inclui PrevHash e encadeia as entradas.
+type Entry struct {
+	Seq       int64     `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+
+	CommandID string   `json:"command_id,omitempty"`
+	Type      string   `json:"type,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Args      []string `json:"args,omitempty"`   // após a sanitização
+	Origin    string   `json:"origin,omitempty"` // ver comms.CommandOrigin*
+	Status    string   `json:"status,omitempty"`
+	ExitCode  int      `json:"exit_code"`
+	ErrorCode string   `json:"error_code,omitempty"`
+
+	// Rotação: arquivo anterior e, se ele não pôde ser lido até o fim, o motivo
+	PrevFile string `json:"prev_file,omitempty"`
+	Note     string `json:"note,omitempty"`
+
+	// Retenção: última entrada dos arquivos apagados, a mesma do âncora
+	Anchor *Head `json:"anchor,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// computeHash calcula o hash da entrada, ignorando o campo Hash
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Head é o fim da cadeia: a última entrada gravada
+type Head struct {
+	File string `json:"file"`
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// FileHead resume um arquivo do log: o intervalo de entradas e o hash
+// final, que a rotação grava na primeira entrada do arquivo seguinte
+type FileHead struct {
+	File     string `json:"file"`
+	FirstSeq int64  `json:"first_seq"`
+	LastSeq  int64  `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+}
+
+// Log grava as entradas no diretório dir. Seguro para uso concorrente; um
+// único processo deve escrever em cada diretório.
+type Log struct {
+	dir      string
+	maxBytes int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	head Head
+
+	// Heads dos arquivos, lidos uma vez no Open e mantidos a cada entrada
+	rotated []FileHead
+	current FileHead
+	anchor  *Head
+}
+
+// Open abre (ou cria) o log em dir e retoma a cadeia da última entrada. Um
+// arquivo atual com linhas ilegíveis é rotacionado sem ser alterado, e a
+// cadeia continua da última entrada válida com o motivo anotado; a falha
+// continua aparecendo em Verify. maxBytes <= 0 usa DefaultMaxBytes e
+// maxFiles <= 0 usa DefaultMaxFiles.
+func Open(dir string, maxBytes int64, maxFiles int) (*Log, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	l := &Log{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles, head: Head{Hash: GenesisHash}}
+
+	// Um âncora ilegível não impede a gravação: Verify aponta a falha, e a
+	// próxima retenção grava um novo a partir da cadeia
+	if anchor, err := readAnchor(dir); err == nil {
+		l.anchor = anchor
+		l.head = *anchor
+	}
+
+	rotated, err := rotatedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	// A retenção mais recente registrada na cadeia; se o âncora não chegou a
+	// ser gravado, a retenção é concluída abaixo
+	var retained *Head
+	for _, name := range rotated {
+		entries, _ := readEntries(filepath.Join(dir, name))
+		l.rotated = append(l.rotated, summarize(name, entries))
+		if last := latestAnchor(entries); last != nil {
+			retained = last
+		}
+		if len(entries) > 0 {
+			final := entries[len(entries)-1]
+			l.head = Head{File: name, Seq: final.Seq, Hash: final.Hash}
+		}
+	}
+
+	path := filepath.Join(dir, currentFile)
+	entries, readErr := readEntries(path)
+	l.current = summarize(currentFile, entries)
+	if last := latestAnchor(entries); last != nil {
+		retained = last
+	}
+	if len(entries) > 0 {
+		final := entries[len(entries)-1]
+		l.head = Head{File: currentFile, Seq: final.Seq, Hash: final.Hash}
+	}
+
+	if err := l.openLocked(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case readErr != nil && !os.IsNotExist(readErr):
+		if err := l.rotateLocked(fmt.Sprintf("previous file unreadable after seq %d: %v", l.head.Seq, readErr)); err != nil {
+			l.Close()
+			return nil, err
+		}
+	case l.size == 0 && len(rotated) > 0:
+		// Rotação interrompida entre o rename e a entrada de rotação
+		if _, err := l.appendLocked(Entry{Kind: KindRotation, PrevFile: l.head.File}); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	// Retenção interrompida antes do âncora ou da remoção dos arquivos; uma
+	// falha aqui fica para a próxima rotação
+	if retained != nil && (l.anchor == nil || retained.Seq > l.anchor.Seq) {
+		_ = l.applyAnchorLocked(*retained)
+	} else if l.anchor != nil {
+		_ = l.applyAnchorLocked(*l.anchor)
+	}
+	_ = l.pruneLocked()
+	return l, nil
+}
+
+// Append grava uma entrada, preenchendo Seq, Timestamp (se vazio), PrevHash e
+// Hash, e a sincroniza em disco antes de retornar
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return Entry{}, fmt.Errorf("audit log is closed")
+	}
+	// Uma rotação que falha não perde a entrada: ela vai para o arquivo atual
+	if l.size >= l.maxBytes {
+		if err := l.rotateLocked(""); err != nil && l.file == nil {
+			return Entry{}, err
+		}
+	}
+	if entry.Kind == "" {
+		entry.Kind = KindCommand
+	}
+	return l.appendLocked(entry)
+}
+
+// appendLocked encadeia a entrada no fim do arquivo atual
+func (l *Log) appendLocked(entry Entry) (Entry, error) {
+	entry.Seq = l.head.Seq + 1
+	entry.PrevHash = l.head.Hash
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	// UTC para que o JSON relido produza o mesmo hash
+	entry.Timestamp = entry.Timestamp.UTC()
+
+	hash, err := entry.computeHash()
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to hash audit entry: %w", err)
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return Entry{}, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.head = Head{File: currentFile, Seq: entry.Seq, Hash: entry.Hash}
+	if l.current.FirstSeq == 0 {
+		l.current.FirstSeq = entry.Seq
+	}
+	l.current.LastSeq, l.current.LastHash = entry.Seq, entry.Hash
+	return entry, nil
+}
+
+// rotateLocked renomeia o arquivo atual para o próximo audit-NNNNNN.jsonl e
+// abre um novo, cuja primeira entrada aponta para o anterior e carrega o
+// hash final dele. Depois aplica a retenção (ver pruneLocked).
+func (l *Log) rotateLocked(note string) error {
+	next := 1
+	if len(l.rotated) > 0 {
+		next = rotatedIndex(l.rotated[len(l.rotated)-1].File) + 1
+	} else if l.anchor != nil {
+		next = rotatedIndex(l.anchor.File) + 1
+	}
+	name := rotatedName(next)
+
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.file = nil
+	if err := os.Rename(filepath.Join(l.dir, currentFile), filepath.Join(l.dir, name)); err != nil {
+		// Segue no arquivo atual; a rotação é tentada de novo na próxima entrada
+		if openErr := l.openLocked(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := l.openLocked(); err != nil {
+		return err
+	}
+	if l.head.File == currentFile {
+		l.head.File = name
+	}
+	l.current.File = name
+	l.rotated = append(l.rotated, l.current)
+	l.current = FileHead{File: currentFile}
+	if _, err := l.appendLocked(Entry{Kind: KindRotation, PrevFile: name, Note: note}); err != nil {
+		return err
+	}
+	return l.pruneLocked()
+}
+
+// pruneLocked apaga os arquivos rotacionados além de maxFiles. Antes de
+// apagar, grava na cadeia uma entrada de retenção com a última entrada dos
+// arquivos que saem; o âncora repete essa entrada e é de onde Verify começa.
+func (l *Log) pruneLocked() error {
+	excess := len(l.rotated) - l.maxFiles
+	if excess <= 0 {
+		return nil
+	}
+
+	removed := l.rotated[:excess]
+	anchor := Head{File: removed[len(removed)-1].File, Seq: l.anchorSeq(), Hash: GenesisHash}
+	if l.anchor != nil {
+		anchor.Hash = l.anchor.Hash
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		if removed[i].LastSeq > 0 {
+			anchor.Seq, anchor.Hash = removed[i].LastSeq, removed[i].LastHash
+			break
+		}
+	}
+
+	_, err := l.appendLocked(Entry{
+		Kind:   KindRetention,
+		Anchor: &anchor,
+		Note:   fmt.Sprintf("removed %d file(s) up to %s", excess, anchor.File),
+	})
+	if err != nil {
+		return err
+	}
+	return l.applyAnchorLocked(anchor)
+}
+
+// anchorSeq é a seq do âncora atual (0 sem âncora)
+func (l *Log) anchorSeq() int64 {
+	if l.anchor == nil {
+		return 0
+	}
+	return l.anchor.Seq
+}
+
+// applyAnchorLocked grava o âncora e apaga os arquivos rotacionados que ele
+// cobre. Os que não puderam ser apagados ficam na lista e são tentados de
+// novo; Verify já os ignora.
+func (l *Log) applyAnchorLocked(anchor Head) error {
+	if l.anchor == nil || *l.anchor != anchor {
+		if err := writeAnchor(l.dir, anchor); err != nil {
+			return err
+		}
+		l.anchor = &anchor
+	}
+
+	var firstErr error
+	limit := rotatedIndex(anchor.File)
+	kept := make([]FileHead, 0, len(l.rotated))
+	for _, head := range l.rotated {
+		if rotatedIndex(head.File) <= limit {
+			err := os.Remove(filepath.Join(l.dir, head.File))
+			if err == nil || os.IsNotExist(err) {
+				continue
+			}
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to remove %s: %w", head.File, err)
+			}
+		}
+		kept = append(kept, head)
+	}
+	l.rotated = kept
+	return firstErr
+}
+
+// openLocked abre o arquivo atual para acréscimo
+func (l *Log) openLocked() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, currentFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Head retorna a última entrada gravada
+func (l *Log) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Dir retorna o diretório do log
+func (l *Log) Dir() string {
+	return l.dir
+}
+
+// Anchor retorna o fim da cadeia nos arquivos já apagados (nil se nenhum foi)
+func (l *Log) Anchor() *Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.anchor == nil {
+		return nil
+	}
+	anchor := *l.anchor
+	return &anchor
+}
+
+// Recent retorna as últimas limit entradas, da mais antiga para a mais
+// recente, lendo os arquivos rotacionados quando o atual não basta
+func (l *Log) Recent(limit int) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var recent []Entry
+	files := l.filesLocked()
+	for i := len(files) - 1; i >= 0 && len(recent) < limit; i-- {
+		if files[i].LastSeq == 0 {
+			continue
+		}
+		entries, err := readEntries(filepath.Join(l.dir, files[i].File))
+		if err != nil && len(entries) == 0 {
+			return nil, err
+		}
+		if missing := limit - len(recent); len(entries) > missing {
+			entries = entries[len(entries)-missing:]
+		}
+		recent = append(entries, recent...)
+	}
+	return recent, nil
+}
+
+// FileHeads resume cada arquivo do log, do mais antigo ao atual, a partir
+// dos heads mantidos em memória, sem reler os arquivos
+func (l *Log) FileHeads() []FileHead {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.filesLocked()
+}
+
+// filesLocked copia os heads dos arquivos rotacionados e do atual
+func (l *Log) filesLocked() []FileHead {
+	files := make([]FileHead, 0, len(l.rotated)+1)
+	files = append(files, l.rotated...)
+	return append(files, l.current)
+}
+
+// Close fecha o arquivo atual
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// VerifyResult é o resultado de Verify. Em uma falha, File, Line (a partir
+// de 1) e Seq apontam a primeira entrada que não confere.
+type VerifyResult struct {
+	OK      bool   `json:"ok"`
+	Files   int    `json:"files"`
+	Entries int64  `json:"entries"`
+	Anchor  *Head  `json:"anchor,omitempty"` // início da conferência, se houve retenção
+	Head    *Head  `json:"head,omitempty"`   // última entrada conferida
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Seq     int64  `json:"seq,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Verify confere a cadeia inteira em dir, do primeiro arquivo rotacionado
+// ao atual: sequência, prev_hash, hash de cada entrada e a ligação entre os
+// arquivos. Com âncora, a conferência começa nele, e alguma entrada de
+// retenção da cadeia precisa confirmá-lo. Entradas removidas do fim não
+// quebram a cadeia; para percebê-las, compare Head com o head obtido antes
+// (ex.: pelo get_audit_log).
+func Verify(dir string) (*VerifyResult, error) {
+	files, err := logFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{}
+	prev := Head{Hash: GenesisHash}
+	anchor, err := readAnchor(dir)
+	switch {
+	case err == nil:
+		result.Anchor = anchor
+		prev = *anchor
+		// Arquivos cobertos pelo âncora que ainda não foram apagados
+		limit := rotatedIndex(anchor.File)
+		kept := files[:0]
+		for _, name := range files {
+			if index := rotatedIndex(name); index == 0 || index > limit {
+				kept = append(kept, name)
+			}
+		}
+		files = kept
+	case !os.IsNotExist(err):
+		result.File, result.Reason = anchorFile, err.Error()
+		return result, nil
+	}
+
+	result.Files = len(files)
+	anchorConfirmed := false
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		line := 0
+		for _, raw := range splitLines(data) {
+			line++
+			fail := func(reason string) (*VerifyResult, error) {
+				result.File, result.Line, result.Seq, result.Reason = name, line, prev.Seq+1, reason
+				return result, nil
+			}
+
+			var entry Entry
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&entry); err != nil {
+				return fail(fmt.Sprintf("invalid entry: %v", err))
+			}
+			if entry.Seq != prev.Seq+1 {
+				return fail(fmt.Sprintf("sequence gap: expected %d, got %d", prev.Seq+1, entry.Seq))
+			}
+			if entry.PrevHash != prev.Hash {
+				return fail("chain broken: prev_hash does not match the previous entry")
+			}
+			if line == 1 && prev.File != "" && (entry.Kind != KindRotation || entry.PrevFile != prev.File) {
+				return fail(fmt.Sprintf("missing rotation link to %s", prev.File))
+			}
+			hash, err := entry.computeHash()
+			if err != nil || hash != entry.Hash {
+				return fail("hash mismatch: entry was modified")
+			}
+			if anchor != nil && entry.Kind == KindRetention && entry.Anchor != nil && *entry.Anchor == *anchor {
+				anchorConfirmed = true
+			}
+
+			prev = Head{File: name, Seq: entry.Seq, Hash: entry.Hash}
+			result.Entries++
+		}
+	}
+
+	if anchor != nil && !anchorConfirmed {
+		result.File, result.Reason = anchorFile, "anchor not confirmed by any retention entry"
+		return result, nil
+	}
+	result.OK = true
+	if result.Entries > 0 {
+		result.Head = &prev
+	}
+	return result, nil
+}
+
+// readAnchor lê o âncora de dir; sem âncora, o erro é os.ErrNotExist
+func readAnchor(dir string) (*Head, error) {
+	data, err := os.ReadFile(filepath.Join(dir, anchorFile))
+	if err != nil {
+		return nil, err
+	}
+	var anchor Head
+	if err := json.Unmarshal(data, &anchor); err != nil {
+		return nil, fmt.Errorf("invalid audit anchor: %w", err)
+	}
+	if rotatedIndex(anchor.File) == 0 || anchor.Seq < 0 || anchor.Hash == "" {
+		return nil, fmt.Errorf("invalid audit anchor: %s", data)
+	}
+	return &anchor, nil
+}
+
+// writeAnchor grava o âncora atomicamente (arquivo temporário + rename)
+func writeAnchor(dir string, anchor Head) error {
+	data, err := json.Marshal(anchor)
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(dir, anchorFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write audit anchor: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, anchorFile)); err != nil {
+		return fmt.Errorf("failed to write audit anchor: %w", err)
+	}
+	return nil
+}
+
+// summarize monta o head de um arquivo a partir das entradas lidas
+func summarize(name string, entries []Entry) FileHead {
+	head := FileHead{File: name}
+	if len(entries) > 0 {
+		head.FirstSeq = entries[0].Seq
+		head.LastSeq = entries[len(entries)-1].Seq
+		head.LastHash = entries[len(entries)-1].Hash
+	}
+	return head
+}
+
+// latestAnchor retorna o âncora da última entrada de retenção (nil se não há)
+func latestAnchor(entries []Entry) *Head {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == KindRetention && entries[i].Anchor != nil {
+			return entries[i].Anchor
+		}
+	}
+	return nil
+}
+
+// readEntries lê as entradas de um arquivo até a primeira linha ilegível,
+// retornando as lidas e o erro
+func readEntries(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for i, raw := range splitLines(data) {
+		var entry Entry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return entries, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// splitLines separa as linhas não vazias; uma última linha sem quebra
+// (escrita interrompida) também é retornada, para falhar na leitura
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, append([]byte(nil), line...))
+		}
+	}
+	return lines
+}
+
+// logFiles lista os arquivos do log em ordem: os rotacionados e o atual
+func logFiles(dir string) ([]string, error) {
+	files, err := rotatedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, currentFile)); err == nil {
+		files = append(files, currentFile)
+	}
+	return files, nil
+}
+
+// rotatedFiles lista os audit-NNNNNN.jsonl em ordem de criação
+func rotatedFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list audit log directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if rotatedIndex(entry.Name()) > 0 {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return rotatedIndex(files[i]) < rotatedIndex(files[j]) })
+	return files, nil
+}
+
+// rotatedName monta o nome do arquivo rotacionado de índice n
+func rotatedName(n int) string {
+	return fmt.Sprintf("%s%0*d%s", rotatedPrefix, rotatedNameSize, n, rotatedSuffix)
+}
+
+// rotatedIndex extrai o índice de um nome audit-NNNNNN.jsonl (0 se não for um)
+func rotatedIndex(name string) int {
+	if !strings.HasPrefix(name, rotatedPrefix) || !strings.HasSuffix(name, rotatedSuffix) {
+		return 0
+	}
+	var n int
+	digits := strings.TrimSuffix(strings.TrimPrefix(name, rotatedPrefix), rotatedSuffix)
+	if _, err := fmt.Sscanf(digits, "%d", &n); err != nil || rotatedName(n) != name {
+		return 0
+	}
+	return n
+}
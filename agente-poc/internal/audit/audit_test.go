@@ -0,0 +1,379 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEntries abre o log em dir e grava n comandos
+func writeEntries(t *testing.T, dir string, maxBytes int64, maxFiles, n int) *Log {
+	t.Helper()
+	l, err := Open(dir, maxBytes, maxFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	for i := 0; i < n; i++ {
+		if _, err := l.Append(Entry{CommandID: fmt.Sprintf("cmd-%d", i), Command: "uptime", Status: "success"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// fileLines lê as linhas de um arquivo do log
+func fileLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return splitLines(data)
+}
+
+// writeLines regrava um arquivo do log com as linhas dadas
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	data := append(bytes.Join(lines, []byte("\n")), '\n')
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seqAt retorna a seq gravada na linha (a partir de 1) de um arquivo
+func seqAt(t *testing.T, path string, line int) int64 {
+	t.Helper()
+	var entry Entry
+	if err := json.Unmarshal(fileLines(t, path)[line-1], &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry.Seq
+}
+
+func TestVerifyIntactLog(t *testing.T) {
+	dir := t.TempDir()
+	l := writeEntries(t, dir, 1024, 100, 20)
+
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK {
+		t.Fatalf("intact log failed verification: %+v", result)
+	}
+	if result.Files < 2 {
+		t.Fatalf("log not rotated: %d file(s)", result.Files)
+	}
+	if result.Head == nil || *result.Head != l.Head() {
+		t.Fatalf("verified head %+v, log head %+v", result.Head, l.Head())
+	}
+}
+
+func TestVerifyTamperedEntryIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string // vazio = arquivo atual
+		line   int
+		tamper func(lines [][]byte, line int) [][]byte
+		reason string
+	}{
+		{
+			name: "edited field",
+			line: 3,
+			tamper: func(lines [][]byte, line int) [][]byte {
+				lines[line-1] = bytes.Replace(lines[line-1], []byte(`"uptime"`), []byte(`"reboot"`), 1)
+				return lines
+			},
+			reason: "hash mismatch",
+		},
+		{
+			name: "edited field in a rotated file",
+			file: rotatedName(1),
+			line: 2,
+			tamper: func(lines [][]byte, line int) [][]byte {
+				lines[line-1] = bytes.Replace(lines[line-1], []byte(`"success"`), []byte(`"error"`), 1)
+				return lines
+			},
+			reason: "hash mismatch",
+		},
+		{
+			name: "removed entry",
+			line: 2,
+			tamper: func(lines [][]byte, line int) [][]byte {
+				return append(lines[:line-1], lines[line:]...)
+			},
+			reason: "sequence gap",
+		},
+		{
+			name: "swapped entries",
+			line: 2,
+			tamper: func(lines [][]byte, line int) [][]byte {
+				lines[line-1], lines[line] = lines[line], lines[line-1]
+				return lines
+			},
+			reason: "sequence gap",
+		},
+		{
+			name: "garbage line",
+			line: 2,
+			tamper: func(lines [][]byte, line int) [][]byte {
+				lines[line-1] = []byte("not json")
+				return lines
+			},
+			reason: "invalid entry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := writeEntries(t, dir, 1024, 100, 20)
+			// Linhas suficientes no arquivo atual, depois da última rotação
+			for len(fileLines(t, filepath.Join(dir, currentFile))) < 4 {
+				if _, err := l.Append(Entry{Command: "uptime", Status: "success"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			l.Close()
+
+			file := tt.file
+			if file == "" {
+				file = currentFile
+			}
+			path := filepath.Join(dir, file)
+			if len(fileLines(t, path)) <= tt.line {
+				t.Fatalf("%s has too few lines for the test", file)
+			}
+			wantSeq := seqAt(t, path, tt.line)
+			writeLines(t, path, tt.tamper(fileLines(t, path), tt.line))
+
+			result, err := Verify(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.OK {
+				t.Fatal("tampered log passed verification")
+			}
+			if result.File != file || result.Line != tt.line || result.Seq != wantSeq {
+				t.Fatalf("failure at %s:%d (seq %d), want %s:%d (seq %d): %s",
+					result.File, result.Line, result.Seq, file, tt.line, wantSeq, result.Reason)
+			}
+			if !bytes.Contains([]byte(result.Reason), []byte(tt.reason)) {
+				t.Fatalf("reason %q, want %q", result.Reason, tt.reason)
+			}
+			if result.Entries != wantSeq-1 {
+				t.Fatalf("%d entries verified before the failure, want %d", result.Entries, wantSeq-1)
+			}
+		})
+	}
+}
+
+func TestRetentionKeepsChain(t *testing.T) {
+	dir := t.TempDir()
+	l := writeEntries(t, dir, 1024, 2, 40)
+
+	rotated, err := rotatedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("%d rotated files kept, want 2: %v", len(rotated), rotated)
+	}
+	anchor := l.Anchor()
+	if anchor == nil {
+		t.Fatal("no anchor after retention")
+	}
+	if rotatedIndex(anchor.File) != rotatedIndex(rotated[0])-1 {
+		t.Fatalf("anchor %s does not precede the oldest kept file %s", anchor.File, rotated[0])
+	}
+
+	result, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK {
+		t.Fatalf("pruned log failed verification: %+v", result)
+	}
+	if result.Anchor == nil || *result.Anchor != *anchor {
+		t.Fatalf("verification started at %+v, want %+v", result.Anchor, anchor)
+	}
+	if result.Head == nil || *result.Head != l.Head() {
+		t.Fatalf("verified head %+v, log head %+v", result.Head, l.Head())
+	}
+	if result.Entries != l.Head().Seq-anchor.Seq {
+		t.Fatalf("%d entries verified after the anchor, want %d", result.Entries, l.Head().Seq-anchor.Seq)
+	}
+
+	// A cadeia continua depois de reabrir
+	l.Close()
+	l = writeEntries(t, dir, 1024, 2, 10)
+	if result, err := Verify(dir); err != nil || !result.OK {
+		t.Fatalf("verification after reopening: %+v, %v", result, err)
+	}
+	if recent, err := l.Recent(5); err != nil || len(recent) != 5 || recent[4].Seq != l.Head().Seq {
+		t.Fatalf("recent entries after retention: %d, %v", len(recent), err)
+	}
+}
+
+func TestRetentionDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string, rotated []string)
+	}{
+		{
+			name: "oldest kept file removed",
+			tamper: func(t *testing.T, dir string, rotated []string) {
+				if err := os.Remove(filepath.Join(dir, rotated[0])); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "anchor moved forward",
+			tamper: func(t *testing.T, dir string, rotated []string) {
+				if err := os.Remove(filepath.Join(dir, rotated[0])); err != nil {
+					t.Fatal(err)
+				}
+				lines := fileLines(t, filepath.Join(dir, rotated[1]))
+				var first Entry
+				if err := json.Unmarshal(lines[0], &first); err != nil {
+					t.Fatal(err)
+				}
+				forged := Head{File: rotated[0], Seq: first.Seq - 1, Hash: first.PrevHash}
+				if err := writeAnchor(dir, forged); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "anchor unreadable",
+			tamper: func(t *testing.T, dir string, rotated []string) {
+				if err := os.WriteFile(filepath.Join(dir, anchorFile), []byte("{"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "anchor removed",
+			tamper: func(t *testing.T, dir string, rotated []string) {
+				if err := os.Remove(filepath.Join(dir, anchorFile)); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeEntries(t, dir, 1024, 2, 40).Close()
+			rotated, err := rotatedFiles(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(t, dir, rotated)
+
+			result, err := Verify(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.OK {
+				t.Fatalf("tampered log passed verification: %+v", result)
+			}
+		})
+	}
+}
+
+func TestOpenCompletesInterruptedRetention(t *testing.T) {
+	dir := t.TempDir()
+	writeEntries(t, dir, 1024, 2, 40).Close()
+	anchor, err := readAnchor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupção entre a entrada de retenção e o âncora
+	if err := os.Remove(filepath.Join(dir, anchorFile)); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(dir, 1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if restored := l.Anchor(); restored == nil || *restored != *anchor {
+		t.Fatalf("anchor restored as %+v, want %+v", restored, anchor)
+	}
+	if result, err := Verify(dir); err != nil || !result.OK {
+		t.Fatalf("verification after restoring the anchor: %+v, %v", result, err)
+	}
+}
+
+func TestOpenAppliesLowerRetention(t *testing.T) {
+	dir := t.TempDir()
+	writeEntries(t, dir, 1024, 100, 40).Close()
+	before, _ := rotatedFiles(dir)
+	if len(before) <= 3 {
+		t.Fatalf("only %d rotated files written", len(before))
+	}
+
+	l, err := Open(dir, 1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	after, _ := rotatedFiles(dir)
+	if len(after) != 3 || after[0] != before[len(before)-3] {
+		t.Fatalf("kept %v, want the last 3 of %v", after, before)
+	}
+	if result, err := Verify(dir); err != nil || !result.OK {
+		t.Fatalf("verification after lowering retention: %+v, %v", result, err)
+	}
+}
+
+func TestFileHeadsCached(t *testing.T) {
+	dir := t.TempDir()
+	l := writeEntries(t, dir, 1024, 3, 30)
+
+	check := func(l *Log) {
+		t.Helper()
+		files, err := logFiles(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		heads := l.FileHeads()
+		if len(heads) != len(files) {
+			t.Fatalf("%d cached heads for %d files", len(heads), len(files))
+		}
+		for i, name := range files {
+			entries, err := readEntries(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := summarize(name, entries); heads[i] != want {
+				t.Errorf("cached head %+v, file has %+v", heads[i], want)
+			}
+		}
+	}
+	check(l)
+
+	l.Close()
+	l, err := Open(dir, 1024, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	check(l)
+
+	// Os heads vêm da memória: alterar um arquivo rotacionado não os muda
+	rotated, _ := rotatedFiles(dir)
+	before := l.FileHeads()
+	writeLines(t, filepath.Join(dir, rotated[0]), fileLines(t, filepath.Join(dir, rotated[0]))[:1])
+	if after := l.FileHeads(); after[0] != before[0] {
+		t.Fatalf("file heads re-read from disk: %+v", after[0])
+	}
+}
@@ -0,0 +1,279 @@
+// Package chaos injeta falhas na fronteira de transporte do agente (erros e
+// latência HTTP, quedas do WebSocket, desvio de relógio) para validar replay
+// de filas, circuit breaker e failover em staging sem quebrar a rede.
+//
+// A injeção só é ativada com o bloco "chaos" da configuração habilitado E a
+// variável de ambiente AGENTE_CHAOS=1; nenhum dos dois sozinho liga a
+// injeção, para que não seja ativada por acidente em produção.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
+)
+
+// EnvVar deve valer "1" para a configuração de chaos ser aplicada
+const EnvVar = "AGENTE_CHAOS"
+
+// Tipos de injeção
+const (
+	KindHTTPError    = "http_error"    // resposta HTTP sintética (status_code; 0 = erro de conexão)
+	KindLatency      = "latency"       // atraso antes de cada requisição HTTP
+	KindWSDisconnect = "ws_disconnect" // derruba a conexão WebSocket a cada interval
+	KindClockSkew    = "clock_skew"    // desloca o relógio usado nos envios
+)
+
+// defaultDisconnectInterval é o intervalo entre tentativas de ws_disconnect
+const defaultDisconnectInterval = 30 * time.Second
+
+// Config é o bloco "chaos" da configuração do agente
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// Seed fixa a sequência de sorteios (0 = aleatória)
+	Seed  int64  `json:"seed,omitempty"`
+	Rules []Rule `json:"rules"`
+}
+
+// Rule é uma injeção. A regra fica ativa de start_after (contado do início
+// do agente) até start_after+duration (duration 0 = até o fim); enquanto
+// ativa, cada oportunidade (requisição HTTP, tick de ws_disconnect) injeta
+// com a probabilidade informada.
+type Rule struct {
+	Name        string  `json:"name"`
+	Kind        string  `json:"kind"`
+	Probability float64 `json:"probability"` // 0 = sempre
+	// Endpoint limita http_error/latency a endpoints com este prefixo
+	Endpoint   string           `json:"endpoint,omitempty"`
+	StatusCode int              `json:"status_code,omitempty"`
+	Latency    timeutil.Seconds `json:"latency,omitempty"`
+	Skew       timeutil.Seconds `json:"skew,omitempty"`
+	Interval   timeutil.Seconds `json:"interval,omitempty"`
+	StartAfter timeutil.Seconds `json:"start_after,omitempty"`
+	Duration   timeutil.Seconds `json:"duration,omitempty"`
+}
+
+// Validate verifica as regras; as mensagens seguem as da validação da
+// configuração do agente
+func (c *Config) Validate() []string {
+	var errors []string
+	for i, rule := range c.Rules {
+		prefix := fmt.Sprintf("chaos.rules[%d]", i)
+		switch rule.Kind {
+		case KindHTTPError:
+			if rule.StatusCode != 0 && (rule.StatusCode < 400 || rule.StatusCode > 599) {
+				errors = append(errors, prefix+": status_code deve estar entre 400 e 599 (ou 0 para erro de conexão)")
+			}
+		case KindLatency:
+			if rule.Latency <= 0 {
+				errors = append(errors, prefix+": latency deve ser maior que 0")
+			}
+		case KindClockSkew:
+			if rule.Skew == 0 {
+				errors = append(errors, prefix+": skew não pode ser 0")
+			}
+		case KindWSDisconnect:
+		default:
+			errors = append(errors, prefix+": kind deve ser http_error, latency, ws_disconnect ou clock_skew")
+		}
+		if rule.Probability < 0 || rule.Probability > 1 {
+			errors = append(errors, prefix+": probability deve estar entre 0 e 1")
+		}
+		if rule.StartAfter < 0 || rule.Duration < 0 || rule.Interval < 0 {
+			errors = append(errors, prefix+": start_after, duration e interval não podem ser negativos")
+		}
+	}
+	return errors
+}
+
+// EnabledByEnv indica se a variável de ambiente libera a injeção
+func EnabledByEnv() bool {
+	return os.Getenv(EnvVar) == "1"
+}
+
+// RuleStatus descreve uma regra e suas injeções, para o comando chaos_status
+type RuleStatus struct {
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"`
+	Active         bool      `json:"active"`
+	Injections     int64     `json:"injections"`
+	LastInjectedAt time.Time `json:"last_injected_at,omitempty"`
+	ActiveFrom     time.Time `json:"active_from"`
+	ActiveUntil    time.Time `json:"active_until,omitempty"`
+}
+
+// Status é o estado do injetor
+type Status struct {
+	Enabled bool         `json:"enabled"`
+	Since   time.Time    `json:"since,omitempty"`
+	Rules   []RuleStatus `json:"rules,omitempty"`
+}
+
+// ruleState é uma regra com seus contadores
+type ruleState struct {
+	Rule
+	injections int64
+	lastAt     time.Time
+	skewing    bool // clock_skew em vigor na última leitura
+}
+
+// Injector aplica as regras. Um *Injector nil é válido e não injeta nada.
+type Injector struct {
+	clock  clock.Clock
+	logger logging.Logger
+	start  time.Time
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	rules []*ruleState
+}
+
+// New cria o injetor a partir da configuração; as janelas das regras contam
+// a partir de agora
+func New(config Config, clk clock.Clock, logger logging.Logger) *Injector {
+	clk = clock.OrReal(clk)
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	injector := &Injector{
+		clock:  clk,
+		logger: logger,
+		start:  clk.Now(),
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%s-%d", rule.Kind, i)
+		}
+		injector.rules = append(injector.rules, &ruleState{Rule: rule})
+	}
+	return injector
+}
+
+// activeLocked indica se a regra está na sua janela
+func (i *Injector) activeLocked(rule *ruleState, now time.Time) bool {
+	elapsed := now.Sub(i.start)
+	if elapsed < rule.StartAfter.Duration() {
+		return false
+	}
+	if rule.Duration > 0 && elapsed >= rule.StartAfter.Duration()+rule.Duration.Duration() {
+		return false
+	}
+	return true
+}
+
+// fire sorteia e, se a regra injeta, contabiliza e registra em log
+func (i *Injector) fire(rule *ruleState, fields map[string]interface{}) bool {
+	i.mu.Lock()
+	now := i.clock.Now()
+	if !i.activeLocked(rule, now) {
+		i.mu.Unlock()
+		return false
+	}
+	if rule.Probability > 0 && i.rand.Float64() >= rule.Probability {
+		i.mu.Unlock()
+		return false
+	}
+	rule.injections++
+	rule.lastAt = now
+	count := rule.injections
+	i.mu.Unlock()
+
+	logFields := map[string]interface{}{
+		"chaos_rule": rule.Name,
+		"chaos_kind": rule.Kind,
+		"injections": count,
+	}
+	for key, value := range fields {
+		logFields[key] = value
+	}
+	i.logger.WithFields(logFields).Warning("Chaos: failure injected")
+	return true
+}
+
+// matching retorna as regras de kind que se aplicam ao endpoint
+func (i *Injector) matching(kind, endpoint string) []*ruleState {
+	var rules []*ruleState
+	for _, rule := range i.rules {
+		if rule.Kind != kind {
+			continue
+		}
+		if !strings.HasPrefix(endpoint, rule.Endpoint) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// RunDisconnects executa as regras ws_disconnect até ctx terminar,
+// chamando disconnect a cada injeção
+func (i *Injector) RunDisconnects(ctx context.Context, disconnect func()) {
+	if i == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, rule := range i.matching(KindWSDisconnect, "") {
+		interval := rule.Interval.Duration()
+		if interval <= 0 {
+			interval = defaultDisconnectInterval
+		}
+
+		wg.Add(1)
+		go func(rule *ruleState) {
+			defer wg.Done()
+			ticker := i.clock.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C():
+					if i.fire(rule, nil) {
+						disconnect()
+					}
+				}
+			}
+		}(rule)
+	}
+	wg.Wait()
+}
+
+// Status retorna as regras, se estão ativas e quantas vezes injetaram
+func (i *Injector) Status() Status {
+	if i == nil {
+		return Status{}
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.clock.Now()
+	status := Status{Enabled: true, Since: i.start}
+	for _, rule := range i.rules {
+		rs := RuleStatus{
+			Name:           rule.Name,
+			Kind:           rule.Kind,
+			Active:         i.activeLocked(rule, now),
+			Injections:     rule.injections,
+			LastInjectedAt: rule.lastAt,
+			ActiveFrom:     i.start.Add(rule.StartAfter.Duration()),
+		}
+		if rule.Duration > 0 {
+			rs.ActiveUntil = rs.ActiveFrom.Add(rule.Duration.Duration())
+		}
+		status.Rules = append(status.Rules, rs)
+	}
+	return status
+}
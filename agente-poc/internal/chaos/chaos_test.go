@@ -0,0 +1,281 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/timeutil"
+)
+
+// testLogger descarta tudo abaixo de FATAL, para não poluir a saída dos testes
+func testLogger(t *testing.T) logging.Logger {
+	t.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+// newTestInjector cria um injetor com semente fixa sobre um relógio falso
+func newTestInjector(t *testing.T, rules ...Rule) (*Injector, *clock.Fake) {
+	t.Helper()
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	return New(Config{Enabled: true, Seed: 42, Rules: rules}, fake, testLogger(t)), fake
+}
+
+// newTestTransport aponta um cliente com o injetor para um servidor que
+// conta as requisições recebidas
+func newTestTransport(t *testing.T, injector *Injector) (*http.Client, string, *atomic.Int64) {
+	t.Helper()
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return &http.Client{Transport: injector.RoundTripper(http.DefaultTransport)}, server.URL, &received
+}
+
+func seconds(d time.Duration) timeutil.Seconds {
+	return timeutil.Seconds(d)
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Rules: []Rule{
+		{Kind: KindHTTPError, StatusCode: 503, Probability: 0.3},
+		{Kind: KindHTTPError},
+		{Kind: KindLatency, Latency: seconds(time.Second)},
+		{Kind: KindWSDisconnect},
+		{Kind: KindClockSkew, Skew: seconds(-10 * time.Minute)},
+	}}
+	if errs := valid.Validate(); len(errs) != 0 {
+		t.Fatalf("valid rules rejected: %v", errs)
+	}
+
+	invalid := Config{Rules: []Rule{
+		{Kind: KindHTTPError, StatusCode: 200},
+		{Kind: KindLatency},
+		{Kind: KindClockSkew},
+		{Kind: "dns"},
+		{Kind: KindWSDisconnect, Probability: 1.5},
+		{Kind: KindWSDisconnect, Duration: seconds(-time.Second)},
+	}}
+	errs := invalid.Validate()
+	if len(errs) != len(invalid.Rules) {
+		t.Fatalf("%d errors for %d invalid rules: %v", len(errs), len(invalid.Rules), errs)
+	}
+	for i, err := range errs {
+		if !strings.HasPrefix(err, "chaos.rules[") {
+			t.Errorf("error %d without the rule index: %s", i, err)
+		}
+	}
+}
+
+func TestEnabledByEnv(t *testing.T) {
+	for value, want := range map[string]bool{"1": true, "": false, "true": false, "0": false} {
+		t.Setenv(EnvVar, value)
+		if got := EnabledByEnv(); got != want {
+			t.Errorf("%s=%q: EnabledByEnv = %t", EnvVar, value, got)
+		}
+	}
+}
+
+func TestHTTPErrorWindow(t *testing.T) {
+	injector, fake := newTestInjector(t, Rule{
+		Name:       "inventory-503",
+		Kind:       KindHTTPError,
+		StatusCode: http.StatusServiceUnavailable,
+		Endpoint:   "/inventory",
+		StartAfter: seconds(time.Minute),
+		Duration:   seconds(2 * time.Minute),
+	})
+	client, url, received := newTestTransport(t, injector)
+
+	get := func(path string) int {
+		t.Helper()
+		resp, err := client.Get(url + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Antes da janela, fora do endpoint e depois da janela, nada é injetado
+	if code := get("/inventory"); code != http.StatusOK {
+		t.Fatalf("status before the window = %d", code)
+	}
+	fake.Advance(time.Minute)
+	if code := get("/inventory/batch"); code != http.StatusServiceUnavailable {
+		t.Fatalf("status inside the window = %d", code)
+	}
+	if code := get("/heartbeat"); code != http.StatusOK {
+		t.Fatalf("other endpoint status = %d", code)
+	}
+	fake.Advance(2 * time.Minute)
+	if code := get("/inventory"); code != http.StatusOK {
+		t.Fatalf("status after the window = %d", code)
+	}
+
+	if received.Load() != 3 {
+		t.Fatalf("server received %d requests, want the 3 not injected", received.Load())
+	}
+	status := injector.Status()
+	rule := status.Rules[0]
+	if !status.Enabled || rule.Injections != 1 || rule.Active {
+		t.Fatalf("status = %+v", status)
+	}
+	if !rule.ActiveFrom.Equal(status.Since.Add(time.Minute)) || !rule.ActiveUntil.Equal(rule.ActiveFrom.Add(2*time.Minute)) {
+		t.Fatalf("rule window %s - %s", rule.ActiveFrom, rule.ActiveUntil)
+	}
+}
+
+func TestHTTPErrorConnection(t *testing.T) {
+	injector, _ := newTestInjector(t, Rule{Kind: KindHTTPError})
+	client, url, received := newTestTransport(t, injector)
+
+	_, err := client.Get(url + "/heartbeat")
+	if err == nil || !strings.Contains(err.Error(), "injected connection error") {
+		t.Fatalf("error = %v", err)
+	}
+	if received.Load() != 0 {
+		t.Fatal("injected connection error reached the server")
+	}
+	if name := injector.Status().Rules[0].Name; name != "http_error-0" {
+		t.Fatalf("default rule name = %q", name)
+	}
+}
+
+func TestHTTPErrorProbability(t *testing.T) {
+	injector, _ := newTestInjector(t, Rule{Kind: KindHTTPError, StatusCode: 503, Probability: 0.3})
+	client, url, received := newTestTransport(t, injector)
+
+	const requests = 1000
+	for i := 0; i < requests; i++ {
+		resp, err := client.Get(url + "/inventory")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	injected := injector.Status().Rules[0].Injections
+	if injected < 250 || injected > 350 {
+		t.Fatalf("%d of %d requests injected, want about 30%%", injected, requests)
+	}
+	if injected+received.Load() != requests {
+		t.Fatalf("%d injected + %d received != %d", injected, received.Load(), requests)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	injector, _ := newTestInjector(t, Rule{Kind: KindLatency, Latency: seconds(50 * time.Millisecond)})
+	client, url, _ := newTestTransport(t, injector)
+
+	start := time.Now()
+	resp, err := client.Get(url + "/heartbeat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("request took %s, want at least the injected 50ms", elapsed)
+	}
+
+	// A latência respeita o cancelamento da requisição
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/heartbeat", nil)
+	if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled request error = %v", err)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	injector, fake := newTestInjector(t, Rule{
+		Kind:       KindClockSkew,
+		Skew:       seconds(-10 * time.Minute),
+		StartAfter: seconds(time.Minute),
+		Duration:   seconds(time.Hour),
+	})
+	skewed := injector.Clock(fake)
+
+	if !skewed.Now().Equal(fake.Now()) {
+		t.Fatal("clock skewed before the window")
+	}
+	fake.Advance(time.Minute)
+	if want := fake.Now().Add(-10 * time.Minute); !skewed.Now().Equal(want) {
+		t.Fatalf("skewed Now = %s, want %s", skewed.Now(), want)
+	}
+	if since := skewed.Since(fake.Now()); since != -10*time.Minute {
+		t.Fatalf("skewed Since = %s", since)
+	}
+	// Cada entrada em vigor conta uma injeção, não cada leitura
+	if n := injector.Status().Rules[0].Injections; n != 1 {
+		t.Fatalf("%d injections while the skew stayed in effect", n)
+	}
+
+	fake.Advance(time.Hour)
+	if !skewed.Now().Equal(fake.Now()) {
+		t.Fatal("clock still skewed after the window")
+	}
+
+	// Sem regras de skew (ou sem injetor) o relógio base é usado diretamente
+	plain, _ := newTestInjector(t, Rule{Kind: KindHTTPError})
+	if plain.Clock(fake) != clock.Clock(fake) {
+		t.Fatal("clock wrapped without clock_skew rules")
+	}
+	var disabled *Injector
+	if disabled.Clock(fake) != clock.Clock(fake) {
+		t.Fatal("nil injector wrapped the clock")
+	}
+}
+
+func TestRunDisconnects(t *testing.T) {
+	injector, fake := newTestInjector(t, Rule{Kind: KindWSDisconnect, Interval: seconds(45 * time.Second)})
+
+	var disconnects atomic.Int64
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		injector.RunDisconnects(ctx, func() { disconnects.Add(1) })
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 1; i <= 3; i++ {
+		fake.Advance(45 * time.Second)
+		for disconnects.Load() < int64(i) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	cancel()
+	<-done
+
+	if disconnects.Load() != 3 || injector.Status().Rules[0].Injections != 3 {
+		t.Fatalf("%d disconnects, %d injections", disconnects.Load(), injector.Status().Rules[0].Injections)
+	}
+}
+
+func TestNilInjector(t *testing.T) {
+	var injector *Injector
+	if status := injector.Status(); status.Enabled || status.Rules != nil {
+		t.Fatalf("nil injector status = %+v", status)
+	}
+	if injector.RoundTripper(http.DefaultTransport) != http.DefaultTransport {
+		t.Fatal("nil injector wrapped the transport")
+	}
+	injector.RunDisconnects(context.Background(), func() { t.Fatal("nil injector disconnected") })
+}
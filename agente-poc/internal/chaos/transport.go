@@ -0,0 +1,125 @@
+package chaos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// RoundTripper envolve next com as regras http_error e latency. Com um
+// injetor nil, retorna next.
+func (i *Injector) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if i == nil {
+		return next
+	}
+	return &roundTripper{injector: i, next: next}
+}
+
+type roundTripper struct {
+	injector *Injector
+	next     http.RoundTripper
+}
+
+// RoundTrip aplica a latência e os erros antes de repassar a requisição
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Path
+	fields := map[string]interface{}{"method": req.Method, "endpoint": endpoint}
+
+	for _, rule := range rt.injector.matching(KindLatency, endpoint) {
+		if !rt.injector.fire(rule, fields) {
+			continue
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(rule.Latency.Duration()):
+		}
+	}
+
+	for _, rule := range rt.injector.matching(KindHTTPError, endpoint) {
+		if !rt.injector.fire(rule, fields) {
+			continue
+		}
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if rule.StatusCode == 0 {
+			return nil, fmt.Errorf("chaos: injected connection error (rule %s)", rule.Name)
+		}
+		body := fmt.Sprintf(`{"error":"chaos","message":"injected by rule %s"}`, rule.Name)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rule.StatusCode, http.StatusText(rule.StatusCode)),
+			StatusCode:    rule.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          io.NopCloser(bytes.NewBufferString(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return rt.next.RoundTrip(req)
+}
+
+// Clock retorna base deslocado pelas regras clock_skew ativas; com um
+// injetor nil ou sem regras de skew, retorna base
+func (i *Injector) Clock(base clock.Clock) clock.Clock {
+	base = clock.OrReal(base)
+	if i == nil || len(i.matching(KindClockSkew, "")) == 0 {
+		return base
+	}
+	return &skewedClock{Clock: base, injector: i}
+}
+
+// skewedClock desloca Now (e Since, derivado de Now); timers não mudam
+type skewedClock struct {
+	clock.Clock
+	injector *Injector
+}
+
+// Now retorna a hora da base mais o skew das regras ativas. Cada regra conta
+// uma injeção (e um log) ao entrar em vigor.
+func (c *skewedClock) Now() time.Time {
+	now := c.Clock.Now()
+	i := c.injector
+
+	var skew time.Duration
+	var started []*ruleState
+	i.mu.Lock()
+	for _, rule := range i.rules {
+		if rule.Kind != KindClockSkew {
+			continue
+		}
+		active := i.activeLocked(rule, now)
+		if active {
+			skew += rule.Skew.Duration()
+			if !rule.skewing {
+				rule.injections++
+				rule.lastAt = now
+				started = append(started, rule)
+			}
+		}
+		rule.skewing = active
+	}
+	i.mu.Unlock()
+
+	for _, rule := range started {
+		i.logger.WithFields(map[string]interface{}{
+			"chaos_rule": rule.Name,
+			"chaos_kind": rule.Kind,
+			"skew":       rule.Skew.String(),
+		}).Warning("Chaos: clock skew applied")
+	}
+	return now.Add(skew)
+}
+
+// Since usa o Now deslocado, como um relógio de parede adiantado
+func (c *skewedClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
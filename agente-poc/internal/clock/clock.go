@@ -0,0 +1,54 @@
+// Package clock abstrai a fonte de tempo do agente. O código de produção usa
+// Real; testes usam Fake para avançar o tempo sem esperas reais e simular
+// saltos do relógio de parede (NTP, fuso, DST, ajuste manual).
+package clock
+
+import "time"
+
+// Clock é a fonte de tempo injetada em Agent, Manager, MessageQueue,
+// RateLimiter, CircuitBreaker e no cache do collector
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker é o subconjunto de *time.Ticker usado pelo agente
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real é o relógio do sistema
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// OrReal retorna c, ou Real se c for nil (campo Clock não preenchido)
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Expired indica se um valor registrado em since com validade ttl expirou.
+// Um tempo decorrido negativo significa que o relógio de parede voltou
+// (ou since veio do disco e perdeu a leitura monotônica): sem isso o item
+// ficaria válido pelo tamanho do salto além do ttl, então é tratado como
+// expirado.
+func Expired(c Clock, since time.Time, ttl time.Duration) bool {
+	elapsed := c.Since(since)
+	return elapsed < 0 || elapsed > ttl
+}
@@ -0,0 +1,121 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 3, 8, 1, 30, 0, 0, time.UTC)
+
+// fired indica se o canal já recebeu um disparo
+func fired(ch <-chan time.Time) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestFakeAfter(t *testing.T) {
+	f := NewFake(testStart)
+	after := f.After(time.Minute)
+	if f.Pending() != 1 {
+		t.Fatalf("%d pending timers, want 1", f.Pending())
+	}
+
+	f.Advance(59 * time.Second)
+	if fired(after) {
+		t.Fatal("After fired before its deadline")
+	}
+	f.Advance(time.Second)
+	if !fired(after) {
+		t.Fatal("After did not fire at its deadline")
+	}
+	if f.Pending() != 0 {
+		t.Fatalf("fired timer still pending")
+	}
+
+	if !fired(f.After(0)) {
+		t.Fatal("After(0) did not fire immediately")
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(testStart)
+	ticker := f.NewTicker(10 * time.Second)
+
+	f.Advance(10 * time.Second)
+	if !fired(ticker.C()) {
+		t.Fatal("ticker did not fire after one period")
+	}
+
+	// Disparos não consumidos são descartados, como em time.Ticker
+	f.Advance(35 * time.Second)
+	if !fired(ticker.C()) || fired(ticker.C()) {
+		t.Fatal("ticker did not coalesce missed ticks into one")
+	}
+	f.Advance(5 * time.Second)
+	if !fired(ticker.C()) {
+		t.Fatal("ticker lost its phase after a late consumer")
+	}
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	if fired(ticker.C()) || f.Pending() != 0 {
+		t.Fatal("stopped ticker still fires")
+	}
+}
+
+func TestFakeSetMovesOnlyWallClock(t *testing.T) {
+	f := NewFake(testStart)
+	after := f.After(time.Minute)
+
+	// Relógio de parede volta uma hora (NTP, DST): timers não disparam
+	f.Set(testStart.Add(-time.Hour))
+	if fired(after) {
+		t.Fatal("Set fired a pending timer")
+	}
+	if since := f.Since(testStart); since != -time.Hour {
+		t.Fatalf("Since after moving back = %s, want -1h", since)
+	}
+
+	// O monotônico continua de onde estava
+	f.Advance(time.Minute)
+	if !fired(after) {
+		t.Fatal("timer did not fire after advancing the monotonic clock")
+	}
+	if now := f.Now(); !now.Equal(testStart.Add(-59 * time.Minute)) {
+		t.Fatalf("Now = %s", now)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	f := NewFake(testStart)
+	since := f.Now()
+
+	f.Advance(time.Minute)
+	if Expired(f, since, time.Hour) {
+		t.Fatal("entry expired within its ttl")
+	}
+	f.Advance(time.Hour)
+	if !Expired(f, since, time.Hour) {
+		t.Fatal("entry valid after its ttl")
+	}
+
+	// Relógio voltando: um tempo decorrido negativo é tratado como expirado
+	f.Set(since.Add(-30 * time.Minute))
+	if !Expired(f, since, time.Hour) {
+		t.Fatal("entry kept valid after the wall clock moved backwards")
+	}
+}
+
+func TestOrReal(t *testing.T) {
+	if OrReal(nil) != Real {
+		t.Fatal("nil clock did not fall back to Real")
+	}
+	f := NewFake(testStart)
+	if OrReal(f) != f {
+		t.Fatal("OrReal replaced a configured clock")
+	}
+}
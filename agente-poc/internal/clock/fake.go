@@ -0,0 +1,144 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake é um relógio controlado manualmente para testes. Advance move o
+// relógio de parede e o monotônico juntos e dispara os timers e tickers
+// vencidos; Set move só o relógio de parede, como um ajuste de NTP ou do
+// usuário, sem disparar nada.
+//
+// Since usa o relógio de parede, que é o pior caso visto pelo código real
+// (tempos lidos do disco não têm leitura monotônica).
+type Fake struct {
+	mu      sync.Mutex
+	wall    time.Time
+	mono    time.Duration
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter é um After ou um Ticker pendente; deadline é monotônico
+type fakeWaiter struct {
+	deadline time.Duration
+	period   time.Duration // 0 para After
+	ch       chan time.Time
+	stopped  bool
+}
+
+// NewFake cria um relógio parado em start
+func NewFake(start time.Time) *Fake {
+	return &Fake{wall: start}
+}
+
+// Now retorna o relógio de parede simulado
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.wall
+}
+
+// Since retorna f.Now().Sub(t); pode ser negativo após Set para trás
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// After dispara quando o relógio monotônico avançar d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{deadline: f.mono + d, ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.ch <- f.wall
+		return w.ch
+	}
+	f.waiters = append(f.waiters, w)
+	return w.ch
+}
+
+// NewTicker dispara a cada d de avanço monotônico; como em time.Ticker,
+// disparos não consumidos são descartados
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{deadline: f.mono + d, period: d, ch: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	return &fakeTicker{clock: f, waiter: w}
+}
+
+// Advance avança os dois relógios em d e dispara o que venceu
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.mono += d
+	f.wall = f.wall.Add(d)
+
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+		if w.deadline > f.mono {
+			pending = append(pending, w)
+			continue
+		}
+
+		select {
+		case w.ch <- f.wall:
+		default:
+		}
+		if w.period > 0 {
+			// Ticker: próximo disparo após o instante atual, como o runtime
+			// faz quando o consumidor atrasa
+			for w.deadline <= f.mono {
+				w.deadline += w.period
+			}
+			pending = append(pending, w)
+		}
+	}
+	f.waiters = pending
+}
+
+// Set ajusta o relógio de parede (inclusive para trás) sem mexer no
+// monotônico: timers e tickers pendentes não são afetados
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wall = t
+}
+
+// Pending retorna o número de timers e tickers ativos; útil para o teste
+// esperar a goroutine sob teste chegar ao After antes de chamar Advance
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, w := range f.waiters {
+		if !w.stopped {
+			n++
+		}
+	}
+	return n
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.waiter.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.waiter.stopped = true
+}
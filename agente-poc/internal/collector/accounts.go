@@ -0,0 +1,388 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Account é uma conta local da máquina. UID é o número no macOS e no Linux
+// e o SID no Windows; ServiceAccount marca, por heurística, contas de
+// sistema e de daemons (ver isServiceAccount).
+type Account struct {
+	Username       string     `json:"username"`
+	UID            string     `json:"uid"`
+	FullName       string     `json:"full_name,omitempty"`
+	HomeDir        string     `json:"home_dir,omitempty"`
+	Shell          string     `json:"shell,omitempty"`
+	Admin          bool       `json:"admin"`
+	ServiceAccount bool       `json:"service_account"`
+	Disabled       bool       `json:"disabled,omitempty"`
+	LastLogin      *time.Time `json:"last_login,omitempty"`
+}
+
+// AccountsInfo é a seção accounts do inventário
+type AccountsInfo struct {
+	Accounts []Account `json:"accounts"`
+	// Warnings registra o que não pôde ser obtido (ex.: grupo de
+	// administradores ou último login), sem descartar a lista de contas
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Arquivos de contas do Linux
+var (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// linuxAdminGroups são os grupos que dão sudo nas distribuições comuns
+var linuxAdminGroups = map[string]bool{"sudo": true, "wheel": true, "admin": true}
+
+// nologinShells indicam contas que não abrem sessão interativa
+var nologinShells = []string{"/nologin", "/false", "/sync", "/shutdown", "/halt"}
+
+// wmicUserAccountArgs lista as contas locais do Windows em CSV
+var wmicUserAccountArgs = []string{
+	"useraccount", "where", "LocalAccount=True",
+	"get", "Name,SID,FullName,Disabled",
+	"/format:csv",
+}
+
+// collectAccounts lista as contas locais e quais são administradoras
+func (c *SystemCollector) collectAccounts(ctx context.Context) (*AccountsInfo, error) {
+	c.logger.Debug("Collecting local accounts...")
+
+	switch runtime.GOOS {
+	case "darwin":
+		return c.collectDarwinAccounts(ctx)
+	case "linux":
+		return c.collectLinuxAccounts(ctx)
+	case "windows":
+		return c.collectWindowsAccounts(ctx)
+	default:
+		return &AccountsInfo{Accounts: []Account{}}, nil
+	}
+}
+
+// collectDarwinAccounts consulta o Directory Service local: um dscl por
+// atributo (evita um dscl por usuário) e o grupo admin
+func (c *SystemCollector) collectDarwinAccounts(ctx context.Context) (*AccountsInfo, error) {
+	output, err := c.runProbe(ctx, "dscl", ".", "-list", "/Users", "UniqueID")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute dscl: %w", err)
+	}
+	uids := parseDsclList(output)
+
+	attributes := make(map[string]map[string]string)
+	for _, attribute := range []string{"NFSHomeDirectory", "UserShell", "RealName"} {
+		if output, err := c.runProbe(ctx, "dscl", ".", "-list", "/Users", attribute); err == nil {
+			attributes[attribute] = parseDsclList(output)
+		}
+	}
+
+	info := &AccountsInfo{}
+	admins := map[string]bool{}
+	if output, err := c.runProbe(ctx, "dscl", ".", "-read", "/Groups/admin", "GroupMembership"); err == nil {
+		admins = parseDsclGroupMembership(output)
+	} else {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("admin group: %v", err))
+	}
+
+	for name, uid := range uids {
+		account := Account{
+			Username: name,
+			UID:      uid,
+			FullName: attributes["RealName"][name],
+			HomeDir:  attributes["NFSHomeDirectory"][name],
+			Shell:    attributes["UserShell"][name],
+			Admin:    admins[name] || uid == "0",
+		}
+		account.ServiceAccount = isServiceAccount(account, 500)
+		info.Accounts = append(info.Accounts, account)
+	}
+	sortAccounts(info.Accounts)
+	return info, nil
+}
+
+// parseDsclList interpreta o dscl . -list /Users <atributo>: nome e valor
+// por linha, separados por espaços (o valor pode conter espaços)
+func parseDsclList(output []byte) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		values[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	return values
+}
+
+// parseDsclGroupMembership interpreta "GroupMembership: root alice"; a
+// lista pode continuar nas linhas seguintes
+func parseDsclGroupMembership(output []byte) map[string]bool {
+	members := make(map[string]bool)
+	text := strings.TrimSpace(string(output))
+	text = strings.TrimPrefix(text, "GroupMembership:")
+	for _, member := range strings.Fields(text) {
+		members[member] = true
+	}
+	return members
+}
+
+// collectLinuxAccounts lê /etc/passwd e /etc/group; o último login vem do
+// lastlog, quando existe
+func (c *SystemCollector) collectLinuxAccounts(ctx context.Context) (*AccountsInfo, error) {
+	passwd, err := os.ReadFile(passwdPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", passwdPath, err)
+	}
+
+	info := &AccountsInfo{Accounts: parsePasswd(passwd)}
+
+	admins := map[string]bool{}
+	if group, err := os.ReadFile(groupPath); err == nil {
+		admins = parseLinuxAdminGroups(group)
+	} else {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("admin groups: %v", err))
+	}
+
+	var lastLogins map[string]time.Time
+	if output, err := c.runProbe(ctx, "lastlog"); err == nil {
+		lastLogins = parseLastlog(output)
+	} else {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("last login: %v", err))
+	}
+
+	for i := range info.Accounts {
+		account := &info.Accounts[i]
+		account.Admin = admins[account.Username] || account.UID == "0"
+		if login, ok := lastLogins[account.Username]; ok {
+			account.LastLogin = &login
+		}
+		account.ServiceAccount = isServiceAccount(*account, 1000)
+	}
+	sortAccounts(info.Accounts)
+	return info, nil
+}
+
+// parsePasswd interpreta o /etc/passwd (nome:senha:uid:gid:gecos:home:shell)
+func parsePasswd(data []byte) []Account {
+	var accounts []Account
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 || fields[0] == "" {
+			continue
+		}
+		accounts = append(accounts, Account{
+			Username: fields[0],
+			UID:      fields[2],
+			FullName: strings.Split(fields[4], ",")[0],
+			HomeDir:  fields[5],
+			Shell:    fields[6],
+		})
+	}
+	return accounts
+}
+
+// parseLinuxAdminGroups retorna os membros dos grupos de linuxAdminGroups
+// no /etc/group (nome:senha:gid:membros)
+func parseLinuxAdminGroups(data []byte) map[string]bool {
+	admins := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) < 4 || !linuxAdminGroups[fields[0]] {
+			continue
+		}
+		for _, member := range strings.Split(fields[3], ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				admins[member] = true
+			}
+		}
+	}
+	return admins
+}
+
+// lastlogTimeLayout é a data do lastlog ("Mon Oct 14 09:12:33 +0000 2024")
+const lastlogTimeLayout = "Mon Jan _2 15:04:05 -0700 2006"
+
+// parseLastlog interpreta o lastlog; contas que nunca entraram ficam de
+// fora. A data são as últimas seis colunas, já que Port e From podem
+// estar vazias.
+func parseLastlog(output []byte) map[string]time.Time {
+	logins := make(map[string]time.Time)
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines[1:] { // Pular cabeçalho
+		fields := strings.Fields(line)
+		if len(fields) < 7 || strings.Contains(line, "**Never logged in**") {
+			continue
+		}
+		stamp := strings.Join(fields[len(fields)-6:], " ")
+		if login, err := time.Parse(lastlogTimeLayout, stamp); err == nil {
+			logins[fields[0]] = login
+		}
+	}
+	return logins
+}
+
+// collectWindowsAccounts lista as contas locais pelo WMI e os membros do
+// grupo Administradores pelo net localgroup
+func (c *SystemCollector) collectWindowsAccounts(ctx context.Context) (*AccountsInfo, error) {
+	output, err := c.runProbe(ctx, "wmic", wmicUserAccountArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute wmic: %w", err)
+	}
+	accounts, err := parseWmicUserAccounts(output)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &AccountsInfo{Accounts: accounts}
+	// "administrators" é aceito também nas instalações localizadas, onde o
+	// nome exibido do grupo muda (ex.: Administradores)
+	if output, err := c.runProbe(ctx, "net", "localgroup", "administrators"); err == nil {
+		admins := parseNetLocalgroup(output)
+		for i := range info.Accounts {
+			info.Accounts[i].Admin = admins[strings.ToLower(info.Accounts[i].Username)]
+		}
+	} else {
+		info.Warnings = append(info.Warnings, fmt.Sprintf("administrators group: %v", err))
+	}
+	sortAccounts(info.Accounts)
+	return info, nil
+}
+
+// parseWmicUserAccounts interpreta `wmic useraccount ... /format:csv`,
+// localizando as colunas pelo cabeçalho
+func parseWmicUserAccounts(output []byte) ([]Account, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+
+	var header map[string]int
+	accounts := []Account{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse wmic output: %w", err)
+		}
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		if len(row) == 1 && row[0] == "" {
+			continue
+		}
+		if header == nil {
+			header = make(map[string]int, len(row))
+			for i, name := range row {
+				header[name] = i
+			}
+			continue
+		}
+		column := func(name string) string {
+			if i, ok := header[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		account := Account{
+			Username: column("Name"),
+			UID:      column("SID"),
+			FullName: column("FullName"),
+			Disabled: strings.EqualFold(column("Disabled"), "TRUE"),
+		}
+		if account.Username == "" {
+			continue
+		}
+		account.ServiceAccount = isWindowsBuiltinAccount(account.UID)
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// parseNetLocalgroup retorna os membros (em minúsculas, sem o domínio) do
+// net localgroup: a lista fica entre a linha de hífens e a mensagem final
+func parseNetLocalgroup(output []byte) map[string]bool {
+	members := make(map[string]bool)
+	inList := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "----") {
+			inList = true
+			continue
+		}
+		if !inList || line == "" {
+			continue
+		}
+		// "The command completed successfully." e traduções
+		if strings.HasSuffix(line, ".") && strings.Contains(line, " ") {
+			break
+		}
+		if idx := strings.LastIndex(line, `\`); idx >= 0 {
+			line = line[idx+1:]
+		}
+		members[strings.ToLower(line)] = true
+	}
+	return members
+}
+
+// isWindowsBuiltinAccount marca as contas embutidas sem sessão interativa
+// pelo RID: Guest (501), DefaultAccount (503) e WDAGUtilityAccount (504)
+func isWindowsBuiltinAccount(sid string) bool {
+	idx := strings.LastIndex(sid, "-")
+	if idx < 0 {
+		return false
+	}
+	switch sid[idx+1:] {
+	case "501", "503", "504":
+		return true
+	}
+	return false
+}
+
+// isServiceAccount aplica a heurística de conta de serviço do macOS e do
+// Linux: UID abaixo do primeiro UID de usuário (exceto root), nome com
+// "_" (daemons do macOS) ou shell que não abre sessão
+func isServiceAccount(account Account, firstUserUID int) bool {
+	if strings.HasPrefix(account.Username, "_") {
+		return true
+	}
+	for _, shell := range nologinShells {
+		if strings.HasSuffix(account.Shell, shell) {
+			return true
+		}
+	}
+	uid, err := strconv.Atoi(account.UID)
+	if err != nil {
+		return false
+	}
+	// nobody costuma ter UID 65534 ou -2
+	return (uid > 0 && uid < firstUserUID) || uid < 0 || uid == 65534
+}
+
+// sortAccounts ordena pelo nome para o inventário não mudar de ordem
+func sortAccounts(accounts []Account) {
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Username < accounts[j].Username
+	})
+}
@@ -0,0 +1,204 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useAccountFiles aponta /etc/passwd e /etc/group para as fixtures
+func useAccountFiles(t *testing.T, passwd, group string) {
+	t.Helper()
+	oldPasswd, oldGroup := passwdPath, groupPath
+	passwdPath, groupPath = filepath.Join("testdata", passwd), filepath.Join("testdata", group)
+	t.Cleanup(func() { passwdPath, groupPath = oldPasswd, oldGroup })
+}
+
+// darwinAccountOutputs são as respostas do dscl das fixtures
+func darwinAccountOutputs(t *testing.T) map[string][]byte {
+	return map[string][]byte{
+		"dscl . -list /Users UniqueID":               readFixture(t, "dscl_users_uniqueid.txt"),
+		"dscl . -list /Users UserShell":              readFixture(t, "dscl_users_shell.txt"),
+		"dscl . -list /Users NFSHomeDirectory":       readFixture(t, "dscl_users_home.txt"),
+		"dscl . -list /Users RealName":               readFixture(t, "dscl_users_realname.txt"),
+		"dscl . -read /Groups/admin GroupMembership": readFixture(t, "dscl_admin_group.txt"),
+	}
+}
+
+// takeLastLogins separa os últimos logins por usuário, para comparar as
+// contas com reflect.DeepEqual
+func takeLastLogins(accounts []Account) map[string]time.Time {
+	logins := make(map[string]time.Time)
+	for i := range accounts {
+		if accounts[i].LastLogin != nil {
+			logins[accounts[i].Username] = *accounts[i].LastLogin
+			accounts[i].LastLogin = nil
+		}
+	}
+	return logins
+}
+
+func TestCollectDarwinAccounts(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(darwinAccountOutputs(t)))
+
+	info, err := c.collectDarwinAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Account{
+		{Username: "_mbsetupuser", UID: "248", FullName: "Setup User", HomeDir: "/var/setup", Shell: "/bin/bash", ServiceAccount: true},
+		{Username: "_spotlight", UID: "89", FullName: "Spotlight", HomeDir: "/var/empty", Shell: "/usr/bin/false", ServiceAccount: true},
+		{Username: "alice", UID: "501", FullName: "Alice Souza", HomeDir: "/Users/alice", Shell: "/bin/zsh", Admin: true},
+		{Username: "bob", UID: "502", FullName: "Bob", HomeDir: "/Users/bob", Shell: "/bin/zsh"},
+		{Username: "daemon", UID: "1", FullName: "System Services", HomeDir: "/var/root", Shell: "/usr/bin/false", ServiceAccount: true},
+		{Username: "nobody", UID: "-2", FullName: "Unprivileged User", HomeDir: "/var/empty", Shell: "/usr/bin/false", ServiceAccount: true},
+		{Username: "root", UID: "0", FullName: "System Administrator", HomeDir: "/var/root", Shell: "/bin/sh", Admin: true},
+	}
+	if !reflect.DeepEqual(info.Accounts, want) || len(info.Warnings) != 0 {
+		t.Fatalf("accounts = %+v, warnings %v", info.Accounts, info.Warnings)
+	}
+
+	// Sem o grupo admin, só o root é administrador e o motivo fica registrado
+	outputs := darwinAccountOutputs(t)
+	delete(outputs, "dscl . -read /Groups/admin GroupMembership")
+	c.SetCommandRunner(newCountingRunner(outputs))
+	c.ClearCache()
+	info, err = c.collectDarwinAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range info.Accounts {
+		if account.Admin != (account.Username == "root") {
+			t.Fatalf("%s admin %t without the admin group", account.Username, account.Admin)
+		}
+	}
+	if len(info.Warnings) != 1 {
+		t.Fatalf("warnings = %v", info.Warnings)
+	}
+}
+
+func TestCollectLinuxAccounts(t *testing.T) {
+	useAccountFiles(t, "passwd", "group")
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{"lastlog": readFixture(t, "lastlog.txt")}))
+
+	info, err := c.collectLinuxAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	logins := takeLastLogins(info.Accounts)
+	want := []Account{
+		{Username: "alice", UID: "1000", FullName: "Alice Souza", HomeDir: "/home/alice", Shell: "/bin/bash", Admin: true},
+		{Username: "bob", UID: "1001", FullName: "Bob", HomeDir: "/home/bob", Shell: "/bin/zsh", Admin: true},
+		{Username: "daemon", UID: "1", FullName: "daemon", HomeDir: "/usr/sbin", Shell: "/usr/sbin/nologin", ServiceAccount: true},
+		// UID de sistema com shell de login e no wheel
+		{Username: "deploy", UID: "998", HomeDir: "/srv/deploy", Shell: "/bin/bash", Admin: true, ServiceAccount: true},
+		{Username: "nobody", UID: "65534", FullName: "nobody", HomeDir: "/nonexistent", Shell: "/usr/sbin/nologin", ServiceAccount: true},
+		{Username: "root", UID: "0", FullName: "root", HomeDir: "/root", Shell: "/bin/bash", Admin: true},
+		{Username: "www-data", UID: "33", FullName: "www-data", HomeDir: "/var/www", Shell: "/usr/sbin/nologin", ServiceAccount: true},
+	}
+	if !reflect.DeepEqual(info.Accounts, want) || len(info.Warnings) != 0 {
+		t.Fatalf("accounts = %+v, warnings %v", info.Accounts, info.Warnings)
+	}
+
+	wantLogins := map[string]time.Time{
+		"alice": time.Date(2024, 10, 14, 9, 12, 33, 0, time.UTC),
+		"bob":   time.Date(2024, 10, 1, 21, 2, 11, 0, time.UTC),
+	}
+	if len(logins) != len(wantLogins) {
+		t.Fatalf("last logins = %v", logins)
+	}
+	for user, login := range wantLogins {
+		if !logins[user].Equal(login) {
+			t.Errorf("%s last login %v, want %v", user, logins[user], login)
+		}
+	}
+}
+
+func TestCollectLinuxAccountsPartial(t *testing.T) {
+	// Sem /etc/group nem lastlog, as contas continuam com avisos
+	useAccountFiles(t, "passwd", "missing-group")
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(nil))
+
+	info, err := c.collectLinuxAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Accounts) != 7 || len(info.Warnings) != 2 {
+		t.Fatalf("%d accounts, warnings %v", len(info.Accounts), info.Warnings)
+	}
+	for _, account := range info.Accounts {
+		if account.Admin != (account.UID == "0") || account.LastLogin != nil {
+			t.Fatalf("account %+v", account)
+		}
+	}
+
+	useAccountFiles(t, "missing-passwd", "group")
+	if _, err := c.collectLinuxAccounts(context.Background()); err == nil {
+		t.Fatal("no error without /etc/passwd")
+	}
+}
+
+func TestCollectWindowsAccounts(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		"wmic useraccount where LocalAccount=True get Name,SID,FullName,Disabled /format:csv": readFixture(t, "wmic_useraccount.csv"),
+		"net localgroup administrators": readFixture(t, "net_localgroup_administrators.txt"),
+	}))
+
+	info, err := c.collectWindowsAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const sid = "S-1-5-21-1004336348-1177238915-682003330-"
+	want := []Account{
+		{Username: "Administrator", UID: sid + "500", Admin: true, Disabled: true},
+		{Username: "DefaultAccount", UID: sid + "503", ServiceAccount: true, Disabled: true},
+		{Username: "Guest", UID: sid + "501", ServiceAccount: true, Disabled: true},
+		{Username: "WDAGUtilityAccount", UID: sid + "504", ServiceAccount: true, Disabled: true},
+		{Username: "alice", UID: sid + "1001", FullName: "Souza, Alice", Admin: true},
+		{Username: "helpdesk", UID: sid + "1002"},
+	}
+	if !reflect.DeepEqual(info.Accounts, want) || len(info.Warnings) != 0 {
+		t.Fatalf("accounts = %+v, warnings %v", info.Accounts, info.Warnings)
+	}
+}
+
+func TestParseNetLocalgroup(t *testing.T) {
+	members := parseNetLocalgroup(readFixture(t, "net_localgroup_administrators.txt"))
+	want := map[string]bool{"administrator": true, "domain admins": true, "alice": true}
+	if !reflect.DeepEqual(members, want) {
+		t.Fatalf("members = %v", members)
+	}
+
+	// Instalação em português: nomes e mensagem final traduzidos
+	localized := "Nome de alias     Administradores\r\n\r\nMembros\r\n\r\n------------------\r\nAdministrador\r\nsuporte\r\nComando concluído com êxito.\r\n"
+	members = parseNetLocalgroup([]byte(localized))
+	if !reflect.DeepEqual(members, map[string]bool{"administrador": true, "suporte": true}) {
+		t.Fatalf("localized members = %v", members)
+	}
+}
+
+func TestAccountsGatedByConfig(t *testing.T) {
+	c := newTestCollector(t)
+	if c.Availability()[SectionAccounts] {
+		t.Fatal("accounts collected without EnableAccounts")
+	}
+
+	c.SetEnableAccounts(true)
+	if !c.Availability()[SectionAccounts] {
+		t.Fatal("accounts not collected with EnableAccounts")
+	}
+
+	// A seção desligada no arquivo vence o EnableAccounts
+	if err := c.SetSections(map[string]bool{SectionAccounts: false}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Availability()[SectionAccounts] {
+		t.Fatal("accounts collected with the section disabled")
+	}
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// fillCache grava uma entrada em cada chave informada
+func fillCache(c *SystemCollector, keys ...string) {
+	for _, key := range keys {
+		c.setInCache(key, key+"-data", time.Hour)
+	}
+}
+
+func TestInvalidateCacheKeepsOtherSections(t *testing.T) {
+	c := newTestCollector(t)
+	fillCache(c, CacheKeySystemInfo, CacheKeyInstalledApps, CacheKeyMachineID, CacheKeyGPUs, CacheKeyNetworkLinks)
+
+	// Atualizar o hardware descarta apenas as seções de hardware
+	c.InvalidateCache(CacheKeySystemInfo, CacheKeyGPUs, CacheKeyNetworkLinks)
+
+	for _, key := range []string{CacheKeySystemInfo, CacheKeyGPUs, CacheKeyNetworkLinks} {
+		if c.getFromCache(key) != nil {
+			t.Errorf("%s still cached after invalidation", key)
+		}
+	}
+	for _, key := range []string{CacheKeyInstalledApps, CacheKeyMachineID} {
+		if c.getFromCache(key) != key+"-data" {
+			t.Errorf("%s evicted by an unrelated invalidation", key)
+		}
+	}
+
+	// Chaves desconhecidas ou repetidas não afetam o restante
+	c.InvalidateCache("unknown", CacheKeySystemInfo)
+	if c.getFromCache(CacheKeyInstalledApps) == nil {
+		t.Error("installed_apps evicted by an unknown key")
+	}
+}
+
+func TestClearCacheKeepsMachineID(t *testing.T) {
+	c := newTestCollector(t)
+	fillCache(c, CacheKeySystemInfo, CacheKeyInstalledApps, CacheKeyMachineID)
+
+	c.ClearCache()
+
+	if c.getFromCache(CacheKeyMachineID) != CacheKeyMachineID+"-data" {
+		t.Error("machine_id cleared by a bulk clear")
+	}
+	for _, key := range []string{CacheKeySystemInfo, CacheKeyInstalledApps} {
+		if c.getFromCache(key) != nil {
+			t.Errorf("%s survived ClearCache", key)
+		}
+	}
+	if stats := c.GetCacheStats(); stats["items"] != 1 {
+		t.Errorf("%v items after ClearCache, want 1", stats["items"])
+	}
+}
+
+func TestGetCacheStatsPerKeyAge(t *testing.T) {
+	c := newTestCollector(t)
+	fake := clock.NewFake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	c.SetClock(fake)
+
+	c.setInCache(CacheKeyInstalledApps, "apps", 5*time.Minute)
+	fake.Advance(2 * time.Minute)
+	c.setInCache(CacheKeySystemInfo, "system", time.Minute)
+	fake.Advance(90 * time.Second)
+
+	stats := c.GetCacheStats()
+	keys, ok := stats["keys"].(map[string]interface{})
+	if !ok || len(keys) != 2 {
+		t.Fatalf("per-key stats missing: %v", stats)
+	}
+
+	tests := map[string]struct {
+		age     float64
+		ttl     float64
+		expired bool
+	}{
+		CacheKeyInstalledApps: {age: 210, ttl: 300, expired: false},
+		CacheKeySystemInfo:    {age: 90, ttl: 60, expired: true},
+	}
+	for key, want := range tests {
+		entry, _ := keys[key].(map[string]interface{})
+		if entry["age_seconds"] != want.age || entry["ttl_seconds"] != want.ttl || entry["expired"] != want.expired {
+			t.Errorf("%s stats = %v, want age %v, ttl %v, expired %t", key, entry, want.age, want.ttl, want.expired)
+		}
+	}
+	if stats["expired"] != 1 {
+		t.Errorf("expired count %v, want 1", stats["expired"])
+	}
+}
+
+func TestCacheExpiresOnClockJump(t *testing.T) {
+	c := newTestCollector(t)
+	fake := clock.NewFake(time.Date(2026, 10, 25, 2, 30, 0, 0, time.UTC))
+	c.SetClock(fake)
+
+	c.setInCache(CacheKeyInstalledApps, "apps", 5*time.Minute)
+	fake.Advance(4 * time.Minute)
+	if c.getFromCache(CacheKeyInstalledApps) == nil {
+		t.Fatal("entry expired within its ttl")
+	}
+
+	// Relógio de parede volta uma hora: sem tratar o salto a entrada ficaria
+	// válida por mais uma hora
+	fake.Set(fake.Now().Add(-time.Hour))
+	if c.getFromCache(CacheKeyInstalledApps) != nil {
+		t.Fatal("entry kept after the wall clock moved backwards")
+	}
+}
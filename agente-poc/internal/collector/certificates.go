@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheKeyCertificates é a chave de cache da lista completa do trust store
+const CacheKeyCertificates = "certificates"
+
+// darwinSystemKeychain é o keychain com as raízes instaladas pelo usuário ou
+// por MDM; as raízes da Apple ficam em SystemRootCertificates e não mudam
+const darwinSystemKeychain = "/Library/Keychains/System.keychain"
+
+// windowsRootStore é o store de raízes confiáveis da máquina
+const windowsRootStore = "ROOT"
+
+// linuxCertBundles são os bundles de CAs das distribuições comuns, em ordem
+// de preferência; sem nenhum, os arquivos de linuxCertDir são lidos um a um
+var linuxCertBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // openSUSE
+	"/etc/ssl/cert.pem",
+}
+
+const linuxCertDir = "/etc/ssl/certs"
+
+// Certificate é um certificado do trust store
+type Certificate struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	SHA256     string    `json:"sha256"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	SelfSigned bool      `json:"self_signed"`
+	Expired    bool      `json:"expired,omitempty"`
+}
+
+// CertificatesInfo resume o trust store do sistema. No inventário,
+// Certificates traz só os certificados fora de CertificateAllowlist (sem
+// allowlist, só o resumo); o comando list_certificates traz todos.
+type CertificatesInfo struct {
+	Store string `json:"store"`
+	Total int    `json:"total"`
+	// Hash é o SHA-256 das impressões digitais ordenadas: muda sempre que
+	// um certificado entra ou sai do store
+	Hash         string        `json:"hash"`
+	Allowlisted  int           `json:"allowlisted,omitempty"`
+	Certificates []Certificate `json:"certificates,omitempty"`
+	// Malformed conta entradas que não puderam ser interpretadas
+	Malformed int `json:"malformed,omitempty"`
+}
+
+// CollectCertificates lista todos os certificados do trust store do sistema
+// (comando list_certificates)
+func (c *SystemCollector) CollectCertificates() (*CertificatesInfo, error) {
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	return c.collectCertificates(ctx)
+}
+
+// collectCertificateSummary é a seção certificates do inventário: o resumo
+// do store e os certificados fora da allowlist
+func (c *SystemCollector) collectCertificateSummary(ctx context.Context) (*CertificatesInfo, error) {
+	full, err := c.collectCertificates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := *full
+	summary.Certificates = nil
+	allowlist := c.configFor(ctx).CertificateAllowlist
+	if len(allowlist) == 0 {
+		return &summary, nil
+	}
+
+	allowed := make(map[string]bool, len(allowlist))
+	for _, fingerprint := range allowlist {
+		allowed[NormalizeFingerprint(fingerprint)] = true
+	}
+	for _, cert := range full.Certificates {
+		if allowed[cert.SHA256] {
+			summary.Allowlisted++
+			continue
+		}
+		summary.Certificates = append(summary.Certificates, cert)
+	}
+	return &summary, nil
+}
+
+// collectCertificates lê o trust store da plataforma, em cache pelo
+// cache_expiration
+func (c *SystemCollector) collectCertificates(ctx context.Context) (*CertificatesInfo, error) {
+	if cached, ok := c.getFromCache(CacheKeyCertificates).(*CertificatesInfo); ok {
+		return cached, nil
+	}
+
+	now := c.clock.Now()
+	var info *CertificatesInfo
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := c.runProbe(ctx, "security", "find-certificate", "-a", "-p", darwinSystemKeychain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute security: %w", err)
+		}
+		info = newCertificatesInfo(darwinSystemKeychain, output, now)
+	case "linux":
+		store, data, err := readLinuxCertificates()
+		if err != nil {
+			return nil, err
+		}
+		info = newCertificatesInfo(store, data, now)
+	case "windows":
+		ders, err := readWindowsRootStore()
+		if err != nil {
+			return nil, err
+		}
+		info = &CertificatesInfo{Store: windowsRootStore}
+		for _, der := range ders {
+			cert, err := parseDERCertificate(der, now)
+			if err != nil {
+				info.Malformed++
+				continue
+			}
+			info.Certificates = append(info.Certificates, cert)
+		}
+		finishCertificatesInfo(info)
+	default:
+		return nil, fmt.Errorf("certificate inventory is not supported on %s", runtime.GOOS)
+	}
+
+	c.setInCache(CacheKeyCertificates, info, c.configFor(ctx).CacheExpiration)
+	return info, nil
+}
+
+// readLinuxCertificates retorna o primeiro bundle existente ou, sem bundle,
+// a concatenação dos PEMs de linuxCertDir
+func readLinuxCertificates() (string, []byte, error) {
+	for _, path := range linuxCertBundles {
+		if data, err := os.ReadFile(path); err == nil {
+			return path, data, nil
+		}
+	}
+
+	entries, err := os.ReadDir(linuxCertDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", linuxCertDir, err)
+	}
+	var data []byte
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".pem", ".crt":
+		default:
+			continue
+		}
+		if content, err := os.ReadFile(filepath.Join(linuxCertDir, entry.Name())); err == nil {
+			data = append(data, content...)
+			data = append(data, '\n')
+		}
+	}
+	return linuxCertDir, data, nil
+}
+
+// newCertificatesInfo interpreta os PEMs do store
+func newCertificatesInfo(store string, data []byte, now time.Time) *CertificatesInfo {
+	certs, malformed := parsePEMCertificates(data, now)
+	info := &CertificatesInfo{Store: store, Certificates: certs, Malformed: malformed}
+	finishCertificatesInfo(info)
+	return info
+}
+
+// parsePEMCertificates interpreta uma sequência de blocos PEM. Blocos de
+// outro tipo são ignorados; certificados inválidos e um bloco truncado no
+// fim contam como malformados.
+func parsePEMCertificates(data []byte, now time.Time) ([]Certificate, int) {
+	var certs []Certificate
+	malformed := 0
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			if bytes.Contains(rest, []byte("-----BEGIN")) {
+				malformed++
+			}
+			break
+		}
+		data = rest
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := parseDERCertificate(block.Bytes, now)
+		if err != nil {
+			malformed++
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs, malformed
+}
+
+// parseDERCertificate converte um certificado DER
+func parseDERCertificate(der []byte, now time.Time) (Certificate, error) {
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return Certificate{}, err
+	}
+	fingerprint := sha256.Sum256(der)
+	return Certificate{
+		Subject:   parsed.Subject.String(),
+		Issuer:    parsed.Issuer.String(),
+		SHA256:    hex.EncodeToString(fingerprint[:]),
+		NotBefore: parsed.NotBefore,
+		NotAfter:  parsed.NotAfter,
+		// Emissor igual ao sujeito e, quando informado, o identificador da
+		// chave do emissor igual ao da própria chave (raízes antigas assinadas
+		// com SHA-1 não passariam por CheckSignature)
+		SelfSigned: bytes.Equal(parsed.RawIssuer, parsed.RawSubject) &&
+			(len(parsed.AuthorityKeyId) == 0 || bytes.Equal(parsed.AuthorityKeyId, parsed.SubjectKeyId)),
+		Expired: now.After(parsed.NotAfter),
+	}, nil
+}
+
+// finishCertificatesInfo remove duplicatas (bundles e diretórios costumam
+// repetir certificados), ordena pelo sujeito e calcula Total e Hash
+func finishCertificatesInfo(info *CertificatesInfo) {
+	seen := make(map[string]bool, len(info.Certificates))
+	unique := info.Certificates[:0]
+	for _, cert := range info.Certificates {
+		if seen[cert.SHA256] {
+			continue
+		}
+		seen[cert.SHA256] = true
+		unique = append(unique, cert)
+	}
+	sort.Slice(unique, func(i, j int) bool {
+		if unique[i].Subject != unique[j].Subject {
+			return unique[i].Subject < unique[j].Subject
+		}
+		return unique[i].SHA256 < unique[j].SHA256
+	})
+	info.Certificates = unique
+	info.Total = len(unique)
+
+	fingerprints := make([]string, 0, len(unique))
+	for _, cert := range unique {
+		fingerprints = append(fingerprints, cert.SHA256)
+	}
+	sort.Strings(fingerprints)
+	hash := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	info.Hash = hex.EncodeToString(hash[:])
+}
+
+// NormalizeFingerprint deixa uma impressão SHA-256 no formato do inventário:
+// hexadecimal minúsculo sem separadores (aceita "AB:CD:..." e "ab cd ...")
+func NormalizeFingerprint(fingerprint string) string {
+	replacer := strings.NewReplacer(":", "", " ", "", "-", "")
+	return strings.ToLower(replacer.Replace(strings.TrimSpace(fingerprint)))
+}
+
+// ValidFingerprint indica se a impressão normalizada é um SHA-256 em hexadecimal
+func ValidFingerprint(fingerprint string) bool {
+	normalized := NormalizeFingerprint(fingerprint)
+	if len(normalized) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(normalized)
+	return err == nil
+}
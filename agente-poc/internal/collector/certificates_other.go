@@ -0,0 +1,10 @@
+//go:build !windows
+
+package collector
+
+import "fmt"
+
+// readWindowsRootStore existe fora do Windows só para o collector compilar
+func readWindowsRootStore() ([][]byte, error) {
+	return nil, fmt.Errorf("the certificate store API is only available on Windows")
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// certificatesNow é o instante usado para marcar os vencidos das fixtures
+var certificatesNow = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+// rootFingerprint é o SHA-256 do certificado de root_ca.pem
+func rootFingerprint(t *testing.T) string {
+	t.Helper()
+	block, _ := pem.Decode(readFixture(t, "root_ca.pem"))
+	if block == nil {
+		t.Fatal("root_ca.pem has no PEM block")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParsePEMCertificates(t *testing.T) {
+	certs, malformed := parsePEMCertificates(readFixture(t, "ca_bundle.pem"), certificatesNow)
+
+	// Três certificados, a duplicata da raiz, e o CRL ignorado; o bloco
+	// inválido e o truncado no fim contam como malformados
+	if len(certs) != 4 || malformed != 2 {
+		t.Fatalf("%d certificates, %d malformed", len(certs), malformed)
+	}
+
+	root, issuing, legacy := certs[0], certs[1], certs[2]
+	if root.Subject != "CN=Example Root CA,O=Example Corp" || root.Issuer != root.Subject || !root.SelfSigned || root.Expired {
+		t.Fatalf("root = %+v", root)
+	}
+	if root.SHA256 != rootFingerprint(t) {
+		t.Fatalf("root fingerprint %s", root.SHA256)
+	}
+	if !root.NotBefore.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) || !root.NotAfter.Equal(time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("root validity %v - %v", root.NotBefore, root.NotAfter)
+	}
+	if issuing.Issuer != root.Subject || issuing.SelfSigned || issuing.Expired {
+		t.Fatalf("issuing CA = %+v", issuing)
+	}
+	if legacy.Subject != "CN=Legacy Root CA,O=Example Corp" || !legacy.SelfSigned || !legacy.Expired {
+		t.Fatalf("expired root = %+v", legacy)
+	}
+	if certs[3] != root {
+		t.Fatalf("duplicate = %+v", certs[3])
+	}
+
+	// Entrada vazia ou sem PEM não é malformada
+	for _, data := range []string{"", "# only a comment\n"} {
+		if certs, malformed := parsePEMCertificates([]byte(data), certificatesNow); len(certs) != 0 || malformed != 0 {
+			t.Fatalf("%q: %d certificates, %d malformed", data, len(certs), malformed)
+		}
+	}
+}
+
+func TestNewCertificatesInfo(t *testing.T) {
+	info := newCertificatesInfo("bundle.pem", readFixture(t, "ca_bundle.pem"), certificatesNow)
+	if info.Store != "bundle.pem" || info.Total != 3 || info.Malformed != 2 || len(info.Certificates) != 3 {
+		t.Fatalf("info = %+v", info)
+	}
+	// Ordenados pelo sujeito, sem a duplicata
+	var subjects []string
+	for _, cert := range info.Certificates {
+		subjects = append(subjects, cert.Subject)
+	}
+	if got := strings.Join(subjects, "; "); got != "CN=Example Issuing CA,O=Example Corp; CN=Example Root CA,O=Example Corp; CN=Legacy Root CA,O=Example Corp" {
+		t.Fatalf("subjects = %s", got)
+	}
+
+	// O hash depende só do conjunto de certificados
+	again := newCertificatesInfo("other.pem", readFixture(t, "ca_bundle.pem"), certificatesNow.Add(time.Hour))
+	rootOnly := newCertificatesInfo("bundle.pem", readFixture(t, "root_ca.pem"), certificatesNow)
+	if again.Hash != info.Hash || rootOnly.Hash == info.Hash || len(info.Hash) != sha256.Size*2 {
+		t.Fatalf("hashes %s, %s, %s", info.Hash, again.Hash, rootOnly.Hash)
+	}
+}
+
+func TestCollectCertificateSummary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads the Linux certificate bundle")
+	}
+	old := linuxCertBundles
+	linuxCertBundles = []string{filepath.Join("testdata", "missing.pem"), filepath.Join("testdata", "ca_bundle.pem")}
+	t.Cleanup(func() { linuxCertBundles = old })
+
+	c := newTestCollector(t)
+	ctx := context.Background()
+
+	// Sem allowlist, o inventário leva só o resumo
+	summary, err := c.collectCertificateSummary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 3 || summary.Certificates != nil || summary.Hash == "" || !strings.HasSuffix(summary.Store, "ca_bundle.pem") {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	// Com a raiz na allowlist (no formato do openssl), só os outros saem
+	fingerprint := rootFingerprint(t)
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, strings.ToUpper(fingerprint[i:i+2]))
+	}
+	c.SetCertificateAllowlist([]string{strings.Join(colons, ":")})
+	summary, err = c.collectCertificateSummary(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Total != 3 || summary.Allowlisted != 1 || len(summary.Certificates) != 2 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	for _, cert := range summary.Certificates {
+		if cert.SHA256 == fingerprint {
+			t.Fatal("allowlisted root in the summary")
+		}
+	}
+
+	// O list_certificates traz todos
+	full, err := c.CollectCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Certificates) != 3 || full.Allowlisted != 0 {
+		t.Fatalf("full list = %+v", full)
+	}
+}
+
+func TestValidFingerprint(t *testing.T) {
+	hexFingerprint := strings.Repeat("ab", sha256.Size)
+	tests := map[string]bool{
+		hexFingerprint:                    true,
+		strings.ToUpper(hexFingerprint):   true,
+		" " + hexFingerprint + "\n":       true,
+		strings.Repeat("AB:", 31) + "AB":  true,
+		strings.Repeat("ab ", 31) + "ab":  true,
+		hexFingerprint[2:]:                false,
+		strings.Repeat("zz", sha256.Size): false,
+		"":                                false,
+	}
+	for fingerprint, want := range tests {
+		if got := ValidFingerprint(fingerprint); got != want {
+			t.Errorf("ValidFingerprint(%q) = %t, want %t", fingerprint, got, want)
+		}
+	}
+	if got := NormalizeFingerprint("AB:cd-EF 01"); got != "abcdef01" {
+		t.Fatalf("NormalizeFingerprint = %q", got)
+	}
+}
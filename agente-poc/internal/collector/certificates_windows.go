@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// readWindowsRootStore retorna os certificados (DER) do store ROOT da
+// máquina, o mesmo do certlm.msc em Autoridades de Certificação Raiz
+// Confiáveis
+func readWindowsRootStore() ([][]byte, error) {
+	name, err := windows.UTF16PtrFromString(windowsRootStore)
+	if err != nil {
+		return nil, err
+	}
+	store, err := windows.CertOpenStore(
+		windows.CERT_STORE_PROV_SYSTEM_W, 0, 0,
+		windows.CERT_SYSTEM_STORE_LOCAL_MACHINE|windows.CERT_STORE_READONLY_FLAG,
+		uintptr(unsafe.Pointer(name)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s store: %w", windowsRootStore, err)
+	}
+	defer windows.CertCloseStore(store, 0)
+
+	var ders [][]byte
+	var cert *windows.CertContext
+	for {
+		cert, err = windows.CertEnumCertificatesInStore(store, cert)
+		if err != nil {
+			// CRYPT_E_NOT_FOUND marca o fim da enumeração
+			if errors.Is(err, windows.Errno(windows.CRYPT_E_NOT_FOUND)) {
+				break
+			}
+			return nil, fmt.Errorf("failed to enumerate %s store: %w", windowsRootStore, err)
+		}
+		// O contexto é liberado pela próxima chamada; o DER é copiado antes
+		der := unsafe.Slice(cert.EncodedCert, cert.Length)
+		ders = append(ders, append([]byte(nil), der...))
+	}
+	return ders, nil
+}
@@ -2,16 +2,15 @@ package collector
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"crypto/sha256"
@@ -22,8 +21,8 @@ import (
 	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
-	"github.com/shirou/gopsutil/v3/process"
 
+	"agente-poc/internal/clock"
 	"agente-poc/internal/logging"
 )
 
@@ -45,8 +44,61 @@ type CollectorConfig struct {
 	MaxProcesses        int
 	MaxApplications     int
 	EnableMacOSSpecific bool
+
+	// Varredura de aplicações instaladas
+	ApplicationsPath string
+	AppScanWorkers   int
+	AppScanTimeout   time.Duration
+	ComputeAppSizes  bool
+
+	// Inclui o JSON bruto do system_profiler no inventário (depuração)
+	IncludeRawSystemProfiler  bool
+	MaxSystemProfilerRawBytes int
+
+	// Saúde SMART dos discos (smartctl, ou diskutil no macOS); desligada por
+	// padrão porque o smartctl costuma exigir root
+	EnableSmart bool
+
+	// Contas locais e administradores (seção accounts); desligada por padrão
+	// porque alguns clientes consideram a lista de usuários sensível
+	EnableAccounts bool
+
+	// Impressões SHA-256 dos certificados conhecidos do trust store; no
+	// inventário só entram os demais (sem allowlist, só o resumo)
+	CertificateAllowlist []string
+
+	// Plugins próprios deste collector, além dos registrados com Register
+	// (ver SetPlugins)
+	Plugins []CollectorPlugin
+
+	// Seleção dos processos do inventário: os MaxProcesses maiores por
+	// ProcessSortKey ("cpu" ou "memory"), descartando os que ficam abaixo
+	// dos dois mínimos (zero desliga o mínimo)
+	ProcessSortKey        string
+	MinProcessCPUPercent  float64
+	MinProcessMemoryBytes uint64
+
+	// NetworkTopTalkers inclui os processos com mais tráfego de rede desde a
+	// coleta anterior (0 desliga; ver processIOIncludesNetwork)
+	NetworkTopTalkers int
+
+	// Seções que CollectInventory deixa de coletar (ver disableableSections)
+	DisabledSections []string
+	// Sections vem do arquivo de configuração (collector_sections); false
+	// desliga a seção e o backend não consegue religá-la
+	Sections map[string]bool
 }
 
+// maxAppScanDepth limita a profundidade de subdiretórios visitados fora de bundles
+const maxAppScanDepth = 2
+
+// Chaves do cache do collector, usadas em InvalidateCache
+const (
+	CacheKeySystemInfo    = "system_info"
+	CacheKeyInstalledApps = "installed_apps"
+	CacheKeyMachineID     = "machine_id"
+)
+
 // CacheItem representa um item em cache
 type CacheItem struct {
 	Data      interface{}
@@ -58,9 +110,22 @@ type CacheItem struct {
 type SystemCollector struct {
 	interval time.Duration
 	logger   logging.Logger
-	config   *CollectorConfig
+	config   atomic.Pointer[CollectorConfig] // trocado inteiro em ApplySettings
+	configMu sync.Mutex                      // serializa as trocas de config
 	cache    map[string]*CacheItem
 	cacheMu  sync.RWMutex
+	runner   CommandRunner
+	registry uninstallReader // chaves Uninstall do Windows
+	clock    clock.Clock
+
+	cpuSampler *ProcessCPUSampler
+	life       *lifecycle
+	// Amostra anterior de swap-ins/outs do macOS, para as taxas por segundo
+	swapSample swapSample
+	// Amostra anterior dos contadores de rede (interfaces e processos)
+	netSample netSample
+	// Últimas saídas dos plugins registrados, reaproveitadas pelo Interval
+	plugins pluginState
 }
 
 // New cria uma nova instância do SystemCollector
@@ -72,98 +137,92 @@ func New(interval time.Duration, logger logging.Logger) *SystemCollector {
 		MaxProcesses:        100,
 		MaxApplications:     200,
 		EnableMacOSSpecific: runtime.GOOS == "darwin",
+		ApplicationsPath:    "/Applications",
+		AppScanWorkers:      8,
+		AppScanTimeout:      10 * time.Second,
+		ComputeAppSizes:     false,
+		ProcessSortKey:      ProcessSortCPU,
+
+		MaxSystemProfilerRawBytes: defaultMaxSystemProfilerRawBytes,
 	}
 
-	return &SystemCollector{
+	c := &SystemCollector{
 		interval: interval,
 		logger:   logger,
-		config:   config,
 		cache:    make(map[string]*CacheItem),
+		runner:   execRunner{},
+		registry: newUninstallReader(),
+		clock:    clock.Real,
+
+		cpuSampler: NewProcessCPUSampler(interval),
+		life:       newLifecycle(),
 	}
+	c.config.Store(config)
+	return c
+}
+
+// SetClock substitui a fonte de tempo usada pelo cache e pelo sampler de CPU
+func (c *SystemCollector) SetClock(clk clock.Clock) {
+	c.clock = clock.OrReal(clk)
+	c.cpuSampler.SetClock(clk)
+}
+
+// CPUSampler retorna o sampler de CPU por processo; as médias sustentadas só
+// existem depois de Start, com o sampler rodando em segundo plano (iniciado
+// no primeiro uso)
+func (c *SystemCollector) CPUSampler() *ProcessCPUSampler {
+	c.ensureHelpers()
+	return c.cpuSampler
 }
 
 // CollectInventory coleta informações completas do sistema
 func (c *SystemCollector) CollectInventory() (*InventoryData, error) {
 	c.logger.Debug("Collecting system inventory...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
-
-	// Coletar dados em paralelo
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	var systemInfo *SystemInfo
-	var hardwareInfo *HardwareInfo
-	var softwareInfo *SoftwareInfo
-	var networkInfo *NetworkInfo
-	var macOSInfo *MacOSInfo
-	var lastError error
-
-	// Função auxiliar para capturar erros
-	setError := func(err error) {
-		mu.Lock()
-		if lastError == nil {
-			lastError = err
-		}
-		mu.Unlock()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
 	}
+	defer end()
 
-	// Coleta de informações básicas do sistema
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if info, err := c.collectSystemInfoInternal(ctx); err != nil {
-			setError(fmt.Errorf("failed to collect system info: %w", err))
-		} else {
-			systemInfo = info
-		}
-	}()
+	// Probes externos repetidos rodam uma única vez nesta coleta
+	ctx = withProbeCache(ctx)
+	config := c.configFor(ctx)
 
-	// Coleta de informações de hardware
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if info, err := c.collectHardwareInfoInternal(ctx); err != nil {
-			setError(fmt.Errorf("failed to collect hardware info: %w", err))
-		} else {
-			hardwareInfo = info
-		}
-	}()
+	inventory := &InventoryData{
+		Software: SoftwareInfo{Skipped: true},
+		Network:  NetworkInfo{Skipped: true},
+	}
 
-	// Coleta de informações de software
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if info, err := c.collectSoftwareInfoInternal(ctx); err != nil {
-			setError(fmt.Errorf("failed to collect software info: %w", err))
-		} else {
-			softwareInfo = info
-		}
-	}()
+	// Coletar as seções em paralelo; cada uma preenche o próprio campo
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var lastError error
 
-	// Coleta de informações de rede
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if info, err := c.collectNetworkInfoInternal(ctx); err != nil {
-			setError(fmt.Errorf("failed to collect network info: %w", err))
-		} else {
-			networkInfo = info
+	for _, section := range builtinSections {
+		if section.enabled != nil && !section.enabled(config) {
+			continue
 		}
-	}()
-
-	// Coleta de informações específicas do macOS
-	if c.config.EnableMacOSSpecific {
 		wg.Add(1)
-		go func() {
+		go func(section builtinSection) {
 			defer wg.Done()
-			if info, err := c.collectMacOSSpecificInternal(ctx); err != nil {
-				c.logger.WithField("error", err).Warning("Failed to collect macOS specific info")
-			} else {
-				macOSInfo = info
+			err := c.runSection(ctx, section, inventory)
+			if err == nil {
+				return
 			}
-		}()
+			if !section.required {
+				c.logger.WithFields(map[string]interface{}{
+					"section": section.name,
+					"error":   err,
+				}).Warning("Failed to collect inventory section")
+				return
+			}
+			mu.Lock()
+			if lastError == nil {
+				lastError = fmt.Errorf("failed to collect %s info: %w", section.name, err)
+			}
+			mu.Unlock()
+		}(section)
 	}
 
 	wg.Wait()
@@ -185,58 +244,141 @@ func (c *SystemCollector) CollectInventory() (*InventoryData, error) {
 		}
 	}
 
-	// Construir dados de inventário
-	inventory := &InventoryData{
-		MachineID:     machineID,
-		Timestamp:     time.Now(),
-		CollectedAt:   time.Now().Format(time.RFC3339),
-		System:        *systemInfo,
-		Hardware:      *hardwareInfo,
-		Software:      *softwareInfo,
-		Network:       *networkInfo,
-		MacOSSpecific: macOSInfo,
-	}
+	inventory.MachineID = machineID
+	inventory.Timestamp = time.Now()
+	inventory.CollectedAt = inventory.Timestamp.Format(time.RFC3339)
+	inventory.Collector = config.settings()
 
 	c.logger.Debug("System inventory collected successfully")
 	return inventory, nil
 }
 
+// Seções de coleta anunciadas nas capacidades do agente
+const (
+	SectionSystem                = "system"
+	SectionHardware              = "hardware"
+	SectionSoftware              = "software"
+	SectionNetwork               = "network"
+	SectionMacOSSpecific         = "macos_specific"
+	SectionSystemProfiler        = "system_profiler"
+	SectionConfigurationProfiles = "configuration_profiles"
+	SectionGroupPolicies         = "group_policies"
+	SectionAccounts              = "accounts"
+	SectionCertificates          = "certificates"
+	SectionSecurityPosture       = "security_posture"
+	SectionVirtualization        = "virtualization"
+	SectionCustom                = "custom"
+)
+
+// collectsMacOSSpecific indica se a coleta específica do macOS (e o
+// system_profiler) roda com a configuração config
+func collectsMacOSSpecific(config *CollectorConfig) bool {
+	return config.EnableMacOSSpecific
+}
+
+// collectsGroupPolicies indica se as GPOs do Windows são coletadas
+func collectsGroupPolicies() bool {
+	return runtime.GOOS == "windows"
+}
+
+// collectsAccounts indica se as contas locais são coletadas: exige
+// EnableAccounts e que a seção não tenha sido desligada
+func collectsAccounts(config *CollectorConfig) bool {
+	return config.EnableAccounts && config.sectionEnabled(SectionAccounts)
+}
+
+// Availability retorna quais seções este collector coleta nesta plataforma,
+// a partir das mesmas condições usadas em CollectInventory
+func (c *SystemCollector) Availability() map[string]bool {
+	config := c.cfg()
+	macOS := collectsMacOSSpecific(config)
+	return map[string]bool{
+		SectionSystem:                true,
+		SectionHardware:              true,
+		SectionSoftware:              config.sectionEnabled(SectionSoftware),
+		SectionNetwork:               config.sectionEnabled(SectionNetwork),
+		SectionMacOSSpecific:         macOS,
+		SectionSystemProfiler:        macOS,
+		SectionConfigurationProfiles: macOS && runtime.GOOS == "darwin",
+		SectionGroupPolicies:         collectsGroupPolicies() && config.sectionEnabled(SectionGroupPolicies),
+		SectionAccounts:              collectsAccounts(config),
+		SectionCertificates:          config.sectionEnabled(SectionCertificates),
+		SectionSecurityPosture:       config.sectionEnabled(SectionSecurityPosture),
+		SectionVirtualization:        config.sectionEnabled(SectionVirtualization),
+		SectionCustom:                collectsCustom(config),
+	}
+}
+
 // CollectBasicInfo coleta informações básicas do sistema
 func (c *SystemCollector) CollectBasicInfo() (*SystemInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 
 	return c.collectSystemInfoInternal(ctx)
 }
 
+// CollectMemoryInfo coleta informações de memória (com as métricas do macOS)
+func (c *SystemCollector) CollectMemoryInfo() (*MemoryInfo, error) {
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	return c.collectMemoryInfo(ctx)
+}
+
 // CollectHardwareInfo coleta informações de hardware
 func (c *SystemCollector) CollectHardwareInfo() (*HardwareInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 
 	return c.collectHardwareInfoInternal(ctx)
 }
 
-// CollectSoftwareInfo coleta informações de software
+// CollectSoftwareInfo coleta informações de software; com a seção
+// desligada retorna a estrutura vazia marcada como Skipped
 func (c *SystemCollector) CollectSoftwareInfo() (*SoftwareInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 
+	if !c.configFor(ctx).sectionEnabled(SectionSoftware) {
+		return &SoftwareInfo{Skipped: true}, nil
+	}
 	return c.collectSoftwareInfoInternal(ctx)
 }
 
-// CollectNetworkInfo coleta informações de rede
+// CollectNetworkInfo coleta informações de rede; com a seção desligada
+// retorna a estrutura vazia marcada como Skipped
 func (c *SystemCollector) CollectNetworkInfo() (*NetworkInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 
+	if !c.configFor(ctx).sectionEnabled(SectionNetwork) {
+		return &NetworkInfo{Skipped: true}, nil
+	}
 	return c.collectNetworkInfoInternal(ctx)
 }
 
 // CollectMacOSSpecific coleta informações específicas do macOS
 func (c *SystemCollector) CollectMacOSSpecific() (*MacOSInfo, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
-	defer cancel()
+	ctx, end, err := c.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
 
 	return c.collectMacOSSpecificInternal(ctx)
 }
@@ -244,7 +386,7 @@ func (c *SystemCollector) CollectMacOSSpecific() (*MacOSInfo, error) {
 // collectSystemInfoInternal coleta informações básicas do sistema
 func (c *SystemCollector) collectSystemInfoInternal(ctx context.Context) (*SystemInfo, error) {
 	// Tentar obter do cache primeiro
-	if cachedData := c.getFromCache("system_info"); cachedData != nil {
+	if cachedData := c.getFromCache(CacheKeySystemInfo); cachedData != nil {
 		if info, ok := cachedData.(*SystemInfo); ok {
 			return info, nil
 		}
@@ -277,7 +419,7 @@ func (c *SystemCollector) collectSystemInfoInternal(ctx context.Context) (*Syste
 	}
 
 	// Cachear o resultado
-	c.setInCache("system_info", info, c.config.CacheExpiration)
+	c.setInCache(CacheKeySystemInfo, info, c.configFor(ctx).CacheExpiration)
 
 	return info, nil
 }
@@ -341,46 +483,118 @@ func (c *SystemCollector) collectHardwareInfoInternal(ctx context.Context) (*Har
 		}
 	}()
 
+	// GPUs são opcionais: a falha não derruba a coleta de hardware
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if gpus, err := c.collectGPUInfo(ctx); err != nil {
+			c.logger.WithField("error", err).Debug("Failed to collect GPU info")
+		} else {
+			mu.Lock()
+			hardwareInfo.GPUs = gpus
+			mu.Unlock()
+		}
+	}()
+
+	// Criptografia de disco também é opcional; falta de permissão já vem
+	// como status unknown
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if encryption, err := c.collectEncryptionInfo(ctx); err != nil {
+			c.logger.WithField("error", err).Debug("Failed to collect disk encryption status")
+		} else {
+			mu.Lock()
+			hardwareInfo.Encryption = encryption
+			mu.Unlock()
+		}
+	}()
+
 	wg.Wait()
 
 	if lastError != nil {
 		return nil, lastError
 	}
 
+	// Campos de patrimônio a partir do system_profiler (já em cache no ciclo)
+	if collectsMacOSSpecific(c.configFor(ctx)) {
+		if result, err := c.getSPHardware(ctx); err == nil {
+			applySPHardware(hardwareInfo, result.hardware)
+		} else {
+			c.logger.WithField("error", err).Debug("Failed to read SPHardware for asset fields")
+		}
+	}
+
 	return hardwareInfo, nil
 }
 
-// collectCPUInfo coleta informações da CPU
-func (c *SystemCollector) collectCPUInfo(ctx context.Context) (*CPUInfo, error) {
-	// Informações estáticas da CPU
-	cpuInfos, err := cpu.InfoWithContext(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get CPU info: %w", err)
-	}
+// SetIncludeRawSystemProfiler ativa a inclusão do JSON bruto do system_profiler
+func (c *SystemCollector) SetIncludeRawSystemProfiler(include bool) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
 
-	if len(cpuInfos) == 0 {
-		return nil, fmt.Errorf("no CPU info available")
+	config := *c.cfg()
+	config.IncludeRawSystemProfiler = include
+	c.config.Store(&config)
+}
+
+// SetEnableSmart ativa a coleta da saúde SMART dos discos
+func (c *SystemCollector) SetEnableSmart(enable bool) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.EnableSmart = enable
+	c.config.Store(&config)
+}
+
+// SetEnableAccounts ativa a coleta das contas locais
+func (c *SystemCollector) SetEnableAccounts(enable bool) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.EnableAccounts = enable
+	c.config.Store(&config)
+}
+
+// SetCertificateAllowlist troca as impressões dos certificados conhecidos
+func (c *SystemCollector) SetCertificateAllowlist(fingerprints []string) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.CertificateAllowlist = append([]string(nil), fingerprints...)
+	c.config.Store(&config)
+}
+
+// SetAppScan ajusta a varredura de aplicações instaladas: workers em
+// paralelo, prazo total e cálculo do tamanho dos bundles. workers e timeout
+// zerados mantêm os valores vigentes.
+func (c *SystemCollector) SetAppScan(workers int, timeout time.Duration, computeSizes bool) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	if workers > 0 {
+		config.AppScanWorkers = workers
+	}
+	if timeout > 0 {
+		config.AppScanTimeout = timeout
 	}
+	config.ComputeAppSizes = computeSizes
+	c.config.Store(&config)
+}
 
-	cpuInfo := cpuInfos[0]
+// SetNetworkTopTalkers define quantos processos com mais tráfego de rede
+// entram no inventário (0 desliga)
+func (c *SystemCollector) SetNetworkTopTalkers(limit int) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
 
-	// Uso da CPU
-	cpuPercent, err := cpu.PercentWithContext(ctx, time.Second, true)
-	if err != nil {
-		c.logger.WithField("error", err).Warning("Failed to get CPU usage")
-		cpuPercent = []float64{0.0} // Valor padrão
-	}
-
-	return &CPUInfo{
-		Model:     cpuInfo.ModelName,
-		Vendor:    cpuInfo.VendorID,
-		Family:    cpuInfo.Family,
-		Cores:     cpuInfo.Cores,
-		Threads:   cpuInfo.Cores, // Assumindo sem hyperthreading
-		Frequency: cpuInfo.Mhz,
-		CacheSize: cpuInfo.CacheSize,
-		Usage:     cpuPercent,
-	}, nil
+	config := *c.cfg()
+	config.NetworkTopTalkers = limit
+	c.config.Store(&config)
 }
 
 // collectMemoryInfo coleta informações de memória
@@ -398,7 +612,7 @@ func (c *SystemCollector) collectMemoryInfo(ctx context.Context) (*MemoryInfo, e
 		swap = &mem.SwapMemoryStat{} // Valor padrão
 	}
 
-	return &MemoryInfo{
+	info := &MemoryInfo{
 		Total:       vmem.Total,
 		Available:   vmem.Available,
 		Used:        vmem.Used,
@@ -412,7 +626,19 @@ func (c *SystemCollector) collectMemoryInfo(ctx context.Context) (*MemoryInfo, e
 			Free:        swap.Free,
 			UsedPercent: swap.UsedPercent,
 		},
-	}, nil
+	}
+
+	// No macOS, Used inclui cache e páginas comprimidas; a pressão de memória
+	// é o que indica falta de memória
+	if c.configFor(ctx).EnableMacOSSpecific && runtime.GOOS == "darwin" {
+		darwin, err := c.collectDarwinMemory(ctx)
+		if err != nil {
+			c.logger.WithField("error", err).Warning("Failed to collect macOS memory metrics")
+		}
+		info.Darwin = darwin
+	}
+
+	return info, nil
 }
 
 // collectDiskInfo coleta informações de disco
@@ -449,9 +675,24 @@ func (c *SystemCollector) collectDiskInfo(ctx context.Context) ([]DiskInfo, erro
 			InodesUsed:  usage.InodesUsed,
 		}
 
+		if c.configFor(ctx).EnableMacOSSpecific && runtime.GOOS == "darwin" && isDarwinVolume(partition.Device) {
+			if volume, err := c.darwinVolume(ctx, partition.Mountpoint); err == nil {
+				diskInfo.Darwin = volume
+			} else {
+				c.logger.WithFields(map[string]interface{}{
+					"partition": partition.Mountpoint,
+					"error":     err,
+				}).Debug("Failed to read volume attributes")
+			}
+		}
+
 		diskInfos = append(diskInfos, diskInfo)
 	}
 
+	if c.configFor(ctx).EnableSmart {
+		c.attachDiskHealth(ctx, diskInfos)
+	}
+
 	return diskInfos, nil
 }
 
@@ -479,11 +720,14 @@ func (c *SystemCollector) collectSoftwareInfoInternal(ctx context.Context) (*Sof
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		if apps, err := c.collectInstalledApps(ctx); err != nil {
+		if apps, partial, err := c.collectInstalledApps(ctx); err != nil {
 			setError(fmt.Errorf("failed to collect installed apps: %w", err))
 		} else {
 			mu.Lock()
 			softwareInfo.InstalledApplications = apps
+			if partial {
+				softwareInfo.Warnings = append(softwareInfo.Warnings, "installed_applications: partial=true (scan cut short)")
+			}
 			mu.Unlock()
 		}
 	}()
@@ -526,227 +770,218 @@ func (c *SystemCollector) collectSoftwareInfoInternal(ctx context.Context) (*Sof
 	return softwareInfo, nil
 }
 
-// collectInstalledApps coleta aplicações instaladas
-func (c *SystemCollector) collectInstalledApps(ctx context.Context) ([]Application, error) {
+// collectInstalledApps coleta aplicações instaladas (no Linux, os pacotes
+// de dpkg, rpm e flatpak; no Windows, o registro; ver
+// collectInstalledPackages e collectRegistryApps).
+// Os bundles são processados por um pool de workers e a coleta respeita um
+// prazo próprio (AppScanTimeout); ao estourar o prazo retorna o que já foi
+// coletado com partial=true.
+func (c *SystemCollector) collectInstalledApps(ctx context.Context) ([]Application, bool, error) {
 	// Tentar obter do cache primeiro
-	if cachedData := c.getFromCache("installed_apps"); cachedData != nil {
+	if cachedData := c.getFromCache(CacheKeyInstalledApps); cachedData != nil {
 		if apps, ok := cachedData.([]Application); ok {
-			return apps, nil
+			return apps, false, nil
 		}
 	}
 
 	c.logger.Debug("Collecting installed applications...")
+	config := c.configFor(ctx)
 
-	var apps []Application
-	applicationsPath := "/Applications"
+	// No Linux as aplicações vêm dos gerenciadores de pacotes e no Windows
+	// das chaves Uninstall do registro
+	switch runtime.GOOS {
+	case "linux":
+		return c.collectInstalledPackages(ctx, config)
+	case "windows":
+		return c.collectRegistryApps(ctx, config)
+	}
+	return c.scanAppBundles(ctx, config)
+}
 
-	// Listar aplicações em /Applications
-	err := filepath.WalkDir(applicationsPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Continuar mesmo com erros
+// scanAppBundles é a varredura dos bundles .app de ApplicationsPath, com
+// AppScanWorkers workers e prazo AppScanTimeout
+func (c *SystemCollector) scanAppBundles(ctx context.Context, config *CollectorConfig) ([]Application, bool, error) {
+	if _, err := os.Stat(config.ApplicationsPath); err != nil {
+		if os.IsNotExist(err) {
+			return []Application{}, false, nil
 		}
+		return nil, false, fmt.Errorf("failed to read applications directory: %w", err)
+	}
 
-		// Verificar se é um .app
-		if d.IsDir() && strings.HasSuffix(path, ".app") {
-			appInfo, err := c.getAppInfo(path)
-			if err != nil {
-				c.logger.WithFields(map[string]interface{}{
-					"path":  path,
-					"error": err,
-				}).Debug("Failed to get app info")
-				return nil // Continuar com outras aplicações
-			}
+	scanCtx, cancel := context.WithTimeout(ctx, config.AppScanTimeout)
+	defer cancel()
 
-			apps = append(apps, *appInfo)
+	workers := config.AppScanWorkers
+	if workers <= 0 {
+		workers = 1
+	}
 
-			// Limitar número de aplicações
-			if len(apps) >= c.config.MaxApplications {
-				return fmt.Errorf("max applications reached") // Parar a caminhada
-			}
-		}
+	bundles := make(chan string)
+	results := make(chan Application)
 
-		return nil
-	})
+	// Produtor: encontra bundles .app sem descer no conteúdo deles
+	go func() {
+		defer close(bundles)
+		c.findAppBundles(scanCtx, config.ApplicationsPath, 0, bundles)
+	}()
 
-	if err != nil && !strings.Contains(err.Error(), "max applications reached") {
-		return nil, fmt.Errorf("failed to walk applications directory: %w", err)
+	// Workers: leem apenas metadados do bundle e Contents/Info.plist
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range bundles {
+				appInfo, err := c.getAppInfo(scanCtx, path)
+				if err != nil {
+					c.logger.WithFields(map[string]interface{}{
+						"path":  path,
+						"error": err,
+					}).Debug("Failed to get app info")
+					continue
+				}
+				select {
+				case results <- *appInfo:
+				case <-scanCtx.Done():
+					return
+				}
+			}
+		}()
 	}
 
-	// Cachear o resultado
-	c.setInCache("installed_apps", apps, c.config.CacheExpiration)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-	return apps, nil
-}
+	apps := make([]Application, 0)
+	limited := false
+	for app := range results {
+		apps = append(apps, app)
 
-// getAppInfo obtém informações de uma aplicação
-func (c *SystemCollector) getAppInfo(appPath string) (*Application, error) {
-	info, err := os.Stat(appPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to stat app: %w", err)
+		// Limitar número de aplicações
+		if len(apps) >= config.MaxApplications {
+			limited = true
+			cancel()
+			break
+		}
 	}
-
-	app := &Application{
-		Name:        strings.TrimSuffix(filepath.Base(appPath), ".app"),
-		Path:        appPath,
-		Size:        0, // Calcular tamanho pode ser custoso
-		InstallDate: info.ModTime().Format(time.RFC3339),
+	// Drenar workers restantes após cancelamento
+	for range results {
 	}
 
-	// Tentar obter informações do Info.plist
-	plistPath := filepath.Join(appPath, "Contents", "Info.plist")
-	if plistInfo, err := c.parseInfoPlist(plistPath); err == nil {
-		if version, ok := plistInfo["CFBundleShortVersionString"].(string); ok {
-			app.Version = version
-		}
-		if vendor, ok := plistInfo["CFBundleIdentifier"].(string); ok {
-			app.Vendor = vendor
-		}
+	// Prazo estourado ou ctx do chamador cancelado: a lista está incompleta.
+	// O cancelamento pelo limite de aplicações não conta.
+	partial := !limited && scanCtx.Err() != nil
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Path < apps[j].Path })
+
+	if partial {
+		c.logger.WithFields(map[string]interface{}{
+			"collected": len(apps),
+			"timeout":   config.AppScanTimeout.String(),
+			"error":     scanCtx.Err(),
+		}).Warning("Installed applications scan cut short, returning partial result")
+		return apps, true, nil
 	}
 
-	return app, nil
-}
+	// Cachear apenas resultados completos
+	c.setInCache(CacheKeyInstalledApps, apps, config.CacheExpiration)
 
-// parseInfoPlist parse básico do Info.plist (simplificado)
-func (c *SystemCollector) parseInfoPlist(path string) (map[string]interface{}, error) {
-	// Implementação simplificada - na prática, usaria uma biblioteca plist
-	// Por ora, retornar vazio
-	return map[string]interface{}{}, nil
+	return apps, false, nil
 }
 
-// collectRunningProcesses coleta processos em execução
-func (c *SystemCollector) collectRunningProcesses(ctx context.Context) ([]Process, error) {
-	c.logger.Debug("Collecting running processes...")
-
-	// Obter lista de PIDs
-	pids, err := process.PidsWithContext(ctx)
+// findAppBundles percorre diretórios enviando caminhos de bundles .app.
+// Bundles não são percorridos internamente e subdiretórios comuns (ex.:
+// /Applications/Utilities) são visitados até maxAppScanDepth níveis.
+func (c *SystemCollector) findAppBundles(ctx context.Context, dir string, depth int, out chan<- string) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get process PIDs: %w", err)
+		c.logger.WithFields(map[string]interface{}{
+			"path":  dir,
+			"error": err,
+		}).Debug("Failed to read directory")
+		return
 	}
 
-	var processes []Process
-	count := 0
-
-	for _, pid := range pids {
-		if count >= c.config.MaxProcesses {
-			break
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return
 		}
-
-		proc, err := process.NewProcessWithContext(ctx, pid)
-		if err != nil {
-			continue // Processo pode ter terminado
+		if !entry.IsDir() {
+			continue
 		}
 
-		processInfo, err := c.getProcessInfo(ctx, proc)
-		if err != nil {
-			continue // Continuar com outros processos
+		path := filepath.Join(dir, entry.Name())
+		if strings.HasSuffix(entry.Name(), ".app") {
+			select {
+			case out <- path:
+			case <-ctx.Done():
+				return
+			}
+			continue
 		}
 
-		processes = append(processes, *processInfo)
-		count++
+		if depth < maxAppScanDepth {
+			c.findAppBundles(ctx, path, depth+1, out)
+		}
 	}
-
-	return processes, nil
 }
 
-// getProcessInfo obtém informações de um processo
-func (c *SystemCollector) getProcessInfo(ctx context.Context, proc *process.Process) (*Process, error) {
-	name, err := proc.NameWithContext(ctx)
-	if err != nil {
-		name = "unknown"
-	}
-
-	cmdline, err := proc.CmdlineWithContext(ctx)
-	if err != nil {
-		cmdline = ""
-	}
-
-	cpuPercent, err := proc.CPUPercentWithContext(ctx)
+// getAppInfo obtém informações de uma aplicação
+func (c *SystemCollector) getAppInfo(ctx context.Context, appPath string) (*Application, error) {
+	info, err := os.Stat(appPath)
 	if err != nil {
-		cpuPercent = 0.0
-	}
-
-	memInfo, err := proc.MemoryInfoWithContext(ctx)
-	var memoryUsage uint64
-	if err == nil {
-		memoryUsage = memInfo.RSS
+		return nil, fmt.Errorf("failed to stat app: %w", err)
 	}
 
-	statusList, err := proc.StatusWithContext(ctx)
-	var status string
-	if err != nil || len(statusList) == 0 {
-		status = "unknown"
-	} else {
-		status = statusList[0] // Usar o primeiro status da lista
+	app := &Application{
+		Name:        strings.TrimSuffix(filepath.Base(appPath), ".app"),
+		Path:        appPath,
+		InstallDate: info.ModTime().Format(time.RFC3339),
 	}
 
-	username, err := proc.UsernameWithContext(ctx)
-	if err != nil {
-		username = "unknown"
+	// Calcular tamanho é custoso, só quando solicitado
+	if c.configFor(ctx).ComputeAppSizes {
+		app.Size = bundleSize(ctx, appPath)
 	}
 
-	createTime, err := proc.CreateTimeWithContext(ctx)
-	var startTime string
-	if err == nil {
-		startTime = time.Unix(createTime/1000, 0).Format(time.RFC3339)
+	// Tentar obter informações do Info.plist
+	plistPath := filepath.Join(appPath, "Contents", "Info.plist")
+	if plistInfo, err := c.parseInfoPlist(plistPath); err == nil {
+		if version, ok := plistInfo["CFBundleShortVersionString"].(string); ok {
+			app.Version = version
+		}
+		if vendor, ok := plistInfo["CFBundleIdentifier"].(string); ok {
+			app.Vendor = vendor
+		}
 	}
 
-	return &Process{
-		PID:         proc.Pid,
-		Name:        name,
-		Command:     cmdline,
-		CPUPercent:  cpuPercent,
-		MemoryUsage: memoryUsage,
-		Status:      status,
-		User:        username,
-		StartTime:   startTime,
-	}, nil
+	return app, nil
 }
 
-// collectRunningServices coleta serviços em execução (específico do macOS)
-func (c *SystemCollector) collectRunningServices(ctx context.Context) ([]Service, error) {
-	c.logger.Debug("Collecting running services...")
-
-	// Executar launchctl list
-	cmd := exec.CommandContext(ctx, "launchctl", "list")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute launchctl: %w", err)
-	}
-
-	var services []Service
-	lines := strings.Split(string(output), "\n")
-
-	for _, line := range lines[1:] { // Pular cabeçalho
-		if line == "" {
-			continue
-		}
-
-		fields := strings.Fields(line)
-		if len(fields) < 3 {
-			continue
+// bundleSize soma o tamanho dos arquivos de um bundle, interrompendo no cancelamento
+func bundleSize(ctx context.Context, root string) int64 {
+	var size int64
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-
-		pid := fields[0]
-		status := fields[1]
-		name := fields[2]
-
-		// Converter PID
-		pidInt, err := strconv.Atoi(pid)
-		var pidInt32 int32
-		if err == nil {
-			pidInt32 = int32(pidInt)
+		if err != nil || d.IsDir() {
+			return nil
 		}
-
-		service := Service{
-			Name:   name,
-			Status: status,
-			PID:    pidInt32,
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
 		}
+		return nil
+	})
+	return size
+}
 
-		services = append(services, service)
-	}
-
-	return services, nil
+// parseInfoPlist parse básico do Info.plist (simplificado)
+func (c *SystemCollector) parseInfoPlist(path string) (map[string]interface{}, error) {
+	// Implementação simplificada - na prática, usaria uma biblioteca plist
+	// Por ora, retornar vazio
+	return map[string]interface{}{}, nil
 }
 
 // collectNetworkInfoInternal coleta informações de rede
@@ -759,40 +994,50 @@ func (c *SystemCollector) collectNetworkInfoInternal(ctx context.Context) (*Netw
 		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
+	// Contadores por interface, lidos uma vez; com pernic=false o gopsutil
+	// retorna só o agregado "all"
+	counters := make(map[string]net.IOCountersStat)
+	if stats, err := net.IOCountersWithContext(ctx, true); err != nil {
+		c.logger.WithField("error", err).Warning("Failed to get network IO counters")
+	} else {
+		for _, stat := range stats {
+			counters[stat.Name] = stat
+		}
+	}
+
+	links := c.interfaceLinks(ctx, interfaces)
+	running := runningInterfaces()
+
 	var networkInterfaces []NetworkInterface
 	var totalBytesSent, totalBytesRecv uint64
 
 	for _, iface := range interfaces {
-		// Obter estatísticas da interface
-		stats, err := net.IOCountersWithContext(ctx, false)
-		if err != nil {
-			c.logger.WithField("error", err).Warning("Failed to get network IO counters")
-			continue
-		}
-
-		var ifaceStats *net.IOCountersStat
-		for _, stat := range stats {
-			if stat.Name == iface.Name {
-				ifaceStats = &stat
-				break
-			}
-		}
-
-		if ifaceStats == nil {
-			continue
+		link := links[iface.Name]
+		// Sem a flag running conhecida, uma interface ligada conta como up
+		isRunning, known := running[iface.Name]
+		if !known {
+			isRunning = true
 		}
-
 		networkInterface := NetworkInterface{
 			Name:         iface.Name,
 			HardwareAddr: iface.HardwareAddr,
 			MTU:          iface.MTU,
-			Status:       "up", // Simplificado
-			BytesSent:    ifaceStats.BytesSent,
-			BytesRecv:    ifaceStats.BytesRecv,
-			PacketsSent:  ifaceStats.PacketsSent,
-			PacketsRecv:  ifaceStats.PacketsRecv,
-			Errors:       ifaceStats.Errin + ifaceStats.Errout,
-			Drops:        ifaceStats.Dropin + ifaceStats.Dropout,
+			Status:       interfaceStatus(iface.Flags, isRunning),
+			Type:         link.Type,
+			Speed:        link.Speed,
+		}
+		if networkInterface.Type == "" {
+			networkInterface.Type = classifyInterface(iface.Name, iface.Flags)
+		}
+
+		// Interfaces sem contadores (ex.: desligadas) entram zeradas
+		if stat, ok := counters[iface.Name]; ok {
+			networkInterface.BytesSent = stat.BytesSent
+			networkInterface.BytesRecv = stat.BytesRecv
+			networkInterface.PacketsSent = stat.PacketsSent
+			networkInterface.PacketsRecv = stat.PacketsRecv
+			networkInterface.Errors = stat.Errin + stat.Errout
+			networkInterface.Drops = stat.Dropin + stat.Dropout
 		}
 
 		// Adicionar endereços IP
@@ -803,17 +1048,23 @@ func (c *SystemCollector) collectNetworkInfoInternal(ctx context.Context) (*Netw
 		networkInterfaces = append(networkInterfaces, networkInterface)
 
 		// Somar para estatísticas globais
-		totalBytesSent += ifaceStats.BytesSent
-		totalBytesRecv += ifaceStats.BytesRecv
+		totalBytesSent += networkInterface.BytesSent
+		totalBytesRecv += networkInterface.BytesRecv
 	}
 
-	return &NetworkInfo{
+	now := c.clock.Now()
+	c.applyInterfaceRates(networkInterfaces, counters, now)
+
+	info := &NetworkInfo{
 		Interfaces: networkInterfaces,
 		Statistics: NetworkStatistics{
 			TotalBytesSent: totalBytesSent,
 			TotalBytesRecv: totalBytesRecv,
 		},
-	}, nil
+		TopTalkers: c.collectTopTalkers(ctx, c.configFor(ctx).NetworkTopTalkers, now),
+	}
+	applyNetworkRouting(info, c.collectNetworkRouting(ctx))
+	return info, nil
 }
 
 // collectMacOSSpecificInternal coleta informações específicas do macOS
@@ -823,8 +1074,11 @@ func (c *SystemCollector) collectMacOSSpecificInternal(ctx context.Context) (*Ma
 	macOSInfo := &MacOSInfo{}
 
 	// Obter informações do system_profiler
-	if systemProfiler, err := c.getSystemProfiler(ctx); err == nil {
-		macOSInfo.SystemProfiler = systemProfiler
+	if result, err := c.getSPHardware(ctx); err == nil {
+		macOSInfo.Hardware = result.hardware
+		if c.configFor(ctx).IncludeRawSystemProfiler {
+			macOSInfo.SystemProfilerRaw, macOSInfo.SystemProfilerRawTruncated = c.systemProfilerRaw(result.raw)
+		}
 	}
 
 	// Obter serviços do launchd
@@ -842,29 +1096,26 @@ func (c *SystemCollector) collectMacOSSpecificInternal(ctx context.Context) (*Ma
 		macOSInfo.XcodeVersion = xcodeVersion
 	}
 
-	return macOSInfo, nil
-}
-
-// getSystemProfiler obtém informações do system_profiler
-func (c *SystemCollector) getSystemProfiler(ctx context.Context) (map[string]interface{}, error) {
-	cmd := exec.CommandContext(ctx, "system_profiler", "SPHardwareDataType", "-json")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute system_profiler: %w", err)
+	// Obter perfis de configuração instalados
+	if policies, err := c.collectPolicies(ctx); err == nil {
+		macOSInfo.Policies = policies
+	} else {
+		c.logger.WithField("error", err).Debug("Failed to collect configuration profiles")
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse system_profiler output: %w", err)
+	// Obter status do Time Machine
+	if timeMachine, err := c.collectTimeMachine(ctx); err == nil {
+		macOSInfo.TimeMachine = timeMachine
+	} else {
+		c.logger.WithField("error", err).Debug("Failed to collect Time Machine status")
 	}
 
-	return result, nil
+	return macOSInfo, nil
 }
 
 // getLaunchdServices obtém serviços do launchd
 func (c *SystemCollector) getLaunchdServices(ctx context.Context) ([]LaunchdService, error) {
-	cmd := exec.CommandContext(ctx, "launchctl", "list")
-	output, err := cmd.Output()
+	output, err := c.runProbe(ctx, "launchctl", "list")
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute launchctl: %w", err)
 	}
@@ -897,8 +1148,7 @@ func (c *SystemCollector) getLaunchdServices(ctx context.Context) ([]LaunchdServ
 // getHomebrewInfo obtém informações do Homebrew
 func (c *SystemCollector) getHomebrewInfo(ctx context.Context) (*HomebrewInfo, error) {
 	// Verificar se o Homebrew está instalado
-	cmd := exec.CommandContext(ctx, "brew", "--version")
-	output, err := cmd.Output()
+	output, err := c.runProbe(ctx, "brew", "--version")
 	if err != nil {
 		return nil, fmt.Errorf("homebrew not installed: %w", err)
 	}
@@ -906,8 +1156,7 @@ func (c *SystemCollector) getHomebrewInfo(ctx context.Context) (*HomebrewInfo, e
 	version := strings.TrimSpace(string(output))
 
 	// Listar pacotes instalados
-	cmd = exec.CommandContext(ctx, "brew", "list")
-	output, err = cmd.Output()
+	output, err = c.runProbe(ctx, "brew", "list")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list brew packages: %w", err)
 	}
@@ -922,8 +1171,7 @@ func (c *SystemCollector) getHomebrewInfo(ctx context.Context) (*HomebrewInfo, e
 
 // getXcodeVersion obtém versão do Xcode
 func (c *SystemCollector) getXcodeVersion(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "xcodebuild", "-version")
-	output, err := cmd.Output()
+	output, err := c.runProbe(ctx, "xcodebuild", "-version")
 	if err != nil {
 		return "", fmt.Errorf("failed to get Xcode version: %w", err)
 	}
@@ -938,7 +1186,7 @@ func (c *SystemCollector) getXcodeVersion(ctx context.Context) (string, error) {
 
 // getFromCache obtém dados do cache
 func (c *SystemCollector) getFromCache(key string) interface{} {
-	if !c.config.EnableCache {
+	if !c.cfg().EnableCache {
 		return nil
 	}
 
@@ -950,8 +1198,8 @@ func (c *SystemCollector) getFromCache(key string) interface{} {
 		return nil
 	}
 
-	// Verificar se expirou
-	if time.Since(item.Timestamp) > item.TTL {
+	// Verificar se expirou (inclusive se o relógio voltou desde o armazenamento)
+	if clock.Expired(c.clock, item.Timestamp, item.TTL) {
 		// Remover item expirado
 		delete(c.cache, key)
 		return nil
@@ -962,7 +1210,7 @@ func (c *SystemCollector) getFromCache(key string) interface{} {
 
 // setInCache armazena dados no cache
 func (c *SystemCollector) setInCache(key string, data interface{}, ttl time.Duration) {
-	if !c.config.EnableCache {
+	if !c.cfg().EnableCache {
 		return
 	}
 
@@ -971,38 +1219,63 @@ func (c *SystemCollector) setInCache(key string, data interface{}, ttl time.Dura
 
 	c.cache[key] = &CacheItem{
 		Data:      data,
-		Timestamp: time.Now(),
+		Timestamp: c.clock.Now(),
 		TTL:       ttl,
 	}
 }
 
-// ClearCache limpa o cache
+// ClearCache limpa o cache, exceto o machine_id (que é persistido e não muda).
+// Prefira InvalidateCache para atualizar apenas as seções necessárias.
 func (c *SystemCollector) ClearCache() {
 	c.cacheMu.Lock()
 	defer c.cacheMu.Unlock()
 
-	c.cache = make(map[string]*CacheItem)
+	for key := range c.cache {
+		if key != CacheKeyMachineID {
+			delete(c.cache, key)
+		}
+	}
 	c.logger.Debug("Cache cleared")
 }
 
+// InvalidateCache remove apenas as chaves informadas do cache
+func (c *SystemCollector) InvalidateCache(keys ...string) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	for _, key := range keys {
+		delete(c.cache, key)
+	}
+	c.logger.Debug("Cache invalidated: %v", keys)
+}
+
 // GetCacheStats retorna estatísticas do cache
 func (c *SystemCollector) GetCacheStats() map[string]interface{} {
 	c.cacheMu.RLock()
 	defer c.cacheMu.RUnlock()
 
 	stats := map[string]interface{}{
-		"enabled": c.config.EnableCache,
+		"enabled": c.cfg().EnableCache,
 		"items":   len(c.cache),
 	}
 
 	var expired int
-	for _, item := range c.cache {
-		if time.Since(item.Timestamp) > item.TTL {
+	keys := make(map[string]interface{}, len(c.cache))
+	for key, item := range c.cache {
+		age := c.clock.Since(item.Timestamp)
+		isExpired := clock.Expired(c.clock, item.Timestamp, item.TTL)
+		if isExpired {
 			expired++
 		}
+		keys[key] = map[string]interface{}{
+			"age_seconds": age.Seconds(),
+			"ttl_seconds": item.TTL.Seconds(),
+			"expired":     isExpired,
+		}
 	}
 
 	stats["expired"] = expired
+	stats["keys"] = keys
 
 	return stats
 }
@@ -1010,7 +1283,7 @@ func (c *SystemCollector) GetCacheStats() map[string]interface{} {
 // generateMachineID gera um identificador único para a máquina
 func (c *SystemCollector) generateMachineID(ctx context.Context) (string, error) {
 	// Tentar obter do cache primeiro (cache persistente)
-	if cachedData := c.getFromCache("machine_id"); cachedData != nil {
+	if cachedData := c.getFromCache(CacheKeyMachineID); cachedData != nil {
 		if machineID, ok := cachedData.(string); ok && machineID != "" {
 			return machineID, nil
 		}
@@ -1021,19 +1294,19 @@ func (c *SystemCollector) generateMachineID(ctx context.Context) (string, error)
 	// Método 1: Hardware UUID via system_profiler
 	if machineID, err := c.getMachineIDFromSystemProfiler(ctx); err == nil && machineID != "" {
 		// Cachear por 24 horas (não deve mudar)
-		c.setInCache("machine_id", machineID, 24*time.Hour)
+		c.setInCache(CacheKeyMachineID, machineID, 24*time.Hour)
 		return machineID, nil
 	}
 
 	// Método 2: Hardware UUID via ioreg
 	if machineID, err := c.getMachineIDFromIOReg(ctx); err == nil && machineID != "" {
-		c.setInCache("machine_id", machineID, 24*time.Hour)
+		c.setInCache(CacheKeyMachineID, machineID, 24*time.Hour)
 		return machineID, nil
 	}
 
 	// Método 3: Fallback - combinação de características únicas
 	if machineID, err := c.generateFallbackMachineID(ctx); err == nil && machineID != "" {
-		c.setInCache("machine_id", machineID, 24*time.Hour)
+		c.setInCache(CacheKeyMachineID, machineID, 24*time.Hour)
 		return machineID, nil
 	}
 
@@ -1042,33 +1315,21 @@ func (c *SystemCollector) generateMachineID(ctx context.Context) (string, error)
 
 // getMachineIDFromSystemProfiler obtém UUID do hardware via system_profiler
 func (c *SystemCollector) getMachineIDFromSystemProfiler(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "system_profiler", "SPHardwareDataType", "-json")
-	output, err := cmd.Output()
+	result, err := c.getSPHardware(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute system_profiler: %w", err)
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(output, &result); err != nil {
-		return "", fmt.Errorf("failed to parse system_profiler output: %w", err)
+		return "", err
 	}
 
-	// Navegar na estrutura JSON para encontrar o UUID
-	if spHardwareData, ok := result["SPHardwareDataType"].([]interface{}); ok && len(spHardwareData) > 0 {
-		if hardwareData, ok := spHardwareData[0].(map[string]interface{}); ok {
-			if platformUUID, ok := hardwareData["platform_UUID"].(string); ok {
-				return platformUUID, nil
-			}
-		}
+	if result.hardware.PlatformUUID == "" {
+		return "", fmt.Errorf("UUID not found in system_profiler output")
 	}
 
-	return "", fmt.Errorf("UUID not found in system_profiler output")
+	return result.hardware.PlatformUUID, nil
 }
 
 // getMachineIDFromIOReg obtém UUID do hardware via ioreg
 func (c *SystemCollector) getMachineIDFromIOReg(ctx context.Context) (string, error) {
-	cmd := exec.CommandContext(ctx, "ioreg", "-rd1", "-c", "IOPlatformExpertDevice")
-	output, err := cmd.Output()
+	output, err := c.runProbe(ctx, "ioreg", "-rd1", "-c", "IOPlatformExpertDevice")
 	if err != nil {
 		return "", fmt.Errorf("failed to execute ioreg: %w", err)
 	}
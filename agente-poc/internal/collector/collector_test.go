@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeAppBundles cria count bundles .app falsos em root, metade dentro de
+// um subdiretório, cada um com files arquivos de 4 KB
+func makeAppBundles(tb testing.TB, root string, count, files int) {
+	tb.Helper()
+	payload := make([]byte, 4096)
+	for i := 0; i < count; i++ {
+		dir := root
+		if i%2 == 1 {
+			dir = filepath.Join(root, "Utilities")
+		}
+		contents := filepath.Join(dir, fmt.Sprintf("App%03d.app", i), "Contents")
+		if err := os.MkdirAll(filepath.Join(contents, "MacOS"), 0755); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(contents, "Info.plist"), []byte("<plist/>"), 0644); err != nil {
+			tb.Fatal(err)
+		}
+		for f := 0; f < files; f++ {
+			if err := os.WriteFile(filepath.Join(contents, "MacOS", fmt.Sprintf("bin%d", f)), payload, 0644); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+}
+
+// appScanConfig é a configuração do collector apontada para root
+func appScanConfig(c *SystemCollector, root string, workers int, timeout time.Duration) *CollectorConfig {
+	config := *c.cfg()
+	config.ApplicationsPath = root
+	config.AppScanWorkers = workers
+	config.AppScanTimeout = timeout
+	config.MaxApplications = 1000
+	return &config
+}
+
+func TestScanAppBundles(t *testing.T) {
+	root := t.TempDir()
+	makeAppBundles(t, root, 40, 2)
+	c := newTestCollector(t)
+
+	config := appScanConfig(c, root, 4, 10*time.Second)
+	config.ComputeAppSizes = true
+	apps, partial, err := c.scanAppBundles(context.WithValue(context.Background(), configKey{}, config), config)
+	if err != nil || partial {
+		t.Fatalf("scan: partial=%t err=%v", partial, err)
+	}
+	if len(apps) != 40 {
+		t.Fatalf("found %d bundles, want 40", len(apps))
+	}
+	for i := 1; i < len(apps); i++ {
+		if apps[i-1].Path > apps[i].Path {
+			t.Fatal("applications not sorted by path")
+		}
+	}
+	if want := int64(2*4096 + len("<plist/>")); apps[0].Size != want {
+		t.Fatalf("bundle size = %d, want %d", apps[0].Size, want)
+	}
+	if cached := c.getFromCache(CacheKeyInstalledApps); cached == nil {
+		t.Fatal("complete scan was not cached")
+	}
+}
+
+func TestScanAppBundlesDeadline(t *testing.T) {
+	root := t.TempDir()
+	makeAppBundles(t, root, 50, 0)
+	c := newTestCollector(t)
+
+	config := appScanConfig(c, root, 2, time.Nanosecond)
+	apps, partial, err := c.scanAppBundles(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !partial || len(apps) == 50 {
+		t.Fatalf("deadline exceeded: partial=%t with %d apps", partial, len(apps))
+	}
+	if cached := c.getFromCache(CacheKeyInstalledApps); cached != nil {
+		t.Fatal("partial scan was cached")
+	}
+}
+
+func TestScanAppBundlesCancelledParent(t *testing.T) {
+	root := t.TempDir()
+	makeAppBundles(t, root, 20, 0)
+	c := newTestCollector(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, partial, err := c.scanAppBundles(ctx, appScanConfig(c, root, 2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !partial {
+		t.Fatal("scan cut short by a cancelled parent context reported as complete")
+	}
+	if cached := c.getFromCache(CacheKeyInstalledApps); cached != nil {
+		t.Fatal("scan cut short by a cancelled parent context was cached")
+	}
+}
+
+func TestScanAppBundlesLimit(t *testing.T) {
+	root := t.TempDir()
+	makeAppBundles(t, root, 30, 0)
+	c := newTestCollector(t)
+
+	config := appScanConfig(c, root, 4, time.Minute)
+	config.MaxApplications = 10
+	apps, partial, err := c.scanAppBundles(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partial || len(apps) != 10 {
+		t.Fatalf("limited scan: partial=%t with %d apps, want 10 complete", partial, len(apps))
+	}
+}
+
+func TestScanAppBundlesMissingDirectory(t *testing.T) {
+	c := newTestCollector(t)
+	apps, partial, err := c.scanAppBundles(context.Background(), appScanConfig(c, filepath.Join(t.TempDir(), "missing"), 2, time.Minute))
+	if err != nil || partial || len(apps) != 0 {
+		t.Fatalf("missing directory: %d apps, partial=%t, err=%v", len(apps), partial, err)
+	}
+}
+
+func TestSetAppScan(t *testing.T) {
+	c := newTestCollector(t)
+	defaults := *c.cfg()
+
+	c.SetAppScan(0, 0, true)
+	if got := c.cfg(); got.AppScanWorkers != defaults.AppScanWorkers || got.AppScanTimeout != defaults.AppScanTimeout || !got.ComputeAppSizes {
+		t.Fatalf("zero values changed the defaults: %+v", got)
+	}
+	c.SetAppScan(3, time.Second, false)
+	if got := c.cfg(); got.AppScanWorkers != 3 || got.AppScanTimeout != time.Second || got.ComputeAppSizes {
+		t.Fatalf("SetAppScan not applied: %+v", got)
+	}
+}
+
+// BenchmarkScanAppBundles varre 500 bundles falsos com tamanhos calculados;
+// o tempo por operação deve cair quase linearmente com os workers
+func BenchmarkScanAppBundles(b *testing.B) {
+	root := b.TempDir()
+	makeAppBundles(b, root, 500, 8)
+	c := newTestCollector(b)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			config := appScanConfig(c, root, workers, time.Minute)
+			config.ComputeAppSizes = true
+			ctx := context.WithValue(context.Background(), configKey{}, config)
+			for i := 0; i < b.N; i++ {
+				apps, partial, err := c.scanAppBundles(ctx, config)
+				if err != nil || partial || len(apps) != 500 {
+					b.Fatalf("scan: %d apps, partial=%t, err=%v", len(apps), partial, err)
+				}
+			}
+		})
+	}
+
+	b.Run("deadline", func(b *testing.B) {
+		config := appScanConfig(c, root, 4, 5*time.Millisecond)
+		config.ComputeAppSizes = true
+		ctx := context.WithValue(context.Background(), configKey{}, config)
+		for i := 0; i < b.N; i++ {
+			start := time.Now()
+			if _, _, err := c.scanAppBundles(ctx, config); err != nil {
+				b.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				b.Fatalf("scan took %s with a 5ms deadline", elapsed)
+			}
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// CPUCluster é um grupo homogêneo de núcleos (no Apple Silicon, os núcleos
+// de desempenho e os de eficiência)
+type CPUCluster struct {
+	Name      string `json:"name"`
+	Cores     int32  `json:"cores"`
+	Threads   int32  `json:"threads"`
+	CacheSize int32  `json:"l2_cache_size_kb,omitempty"` // soma dos L2 do cluster
+}
+
+// collectCPUInfo coleta informações da CPU: núcleos físicos e threads
+// lógicas vêm das contagens do sistema, não do primeiro InfoStat
+func (c *SystemCollector) collectCPUInfo(ctx context.Context) (*CPUInfo, error) {
+	// Informações estáticas da CPU
+	cpuInfos, err := cpu.InfoWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CPU info: %w", err)
+	}
+
+	if len(cpuInfos) == 0 {
+		return nil, fmt.Errorf("no CPU info available")
+	}
+
+	physical, err := cpu.CountsWithContext(ctx, false)
+	if err != nil {
+		c.logger.WithField("error", err).Debug("Failed to count physical CPU cores")
+		physical = 0
+	}
+	logical, err := cpu.CountsWithContext(ctx, true)
+	if err != nil {
+		c.logger.WithField("error", err).Debug("Failed to count logical CPUs")
+		logical = 0
+	}
+
+	// Uso da CPU por núcleo lógico
+	cpuPercent, err := cpu.PercentWithContext(ctx, time.Second, true)
+	if err != nil {
+		c.logger.WithField("error", err).Warning("Failed to get CPU usage")
+		cpuPercent = []float64{0.0} // Valor padrão
+	}
+
+	info := summarizeCPU(cpuInfos, physical, logical, cpuPercent)
+
+	// No Apple Silicon o InfoStat não traz cache; os clusters vêm do sysctl
+	if runtime.GOOS == "darwin" {
+		if clusters, err := c.darwinCPUClusters(ctx); err == nil && len(clusters) > 0 {
+			applyCPUClusters(info, clusters)
+		}
+	}
+
+	return info, nil
+}
+
+// summarizeCPU monta o CPUInfo a partir do que o gopsutil retorna. O número
+// de InfoStat varia por plataforma (um por thread no Linux, um por soquete
+// no Windows, um só no macOS), então contagens, cache e frequência não
+// podem sair do primeiro elemento. Contagens zeradas (falha ao contar)
+// são estimadas dos InfoStat.
+func summarizeCPU(infos []cpu.InfoStat, physical, logical int, perCore []float64) *CPUInfo {
+	first := infos[0]
+	info := &CPUInfo{
+		Model:  first.ModelName,
+		Vendor: first.VendorID,
+		Family: first.Family,
+		Usage:  perCore,
+	}
+
+	if physical <= 0 {
+		physical = countPhysicalCores(infos)
+	}
+	if logical <= 0 {
+		logical = physical
+	}
+	info.Cores = int32(physical)
+	info.Threads = int32(logical)
+
+	// Frequência: a maior entre os núcleos (em CPUs híbridas, a dos núcleos
+	// de desempenho)
+	for _, stat := range infos {
+		info.Frequency = math.Max(info.Frequency, stat.Mhz)
+	}
+
+	// Cache: cada thread do mesmo soquete repete o cache compartilhado, então
+	// conta-se uma vez por soquete
+	sockets := make(map[string]int32)
+	for _, stat := range infos {
+		if stat.CacheSize > sockets[stat.PhysicalID] {
+			sockets[stat.PhysicalID] = stat.CacheSize
+		}
+	}
+	for _, size := range sockets {
+		info.CacheSize += size
+	}
+
+	if len(perCore) > 0 {
+		var total float64
+		for _, usage := range perCore {
+			total += usage
+		}
+		info.UsageTotal = math.Round(total/float64(len(perCore))*100) / 100
+	}
+
+	return info
+}
+
+// countPhysicalCores estima os núcleos físicos pelos InfoStat: pares
+// soquete/núcleo distintos quando o CoreID é informado (Linux), senão a
+// soma de Cores
+func countPhysicalCores(infos []cpu.InfoStat) int {
+	cores := make(map[string]struct{})
+	total := 0
+	for _, stat := range infos {
+		if stat.CoreID != "" {
+			cores[stat.PhysicalID+"/"+stat.CoreID] = struct{}{}
+		}
+		total += int(stat.Cores)
+	}
+	if len(cores) > 0 {
+		return len(cores)
+	}
+	return total
+}
+
+// darwinCPUClusters lê os níveis de desempenho (hw.perflevelN) do Apple
+// Silicon; Macs Intel não têm hw.nperflevels e retornam erro
+func (c *SystemCollector) darwinCPUClusters(ctx context.Context) ([]CPUCluster, error) {
+	output, err := c.runProbe(ctx, "sysctl", "-n", "hw.nperflevels")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hw.nperflevels: %w", err)
+	}
+	levels, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil || levels <= 0 {
+		return nil, fmt.Errorf("unexpected hw.nperflevels: %q", strings.TrimSpace(string(output)))
+	}
+
+	names := make([]string, levels)
+	for i := range names {
+		names[i] = fmt.Sprintf("hw.perflevel%d", i)
+	}
+	output, err = c.runProbe(ctx, "sysctl", names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CPU performance levels: %w", err)
+	}
+	return parsePerfLevels(output, levels), nil
+}
+
+// parsePerfLevels interpreta as linhas "hw.perflevelN.chave: valor" do
+// sysctl. O L2 é compartilhado por cpusperl2 núcleos, então o cache do
+// cluster é l2cachesize × (núcleos / cpusperl2).
+func parsePerfLevels(output []byte, levels int) []CPUCluster {
+	values := make([]map[string]string, levels)
+	for i := range values {
+		values[i] = make(map[string]string)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		var level int
+		var field string
+		if n, _ := fmt.Sscanf(strings.Replace(key, ".", " ", 2), "hw perflevel%d %s", &level, &field); n != 2 {
+			continue
+		}
+		if level >= 0 && level < levels {
+			values[level][field] = strings.TrimSpace(value)
+		}
+	}
+
+	number := func(fields map[string]string, key string) int64 {
+		value, _ := strconv.ParseInt(fields[key], 10, 64)
+		return value
+	}
+
+	var clusters []CPUCluster
+	for i, fields := range values {
+		cores := number(fields, "physicalcpu")
+		if cores <= 0 {
+			continue
+		}
+		cluster := CPUCluster{
+			Name:    fields["name"],
+			Cores:   int32(cores),
+			Threads: int32(number(fields, "logicalcpu")),
+		}
+		if cluster.Name == "" {
+			cluster.Name = fmt.Sprintf("perflevel%d", i)
+		}
+		if l2, perL2 := number(fields, "l2cachesize"), number(fields, "cpusperl2"); l2 > 0 && perL2 > 0 {
+			shared := (cores + perL2 - 1) / perL2
+			cluster.CacheSize = int32(l2 * shared / 1024)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// applyCPUClusters registra os clusters e soma o cache de todos eles
+func applyCPUClusters(info *CPUInfo, clusters []CPUCluster) {
+	info.Clusters = clusters
+	var cache int32
+	for _, cluster := range clusters {
+		cache += cluster.CacheSize
+	}
+	if cache > 0 {
+		info.CacheSize = cache
+	}
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// linuxThreads monta os InfoStat do Linux: um por thread lógica, com
+// threadsPerCore threads em cada núcleo de cada soquete
+func linuxThreads(sockets, coresPerSocket, threadsPerCore int, cacheKB int32) []cpu.InfoStat {
+	var infos []cpu.InfoStat
+	for socket := 0; socket < sockets; socket++ {
+		for core := 0; core < coresPerSocket; core++ {
+			for thread := 0; thread < threadsPerCore; thread++ {
+				infos = append(infos, cpu.InfoStat{
+					ModelName:  "Intel(R) Xeon(R) Gold 6230",
+					VendorID:   "GenuineIntel",
+					Family:     "6",
+					PhysicalID: string(rune('0' + socket)),
+					CoreID:     string(rune('0' + core)),
+					Cores:      1,
+					Mhz:        2100,
+					CacheSize:  cacheKB,
+				})
+			}
+		}
+	}
+	return infos
+}
+
+func TestSummarizeCPU(t *testing.T) {
+	tests := []struct {
+		name              string
+		infos             []cpu.InfoStat
+		physical, logical int
+		wantCores         int32
+		wantThreads       int32
+		wantCache         int32
+	}{
+		{
+			name:      "linux with hyperthreading",
+			infos:     linuxThreads(1, 4, 2, 8192),
+			physical:  4,
+			logical:   8,
+			wantCores: 4, wantThreads: 8, wantCache: 8192,
+		},
+		{
+			name:      "linux without hyperthreading",
+			infos:     linuxThreads(1, 4, 1, 6144),
+			physical:  4,
+			logical:   4,
+			wantCores: 4, wantThreads: 4, wantCache: 6144,
+		},
+		{
+			// O cache de cada soquete conta uma vez
+			name:      "two sockets",
+			infos:     linuxThreads(2, 4, 2, 22528),
+			physical:  8,
+			logical:   16,
+			wantCores: 8, wantThreads: 16, wantCache: 2 * 22528,
+		},
+		{
+			// Contagens falharam: núcleos pelos pares soquete/núcleo distintos
+			name:      "counts unavailable with core IDs",
+			infos:     linuxThreads(1, 4, 2, 8192),
+			wantCores: 4, wantThreads: 4, wantCache: 8192,
+		},
+		{
+			// Windows: um InfoStat por soquete, com Cores preenchido
+			name:      "counts unavailable per socket",
+			infos:     []cpu.InfoStat{{ModelName: "AMD Ryzen 7 5800X", PhysicalID: "0", Cores: 8, Mhz: 3800}},
+			wantCores: 8, wantThreads: 8,
+		},
+		{
+			// macOS: um InfoStat só para a máquina inteira
+			name:      "apple silicon",
+			infos:     []cpu.InfoStat{{ModelName: "Apple M2 Pro", Cores: 12, Mhz: 3504}},
+			physical:  12,
+			logical:   12,
+			wantCores: 12, wantThreads: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := summarizeCPU(tt.infos, tt.physical, tt.logical, nil)
+			if info.Cores != tt.wantCores || info.Threads != tt.wantThreads || info.CacheSize != tt.wantCache {
+				t.Fatalf("cores %d, threads %d, cache %d; want %d, %d, %d",
+					info.Cores, info.Threads, info.CacheSize, tt.wantCores, tt.wantThreads, tt.wantCache)
+			}
+			if info.Model != tt.infos[0].ModelName {
+				t.Fatalf("model = %q", info.Model)
+			}
+		})
+	}
+}
+
+func TestSummarizeCPUFrequencyAndUsage(t *testing.T) {
+	// CPU híbrida: a frequência reportada é a dos núcleos de desempenho
+	infos := []cpu.InfoStat{
+		{ModelName: "12th Gen Intel(R) Core(TM) i7-1260P", PhysicalID: "0", CoreID: "0", Mhz: 2100},
+		{ModelName: "12th Gen Intel(R) Core(TM) i7-1260P", PhysicalID: "0", CoreID: "8", Mhz: 4700},
+		{ModelName: "12th Gen Intel(R) Core(TM) i7-1260P", PhysicalID: "0", CoreID: "9", Mhz: 3400},
+	}
+	perCore := []float64{10, 20, 33.333}
+	info := summarizeCPU(infos, 3, 3, perCore)
+	if info.Frequency != 4700 {
+		t.Fatalf("frequency = %f, want the highest 4700", info.Frequency)
+	}
+	if info.UsageTotal != 21.11 || !reflect.DeepEqual(info.Usage, perCore) {
+		t.Fatalf("usage total %f, per core %v", info.UsageTotal, info.Usage)
+	}
+	if info := summarizeCPU(infos, 3, 3, nil); info.UsageTotal != 0 {
+		t.Fatalf("usage total without samples = %f", info.UsageTotal)
+	}
+}
+
+func TestParsePerfLevels(t *testing.T) {
+	clusters := parsePerfLevels(readFixture(t, "sysctl_perflevels.txt"), 2)
+	// 16 MiB de L2 por grupo de 4 núcleos: dois grupos no de desempenho
+	want := []CPUCluster{
+		{Name: "Performance", Cores: 8, Threads: 8, CacheSize: 2 * 16384},
+		{Name: "Efficiency", Cores: 4, Threads: 4, CacheSize: 4096},
+	}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Fatalf("parsePerfLevels = %+v, want %+v", clusters, want)
+	}
+
+	// Nível sem núcleos é ignorado; sem nome recebe o do índice
+	clusters = parsePerfLevels([]byte("hw.perflevel0.physicalcpu: 4\nhw.perflevel1.physicalcpu: 0\nhw.perflevel2.physicalcpu: 2\n"), 2)
+	if len(clusters) != 1 || clusters[0].Name != "perflevel0" || clusters[0].CacheSize != 0 {
+		t.Fatalf("clusters = %+v", clusters)
+	}
+
+	info := &CPUInfo{CacheSize: 128}
+	applyCPUClusters(info, want)
+	if info.CacheSize != 2*16384+4096 || len(info.Clusters) != 2 {
+		t.Fatalf("cache with clusters = %d", info.CacheSize)
+	}
+	info = &CPUInfo{CacheSize: 128}
+	applyCPUClusters(info, []CPUCluster{{Name: "perflevel0", Cores: 4}})
+	if info.CacheSize != 128 {
+		t.Fatalf("cache replaced by clusters without L2: %d", info.CacheSize)
+	}
+}
+
+func TestDarwinCPUClusters(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		"sysctl -n hw.nperflevels":           []byte("2\n"),
+		"sysctl hw.perflevel0 hw.perflevel1": readFixture(t, "sysctl_perflevels.txt"),
+	}))
+	clusters, err := c.darwinCPUClusters(context.Background())
+	if err != nil || len(clusters) != 2 {
+		t.Fatalf("clusters = %+v, %v", clusters, err)
+	}
+
+	// Mac Intel: sem hw.nperflevels
+	c.SetCommandRunner(newCountingRunner(nil))
+	if _, err := c.darwinCPUClusters(context.Background()); err == nil {
+		t.Fatal("clusters without hw.nperflevels")
+	}
+}
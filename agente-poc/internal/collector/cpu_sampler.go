@@ -0,0 +1,248 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"agente-poc/internal/clock"
+)
+
+// DefaultCPUSampleInterval é o intervalo entre amostras do ProcessCPUSampler
+const DefaultCPUSampleInterval = 10 * time.Second
+
+// ProcessKey identifica um processo de forma estável: o PID pode ser reusado
+// pelo sistema, o par PID + horário de criação não
+type ProcessKey struct {
+	PID        int32
+	CreateTime int64 // milissegundos desde a época, como em gopsutil
+}
+
+// ProcessCPUSample é o tempo de CPU acumulado de um processo em uma amostra
+type ProcessCPUSample struct {
+	Key        ProcessKey
+	Name       string
+	CPUSeconds float64 // user + system
+}
+
+// SustainedProcess é o processo com maior média sustentada de CPU
+type SustainedProcess struct {
+	PID                 int32   `json:"pid"`
+	Name                string  `json:"name"`
+	SustainedCPUPercent float64 `json:"sustained_cpu_percent"`
+	StartTime           string  `json:"start_time,omitempty"`
+}
+
+// cpuTrack é o estado da média de um processo entre amostras
+type cpuTrack struct {
+	name       string
+	lastCPU    float64
+	lastSample time.Time
+	average    float64
+	hasAverage bool
+}
+
+// ProcessCPUSampler mantém, em segundo plano, médias móveis exponenciais do
+// uso de CPU por processo. Uma amostra instantânea no momento da coleta
+// aponta processos que tiveram um pico de um segundo e perde os que rodam
+// quentes entre coletas; a média sustentada cobre a janela da coleta.
+type ProcessCPUSampler struct {
+	mu     sync.Mutex
+	window time.Duration
+	tracks map[ProcessKey]*cpuTrack
+	clock  clock.Clock
+}
+
+// NewProcessCPUSampler cria um sampler cuja média cobre window (a constante
+// de tempo da média exponencial, normalmente o intervalo de coleta)
+func NewProcessCPUSampler(window time.Duration) *ProcessCPUSampler {
+	return &ProcessCPUSampler{
+		window: window,
+		tracks: make(map[ProcessKey]*cpuTrack),
+		clock:  clock.Real,
+	}
+}
+
+// SetClock substitui a fonte de tempo usada nas amostras
+func (s *ProcessCPUSampler) SetClock(clk clock.Clock) {
+	s.mu.Lock()
+	s.clock = clock.OrReal(clk)
+	s.mu.Unlock()
+}
+
+// Run amostra os processos a cada interval até ctx ser cancelado
+func (s *ProcessCPUSampler) Run(ctx context.Context, interval time.Duration) {
+	s.Sample(ctx)
+
+	s.mu.Lock()
+	ticker := s.clock.NewTicker(interval)
+	s.mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			s.Sample(ctx)
+		}
+	}
+}
+
+// Sample lê o tempo de CPU de todos os processos e atualiza as médias
+func (s *ProcessCPUSampler) Sample(ctx context.Context) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return
+	}
+
+	samples := make([]ProcessCPUSample, 0, len(procs))
+	for _, proc := range procs {
+		createTime, err := proc.CreateTimeWithContext(ctx)
+		if err != nil {
+			continue // Processo pode ter terminado
+		}
+		times, err := proc.TimesWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		sample := ProcessCPUSample{
+			Key:        ProcessKey{PID: proc.Pid, CreateTime: createTime},
+			CPUSeconds: times.User + times.System,
+		}
+		// O nome só é lido na primeira vez que o processo aparece
+		if !s.tracked(sample.Key) {
+			sample.Name, _ = proc.NameWithContext(ctx)
+		}
+		samples = append(samples, sample)
+	}
+
+	s.mu.Lock()
+	now := s.clock.Now()
+	s.mu.Unlock()
+	s.Observe(samples, now)
+}
+
+// tracked indica se o processo já tem estado de amostras anteriores
+func (s *ProcessCPUSampler) tracked(key ProcessKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tracks[key]
+	return ok
+}
+
+// Observe incorpora uma rodada de amostras tomadas em now. O uso no
+// intervalo (delta de CPU / tempo decorrido) entra na média com peso
+// 1 - e^(-dt/window), de modo que intervalos irregulares pesam pelo tempo
+// que cobriram. A primeira amostra de um processo só estabelece a base.
+// Processos ausentes na rodada terminaram e são descartados; um PID reusado
+// tem outro horário de criação e começa do zero.
+func (s *ProcessCPUSampler) Observe(samples []ProcessCPUSample, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[ProcessKey]struct{}, len(samples))
+	for _, sample := range samples {
+		seen[sample.Key] = struct{}{}
+
+		track, ok := s.tracks[sample.Key]
+		if !ok {
+			s.tracks[sample.Key] = &cpuTrack{
+				name:       sample.Name,
+				lastCPU:    sample.CPUSeconds,
+				lastSample: now,
+			}
+			continue
+		}
+
+		elapsed := now.Sub(track.lastSample)
+		delta := sample.CPUSeconds - track.lastCPU
+		track.lastCPU = sample.CPUSeconds
+		track.lastSample = now
+		if sample.Name != "" {
+			track.name = sample.Name
+		}
+
+		// Relógio voltou ou contador regrediu: recomeça a base sem atualizar a média
+		if elapsed <= 0 || delta < 0 {
+			continue
+		}
+
+		percent := delta / elapsed.Seconds() * 100
+		if !track.hasAverage {
+			track.average = percent
+			track.hasAverage = true
+			continue
+		}
+
+		alpha := 1.0
+		if s.window > 0 {
+			alpha = 1 - math.Exp(-elapsed.Seconds()/s.window.Seconds())
+		}
+		track.average += alpha * (percent - track.average)
+	}
+
+	for key := range s.tracks {
+		if _, ok := seen[key]; !ok {
+			delete(s.tracks, key)
+		}
+	}
+}
+
+// Sustained retorna a média sustentada do processo; false se ainda não há
+// duas amostras dele
+func (s *ProcessCPUSampler) Sustained(key ProcessKey) (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	track, ok := s.tracks[key]
+	if !ok || !track.hasAverage {
+		return 0, false
+	}
+	return track.average, true
+}
+
+// Top retorna o processo com maior média sustentada
+func (s *ProcessCPUSampler) Top() (SustainedProcess, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.topKeysLocked(1)
+	if len(keys) == 0 {
+		return SustainedProcess{}, false
+	}
+	track := s.tracks[keys[0]]
+	return SustainedProcess{
+		PID:                 keys[0].PID,
+		Name:                track.name,
+		SustainedCPUPercent: math.Round(track.average*100) / 100,
+		StartTime:           time.Unix(keys[0].CreateTime/1000, 0).Format(time.RFC3339),
+	}, true
+}
+
+// topKeysLocked retorna até n processos com média, da maior para a menor;
+// mu já deve estar travado
+func (s *ProcessCPUSampler) topKeysLocked(n int) []ProcessKey {
+	keys := make([]ProcessKey, 0, len(s.tracks))
+	for key, track := range s.tracks {
+		if track.hasAverage {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := s.tracks[keys[i]].average, s.tracks[keys[j]].average
+		if a != b {
+			return a > b
+		}
+		return keys[i].PID < keys[j].PID
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// cpuSample monta uma amostra de CPU acumulada de um processo
+func cpuSample(pid int32, created int64, name string, cpuSeconds float64) ProcessCPUSample {
+	return ProcessCPUSample{Key: ProcessKey{PID: pid, CreateTime: created}, Name: name, CPUSeconds: cpuSeconds}
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestProcessCPUSamplerEWMA(t *testing.T) {
+	sampler := NewProcessCPUSampler(time.Minute)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	key := ProcessKey{PID: 100, CreateTime: 1000}
+
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "miner", 0)}, start)
+	if _, ok := sampler.Sustained(key); ok {
+		t.Fatal("average reported from a single sample")
+	}
+
+	// 5s de CPU em 10s: a primeira média é o próprio uso, 50%
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 5)}, start.Add(10*time.Second))
+	if average, _ := sampler.Sustained(key); !almostEqual(average, 50) {
+		t.Fatalf("first average = %f, want 50", average)
+	}
+
+	// 10s de CPU em 10s (100%) entram com peso 1 - e^(-10/60)
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 15)}, start.Add(20*time.Second))
+	want := 50 + (1-math.Exp(-10.0/60.0))*(100-50)
+	if average, _ := sampler.Sustained(key); !almostEqual(average, want) {
+		t.Fatalf("second average = %f, want %f", average, want)
+	}
+
+	// Um intervalo mais longo pesa mais: 30s ociosos
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 15)}, start.Add(50*time.Second))
+	want += (1 - math.Exp(-30.0/60.0)) * (0 - want)
+	if average, _ := sampler.Sustained(key); !almostEqual(average, want) {
+		t.Fatalf("average after an idle interval = %f, want %f", average, want)
+	}
+
+	top, ok := sampler.Top()
+	if !ok || top.PID != 100 || top.Name != "miner" || top.SustainedCPUPercent != math.Round(want*100)/100 {
+		t.Fatalf("Top() = %+v, %t", top, ok)
+	}
+}
+
+func TestProcessCPUSamplerSpikeVersusSustained(t *testing.T) {
+	sampler := NewProcessCPUSampler(time.Minute)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	// O minerador usa 80% o tempo todo; o outro processo só tem um pico na
+	// última amostra
+	var miner, spiky float64
+	for i := 0; i <= 6; i++ {
+		if i > 0 {
+			miner += 8
+		}
+		if i == 6 {
+			spiky += 10
+		}
+		sampler.Observe([]ProcessCPUSample{
+			cpuSample(200, 1, "miner", miner),
+			cpuSample(300, 1, "spiky", spiky),
+		}, start.Add(time.Duration(i)*10*time.Second))
+	}
+
+	top, ok := sampler.Top()
+	if !ok || top.PID != 200 {
+		t.Fatalf("Top() = %+v, want the sustained consumer", top)
+	}
+	spike, _ := sampler.Sustained(ProcessKey{PID: 300, CreateTime: 1})
+	if spike >= top.SustainedCPUPercent {
+		t.Fatalf("one-sample spike averaged %f, above the sustained %f", spike, top.SustainedCPUPercent)
+	}
+}
+
+func TestProcessCPUSamplerPIDReuse(t *testing.T) {
+	sampler := NewProcessCPUSampler(time.Minute)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	old := ProcessKey{PID: 100, CreateTime: 1000}
+	reused := ProcessKey{PID: 100, CreateTime: 2000}
+
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "miner", 0)}, start)
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 9)}, start.Add(10*time.Second))
+	if average, ok := sampler.Sustained(old); !ok || !almostEqual(average, 90) {
+		t.Fatalf("average before reuse = %f, %t", average, ok)
+	}
+
+	// O PID volta com outro horário de criação: novo processo, sem média herdada
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 2000, "shell", 0.1)}, start.Add(20*time.Second))
+	if _, ok := sampler.Sustained(old); ok {
+		t.Fatal("exited process still tracked")
+	}
+	if _, ok := sampler.Sustained(reused); ok {
+		t.Fatal("reused PID inherited an average")
+	}
+	if _, ok := sampler.Top(); ok {
+		t.Fatal("Top() reported a process without an average")
+	}
+
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 2000, "", 0.2)}, start.Add(30*time.Second))
+	if average, ok := sampler.Sustained(reused); !ok || !almostEqual(average, 1) {
+		t.Fatalf("reused PID average = %f, %t, want 1", average, ok)
+	}
+	if top, _ := sampler.Top(); top.Name != "shell" {
+		t.Fatalf("reused PID reported as %q", top.Name)
+	}
+}
+
+func TestProcessCPUSamplerResetsBaseline(t *testing.T) {
+	sampler := NewProcessCPUSampler(time.Minute)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	key := ProcessKey{PID: 100, CreateTime: 1000}
+
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "app", 0)}, start)
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 2)}, start.Add(10*time.Second))
+
+	// Contador regrediu e relógio voltou: a média fica como estava
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 1)}, start.Add(20*time.Second))
+	sampler.Observe([]ProcessCPUSample{cpuSample(100, 1000, "", 5)}, start.Add(15*time.Second))
+	if average, _ := sampler.Sustained(key); !almostEqual(average, 20) {
+		t.Fatalf("average after a counter and clock regression = %f, want 20", average)
+	}
+}
+
+func TestProcessCPUSamplerTopOrder(t *testing.T) {
+	sampler := NewProcessCPUSampler(0)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	sampler.Observe([]ProcessCPUSample{
+		cpuSample(30, 1, "c", 0), cpuSample(10, 1, "a", 0), cpuSample(20, 1, "b", 0),
+	}, start)
+	sampler.Observe([]ProcessCPUSample{
+		cpuSample(30, 1, "", 2), cpuSample(10, 1, "", 5), cpuSample(20, 1, "", 5),
+	}, start.Add(10*time.Second))
+
+	sampler.mu.Lock()
+	keys := sampler.topKeysLocked(2)
+	sampler.mu.Unlock()
+	if len(keys) != 2 || keys[0].PID != 10 || keys[1].PID != 20 {
+		t.Fatalf("top keys = %v, want PIDs 10 and 20 (ties by PID)", keys)
+	}
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// darwinDiskCacheTTL é a validade dos atributos de volume e do status do
+// Time Machine; mudam raramente e diskutil/tmutil são lentos
+const darwinDiskCacheTTL = time.Hour
+
+// Chaves de cache dos dados de disco do macOS
+const (
+	cacheKeyDarwinVolumePrefix = "darwin_volume:"
+	CacheKeyTimeMachine        = "time_machine"
+)
+
+// DarwinVolumeInfo são os atributos de um volume do macOS segundo
+// `diskutil info -plist`
+type DarwinVolumeInfo struct {
+	VolumeName     string `json:"volume_name,omitempty"`
+	FilesystemName string `json:"filesystem_name,omitempty"` // ex.: "Case-sensitive APFS"
+	CaseSensitive  bool   `json:"case_sensitive"`
+	Encrypted      bool   `json:"encrypted"`
+	FileVault      bool   `json:"filevault"`
+
+	// Volumes APFS compartilham o espaço livre do container
+	APFSContainer      string   `json:"apfs_container,omitempty"`
+	ContainerTotal     uint64   `json:"container_total_bytes,omitempty"`
+	ContainerFree      uint64   `json:"container_free_bytes,omitempty" diff:"-"`
+	APFSPhysicalStores []string `json:"apfs_physical_stores,omitempty"`
+}
+
+// TimeMachineInfo resume o Time Machine da máquina
+type TimeMachineInfo struct {
+	// Enabled indica que há ao menos um destino configurado
+	Enabled      bool   `json:"enabled"`
+	Destinations int    `json:"destinations"`
+	Running      bool   `json:"running"`
+	Phase        string `json:"phase,omitempty"`
+
+	LastBackup time.Time `json:"last_backup,omitempty"`
+	// LastBackupAgeHours é calculado a cada coleta, mesmo com o resto em cache
+	LastBackupAgeHours *float64 `json:"last_backup_age_hours,omitempty"`
+}
+
+// parseDiskutilInfo interpreta `diskutil info -plist <volume>`
+func parseDiskutilInfo(output []byte) (*DarwinVolumeInfo, error) {
+	root, err := parsePlist(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diskutil output: %w", err)
+	}
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected diskutil output format")
+	}
+
+	info := &DarwinVolumeInfo{
+		VolumeName:     plistString(dict, "VolumeName"),
+		FilesystemName: plistString(dict, "FilesystemName"),
+		Encrypted:      plistBool(dict, "Encryption"),
+		FileVault:      plistBool(dict, "FileVault"),
+		APFSContainer:  plistString(dict, "APFSContainerReference"),
+		ContainerTotal: plistUint(dict, "APFSContainerSize"),
+		ContainerFree:  plistUint(dict, "APFSContainerFree"),
+	}
+
+	// A personalidade traz a sensibilidade a maiúsculas tanto no APFS
+	// ("Case-sensitive APFS") quanto no HFS+ ("Case-sensitive Journaled HFS+")
+	personality := plistString(dict, "FilesystemPersonality")
+	if personality == "" {
+		personality = info.FilesystemName
+	}
+	info.CaseSensitive = strings.Contains(strings.ToLower(personality), "case-sensitive")
+	if personality != "" {
+		info.FilesystemName = personality
+	}
+
+	if stores, ok := dict["APFSPhysicalStores"].([]interface{}); ok {
+		for _, store := range stores {
+			entry, ok := store.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if id := plistString(entry, "APFSPhysicalStore"); id != "" {
+				info.APFSPhysicalStores = append(info.APFSPhysicalStores, id)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// plistBool retorna um valor booleano de um dict do plist
+func plistBool(dict map[string]interface{}, key string) bool {
+	value, _ := dict[key].(bool)
+	return value
+}
+
+// plistUint retorna um inteiro não negativo de um dict do plist
+func plistUint(dict map[string]interface{}, key string) uint64 {
+	if value, ok := dict[key].(int64); ok && value > 0 {
+		return uint64(value)
+	}
+	return 0
+}
+
+// darwinVolume retorna os atributos do volume montado em mountpoint, em
+// cache por uma hora
+func (c *SystemCollector) darwinVolume(ctx context.Context, mountpoint string) (*DarwinVolumeInfo, error) {
+	key := cacheKeyDarwinVolumePrefix + mountpoint
+	if cached := c.getFromCache(key); cached != nil {
+		if info, ok := cached.(*DarwinVolumeInfo); ok {
+			return info, nil
+		}
+	}
+
+	output, err := c.runProbe(ctx, "diskutil", "info", "-plist", mountpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute diskutil: %w", err)
+	}
+	info, err := parseDiskutilInfo(output)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setInCache(key, info, darwinDiskCacheTTL)
+	return info, nil
+}
+
+// isDarwinVolume indica se a partição é um volume de disco (não devfs,
+// autofs ou montagens de rede)
+func isDarwinVolume(device string) bool {
+	return strings.HasPrefix(device, "/dev/disk")
+}
+
+// tmutilStatusLine casa as linhas "Chave = valor;" do `tmutil status`
+var tmutilStatusLine = regexp.MustCompile(`^\s*"?(\w+)"?\s*=\s*"?([^";]*)"?;`)
+
+// parseTmutilStatus interpreta `tmutil status` (formato de plist ASCII)
+func parseTmutilStatus(output []byte, info *TimeMachineInfo) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		match := tmutilStatusLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		switch match[1] {
+		case "Running":
+			info.Running = match[2] == "1"
+		case "BackupPhase":
+			info.Phase = match[2]
+		}
+	}
+}
+
+// parseTmutilDestinations conta os destinos de `tmutil destinationinfo -X`
+func parseTmutilDestinations(output []byte) (int, error) {
+	root, err := parsePlist(output)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse tmutil destinationinfo output: %w", err)
+	}
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("unexpected tmutil destinationinfo output format")
+	}
+	destinations, _ := dict["Destinations"].([]interface{})
+	return len(destinations), nil
+}
+
+// tmutilBackupName casa o nome do backup ("2024-01-15-103000" ou
+// "2024-01-15-103000.backup")
+var tmutilBackupName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}-\d{6})(\.backup|\.inprogress)?$`)
+
+// parseLatestBackup extrai o horário do caminho de `tmutil latestbackup`
+// (hora local da máquina)
+func parseLatestBackup(output []byte) (time.Time, error) {
+	name := path.Base(strings.TrimSpace(string(output)))
+	match := tmutilBackupName.FindStringSubmatch(name)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("unexpected tmutil latestbackup output: %q", name)
+	}
+	return time.ParseInLocation("2006-01-02-150405", match[1], time.Local)
+}
+
+// collectTimeMachine resume o Time Machine. Destinos e último backup ficam em
+// cache por uma hora; o status e a idade do backup são lidos a cada coleta.
+func (c *SystemCollector) collectTimeMachine(ctx context.Context) (*TimeMachineInfo, error) {
+	var info TimeMachineInfo
+	if cached, ok := c.getFromCache(CacheKeyTimeMachine).(*TimeMachineInfo); ok {
+		info = *cached
+	} else {
+		// Sem destino configurado o tmutil sai com erro; isso é "desabilitado"
+		if output, err := c.runProbe(ctx, "tmutil", "destinationinfo", "-X"); err == nil {
+			count, err := parseTmutilDestinations(output)
+			if err != nil {
+				return nil, err
+			}
+			info.Destinations = count
+			info.Enabled = count > 0
+		}
+
+		// latestbackup exige acesso total ao disco; sem ele a data fica ausente
+		if info.Enabled {
+			if output, err := c.runProbe(ctx, "tmutil", "latestbackup"); err == nil {
+				if last, err := parseLatestBackup(output); err == nil {
+					info.LastBackup = last
+				}
+			}
+		}
+
+		cached := info
+		c.setInCache(CacheKeyTimeMachine, &cached, darwinDiskCacheTTL)
+	}
+
+	// O status muda durante o backup e é barato; não entra no cache
+	if info.Enabled {
+		if output, err := c.runProbe(ctx, "tmutil", "status"); err == nil {
+			parseTmutilStatus(output, &info)
+		} else {
+			c.logger.WithField("error", err).Debug("Failed to read Time Machine status")
+		}
+	}
+
+	if !info.LastBackup.IsZero() {
+		age := math.Round(c.clock.Since(info.LastBackup).Hours()*10) / 10
+		info.LastBackupAgeHours = &age
+	}
+	return &info, nil
+}
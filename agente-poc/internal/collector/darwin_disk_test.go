@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+func TestParseDiskutilInfo(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    DarwinVolumeInfo
+	}{
+		{
+			fixture: "diskutil_apfs.plist",
+			want: DarwinVolumeInfo{
+				VolumeName:         "Data",
+				FilesystemName:     "Case-sensitive APFS",
+				CaseSensitive:      true,
+				Encrypted:          true,
+				FileVault:          true,
+				APFSContainer:      "disk3",
+				ContainerTotal:     494384795648,
+				ContainerFree:      245887909888,
+				APFSPhysicalStores: []string{"disk0s2"},
+			},
+		},
+		{
+			// No HFS+ a sensibilidade vem da personalidade, não do nome exibido
+			fixture: "diskutil_hfs.plist",
+			want: DarwinVolumeInfo{
+				VolumeName:     "Backup",
+				FilesystemName: "Case-sensitive Journaled HFS+",
+				CaseSensitive:  true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			info, err := parseDiskutilInfo(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*info, tt.want) {
+				t.Fatalf("parseDiskutilInfo = %+v, want %+v", *info, tt.want)
+			}
+		})
+	}
+
+	insensitive := []byte(`<plist version="1.0"><dict><key>FilesystemName</key><string>APFS</string></dict></plist>`)
+	if info, err := parseDiskutilInfo(insensitive); err != nil || info.CaseSensitive {
+		t.Fatalf("plain APFS: %+v, %v", info, err)
+	}
+	if _, err := parseDiskutilInfo([]byte(`<plist version="1.0"><array/></plist>`)); err == nil {
+		t.Fatal("non-dict plist accepted")
+	}
+}
+
+func TestDarwinVolumeCachedForAnHour(t *testing.T) {
+	c := newTestCollector(t)
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	c.SetClock(fake)
+	runner := newCountingRunner(map[string][]byte{
+		"diskutil info -plist /": readFixture(t, "diskutil_apfs.plist"),
+	})
+	c.SetCommandRunner(runner)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.darwinVolume(context.Background(), "/"); err != nil {
+			t.Fatal(err)
+		}
+		fake.Advance(20 * time.Minute)
+	}
+	if calls := runner.snapshot(); calls["diskutil info -plist /"] != 1 {
+		t.Fatalf("diskutil calls within the hour = %v", calls)
+	}
+
+	fake.Advance(time.Minute)
+	if _, err := c.darwinVolume(context.Background(), "/"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := runner.snapshot(); calls["diskutil info -plist /"] != 1 {
+		t.Fatalf("diskutil calls after the hour = %v", calls)
+	}
+
+	for device, want := range map[string]bool{"/dev/disk3s5": true, "devfs": false, "map auto_home": false, "//user@nas/share": false} {
+		if got := isDarwinVolume(device); got != want {
+			t.Errorf("isDarwinVolume(%q) = %t", device, got)
+		}
+	}
+}
+
+func TestParseTmutil(t *testing.T) {
+	count, err := parseTmutilDestinations(readFixture(t, "tmutil_destinationinfo.plist"))
+	if err != nil || count != 2 {
+		t.Fatalf("destinations = %d, %v", count, err)
+	}
+	if count, err := parseTmutilDestinations([]byte(`<plist version="1.0"><dict/></plist>`)); err != nil || count != 0 {
+		t.Fatalf("no destinations = %d, %v", count, err)
+	}
+
+	var info TimeMachineInfo
+	parseTmutilStatus(readFixture(t, "tmutil_status.txt"), &info)
+	if !info.Running || info.Phase != "Copying" {
+		t.Fatalf("status = %+v", info)
+	}
+	info = TimeMachineInfo{}
+	parseTmutilStatus([]byte("Backup session status:\n{\n    ClientID = \"com.apple.backupd\";\n    Running = 0;\n}\n"), &info)
+	if info.Running || info.Phase != "" {
+		t.Fatalf("idle status = %+v", info)
+	}
+
+	for output, want := range map[string]time.Time{
+		"/Volumes/Backup/Backups.backupdb/Mac/2026-01-05-060000\n":                    time.Date(2026, 1, 5, 6, 0, 0, 0, time.Local),
+		"/Volumes/.timemachine/ABC/2026-01-04-231500.backup/2026-01-04-231500.backup": time.Date(2026, 1, 4, 23, 15, 0, 0, time.Local),
+	} {
+		got, err := parseLatestBackup([]byte(output))
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseLatestBackup(%q) = %s, %v", output, got, err)
+		}
+	}
+	if _, err := parseLatestBackup([]byte("Failed to mount backup destination")); err == nil {
+		t.Fatal("error output parsed as a backup")
+	}
+}
+
+func TestCollectTimeMachine(t *testing.T) {
+	c := newTestCollector(t)
+	last := time.Date(2026, 1, 5, 6, 0, 0, 0, time.Local)
+	fake := clock.NewFake(last.Add(3 * time.Hour))
+	c.SetClock(fake)
+	runner := newCountingRunner(map[string][]byte{
+		"tmutil destinationinfo -X": readFixture(t, "tmutil_destinationinfo.plist"),
+		"tmutil latestbackup":       []byte("/Volumes/Backup/Backups.backupdb/Mac/2026-01-05-060000\n"),
+		"tmutil status":             readFixture(t, "tmutil_status.txt"),
+	})
+	c.SetCommandRunner(runner)
+
+	info, err := c.collectTimeMachine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Enabled || info.Destinations != 2 || !info.Running || !info.LastBackup.Equal(last) {
+		t.Fatalf("time machine = %+v", info)
+	}
+	if info.LastBackupAgeHours == nil || *info.LastBackupAgeHours != 3 {
+		t.Fatalf("backup age = %v", info.LastBackupAgeHours)
+	}
+
+	// Destinos e último backup vêm do cache; status e idade são recalculados
+	runner.outputs["tmutil status"] = []byte("Backup session status:\n{\n    Running = 0;\n}\n")
+	fake.Advance(30 * time.Minute)
+	runner.snapshot()
+	info, err = c.collectTimeMachine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Running || info.Phase != "" || *info.LastBackupAgeHours != 3.5 {
+		t.Fatalf("second collection = %+v, age %v", info, *info.LastBackupAgeHours)
+	}
+	if calls := runner.snapshot(); !reflect.DeepEqual(calls, map[string]int{"tmutil status": 1}) {
+		t.Fatalf("second collection ran %v, want only tmutil status", calls)
+	}
+
+	// Passada a hora, destinos e último backup são lidos de novo
+	fake.Advance(31 * time.Minute)
+	if _, err := c.collectTimeMachine(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls := runner.snapshot(); calls["tmutil destinationinfo -X"] != 1 || calls["tmutil latestbackup"] != 1 {
+		t.Fatalf("collection after the hour ran %v", calls)
+	}
+}
+
+func TestCollectTimeMachineDisabled(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(nil)
+	c.SetCommandRunner(runner)
+
+	// Sem destino o tmutil falha: desabilitado, sem status nem último backup
+	info, err := c.collectTimeMachine(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Enabled || info.Destinations != 0 || info.LastBackupAgeHours != nil {
+		t.Fatalf("time machine without destinations = %+v", info)
+	}
+	if calls := runner.snapshot(); !reflect.DeepEqual(calls, map[string]int{"tmutil destinationinfo -X": 1}) {
+		t.Fatalf("commands run = %v", calls)
+	}
+}
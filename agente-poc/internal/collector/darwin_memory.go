@@ -0,0 +1,208 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Níveis de pressão de memória do macOS (kern.memorystatus_vm_pressure_level),
+// os mesmos do gráfico "Pressão de memória" do Monitor de Atividade
+const (
+	MemoryPressureNormal   = "normal"
+	MemoryPressureWarning  = "warning"
+	MemoryPressureCritical = "critical"
+)
+
+// MemoryPressureRank ordena os níveis (0 normal, 1 warning, 2 critical);
+// -1 para nível desconhecido
+func MemoryPressureRank(level string) int {
+	switch level {
+	case MemoryPressureNormal:
+		return 0
+	case MemoryPressureWarning:
+		return 1
+	case MemoryPressureCritical:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// DarwinMemoryInfo são as métricas de memória do macOS como o Monitor de
+// Atividade as apresenta. No macOS, MemoryInfo.Used inclui cache de arquivos
+// e páginas comprimidas; a pressão é o indicador de falta de memória.
+type DarwinMemoryInfo struct {
+	PressureLevel string `json:"pressure_level,omitempty"`
+	// FreePercent é o "System-wide memory free percentage" do memory_pressure
+	FreePercent *float64 `json:"free_percent,omitempty"`
+
+	AppBytes         uint64 `json:"app_bytes"`
+	WiredBytes       uint64 `json:"wired_bytes"`
+	CompressedBytes  uint64 `json:"compressed_bytes"`
+	CachedFilesBytes uint64 `json:"cached_files_bytes"`
+	// UsedBytes é app + wired + comprimida ("Memória usada" do Monitor de Atividade)
+	UsedBytes uint64 `json:"used_bytes"`
+
+	// Contadores acumulados desde o boot e taxas desde a amostra anterior
+	// (ausentes na primeira)
+	SwapIns           uint64   `json:"swap_ins"`
+	SwapOuts          uint64   `json:"swap_outs"`
+	SwapInsPerSecond  *float64 `json:"swap_ins_per_second,omitempty"`
+	SwapOutsPerSecond *float64 `json:"swap_outs_per_second,omitempty"`
+}
+
+// vmStat são os contadores de páginas do vm_stat
+type vmStat struct {
+	pageSize uint64
+	pages    map[string]uint64
+}
+
+var vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// parseVMStat interpreta a saída do vm_stat ("Pages free:   1234.")
+func parseVMStat(output []byte) (*vmStat, error) {
+	stat := &vmStat{pages: make(map[string]uint64)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := vmStatPageSize.FindStringSubmatch(line); match != nil {
+			stat.pageSize, _ = strconv.ParseUint(match[1], 10, 64)
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.TrimSuffix(strings.TrimSpace(value), ".")
+		count, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		stat.pages[strings.Trim(strings.TrimSpace(key), `"`)] = count
+	}
+
+	if stat.pageSize == 0 {
+		return nil, fmt.Errorf("vm_stat: page size not found")
+	}
+	if len(stat.pages) == 0 {
+		return nil, fmt.Errorf("vm_stat: no counters found")
+	}
+	return stat, nil
+}
+
+// bytes retorna o contador de páginas em bytes
+func (s *vmStat) bytes(key string) uint64 {
+	return s.pages[key] * s.pageSize
+}
+
+// breakdown calcula a divisão do Monitor de Atividade: app = anônimas menos
+// purgeable; cache de arquivos = páginas de arquivo mais purgeable
+func (s *vmStat) breakdown(info *DarwinMemoryInfo) {
+	anonymous := s.bytes("Anonymous pages")
+	purgeable := s.bytes("Pages purgeable")
+	if anonymous > purgeable {
+		info.AppBytes = anonymous - purgeable
+	}
+	info.WiredBytes = s.bytes("Pages wired down")
+	info.CompressedBytes = s.bytes("Pages occupied by compressor")
+	info.CachedFilesBytes = s.bytes("File-backed pages") + purgeable
+	info.UsedBytes = info.AppBytes + info.WiredBytes + info.CompressedBytes
+	info.SwapIns = s.pages["Swapins"]
+	info.SwapOuts = s.pages["Swapouts"]
+}
+
+// parsePressureLevel interpreta `sysctl -n kern.memorystatus_vm_pressure_level`
+// (1 normal, 2 warning, 4 critical)
+func parsePressureLevel(output []byte) (string, error) {
+	switch value := strings.TrimSpace(string(output)); value {
+	case "1":
+		return MemoryPressureNormal, nil
+	case "2":
+		return MemoryPressureWarning, nil
+	case "4":
+		return MemoryPressureCritical, nil
+	default:
+		return "", fmt.Errorf("unknown memory pressure level: %q", value)
+	}
+}
+
+var memoryFreePercent = regexp.MustCompile(`System-wide memory free percentage:\s*(\d+(?:\.\d+)?)%`)
+
+// parseMemoryPressure extrai o percentual livre da saída do memory_pressure
+func parseMemoryPressure(output []byte) (float64, error) {
+	match := memoryFreePercent.FindSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("memory_pressure: free percentage not found")
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// swapSample é a amostra anterior dos contadores de swap, para as taxas
+type swapSample struct {
+	mu       sync.Mutex
+	at       time.Time
+	swapIns  uint64
+	swapOuts uint64
+}
+
+// rates atualiza a amostra e calcula as taxas por segundo desde a anterior;
+// nil na primeira amostra ou se os contadores voltaram (reboot)
+func (s *swapSample) rates(now time.Time, swapIns, swapOuts uint64) (*float64, *float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prevAt, prevIns, prevOuts := s.at, s.swapIns, s.swapOuts
+	s.at, s.swapIns, s.swapOuts = now, swapIns, swapOuts
+
+	elapsed := now.Sub(prevAt).Seconds()
+	if prevAt.IsZero() || elapsed <= 0 || swapIns < prevIns || swapOuts < prevOuts {
+		return nil, nil
+	}
+	ins := float64(swapIns-prevIns) / elapsed
+	outs := float64(swapOuts-prevOuts) / elapsed
+	return &ins, &outs
+}
+
+// collectDarwinMemory coleta as métricas de memória do macOS. Só o vm_stat é
+// obrigatório; nível de pressão e percentual livre são omitidos se falharem.
+func (c *SystemCollector) collectDarwinMemory(ctx context.Context) (*DarwinMemoryInfo, error) {
+	output, err := c.runProbe(ctx, "vm_stat")
+	if err != nil {
+		return nil, fmt.Errorf("vm_stat: %w", err)
+	}
+	stat, err := parseVMStat(output)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DarwinMemoryInfo{}
+	stat.breakdown(info)
+	info.SwapInsPerSecond, info.SwapOutsPerSecond = c.swapSample.rates(c.clock.Now(), info.SwapIns, info.SwapOuts)
+
+	if output, err := c.runProbe(ctx, "sysctl", "-n", "kern.memorystatus_vm_pressure_level"); err == nil {
+		if level, err := parsePressureLevel(output); err == nil {
+			info.PressureLevel = level
+		} else {
+			c.logger.WithField("error", err).Debug("Failed to parse memory pressure level")
+		}
+	} else {
+		c.logger.WithField("error", err).Debug("Failed to read memory pressure level")
+	}
+
+	if output, err := c.runProbe(ctx, "memory_pressure"); err == nil {
+		if free, err := parseMemoryPressure(output); err == nil {
+			info.FreePercent = &free
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+func TestParseVMStat(t *testing.T) {
+	stat, err := parseVMStat(readFixture(t, "vm_stat.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.pageSize != 16384 {
+		t.Fatalf("page size = %d", stat.pageSize)
+	}
+	// A chave entre aspas perde as aspas
+	if stat.pages["Translation faults"] != 842357402 || stat.pages["Pages free"] != 3863 {
+		t.Fatalf("counters = %v", stat.pages)
+	}
+
+	var info DarwinMemoryInfo
+	stat.breakdown(&info)
+	const page = 16384
+	want := DarwinMemoryInfo{
+		AppBytes:         (316526 - 3306) * page,
+		WiredBytes:       126553 * page,
+		CompressedBytes:  366306 * page,
+		CachedFilesBytes: (189870 + 3306) * page,
+		UsedBytes:        (316526 - 3306 + 126553 + 366306) * page,
+		SwapIns:          408312,
+		SwapOuts:         558947,
+	}
+	if info != want {
+		t.Fatalf("breakdown = %+v, want %+v", info, want)
+	}
+
+	for name, output := range map[string]string{
+		"no page size": "Pages free: 10.\n",
+		"no counters":  "Mach Virtual Memory Statistics: (page size of 4096 bytes)\n",
+	} {
+		if _, err := parseVMStat([]byte(output)); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func TestParseVMStatPurgeableAboveAnonymous(t *testing.T) {
+	stat, err := parseVMStat([]byte("Mach Virtual Memory Statistics: (page size of 4096 bytes)\nAnonymous pages: 10.\nPages purgeable: 20.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info DarwinMemoryInfo
+	stat.breakdown(&info)
+	if info.AppBytes != 0 || info.CachedFilesBytes != 20*4096 {
+		t.Fatalf("breakdown = %+v", info)
+	}
+}
+
+func TestParsePressureLevel(t *testing.T) {
+	for output, want := range map[string]string{
+		"1\n": MemoryPressureNormal,
+		"2\n": MemoryPressureWarning,
+		"4\n": MemoryPressureCritical,
+	} {
+		level, err := parsePressureLevel([]byte(output))
+		if err != nil || level != want {
+			t.Errorf("parsePressureLevel(%q) = %q, %v; want %q", output, level, err, want)
+		}
+	}
+	if _, err := parsePressureLevel([]byte("3\n")); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+
+	for level, rank := range map[string]int{
+		MemoryPressureNormal: 0, MemoryPressureWarning: 1, MemoryPressureCritical: 2, "": -1, "high": -1,
+	} {
+		if got := MemoryPressureRank(level); got != rank {
+			t.Errorf("MemoryPressureRank(%q) = %d, want %d", level, got, rank)
+		}
+	}
+}
+
+func TestParseMemoryPressure(t *testing.T) {
+	free, err := parseMemoryPressure(readFixture(t, "memory_pressure.txt"))
+	if err != nil || free != 42 {
+		t.Fatalf("free percentage = %f, %v", free, err)
+	}
+	if free, err := parseMemoryPressure([]byte("System-wide memory free percentage: 7.5%")); err != nil || free != 7.5 {
+		t.Fatalf("fractional free percentage = %f, %v", free, err)
+	}
+	if _, err := parseMemoryPressure([]byte("The system has 17179869184 bytes")); err == nil {
+		t.Fatal("output without the percentage accepted")
+	}
+}
+
+func TestSwapRates(t *testing.T) {
+	var sample swapSample
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	if ins, outs := sample.rates(start, 100, 200); ins != nil || outs != nil {
+		t.Fatal("rates reported on the first sample")
+	}
+	ins, outs := sample.rates(start.Add(10*time.Second), 150, 400)
+	if ins == nil || outs == nil || *ins != 5 || *outs != 20 {
+		t.Fatalf("rates = %v, %v; want 5 and 20 per second", ins, outs)
+	}
+	// Contadores voltaram (reboot): sem taxa, a amostra vira a nova base
+	if ins, _ := sample.rates(start.Add(20*time.Second), 10, 10); ins != nil {
+		t.Fatal("rate reported after the counters went back")
+	}
+	if ins, _ := sample.rates(start.Add(20*time.Second), 20, 20); ins != nil {
+		t.Fatal("rate reported without elapsed time")
+	}
+	if ins, _ := sample.rates(start.Add(30*time.Second), 30, 20); ins == nil || *ins != 1 {
+		t.Fatalf("rate after the new baseline = %v", ins)
+	}
+}
+
+func TestCollectDarwinMemory(t *testing.T) {
+	c := newTestCollector(t)
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	c.SetClock(fake)
+
+	vmStat := readFixture(t, "vm_stat.txt")
+	runner := newCountingRunner(map[string][]byte{
+		"vm_stat": vmStat,
+		"sysctl -n kern.memorystatus_vm_pressure_level": []byte("2\n"),
+		"memory_pressure": readFixture(t, "memory_pressure.txt"),
+	})
+	c.SetCommandRunner(runner)
+
+	info, err := c.collectDarwinMemory(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PressureLevel != MemoryPressureWarning || info.FreePercent == nil || *info.FreePercent != 42 {
+		t.Fatalf("pressure = %q, free = %v", info.PressureLevel, info.FreePercent)
+	}
+	if info.SwapIns != 408312 || info.SwapInsPerSecond != nil {
+		t.Fatalf("first sample swap: %d, rate %v", info.SwapIns, info.SwapInsPerSecond)
+	}
+
+	// Segunda amostra 30s depois: 300 swap-ins e 60 swap-outs a mais
+	runner.outputs["vm_stat"] = bytes.Replace(bytes.Replace(vmStat,
+		[]byte("408312."), []byte("408612."), 1),
+		[]byte("558947."), []byte("559007."), 1)
+	fake.Advance(30 * time.Second)
+	info, err = c.collectDarwinMemory(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SwapInsPerSecond == nil || *info.SwapInsPerSecond != 10 || *info.SwapOutsPerSecond != 2 {
+		t.Fatalf("swap rates = %v, %v", info.SwapInsPerSecond, info.SwapOutsPerSecond)
+	}
+}
+
+func TestCollectDarwinMemoryOptionalProbes(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{"vm_stat": readFixture(t, "vm_stat.txt")}))
+
+	// Sem sysctl e memory_pressure, os campos ficam ausentes
+	info, err := c.collectDarwinMemory(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PressureLevel != "" || info.FreePercent != nil || info.UsedBytes == 0 {
+		t.Fatalf("info without the optional probes = %+v", info)
+	}
+
+	// Sem vm_stat não há métricas
+	c.SetCommandRunner(newCountingRunner(nil))
+	if _, err := c.collectDarwinMemory(context.Background()); err == nil {
+		t.Fatal("collected without vm_stat")
+	}
+}
@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InventoryDiff são as mudanças entre dois inventários consecutivos da mesma
+// máquina. Campos marcados com a tag `diff:"-"` mudam a cada coleta (uso de
+// disco, contadores de rede, PIDs) e não contam como mudança. Coleções que
+// não foram coletadas em um dos dois (seção desligada, descartada pelo
+// planner ou vazia) não entram, para uma seção ausente não aparecer como
+// tudo removido.
+type InventoryDiff struct {
+	From              time.Time    `json:"from"`
+	To                time.Time    `json:"to"`
+	OSVersion         *ValueChange `json:"os_version,omitempty"`
+	Applications      *ItemChanges `json:"applications,omitempty"`
+	Services          *ItemChanges `json:"services,omitempty"`
+	NetworkInterfaces *ItemChanges `json:"network_interfaces,omitempty"`
+	Disks             *ItemChanges `json:"disks,omitempty"`
+}
+
+// ValueChange é um valor que mudou entre os inventários
+type ValueChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ItemChanges são os itens de uma coleção adicionados, removidos e alterados,
+// identificados pela chave do item (nome do aplicativo, do serviço ou da
+// interface; ponto de montagem do disco)
+type ItemChanges struct {
+	Added   []string     `json:"added,omitempty"`
+	Removed []string     `json:"removed,omitempty"`
+	Changed []ItemChange `json:"changed,omitempty"`
+}
+
+// ItemChange são os campos alterados de um item, pelo caminho no JSON
+type ItemChange struct {
+	Key    string                 `json:"key"`
+	Fields map[string]ValueChange `json:"fields"`
+}
+
+// Empty indica que nada mudou
+func (d *InventoryDiff) Empty() bool {
+	return d.OSVersion == nil && d.Applications == nil && d.Services == nil &&
+		d.NetworkInterfaces == nil && d.Disks == nil
+}
+
+// Count retorna o total de itens adicionados, removidos e alterados
+func (d *InventoryDiff) Count() int {
+	count := 0
+	if d.OSVersion != nil {
+		count++
+	}
+	for _, changes := range []*ItemChanges{d.Applications, d.Services, d.NetworkInterfaces, d.Disks} {
+		if changes != nil {
+			count += len(changes.Added) + len(changes.Removed) + len(changes.Changed)
+		}
+	}
+	return count
+}
+
+// DiffInventories compara o inventário anterior com o atual
+func DiffInventories(previous, current *InventoryData) *InventoryDiff {
+	diff := &InventoryDiff{From: previous.Timestamp, To: current.Timestamp}
+
+	if previous.System.OSVersion != current.System.OSVersion {
+		diff.OSVersion = &ValueChange{Old: previous.System.OSVersion, New: current.System.OSVersion}
+	}
+
+	if collectedBoth(previous, current, PlanSectionApplications, func(d *InventoryData) bool {
+		return !d.Software.Skipped && len(d.Software.InstalledApplications) > 0
+	}) {
+		diff.Applications = diffItems(previous.Software.InstalledApplications, current.Software.InstalledApplications, applicationKey)
+	}
+	if collectedBoth(previous, current, PlanSectionServices, func(d *InventoryData) bool {
+		return !d.Software.Skipped && len(d.Software.RunningServices) > 0
+	}) {
+		diff.Services = diffItems(previous.Software.RunningServices, current.Software.RunningServices, func(s Service) string { return s.Name })
+	}
+	if collectedBoth(previous, current, PlanSectionNetwork, func(d *InventoryData) bool {
+		return !d.Network.Skipped && len(d.Network.Interfaces) > 0
+	}) {
+		diff.NetworkInterfaces = diffItems(previous.Network.Interfaces, current.Network.Interfaces, func(i NetworkInterface) string { return i.Name })
+	}
+	if len(previous.Hardware.Disk) > 0 && len(current.Hardware.Disk) > 0 {
+		diff.Disks = diffItems(previous.Hardware.Disk, current.Hardware.Disk, diskKey)
+	}
+
+	return diff
+}
+
+// collectedBoth indica se a coleção foi coletada e enviada nos dois
+// inventários
+func collectedBoth(previous, current *InventoryData, section string, present func(*InventoryData) bool) bool {
+	for _, data := range []*InventoryData{previous, current} {
+		if !present(data) || data.Plan.droppedSection(section) {
+			return false
+		}
+	}
+	return true
+}
+
+// droppedSection indica se o planner descartou a seção
+func (p *InventoryPlan) droppedSection(section string) bool {
+	if p == nil {
+		return false
+	}
+	for _, drop := range p.Dropped {
+		if drop.Section == section {
+			return true
+		}
+	}
+	return false
+}
+
+// applicationKey identifica o aplicativo pelo nome e, quando houver, pelo
+// caminho, já que a mesma aplicação pode estar instalada em dois lugares
+func applicationKey(app Application) string {
+	if app.Path == "" {
+		return app.Name
+	}
+	return app.Name + " (" + app.Path + ")"
+}
+
+// diskKey identifica a partição pelo ponto de montagem, que sobrevive à
+// renumeração dos dispositivos
+func diskKey(disk DiskInfo) string {
+	if disk.Mountpoint == "" {
+		return disk.Device
+	}
+	return disk.Mountpoint
+}
+
+// diffItems compara duas coleções pelos itens com a mesma chave; retorna nil
+// se nada mudou. Em chaves repetidas vale o primeiro item; itens sem chave
+// ficam de fora.
+func diffItems[T any](previous, current []T, key func(T) string) *ItemChanges {
+	before := indexItems(previous, key)
+	after := indexItems(current, key)
+
+	changes := &ItemChanges{}
+	for k, item := range after {
+		old, ok := before[k]
+		if !ok {
+			changes.Added = append(changes.Added, k)
+			continue
+		}
+		fields := make(map[string]ValueChange)
+		diffFields(reflect.ValueOf(old), reflect.ValueOf(item), "", fields)
+		if len(fields) > 0 {
+			changes.Changed = append(changes.Changed, ItemChange{Key: k, Fields: fields})
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changes.Removed = append(changes.Removed, k)
+		}
+	}
+
+	if len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Changed) == 0 {
+		return nil
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Slice(changes.Changed, func(i, j int) bool { return changes.Changed[i].Key < changes.Changed[j].Key })
+	return changes
+}
+
+// indexItems mapeia os itens pela chave, mantendo o primeiro de cada chave
+func indexItems[T any](items []T, key func(T) string) map[string]T {
+	index := make(map[string]T, len(items))
+	for _, item := range items {
+		if k := key(item); k != "" {
+			if _, ok := index[k]; !ok {
+				index[k] = item
+			}
+		}
+	}
+	return index
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// diffFields compara os campos de dois structs, descendo em structs e
+// ponteiros para struct, e guarda os diferentes em fields pelo caminho no
+// JSON. Campos com `diff:"-"` e sem nome no JSON são ignorados.
+func diffFields(old, cur reflect.Value, path string, fields map[string]ValueChange) {
+	if old.Kind() == reflect.Pointer {
+		if old.IsNil() || cur.IsNil() {
+			if old.IsNil() != cur.IsNil() {
+				fields[path] = ValueChange{Old: old.Interface(), New: cur.Interface()}
+			}
+			return
+		}
+		old, cur = old.Elem(), cur.Elem()
+	}
+
+	if old.Kind() != reflect.Struct || old.Type() == timeType {
+		if !reflect.DeepEqual(old.Interface(), cur.Interface()) {
+			fields[path] = ValueChange{Old: old.Interface(), New: cur.Interface()}
+		}
+		return
+	}
+
+	t := old.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("diff") == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+		diffFields(old.Field(i), cur.Field(i), name, fields)
+	}
+}
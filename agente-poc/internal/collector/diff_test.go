@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// readInventoryFixture lê um inventário salvo em testdata
+func readInventoryFixture(t *testing.T, name string) *InventoryData {
+	t.Helper()
+	var data InventoryData
+	if err := json.Unmarshal(readFixture(t, name), &data); err != nil {
+		t.Fatal(err)
+	}
+	return &data
+}
+
+func TestDiffInventoriesGolden(t *testing.T) {
+	previous := readInventoryFixture(t, "inventory_diff_before.json")
+	current := readInventoryFixture(t, "inventory_diff_after.json")
+
+	diff := DiffInventories(previous, current)
+	got, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compara o JSON decodificado, sem depender da formatação do arquivo
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(readFixture(t, "inventory_diff_expected.json"), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Fatalf("diff does not match inventory_diff_expected.json:\n%s", got)
+	}
+	if diff.Empty() || diff.Count() != 10 {
+		t.Fatalf("Count() = %d", diff.Count())
+	}
+}
+
+func TestDiffInventoriesIgnoresVolatileFields(t *testing.T) {
+	previous := readInventoryFixture(t, "inventory_diff_before.json")
+	current := readInventoryFixture(t, "inventory_diff_before.json")
+
+	// Uso de CPU, memória e disco, contadores de rede, PIDs, tamanhos e a
+	// lista de processos mudam a cada coleta
+	current.Hardware.CPU.UsageTotal = 99
+	current.Hardware.Memory.Used++
+	current.Hardware.Disk[0].Free -= 1 << 30
+	current.Hardware.Disk[0].UsedPercent = 80
+	current.Software.InstalledApplications[0].Size++
+	current.Software.RunningServices[0].PID = 4242
+	current.Software.RunningProcesses = nil
+	rate := 10.5
+	current.Network.Interfaces[0].BytesSent += 1 << 20
+	current.Network.Interfaces[0].SendRate = &rate
+	current.Network.Interfaces[0].CountersReset = true
+	current.Network.Statistics.TotalBytesSent = 0
+	current.System.Uptime += 3600
+
+	if diff := DiffInventories(previous, current); !diff.Empty() || diff.Count() != 0 {
+		t.Fatalf("volatile changes produced a diff: %+v", diff)
+	}
+}
+
+func TestDiffInventoriesSkipsMissingSections(t *testing.T) {
+	previous := readInventoryFixture(t, "inventory_diff_before.json")
+
+	tests := []struct {
+		name  string
+		strip func(*InventoryData)
+		check func(*InventoryDiff) bool
+	}{
+		{
+			name:  "software section off",
+			strip: func(d *InventoryData) { d.Software = SoftwareInfo{Skipped: true} },
+			check: func(d *InventoryDiff) bool { return d.Applications == nil && d.Services == nil },
+		},
+		{
+			name: "applications dropped by the planner",
+			strip: func(d *InventoryData) {
+				d.Software.InstalledApplications = nil
+				d.Plan = &InventoryPlan{Dropped: []PlanDrop{{Section: PlanSectionApplications}}}
+			},
+			check: func(d *InventoryDiff) bool { return d.Applications == nil },
+		},
+		{
+			name:  "network section off",
+			strip: func(d *InventoryData) { d.Network = NetworkInfo{Skipped: true} },
+			check: func(d *InventoryDiff) bool { return d.NetworkInterfaces == nil },
+		},
+		{
+			name:  "disks not collected",
+			strip: func(d *InventoryData) { d.Hardware.Disk = nil },
+			check: func(d *InventoryDiff) bool { return d.Disks == nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := readInventoryFixture(t, "inventory_diff_before.json")
+			tt.strip(current)
+			diff := DiffInventories(previous, current)
+			if !tt.check(diff) || !diff.Empty() {
+				t.Fatalf("missing section reported as removed: %+v", diff)
+			}
+			// Também na direção contrária: a seção que volta não vira "tudo adicionado"
+			if diff := DiffInventories(current, previous); !diff.Empty() {
+				t.Fatalf("returning section reported as added: %+v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Estados de saúde de disco (DiskHealth.Status)
+const (
+	DiskHealthPassed  = "passed"
+	DiskHealthFailed  = "failed"
+	DiskHealthUnknown = "unknown"
+)
+
+// cacheKeyDiskHealthPrefix prefixa a chave de cache por disco físico
+const cacheKeyDiskHealthPrefix = "disk_health:"
+
+// smartAttributeReallocated é o atributo ATA Reallocated_Sector_Ct
+const smartAttributeReallocated = 5
+
+// DiskHealth é o estado SMART do disco físico de uma partição. Campos que o
+// disco não informa ficam ausentes.
+type DiskHealth struct {
+	Device             string  `json:"device"` // disco físico consultado
+	Status             string  `json:"status"`
+	Source             string  `json:"source,omitempty"` // smartctl ou diskutil
+	Model              string  `json:"model,omitempty"`
+	Temperature        *int64  `json:"temperature_celsius,omitempty" diff:"-"`
+	PowerOnHours       *uint64 `json:"power_on_hours,omitempty" diff:"-"`
+	ReallocatedSectors *uint64 `json:"reallocated_sectors,omitempty"`
+	// Error explica o status unknown (smartctl ausente, permissão negada...)
+	Error string `json:"error,omitempty" diff:"-"`
+}
+
+// smartctlOutput são os campos usados do `smartctl -H -A -j` (smartctl 7.x)
+type smartctlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	ModelName   string `json:"model_name"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current int64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours uint64 `json:"hours"`
+	} `json:"power_on_time"`
+	ATASmartAttributes *struct {
+		Table []struct {
+			ID  int `json:"id"`
+			Raw struct {
+				Value uint64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// parseSmartctl interpreta a saída JSON do smartctl. Sem smart_status (ex.:
+// permissão negada) o status é unknown e a mensagem de erro vai em Error.
+func parseSmartctl(device string, output []byte) (*DiskHealth, error) {
+	var result smartctlOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse smartctl output: %w", err)
+	}
+
+	health := &DiskHealth{
+		Device: device,
+		Status: DiskHealthUnknown,
+		Source: "smartctl",
+		Model:  result.ModelName,
+	}
+	if result.SmartStatus != nil {
+		health.Status = DiskHealthFailed
+		if result.SmartStatus.Passed {
+			health.Status = DiskHealthPassed
+		}
+	} else {
+		for _, message := range result.Smartctl.Messages {
+			if message.Severity == "error" {
+				health.Error = message.String
+				break
+			}
+		}
+		if health.Error == "" {
+			health.Error = fmt.Sprintf("smartctl exit status %d", result.Smartctl.ExitStatus)
+		}
+	}
+
+	if result.Temperature != nil {
+		temperature := result.Temperature.Current
+		health.Temperature = &temperature
+	}
+	if result.PowerOnTime != nil {
+		hours := result.PowerOnTime.Hours
+		health.PowerOnHours = &hours
+	}
+	if result.ATASmartAttributes != nil {
+		for _, attribute := range result.ATASmartAttributes.Table {
+			if attribute.ID == smartAttributeReallocated {
+				reallocated := attribute.Raw.Value
+				health.ReallocatedSectors = &reallocated
+				break
+			}
+		}
+	}
+	return health, nil
+}
+
+// parseDiskutilSMART interpreta o SMARTStatus de `diskutil info -plist`
+// ("Verified", "Failing", "Not Supported")
+func parseDiskutilSMART(device string, output []byte) (*DiskHealth, error) {
+	root, err := parsePlist(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diskutil output: %w", err)
+	}
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected diskutil output format")
+	}
+
+	health := &DiskHealth{
+		Device: device,
+		Status: DiskHealthUnknown,
+		Source: "diskutil",
+		Model:  plistString(dict, "MediaName"),
+	}
+	switch status := plistString(dict, "SMARTStatus"); status {
+	case "Verified":
+		health.Status = DiskHealthPassed
+	case "Failing":
+		health.Status = DiskHealthFailed
+	case "":
+		health.Error = "SMART status not reported"
+	default:
+		health.Error = "SMART status: " + status
+	}
+	return health, nil
+}
+
+// Partições no Linux ("sda1", "nvme0n1p2", "mmcblk0p1") e no macOS
+// ("disk3s1s1"); o disco físico é o prefixo
+var (
+	linuxPartitionSuffix  = regexp.MustCompile(`^((?:nvme\d+n\d+|mmcblk\d+))p\d+$|^([a-z]+)\d+$`)
+	darwinPartitionSuffix = regexp.MustCompile(`^(disk\d+)(?:s\d+)*$`)
+)
+
+// physicalDisk retorna o disco físico de uma partição; vazio para o que não
+// é disco (tmpfs, overlay, montagens de rede)
+func physicalDisk(info *DiskInfo) string {
+	if !strings.HasPrefix(info.Device, "/dev/") {
+		return ""
+	}
+	name := path.Base(info.Device)
+
+	switch runtime.GOOS {
+	case "linux":
+		if match := linuxPartitionSuffix.FindStringSubmatch(name); match != nil {
+			if match[1] != "" {
+				return "/dev/" + match[1]
+			}
+			return "/dev/" + match[2]
+		}
+		return info.Device
+	case "darwin":
+		// Volumes APFS ficam num disco sintetizado; o físico é o physical store
+		if info.Darwin != nil && len(info.Darwin.APFSPhysicalStores) > 0 {
+			name = info.Darwin.APFSPhysicalStores[0]
+		}
+		if match := darwinPartitionSuffix.FindStringSubmatch(name); match != nil {
+			return "/dev/" + match[1]
+		}
+	}
+	return ""
+}
+
+// collectDiskHealth consulta a saúde SMART de device, em cache pelo
+// cache_expiration. Usa o smartctl e, no macOS sem smartctl, o diskutil.
+// Nunca falha: sem ferramenta ou sem permissão o status é unknown.
+func (c *SystemCollector) collectDiskHealth(ctx context.Context, device string) *DiskHealth {
+	key := cacheKeyDiskHealthPrefix + device
+	if cached, ok := c.getFromCache(key).(*DiskHealth); ok {
+		return cached
+	}
+
+	health := c.smartctlHealth(ctx, device)
+	if health.Status == DiskHealthUnknown && runtime.GOOS == "darwin" {
+		if output, err := c.runProbe(ctx, "diskutil", "info", "-plist", device); err == nil {
+			if parsed, err := parseDiskutilSMART(device, output); err == nil && parsed.Status != DiskHealthUnknown {
+				health = parsed
+			}
+		}
+	}
+
+	c.setInCache(key, health, c.configFor(ctx).CacheExpiration)
+	return health
+}
+
+// smartctlHealth executa o smartctl. O código de saída é uma máscara de bits
+// (ex.: disco falhando) e vem diferente de zero mesmo com o JSON completo,
+// por isso o runner é chamado direto, sem descartar a saída.
+func (c *SystemCollector) smartctlHealth(ctx context.Context, device string) *DiskHealth {
+	output, err := c.runner.Output(ctx, "smartctl", "-H", "-A", "-j", device)
+	if err != nil && len(output) == 0 {
+		message := err.Error()
+		if errors.Is(err, exec.ErrNotFound) {
+			message = "smartctl not installed"
+		}
+		return &DiskHealth{Device: device, Status: DiskHealthUnknown, Error: message}
+	}
+
+	health, parseErr := parseSmartctl(device, output)
+	if parseErr != nil {
+		return &DiskHealth{Device: device, Status: DiskHealthUnknown, Source: "smartctl", Error: parseErr.Error()}
+	}
+	return health
+}
+
+// attachDiskHealth liga a saúde SMART às partições pelo disco físico; cada
+// disco é consultado uma vez por coleta
+func (c *SystemCollector) attachDiskHealth(ctx context.Context, disks []DiskInfo) {
+	byDevice := make(map[string]*DiskHealth)
+	for i := range disks {
+		device := physicalDisk(&disks[i])
+		if device == "" {
+			continue
+		}
+		health, ok := byDevice[device]
+		if !ok {
+			health = c.collectDiskHealth(ctx, device)
+			byDevice[device] = health
+		}
+		disks[i].Health = health
+	}
+}
@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// exitRunner simula o smartctl: devolve a saída fixa junto com o erro do
+// código de saída diferente de zero, como o exec faz
+type exitRunner struct {
+	output []byte
+	err    error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *exitRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, args[len(args)-1])
+	r.mu.Unlock()
+	return r.output, r.err
+}
+
+func uint64Ptr(v uint64) *uint64 { return &v }
+func int64Ptr(v int64) *int64    { return &v }
+
+func TestParseSmartctl(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    DiskHealth
+	}{
+		{
+			fixture: "smartctl_ata_passed.json",
+			want: DiskHealth{
+				Device: "/dev/sda", Status: DiskHealthPassed, Source: "smartctl", Model: "Samsung SSD 870 EVO 1TB",
+				Temperature: int64Ptr(34), PowerOnHours: uint64Ptr(12873), ReallocatedSectors: uint64Ptr(0),
+			},
+		},
+		{
+			// Código de saída 8 (disco falhando) com o JSON completo
+			fixture: "smartctl_ata_failing.json",
+			want: DiskHealth{
+				Device: "/dev/sda", Status: DiskHealthFailed, Source: "smartctl", Model: "WDC WD40EFRX-68N32N0",
+				Temperature: int64Ptr(41), PowerOnHours: uint64Ptr(41237), ReallocatedSectors: uint64Ptr(2184),
+			},
+		},
+		{
+			// NVMe não tem a tabela de atributos ATA
+			fixture: "smartctl_nvme.json",
+			want: DiskHealth{
+				Device: "/dev/sda", Status: DiskHealthPassed, Source: "smartctl", Model: "Samsung SSD 980 PRO 1TB",
+				Temperature: int64Ptr(38), PowerOnHours: uint64Ptr(4412),
+			},
+		},
+		{
+			fixture: "smartctl_permission_denied.json",
+			want: DiskHealth{
+				Device: "/dev/sda", Status: DiskHealthUnknown, Source: "smartctl",
+				Error: "Smartctl open device: /dev/sda failed: Permission denied",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			health, err := parseSmartctl("/dev/sda", readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*health, tt.want) {
+				t.Fatalf("parseSmartctl = %+v, want %+v", *health, tt.want)
+			}
+		})
+	}
+
+	// Sem mensagem de erro, o status de saída explica o unknown
+	health, err := parseSmartctl("/dev/sda", []byte(`{"smartctl":{"exit_status":4}}`))
+	if err != nil || health.Status != DiskHealthUnknown || health.Error != "smartctl exit status 4" {
+		t.Fatalf("without smart_status = %+v, %v", health, err)
+	}
+	if _, err := parseSmartctl("/dev/sda", []byte("smartctl 6.6")); err == nil {
+		t.Fatal("non-JSON output accepted")
+	}
+}
+
+func TestParseDiskutilSMART(t *testing.T) {
+	health, err := parseDiskutilSMART("/dev/disk0", readFixture(t, "diskutil_smart.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DiskHealth{Device: "/dev/disk0", Status: DiskHealthPassed, Source: "diskutil", Model: "APPLE SSD AP0512Q"}
+	if !reflect.DeepEqual(*health, want) {
+		t.Fatalf("parseDiskutilSMART = %+v, want %+v", *health, want)
+	}
+
+	for status, want := range map[string]string{"Failing": DiskHealthFailed, "Not Supported": DiskHealthUnknown} {
+		output := bytes.Replace(readFixture(t, "diskutil_smart.plist"), []byte("Verified"), []byte(status), 1)
+		health, err := parseDiskutilSMART("/dev/disk0", output)
+		if err != nil || health.Status != want {
+			t.Errorf("SMARTStatus %q = %+v, %v", status, health, err)
+		}
+	}
+}
+
+func TestPhysicalDisk(t *testing.T) {
+	tests := map[string][]struct {
+		disk DiskInfo
+		want string
+	}{
+		"linux": {
+			{DiskInfo{Device: "/dev/sda1"}, "/dev/sda"},
+			{DiskInfo{Device: "/dev/sda"}, "/dev/sda"},
+			{DiskInfo{Device: "/dev/nvme0n1p2"}, "/dev/nvme0n1"},
+			{DiskInfo{Device: "/dev/mmcblk0p1"}, "/dev/mmcblk0"},
+			{DiskInfo{Device: "/dev/mapper/vg-root"}, "/dev/mapper/vg-root"},
+			{DiskInfo{Device: "tmpfs"}, ""},
+			{DiskInfo{Device: "server:/export"}, ""},
+		},
+		"darwin": {
+			{DiskInfo{Device: "/dev/disk1s1"}, "/dev/disk1"},
+			{DiskInfo{Device: "/dev/disk3s1s1", Darwin: &DarwinVolumeInfo{APFSPhysicalStores: []string{"disk0s2"}}}, "/dev/disk0"},
+			{DiskInfo{Device: "devfs"}, ""},
+		},
+	}
+	cases, ok := tests[runtime.GOOS]
+	if !ok {
+		t.Skip("no partition naming on " + runtime.GOOS)
+	}
+	for _, tt := range cases {
+		if got := physicalDisk(&tt.disk); got != tt.want {
+			t.Errorf("physicalDisk(%s) = %q, want %q", tt.disk.Device, got, tt.want)
+		}
+	}
+}
+
+func TestSmartctlHealthDegrades(t *testing.T) {
+	c := newTestCollector(t)
+
+	// Sem smartctl instalado
+	c.SetCommandRunner(newCountingRunner(nil))
+	if health := c.smartctlHealth(context.Background(), "/dev/sda"); health.Status != DiskHealthUnknown || health.Error != "smartctl not installed" {
+		t.Fatalf("without smartctl = %+v", health)
+	}
+
+	// Saída diferente de zero sem JSON
+	c.SetCommandRunner(&exitRunner{err: errors.New("exit status 1")})
+	if health := c.smartctlHealth(context.Background(), "/dev/sda"); health.Status != DiskHealthUnknown || health.Error != "exit status 1" {
+		t.Fatalf("failed without output = %+v", health)
+	}
+
+	// A máscara de saída do smartctl não descarta o JSON completo
+	c.SetCommandRunner(&exitRunner{output: readFixture(t, "smartctl_ata_failing.json"), err: errors.New("exit status 8")})
+	if health := c.smartctlHealth(context.Background(), "/dev/sda"); health.Status != DiskHealthFailed || *health.ReallocatedSectors != 2184 {
+		t.Fatalf("failing disk = %+v", health)
+	}
+
+	// Permissão negada
+	c.SetCommandRunner(&exitRunner{output: readFixture(t, "smartctl_permission_denied.json"), err: errors.New("exit status 2")})
+	if health := c.smartctlHealth(context.Background(), "/dev/sda"); health.Status != DiskHealthUnknown || health.Error == "" {
+		t.Fatalf("permission denied = %+v", health)
+	}
+}
+
+func TestAttachDiskHealth(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses Linux partition names")
+	}
+	c := newTestCollector(t)
+	runner := &exitRunner{output: readFixture(t, "smartctl_ata_passed.json")}
+	c.SetCommandRunner(runner)
+
+	disks := []DiskInfo{{Device: "/dev/sda1"}, {Device: "/dev/sda2"}, {Device: "/dev/nvme0n1p1"}, {Device: "tmpfs"}}
+	c.attachDiskHealth(context.Background(), disks)
+
+	// Um disco físico é consultado uma vez, e as partições compartilham o resultado
+	if want := []string{"/dev/sda", "/dev/nvme0n1"}; !reflect.DeepEqual(runner.calls, want) {
+		t.Fatalf("smartctl queried %v, want %v", runner.calls, want)
+	}
+	if disks[0].Health == nil || disks[0].Health != disks[1].Health || disks[0].Health.Status != DiskHealthPassed {
+		t.Fatalf("partition health = %+v, %+v", disks[0].Health, disks[1].Health)
+	}
+	if disks[3].Health != nil {
+		t.Fatalf("tmpfs got health %+v", disks[3].Health)
+	}
+
+	// A coleta seguinte usa o cache
+	c.attachDiskHealth(context.Background(), []DiskInfo{{Device: "/dev/sda1"}})
+	if len(runner.calls) != 2 {
+		t.Fatalf("smartctl queried again within cache_expiration: %v", runner.calls)
+	}
+}
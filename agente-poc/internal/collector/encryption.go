@@ -0,0 +1,409 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Estados de criptografia de um volume
+const (
+	EncryptionEnabled  = "enabled"
+	EncryptionDisabled = "disabled"
+	EncryptionPartial  = "partial" // criptografando ou descriptografando
+	EncryptionUnknown  = "unknown"
+)
+
+// CacheKeyEncryption é a chave de cache do estado de criptografia; as
+// ferramentas consultadas são lentas e o estado muda raramente
+const CacheKeyEncryption = "encryption"
+
+// encryptionPermissionDenied é o motivo registrado quando a ferramenta
+// exige elevação
+const encryptionPermissionDenied = "permission denied"
+
+// VolumeEncryption é o estado de criptografia de um volume
+type VolumeEncryption struct {
+	// Volume é o ponto de montagem (letra da unidade no Windows)
+	Volume string `json:"volume"`
+	Device string `json:"device,omitempty"`
+	Status string `json:"status"`
+	Method string `json:"method,omitempty"`
+	// Percent é o progresso da conversão quando Status é partial
+	Percent float64 `json:"percent,omitempty"`
+	Boot    bool    `json:"boot,omitempty"`
+}
+
+// EncryptionInfo resume a criptografia de disco da máquina. Status é o do
+// volume de boot, o que importa para compliance; sem permissão para
+// consultar a ferramenta, Status é unknown e Reason explica o motivo.
+type EncryptionInfo struct {
+	Status  string             `json:"status"`
+	Method  string             `json:"method,omitempty"`
+	Reason  string             `json:"reason,omitempty"`
+	Volumes []VolumeEncryption `json:"volumes,omitempty"`
+}
+
+// collectEncryptionInfo consulta a criptografia de disco da plataforma:
+// FileVault no macOS, BitLocker no Windows e LUKS no Linux. Uma falha de
+// permissão vira status unknown em vez de erro.
+func (c *SystemCollector) collectEncryptionInfo(ctx context.Context) (*EncryptionInfo, error) {
+	if cached, ok := c.getFromCache(CacheKeyEncryption).(*EncryptionInfo); ok {
+		return cached, nil
+	}
+
+	var info *EncryptionInfo
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		info, err = c.collectFileVault(ctx)
+	case "windows":
+		info, err = c.collectBitLocker(ctx)
+	case "linux":
+		info, err = c.collectLUKS(ctx)
+	default:
+		return &EncryptionInfo{Status: EncryptionUnknown}, nil
+	}
+	if err != nil {
+		if isPermissionDenied(err) {
+			return &EncryptionInfo{Status: EncryptionUnknown, Reason: encryptionPermissionDenied}, nil
+		}
+		return nil, err
+	}
+	c.setInCache(CacheKeyEncryption, info, c.configFor(ctx).CacheExpiration)
+	return info, nil
+}
+
+// isPermissionDenied indica se a ferramenta falhou por falta de elevação:
+// EACCES ao executar, mensagens de permissão no stderr ou o E_ACCESSDENIED
+// (0x80070005) como código de saída do manage-bde
+func isPermissionDenied(err error) bool {
+	if errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	if uint32(exitErr.ExitCode()) == 0x80070005 {
+		return true
+	}
+	stderr := strings.ToLower(string(exitErr.Stderr))
+	for _, marker := range []string{"permission denied", "access is denied", "must be run as root", "requires root", "administrator"} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectFileVault usa o fdesetup status para o volume de boot e o diskutil
+// apfs list para os demais volumes APFS
+func (c *SystemCollector) collectFileVault(ctx context.Context) (*EncryptionInfo, error) {
+	output, err := c.runProbe(ctx, "fdesetup", "status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute fdesetup: %w", err)
+	}
+	boot := parseFdesetupStatus(output)
+	boot.Volume = "/"
+	boot.Boot = true
+
+	info := &EncryptionInfo{Status: boot.Status, Method: boot.Method}
+	volumes := []VolumeEncryption{boot}
+	if output, err := c.runProbe(ctx, "diskutil", "apfs", "list"); err == nil {
+		for _, volume := range parseDiskutilAPFSList(output) {
+			// O volume de boot já veio do fdesetup
+			if volume.Volume == "/" || volume.Volume == "/System/Volumes/Data" {
+				continue
+			}
+			volumes = append(volumes, volume)
+		}
+	} else {
+		c.logger.WithField("error", err).Debug("Failed to list APFS volumes")
+	}
+	info.Volumes = volumes
+	return info, nil
+}
+
+// fdesetupProgress captura o percentual de "Encryption in progress: Percent
+// completed = 42.5"
+var fdesetupProgress = regexp.MustCompile(`Percent completed = ([0-9.]+)`)
+
+// parseFdesetupStatus interpreta o fdesetup status ("FileVault is On.",
+// "FileVault is Off." ou a conversão em andamento)
+func parseFdesetupStatus(output []byte) VolumeEncryption {
+	text := string(output)
+	volume := VolumeEncryption{Status: EncryptionUnknown}
+	switch {
+	case strings.Contains(text, "Encryption in progress"), strings.Contains(text, "Decryption in progress"):
+		volume.Status = EncryptionPartial
+		if match := fdesetupProgress.FindStringSubmatch(text); match != nil {
+			volume.Percent, _ = strconv.ParseFloat(match[1], 64)
+		}
+	case strings.Contains(text, "FileVault is On"):
+		volume.Status = EncryptionEnabled
+	case strings.Contains(text, "FileVault is Off"):
+		volume.Status = EncryptionDisabled
+	}
+	if volume.Status != EncryptionDisabled && volume.Status != EncryptionUnknown {
+		volume.Method = "FileVault"
+	}
+	return volume
+}
+
+// parseDiskutilAPFSList interpreta os volumes do diskutil apfs list: cada
+// bloco "+-> Volume diskXsY" traz "Mount Point:" e "FileVault:" ("Yes
+// (Unlocked)", "Yes (Locked)" ou "No"). Volumes sem ponto de montagem
+// (Preboot, VM, snapshots desmontados) são ignorados.
+func parseDiskutilAPFSList(output []byte) []VolumeEncryption {
+	var volumes []VolumeEncryption
+	var current *VolumeEncryption
+	flush := func() {
+		if current != nil && current.Volume != "" {
+			volumes = append(volumes, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), "| ")
+		if strings.HasPrefix(line, "+-> Volume ") {
+			flush()
+			fields := strings.Fields(line)
+			current = &VolumeEncryption{Device: fields[2], Status: EncryptionUnknown}
+			continue
+		}
+		if strings.HasPrefix(line, "+->") || strings.HasPrefix(line, "+--") {
+			flush()
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Mount Point":
+			if !strings.HasPrefix(value, "Not Mounted") {
+				current.Volume = value
+			}
+		case "FileVault":
+			if strings.HasPrefix(value, "Yes") {
+				current.Status = EncryptionEnabled
+				current.Method = "FileVault"
+			} else if strings.HasPrefix(value, "No") {
+				current.Status = EncryptionDisabled
+			}
+		}
+	}
+	flush()
+	return volumes
+}
+
+// collectBitLocker consulta o manage-bde -status, que exige administrador
+func (c *SystemCollector) collectBitLocker(ctx context.Context) (*EncryptionInfo, error) {
+	output, err := c.runProbe(ctx, "manage-bde", "-status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute manage-bde: %w", err)
+	}
+
+	volumes := parseManageBDEStatus(output)
+	info := &EncryptionInfo{Status: EncryptionUnknown, Volumes: volumes}
+	systemDrive := strings.ToUpper(os.Getenv("SystemDrive"))
+	for i := range volumes {
+		if !volumes[i].Boot && systemDrive != "" && volumes[i].Volume == systemDrive {
+			volumes[i].Boot = true
+		}
+		if volumes[i].Boot {
+			info.Status = volumes[i].Status
+			info.Method = volumes[i].Method
+		}
+	}
+	return info, nil
+}
+
+// manageBDEVolume captura a letra de "Volume C: [OS]"
+var manageBDEVolume = regexp.MustCompile(`^Volume ([A-Za-z]:)`)
+
+// parseManageBDEStatus interpreta o manage-bde -status (saída em inglês):
+// um bloco por volume, com "[OS Volume]" marcando o de boot e o estado em
+// "Conversion Status"
+func parseManageBDEStatus(output []byte) []VolumeEncryption {
+	var volumes []VolumeEncryption
+	var current *VolumeEncryption
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := manageBDEVolume.FindStringSubmatch(line); match != nil {
+			volumes = append(volumes, VolumeEncryption{Volume: strings.ToUpper(match[1]), Status: EncryptionUnknown})
+			current = &volumes[len(volumes)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line == "[OS Volume]" {
+			current.Boot = true
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Conversion Status":
+			current.Status = bitLockerConversionStatus(value)
+		case "Percentage Encrypted":
+			if current.Status == EncryptionPartial {
+				current.Percent, _ = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			}
+		case "Encryption Method":
+			if value != "None" {
+				current.Method = "BitLocker " + value
+			}
+		}
+	}
+	return volumes
+}
+
+// bitLockerConversionStatus traduz o Conversion Status do manage-bde
+func bitLockerConversionStatus(value string) string {
+	switch {
+	case strings.HasPrefix(value, "Fully Encrypted"), strings.HasPrefix(value, "Used Space Only Encrypted"):
+		return EncryptionEnabled
+	case strings.HasPrefix(value, "Fully Decrypted"):
+		return EncryptionDisabled
+	case strings.Contains(value, "Progress"), strings.Contains(value, "Paused"):
+		return EncryptionPartial
+	default:
+		return EncryptionUnknown
+	}
+}
+
+// lsblkDevice é um dispositivo da árvore do lsblk --json
+type lsblkDevice struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	FSType     string        `json:"fstype"`
+	Mountpoint string        `json:"mountpoint"`
+	Children   []lsblkDevice `json:"children"`
+}
+
+// collectLUKS percorre a árvore do lsblk: um volume montado abaixo de um
+// dispositivo crypt (dm-crypt/LUKS) está criptografado. O cipher vem do
+// cryptsetup status, que exige root; sem ele o método fica só "LUKS".
+func (c *SystemCollector) collectLUKS(ctx context.Context) (*EncryptionInfo, error) {
+	output, err := c.runProbe(ctx, "lsblk", "--json", "-o", "NAME,TYPE,FSTYPE,MOUNTPOINT")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute lsblk: %w", err)
+	}
+	volumes, mappings, err := parseLsblkEncryption(output)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphers := make(map[string]string)
+	for _, mapping := range mappings {
+		if output, err := c.runProbe(ctx, "cryptsetup", "status", mapping); err == nil {
+			ciphers[mapping] = parseCryptsetupStatus(output)
+		}
+	}
+
+	info := &EncryptionInfo{Status: EncryptionUnknown}
+	for i := range volumes {
+		if cipher := ciphers[volumes[i].Device]; cipher != "" {
+			volumes[i].Method = cipher
+		}
+		if volumes[i].Boot {
+			info.Status = volumes[i].Status
+			info.Method = volumes[i].Method
+		}
+	}
+	info.Volumes = volumes
+	return info, nil
+}
+
+// parseLsblkEncryption retorna os volumes montados (sem swap) com o estado
+// de criptografia e os nomes dos mapeamentos crypt encontrados
+func parseLsblkEncryption(output []byte) ([]VolumeEncryption, []string, error) {
+	var tree struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal(output, &tree); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse lsblk output: %w", err)
+	}
+
+	var volumes []VolumeEncryption
+	var mappings []string
+	// mapping é o dispositivo crypt mais próximo acima; method, o formato
+	// da partição que o contém (crypto_LUKS)
+	var walk func(device lsblkDevice, mapping, method string)
+	walk = func(device lsblkDevice, mapping, method string) {
+		if device.Type == "crypt" {
+			mapping = device.Name
+			mappings = append(mappings, device.Name)
+		}
+		if device.FSType == "crypto_LUKS" {
+			method = "LUKS"
+		}
+		if device.Mountpoint != "" && device.Mountpoint != "[SWAP]" {
+			volume := VolumeEncryption{
+				Volume: device.Mountpoint,
+				Device: device.Name,
+				Status: EncryptionDisabled,
+				Boot:   device.Mountpoint == "/",
+			}
+			if mapping != "" {
+				volume.Status = EncryptionEnabled
+				volume.Device = mapping
+				volume.Method = method
+				if volume.Method == "" {
+					volume.Method = "dm-crypt"
+				}
+			}
+			volumes = append(volumes, volume)
+		}
+		for _, child := range device.Children {
+			walk(child, mapping, method)
+		}
+	}
+	for _, device := range tree.BlockDevices {
+		walk(device, "", "")
+	}
+	return volumes, mappings, nil
+}
+
+// parseCryptsetupStatus retorna "<type> <cipher>" do cryptsetup status
+// (ex.: "LUKS2 aes-xts-plain64")
+func parseCryptsetupStatus(output []byte) string {
+	var kind, cipher string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "type":
+			kind = strings.TrimSpace(value)
+		case "cipher":
+			cipher = strings.TrimSpace(value)
+		}
+	}
+	return strings.TrimSpace(kind + " " + cipher)
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseFdesetupStatus(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    VolumeEncryption
+	}{
+		{"fdesetup_on.txt", VolumeEncryption{Status: EncryptionEnabled, Method: "FileVault"}},
+		{"fdesetup_off.txt", VolumeEncryption{Status: EncryptionDisabled}},
+		{"fdesetup_encrypting.txt", VolumeEncryption{Status: EncryptionPartial, Method: "FileVault", Percent: 42.5}},
+	}
+	for _, tt := range tests {
+		if got := parseFdesetupStatus(readFixture(t, tt.fixture)); got != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.fixture, got, tt.want)
+		}
+	}
+	if got := parseFdesetupStatus([]byte("unexpected\n")); got.Status != EncryptionUnknown || got.Method != "" {
+		t.Errorf("unexpected output: %+v", got)
+	}
+}
+
+func TestCollectFileVault(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		"fdesetup status":    readFixture(t, "fdesetup_on.txt"),
+		"diskutil apfs list": readFixture(t, "diskutil_apfs_list.txt"),
+	}))
+
+	info, err := c.collectFileVault(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O boot vem do fdesetup; System, Data e os volumes desmontados ficam de fora
+	want := &EncryptionInfo{
+		Status: EncryptionEnabled,
+		Method: "FileVault",
+		Volumes: []VolumeEncryption{
+			{Volume: "/", Status: EncryptionEnabled, Method: "FileVault", Boot: true},
+			{Volume: "/Volumes/Projects", Device: "disk3s7", Status: EncryptionEnabled, Method: "FileVault"},
+			{Volume: "/Volumes/Backup", Device: "disk5s1", Status: EncryptionDisabled},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("info = %+v", info)
+	}
+
+	// Sem o diskutil, fica só o volume de boot
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{"fdesetup status": readFixture(t, "fdesetup_off.txt")}))
+	c.ClearCache()
+	info, err = c.collectFileVault(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != EncryptionDisabled || len(info.Volumes) != 1 || !info.Volumes[0].Boot {
+		t.Fatalf("info = %+v", info)
+	}
+}
+
+func TestCollectBitLocker(t *testing.T) {
+	t.Setenv("SystemDrive", "")
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{"manage-bde -status": readFixture(t, "manage_bde_status.txt")}))
+
+	info, err := c.collectBitLocker(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EncryptionInfo{
+		Status: EncryptionEnabled,
+		Method: "BitLocker XTS-AES 128",
+		Volumes: []VolumeEncryption{
+			{Volume: "C:", Status: EncryptionEnabled, Method: "BitLocker XTS-AES 128", Boot: true},
+			{Volume: "D:", Status: EncryptionPartial, Method: "BitLocker XTS-AES 256", Percent: 37.5},
+			{Volume: "E:", Status: EncryptionDisabled},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("info = %+v", info)
+	}
+}
+
+func TestBitLockerConversionStatus(t *testing.T) {
+	tests := map[string]string{
+		"Fully Encrypted":           EncryptionEnabled,
+		"Used Space Only Encrypted": EncryptionEnabled,
+		"Fully Decrypted":           EncryptionDisabled,
+		"Encryption in Progress":    EncryptionPartial,
+		"Decryption in Progress":    EncryptionPartial,
+		"Encryption Paused":         EncryptionPartial,
+		"Totalmente criptografado":  EncryptionUnknown,
+	}
+	for value, want := range tests {
+		if got := bitLockerConversionStatus(value); got != want {
+			t.Errorf("bitLockerConversionStatus(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestCollectLUKS(t *testing.T) {
+	lsblk := "lsblk --json -o NAME,TYPE,FSTYPE,MOUNTPOINT"
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		lsblk:                               readFixture(t, "lsblk_luks.json"),
+		"cryptsetup status nvme0n1p3_crypt": readFixture(t, "cryptsetup_status.txt"),
+	}))
+
+	info, err := c.collectLUKS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EncryptionInfo{
+		Status: EncryptionEnabled,
+		Method: "LUKS2 aes-xts-plain64",
+		Volumes: []VolumeEncryption{
+			{Volume: "/boot/efi", Device: "nvme0n1p1", Status: EncryptionDisabled},
+			{Volume: "/boot", Device: "nvme0n1p2", Status: EncryptionDisabled},
+			{Volume: "/", Device: "nvme0n1p3_crypt", Status: EncryptionEnabled, Method: "LUKS2 aes-xts-plain64", Boot: true},
+			{Volume: "/srv/data", Device: "sda1", Status: EncryptionDisabled},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("info = %+v", info)
+	}
+
+	// Sem root para o cryptsetup, o método fica só LUKS
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{lsblk: readFixture(t, "lsblk_luks.json")}))
+	c.ClearCache()
+	info, err = c.collectLUKS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != EncryptionEnabled || info.Method != "LUKS" {
+		t.Fatalf("info without cryptsetup = %+v", info)
+	}
+
+	// Saída que não é JSON é erro
+	if _, _, err := parseLsblkEncryption([]byte("NAME TYPE\nsda disk\n")); err == nil {
+		t.Fatal("table output parsed")
+	}
+}
+
+func TestCollectEncryptionPermissionDenied(t *testing.T) {
+	tool := map[string]string{"darwin": "fdesetup", "windows": "manage-bde", "linux": "lsblk"}[runtime.GOOS]
+	if tool == "" {
+		t.Skip("no encryption tool on " + runtime.GOOS)
+	}
+
+	for name, err := range map[string]error{
+		"stderr": &exec.ExitError{Stderr: []byte("Error: This command must be run as root.\n")},
+		"exec":   fmt.Errorf("fork/exec: %w", os.ErrPermission),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestCollector(t)
+			c.SetCommandRunner(&fakePackageRunner{errs: map[string]error{tool: err}})
+
+			info, collectErr := c.collectEncryptionInfo(context.Background())
+			if collectErr != nil {
+				t.Fatal(collectErr)
+			}
+			if info.Status != EncryptionUnknown || info.Reason != encryptionPermissionDenied {
+				t.Fatalf("info = %+v", info)
+			}
+		})
+	}
+
+	// Outras falhas continuam sendo erro
+	c := newTestCollector(t)
+	c.SetCommandRunner(&fakePackageRunner{errs: map[string]error{tool: &exec.ExitError{Stderr: []byte("device busy\n")}}})
+	if _, err := c.collectEncryptionInfo(context.Background()); err == nil {
+		t.Fatal("generic failure reported as permission denied")
+	}
+}
@@ -0,0 +1,349 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CacheKeyGPUs é a chave de cache da lista de GPUs; placas mudam raramente
+// e os comandos consultados são lentos
+const CacheKeyGPUs = "gpus"
+
+// GPUInfo descreve um adaptador de vídeo. Campos ausentes na fonte da
+// plataforma ficam vazios.
+type GPUInfo struct {
+	Model  string `json:"model"`
+	Vendor string `json:"vendor,omitempty"`
+	// VRAM é zero em GPUs de memória compartilhada (Apple Silicon, Intel
+	// integrada); no Windows o AdapterRAM satura em 4 GiB
+	VRAM          uint64 `json:"vram_bytes,omitempty"`
+	Driver        string `json:"driver,omitempty"` // módulo do kernel no Linux
+	DriverVersion string `json:"driver_version,omitempty"`
+	// BusID é o endereço PCI no Linux (ex.: "01:00.0")
+	BusID string `json:"bus_id,omitempty"`
+}
+
+// spDisplaysVendor casa o fabricante em "sppci_vendor_Apple" ou
+// "NVIDIA (0x10de)"
+var spDisplaysVendor = regexp.MustCompile(`^(?:sppci_vendor_)?(.*?)(?:\s*\(0x[0-9a-fA-F]+\))?$`)
+
+// parseSPDisplays interpreta `system_profiler SPDisplaysDataType -json`
+func parseSPDisplays(data []byte) ([]GPUInfo, error) {
+	var result struct {
+		SPDisplaysDataType []map[string]interface{} `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse system_profiler output: %w", err)
+	}
+
+	gpus := []GPUInfo{}
+	for _, item := range result.SPDisplaysDataType {
+		field := func(key string) string {
+			value, _ := item[key].(string)
+			return strings.TrimSpace(value)
+		}
+
+		gpu := GPUInfo{Model: field("sppci_model")}
+		if gpu.Model == "" {
+			gpu.Model = field("_name")
+		}
+		if gpu.Model == "" {
+			continue
+		}
+		if match := spDisplaysVendor.FindStringSubmatch(field("sppci_vendor")); match != nil {
+			gpu.Vendor = match[1]
+		}
+		// Macs Intel com GPU dedicada trazem spdisplays_vram; a integrada
+		// traz spdisplays_vram_shared, que não é memória própria
+		gpu.VRAM = parseMemorySize(field("spdisplays_vram"))
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// parseMemorySize converte "1536 MB" ou "8 GB" em bytes; zero se não entender
+func parseMemorySize(value string) uint64 {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0
+	}
+	amount, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.ToUpper(fields[1]) {
+	case "MB":
+		return amount << 20
+	case "GB":
+		return amount << 30
+	}
+	return 0
+}
+
+// lspciDisplayClasses são as classes PCI de adaptadores de vídeo
+var lspciDisplayClasses = map[string]bool{
+	"VGA compatible controller": true,
+	"3D controller":             true,
+	"Display controller":        true,
+}
+
+// parseLspci interpreta `lspci -vmmk`: um registro "Chave:\tvalor" por
+// dispositivo, separados por linha em branco. Só adaptadores de vídeo entram.
+func parseLspci(output []byte) []GPUInfo {
+	gpus := []GPUInfo{}
+	record := map[string]string{}
+	flush := func() {
+		if lspciDisplayClasses[record["Class"]] && record["Device"] != "" {
+			gpus = append(gpus, GPUInfo{
+				Model:  record["Device"],
+				Vendor: record["Vendor"],
+				Driver: record["Driver"],
+				BusID:  record["Slot"],
+			})
+		}
+		record = map[string]string{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Chaves repetidas (ex.: Module) mantêm a primeira ocorrência
+		if _, seen := record[key]; !seen {
+			record[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return gpus
+}
+
+// nvidiaSMIArgs consulta as GPUs NVIDIA; memory.total vem em MiB
+var nvidiaSMIArgs = []string{
+	"--query-gpu=name,memory.total,driver_version,pci.bus_id",
+	"--format=csv,noheader,nounits",
+}
+
+// parseNvidiaSMI interpreta a saída CSV de nvidia-smi
+func parseNvidiaSMI(output []byte) ([]GPUInfo, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	gpus := []GPUInfo{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse nvidia-smi output: %w", err)
+		}
+		if len(row) < 4 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		gpu := GPUInfo{
+			Model:         strings.TrimSpace(row[0]),
+			Vendor:        "NVIDIA Corporation",
+			DriverVersion: strings.TrimSpace(row[2]),
+			BusID:         normalizePCIAddress(row[3]),
+		}
+		if mib, err := strconv.ParseUint(strings.TrimSpace(row[1]), 10, 64); err == nil {
+			gpu.VRAM = mib << 20
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// normalizePCIAddress reduz "00000000:01:00.0" ao formato do lspci
+// ("01:00.0"); o domínio só é mantido quando não é zero
+func normalizePCIAddress(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	parts := strings.Split(address, ":")
+	if len(parts) == 3 && strings.Trim(parts[0], "0") == "" {
+		return parts[1] + ":" + parts[2]
+	}
+	return address
+}
+
+// mergeNvidiaSMI completa as GPUs do lspci com VRAM e versão do driver do
+// nvidia-smi, casando pelo endereço PCI; as que não casam são acrescentadas
+func mergeNvidiaSMI(gpus, nvidia []GPUInfo) []GPUInfo {
+	for _, extra := range nvidia {
+		merged := false
+		for i := range gpus {
+			if extra.BusID != "" && normalizePCIAddress(gpus[i].BusID) == extra.BusID {
+				gpus[i].Model = extra.Model
+				gpus[i].VRAM = extra.VRAM
+				gpus[i].DriverVersion = extra.DriverVersion
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			gpus = append(gpus, extra)
+		}
+	}
+	return gpus
+}
+
+// wmicVideoControllerArgs lista os adaptadores do Windows em CSV
+var wmicVideoControllerArgs = []string{
+	"path", "win32_VideoController",
+	"get", "Name,AdapterCompatibility,AdapterRAM,DriverVersion",
+	"/format:csv",
+}
+
+// parseWmicVideoController interpreta `wmic ... /format:csv`. As colunas
+// vêm em ordem alfabética depois de Node e são localizadas pelo cabeçalho.
+func parseWmicVideoController(output []byte) ([]GPUInfo, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+
+	var header map[string]int
+	gpus := []GPUInfo{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse wmic output: %w", err)
+		}
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+		if len(row) == 1 && row[0] == "" {
+			continue
+		}
+		if header == nil {
+			header = make(map[string]int, len(row))
+			for i, name := range row {
+				header[name] = i
+			}
+			continue
+		}
+		column := func(name string) string {
+			if i, ok := header[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		gpu := GPUInfo{
+			Model:         column("Name"),
+			Vendor:        column("AdapterCompatibility"),
+			DriverVersion: column("DriverVersion"),
+		}
+		if gpu.Model == "" {
+			continue
+		}
+		if ram, err := strconv.ParseUint(column("AdapterRAM"), 10, 64); err == nil {
+			gpu.VRAM = ram
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus, nil
+}
+
+// collectGPUInfo lista as GPUs pela fonte da plataforma. Máquinas sem GPU
+// (ou sem a ferramenta, como servidores sem pciutils) retornam lista vazia.
+func (c *SystemCollector) collectGPUInfo(ctx context.Context) ([]GPUInfo, error) {
+	if cached, ok := c.getFromCache(CacheKeyGPUs).([]GPUInfo); ok {
+		return cached, nil
+	}
+
+	var gpus []GPUInfo
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		gpus, err = c.collectDarwinGPUs(ctx)
+	case "linux":
+		gpus, err = c.collectLinuxGPUs(ctx)
+	case "windows":
+		gpus, err = c.collectWindowsGPUs(ctx)
+	default:
+		gpus = []GPUInfo{}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.setInCache(CacheKeyGPUs, gpus, c.configFor(ctx).CacheExpiration)
+	return gpus, nil
+}
+
+// collectDarwinGPUs consulta o system_profiler
+func (c *SystemCollector) collectDarwinGPUs(ctx context.Context) ([]GPUInfo, error) {
+	output, err := c.runProbe(ctx, "system_profiler", "SPDisplaysDataType", "-json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute system_profiler: %w", err)
+	}
+	return parseSPDisplays(output)
+}
+
+// collectLinuxGPUs consulta o lspci e, havendo placa NVIDIA (ou sem lspci),
+// o nvidia-smi; ferramentas ausentes não são erro
+func (c *SystemCollector) collectLinuxGPUs(ctx context.Context) ([]GPUInfo, error) {
+	gpus := []GPUInfo{}
+	queryNvidia := true
+
+	output, err := c.runProbe(ctx, "lspci", "-vmmk")
+	switch {
+	case err == nil:
+		gpus = parseLspci(output)
+		queryNvidia = false
+		for _, gpu := range gpus {
+			if strings.Contains(strings.ToLower(gpu.Vendor), "nvidia") {
+				queryNvidia = true
+				break
+			}
+		}
+	case !errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("failed to execute lspci: %w", err)
+	}
+
+	if queryNvidia {
+		output, err := c.runProbe(ctx, "nvidia-smi", nvidiaSMIArgs...)
+		if err == nil {
+			nvidia, err := parseNvidiaSMI(output)
+			if err != nil {
+				return nil, err
+			}
+			gpus = mergeNvidiaSMI(gpus, nvidia)
+		} else if !errors.Is(err, exec.ErrNotFound) {
+			c.logger.WithField("error", err).Debug("Failed to query nvidia-smi")
+		}
+	}
+	return gpus, nil
+}
+
+// collectWindowsGPUs consulta o Win32_VideoController pelo wmic
+func (c *SystemCollector) collectWindowsGPUs(ctx context.Context) ([]GPUInfo, error) {
+	output, err := c.runProbe(ctx, "wmic", wmicVideoControllerArgs...)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return []GPUInfo{}, nil
+		}
+		return nil, fmt.Errorf("failed to execute wmic: %w", err)
+	}
+	return parseWmicVideoController(output)
+}
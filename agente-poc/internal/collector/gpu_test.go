@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseSPDisplays(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []GPUInfo
+	}{
+		{
+			// Apple Silicon: memória compartilhada, sem VRAM
+			fixture: "spdisplays_apple_silicon.json",
+			want:    []GPUInfo{{Model: "Apple M2 Pro", Vendor: "Apple"}},
+		},
+		{
+			// A integrada só tem spdisplays_vram_shared, que não conta como VRAM
+			fixture: "spdisplays_intel_dual.json",
+			want: []GPUInfo{
+				{Model: "Intel UHD Graphics 630", Vendor: "Intel"},
+				{Model: "AMD Radeon Pro 5500M", Vendor: "AMD", VRAM: 8 << 30},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			gpus, err := parseSPDisplays(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gpus, tt.want) {
+				t.Fatalf("parseSPDisplays = %+v, want %+v", gpus, tt.want)
+			}
+		})
+	}
+
+	if gpus, err := parseSPDisplays([]byte(`{"SPDisplaysDataType":[]}`)); err != nil || gpus == nil || len(gpus) != 0 {
+		t.Fatalf("no adapters = %v, %v; want an empty list", gpus, err)
+	}
+	if _, err := parseSPDisplays([]byte("system_profiler: not found")); err == nil {
+		t.Fatal("invalid output accepted")
+	}
+
+	for value, want := range map[string]uint64{"1536 MB": 1536 << 20, "8 GB": 8 << 30, "8GB": 0, "2 TB": 0, "": 0} {
+		if got := parseMemorySize(value); got != want {
+			t.Errorf("parseMemorySize(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestParseLspci(t *testing.T) {
+	gpus := parseLspci(readFixture(t, "lspci_vmmk.txt"))
+	// Só as classes de vídeo; Module repetido não sobrescreve o Driver
+	want := []GPUInfo{
+		{Model: "CoffeeLake-H GT2 [UHD Graphics 630]", Vendor: "Intel Corporation", Driver: "i915", BusID: "00:02.0"},
+		{Model: "TU117M [GeForce GTX 1650 Mobile / Max-Q]", Vendor: "NVIDIA Corporation", Driver: "nvidia", BusID: "01:00.0"},
+	}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Fatalf("parseLspci = %+v, want %+v", gpus, want)
+	}
+	if gpus := parseLspci(nil); gpus == nil || len(gpus) != 0 {
+		t.Fatalf("empty output = %v; want an empty list", gpus)
+	}
+}
+
+func TestParseNvidiaSMI(t *testing.T) {
+	gpus, err := parseNvidiaSMI(readFixture(t, "nvidia_smi.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GPUInfo{{
+		Model:         "NVIDIA GeForce GTX 1650 with Max-Q Design",
+		Vendor:        "NVIDIA Corporation",
+		VRAM:          4096 << 20,
+		DriverVersion: "535.129.03",
+		BusID:         "01:00.0",
+	}}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Fatalf("parseNvidiaSMI = %+v, want %+v", gpus, want)
+	}
+
+	for address, want := range map[string]string{
+		"00000000:01:00.0": "01:00.0",
+		"0000:3B:00.0":     "3b:00.0",
+		"00000001:01:00.0": "00000001:01:00.0",
+		"01:00.0":          "01:00.0",
+	} {
+		if got := normalizePCIAddress(address); got != want {
+			t.Errorf("normalizePCIAddress(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestMergeNvidiaSMI(t *testing.T) {
+	lspci := parseLspci(readFixture(t, "lspci_vmmk.txt"))
+	nvidia, err := parseNvidiaSMI(readFixture(t, "nvidia_smi.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nvidia = append(nvidia, GPUInfo{Model: "Tesla T4", Vendor: "NVIDIA Corporation", BusID: "3b:00.0"})
+
+	gpus := mergeNvidiaSMI(lspci, nvidia)
+	if len(gpus) != 3 {
+		t.Fatalf("merged %d GPUs, want 3: %+v", len(gpus), gpus)
+	}
+	// A placa casada pelo endereço mantém o driver do lspci e ganha VRAM e versão
+	merged := gpus[1]
+	if merged.Model != "NVIDIA GeForce GTX 1650 with Max-Q Design" || merged.Driver != "nvidia" || merged.VRAM != 4096<<20 || merged.DriverVersion != "535.129.03" {
+		t.Fatalf("merged GPU = %+v", merged)
+	}
+	if gpus[0].DriverVersion != "" || gpus[2].Model != "Tesla T4" {
+		t.Fatalf("unmatched GPUs = %+v, %+v", gpus[0], gpus[2])
+	}
+}
+
+func TestParseWmicVideoController(t *testing.T) {
+	gpus, err := parseWmicVideoController(readFixture(t, "wmic_videocontroller.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// AdapterRAM satura perto de 4 GiB; a linha sem Name é ignorada
+	want := []GPUInfo{
+		{Model: "Intel(R) UHD Graphics 630", Vendor: "Intel Corporation", VRAM: 1 << 30, DriverVersion: "31.0.101.2125"},
+		{Model: "NVIDIA GeForce GTX 1650", Vendor: "NVIDIA", VRAM: 4293918720, DriverVersion: "31.0.15.3713"},
+	}
+	if !reflect.DeepEqual(gpus, want) {
+		t.Fatalf("parseWmicVideoController = %+v, want %+v", gpus, want)
+	}
+
+	// As colunas são localizadas pelo cabeçalho, não pela posição
+	reordered := "Node,Name,DriverVersion\nPC,Microsoft Basic Display Adapter,10.0.19041.1\n"
+	gpus, err = parseWmicVideoController([]byte(reordered))
+	if err != nil || len(gpus) != 1 || gpus[0].Model != "Microsoft Basic Display Adapter" || gpus[0].VRAM != 0 {
+		t.Fatalf("reordered columns = %+v, %v", gpus, err)
+	}
+}
+
+func TestCollectLinuxGPUs(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{
+		"lspci -vmmk": readFixture(t, "lspci_vmmk.txt"),
+		"nvidia-smi --query-gpu=name,memory.total,driver_version,pci.bus_id --format=csv,noheader,nounits": readFixture(t, "nvidia_smi.csv"),
+	})
+	c.SetCommandRunner(runner)
+
+	gpus, err := c.collectLinuxGPUs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpus) != 2 || gpus[1].DriverVersion != "535.129.03" {
+		t.Fatalf("GPUs = %+v", gpus)
+	}
+
+	// Sem placa NVIDIA no lspci, o nvidia-smi não é consultado
+	runner.outputs["lspci -vmmk"] = []byte("Slot:\t00:02.0\nClass:\tVGA compatible controller\nVendor:\tIntel Corporation\nDevice:\tUHD Graphics 620\n")
+	runner.snapshot()
+	if gpus, err := c.collectLinuxGPUs(context.Background()); err != nil || len(gpus) != 1 {
+		t.Fatalf("Intel-only GPUs = %+v, %v", gpus, err)
+	}
+	if calls := runner.snapshot(); !reflect.DeepEqual(calls, map[string]int{"lspci -vmmk": 1}) {
+		t.Fatalf("commands run without NVIDIA = %v", calls)
+	}
+}
+
+func TestCollectGPUsWithoutTools(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(nil))
+
+	// Servidor sem pciutils nem driver NVIDIA: lista vazia, não erro
+	gpus, err := c.collectLinuxGPUs(context.Background())
+	if err != nil || gpus == nil || len(gpus) != 0 {
+		t.Fatalf("linux without tools = %v, %v", gpus, err)
+	}
+	gpus, err = c.collectWindowsGPUs(context.Background())
+	if err != nil || gpus == nil || len(gpus) != 0 {
+		t.Fatalf("windows without wmic = %v, %v", gpus, err)
+	}
+	if _, err := c.collectDarwinGPUs(context.Background()); err == nil {
+		t.Fatal("darwin without system_profiler returned no error")
+	}
+}
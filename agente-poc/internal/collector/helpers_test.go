@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"agente-poc/internal/logging"
+)
+
+// testLogger descarta tudo abaixo de FATAL, para não poluir a saída dos testes
+func testLogger(tb testing.TB) logging.Logger {
+	tb.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return logger
+}
+
+// newTestCollector cria um SystemCollector fechado ao fim do teste
+func newTestCollector(tb testing.TB) *SystemCollector {
+	tb.Helper()
+	c := New(time.Minute, testLogger(tb))
+	tb.Cleanup(func() { _ = c.Close() })
+	return c
+}
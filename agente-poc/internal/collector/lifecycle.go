@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed é retornado pelas coletas depois de Close
+var ErrClosed = errors.New("collector closed")
+
+// lifecycle controla o tempo de vida do SystemCollector: os ajudantes em
+// segundo plano (sampler de CPU) só existem depois de Start e começam no
+// primeiro uso; Close os para e aguarda as coletas em andamento. Sem Start
+// (selftest, subcomandos de uma coleta só) nenhuma goroutine sobrevive à coleta.
+type lifecycle struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stop    func() bool // desfaz o vínculo com o contexto de Start
+	started bool
+	running bool // sampler de CPU em execução
+	closed  bool
+
+	helpers  sync.WaitGroup
+	inflight sync.WaitGroup
+}
+
+// newLifecycle cria o controle com um contexto próprio, cancelado em Close
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Start habilita os ajudantes em segundo plano, que terminam junto com ctx
+// ou em Close. Chamadas repetidas não têm efeito; depois de Close retorna
+// ErrClosed.
+func (c *SystemCollector) Start(ctx context.Context) error {
+	l := c.life
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if l.started {
+		return nil
+	}
+	l.started = true
+	l.stop = context.AfterFunc(ctx, l.cancel)
+	return nil
+}
+
+// ensureHelpers inicia o sampler de CPU no primeiro uso depois de Start
+func (c *SystemCollector) ensureHelpers() {
+	l := c.life
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.started || l.running || l.closed {
+		return
+	}
+	l.running = true
+	l.helpers.Add(1)
+	go func() {
+		defer l.helpers.Done()
+		c.cpuSampler.Run(l.ctx, DefaultCPUSampleInterval)
+	}()
+}
+
+// begin registra uma coleta em andamento e retorna o contexto dela, limitado
+// pelo timeout da configuração e cancelado em Close; end deve ser chamado ao
+// terminar
+func (c *SystemCollector) begin() (ctx context.Context, end func(), err error) {
+	l := c.life
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, nil, ErrClosed
+	}
+	l.inflight.Add(1)
+	l.mu.Unlock()
+
+	c.ensureHelpers()
+
+	// A configuração fica fixa durante a coleta, mesmo com ApplySettings no meio
+	config := c.cfg()
+	ctx, cancel := context.WithTimeout(context.WithValue(l.ctx, configKey{}, config), config.Timeout)
+	return ctx, func() {
+		cancel()
+		l.inflight.Done()
+	}, nil
+}
+
+// Close para o sampler de CPU, cancela e aguarda as coletas em andamento e
+// descarta o cache. Pode ser chamado mais de uma vez; coletas posteriores
+// retornam ErrClosed.
+func (c *SystemCollector) Close() error {
+	l := c.life
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	if l.stop != nil {
+		l.stop()
+	}
+	l.mu.Unlock()
+
+	l.cancel()
+	l.helpers.Wait()
+	l.inflight.Wait()
+
+	c.cacheMu.Lock()
+	c.cache = make(map[string]*CacheItem)
+	c.cacheMu.Unlock()
+
+	c.logger.Debug("Collector closed")
+	return nil
+}
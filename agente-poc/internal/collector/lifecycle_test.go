@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestCollectorOneShotLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	// Sem Start (selftest, export), a coleta não deixa ajudantes rodando
+	c := New(time.Minute, testLogger(t))
+	if _, err := c.CollectMemoryInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if c.life.running {
+		t.Fatal("CPU sampler started without Start")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorStartClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c := New(time.Minute, testLogger(t))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("second Start: %v", err)
+	}
+	if c.life.running {
+		t.Fatal("CPU sampler started before the first collection")
+	}
+
+	// O sampler começa no primeiro uso
+	if _, err := c.CollectMemoryInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.life.running {
+		t.Fatal("CPU sampler not started on first use")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Close(); err != nil {
+			t.Fatalf("Close %d: %v", i+1, err)
+		}
+	}
+	if _, err := c.CollectMemoryInfo(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("collection after Close: %v", err)
+	}
+	if err := c.Start(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Start after Close: %v", err)
+	}
+	if stats := c.GetCacheStats(); stats["items"] != 0 {
+		t.Fatalf("cache after Close = %v", stats)
+	}
+}
+
+func TestCollectorStopsWithStartContext(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c := New(time.Minute, testLogger(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.ensureHelpers()
+
+	// O fim do contexto de Start para o sampler mesmo sem Close
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		c.life.helpers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("CPU sampler still running after the Start context ended")
+	}
+	_ = c.Close()
+}
+
+func TestCollectorCloseWaitsForCollections(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c := New(time.Minute, testLogger(t))
+	ctx, end, err := c.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		_ = c.Close()
+		close(closed)
+	}()
+
+	// Close cancela a coleta em andamento, mas só retorna quando ela termina
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight collection not cancelled by Close")
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the in-flight collection ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+	end()
+	<-closed
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// linuxPackageSource é um gerenciador de pacotes consultado no Linux
+type linuxPackageSource struct {
+	name  string
+	args  []string
+	parse func(output []byte) []Application
+}
+
+// linuxPackageSources são consultados em ordem; os ausentes na máquina são
+// ignorados. Os campos saem separados por tab, uma linha por pacote.
+var linuxPackageSources = []linuxPackageSource{
+	{
+		name:  "dpkg-query",
+		args:  []string{"-W", "-f", "${db:Status-Abbrev}\t${binary:Package}\t${Version}\t${Maintainer}\n"},
+		parse: parseDpkgPackages,
+	},
+	{
+		name:  "rpm",
+		args:  []string{"-qa", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}\t%{VENDOR}\t%{INSTALLTIME}\t%{ARCH}\n"},
+		parse: parseRPMPackages,
+	},
+	{
+		name:  "flatpak",
+		args:  []string{"list", "--app", "--columns=application,name,version,origin"},
+		parse: parseFlatpakApps,
+	},
+}
+
+// splitPackageLines divide a saída em linhas de campos separados por tab
+func splitPackageLines(output []byte, fields int) [][]string {
+	var rows [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "\t", fields)
+		for len(parts) < fields {
+			parts = append(parts, "")
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		rows = append(rows, parts)
+	}
+	return rows
+}
+
+// parseDpkgPackages interpreta o dpkg-query; só pacotes instalados ("ii")
+// entram. O dpkg não guarda a data de instalação.
+func parseDpkgPackages(output []byte) []Application {
+	var apps []Application
+	for _, row := range splitPackageLines(output, 4) {
+		if !strings.HasPrefix(row[0], "ii") || row[1] == "" {
+			continue
+		}
+		apps = append(apps, Application{
+			Name:    row[1],
+			Version: row[2],
+			Vendor:  row[3],
+			Path:    "dpkg:" + row[1],
+		})
+	}
+	return apps
+}
+
+// parseRPMPackages interpreta o rpm -qa; INSTALLTIME vem em segundos Unix
+func parseRPMPackages(output []byte) []Application {
+	var apps []Application
+	for _, row := range splitPackageLines(output, 5) {
+		if row[0] == "" {
+			continue
+		}
+		app := Application{
+			Name:    row[0],
+			Version: row[1],
+			Path:    "rpm:" + row[0],
+		}
+		if row[2] != "(none)" {
+			app.Vendor = row[2]
+		}
+		if seconds, err := strconv.ParseInt(row[3], 10, 64); err == nil && seconds > 0 {
+			app.InstallDate = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		}
+		// Pacotes multiarch (ex.: glibc x86_64 e i686) aparecem uma vez por arquitetura
+		if row[4] != "" && row[4] != "(none)" {
+			app.Path += "." + row[4]
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// parseFlatpakApps interpreta o flatpak list; o remoto de origem vai em Vendor
+func parseFlatpakApps(output []byte) []Application {
+	var apps []Application
+	for _, row := range splitPackageLines(output, 4) {
+		if row[0] == "" {
+			continue
+		}
+		name := row[1]
+		if name == "" {
+			name = row[0]
+		}
+		apps = append(apps, Application{
+			Name:    name,
+			Version: row[2],
+			Vendor:  row[3],
+			Path:    "flatpak:" + row[0],
+		})
+	}
+	return apps
+}
+
+// collectLinuxPackages consulta os gerenciadores de pacotes presentes. A
+// falha de um não impede os demais; gerenciadores ausentes são ignorados.
+func (c *SystemCollector) collectLinuxPackages(ctx context.Context) []Application {
+	var apps []Application
+	for _, source := range linuxPackageSources {
+		output, err := c.runProbe(ctx, source.name, source.args...)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				continue
+			}
+			c.logger.WithFields(map[string]interface{}{
+				"source": source.name,
+				"error":  err,
+			}).Warning("Failed to list installed packages")
+			continue
+		}
+		apps = append(apps, source.parse(output)...)
+	}
+
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Path < apps[j].Path })
+	return apps
+}
+
+// collectInstalledPackages é o collectInstalledApps do Linux: mesmo prazo
+// (AppScanTimeout), limite (MaxApplications) e cache da varredura do macOS
+func (c *SystemCollector) collectInstalledPackages(ctx context.Context, config *CollectorConfig) ([]Application, bool, error) {
+	scanCtx, cancel := context.WithTimeout(ctx, config.AppScanTimeout)
+	defer cancel()
+
+	apps := c.collectLinuxPackages(scanCtx)
+	if apps == nil {
+		apps = []Application{}
+	}
+	if len(apps) > config.MaxApplications {
+		apps = apps[:config.MaxApplications]
+	}
+
+	// Prazo estourado ou ctx do chamador cancelado: a lista está incompleta
+	if err := scanCtx.Err(); err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"collected": len(apps),
+			"timeout":   config.AppScanTimeout.String(),
+			"error":     err,
+		}).Warning("Installed packages listing cut short, returning partial result")
+		return apps, true, nil
+	}
+
+	// Cachear apenas resultados completos
+	c.setInCache(CacheKeyInstalledApps, apps, config.CacheExpiration)
+	return apps, false, nil
+}
@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePackageRunner responde aos gerenciadores de pacotes com saídas fixas;
+// os ausentes do mapa retornam exec.ErrNotFound
+type fakePackageRunner struct {
+	outputs map[string]string
+	errs    map[string]error
+	// block faz os comandos esperarem o ctx (gerenciador travado)
+	block bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *fakePackageRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, name)
+	r.mu.Unlock()
+
+	if r.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	output, ok := r.outputs[name]
+	if !ok {
+		return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+	}
+	return []byte(output), nil
+}
+
+const (
+	dpkgOutput = "ii \tbash\t5.2.15-2\tGNU Maintainers <bash@gnu.org>\n" +
+		"rc \told-lib\t1.0\tSomeone\n" +
+		"ii \tcurl\t7.88.1\tDebian <curl@debian.org>\r\n" +
+		"\n"
+	rpmOutput = "glibc\t2.34-60.el9\tRed Hat, Inc.\t1700000000\tx86_64\n" +
+		"glibc\t2.34-60.el9\tRed Hat, Inc.\t1700000000\ti686\n" +
+		"gpg-pubkey\tfd431d51-4ae0493b\t(none)\t0\t(none)\n"
+	flatpakOutput = "org.mozilla.firefox\tFirefox\t128.0\tflathub\n" +
+		"com.example.NoName\t\t1.0\tflathub\n"
+)
+
+func TestParseDpkgPackages(t *testing.T) {
+	want := []Application{
+		{Name: "bash", Version: "5.2.15-2", Vendor: "GNU Maintainers <bash@gnu.org>", Path: "dpkg:bash"},
+		{Name: "curl", Version: "7.88.1", Vendor: "Debian <curl@debian.org>", Path: "dpkg:curl"},
+	}
+	if got := parseDpkgPackages([]byte(dpkgOutput)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("dpkg packages = %+v", got)
+	}
+}
+
+func TestParseRPMPackages(t *testing.T) {
+	installed := time.Unix(1700000000, 0).UTC().Format(time.RFC3339)
+	want := []Application{
+		{Name: "glibc", Version: "2.34-60.el9", Vendor: "Red Hat, Inc.", InstallDate: installed, Path: "rpm:glibc.x86_64"},
+		{Name: "glibc", Version: "2.34-60.el9", Vendor: "Red Hat, Inc.", InstallDate: installed, Path: "rpm:glibc.i686"},
+		{Name: "gpg-pubkey", Version: "fd431d51-4ae0493b", Path: "rpm:gpg-pubkey"},
+	}
+	if got := parseRPMPackages([]byte(rpmOutput)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rpm packages = %+v", got)
+	}
+}
+
+func TestParseFlatpakApps(t *testing.T) {
+	want := []Application{
+		{Name: "Firefox", Version: "128.0", Vendor: "flathub", Path: "flatpak:org.mozilla.firefox"},
+		{Name: "com.example.NoName", Version: "1.0", Vendor: "flathub", Path: "flatpak:com.example.NoName"},
+	}
+	if got := parseFlatpakApps([]byte(flatpakOutput)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("flatpak apps = %+v", got)
+	}
+}
+
+// packageScanConfig é a configuração do collector para a listagem de pacotes
+func packageScanConfig(c *SystemCollector, timeout time.Duration) *CollectorConfig {
+	return appScanConfig(c, "", 1, timeout)
+}
+
+func TestCollectInstalledPackages(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(&fakePackageRunner{outputs: map[string]string{
+		"dpkg-query": dpkgOutput,
+		"flatpak":    flatpakOutput,
+	}})
+
+	apps, partial, err := c.collectInstalledPackages(context.Background(), packageScanConfig(c, time.Minute))
+	if err != nil || partial {
+		t.Fatalf("partial=%t, err=%v", partial, err)
+	}
+	var paths []string
+	for _, app := range apps {
+		paths = append(paths, app.Path)
+	}
+	want := "dpkg:bash dpkg:curl flatpak:com.example.NoName flatpak:org.mozilla.firefox"
+	if strings.Join(paths, " ") != want {
+		t.Fatalf("packages = %v, want %s (rpm missing, sorted by path)", paths, want)
+	}
+	if cached := c.getFromCache(CacheKeyInstalledApps); cached == nil {
+		t.Fatal("complete listing was not cached")
+	}
+}
+
+func TestCollectInstalledPackagesFailingManager(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(&fakePackageRunner{
+		outputs: map[string]string{"rpm": rpmOutput},
+		errs:    map[string]error{"dpkg-query": errors.New("exit status 2")},
+	})
+
+	apps, partial, err := c.collectInstalledPackages(context.Background(), packageScanConfig(c, time.Minute))
+	if err != nil || partial || len(apps) != 3 {
+		t.Fatalf("failing dpkg: %d apps, partial=%t, err=%v", len(apps), partial, err)
+	}
+}
+
+func TestCollectInstalledPackagesLimit(t *testing.T) {
+	var output strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&output, "ii \tpkg%02d\t1.0\tVendor\n", i)
+	}
+	c := newTestCollector(t)
+	c.SetCommandRunner(&fakePackageRunner{outputs: map[string]string{"dpkg-query": output.String()}})
+
+	config := packageScanConfig(c, time.Minute)
+	config.MaxApplications = 10
+	apps, partial, err := c.collectInstalledPackages(context.Background(), config)
+	if err != nil || partial || len(apps) != 10 {
+		t.Fatalf("limited listing: %d apps, partial=%t, err=%v", len(apps), partial, err)
+	}
+}
+
+func TestCollectInstalledPackagesCutShort(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     func() (context.Context, context.CancelFunc)
+		timeout time.Duration
+	}{
+		{
+			name:    "deadline",
+			ctx:     func() (context.Context, context.CancelFunc) { return context.Background(), func() {} },
+			timeout: 20 * time.Millisecond,
+		},
+		{
+			name: "cancelled parent",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			timeout: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t)
+			c.SetCommandRunner(&fakePackageRunner{block: true})
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			apps, partial, err := c.collectInstalledPackages(ctx, packageScanConfig(c, tt.timeout))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !partial || apps == nil {
+				t.Fatalf("listing cut short: partial=%t, apps=%v", partial, apps)
+			}
+			if cached := c.getFromCache(CacheKeyInstalledApps); cached != nil {
+				t.Fatal("listing cut short was cached")
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// Tipos de interface de rede (NetworkInterface.Type)
+const (
+	InterfaceEthernet = "ethernet"
+	InterfaceWiFi     = "wifi"
+	InterfaceLoopback = "loopback"
+	InterfaceVirtual  = "virtual"
+	InterfaceOther    = "other"
+)
+
+// Estados de interface de rede (NetworkInterface.Status)
+const (
+	InterfaceUp        = "up"
+	InterfaceDown      = "down"
+	InterfaceNoCarrier = "no_carrier" // ligada, mas sem link
+)
+
+// CacheKeyNetworkLinks é a chave de cache do tipo e velocidade das
+// interfaces do macOS, lidos do system_profiler
+const CacheKeyNetworkLinks = "network_links"
+
+// sysClassNet é onde o Linux expõe os atributos das interfaces
+const sysClassNet = "/sys/class/net"
+
+// interfaceLink é o tipo e a velocidade de uma interface segundo o sistema
+type interfaceLink struct {
+	Type  string
+	Speed uint64 // Mbps
+}
+
+// interfaceStatus deriva o estado das flags: sem "up" a interface está
+// desligada administrativamente; ligada e sem link (running false) fica
+// no_carrier. Loopback não tem link e conta como ligada.
+func interfaceStatus(flags []string, running bool) string {
+	switch {
+	case !hasFlag(flags, "up"):
+		return InterfaceDown
+	case running || hasFlag(flags, "loopback"):
+		return InterfaceUp
+	default:
+		return InterfaceNoCarrier
+	}
+}
+
+// runningInterfaces indica, por nome, as interfaces com link ativo. O
+// gopsutil não repassa a flag running, que vem do pacote net padrão; nil
+// se a leitura falhar.
+func runningInterfaces() map[string]bool {
+	interfaces, err := stdnet.Interfaces()
+	if err != nil {
+		return nil
+	}
+	running := make(map[string]bool, len(interfaces))
+	for _, iface := range interfaces {
+		running[iface.Name] = iface.Flags&stdnet.FlagRunning != 0
+	}
+	return running
+}
+
+// hasFlag indica se flags contém flag
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// virtualInterfacePrefixes são nomes de interfaces criadas por software
+// (containers, VMs, VPNs, pontes e interfaces internas do macOS)
+var virtualInterfacePrefixes = []string{
+	"docker", "veth", "br-", "virbr", "vmnet", "vboxnet", "vnet", "tun", "tap",
+	"utun", "wg", "tailscale", "zt", "bridge", "awdl", "llw", "gif", "stf",
+	"anpi", "ap", "ipsec", "ppp", "isatap", "teredo",
+}
+
+// wifiInterfaceName casa nomes comuns de interfaces sem fio no Linux e no
+// Windows ("wlan0", "wlp3s0", "Wi-Fi", "Wireless Network Connection")
+var wifiInterfaceName = regexp.MustCompile(`(?i)^(wlan|wlp|wlx|ath|wifi)|wi-fi|wireless`)
+
+// classifyInterface deduz o tipo pelo nome e pelas flags, quando o sistema
+// não informa o tipo diretamente
+func classifyInterface(name string, flags []string) string {
+	if hasFlag(flags, "loopback") {
+		return InterfaceLoopback
+	}
+	if wifiInterfaceName.MatchString(name) {
+		return InterfaceWiFi
+	}
+	lower := strings.ToLower(name)
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return InterfaceVirtual
+		}
+	}
+	if hasFlag(flags, "pointtopoint") {
+		return InterfaceVirtual
+	}
+	if strings.HasPrefix(lower, "en") || strings.HasPrefix(lower, "eth") || strings.Contains(lower, "ethernet") {
+		return InterfaceEthernet
+	}
+	return InterfaceOther
+}
+
+// interfaceLinks lê tipo e velocidade das interfaces onde o sistema os
+// expõe: sysfs no Linux e SPNetworkDataType no macOS. Interfaces ausentes
+// do resultado são classificadas por classifyInterface.
+func (c *SystemCollector) interfaceLinks(ctx context.Context, interfaces net.InterfaceStatList) map[string]interfaceLink {
+	switch runtime.GOOS {
+	case "linux":
+		links := make(map[string]interfaceLink, len(interfaces))
+		for _, iface := range interfaces {
+			links[iface.Name] = sysfsInterfaceLink(sysClassNet, iface.Name, iface.Flags)
+		}
+		return links
+	case "darwin":
+		if !collectsMacOSSpecific(c.configFor(ctx)) {
+			return nil
+		}
+		links, err := c.darwinInterfaceLinks(ctx)
+		if err != nil {
+			c.logger.WithField("error", err).Debug("Failed to read network hardware from system_profiler")
+		}
+		return links
+	}
+	return nil
+}
+
+// sysfsInterfaceLink lê os atributos de uma interface em root/<nome>. Só
+// interfaces com dispositivo físico (device) são ethernet ou wifi; o speed
+// vale -1 ou falha na leitura quando não há link.
+func sysfsInterfaceLink(root, name string, flags []string) interfaceLink {
+	dir := filepath.Join(root, name)
+	var link interfaceLink
+
+	switch {
+	case hasFlag(flags, "loopback"):
+		link.Type = InterfaceLoopback
+	case pathExists(filepath.Join(dir, "wireless")) || pathExists(filepath.Join(dir, "phy80211")):
+		link.Type = InterfaceWiFi
+	case !pathExists(filepath.Join(dir, "device")):
+		link.Type = InterfaceVirtual
+	default:
+		link.Type = InterfaceEthernet
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, "speed")); err == nil {
+		if speed, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && speed > 0 {
+			link.Speed = uint64(speed)
+		}
+	}
+	return link
+}
+
+// pathExists indica se o caminho existe
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// darwinInterfaceLinks consulta o system_profiler, em cache pelo
+// cache_expiration
+func (c *SystemCollector) darwinInterfaceLinks(ctx context.Context) (map[string]interfaceLink, error) {
+	if cached, ok := c.getFromCache(CacheKeyNetworkLinks).(map[string]interfaceLink); ok {
+		return cached, nil
+	}
+
+	output, err := c.runProbe(ctx, "system_profiler", "SPNetworkDataType", "-json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute system_profiler: %w", err)
+	}
+	links, err := parseSPNetwork(output)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setInCache(CacheKeyNetworkLinks, links, c.configFor(ctx).CacheExpiration)
+	return links, nil
+}
+
+// mediaSubTypeSpeed extrai a velocidade de "1000baseT", "10GbaseT" ou
+// "2500Base-T"
+var mediaSubTypeSpeed = regexp.MustCompile(`(?i)^(\d+)(g?)base`)
+
+// parseSPNetwork interpreta `system_profiler SPNetworkDataType -json`:
+// um serviço por interface, com o tipo ("AirPort", "Ethernet", "VPN"...) e,
+// em Ethernet, o MediaSubType negociado
+func parseSPNetwork(data []byte) (map[string]interfaceLink, error) {
+	var result struct {
+		SPNetworkDataType []struct {
+			Interface string `json:"interface"`
+			Type      string `json:"type"`
+			Hardware  string `json:"hardware"`
+			Ethernet  struct {
+				MediaSubType string `json:"MediaSubType"`
+			} `json:"Ethernet"`
+		} `json:"SPNetworkDataType"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse system_profiler output: %w", err)
+	}
+
+	links := make(map[string]interfaceLink)
+	for _, service := range result.SPNetworkDataType {
+		if service.Interface == "" {
+			continue
+		}
+		kind := service.Hardware
+		if kind == "" {
+			kind = service.Type
+		}
+
+		var link interfaceLink
+		switch strings.ToLower(kind) {
+		case "airport", "wi-fi":
+			link.Type = InterfaceWiFi
+		case "ethernet":
+			link.Type = InterfaceEthernet
+		default:
+			link.Type = InterfaceVirtual
+		}
+		if match := mediaSubTypeSpeed.FindStringSubmatch(service.Ethernet.MediaSubType); match != nil {
+			if speed, err := strconv.ParseUint(match[1], 10, 64); err == nil {
+				if match[2] != "" {
+					speed *= 1000
+				}
+				link.Speed = speed
+			}
+		}
+		links[service.Interface] = link
+	}
+	return links, nil
+}
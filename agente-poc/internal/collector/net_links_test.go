@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"context"
+	"io"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInterfaceStatus(t *testing.T) {
+	tests := []struct {
+		flags   []string
+		running bool
+		want    string
+	}{
+		{[]string{"up", "broadcast", "multicast"}, true, InterfaceUp},
+		{[]string{"up", "broadcast", "multicast"}, false, InterfaceNoCarrier},
+		{[]string{"broadcast", "multicast"}, false, InterfaceDown},
+		// Desligada administrativamente vence o link
+		{[]string{"broadcast"}, true, InterfaceDown},
+		{[]string{"up", "loopback"}, false, InterfaceUp},
+	}
+	for _, tt := range tests {
+		if got := interfaceStatus(tt.flags, tt.running); got != tt.want {
+			t.Errorf("interfaceStatus(%v, %t) = %s, want %s", tt.flags, tt.running, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyInterface(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{"lo", []string{"up", "loopback"}, InterfaceLoopback},
+		{"lo0", []string{"up", "loopback"}, InterfaceLoopback},
+		{"eth0", []string{"up", "broadcast"}, InterfaceEthernet},
+		{"enp3s0", nil, InterfaceEthernet},
+		{"en0", nil, InterfaceEthernet},
+		{"Ethernet 2", nil, InterfaceEthernet},
+		{"wlan0", nil, InterfaceWiFi},
+		{"wlp2s0", nil, InterfaceWiFi},
+		{"Wi-Fi", nil, InterfaceWiFi},
+		{"Wireless Network Connection", nil, InterfaceWiFi},
+		{"docker0", nil, InterfaceVirtual},
+		{"veth1a2b3c", nil, InterfaceVirtual},
+		{"utun3", nil, InterfaceVirtual},
+		{"wg0", nil, InterfaceVirtual},
+		{"awdl0", nil, InterfaceVirtual},
+		{"corp", []string{"up", "pointtopoint"}, InterfaceVirtual},
+		{"ib0", nil, InterfaceOther},
+	}
+	for _, tt := range tests {
+		if got := classifyInterface(tt.name, tt.flags); got != tt.want {
+			t.Errorf("classifyInterface(%q, %v) = %s, want %s", tt.name, tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestSysfsInterfaceLink(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("eth0/device/vendor", "0x8086\n")
+	write("eth0/speed", "1000\n")
+	write("wlan0/device/vendor", "0x8086\n")
+	write("wlan0/phy80211/name", "phy0\n")
+	write("wlan0/speed", "-1\n") // Wi-Fi não informa a velocidade
+	write("enp4s0/device/vendor", "0x10ec\n")
+	write("docker0/speed", "10000\n")
+	write("lo/speed", "")
+
+	tests := []struct {
+		name  string
+		flags []string
+		want  interfaceLink
+	}{
+		{"eth0", []string{"up"}, interfaceLink{Type: InterfaceEthernet, Speed: 1000}},
+		{"wlan0", []string{"up"}, interfaceLink{Type: InterfaceWiFi}},
+		// Sem cabo o speed não existe ou falha na leitura
+		{"enp4s0", []string{"up"}, interfaceLink{Type: InterfaceEthernet}},
+		{"docker0", []string{"up"}, interfaceLink{Type: InterfaceVirtual, Speed: 10000}},
+		{"lo", []string{"up", "loopback"}, interfaceLink{Type: InterfaceLoopback}},
+	}
+	for _, tt := range tests {
+		if got := sysfsInterfaceLink(root, tt.name, tt.flags); got != tt.want {
+			t.Errorf("sysfsInterfaceLink(%s) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSPNetwork(t *testing.T) {
+	links, err := parseSPNetwork(readFixture(t, "spnetwork.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interfaceLink{
+		"en0":     {Type: InterfaceEthernet, Speed: 1000},
+		"en5":     {Type: InterfaceEthernet, Speed: 10000},
+		"en1":     {Type: InterfaceWiFi},
+		"bridge0": {Type: InterfaceEthernet},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Fatalf("parseSPNetwork = %+v, want %+v", links, want)
+	}
+	if _, err := parseSPNetwork([]byte("not json")); err == nil {
+		t.Fatal("invalid output accepted")
+	}
+}
+
+// loopbackTraffic troca bytes pela interface de loopback, para que ela
+// tenha contadores diferentes das demais
+func loopbackTraffic(t *testing.T, size int) {
+	t.Helper()
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	received := make(chan int64, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- 0
+			return
+		}
+		defer conn.Close()
+		n, _ := io.Copy(io.Discard, conn)
+		received <- n
+	}()
+
+	conn, err := stdnet.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if n := <-received; n != int64(size) {
+		t.Fatalf("loopback received %d of %d bytes", n, size)
+	}
+}
+
+func TestCollectNetworkInfoPerInterfaceCounters(t *testing.T) {
+	loopbackTraffic(t, 1<<20)
+	c := newTestCollector(t)
+
+	info, err := c.collectNetworkInfoInternal(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loopback *NetworkInterface
+	var sent, recv uint64
+	distinct := make(map[[2]uint64]bool)
+	for i := range info.Interfaces {
+		iface := &info.Interfaces[i]
+		if iface.Type == InterfaceLoopback {
+			loopback = iface
+		}
+		if iface.Status == "" || iface.Type == "" {
+			t.Errorf("interface %s without status or type: %+v", iface.Name, iface)
+		}
+		sent += iface.BytesSent
+		recv += iface.BytesRecv
+		distinct[[2]uint64{iface.BytesSent, iface.BytesRecv}] = true
+	}
+
+	if loopback == nil {
+		t.Skip("no loopback interface listed")
+	}
+	if loopback.Status != InterfaceUp || loopback.BytesSent < 1<<20 {
+		t.Fatalf("loopback = %+v", loopback)
+	}
+	// Cada interface tem os próprios contadores, não o agregado repetido
+	if len(info.Interfaces) > 1 && len(distinct) == 1 {
+		t.Fatalf("every interface reports the same counters: %+v", info.Interfaces)
+	}
+	if info.Statistics.TotalBytesSent != sent || info.Statistics.TotalBytesRecv != recv {
+		t.Fatalf("totals %d/%d, sum of interfaces %d/%d", info.Statistics.TotalBytesSent, info.Statistics.TotalBytesRecv, sent, recv)
+	}
+}
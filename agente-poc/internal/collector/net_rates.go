@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// processIOIncludesNetwork indica se os contadores de I/O por processo do
+// gopsutil incluem o tráfego de rede. No Windows (GetProcessIoCounters) eles
+// somam arquivos, rede e dispositivos; no Linux read_bytes/write_bytes são só
+// disco e no macOS não existem, então os top talkers não são coletados.
+var processIOIncludesNetwork = runtime.GOOS == "windows"
+
+// byteCounters são os contadores acumulados de envio e recebimento (ou
+// escrita e leitura) de uma interface ou processo
+type byteCounters struct {
+	sent uint64
+	recv uint64
+}
+
+// counterRate é a taxa de um par de contadores desde a amostra anterior;
+// send/recv ficam nil na primeira amostra e quando os contadores voltaram
+type counterRate struct {
+	send  *float64
+	recv  *float64
+	reset bool
+}
+
+// netSample é a amostra anterior dos contadores de rede, para as taxas
+type netSample struct {
+	mu          sync.Mutex
+	at          time.Time
+	interfaces  map[string]byteCounters
+	processesAt time.Time
+	processes   map[ProcessKey]byteCounters
+}
+
+// rate calcula a taxa de current em relação a previous. Contadores menores
+// que os anteriores (reboot, interface recriada, estouro de 32 bits) são um
+// novo ponto de partida, nunca uma taxa negativa.
+func rate(previous byteCounters, known bool, current byteCounters, elapsed float64) counterRate {
+	if !known || elapsed <= 0 {
+		return counterRate{}
+	}
+	if current.sent < previous.sent || current.recv < previous.recv {
+		return counterRate{reset: true}
+	}
+	send := math.Round(float64(current.sent-previous.sent)/elapsed*100) / 100
+	recv := math.Round(float64(current.recv-previous.recv)/elapsed*100) / 100
+	return counterRate{send: &send, recv: &recv}
+}
+
+// elapsedSince retorna os segundos entre a amostra anterior e now (zero sem
+// amostra anterior)
+func elapsedSince(previous, now time.Time) float64 {
+	if previous.IsZero() {
+		return 0
+	}
+	return now.Sub(previous).Seconds()
+}
+
+// interfaceRates atualiza a amostra das interfaces e retorna a taxa de cada
+// uma desde a anterior
+func (s *netSample) interfaceRates(now time.Time, counters map[string]net.IOCountersStat) map[string]counterRate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := elapsedSince(s.at, now)
+	current := make(map[string]byteCounters, len(counters))
+	rates := make(map[string]counterRate, len(counters))
+	for name, stat := range counters {
+		sample := byteCounters{sent: stat.BytesSent, recv: stat.BytesRecv}
+		previous, known := s.interfaces[name]
+		rates[name] = rate(previous, known, sample, elapsed)
+		current[name] = sample
+	}
+	s.at = now
+	s.interfaces = current
+	return rates
+}
+
+// processRates troca a amostra dos processos e retorna a taxa dos que já
+// estavam na anterior; processos novos e encerrados ficam de fora
+func (s *netSample) processRates(now time.Time, counters map[ProcessKey]byteCounters) map[ProcessKey]counterRate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := elapsedSince(s.processesAt, now)
+	rates := make(map[ProcessKey]counterRate, len(counters))
+	for key, sample := range counters {
+		previous, known := s.processes[key]
+		if r := rate(previous, known, sample, elapsed); r.send != nil {
+			rates[key] = r
+		}
+	}
+	s.processesAt = now
+	s.processes = counters
+	return rates
+}
+
+// applyInterfaceRates preenche as taxas das interfaces a partir dos
+// contadores desta coleta
+func (c *SystemCollector) applyInterfaceRates(interfaces []NetworkInterface, counters map[string]net.IOCountersStat, now time.Time) {
+	rates := c.netSample.interfaceRates(now, counters)
+	for i := range interfaces {
+		r := rates[interfaces[i].Name]
+		interfaces[i].SendRate = r.send
+		interfaces[i].RecvRate = r.recv
+		interfaces[i].CountersReset = r.reset
+	}
+}
+
+// collectTopTalkers retorna os limit processos com mais tráfego desde a
+// coleta anterior, quando a plataforma tem contadores que incluem a rede
+func (c *SystemCollector) collectTopTalkers(ctx context.Context, limit int, now time.Time) []NetworkTalker {
+	if limit <= 0 || !processIOIncludesNetwork {
+		return nil
+	}
+
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		c.logger.WithField("error", err).Debug("Failed to list processes for network usage")
+		return nil
+	}
+
+	byKey := make(map[ProcessKey]*process.Process, len(procs))
+	counters := make(map[ProcessKey]byteCounters, len(procs))
+	for _, proc := range procs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Processos de outros usuários podem negar leitura; ficam de fora
+		stat, err := proc.IOCountersWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		createTime, err := proc.CreateTimeWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		key := ProcessKey{PID: proc.Pid, CreateTime: createTime}
+		byKey[key] = proc
+		counters[key] = byteCounters{sent: stat.WriteBytes, recv: stat.ReadBytes}
+	}
+
+	return c.topTalkers(ctx, c.netSample.processRates(now, counters), byKey, limit)
+}
+
+// topTalkers ordena as taxas por tráfego total e completa os limit maiores
+// com o nome do processo; processos sem tráfego no intervalo não entram
+func (c *SystemCollector) topTalkers(ctx context.Context, rates map[ProcessKey]counterRate, procs map[ProcessKey]*process.Process, limit int) []NetworkTalker {
+	type ranked struct {
+		key   ProcessKey
+		total float64
+	}
+	ranking := make([]ranked, 0, len(rates))
+	for key, r := range rates {
+		if total := *r.send + *r.recv; total > 0 {
+			ranking = append(ranking, ranked{key: key, total: total})
+		}
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].total != ranking[j].total {
+			return ranking[i].total > ranking[j].total
+		}
+		return ranking[i].key.PID < ranking[j].key.PID
+	})
+	if len(ranking) > limit {
+		ranking = ranking[:limit]
+	}
+
+	talkers := make([]NetworkTalker, 0, len(ranking))
+	for _, entry := range ranking {
+		r := rates[entry.key]
+		talker := NetworkTalker{PID: entry.key.PID, SendRate: *r.send, RecvRate: *r.recv}
+		if name, err := procs[entry.key].NameWithContext(ctx); err == nil {
+			talker.Name = name
+		}
+		talkers = append(talkers, talker)
+	}
+	return talkers
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ioCounters monta os contadores do gopsutil por interface
+func ioCounters(samples map[string][2]uint64) map[string]net.IOCountersStat {
+	counters := make(map[string]net.IOCountersStat, len(samples))
+	for name, sample := range samples {
+		counters[name] = net.IOCountersStat{Name: name, BytesSent: sample[0], BytesRecv: sample[1]}
+	}
+	return counters
+}
+
+func TestApplyInterfaceRates(t *testing.T) {
+	c := newTestCollector(t)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	interfaces := func() []NetworkInterface {
+		return []NetworkInterface{{Name: "eth0"}, {Name: "wlan0"}, {Name: "tun0"}}
+	}
+
+	// Primeira amostra: só o ponto de partida
+	first := interfaces()
+	c.applyInterfaceRates(first, ioCounters(map[string][2]uint64{
+		"eth0":  {1_000, 2_000},
+		"wlan0": {50_000, 80_000},
+	}), start)
+	for _, iface := range first {
+		if iface.SendRate != nil || iface.RecvRate != nil || iface.CountersReset {
+			t.Fatalf("%s has rates on the first sample: %+v", iface.Name, iface)
+		}
+	}
+
+	// Segunda amostra 10 s depois: wlan0 voltou (interface recriada) e tun0
+	// apareceu
+	second := interfaces()
+	c.applyInterfaceRates(second, ioCounters(map[string][2]uint64{
+		"eth0":  {11_000, 7_000},
+		"wlan0": {100, 200},
+		"tun0":  {5_000, 5_000},
+	}), start.Add(10*time.Second))
+
+	eth0, wlan0, tun0 := second[0], second[1], second[2]
+	if eth0.SendRate == nil || *eth0.SendRate != 1_000 || *eth0.RecvRate != 500 || eth0.CountersReset {
+		t.Fatalf("eth0 = %+v", eth0)
+	}
+	if wlan0.SendRate != nil || wlan0.RecvRate != nil || !wlan0.CountersReset {
+		t.Fatalf("wlan0 after a counter reset = %+v", wlan0)
+	}
+	if tun0.SendRate != nil || tun0.CountersReset {
+		t.Fatalf("new interface tun0 = %+v", tun0)
+	}
+
+	// A amostra do reset é o novo ponto de partida
+	third := interfaces()
+	c.applyInterfaceRates(third, ioCounters(map[string][2]uint64{
+		"eth0":  {11_000, 7_000},
+		"wlan0": {400, 1_700},
+		"tun0":  {5_004, 5_000},
+	}), start.Add(14*time.Second))
+	if r := third[1]; r.SendRate == nil || *r.SendRate != 75 || *r.RecvRate != 375 || r.CountersReset {
+		t.Fatalf("wlan0 after the new baseline = %+v", r)
+	}
+	if r := third[0]; r.SendRate == nil || *r.SendRate != 0 || *r.RecvRate != 0 {
+		t.Fatalf("idle eth0 = %+v", r)
+	}
+	if r := third[2]; r.SendRate == nil || *r.SendRate != 1 {
+		t.Fatalf("tun0 = %+v", r)
+	}
+}
+
+func TestRateRoundsAndIgnoresZeroInterval(t *testing.T) {
+	r := rate(byteCounters{sent: 0, recv: 0}, true, byteCounters{sent: 1_000, recv: 10}, 3)
+	if *r.send != 333.33 || *r.recv != 3.33 {
+		t.Fatalf("rate = %v/%v", *r.send, *r.recv)
+	}
+	// Duas coletas no mesmo instante não geram taxa
+	if r := rate(byteCounters{}, true, byteCounters{sent: 1}, 0); r.send != nil || r.reset {
+		t.Fatalf("zero interval rate = %+v", r)
+	}
+}
+
+func TestTopTalkers(t *testing.T) {
+	c := newTestCollector(t)
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	browser := ProcessKey{PID: 100, CreateTime: 1}
+	backup := ProcessKey{PID: 200, CreateTime: 1}
+	idle := ProcessKey{PID: 300, CreateTime: 1}
+	restarted := ProcessKey{PID: 400, CreateTime: 1}
+
+	c.netSample.processRates(start, map[ProcessKey]byteCounters{
+		browser:   {sent: 1_000, recv: 10_000},
+		backup:    {sent: 0, recv: 0},
+		idle:      {sent: 500, recv: 500},
+		restarted: {sent: 9_000, recv: 9_000},
+	})
+	// O PID 400 foi reaproveitado por outro processo (CreateTime novo) e o
+	// 500 apareceu: nenhum dos dois tem taxa no intervalo
+	rates := c.netSample.processRates(start.Add(10*time.Second), map[ProcessKey]byteCounters{
+		browser:                     {sent: 2_000, recv: 30_000},
+		backup:                      {sent: 500_000, recv: 0},
+		idle:                        {sent: 500, recv: 500},
+		{PID: 400, CreateTime: 2}:   {sent: 1, recv: 1},
+		{PID: 500, CreateTime: 100}: {sent: 9_999, recv: 0},
+	})
+	if len(rates) != 3 {
+		t.Fatalf("rates = %v", rates)
+	}
+
+	procs := map[ProcessKey]*process.Process{
+		browser: {Pid: browser.PID},
+		backup:  {Pid: backup.PID},
+		idle:    {Pid: idle.PID},
+	}
+	talkers := c.topTalkers(context.Background(), rates, procs, 5)
+	// Ordem por tráfego total; processo sem tráfego fica de fora
+	if len(talkers) != 2 || talkers[0].PID != 200 || talkers[0].SendRate != 50_000 ||
+		talkers[1].PID != 100 || talkers[1].SendRate != 100 || talkers[1].RecvRate != 2_000 {
+		t.Fatalf("talkers = %+v", talkers)
+	}
+	if talkers := c.topTalkers(context.Background(), rates, procs, 1); len(talkers) != 1 || talkers[0].PID != 200 {
+		t.Fatalf("top 1 = %+v", talkers)
+	}
+}
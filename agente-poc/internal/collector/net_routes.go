@@ -0,0 +1,409 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CacheKeyNetworkRouting é a chave de cache das rotas padrão e servidores DNS
+const CacheKeyNetworkRouting = "network_routing"
+
+// Arquivos de resolvedor do Linux; com o systemd-resolved o resolv.conf só
+// aponta o stub local e os servidores reais ficam no segundo arquivo
+const (
+	resolvConfPath         = "/etc/resolv.conf"
+	resolvedResolvConfPath = "/run/systemd/resolve/resolv.conf"
+	resolvedStubAddress    = "127.0.0.53"
+)
+
+// Route é uma rota padrão. No Windows Interface é o endereço da interface,
+// como no route print.
+type Route struct {
+	Gateway   string `json:"gateway,omitempty"` // vazio em rotas de enlace (VPNs ponto a ponto)
+	Interface string `json:"interface,omitempty"`
+	Metric    int    `json:"metric,omitempty"`
+}
+
+// DNSResolver é um conjunto de servidores DNS. Com Domains, só atende esses
+// domínios (ex.: DNS injetado por uma VPN)
+type DNSResolver struct {
+	Servers   []string `json:"servers"`
+	Interface string   `json:"interface,omitempty"`
+	Domains   []string `json:"domains,omitempty"`
+}
+
+// networkRouting é o resultado em cache: rotas padrão em ordem de
+// prioridade e resolvedores DNS
+type networkRouting struct {
+	routes    []Route
+	resolvers []DNSResolver
+}
+
+// applyNetworkRouting preenche as rotas e o DNS em info; a rota padrão é a
+// de maior prioridade e DNSServers junta os servidores de todos os
+// resolvedores, sem repetição
+func applyNetworkRouting(info *NetworkInfo, routing *networkRouting) {
+	info.AllRoutes = routing.routes
+	if len(routing.routes) > 0 {
+		info.DefaultRoute = routing.routes[0].Gateway
+		info.DefaultInterface = routing.routes[0].Interface
+	}
+
+	info.DNSResolvers = routing.resolvers
+	seen := make(map[string]bool)
+	for _, resolver := range routing.resolvers {
+		for _, server := range resolver.Servers {
+			if !seen[server] {
+				seen[server] = true
+				info.DNSServers = append(info.DNSServers, server)
+			}
+		}
+	}
+}
+
+// collectNetworkRouting lê rotas padrão e DNS pela fonte da plataforma, em
+// cache pelo cache_expiration. A falha de uma das fontes não descarta a outra.
+func (c *SystemCollector) collectNetworkRouting(ctx context.Context) *networkRouting {
+	if cached, ok := c.getFromCache(CacheKeyNetworkRouting).(*networkRouting); ok {
+		return cached
+	}
+
+	routing := &networkRouting{}
+	var routeErr, dnsErr error
+	switch runtime.GOOS {
+	case "linux":
+		routing.routes, routeErr = c.linuxDefaultRoutes(ctx)
+		routing.resolvers, dnsErr = linuxResolvers()
+	case "darwin":
+		routing.routes, routeErr = c.darwinDefaultRoutes(ctx)
+		routing.resolvers, dnsErr = c.darwinResolvers(ctx)
+	case "windows":
+		routing.routes, routeErr = c.windowsDefaultRoutes(ctx)
+		routing.resolvers, dnsErr = c.windowsResolvers(ctx)
+	}
+	if routeErr != nil {
+		c.logger.WithField("error", routeErr).Debug("Failed to collect default routes")
+	}
+	if dnsErr != nil {
+		c.logger.WithField("error", dnsErr).Debug("Failed to collect DNS servers")
+	}
+
+	c.setInCache(CacheKeyNetworkRouting, routing, c.configFor(ctx).CacheExpiration)
+	return routing
+}
+
+// linuxDefaultRoutes consulta `ip route show default`
+func (c *SystemCollector) linuxDefaultRoutes(ctx context.Context) ([]Route, error) {
+	output, err := c.runProbe(ctx, "ip", "route", "show", "default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute ip route: %w", err)
+	}
+	return parseIPRouteDefault(output), nil
+}
+
+// parseIPRouteDefault interpreta `ip route show default`, uma rota por
+// linha ("default via 192.168.1.1 dev eth0 proto dhcp metric 100"). Menor
+// métrica vem primeiro; sem métrica, o kernel usa 0.
+func parseIPRouteDefault(output []byte) []Route {
+	var routes []Route
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "default" {
+			continue
+		}
+		var route Route
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				route.Gateway = fields[i+1]
+			case "dev":
+				route.Interface = fields[i+1]
+			case "metric":
+				route.Metric, _ = strconv.Atoi(fields[i+1])
+			}
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Metric < routes[j].Metric })
+	return routes
+}
+
+// linuxResolvers lê o resolv.conf; se ele só aponta o stub do
+// systemd-resolved, lê os servidores reais (incluindo os de VPNs)
+func linuxResolvers() ([]DNSResolver, error) {
+	data, err := os.ReadFile(resolvConfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", resolvConfPath, err)
+	}
+	resolvers := parseResolvConf(data)
+	if len(resolvers) == 1 && len(resolvers[0].Servers) == 1 && resolvers[0].Servers[0] == resolvedStubAddress {
+		if upstream, err := os.ReadFile(resolvedResolvConfPath); err == nil {
+			if parsed := parseResolvConf(upstream); len(parsed) > 0 {
+				return parsed, nil
+			}
+		}
+	}
+	return resolvers, nil
+}
+
+// parseResolvConf extrai os nameserver do resolv.conf como um resolvedor
+// geral; comentários (# ou ;) são ignorados
+func parseResolvConf(data []byte) []DNSResolver {
+	var servers []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+	return []DNSResolver{{Servers: servers}}
+}
+
+// darwinDefaultRoutes combina `route -n get default` (a rota escolhida pelo
+// sistema) com as demais rotas padrão de `netstat -rn -f inet`
+func (c *SystemCollector) darwinDefaultRoutes(ctx context.Context) ([]Route, error) {
+	output, err := c.runProbe(ctx, "route", "-n", "get", "default")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute route get: %w", err)
+	}
+	var routes []Route
+	if primary, ok := parseRouteGet(output); ok {
+		routes = append(routes, primary)
+	}
+
+	if output, err := c.runProbe(ctx, "netstat", "-rn", "-f", "inet"); err == nil {
+		for _, route := range parseNetstatDefaults(output) {
+			if len(routes) > 0 && route == routes[0] {
+				continue
+			}
+			routes = append(routes, route)
+		}
+	} else {
+		c.logger.WithField("error", err).Debug("Failed to list default routes with netstat")
+	}
+	return routes, nil
+}
+
+// parseRouteGet interpreta `route -n get default` (linhas "chave: valor")
+func parseRouteGet(output []byte) (Route, bool) {
+	var route Route
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "gateway":
+			route.Gateway = strings.TrimSpace(value)
+		case "interface":
+			route.Interface = strings.TrimSpace(value)
+		}
+	}
+	return route, route.Interface != ""
+}
+
+// parseNetstatDefaults extrai as rotas "default" de `netstat -rn -f inet`
+// (Destination Gateway Flags Netif ...); gateways "link#N" são rotas de
+// enlace, típicas de VPN, e ficam sem gateway
+func parseNetstatDefaults(output []byte) []Route {
+	var routes []Route
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "default" {
+			continue
+		}
+		route := Route{Gateway: fields[1], Interface: fields[3]}
+		if strings.HasPrefix(route.Gateway, "link#") {
+			route.Gateway = ""
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// darwinResolvers consulta `scutil --dns`
+func (c *SystemCollector) darwinResolvers(ctx context.Context) ([]DNSResolver, error) {
+	output, err := c.runProbe(ctx, "scutil", "--dns")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute scutil: %w", err)
+	}
+	return parseScutilDNS(output), nil
+}
+
+// scutilInterface extrai o nome em "if_index : 15 (utun3)"
+var scutilInterface = regexp.MustCompile(`\(([^)]+)\)`)
+
+// parseScutilDNS interpreta `scutil --dns`. Resolvedores sem nameserver
+// (mDNS) são ignorados; o mesmo resolvedor aparece nas seções geral e
+// "for scoped queries" e entra uma vez só. Resolvedores com domain são os
+// restritos a um domínio, como os injetados por VPNs.
+func parseScutilDNS(output []byte) []DNSResolver {
+	var resolvers []DNSResolver
+	seen := make(map[string]bool)
+	var current *DNSResolver
+	flush := func() {
+		if current == nil || len(current.Servers) == 0 {
+			current = nil
+			return
+		}
+		key := current.Interface + "|" + strings.Join(current.Domains, ",") + "|" + strings.Join(current.Servers, ",")
+		if !seen[key] {
+			seen[key] = true
+			resolvers = append(resolvers, *current)
+		}
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "resolver #") {
+			flush()
+			current = &DNSResolver{}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "DNS configuration") {
+			flush()
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(key, "nameserver["):
+			current.Servers = append(current.Servers, value)
+		case key == "domain":
+			current.Domains = append(current.Domains, value)
+		case key == "if_index":
+			if match := scutilInterface.FindStringSubmatch(value); match != nil {
+				current.Interface = match[1]
+			}
+		}
+	}
+	flush()
+	return resolvers
+}
+
+// windowsDefaultRoutes consulta `route print -4 0.0.0.0`
+func (c *SystemCollector) windowsDefaultRoutes(ctx context.Context) ([]Route, error) {
+	output, err := c.runProbe(ctx, "route", "print", "-4", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute route print: %w", err)
+	}
+	return parseRoutePrint(output), nil
+}
+
+// parseRoutePrint extrai as rotas 0.0.0.0/0 da seção "Active Routes" do
+// route print (Network Destination, Netmask, Gateway, Interface, Metric).
+// A métrica já soma a da interface; menor vem primeiro.
+func parseRoutePrint(output []byte) []Route {
+	var routes []Route
+	active := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Active Routes"):
+			active = true
+			continue
+		case strings.HasPrefix(line, "Persistent Routes"):
+			active = false
+			continue
+		}
+		fields := strings.Fields(line)
+		if !active || len(fields) != 5 || fields[0] != "0.0.0.0" || fields[1] != "0.0.0.0" {
+			continue
+		}
+		route := Route{Gateway: fields[2], Interface: fields[3]}
+		if strings.EqualFold(route.Gateway, "On-link") {
+			route.Gateway = ""
+		}
+		route.Metric, _ = strconv.Atoi(fields[4])
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Metric < routes[j].Metric })
+	return routes
+}
+
+// windowsDNSCommand lista os servidores DNS por interface em JSON
+const windowsDNSCommand = "Get-DnsClientServerAddress | Where-Object { $_.ServerAddresses } | " +
+	"Select-Object InterfaceAlias,ServerAddresses | ConvertTo-Json -Compress"
+
+// windowsResolvers consulta Get-DnsClientServerAddress pelo PowerShell
+func (c *SystemCollector) windowsResolvers(ctx context.Context) ([]DNSResolver, error) {
+	output, err := c.runProbe(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", windowsDNSCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute Get-DnsClientServerAddress: %w", err)
+	}
+	return parseDnsClientServerAddress(output)
+}
+
+// parseDnsClientServerAddress interpreta o JSON de Get-DnsClientServerAddress.
+// O ConvertTo-Json devolve um objeto quando há uma entrada só e uma lista
+// quando há várias; IPv4 e IPv6 da mesma interface vêm separados e são
+// juntados.
+func parseDnsClientServerAddress(output []byte) ([]DNSResolver, error) {
+	type entry struct {
+		InterfaceAlias  string   `json:"InterfaceAlias"`
+		ServerAddresses []string `json:"ServerAddresses"`
+	}
+
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, nil
+	}
+	var entries []entry
+	if output[0] == '{' {
+		var single entry
+		if err := json.Unmarshal(output, &single); err != nil {
+			return nil, fmt.Errorf("failed to parse Get-DnsClientServerAddress output: %w", err)
+		}
+		entries = []entry{single}
+	} else if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse Get-DnsClientServerAddress output: %w", err)
+	}
+
+	var resolvers []DNSResolver
+	index := make(map[string]int)
+	for _, e := range entries {
+		if len(e.ServerAddresses) == 0 {
+			continue
+		}
+		if i, ok := index[e.InterfaceAlias]; ok {
+			resolvers[i].Servers = append(resolvers[i].Servers, e.ServerAddresses...)
+			continue
+		}
+		index[e.InterfaceAlias] = len(resolvers)
+		resolvers = append(resolvers, DNSResolver{
+			Servers:   append([]string(nil), e.ServerAddresses...),
+			Interface: e.InterfaceAlias,
+		})
+	}
+	return resolvers, nil
+}
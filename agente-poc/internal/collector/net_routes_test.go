@@ -0,0 +1,184 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseIPRouteDefault(t *testing.T) {
+	routes := parseIPRouteDefault(readFixture(t, "ip_route_default.txt"))
+	// Menor métrica primeiro; a rota da VPN é de enlace, sem gateway
+	want := []Route{
+		{Interface: "wg0", Metric: 50},
+		{Gateway: "10.0.0.1", Interface: "enp3s0", Metric: 100},
+		{Gateway: "192.168.1.1", Interface: "wlp2s0", Metric: 600},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("parseIPRouteDefault = %+v, want %+v", routes, want)
+	}
+	if routes := parseIPRouteDefault([]byte("10.0.0.0/8 via 10.0.0.1 dev eth0\n")); routes != nil {
+		t.Fatalf("non-default routes parsed: %+v", routes)
+	}
+}
+
+func TestParseResolvConf(t *testing.T) {
+	stub := parseResolvConf(readFixture(t, "resolv_stub.conf"))
+	if !reflect.DeepEqual(stub, []DNSResolver{{Servers: []string{resolvedStubAddress}}}) {
+		t.Fatalf("stub resolv.conf = %+v", stub)
+	}
+
+	upstream := parseResolvConf(readFixture(t, "resolv_upstream.conf"))
+	want := []DNSResolver{{Servers: []string{"10.8.0.1", "192.168.1.1", "2001:4860:4860::8888"}}}
+	if !reflect.DeepEqual(upstream, want) {
+		t.Fatalf("upstream resolv.conf = %+v, want %+v", upstream, want)
+	}
+
+	if resolvers := parseResolvConf([]byte("# nameserver 1.1.1.1\n; nameserver 8.8.8.8\nsearch example.com\n")); resolvers != nil {
+		t.Fatalf("commented nameservers parsed: %+v", resolvers)
+	}
+}
+
+func TestParseDarwinRoutes(t *testing.T) {
+	primary, ok := parseRouteGet(readFixture(t, "route_get_default.txt"))
+	if !ok || primary != (Route{Gateway: "192.168.1.1", Interface: "en0"}) {
+		t.Fatalf("parseRouteGet = %+v, %t", primary, ok)
+	}
+	if _, ok := parseRouteGet([]byte("route: writing to routing socket: not in table\n")); ok {
+		t.Fatal("route without a default accepted")
+	}
+
+	routes := parseNetstatDefaults(readFixture(t, "netstat_rn.txt"))
+	want := []Route{
+		{Gateway: "192.168.1.1", Interface: "en0"},
+		{Interface: "utun3"},
+		{Gateway: "10.0.0.1", Interface: "en7"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("parseNetstatDefaults = %+v, want %+v", routes, want)
+	}
+}
+
+func TestDarwinDefaultRoutes(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		"route -n get default": readFixture(t, "route_get_default.txt"),
+		"netstat -rn -f inet":  readFixture(t, "netstat_rn.txt"),
+	}))
+
+	// A rota escolhida pelo sistema vem primeiro e não se repete
+	routes, err := c.darwinDefaultRoutes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Route{
+		{Gateway: "192.168.1.1", Interface: "en0"},
+		{Interface: "utun3"},
+		{Gateway: "10.0.0.1", Interface: "en7"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("darwinDefaultRoutes = %+v, want %+v", routes, want)
+	}
+}
+
+func TestParseScutilDNS(t *testing.T) {
+	resolvers := parseScutilDNS(readFixture(t, "scutil_dns.txt"))
+	// O mDNS sem nameserver é ignorado e as seções repetidas entram uma vez
+	want := []DNSResolver{
+		{Servers: []string{"10.8.0.1"}, Interface: "utun3"},
+		{Servers: []string{"10.8.0.1", "10.8.0.2"}, Interface: "utun3", Domains: []string{"corp.example.com"}},
+		{Servers: []string{"192.168.1.1"}, Interface: "en0"},
+	}
+	if !reflect.DeepEqual(resolvers, want) {
+		t.Fatalf("parseScutilDNS = %+v, want %+v", resolvers, want)
+	}
+}
+
+func TestParseWindowsRouting(t *testing.T) {
+	routes := parseRoutePrint(readFixture(t, "route_print.txt"))
+	// Só as rotas ativas; On-link é rota de enlace, sem gateway
+	want := []Route{
+		{Interface: "10.8.0.14", Metric: 5},
+		{Gateway: "192.168.1.1", Interface: "192.168.1.42", Metric: 35},
+		{Gateway: "172.20.0.1", Interface: "172.20.5.17", Metric: 271},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Fatalf("parseRoutePrint = %+v, want %+v", routes, want)
+	}
+
+	resolvers, err := parseDnsClientServerAddress(readFixture(t, "dns_client_server_address.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IPv4 e IPv6 da mesma interface são juntados
+	wantResolvers := []DNSResolver{
+		{Servers: []string{"192.168.1.1", "fe80::1"}, Interface: "Wi-Fi"},
+		{Servers: []string{"10.8.0.1", "10.8.0.2"}, Interface: "Corporate VPN"},
+	}
+	if !reflect.DeepEqual(resolvers, wantResolvers) {
+		t.Fatalf("parseDnsClientServerAddress = %+v, want %+v", resolvers, wantResolvers)
+	}
+
+	// Com uma entrada só o ConvertTo-Json devolve um objeto
+	single, err := parseDnsClientServerAddress([]byte(`{"InterfaceAlias":"Ethernet","ServerAddresses":["10.0.0.2"]}` + "\r\n"))
+	if err != nil || !reflect.DeepEqual(single, []DNSResolver{{Servers: []string{"10.0.0.2"}, Interface: "Ethernet"}}) {
+		t.Fatalf("single entry = %+v, %v", single, err)
+	}
+	if resolvers, err := parseDnsClientServerAddress(nil); err != nil || resolvers != nil {
+		t.Fatalf("empty output = %+v, %v", resolvers, err)
+	}
+	if _, err := parseDnsClientServerAddress([]byte("Get-DnsClientServerAddress : not recognized")); err == nil {
+		t.Fatal("PowerShell error accepted")
+	}
+}
+
+func TestApplyNetworkRouting(t *testing.T) {
+	var info NetworkInfo
+	applyNetworkRouting(&info, &networkRouting{
+		routes: parseIPRouteDefault(readFixture(t, "ip_route_default.txt")),
+		resolvers: []DNSResolver{
+			{Servers: []string{"10.8.0.1"}, Interface: "utun3"},
+			{Servers: []string{"10.8.0.1", "10.8.0.2"}, Interface: "utun3", Domains: []string{"corp.example.com"}},
+			{Servers: []string{"192.168.1.1"}, Interface: "en0"},
+		},
+	})
+
+	if info.DefaultRoute != "" || info.DefaultInterface != "wg0" || len(info.AllRoutes) != 3 {
+		t.Fatalf("default route %q via %q, %d routes", info.DefaultRoute, info.DefaultInterface, len(info.AllRoutes))
+	}
+	if want := []string{"10.8.0.1", "10.8.0.2", "192.168.1.1"}; !reflect.DeepEqual(info.DNSServers, want) {
+		t.Fatalf("DNSServers = %v, want %v", info.DNSServers, want)
+	}
+
+	var empty NetworkInfo
+	applyNetworkRouting(&empty, &networkRouting{})
+	if empty.DefaultRoute != "" || empty.DNSServers != nil {
+		t.Fatalf("empty routing = %+v", empty)
+	}
+}
+
+func TestCollectNetworkRoutingCached(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads the Linux route command")
+	}
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{"ip route show default": readFixture(t, "ip_route_default.txt")})
+	c.SetCommandRunner(runner)
+
+	for i := 0; i < 3; i++ {
+		routing := c.collectNetworkRouting(context.Background())
+		if len(routing.routes) != 3 || routing.routes[0].Interface != "wg0" {
+			t.Fatalf("routes = %+v", routing.routes)
+		}
+	}
+	if calls := runner.snapshot(); calls["ip route show default"] != 1 {
+		t.Fatalf("ip route calls = %v, want 1 within cache_expiration", calls)
+	}
+
+	c.ClearCache()
+	c.collectNetworkRouting(context.Background())
+	if calls := runner.snapshot(); calls["ip route show default"] != 1 {
+		t.Fatalf("ip route calls after the cache was cleared = %v", calls)
+	}
+}
@@ -0,0 +1,504 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"sort"
+)
+
+// Seções e sub-coleções do inventário conhecidas pelo planner. Os nomes
+// seguem o caminho no JSON do inventário.
+const (
+	PlanSectionSystem            = "system"
+	PlanSectionHardware          = "hardware"
+	PlanSectionNetwork           = "network"
+	PlanSectionApplications      = "software.installed_applications"
+	PlanSectionServices          = "software.running_services"
+	PlanSectionProcesses         = "software.running_processes"
+	PlanSectionUpdates           = "software.system_updates"
+	PlanSectionMacOSHardware     = "macos_specific.hardware"
+	PlanSectionLaunchdServices   = "macos_specific.launchd_services"
+	PlanSectionHomebrew          = "macos_specific.homebrew"
+	PlanSectionMacOSPolicies     = "macos_specific.policies"
+	PlanSectionTimeMachine       = "macos_specific.time_machine"
+	PlanSectionSystemProfilerRaw = "macos_specific.system_profiler_raw"
+	PlanSectionWindowsPolicies   = "windows_specific.policies"
+	PlanSectionAccounts          = "accounts"
+	PlanSectionCertificates      = "certificates"
+	PlanSectionSecurityPosture   = "security_posture"
+	PlanSectionVirtualization    = "virtualization"
+	PlanSectionCustom            = "custom"
+)
+
+// PriorityRequired marca seções que nunca são descartadas
+const PriorityRequired = 100
+
+// Restrições que podem causar o descarte de uma seção
+const (
+	PlanConstraintSizeCap     = "size_cap"
+	PlanConstraintBudget      = "bandwidth_budget"
+	PlanConstraintMinPriority = "min_priority"
+)
+
+// SectionPolicy é a importância de uma seção: Priority de 0 a 100 (maior
+// sobrevive por mais tempo; 100 é obrigatória) e Cost, o tamanho aproximado
+// em bytes usado quando a seção ainda não foi coletada
+type SectionPolicy struct {
+	Priority int   `json:"priority"`
+	Cost     int64 `json:"cost"`
+}
+
+// PlanConfig é a configuração por site do planner: limite de tamanho do
+// inventário e prioridades/custos que substituem os padrões
+type PlanConfig struct {
+	MaxBytes int64                    `json:"max_bytes"`
+	Sections map[string]SectionPolicy `json:"sections,omitempty"`
+}
+
+// PlanConstraints são as restrições vigentes para um inventário. Todo
+// mecanismo que corta o inventário (limite de tamanho, orçamento de banda,
+// intervalo adaptativo) se expressa aqui em vez de decidir sozinho.
+type PlanConstraints struct {
+	// MaxBytes limita o tamanho do inventário serializado (0 = sem limite)
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// BudgetBytes é o orçamento de banda restante (nil = sem orçamento)
+	BudgetBytes *int64 `json:"budget_bytes,omitempty"`
+	// MinPriority descarta seções abaixo dessa prioridade (0 = todas entram)
+	MinPriority int `json:"min_priority,omitempty"`
+}
+
+// PlanDrop é uma seção descartada e a restrição que causou o descarte
+type PlanDrop struct {
+	Section    string `json:"section"`
+	Priority   int    `json:"priority"`
+	Bytes      int64  `json:"bytes"`
+	Constraint string `json:"constraint"`
+}
+
+// InventoryPlan é a decisão do planner, registrada no próprio inventário
+type InventoryPlan struct {
+	Included    []string        `json:"included"`
+	Dropped     []PlanDrop      `json:"dropped,omitempty"`
+	Constraints PlanConstraints `json:"constraints"`
+	// Bytes é o tamanho estimado do inventário após os descartes; OverLimit
+	// indica que nem só as seções obrigatórias cabem no limite
+	Bytes     int64 `json:"bytes"`
+	OverLimit bool  `json:"over_limit,omitempty"`
+}
+
+// planSection descreve como medir e descartar uma seção do inventário
+type planSection struct {
+	name     string
+	platform string // vazio = todas; senão o runtime.GOOS onde a seção existe
+	defaults SectionPolicy
+	value    func(*InventoryData) interface{} // nil quando a seção não foi coletada
+	drop     func(*InventoryData)
+}
+
+// planSections são as seções na ordem do inventário, com os padrões de
+// prioridade e custo aproximado
+var planSections = []planSection{
+	{
+		name:     PlanSectionSystem,
+		defaults: SectionPolicy{Priority: PriorityRequired, Cost: 2 * 1024},
+		value:    func(d *InventoryData) interface{} { return d.System },
+	},
+	{
+		name:     PlanSectionHardware,
+		defaults: SectionPolicy{Priority: PriorityRequired, Cost: 4 * 1024},
+		value:    func(d *InventoryData) interface{} { return d.Hardware },
+	},
+	{
+		name:     PlanSectionNetwork,
+		defaults: SectionPolicy{Priority: 90, Cost: 4 * 1024},
+		value:    func(d *InventoryData) interface{} { return d.Network },
+		drop:     func(d *InventoryData) { d.Network = NetworkInfo{} },
+	},
+	{
+		name:     PlanSectionApplications,
+		defaults: SectionPolicy{Priority: 70, Cost: 60 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if len(d.Software.InstalledApplications) == 0 {
+				return nil
+			}
+			return d.Software.InstalledApplications
+		},
+		drop: func(d *InventoryData) { d.Software.InstalledApplications = nil },
+	},
+	{
+		name:     PlanSectionServices,
+		defaults: SectionPolicy{Priority: 50, Cost: 20 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if len(d.Software.RunningServices) == 0 {
+				return nil
+			}
+			return d.Software.RunningServices
+		},
+		drop: func(d *InventoryData) { d.Software.RunningServices = nil },
+	},
+	{
+		name:     PlanSectionProcesses,
+		defaults: SectionPolicy{Priority: 20, Cost: 30 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if len(d.Software.RunningProcesses) == 0 {
+				return nil
+			}
+			return d.Software.RunningProcesses
+		},
+		drop: func(d *InventoryData) { d.Software.RunningProcesses = nil },
+	},
+	{
+		name:     PlanSectionUpdates,
+		defaults: SectionPolicy{Priority: 60, Cost: 4 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if len(d.Software.SystemUpdates) == 0 {
+				return nil
+			}
+			return d.Software.SystemUpdates
+		},
+		drop: func(d *InventoryData) { d.Software.SystemUpdates = nil },
+	},
+	{
+		name:     PlanSectionMacOSHardware,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 55, Cost: 3 * 1024},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if m.Hardware == nil {
+				return nil
+			}
+			return m.Hardware
+		}),
+		drop: func(d *InventoryData) { d.MacOSSpecific.Hardware = nil },
+	},
+	{
+		name:     PlanSectionLaunchdServices,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 30, Cost: 40 * 1024},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if len(m.LaunchdServices) == 0 {
+				return nil
+			}
+			return m.LaunchdServices
+		}),
+		drop: func(d *InventoryData) { d.MacOSSpecific.LaunchdServices = nil },
+	},
+	{
+		name:     PlanSectionHomebrew,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 40, Cost: 20 * 1024},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if m.Homebrew == nil {
+				return nil
+			}
+			return m.Homebrew
+		}),
+		drop: func(d *InventoryData) { d.MacOSSpecific.Homebrew = nil },
+	},
+	{
+		name:     PlanSectionMacOSPolicies,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 80, Cost: 10 * 1024},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if m.Policies == nil {
+				return nil
+			}
+			return m.Policies
+		}),
+		drop: func(d *InventoryData) { d.MacOSSpecific.Policies = nil },
+	},
+	{
+		name:     PlanSectionTimeMachine,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 60, Cost: 512},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if m.TimeMachine == nil {
+				return nil
+			}
+			return m.TimeMachine
+		}),
+		drop: func(d *InventoryData) { d.MacOSSpecific.TimeMachine = nil },
+	},
+	{
+		name:     PlanSectionSystemProfilerRaw,
+		platform: "darwin",
+		defaults: SectionPolicy{Priority: 0, Cost: defaultMaxSystemProfilerRawBytes},
+		value: macOSValue(func(m *MacOSInfo) interface{} {
+			if m.SystemProfilerRaw == "" {
+				return nil
+			}
+			return m.SystemProfilerRaw
+		}),
+		drop: func(d *InventoryData) {
+			d.MacOSSpecific.SystemProfilerRaw = ""
+			d.MacOSSpecific.SystemProfilerRawTruncated = false
+		},
+	},
+	{
+		name:     PlanSectionWindowsPolicies,
+		platform: "windows",
+		defaults: SectionPolicy{Priority: 80, Cost: 10 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if d.WindowsSpecific == nil || d.WindowsSpecific.Policies == nil {
+				return nil
+			}
+			return d.WindowsSpecific.Policies
+		},
+		drop: func(d *InventoryData) { d.WindowsSpecific.Policies = nil },
+	},
+	{
+		name:     PlanSectionAccounts,
+		defaults: SectionPolicy{Priority: 45, Cost: 8 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if d.Accounts == nil {
+				return nil
+			}
+			return d.Accounts
+		},
+		drop: func(d *InventoryData) { d.Accounts = nil },
+	},
+	{
+		name:     PlanSectionCertificates,
+		defaults: SectionPolicy{Priority: 65, Cost: 2 * 1024},
+		value: func(d *InventoryData) interface{} {
+			if d.Certificates == nil {
+				return nil
+			}
+			return d.Certificates
+		},
+		drop: func(d *InventoryData) { d.Certificates = nil },
+	},
+	{
+		name:     PlanSectionSecurityPosture,
+		defaults: SectionPolicy{Priority: 75, Cost: 1024},
+		value: func(d *InventoryData) interface{} {
+			if d.SecurityPosture == nil {
+				return nil
+			}
+			return d.SecurityPosture
+		},
+		drop: func(d *InventoryData) { d.SecurityPosture = nil },
+	},
+	{
+		name:     PlanSectionVirtualization,
+		defaults: SectionPolicy{Priority: 70, Cost: 512},
+		value: func(d *InventoryData) interface{} {
+			if d.Virtualization == nil {
+				return nil
+			}
+			return d.Virtualization
+		},
+		drop: func(d *InventoryData) { d.Virtualization = nil },
+	},
+	{
+		name:     PlanSectionCustom,
+		defaults: SectionPolicy{Priority: 80, Cost: 1024},
+		value: func(d *InventoryData) interface{} {
+			if d.Custom == nil {
+				return nil
+			}
+			return d.Custom
+		},
+		drop: func(d *InventoryData) { d.Custom = nil },
+	},
+}
+
+// macOSValue adapta uma sub-coleção de MacOSSpecific
+func macOSValue(value func(*MacOSInfo) interface{}) func(*InventoryData) interface{} {
+	return func(d *InventoryData) interface{} {
+		if d.MacOSSpecific == nil {
+			return nil
+		}
+		return value(d.MacOSSpecific)
+	}
+}
+
+// IsPlanSection indica se name é uma seção conhecida pelo planner
+func IsPlanSection(name string) bool {
+	for _, section := range planSections {
+		if section.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Planner decide quais seções entram no inventário dadas as restrições. É
+// o único lugar com a ordem de descarte; quem precisa cortar o inventário
+// informa a restrição e consulta o planner.
+type Planner struct {
+	policies map[string]SectionPolicy
+}
+
+// NewPlanner cria o planner com os padrões sobrepostos pelas políticas de
+// config (que pode ser nil)
+func NewPlanner(config *PlanConfig) (*Planner, error) {
+	policies := make(map[string]SectionPolicy, len(planSections))
+	for _, section := range planSections {
+		policies[section.name] = section.defaults
+	}
+	if config != nil {
+		if config.MaxBytes < 0 {
+			return nil, fmt.Errorf("max_bytes must not be negative")
+		}
+		for name, policy := range config.Sections {
+			if !IsPlanSection(name) {
+				return nil, fmt.Errorf("unknown inventory section %q", name)
+			}
+			if policy.Priority < 0 || policy.Priority > PriorityRequired {
+				return nil, fmt.Errorf("inventory section %q: priority must be between 0 and %d", name, PriorityRequired)
+			}
+			if policy.Cost < 0 {
+				return nil, fmt.Errorf("inventory section %q: cost must not be negative", name)
+			}
+			if policy.Cost == 0 {
+				policy.Cost = policies[name].Cost
+			}
+			policies[name] = policy
+		}
+	}
+	return &Planner{policies: policies}, nil
+}
+
+// Policy retorna a prioridade e o custo vigentes de uma seção
+func (p *Planner) Policy(name string) SectionPolicy {
+	return p.policies[name]
+}
+
+// Plan decide antes da coleta, pelos custos aproximados das seções desta
+// plataforma, quais caberiam nas restrições
+func (p *Planner) Plan(constraints PlanConstraints) *InventoryPlan {
+	sizes := make(map[string]int64, len(planSections))
+	for _, section := range planSections {
+		if section.platform != "" && section.platform != runtime.GOOS {
+			continue
+		}
+		sizes[section.name] = p.policies[section.name].Cost
+	}
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return p.decide(sizes, total, constraints)
+}
+
+// Apply mede as seções de data, descarta o que não cabe nas restrições e
+// registra a decisão em data.Plan
+func (p *Planner) Apply(data *InventoryData, constraints PlanConstraints) *InventoryPlan {
+	sizes := make(map[string]int64, len(planSections))
+	for _, section := range planSections {
+		if value := section.value(data); value != nil {
+			sizes[section.name] = jsonSize(value)
+		}
+	}
+
+	data.Plan = nil
+	plan := p.decide(sizes, jsonSize(data), constraints)
+	if len(plan.Dropped) > 0 {
+		dropped := make(map[string]bool, len(plan.Dropped))
+		for _, drop := range plan.Dropped {
+			dropped[drop.Section] = true
+		}
+		for _, section := range planSections {
+			if dropped[section.name] {
+				section.drop(data)
+			}
+		}
+		plan.Bytes = jsonSize(data)
+	}
+	data.Plan = plan
+	return plan
+}
+
+// decide aplica as restrições às seções presentes em sizes. Primeiro saem as
+// seções abaixo de MinPriority; depois, enquanto total exceder o limite, a
+// de menor prioridade (e, no empate, a maior).
+func (p *Planner) decide(sizes map[string]int64, total int64, constraints PlanConstraints) *InventoryPlan {
+	plan := &InventoryPlan{Constraints: constraints}
+
+	var candidates []string
+	for _, section := range planSections {
+		if _, ok := sizes[section.name]; !ok {
+			continue
+		}
+		if p.policies[section.name].Priority < PriorityRequired {
+			candidates = append(candidates, section.name)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := p.policies[candidates[i]].Priority, p.policies[candidates[j]].Priority
+		if pi != pj {
+			return pi < pj
+		}
+		return sizes[candidates[i]] > sizes[candidates[j]]
+	})
+
+	dropped := make(map[string]bool)
+	drop := func(name, constraint string) {
+		dropped[name] = true
+		total -= sizes[name]
+		plan.Dropped = append(plan.Dropped, PlanDrop{
+			Section:    name,
+			Priority:   p.policies[name].Priority,
+			Bytes:      sizes[name],
+			Constraint: constraint,
+		})
+	}
+
+	if constraints.MinPriority > 0 {
+		for _, name := range candidates {
+			if p.policies[name].Priority < constraints.MinPriority {
+				drop(name, PlanConstraintMinPriority)
+			}
+		}
+	}
+
+	limit, constraint := constraints.limit()
+	if limit >= 0 {
+		for _, name := range candidates {
+			if total <= limit {
+				break
+			}
+			if !dropped[name] {
+				drop(name, constraint)
+			}
+		}
+		plan.OverLimit = total > limit
+	}
+
+	for _, section := range planSections {
+		if _, ok := sizes[section.name]; ok && !dropped[section.name] {
+			plan.Included = append(plan.Included, section.name)
+		}
+	}
+	plan.Bytes = total
+	return plan
+}
+
+// limit retorna o menor limite de bytes vigente e a restrição que o impõe;
+// -1 quando não há limite
+func (c PlanConstraints) limit() (int64, string) {
+	limit, constraint := int64(-1), ""
+	if c.MaxBytes > 0 {
+		limit, constraint = c.MaxBytes, PlanConstraintSizeCap
+	}
+	if c.BudgetBytes != nil {
+		budget := *c.BudgetBytes
+		if budget < 0 {
+			budget = 0
+		}
+		if limit < 0 || budget < limit {
+			limit, constraint = budget, PlanConstraintBudget
+		}
+	}
+	return limit, constraint
+}
+
+// jsonSize é o tamanho de value serializado em JSON
+func jsonSize(value interface{}) int64 {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return int64(len(encoded))
+}
@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// planInventory monta um inventário com aplicações, serviços e processos de
+// tamanhos conhecidos
+func planInventory() *InventoryData {
+	data := &InventoryData{MachineID: "test-machine"}
+	data.System.Hostname = "test-host"
+	for i := 0; i < 50; i++ {
+		data.Software.InstalledApplications = append(data.Software.InstalledApplications,
+			Application{Name: fmt.Sprintf("App %d", i), Version: "1.0", Path: fmt.Sprintf("/Applications/App %d.app", i)})
+		data.Software.RunningProcesses = append(data.Software.RunningProcesses,
+			Process{PID: int32(i), Name: fmt.Sprintf("proc%d", i), Command: "/usr/bin/proc --flag", Status: "running"})
+	}
+	for i := 0; i < 10; i++ {
+		data.Software.RunningServices = append(data.Software.RunningServices, Service{Name: fmt.Sprintf("svc%d", i), Status: "running"})
+	}
+	return data
+}
+
+// dropped lista as seções descartadas com a restrição de cada uma
+func dropped(plan *InventoryPlan) []string {
+	var sections []string
+	for _, drop := range plan.Dropped {
+		sections = append(sections, drop.Section+":"+drop.Constraint)
+	}
+	return sections
+}
+
+func budget(bytes int64) *int64 {
+	return &bytes
+}
+
+func TestPlannerApplyConstraints(t *testing.T) {
+	planner, err := NewPlanner(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := jsonSize(planInventory())
+	processes := jsonSize(planInventory().Software.RunningProcesses)
+	services := jsonSize(planInventory().Software.RunningServices)
+
+	tests := []struct {
+		name        string
+		constraints PlanConstraints
+		want        []string
+		overLimit   bool
+	}{
+		{"no constraints", PlanConstraints{}, nil, false},
+		{"size cap fits", PlanConstraints{MaxBytes: full}, nil, false},
+		// Processos (20) saem antes de serviços (50) e aplicações (70)
+		{"size cap drops processes", PlanConstraints{MaxBytes: full - 1}, []string{PlanSectionProcesses + ":size_cap"}, false},
+		{"size cap drops two", PlanConstraints{MaxBytes: full - processes - 1}, []string{PlanSectionProcesses + ":size_cap", PlanSectionServices + ":size_cap"}, false},
+		{"budget below the cap", PlanConstraints{MaxBytes: full, BudgetBytes: budget(full - processes - services - 1)},
+			[]string{PlanSectionProcesses + ":bandwidth_budget", PlanSectionServices + ":bandwidth_budget", PlanSectionApplications + ":bandwidth_budget"}, false},
+		{"cap below the budget", PlanConstraints{MaxBytes: full - 1, BudgetBytes: budget(full)}, []string{PlanSectionProcesses + ":size_cap"}, false},
+		{"min priority", PlanConstraints{MinPriority: 60}, []string{PlanSectionProcesses + ":min_priority", PlanSectionServices + ":min_priority"}, false},
+		{"min priority then cap", PlanConstraints{MinPriority: 30, MaxBytes: full - processes - 1},
+			[]string{PlanSectionProcesses + ":min_priority", PlanSectionServices + ":size_cap"}, false},
+		// Sem orçamento, só as obrigatórias ficam, e ainda assim passam do limite
+		{"exhausted budget", PlanConstraints{BudgetBytes: budget(-10)},
+			[]string{PlanSectionProcesses + ":bandwidth_budget", PlanSectionServices + ":bandwidth_budget", PlanSectionApplications + ":bandwidth_budget", PlanSectionNetwork + ":bandwidth_budget"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := planInventory()
+			plan := planner.Apply(data, tt.constraints)
+
+			if got := dropped(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("dropped = %v, want %v", got, tt.want)
+			}
+			if plan.OverLimit != tt.overLimit {
+				t.Fatalf("over limit = %t", plan.OverLimit)
+			}
+			if data.Plan != plan || !reflect.DeepEqual(plan.Constraints, tt.constraints) {
+				t.Fatal("decision not recorded in the inventory")
+			}
+			for _, drop := range plan.Dropped {
+				for _, included := range plan.Included {
+					if included == drop.Section {
+						t.Fatalf("%s both included and dropped", drop.Section)
+					}
+				}
+			}
+
+			// O tamanho registrado é o do inventário sem as seções descartadas
+			data.Plan = nil
+			if size := jsonSize(data); plan.Bytes != size {
+				t.Fatalf("plan bytes = %d, inventory has %d", plan.Bytes, size)
+			}
+		})
+	}
+}
+
+func TestPlannerApplyDropsSections(t *testing.T) {
+	planner, err := NewPlanner(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := planInventory()
+	data.MacOSSpecific = &MacOSInfo{SystemProfilerRaw: `{"SPHardwareDataType":[]}`, SystemProfilerRawTruncated: true}
+	data.Accounts = &AccountsInfo{}
+
+	planner.Apply(data, PlanConstraints{MinPriority: 50})
+	if data.MacOSSpecific.SystemProfilerRaw != "" || data.MacOSSpecific.SystemProfilerRawTruncated || data.Accounts != nil {
+		t.Fatalf("low-priority sections kept: %+v %+v", data.MacOSSpecific, data.Accounts)
+	}
+	if data.Software.InstalledApplications == nil || data.Software.RunningServices == nil {
+		t.Fatal("sections at or above min_priority dropped")
+	}
+	if !reflect.DeepEqual(data.Plan.Included, []string{PlanSectionSystem, PlanSectionHardware, PlanSectionNetwork, PlanSectionApplications, PlanSectionServices}) {
+		t.Fatalf("included = %v", data.Plan.Included)
+	}
+}
+
+func TestPlannerConfigOverrides(t *testing.T) {
+	planner, err := NewPlanner(&PlanConfig{Sections: map[string]SectionPolicy{
+		PlanSectionProcesses: {Priority: PriorityRequired},
+		PlanSectionServices:  {Priority: 10, Cost: 999},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy := planner.Policy(PlanSectionProcesses); policy.Cost != 30*1024 {
+		t.Fatalf("zero cost did not keep the default: %+v", policy)
+	}
+
+	// Processos viraram obrigatórios; serviços passam a sair primeiro
+	data := planInventory()
+	plan := planner.Apply(data, PlanConstraints{MaxBytes: 1})
+	if got := dropped(plan); !reflect.DeepEqual(got, []string{
+		PlanSectionServices + ":size_cap", PlanSectionApplications + ":size_cap", PlanSectionNetwork + ":size_cap",
+	}) {
+		t.Fatalf("dropped = %v", got)
+	}
+	if data.Software.RunningProcesses == nil || !plan.OverLimit {
+		t.Fatal("required processes dropped")
+	}
+
+	for name, config := range map[string]*PlanConfig{
+		"unknown section":   {Sections: map[string]SectionPolicy{"software.drivers": {Priority: 10}}},
+		"priority too high": {Sections: map[string]SectionPolicy{PlanSectionNetwork: {Priority: 101}}},
+		"negative priority": {Sections: map[string]SectionPolicy{PlanSectionNetwork: {Priority: -1}}},
+		"negative cost":     {Sections: map[string]SectionPolicy{PlanSectionNetwork: {Cost: -1}}},
+		"negative max":      {MaxBytes: -1},
+	} {
+		if _, err := NewPlanner(config); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestPlannerPlanUsesCosts(t *testing.T) {
+	planner, err := NewPlanner(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Antes da coleta valem os custos aproximados das seções desta plataforma
+	plan := planner.Plan(PlanConstraints{})
+	var total int64
+	for _, name := range plan.Included {
+		total += planner.Policy(name).Cost
+	}
+	if total != plan.Bytes || len(plan.Dropped) != 0 {
+		t.Fatalf("plan = %+v, costs add up to %d", plan, total)
+	}
+	for _, name := range plan.Included {
+		if name == PlanSectionSystemProfilerRaw && runtime.GOOS != "darwin" || name == PlanSectionWindowsPolicies && runtime.GOOS != "windows" {
+			t.Fatalf("%s planned on %s", name, runtime.GOOS)
+		}
+	}
+
+	// Com pouca banda sai primeiro a seção de menor prioridade da plataforma
+	want := PlanSectionProcesses
+	if runtime.GOOS == "darwin" {
+		want = PlanSectionSystemProfilerRaw
+	}
+	plan = planner.Plan(PlanConstraints{BudgetBytes: budget(plan.Bytes - 1)})
+	if got := dropped(plan); !reflect.DeepEqual(got, []string{want + ":bandwidth_budget"}) {
+		t.Fatalf("dropped = %v", got)
+	}
+}
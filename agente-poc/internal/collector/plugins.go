@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CollectorPlugin é um coletor de terceiros registrado com Register. A saída
+// de Collect entra em InventoryData.Custom sob Name(); Interval é o tempo
+// mínimo entre execuções (zero roda a cada inventário), e entre elas o
+// inventário repete a última saída.
+//
+// Collect deve respeitar ctx: ele é cancelado no timeout do plugin
+// (DefaultPluginTimeout, ou o de PluginTimeout). Um plugin que falha, estoura
+// o timeout ou entra em panic não derruba a coleta; o erro aparece em
+// Custom[Name()] como {"error": "..."}.
+type CollectorPlugin interface {
+	Name() string
+	Collect(ctx context.Context) (json.RawMessage, error)
+	Interval() time.Duration
+}
+
+// PluginTimeout pode ser implementada por um CollectorPlugin para trocar
+// DefaultPluginTimeout
+type PluginTimeout interface {
+	Timeout() time.Duration
+}
+
+// DefaultPluginTimeout limita cada execução de um plugin sem PluginTimeout
+const DefaultPluginTimeout = 10 * time.Second
+
+// validPluginName restringe os nomes a chaves estáveis de JSON
+var validPluginName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = map[string]CollectorPlugin{}
+)
+
+// Register adiciona um plugin a todos os collectors; deve ser chamado antes
+// das coletas (tipicamente no init do pacote do plugin). Nomes repetidos ou
+// iguais aos de uma seção nativa são rejeitados.
+func Register(plugin CollectorPlugin) error {
+	if plugin == nil {
+		return fmt.Errorf("plugin must not be nil")
+	}
+	name := plugin.Name()
+	if err := ValidatePluginName(name); err != nil {
+		return err
+	}
+
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if _, exists := plugins[name]; exists {
+		return fmt.Errorf("plugin %q already registered", name)
+	}
+	plugins[name] = plugin
+	return nil
+}
+
+// ValidatePluginName confere o nome de um plugin: a-z, 0-9, "_", "." e "-",
+// sem repetir uma seção nativa
+func ValidatePluginName(name string) error {
+	if !validPluginName.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %q", name)
+	}
+	if isBuiltinSection(name) {
+		return fmt.Errorf("plugin name %q conflicts with a built-in section", name)
+	}
+	return nil
+}
+
+// SetPlugins troca os plugins próprios deste collector (ex.: os coletores
+// por script da configuração do agente), que rodam junto dos registrados
+// com Register. Vale a partir da próxima coleta.
+func (c *SystemCollector) SetPlugins(list []CollectorPlugin) error {
+	registered := RegisteredPlugins()
+	seen := make(map[string]bool, len(list))
+	for _, plugin := range list {
+		if plugin == nil {
+			return fmt.Errorf("plugin must not be nil")
+		}
+		name := plugin.Name()
+		if err := ValidatePluginName(name); err != nil {
+			return err
+		}
+		idx := sort.SearchStrings(registered, name)
+		if seen[name] || (idx < len(registered) && registered[idx] == name) {
+			return fmt.Errorf("plugin %q already registered", name)
+		}
+		seen[name] = true
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	config := *c.cfg()
+	config.Plugins = append([]CollectorPlugin(nil), list...)
+	c.config.Store(&config)
+	return nil
+}
+
+// RegisteredPlugins retorna os nomes dos plugins registrados, em ordem
+func RegisteredPlugins() []string {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginsFor junta os plugins registrados e os de config para a coleta atual
+func pluginsFor(config *CollectorConfig) []CollectorPlugin {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	list := make([]CollectorPlugin, 0, len(plugins)+len(config.Plugins))
+	for _, plugin := range plugins {
+		list = append(list, plugin)
+	}
+	return append(list, config.Plugins...)
+}
+
+// pluginResult é a última saída bem-sucedida de um plugin, reaproveitada até
+// vencer o Interval
+type pluginResult struct {
+	output json.RawMessage
+	at     time.Time
+}
+
+// pluginState guarda as últimas saídas dos plugins deste collector
+type pluginState struct {
+	mu      sync.Mutex
+	results map[string]pluginResult
+}
+
+// runIsolated executa collect com timeout (zero usa só o de ctx) e converte
+// panic em erro, para que uma seção ou plugin não derrube a coleta. Se
+// collect ignorar o cancelamento, a coleta segue sem esperar por ele.
+func runIsolated[T any](ctx context.Context, timeout time.Duration, collect func(context.Context) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		value T
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		var result outcome
+		defer func() {
+			if r := recover(); r != nil {
+				result.err = fmt.Errorf("panic: %v", r)
+			}
+			done <- result
+		}()
+		result.value, result.err = collect(ctx)
+	}()
+
+	select {
+	case result := <-done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// collectPlugins roda os plugins registrados em paralelo e junta as saídas
+// por nome; falhas viram {"error": "..."} na chave do plugin
+func (c *SystemCollector) collectPlugins(ctx context.Context) map[string]json.RawMessage {
+	list := pluginsFor(c.configFor(ctx))
+	if len(list) == 0 {
+		return nil
+	}
+
+	custom := make(map[string]json.RawMessage, len(list))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, plugin := range list {
+		wg.Add(1)
+		go func(plugin CollectorPlugin) {
+			defer wg.Done()
+			output := c.runPlugin(ctx, plugin)
+			mu.Lock()
+			custom[plugin.Name()] = output
+			mu.Unlock()
+		}(plugin)
+	}
+	wg.Wait()
+	return custom
+}
+
+// runPlugin executa um plugin, respeitando o Interval, e retorna a saída ou
+// o objeto de erro
+func (c *SystemCollector) runPlugin(ctx context.Context, plugin CollectorPlugin) json.RawMessage {
+	name := plugin.Name()
+	now := c.clock.Now()
+
+	c.plugins.mu.Lock()
+	last, ok := c.plugins.results[name]
+	c.plugins.mu.Unlock()
+	if ok && now.Sub(last.at) < plugin.Interval() {
+		return last.output
+	}
+
+	timeout := DefaultPluginTimeout
+	if custom, ok := plugin.(PluginTimeout); ok && custom.Timeout() > 0 {
+		timeout = custom.Timeout()
+	}
+
+	output, err := runIsolated(ctx, timeout, plugin.Collect)
+	if err == nil && !json.Valid(output) {
+		err = fmt.Errorf("plugin returned invalid JSON")
+	}
+	if err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"plugin": name,
+			"error":  err,
+		}).Warning("Collector plugin failed")
+		return pluginError(err)
+	}
+
+	c.plugins.mu.Lock()
+	if c.plugins.results == nil {
+		c.plugins.results = make(map[string]pluginResult)
+	}
+	c.plugins.results[name] = pluginResult{output: output, at: now}
+	c.plugins.mu.Unlock()
+	return output
+}
+
+// pluginError monta o objeto de erro publicado no lugar da saída
+func pluginError(err error) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return data
+}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// testPlugin é um CollectorPlugin com Collect configurável
+type testPlugin struct {
+	name     string
+	interval time.Duration
+	timeout  time.Duration
+	collect  func(ctx context.Context) (json.RawMessage, error)
+	calls    atomic.Int32
+}
+
+func (p *testPlugin) Name() string            { return p.name }
+func (p *testPlugin) Interval() time.Duration { return p.interval }
+func (p *testPlugin) Timeout() time.Duration  { return p.timeout }
+
+func (p *testPlugin) Collect(ctx context.Context) (json.RawMessage, error) {
+	p.calls.Add(1)
+	return p.collect(ctx)
+}
+
+// staticPlugin sempre retorna output
+func staticPlugin(name, output string) *testPlugin {
+	return &testPlugin{name: name, collect: func(context.Context) (json.RawMessage, error) {
+		return json.RawMessage(output), nil
+	}}
+}
+
+// registerForTest registra plugin globalmente e o remove no fim do teste
+func registerForTest(t *testing.T, plugin CollectorPlugin) {
+	t.Helper()
+	if err := Register(plugin); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pluginsMu.Lock()
+		delete(plugins, plugin.Name())
+		pluginsMu.Unlock()
+	})
+}
+
+// pluginErrorMessage lê o {"error": "..."} publicado no lugar da saída
+func pluginErrorMessage(t *testing.T, output json.RawMessage) string {
+	t.Helper()
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(output, &failure); err != nil {
+		t.Fatalf("output %s: %v", output, err)
+	}
+	return failure.Error
+}
+
+func TestCollectInventoryIsolatesPluginFailures(t *testing.T) {
+	// O plugin travado ignora o ctx; só é liberado no fim do teste
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(nil))
+	err := c.SetPlugins([]CollectorPlugin{
+		staticPlugin("asset_tags", `{"tag":"PAT-00042"}`),
+		&testPlugin{name: "failing", collect: func(context.Context) (json.RawMessage, error) {
+			return nil, errors.New("asset database unreachable")
+		}},
+		&testPlugin{name: "panicking", collect: func(context.Context) (json.RawMessage, error) {
+			var tags map[string]string
+			tags["x"] = "y"
+			return nil, nil
+		}},
+		&testPlugin{name: "stuck", timeout: 50 * time.Millisecond, collect: func(context.Context) (json.RawMessage, error) {
+			<-release
+			return json.RawMessage(`{}`), nil
+		}},
+		staticPlugin("invalid", `{"tag":`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := c.CollectInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inventory.System.Hostname == "" {
+		t.Fatal("built-in sections missing after plugin failures")
+	}
+	if len(inventory.Custom) != 5 {
+		t.Fatalf("custom = %v", inventory.Custom)
+	}
+	if got := string(inventory.Custom["asset_tags"]); got != `{"tag":"PAT-00042"}` {
+		t.Fatalf("asset_tags = %s", got)
+	}
+	for name, want := range map[string]string{
+		"failing":   "asset database unreachable",
+		"panicking": "panic: assignment to entry in nil map",
+		"stuck":     context.DeadlineExceeded.Error(),
+		"invalid":   "plugin returned invalid JSON",
+	} {
+		if got := pluginErrorMessage(t, inventory.Custom[name]); !strings.Contains(got, want) {
+			t.Errorf("%s error %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPluginIntervalReusesOutput(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	c := newTestCollector(t)
+	c.SetClock(clk)
+
+	var n atomic.Int32
+	slow := &testPlugin{name: "hourly", interval: time.Hour, collect: func(context.Context) (json.RawMessage, error) {
+		return json.RawMessage(`{"run":` + strconv.Itoa(int(n.Add(1))) + `}`), nil
+	}}
+	flaky := &testPlugin{name: "flaky", interval: time.Hour, collect: func(context.Context) (json.RawMessage, error) {
+		return nil, errors.New("temporary failure")
+	}}
+	if err := c.SetPlugins([]CollectorPlugin{slow, flaky}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	first := c.collectPlugins(ctx)
+	clk.Advance(30 * time.Minute)
+	second := c.collectPlugins(ctx)
+	if string(first["hourly"]) != `{"run":1}` || string(second["hourly"]) != `{"run":1}` || slow.calls.Load() != 1 {
+		t.Fatalf("within the interval: %s, %s after %d calls", first["hourly"], second["hourly"], slow.calls.Load())
+	}
+	// Falhas não são guardadas: o plugin roda de novo na próxima coleta
+	if flaky.calls.Load() != 2 {
+		t.Fatalf("failing plugin ran %d times", flaky.calls.Load())
+	}
+
+	clk.Advance(30 * time.Minute)
+	if third := c.collectPlugins(ctx); string(third["hourly"]) != `{"run":2}` {
+		t.Fatalf("after the interval: %s", third["hourly"])
+	}
+}
+
+func TestRegisterMergesWithCollectorPlugins(t *testing.T) {
+	for _, plugin := range []CollectorPlugin{
+		nil,
+		staticPlugin("Asset Tags", `{}`),
+		staticPlugin(SectionHardware, `{}`),
+		staticPlugin("collected_at", `{}`),
+	} {
+		if err := Register(plugin); err == nil {
+			t.Errorf("Register(%v) accepted", plugin)
+		}
+	}
+
+	registerForTest(t, staticPlugin("asset_tags", `["PAT-00042"]`))
+	if err := Register(staticPlugin("asset_tags", `[]`)); err == nil {
+		t.Fatal("duplicate plugin registered")
+	}
+	if names := RegisteredPlugins(); len(names) != 1 || names[0] != "asset_tags" {
+		t.Fatalf("registered = %v", names)
+	}
+
+	c := newTestCollector(t)
+	// Um plugin do collector não pode repetir um registrado
+	if err := c.SetPlugins([]CollectorPlugin{staticPlugin("asset_tags", `[]`)}); err == nil {
+		t.Fatal("collector plugin shadowed a registered one")
+	}
+	if err := c.SetPlugins([]CollectorPlugin{staticPlugin("owner", `"alice"`), staticPlugin("owner", `"bob"`)}); err == nil {
+		t.Fatal("duplicate collector plugins accepted")
+	}
+	if err := c.SetPlugins([]CollectorPlugin{staticPlugin("owner", `"alice"`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	custom := c.collectPlugins(context.Background())
+	if len(custom) != 2 || string(custom["asset_tags"]) != `["PAT-00042"]` || string(custom["owner"]) != `"alice"` {
+		t.Fatalf("custom = %v", custom)
+	}
+
+	// Com a seção custom desligada os plugins não rodam
+	if err := c.SetSections(map[string]bool{SectionCustom: false}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Availability()[SectionCustom] {
+		t.Fatal("custom section collected while disabled")
+	}
+}
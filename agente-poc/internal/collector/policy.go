@@ -0,0 +1,389 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// collectPolicies detecta perfis de configuração (macOS) ou GPOs aplicadas (Windows).
+// No Linux não há equivalente e retorna nil.
+func (c *SystemCollector) collectPolicies(ctx context.Context) (*PolicyInfo, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return c.collectConfigurationProfiles(ctx)
+	case "windows":
+		return c.collectGroupPolicies(ctx)
+	default:
+		return nil, nil
+	}
+}
+
+// PolicyCount retorna o número de políticas aplicadas no inventário, ou -1 se desconhecido
+func (d *InventoryData) PolicyCount() int {
+	switch {
+	case d.MacOSSpecific != nil && d.MacOSSpecific.Policies != nil:
+		return d.MacOSSpecific.Policies.Count
+	case d.WindowsSpecific != nil && d.WindowsSpecific.Policies != nil:
+		return d.WindowsSpecific.Policies.Count
+	default:
+		return -1
+	}
+}
+
+// collectConfigurationProfiles lista os perfis instalados via `profiles -P -o stdout-xml`
+func (c *SystemCollector) collectConfigurationProfiles(ctx context.Context) (*PolicyInfo, error) {
+	output, err := c.runProbe(ctx, "profiles", "-P", "-o", "stdout-xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute profiles: %w", err)
+	}
+
+	profiles, err := parseConfigurationProfiles(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyInfo{
+		Source:   PolicySourceProfiles,
+		Count:    len(profiles),
+		Profiles: profiles,
+	}, nil
+}
+
+// parseConfigurationProfiles extrai nome, organização e tipos de payload da
+// saída XML do `profiles`. O conteúdo dos payloads nunca é copiado, pois pode
+// conter segredos (senhas de Wi-Fi, certificados, tokens).
+func parseConfigurationProfiles(data []byte) ([]ConfigurationProfile, error) {
+	root, err := parsePlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profiles output: %w", err)
+	}
+
+	scopes, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected profiles output format")
+	}
+
+	// As chaves são "_computerlevel" ou o nome do usuário
+	scopeNames := make([]string, 0, len(scopes))
+	for name := range scopes {
+		scopeNames = append(scopeNames, name)
+	}
+	sort.Strings(scopeNames)
+
+	var profiles []ConfigurationProfile
+	for _, scopeName := range scopeNames {
+		items, ok := scopes[scopeName].([]interface{})
+		if !ok {
+			continue
+		}
+
+		scope := "user"
+		if scopeName == "_computerlevel" {
+			scope = "computer"
+		}
+
+		for _, item := range items {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			profile := ConfigurationProfile{
+				Name:         plistString(entry, "ProfileDisplayName"),
+				Identifier:   plistString(entry, "ProfileIdentifier"),
+				Organization: plistString(entry, "ProfileOrganization"),
+				Scope:        scope,
+				PayloadTypes: payloadTypes(entry["ProfileItems"]),
+			}
+			if installed, ok := entry["ProfileInstallDate"].(time.Time); ok {
+				profile.InstallDate = installed
+			} else if raw := plistString(entry, "ProfileInstallDate"); raw != "" {
+				if installed, err := time.Parse("2006-01-02 15:04:05 -0700", raw); err == nil {
+					profile.InstallDate = installed
+				}
+			}
+
+			profiles = append(profiles, profile)
+		}
+	}
+
+	return profiles, nil
+}
+
+// payloadTypes resume ProfileItems apenas pelos tipos de payload, sem duplicatas
+func payloadTypes(items interface{}) []string {
+	list, ok := items.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var types []string
+	for _, item := range list {
+		payload, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		payloadType := plistString(payload, "PayloadType")
+		if payloadType == "" || seen[payloadType] {
+			continue
+		}
+		seen[payloadType] = true
+		types = append(types, payloadType)
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+// plistString retorna um valor string de um dict do plist
+func plistString(dict map[string]interface{}, key string) string {
+	value, _ := dict[key].(string)
+	return value
+}
+
+// parsePlist converte um plist XML em valores Go
+// (dict -> map, array -> slice, date -> time.Time, integer -> int64)
+func parsePlist(data []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local == "plist" {
+			continue
+		}
+		return parsePlistValue(decoder, start)
+	}
+}
+
+// parsePlistValue lê o valor iniciado por start
+func parsePlistValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		var key string
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch element := token.(type) {
+			case xml.StartElement:
+				if element.Name.Local == "key" {
+					if err := decoder.DecodeElement(&key, &element); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				value, err := parsePlistValue(decoder, element)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		var array []interface{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch element := token.(type) {
+			case xml.StartElement:
+				value, err := parsePlistValue(decoder, element)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	case "data":
+		// Dados binários (certificados, payloads) são descartados
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	default:
+		var text string
+		if err := decoder.DecodeElement(&text, &start); err != nil {
+			return nil, err
+		}
+		text = strings.TrimSpace(text)
+
+		switch start.Name.Local {
+		case "integer":
+			if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return value, nil
+			}
+		case "date":
+			if value, err := time.Parse(time.RFC3339, text); err == nil {
+				return value, nil
+			}
+		}
+		return text, nil
+	}
+}
+
+// gpResult é o subconjunto do relatório `gpresult /x` usado pelo agente
+type gpResult struct {
+	ComputerResults *gpScopeResult `xml:"ComputerResults"`
+	UserResults     *gpScopeResult `xml:"UserResults"`
+}
+
+type gpScopeResult struct {
+	GPOs []struct {
+		Name          string `xml:"Name"`
+		Enabled       bool   `xml:"Enabled"`
+		IsValid       bool   `xml:"IsValid"`
+		FilterAllowed bool   `xml:"FilterAllowed"`
+		AccessDenied  bool   `xml:"AccessDenied"`
+		Link          struct {
+			SOMPath      string `xml:"SOMPath"`
+			AppliedOrder int    `xml:"AppliedOrder"`
+		} `xml:"Link"`
+	} `xml:"GPO"`
+	ExtensionStatus []struct {
+		Name      string `xml:"Name"`
+		BeginTime string `xml:"BeginTime"`
+		EndTime   string `xml:"EndTime"`
+	} `xml:"ExtensionStatus"`
+}
+
+// collectGroupPolicies resume as GPOs aplicadas a partir de `gpresult /x`
+func (c *SystemCollector) collectGroupPolicies(ctx context.Context) (*PolicyInfo, error) {
+	tmpDir, err := os.MkdirTemp("", "agente-gpresult")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// gpresult só escreve XML em arquivo
+	reportPath := filepath.Join(tmpDir, "gpresult.xml")
+	cmd := exec.CommandContext(ctx, "gpresult", "/x", reportPath, "/f")
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to execute gpresult: %w", err)
+	}
+
+	file, err := os.Open(reportPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gpresult report: %w", err)
+	}
+	defer file.Close()
+
+	gpos, err := parseGroupPolicyResult(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyInfo{
+		Source: PolicySourceGroupPolicy,
+		Count:  len(gpos),
+		GPOs:   gpos,
+	}, nil
+}
+
+// parseGroupPolicyResult extrai as GPOs aplicadas (nome, escopo, horário de aplicação).
+// GPOs negadas ou filtradas não contam como aplicadas.
+func parseGroupPolicyResult(reader io.Reader) ([]GroupPolicyObject, error) {
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gpresult output: %w", err)
+	}
+
+	// gpresult grava em UTF-16; o conteúdo é convertido e a declaração de
+	// encoding passa a ser ignorada
+	decoder := xml.NewDecoder(strings.NewReader(decodeUTF16(raw)))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var result gpResult
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse gpresult output: %w", err)
+	}
+
+	var gpos []GroupPolicyObject
+	for _, scope := range []struct {
+		name    string
+		results *gpScopeResult
+	}{
+		{"computer", result.ComputerResults},
+		{"user", result.UserResults},
+	} {
+		if scope.results == nil {
+			continue
+		}
+
+		// O relatório traz horários por extensão; o fim da última é o horário de aplicação
+		var appliedAt time.Time
+		for _, extension := range scope.results.ExtensionStatus {
+			if end, err := time.Parse(time.RFC3339, strings.TrimSpace(extension.EndTime)); err == nil && end.After(appliedAt) {
+				appliedAt = end
+			}
+		}
+
+		for _, gpo := range scope.results.GPOs {
+			if !gpo.Enabled || !gpo.IsValid || !gpo.FilterAllowed || gpo.AccessDenied {
+				continue
+			}
+			gpos = append(gpos, GroupPolicyObject{
+				Name:      gpo.Name,
+				Scope:     scope.name,
+				LinkPath:  gpo.Link.SOMPath,
+				Order:     gpo.Link.AppliedOrder,
+				AppliedAt: appliedAt,
+			})
+		}
+	}
+
+	return gpos, nil
+}
+
+// decodeUTF16 converte conteúdo UTF-16 com BOM para string; outros conteúdos são mantidos
+func decodeUTF16(data []byte) string {
+	if len(data) < 2 {
+		return string(data)
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case data[0] == 0xFF && data[1] == 0xFE:
+		order = binary.LittleEndian
+	case data[0] == 0xFE && data[1] == 0xFF:
+		order = binary.BigEndian
+	default:
+		return strings.TrimPrefix(string(data), "\ufeff")
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
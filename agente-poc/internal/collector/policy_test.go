@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// readFixture lê um arquivo de testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// encodeUTF16 codifica texto como o gpresult grava: UTF-16 com BOM
+func encodeUTF16(text string, order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	bom := make([]byte, 2)
+	order.PutUint16(bom, 0xFEFF)
+	buf.Write(bom)
+	for _, unit := range utf16.Encode([]rune(text)) {
+		_ = binary.Write(&buf, order, unit)
+	}
+	return buf.Bytes()
+}
+
+func TestParseConfigurationProfiles(t *testing.T) {
+	profiles, err := parseConfigurationProfiles(readFixture(t, "profiles.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ConfigurationProfile{
+		{
+			Name:         "Corporate Wi-Fi",
+			Identifier:   "com.example.mdm.wifi",
+			Organization: "Example Corp IT",
+			Scope:        "computer",
+			PayloadTypes: []string{"com.apple.security.root", "com.apple.wifi.managed"},
+			InstallDate:  time.Date(2024, 3, 4, 12, 30, 45, 0, time.UTC),
+		},
+		{
+			Name:         "FileVault Escrow",
+			Identifier:   "com.example.mdm.filevault",
+			Organization: "Example Corp IT",
+			Scope:        "computer",
+			PayloadTypes: []string{"com.apple.security.FDERecoveryKeyEscrow"},
+			InstallDate:  time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			Name:         "Mail Account",
+			Identifier:   "com.example.mdm.mail",
+			Scope:        "user",
+			PayloadTypes: []string{"com.apple.mail.managed"},
+		},
+	}
+	if len(profiles) != len(want) {
+		t.Fatalf("%d profiles parsed, want %d: %+v", len(profiles), len(want), profiles)
+	}
+	for i := range want {
+		got := profiles[i]
+		if !got.InstallDate.Equal(want[i].InstallDate) {
+			t.Errorf("%s: install date %s, want %s", got.Name, got.InstallDate, want[i].InstallDate)
+		}
+		got.InstallDate = want[i].InstallDate
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("profile %d:\n got %+v\nwant %+v", i, got, want[i])
+		}
+	}
+
+	// O conteúdo dos payloads nunca chega ao inventário
+	data, err := json.Marshal(PolicyInfo{Source: PolicySourceProfiles, Count: len(profiles), Profiles: profiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"REDACTED-WIFI-SECRET", "REDACTED-MAIL-SECRET", "EXAMPLE-CORP", "TUlJQ2RE"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("payload content %q leaked into the policy summary", secret)
+		}
+	}
+}
+
+func TestParseConfigurationProfilesEmptyAndInvalid(t *testing.T) {
+	empty := `<?xml version="1.0" encoding="UTF-8"?><plist version="1.0"><dict/></plist>`
+	profiles, err := parseConfigurationProfiles([]byte(empty))
+	if err != nil || len(profiles) != 0 {
+		t.Fatalf("no profiles installed: %v, %v", profiles, err)
+	}
+
+	for name, input := range map[string]string{
+		"not xml":     "profiles: command not found",
+		"array root":  `<plist version="1.0"><array/></plist>`,
+		"truncated":   `<plist version="1.0"><dict><key>_computerlevel</key><array><dict>`,
+		"empty input": "",
+	} {
+		if _, err := parseConfigurationProfiles([]byte(input)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestParseGroupPolicyResult(t *testing.T) {
+	fixture := string(readFixture(t, "gpresult.xml"))
+	want := []GroupPolicyObject{
+		{Name: "Default Domain Policy", Scope: "computer", LinkPath: "example.local", Order: 2},
+		{Name: "Workstation Hardening", Scope: "computer", LinkPath: "example.local/Workstations", Order: 1},
+		{Name: "Drive Mappings", Scope: "user", LinkPath: "example.local/Users", Order: 1},
+	}
+	computerApplied := time.Date(2024, 6, 10, 13, 55, 9, 0, time.UTC)
+	userApplied := time.Date(2024, 6, 10, 14, 1, 31, 0, time.UTC)
+
+	inputs := map[string][]byte{
+		"utf-16le": encodeUTF16(fixture, binary.LittleEndian),
+		"utf-16be": encodeUTF16(fixture, binary.BigEndian),
+		"utf-8":    []byte(strings.Replace(fixture, `encoding="utf-16"`, `encoding="utf-8"`, 1)),
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			gpos, err := parseGroupPolicyResult(bytes.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(gpos) != len(want) {
+				t.Fatalf("%d GPOs parsed, want %d (denied, filtered and disabled ones skipped): %+v", len(gpos), len(want), gpos)
+			}
+			for i := range want {
+				applied := computerApplied
+				if want[i].Scope == "user" {
+					applied = userApplied
+				}
+				if !gpos[i].AppliedAt.Equal(applied) {
+					t.Errorf("%s: applied at %s, want %s", gpos[i].Name, gpos[i].AppliedAt, applied)
+				}
+				got := gpos[i]
+				got.AppliedAt = time.Time{}
+				if got != want[i] {
+					t.Errorf("GPO %d:\n got %+v\nwant %+v", i, got, want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := parseGroupPolicyResult(strings.NewReader("ERROR: Access denied.")); err == nil {
+		t.Error("gpresult error text accepted")
+	}
+}
+
+func TestPolicyCount(t *testing.T) {
+	policies := &PolicyInfo{Count: 3}
+	tests := []struct {
+		name string
+		data InventoryData
+		want int
+	}{
+		{"unknown", InventoryData{}, -1},
+		{"macos", InventoryData{MacOSSpecific: &MacOSInfo{Policies: policies}}, 3},
+		{"windows", InventoryData{WindowsSpecific: &WindowsInfo{Policies: &PolicyInfo{}}}, 0},
+		{"macos without policies", InventoryData{MacOSSpecific: &MacOSInfo{}}, -1},
+	}
+	for _, tt := range tests {
+		if got := tt.data.PolicyCount(); got != tt.want {
+			t.Errorf("%s: PolicyCount() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
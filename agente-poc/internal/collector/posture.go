@@ -0,0 +1,363 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PostureCheck é o resultado de uma verificação de segurança. Enabled diz
+// se o recurso está ligado; com Error preenchido a verificação falhou e
+// Enabled não tem significado.
+type PostureCheck struct {
+	Enabled bool   `json:"enabled"`
+	Detail  string `json:"detail,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SecurityPosture resume a higiene básica do endpoint. Verificações que não
+// existem na plataforma ficam nil (SIP e Gatekeeper só no macOS).
+type SecurityPosture struct {
+	Firewall   *PostureCheck `json:"firewall,omitempty"`
+	ScreenLock *PostureCheck `json:"screen_lock,omitempty"`
+	SIP        *PostureCheck `json:"sip,omitempty"`
+	Gatekeeper *PostureCheck `json:"gatekeeper,omitempty"`
+	// RemoteLogin é SSH no macOS e no Linux e RDP no Windows; ao contrário
+	// dos demais, ligado é o estado menos seguro
+	RemoteLogin *PostureCheck `json:"remote_login,omitempty"`
+}
+
+// postureCheck é uma verificação independente: platforms vazio roda em
+// todas as plataformas
+type postureCheck struct {
+	platforms []string
+	run       func(c *SystemCollector, ctx context.Context) (*PostureCheck, error)
+	field     func(p *SecurityPosture) **PostureCheck
+}
+
+var postureChecks = []postureCheck{
+	{
+		run:   (*SystemCollector).checkFirewall,
+		field: func(p *SecurityPosture) **PostureCheck { return &p.Firewall },
+	},
+	{
+		run:   (*SystemCollector).checkScreenLock,
+		field: func(p *SecurityPosture) **PostureCheck { return &p.ScreenLock },
+	},
+	{
+		platforms: []string{"darwin"},
+		run:       (*SystemCollector).checkSIP,
+		field:     func(p *SecurityPosture) **PostureCheck { return &p.SIP },
+	},
+	{
+		platforms: []string{"darwin"},
+		run:       (*SystemCollector).checkGatekeeper,
+		field:     func(p *SecurityPosture) **PostureCheck { return &p.Gatekeeper },
+	},
+	{
+		run:   (*SystemCollector).checkRemoteLogin,
+		field: func(p *SecurityPosture) **PostureCheck { return &p.RemoteLogin },
+	},
+}
+
+// collectSecurityPosture roda as verificações em paralelo; a falha de uma
+// fica no Error dela e não afeta as demais
+func (c *SystemCollector) collectSecurityPosture(ctx context.Context) *SecurityPosture {
+	c.logger.Debug("Collecting security posture...")
+
+	posture := &SecurityPosture{}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, check := range postureChecks {
+		if !postureCheckApplies(check) {
+			continue
+		}
+		wg.Add(1)
+		go func(check postureCheck) {
+			defer wg.Done()
+			result, err := check.run(c, ctx)
+			if err != nil {
+				result = &PostureCheck{Error: err.Error()}
+			}
+			mu.Lock()
+			*check.field(posture) = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return posture
+}
+
+// postureCheckApplies indica se a verificação existe nesta plataforma
+func postureCheckApplies(check postureCheck) bool {
+	if len(check.platforms) == 0 {
+		return true
+	}
+	for _, platform := range check.platforms {
+		if platform == runtime.GOOS {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode retorna o código de saída de um comando que terminou com erro
+func exitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	return 0, false
+}
+
+// checkFirewall verifica o firewall do sistema: socketfilterfw no macOS,
+// ufw ou firewalld no Linux e os perfis do netsh advfirewall no Windows
+func (c *SystemCollector) checkFirewall(ctx context.Context) (*PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := c.runProbe(ctx, "/usr/libexec/ApplicationFirewall/socketfilterfw", "--getglobalstate")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute socketfilterfw: %w", err)
+		}
+		return parseSocketFilterFW(output), nil
+	case "linux":
+		output, ufwErr := c.runProbe(ctx, "ufw", "status")
+		if ufwErr == nil {
+			return parseUFWStatus(output), nil
+		}
+		_, err := c.runProbe(ctx, "firewall-cmd", "--state")
+		if err == nil {
+			return &PostureCheck{Enabled: true, Detail: "firewalld"}, nil
+		}
+		// firewall-cmd --state sai com 252 e "not running" com o serviço parado
+		if code, ok := exitCode(err); ok && code == 252 {
+			return &PostureCheck{Enabled: false, Detail: "firewalld"}, nil
+		}
+		return nil, fmt.Errorf("no firewall frontend available (ufw: %v; firewall-cmd: %v)", ufwErr, err)
+	case "windows":
+		output, err := c.runProbe(ctx, "netsh", "advfirewall", "show", "allprofiles", "state")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute netsh: %w", err)
+		}
+		return parseNetshFirewallState(output)
+	default:
+		return nil, fmt.Errorf("firewall check is not supported on %s", runtime.GOOS)
+	}
+}
+
+// parseSocketFilterFW interpreta "Firewall is enabled. (State = 1)"; o
+// estado 2 (bloquear tudo) também conta como ligado
+func parseSocketFilterFW(output []byte) *PostureCheck {
+	text := string(output)
+	return &PostureCheck{
+		Enabled: strings.Contains(text, "enabled") || strings.Contains(text, "blocking all"),
+		Detail:  strings.TrimSpace(text),
+	}
+}
+
+// parseUFWStatus interpreta a primeira linha do ufw status ("Status: active")
+func parseUFWStatus(output []byte) *PostureCheck {
+	for _, line := range strings.Split(string(output), "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "Status:"); ok {
+			return &PostureCheck{Enabled: strings.TrimSpace(value) == "active", Detail: "ufw"}
+		}
+	}
+	return &PostureCheck{Detail: "ufw"}
+}
+
+// parseNetshFirewallState interpreta o netsh advfirewall show allprofiles
+// state: o firewall só conta como ligado com todos os perfis em ON. A
+// linha do perfil é traduzida no Windows localizado, mas ON/OFF não.
+func parseNetshFirewallState(output []byte) (*PostureCheck, error) {
+	var profile string
+	var off []string
+	profiles := 0
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "-") {
+			profile = strings.TrimSuffix(line, ":")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[len(fields)-1] {
+		case "ON":
+			profiles++
+		case "OFF":
+			profiles++
+			off = append(off, profile)
+		}
+	}
+	if profiles == 0 {
+		return nil, fmt.Errorf("no firewall profile state in netsh output")
+	}
+	check := &PostureCheck{Enabled: len(off) == 0}
+	if len(off) > 0 {
+		check.Detail = "off: " + strings.Join(off, ", ")
+	}
+	return check, nil
+}
+
+// Chaves do registro consultadas no Windows
+const (
+	windowsPoliciesSystemKey = `HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Policies\System`
+	windowsTerminalServerKey = `HKLM\SYSTEM\CurrentControlSet\Control\Terminal Server`
+)
+
+// checkScreenLock verifica se a tela bloqueia sozinha: sysadminctl no
+// macOS, a política InactivityTimeoutSecs no Windows e o gsettings do GNOME
+// no Linux (que só responde na sessão do usuário)
+func (c *SystemCollector) checkScreenLock(ctx context.Context) (*PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		// sysadminctl escreve o estado no stderr
+		output, err := c.runProbe(ctx, "sh", "-c", "sysadminctl -screenLock status 2>&1")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute sysadminctl: %w", err)
+		}
+		return parseSysadminctlScreenLock(output), nil
+	case "windows":
+		output, err := c.runProbe(ctx, "reg", "query", windowsPoliciesSystemKey, "/v", "InactivityTimeoutSecs")
+		if err != nil {
+			// reg query sai com 1 quando o valor não existe
+			if code, ok := exitCode(err); ok && code == 1 {
+				return &PostureCheck{Enabled: false, Detail: "not configured"}, nil
+			}
+			return nil, fmt.Errorf("failed to execute reg: %w", err)
+		}
+		seconds, err := parseRegDWORD(output, "InactivityTimeoutSecs")
+		if err != nil {
+			return nil, err
+		}
+		return &PostureCheck{Enabled: seconds > 0, Detail: fmt.Sprintf("%d seconds", seconds)}, nil
+	case "linux":
+		output, err := c.runProbe(ctx, "gsettings", "get", "org.gnome.desktop.screensaver", "lock-enabled")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute gsettings: %w", err)
+		}
+		return &PostureCheck{Enabled: strings.TrimSpace(string(output)) == "true", Detail: "gnome"}, nil
+	default:
+		return nil, fmt.Errorf("screen lock check is not supported on %s", runtime.GOOS)
+	}
+}
+
+// parseSysadminctlScreenLock interpreta "screenLock delay is 300 seconds",
+// "screenLock is immediate" ou "screenLock is off", precedidos de data e
+// processo
+func parseSysadminctlScreenLock(output []byte) *PostureCheck {
+	text := strings.TrimSpace(string(output))
+	idx := strings.Index(text, "screenLock")
+	if idx < 0 {
+		return &PostureCheck{Detail: text}
+	}
+	detail := text[idx:]
+	return &PostureCheck{Enabled: !strings.Contains(detail, "is off"), Detail: detail}
+}
+
+// parseRegDWORD lê um valor REG_DWORD do reg query
+// ("    InactivityTimeoutSecs    REG_DWORD    0x384")
+func parseRegDWORD(output []byte, name string) (uint64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && strings.EqualFold(fields[0], name) && fields[1] == "REG_DWORD" {
+			return strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		}
+	}
+	return 0, fmt.Errorf("%s not found in reg output", name)
+}
+
+// checkSIP verifica o System Integrity Protection pelo csrutil status
+func (c *SystemCollector) checkSIP(ctx context.Context) (*PostureCheck, error) {
+	output, err := c.runProbe(ctx, "csrutil", "status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute csrutil: %w", err)
+	}
+	return parseCsrutilStatus(output), nil
+}
+
+// parseCsrutilStatus interpreta "System Integrity Protection status:
+// enabled." (ou "disabled.", e configurações parciais com "Custom
+// Configuration", que não contam como ligado)
+func parseCsrutilStatus(output []byte) *PostureCheck {
+	text := strings.TrimSpace(string(output))
+	_, status, _ := strings.Cut(text, "status:")
+	status = strings.TrimSpace(status)
+	return &PostureCheck{
+		Enabled: strings.HasPrefix(status, "enabled") && !strings.Contains(text, "Custom Configuration"),
+		Detail:  strings.TrimSuffix(strings.SplitN(status, "\n", 2)[0], "."),
+	}
+}
+
+// checkGatekeeper verifica o Gatekeeper pelo spctl --status
+func (c *SystemCollector) checkGatekeeper(ctx context.Context) (*PostureCheck, error) {
+	output, err := c.runProbe(ctx, "spctl", "--status")
+	if err != nil {
+		// spctl --status sai com 1 quando as avaliações estão desligadas
+		if code, ok := exitCode(err); ok && code == 1 {
+			return &PostureCheck{Enabled: false, Detail: "assessments disabled"}, nil
+		}
+		return nil, fmt.Errorf("failed to execute spctl: %w", err)
+	}
+	return parseSpctlStatus(output), nil
+}
+
+// parseSpctlStatus interpreta "assessments enabled" / "assessments disabled"
+func parseSpctlStatus(output []byte) *PostureCheck {
+	text := strings.TrimSpace(string(output))
+	return &PostureCheck{Enabled: text == "assessments enabled", Detail: text}
+}
+
+// checkRemoteLogin verifica o acesso remoto: Remote Login (SSH) no macOS,
+// o serviço do OpenSSH no Linux e o RDP no Windows
+func (c *SystemCollector) checkRemoteLogin(ctx context.Context) (*PostureCheck, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := c.runProbe(ctx, "systemsetup", "-getremotelogin")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute systemsetup: %w", err)
+		}
+		return parseSystemsetupRemoteLogin(output), nil
+	case "linux":
+		// is-active sai com 0 só quando a unit está ativa; ssh no Debian,
+		// sshd nas demais
+		for _, unit := range []string{"ssh", "sshd"} {
+			_, err := c.runProbe(ctx, "systemctl", "is-active", "--quiet", unit)
+			if err == nil {
+				return &PostureCheck{Enabled: true, Detail: unit}, nil
+			}
+			if _, ok := exitCode(err); !ok {
+				return nil, fmt.Errorf("failed to execute systemctl: %w", err)
+			}
+		}
+		return &PostureCheck{Enabled: false, Detail: "ssh"}, nil
+	case "windows":
+		output, err := c.runProbe(ctx, "reg", "query", windowsTerminalServerKey, "/v", "fDenyTSConnections")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute reg: %w", err)
+		}
+		deny, err := parseRegDWORD(output, "fDenyTSConnections")
+		if err != nil {
+			return nil, err
+		}
+		return &PostureCheck{Enabled: deny == 0, Detail: "rdp"}, nil
+	default:
+		return nil, fmt.Errorf("remote login check is not supported on %s", runtime.GOOS)
+	}
+}
+
+// parseSystemsetupRemoteLogin interpreta "Remote Login: On" / "Remote Login: Off"
+func parseSystemsetupRemoteLogin(output []byte) *PostureCheck {
+	_, value, _ := strings.Cut(strings.TrimSpace(string(output)), ":")
+	return &PostureCheck{Enabled: strings.TrimSpace(value) == "On", Detail: "ssh"}
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// CommandRunner executa comandos externos usados na coleta.
+// Pode ser substituído em testes para contar ou simular invocações.
+type CommandRunner interface {
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// execRunner é o CommandRunner padrão, baseado em os/exec
+type execRunner struct{}
+
+func (execRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// SetCommandRunner substitui o executor de comandos externos
+func (c *SystemCollector) SetCommandRunner(runner CommandRunner) {
+	c.runner = runner
+}
+
+// probeCacheKey é a chave do probeCache no contexto da coleta
+type probeCacheKey struct{}
+
+// probeCache guarda resultados de probes durante uma única coleta, para que
+// comandos lentos (system_profiler, launchctl list) rodem uma vez por ciclo
+// mesmo quando vários coletores precisam do mesmo dado. Não substitui o cache
+// com TTL: termina junto com o contexto da coleta.
+type probeCache struct {
+	mu      sync.Mutex
+	entries map[string]*probeEntry
+}
+
+type probeEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// withProbeCache associa um probeCache novo ao contexto da coleta
+func withProbeCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeCacheKey{}, &probeCache{entries: make(map[string]*probeEntry)})
+}
+
+// cycleValue calcula o valor da chave uma única vez por coleta; chamadas
+// concorrentes aguardam a primeira. Sem probeCache no contexto, calcula sempre.
+func cycleValue(ctx context.Context, key string, compute func() (interface{}, error)) (interface{}, error) {
+	cache, ok := ctx.Value(probeCacheKey{}).(*probeCache)
+	if !ok {
+		return compute()
+	}
+
+	cache.mu.Lock()
+	entry, exists := cache.entries[key]
+	if !exists {
+		entry = &probeEntry{}
+		cache.entries[key] = entry
+	}
+	cache.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.value, entry.err = compute()
+	})
+	return entry.value, entry.err
+}
+
+// runProbe executa um comando externo, reaproveitando a saída dentro da mesma coleta
+func (c *SystemCollector) runProbe(ctx context.Context, name string, args ...string) ([]byte, error) {
+	key := "cmd:" + name + " " + strings.Join(args, " ")
+	value, err := cycleValue(ctx, key, func() (interface{}, error) {
+		return c.runner.Output(ctx, name, args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
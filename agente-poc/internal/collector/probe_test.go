@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingRunner conta as invocações de cada comando. Os comandos em
+// outputs respondem com a saída fixa; os demais, com exec.ErrNotFound.
+type countingRunner struct {
+	outputs map[string][]byte
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingRunner(outputs map[string][]byte) *countingRunner {
+	return &countingRunner{outputs: outputs, calls: make(map[string]int)}
+}
+
+func (r *countingRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	key := strings.Join(append([]string{name}, args...), " ")
+	r.mu.Lock()
+	r.calls[key]++
+	r.mu.Unlock()
+
+	if output, ok := r.outputs[key]; ok {
+		return output, nil
+	}
+	return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// snapshot copia e zera as contagens
+func (r *countingRunner) snapshot() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.calls
+	r.calls = make(map[string]int)
+	return calls
+}
+
+func TestGetSPHardwareOncePerCycle(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{
+		"system_profiler SPHardwareDataType -json": readFixture(t, "sphardware_apple_silicon.json"),
+	})
+	c.SetCommandRunner(runner)
+
+	for cycle := 1; cycle <= 2; cycle++ {
+		ctx := withProbeCache(context.Background())
+
+		// Inventário macOS, machine ID e campos de patrimônio pedem o mesmo dado
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := c.getSPHardware(ctx)
+				if err != nil || result.hardware.Chip != "Apple M2 Pro" {
+					t.Errorf("getSPHardware: %+v, %v", result, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if _, err := c.runProbe(ctx, "system_profiler", "SPHardwareDataType", "-json"); err != nil {
+			t.Fatal(err)
+		}
+
+		if calls := runner.snapshot(); calls["system_profiler SPHardwareDataType -json"] != 1 || len(calls) != 1 {
+			t.Fatalf("cycle %d: invocations %v, want system_profiler once", cycle, calls)
+		}
+	}
+}
+
+func TestRunProbeSharesErrors(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(nil)
+	c.SetCommandRunner(runner)
+
+	ctx := withProbeCache(context.Background())
+	for i := 0; i < 3; i++ {
+		if _, err := c.runProbe(ctx, "launchctl", "list"); err == nil {
+			t.Fatal("missing command succeeded")
+		}
+	}
+	// Argumentos diferentes são outro probe
+	_, _ = c.runProbe(ctx, "launchctl", "print", "system")
+
+	calls := runner.snapshot()
+	if calls["launchctl list"] != 1 || calls["launchctl print system"] != 1 {
+		t.Fatalf("invocations %v, want each probe once", calls)
+	}
+}
+
+func TestRunProbeWithoutCycle(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{"vm_stat": []byte("Pages free: 1.")})
+	c.SetCommandRunner(runner)
+
+	// Fora de uma coleta (ex.: comando avulso) não há reaproveitamento
+	for i := 0; i < 3; i++ {
+		if _, err := c.runProbe(context.Background(), "vm_stat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := runner.snapshot(); calls["vm_stat"] != 3 {
+		t.Fatalf("invocations %v, want 3 without a probe cache", calls)
+	}
+}
+
+func TestCollectInventoryRunsEachProbeOncePerCycle(t *testing.T) {
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{
+		"system_profiler SPHardwareDataType -json": readFixture(t, "sphardware_intel.json"),
+	})
+	c.SetCommandRunner(runner)
+
+	for cycle := 1; cycle <= 2; cycle++ {
+		if _, err := c.CollectInventory(); err != nil {
+			t.Fatal(err)
+		}
+		calls := runner.snapshot()
+		if len(calls) == 0 {
+			t.Fatalf("cycle %d: no probe went through the command runner", cycle)
+		}
+		for command, count := range calls {
+			if count != 1 {
+				t.Errorf("cycle %d: %q ran %d times", cycle, command, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// Critérios de ordenação dos processos do inventário (ProcessSortKey)
+const (
+	ProcessSortCPU    = "cpu"
+	ProcessSortMemory = "memory"
+)
+
+// ValidateProcessSortKey confere o critério de ordenação dos processos;
+// vazio equivale a ProcessSortCPU
+func ValidateProcessSortKey(key string) error {
+	switch key {
+	case "", ProcessSortCPU, ProcessSortMemory:
+		return nil
+	}
+	return fmt.Errorf("unknown process sort key %q (expected %q or %q)", key, ProcessSortCPU, ProcessSortMemory)
+}
+
+// SetProcessSelection define o critério de ordenação e os mínimos de CPU e
+// memória dos processos do inventário
+func (c *SystemCollector) SetProcessSelection(sortKey string, minCPUPercent float64, minMemoryBytes uint64) error {
+	if err := ValidateProcessSortKey(sortKey); err != nil {
+		return err
+	}
+	if minCPUPercent < 0 {
+		return fmt.Errorf("minimum process CPU percent cannot be negative")
+	}
+	if sortKey == "" {
+		sortKey = ProcessSortCPU
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.ProcessSortKey = sortKey
+	config.MinProcessCPUPercent = minCPUPercent
+	config.MinProcessMemoryBytes = minMemoryBytes
+	c.config.Store(&config)
+	return nil
+}
+
+// processCandidate são os campos baratos lidos de todos os processos na
+// primeira passada, suficientes para ordenar e filtrar
+type processCandidate struct {
+	proc       *process.Process
+	cpuPercent float64
+	memory     uint64
+	createTime int64
+	// sustained é a média do ProcessCPUSampler, quando já existe
+	sustained    float64
+	hasSustained bool
+}
+
+// cpuScore é o uso de CPU usado na ordenação: a média sustentada quando
+// conhecida, senão a média desde o início do processo
+func (p *processCandidate) cpuScore() float64 {
+	if p.hasSustained {
+		return p.sustained
+	}
+	return p.cpuPercent
+}
+
+// idle indica que o processo fica abaixo de todos os mínimos configurados
+func (p *processCandidate) idle(config *CollectorConfig) bool {
+	if config.MinProcessCPUPercent <= 0 && config.MinProcessMemoryBytes == 0 {
+		return false
+	}
+	if config.MinProcessCPUPercent > 0 && p.cpuScore() >= config.MinProcessCPUPercent {
+		return false
+	}
+	if config.MinProcessMemoryBytes > 0 && p.memory >= config.MinProcessMemoryBytes {
+		return false
+	}
+	return true
+}
+
+// selectProcesses ordena os candidatos por sortKey (maior primeiro, PID
+// como desempate), descarta os ociosos e mantém até max
+func selectProcesses(candidates []processCandidate, config *CollectorConfig) []processCandidate {
+	kept := candidates[:0]
+	for _, candidate := range candidates {
+		if !candidate.idle(config) {
+			kept = append(kept, candidate)
+		}
+	}
+
+	byMemory := config.ProcessSortKey == ProcessSortMemory
+	sort.Slice(kept, func(i, j int) bool {
+		a, b := &kept[i], &kept[j]
+		if byMemory {
+			if a.memory != b.memory {
+				return a.memory > b.memory
+			}
+		} else if a.cpuScore() != b.cpuScore() {
+			return a.cpuScore() > b.cpuScore()
+		}
+		return a.proc.Pid < b.proc.Pid
+	})
+
+	if len(kept) > config.MaxProcesses {
+		kept = kept[:config.MaxProcesses]
+	}
+	return kept
+}
+
+// collectRunningProcesses coleta os MaxProcesses processos mais pesados em
+// duas passadas: CPU e memória de todos os processos, depois os campos
+// caros (linha de comando, usuário, status) só dos selecionados. Se o prazo
+// da coleta acabar, retorna o que já foi lido.
+func (c *SystemCollector) collectRunningProcesses(ctx context.Context) ([]Process, error) {
+	c.logger.Debug("Collecting running processes...")
+	config := c.configFor(ctx)
+
+	// Obter lista de PIDs
+	pids, err := process.PidsWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get process PIDs: %w", err)
+	}
+
+	candidates := make([]processCandidate, 0, len(pids))
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			break
+		}
+		proc, err := process.NewProcessWithContext(ctx, pid)
+		if err != nil {
+			continue // Processo pode ter terminado
+		}
+		candidates = append(candidates, c.readProcessCandidate(ctx, proc))
+	}
+
+	selected := selectProcesses(candidates, config)
+	processes := make([]Process, 0, len(selected))
+	for i := range selected {
+		if ctx.Err() != nil {
+			c.logger.WithFields(map[string]interface{}{
+				"collected": len(processes),
+				"selected":  len(selected),
+			}).Warning("Process collection hit its deadline, returning partial result")
+			break
+		}
+		processes = append(processes, c.getProcessInfo(ctx, &selected[i]))
+	}
+
+	return processes, nil
+}
+
+// readProcessCandidate lê os campos da primeira passada; falhas deixam o
+// campo zerado (processos de outros usuários podem negar leitura)
+func (c *SystemCollector) readProcessCandidate(ctx context.Context, proc *process.Process) processCandidate {
+	candidate := processCandidate{proc: proc}
+
+	if cpuPercent, err := proc.CPUPercentWithContext(ctx); err == nil {
+		candidate.cpuPercent = cpuPercent
+	}
+	if memInfo, err := proc.MemoryInfoWithContext(ctx); err == nil {
+		candidate.memory = memInfo.RSS
+	}
+	if createTime, err := proc.CreateTimeWithContext(ctx); err == nil {
+		candidate.createTime = createTime
+		candidate.sustained, candidate.hasSustained = c.cpuSampler.Sustained(ProcessKey{PID: proc.Pid, CreateTime: createTime})
+	}
+	return candidate
+}
+
+// getProcessInfo completa um processo selecionado com os campos caros
+func (c *SystemCollector) getProcessInfo(ctx context.Context, candidate *processCandidate) Process {
+	proc := candidate.proc
+
+	name, err := proc.NameWithContext(ctx)
+	if err != nil {
+		name = "unknown"
+	}
+
+	cmdline, err := proc.CmdlineWithContext(ctx)
+	if err != nil {
+		cmdline = ""
+	}
+
+	statusList, err := proc.StatusWithContext(ctx)
+	var status string
+	if err != nil || len(statusList) == 0 {
+		status = "unknown"
+	} else {
+		status = statusList[0] // Usar o primeiro status da lista
+	}
+
+	username, err := proc.UsernameWithContext(ctx)
+	if err != nil {
+		username = "unknown"
+	}
+
+	var startTime string
+	if candidate.createTime > 0 {
+		startTime = time.Unix(candidate.createTime/1000, 0).Format(time.RFC3339)
+	}
+	var sustainedCPU *float64
+	if candidate.hasSustained {
+		average := math.Round(candidate.sustained*100) / 100
+		sustainedCPU = &average
+	}
+
+	return Process{
+		PID:         proc.Pid,
+		Name:        name,
+		Command:     cmdline,
+		CPUPercent:  candidate.cpuPercent,
+		MemoryUsage: candidate.memory,
+		Status:      status,
+		User:        username,
+		StartTime:   startTime,
+
+		SustainedCPUPercent: sustainedCPU,
+	}
+}
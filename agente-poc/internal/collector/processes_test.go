@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// candidate monta um candidato da primeira passada sem ler o sistema
+func candidate(pid int32, cpuPercent float64, memory uint64) processCandidate {
+	return processCandidate{proc: &process.Process{Pid: pid}, cpuPercent: cpuPercent, memory: memory}
+}
+
+// pids lista os PIDs selecionados, na ordem
+func pids(selected []processCandidate) []int32 {
+	result := make([]int32, 0, len(selected))
+	for _, candidate := range selected {
+		result = append(result, candidate.proc.Pid)
+	}
+	return result
+}
+
+func TestSelectProcesses(t *testing.T) {
+	// O PID 5 tem média sustentada baixa apesar do pico desde o início
+	sustained := candidate(5, 90, 10<<20)
+	sustained.sustained, sustained.hasSustained = 1, true
+
+	tests := []struct {
+		name   string
+		config CollectorConfig
+		want   []int32
+	}{
+		{
+			name:   "cpu",
+			config: CollectorConfig{MaxProcesses: 10, ProcessSortKey: ProcessSortCPU},
+			want:   []int32{2, 3, 4, 1, 5, 6},
+		},
+		{
+			// Sem critério explícito ordena por CPU
+			name:   "default key with cap",
+			config: CollectorConfig{MaxProcesses: 3},
+			want:   []int32{2, 3, 4},
+		},
+		{
+			name:   "memory",
+			config: CollectorConfig{MaxProcesses: 10, ProcessSortKey: ProcessSortMemory},
+			want:   []int32{1, 4, 2, 3, 5, 6},
+		},
+		{
+			name:   "min cpu",
+			config: CollectorConfig{MaxProcesses: 10, MinProcessCPUPercent: 5},
+			want:   []int32{2, 3, 4},
+		},
+		{
+			name:   "min memory",
+			config: CollectorConfig{MaxProcesses: 10, ProcessSortKey: ProcessSortMemory, MinProcessMemoryBytes: 500 << 20},
+			want:   []int32{1, 4},
+		},
+		{
+			// Basta passar de um dos mínimos para ficar
+			name:   "min cpu or memory",
+			config: CollectorConfig{MaxProcesses: 10, MinProcessCPUPercent: 5, MinProcessMemoryBytes: 1 << 30},
+			want:   []int32{2, 3, 4, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := []processCandidate{
+				candidate(1, 2, 2<<30),
+				candidate(2, 40, 200<<20),
+				candidate(3, 40, 150<<20), // empate de CPU com o 2: menor PID primeiro
+				candidate(4, 12, 1<<30),
+				sustained,
+				candidate(6, 0, 4<<20),
+			}
+			if got := pids(selectProcesses(candidates, &tt.config)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetProcessSelection(t *testing.T) {
+	c := newTestCollector(t)
+	if err := c.SetProcessSelection("", 0.5, 64<<20); err != nil {
+		t.Fatal(err)
+	}
+	config := c.cfg()
+	if config.ProcessSortKey != ProcessSortCPU || config.MinProcessCPUPercent != 0.5 || config.MinProcessMemoryBytes != 64<<20 {
+		t.Fatalf("process selection = %q, %f, %d", config.ProcessSortKey, config.MinProcessCPUPercent, config.MinProcessMemoryBytes)
+	}
+
+	if err := c.SetProcessSelection("pid", 0, 0); err == nil {
+		t.Fatal("unknown sort key accepted")
+	}
+	if err := c.SetProcessSelection(ProcessSortMemory, -1, 0); err == nil {
+		t.Fatal("negative minimum CPU accepted")
+	}
+	if c.cfg().ProcessSortKey != ProcessSortCPU {
+		t.Fatal("rejected selection replaced the previous one")
+	}
+}
+
+// startIdleProcesses inicia n processos parados até o fim do teste
+func startIdleProcesses(tb testing.TB, n int) {
+	tb.Helper()
+	if runtime.GOOS == "windows" {
+		tb.Skip("uses sleep(1)")
+	}
+	for i := 0; i < n; i++ {
+		cmd := exec.Command("sleep", "300")
+		if err := cmd.Start(); err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		})
+	}
+}
+
+func TestCollectRunningProcessesWithinDeadline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts several hundred processes")
+	}
+	startIdleProcesses(t, 300)
+	c := newTestCollector(t)
+	if err := c.SetProcessSelection(ProcessSortMemory, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Várias centenas de processos cabem com folga no prazo da coleta
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	processes, err := c.collectRunningProcesses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("collection of 300+ processes took %s", elapsed)
+	}
+
+	if len(processes) != c.cfg().MaxProcesses {
+		t.Fatalf("%d processes collected, want the %d cap", len(processes), c.cfg().MaxProcesses)
+	}
+	for i := 1; i < len(processes); i++ {
+		if processes[i].MemoryUsage > processes[i-1].MemoryUsage {
+			t.Fatalf("process %d uses more memory than process %d", i, i-1)
+		}
+	}
+	// A segunda passada preencheu os campos caros dos selecionados
+	if processes[0].Name == "" || processes[0].Status == "" {
+		t.Fatalf("heaviest process = %+v", processes[0])
+	}
+
+	// Com o prazo vencido, retorna o que tiver sem erro
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	if _, err := c.collectRunningProcesses(expired); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("expired collection: %v", err)
+	}
+}
+
+func BenchmarkCollectRunningProcesses(b *testing.B) {
+	startIdleProcesses(b, 300)
+	c := newTestCollector(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.collectRunningProcesses(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// uninstallKeyPath é a chave com os programas de "Adicionar ou remover
+// programas" do Windows, abaixo de HKLM e HKCU
+const uninstallKeyPath = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`
+
+// uninstallRoot é uma das chaves Uninstall lidas: hive e visão do registro
+// (WOW64 de 32 bits ou nativa de 64 bits)
+type uninstallRoot struct {
+	Hive  string // "HKLM" ou "HKCU"
+	WOW32 bool
+}
+
+// uninstallRoots são as quatro combinações de hive e visão
+var uninstallRoots = []uninstallRoot{
+	{Hive: "HKLM"},
+	{Hive: "HKLM", WOW32: true},
+	{Hive: "HKCU"},
+	{Hive: "HKCU", WOW32: true},
+}
+
+// String identifica a chave nos logs
+func (r uninstallRoot) String() string {
+	if r.WOW32 {
+		return r.Hive + `\` + uninstallKeyPath + " (32-bit)"
+	}
+	return r.Hive + `\` + uninstallKeyPath
+}
+
+// uninstallEntry são os valores de uma subchave Uninstall
+type uninstallEntry struct {
+	Key             string
+	DisplayName     string
+	DisplayVersion  string
+	Publisher       string
+	InstallDate     string // "YYYYMMDD"
+	InstallLocation string
+}
+
+// uninstallReader lê as subchaves de uma chave Uninstall; substituível em
+// testes
+type uninstallReader interface {
+	ReadUninstall(root uninstallRoot) ([]uninstallEntry, error)
+}
+
+// uninstallApplication converte uma entrada do registro; entradas sem
+// DisplayName (componentes e atualizações) não são aplicações
+func uninstallApplication(root uninstallRoot, entry uninstallEntry) (Application, bool) {
+	name := strings.TrimSpace(entry.DisplayName)
+	if name == "" {
+		return Application{}, false
+	}
+
+	app := Application{
+		Name:    name,
+		Version: strings.TrimSpace(entry.DisplayVersion),
+		Vendor:  strings.TrimSpace(entry.Publisher),
+		Path:    strings.TrimSpace(entry.InstallLocation),
+	}
+	if app.Path == "" {
+		app.Path = root.Hive + `\` + uninstallKeyPath + `\` + entry.Key
+	}
+	if date, err := time.Parse("20060102", strings.TrimSpace(entry.InstallDate)); err == nil {
+		app.InstallDate = date.Format(time.RFC3339)
+	}
+	return app, true
+}
+
+// collectRegistryApps é o collectInstalledApps do Windows: lê as chaves
+// Uninstall com o mesmo limite (MaxApplications) e cache da varredura do
+// macOS. Uma chave ilegível não impede as demais.
+func (c *SystemCollector) collectRegistryApps(ctx context.Context, config *CollectorConfig) ([]Application, bool, error) {
+	apps := make([]Application, 0)
+	seen := make(map[string]bool)
+
+	for _, root := range uninstallRoots {
+		if ctx.Err() != nil {
+			break
+		}
+		entries, err := c.registry.ReadUninstall(root)
+		if err != nil {
+			c.logger.WithFields(map[string]interface{}{
+				"key":   root.String(),
+				"error": err,
+			}).Debug("Failed to read uninstall registry key")
+			continue
+		}
+		for _, entry := range entries {
+			app, ok := uninstallApplication(root, entry)
+			if !ok {
+				continue
+			}
+			// O mesmo programa pode aparecer nas duas visões do HKCU
+			id := app.Name + "\x00" + app.Version + "\x00" + app.Vendor
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Path < apps[j].Path })
+	if len(apps) > config.MaxApplications {
+		apps = apps[:config.MaxApplications]
+	}
+
+	if ctx.Err() != nil {
+		return apps, true, nil
+	}
+
+	// Cachear apenas resultados completos
+	c.setInCache(CacheKeyInstalledApps, apps, config.CacheExpiration)
+	return apps, false, nil
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package collector
+
+import "fmt"
+
+// otherUninstallReader existe fora do Windows só para o collector compilar
+type otherUninstallReader struct{}
+
+// newUninstallReader retorna um leitor que sempre falha fora do Windows
+func newUninstallReader() uninstallReader {
+	return otherUninstallReader{}
+}
+
+func (otherUninstallReader) ReadUninstall(root uninstallRoot) ([]uninstallEntry, error) {
+	return nil, fmt.Errorf("the registry is only available on Windows")
+}
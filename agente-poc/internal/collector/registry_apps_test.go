@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeUninstallReader devolve entradas fixas por chave Uninstall e conta as
+// leituras
+type fakeUninstallReader struct {
+	entries map[uninstallRoot][]uninstallEntry
+	failing map[uninstallRoot]bool
+	reads   int
+}
+
+func (r *fakeUninstallReader) ReadUninstall(root uninstallRoot) ([]uninstallEntry, error) {
+	r.reads++
+	if r.failing[root] {
+		return nil, errors.New("access denied")
+	}
+	return r.entries[root], nil
+}
+
+func TestUninstallApplication(t *testing.T) {
+	root := uninstallRoot{Hive: "HKLM"}
+	tests := []struct {
+		name  string
+		entry uninstallEntry
+		want  Application
+		ok    bool
+	}{
+		{
+			name: "complete",
+			entry: uninstallEntry{
+				Key:             "{90160000-0011-0000-1000-0000000FF1CE}",
+				DisplayName:     " Microsoft Office ",
+				DisplayVersion:  "16.0.4266.1001",
+				Publisher:       "Microsoft Corporation",
+				InstallDate:     "20250312",
+				InstallLocation: `C:\Program Files\Microsoft Office`,
+			},
+			want: Application{
+				Name:        "Microsoft Office",
+				Version:     "16.0.4266.1001",
+				Vendor:      "Microsoft Corporation",
+				Path:        `C:\Program Files\Microsoft Office`,
+				InstallDate: "2025-03-12T00:00:00Z",
+			},
+			ok: true,
+		},
+		{
+			// Sem InstallLocation o caminho é a própria chave; data inválida fica vazia
+			name:  "no location",
+			entry: uninstallEntry{Key: "7-Zip", DisplayName: "7-Zip 23.01", InstallDate: "2023-06-20"},
+			want:  Application{Name: "7-Zip 23.01", Path: `HKLM\` + uninstallKeyPath + `\7-Zip`},
+			ok:    true,
+		},
+		{
+			name:  "no display name",
+			entry: uninstallEntry{Key: "KB5034441", DisplayVersion: "1.0"},
+		},
+		{
+			name:  "blank display name",
+			entry: uninstallEntry{Key: "Component", DisplayName: "   "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, ok := uninstallApplication(root, tt.entry)
+			if ok != tt.ok || !reflect.DeepEqual(app, tt.want) {
+				t.Fatalf("uninstallApplication = %+v, %t; want %+v, %t", app, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCollectRegistryApps(t *testing.T) {
+	c := newTestCollector(t)
+	chrome := uninstallEntry{Key: "Google Chrome", DisplayName: "Google Chrome", DisplayVersion: "120.0", Publisher: "Google LLC", InstallLocation: `C:\Program Files\Google\Chrome`}
+	reader := &fakeUninstallReader{
+		entries: map[uninstallRoot][]uninstallEntry{
+			{Hive: "HKLM"}: {
+				chrome,
+				{Key: "KB5034441"},
+				{Key: "Git_is1", DisplayName: "Git", InstallLocation: `C:\Program Files\Git`},
+			},
+			{Hive: "HKLM", WOW32: true}: {
+				{Key: "Notepad++", DisplayName: "Notepad++", InstallLocation: `C:\Program Files (x86)\Notepad++`},
+			},
+			// A mesma instalação vista nas duas visões do HKCU
+			{Hive: "HKCU"}:              {{Key: "Zoom", DisplayName: "Zoom", DisplayVersion: "5.17"}},
+			{Hive: "HKCU", WOW32: true}: {{Key: "Zoom", DisplayName: "Zoom", DisplayVersion: "5.17"}},
+		},
+	}
+	c.registry = reader
+
+	apps, partial, err := c.collectRegistryApps(context.Background(), c.configFor(context.Background()))
+	if err != nil || partial {
+		t.Fatalf("collectRegistryApps: partial %t, %v", partial, err)
+	}
+	var names []string
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	// Ordenadas pelo caminho, sem a entrada sem nome e sem a duplicada
+	if want := []string{"Notepad++", "Git", "Google Chrome", "Zoom"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("applications = %v, want %v", names, want)
+	}
+	if reader.reads != len(uninstallRoots) {
+		t.Fatalf("%d keys read, want %d", reader.reads, len(uninstallRoots))
+	}
+
+	// O resultado completo vai para o cache com o TTL das aplicações
+	cached, ok := c.getFromCache(CacheKeyInstalledApps).([]Application)
+	if !ok || !reflect.DeepEqual(cached, apps) {
+		t.Fatalf("cached applications = %v", cached)
+	}
+}
+
+func TestCollectRegistryAppsLimitsAndFailures(t *testing.T) {
+	c := newTestCollector(t)
+	c.registry = &fakeUninstallReader{
+		entries: map[uninstallRoot][]uninstallEntry{
+			{Hive: "HKLM"}: {{Key: "a", DisplayName: "A"}, {Key: "b", DisplayName: "B"}, {Key: "c", DisplayName: "C"}},
+			{Hive: "HKCU"}: {{Key: "d", DisplayName: "D"}},
+		},
+		// Uma chave ilegível não impede as demais
+		failing: map[uninstallRoot]bool{{Hive: "HKLM", WOW32: true}: true},
+	}
+
+	config := *c.configFor(context.Background())
+	config.MaxApplications = 2
+	apps, _, err := c.collectRegistryApps(context.Background(), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// O corte vem depois da ordenação pelo caminho (HKCU antes de HKLM)
+	if len(apps) != 2 || apps[0].Name != "D" || apps[1].Name != "A" {
+		t.Fatalf("applications with max_applications 2 = %+v", apps)
+	}
+
+	// Coleta cancelada é parcial e não vai para o cache
+	c.ClearCache()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, partial, err := c.collectRegistryApps(ctx, &config); err != nil || !partial {
+		t.Fatalf("cancelled collection: partial %t, %v", partial, err)
+	}
+	if c.getFromCache(CacheKeyInstalledApps) != nil {
+		t.Fatal("partial result cached")
+	}
+}
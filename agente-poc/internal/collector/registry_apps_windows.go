@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// windowsUninstallReader lê as chaves Uninstall do registro
+type windowsUninstallReader struct{}
+
+// newUninstallReader retorna o leitor do registro do Windows
+func newUninstallReader() uninstallReader {
+	return windowsUninstallReader{}
+}
+
+func (windowsUninstallReader) ReadUninstall(root uninstallRoot) ([]uninstallEntry, error) {
+	hive := registry.LOCAL_MACHINE
+	if root.Hive == "HKCU" {
+		hive = registry.CURRENT_USER
+	}
+	view := uint32(registry.WOW64_64KEY)
+	if root.WOW32 {
+		view = registry.WOW64_32KEY
+	}
+
+	key, err := registry.OpenKey(hive, uninstallKeyPath, registry.ENUMERATE_SUB_KEYS|registry.QUERY_VALUE|view)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", root, err)
+	}
+	defer key.Close()
+
+	names, err := key.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", root, err)
+	}
+
+	entries := make([]uninstallEntry, 0, len(names))
+	for _, name := range names {
+		sub, err := registry.OpenKey(key, name, registry.QUERY_VALUE|view)
+		if err != nil {
+			continue
+		}
+		// Valores ausentes ficam vazios
+		value := func(name string) string {
+			v, _, _ := sub.GetStringValue(name)
+			return v
+		}
+		entries = append(entries, uninstallEntry{
+			Key:             name,
+			DisplayName:     value("DisplayName"),
+			DisplayVersion:  value("DisplayVersion"),
+			Publisher:       value("Publisher"),
+			InstallDate:     value("InstallDate"),
+			InstallLocation: value("InstallLocation"),
+		})
+		sub.Close()
+	}
+	return entries, nil
+}
@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+)
+
+// builtinSection é uma seção nativa do inventário. Todas rodam em paralelo
+// em CollectInventory, como os plugins, com panic convertido em erro; a
+// falha de uma seção required derruba a coleta, a das demais só gera um
+// aviso. collect preenche o próprio campo de d.
+type builtinSection struct {
+	name     string
+	required bool
+	// enabled nil indica seção sempre coletada
+	enabled func(config *CollectorConfig) bool
+	collect func(c *SystemCollector, ctx context.Context, d *InventoryData) error
+}
+
+// sectionToggle habilita a seção enquanto ela não for desligada
+func sectionToggle(section string) func(*CollectorConfig) bool {
+	return func(config *CollectorConfig) bool {
+		return config.sectionEnabled(section)
+	}
+}
+
+// builtinSections são as seções nativas, na ordem de CollectInventory
+var builtinSections = []builtinSection{
+	{
+		name:     SectionSystem,
+		required: true,
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectSystemInfoInternal(ctx)
+			if err != nil {
+				return err
+			}
+			d.System = *info
+			return nil
+		},
+	},
+	{
+		name:     SectionHardware,
+		required: true,
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectHardwareInfoInternal(ctx)
+			if err != nil {
+				return err
+			}
+			d.Hardware = *info
+			return nil
+		},
+	},
+	{
+		name:     SectionSoftware,
+		required: true,
+		enabled:  sectionToggle(SectionSoftware),
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectSoftwareInfoInternal(ctx)
+			if err != nil {
+				return err
+			}
+			d.Software = *info
+			return nil
+		},
+	},
+	{
+		name:     SectionNetwork,
+		required: true,
+		enabled:  sectionToggle(SectionNetwork),
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectNetworkInfoInternal(ctx)
+			if err != nil {
+				return err
+			}
+			d.Network = *info
+			return nil
+		},
+	},
+	{
+		name:    SectionMacOSSpecific,
+		enabled: collectsMacOSSpecific,
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectMacOSSpecificInternal(ctx)
+			if err != nil {
+				return err
+			}
+			d.MacOSSpecific = info
+			return nil
+		},
+	},
+	{
+		// Políticas de gerenciamento no Windows (no macOS vêm junto das
+		// informações específicas)
+		name: SectionGroupPolicies,
+		enabled: func(config *CollectorConfig) bool {
+			return collectsGroupPolicies() && config.sectionEnabled(SectionGroupPolicies)
+		},
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			policies, err := c.collectPolicies(ctx)
+			if err != nil {
+				return err
+			}
+			d.WindowsSpecific = &WindowsInfo{Policies: policies}
+			return nil
+		},
+	},
+	{
+		name:    SectionAccounts,
+		enabled: collectsAccounts,
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectAccounts(ctx)
+			if err != nil {
+				return err
+			}
+			d.Accounts = info
+			return nil
+		},
+	},
+	{
+		name:    SectionCertificates,
+		enabled: sectionToggle(SectionCertificates),
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			info, err := c.collectCertificateSummary(ctx)
+			if err != nil {
+				return err
+			}
+			d.Certificates = info
+			return nil
+		},
+	},
+	{
+		// Cada verificação de postura falha sozinha
+		name:    SectionSecurityPosture,
+		enabled: sectionToggle(SectionSecurityPosture),
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			d.SecurityPosture = c.collectSecurityPosture(ctx)
+			return nil
+		},
+	},
+	{
+		// Falhas de detecção viram valores unknown
+		name:    SectionVirtualization,
+		enabled: sectionToggle(SectionVirtualization),
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			d.Virtualization = c.collectVirtualization(ctx)
+			return nil
+		},
+	},
+	{
+		// Plugins registrados com Register e de SetPlugins; cada um falha
+		// sozinho
+		name:    SectionCustom,
+		enabled: collectsCustom,
+		collect: func(c *SystemCollector, ctx context.Context, d *InventoryData) error {
+			d.Custom = c.collectPlugins(ctx)
+			return nil
+		},
+	},
+}
+
+// collectsCustom indica se há plugins (registrados ou de SetPlugins) e a
+// seção custom não foi desligada
+func collectsCustom(config *CollectorConfig) bool {
+	return len(pluginsFor(config)) > 0 && config.sectionEnabled(SectionCustom)
+}
+
+// isBuiltinSection indica se name já é uma seção nativa, reservada para
+// que um plugin não a sombreie
+func isBuiltinSection(name string) bool {
+	switch name {
+	case SectionSystemProfiler, SectionConfigurationProfiles, "collector", "machine_id", "timestamp", "collected_at":
+		return true
+	}
+	for _, section := range builtinSections {
+		if section.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runSection executa uma seção nativa convertendo panic em erro. Diferente
+// dos plugins, a coleta espera a seção terminar, já que ela escreve direto
+// no inventário.
+func (c *SystemCollector) runSection(ctx context.Context, section builtinSection, d *InventoryData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return section.collect(c, ctx, d)
+}
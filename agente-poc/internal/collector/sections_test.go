@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSectionCombinations(t *testing.T) {
+	for _, software := range []bool{true, false} {
+		for _, network := range []bool{true, false} {
+			t.Run(fmt.Sprintf("software=%t,network=%t", software, network), func(t *testing.T) {
+				c := newTestCollector(t)
+				c.SetCommandRunner(newCountingRunner(nil))
+				if err := c.SetSections(map[string]bool{SectionSoftware: software, SectionNetwork: network}); err != nil {
+					t.Fatal(err)
+				}
+
+				availability := c.Availability()
+				if availability[SectionSoftware] != software || availability[SectionNetwork] != network || !availability[SectionSystem] || !availability[SectionHardware] {
+					t.Fatalf("availability = %v", availability)
+				}
+
+				inventory, err := c.CollectInventory()
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Seção desligada vem vazia e marcada, nunca nil
+				if inventory.Software.Skipped == software || inventory.Network.Skipped == network {
+					t.Fatalf("skipped: software %t, network %t", inventory.Software.Skipped, inventory.Network.Skipped)
+				}
+				if !software && len(inventory.Software.RunningProcesses) != 0 {
+					t.Fatal("processes collected with software disabled")
+				}
+				if inventory.System.Hostname == "" {
+					t.Fatal("system section missing")
+				}
+
+				data, err := json.Marshal(inventory)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var document map[string]json.RawMessage
+				if err := json.Unmarshal(data, &document); err != nil {
+					t.Fatal(err)
+				}
+				for section, enabled := range map[string]bool{"software": software, "network": network} {
+					var fields map[string]interface{}
+					if err := json.Unmarshal(document[section], &fields); err != nil {
+						t.Fatalf("%s JSON: %v", section, err)
+					}
+					if _, skipped := fields["skipped"]; skipped == enabled {
+						t.Errorf("%s JSON: skipped marker present %t with the section enabled %t", section, skipped, enabled)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConfigSectionsSurviveSettingsUpdates(t *testing.T) {
+	c := newTestCollector(t)
+	if err := c.SetSections(map[string]bool{SectionNetwork: false, SectionSoftware: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// O backend não religa a seção desligada no arquivo de configuração
+	if _, _, err := c.ApplySettings(Settings{MaxProcesses: 10, MaxApplications: 10}); err != nil {
+		t.Fatal(err)
+	}
+	network, err := c.CollectNetworkInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !network.Skipped || c.Availability()[SectionNetwork] {
+		t.Fatal("network re-enabled by a settings update")
+	}
+
+	// Mas pode desligar o que a configuração deixou ligado
+	if _, _, err := c.ApplySettings(Settings{MaxProcesses: 10, MaxApplications: 10, DisabledSections: []string{SectionSoftware}}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Availability()[SectionSoftware] {
+		t.Fatal("software enabled after the backend disabled it")
+	}
+}
+
+func TestSetSectionsValidation(t *testing.T) {
+	c := newTestCollector(t)
+	if err := c.SetSections(map[string]bool{SectionSoftware: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sections := range []map[string]bool{
+		{SectionSystem: false},
+		{SectionHardware: false},
+		{"applications": false},
+	} {
+		if err := c.SetSections(sections); err == nil {
+			t.Errorf("SetSections(%v) accepted", sections)
+		}
+	}
+	// A configuração recusada não substitui a anterior
+	if c.Availability()[SectionSoftware] {
+		t.Fatal("rejected sections replaced the previous ones")
+	}
+
+	// Ligar explicitamente system e hardware é aceito
+	if err := c.SetSections(map[string]bool{SectionSystem: true, SectionHardware: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Availability()[SectionSoftware] {
+		t.Fatal("software still disabled after new sections were set")
+	}
+}
@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// collectRunningServices coleta os serviços da máquina: launchd no macOS,
+// o Service Control Manager no Windows e systemd (ou os scripts de init)
+// no Linux
+func (c *SystemCollector) collectRunningServices(ctx context.Context) ([]Service, error) {
+	c.logger.Debug("Collecting running services...")
+
+	switch runtime.GOOS {
+	case "windows":
+		return collectWindowsServices(ctx)
+	case "linux":
+		return c.collectLinuxServices(ctx)
+	default:
+		output, err := c.runProbe(ctx, "launchctl", "list")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute launchctl: %w", err)
+		}
+		return parseLaunchctlList(output), nil
+	}
+}
+
+// parseLaunchctlList interpreta o launchctl list (PID, status e label,
+// com cabeçalho); PID "-" indica um job carregado mas parado
+func parseLaunchctlList(output []byte) []Service {
+	var services []Service
+	lines := strings.Split(string(output), "\n")
+
+	for _, line := range lines[1:] { // Pular cabeçalho
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		service := Service{
+			Name:   fields[2],
+			Status: fields[1],
+		}
+		if pid, err := strconv.Atoi(fields[0]); err == nil {
+			service.PID = int32(pid)
+		}
+		services = append(services, service)
+	}
+
+	return services
+}
+
+// collectLinuxServices lista as units de serviço do systemd, em JSON
+// (systemd 246+) ou texto; sem systemd, os scripts de init pelo service
+func (c *SystemCollector) collectLinuxServices(ctx context.Context) ([]Service, error) {
+	output, err := c.runProbe(ctx, "systemctl", "list-units", "--type=service", "--all", "--no-pager", "--output=json")
+	if err == nil {
+		if services, parseErr := parseSystemctlJSON(output); parseErr == nil {
+			return services, nil
+		}
+	}
+
+	output, err = c.runProbe(ctx, "systemctl", "list-units", "--type=service", "--all", "--no-pager", "--no-legend", "--plain")
+	if err == nil {
+		return parseSystemctlPlain(output), nil
+	}
+	systemctlErr := err
+
+	output, err = c.runProbe(ctx, "service", "--status-all")
+	if err != nil && len(output) == 0 {
+		return nil, fmt.Errorf("failed to list services (systemctl: %v; service: %w)", systemctlErr, err)
+	}
+	// service --status-all sai com código != 0 quando algum script falha
+	return parseServiceStatusAll(output), nil
+}
+
+// systemctlUnit é uma unit do systemctl list-units --output=json
+type systemctlUnit struct {
+	Unit        string `json:"unit"`
+	Load        string `json:"load"`
+	Active      string `json:"active"`
+	Sub         string `json:"sub"`
+	Description string `json:"description"`
+}
+
+// parseSystemctlJSON interpreta o systemctl list-units --output=json
+func parseSystemctlJSON(output []byte) ([]Service, error) {
+	var units []systemctlUnit
+	if err := json.Unmarshal(output, &units); err != nil {
+		return nil, fmt.Errorf("failed to parse systemctl output: %w", err)
+	}
+
+	services := make([]Service, 0, len(units))
+	for _, unit := range units {
+		if unit.Load == "not-found" {
+			continue
+		}
+		services = append(services, systemdService(unit))
+	}
+	return services, nil
+}
+
+// parseSystemctlPlain interpreta o systemctl list-units --no-legend --plain:
+// unit, load, active, sub e a descrição (com espaços) por linha. Units com
+// falha podem vir precedidas de "●".
+func parseSystemctlPlain(output []byte) []Service {
+	var services []Service
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "●"))
+		if len(fields) < 4 || fields[1] == "not-found" {
+			continue
+		}
+		services = append(services, systemdService(systemctlUnit{
+			Unit:        fields[0],
+			Load:        fields[1],
+			Active:      fields[2],
+			Sub:         fields[3],
+			Description: strings.Join(fields[4:], " "),
+		}))
+	}
+	return services
+}
+
+// systemdService converte a unit; o status é o sub-estado (running,
+// exited, dead, failed), mais específico que o active
+func systemdService(unit systemctlUnit) Service {
+	status := unit.Sub
+	if status == "" {
+		status = unit.Active
+	}
+	return Service{
+		Name:        strings.TrimSuffix(unit.Unit, ".service"),
+		Status:      status,
+		Description: unit.Description,
+	}
+}
+
+// parseServiceStatusAll interpreta o service --status-all dos sistemas sem
+// systemd: " [ + ]  cron", com + rodando, - parado e ? desconhecido
+func parseServiceStatusAll(output []byte) []Service {
+	var services []Service
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		end := strings.Index(line, "]")
+		if end < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[end+1:])
+		if name == "" {
+			continue
+		}
+
+		status := "unknown"
+		switch strings.TrimSpace(line[1:end]) {
+		case "+":
+			status = "running"
+		case "-":
+			status = "stopped"
+		}
+		services = append(services, Service{Name: name, Status: status})
+	}
+	return services
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package collector
+
+import (
+	"context"
+	"fmt"
+)
+
+// collectWindowsServices existe fora do Windows só para o collector compilar
+func collectWindowsServices(ctx context.Context) ([]Service, error) {
+	return nil, fmt.Errorf("the service control manager is only available on Windows")
+}
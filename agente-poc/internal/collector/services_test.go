@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+const (
+	systemctlJSONCommand  = "systemctl list-units --type=service --all --no-pager --output=json"
+	systemctlPlainCommand = "systemctl list-units --type=service --all --no-pager --no-legend --plain"
+	serviceStatusCommand  = "service --status-all"
+)
+
+// systemdServices são as units das fixtures do systemctl, sem a not-found
+var systemdServices = []Service{
+	{Name: "cron", Status: "running", Description: "Regular background program processing daemon"},
+	{Name: "networking", Status: "exited", Description: "Raise network interfaces"},
+	{Name: "nginx", Status: "failed", Description: "A high performance web server and a reverse proxy server"},
+	{Name: "ssh", Status: "dead", Description: "OpenBSD Secure Shell server"},
+}
+
+func TestParseSystemctlJSON(t *testing.T) {
+	services, err := parseSystemctlJSON(readFixture(t, "systemctl_list_units.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(services, systemdServices) {
+		t.Fatalf("services = %+v", services)
+	}
+
+	// systemd anterior ao 246 ignora --output=json e imprime a tabela
+	if _, err := parseSystemctlJSON(readFixture(t, "systemctl_list_units_plain.txt")); err == nil {
+		t.Fatal("table output parsed as JSON")
+	}
+}
+
+func TestParseSystemctlPlain(t *testing.T) {
+	services := parseSystemctlPlain(readFixture(t, "systemctl_list_units_plain.txt"))
+	if !reflect.DeepEqual(services, systemdServices) {
+		t.Fatalf("services = %+v", services)
+	}
+}
+
+func TestParseServiceStatusAll(t *testing.T) {
+	services := parseServiceStatusAll(readFixture(t, "service_status_all.txt"))
+	want := []Service{
+		{Name: "cron", Status: "running"},
+		{Name: "hwclock.sh", Status: "stopped"},
+		{Name: "kmod", Status: "unknown"},
+		{Name: "ssh", Status: "running"},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("services = %+v", services)
+	}
+}
+
+func TestParseLaunchctlList(t *testing.T) {
+	services := parseLaunchctlList(readFixture(t, "launchctl_list.txt"))
+	want := []Service{
+		{Name: "com.apple.SafariHistoryServiceAgent", Status: "0"},
+		{Name: "com.apple.Finder", Status: "0", PID: 412},
+		{Name: "com.apple.quicklook", Status: "-9"},
+	}
+	if !reflect.DeepEqual(services, want) {
+		t.Fatalf("services = %+v", services)
+	}
+}
+
+func TestCollectLinuxServicesFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs map[string][]byte
+		want    []Service
+	}{
+		{
+			name:    "systemd json",
+			outputs: map[string][]byte{systemctlJSONCommand: readFixture(t, "systemctl_list_units.json")},
+			want:    systemdServices,
+		},
+		{
+			// A tabela impressa no lugar do JSON cai para o modo texto
+			name: "systemd without json output",
+			outputs: map[string][]byte{
+				systemctlJSONCommand:  readFixture(t, "systemctl_list_units_plain.txt"),
+				systemctlPlainCommand: readFixture(t, "systemctl_list_units_plain.txt"),
+			},
+			want: systemdServices,
+		},
+		{
+			name:    "init scripts",
+			outputs: map[string][]byte{serviceStatusCommand: readFixture(t, "service_status_all.txt")},
+			want:    parseServiceStatusAll(readFixture(t, "service_status_all.txt")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t)
+			c.SetCommandRunner(newCountingRunner(tt.outputs))
+
+			services, err := c.collectLinuxServices(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(services, tt.want) {
+				t.Fatalf("services = %+v", services)
+			}
+		})
+	}
+
+	// Sem systemctl nem service, o erro cita os dois
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(nil))
+	if services, err := c.collectLinuxServices(context.Background()); err == nil || services != nil {
+		t.Fatalf("services %+v, err %v", services, err)
+	}
+}
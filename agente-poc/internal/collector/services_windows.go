@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// collectWindowsServices lista todos os serviços do Service Control Manager
+// (como sc query state= all) com nome de exibição, estado, tipo de início e
+// PID. Serviços que não podem ser abertos com acesso de leitura ficam só
+// com o nome.
+func collectWindowsServices(ctx context.Context) ([]Service, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer manager.Disconnect()
+
+	names, err := manager.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	services := make([]Service, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		services = append(services, windowsService(manager, name))
+	}
+	return services, nil
+}
+
+// windowsService lê configuração e estado de um serviço
+func windowsService(manager *mgr.Mgr, name string) Service {
+	service := Service{Name: name, Status: "unknown"}
+
+	handle, err := windows.OpenService(manager.Handle, windows.StringToUTF16Ptr(name), windows.SERVICE_QUERY_CONFIG|windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return service
+	}
+	s := &mgr.Service{Name: name, Handle: handle}
+	defer s.Close()
+
+	if config, err := s.Config(); err == nil {
+		service.DisplayName = config.DisplayName
+		service.Description = config.Description
+		service.StartType = windowsStartType(config.StartType, config.DelayedAutoStart)
+	}
+	if status, err := s.Query(); err == nil {
+		service.Status = windowsServiceState(status.State)
+		service.PID = int32(status.ProcessId)
+	}
+	return service
+}
+
+// windowsStartType traduz o tipo de início para os nomes do sc qc
+func windowsStartType(startType uint32, delayed bool) string {
+	switch startType {
+	case windows.SERVICE_BOOT_START:
+		return "boot"
+	case windows.SERVICE_SYSTEM_START:
+		return "system"
+	case mgr.StartAutomatic:
+		if delayed {
+			return "auto_delayed"
+		}
+		return "auto"
+	case mgr.StartManual:
+		return "demand"
+	case mgr.StartDisabled:
+		return "disabled"
+	default:
+		return "unknown"
+	}
+}
+
+// windowsServiceState traduz o estado para os nomes do sc query, em minúsculas
+func windowsServiceState(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "start_pending"
+	case svc.StopPending:
+		return "stop_pending"
+	case svc.Running:
+		return "running"
+	case svc.ContinuePending:
+		return "continue_pending"
+	case svc.PausePending:
+		return "pause_pending"
+	case svc.Paused:
+		return "paused"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func TestWindowsStartType(t *testing.T) {
+	tests := []struct {
+		startType uint32
+		delayed   bool
+		want      string
+	}{
+		{windows.SERVICE_BOOT_START, false, "boot"},
+		{windows.SERVICE_SYSTEM_START, false, "system"},
+		{mgr.StartAutomatic, false, "auto"},
+		{mgr.StartAutomatic, true, "auto_delayed"},
+		{mgr.StartManual, false, "demand"},
+		{mgr.StartDisabled, false, "disabled"},
+		{42, false, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := windowsStartType(tt.startType, tt.delayed); got != tt.want {
+			t.Errorf("windowsStartType(%d, %v) = %q, want %q", tt.startType, tt.delayed, got, tt.want)
+		}
+	}
+}
+
+func TestWindowsServiceState(t *testing.T) {
+	tests := map[svc.State]string{
+		svc.Stopped:         "stopped",
+		svc.StartPending:    "start_pending",
+		svc.StopPending:     "stop_pending",
+		svc.Running:         "running",
+		svc.ContinuePending: "continue_pending",
+		svc.PausePending:    "pause_pending",
+		svc.Paused:          "paused",
+		svc.State(42):       "unknown",
+	}
+	for state, want := range tests {
+		if got := windowsServiceState(state); got != want {
+			t.Errorf("windowsServiceState(%d) = %q, want %q", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+
+	"agente-poc/internal/timeutil"
+)
+
+// Faixas aceitas para os ajustes remotos; valores fora delas são trazidos
+// para o limite mais próximo
+const (
+	minMaxProcesses    = 1
+	maxMaxProcesses    = 1000
+	minMaxApplications = 1
+	maxMaxApplications = 5000
+	minCacheExpiration = 10 * time.Second
+	maxCacheExpiration = 24 * time.Hour
+)
+
+// disableableSections são as seções que o backend pode desligar; system e
+// hardware identificam a máquina e são sempre coletadas
+var disableableSections = map[string]bool{
+	SectionSoftware:        true,
+	SectionNetwork:         true,
+	SectionGroupPolicies:   true,
+	SectionAccounts:        true,
+	SectionCertificates:    true,
+	SectionSecurityPosture: true,
+	SectionVirtualization:  true,
+	SectionCustom:          true,
+}
+
+// Settings são os ajustes do collector que o backend pode trocar em execução
+// (config_update); o restante de CollectorConfig é fixo
+type Settings struct {
+	MaxProcesses        int              `json:"max_processes"`
+	MaxApplications     int              `json:"max_applications"`
+	CacheExpiration     timeutil.Seconds `json:"cache_expiration"`
+	EnableMacOSSpecific bool             `json:"enable_macos_specific"`
+	DisabledSections    []string         `json:"disabled_sections,omitempty"`
+}
+
+// SettingClamp registra um ajuste trazido para dentro da faixa aceita
+type SettingClamp struct {
+	Setting   string      `json:"setting"`
+	Requested interface{} `json:"requested"`
+	Applied   interface{} `json:"applied"`
+}
+
+// configKey guarda no contexto da coleta a configuração fixada em begin
+type configKey struct{}
+
+// cfg retorna a configuração vigente
+func (c *SystemCollector) cfg() *CollectorConfig {
+	return c.config.Load()
+}
+
+// configFor retorna a configuração fixada no início da coleta de ctx, para
+// que um ApplySettings não mude os limites no meio de uma coleta
+func (c *SystemCollector) configFor(ctx context.Context) *CollectorConfig {
+	if config, ok := ctx.Value(configKey{}).(*CollectorConfig); ok {
+		return config
+	}
+	return c.cfg()
+}
+
+// sectionEnabled indica se a seção não foi desligada nem pela configuração
+// local nem pelo backend
+func (config *CollectorConfig) sectionEnabled(section string) bool {
+	if enabled, ok := config.Sections[section]; ok && !enabled {
+		return false
+	}
+	for _, disabled := range config.DisabledSections {
+		if disabled == section {
+			return false
+		}
+	}
+	return true
+}
+
+// DisableableSections retorna, em ordem alfabética, as seções que podem ser
+// desligadas; system e hardware ficam de fora porque são sempre coletadas
+func DisableableSections() []string {
+	sections := make([]string, 0, len(disableableSections))
+	for section := range disableableSections {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// ValidateSections confere o bloco collector_sections da configuração: só
+// seções conhecidas, e apenas as de disableableSections podem ser false
+func ValidateSections(sections map[string]bool) error {
+	for section, enabled := range sections {
+		switch {
+		case disableableSections[section]:
+		case section == SectionSystem || section == SectionHardware:
+			if !enabled {
+				return fmt.Errorf("section %q cannot be disabled", section)
+			}
+		default:
+			return fmt.Errorf("unknown section %q", section)
+		}
+	}
+	return nil
+}
+
+// SetSections fixa as seções ligadas e desligadas pela configuração local;
+// vale a partir da próxima coleta e sobrevive a ApplySettings
+func (c *SystemCollector) SetSections(sections map[string]bool) error {
+	if err := ValidateSections(sections); err != nil {
+		return err
+	}
+	copied := make(map[string]bool, len(sections))
+	for section, enabled := range sections {
+		copied[section] = enabled
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.Sections = copied
+	c.config.Store(&config)
+	return nil
+}
+
+// settings extrai os ajustes remotos da configuração
+func (config *CollectorConfig) settings() *Settings {
+	return &Settings{
+		MaxProcesses:        config.MaxProcesses,
+		MaxApplications:     config.MaxApplications,
+		CacheExpiration:     timeutil.Seconds(config.CacheExpiration),
+		EnableMacOSSpecific: config.EnableMacOSSpecific,
+		DisabledSections:    append([]string(nil), config.DisabledSections...),
+	}
+}
+
+// Settings retorna os ajustes vigentes
+func (c *SystemCollector) Settings() Settings {
+	return *c.cfg().settings()
+}
+
+// ApplySettings troca os ajustes de uma vez só: coletas em andamento
+// terminam com os valores antigos e a próxima já usa os novos. Seções
+// desconhecidas ou não desligáveis recusam a atualização inteira; valores
+// fora da faixa são ajustados e retornados em clamps.
+func (c *SystemCollector) ApplySettings(settings Settings) (applied Settings, clamps []SettingClamp, err error) {
+	disabled := make([]string, 0, len(settings.DisabledSections))
+	seen := make(map[string]bool)
+	for _, section := range settings.DisabledSections {
+		if !disableableSections[section] {
+			return Settings{}, nil, fmt.Errorf("section %q cannot be disabled", section)
+		}
+		if !seen[section] {
+			seen[section] = true
+			disabled = append(disabled, section)
+		}
+	}
+	sort.Strings(disabled)
+
+	clampInt := func(name string, value, min, max int) int {
+		clamped := value
+		if clamped < min {
+			clamped = min
+		} else if clamped > max {
+			clamped = max
+		}
+		if clamped != value {
+			clamps = append(clamps, SettingClamp{Setting: name, Requested: value, Applied: clamped})
+		}
+		return clamped
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	config := *c.cfg()
+	config.MaxProcesses = clampInt("max_processes", settings.MaxProcesses, minMaxProcesses, maxMaxProcesses)
+	config.MaxApplications = clampInt("max_applications", settings.MaxApplications, minMaxApplications, maxMaxApplications)
+
+	expiration := settings.CacheExpiration.Duration()
+	config.CacheExpiration = expiration
+	if expiration < minCacheExpiration {
+		config.CacheExpiration = minCacheExpiration
+	} else if expiration > maxCacheExpiration {
+		config.CacheExpiration = maxCacheExpiration
+	}
+	if config.CacheExpiration != expiration {
+		clamps = append(clamps, SettingClamp{
+			Setting:   "cache_expiration",
+			Requested: settings.CacheExpiration,
+			Applied:   timeutil.Seconds(config.CacheExpiration),
+		})
+	}
+
+	// A coleta específica do macOS só existe no macOS
+	config.EnableMacOSSpecific = settings.EnableMacOSSpecific && runtime.GOOS == "darwin"
+	config.DisabledSections = disabled
+	c.config.Store(&config)
+
+	c.logger.WithField("settings", config.settings()).Info("Collector settings applied")
+	return *config.settings(), clamps, nil
+}
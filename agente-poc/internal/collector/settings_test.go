@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"agente-poc/internal/timeutil"
+)
+
+func TestApplySettingsClamps(t *testing.T) {
+	c := newTestCollector(t)
+
+	applied, clamps, err := c.ApplySettings(Settings{
+		MaxProcesses:        0,
+		MaxApplications:     9000,
+		CacheExpiration:     timeutil.Seconds(time.Second),
+		EnableMacOSSpecific: true,
+		DisabledSections:    []string{SectionNetwork, SectionSoftware, SectionNetwork},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Settings{
+		MaxProcesses:        minMaxProcesses,
+		MaxApplications:     maxMaxApplications,
+		CacheExpiration:     timeutil.Seconds(minCacheExpiration),
+		EnableMacOSSpecific: runtime.GOOS == "darwin",
+		DisabledSections:    []string{SectionNetwork, SectionSoftware},
+	}
+	if !reflect.DeepEqual(applied, want) || !reflect.DeepEqual(c.Settings(), want) {
+		t.Fatalf("applied = %+v, settings = %+v, want %+v", applied, c.Settings(), want)
+	}
+
+	var clamped []string
+	for _, clamp := range clamps {
+		clamped = append(clamped, clamp.Setting)
+	}
+	if !reflect.DeepEqual(clamped, []string{"max_processes", "max_applications", "cache_expiration"}) {
+		t.Fatalf("clamps = %+v", clamps)
+	}
+	if clamps[0].Requested != 0 || clamps[0].Applied != minMaxProcesses {
+		t.Fatalf("max_processes clamp = %+v", clamps[0])
+	}
+
+	// Valores na faixa passam sem ajuste
+	if _, clamps, err := c.ApplySettings(Settings{MaxProcesses: 50, MaxApplications: 100, CacheExpiration: timeutil.Seconds(time.Minute)}); err != nil || len(clamps) != 0 {
+		t.Fatalf("in-range settings: clamps %v, %v", clamps, err)
+	}
+}
+
+func TestApplySettingsRejectsSections(t *testing.T) {
+	c := newTestCollector(t)
+	before := c.Settings()
+
+	for _, section := range []string{SectionSystem, SectionHardware, "drivers"} {
+		_, _, err := c.ApplySettings(Settings{MaxProcesses: 5, MaxApplications: 5, DisabledSections: []string{SectionNetwork, section}})
+		if err == nil {
+			t.Errorf("disabling %s accepted", section)
+		}
+	}
+	// A atualização recusada não muda nada
+	if !reflect.DeepEqual(c.Settings(), before) {
+		t.Fatalf("settings changed by a rejected update: %+v", c.Settings())
+	}
+
+	if err := ValidateSections(map[string]bool{SectionSystem: true, SectionSoftware: false}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sections := range []map[string]bool{{SectionHardware: false}, {"drivers": true}} {
+		if err := ValidateSections(sections); err == nil {
+			t.Errorf("ValidateSections(%v) accepted", sections)
+		}
+	}
+}
+
+func TestApplySettingsBetweenCollections(t *testing.T) {
+	c := newTestCollector(t)
+	if _, _, err := c.ApplySettings(Settings{MaxProcesses: 100, MaxApplications: 200, CacheExpiration: timeutil.Seconds(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A coleta em andamento termina com a configuração com que começou
+	ctx, end, err := c.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ApplySettings(Settings{MaxProcesses: 1, MaxApplications: 200, CacheExpiration: timeutil.Seconds(time.Minute), DisabledSections: []string{SectionSoftware}}); err != nil {
+		t.Fatal(err)
+	}
+	if config := c.configFor(ctx); config.MaxProcesses != 100 || !config.sectionEnabled(SectionSoftware) {
+		t.Fatalf("in-flight collection sees max_processes %d", config.MaxProcesses)
+	}
+	end()
+
+	// A próxima já usa os novos valores
+	software, err := c.CollectSoftwareInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !software.Skipped {
+		t.Fatal("software collected after the section was disabled")
+	}
+
+	if _, _, err := c.ApplySettings(Settings{MaxProcesses: 1, MaxApplications: 200, CacheExpiration: timeutil.Seconds(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	software, err = c.CollectSoftwareInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if software.Skipped || len(software.RunningProcesses) > 1 {
+		t.Fatalf("next collection: skipped %t, %d processes with max_processes 1", software.Skipped, len(software.RunningProcesses))
+	}
+}
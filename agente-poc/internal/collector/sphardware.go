@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultMaxSystemProfilerRawBytes limita o JSON bruto incluído para depuração
+const defaultMaxSystemProfilerRawBytes = 64 * 1024
+
+// SPHardware contém os campos relevantes de `system_profiler SPHardwareDataType`
+type SPHardware struct {
+	ModelName       string `json:"model_name,omitempty"`
+	ModelIdentifier string `json:"model_identifier,omitempty"`
+	ModelNumber     string `json:"model_number,omitempty"`
+	// Chip é o chip_type no Apple Silicon ou o cpu_type em Macs Intel
+	Chip            string `json:"chip,omitempty"`
+	ProcessorSpeed  string `json:"processor_speed,omitempty"`
+	Processors      string `json:"processors,omitempty"`
+	Memory          string `json:"memory,omitempty"`
+	SerialNumber    string `json:"serial_number,omitempty"`
+	PlatformUUID    string `json:"platform_uuid,omitempty"`
+	FirmwareVersion string `json:"firmware_version,omitempty"`
+	OSLoaderVersion string `json:"os_loader_version,omitempty"`
+	SMCVersion      string `json:"smc_version,omitempty"`
+	AppleSilicon    bool   `json:"apple_silicon"`
+}
+
+// spHardwareResult guarda os campos tipados e a saída bruta do system_profiler
+type spHardwareResult struct {
+	hardware *SPHardware
+	raw      []byte
+}
+
+// getSPHardware executa o system_profiler uma vez por coleta; o resultado é
+// compartilhado pelo inventário macOS, pelo machine ID e pelos campos de patrimônio
+func (c *SystemCollector) getSPHardware(ctx context.Context) (*spHardwareResult, error) {
+	value, err := cycleValue(ctx, "parsed:SPHardwareDataType", func() (interface{}, error) {
+		output, err := c.runProbe(ctx, "system_profiler", "SPHardwareDataType", "-json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute system_profiler: %w", err)
+		}
+
+		hardware, err := parseSPHardware(output)
+		if err != nil {
+			return nil, err
+		}
+		return &spHardwareResult{hardware: hardware, raw: output}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*spHardwareResult), nil
+}
+
+// parseSPHardware extrai os campos tipados da saída JSON do system_profiler.
+// As chaves variam entre Intel (cpu_type, current_processor_speed,
+// number_processors numérico) e Apple Silicon (chip_type, number_processors
+// como "proc 10:8:2").
+func parseSPHardware(data []byte) (*SPHardware, error) {
+	var result struct {
+		SPHardwareDataType []map[string]interface{} `json:"SPHardwareDataType"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse system_profiler output: %w", err)
+	}
+	if len(result.SPHardwareDataType) == 0 {
+		return nil, fmt.Errorf("SPHardwareDataType not found in system_profiler output")
+	}
+
+	item := result.SPHardwareDataType[0]
+	field := func(key string) string {
+		switch value := item[key].(type) {
+		case string:
+			return strings.TrimSpace(value)
+		case float64:
+			return fmt.Sprintf("%d", int64(value))
+		default:
+			return ""
+		}
+	}
+
+	hardware := &SPHardware{
+		ModelName:       field("machine_name"),
+		ModelIdentifier: field("machine_model"),
+		ModelNumber:     field("model_number"),
+		Chip:            field("chip_type"),
+		ProcessorSpeed:  field("current_processor_speed"),
+		Processors:      field("number_processors"),
+		Memory:          field("physical_memory"),
+		SerialNumber:    field("serial_number"),
+		PlatformUUID:    field("platform_UUID"),
+		FirmwareVersion: field("boot_rom_version"),
+		OSLoaderVersion: field("os_loader_version"),
+		SMCVersion:      field("SMC_version_system"),
+	}
+
+	hardware.AppleSilicon = hardware.Chip != ""
+	if !hardware.AppleSilicon {
+		hardware.Chip = field("cpu_type")
+	}
+
+	return hardware, nil
+}
+
+// systemProfilerRaw retorna o JSON bruto limitado a MaxSystemProfilerRawBytes
+func (c *SystemCollector) systemProfilerRaw(raw []byte) (string, bool) {
+	limit := c.cfg().MaxSystemProfilerRawBytes
+	if limit <= 0 {
+		limit = defaultMaxSystemProfilerRawBytes
+	}
+	if len(raw) <= limit {
+		return string(raw), false
+	}
+	return string(raw[:limit]), true
+}
+
+// applySPHardware preenche os campos de patrimônio do HardwareInfo
+func applySPHardware(info *HardwareInfo, hardware *SPHardware) {
+	info.System.Manufacturer = "Apple"
+	info.System.Model = hardware.ModelIdentifier
+	info.System.SerialNumber = hardware.SerialNumber
+	info.System.UUID = hardware.PlatformUUID
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSPHardware(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    SPHardware
+	}{
+		{
+			fixture: "sphardware_intel.json",
+			want: SPHardware{
+				ModelName:       "MacBook Pro",
+				ModelIdentifier: "MacBookPro16,1",
+				Chip:            "6-Core Intel Core i7",
+				ProcessorSpeed:  "2,6 GHz",
+				Processors:      "1",
+				Memory:          "16 GB",
+				SerialNumber:    "C02XXXXXXXXX",
+				PlatformUUID:    "00000000-0000-0000-0000-00000000AAAA",
+				FirmwareVersion: "1968.100.17.0.0 (iBridge: 20.16.4252.0.0,0)",
+				SMCVersion:      "2.46f12",
+				AppleSilicon:    false,
+			},
+		},
+		{
+			fixture: "sphardware_apple_silicon.json",
+			want: SPHardware{
+				ModelName:       "MacBook Pro",
+				ModelIdentifier: "Mac14,9",
+				ModelNumber:     "Z17G000XXLL/A",
+				Chip:            "Apple M2 Pro",
+				Processors:      "proc 10:6:4",
+				Memory:          "32 GB",
+				SerialNumber:    "XXXXXXXXXX",
+				PlatformUUID:    "00000000-0000-0000-0000-00000000BBBB",
+				FirmwareVersion: "10151.121.1",
+				OSLoaderVersion: "10151.121.1",
+				AppleSilicon:    true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			hardware, err := parseSPHardware(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *hardware != tt.want {
+				t.Fatalf("\n got %+v\nwant %+v", *hardware, tt.want)
+			}
+
+			var info HardwareInfo
+			applySPHardware(&info, hardware)
+			if info.System.Manufacturer != "Apple" || info.System.Model != tt.want.ModelIdentifier ||
+				info.System.SerialNumber != tt.want.SerialNumber || info.System.UUID != tt.want.PlatformUUID {
+				t.Fatalf("asset fields = %+v", info.System)
+			}
+		})
+	}
+}
+
+func TestParseSPHardwareInvalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"not json":      "system_profiler: command not found",
+		"missing type":  `{"SPSoftwareDataType": [{}]}`,
+		"empty section": `{"SPHardwareDataType": []}`,
+	} {
+		if _, err := parseSPHardware([]byte(input)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestSystemProfilerRawLimit(t *testing.T) {
+	c := newTestCollector(t)
+	raw := []byte(strings.Repeat("x", defaultMaxSystemProfilerRawBytes+10))
+
+	got, truncated := c.systemProfilerRaw(raw)
+	if !truncated || len(got) != defaultMaxSystemProfilerRawBytes {
+		t.Fatalf("default limit: %d bytes, truncated %t", len(got), truncated)
+	}
+
+	config := *c.cfg()
+	config.MaxSystemProfilerRawBytes = 16
+	c.config.Store(&config)
+	if got, truncated := c.systemProfilerRaw([]byte("short")); truncated || got != "short" {
+		t.Fatalf("short output: %q, truncated %t", got, truncated)
+	}
+	if got, truncated := c.systemProfilerRaw(raw); !truncated || len(got) != 16 {
+		t.Fatalf("custom limit: %d bytes, truncated %t", len(got), truncated)
+	}
+}
@@ -1,6 +1,9 @@
 package collector
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // SystemInfo contém informações básicas do sistema
 type SystemInfo struct {
@@ -19,7 +22,11 @@ type HardwareInfo struct {
 	CPU    CPUInfo    `json:"cpu"`
 	Memory MemoryInfo `json:"memory"`
 	Disk   []DiskInfo `json:"disk"`
-	System struct {
+	// GPUs é vazio em máquinas sem adaptador de vídeo e nil se a coleta falhou
+	GPUs []GPUInfo `json:"gpus"`
+	// Criptografia de disco (FileVault, BitLocker, LUKS); nil se a coleta falhou
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+	System     struct {
 		Manufacturer string `json:"manufacturer"`
 		Model        string `json:"model"`
 		SerialNumber string `json:"serial_number"`
@@ -33,11 +40,14 @@ type CPUInfo struct {
 	Cores       int32     `json:"cores"`
 	Threads     int32     `json:"threads"`
 	Frequency   float64   `json:"frequency_mhz"`
-	Usage       []float64 `json:"usage_percent"`
+	Usage       []float64 `json:"usage_percent"` // por núcleo lógico
+	UsageTotal  float64   `json:"usage_total_percent"`
 	Temperature float64   `json:"temperature_celsius,omitempty"`
 	CacheSize   int32     `json:"cache_size_kb,omitempty"`
 	Vendor      string    `json:"vendor"`
 	Family      string    `json:"family"`
+	// Clusters de núcleos heterogêneos (Apple Silicon)
+	Clusters []CPUCluster `json:"clusters,omitempty"`
 }
 
 // MemoryInfo contém informações de memória
@@ -50,6 +60,8 @@ type MemoryInfo struct {
 	Cached      uint64   `json:"cached_bytes,omitempty"`
 	Buffers     uint64   `json:"buffers_bytes,omitempty"`
 	Swap        SwapInfo `json:"swap"`
+	// Darwin traz pressão de memória e a divisão do Monitor de Atividade (só macOS)
+	Darwin *DarwinMemoryInfo `json:"darwin,omitempty"`
 }
 
 // SwapInfo contém informações de swap
@@ -66,12 +78,19 @@ type DiskInfo struct {
 	Mountpoint  string  `json:"mountpoint"`
 	Fstype      string  `json:"fstype"`
 	Total       uint64  `json:"total_bytes"`
-	Free        uint64  `json:"free_bytes"`
-	Used        uint64  `json:"used_bytes"`
-	UsedPercent float64 `json:"used_percent"`
+	Free        uint64  `json:"free_bytes" diff:"-"`
+	Used        uint64  `json:"used_bytes" diff:"-"`
+	UsedPercent float64 `json:"used_percent" diff:"-"`
 	Inodes      uint64  `json:"inodes,omitempty"`
-	InodesFree  uint64  `json:"inodes_free,omitempty"`
-	InodesUsed  uint64  `json:"inodes_used,omitempty"`
+	InodesFree  uint64  `json:"inodes_free,omitempty" diff:"-"`
+	InodesUsed  uint64  `json:"inodes_used,omitempty" diff:"-"`
+
+	// Atributos do volume no macOS (sensibilidade a maiúsculas, criptografia,
+	// container APFS), com EnableMacOSSpecific
+	Darwin *DarwinVolumeInfo `json:"darwin,omitempty"`
+
+	// Saúde SMART do disco físico da partição, com EnableSmart
+	Health *DiskHealth `json:"health,omitempty"`
 }
 
 // SoftwareInfo contém informações de software
@@ -80,6 +99,9 @@ type SoftwareInfo struct {
 	RunningServices       []Service     `json:"running_services"`
 	RunningProcesses      []Process     `json:"running_processes"`
 	SystemUpdates         []Update      `json:"system_updates,omitempty"`
+	Warnings              []string      `json:"warnings,omitempty"`
+	// Skipped indica que a seção está desligada e não foi coletada
+	Skipped bool `json:"skipped,omitempty"`
 }
 
 // Application representa uma aplicação instalada
@@ -87,7 +109,7 @@ type Application struct {
 	Name        string `json:"name"`
 	Version     string `json:"version"`
 	Path        string `json:"path"`
-	Size        int64  `json:"size_bytes,omitempty"`
+	Size        int64  `json:"size_bytes,omitempty" diff:"-"`
 	InstallDate string `json:"install_date,omitempty"`
 	Vendor      string `json:"vendor,omitempty"`
 }
@@ -95,8 +117,9 @@ type Application struct {
 // Service representa um serviço em execução
 type Service struct {
 	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
 	Status      string `json:"status"`
-	PID         int32  `json:"pid,omitempty"`
+	PID         int32  `json:"pid,omitempty" diff:"-"`
 	StartType   string `json:"start_type,omitempty"`
 	Description string `json:"description,omitempty"`
 }
@@ -111,6 +134,10 @@ type Process struct {
 	Status      string  `json:"status"`
 	User        string  `json:"user"`
 	StartTime   string  `json:"start_time"`
+
+	// Média exponencial do uso de CPU no intervalo de coleta, mantida pelo
+	// ProcessCPUSampler; ausente enquanto não há duas amostras do processo
+	SustainedCPUPercent *float64 `json:"sustained_cpu_percent,omitempty"`
 }
 
 // Update representa uma atualização do sistema
@@ -129,6 +156,27 @@ type NetworkInfo struct {
 	Statistics   NetworkStatistics   `json:"statistics"`
 	DefaultRoute string              `json:"default_route,omitempty"`
 	DNSServers   []string            `json:"dns_servers,omitempty"`
+	// Interface da rota padrão e todas as rotas padrão, da maior para a
+	// menor prioridade
+	DefaultInterface string  `json:"default_interface,omitempty"`
+	AllRoutes        []Route `json:"all_routes,omitempty"`
+	// Resolvedores DNS por interface, inclusive os restritos a domínios
+	// (VPNs); DNSServers junta os servidores de todos
+	DNSResolvers []DNSResolver `json:"dns_resolvers,omitempty"`
+	// TopTalkers são os processos com mais tráfego desde a coleta anterior
+	// (network_top_talkers; só no Windows, onde os contadores de I/O por
+	// processo incluem a rede, e somando também o I/O de arquivos)
+	TopTalkers []NetworkTalker `json:"top_talkers,omitempty"`
+	// Skipped indica que a seção está desligada e não foi coletada
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// NetworkTalker é um processo e seu tráfego desde a coleta anterior
+type NetworkTalker struct {
+	PID      int32   `json:"pid"`
+	Name     string  `json:"name,omitempty"`
+	SendRate float64 `json:"send_bytes_per_sec"`
+	RecvRate float64 `json:"recv_bytes_per_sec"`
 }
 
 // NetworkInterface representa uma interface de rede
@@ -138,14 +186,20 @@ type NetworkInterface struct {
 	IPAddresses  []string `json:"ip_addresses"`
 	Status       string   `json:"status"`
 	MTU          int      `json:"mtu"`
-	Speed        uint64   `json:"speed_mbps,omitempty"`
+	Speed        uint64   `json:"speed_mbps,omitempty" diff:"-"`
 	Type         string   `json:"type"`
-	BytesSent    uint64   `json:"bytes_sent"`
-	BytesRecv    uint64   `json:"bytes_recv"`
-	PacketsSent  uint64   `json:"packets_sent"`
-	PacketsRecv  uint64   `json:"packets_recv"`
-	Errors       uint64   `json:"errors"`
-	Drops        uint64   `json:"drops"`
+	BytesSent    uint64   `json:"bytes_sent" diff:"-"`
+	BytesRecv    uint64   `json:"bytes_recv" diff:"-"`
+	PacketsSent  uint64   `json:"packets_sent" diff:"-"`
+	PacketsRecv  uint64   `json:"packets_recv" diff:"-"`
+	Errors       uint64   `json:"errors" diff:"-"`
+	Drops        uint64   `json:"drops" diff:"-"`
+	// Taxas desde a coleta anterior em bytes/s; ausentes na primeira coleta
+	// e quando os contadores voltaram (reboot, interface recriada, estouro),
+	// caso em que CountersReset marca o novo ponto de partida
+	SendRate      *float64 `json:"send_bytes_per_sec,omitempty" diff:"-"`
+	RecvRate      *float64 `json:"recv_bytes_per_sec,omitempty" diff:"-"`
+	CountersReset bool     `json:"counters_reset,omitempty" diff:"-"`
 }
 
 // NetworkConnection representa uma conexão de rede
@@ -169,22 +223,88 @@ type NetworkStatistics struct {
 
 // InventoryData contém todos os dados coletados do sistema
 type InventoryData struct {
-	MachineID     string       `json:"machine_id"`
-	Timestamp     time.Time    `json:"timestamp"`
-	CollectedAt   string       `json:"collected_at"`
-	System        SystemInfo   `json:"system"`
-	Hardware      HardwareInfo `json:"hardware"`
-	Software      SoftwareInfo `json:"software"`
-	Network       NetworkInfo  `json:"network"`
-	MacOSSpecific *MacOSInfo   `json:"macos_specific,omitempty"`
+	MachineID       string       `json:"machine_id"`
+	Timestamp       time.Time    `json:"timestamp"`
+	CollectedAt     string       `json:"collected_at"`
+	System          SystemInfo   `json:"system"`
+	Hardware        HardwareInfo `json:"hardware"`
+	Software        SoftwareInfo `json:"software"`
+	Network         NetworkInfo  `json:"network"`
+	MacOSSpecific   *MacOSInfo   `json:"macos_specific,omitempty"`
+	WindowsSpecific *WindowsInfo `json:"windows_specific,omitempty"`
+	// Contas locais, só com EnableAccounts
+	Accounts *AccountsInfo `json:"accounts,omitempty"`
+	// Resumo do trust store e certificados fora da allowlist
+	Certificates *CertificatesInfo `json:"certificates,omitempty"`
+	// Firewall, bloqueio de tela, SIP/Gatekeeper e acesso remoto
+	SecurityPosture *SecurityPosture `json:"security_posture,omitempty"`
+	// Host virtualizado e runtimes de contêiner instalados
+	Virtualization *VirtualizationInfo `json:"virtualization,omitempty"`
+	// Saídas dos plugins registrados (ver CollectorPlugin), por nome
+	Custom map[string]json.RawMessage `json:"custom,omitempty"`
+
+	// Ajustes do collector vigentes nesta coleta (ver ApplySettings)
+	Collector *Settings `json:"collector,omitempty"`
+
+	// Seções incluídas e descartadas pelo Planner, com a restrição que
+	// causou cada descarte
+	Plan *InventoryPlan `json:"plan,omitempty"`
+
+	// Mudanças desde o inventário anterior (ver DiffInventories)
+	Changes *InventoryDiff `json:"changes,omitempty"`
 }
 
 // MacOSInfo contém informações específicas do macOS
 type MacOSInfo struct {
-	SystemProfiler  map[string]interface{} `json:"system_profiler,omitempty"`
-	LaunchdServices []LaunchdService       `json:"launchd_services,omitempty"`
-	Homebrew        *HomebrewInfo          `json:"homebrew,omitempty"`
-	XcodeVersion    string                 `json:"xcode_version,omitempty"`
+	Hardware        *SPHardware      `json:"hardware,omitempty"`
+	LaunchdServices []LaunchdService `json:"launchd_services,omitempty"`
+	Homebrew        *HomebrewInfo    `json:"homebrew,omitempty"`
+	XcodeVersion    string           `json:"xcode_version,omitempty"`
+	Policies        *PolicyInfo      `json:"policies,omitempty"`
+	TimeMachine     *TimeMachineInfo `json:"time_machine,omitempty"`
+
+	// JSON bruto do system_profiler, apenas com IncludeRawSystemProfiler
+	SystemProfilerRaw          string `json:"system_profiler_raw,omitempty"`
+	SystemProfilerRawTruncated bool   `json:"system_profiler_raw_truncated,omitempty"`
+}
+
+// WindowsInfo contém informações específicas do Windows
+type WindowsInfo struct {
+	Policies *PolicyInfo `json:"policies,omitempty"`
+}
+
+// Origens de políticas de gerenciamento
+const (
+	PolicySourceProfiles    = "configuration_profiles"
+	PolicySourceGroupPolicy = "group_policy"
+)
+
+// PolicyInfo resume as políticas de MDM/GPO aplicadas na máquina
+type PolicyInfo struct {
+	Source   string                 `json:"source"`
+	Count    int                    `json:"count"`
+	Profiles []ConfigurationProfile `json:"profiles,omitempty"`
+	GPOs     []GroupPolicyObject    `json:"gpos,omitempty"`
+}
+
+// ConfigurationProfile representa um perfil de configuração instalado (macOS).
+// Apenas os tipos de payload são reportados, nunca o conteúdo.
+type ConfigurationProfile struct {
+	Name         string    `json:"name"`
+	Identifier   string    `json:"identifier"`
+	Organization string    `json:"organization,omitempty"`
+	Scope        string    `json:"scope"`
+	PayloadTypes []string  `json:"payload_types"`
+	InstallDate  time.Time `json:"install_date,omitempty"`
+}
+
+// GroupPolicyObject representa uma GPO aplicada (Windows)
+type GroupPolicyObject struct {
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	LinkPath  string    `json:"link_path,omitempty"`
+	Order     int       `json:"order,omitempty"`
+	AppliedAt time.Time `json:"applied_at,omitempty"`
 }
 
 // LaunchdService representa um serviço do launchd
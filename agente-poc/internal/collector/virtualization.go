@@ -0,0 +1,450 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// CacheKeyVirtualization é a chave de cache da detecção de virtualização e
+// dos runtimes de contêiner, que mudam raramente
+const CacheKeyVirtualization = "virtualization"
+
+// Tipos de host em VirtualizationInfo.Host
+const (
+	HostPhysical = "physical"
+	HostVirtual  = "virtual"
+	HostUnknown  = "unknown"
+)
+
+// VirtualizationInfo diz se a própria máquina é uma VM e quais runtimes de
+// contêiner estão instalados
+type VirtualizationInfo struct {
+	// Host é physical, virtual ou unknown (detecção falhou)
+	Host string `json:"host"`
+	// Hypervisor é o nome normalizado (kvm, vmware, hyperv, virtualbox,
+	// xen, parallels, apple...) ou unknown quando a VM não é identificada
+	Hypervisor string             `json:"hypervisor,omitempty"`
+	Source     string             `json:"source,omitempty"`
+	Runtimes   []ContainerRuntime `json:"container_runtimes"`
+}
+
+// ContainerRuntime é um runtime de contêiner instalado. Contagens ficam
+// ausentes quando o daemon não respondeu.
+type ContainerRuntime struct {
+	Name              string `json:"name"`
+	Version           string `json:"version,omitempty"`
+	Running           bool   `json:"running"`
+	ContainersRunning *int   `json:"containers_running,omitempty"`
+	Containers        *int   `json:"containers,omitempty"`
+	Images            *int   `json:"images,omitempty"`
+	// RunningInstances conta as VMs ativas do colima/lima
+	RunningInstances *int   `json:"running_instances,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// hypervisorModels associa trechos do fabricante/modelo (DMI, hw.model ou
+// Win32_ComputerSystem) ao hypervisor
+var hypervisorModels = []struct {
+	marker     string
+	hypervisor string
+}{
+	{"vmware", "vmware"},
+	{"virtualbox", "virtualbox"},
+	{"innotek", "virtualbox"},
+	{"kvm", "kvm"},
+	{"qemu", "qemu"},
+	{"standard pc", "qemu"},
+	{"xen", "xen"},
+	{"hvm domu", "xen"},
+	{"parallels", "parallels"},
+	{"virtualmac", "apple"},
+	{"amazon ec2", "kvm"},
+	{"google compute engine", "kvm"},
+	{"microsoft corporation virtual machine", "hyperv"},
+	{"virtual machine", "hyperv"},
+}
+
+// hypervisorFromModel identifica o hypervisor pelo fabricante e modelo;
+// vazio quando nada indica uma VM
+func hypervisorFromModel(vendor, model string) string {
+	text := strings.ToLower(strings.TrimSpace(vendor + " " + model))
+	for _, entry := range hypervisorModels {
+		if strings.Contains(text, entry.marker) {
+			return entry.hypervisor
+		}
+	}
+	return ""
+}
+
+// collectVirtualization detecta o tipo de host e os runtimes de contêiner,
+// em cache pelo cache_expiration
+func (c *SystemCollector) collectVirtualization(ctx context.Context) *VirtualizationInfo {
+	if cached, ok := c.getFromCache(CacheKeyVirtualization).(*VirtualizationInfo); ok {
+		return cached
+	}
+
+	c.logger.Debug("Collecting virtualization info...")
+	info := &VirtualizationInfo{Host: HostUnknown}
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		err = c.detectLinuxVirtualization(ctx, info)
+	case "darwin":
+		err = c.detectDarwinVirtualization(ctx, info)
+	case "windows":
+		err = c.detectWindowsVirtualization(ctx, info)
+	default:
+		err = fmt.Errorf("virtualization detection is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		c.logger.WithField("error", err).Debug("Failed to detect virtualization")
+		info.Host = HostUnknown
+	}
+
+	info.Runtimes = c.collectContainerRuntimes(ctx)
+	c.setInCache(CacheKeyVirtualization, info, c.configFor(ctx).CacheExpiration)
+	return info
+}
+
+// detectLinuxVirtualization usa o systemd-detect-virt --vm e, sem ele, a
+// flag hypervisor do /proc/cpuinfo com o DMI para nomear o hypervisor
+func (c *SystemCollector) detectLinuxVirtualization(ctx context.Context, info *VirtualizationInfo) error {
+	output, err := c.runProbe(ctx, "systemd-detect-virt", "--vm")
+	if err == nil {
+		info.Source = "systemd-detect-virt"
+		applyDetectVirt(info, strings.TrimSpace(string(output)))
+		return nil
+	}
+	// Sem VM, o systemd-detect-virt imprime "none" e sai com 1
+	if code, ok := exitCode(err); ok && code == 1 {
+		info.Source = "systemd-detect-virt"
+		applyDetectVirt(info, "none")
+		return nil
+	}
+
+	cpuinfo, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return fmt.Errorf("failed to read /proc/cpuinfo: %w", err)
+	}
+	info.Source = "cpuinfo"
+	if !cpuinfoHasHypervisor(cpuinfo) {
+		info.Host = HostPhysical
+		return nil
+	}
+	info.Host = HostVirtual
+	info.Hypervisor = HostUnknown
+	vendor, _ := os.ReadFile("/sys/class/dmi/id/sys_vendor")
+	product, _ := os.ReadFile("/sys/class/dmi/id/product_name")
+	if hypervisor := hypervisorFromModel(string(vendor), string(product)); hypervisor != "" {
+		info.Hypervisor = hypervisor
+	}
+	return nil
+}
+
+// applyDetectVirt interpreta a saída do systemd-detect-virt ("none", "kvm",
+// "vmware", "microsoft", "oracle"...)
+func applyDetectVirt(info *VirtualizationInfo, value string) {
+	switch value {
+	case "none":
+		info.Host = HostPhysical
+	case "":
+		info.Host = HostUnknown
+	default:
+		info.Host = HostVirtual
+		switch value {
+		case "microsoft":
+			info.Hypervisor = "hyperv"
+		case "oracle":
+			info.Hypervisor = "virtualbox"
+		default:
+			info.Hypervisor = value
+		}
+	}
+}
+
+// cpuinfoHasHypervisor procura a flag hypervisor, que o kernel expõe dentro
+// de VMs x86
+func cpuinfoHasHypervisor(cpuinfo []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(cpuinfo))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "flags" {
+			continue
+		}
+		for _, flag := range strings.Fields(value) {
+			if flag == "hypervisor" {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// detectDarwinVirtualization usa o kern.hv_vmm_present (1 dentro de VMs) e,
+// em versões sem ele, a flag VMM de machdep.cpu.features; o hw.model nomeia
+// o hypervisor
+func (c *SystemCollector) detectDarwinVirtualization(ctx context.Context, info *VirtualizationInfo) error {
+	virtual := false
+	if output, err := c.runProbe(ctx, "sysctl", "-n", "kern.hv_vmm_present"); err == nil {
+		info.Source = "kern.hv_vmm_present"
+		virtual = strings.TrimSpace(string(output)) == "1"
+	} else if output, err := c.runProbe(ctx, "sysctl", "-n", "machdep.cpu.features"); err == nil {
+		info.Source = "machdep.cpu.features"
+		virtual = slices.Contains(strings.Fields(string(output)), "VMM")
+	} else {
+		return fmt.Errorf("failed to execute sysctl: %w", err)
+	}
+
+	if !virtual {
+		info.Host = HostPhysical
+		return nil
+	}
+	info.Host = HostVirtual
+	info.Hypervisor = HostUnknown
+	if output, err := c.runProbe(ctx, "sysctl", "-n", "hw.model"); err == nil {
+		if hypervisor := hypervisorFromModel("", string(output)); hypervisor != "" {
+			info.Hypervisor = hypervisor
+		}
+	}
+	return nil
+}
+
+// detectWindowsVirtualization usa fabricante e modelo do
+// Win32_ComputerSystem. HypervisorPresent não serve: é verdadeiro também
+// em máquinas físicas com Hyper-V ou VBS ligados.
+func (c *SystemCollector) detectWindowsVirtualization(ctx context.Context, info *VirtualizationInfo) error {
+	output, err := c.runProbe(ctx, "wmic", "computersystem", "get", "Manufacturer,Model", "/format:csv")
+	if err != nil {
+		return fmt.Errorf("failed to execute wmic: %w", err)
+	}
+	vendor, model, err := parseWmicComputerSystem(output)
+	if err != nil {
+		return err
+	}
+	info.Source = "Win32_ComputerSystem"
+	if hypervisor := hypervisorFromModel(vendor, model); hypervisor != "" {
+		info.Host = HostVirtual
+		info.Hypervisor = hypervisor
+	} else {
+		info.Host = HostPhysical
+	}
+	return nil
+}
+
+// parseWmicComputerSystem lê Manufacturer e Model do wmic computersystem
+// em CSV
+func parseWmicComputerSystem(output []byte) (string, string, error) {
+	reader := csv.NewReader(bytes.NewReader(output))
+	reader.FieldsPerRecord = -1
+
+	var header map[string]int
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse wmic output: %w", err)
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		if header == nil {
+			header = make(map[string]int, len(row))
+			for i, name := range row {
+				header[strings.TrimSpace(name)] = i
+			}
+			continue
+		}
+		column := func(name string) string {
+			if i, ok := header[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		return column("Manufacturer"), column("Model"), nil
+	}
+	return "", "", fmt.Errorf("no computer system in wmic output")
+}
+
+// collectContainerRuntimes lista os runtimes instalados: docker e podman em
+// todas as plataformas e colima/lima no macOS. Um binário ausente deixa o
+// runtime fora da lista; um daemon parado aparece com running false.
+func (c *SystemCollector) collectContainerRuntimes(ctx context.Context) []ContainerRuntime {
+	runtimes := []ContainerRuntime{}
+	if rt, ok := c.dockerRuntime(ctx); ok {
+		runtimes = append(runtimes, rt)
+	}
+	if rt, ok := c.podmanRuntime(ctx); ok {
+		runtimes = append(runtimes, rt)
+	}
+	if runtime.GOOS == "darwin" {
+		if rt, ok := c.colimaRuntime(ctx); ok {
+			runtimes = append(runtimes, rt)
+		}
+		if rt, ok := c.limaRuntime(ctx); ok {
+			runtimes = append(runtimes, rt)
+		}
+	}
+	return runtimes
+}
+
+// notInstalled indica que o binário do runtime não existe
+func notInstalled(err error) bool {
+	return errors.Is(err, exec.ErrNotFound)
+}
+
+// dockerInfo são os campos usados do docker info
+type dockerInfo struct {
+	ServerVersion     string `json:"ServerVersion"`
+	Containers        int    `json:"Containers"`
+	ContainersRunning int    `json:"ContainersRunning"`
+	Images            int    `json:"Images"`
+}
+
+// dockerRuntime consulta o docker info; sem daemon (ou sem permissão no
+// socket) o comando falha e as contagens ficam desconhecidas
+func (c *SystemCollector) dockerRuntime(ctx context.Context) (ContainerRuntime, bool) {
+	rt := ContainerRuntime{Name: "docker"}
+	output, err := c.runProbe(ctx, "docker", "info", "--format", "{{json .}}")
+	if err != nil {
+		if notInstalled(err) {
+			return rt, false
+		}
+		rt.Error = err.Error()
+		return rt, true
+	}
+	if err := parseDockerInfo(output, &rt); err != nil {
+		rt.Error = err.Error()
+	}
+	return rt, true
+}
+
+// parseDockerInfo interpreta o docker info --format '{{json .}}'
+func parseDockerInfo(output []byte, rt *ContainerRuntime) error {
+	var info dockerInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return fmt.Errorf("failed to parse docker info: %w", err)
+	}
+	rt.Running = info.ServerVersion != ""
+	rt.Version = info.ServerVersion
+	if rt.Running {
+		rt.Containers = &info.Containers
+		rt.ContainersRunning = &info.ContainersRunning
+		rt.Images = &info.Images
+	}
+	return nil
+}
+
+// podmanInfo são os campos usados do podman info
+type podmanInfo struct {
+	Store struct {
+		ContainerStore struct {
+			Number  int `json:"number"`
+			Running int `json:"running"`
+		} `json:"containerStore"`
+		ImageStore struct {
+			Number int `json:"number"`
+		} `json:"imageStore"`
+	} `json:"store"`
+	Version struct {
+		Version string `json:"Version"`
+	} `json:"version"`
+}
+
+// podmanRuntime consulta o podman info; o podman não tem daemon, então
+// "running" indica que o armazenamento respondeu
+func (c *SystemCollector) podmanRuntime(ctx context.Context) (ContainerRuntime, bool) {
+	rt := ContainerRuntime{Name: "podman"}
+	output, err := c.runProbe(ctx, "podman", "info", "--format", "json")
+	if err != nil {
+		if notInstalled(err) {
+			return rt, false
+		}
+		rt.Error = err.Error()
+		return rt, true
+	}
+	if err := parsePodmanInfo(output, &rt); err != nil {
+		rt.Error = err.Error()
+	}
+	return rt, true
+}
+
+// parsePodmanInfo interpreta o podman info --format json
+func parsePodmanInfo(output []byte, rt *ContainerRuntime) error {
+	var info podmanInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return fmt.Errorf("failed to parse podman info: %w", err)
+	}
+	rt.Running = true
+	rt.Version = info.Version.Version
+	rt.Containers = &info.Store.ContainerStore.Number
+	rt.ContainersRunning = &info.Store.ContainerStore.Running
+	rt.Images = &info.Store.ImageStore.Number
+	return nil
+}
+
+// colimaRuntime consulta o colima status, que sai com 0 só com a VM ativa
+func (c *SystemCollector) colimaRuntime(ctx context.Context) (ContainerRuntime, bool) {
+	rt := ContainerRuntime{Name: "colima"}
+	_, err := c.runProbe(ctx, "colima", "status")
+	if err != nil {
+		if notInstalled(err) {
+			return rt, false
+		}
+		if _, ok := exitCode(err); !ok {
+			rt.Error = err.Error()
+		}
+		return rt, true
+	}
+	rt.Running = true
+	return rt, true
+}
+
+// limaRuntime conta as instâncias ativas do limactl list --json (um
+// objeto JSON por linha)
+func (c *SystemCollector) limaRuntime(ctx context.Context) (ContainerRuntime, bool) {
+	rt := ContainerRuntime{Name: "lima"}
+	output, err := c.runProbe(ctx, "limactl", "list", "--json")
+	if err != nil {
+		if notInstalled(err) {
+			return rt, false
+		}
+		rt.Error = err.Error()
+		return rt, true
+	}
+	running := parseLimactlList(output)
+	rt.Running = running > 0
+	rt.RunningInstances = &running
+	return rt, true
+}
+
+// parseLimactlList conta as instâncias com status Running
+func parseLimactlList(output []byte) int {
+	running := 0
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var instance struct {
+			Status string `json:"status"`
+		}
+		if err := decoder.Decode(&instance); err != nil {
+			break
+		}
+		if instance.Status == "Running" {
+			running++
+		}
+	}
+	return running
+}
@@ -0,0 +1,262 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+const dockerInfoCommand = "docker info --format {{json .}}"
+
+// exitError produz um *exec.ExitError real com o código pedido
+func exitError(t *testing.T, code int) error {
+	t.Helper()
+	err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Skipf("no shell to produce exit code %d: %v", code, err)
+	}
+	return exitErr
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestDetectLinuxVirtualization(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner CommandRunner
+		want   VirtualizationInfo
+	}{
+		{
+			name:   "kvm",
+			runner: newCountingRunner(map[string][]byte{"systemd-detect-virt --vm": []byte("kvm\n")}),
+			want:   VirtualizationInfo{Host: HostVirtual, Hypervisor: "kvm", Source: "systemd-detect-virt"},
+		},
+		{
+			name:   "hyper-v",
+			runner: newCountingRunner(map[string][]byte{"systemd-detect-virt --vm": []byte("microsoft\n")}),
+			want:   VirtualizationInfo{Host: HostVirtual, Hypervisor: "hyperv", Source: "systemd-detect-virt"},
+		},
+		{
+			// Em máquina física o systemd-detect-virt imprime "none" e sai com 1
+			name:   "bare metal",
+			runner: &fakePackageRunner{errs: map[string]error{"systemd-detect-virt": exitError(t, 1)}},
+			want:   VirtualizationInfo{Host: HostPhysical, Source: "systemd-detect-virt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t)
+			c.SetCommandRunner(tt.runner)
+			info := VirtualizationInfo{Host: HostUnknown}
+			if err := c.detectLinuxVirtualization(context.Background(), &info); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(info, tt.want) {
+				t.Fatalf("info = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestCpuinfoHasHypervisor(t *testing.T) {
+	vm := "processor\t: 0\nvendor_id\t: GenuineIntel\nflags\t\t: fpu vme de pse tsc msr hypervisor lahf_lm\n\nprocessor\t: 1\n"
+	metal := "processor\t: 0\nvendor_id\t: AuthenticAMD\nflags\t\t: fpu vme de pse tsc msr svm lahf_lm\n"
+	if !cpuinfoHasHypervisor([]byte(vm)) {
+		t.Fatal("hypervisor flag not detected")
+	}
+	if cpuinfoHasHypervisor([]byte(metal)) || cpuinfoHasHypervisor(nil) {
+		t.Fatal("bare metal detected as a VM")
+	}
+}
+
+func TestDetectDarwinVirtualization(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs map[string][]byte
+		want    VirtualizationInfo
+	}{
+		{
+			name: "apple virtualization",
+			outputs: map[string][]byte{
+				"sysctl -n kern.hv_vmm_present": []byte("1\n"),
+				"sysctl -n hw.model":            []byte("VirtualMac2,1\n"),
+			},
+			want: VirtualizationInfo{Host: HostVirtual, Hypervisor: "apple", Source: "kern.hv_vmm_present"},
+		},
+		{
+			// macOS antigo, sem kern.hv_vmm_present
+			name: "vmware on intel",
+			outputs: map[string][]byte{
+				"sysctl -n machdep.cpu.features": []byte("FPU VME DE PSE TSC MSR PAE SSE3 VMM\n"),
+				"sysctl -n hw.model":             []byte("VMware7,1\n"),
+			},
+			want: VirtualizationInfo{Host: HostVirtual, Hypervisor: "vmware", Source: "machdep.cpu.features"},
+		},
+		{
+			name:    "bare metal",
+			outputs: map[string][]byte{"sysctl -n kern.hv_vmm_present": []byte("0\n")},
+			want:    VirtualizationInfo{Host: HostPhysical, Source: "kern.hv_vmm_present"},
+		},
+		{
+			// VM não identificada pelo modelo
+			name:    "unknown hypervisor",
+			outputs: map[string][]byte{"sysctl -n kern.hv_vmm_present": []byte("1\n")},
+			want:    VirtualizationInfo{Host: HostVirtual, Hypervisor: HostUnknown, Source: "kern.hv_vmm_present"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t)
+			c.SetCommandRunner(newCountingRunner(tt.outputs))
+			info := VirtualizationInfo{Host: HostUnknown}
+			if err := c.detectDarwinVirtualization(context.Background(), &info); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(info, tt.want) {
+				t.Fatalf("info = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+
+	// Sem sysctl a detecção falha
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(nil))
+	if err := c.detectDarwinVirtualization(context.Background(), &VirtualizationInfo{}); err == nil {
+		t.Fatal("no error without sysctl")
+	}
+}
+
+func TestDetectWindowsVirtualization(t *testing.T) {
+	const wmic = "wmic computersystem get Manufacturer,Model /format:csv"
+	tests := []struct {
+		fixture string
+		want    VirtualizationInfo
+	}{
+		{"wmic_computersystem_hyperv.csv", VirtualizationInfo{Host: HostVirtual, Hypervisor: "hyperv", Source: "Win32_ComputerSystem"}},
+		{"wmic_computersystem_dell.csv", VirtualizationInfo{Host: HostPhysical, Source: "Win32_ComputerSystem"}},
+	}
+	for _, tt := range tests {
+		c := newTestCollector(t)
+		c.SetCommandRunner(newCountingRunner(map[string][]byte{wmic: readFixture(t, tt.fixture)}))
+		info := VirtualizationInfo{Host: HostUnknown}
+		if err := c.detectWindowsVirtualization(context.Background(), &info); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(info, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.fixture, info, tt.want)
+		}
+	}
+
+	// Saída sem linha de dados é erro
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{wmic: []byte("\r\nNode,Manufacturer,Model\r\n")}))
+	if err := c.detectWindowsVirtualization(context.Background(), &VirtualizationInfo{}); err == nil {
+		t.Fatal("empty wmic output parsed")
+	}
+}
+
+func TestHypervisorFromModel(t *testing.T) {
+	tests := []struct {
+		vendor, model, want string
+	}{
+		{"QEMU", "Standard PC (Q35 + ICH9, 2009)", "qemu"},
+		{"innotek GmbH", "VirtualBox", "virtualbox"},
+		{"Amazon EC2", "m5.large", "kvm"},
+		{"Xen", "HVM domU", "xen"},
+		{"Parallels Software International Inc.", "Parallels Virtual Platform", "parallels"},
+		{"LENOVO", "20XW0026BR", ""},
+		{"", "MacBookPro18,3", ""},
+	}
+	for _, tt := range tests {
+		if got := hypervisorFromModel(tt.vendor, tt.model); got != tt.want {
+			t.Errorf("hypervisorFromModel(%q, %q) = %q, want %q", tt.vendor, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCollectContainerRuntimes(t *testing.T) {
+	c := newTestCollector(t)
+	c.SetCommandRunner(newCountingRunner(map[string][]byte{
+		dockerInfoCommand:           readFixture(t, "docker_info.json"),
+		"podman info --format json": readFixture(t, "podman_info.json"),
+		"colima status":             nil,
+		"limactl list --json":       readFixture(t, "limactl_list.json"),
+	}))
+
+	runtimes := c.collectContainerRuntimes(context.Background())
+	want := []ContainerRuntime{
+		{Name: "docker", Version: "27.3.1", Running: true, ContainersRunning: intPtr(2), Containers: intPtr(5), Images: intPtr(12)},
+		{Name: "podman", Version: "5.2.2", Running: true, ContainersRunning: intPtr(1), Containers: intPtr(3), Images: intPtr(7)},
+	}
+	// colima e lima só são consultados no macOS
+	if runtime.GOOS == "darwin" {
+		want = append(want,
+			ContainerRuntime{Name: "colima", Running: true},
+			ContainerRuntime{Name: "lima", Running: true, RunningInstances: intPtr(2)},
+		)
+	}
+	if !reflect.DeepEqual(runtimes, want) {
+		t.Fatalf("runtimes = %+v", runtimes)
+	}
+
+	// Sem nenhum binário a lista fica vazia, não nula
+	c.SetCommandRunner(newCountingRunner(nil))
+	if runtimes := c.collectContainerRuntimes(context.Background()); runtimes == nil || len(runtimes) != 0 {
+		t.Fatalf("runtimes without binaries = %+v", runtimes)
+	}
+}
+
+func TestDockerRuntimeDaemonDown(t *testing.T) {
+	// Com o daemon parado o docker info sai com 1: o runtime aparece sem contagens
+	c := newTestCollector(t)
+	c.SetCommandRunner(&fakePackageRunner{errs: map[string]error{"docker": exitError(t, 1)}})
+
+	rt, ok := c.dockerRuntime(context.Background())
+	if !ok || rt.Running || rt.Error == "" || rt.Containers != nil || rt.Images != nil {
+		t.Fatalf("docker = %+v, %t", rt, ok)
+	}
+
+	// Só o cliente instalado: ServerVersion vazio
+	var client ContainerRuntime
+	if err := parseDockerInfo([]byte(`{"ServerVersion":"","Containers":0}`), &client); err != nil || client.Running || client.Containers != nil {
+		t.Fatalf("client only = %+v, %v", client, err)
+	}
+}
+
+func TestCollectVirtualizationCached(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("runs the Linux detection")
+	}
+	c := newTestCollector(t)
+	runner := newCountingRunner(map[string][]byte{
+		"systemd-detect-virt --vm": []byte("vmware\n"),
+		dockerInfoCommand:          readFixture(t, "docker_info.json"),
+	})
+	c.SetCommandRunner(runner)
+
+	info := c.collectVirtualization(context.Background())
+	if info.Host != HostVirtual || info.Hypervisor != "vmware" || len(info.Runtimes) != 1 || info.Runtimes[0].Name != "docker" {
+		t.Fatalf("info = %+v", info)
+	}
+	runner.snapshot()
+
+	if again := c.collectVirtualization(context.Background()); again != info {
+		t.Fatal("virtualization not served from the cache")
+	}
+	if calls := runner.snapshot(); len(calls) != 0 {
+		t.Fatalf("commands run with a cached result: %v", calls)
+	}
+}
+
+func TestApplyDetectVirtUnknown(t *testing.T) {
+	info := VirtualizationInfo{Host: HostPhysical}
+	applyDetectVirt(&info, "")
+	if info.Host != HostUnknown || info.Hypervisor != "" {
+		t.Fatalf("info = %+v", info)
+	}
+}
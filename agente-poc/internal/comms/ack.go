@@ -0,0 +1,273 @@
+package comms
+
+import (
+	"sync"
+	"time"
+)
+
+// Padrões do protocolo de confirmação do WebSocket (ver WSMessageTypeAck)
+const (
+	// DefaultWSMaxUnacked limita as mensagens sem ack em memória; acima
+	// disso a mais antiga vai para a fila offline
+	DefaultWSMaxUnacked = 1000
+	// DefaultWSDedupWindow é por quanto tempo o ID de um comando recebido é
+	// lembrado para descartar reentregas
+	DefaultWSDedupWindow = 10 * time.Minute
+)
+
+// unackedMessage é uma mensagem confiável enviada e ainda não confirmada
+type unackedMessage struct {
+	message WebSocketMessage
+	// spill é a forma da mensagem na fila offline (entregue por HTTP) se ela
+	// sair do buffer sem ack; nil descarta
+	spill *QueuedMessage
+	// generation é a conexão em que a mensagem foi escrita por último; a
+	// retransmissão só reenvia as escritas em conexões anteriores
+	generation uint64
+}
+
+// ackTracker guarda as mensagens confiáveis sem ack, em ordem de envio, até
+// max; cheio, a mais antiga sai para dar lugar à nova
+type ackTracker struct {
+	mu      sync.Mutex
+	pending []unackedMessage
+	max     int
+}
+
+// newAckTracker cria o buffer com a capacidade informada (zero ou negativa
+// usa DefaultWSMaxUnacked)
+func newAckTracker(max int) *ackTracker {
+	if max <= 0 {
+		max = DefaultWSMaxUnacked
+	}
+	return &ackTracker{max: max}
+}
+
+// track acrescenta uma mensagem pendente; retorna a que saiu do buffer, se
+// ele estava cheio
+func (t *ackTracker) track(entry unackedMessage) *unackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var evicted *unackedMessage
+	if len(t.pending) >= t.max {
+		oldest := t.pending[0]
+		evicted = &oldest
+		t.pending = t.pending[1:]
+	}
+	t.pending = append(t.pending, entry)
+	return evicted
+}
+
+// acknowledge remove as pendentes com id (e do tipo messageType, se
+// informado) e retorna quantas foram confirmadas
+func (t *ackTracker) acknowledge(id, messageType string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	kept := t.pending[:0]
+	acked := 0
+	for _, entry := range t.pending {
+		if entry.message.ID == id && (messageType == "" || entry.message.Type == messageType) {
+			acked++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	t.pending = kept
+	return acked
+}
+
+// untrack desfaz o track de uma mensagem cuja escrita falhou
+func (t *ackTracker) untrack(message WebSocketMessage) {
+	t.acknowledge(message.ID, message.Type)
+}
+
+// stale retorna, em ordem de envio, as mensagens escritas antes da conexão
+// generation e as marca como escritas nela
+func (t *ackTracker) stale(generation uint64) []WebSocketMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var messages []WebSocketMessage
+	for i := range t.pending {
+		if t.pending[i].generation < generation {
+			messages = append(messages, t.pending[i].message)
+			t.pending[i].generation = generation
+		}
+	}
+	return messages
+}
+
+// drain esvazia o buffer e retorna as pendentes em ordem de envio
+func (t *ackTracker) drain() []unackedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := t.pending
+	t.pending = nil
+	return pending
+}
+
+// size retorna quantas mensagens aguardam ack
+func (t *ackTracker) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// dedupWindow lembra os IDs de mensagens recebidas por window, para
+// descartar reentregas (o servidor reenvia o que não recebeu ack)
+type dedupWindow struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+}
+
+// newDedupWindow cria a janela (zero ou negativa usa DefaultWSDedupWindow)
+func newDedupWindow(window time.Duration) *dedupWindow {
+	if window <= 0 {
+		window = DefaultWSDedupWindow
+	}
+	return &dedupWindow{window: window, seen: make(map[string]time.Time)}
+}
+
+// duplicate indica se id já foi aceito dentro da janela, esquecendo os
+// que saíram dela. IDs vazios nunca são duplicatas.
+func (d *dedupWindow) duplicate(id string, now time.Time) bool {
+	if id == "" {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for seenID, at := range d.seen {
+		if now.Sub(at) > d.window {
+			delete(d.seen, seenID)
+		}
+	}
+	_, ok := d.seen[id]
+	return ok
+}
+
+// record registra id como aceito. Só o comando que entrou no canal é
+// registrado: um descartado precisa passar quando o servidor o reenviar.
+func (d *dedupWindow) record(id string, now time.Time) {
+	if id == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen[id] = now
+}
+
+// SendReliable envia uma mensagem que precisa chegar ao backend. Com
+// MessageAcks, ela fica pendente até o ack do servidor e é retransmitida a
+// cada reconexão; se o buffer de pendentes encher, a mais antiga vai para a
+// fila offline na forma spill (nil descarta). Sem MessageAcks equivale a
+// SendMessage. Um erro de escrita (inclusive sem conexão) retorna sem deixar
+// a mensagem pendente, para quem chamou seguir por outro caminho (HTTP).
+func (ws *WebSocketClient) SendReliable(message WebSocketMessage, spill *QueuedMessage) error {
+	if !ws.messageAcks {
+		return ws.SendMessage(message)
+	}
+
+	// Rastrear antes de escrever: o ack pode chegar antes de a escrita retornar
+	ws.connMutex.RLock()
+	generation := ws.generation
+	ws.connMutex.RUnlock()
+	if evicted := ws.acks.track(unackedMessage{message: message, spill: spill, generation: generation}); evicted != nil {
+		ws.spillEvicted(*evicted)
+	}
+
+	if err := ws.writeMessage(message); err != nil {
+		ws.acks.untrack(message)
+		return err
+	}
+	return nil
+}
+
+// spillEvicted manda para a fila offline uma mensagem que saiu do buffer
+// de pendentes sem ack
+func (ws *WebSocketClient) spillEvicted(entry unackedMessage) {
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.UnackedSpilled++ })
+	if entry.spill == nil || ws.spillUnacked == nil {
+		ws.logger.Warning("Unacknowledged %s %s dropped: too many messages awaiting ack", entry.message.Type, entry.message.ID)
+		return
+	}
+	ws.logger.Warning("Unacknowledged %s %s moved to the offline queue: too many messages awaiting ack", entry.message.Type, entry.message.ID)
+	ws.spillUnacked(*entry.spill)
+}
+
+// TakeUnacked esvazia o buffer de pendentes e retorna, na forma da fila
+// offline, as mensagens que têm uma; chamado ao parar o Manager, para que
+// resultados sem ack sobrevivam ao reinício
+func (ws *WebSocketClient) TakeUnacked() []QueuedMessage {
+	var spilled []QueuedMessage
+	for _, entry := range ws.acks.drain() {
+		if entry.spill != nil {
+			spilled = append(spilled, *entry.spill)
+		}
+	}
+	return spilled
+}
+
+// UnackedCount retorna quantas mensagens aguardam ack do servidor
+func (ws *WebSocketClient) UnackedCount() int {
+	return ws.acks.size()
+}
+
+// retransmitUnacked reenvia, em ordem, as mensagens sem ack escritas em
+// conexões anteriores a generation. Na primeira falha para: as restantes
+// continuam pendentes para a próxima reconexão.
+func (ws *WebSocketClient) retransmitUnacked(generation uint64) {
+	if !ws.messageAcks {
+		return
+	}
+
+	stale := ws.acks.stale(generation)
+	for i, message := range stale {
+		if err := ws.enqueueMessage(message, false, true); err != nil {
+			ws.logger.Warning("Failed to retransmit unacknowledged message, %d message(s) kept for the next reconnect: %v", len(stale)-i, err)
+			return
+		}
+		ws.updateMetrics(func(m *WebSocketMetrics) { m.Retransmits++ })
+	}
+	if len(stale) > 0 {
+		ws.logger.Info("Retransmitted %d unacknowledged message(s) after reconnect", len(stale))
+	}
+}
+
+// sendAck confirma o recebimento de uma mensagem com ID. Ping e pong já são
+// respondidos (ou são a resposta) e não recebem ack.
+func (ws *WebSocketClient) sendAck(message WebSocketMessage) {
+	if !ws.messageAcks || message.ID == "" || message.Type == "ping" || message.Type == "pong" {
+		return
+	}
+
+	ack := WebSocketMessage{
+		Type:      WSMessageTypeAck,
+		ID:        message.ID,
+		Timestamp: time.Now(),
+		Data:      AckData{MessageType: message.Type},
+	}
+	if err := ws.writeMessage(ack); err != nil {
+		ws.logger.Debug("Failed to ack %s %s: %v", message.Type, message.ID, err)
+		return
+	}
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.AcksSent++ })
+}
+
+// handleAck remove das pendentes a mensagem confirmada pelo servidor
+func (ws *WebSocketClient) handleAck(message WebSocketMessage) {
+	var messageType string
+	if data, ok := message.Data.(map[string]interface{}); ok {
+		messageType, _ = data["message_type"].(string)
+	}
+
+	acked := ws.acks.acknowledge(message.ID, messageType)
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.AcksReceived++ })
+	ws.logger.Debug("Ack received for %s %s (%d pending message(s) confirmed)", messageType, message.ID, acked)
+}
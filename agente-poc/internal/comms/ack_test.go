@@ -0,0 +1,424 @@
+package comms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ackFrame é um frame visto pelo servidor de teste: conexão (1, 2...),
+// tipo, ID e o campo data decodificado
+type ackFrame struct {
+	connection int
+	kind       string
+	id         string
+	data       map[string]interface{}
+}
+
+// ackServer é um backend WebSocket que registra os frames recebidos, com
+// autoAck confirma na hora cada command_result, e permite ao teste escrever
+// na conexão atual ou derrubá-la
+type ackServer struct {
+	server  *httptest.Server
+	autoAck atomic.Bool
+
+	mu          sync.Mutex
+	frames      []ackFrame
+	conn        *websocket.Conn
+	connections int
+}
+
+func newAckServer(t *testing.T) *ackServer {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	backend := &ackServer{}
+	upgrader := websocket.Upgrader{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		backend.mu.Lock()
+		backend.connections++
+		connection := backend.connections
+		backend.conn = conn
+		backend.mu.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message WebSocketMessage
+			if json.Unmarshal(data, &message) != nil {
+				continue
+			}
+			backend.mu.Lock()
+			fields, _ := message.Data.(map[string]interface{})
+			backend.frames = append(backend.frames, ackFrame{connection: connection, kind: message.Type, id: message.ID, data: fields})
+			backend.mu.Unlock()
+			if message.Type == "command_result" && backend.autoAck.Load() {
+				backend.send(t, ackMessage(message.ID, message.Type))
+			}
+		}
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *ackServer) url() string {
+	return "ws" + strings.TrimPrefix(b.server.URL, "http")
+}
+
+// send escreve na conexão atual
+func (b *ackServer) send(t *testing.T, message WebSocketMessage) {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.conn.WriteJSON(message); err != nil {
+		t.Errorf("server write: %v", err)
+	}
+}
+
+// drop fecha a conexão atual sem aviso
+func (b *ackServer) drop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_ = b.conn.Close()
+}
+
+// received lista os frames do tipo kind, em ordem de chegada
+func (b *ackServer) received(kind string) []ackFrame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var frames []ackFrame
+	for _, frame := range b.frames {
+		if frame.kind == kind {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+func ackMessage(id, messageType string) WebSocketMessage {
+	return WebSocketMessage{Type: WSMessageTypeAck, ID: id, Timestamp: time.Now(), Data: AckData{MessageType: messageType}}
+}
+
+func resultMessage(id string) WebSocketMessage {
+	return WebSocketMessage{Type: "command_result", ID: id, Timestamp: time.Now(), Data: map[string]interface{}{"command_id": id}}
+}
+
+func newAckTestClient(t *testing.T, backend *ackServer, maxUnacked int) *WebSocketClient {
+	t.Helper()
+	ws, err := NewWebSocketClient(WebSocketConfig{
+		URL:            backend.url(),
+		MachineID:      "machine-1",
+		ReconnectDelay: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		MaxReconnects:  -1,
+		PingInterval:   time.Hour,
+		PongTimeout:    time.Minute,
+		MaxQueueSize:   100,
+		Logger:         testLogger(t),
+		MessageAcks:    true,
+		MaxUnacked:     maxUnacked,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func TestRetransmitUnackedAfterReconnect(t *testing.T) {
+	backend := newAckServer(t)
+	ws := newAckTestClient(t, backend, 0)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if err := ws.SendReliable(resultMessage(id), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "the first results", 2*time.Second, func() bool { return len(backend.received("command_result")) == 3 })
+
+	// Só r2 é confirmado antes de a conexão cair
+	backend.send(t, ackMessage("r2", "command_result"))
+	waitFor(t, "the ack of r2", 2*time.Second, func() bool { return ws.UnackedCount() == 2 })
+	backend.autoAck.Store(true)
+	backend.drop()
+
+	// Na nova conexão, r1 e r3 voltam, em ordem, e o ack esvazia as pendentes
+	waitFor(t, "the retransmission", 2*time.Second, func() bool { return ws.UnackedCount() == 0 })
+	var retransmitted []string
+	for _, frame := range backend.received("command_result") {
+		if frame.connection == 2 {
+			retransmitted = append(retransmitted, frame.id)
+		}
+	}
+	if strings.Join(retransmitted, ",") != "r1,r3" {
+		t.Fatalf("retransmitted %v, want [r1 r3]", retransmitted)
+	}
+	if metrics := ws.GetMetrics(); metrics.Retransmits != 2 || metrics.AcksReceived != 3 {
+		t.Fatalf("metrics: %d retransmits, %d acks received", metrics.Retransmits, metrics.AcksReceived)
+	}
+
+	// Confirmadas, não voltam em uma terceira conexão
+	backend.drop()
+	waitFor(t, "the third connection", 2*time.Second, func() bool {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return backend.connections == 3
+	})
+	if err := ws.SendReliable(resultMessage("r4"), nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "r4", 2*time.Second, func() bool { return ws.UnackedCount() == 0 })
+	for _, frame := range backend.received("command_result") {
+		if frame.connection == 3 && frame.id != "r4" {
+			t.Fatalf("acked message %s sent again", frame.id)
+		}
+	}
+}
+
+func TestInboundCommandAckedAndDeduplicated(t *testing.T) {
+	backend := newAckServer(t)
+	ws := newAckTestClient(t, backend, 0)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	command := WebSocketMessage{Type: "command", ID: "cmd-1", Timestamp: time.Now(), Data: map[string]interface{}{"type": "shell", "command": "uptime"}}
+	// Reentrega do servidor (o ack se perdeu) e um ping, que não recebe ack
+	for _, message := range []WebSocketMessage{command, command, {Type: "ping", ID: "ping-1", Timestamp: time.Now()}} {
+		backend.send(t, message)
+	}
+
+	select {
+	case received := <-ws.CommandChannel():
+		if received.ID != "cmd-1" {
+			t.Fatalf("command = %+v", received)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("command not delivered")
+	}
+	waitFor(t, "the duplicate", 2*time.Second, func() bool { return ws.GetMetrics().DuplicateCommands == 1 })
+	select {
+	case duplicate := <-ws.CommandChannel():
+		t.Fatalf("duplicate command delivered: %+v", duplicate)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Cada entrega recebe ack, para o servidor parar de reenviar
+	waitFor(t, "the acks", 2*time.Second, func() bool { return len(backend.received(WSMessageTypeAck)) == 2 })
+	for _, frame := range backend.received(WSMessageTypeAck) {
+		if frame.id != "cmd-1" {
+			t.Fatalf("ack for %s", frame.id)
+		}
+	}
+}
+
+func TestSendReliableSpillsWhenFull(t *testing.T) {
+	backend := newAckServer(t)
+	ws := newAckTestClient(t, backend, 2)
+	var (
+		mu      sync.Mutex
+		spilled []string
+	)
+	ws.spillUnacked = func(message QueuedMessage) {
+		mu.Lock()
+		spilled = append(spilled, message.ID)
+		mu.Unlock()
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// r1 sai do buffer para a fila; r2 (sem forma na fila) é descartado
+	for _, id := range []string{"r1", "r2", "r3", "r4"} {
+		spill := &QueuedMessage{ID: id, Type: "command_result"}
+		if id == "r2" {
+			spill = nil
+		}
+		if err := ws.SendReliable(resultMessage(id), spill); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	got := strings.Join(spilled, ",")
+	mu.Unlock()
+	if got != "r1" || ws.UnackedCount() != 2 || ws.GetMetrics().UnackedSpilled != 2 {
+		t.Fatalf("spilled %q, %d pending, metrics %+v", got, ws.UnackedCount(), ws.GetMetrics())
+	}
+
+	// Ao parar, as pendentes com forma na fila saem para ela
+	taken := ws.TakeUnacked()
+	if len(taken) != 2 || taken[0].ID != "r3" || taken[1].ID != "r4" || ws.UnackedCount() != 0 {
+		t.Fatalf("taken %+v, %d pending", taken, ws.UnackedCount())
+	}
+}
+
+func TestSendReliableWithoutConnection(t *testing.T) {
+	ws := newAckTestClient(t, newAckServer(t), 0)
+	// Sem conexão o erro volta e nada fica pendente: quem chamou segue por HTTP
+	if err := ws.SendReliable(resultMessage("r1"), &QueuedMessage{ID: "r1"}); err == nil {
+		t.Fatal("reliable send without a connection succeeded")
+	}
+	if n := ws.UnackedCount(); n != 0 {
+		t.Fatalf("%d pending after a failed write", n)
+	}
+}
+
+func TestAckTrackerAcknowledgeByType(t *testing.T) {
+	tracker := newAckTracker(10)
+	tracker.track(unackedMessage{message: WebSocketMessage{Type: "command_result", ID: "cmd-1"}})
+	tracker.track(unackedMessage{message: WebSocketMessage{Type: "command_progress", ID: "cmd-1"}})
+	tracker.track(unackedMessage{message: WebSocketMessage{Type: "command_result", ID: "cmd-2"}, generation: 2})
+
+	// O tipo desambigua o mesmo ID
+	if acked := tracker.acknowledge("cmd-1", "command_progress"); acked != 1 || tracker.size() != 2 {
+		t.Fatalf("acked %d, %d pending", acked, tracker.size())
+	}
+	// Só as escritas em conexões anteriores são retransmitidas, uma vez
+	if stale := tracker.stale(2); len(stale) != 1 || stale[0].ID != "cmd-1" {
+		t.Fatalf("stale = %+v", stale)
+	}
+	if stale := tracker.stale(2); len(stale) != 0 {
+		t.Fatalf("stale again = %+v", stale)
+	}
+	// Sem tipo, vale para todas com o ID
+	tracker.track(unackedMessage{message: WebSocketMessage{Type: "status_response", ID: "cmd-2"}})
+	if acked := tracker.acknowledge("cmd-2", ""); acked != 2 || tracker.size() != 1 {
+		t.Fatalf("acked %d, %d pending", acked, tracker.size())
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	dedup := newDedupWindow(time.Minute)
+	now := time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC)
+
+	if dedup.duplicate("cmd-1", now) {
+		t.Fatal("first delivery seen as duplicate")
+	}
+	// Sem record (comando descartado), a reentrega passa
+	if dedup.duplicate("cmd-1", now.Add(time.Second)) {
+		t.Fatal("dropped command seen as duplicate")
+	}
+	dedup.record("cmd-1", now)
+	if !dedup.duplicate("cmd-1", now.Add(30*time.Second)) {
+		t.Fatal("redelivery inside the window not detected")
+	}
+	// Fora da janela o ID é esquecido
+	if dedup.duplicate("cmd-1", now.Add(2*time.Minute)) {
+		t.Fatal("redelivery after the window dropped")
+	}
+	dedup.record("", now)
+	if dedup.duplicate("", now) {
+		t.Fatal("empty ID treated as duplicate")
+	}
+}
+
+func TestWebSocketCommandChannelFullRejectsQueueFull(t *testing.T) {
+	backend := newAckServer(t)
+	m, err := New(&Config{
+		WebSocketURL:   backend.url(),
+		Logger:         testLogger(t),
+		Clock:          newTestClock(),
+		WSPingInterval: time.Hour,
+		WSMessageAcks:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.wsClient.Close() })
+	if err := m.wsClient.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sem o Manager consumindo (ocupado num config_update, por exemplo), o
+	// canal do WebSocket enche com 100 comandos; o 101º é recusado
+	capacity := cap(m.wsClient.CommandChannel())
+	for i := 1; i <= capacity+1; i++ {
+		backend.send(t, WebSocketMessage{Type: "command", ID: fmt.Sprintf("cmd-%d", i), Timestamp: time.Now(), Data: map[string]interface{}{"type": "shell", "command": "uptime"}})
+	}
+	overflow := fmt.Sprintf("cmd-%d", capacity+1)
+	waitFor(t, "the queue_full result", 2*time.Second, func() bool { return len(backend.received("command_result")) == 1 })
+
+	result := backend.received("command_result")[0]
+	if result.id != overflow || result.data["status"] != string(StatusRejected) || result.data["error_code"] != string(ErrCodeCommandQueueFull) {
+		t.Fatalf("result = %+v", result)
+	}
+	// Direto no contador: o resto de ManagerMetrics não é sincronizado e o
+	// envio do resultado ainda pode estar atualizando ResultsSent
+	if dropped := m.commandsDropped.Load(); dropped != 1 {
+		t.Fatalf("CommandsDropped = %d", dropped)
+	}
+	if queued := len(m.wsClient.CommandChannel()); queued != capacity {
+		t.Fatalf("%d commands queued, want %d", queued, capacity)
+	}
+}
+
+func TestDroppedCommandNotAckedNorDeduplicated(t *testing.T) {
+	backend := newAckServer(t)
+	ws := newAckTestClient(t, backend, 0)
+	var dropped []string
+	var droppedMu sync.Mutex
+	ws.commandDropped = func(command Command) {
+		droppedMu.Lock()
+		dropped = append(dropped, command.ID)
+		droppedMu.Unlock()
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	command := func(id string) WebSocketMessage {
+		return WebSocketMessage{Type: "command", ID: id, Timestamp: time.Now(), Data: map[string]interface{}{"type": "shell", "command": "uptime"}}
+	}
+
+	capacity := cap(ws.CommandChannel())
+	for i := 1; i <= capacity; i++ {
+		backend.send(t, command(fmt.Sprintf("cmd-%d", i)))
+	}
+	backend.send(t, command("cmd-late"))
+	waitFor(t, "the accepted commands' acks", 2*time.Second, func() bool { return len(backend.received(WSMessageTypeAck)) == capacity })
+	waitFor(t, "the dropped command", 2*time.Second, func() bool {
+		droppedMu.Lock()
+		defer droppedMu.Unlock()
+		return len(dropped) == 1 && dropped[0] == "cmd-late"
+	})
+	for _, frame := range backend.received(WSMessageTypeAck) {
+		if frame.id == "cmd-late" {
+			t.Fatal("dropped command acked")
+		}
+	}
+
+	// Com espaço no canal, o reenvio do descartado é aceito, não descartado
+	// como duplicata
+	<-ws.CommandChannel()
+	backend.send(t, command("cmd-late"))
+	waitFor(t, "the redelivered command's ack", 2*time.Second, func() bool {
+		acks := backend.received(WSMessageTypeAck)
+		return len(acks) == capacity+1 && acks[capacity].id == "cmd-late"
+	})
+	if duplicates := ws.GetMetrics().DuplicateCommands; duplicates != 0 {
+		t.Fatalf("DuplicateCommands = %d", duplicates)
+	}
+	for len(ws.CommandChannel()) > 1 {
+		<-ws.CommandChannel()
+	}
+	if last := <-ws.CommandChannel(); last.ID != "cmd-late" {
+		t.Fatalf("last command = %+v", last)
+	}
+}
@@ -0,0 +1,561 @@
+package comms
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// Estados do circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Padrões do circuit breaker sem configuração
+const (
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerResetTimeout     = 30 * time.Second
+	DefaultBreakerHalfOpenMaxCalls = 3
+)
+
+// Endpoints com circuit breaker próprio. Heartbeat é crítico; inventário é
+// bulk, com limites próprios, para um pipeline de ingestão lento não
+// derrubar os heartbeats.
+const (
+	EndpointHeartbeat = "/heartbeat"
+	EndpointInventory = "/inventory"
+)
+
+// Classes de endpoint (ver EndpointBreakersConfig)
+const (
+	EndpointClassCritical = "critical"
+	EndpointClassBulk     = "bulk"
+)
+
+// bulkEndpoints são os endpoints da classe bulk; os demais são críticos
+var bulkEndpoints = map[string]bool{
+	EndpointInventory: true,
+}
+
+// breakerProbeTimeout limita o GET /health que testa o backend com um
+// circuito aberto
+const breakerProbeTimeout = 5 * time.Second
+
+// ErrCircuitOpen indica que o envio nem foi tentado porque o circuit breaker
+// do endpoint está aberto; não adianta repetir antes do probe
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerConfig contém configurações do circuit breaker
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	ResetTimeout     time.Duration
+	HalfOpenMaxCalls int
+}
+
+// withDefaults preenche com o padrão os campos não configurados (zero)
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if c.ResetTimeout <= 0 {
+		c.ResetTimeout = DefaultBreakerResetTimeout
+	}
+	if c.HalfOpenMaxCalls <= 0 {
+		c.HalfOpenMaxCalls = DefaultBreakerHalfOpenMaxCalls
+	}
+	return c
+}
+
+// CircuitBreaker é o circuit breaker de um endpoint do backend. Aberto, um
+// probe agendado testa o backend após ResetTimeout em vez de esperar o
+// próximo envio.
+type CircuitBreaker struct {
+	endpoint        string
+	class           string
+	config          CircuitBreakerConfig
+	failures        int
+	lastFailureTime time.Time
+	lastError       string
+	state           string // "closed", "open", "half-open"
+	halfOpenCalls   int
+	mu              sync.RWMutex
+	clock           clock.Clock
+
+	// Métricas: envios e falhas registrados, aberturas a partir de closed,
+	// probes feitos, tempo aberto dos períodos encerrados e início do
+	// período atual (zero se fechado)
+	requests       int64
+	totalFailures  int64
+	trips          int64
+	probes         int64
+	timeOpen       time.Duration
+	lastOpen       time.Duration
+	openedAt       time.Time
+	lastTransition time.Time
+
+	// changed acorda o agendador de probes a cada troca de estado
+	// (compartilhado pelos endpoints de um EndpointBreakers)
+	changed chan struct{}
+	// onTransition recebe cada troca de estado com o status já atualizado,
+	// fora do lock
+	onTransition func(from, to string, status CircuitBreakerStatus)
+}
+
+// CircuitBreakerStatus é o estado do circuit breaker de um endpoint para o
+// health, as métricas e os eventos. Half-open conta como aberto no tempo
+// aberto.
+type CircuitBreakerStatus struct {
+	Endpoint         string     `json:"endpoint"`
+	Class            string     `json:"class"`
+	State            string     `json:"state"`
+	Failures         int        `json:"failures"`
+	FailureThreshold int        `json:"failure_threshold"`
+	ResetTimeout     float64    `json:"reset_timeout"`
+	Requests         int64      `json:"requests"`
+	TotalFailures    int64      `json:"total_failures"`
+	Trips            int64      `json:"trips"`
+	Probes           int64      `json:"probes"`
+	TimeOpenSeconds  float64    `json:"time_open_seconds"`
+	LastOpenSeconds  float64    `json:"last_open_seconds,omitempty"`
+	LastTransition   time.Time  `json:"last_transition,omitempty"`
+	NextProbe        *time.Time `json:"next_probe,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// setStateLocked troca o estado e atualiza as métricas; chamado com mu
+// travado. Retorna o estado anterior.
+func (cb *CircuitBreaker) setStateLocked(to string) string {
+	from := cb.state
+	if from == to {
+		return from
+	}
+
+	now := cb.clock.Now()
+	switch {
+	case from == BreakerClosed:
+		cb.trips++
+		cb.openedAt = now
+	case to == BreakerClosed:
+		cb.lastOpen = now.Sub(cb.openedAt)
+		cb.timeOpen += cb.lastOpen
+		cb.openedAt = time.Time{}
+	}
+	if to == BreakerHalfOpen {
+		cb.halfOpenCalls = 0
+	}
+	cb.state = to
+	cb.lastTransition = now
+	return from
+}
+
+// notify avisa o agendador e o callback de uma troca de estado; chamado sem
+// o lock
+func (cb *CircuitBreaker) notify(from, to string) {
+	if from == to {
+		return
+	}
+	select {
+	case cb.changed <- struct{}{}:
+	default:
+	}
+	if cb.onTransition != nil {
+		cb.onTransition(from, to, cb.Status())
+	}
+}
+
+// Allow verifica se o circuit breaker permite o envio; em half-open libera
+// até HalfOpenMaxCalls tentativas
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	from, allowed := cb.state, false
+	switch cb.state {
+	case BreakerClosed:
+		allowed = true
+	case BreakerOpen:
+		// Relógio voltando também libera a tentativa, em vez de manter o
+		// circuito aberto pelo tamanho do salto
+		if clock.Expired(cb.clock, cb.lastFailureTime, cb.config.ResetTimeout) {
+			cb.setStateLocked(BreakerHalfOpen)
+			cb.halfOpenCalls++
+			allowed = true
+		}
+	case BreakerHalfOpen:
+		if cb.halfOpenCalls < cb.config.HalfOpenMaxCalls {
+			cb.halfOpenCalls++
+			allowed = true
+		}
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return allowed
+}
+
+// RecordSuccess registra um envio bem-sucedido, que fecha o circuito
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	cb.requests++
+	cb.failures = 0
+	cb.lastError = ""
+	from := cb.setStateLocked(BreakerClosed)
+	cb.mu.Unlock()
+
+	cb.notify(from, BreakerClosed)
+}
+
+// RecordFailure registra um envio com falha; abre o circuito ao atingir
+// FailureThreshold ou em qualquer falha em half-open
+func (cb *CircuitBreaker) RecordFailure(err error) {
+	cb.mu.Lock()
+	cb.requests++
+	cb.totalFailures++
+	cb.failures++
+	from, to := cb.failLocked(err)
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+// failLocked marca a falha e abre o circuito se preciso; chamado com mu
+// travado
+func (cb *CircuitBreaker) failLocked(err error) (from, to string) {
+	cb.lastFailureTime = cb.clock.Now()
+	if err != nil {
+		cb.lastError = err.Error()
+	}
+	from = cb.state
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.config.FailureThreshold {
+		cb.setStateLocked(BreakerOpen)
+	}
+	return from, cb.state
+}
+
+// probeFailed reabre o circuito em half-open depois de um probe que falhou,
+// sem contar como envio do endpoint
+func (cb *CircuitBreaker) probeFailed(err error) {
+	cb.mu.Lock()
+	from, to := cb.failLocked(err)
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+}
+
+// halfOpen passa um circuito aberto para half-open antes do reset_timeout
+// (ex.: o backend trocou de endpoint); retorna true se o estado mudou
+func (cb *CircuitBreaker) halfOpen() bool {
+	cb.mu.Lock()
+	from := cb.state
+	if cb.state == BreakerOpen {
+		cb.setStateLocked(BreakerHalfOpen)
+	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notify(from, to)
+	return from != to
+}
+
+// untilProbe retorna quanto falta para o probe de um circuito aberto; ok é
+// false com o circuito fechado ou em half-open
+func (cb *CircuitBreaker) untilProbe() (wait time.Duration, ok bool) {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	if cb.state != BreakerOpen {
+		return 0, false
+	}
+	wait = cb.config.ResetTimeout - cb.clock.Since(cb.lastFailureTime)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// beginProbe passa um circuito aberto há ResetTimeout para half-open e
+// conta o probe; retorna false se não há o que testar
+func (cb *CircuitBreaker) beginProbe() bool {
+	cb.mu.Lock()
+	from := cb.state
+	// Relógio voltando também libera o probe, como em Allow
+	elapsed := cb.clock.Since(cb.lastFailureTime)
+	if cb.state != BreakerOpen || (elapsed >= 0 && elapsed < cb.config.ResetTimeout) {
+		cb.mu.Unlock()
+		return false
+	}
+	cb.setStateLocked(BreakerHalfOpen)
+	cb.probes++
+	cb.mu.Unlock()
+
+	cb.notify(from, BreakerHalfOpen)
+	return true
+}
+
+// State retorna o estado atual
+func (cb *CircuitBreaker) State() string {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return cb.state
+}
+
+// Status retorna uma cópia do estado e das métricas
+func (cb *CircuitBreaker) Status() CircuitBreakerStatus {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	now := cb.clock.Now()
+	timeOpen := cb.timeOpen
+	if !cb.openedAt.IsZero() {
+		timeOpen += now.Sub(cb.openedAt)
+	}
+	status := CircuitBreakerStatus{
+		Endpoint:         cb.endpoint,
+		Class:            cb.class,
+		State:            cb.state,
+		Failures:         cb.failures,
+		FailureThreshold: cb.config.FailureThreshold,
+		ResetTimeout:     cb.config.ResetTimeout.Seconds(),
+		Requests:         cb.requests,
+		TotalFailures:    cb.totalFailures,
+		Trips:            cb.trips,
+		Probes:           cb.probes,
+		TimeOpenSeconds:  timeOpen.Seconds(),
+		LastOpenSeconds:  cb.lastOpen.Seconds(),
+		LastTransition:   cb.lastTransition,
+		LastError:        cb.lastError,
+	}
+	if cb.state == BreakerOpen {
+		next := cb.lastFailureTime.Add(cb.config.ResetTimeout)
+		status.NextProbe = &next
+	}
+	return status
+}
+
+// EndpointBreakersConfig configura os circuit breakers por endpoint. Critical
+// vale para heartbeat e demais endpoints leves; Bulk para os envios pesados
+// (inventário). Zeros valem o padrão.
+type EndpointBreakersConfig struct {
+	Critical CircuitBreakerConfig
+	Bulk     CircuitBreakerConfig
+	// OnTransition recebe cada troca de estado de um endpoint, fora do lock
+	OnTransition func(endpoint, from, to string, status CircuitBreakerStatus)
+	// Clock é a fonte de tempo dos circuitos (nil = relógio do sistema)
+	Clock clock.Clock
+}
+
+// EndpointBreakers mantém um circuit breaker independente por endpoint do
+// backend, criado no primeiro uso; heartbeat e inventário já nascem
+// registrados para aparecer no health. Sobrevive à recriação do Manager.
+// Seguro para várias goroutines.
+type EndpointBreakers struct {
+	config   EndpointBreakersConfig
+	mu       sync.RWMutex
+	breakers map[string]*CircuitBreaker
+	changed  chan struct{}
+}
+
+// NewEndpointBreakers cria os circuit breakers, todos fechados
+func NewEndpointBreakers(config EndpointBreakersConfig) *EndpointBreakers {
+	config.Critical = config.Critical.withDefaults()
+	config.Bulk = config.Bulk.withDefaults()
+	config.Clock = clock.OrReal(config.Clock)
+
+	e := &EndpointBreakers{
+		config:   config,
+		breakers: make(map[string]*CircuitBreaker),
+		changed:  make(chan struct{}, 1),
+	}
+	e.For(EndpointHeartbeat)
+	e.For(EndpointInventory)
+	return e
+}
+
+// For retorna o circuit breaker do endpoint, criando-o se preciso
+func (e *EndpointBreakers) For(endpoint string) *CircuitBreaker {
+	e.mu.RLock()
+	cb, ok := e.breakers[endpoint]
+	e.mu.RUnlock()
+	if ok {
+		return cb
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if cb, ok := e.breakers[endpoint]; ok {
+		return cb
+	}
+
+	class, config := EndpointClassCritical, e.config.Critical
+	if bulkEndpoints[endpoint] {
+		class, config = EndpointClassBulk, e.config.Bulk
+	}
+	cb = &CircuitBreaker{
+		endpoint: endpoint,
+		class:    class,
+		config:   config,
+		state:    BreakerClosed,
+		clock:    e.config.Clock,
+		changed:  e.changed,
+	}
+	if onTransition := e.config.OnTransition; onTransition != nil {
+		cb.onTransition = func(from, to string, status CircuitBreakerStatus) {
+			onTransition(endpoint, from, to, status)
+		}
+	}
+	e.breakers[endpoint] = cb
+	return cb
+}
+
+// SetClock troca a fonte de tempo de todos os circuitos; deve ser chamado
+// antes do primeiro envio
+func (e *EndpointBreakers) SetClock(clk clock.Clock) {
+	clk = clock.OrReal(clk)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.config.Clock = clk
+	for _, cb := range e.breakers {
+		cb.mu.Lock()
+		cb.clock = clk
+		cb.mu.Unlock()
+	}
+}
+
+// all retorna os circuitos ordenados pelo endpoint
+func (e *EndpointBreakers) all() []*CircuitBreaker {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	breakers := make([]*CircuitBreaker, 0, len(e.breakers))
+	for _, cb := range e.breakers {
+		breakers = append(breakers, cb)
+	}
+	sort.Slice(breakers, func(i, j int) bool { return breakers[i].endpoint < breakers[j].endpoint })
+	return breakers
+}
+
+// Statuses retorna o status de cada endpoint
+func (e *EndpointBreakers) Statuses() map[string]CircuitBreakerStatus {
+	statuses := make(map[string]CircuitBreakerStatus)
+	for _, cb := range e.all() {
+		statuses[cb.endpoint] = cb.Status()
+	}
+	return statuses
+}
+
+// State retorna o pior estado entre os endpoints (open, half-open, closed)
+func (e *EndpointBreakers) State() string {
+	state := BreakerClosed
+	for _, cb := range e.all() {
+		switch cb.State() {
+		case BreakerOpen:
+			return BreakerOpen
+		case BreakerHalfOpen:
+			state = BreakerHalfOpen
+		}
+	}
+	return state
+}
+
+// HalfOpen passa para half-open todos os circuitos abertos (ex.: failover
+// para outro endpoint do backend); retorna true se algum mudou
+func (e *EndpointBreakers) HalfOpen() bool {
+	changed := false
+	for _, cb := range e.all() {
+		if cb.halfOpen() {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// untilProbe retorna quanto falta para o primeiro probe entre os circuitos
+// abertos; ok é false se nenhum está aberto
+func (e *EndpointBreakers) untilProbe() (wait time.Duration, ok bool) {
+	for _, cb := range e.all() {
+		if next, open := cb.untilProbe(); open && (!ok || next < wait) {
+			wait, ok = next, true
+		}
+	}
+	return wait, ok
+}
+
+// beginProbes passa para half-open os circuitos abertos há ResetTimeout e
+// retorna os que serão testados
+func (e *EndpointBreakers) beginProbes() []*CircuitBreaker {
+	var due []*CircuitBreaker
+	for _, cb := range e.all() {
+		if cb.beginProbe() {
+			due = append(due, cb)
+		}
+	}
+	return due
+}
+
+// runBreakerProbes agenda o probe do backend para quando um circuito aberto
+// completar ResetTimeout, sem esperar o próximo envio do endpoint
+func (m *Manager) runBreakerProbes() {
+	breakers := m.config.Breakers
+	for {
+		var probeAt <-chan time.Time
+		if wait, ok := breakers.untilProbe(); ok {
+			probeAt = m.clock.After(wait)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-breakers.changed:
+			// estado mudou; recalcula o próximo probe
+		case <-probeAt:
+			m.probeBreakers()
+		}
+	}
+}
+
+// probeBreakers testa o backend com um GET /health pelos circuitos vencidos.
+// Falha os reabre por mais ResetTimeout; sucesso os deixa em half-open, já
+// que o /health não prova que o endpoint em si voltou: o próximo envio de
+// cada um decide.
+func (m *Manager) probeBreakers() {
+	due := m.config.Breakers.beginProbes()
+	if len(due) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, breakerProbeTimeout)
+	defer cancel()
+	err := m.ProbeBackend(ctx)
+	if err == nil {
+		return
+	}
+	m.logger.WithField("error", err).Debug("Circuit breaker probe failed")
+	for _, cb := range due {
+		cb.probeFailed(err)
+	}
+}
+
+// allowEndpoint consulta o circuit breaker do endpoint antes de um envio;
+// sem EndpointBreakers tudo sai
+func (m *Manager) allowEndpoint(endpoint string) bool {
+	return m.config.Breakers == nil || m.config.Breakers.For(endpoint).Allow()
+}
+
+// recordEndpoint registra o resultado de um envio no circuit breaker do
+// endpoint
+func (m *Manager) recordEndpoint(endpoint string, err error) {
+	if m.config.Breakers == nil {
+		return
+	}
+	if err != nil {
+		m.config.Breakers.For(endpoint).RecordFailure(err)
+		return
+	}
+	m.config.Breakers.For(endpoint).RecordSuccess()
+}
@@ -0,0 +1,272 @@
+package comms
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// breakerBackend responde 500 aos caminhos em falha (ou a tudo, com down) e
+// registra os caminhos de todas as requisições recebidas
+type breakerBackend struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	down    bool
+	failing map[string]bool
+	paths   []string
+}
+
+func newBreakerBackend(t *testing.T) *breakerBackend {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	backend := &breakerBackend{failing: make(map[string]bool)}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend.mu.Lock()
+		backend.paths = append(backend.paths, r.URL.Path)
+		fail := backend.down || backend.failing[r.URL.Path]
+		backend.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *breakerBackend) setDown(down bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.down = down
+}
+
+// failPath passa a responder 500 só no caminho informado
+func (b *breakerBackend) failPath(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failing[path] = true
+}
+
+// count retorna quantas requisições chegaram no caminho
+func (b *breakerBackend) count(path string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, p := range b.paths {
+		if p == path {
+			n++
+		}
+	}
+	return n
+}
+
+// transitionLog registra as trocas de estado dos circuitos
+type transitionLog struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (l *transitionLog) record(endpoint, from, to string, _ CircuitBreakerStatus) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transitions = append(l.transitions, endpoint+" "+from+">"+to)
+}
+
+func (l *transitionLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.transitions...)
+}
+
+// newBreakerTestManager cria um Manager sem retentativas com os circuit
+// breakers informados
+func newBreakerTestManager(t *testing.T, url string, breakers *EndpointBreakers, fake *clock.Fake) *Manager {
+	t.Helper()
+	m, err := New(&Config{
+		BackendURL:     url,
+		Token:          "test-token",
+		MachineID:      "test-machine",
+		Logger:         testLogger(t),
+		Clock:          fake,
+		HTTPTimeout:    5 * time.Second,
+		HTTPMaxRetries: -1,
+		QueuePath:      filepath.Join(t.TempDir(), "queue.json"),
+		Breakers:       breakers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.cancel)
+	return m
+}
+
+// probeAfter avança o relógio falso até um segundo antes do reset_timeout,
+// confere que nenhum probe saiu e então completa o intervalo, aguardando o
+// probe número n. O agendador calcula o prazo pelo instante da falha, então
+// o atraso da goroutine não muda o momento do disparo.
+func probeAfter(t *testing.T, backend *breakerBackend, fake *clock.Fake, resetTimeout time.Duration, n int) {
+	t.Helper()
+	fake.Advance(resetTimeout - time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if got := backend.count("/health"); got != n-1 {
+		t.Fatalf("%d probes before the reset timeout, want %d", got, n-1)
+	}
+	fake.Advance(time.Second)
+	waitFor(t, "the breaker probe", 2*time.Second, func() bool { return backend.count("/health") == n })
+}
+
+func TestBreakerProbesBackendAfterResetTimeout(t *testing.T) {
+	backend := newBreakerBackend(t)
+	fake := newTestClock()
+	var log transitionLog
+	breakers := NewEndpointBreakers(EndpointBreakersConfig{
+		Critical:     CircuitBreakerConfig{FailureThreshold: 3, ResetTimeout: 30 * time.Second},
+		OnTransition: log.record,
+		Clock:        fake,
+	})
+	m := newBreakerTestManager(t, backend.server.URL, breakers, fake)
+	go m.runBreakerProbes()
+	heartbeat := breakers.For(EndpointHeartbeat)
+
+	// O backend cai: três heartbeats com falha abrem o circuito
+	backend.setDown(true)
+	for i := 0; i < 3; i++ {
+		if err := m.SendHeartbeat(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("heartbeat %d = %v", i+1, err)
+		}
+	}
+	if heartbeat.State() != BreakerOpen {
+		t.Fatalf("heartbeat breaker = %s after the failure threshold", heartbeat.State())
+	}
+	opened := fake.Now()
+
+	// Aberto, o heartbeat nem chega ao backend
+	if err := m.SendHeartbeat(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("heartbeat with the breaker open = %v", err)
+	}
+	if n := backend.count(EndpointHeartbeat); n != 3 {
+		t.Fatalf("backend received %d heartbeats, want 3", n)
+	}
+	if status := heartbeat.Status(); status.NextProbe == nil || !status.NextProbe.Equal(opened.Add(30*time.Second)) {
+		t.Fatalf("next probe = %v", status.NextProbe)
+	}
+
+	// O probe sai sozinho ao fim do reset_timeout, sem esperar um envio;
+	// com o backend ainda fora, o circuito reabre por mais 30s
+	probeAfter(t, backend, fake, 30*time.Second, 1)
+	waitFor(t, "the failed probe to reopen the breaker", 2*time.Second, func() bool {
+		return heartbeat.State() == BreakerOpen && heartbeat.Status().Probes == 1
+	})
+
+	// O backend volta; o próximo probe passa o circuito para half-open
+	backend.setDown(false)
+	probeAfter(t, backend, fake, 30*time.Second, 2)
+	waitFor(t, "the successful probe", 2*time.Second, func() bool { return heartbeat.State() == BreakerHalfOpen })
+
+	// O heartbeat seguinte confirma o endpoint e fecha o circuito
+	if err := m.SendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if heartbeat.State() != BreakerClosed || backend.count(EndpointHeartbeat) != 4 {
+		t.Fatalf("breaker %s, %d heartbeats after recovery", heartbeat.State(), backend.count(EndpointHeartbeat))
+	}
+
+	want := []string{
+		"/heartbeat closed>open",
+		"/heartbeat open>half-open",
+		"/heartbeat half-open>open",
+		"/heartbeat open>half-open",
+		"/heartbeat half-open>closed",
+	}
+	if got := log.list(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("transitions = %v, want %v", got, want)
+	}
+
+	// Métricas: uma abertura, dois probes e o minuto aberto
+	status := m.GetMetrics().Breakers[EndpointHeartbeat]
+	if status.Trips != 1 || status.Probes != 2 || status.TotalFailures != 3 || status.Requests != 4 ||
+		status.TimeOpenSeconds != 60 || status.LastOpenSeconds != 60 || status.NextProbe != nil {
+		t.Fatalf("heartbeat breaker status = %+v", status)
+	}
+	if inventory := m.GetMetrics().Breakers[EndpointInventory]; inventory.State != BreakerClosed || inventory.Trips != 0 {
+		t.Fatalf("inventory breaker = %+v", inventory)
+	}
+}
+
+func TestInventoryFailuresDoNotThrottleHeartbeats(t *testing.T) {
+	backend := newBreakerBackend(t)
+	backend.failPath(EndpointInventory)
+	fake := newTestClock()
+	breakers := NewEndpointBreakers(EndpointBreakersConfig{
+		Critical: CircuitBreakerConfig{FailureThreshold: 3, ResetTimeout: 30 * time.Second},
+		Bulk:     CircuitBreakerConfig{FailureThreshold: 2, ResetTimeout: 5 * time.Minute},
+		Clock:    fake,
+	})
+	m := newBreakerTestManager(t, backend.server.URL, breakers, fake)
+
+	// O pipeline de ingestão falha; os heartbeats intercalados continuam
+	for i := 0; i < 2; i++ {
+		if err := m.SendInventory(spoolTestInventory()); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("inventory %d = %v", i+1, err)
+		}
+		if err := m.SendHeartbeat(); err != nil {
+			t.Fatalf("heartbeat after inventory failure %d: %v", i+1, err)
+		}
+	}
+	if err := m.SendInventory(spoolTestInventory()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("inventory with the bulk breaker open = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := m.SendHeartbeat(); err != nil {
+			t.Fatalf("heartbeat %d with the inventory breaker open: %v", i+1, err)
+		}
+	}
+	if heartbeats, inventories := backend.count(EndpointHeartbeat), backend.count(EndpointInventory); heartbeats != 7 || inventories != 2 {
+		t.Fatalf("backend received %d heartbeats and %d inventories", heartbeats, inventories)
+	}
+
+	// Cada endpoint com a sua classe, limites e contadores
+	statuses := m.GetMetrics().Breakers
+	heartbeat, inventory := statuses[EndpointHeartbeat], statuses[EndpointInventory]
+	if heartbeat.State != BreakerClosed || heartbeat.Class != EndpointClassCritical || heartbeat.FailureThreshold != 3 ||
+		heartbeat.TotalFailures != 0 || heartbeat.Requests != 7 {
+		t.Fatalf("heartbeat breaker = %+v", heartbeat)
+	}
+	if inventory.State != BreakerOpen || inventory.Class != EndpointClassBulk || inventory.FailureThreshold != 2 ||
+		inventory.ResetTimeout != 300 || inventory.TotalFailures != 2 {
+		t.Fatalf("inventory breaker = %+v", inventory)
+	}
+
+	// O health ganha um componente por endpoint no lugar do "http"
+	monitor := NewMonitor(MonitorConfig{Logger: testLogger(t), Endpoints: breakers.Statuses})
+	monitor.checkHealth()
+	health := monitor.GetHealthCheck()
+	if _, ok := health.Components["http"]; ok {
+		t.Fatal("health still has the shared http component")
+	}
+	if c := health.Components["http:"+EndpointHeartbeat]; c.Status != "healthy" || !c.Critical {
+		t.Fatalf("heartbeat component = %+v", c)
+	}
+	if c := health.Components["http:"+EndpointInventory]; c.Status != "unhealthy" || c.Critical || c.ErrorRate != 1 {
+		t.Fatalf("inventory component = %+v", c)
+	}
+	var issue *HealthIssue
+	for i := range health.Issues {
+		if health.Issues[i].Component == "http:"+EndpointInventory {
+			issue = &health.Issues[i]
+		}
+	}
+	// Endpoint bulk aberto é warning; o crítico seria critical
+	if issue == nil || issue.Severity != "warning" {
+		t.Fatalf("health issues = %+v", health.Issues)
+	}
+}
@@ -0,0 +1,50 @@
+package comms
+
+import (
+	"testing"
+)
+
+func TestHandleCommandCancel(t *testing.T) {
+	ws, err := NewWebSocketClient(WebSocketConfig{URL: "ws://127.0.0.1:1", Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+
+	tests := []struct {
+		name    string
+		message WebSocketMessage
+		want    *CommandCancel
+	}{
+		{
+			name:    "command_id and reason",
+			message: WebSocketMessage{Type: "command_cancel", ID: "msg-1", Data: map[string]interface{}{"command_id": "cmd-1", "reason": "operator"}},
+			want:    &CommandCancel{CommandID: "cmd-1", Reason: "operator"},
+		},
+		{
+			// Sem command_id nos dados, vale o ID da mensagem
+			name:    "message id",
+			message: WebSocketMessage{Type: "command_cancel", ID: "cmd-2"},
+			want:    &CommandCancel{CommandID: "cmd-2"},
+		},
+		{
+			name:    "no id",
+			message: WebSocketMessage{Type: "command_cancel", Data: map[string]interface{}{"reason": "operator"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws.handleCommandCancel(tt.message)
+			select {
+			case cancel := <-ws.CancelChannel():
+				if tt.want == nil || cancel != *tt.want {
+					t.Fatalf("cancel = %+v, want %+v", cancel, tt.want)
+				}
+			default:
+				if tt.want != nil {
+					t.Fatal("cancel not delivered")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package comms
+
+// SchemaVersion é a versão do formato das mensagens enviadas pelo agente
+// (inventário, heartbeat, resultados); incrementar em mudanças incompatíveis
+const SchemaVersion = 1
+
+// Recursos de transporte anunciados em Capabilities.Transport
+const (
+	TransportCompression   = "compression"    // artefatos com Encoding "gzip" e corpos com Content-Encoding negociado
+	TransportBatching      = "batching"       // vários inventários/resultados por requisição
+	TransportStreaming     = "streaming"      // saída de comando enviada durante a execução
+	TransportChunkedUpload = "chunked_upload" // upload de artefatos em partes
+	TransportMessageAcks   = "message_acks"   // acks e retransmissão no WebSocket (ver WSMessageTypeAck)
+)
+
+// Capabilities descreve o que este agente suporta, enviado no registro, no
+// heartbeat e em resultados de comandos não suportados, para que o backend
+// não envie comandos que o agente vai recusar
+type Capabilities struct {
+	SchemaVersion int             `json:"schema_version"`
+	Platform      string          `json:"platform"`
+	CommandTypes  []string        `json:"command_types"`
+	Transport     map[string]bool `json:"transport"`
+	Collectors    map[string]bool `json:"collectors"`
+}
+
+// TransportFeatures retorna os recursos de transporte e se este agente os
+// implementa. Recursos não implementados são enviados como false para o
+// backend distinguir "não suportado" de agente antigo sem o campo.
+func TransportFeatures() map[string]bool {
+	return map[string]bool{
+		TransportCompression:   true,
+		TransportBatching:      false,
+		TransportStreaming:     true,
+		TransportChunkedUpload: false,
+		TransportMessageAcks:   false, // depende da configuração (ws_message_acks)
+	}
+}
+
+// Supports indica se commandType está entre os tipos anunciados
+func (c *Capabilities) Supports(commandType string) bool {
+	if c == nil {
+		return false
+	}
+	for _, t := range c.CommandTypes {
+		if t == commandType {
+			return true
+		}
+	}
+	return false
+}
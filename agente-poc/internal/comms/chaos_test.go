@@ -0,0 +1,87 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/timeutil"
+)
+
+// TestChaosInventory503Scenario é o cenário de docs/CHAOS.md: 30% de 503 no
+// inventário por 2 minutos. As retentativas entregam todos os inventários,
+// cada um uma única vez.
+func TestChaosInventory503Scenario(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		received[body.ID]++
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+
+	fake := newTestClock()
+	injector := chaos.New(chaos.Config{Enabled: true, Seed: 7, Rules: []chaos.Rule{{
+		Name:        "inventory-503",
+		Kind:        chaos.KindHTTPError,
+		StatusCode:  http.StatusServiceUnavailable,
+		Endpoint:    "/inventory",
+		Probability: 0.3,
+		Duration:    timeutil.Seconds(2 * time.Minute),
+	}}}, fake, testLogger(t))
+
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:       server.URL,
+		MaxRetries:    20,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 2 * time.Millisecond,
+		Chaos:         injector,
+		Logger:        testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Um inventário a cada 2s durante a janela e mais 10 depois dela
+	const inWindow, after = 60, 10
+	for i := 0; i < inWindow+after; i++ {
+		id := fmt.Sprintf("inventory-%d", i)
+		if err := client.POST(context.Background(), "/inventory", map[string]string{"id": id}, nil); err != nil {
+			t.Fatalf("%s lost: %v", id, err)
+		}
+		fake.Advance(2 * time.Second)
+		if i == inWindow-1 {
+			if rule := injector.Status().Rules[0]; rule.Active || rule.Injections == 0 {
+				t.Fatalf("rule at the end of the window: %+v", rule)
+			}
+		}
+	}
+	injected := injector.Status().Rules[0].Injections
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != inWindow+after {
+		t.Fatalf("%d distinct inventories received, want %d", len(received), inWindow+after)
+	}
+	for id, count := range received {
+		if count != 1 {
+			t.Errorf("%s received %d times", id, count)
+		}
+	}
+	if metrics := client.GetMetrics(); metrics.RetryCount != injected {
+		t.Fatalf("%d retries for %d injected 503s", metrics.RetryCount, injected)
+	}
+}
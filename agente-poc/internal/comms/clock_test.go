@@ -0,0 +1,170 @@
+package comms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// newTestClock cria o relógio falso dos testes de comms
+func newTestClock() *clock.Fake {
+	return clock.NewFake(time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC))
+}
+
+func TestMessageQueueExpiry(t *testing.T) {
+	fake := newTestClock()
+	queue, err := NewMessageQueue(QueueConfig{Logger: testLogger(t), Clock: fake})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []QueuedMessage{
+		{ID: "short", Type: "heartbeat", ExpiresAt: fake.Now().Add(5 * time.Minute)},
+		{ID: "long", Type: "inventory", ExpiresAt: fake.Now().Add(time.Hour)},
+		{ID: "default", Type: "alert"},
+	} {
+		if err := queue.Enqueue(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fake.Advance(10 * time.Minute)
+	delivered := make(map[string]bool)
+	for {
+		message, err := queue.Dequeue()
+		if err != nil || message == nil {
+			break
+		}
+		delivered[message.ID] = true
+	}
+	if delivered["short"] || !delivered["long"] || !delivered["default"] {
+		t.Fatalf("delivered %v after 10 minutes, want long and default", delivered)
+	}
+	if expired := queue.GetMetrics().ExpiredMessages; expired != 1 {
+		t.Fatalf("%d expired messages after 10 minutes, want 1", expired)
+	}
+
+	// Sem ExpiresAt, a mensagem vale 24h no relógio da fila
+	if err := queue.Enqueue(QueuedMessage{ID: "late", Type: "alert"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(25 * time.Hour)
+	if message, _ := queue.Dequeue(); message != nil {
+		t.Fatalf("message %s delivered after expiring", message.ID)
+	}
+}
+
+func TestRateLimiterClockJump(t *testing.T) {
+	fake := newTestClock()
+	sm := NewSecurityManager(SecurityConfig{
+		RateLimitWindow:      time.Minute,
+		MaxRequestsPerWindow: 2,
+		Logger:               testLogger(t),
+		Clock:                fake,
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := sm.CheckRateLimit("machine"); err != nil {
+			t.Fatalf("request %d limited: %v", i+1, err)
+		}
+	}
+	if err := sm.CheckRateLimit("machine"); err == nil {
+		t.Fatal("third request in the window allowed")
+	}
+	fake.Advance(30 * time.Second)
+	if err := sm.CheckRateLimit("machine"); err == nil {
+		t.Fatal("blocked identifier allowed within the window")
+	}
+
+	// Relógio volta uma hora: o bloqueio não se estende pelo tamanho do salto
+	fake.Set(fake.Now().Add(-time.Hour))
+	if err := sm.CheckRateLimit("machine"); err != nil {
+		t.Fatalf("identifier still blocked after the clock moved backwards: %v", err)
+	}
+
+	// Janela normal volta a valer a partir do novo horário
+	fake.Advance(time.Second)
+	if err := sm.CheckRateLimit("machine"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.CheckRateLimit("machine"); err == nil {
+		t.Fatal("limit not enforced after the clock jump")
+	}
+}
+
+func TestTokenExpiryClockJump(t *testing.T) {
+	fake := newTestClock()
+	sm := NewSecurityManager(SecurityConfig{
+		TokenValidityPeriod: time.Hour,
+		Logger:              testLogger(t),
+		Clock:               fake,
+	})
+
+	token, err := sm.GenerateToken("machine", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(59 * time.Minute)
+	if _, err := sm.ValidateToken(token.Value); err != nil {
+		t.Fatalf("token rejected within its validity: %v", err)
+	}
+	fake.Advance(2 * time.Minute)
+	if _, err := sm.ValidateToken(token.Value); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("token after its validity: %v", err)
+	}
+
+	// Relógio voltando para antes da emissão não estende a validade
+	jumped, err := sm.GenerateToken("machine", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Set(fake.Now().Add(-2 * time.Hour))
+	if _, err := sm.ValidateToken(jumped.Value); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("token valid after the clock moved before its issue time: %v", err)
+	}
+}
+
+func TestCircuitBreakerResetTimeout(t *testing.T) {
+	fake := newTestClock()
+	breakers := NewEndpointBreakers(EndpointBreakersConfig{
+		Critical: CircuitBreakerConfig{FailureThreshold: 2, ResetTimeout: 30 * time.Second, HalfOpenMaxCalls: 1},
+		Clock:    fake,
+	})
+	cb := breakers.For(EndpointHeartbeat)
+
+	trip := func() {
+		t.Helper()
+		for i := 0; i < 2; i++ {
+			cb.RecordFailure(errors.New("connection refused"))
+		}
+		if cb.State() != BreakerOpen || cb.Allow() {
+			t.Fatalf("breaker %s after the failure threshold", cb.State())
+		}
+	}
+
+	trip()
+	fake.Advance(29 * time.Second)
+	if cb.Allow() {
+		t.Fatal("open breaker allowed a call before the reset timeout")
+	}
+	fake.Advance(2 * time.Second)
+	if !cb.Allow() || cb.State() != BreakerHalfOpen {
+		t.Fatalf("breaker %s after the reset timeout", cb.State())
+	}
+	if cb.Allow() {
+		t.Fatal("half-open breaker allowed more than HalfOpenMaxCalls")
+	}
+	cb.RecordSuccess()
+	if cb.State() != BreakerClosed {
+		t.Fatalf("breaker %s after a successful probe", cb.State())
+	}
+
+	// Relógio voltando libera o probe em vez de manter o circuito aberto
+	trip()
+	fake.Set(fake.Now().Add(-time.Hour))
+	if !cb.Allow() || cb.State() != BreakerHalfOpen {
+		t.Fatalf("breaker %s after the clock moved backwards", cb.State())
+	}
+}
@@ -0,0 +1,288 @@
+package comms
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CommandDecodeError indica um campo do comando com tipo incorreto
+type CommandDecodeError struct {
+	Field    string
+	Expected string
+	Got      string
+}
+
+func (e *CommandDecodeError) Error() string {
+	return fmt.Sprintf("invalid command field %q: expected %s, got %s", e.Field, e.Expected, e.Got)
+}
+
+// commandFieldTypes descreve os campos aceitos em WebSocketMessage.Data de um comando
+var commandFieldTypes = map[string]string{
+	"id":            "string",
+	"type":          "string",
+	"command":       "string",
+	"args":          "array of strings",
+	"options":       "object",
+	"timeout":       "integer",
+	"timestamp":     "RFC 3339 string or Unix epoch",
+	"requires_auth": "boolean",
+}
+
+// knownCommandOptions lista as chaves de Options conhecidas pelo agente e seus tipos.
+// Novas opções devem ser registradas aqui para não gerarem avisos.
+var knownCommandOptions = map[string]string{
+	"cwd":                  "string",
+	"deferrable":           "boolean",
+	"env":                  "object",
+	"insecure_skip_verify": "boolean",
+	"interpreter":          "string",
+	"limit":                "number",
+	"script":               "string",
+	"sha256":               "string",
+	"signature":            "string",
+	"since":                "string",
+	"snapshot_id":          "string",
+	"stream":               "boolean",
+	"token":                "string",
+	"url":                  "string",
+	"version":              "string",
+}
+
+// epochMillisThreshold separa épocas em segundos das em milissegundos: em
+// segundos, o valor só passa dele no ano 33658
+const epochMillisThreshold = 1e12
+
+// commandTimestamp aceita o horário do comando como string RFC 3339 ou como
+// época Unix em segundos ou milissegundos (número ou string numérica), os
+// formatos enviados pelas versões do backend
+type commandTimestamp time.Time
+
+func (t *commandTimestamp) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	var epoch float64
+	switch v := value.(type) {
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			*t = commandTimestamp(parsed)
+			return nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", v)
+		}
+		epoch = n
+	case float64:
+		epoch = v
+	default:
+		return fmt.Errorf("invalid timestamp type")
+	}
+
+	if epoch <= 0 || math.IsInf(epoch, 0) || math.IsNaN(epoch) {
+		return fmt.Errorf("invalid epoch timestamp %v", epoch)
+	}
+	if epoch >= epochMillisThreshold {
+		*t = commandTimestamp(time.UnixMilli(int64(epoch)))
+		return nil
+	}
+	seconds, fraction := math.Modf(epoch)
+	*t = commandTimestamp(time.Unix(int64(seconds), int64(fraction*float64(time.Second))))
+	return nil
+}
+
+// DecodeCommand converte WebSocketMessage.Data em Command com verificação de tipos.
+// Sem timestamp, o comando fica com o horário do recebimento.
+//
+// No modo estrito, um campo com tipo incorreto retorna *CommandDecodeError e o
+// comando não deve ser executado. Campos e opções desconhecidos nunca são erro:
+// são listados em Command.DecodeWarnings para serem reportados no resultado.
+//
+// O modo lenient mantém o comportamento antigo (valores incompatíveis viram
+// zero, com conversão de strings numéricas) apenas durante a migração do
+// backend; cada coerção também gera um aviso.
+//
+// Comandos acima de limits retornam *OversizedCommandError antes de args e
+// options serem decodificados; com data em json.RawMessage (como chega do
+// WebSocket), o custo da recusa fica limitado ao JSON lido até o limite.
+func DecodeCommand(id string, data interface{}, lenient bool, limits CommandLimits) (Command, error) {
+	command := Command{
+		ID:        id,
+		Timestamp: time.Now(),
+	}
+
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(data); err != nil {
+			return command, &CommandDecodeError{Field: "data", Expected: "object", Got: "unencodable value"}
+		}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return command, &CommandDecodeError{Field: "data", Expected: "object", Got: jsonKind(raw)}
+	}
+
+	if err := checkRawCommandLimits(fields, limits); err != nil {
+		return command, err
+	}
+
+	decode := func(name string, target interface{}) error {
+		value, ok := fields[name]
+		if !ok || string(value) == "null" {
+			return nil
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			decodeErr := &CommandDecodeError{Field: name, Expected: commandFieldTypes[name], Got: jsonKind(value)}
+			if !lenient {
+				return decodeErr
+			}
+			command.DecodeWarnings = append(command.DecodeWarnings, decodeErr.Error()+" (lenient: coerced)")
+			coerce(value, target)
+		}
+		return nil
+	}
+
+	for _, step := range []struct {
+		name   string
+		target interface{}
+	}{
+		{"type", &command.Type},
+		{"command", &command.Command},
+		{"args", &command.Args},
+		{"options", &command.Options},
+		{"timeout", &command.Timeout},
+		{"requires_auth", &command.RequiresAuth},
+		{"timestamp", (*commandTimestamp)(&command.Timestamp)},
+	} {
+		if err := decode(step.name, step.target); err != nil {
+			return command, err
+		}
+	}
+
+	if err := CheckCommandLimits(&command, limits); err != nil {
+		command.Args = nil
+		command.Options = nil
+		return command, err
+	}
+
+	var unknown []string
+	for name := range fields {
+		if _, ok := commandFieldTypes[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		command.DecodeWarnings = append(command.DecodeWarnings, fmt.Sprintf("unknown field %q ignored", name))
+	}
+
+	return command, checkCommandOptions(&command, lenient)
+}
+
+// checkCommandOptions valida os tipos das opções conhecidas e avisa sobre as desconhecidas
+func checkCommandOptions(command *Command, lenient bool) error {
+	keys := make([]string, 0, len(command.Options))
+	for key := range command.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		expected, known := knownCommandOptions[key]
+		if !known {
+			command.DecodeWarnings = append(command.DecodeWarnings, fmt.Sprintf("unknown option %q", key))
+			continue
+		}
+
+		value := command.Options[key]
+		var ok bool
+		switch expected {
+		case "boolean":
+			_, ok = value.(bool)
+		case "number":
+			_, ok = value.(float64)
+		case "string":
+			_, ok = value.(string)
+		case "object":
+			_, ok = value.(map[string]interface{})
+		}
+		if ok {
+			continue
+		}
+
+		raw, _ := json.Marshal(value)
+		decodeErr := &CommandDecodeError{Field: "options." + key, Expected: expected, Got: jsonKind(raw)}
+		if !lenient {
+			return decodeErr
+		}
+		command.DecodeWarnings = append(command.DecodeWarnings, decodeErr.Error()+" (lenient: removed)")
+		delete(command.Options, key)
+	}
+
+	return nil
+}
+
+// coerce aplica a conversão do modo lenient: strings numéricas viram inteiros,
+// demais incompatibilidades ficam com o valor zero
+func coerce(value json.RawMessage, target interface{}) {
+	switch t := target.(type) {
+	case *int:
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			if n, err := strconv.Atoi(s); err == nil {
+				*t = n
+			}
+		}
+	case *[]string:
+		// Mantém apenas os elementos string, como o helper antigo
+		var items []interface{}
+		if json.Unmarshal(value, &items) == nil {
+			result := make([]string, len(items))
+			for i, item := range items {
+				result[i], _ = item.(string)
+			}
+			*t = result
+		}
+	}
+}
+
+// jsonKind descreve o tipo JSON de um valor para mensagens de erro
+func jsonKind(raw json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "invalid JSON"
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return "array with non-string elements"
+			}
+		}
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,245 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeJSON decodifica um comando a partir do JSON de WebSocketMessage.Data
+func decodeJSON(t *testing.T, data string, lenient bool) (Command, error) {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatal(err)
+	}
+	return DecodeCommand("cmd-1", value, lenient, CommandLimits{})
+}
+
+func TestDecodeCommandFieldTypes(t *testing.T) {
+	values := map[string]string{
+		"string":  `"60"`,
+		"integer": `60`,
+		"number":  `1.5`,
+		"boolean": `true`,
+		"array":   `["a","b"]`,
+		"mixed":   `["a",1]`,
+		"object":  `{"cwd":"/tmp"}`,
+	}
+	accepted := map[string][]string{
+		"type":          {"string"},
+		"command":       {"string"},
+		"args":          {"array"},
+		"options":       {"object"},
+		"timeout":       {"integer"},
+		"requires_auth": {"boolean"},
+		"timestamp":     {"integer", "number", "string"},
+	}
+
+	for field, ok := range accepted {
+		for kind, value := range values {
+			t.Run(field+"="+kind, func(t *testing.T) {
+				command, err := decodeJSON(t, `{"type":"shell","`+field+`":`+value+`}`, false)
+				if slices.Contains(ok, kind) {
+					if err != nil {
+						t.Fatalf("valid %s rejected: %v", kind, err)
+					}
+					return
+				}
+
+				var decodeErr *CommandDecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("%s accepted as %s: %+v", kind, field, command)
+				}
+				if decodeErr.Field != field || decodeErr.Expected != commandFieldTypes[field] {
+					t.Fatalf("error = %+v", decodeErr)
+				}
+				if !strings.Contains(err.Error(), field) {
+					t.Fatalf("error does not name the field: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeCommandValues(t *testing.T) {
+	command, err := decodeJSON(t, `{
+		"id": "ignored", "type": "shell", "command": "df", "args": ["-h", "/"],
+		"options": {"cwd": "/tmp", "stream": true}, "timeout": 60,
+		"requires_auth": true, "timestamp": "2026-03-01T12:00:00Z"
+	}`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.ID != "cmd-1" || command.Type != "shell" || command.Command != "df" || command.Timeout != 60 || !command.RequiresAuth {
+		t.Fatalf("decoded command = %+v", command)
+	}
+	if !slices.Equal(command.Args, []string{"-h", "/"}) || command.Options["cwd"] != "/tmp" {
+		t.Fatalf("args/options = %v %v", command.Args, command.Options)
+	}
+	if !command.Timestamp.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("timestamp = %s", command.Timestamp)
+	}
+	if len(command.DecodeWarnings) != 0 {
+		t.Fatalf("warnings for a clean command: %v", command.DecodeWarnings)
+	}
+}
+
+func TestDecodeCommandTimestamp(t *testing.T) {
+	want := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Time
+		fails bool
+	}{
+		{name: "RFC 3339", value: `"2026-03-01T12:00:00Z"`, want: want},
+		{name: "RFC 3339 with offset", value: `"2026-03-01T09:00:00-03:00"`, want: want},
+		{name: "RFC 3339 nanoseconds", value: `"2026-03-01T12:00:00.25Z"`, want: want.Add(250 * time.Millisecond)},
+		{name: "epoch seconds", value: `1772366400`, want: want},
+		{name: "fractional epoch seconds", value: `1772366400.5`, want: want.Add(500 * time.Millisecond)},
+		{name: "epoch milliseconds", value: `1772366400250`, want: want.Add(250 * time.Millisecond)},
+		{name: "numeric string", value: `"1772366400"`, want: want},
+		{name: "unparseable string", value: `"yesterday"`, fails: true},
+		{name: "zero epoch", value: `0`, fails: true},
+		{name: "negative epoch", value: `-5`, fails: true},
+		{name: "boolean", value: `true`, fails: true},
+		{name: "object", value: `{"seconds":1}`, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, err := decodeJSON(t, `{"type":"shell","timestamp":`+tt.value+`}`, false)
+			if tt.fails {
+				var decodeErr *CommandDecodeError
+				if !errors.As(err, &decodeErr) || decodeErr.Field != "timestamp" {
+					t.Fatalf("timestamp %s accepted: %v (%s)", tt.value, err, command.Timestamp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !command.Timestamp.Equal(tt.want) {
+				t.Fatalf("timestamp = %s, want %s", command.Timestamp, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandTimestampDefault(t *testing.T) {
+	before := time.Now()
+	for _, data := range []string{`{"type":"shell"}`, `{"type":"shell","timestamp":null}`} {
+		command, err := decodeJSON(t, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if command.Timestamp.Before(before) || command.Timestamp.After(time.Now()) {
+			t.Fatalf("missing timestamp decoded as %s", command.Timestamp)
+		}
+	}
+
+	// No modo lenient um timestamp inválido vira o horário do recebimento
+	command, err := decodeJSON(t, `{"type":"shell","timestamp":"yesterday"}`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command.Timestamp.Before(before) || len(command.DecodeWarnings) != 1 {
+		t.Fatalf("lenient timestamp = %s, warnings %v", command.Timestamp, command.DecodeWarnings)
+	}
+}
+
+func TestDecodeCommandLenient(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		check func(Command) bool
+	}{
+		{
+			name:  "numeric string timeout",
+			data:  `{"type":"shell","timeout":"60"}`,
+			check: func(c Command) bool { return c.Timeout == 60 },
+		},
+		{
+			name:  "non-numeric timeout",
+			data:  `{"type":"shell","timeout":"soon"}`,
+			check: func(c Command) bool { return c.Timeout == 0 },
+		},
+		{
+			name:  "args with non-string elements",
+			data:  `{"type":"shell","args":["-h",3]}`,
+			check: func(c Command) bool { return slices.Equal(c.Args, []string{"-h", ""}) },
+		},
+		{
+			name:  "boolean command",
+			data:  `{"type":"shell","command":true}`,
+			check: func(c Command) bool { return c.Command == "" },
+		},
+		{
+			name:  "wrong option type",
+			data:  `{"type":"shell","options":{"stream":"yes"}}`,
+			check: func(c Command) bool { _, ok := c.Options["stream"]; return !ok },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeJSON(t, tt.data, false); err == nil {
+				t.Fatal("strict mode accepted the command")
+			}
+			command, err := decodeJSON(t, tt.data, true)
+			if err != nil {
+				t.Fatalf("lenient mode rejected the command: %v", err)
+			}
+			if !tt.check(command) {
+				t.Fatalf("lenient coercion = %+v", command)
+			}
+			if len(command.DecodeWarnings) != 1 || !strings.Contains(command.DecodeWarnings[0], "lenient") {
+				t.Fatalf("warnings = %v", command.DecodeWarnings)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandOptions(t *testing.T) {
+	for key, expected := range knownCommandOptions {
+		wrong := `"text"`
+		if expected == "string" {
+			wrong = `42`
+		}
+		t.Run(key, func(t *testing.T) {
+			_, err := decodeJSON(t, `{"type":"shell","options":{"`+key+`":`+wrong+`}}`, false)
+			var decodeErr *CommandDecodeError
+			if !errors.As(err, &decodeErr) || decodeErr.Field != "options."+key || decodeErr.Expected != expected {
+				t.Fatalf("wrong-typed option %s: %v", key, err)
+			}
+		})
+	}
+}
+
+func TestDecodeCommandWarnings(t *testing.T) {
+	command, err := decodeJSON(t, `{"type":"shell","priority_hint":1,"extra":true,"options":{"colour":"red","cwd":"/tmp"}}`, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`unknown field "extra" ignored`,
+		`unknown field "priority_hint" ignored`,
+		`unknown option "colour"`,
+	}
+	if !slices.Equal(command.DecodeWarnings, want) {
+		t.Fatalf("warnings = %q, want %q", command.DecodeWarnings, want)
+	}
+}
+
+func TestDecodeCommandNotAnObject(t *testing.T) {
+	for _, data := range []interface{}{"shell", 42, []interface{}{"a"}, nil} {
+		var decodeErr *CommandDecodeError
+		if _, err := DecodeCommand("cmd-1", data, true, CommandLimits{}); !errors.As(err, &decodeErr) || decodeErr.Field != "data" {
+			t.Errorf("data %v: %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package comms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites padrão de entrada de comandos
+const (
+	DefaultMaxCommandArgs         = 64
+	DefaultMaxCommandArgsBytes    = 32 * 1024
+	DefaultMaxCommandOptions      = 32
+	DefaultMaxCommandOptionsDepth = 4
+
+	// commandPreviewBytes limita o trecho de uma entrada grande exibido em logs
+	commandPreviewBytes = 128
+	// commandPreviewArgs limita quantos args aparecem na prévia
+	commandPreviewArgs = 3
+)
+
+// CommandLimits define o tamanho máximo aceito para args e options de um comando.
+// É verificado no JSON bruto, antes de args e options serem decodificados,
+// e também nos comandos reenfileirados pelo agente. Valores zero usam os
+// padrões.
+type CommandLimits struct {
+	MaxArgs      int // quantidade de args
+	MaxArgsBytes int // soma dos bytes de todos os args
+	MaxOptions   int // entradas de options, incluindo as aninhadas
+	MaxDepth     int // profundidade de options (o próprio mapa conta como 1)
+}
+
+// withDefaults preenche os limites não configurados
+func (l CommandLimits) withDefaults() CommandLimits {
+	if l.MaxArgs <= 0 {
+		l.MaxArgs = DefaultMaxCommandArgs
+	}
+	if l.MaxArgsBytes <= 0 {
+		l.MaxArgsBytes = DefaultMaxCommandArgsBytes
+	}
+	if l.MaxOptions <= 0 {
+		l.MaxOptions = DefaultMaxCommandOptions
+	}
+	if l.MaxDepth <= 0 {
+		l.MaxDepth = DefaultMaxCommandOptionsDepth
+	}
+	return l
+}
+
+// OversizedCommandError indica um comando que excede CommandLimits.
+// Preview contém apenas um trecho truncado da entrada, seguro para logs.
+type OversizedCommandError struct {
+	Field   string
+	Limit   int
+	Actual  int
+	Preview string
+}
+
+func (e *OversizedCommandError) Error() string {
+	return fmt.Sprintf("command %s exceeds limit: %d > %d", e.Field, e.Actual, e.Limit)
+}
+
+// CheckCommandLimits verifica args e options do comando contra os limites.
+// A contagem é interrompida assim que um limite é ultrapassado, para que
+// entradas enormes não custem mais que o necessário para rejeitá-las.
+func CheckCommandLimits(command *Command, limits CommandLimits) error {
+	limits = limits.withDefaults()
+
+	if len(command.Args) > limits.MaxArgs {
+		return &OversizedCommandError{
+			Field:   "args",
+			Limit:   limits.MaxArgs,
+			Actual:  len(command.Args),
+			Preview: PreviewArgs(command.Args),
+		}
+	}
+
+	total := 0
+	for _, arg := range command.Args {
+		total += len(arg)
+	}
+	if total > limits.MaxArgsBytes {
+		return &OversizedCommandError{
+			Field:   "args_bytes",
+			Limit:   limits.MaxArgsBytes,
+			Actual:  total,
+			Preview: PreviewArgs(command.Args),
+		}
+	}
+
+	if len(command.Options) > limits.MaxOptions {
+		return &OversizedCommandError{
+			Field:  "options",
+			Limit:  limits.MaxOptions,
+			Actual: len(command.Options),
+		}
+	}
+
+	entries, depth := 0, 0
+	measureOptions(command.Options, 1, limits, &entries, &depth)
+	if depth > limits.MaxDepth {
+		return &OversizedCommandError{Field: "options_depth", Limit: limits.MaxDepth, Actual: depth}
+	}
+	if entries > limits.MaxOptions {
+		return &OversizedCommandError{Field: "options", Limit: limits.MaxOptions, Actual: entries}
+	}
+
+	return nil
+}
+
+// checkRawCommandLimits verifica args e options ainda em JSON bruto, lendo
+// token a token e parando no primeiro limite ultrapassado: um comando
+// enorme é recusado sem que args ou options sejam materializados. Valores
+// que não são array de strings ou objeto ficam para a decodificação tipada
+// reportar. Actual é então um mínimo.
+func checkRawCommandLimits(fields map[string]json.RawMessage, limits CommandLimits) error {
+	limits = limits.withDefaults()
+
+	if raw, ok := fields["args"]; ok {
+		if err := scanRawArgs(raw, limits); err != nil {
+			return err
+		}
+	}
+	if raw, ok := fields["options"]; ok {
+		if err := scanRawOptions(raw, limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRawArgs conta args e bytes de um array JSON de strings
+func scanRawArgs(raw json.RawMessage, limits CommandLimits) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil
+	}
+
+	var preview []string
+	count, total := 0, 0
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		arg, ok := token.(string)
+		if !ok {
+			return nil
+		}
+
+		count++
+		total += len(arg)
+		if len(preview) < commandPreviewArgs {
+			preview = append(preview, Preview(arg))
+		}
+
+		if count > limits.MaxArgs {
+			return &OversizedCommandError{
+				Field:   "args",
+				Limit:   limits.MaxArgs,
+				Actual:  count,
+				Preview: PreviewArgs(preview) + fmt.Sprintf(" ... (more than %d args)", limits.MaxArgs),
+			}
+		}
+		if total > limits.MaxArgsBytes {
+			return &OversizedCommandError{
+				Field:   "args_bytes",
+				Limit:   limits.MaxArgsBytes,
+				Actual:  total,
+				Preview: PreviewArgs(preview),
+			}
+		}
+	}
+	return nil
+}
+
+// scanRawOptions conta entradas e profundidade de um objeto JSON com as
+// mesmas regras de measureOptions
+func scanRawOptions(raw json.RawMessage, limits CommandLimits) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil
+	}
+
+	// Cada nível aberto guarda se é objeto e se o próximo token é uma chave
+	type level struct {
+		object    bool
+		expectKey bool
+	}
+	stack := []level{{object: true, expectKey: true}}
+	entries := 0
+
+	for len(stack) > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil
+		}
+		top := &stack[len(stack)-1]
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top.object && top.expectKey {
+			entries++
+			top.expectKey = false
+		} else {
+			if top.object {
+				top.expectKey = true
+			} else {
+				entries++
+			}
+			if delim, ok := token.(json.Delim); ok {
+				stack = append(stack, level{object: delim == '{', expectKey: delim == '{'})
+				if len(stack) > limits.MaxDepth {
+					return &OversizedCommandError{Field: "options_depth", Limit: limits.MaxDepth, Actual: len(stack)}
+				}
+			}
+		}
+
+		if entries > limits.MaxOptions {
+			return &OversizedCommandError{Field: "options", Limit: limits.MaxOptions, Actual: entries}
+		}
+	}
+	return nil
+}
+
+// measureOptions conta entradas e profundidade de um valor de options,
+// parando ao passar de qualquer limite (Actual é então um mínimo)
+func measureOptions(value interface{}, level int, limits CommandLimits, entries, depth *int) {
+	if *entries > limits.MaxOptions || *depth > limits.MaxDepth {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if level > *depth {
+			*depth = level
+		}
+		for _, item := range v {
+			*entries++
+			measureOptions(item, level+1, limits, entries, depth)
+			if *entries > limits.MaxOptions || *depth > limits.MaxDepth {
+				return
+			}
+		}
+	case []interface{}:
+		if level > *depth {
+			*depth = level
+		}
+		for _, item := range v {
+			*entries++
+			measureOptions(item, level+1, limits, entries, depth)
+			if *entries > limits.MaxOptions || *depth > limits.MaxDepth {
+				return
+			}
+		}
+	}
+}
+
+// PreviewArgs retorna uma prévia curta dos args para logs
+func PreviewArgs(args []string) string {
+	count := len(args)
+	if count > commandPreviewArgs {
+		args = args[:commandPreviewArgs]
+	}
+
+	preview := Preview(strings.Join(args, " "))
+	if count > commandPreviewArgs {
+		preview += fmt.Sprintf(" ... (%d args)", count)
+	}
+	return preview
+}
+
+// Preview trunca um texto para exibição em logs sem cortar caracteres UTF-8
+func Preview(text string) string {
+	if len(text) <= commandPreviewBytes {
+		return text
+	}
+
+	cut := commandPreviewBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + fmt.Sprintf("... (%d bytes)", len(text))
+}
@@ -0,0 +1,148 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// argsJSON monta um array JSON com count args de size bytes cada
+func argsJSON(count, size int) string {
+	args := make([]string, count)
+	for i := range args {
+		args[i] = fmt.Sprintf("%q", strings.Repeat("a", size))
+	}
+	return "[" + strings.Join(args, ",") + "]"
+}
+
+// flatOptionsJSON monta um objeto JSON com count entradas
+func flatOptionsJSON(count int) string {
+	entries := make([]string, count)
+	for i := range entries {
+		entries[i] = fmt.Sprintf(`"k%d":%d`, i, i)
+	}
+	return "{" + strings.Join(entries, ",") + "}"
+}
+
+// nestedOptionsJSON monta um objeto JSON com depth níveis (o próprio objeto conta como 1)
+func nestedOptionsJSON(depth int) string {
+	value := "1"
+	for i := 1; i < depth; i++ {
+		value = `{"n":` + value + "}"
+	}
+	return `{"n":` + value + "}"
+}
+
+func TestDecodeCommandLimits(t *testing.T) {
+	limits := CommandLimits{MaxArgs: 4, MaxArgsBytes: 40, MaxOptions: 6, MaxDepth: 3}
+
+	tests := []struct {
+		name  string
+		field string
+		value string
+		want  string // Field do erro; vazio aceita
+	}{
+		{"args below limit", "args", argsJSON(3, 1), ""},
+		{"args at limit", "args", argsJSON(4, 1), ""},
+		{"args above limit", "args", argsJSON(5, 1), "args"},
+		{"args far above limit", "args", argsJSON(10000, 1), "args"},
+		{"args bytes below limit", "args", argsJSON(4, 9), ""},
+		{"args bytes at limit", "args", argsJSON(4, 10), ""},
+		{"args bytes above limit", "args", argsJSON(4, 11), "args_bytes"},
+		{"single huge arg", "args", argsJSON(1, 1<<20), "args_bytes"},
+		{"escaped bytes counted decoded", "args", `["` + strings.Repeat(`\u0041`, 40) + `"]`, ""},
+		{"options below limit", "options", flatOptionsJSON(5), ""},
+		{"options at limit", "options", flatOptionsJSON(6), ""},
+		{"options above limit", "options", flatOptionsJSON(7), "options"},
+		{"nested entries counted", "options", `{"a":[1,2,3],"b":{"c":1,"d":2}}`, "options"},
+		{"nested entries at limit", "options", `{"a":[1,2],"b":{"c":1}}`, ""},
+		{"depth below limit", "options", nestedOptionsJSON(2), ""},
+		{"depth at limit", "options", nestedOptionsJSON(3), ""},
+		{"depth above limit", "options", nestedOptionsJSON(4), "options_depth"},
+		{"array depth above limit", "options", `{"a":[[[1]]]}`, "options_depth"},
+		{"deeply nested", "options", strings.Repeat(`{"n":`, 5000) + "1" + strings.Repeat("}", 5000), "options_depth"},
+	}
+
+	for _, tt := range tests {
+		data := fmt.Sprintf(`{"type":"shell","%s":%s}`, tt.field, tt.value)
+		var generic interface{}
+		if err := json.Unmarshal([]byte(data), &generic); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		for input, value := range map[string]interface{}{"raw": json.RawMessage(data), "decoded": generic} {
+			t.Run(tt.name+"/"+input, func(t *testing.T) {
+				command, err := DecodeCommand("cmd-1", value, false, limits)
+				var oversized *OversizedCommandError
+				if tt.want == "" {
+					if err != nil {
+						t.Fatalf("rejected: %v", err)
+					}
+					return
+				}
+				if !errors.As(err, &oversized) || oversized.Field != tt.want {
+					t.Fatalf("error = %v, want %s limit", err, tt.want)
+				}
+				if oversized.Actual <= oversized.Limit {
+					t.Errorf("actual %d not above limit %d", oversized.Actual, oversized.Limit)
+				}
+				if command.Args != nil || command.Options != nil {
+					t.Error("oversized command kept args or options")
+				}
+				if len(oversized.Preview) > 4*commandPreviewBytes {
+					t.Errorf("preview has %d bytes", len(oversized.Preview))
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeCommandLimitsLeaveTypeErrorsToDecoding(t *testing.T) {
+	limits := CommandLimits{MaxArgs: 1}
+	for _, data := range []string{`{"args":"a b c"}`, `{"args":[1,2,3]}`, `{"options":[1,2]}`} {
+		_, err := DecodeCommand("cmd-1", json.RawMessage(data), false, limits)
+		var decodeErr *CommandDecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%s: error = %v, want a decode error", data, err)
+		}
+	}
+}
+
+func TestCheckCommandLimitsDefaults(t *testing.T) {
+	command := &Command{Args: make([]string, DefaultMaxCommandArgs)}
+	if err := CheckCommandLimits(command, CommandLimits{}); err != nil {
+		t.Fatalf("args at the default limit rejected: %v", err)
+	}
+	command.Args = append(command.Args, "x")
+	var oversized *OversizedCommandError
+	if err := CheckCommandLimits(command, CommandLimits{}); !errors.As(err, &oversized) || oversized.Limit != DefaultMaxCommandArgs {
+		t.Fatalf("args above the default limit: %v", err)
+	}
+}
+
+func TestParseWebSocketMessageKeepsCommandRaw(t *testing.T) {
+	message, err := parseWebSocketMessage([]byte(`{"type":"command","id":"c1","data":{"type":"info","args":["a"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := message.Data.(json.RawMessage); !ok || message.ID != "c1" {
+		t.Fatalf("command data = %T, id = %q", message.Data, message.ID)
+	}
+
+	message, err = parseWebSocketMessage([]byte(`{"type":"ping","data":{"seq":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := message.Data.(map[string]interface{}); !ok {
+		t.Fatalf("ping data = %T", message.Data)
+	}
+
+	if message, err = parseWebSocketMessage([]byte(`{"type":"command"}`)); err != nil || message.Data != nil {
+		t.Fatalf("command without data = %v, %v", message.Data, err)
+	}
+	if _, err := parseWebSocketMessage([]byte(`{"type":`)); err == nil {
+		t.Fatal("truncated frame accepted")
+	}
+}
@@ -0,0 +1,474 @@
+package comms
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"agente-poc/internal/version"
+
+	"github.com/gorilla/websocket"
+)
+
+// Diagnostic step statuses
+const (
+	DiagnosticPass = "pass"
+	DiagnosticFail = "fail"
+	DiagnosticWarn = "warn"
+)
+
+// DiagnosticsConfig configures a connectivity diagnostics run
+type DiagnosticsConfig struct {
+	BackendURL         string
+	WebSocketURL       string
+	Token              string
+	HealthPath         string
+	FallbackResolver   string
+	StepTimeout        time.Duration
+	PinnedCertificates []string
+	TLSSkipVerify      bool
+	TLSFiles                  // certificado de cliente e CA, como nos clientes do agente
+	ProxyURL           string // proxy explícito, como nos clientes do agente (ver ProxyFunc)
+	MaxClockSkew       time.Duration
+}
+
+// tlsConfig monta a configuração TLS das sondagens a partir de TLSFiles e
+// TLSSkipVerify, como BuildTLSConfig faz para os clientes do agente
+func (c DiagnosticsConfig) tlsConfig() (*tls.Config, error) {
+	return BuildTLSConfig(c.TLSFiles, c.TLSSkipVerify)
+}
+
+// DiagnosticStep is the outcome of a single probe
+type DiagnosticStep struct {
+	Name       string                 `json:"name"`
+	Target     string                 `json:"target,omitempty"`
+	Status     string                 `json:"status"`
+	DurationMs int64                  `json:"duration_ms"`
+	Error      string                 `json:"error,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// DiagnosticReport is the full connectivity report, suitable for attaching to a ticket
+type DiagnosticReport struct {
+	StartedAt    time.Time        `json:"started_at"`
+	FinishedAt   time.Time        `json:"finished_at"`
+	Hostname     string           `json:"hostname"`
+	BackendURL   string           `json:"backend_url"`
+	WebSocketURL string           `json:"websocket_url"`
+	Steps        []DiagnosticStep `json:"steps"`
+	Passed       int              `json:"passed"`
+	Failed       int              `json:"failed"`
+	Warnings     int              `json:"warnings"`
+}
+
+// OK reports whether no step failed
+func (r *DiagnosticReport) OK() bool {
+	return r.Failed == 0
+}
+
+// diagnosticTarget is a host:port endpoint derived from a configured URL
+type diagnosticTarget struct {
+	host string
+	port string
+	tls  bool
+}
+
+// RunDiagnostics runs the connectivity probe sequence. Every step is bounded
+// by StepTimeout and the report always contains whatever was gathered, even
+// if ctx is cancelled midway.
+func RunDiagnostics(ctx context.Context, config DiagnosticsConfig) *DiagnosticReport {
+	if config.StepTimeout <= 0 {
+		config.StepTimeout = 5 * time.Second
+	}
+	if config.HealthPath == "" {
+		config.HealthPath = "/health"
+	}
+	if config.FallbackResolver == "" {
+		config.FallbackResolver = "1.1.1.1:53"
+	}
+	if config.MaxClockSkew <= 0 {
+		config.MaxClockSkew = 2 * time.Minute
+	}
+
+	hostname, _ := os.Hostname()
+	report := &DiagnosticReport{
+		StartedAt:    time.Now(),
+		Hostname:     hostname,
+		BackendURL:   config.BackendURL,
+		WebSocketURL: config.WebSocketURL,
+		Steps:        make([]DiagnosticStep, 0),
+	}
+
+	var targets []diagnosticTarget
+	for _, raw := range []string{config.BackendURL, config.WebSocketURL} {
+		if raw == "" {
+			continue
+		}
+		target, err := parseDiagnosticTarget(raw)
+		if err != nil {
+			report.add(DiagnosticStep{Name: "parse_url", Target: raw, Status: DiagnosticFail, Error: err.Error()})
+			continue
+		}
+		targets = append(targets, target)
+	}
+
+	var localAddr net.Addr
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		report.add(runStep(ctx, config.StepTimeout, "dns_system", target.host, func(c context.Context) (map[string]interface{}, error) {
+			return resolveHost(c, net.DefaultResolver, target.host)
+		}))
+		report.add(runStep(ctx, config.StepTimeout, "dns_fallback", target.host, func(c context.Context) (map[string]interface{}, error) {
+			return resolveHost(c, fallbackResolver(config.FallbackResolver), target.host)
+		}))
+		report.add(runStep(ctx, config.StepTimeout, "tcp_connect", net.JoinHostPort(target.host, target.port), func(c context.Context) (map[string]interface{}, error) {
+			addr, err := probeTCP(c, target)
+			if err != nil {
+				return nil, err
+			}
+			localAddr = addr
+			return map[string]interface{}{"local_addr": addr.String()}, nil
+		}))
+		if target.tls {
+			report.add(runStep(ctx, config.StepTimeout, "tls_handshake", net.JoinHostPort(target.host, target.port), func(c context.Context) (map[string]interface{}, error) {
+				return probeTLS(c, target, config)
+			}))
+		}
+	}
+
+	if config.BackendURL != "" && ctx.Err() == nil {
+		healthURL := strings.TrimRight(config.BackendURL, "/") + config.HealthPath
+		report.add(runStep(ctx, config.StepTimeout, "http_health", healthURL, func(c context.Context) (map[string]interface{}, error) {
+			return probeHTTP(c, healthURL, config)
+		}))
+		report.add(runStep(ctx, config.StepTimeout, "proxy", config.BackendURL, func(c context.Context) (map[string]interface{}, error) {
+			return detectProxy(config)
+		}))
+	}
+
+	if config.WebSocketURL != "" && ctx.Err() == nil {
+		report.add(runStep(ctx, config.StepTimeout, "websocket_upgrade", config.WebSocketURL, func(c context.Context) (map[string]interface{}, error) {
+			return probeWebSocket(c, config)
+		}))
+	}
+
+	if ctx.Err() == nil {
+		report.add(runStep(ctx, config.StepTimeout, "mtu", "", func(c context.Context) (map[string]interface{}, error) {
+			return probeMTU(localAddr)
+		}))
+	}
+
+	if ctx.Err() != nil {
+		report.add(DiagnosticStep{Name: "aborted", Status: DiagnosticWarn, Error: ctx.Err().Error()})
+	}
+
+	report.FinishedAt = time.Now()
+	return report
+}
+
+// add appends a step and updates the counters
+func (r *DiagnosticReport) add(step DiagnosticStep) {
+	switch step.Status {
+	case DiagnosticPass:
+		r.Passed++
+	case DiagnosticFail:
+		r.Failed++
+	case DiagnosticWarn:
+		r.Warnings++
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+// runStep executes a probe under its own timeout. A probe may downgrade
+// its result to a warning by setting details["warning"].
+func runStep(ctx context.Context, timeout time.Duration, name, target string, probe func(context.Context) (map[string]interface{}, error)) DiagnosticStep {
+	stepCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := probe(stepCtx)
+	step := DiagnosticStep{
+		Name:       name,
+		Target:     target,
+		Status:     DiagnosticPass,
+		DurationMs: time.Since(start).Milliseconds(),
+		Details:    details,
+	}
+
+	if err != nil {
+		step.Status = DiagnosticFail
+		step.Error = err.Error()
+	} else if _, warn := details["warning"]; warn {
+		step.Status = DiagnosticWarn
+	}
+
+	return step
+}
+
+// parseDiagnosticTarget extracts host, port and scheme from a backend URL
+func parseDiagnosticTarget(raw string) (diagnosticTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return diagnosticTarget{}, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return diagnosticTarget{}, fmt.Errorf("URL has no host: %s", raw)
+	}
+
+	target := diagnosticTarget{host: u.Hostname(), port: u.Port()}
+	switch u.Scheme {
+	case "https", "wss":
+		target.tls = true
+		if target.port == "" {
+			target.port = "443"
+		}
+	case "http", "ws":
+		if target.port == "" {
+			target.port = "80"
+		}
+	default:
+		return diagnosticTarget{}, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+
+	return target, nil
+}
+
+// fallbackResolver returns a resolver that bypasses the system configuration
+func fallbackResolver(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// resolveHost resolves a host name with the given resolver
+func resolveHost(ctx context.Context, resolver *net.Resolver, host string) (map[string]interface{}, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return map[string]interface{}{"addresses": []string{host}, "literal": true}, nil
+	}
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"addresses": addrs}, nil
+}
+
+// probeTCP opens and closes a TCP connection, returning the local address used
+func probeTCP(ctx context.Context, target diagnosticTarget) (net.Addr, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(target.host, target.port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr(), nil
+}
+
+// probeTLS performs a TLS handshake and summarizes the negotiated session
+func probeTLS(ctx context.Context, target diagnosticTarget, config DiagnosticsConfig) (map[string]interface{}, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = target.host
+	dialer := &tls.Dialer{Config: tlsConfig}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target.host, target.port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	chain := make([]map[string]interface{}, 0, len(state.PeerCertificates))
+	for _, cert := range state.PeerCertificates {
+		sum := sha256.Sum256(cert.Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		chain = append(chain, map[string]interface{}{
+			"subject":    cert.Subject.String(),
+			"issuer":     cert.Issuer.String(),
+			"not_before": cert.NotBefore,
+			"not_after":  cert.NotAfter,
+			"sha256":     fingerprint,
+		})
+	}
+
+	details := map[string]interface{}{
+		"version":      tls.VersionName(state.Version),
+		"cipher_suite": tls.CipherSuiteName(state.CipherSuite),
+		"alpn":         state.NegotiatedProtocol,
+		"chain":        chain,
+	}
+
+	if len(config.PinnedCertificates) > 0 {
+		rawCerts := make([][]byte, 0, len(state.PeerCertificates))
+		for _, cert := range state.PeerCertificates {
+			rawCerts = append(rawCerts, cert.Raw)
+		}
+		matched := CertificatePinMatch(rawCerts, config.PinnedCertificates)
+		details["pin_match"] = matched
+		if !matched {
+			return details, fmt.Errorf("no certificate in chain matches the configured pins")
+		}
+	}
+
+	return details, nil
+}
+
+// probeHTTP fetches the health endpoint and checks the clock skew against the Date header
+func probeHTTP(ctx context.Context, healthURL string, config DiagnosticsConfig) (map[string]interface{}, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := ProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:                  proxy,
+		OnProxyConnectResponse: proxyConnectResponse,
+		TLSClientConfig:        tlsConfig,
+	}}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	details := map[string]interface{}{
+		"status_code": resp.StatusCode,
+		"protocol":    resp.Proto,
+	}
+
+	if serverDate, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		skew := time.Since(serverDate)
+		details["clock_skew_seconds"] = skew.Seconds()
+		if skew > config.MaxClockSkew || skew < -config.MaxClockSkew {
+			details["warning"] = fmt.Sprintf("local clock differs from server by %s", skew.Round(time.Second))
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		return details, fmt.Errorf("health endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return details, nil
+}
+
+// detectProxy reports the proxy that would be used for the backend URL and
+// whether it comes from the agent configuration or the environment
+func detectProxy(config DiagnosticsConfig) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, config.BackendURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := ProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := proxy(req)
+	if err != nil {
+		return nil, err
+	}
+
+	source := "environment"
+	if config.ProxyURL != "" {
+		source = "config"
+	}
+	return map[string]interface{}{
+		"proxy":  redactedProxy(proxyURL),
+		"source": source,
+	}, nil
+}
+
+// probeWebSocket attempts a WebSocket upgrade and closes it immediately
+func probeWebSocket(ctx context.Context, config DiagnosticsConfig) (map[string]interface{}, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := ProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	dialer := websocket.Dialer{
+		Proxy:           proxy,
+		TLSClientConfig: tlsConfig,
+	}
+
+	headers := http.Header{}
+	headers.Set("User-Agent", version.UserAgent())
+	if config.Token != "" {
+		headers.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, config.WebSocketURL, headers)
+	details := map[string]interface{}{}
+	if resp != nil {
+		details["status_code"] = resp.StatusCode
+	}
+	if err != nil {
+		return details, err
+	}
+	defer conn.Close()
+
+	_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return details, nil
+}
+
+// probeMTU reports the MTU of the interface used to reach the backend
+func probeMTU(localAddr net.Addr) (map[string]interface{}, error) {
+	tcpAddr, ok := localAddr.(*net.TCPAddr)
+	if !ok || tcpAddr == nil {
+		return map[string]interface{}{"warning": "no successful connection to derive the outbound interface"}, nil
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(tcpAddr.IP) {
+				details := map[string]interface{}{"interface": iface.Name, "mtu": iface.MTU}
+				if iface.MTU > 0 && iface.MTU < 1280 {
+					details["warning"] = "MTU below the IPv6 minimum; large TLS records may be fragmented"
+				}
+				return details, nil
+			}
+		}
+	}
+
+	return map[string]interface{}{"warning": "outbound interface not found"}, nil
+}
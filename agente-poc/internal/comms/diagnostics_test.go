@@ -0,0 +1,252 @@
+package comms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// diagnosticsBackend é um backend falso com /health e /ws, cada etapa
+// quebrável isoladamente
+type diagnosticsBackend struct {
+	server       *httptest.Server
+	healthStatus int
+	refuseWS     bool
+}
+
+func newDiagnosticsBackend(t *testing.T) *diagnosticsBackend {
+	t.Helper()
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	backend := &diagnosticsBackend{healthStatus: http.StatusOK}
+	upgrader := websocket.Upgrader{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(backend.healthStatus)
+	})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if backend.refuseWS {
+			http.Error(w, "upgrade refused", http.StatusForbidden)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _, _ = conn.ReadMessage()
+	})
+	backend.server = httptest.NewUnstartedServer(mux)
+	backend.server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	backend.server.StartTLS()
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+// config aponta o diagnóstico para o backend, confiando no certificado dele
+func (b *diagnosticsBackend) config(t *testing.T) DiagnosticsConfig {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b.server.Certificate().Raw})
+	if err := os.WriteFile(caFile, block, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return DiagnosticsConfig{
+		BackendURL:       b.server.URL,
+		WebSocketURL:     "wss" + strings.TrimPrefix(b.server.URL, "https") + "/ws",
+		FallbackResolver: "127.0.0.1:1",
+		StepTimeout:      2 * time.Second,
+		TLSFiles:         TLSFiles{CACertFile: caFile},
+	}
+}
+
+// fingerprint é o pin do certificado do backend
+func (b *diagnosticsBackend) fingerprint() string {
+	sum := sha256.Sum256(b.server.Certificate().Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// steps indexa as etapas do relatório pelo nome (a última com cada nome)
+func steps(report *DiagnosticReport) map[string]DiagnosticStep {
+	byName := make(map[string]DiagnosticStep)
+	for _, step := range report.Steps {
+		byName[step.Name] = step
+	}
+	return byName
+}
+
+func TestRunDiagnosticsBrokenStages(t *testing.T) {
+	tests := []struct {
+		name       string
+		breakStage func(*diagnosticsBackend, *DiagnosticsConfig)
+		failed     []string
+	}{
+		{
+			name: "healthy backend",
+		},
+		{
+			name: "pin mismatch",
+			breakStage: func(_ *diagnosticsBackend, config *DiagnosticsConfig) {
+				config.PinnedCertificates = []string{strings.Repeat("ab", sha256.Size)}
+			},
+			failed: []string{"tls_handshake"},
+		},
+		{
+			name: "untrusted certificate",
+			breakStage: func(_ *diagnosticsBackend, config *DiagnosticsConfig) {
+				config.TLSFiles = TLSFiles{}
+			},
+			failed: []string{"tls_handshake", "http_health", "websocket_upgrade"},
+		},
+		{
+			name: "health endpoint failing",
+			breakStage: func(backend *diagnosticsBackend, _ *DiagnosticsConfig) {
+				backend.healthStatus = http.StatusServiceUnavailable
+			},
+			failed: []string{"http_health"},
+		},
+		{
+			name: "websocket upgrade refused",
+			breakStage: func(backend *diagnosticsBackend, _ *DiagnosticsConfig) {
+				backend.refuseWS = true
+			},
+			failed: []string{"websocket_upgrade"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newDiagnosticsBackend(t)
+			config := backend.config(t)
+			config.PinnedCertificates = []string{backend.fingerprint()}
+			if tt.breakStage != nil {
+				tt.breakStage(backend, &config)
+			}
+
+			report := RunDiagnostics(context.Background(), config)
+			byName := steps(report)
+
+			for _, name := range []string{"dns_system", "tcp_connect", "tls_handshake", "http_health", "proxy", "websocket_upgrade", "mtu"} {
+				step, ok := byName[name]
+				if !ok {
+					t.Fatalf("step %s missing from report", name)
+				}
+				shouldFail := slices.Contains(tt.failed, name)
+				if shouldFail && step.Status != DiagnosticFail {
+					t.Errorf("step %s: status %s, want fail", name, step.Status)
+				}
+				if !shouldFail && step.Status == DiagnosticFail {
+					t.Errorf("step %s failed: %s", name, step.Error)
+				}
+			}
+			// O resolvedor de fallback aponta para uma porta fechada, mas um
+			// IP literal não é resolvido
+			if step := byName["dns_fallback"]; step.Status != DiagnosticPass {
+				t.Errorf("dns_fallback for a literal IP: %s (%s)", step.Status, step.Error)
+			}
+			if report.OK() != (len(tt.failed) == 0) {
+				t.Errorf("OK() = %t with %d failed steps", report.OK(), report.Failed)
+			}
+		})
+	}
+}
+
+func TestRunDiagnosticsPinMatch(t *testing.T) {
+	backend := newDiagnosticsBackend(t)
+	config := backend.config(t)
+	config.PinnedCertificates = []string{strings.ToUpper(backend.fingerprint())}
+
+	step := steps(RunDiagnostics(context.Background(), config))["tls_handshake"]
+	if step.Status != DiagnosticPass || step.Details["pin_match"] != true {
+		t.Fatalf("tls_handshake = %s, pin_match = %v", step.Status, step.Details["pin_match"])
+	}
+
+	config.PinnedCertificates = nil
+	step = steps(RunDiagnostics(context.Background(), config))["tls_handshake"]
+	if _, ok := step.Details["pin_match"]; ok {
+		t.Fatal("pin_match reported without configured pins")
+	}
+}
+
+func TestRunDiagnosticsUnreachableBackend(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	report := RunDiagnostics(context.Background(), DiagnosticsConfig{
+		BackendURL:  "http://" + addr,
+		StepTimeout: time.Second,
+	})
+	byName := steps(report)
+	if byName["tcp_connect"].Status != DiagnosticFail || byName["http_health"].Status != DiagnosticFail {
+		t.Fatalf("tcp_connect = %s, http_health = %s", byName["tcp_connect"].Status, byName["http_health"].Status)
+	}
+	if byName["mtu"].Status != DiagnosticWarn {
+		t.Fatalf("mtu without a connection = %s, want warn", byName["mtu"].Status)
+	}
+}
+
+func TestRunDiagnosticsInvalidURLAndCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := RunDiagnostics(ctx, DiagnosticsConfig{
+		BackendURL:   "ftp://backend.example",
+		WebSocketURL: "wss://",
+	})
+	byName := steps(report)
+	if byName["parse_url"].Status != DiagnosticFail {
+		t.Fatalf("parse_url = %s, want fail", byName["parse_url"].Status)
+	}
+	if byName["aborted"].Status != DiagnosticWarn {
+		t.Fatal("cancelled run did not report the abort")
+	}
+	if report.FinishedAt.IsZero() {
+		t.Fatal("partial report not finished")
+	}
+}
+
+func TestRunDiagnosticsStepTimeout(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	start := time.Now()
+	report := RunDiagnostics(context.Background(), DiagnosticsConfig{
+		BackendURL:  server.URL,
+		StepTimeout: 200 * time.Millisecond,
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("diagnostics took %s with a hanging health endpoint", elapsed)
+	}
+	if step := steps(report)["http_health"]; step.Status != DiagnosticFail {
+		t.Fatalf("http_health = %s, want fail on timeout", step.Status)
+	}
+}
@@ -0,0 +1,112 @@
+package comms
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"agente-poc/internal/version"
+)
+
+// downloadServer serve /file com o corpo informado e registra o
+// Authorization de cada requisição
+type downloadServer struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	auths []string
+}
+
+func newDownloadServer(t *testing.T, body string, chunked bool) *downloadServer {
+	t.Helper()
+	backend := &downloadServer{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend.mu.Lock()
+		backend.auths = append(backend.auths, r.Header.Get("Authorization"))
+		backend.mu.Unlock()
+		if r.Header.Get("X-Agent-Version") != version.Version {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		if chunked {
+			// Sem Content-Length: o limite vale durante a cópia
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *downloadServer) lastAuth() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.auths[len(b.auths)-1]
+}
+
+func TestDownload(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	backend := newDownloadServer(t, "binary", false)
+	other := newDownloadServer(t, "binary", false)
+	client, err := NewHTTPClient(HTTPConfig{BaseURL: backend.server.URL, Token: "secret", MaxRetries: -1, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Caminho relativo vai para o backend, com o token
+	var buf bytes.Buffer
+	if written, err := client.Download(context.Background(), "/file", &buf, 1024); err != nil || written != 6 || buf.String() != "binary" {
+		t.Fatalf("relative download = %d %q, %v", written, buf.String(), err)
+	}
+	if auth := backend.lastAuth(); auth != "Bearer secret" {
+		t.Fatalf("backend Authorization = %q", auth)
+	}
+
+	// Outro host (CDN) não recebe o token
+	buf.Reset()
+	if _, err := client.Download(context.Background(), other.server.URL+"/file", &buf, 1024); err != nil || buf.String() != "binary" {
+		t.Fatalf("external download = %q, %v", buf.String(), err)
+	}
+	if auth := other.lastAuth(); auth != "" {
+		t.Fatalf("token sent to another host: %q", auth)
+	}
+
+	if _, err := client.Download(context.Background(), "/missing", &buf, 1024); err == nil {
+		t.Fatal("404 accepted")
+	} else if status, ok := err.(*HTTPStatusError); !ok || status.StatusCode != http.StatusNotFound {
+		t.Fatalf("404 error = %v", err)
+	}
+}
+
+func TestDownloadSizeLimit(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	body := strings.Repeat("x", 100)
+	for _, chunked := range []bool{false, true} {
+		backend := newDownloadServer(t, body, chunked)
+		client, err := NewHTTPClient(HTTPConfig{BaseURL: backend.server.URL, MaxRetries: -1, Logger: testLogger(t)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if _, err := client.Download(context.Background(), "/file", &buf, 99); err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Fatalf("chunked %t: over the limit: %v", chunked, err)
+		}
+		if buf.Len() > 100 {
+			t.Fatalf("chunked %t: wrote %d bytes", chunked, buf.Len())
+		}
+
+		buf.Reset()
+		if written, err := client.Download(context.Background(), "/file", &buf, 100); err != nil || written != 100 {
+			t.Fatalf("chunked %t: at the limit = %d, %v", chunked, written, err)
+		}
+	}
+}
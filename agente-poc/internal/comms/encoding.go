@@ -0,0 +1,148 @@
+package comms
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content-Encoding dos corpos enviados ao backend
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+// SupportedEncodings são as codificações do agente em ordem de preferência;
+// enviadas no registro para o backend saber o que pode anunciar
+var SupportedEncodings = []string{EncodingZstd, EncodingGzip, EncodingIdentity}
+
+// DefaultCompressionThreshold é o tamanho padrão abaixo do qual o corpo vai
+// sem compressão (heartbeats e resultados curtos não compensam o custo)
+const DefaultCompressionThreshold = 16 * 1024
+
+// zstdEncoder é compartilhado; EncodeAll pode ser chamado concorrentemente
+var (
+	zstdEncoder     *zstd.Encoder
+	zstdEncoderErr  error
+	zstdEncoderOnce sync.Once
+)
+
+// NegotiateEncoding escolhe a codificação preferida do agente entre as
+// aceitas pelo backend. Sem lista (backend antigo), envia sem compressão;
+// se o backend deixa de anunciar zstd, a escolha cai para gzip.
+func NegotiateEncoding(accepted []string) string {
+	for _, encoding := range SupportedEncodings {
+		for _, candidate := range accepted {
+			if candidate == encoding {
+				return encoding
+			}
+		}
+	}
+	return EncodingIdentity
+}
+
+// fallbackEncoding é a próxima codificação na ordem de preferência, usada
+// quando o backend recusa a atual (415)
+func fallbackEncoding(encoding string) string {
+	for i, candidate := range SupportedEncodings {
+		if candidate == encoding && i+1 < len(SupportedEncodings) {
+			return SupportedEncodings[i+1]
+		}
+	}
+	return EncodingIdentity
+}
+
+// EncodeBody comprime data com a codificação informada
+func EncodeBody(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case EncodingIdentity, "":
+		return data, nil
+	case EncodingGzip:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return buf.Bytes(), nil
+	case EncodingZstd:
+		zstdEncoderOnce.Do(func() {
+			zstdEncoder, zstdEncoderErr = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		})
+		if zstdEncoderErr != nil {
+			return nil, fmt.Errorf("zstd: %w", zstdEncoderErr)
+		}
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/4)), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+// CompressionStats acumula o resultado da compressão dos corpos enviados
+// com uma codificação
+type CompressionStats struct {
+	Payloads     int64         `json:"payloads"`
+	RawBytes     int64         `json:"raw_bytes"`
+	EncodedBytes int64         `json:"encoded_bytes"`
+	EncodeTime   time.Duration `json:"encode_time_ns"`
+	// Ratio é RawBytes/EncodedBytes; LastRatio, o do último corpo
+	Ratio     float64 `json:"ratio"`
+	LastRatio float64 `json:"last_ratio"`
+}
+
+// CompressionMetrics registra razão de compressão e tempo de codificação
+// por codificação
+type CompressionMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*CompressionStats
+}
+
+// NewCompressionMetrics cria métricas vazias
+func NewCompressionMetrics() *CompressionMetrics {
+	return &CompressionMetrics{stats: make(map[string]*CompressionStats)}
+}
+
+// Record contabiliza um corpo de raw bytes codificado em encoded bytes
+func (m *CompressionMetrics) Record(encoding string, raw, encoded int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.stats[encoding]
+	if !ok {
+		stats = &CompressionStats{}
+		m.stats[encoding] = stats
+	}
+	stats.Payloads++
+	stats.RawBytes += int64(raw)
+	stats.EncodedBytes += int64(encoded)
+	stats.EncodeTime += elapsed
+	stats.LastRatio = compressionRatio(int64(raw), int64(encoded))
+	stats.Ratio = compressionRatio(stats.RawBytes, stats.EncodedBytes)
+}
+
+// Snapshot retorna uma cópia das estatísticas por codificação
+func (m *CompressionMetrics) Snapshot() map[string]CompressionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]CompressionStats, len(m.stats))
+	for encoding, stats := range m.stats {
+		snapshot[encoding] = *stats
+	}
+	return snapshot
+}
+
+// compressionRatio retorna raw/encoded (1 se nada foi codificado)
+func compressionRatio(raw, encoded int64) float64 {
+	if encoded <= 0 {
+		return 1
+	}
+	return float64(raw) / float64(encoded)
+}
@@ -0,0 +1,320 @@
+package comms
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decodeBody descomprime um corpo recebido conforme o Content-Encoding
+func decodeBody(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+	switch encoding {
+	case "", EncodingIdentity:
+		return body
+	case EncodingGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer decoder.Close()
+		data, err := decoder.DecodeAll(body, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	t.Fatalf("unexpected Content-Encoding %q", encoding)
+	return nil
+}
+
+// encodingBackend é um backend falso que recusa com 415 as codificações em
+// rejected e registra a codificação e o corpo decodificado de cada requisição
+type encodingBackend struct {
+	t        *testing.T
+	mu       sync.Mutex
+	rejected map[string]bool
+	received []string
+	bodies   [][]byte
+}
+
+func (b *encodingBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := r.Header.Get("Content-Encoding")
+	body, _ := io.ReadAll(r.Body)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.received = append(b.received, encoding)
+	if b.rejected[encoding] {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = w.Write([]byte(`{"error":"unsupported encoding"}`))
+		return
+	}
+	b.bodies = append(b.bodies, decodeBody(b.t, encoding, body))
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// newEncodingTestClient cria um cliente HTTP com limite de compressão de
+// 1 KiB apontando para o backend falso
+func newEncodingTestClient(t *testing.T, backend *encodingBackend) *HTTPClient {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:              server.URL,
+		MaxRetries:           -1,
+		CompressionThreshold: 1024,
+		Logger:               testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// largePayload é um corpo acima do limite de compressão e compressível
+func largePayload() map[string]string {
+	return map[string]string{"data": strings.Repeat("inventory ", 1000)}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accepted []string
+		want     string
+	}{
+		{nil, EncodingIdentity},
+		{[]string{}, EncodingIdentity},
+		{[]string{"gzip", "zstd"}, EncodingZstd},
+		{[]string{"identity", "gzip"}, EncodingGzip},
+		{[]string{"br", "deflate"}, EncodingIdentity},
+		{[]string{"identity"}, EncodingIdentity},
+	}
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.accepted); got != tt.want {
+			t.Errorf("NegotiateEncoding(%v) = %s, want %s", tt.accepted, got, tt.want)
+		}
+	}
+
+	for encoding, want := range map[string]string{
+		EncodingZstd:     EncodingGzip,
+		EncodingGzip:     EncodingIdentity,
+		EncodingIdentity: EncodingIdentity,
+		"br":             EncodingIdentity,
+	} {
+		if got := fallbackEncoding(encoding); got != want {
+			t.Errorf("fallbackEncoding(%s) = %s, want %s", encoding, got, want)
+		}
+	}
+}
+
+func TestEncodeBodyRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"name":"disk","size":1024}`, 200))
+	for _, encoding := range SupportedEncodings {
+		encoded, err := EncodeBody(encoding, data)
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if encoding != EncodingIdentity && len(encoded) >= len(data) {
+			t.Errorf("%s did not compress: %d -> %d bytes", encoding, len(data), len(encoded))
+		}
+		if decoded := decodeBody(t, encoding, encoded); !bytes.Equal(decoded, data) {
+			t.Errorf("%s round trip changed the body", encoding)
+		}
+	}
+	if _, err := EncodeBody("br", data); err == nil {
+		t.Fatal("unsupported encoding accepted")
+	}
+}
+
+func TestCompressionMetricsRecord(t *testing.T) {
+	metrics := NewCompressionMetrics()
+	metrics.Record(EncodingGzip, 1000, 250, 2*time.Millisecond)
+	metrics.Record(EncodingGzip, 3000, 500, 3*time.Millisecond)
+	metrics.Record(EncodingZstd, 1000, 0, time.Millisecond)
+
+	snapshot := metrics.Snapshot()
+	gzipStats := snapshot[EncodingGzip]
+	if gzipStats.Payloads != 2 || gzipStats.RawBytes != 4000 || gzipStats.EncodedBytes != 750 || gzipStats.EncodeTime != 5*time.Millisecond {
+		t.Fatalf("gzip stats = %+v", gzipStats)
+	}
+	if gzipStats.LastRatio != 6 || gzipStats.Ratio != 4000.0/750.0 {
+		t.Fatalf("gzip ratios = %f (last %f)", gzipStats.Ratio, gzipStats.LastRatio)
+	}
+	if zstdStats := snapshot[EncodingZstd]; zstdStats.Ratio != 1 || zstdStats.Payloads != 1 {
+		t.Fatalf("zstd stats with empty output = %+v", zstdStats)
+	}
+
+	// O snapshot é uma cópia
+	gzipStats.Payloads = 99
+	if metrics.Snapshot()[EncodingGzip].Payloads != 2 {
+		t.Fatal("snapshot shares state with the metrics")
+	}
+}
+
+func TestHTTPClientCompressesLargeBodies(t *testing.T) {
+	backend := &encodingBackend{t: t}
+	client := newEncodingTestClient(t, backend)
+	client.SetEncoding(EncodingZstd)
+
+	if err := client.POST(context.Background(), "/inventory", largePayload(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.POST(context.Background(), "/heartbeat", map[string]string{"status": "online"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// O corpo pequeno vai sem Content-Encoding
+	if got := strings.Join(backend.received, ","); got != "zstd," {
+		t.Fatalf("Content-Encoding received = %q", got)
+	}
+	if !bytes.Contains(backend.bodies[0], []byte("inventory inventory")) {
+		t.Fatal("compressed body not decoded to the original")
+	}
+
+	stats := client.CompressionStats()
+	if len(stats) != 1 {
+		t.Fatalf("compression recorded for %d encodings: %v", len(stats), stats)
+	}
+	zstdStats := stats[EncodingZstd]
+	if zstdStats.Payloads != 1 || zstdStats.RawBytes != int64(len(backend.bodies[0])) || zstdStats.Ratio <= 1 {
+		t.Fatalf("zstd stats = %+v", zstdStats)
+	}
+
+	metrics := client.GetMetrics()
+	if metrics.CompressedRequests != 1 || metrics.RawBodyBytes <= metrics.SentBodyBytes {
+		t.Fatalf("body metrics: %d compressed, %d raw, %d sent", metrics.CompressedRequests, metrics.RawBodyBytes, metrics.SentBodyBytes)
+	}
+}
+
+func TestHTTPClientEncodingFallbackOn415(t *testing.T) {
+	backend := &encodingBackend{t: t, rejected: map[string]bool{EncodingZstd: true}}
+	client := newEncodingTestClient(t, backend)
+	client.SetEncoding(EncodingZstd)
+
+	if err := client.POST(context.Background(), "/inventory", largePayload(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(backend.received, ","); got != "zstd,gzip" {
+		t.Fatalf("Content-Encoding tried = %q", got)
+	}
+	if client.Encoding() != EncodingGzip {
+		t.Fatalf("encoding after 415 = %s, want gzip", client.Encoding())
+	}
+
+	// Sem gzip também, cai para identity
+	backend.rejected[EncodingGzip] = true
+	if err := client.POST(context.Background(), "/inventory", largePayload(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if client.Encoding() != EncodingIdentity || backend.received[len(backend.received)-1] != "" {
+		t.Fatalf("encoding after a second 415 = %s", client.Encoding())
+	}
+}
+
+func TestManagerAcceptedEncodings(t *testing.T) {
+	client := newEncodingTestClient(t, &encodingBackend{t: t})
+	m := &Manager{config: &Config{}, logger: testLogger(t), httpClient: client}
+
+	m.applyAcceptedEncodings([]string{"gzip", "zstd"})
+	if m.Encoding() != EncodingZstd {
+		t.Fatalf("encoding = %s, want zstd", m.Encoding())
+	}
+
+	// O backend deixou de anunciar zstd: cai para gzip automaticamente
+	m.applyAcceptedEncodings([]string{"gzip"})
+	if m.Encoding() != EncodingGzip {
+		t.Fatalf("encoding without zstd = %s, want gzip", m.Encoding())
+	}
+
+	m.applyAcceptedEncodings(nil)
+	if m.Encoding() != EncodingIdentity {
+		t.Fatalf("encoding without a list = %s, want identity", m.Encoding())
+	}
+
+	// enable_compression usa gzip com backends que não anunciam
+	m.config.EnableCompression = true
+	m.applyAcceptedEncodings(nil)
+	if m.Encoding() != EncodingGzip {
+		t.Fatalf("encoding with compression enabled = %s, want gzip", m.Encoding())
+	}
+	m.applyAcceptedEncodings([]string{"identity"})
+	if m.Encoding() != EncodingIdentity {
+		t.Fatalf("advertised identity overridden: %s", m.Encoding())
+	}
+}
+
+func TestHTTPClientOptInGzip(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	backend := &encodingBackend{t: t, rejected: map[string]bool{}}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	// Sem limite configurado vale o padrão de 16 KB
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:           server.URL,
+		MaxRetries:        -1,
+		EnableCompression: true,
+		Logger:            testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Encoding() != EncodingGzip {
+		t.Fatalf("initial encoding = %s, want gzip", client.Encoding())
+	}
+
+	inventory := map[string]string{"data": strings.Repeat("x", DefaultCompressionThreshold)}
+	small := map[string]string{"data": strings.Repeat("x", DefaultCompressionThreshold/2)}
+	for _, body := range []interface{}{inventory, small, map[string]string{"status": "online"}} {
+		if err := client.POST(context.Background(), "/inventory", body, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Só o corpo acima do limite vai em gzip, e o backend o decodifica inteiro
+	if got := strings.Join(backend.received, ","); got != "gzip,," {
+		t.Fatalf("Content-Encoding received = %q", got)
+	}
+	if !bytes.Contains(backend.bodies[0], []byte(strings.Repeat("x", DefaultCompressionThreshold))) {
+		t.Fatal("gzip body not decoded to the original JSON")
+	}
+
+	metrics := client.GetMetrics()
+	var raw int64
+	for _, body := range backend.bodies {
+		raw += int64(len(body))
+	}
+	if metrics.CompressedRequests != 1 || metrics.RawBodyBytes != raw || metrics.SentBodyBytes >= metrics.RawBodyBytes {
+		t.Fatalf("body metrics: %d compressed, %d raw (want %d), %d sent", metrics.CompressedRequests, metrics.RawBodyBytes, raw, metrics.SentBodyBytes)
+	}
+
+	// Backend que não aceita gzip: 415 e reenvio sem compressão
+	backend.rejected[EncodingGzip] = true
+	if err := client.POST(context.Background(), "/inventory", inventory, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(backend.received[3:], ","); got != "gzip," || client.Encoding() != EncodingIdentity {
+		t.Fatalf("after 415: sent %q, encoding %s", got, client.Encoding())
+	}
+}
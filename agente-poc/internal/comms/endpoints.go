@@ -0,0 +1,141 @@
+package comms
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultFailoverThreshold é o número padrão de falhas consecutivas no
+// endpoint ativo antes de passar para o próximo
+const DefaultFailoverThreshold = 3
+
+// EndpointSet guarda as URLs do backend (uma por região) em ordem de
+// preferência. O ativo é o último que funcionou; depois de threshold falhas
+// consecutivas nele, o próximo da lista passa a ser o ativo. Não volta
+// sozinho para o primeiro: o ativo só muda com novas falhas. Seguro para uso
+// concorrente.
+type EndpointSet struct {
+	mu         sync.Mutex
+	urls       []string
+	active     int
+	failures   int
+	threshold  int
+	failovers  int64
+	onFailover func(from, to string)
+}
+
+// EndpointStatus descreve os endpoints configurados e o ativo
+type EndpointStatus struct {
+	Active              string   `json:"active"`
+	Endpoints           []string `json:"endpoints"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	Failovers           int64    `json:"failovers"`
+}
+
+// NewEndpointSet cria o conjunto com as URLs informadas (a primeira é a
+// primária); vazias e duplicadas são ignoradas. threshold <= 0 usa
+// DefaultFailoverThreshold. onFailover (opcional) é chamado fora do lock a
+// cada troca do ativo.
+func NewEndpointSet(urls []string, threshold int, onFailover func(from, to string)) *EndpointSet {
+	if threshold <= 0 {
+		threshold = DefaultFailoverThreshold
+	}
+	s := &EndpointSet{threshold: threshold, onFailover: onFailover}
+	for _, url := range urls {
+		if url != "" && !s.contains(url) {
+			s.urls = append(s.urls, url)
+		}
+	}
+	return s
+}
+
+func (s *EndpointSet) contains(url string) bool {
+	for _, existing := range s.urls {
+		if existing == url {
+			return true
+		}
+	}
+	return false
+}
+
+// Active retorna a URL em uso (vazia se nenhuma foi configurada)
+func (s *EndpointSet) Active() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.urls) == 0 {
+		return ""
+	}
+	return s.urls[s.active]
+}
+
+// Owns indica se rawURL aponta para um dos endpoints do conjunto
+func (s *EndpointSet) Owns(rawURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, url := range s.urls {
+		if rawURL == url || strings.HasPrefix(rawURL, strings.TrimSuffix(url, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Succeeded registra que url respondeu. Uma requisição iniciada antes de um
+// failover que ainda assim funcionou torna url o ativo de novo.
+func (s *EndpointSet) Succeeded(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.urls {
+		if existing == url {
+			s.active = i
+			s.failures = 0
+			return
+		}
+	}
+}
+
+// Failed registra uma falha em url e retorna true se ela causou a troca do
+// ativo. Falhas em um endpoint que já não é o ativo (requisições iniciadas
+// antes de um failover) não contam.
+func (s *EndpointSet) Failed(url string) bool {
+	s.mu.Lock()
+	if len(s.urls) == 0 || s.urls[s.active] != url {
+		s.mu.Unlock()
+		return false
+	}
+	s.failures++
+	if s.failures < s.threshold || len(s.urls) == 1 {
+		s.mu.Unlock()
+		return false
+	}
+
+	s.active = (s.active + 1) % len(s.urls)
+	s.failures = 0
+	s.failovers++
+	to := s.urls[s.active]
+	s.mu.Unlock()
+
+	if s.onFailover != nil {
+		s.onFailover(url, to)
+	}
+	return true
+}
+
+// Status retorna o estado atual do conjunto
+func (s *EndpointSet) Status() EndpointStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := EndpointStatus{
+		Endpoints:           append([]string(nil), s.urls...),
+		ConsecutiveFailures: s.failures,
+		Failovers:           s.failovers,
+	}
+	if len(s.urls) > 0 {
+		status.Active = s.urls[s.active]
+	}
+	return status
+}
@@ -0,0 +1,270 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// regionBackend simula uma região do backend: HTTP em qualquer caminho e
+// WebSocket em /ws. Com down, responde 503 a tudo e derruba as conexões.
+type regionBackend struct {
+	server *httptest.Server
+	down   atomic.Bool
+
+	mu          sync.Mutex
+	paths       []string
+	heartbeats  []map[string]interface{}
+	conns       []*websocket.Conn
+	connections int
+}
+
+func newRegionBackend(t *testing.T) *regionBackend {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+
+	backend := &regionBackend{}
+	upgrader := websocket.Upgrader{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if backend.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Path == "/ws" {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			backend.mu.Lock()
+			backend.conns = append(backend.conns, conn)
+			backend.connections++
+			backend.mu.Unlock()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		backend.mu.Lock()
+		backend.paths = append(backend.paths, r.URL.Path)
+		if r.URL.Path == EndpointHeartbeat {
+			backend.heartbeats = append(backend.heartbeats, body)
+		}
+		backend.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *regionBackend) wsURL() string {
+	return "ws" + strings.TrimPrefix(b.server.URL, "http") + "/ws"
+}
+
+// goDown passa a recusar requisições e fecha as conexões WebSocket abertas
+func (b *regionBackend) goDown() {
+	b.down.Store(true)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+}
+
+// received retorna os caminhos HTTP atendidos e as conexões WebSocket aceitas
+func (b *regionBackend) received() ([]string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.paths...), b.connections
+}
+
+// lastHeartbeat retorna o corpo do último heartbeat recebido
+func (b *regionBackend) lastHeartbeat() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.heartbeats) == 0 {
+		return nil
+	}
+	return b.heartbeats[len(b.heartbeats)-1]
+}
+
+func TestEndpointSet(t *testing.T) {
+	var failovers []string
+	set := NewEndpointSet([]string{"https://a", "", "https://b", "https://a"}, 2, func(from, to string) {
+		failovers = append(failovers, from+">"+to)
+	})
+	if status := set.Status(); status.Active != "https://a" || len(status.Endpoints) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// Falha no endpoint que já não é o ativo não conta
+	if set.Failed("https://a") || set.Failed("https://b") {
+		t.Fatal("failover before the threshold")
+	}
+	if !set.Failed("https://a") || set.Active() != "https://b" {
+		t.Fatalf("active after 2 failures = %s", set.Active())
+	}
+	if set.Failed("https://a") || set.Status().ConsecutiveFailures != 0 {
+		t.Fatalf("stale failure counted: %+v", set.Status())
+	}
+
+	// O ativo não volta sozinho; só um sucesso no antigo o torna ativo de novo
+	set.Succeeded("https://b")
+	if set.Active() != "https://b" {
+		t.Fatalf("active = %s", set.Active())
+	}
+	set.Succeeded("https://a")
+	if set.Active() != "https://a" || set.Status().Failovers != 1 || len(failovers) != 1 || failovers[0] != "https://a>https://b" {
+		t.Fatalf("status %+v, failovers %v", set.Status(), failovers)
+	}
+
+	// Com um endpoint só não há para onde ir
+	single := NewEndpointSet([]string{"https://a"}, 1, nil)
+	if single.Failed("https://a") || single.Failed("https://a") || single.Active() != "https://a" {
+		t.Fatal("single endpoint failed over")
+	}
+	if !single.Owns("https://a/updates/agent.bin") || single.Owns("https://attacker/x") {
+		t.Fatal("Owns matched the wrong URLs")
+	}
+}
+
+func TestHTTPClientFailsOverWhenPrimaryGoesDown(t *testing.T) {
+	primary := newRegionBackend(t)
+	secondary := newRegionBackend(t)
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:      primary.server.URL,
+		FailoverURLs: []string{secondary.server.URL},
+		MaxRetries:   -1,
+		Logger:       testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() error {
+		var response map[string]interface{}
+		return client.GET(context.Background(), "/api/status", &response)
+	}
+
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A primária cai no meio da execução: DefaultFailoverThreshold falhas e
+	// a secundária assume
+	primary.goDown()
+	for i := 0; i < DefaultFailoverThreshold; i++ {
+		if err := get(); err == nil {
+			t.Fatalf("request %d succeeded on a down primary", i+1)
+		}
+	}
+	if client.Endpoint() != secondary.server.URL {
+		t.Fatalf("active endpoint = %s", client.Endpoint())
+	}
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A primária volta, mas a secundária que funcionou continua preferida
+	primary.down.Store(false)
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	primaryPaths, _ := primary.received()
+	secondaryPaths, _ := secondary.received()
+	if len(primaryPaths) != 1 || len(secondaryPaths) != 2 {
+		t.Fatalf("primary served %d, secondary %d", len(primaryPaths), len(secondaryPaths))
+	}
+	if status := client.EndpointStatus(); status.Failovers != 1 || status.ConsecutiveFailures != 0 {
+		t.Fatalf("endpoint status = %+v", status)
+	}
+}
+
+func TestManagerFailsOverWebSocketAndReportsEndpoint(t *testing.T) {
+	primary := newRegionBackend(t)
+	secondary := newRegionBackend(t)
+
+	var mu sync.Mutex
+	failovers := make(map[string]string)
+	m, err := New(&Config{
+		BackendURL:        primary.server.URL,
+		BackendURLs:       []string{secondary.server.URL},
+		WebSocketURL:      primary.wsURL(),
+		WebSocketURLs:     []string{secondary.wsURL()},
+		FailoverThreshold: 2,
+		Token:             "test-token",
+		MachineID:         "test-machine",
+		Logger:            testLogger(t),
+		HTTPTimeout:       5 * time.Second,
+		HTTPMaxRetries:    -1,
+		WSReconnectDelay:  time.Millisecond,
+		WSMaxBackoff:      4 * time.Millisecond,
+		WSMaxReconnects:   -1,
+		WSPingInterval:    time.Hour,
+		OnEndpointFailover: func(transport, from, to string) {
+			mu.Lock()
+			defer mu.Unlock()
+			failovers[transport] = from + ">" + to
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.cancel()
+		_ = m.wsClient.Close()
+	})
+
+	if err := m.wsClient.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if heartbeat := primary.lastHeartbeat(); heartbeat["active_endpoint"] != primary.server.URL || heartbeat["active_websocket_endpoint"] != primary.wsURL() {
+		t.Fatalf("primary heartbeat = %v", heartbeat)
+	}
+
+	// A conexão longa cai com a primária; a reconexão gira para a secundária
+	primary.goDown()
+	waitFor(t, "the websocket failover", 10*time.Second, func() bool {
+		_, connections := secondary.received()
+		return connections == 1 && m.wsClient.IsConnected()
+	})
+
+	// Heartbeats falham na primária até o failover do HTTP
+	for i := 0; i < 2; i++ {
+		if err := m.SendHeartbeat(); err == nil {
+			t.Fatalf("heartbeat %d succeeded on a down primary", i+1)
+		}
+	}
+	if err := m.SendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	heartbeat := secondary.lastHeartbeat()
+	if heartbeat["active_endpoint"] != secondary.server.URL || heartbeat["active_websocket_endpoint"] != secondary.wsURL() {
+		t.Fatalf("secondary heartbeat = %v", heartbeat)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if failovers["http"] != primary.server.URL+">"+secondary.server.URL || failovers["websocket"] != primary.wsURL()+">"+secondary.wsURL() {
+		t.Fatalf("failovers = %v", failovers)
+	}
+	status := m.EndpointStatus()
+	if status["http"].Active != secondary.server.URL || status["websocket"].Active != secondary.wsURL() {
+		t.Fatalf("endpoint status = %+v", status)
+	}
+}
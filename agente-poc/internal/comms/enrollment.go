@@ -0,0 +1,161 @@
+package comms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/collector"
+	"agente-poc/internal/logging"
+	"agente-poc/internal/version"
+)
+
+const (
+	// enrollmentEndpoint troca a chave de enrollment pelo token da máquina
+	enrollmentEndpoint = "/machines/enroll"
+	// tokenRefreshEndpoint estende a validade do token da máquina
+	tokenRefreshEndpoint = "/machines/token/refresh"
+)
+
+// EnrollmentRequest é enviado no primeiro início, autenticado com a chave de
+// enrollment no lugar do token
+type EnrollmentRequest struct {
+	MachineID    string               `json:"machine_id"`
+	SystemInfo   collector.SystemInfo `json:"system_info"`
+	AgentVersion string               `json:"agent_version"`
+	InstanceID   string               `json:"instance_id,omitempty"`
+	Timestamp    time.Time            `json:"timestamp"`
+}
+
+// MachineTokenResponse é a resposta do enrollment e da renovação: o token da
+// máquina e a validade. ExpiresAt ausente = token sem expiração.
+type MachineTokenResponse struct {
+	Token     string    `json:"token"`
+	IssuedAt  time.Time `json:"issued_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// TokenRefreshRequest pede a renovação do token em uso (enviado no
+// Authorization); RefreshCount é o número de renovações já feitas
+type TokenRefreshRequest struct {
+	MachineID    string    `json:"machine_id"`
+	RefreshCount int       `json:"refresh_count"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// EnrollConfig é a conexão usada no enrollment, antes de existir o Manager
+type EnrollConfig struct {
+	BackendURL    string
+	EnrollmentKey string
+	TLSFiles
+	TLSSkipVerify      bool
+	PinnedCertificates []string
+	ProxyURL           string
+	Timeout            time.Duration // 0 = 30s
+	InstanceID         string
+	Logger             logging.Logger
+	Clock              clock.Clock // nil = relógio do sistema
+}
+
+// Enroll troca a chave de enrollment pelo token da máquina. Uma chave
+// recusada (401/403) não é retentada.
+func Enroll(ctx context.Context, config EnrollConfig, request EnrollmentRequest) (*Token, error) {
+	if config.EnrollmentKey == "" {
+		return nil, fmt.Errorf("enrollment key is empty")
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	config.Clock = clock.OrReal(config.Clock)
+
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:            config.BackendURL,
+		Token:              config.EnrollmentKey,
+		UserAgent:          version.UserAgent(),
+		Timeout:            config.Timeout,
+		TLSSkipVerify:      config.TLSSkipVerify,
+		TLSFiles:           config.TLSFiles,
+		PinnedCertificates: config.PinnedCertificates,
+		ProxyURL:           config.ProxyURL,
+		ConnectTimeout:     10 * time.Second,
+		IdleTimeout:        90 * time.Second,
+		Logger:             config.Logger,
+		Clock:              config.Clock,
+		InstanceID:         config.InstanceID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	if request.AgentVersion == "" {
+		request.AgentVersion = version.Version
+	}
+	if request.Timestamp.IsZero() {
+		request.Timestamp = config.Clock.Now()
+	}
+
+	var response MachineTokenResponse
+	if err := client.POST(ctx, enrollmentEndpoint, request, &response); err != nil {
+		switch HTTPStatusCode(err) {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, fmt.Errorf("enrollment key rejected by backend: %w", err)
+		}
+		return nil, fmt.Errorf("enrollment failed: %w", err)
+	}
+	return response.token(request.MachineID, 0, config.Clock.Now())
+}
+
+// RefreshMachineToken renova o token da máquina no backend, com as regras de
+// SecurityManager.RefreshToken (token não expirado e abaixo de maxRefresh).
+// O token renovado substitui o atual no conjunto usado por HTTP e WebSocket.
+func (m *Manager) RefreshMachineToken(ctx context.Context, current *Token, maxRefresh int) (*Token, error) {
+	security := NewSecurityManager(SecurityConfig{MaxTokenRefresh: maxRefresh, Logger: m.logger, Clock: m.clock})
+	if err := security.CheckRefresh(current); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	request := TokenRefreshRequest{
+		MachineID:    current.MachineID,
+		RefreshCount: current.RefreshCount,
+		Timestamp:    m.clock.Now(),
+	}
+	var response MachineTokenResponse
+	if err := m.httpClient.POST(ctx, tokenRefreshEndpoint, request, &response); err != nil {
+		return nil, fmt.Errorf("failed to refresh machine token: %w", err)
+	}
+
+	refreshed, err := response.token(current.MachineID, current.RefreshCount+1, m.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	m.tokens.Replace(current.Value, refreshed.Value)
+	m.logger.WithFields(map[string]interface{}{
+		"token_id":      TokenFingerprint(refreshed.Value),
+		"refresh_count": refreshed.RefreshCount,
+		"expires_at":    refreshed.ExpiresAt,
+	}).Debug("Machine token refreshed")
+	return refreshed, nil
+}
+
+// token converte a resposta do backend; IssuedAt ausente vira now
+func (r MachineTokenResponse) token(machineID string, refreshCount int, now time.Time) (*Token, error) {
+	if r.Token == "" {
+		return nil, fmt.Errorf("backend returned an empty machine token")
+	}
+	issuedAt := r.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = now
+	}
+	return &Token{
+		Value:        r.Token,
+		IssuedAt:     issuedAt,
+		ExpiresAt:    r.ExpiresAt,
+		RefreshCount: refreshCount,
+		MachineID:    machineID,
+	}, nil
+}
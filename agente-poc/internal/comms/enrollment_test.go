@@ -0,0 +1,143 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// newEnrollmentBackend responde em path com status e body e guarda o
+// Authorization e o corpo da última requisição
+func newEnrollmentBackend(t *testing.T, path string, status int, body string, auth *string, request interface{}) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		*auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(request)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	return server.URL
+}
+
+func TestEnroll(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	expiresAt := fake.Now().Add(30 * 24 * time.Hour)
+	var auth string
+	var received EnrollmentRequest
+	url := newEnrollmentBackend(t, enrollmentEndpoint, http.StatusOK,
+		`{"token": "machine-token", "expires_at": "`+expiresAt.Format(time.RFC3339)+`"}`, &auth, &received)
+
+	token, err := Enroll(context.Background(), EnrollConfig{
+		BackendURL:    url,
+		EnrollmentKey: "enroll-key",
+		Logger:        testLogger(t),
+		Clock:         fake,
+	}, EnrollmentRequest{MachineID: "test-machine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A chave de enrollment vai no lugar do token
+	if auth != "Bearer enroll-key" {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	if received.MachineID != "test-machine" || received.AgentVersion == "" || !received.Timestamp.Equal(fake.Now()) {
+		t.Fatalf("request = %+v", received)
+	}
+	// issued_at ausente vira o instante da resposta
+	if token.Value != "machine-token" || token.MachineID != "test-machine" || !token.IssuedAt.Equal(fake.Now()) ||
+		!token.ExpiresAt.Equal(expiresAt) || token.RefreshCount != 0 {
+		t.Fatalf("token = %+v", token)
+	}
+}
+
+func TestEnrollFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"rejected key", http.StatusUnauthorized, `{"error": "invalid enrollment key"}`, "enrollment key rejected by backend"},
+		{"revoked key", http.StatusForbidden, `{}`, "enrollment key rejected by backend"},
+		{"empty token", http.StatusOK, `{"token": ""}`, "empty machine token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var auth string
+			var received EnrollmentRequest
+			url := newEnrollmentBackend(t, enrollmentEndpoint, tt.status, tt.body, &auth, &received)
+			_, err := Enroll(context.Background(), EnrollConfig{
+				BackendURL:    url,
+				EnrollmentKey: "enroll-key",
+				Logger:        testLogger(t),
+			}, EnrollmentRequest{MachineID: "test-machine"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Enroll(context.Background(), EnrollConfig{BackendURL: "http://127.0.0.1:1"}, EnrollmentRequest{}); err == nil {
+		t.Fatal("empty enrollment key accepted")
+	}
+}
+
+func TestRefreshMachineToken(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC))
+	var auth string
+	var received TokenRefreshRequest
+	url := newEnrollmentBackend(t, tokenRefreshEndpoint, http.StatusOK,
+		`{"token": "refreshed-token", "issued_at": "2026-01-05T09:00:00Z", "expires_at": "2026-02-04T09:00:00Z"}`, &auth, &received)
+	m := newSpoolTestManager(t, url, filepath.Join(t.TempDir(), "queue"), fake)
+
+	current := &Token{
+		Value:        "test-token",
+		IssuedAt:     fake.Now().Add(-24 * time.Hour),
+		ExpiresAt:    fake.Now().Add(time.Hour),
+		RefreshCount: 1,
+		MachineID:    "test-machine",
+	}
+	refreshed, err := m.RefreshMachineToken(context.Background(), current, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer test-token" || received.MachineID != "test-machine" || received.RefreshCount != 1 {
+		t.Fatalf("Authorization %q, request %+v", auth, received)
+	}
+	if refreshed.Value != "refreshed-token" || refreshed.RefreshCount != 2 || refreshed.ExpiresAt.Month() != time.February {
+		t.Fatalf("refreshed = %+v", refreshed)
+	}
+	// O token renovado passa a ser usado nas próximas requisições
+	if active := m.tokens.Active(); active != "refreshed-token" {
+		t.Fatalf("active token = %q", active)
+	}
+
+	// Expirado ou no limite de renovações, o backend nem é chamado
+	auth = ""
+	expired := &Token{Value: "refreshed-token", ExpiresAt: fake.Now().Add(-time.Minute)}
+	if _, err := m.RefreshMachineToken(context.Background(), expired, 10); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expired token: %v", err)
+	}
+	exhausted := &Token{Value: "refreshed-token", RefreshCount: 10}
+	if _, err := m.RefreshMachineToken(context.Background(), exhausted, 10); !errors.Is(err, ErrTokenRefreshLimit) {
+		t.Fatalf("exhausted token: %v", err)
+	}
+	if auth != "" {
+		t.Fatal("backend called for a token that cannot be refreshed")
+	}
+}
@@ -0,0 +1,219 @@
+package comms
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// EnvelopeAlgorithm identifica o esquema do envelope: chave efêmera X25519,
+// chave simétrica derivada por HKDF-SHA256 e corpo em XChaCha20-Poly1305
+const EnvelopeAlgorithm = "x25519-xchacha20"
+
+// envelopeKDFInfo separa as chaves derivadas para o envelope de qualquer
+// outro uso do mesmo segredo
+const envelopeKDFInfo = "agente-poc envelope v1"
+
+// ErrEnvelopeKeyUnavailable indica que o modo envelope está ligado mas a
+// chave pública do backend ainda não é conhecida; nada é enviado em claro
+var ErrEnvelopeKeyUnavailable = errors.New("envelope encryption enabled but backend public key is not available")
+
+// Envelope é o corpo cifrado enviado no lugar do JSON original. Key é a chave
+// pública efêmera do agente; Body inclui a tag de autenticação. Campos em
+// base64 padrão.
+type Envelope struct {
+	Enc   string `json:"enc"`
+	Key   string `json:"key"`
+	Nonce string `json:"nonce"`
+	Body  string `json:"body"`
+}
+
+// EnvelopeKeyFingerprint é a impressão digital pinada na configuração:
+// "sha256:" + hex do SHA-256 da chave pública X25519 crua (32 bytes)
+func EnvelopeKeyFingerprint(publicKey []byte) string {
+	sum := sha256.Sum256(publicKey)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ParseEnvelopePublicKey decodifica uma chave X25519 em base64 e confere a
+// impressão digital pinada
+func ParseEnvelopePublicKey(encoded, fingerprint string) (*ecdh.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope public key encoding: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope public key: %w", err)
+	}
+	if got := EnvelopeKeyFingerprint(raw); !strings.EqualFold(got, strings.TrimSpace(fingerprint)) {
+		return nil, fmt.Errorf("envelope public key fingerprint %s does not match pinned %s", got, fingerprint)
+	}
+	return key, nil
+}
+
+// EnvelopeSealer cifra os corpos para a chave pública do backend. A chave
+// pode vir da configuração ou da resposta do registro; em ambos os casos
+// precisa bater com a impressão digital pinada. Um *EnvelopeSealer nil
+// desativa o modo envelope.
+type EnvelopeSealer struct {
+	fingerprint string
+
+	mu  sync.RWMutex
+	key *ecdh.PublicKey
+}
+
+// NewEnvelopeSealer cria o sealer com a impressão digital pinada e, se
+// informada, a chave pública da configuração
+func NewEnvelopeSealer(publicKey, fingerprint string) (*EnvelopeSealer, error) {
+	if strings.TrimSpace(fingerprint) == "" {
+		return nil, fmt.Errorf("envelope encryption requires a pinned backend key fingerprint")
+	}
+	sealer := &EnvelopeSealer{fingerprint: fingerprint}
+	if publicKey != "" {
+		if err := sealer.SetPublicKey(publicKey); err != nil {
+			return nil, err
+		}
+	}
+	return sealer, nil
+}
+
+// SetPublicKey troca a chave do backend (ex.: anunciada no registro); chaves
+// que não batem com a impressão digital pinada são recusadas
+func (s *EnvelopeSealer) SetPublicKey(encoded string) error {
+	key, err := ParseEnvelopePublicKey(encoded, s.fingerprint)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.key = key
+	s.mu.Unlock()
+	return nil
+}
+
+// HasKey indica se a chave do backend já é conhecida
+func (s *EnvelopeSealer) HasKey() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.key != nil
+}
+
+// Fingerprint retorna a impressão digital pinada
+func (s *EnvelopeSealer) Fingerprint() string {
+	if s == nil {
+		return ""
+	}
+	return s.fingerprint
+}
+
+// Seal cifra plaintext com uma chave efêmera nova
+func (s *EnvelopeSealer) Seal(plaintext []byte) (*Envelope, error) {
+	s.mu.RLock()
+	recipient := s.key
+	s.mu.RUnlock()
+	if recipient == nil {
+		return nil, ErrEnvelopeKeyUnavailable
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+	aead, err := envelopeAEAD(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &Envelope{
+		Enc:   EnvelopeAlgorithm,
+		Key:   base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		Body:  base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(EnvelopeAlgorithm))),
+	}, nil
+}
+
+// SealValue serializa value em JSON e o cifra
+func (s *EnvelopeSealer) SealValue(value interface{}) (*Envelope, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope body: %w", err)
+	}
+	return s.Seal(plaintext)
+}
+
+// OpenEnvelope é a decifragem de referência para o backend: com a chave
+// privada X25519 correspondente à pinada, retorna o JSON original
+func OpenEnvelope(privateKey *ecdh.PrivateKey, envelope *Envelope) ([]byte, error) {
+	if envelope.Enc != EnvelopeAlgorithm {
+		return nil, fmt.Errorf("unsupported envelope algorithm: %q", envelope.Enc)
+	}
+
+	ephemeralRaw, err := base64.StdEncoding.DecodeString(envelope.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralRaw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope key: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(envelope.Nonce)
+	if err != nil || len(nonce) != chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("invalid envelope nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid envelope body: %w", err)
+	}
+
+	shared, err := privateKey.ECDH(ephemeral)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive shared secret: %w", err)
+	}
+	aead, err := envelopeAEAD(shared, ephemeralRaw, privateKey.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(EnvelopeAlgorithm))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt envelope: %w", err)
+	}
+	return plaintext, nil
+}
+
+// envelopeAEAD deriva a chave simétrica do segredo compartilhado; o salt
+// amarra a chave às duas chaves públicas da troca
+func envelopeAEAD(shared, ephemeralPublic, recipientPublic []byte) (cipher.AEAD, error) {
+	salt := make([]byte, 0, len(ephemeralPublic)+len(recipientPublic))
+	salt = append(salt, ephemeralPublic...)
+	salt = append(salt, recipientPublic...)
+
+	key, err := hkdf.Key(sha256.New, shared, salt, envelopeKDFInfo, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive envelope key: %w", err)
+	}
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,336 @@
+package comms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBackendKey gera o par X25519 do backend e devolve a chave pública em
+// base64 com a impressão digital a pinar
+func newBackendKey(t *testing.T) (*ecdh.PrivateKey, string, string) {
+	t.Helper()
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := private.PublicKey().Bytes()
+	return private, base64.StdEncoding.EncodeToString(raw), EnvelopeKeyFingerprint(raw)
+}
+
+// openBody decifra um corpo recebido pelo backend falso
+func openBody(t *testing.T, private *ecdh.PrivateKey, body []byte) []byte {
+	t.Helper()
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("body is not an envelope: %v\n%s", err, body)
+	}
+	plaintext, err := OpenEnvelope(private, &envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plaintext
+}
+
+// envelopeBackend registra o endpoint e o corpo de cada requisição
+type envelopeBackend struct {
+	mu        sync.Mutex
+	endpoints []string
+	bodies    [][]byte
+}
+
+func (b *envelopeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	b.mu.Lock()
+	b.endpoints = append(b.endpoints, r.URL.Path)
+	b.bodies = append(b.bodies, body)
+	b.mu.Unlock()
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// newEnvelopeTestClient cria um cliente HTTP com o sealer apontando para o
+// backend falso
+func newEnvelopeTestClient(t *testing.T, backend *envelopeBackend, sealer *EnvelopeSealer) *HTTPClient {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:    server.URL,
+		MaxRetries: -1,
+		Logger:     testLogger(t),
+		Envelope:   sealer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	private, publicKey, fingerprint := newBackendKey(t)
+	sealer, err := NewEnvelopeSealer(publicKey, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte(`{"machine_id":"test-machine","serial":"C02XK0AAJG5J"}`)
+	first, err := sealer.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sealer.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Enc != EnvelopeAlgorithm || strings.Contains(first.Body, "C02XK0AAJG5J") {
+		t.Fatalf("envelope = %+v", first)
+	}
+	// Chave efêmera e nonce novos a cada corpo
+	if first.Key == second.Key || first.Nonce == second.Nonce || first.Body == second.Body {
+		t.Fatal("two seals of the same body share key material")
+	}
+	for _, envelope := range []*Envelope{first, second} {
+		opened, err := OpenEnvelope(private, envelope)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Fatalf("OpenEnvelope = %s, %v", opened, err)
+		}
+	}
+
+	// O formato no fio é o documentado
+	data, _ := json.Marshal(first)
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 4 || fields["enc"] != "x25519-xchacha20" {
+		t.Fatalf("wire format = %s", data)
+	}
+
+	value, err := sealer.SealValue(map[string]int{"cpu": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, _ := OpenEnvelope(private, value); string(opened) != `{"cpu":42}` {
+		t.Fatalf("SealValue opened to %s", opened)
+	}
+}
+
+func TestOpenEnvelopeRejectsTampering(t *testing.T) {
+	private, publicKey, fingerprint := newBackendKey(t)
+	sealer, err := NewEnvelopeSealer(publicKey, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := sealer.Seal([]byte(`{"status":"online"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := sealer.Seal([]byte(`{"status":"online"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey, _, _ := newBackendKey(t)
+
+	flip := func(encoded string) string {
+		raw, _ := base64.StdEncoding.DecodeString(encoded)
+		raw[0] ^= 0x01
+		return base64.StdEncoding.EncodeToString(raw)
+	}
+
+	tests := []struct {
+		name    string
+		private *ecdh.PrivateKey
+		modify  func(e *Envelope)
+	}{
+		{"body bit flipped", private, func(e *Envelope) { e.Body = flip(e.Body) }},
+		{"nonce from another envelope", private, func(e *Envelope) { e.Nonce = other.Nonce }},
+		{"ephemeral key from another envelope", private, func(e *Envelope) { e.Key = other.Key }},
+		{"short nonce", private, func(e *Envelope) { e.Nonce = base64.StdEncoding.EncodeToString(make([]byte, 12)) }},
+		{"unknown algorithm", private, func(e *Envelope) { e.Enc = "x25519-aesgcm" }},
+		{"body not base64", private, func(e *Envelope) { e.Body = "%%%" }},
+		{"wrong backend key", wrongKey, func(e *Envelope) {}},
+	}
+	for _, tt := range tests {
+		envelope := *original
+		tt.modify(&envelope)
+		if _, err := OpenEnvelope(tt.private, &envelope); err == nil {
+			t.Errorf("%s: envelope opened", tt.name)
+		}
+	}
+}
+
+func TestEnvelopeKeyPinning(t *testing.T) {
+	_, publicKey, fingerprint := newBackendKey(t)
+	_, otherKey, _ := newBackendKey(t)
+
+	if _, err := NewEnvelopeSealer(publicKey, ""); err == nil {
+		t.Fatal("sealer created without a pinned fingerprint")
+	}
+	if _, err := NewEnvelopeSealer(otherKey, fingerprint); err == nil {
+		t.Fatal("configured key accepted with another key's fingerprint")
+	}
+	if _, err := ParseEnvelopePublicKey(publicKey, strings.ToUpper(fingerprint)); err != nil {
+		t.Fatalf("fingerprint case rejected: %v", err)
+	}
+	if _, err := ParseEnvelopePublicKey("bm90IGEga2V5", fingerprint); err == nil {
+		t.Fatal("short key accepted")
+	}
+
+	// Sem a chave na configuração, nada é cifrado até ela chegar
+	sealer, err := NewEnvelopeSealer("", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sealer.Seal([]byte(`{}`)); !errors.Is(err, ErrEnvelopeKeyUnavailable) {
+		t.Fatalf("seal without a key: %v", err)
+	}
+	if err := sealer.SetPublicKey(otherKey); err == nil || sealer.HasKey() {
+		t.Fatal("key with another fingerprint adopted")
+	}
+	if err := sealer.SetPublicKey(publicKey); err != nil || !sealer.HasKey() {
+		t.Fatalf("pinned key rejected: %v", err)
+	}
+
+	var disabled *EnvelopeSealer
+	if disabled.HasKey() || disabled.Fingerprint() != "" {
+		t.Fatal("nil sealer reports a key")
+	}
+}
+
+func TestHTTPClientSealsBodies(t *testing.T) {
+	private, publicKey, fingerprint := newBackendKey(t)
+	sealer, err := NewEnvelopeSealer("", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &envelopeBackend{}
+	client := newEnvelopeTestClient(t, backend, sealer)
+	ctx := context.Background()
+
+	// Antes da chave, só o registro sai, e em claro; o resto falha sem enviar
+	if err := client.POST(ctx, "/heartbeat", map[string]string{"status": "online"}, nil); !errors.Is(err, ErrEnvelopeKeyUnavailable) {
+		t.Fatalf("heartbeat without a key: %v", err)
+	}
+	if err := client.POST(ctx, registrationEndpoint, map[string]string{"machine_id": "test-machine"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.bodies) != 1 || !bytes.Contains(backend.bodies[0], []byte(`"machine_id":"test-machine"`)) {
+		t.Fatalf("requests before the key: %v %s", backend.endpoints, backend.bodies)
+	}
+
+	// Com a chave, todos os corpos vão cifrados, inclusive um novo registro
+	if err := sealer.SetPublicKey(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range []string{"/heartbeat", "/inventory", registrationEndpoint} {
+		if err := client.POST(ctx, endpoint, map[string]string{"secret": "C02XK0AAJG5J"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, body := range backend.bodies[1:] {
+		if bytes.Contains(body, []byte("C02XK0AAJG5J")) {
+			t.Fatalf("%s sent in plaintext", backend.endpoints[i+1])
+		}
+		if opened := openBody(t, private, body); string(opened) != `{"secret":"C02XK0AAJG5J"}` {
+			t.Fatalf("%s opened to %s", backend.endpoints[i+1], opened)
+		}
+	}
+}
+
+func TestQueueStoresOnlySealedMessages(t *testing.T) {
+	private, publicKey, fingerprint := newBackendKey(t)
+	sealer, err := NewEnvelopeSealer(publicKey, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue, err := NewMessageQueue(QueueConfig{PersistPath: path, Logger: testLogger(t), Sealer: sealer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := newInventoryMessage(map[string]interface{}{
+		"machine_id": "test-machine",
+		"timestamp":  time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		"data":       map[string]interface{}{"serial": "C02XK0AAJG5J"},
+	})
+	if err := queue.Enqueue(message); err != nil {
+		t.Fatal(err)
+	}
+
+	persisted, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(persisted, []byte("C02XK0AAJG5J")) || bytes.Contains(persisted, []byte("test-machine")) {
+		t.Fatalf("plaintext persisted: %s", persisted)
+	}
+
+	// A mensagem recarregada do disco sai como está e o backend a decifra
+	reloaded, err := NewMessageQueue(QueueConfig{PersistPath: path, Logger: testLogger(t), Sealer: sealer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := reloaded.Dequeue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued.Data != nil || queued.Envelope == nil {
+		t.Fatalf("queued message = %+v", queued)
+	}
+
+	backend := &envelopeBackend{}
+	m := &Manager{config: &Config{HTTPTimeout: time.Second}, logger: testLogger(t), ctx: context.Background(),
+		httpClient: newEnvelopeTestClient(t, backend, sealer)}
+	if err := m.replay(queued); err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(openBody(t, private, backend.bodies[0]), &body); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := body["data"].(map[string]interface{}); data["serial"] != "C02XK0AAJG5J" || body["idempotency_key"] == nil {
+		t.Fatalf("replayed body = %v", body)
+	}
+}
+
+func TestManagerApplyEnvelopeKey(t *testing.T) {
+	_, publicKey, fingerprint := newBackendKey(t)
+	_, otherKey, _ := newBackendKey(t)
+	sealer, err := NewEnvelopeSealer("", fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{config: &Config{Envelope: sealer}, logger: testLogger(t)}
+
+	// Chave anunciada que não bate com a pinada é ignorada
+	m.applyEnvelopeKey(otherKey)
+	if status := m.EnvelopeStatus(); status["key_known"] != false || status["fingerprint"] != fingerprint {
+		t.Fatalf("status after a mismatched key = %v", status)
+	}
+	m.applyEnvelopeKey(publicKey)
+	if status := m.EnvelopeStatus(); status["key_known"] != true || status["algorithm"] != EnvelopeAlgorithm {
+		t.Fatalf("status after the pinned key = %v", status)
+	}
+
+	plain := &Manager{config: &Config{}, logger: testLogger(t)}
+	if status := plain.EnvelopeStatus(); status["enabled"] != false {
+		t.Fatalf("status without envelope = %v", status)
+	}
+	if data, err := plain.wsData("payload"); err != nil || data != "payload" {
+		t.Fatalf("wsData without envelope = %v, %v", data, err)
+	}
+}
@@ -0,0 +1,178 @@
+package comms
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrorCode identifica de forma estável o motivo de falha de um CommandResult.
+// O texto em CommandResult.Error é sempre inglês; a tradução para exibição é
+// feita pela interface a partir do código.
+type ErrorCode string
+
+const (
+	ErrCodeCommandNotAllowed       ErrorCode = "command_not_allowed"
+	ErrCodeTooManyArguments        ErrorCode = "too_many_arguments"
+	ErrCodeForbiddenArgument       ErrorCode = "forbidden_argument"
+	ErrCodeArgumentNotAllowed      ErrorCode = "argument_not_allowed"
+	ErrCodeArgumentPatternMismatch ErrorCode = "argument_pattern_mismatch"
+	ErrCodeUnsafeCommand           ErrorCode = "unsafe_command"
+	ErrCodeCommandSpecNotFound     ErrorCode = "command_spec_not_found"
+	ErrCodeUnsupportedCommandType  ErrorCode = "unsupported_command_type"
+	ErrCodeExecutorQueueTimeout    ErrorCode = "executor_queue_timeout"
+	ErrCodeCommandQueueFull        ErrorCode = "queue_full"
+	ErrCodeInvalidURL              ErrorCode = "invalid_url"
+	ErrCodeHostNotAllowed          ErrorCode = "host_not_allowed"
+	ErrCodeInsecureNotLoopback     ErrorCode = "insecure_skip_verify_not_loopback"
+	ErrCodeTooManyRedirects        ErrorCode = "too_many_redirects"
+	ErrCodeRedirectNotAllowed      ErrorCode = "redirect_not_allowed"
+	ErrCodeInvalidCommandField     ErrorCode = "invalid_command_field"
+	ErrCodeOversizedInput          ErrorCode = "oversized_input"
+	ErrCodeSnapshotsDisabled       ErrorCode = "snapshots_disabled"
+	ErrCodeSnapshotIDRequired      ErrorCode = "snapshot_id_required"
+	ErrCodeTokenRequired           ErrorCode = "token_required"
+	ErrCodeInvalidSignature        ErrorCode = "invalid_signature"
+	ErrCodeExecutionFailed         ErrorCode = "execution_failed"
+	ErrCodeCommandCancelled        ErrorCode = "command_cancelled"
+	ErrCodeWorkingDirNotAllowed    ErrorCode = "working_dir_not_allowed"
+	ErrCodeEnvVarNotAllowed        ErrorCode = "env_var_not_allowed"
+	ErrCodeInvalidEnvValue         ErrorCode = "invalid_env_value"
+	ErrCodePathNotAllowed          ErrorCode = "path_not_allowed"
+	ErrCodeNotRegularFile          ErrorCode = "not_regular_file"
+	ErrCodeFileTooLarge            ErrorCode = "file_too_large"
+	ErrCodeFileChangedDuringRead   ErrorCode = "file_changed_during_read"
+	ErrCodeScriptRequired          ErrorCode = "script_required"
+	ErrCodeInterpreterNotAllowed   ErrorCode = "interpreter_not_allowed"
+	ErrCodeScriptsDisabled         ErrorCode = "scripts_disabled"
+	ErrCodeScriptUnsigned          ErrorCode = "script_unsigned"
+	ErrCodeScriptExpired           ErrorCode = "script_expired"
+	ErrCodeScriptReplayed          ErrorCode = "script_replayed"
+	ErrCodeInvalidUpdate           ErrorCode = "invalid_update"
+	ErrCodeUpdateInProgress        ErrorCode = "update_in_progress"
+	ErrCodeChecksumMismatch        ErrorCode = "checksum_mismatch"
+	ErrCodeOfflineMode             ErrorCode = "offline_mode"
+	ErrCodeAuditLogDisabled        ErrorCode = "audit_log_disabled"
+)
+
+// errorSpec é a entrada do catálogo: mensagem inglesa e o texto antigo
+// (em português, como era enviado em CommandResult.Error) com os mesmos argumentos
+type errorSpec struct {
+	message string
+	legacy  string
+}
+
+// errorCatalog é a tabela de mapeamento entre códigos, mensagens e textos
+// antigos. O backend que ainda compara CommandResult.Error deve migrar para
+// error_code; legacy_error é enviado apenas durante a transição.
+var errorCatalog = map[ErrorCode]errorSpec{
+	ErrCodeCommandNotAllowed:       {"command not allowed: %s", "comando rejeitado: comando não permitido: %s"},
+	ErrCodeTooManyArguments:        {"too many arguments for command %s: max %d, got %d", "comando rejeitado: muitos argumentos para comando %s: máximo %d, recebido %d"},
+	ErrCodeForbiddenArgument:       {"forbidden argument '%s' for command %s", "comando rejeitado: argumento proibido '%s' para comando %s"},
+	ErrCodeArgumentNotAllowed:      {"argument not allowed '%s' for command %s", "comando rejeitado: argumento não permitido '%s' para comando %s"},
+	ErrCodeArgumentPatternMismatch: {"argument %d '%s' does not match the expected pattern for command %s", "comando rejeitado: argumento %d '%s' não corresponde ao padrão esperado para comando %s"},
+	ErrCodeUnsafeCommand:           {"command considered unsafe", "comando considerado inseguro"},
+	ErrCodeCommandSpecNotFound:     {"command specification not found", "especificações do comando não encontradas"},
+	ErrCodeUnsupportedCommandType:  {"unsupported command type: %s", "tipo de comando não suportado: %s"},
+	ErrCodeExecutorQueueTimeout:    {"timed out waiting for an execution slot", "timeout na fila de execução"},
+	ErrCodeCommandQueueFull:        {"command queue is full", "fila de comandos cheia"},
+	ErrCodeInvalidURL:              {"invalid URL for http_probe", "URL inválida para http_probe"},
+	ErrCodeHostNotAllowed:          {"host not allowed: %s", "host não permitido: %s"},
+	ErrCodeInsecureNotLoopback:     {"insecure_skip_verify is only allowed for localhost", "insecure_skip_verify só é permitido em localhost"},
+	ErrCodeTooManyRedirects:        {"too many redirects", "muitos redirecionamentos"},
+	ErrCodeRedirectNotAllowed:      {"redirect to a host that is not allowed: %s", "redirecionamento para host não permitido: %s"},
+	ErrCodeInvalidCommandField:     {"%s", "%s"},
+	ErrCodeOversizedInput:          {"%s", "%s"},
+	ErrCodeSnapshotsDisabled:       {"snapshot retention is disabled", "snapshot retention is disabled"},
+	ErrCodeSnapshotIDRequired:      {"snapshot_id is required", "snapshot_id is required"},
+	ErrCodeTokenRequired:           {"token option is required", "token option is required"},
+	ErrCodeInvalidSignature:        {"invalid command signature", "invalid command signature"},
+	ErrCodeExecutionFailed:         {"%s", "%s"},
+	ErrCodeCommandCancelled:        {"command cancelled: %s", "comando cancelado: %s"},
+	ErrCodeWorkingDirNotAllowed:    {"working directory not allowed for command %s: %s", "comando rejeitado: diretório de trabalho não permitido para comando %s: %s"},
+	ErrCodeEnvVarNotAllowed:        {"environment variable not allowed for command %s: %s", "comando rejeitado: variável de ambiente não permitida para comando %s: %s"},
+	ErrCodeInvalidEnvValue:         {"invalid value for environment variable %s", "comando rejeitado: valor inválido para variável de ambiente %s"},
+	ErrCodePathNotAllowed:          {"path not allowed: %s", "comando rejeitado: caminho não permitido: %s"},
+	ErrCodeNotRegularFile:          {"not a regular file: %s", "comando rejeitado: não é um arquivo regular: %s"},
+	ErrCodeFileTooLarge:            {"file too large: %s has %d bytes, max %d", "comando rejeitado: arquivo muito grande: %s tem %d bytes, máximo %d"},
+	ErrCodeFileChangedDuringRead:   {"file changed while being read: %s", "arquivo alterado durante a leitura: %s"},
+	ErrCodeScriptRequired:          {"script option is required", "comando rejeitado: opção script é obrigatória"},
+	ErrCodeInterpreterNotAllowed:   {"interpreter not allowed: %s", "comando rejeitado: interpretador não permitido: %s"},
+	ErrCodeScriptsDisabled:         {"script execution is disabled: no signing keys configured", "comando rejeitado: execução de scripts desativada: nenhuma chave de assinatura configurada"},
+	ErrCodeScriptUnsigned:          {"script is not signed", "comando rejeitado: script sem assinatura"},
+	ErrCodeScriptExpired:           {"script expiry not accepted: %s", "comando rejeitado: validade do script não aceita: %s"},
+	ErrCodeScriptReplayed:          {"script command %s was already accepted", "comando rejeitado: script do comando %s já foi aceito"},
+	ErrCodeInvalidUpdate:           {"invalid update: %s", "atualização inválida: %s"},
+	ErrCodeUpdateInProgress:        {"an agent update is already in progress", "uma atualização do agente já está em andamento"},
+	ErrCodeChecksumMismatch:        {"checksum mismatch: expected %s, got %s", "checksum não confere: esperado %s, recebido %s"},
+	ErrCodeOfflineMode:             {"command %s requires a backend (agent is in offline mode)", "comando %s exige o backend (agente em modo offline)"},
+	ErrCodeAuditLogDisabled:        {"audit log is disabled", "log de auditoria desativado"},
+}
+
+// CodedError é um erro com código do catálogo, usado nos caminhos de rejeição
+type CodedError struct {
+	Code    ErrorCode
+	Message string
+	Legacy  string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+// NewCodedError formata a mensagem e o texto antigo do código com os argumentos
+func NewCodedError(code ErrorCode, args ...interface{}) *CodedError {
+	spec, ok := errorCatalog[code]
+	if !ok {
+		args = []interface{}{string(code)}
+		code = ErrCodeExecutionFailed
+		spec = errorCatalog[code]
+	}
+
+	return &CodedError{
+		Code:    code,
+		Message: fmt.Sprintf(spec.message, args...),
+		Legacy:  fmt.Sprintf(spec.legacy, args...),
+	}
+}
+
+// ErrorCodes retorna os códigos registrados, em ordem alfabética
+func ErrorCodes() []ErrorCode {
+	codes := make([]ErrorCode, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// IsRegistered verifica se o código pertence ao catálogo
+func (c ErrorCode) IsRegistered() bool {
+	_, ok := errorCatalog[c]
+	return ok
+}
+
+// SetError preenche Error, ErrorCode e LegacyError a partir do erro.
+// Erros sem código (falhas de execução do sistema) recebem execution_failed.
+func (r *CommandResult) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	var coded *CodedError
+	var decodeErr *CommandDecodeError
+	var oversizedErr *OversizedCommandError
+	switch {
+	case errors.As(err, &coded):
+	case errors.As(err, &decodeErr):
+		coded = NewCodedError(ErrCodeInvalidCommandField, decodeErr.Error())
+	case errors.As(err, &oversizedErr):
+		coded = NewCodedError(ErrCodeOversizedInput, oversizedErr.Error())
+	default:
+		coded = NewCodedError(ErrCodeExecutionFailed, err.Error())
+	}
+
+	r.Error = coded.Message
+	r.ErrorCode = coded.Code
+	r.LegacyError = coded.Legacy
+}
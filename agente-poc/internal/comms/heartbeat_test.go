@@ -0,0 +1,29 @@
+package comms
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetSystemHealth(t *testing.T) {
+	m := &Manager{config: &Config{}, logger: testLogger(t)}
+	if got := m.getSystemHealth(); !reflect.DeepEqual(got, map[string]interface{}{"status": "unknown"}) {
+		t.Fatalf("health without a sampler = %v", got)
+	}
+
+	calls := 0
+	m.config.SystemHealth = func() SystemHealthStatus {
+		calls++
+		return SystemHealthStatus{CPUUsage: 12.5, MemoryUsage: 91, DiskUsage: 40, MemoryPressure: "normal", Status: "healthy"}
+	}
+	want := map[string]interface{}{
+		"cpu_usage_percent":    12.5,
+		"memory_usage_percent": 91.0,
+		"disk_usage_percent":   40.0,
+		"memory_pressure":      "normal",
+		"status":               "healthy",
+	}
+	if got := m.getSystemHealth(); !reflect.DeepEqual(got, want) || calls != 1 {
+		t.Fatalf("health = %v (%d calls), want %v", got, calls, want)
+	}
+}
@@ -0,0 +1,17 @@
+package comms
+
+import (
+	"testing"
+
+	"agente-poc/internal/logging"
+)
+
+// testLogger descarta tudo abaixo de FATAL, para não poluir a saída dos testes
+func testLogger(t *testing.T) logging.Logger {
+	t.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
@@ -3,24 +3,53 @@ package comms
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/clock"
 	"agente-poc/internal/logging"
+	"agente-poc/internal/version"
 )
 
 // HTTPClient wraps the HTTP client with retry, authentication and monitoring
 type HTTPClient struct {
-	client    *http.Client
-	baseURL   string
-	token     string
-	userAgent string
-	logger    logging.Logger
-	metrics   *HTTPMetrics
+	client     *http.Client
+	endpoints  *EndpointSet
+	tokens     *TokenSet
+	userAgent  string
+	instanceID string
+	logger     logging.Logger
+	metrics    *HTTPMetrics
+	clock      clock.Clock
+
+	// Retentativas (ver retry.go); jitter sorteia o backoff em [0, 1)
+	maxRetries     int
+	retryBaseDelay time.Duration
+	maxRetryDelay  time.Duration
+	retryBudget    time.Duration
+	jitter         func() float64
+
+	// Codificação negociada com o backend (ver NegotiateEncoding); corpos
+	// menores que compressionThreshold vão sem codificação
+	encodingMu           sync.RWMutex
+	encoding             string
+	compression          *CompressionMetrics
+	compressionThreshold int
+
+	// Cifra os corpos para o backend (modo envelope); nil desativa
+	envelope *EnvelopeSealer
+
+	// Limita a taxa de envio dos corpos (ver Throttle); nil ou taxa 0 desativa
+	throttle *Throttle
 }
 
 // HTTPMetrics tracks HTTP client metrics
@@ -33,27 +62,92 @@ type HTTPMetrics struct {
 	LastRequestTime  time.Time
 	TotalBytes       int64
 	ConnectionErrors int64
+	// ProxyErrors conta as falhas no proxy (conexão recusada, CONNECT
+	// negado ou 407), separadas das falhas de conexão com o backend
+	ProxyErrors int64
+
+	// Retentativas por resposta do backend (já incluídas em RetryCount):
+	// 429 (rate limit) e erros 5xx
+	RateLimitedRetries int64
+	ServerErrorRetries int64
+
+	// Corpos enviados: bytes do JSON antes da compressão e bytes que foram
+	// para a rede; CompressedRequests conta os corpos codificados
+	RawBodyBytes       int64
+	SentBodyBytes      int64
+	CompressedRequests int64
 }
 
 // HTTPConfig configuration for HTTP client
 type HTTPConfig struct {
-	BaseURL         string
-	Token           string
-	UserAgent       string
-	Timeout         time.Duration
-	MaxRetries      int
-	RetryDelay      time.Duration
-	MaxRetryDelay   time.Duration
-	TLSSkipVerify   bool
-	ConnectTimeout  time.Duration
-	IdleTimeout     time.Duration
-	MaxIdleConns    int
-	MaxConnsPerHost int
-	Logger          logging.Logger
-}
-
-// NewHTTPClient creates a new HTTP client with the given configuration
-func NewHTTPClient(config HTTPConfig) *HTTPClient {
+	BaseURL            string
+	FailoverURLs       []string     // tentadas depois de BaseURL, em ordem (ver EndpointSet)
+	Endpoints          *EndpointSet // tem precedência sobre BaseURL e FailoverURLs
+	Token              string
+	Tokens             *TokenSet // tem precedência sobre Token
+	UserAgent          string
+	Timeout            time.Duration
+	MaxRetries         int           // 0 = DefaultHTTPMaxRetries; negativo = sem retentativas
+	RetryDelay         time.Duration // base do backoff exponencial (0 = DefaultHTTPRetryDelay)
+	MaxRetryDelay      time.Duration // teto do backoff, não do Retry-After (0 = DefaultHTTPMaxRetryDelay)
+	RetryBudget        time.Duration // tempo total de tentativas e esperas (0 = DefaultHTTPRetryBudget)
+	TLSSkipVerify      bool
+	TLSFiles                    // certificado de cliente e CA para mTLS (opcionais)
+	PinnedCertificates []string // SHA-256 (hex) exigidos na cadeia do servidor (ver PinCertificates)
+	ProxyURL           string   // substitui HTTP(S)_PROXY do ambiente (ver ProxyFunc)
+	ConnectTimeout     time.Duration
+	IdleTimeout        time.Duration
+	MaxIdleConns       int
+	MaxConnsPerHost    int
+	Logger             logging.Logger
+	Clock              clock.Clock     // espera entre tentativas (nil = relógio do sistema)
+	InstanceID         string          // enviado em X-Agent-Instance-ID
+	Chaos              *chaos.Injector // injeção de falhas em staging (nil = desativada)
+	Envelope           *EnvelopeSealer // cifra os corpos para o backend (nil = desativado)
+	Throttle           *Throttle       // limita a taxa de upload dos corpos (nil = sem limite)
+
+	// EnableCompression envia corpos em gzip antes de o backend anunciar as
+	// codificações aceitas; um 415 volta para o envio sem compressão
+	EnableCompression    bool
+	CompressionThreshold int // bytes; 0 = DefaultCompressionThreshold
+}
+
+// HTTPStatusError é uma resposta de erro do backend; permite aos chamadores
+// tratar códigos específicos (ex.: 409 e 401 no registro)
+type HTTPStatusError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter é a espera pedida pelo backend em 429/503 (zero sem Retry-After)
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP error %d: %s", e.StatusCode, e.Message)
+}
+
+// HTTPStatusCode retorna o código HTTP de um erro de resposta, ou 0
+func HTTPStatusCode(err error) int {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// NewHTTPClient creates a new HTTP client with the given configuration. Os
+// arquivos de TLSFiles são carregados e ProxyURL é validado aqui; um erro
+// neles impede a criação.
+func NewHTTPClient(config HTTPConfig) (*HTTPClient, error) {
+	tlsConfig, err := BuildTLSConfig(config.TLSFiles, config.TLSSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	PinCertificates(tlsConfig, config.PinnedCertificates)
+	proxy, err := ProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create custom transport with timeouts and connection pooling
 	transport := &http.Transport{
 		MaxIdleConns:       config.MaxIdleConns,
@@ -61,29 +155,162 @@ func NewHTTPClient(config HTTPConfig) *HTTPClient {
 		IdleConnTimeout:    config.IdleTimeout,
 		DisableCompression: false,
 		ForceAttemptHTTP2:  true,
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: config.TLSSkipVerify,
-		},
+		TLSClientConfig:    tlsConfig,
+		Proxy:              proxy,
+		// CONNECT recusado vira *ProxyError, contado em ProxyErrors
+		OnProxyConnectResponse: proxyConnectResponse,
 	}
 
 	// Create HTTP client with custom transport
 	client := &http.Client{
-		Transport: transport,
+		Transport: config.Chaos.RoundTripper(transport),
 		Timeout:   config.Timeout,
 	}
 
+	tokens := config.Tokens
+	if tokens == nil {
+		tokens = NewTokenSet(config.Token)
+	}
+	endpoints := config.Endpoints
+	if endpoints == nil {
+		endpoints = NewEndpointSet(append([]string{config.BaseURL}, config.FailoverURLs...), 0, nil)
+	}
+
+	encoding := EncodingIdentity
+	if config.EnableCompression {
+		encoding = EncodingGzip
+	}
+	threshold := config.CompressionThreshold
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+
+	switch {
+	case config.MaxRetries == 0:
+		config.MaxRetries = DefaultHTTPMaxRetries
+	case config.MaxRetries < 0:
+		config.MaxRetries = 0
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultHTTPRetryDelay
+	}
+	if config.MaxRetryDelay <= 0 {
+		config.MaxRetryDelay = DefaultHTTPMaxRetryDelay
+	}
+	if config.MaxRetryDelay < config.RetryDelay {
+		config.MaxRetryDelay = config.RetryDelay
+	}
+	if config.RetryBudget <= 0 {
+		config.RetryBudget = DefaultHTTPRetryBudget
+	}
+
 	return &HTTPClient{
-		client:    client,
-		baseURL:   config.BaseURL,
-		token:     config.Token,
-		userAgent: config.UserAgent,
-		logger:    config.Logger,
-		metrics:   &HTTPMetrics{},
+		client:     client,
+		endpoints:  endpoints,
+		tokens:     tokens,
+		userAgent:  config.UserAgent,
+		instanceID: config.InstanceID,
+		logger:     config.Logger,
+		metrics:    &HTTPMetrics{},
+		clock:      clock.OrReal(config.Clock),
+
+		maxRetries:     config.MaxRetries,
+		retryBaseDelay: config.RetryDelay,
+		maxRetryDelay:  config.MaxRetryDelay,
+		retryBudget:    config.RetryBudget,
+		jitter:         rand.Float64,
+
+		encoding:             encoding,
+		compression:          NewCompressionMetrics(),
+		compressionThreshold: threshold,
+
+		envelope: config.Envelope,
+		throttle: config.Throttle,
+	}, nil
+}
+
+// SetEncoding define a codificação dos corpos enviados; retorna se mudou
+func (c *HTTPClient) SetEncoding(encoding string) bool {
+	c.encodingMu.Lock()
+	defer c.encodingMu.Unlock()
+
+	if c.encoding == encoding {
+		return false
+	}
+	c.encoding = encoding
+	return true
+}
+
+// Encoding retorna a codificação em uso
+func (c *HTTPClient) Encoding() string {
+	c.encodingMu.RLock()
+	defer c.encodingMu.RUnlock()
+	return c.encoding
+}
+
+// CompressionStats retorna razão de compressão e tempo de codificação por codificação
+func (c *HTTPClient) CompressionStats() map[string]CompressionStats {
+	return c.compression.Snapshot()
+}
+
+// encodeBody comprime o corpo com a codificação em uso; corpos pequenos e
+// falhas de compressão vão sem codificação
+func (c *HTTPClient) encodeBody(jsonBody []byte) ([]byte, string) {
+	payload, encoding := c.compressBody(jsonBody)
+
+	c.metrics.RawBodyBytes += int64(len(jsonBody))
+	c.metrics.SentBodyBytes += int64(len(payload))
+	if encoding != EncodingIdentity {
+		c.metrics.CompressedRequests++
 	}
+	return payload, encoding
 }
 
-// sendRequest sends an HTTP request with retry logic
-func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, body interface{}, target interface{}) error {
+// compressBody aplica a codificação em uso quando o corpo passa do limite
+func (c *HTTPClient) compressBody(jsonBody []byte) ([]byte, string) {
+	encoding := c.Encoding()
+	if encoding == EncodingIdentity || len(jsonBody) < c.compressionThreshold {
+		return jsonBody, EncodingIdentity
+	}
+
+	start := time.Now()
+	encoded, err := EncodeBody(encoding, jsonBody)
+	if err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"encoding": encoding,
+			"error":    err.Error(),
+		}).Warning("Failed to encode request body, sending uncompressed")
+		return jsonBody, EncodingIdentity
+	}
+	c.compression.Record(encoding, len(jsonBody), len(encoded), time.Since(start))
+	return encoded, encoding
+}
+
+// registrationEndpoint é o único endpoint que pode sair sem envelope (ver sealBody)
+const registrationEndpoint = "/machines/register"
+
+// sealBody cifra o corpo no modo envelope. Só o registro pode sair em claro,
+// e apenas enquanto a chave do backend não é conhecida (é ele que a traz).
+func (c *HTTPClient) sealBody(endpoint string, jsonBody []byte) ([]byte, error) {
+	if c.envelope == nil {
+		return jsonBody, nil
+	}
+	if endpoint == registrationEndpoint && !c.envelope.HasKey() {
+		return jsonBody, nil
+	}
+
+	envelope, err := c.envelope.Seal(jsonBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal request body for %s: %w", endpoint, err)
+	}
+	return json.Marshal(envelope)
+}
+
+// sendRequest sends an HTTP request with retry logic.
+// Em 401, os demais tokens do TokenSet são tentados; o que for aceito é promovido.
+// headers são cabeçalhos extras desta requisição (ex.: Idempotency-Key),
+// enviados iguais em todas as tentativas.
+func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, body interface{}, target interface{}, headers map[string]string) error {
 	var jsonBody []byte
 	var err error
 
@@ -93,31 +320,149 @@ func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, b
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
+		if jsonBody, err = c.sealBody(endpoint, jsonBody); err != nil {
+			return err
+		}
 	}
+	return c.sendJSON(ctx, method, endpoint, jsonBody, target, headers)
+}
 
-	url := c.baseURL + endpoint
-	maxRetries := 3
-	baseDelay := 1 * time.Second
+// sendJSON envia um corpo já serializado (e, no modo envelope, já cifrado),
+// aplicando a codificação negociada
+func (c *HTTPClient) sendJSON(ctx context.Context, method, endpoint string, jsonBody []byte, target interface{}, headers map[string]string) error {
+	payload, encoding := c.encodeBody(jsonBody)
+	err := c.sendWithTokens(ctx, method, endpoint, payload, encoding, target, headers)
+
+	// 415: o backend deixou de aceitar a codificação; cair para a próxima
+	for HTTPStatusCode(err) == http.StatusUnsupportedMediaType && encoding != EncodingIdentity {
+		fallback := fallbackEncoding(encoding)
+		c.logger.WithFields(map[string]interface{}{
+			"encoding": encoding,
+			"fallback": fallback,
+			"endpoint": endpoint,
+		}).Warning("Backend rejected request encoding (415), falling back")
+		c.SetEncoding(fallback)
+
+		payload, encoding = c.encodeBody(jsonBody)
+		err = c.sendWithTokens(ctx, method, endpoint, payload, encoding, target, headers)
+	}
+	return err
+}
+
+// sendWithTokens tenta os tokens configurados em ordem
+func (c *HTTPClient) sendWithTokens(ctx context.Context, method, endpoint string, body []byte, encoding string, target interface{}, headers map[string]string) error {
+	var err error
+	candidates := c.tokens.Candidates()
+	if len(candidates) == 0 {
+		return c.sendWithToken(ctx, method, endpoint, body, encoding, target, "", headers)
+	}
+
+	for i, token := range candidates {
+		err = c.sendWithToken(ctx, method, endpoint, body, encoding, target, token, headers)
+		if HTTPStatusCode(err) != http.StatusUnauthorized {
+			if err == nil && i > 0 && c.tokens.Promote(token) {
+				c.logger.WithFields(map[string]interface{}{
+					"token_id":          TokenFingerprint(token),
+					"previous_token_id": TokenFingerprint(candidates[0]),
+				}).Info("Backend accepted a fallback token, promoted to active")
+			}
+			return err
+		}
+		if i < len(candidates)-1 {
+			c.logger.WithFields(map[string]interface{}{
+				"token_id": TokenFingerprint(token),
+				"endpoint": endpoint,
+			}).Warning("Token rejected (401), trying next configured token")
+		}
+	}
+	return err
+}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
+// sendWithToken executa a requisição com o token informado no endpoint
+// ativo e conta o resultado para o failover
+func (c *HTTPClient) sendWithToken(ctx context.Context, method, endpoint string, jsonBody []byte, encoding string, target interface{}, token string, headers map[string]string) error {
+	base := c.endpoints.Active()
+	err := c.sendTo(ctx, base, method, endpoint, jsonBody, encoding, target, token, headers)
+	c.recordEndpoint(ctx, base, err)
+	return err
+}
+
+// recordEndpoint conta o resultado no endpoint usado. Falhas de rede e 5xx
+// (depois das retentativas) contam para o failover; qualquer outra resposta
+// mostra que o backend está de pé. Cancelamentos e falhas do proxy não dizem
+// nada sobre o endpoint.
+func (c *HTTPClient) recordEndpoint(ctx context.Context, base string, err error) {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		c.endpoints.Succeeded(base)
+	case ctx.Err() != nil || IsProxyError(err):
+	case HTTPStatusCode(err) >= 500 || errors.As(err, &netErr):
+		if c.endpoints.Failed(base) {
+			c.logger.WithFields(map[string]interface{}{
+				"from":  base,
+				"to":    c.endpoints.Active(),
+				"error": err.Error(),
+			}).Debug("Backend endpoint failing, switched to the next one")
+		}
+	case HTTPStatusCode(err) != 0:
+		c.endpoints.Succeeded(base)
+	}
+}
+
+// throttled retorna o corpo limitado pela taxa e o cliente para enviá-lo: o
+// timeout da requisição ganha o tempo que o corpo leva para sair na taxa
+// atual, senão uploads grandes em links limitados estourariam o timeout
+func (c *HTTPClient) throttled(ctx context.Context, jsonBody []byte, rate int64) (io.Reader, *http.Client) {
+	client := c.client
+	if client.Timeout > 0 {
+		extended := *client
+		extended.Timeout += time.Duration(int64(len(jsonBody)) * int64(time.Second) / rate)
+		client = &extended
+	}
+	return c.throttle.Body(ctx, jsonBody), client
+}
+
+// sendTo executa a requisição em base, com as retentativas
+func (c *HTTPClient) sendTo(ctx context.Context, base, method, endpoint string, jsonBody []byte, encoding string, target interface{}, token string, headers map[string]string) error {
+	url := base + endpoint
+	start := c.clock.Now()
+
+	for attempt := 0; ; attempt++ {
 		// Create request
-		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(jsonBody))
+		client := c.client
+		var body io.Reader = bytes.NewBuffer(jsonBody)
+		if rate := c.throttle.Rate(); rate > 0 && len(jsonBody) > 0 {
+			body, client = c.throttled(ctx, jsonBody, rate)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, body)
 		if err != nil {
 			return fmt.Errorf("failed to create request: %w", err)
 		}
+		// o leitor limitado não é um dos tipos que NewRequest sabe medir
+		req.ContentLength = int64(len(jsonBody))
 
 		// Set headers
 		req.Header.Set("Content-Type", "application/json")
+		if encoding != EncodingIdentity && len(jsonBody) > 0 {
+			req.Header.Set("Content-Encoding", encoding)
+		}
 		req.Header.Set("User-Agent", c.userAgent)
 		req.Header.Set("Accept", "application/json")
 
-		if c.token != "" {
-			req.Header.Set("Authorization", "Bearer "+c.token)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
 		}
 
 		// Add security headers
 		req.Header.Set("X-Request-ID", fmt.Sprintf("%d", time.Now().UnixNano()))
-		req.Header.Set("X-Agent-Version", "1.0.0")
+		req.Header.Set("X-Agent-Version", version.Version)
+		if c.instanceID != "" {
+			req.Header.Set("X-Agent-Instance-ID", c.instanceID)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
 
 		// Record metrics
 		c.metrics.TotalRequests++
@@ -125,13 +470,16 @@ func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, b
 		startTime := time.Now()
 
 		// Send request
-		resp, err := c.client.Do(req)
+		resp, err := client.Do(req)
 		if err != nil {
 			c.metrics.FailedRequests++
-			c.metrics.ConnectionErrors++
+			if IsProxyError(err) {
+				c.metrics.ProxyErrors++
+			} else {
+				c.metrics.ConnectionErrors++
+			}
 
-			if attempt < maxRetries {
-				delay := time.Duration(attempt+1) * baseDelay
+			if delay, _ := c.retryDelay(attempt, nil); c.canRetry(attempt, start, delay) {
 				c.logger.WithFields(map[string]interface{}{
 					"attempt": attempt + 1,
 					"delay":   delay,
@@ -140,16 +488,13 @@ func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, b
 				}).Warning("HTTP request failed, retrying...")
 
 				c.metrics.RetryCount++
-
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(delay):
-					continue
+				if err := c.waitRetry(ctx, delay); err != nil {
+					return err
 				}
+				continue
 			}
 
-			return fmt.Errorf("HTTP request failed after %d attempts: %w", maxRetries+1, err)
+			return fmt.Errorf("HTTP request failed after %d attempts: %w", attempt+1, err)
 		}
 
 		// Update metrics
@@ -189,6 +534,48 @@ func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, b
 			return nil
 		}
 
+		// 407 vem do proxy (requisições http:// encaminhadas sem CONNECT), não do backend
+		if resp.StatusCode == http.StatusProxyAuthRequired {
+			c.metrics.FailedRequests++
+			c.metrics.ProxyErrors++
+			return &ProxyError{Proxy: c.proxyFor(req), StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+
+		// 429 e 5xx: nova tentativa após o Retry-After ou o backoff
+		if retryableStatus(resp.StatusCode) {
+			delay, fromHeader := c.retryDelay(attempt, resp)
+			statusErr := &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
+			if fromHeader {
+				statusErr.RetryAfter = delay
+			}
+
+			if c.canRetry(attempt, start, delay) {
+				fields := map[string]interface{}{
+					"attempt":     attempt + 1,
+					"delay":       delay,
+					"retry_after": fromHeader,
+					"status_code": resp.StatusCode,
+					"url":         url,
+				}
+				c.metrics.RetryCount++
+				if resp.StatusCode == http.StatusTooManyRequests {
+					c.metrics.RateLimitedRetries++
+					c.logger.WithFields(fields).Warning("HTTP request rate limited, retrying...")
+				} else {
+					c.metrics.ServerErrorRetries++
+					c.logger.WithFields(fields).Warning("HTTP server error, retrying...")
+				}
+
+				if err := c.waitRetry(ctx, delay); err != nil {
+					return err
+				}
+				continue
+			}
+
+			c.metrics.FailedRequests++
+			return statusErr
+		}
+
 		// Handle error responses
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
 			// Client errors - don't retry
@@ -196,57 +583,99 @@ func (c *HTTPClient) sendRequest(ctx context.Context, method, endpoint string, b
 
 			var errorResp ErrorResponse
 			if err := json.Unmarshal(bodyBytes, &errorResp); err == nil {
-				return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, errorResp.Message)
+				return &HTTPStatusError{StatusCode: resp.StatusCode, Message: errorResp.Message}
 			}
 
-			return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(bodyBytes))
-		}
-
-		// Server errors - retry if possible
-		if resp.StatusCode >= 500 && attempt < maxRetries {
-			delay := time.Duration(attempt+1) * baseDelay
-			c.logger.WithFields(map[string]interface{}{
-				"attempt":     attempt + 1,
-				"delay":       delay,
-				"status_code": resp.StatusCode,
-				"url":         url,
-			}).Warning("HTTP server error, retrying...")
-
-			c.metrics.RetryCount++
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(delay):
-				continue
-			}
+			return &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
 		}
 
 		c.metrics.FailedRequests++
-		return fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(bodyBytes))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(bodyBytes)}
 	}
-
-	return fmt.Errorf("HTTP request failed after %d attempts", maxRetries+1)
 }
 
 // GET performs a GET request
 func (c *HTTPClient) GET(ctx context.Context, endpoint string, target interface{}) error {
-	return c.sendRequest(ctx, "GET", endpoint, nil, target)
+	return c.sendRequest(ctx, "GET", endpoint, nil, target, nil)
 }
 
 // POST performs a POST request
 func (c *HTTPClient) POST(ctx context.Context, endpoint string, body interface{}, target interface{}) error {
-	return c.sendRequest(ctx, "POST", endpoint, body, target)
+	return c.sendRequest(ctx, "POST", endpoint, body, target, nil)
+}
+
+// POSTWithHeaders performs a POST request with extra headers, sent
+// unchanged on every retry (ex.: Idempotency-Key)
+func (c *HTTPClient) POSTWithHeaders(ctx context.Context, endpoint string, body interface{}, target interface{}, headers map[string]string) error {
+	return c.sendRequest(ctx, "POST", endpoint, body, target, headers)
 }
 
 // PUT performs a PUT request
 func (c *HTTPClient) PUT(ctx context.Context, endpoint string, body interface{}, target interface{}) error {
-	return c.sendRequest(ctx, "PUT", endpoint, body, target)
+	return c.sendRequest(ctx, "PUT", endpoint, body, target, nil)
 }
 
 // DELETE performs a DELETE request
 func (c *HTTPClient) DELETE(ctx context.Context, endpoint string, target interface{}) error {
-	return c.sendRequest(ctx, "DELETE", endpoint, nil, target)
+	return c.sendRequest(ctx, "DELETE", endpoint, nil, target, nil)
+}
+
+// Download grava em w o corpo de um GET em rawURL, com no máximo maxBytes.
+// Um caminho relativo ("/updates/...") é resolvido contra o backend; o token
+// só é enviado para URLs do próprio backend. Sem retentativas: quem chama
+// decide se repete.
+func (c *HTTPClient) Download(ctx context.Context, rawURL string, w io.Writer, maxBytes int64) (int64, error) {
+	if strings.HasPrefix(rawURL, "/") {
+		rawURL = c.endpoints.Active() + rawURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("X-Agent-Version", version.Version)
+	if c.instanceID != "" {
+		req.Header.Set("X-Agent-Instance-ID", c.instanceID)
+	}
+	if c.endpoints.Owns(rawURL) {
+		if token := c.tokens.Active(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, &HTTPStatusError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+	if resp.ContentLength > maxBytes {
+		return 0, fmt.Errorf("download too large: %d bytes, max %d", resp.ContentLength, maxBytes)
+	}
+
+	written, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return written, fmt.Errorf("download failed: %w", err)
+	}
+	if written > maxBytes {
+		return written, fmt.Errorf("download too large: more than %d bytes", maxBytes)
+	}
+	return written, nil
+}
+
+// Endpoint retorna a URL do backend em uso
+func (c *HTTPClient) Endpoint() string {
+	return c.endpoints.Active()
+}
+
+// EndpointStatus retorna os endpoints configurados e o ativo
+func (c *HTTPClient) EndpointStatus() EndpointStatus {
+	return c.endpoints.Status()
 }
 
 // GetMetrics returns the current HTTP client metrics
@@ -269,6 +698,19 @@ func (c *HTTPClient) IsHealthy() bool {
 	return successRate >= 0.8 // 80% success rate threshold
 }
 
+// proxyFor retorna o proxy (sem credenciais) usado para req, para mensagens de erro
+func (c *HTTPClient) proxyFor(req *http.Request) string {
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return ""
+	}
+	proxyURL, err := transport.Proxy(req)
+	if err != nil {
+		return ""
+	}
+	return redactedProxy(proxyURL)
+}
+
 // Close closes the HTTP client and cleans up resources
 func (c *HTTPClient) Close() error {
 	if transport, ok := c.client.Transport.(*http.Transport); ok {
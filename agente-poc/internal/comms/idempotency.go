@@ -0,0 +1,71 @@
+package comms
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKeyHeader leva a chave de idempotência de inventários e
+// resultados de comando. A mesma chave vai em todas as tentativas de uma
+// mensagem (retentativas HTTP, retentativas do agente e reenvios da fila
+// offline, inclusive após reiniciar), para que o backend descarte as
+// duplicatas de um POST que expirou do lado do agente mas foi processado.
+// Backends antigos, que não leem o cabeçalho, encontram a mesma chave no
+// campo idempotency_key do corpo.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentMessageTypes são os tipos da fila offline que levam chave
+var idempotentMessageTypes = map[string]bool{
+	"inventory":      true,
+	"command_result": true,
+}
+
+// NewIdempotencyKey gera uma chave nova (UUID v4) para uma mensagem lógica
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Sem entropia do sistema, uma chave única por instante ainda
+		// distingue mensagens diferentes
+		return fmt.Sprintf("ts-%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // versão 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variante RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// idempotencyHeaders monta os cabeçalhos extras de uma requisição com chave
+// (nil sem chave)
+func idempotencyHeaders(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+	return map[string]string{IdempotencyKeyHeader: key}
+}
+
+// ensureIdempotencyKey garante a chave de inventários e resultados de
+// comando ao entrar na fila. Mensagens criadas sem chave (ou gravadas por
+// versões anteriores do agente) recebem uma nova, que passa a valer para
+// todos os reenvios; ela também vai para o corpo, se ainda não cifrado.
+// Retorna true se a mensagem mudou.
+func ensureIdempotencyKey(message *QueuedMessage) bool {
+	if !idempotentMessageTypes[message.Type] {
+		return false
+	}
+	changed := false
+	if message.IdempotencyKey == "" {
+		changed = true
+		if key, ok := message.Data["idempotency_key"].(string); ok && key != "" {
+			message.IdempotencyKey = key
+		} else {
+			message.IdempotencyKey = NewIdempotencyKey()
+		}
+	}
+	if message.Data != nil {
+		if _, ok := message.Data["idempotency_key"]; !ok {
+			message.Data["idempotency_key"] = message.IdempotencyKey
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,251 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keyedRequest é a chave de idempotência vista pelo backend numa requisição
+type keyedRequest struct {
+	path   string
+	header string
+	body   string
+}
+
+// keyBackend responde 503 enquanto houver falhas pendentes e registra a
+// chave (cabeçalho e corpo) de cada requisição, inclusive as que falharam
+type keyBackend struct {
+	server   *httptest.Server
+	failures atomic.Int64
+
+	mu       sync.Mutex
+	requests []keyedRequest
+}
+
+func newKeyBackend(t *testing.T) *keyBackend {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	backend := &keyBackend{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IdempotencyKey string `json:"idempotency_key"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		backend.mu.Lock()
+		backend.requests = append(backend.requests, keyedRequest{path: r.URL.Path, header: r.Header.Get(IdempotencyKeyHeader), body: body.IdempotencyKey})
+		backend.mu.Unlock()
+
+		if backend.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+// take retorna e esquece as requisições registradas
+func (b *keyBackend) take() []keyedRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	requests := b.requests
+	b.requests = nil
+	return requests
+}
+
+// sameKey confere que todas as requisições levaram a mesma chave, no
+// cabeçalho e no corpo, e a retorna
+func sameKey(t *testing.T, requests []keyedRequest, want int) string {
+	t.Helper()
+	if len(requests) != want {
+		t.Fatalf("%d requests, want %d", len(requests), want)
+	}
+	key := requests[0].header
+	if key == "" {
+		t.Fatalf("request without %s: %+v", IdempotencyKeyHeader, requests[0])
+	}
+	for i, request := range requests {
+		if request.header != key || request.body != key {
+			t.Fatalf("request %d carried header %q, body %q; want %q in both", i, request.header, request.body, key)
+		}
+	}
+	return key
+}
+
+func TestNewIdempotencyKey(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key := NewIdempotencyKey()
+		if !uuidV4.MatchString(key) {
+			t.Fatalf("key %q is not a UUID v4", key)
+		}
+		if seen[key] {
+			t.Fatalf("key %q generated twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestIdempotencyKeyReusedAcrossRetries(t *testing.T) {
+	backend := newKeyBackend(t)
+	m, err := New(&Config{
+		BackendURL:        backend.server.URL,
+		Token:             "test-token",
+		MachineID:         "test-machine",
+		Logger:            testLogger(t),
+		HTTPTimeout:       5 * time.Second,
+		HTTPMaxRetries:    3,
+		HTTPRetryDelay:    time.Millisecond,
+		HTTPMaxRetryDelay: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.cancel)
+
+	// Duas falhas e o sucesso: as três tentativas com a mesma chave
+	sends := []func() error{
+		func() error { return m.SendInventoryWithSequence(spoolTestInventory(), 1) },
+		func() error { return m.SendInventoryWithSequence(spoolTestInventory(), 1) },
+		func() error {
+			return m.SendCommandResult(&CommandResult{ID: "cmd-1", CommandID: "cmd-1", Status: StatusSuccess})
+		},
+		func() error {
+			return m.SendCommandResult(&CommandResult{ID: "cmd-2", CommandID: "cmd-2", Status: StatusSuccess})
+		},
+	}
+	keys := make(map[string]bool)
+	for i, send := range sends {
+		backend.failures.Store(2)
+		if err := send(); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+		key := sameKey(t, backend.take(), 3)
+		// Mensagens distintas, mesmo com conteúdo igual, têm chaves distintas
+		if keys[key] {
+			t.Fatalf("send %d reused key %q", i, key)
+		}
+		keys[key] = true
+	}
+
+	// Quem reenvia o mesmo inventário repete a chave
+	for i := 0; i < 2; i++ {
+		if err := m.SendInventoryWithKey(spoolTestInventory(), 2, "fixed-key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if key := sameKey(t, backend.take(), 2); key != "fixed-key" {
+		t.Fatalf("explicit key replaced by %q", key)
+	}
+
+	// O resultado guarda a chave: o mesmo resultado reenviado a repete
+	result := &CommandResult{ID: "cmd-3", CommandID: "cmd-3", Status: StatusSuccess}
+	for i := 0; i < 2; i++ {
+		if err := m.SendCommandResult(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if key := sameKey(t, backend.take(), 2); key != result.IdempotencyKey {
+		t.Fatalf("result key %q, sent %q", result.IdempotencyKey, key)
+	}
+}
+
+func TestIdempotencyKeySurvivesRestart(t *testing.T) {
+	backend := newKeyBackend(t)
+	backend.failures.Store(2)
+	fake := newTestClock()
+	result := &CommandResult{ID: "cmd-1", CommandID: "cmd-1", Status: StatusSuccess}
+	queuePath := filepath.Join(t.TempDir(), "queue.json")
+
+	m := newSpoolTestManager(t, backend.server.URL, queuePath, fake)
+	if err := m.SendInventoryWithSequence(spoolTestInventory(), 1); !errors.Is(err, ErrSpooled) {
+		t.Fatalf("inventory during the outage: %v", err)
+	}
+	// O resultado que falhou vai para a fila como no transbordo do WebSocket
+	if err := m.SendCommandResult(result); err == nil {
+		t.Fatal("result sent during the outage")
+	}
+	if err := m.OfflineQueue().Enqueue(commandResultMessage(result)); err != nil {
+		t.Fatal(err)
+	}
+	failed := backend.take()
+	if len(failed) != 2 || failed[0].header == "" || failed[1].header == "" || failed[0].header == failed[1].header {
+		t.Fatalf("failed attempts = %+v", failed)
+	}
+
+	// Depois de reiniciar, o reenvio da fila usa a chave da primeira tentativa
+	restarted := newSpoolTestManager(t, backend.server.URL, queuePath, fake)
+	restarted.replayQueued()
+	replayed := make(map[string]string)
+	for _, request := range backend.take() {
+		if request.header != request.body {
+			t.Fatalf("replay carried header %q, body %q", request.header, request.body)
+		}
+		replayed[request.path] = request.header
+	}
+	if replayed[EndpointInventory] != failed[0].header || replayed["/commands/result"] != failed[1].header {
+		t.Fatalf("replayed keys %v, first attempts %+v", replayed, failed)
+	}
+}
+
+func TestQueueKeysLegacyMessages(t *testing.T) {
+	queuePath := filepath.Join(t.TempDir(), "queue.json")
+	// Fila gravada por uma versão sem chaves
+	legacy := []QueuedMessage{
+		{ID: "inv-1", Type: "inventory", Method: http.MethodPost, Endpoint: EndpointInventory, Data: map[string]interface{}{"machine_id": "m"}, Timestamp: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: "hb-1", Type: "heartbeat", Method: http.MethodPost, Endpoint: EndpointHeartbeat, Data: map[string]interface{}{"machine_id": "m"}, Timestamp: time.Now(), ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(queuePath, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// load abre a fila (como no início do agente) e lê o que ficou no disco
+	load := func() map[string]QueuedMessage {
+		t.Helper()
+		if _, err := NewMessageQueue(QueueConfig{PersistPath: queuePath, Logger: testLogger(t)}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(queuePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored []QueuedMessage
+		if err := json.Unmarshal(data, &stored); err != nil {
+			t.Fatal(err)
+		}
+		messages := make(map[string]QueuedMessage)
+		for _, message := range stored {
+			messages[message.ID] = message
+		}
+		return messages
+	}
+
+	first := load()
+	inventory := first["inv-1"]
+	if inventory.IdempotencyKey == "" || inventory.Data["idempotency_key"] != inventory.IdempotencyKey {
+		t.Fatalf("legacy inventory not keyed: %+v", inventory)
+	}
+	if heartbeat := first["hb-1"]; heartbeat.IdempotencyKey != "" || heartbeat.Data["idempotency_key"] != nil {
+		t.Fatalf("heartbeat keyed: %+v", heartbeat)
+	}
+
+	// A chave foi gravada: outro reinício não a troca
+	if again := load()["inv-1"]; again.IdempotencyKey != inventory.IdempotencyKey {
+		t.Fatalf("key changed after a second restart: %q, was %q", again.IdempotencyKey, inventory.IdempotencyKey)
+	}
+}
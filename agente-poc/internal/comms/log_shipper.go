@@ -0,0 +1,265 @@
+package comms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/logging"
+)
+
+// Padrões do LogShipper
+const (
+	DefaultLogShipBatchSize     = 100
+	DefaultLogShipFlushInterval = 30 * time.Second
+	DefaultLogShipBufferSize    = 1000
+)
+
+// logsEndpoint recebe os lotes de log do agente
+const logsEndpoint = "/logs"
+
+// LogRecord é uma linha de log como enviada ao backend
+type LogRecord struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// LogBatch é o corpo do POST /logs. Dropped conta as linhas descartadas
+// desde o lote anterior (buffer cheio ou envio recusado).
+type LogBatch struct {
+	MachineID  string      `json:"machine_id"`
+	InstanceID string      `json:"instance_id,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+	Dropped    int64       `json:"dropped,omitempty"`
+	Entries    []LogRecord `json:"entries"`
+}
+
+// LogShipperConfig configura o LogShipper
+type LogShipperConfig struct {
+	// MinLevel é o nível mínimo enviado
+	MinLevel logging.LogLevel
+	// BatchSize linhas por lote; um lote cheio é enviado sem esperar o intervalo
+	BatchSize int
+	// FlushInterval é o intervalo máximo entre a linha e o envio
+	FlushInterval time.Duration
+	// BufferSize linhas aguardando envio; acima disso são descartadas
+	BufferSize int
+	// Send entrega um lote (ver Manager.SendLogs); ErrSpooled conta como
+	// entregue, já que o lote ficou na fila offline
+	Send func(batch *LogBatch) error
+	// Clock é a fonte do ticker (nil = relógio do sistema)
+	Clock clock.Clock
+}
+
+// LogShipperStats são os contadores do LogShipper
+type LogShipperStats struct {
+	Shipped   int64  `json:"shipped"`
+	Spooled   int64  `json:"spooled"`
+	Dropped   int64  `json:"dropped"`
+	Failed    int64  `json:"failed"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// LogShipper é um logging.Sink que junta as linhas a partir de MinLevel e
+// as envia em lotes ao backend. Handle nunca bloqueia quem loga: com o
+// buffer cheio a linha é descartada e contada. Os avisos do próprio envio
+// (retentativas HTTP) também são enviados, no máximo um lote por intervalo.
+type LogShipper struct {
+	config  LogShipperConfig
+	clock   clock.Clock
+	entries chan logging.Entry
+
+	started   atomic.Bool
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+
+	shipped atomic.Int64
+	spooled atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+	// unreported são as descartadas ainda não informadas em LogBatch.Dropped
+	unreported atomic.Int64
+	lastErr    atomic.Value // string
+}
+
+// NewLogShipper cria o shipper; as linhas ficam no buffer até Start
+func NewLogShipper(config LogShipperConfig) *LogShipper {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultLogShipBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultLogShipFlushInterval
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultLogShipBufferSize
+	}
+	return &LogShipper{
+		config:  config,
+		clock:   clock.OrReal(config.Clock),
+		entries: make(chan logging.Entry, config.BufferSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Handle implementa logging.Sink
+func (s *LogShipper) Handle(entry logging.Entry) {
+	if entry.Level < s.config.MinLevel {
+		return
+	}
+	select {
+	case s.entries <- entry:
+	default:
+		s.dropped.Add(1)
+		s.unreported.Add(1)
+	}
+}
+
+// Start inicia o envio periódico
+func (s *LogShipper) Start() {
+	if s.started.CompareAndSwap(false, true) {
+		go s.run()
+	}
+}
+
+// Close envia o que está no buffer e encerra o envio. Sem Start, as linhas
+// pendentes são descartadas.
+func (s *LogShipper) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.started.Load() {
+			<-s.stopped
+		}
+	})
+}
+
+// Stats retorna os contadores de envio
+func (s *LogShipper) Stats() LogShipperStats {
+	stats := LogShipperStats{
+		Shipped: s.shipped.Load(),
+		Spooled: s.spooled.Load(),
+		Dropped: s.dropped.Load(),
+		Failed:  s.failed.Load(),
+	}
+	if lastErr, ok := s.lastErr.Load().(string); ok {
+		stats.LastError = lastErr
+	}
+	return stats
+}
+
+// run junta as linhas em lotes até Close
+func (s *LogShipper) run() {
+	defer close(s.stopped)
+
+	ticker := s.clock.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]logging.Entry, 0, s.config.BatchSize)
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.config.BatchSize {
+				s.ship(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C():
+			if len(batch) > 0 {
+				s.ship(batch)
+				batch = batch[:0]
+			}
+		case <-s.done:
+			// Esvazia o buffer, em lotes de BatchSize
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+					if len(batch) >= s.config.BatchSize {
+						s.ship(batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						s.ship(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// ship envia um lote; recusado, o lote é descartado e contado
+func (s *LogShipper) ship(entries []logging.Entry) {
+	batch := &LogBatch{
+		Timestamp: s.clock.Now(),
+		Dropped:   s.unreported.Swap(0),
+		Entries:   make([]LogRecord, len(entries)),
+	}
+	for i, entry := range entries {
+		batch.Entries[i] = LogRecord{
+			Timestamp: entry.Time,
+			Level:     entry.Level.String(),
+			Message:   entry.Message,
+			Fields:    entry.Fields,
+		}
+	}
+
+	err := s.config.Send(batch)
+	switch {
+	case err == nil:
+		s.shipped.Add(int64(len(entries)))
+	case errors.Is(err, ErrSpooled):
+		s.spooled.Add(int64(len(entries)))
+	default:
+		s.failed.Add(int64(len(entries)))
+		s.unreported.Add(batch.Dropped + int64(len(entries)))
+		s.lastErr.Store(err.Error())
+	}
+}
+
+// SendLogs envia um lote de logs com o machine_id e o instance_id desta
+// execução; falhas transitórias deixam o lote na fila offline (ErrSpooled)
+func (m *Manager) SendLogs(batch *LogBatch) error {
+	batch.MachineID = m.getActualMachineID()
+	batch.InstanceID = m.config.InstanceID
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	if err := m.httpClient.POST(ctx, logsEndpoint, batch, nil); err != nil {
+		return fmt.Errorf("failed to send logs: %w", m.spool(newLogsMessage(batch), err))
+	}
+	m.metrics.HTTPRequests++
+	return nil
+}
+
+// newLogsMessage enfileira um lote de logs com a menor prioridade da fila:
+// com ela cheia, é o primeiro a sair
+func newLogsMessage(batch *LogBatch) QueuedMessage {
+	body := map[string]interface{}{
+		"machine_id":  batch.MachineID,
+		"instance_id": batch.InstanceID,
+		"timestamp":   batch.Timestamp,
+		"entries":     batch.Entries,
+	}
+	if batch.Dropped > 0 {
+		body["dropped"] = batch.Dropped
+	}
+	return QueuedMessage{
+		Type:       "logs",
+		Priority:   1, // Lowest priority
+		Data:       body,
+		Endpoint:   logsEndpoint,
+		Method:     "POST",
+		MaxRetries: 3,
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+	}
+}
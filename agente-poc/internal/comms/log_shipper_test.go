@@ -0,0 +1,216 @@
+package comms
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/logging"
+)
+
+// shipperSink recebe os lotes do LogShipper; err, se não nil, é devolvido
+// ao primeiro envio
+type shipperSink struct {
+	batches chan *LogBatch
+	err     error
+}
+
+func (s *shipperSink) send(batch *LogBatch) error {
+	s.batches <- batch
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// newTestLogShipper cria um shipper com relógio falso que entrega os lotes
+// no canal do sink
+func newTestLogShipper(t *testing.T, config LogShipperConfig) (*LogShipper, *shipperSink, *clock.Fake) {
+	t.Helper()
+	fake := newTestClock()
+	sink := &shipperSink{batches: make(chan *LogBatch, 16)}
+	config.Send = sink.send
+	config.Clock = fake
+	s := NewLogShipper(config)
+	t.Cleanup(s.Close)
+	return s, sink, fake
+}
+
+// nextBatch espera o próximo lote enviado
+func nextBatch(t *testing.T, sink *shipperSink) *LogBatch {
+	t.Helper()
+	select {
+	case batch := <-sink.batches:
+		return batch
+	case <-time.After(2 * time.Second):
+		t.Fatal("no batch shipped")
+		return nil
+	}
+}
+
+// noBatch confirma que nada foi enviado
+func noBatch(t *testing.T, sink *shipperSink) {
+	t.Helper()
+	select {
+	case batch := <-sink.batches:
+		t.Fatalf("unexpected batch of %d entries", len(batch.Entries))
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func shipperEntry(level logging.LogLevel, msg string) logging.Entry {
+	return logging.Entry{Time: time.Now(), Level: level, Message: msg}
+}
+
+func TestLogShipperBatchesBySize(t *testing.T) {
+	s, sink, fake := newTestLogShipper(t, LogShipperConfig{BatchSize: 3, FlushInterval: time.Minute})
+
+	for i := 0; i < 7; i++ {
+		s.Handle(shipperEntry(logging.ERROR, "line"))
+	}
+	s.Start()
+
+	// Lotes cheios saem sem esperar o intervalo
+	for i := 0; i < 2; i++ {
+		if batch := nextBatch(t, sink); len(batch.Entries) != 3 {
+			t.Fatalf("batch %d has %d entries", i, len(batch.Entries))
+		}
+	}
+	noBatch(t, sink)
+
+	// O resto sai no próximo tick
+	waitFor(t, "buffer drained", 2*time.Second, func() bool { return len(s.entries) == 0 && fake.Pending() == 1 })
+	fake.Advance(time.Minute)
+	batch := nextBatch(t, sink)
+	if len(batch.Entries) != 1 || batch.Dropped != 0 || !batch.Timestamp.Equal(fake.Now()) {
+		t.Fatalf("tick batch = %+v", batch)
+	}
+
+	// Tick sem linhas pendentes não envia lote vazio
+	fake.Advance(time.Minute)
+	noBatch(t, sink)
+
+	s.Close()
+	if stats := s.Stats(); stats.Shipped != 7 || stats.Dropped != 0 || stats.Failed != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestLogShipperMinLevel(t *testing.T) {
+	logger, err := logging.NewLogger(&logging.Config{
+		Level:    logging.DEBUG,
+		Format:   "json",
+		Output:   "file",
+		FilePath: filepath.Join(t.TempDir(), "agent.log"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, sink, _ := newTestLogShipper(t, LogShipperConfig{MinLevel: logging.WARNING})
+	detach, err := logging.AttachSink(logger, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Derivados criados antes ou depois do AttachSink também entregam
+	derived := logger.WithField("component", "comms")
+	derived.Debug("debug line")
+	derived.Info("info line")
+	derived.Warning("warning line")
+	logger.WithError(errors.New("boom")).Error("error line")
+
+	detach()
+	logger.Error("after detach")
+	s.Start()
+	s.Close()
+
+	batch := nextBatch(t, sink)
+	if len(batch.Entries) != 2 {
+		t.Fatalf("entries = %+v", batch.Entries)
+	}
+	warning, failure := batch.Entries[0], batch.Entries[1]
+	if warning.Level != "WARNING" || warning.Message != "warning line" || warning.Fields["component"] != "comms" {
+		t.Fatalf("warning entry = %+v", warning)
+	}
+	if failure.Level != "ERROR" || failure.Fields["error"] != "boom" || failure.Fields["error_type"] != "*errors.errorString" {
+		t.Fatalf("error entry = %+v", failure)
+	}
+}
+
+func TestLogShipperDropsWhenBufferFull(t *testing.T) {
+	s, sink, _ := newTestLogShipper(t, LogShipperConfig{BufferSize: 2})
+
+	for i := 0; i < 5; i++ {
+		s.Handle(shipperEntry(logging.ERROR, "line"))
+	}
+	if stats := s.Stats(); stats.Dropped != 3 {
+		t.Fatalf("dropped %d, want 3", stats.Dropped)
+	}
+
+	// O Close envia o buffer e informa as descartadas no lote
+	s.Start()
+	s.Close()
+	batch := nextBatch(t, sink)
+	if len(batch.Entries) != 2 || batch.Dropped != 3 {
+		t.Fatalf("batch has %d entries, dropped %d", len(batch.Entries), batch.Dropped)
+	}
+	noBatch(t, sink)
+}
+
+func TestLogShipperFailedBatchReported(t *testing.T) {
+	s, sink, _ := newTestLogShipper(t, LogShipperConfig{BatchSize: 2})
+	sink.err = errors.New("backend down")
+
+	s.Handle(shipperEntry(logging.ERROR, "lost 1"))
+	s.Handle(shipperEntry(logging.ERROR, "lost 2"))
+	s.Start()
+	if batch := nextBatch(t, sink); len(batch.Entries) != 2 {
+		t.Fatalf("first batch = %+v", batch)
+	}
+
+	// O lote recusado é descartado e informado no seguinte
+	s.Handle(shipperEntry(logging.ERROR, "kept"))
+	s.Close()
+	batch := nextBatch(t, sink)
+	if len(batch.Entries) != 1 || batch.Entries[0].Message != "kept" || batch.Dropped != 2 {
+		t.Fatalf("second batch = %+v", batch)
+	}
+	stats := s.Stats()
+	if stats.Shipped != 1 || stats.Failed != 2 || stats.LastError != "backend down" {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestLogShipperSpoolsOffline(t *testing.T) {
+	backend := newOutageBackend(t, 1)
+	fake := newTestClock()
+	m := newSpoolTestManager(t, backend.server.URL, filepath.Join(t.TempDir(), "queue.json"), fake)
+
+	s := NewLogShipper(LogShipperConfig{Send: m.SendLogs, Clock: fake})
+	s.Handle(shipperEntry(logging.ERROR, "while offline"))
+	s.Start()
+	s.Close()
+
+	// Com o backend fora, o lote vai para a fila com a menor prioridade
+	stats := s.Stats()
+	if stats.Spooled != 1 || stats.Shipped != 0 || stats.Failed != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	queued, err := m.OfflineQueue().Peek()
+	if err != nil || queued.Type != "logs" || queued.Priority != 1 || queued.Endpoint != logsEndpoint {
+		t.Fatalf("queued = %+v, %v", queued, err)
+	}
+	if queued.Data["machine_id"] != "test-machine" {
+		t.Fatalf("queued data = %v", queued.Data)
+	}
+
+	// De volta, o reenvio da fila entrega o lote
+	m.replayQueued()
+	if received := backend.received(); len(received) != 1 || received[0] != logsEndpoint {
+		t.Fatalf("backend received %v", received)
+	}
+	if size := m.OfflineQueue().Size(); size != 0 {
+		t.Fatalf("%d messages left in the queue", size)
+	}
+}
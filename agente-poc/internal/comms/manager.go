@@ -5,38 +5,184 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"agente-poc/internal/chaos"
+	"agente-poc/internal/clock"
 	"agente-poc/internal/collector"
+	"agente-poc/internal/events"
 	"agente-poc/internal/logging"
-
-	"github.com/gorilla/websocket"
+	"agente-poc/internal/version"
 )
 
 // Config contém a configuração do communications manager
 type Config struct {
-	BackendURL        string
-	WebSocketURL      string
+	BackendURL   string
+	WebSocketURL string
+	// Endpoints de failover (outras regiões), tentados depois de BackendURL e
+	// WebSocketURL em ordem; o ativo troca depois de FailoverThreshold falhas
+	// consecutivas (0 = DefaultFailoverThreshold) e continua sendo o preferido
+	// nas reconexões (ver EndpointSet)
+	BackendURLs       []string
+	WebSocketURLs     []string
+	FailoverThreshold int
 	Token             string
+	// Tokens adicionais para rotação (após Token, em ordem de preferência)
+	Tokens            []string
 	MachineID         string
 	RetryInterval     time.Duration
 	HeartbeatInterval time.Duration
 	Logger            logging.Logger
 
-	// HTTP configuration
-	HTTPTimeout    time.Duration
-	HTTPMaxRetries int
-	HTTPRetryDelay time.Duration
-	TLSSkipVerify  bool
-
-	// WebSocket configuration
+	// HTTP configuration. As retentativas (429, 5xx e falhas de rede) usam
+	// o Retry-After do backend ou backoff exponencial de HTTPRetryDelay até
+	// HTTPMaxRetryDelay, sem passar de HTTPRetryBudget por requisição
+	HTTPTimeout       time.Duration
+	HTTPMaxRetries    int
+	HTTPRetryDelay    time.Duration
+	HTTPMaxRetryDelay time.Duration
+	HTTPRetryBudget   time.Duration
+	TLSSkipVerify     bool
+	// TLSFiles habilita a autenticação mútua (certificado de cliente) e troca
+	// as raízes do sistema pela CA informada; exclusivo com TLSSkipVerify
+	TLSFiles
+	// PinnedCertificates são os SHA-256 (hex) de certificados dos quais um
+	// precisa estar na cadeia do backend, além da verificação normal
+	PinnedCertificates []string
+	// ProxyURL é o proxy de saída do HTTP e do WebSocket (http:// ou
+	// socks5://, credenciais no userinfo); vazio segue HTTPS_PROXY/HTTP_PROXY
+	// do ambiente. NO_PROXY vale nos dois casos.
+	ProxyURL string
+
+	// WebSocket configuration. A reconexão usa backoff exponencial de
+	// WSReconnectDelay até WSMaxBackoff; WSMaxReconnects -1 nunca desiste
 	WSReconnectDelay time.Duration
+	WSMaxBackoff     time.Duration
 	WSMaxReconnects  int
 	WSPingInterval   time.Duration
 	WSPongTimeout    time.Duration
 	WSMaxQueueSize   int
+
+	// WSMessageAcks liga o protocolo de confirmação do WebSocket (ver
+	// WSMessageTypeAck): resultados de comando e respostas de status ficam
+	// pendentes até o ack e são retransmitidos após reconectar; acima de
+	// WSMaxUnacked pendentes, os resultados mais antigos vão para a fila
+	// offline. Comandos repetidos dentro de WSDedupWindow são descartados
+	// mesmo sem acks.
+	WSMessageAcks bool
+	WSMaxUnacked  int
+	WSDedupWindow time.Duration
+
+	// LenientCommandDecoding mantém a conversão permissiva de comandos
+	// durante a migração do backend (ver DecodeCommand)
+	LenientCommandDecoding bool
+	// CommandLimits limita args e options dos comandos recebidos
+	CommandLimits CommandLimits
+
+	// OnRegistration recebe o resultado do registro automático feito no Start
+	// (nil em caso de sucesso); sem callback, o erro é apenas registrado em log
+	OnRegistration func(err error)
+
+	// HeartbeatExtras retorna campos adicionais para o próximo heartbeat
+	// (ex.: woke_from_sleep); campos de um envio que falhou são reaproveitados
+	HeartbeatExtras func() map[string]interface{}
+	// SleepCovered indica se um intervalo sem heartbeats foi passado em
+	// suspensão, para não alertar heartbeats perdidos nesse período
+	SleepCovered func(from, to time.Time) bool
+	// OnHeartbeatResponse recebe a resposta de cada heartbeat aceito
+	// (ex.: sequência de inventário já processada pelo backend)
+	OnHeartbeatResponse func(response *HeartbeatResponse)
+	// OnHeartbeatFailure recebe o erro de cada heartbeat que falhou; chamado
+	// com o heartbeat em andamento, não deve bloquear
+	OnHeartbeatFailure func(err error)
+	// Breakers são os circuit breakers por endpoint consultados antes de
+	// cada heartbeat e inventário (nil = sem circuit breaker); ficam com
+	// quem cria o Manager para o estado sobreviver à recriação dele
+	Breakers *EndpointBreakers
+
+	// SystemHealth amostra CPU, memória e disco para heartbeats, pings e
+	// status_request; sem callback o status vai como "unknown"
+	SystemHealth func() SystemHealthStatus
+
+	// Capabilities é anunciado no registro e em cada heartbeat
+	Capabilities *Capabilities
+
+	// OnIdentityLinked é chamado quando a resposta do registro confirma o
+	// vínculo do new_machine_id informado em SetNewMachineID
+	OnIdentityLinked func(newMachineID string)
+
+	// OnCommand recebe os comandos do backend e decide a admissão (fila,
+	// prioridade e rejeição); sem callback, eles vão para CommandChannel e
+	// são recusados com queue_full se o canal estiver cheio
+	OnCommand func(command *Command) error
+
+	// OnCommandCancel recebe os pedidos command_cancel do backend; sem
+	// callback, o pedido é apenas registrado em log
+	OnCommandCancel func(cancel CommandCancel)
+
+	// OnConfigUpdate recebe as mensagens config_update do backend; sem
+	// callback, a atualização é apenas registrada em log
+	OnConfigUpdate func(update *ConfigUpdate)
+
+	// OnScheduleUpdate recebe as mensagens schedule_update do backend; sem
+	// callback, a atualização é apenas registrada em log
+	OnScheduleUpdate func(update *ScheduleUpdate)
+
+	// OnWhitelistUpdate recebe as mensagens whitelist_update do backend; sem
+	// callback, a atualização é apenas registrada em log
+	OnWhitelistUpdate func(update *WhitelistUpdate)
+
+	// OnEndpointFailover é chamado quando o endpoint ativo troca; transport
+	// é "http" ou "websocket". Chamado na goroutine do envio, não deve bloquear
+	OnEndpointFailover func(transport, from, to string)
+
+	// OnConnectionChange é chamado quando o WebSocket conecta (true) ou cai
+	// (false); chamado pelo loop de conexão, não deve bloquear
+	OnConnectionChange func(connected bool)
+
+	// Clock é a fonte de tempo dos tickers, backoffs e timestamps (nil = relógio do sistema)
+	Clock clock.Clock
+
+	// InstanceID identifica este processo do agente em todos os envios, para o
+	// backend detectar duas instâncias enviando pela mesma máquina
+	InstanceID string
+
+	// Chaos injeta falhas no transporte (HTTP e WebSocket) para testes de
+	// resiliência em staging; nil desativa. O desvio de relógio vem em Clock.
+	Chaos *chaos.Injector
+
+	// Envelope cifra os corpos (HTTP e dados das mensagens WebSocket) para a
+	// chave pública do backend; nil desativa o modo envelope
+	Envelope *EnvelopeSealer
+
+	// EnableCompression comprime em gzip os corpos HTTP maiores que
+	// CompressionThreshold mesmo com backends que não anunciam
+	// accepted_encodings; a codificação anunciada continua tendo precedência
+	EnableCompression    bool
+	CompressionThreshold int
+
+	// UploadRateLimit limita os corpos HTTP a tantos bytes/s (0 = sem limite);
+	// InventoryWindow restringe o envio de inventários a um intervalo diário
+	// (nil = sempre). Os dois mudam em execução com SetUploadRate e
+	// SetSendWindow; heartbeats e resultados de comando não esperam a janela.
+	UploadRateLimit int64
+	InventoryWindow *SendWindow
+
+	// Fila offline: heartbeats e inventórios que falham por erro transitório
+	// ficam em QueuePath (vazio = só em memória) e são reenviados a cada
+	// QueueDrainInterval enquanto houver conexão
+	QueuePath          string
+	QueueMaxSize       int
+	QueueDrainInterval time.Duration
+	// Queue reaproveita a fila de um Manager anterior (recarga da
+	// configuração): tem precedência sobre QueuePath e QueueMaxSize, e o
+	// arquivo não é relido
+	Queue *MessageQueue
 }
 
 // Manager gerencia as comunicações com o backend
@@ -45,6 +191,14 @@ type Manager struct {
 	logger     logging.Logger
 	httpClient *HTTPClient
 	wsClient   *WebSocketClient
+	tokens     *TokenSet
+	clock      clock.Clock
+	queue      *MessageQueue
+	throttle   *Throttle
+
+	// Janela de envio dos inventários (ver SetSendWindow)
+	windowMutex sync.RWMutex
+	window      *SendWindow
 
 	// State management
 	running      bool
@@ -54,22 +208,31 @@ type Manager struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	// Metrics
-	metrics *ManagerMetrics
+	// Metrics. commandsDropped é contado à parte porque o readPump do
+	// WebSocket também descarta comandos (ver dropCommand)
+	metrics         *ManagerMetrics
+	commandsDropped atomic.Int64
 
 	// Channels
 	commandChan chan Command
 	resultChan  chan CommandResult
+	// wsFailed recebe o aviso de que a reconexão do WebSocket desistiu
+	wsFailed chan error
 
-	// Heartbeat control
-	lastHeartbeat  time.Time
-	heartbeatMutex sync.RWMutex
+	// Heartbeat control. heartbeatInterval pode mudar em execução
+	// (SetHeartbeatInterval); heartbeatReset avisa o ticker.
+	lastHeartbeat     time.Time
+	heartbeatInterval time.Duration
+	heartbeatReset    chan struct{}
+	heartbeatMutex    sync.RWMutex
+	pendingExtras     map[string]interface{}
 
 	// System data cache (para consistência entre heartbeat e inventory)
 	systemDataMutex  sync.RWMutex
 	actualMachineID  string
 	actualHostname   string
 	lastSystemUpdate time.Time
+	newMachineID     string // migração de identidade em andamento
 }
 
 // ManagerMetrics tracks manager-level metrics
@@ -77,6 +240,7 @@ type ManagerMetrics struct {
 	StartTime         time.Time
 	TotalUptime       time.Duration
 	HeartbeatsSent    int64
+	MissedHeartbeats  int64
 	InventoriesSent   int64
 	CommandsReceived  int64
 	ResultsSent       int64
@@ -87,6 +251,17 @@ type ManagerMetrics struct {
 	LastErrorTime     time.Time
 	ConnectionStatus  string
 	LastInventoryTime time.Time
+	LastHeartbeatTime time.Time
+	// WSPermanentFailures conta as vezes em que a reconexão do WebSocket
+	// esgotou as tentativas e a conexão foi reiniciada do zero
+	WSPermanentFailures int64
+	// ProgressSent conta os frames command_progress enviados
+	ProgressSent int64
+	// CommandsDropped conta os comandos recusados com queue_full porque o
+	// canal do WebSocket ou o CommandChannel estava cheio
+	CommandsDropped int64
+	// Breakers é o estado do circuit breaker de cada endpoint, pelo caminho
+	Breakers map[string]CircuitBreakerStatus
 }
 
 // New cria uma nova instância do communications manager
@@ -104,10 +279,16 @@ func New(config *Config) (*Manager, error) {
 		config.HTTPTimeout = 30 * time.Second
 	}
 	if config.HTTPMaxRetries == 0 {
-		config.HTTPMaxRetries = 3
+		config.HTTPMaxRetries = DefaultHTTPMaxRetries
 	}
 	if config.HTTPRetryDelay == 0 {
-		config.HTTPRetryDelay = 1 * time.Second
+		config.HTTPRetryDelay = DefaultHTTPRetryDelay
+	}
+	if config.HTTPMaxRetryDelay == 0 {
+		config.HTTPMaxRetryDelay = DefaultHTTPMaxRetryDelay
+	}
+	if config.HTTPRetryBudget == 0 {
+		config.HTTPRetryBudget = DefaultHTTPRetryBudget
 	}
 	if config.WSReconnectDelay == 0 {
 		config.WSReconnectDelay = 5 * time.Second
@@ -115,6 +296,9 @@ func New(config *Config) (*Manager, error) {
 	if config.WSMaxReconnects == 0 {
 		config.WSMaxReconnects = 10
 	}
+	if config.WSMaxBackoff == 0 {
+		config.WSMaxBackoff = DefaultWSMaxBackoff
+	}
 	if config.WSPingInterval == 0 {
 		config.WSPingInterval = 30 * time.Second
 	}
@@ -127,56 +311,149 @@ func New(config *Config) (*Manager, error) {
 	if config.HeartbeatInterval == 0 {
 		config.HeartbeatInterval = 30 * time.Second
 	}
+	if config.CompressionThreshold == 0 {
+		config.CompressionThreshold = DefaultCompressionThreshold
+	}
+	if config.QueueDrainInterval == 0 {
+		config.QueueDrainInterval = 30 * time.Second
+	}
+	config.Clock = clock.OrReal(config.Clock)
+
+	if _, err := ProxyFunc(config.ProxyURL); err != nil {
+		return nil, fmt.Errorf("invalid proxy configuration: %w", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Conjunto de tokens compartilhado por HTTP e WebSocket, para que a
+	// promoção feita por um valha para o outro
+	tokens := NewTokenSet(append([]string{config.Token}, config.Tokens...)...)
+
+	// Um conjunto de endpoints por transporte: o WebSocket pode estar em uma
+	// região enquanto o HTTP já passou para outra
+	failover := func(transport string) func(from, to string) {
+		return func(from, to string) {
+			config.Logger.WithFields(map[string]interface{}{
+				"transport": transport,
+				"from":      from,
+				"to":        to,
+			}).Warning("Backend endpoint failover")
+			if config.OnEndpointFailover != nil {
+				config.OnEndpointFailover(transport, from, to)
+			}
+		}
+	}
+	httpEndpoints := NewEndpointSet(append([]string{config.BackendURL}, config.BackendURLs...), config.FailoverThreshold, failover("http"))
+	wsEndpoints := NewEndpointSet(append([]string{config.WebSocketURL}, config.WebSocketURLs...), config.FailoverThreshold, failover("websocket"))
+
+	throttle := NewThrottle(config.UploadRateLimit, config.Clock)
+
 	// Create HTTP client
-	httpClient := NewHTTPClient(HTTPConfig{
-		BaseURL:         config.BackendURL,
-		Token:           config.Token,
-		UserAgent:       "MacOS-Agent/1.0.0",
-		Timeout:         config.HTTPTimeout,
-		MaxRetries:      config.HTTPMaxRetries,
-		RetryDelay:      config.HTTPRetryDelay,
-		TLSSkipVerify:   config.TLSSkipVerify,
-		ConnectTimeout:  10 * time.Second,
-		IdleTimeout:     90 * time.Second,
-		MaxIdleConns:    10,
-		MaxConnsPerHost: 10,
-		Logger:          config.Logger,
+	httpClient, err := NewHTTPClient(HTTPConfig{
+		Endpoints:          httpEndpoints,
+		Tokens:             tokens,
+		UserAgent:          version.UserAgent(),
+		Timeout:            config.HTTPTimeout,
+		MaxRetries:         config.HTTPMaxRetries,
+		RetryDelay:         config.HTTPRetryDelay,
+		MaxRetryDelay:      config.HTTPMaxRetryDelay,
+		RetryBudget:        config.HTTPRetryBudget,
+		TLSSkipVerify:      config.TLSSkipVerify,
+		TLSFiles:           config.TLSFiles,
+		PinnedCertificates: config.PinnedCertificates,
+		ProxyURL:           config.ProxyURL,
+		ConnectTimeout:     10 * time.Second,
+		IdleTimeout:        90 * time.Second,
+		MaxIdleConns:       10,
+		MaxConnsPerHost:    10,
+		Logger:             config.Logger,
+		Clock:              config.Clock,
+		InstanceID:         config.InstanceID,
+		Chaos:              config.Chaos,
+		Envelope:           config.Envelope,
+		Throttle:           throttle,
+
+		EnableCompression:    config.EnableCompression,
+		CompressionThreshold: config.CompressionThreshold,
 	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
 
 	// Create WebSocket client
-	wsClient := NewWebSocketClient(WebSocketConfig{
-		URL:                  config.WebSocketURL,
-		Token:                config.Token,
+	wsClient, err := NewWebSocketClient(WebSocketConfig{
+		Endpoints:            wsEndpoints,
+		Tokens:               tokens,
 		MachineID:            config.MachineID, // Inicialmente usar config, será atualizado depois
 		ReconnectDelay:       config.WSReconnectDelay,
+		MaxBackoff:           config.WSMaxBackoff,
 		MaxReconnects:        config.WSMaxReconnects,
 		PingInterval:         config.WSPingInterval,
 		PongTimeout:          config.WSPongTimeout,
 		MaxQueueSize:         config.WSMaxQueueSize,
 		Logger:               config.Logger,
 		SystemHealthCallback: nil, // Será definido após criação do manager
+		LenientDecoding:      config.LenientCommandDecoding,
+		CommandLimits:        config.CommandLimits,
+		InstanceID:           config.InstanceID,
+		TLSSkipVerify:        config.TLSSkipVerify,
+		TLSFiles:             config.TLSFiles,
+		PinnedCertificates:   config.PinnedCertificates,
+		ProxyURL:             config.ProxyURL,
+		MessageAcks:          config.WSMessageAcks,
+		MaxUnacked:           config.WSMaxUnacked,
+		DedupWindow:          config.WSDedupWindow,
 	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	queue := config.Queue
+	if queue == nil {
+		queue, err = NewMessageQueue(QueueConfig{
+			MaxSize:     config.QueueMaxSize,
+			PersistPath: config.QueuePath,
+			Logger:      config.Logger,
+			Clock:       config.Clock,
+			Sealer:      config.Envelope,
+		})
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create offline queue: %w", err)
+		}
+	}
 
 	manager := &Manager{
 		config:     config,
 		logger:     config.Logger,
 		httpClient: httpClient,
 		wsClient:   wsClient,
+		tokens:     tokens,
+		clock:      config.Clock,
+		queue:      queue,
+		throttle:   throttle,
+		window:     config.InventoryWindow,
 		ctx:        ctx,
 		cancel:     cancel,
 		metrics: &ManagerMetrics{
-			StartTime:        time.Now(),
+			StartTime:        config.Clock.Now(),
 			ConnectionStatus: "disconnected",
 		},
 		commandChan: make(chan Command, 100),
 		resultChan:  make(chan CommandResult, 100),
+		wsFailed:    make(chan error, 1),
+
+		heartbeatInterval: config.HeartbeatInterval,
+		heartbeatReset:    make(chan struct{}, 1),
 	}
 
 	// Definir callback de sistema health para o WebSocket client
 	wsClient.systemHealthCallback = manager.getSystemHealth
+	wsClient.onPermanentFailure = manager.handleWSPermanentFailure
+	wsClient.spillUnacked = manager.spillUnacked
+	wsClient.commandDropped = manager.dropCommand
 
 	return manager, nil
 }
@@ -192,10 +469,11 @@ func (m *Manager) Start(ctx context.Context) error {
 
 	m.logger.Info("Starting communications manager...")
 	m.running = true
-	m.metrics.StartTime = time.Now()
+	m.metrics.StartTime = m.clock.Now()
 
 	// Start WebSocket connection
 	go m.startWebSocketConnection()
+	go m.handleWebSocketMessages()
 
 	// Start heartbeat
 	m.logger.Debug("Starting heartbeat goroutine")
@@ -204,9 +482,20 @@ func (m *Manager) Start(ctx context.Context) error {
 	// Start command processing
 	go m.processCommands()
 
+	// Quedas de WebSocket injetadas (apenas com chaos ativo)
+	go m.config.Chaos.RunDisconnects(m.ctx, m.wsClient.DropConnection)
+
 	// Start result processing
 	go m.processResults()
 
+	// Reenvio da fila offline
+	go m.drainQueue()
+
+	// Probes dos circuit breakers abertos
+	if m.config.Breakers != nil {
+		go m.runBreakerProbes()
+	}
+
 	// Monitor context cancellation
 	go func() {
 		select {
@@ -221,9 +510,13 @@ func (m *Manager) Start(ctx context.Context) error {
 	// Try to register machine if not already registered
 	go func() {
 		time.Sleep(2 * time.Second) // Wait for initial connections
-		if err := m.RegisterMachine(); err != nil {
+		err := m.RegisterMachine()
+		if err != nil {
 			m.logger.Error("Failed to register machine: %v", err)
 		}
+		if m.config.OnRegistration != nil {
+			m.config.OnRegistration(err)
+		}
 	}()
 
 	m.logger.Info("Communications manager started successfully")
@@ -245,6 +538,11 @@ func (m *Manager) Stop() error {
 	// Cancel context
 	m.cancel()
 
+	// Resultados ainda sem ack vão para a fila offline, persistida em disco
+	for _, message := range m.wsClient.TakeUnacked() {
+		m.spillUnacked(message)
+	}
+
 	// Close WebSocket
 	if err := m.wsClient.Close(); err != nil {
 		m.logger.Error("Error closing WebSocket client: %v", err)
@@ -263,59 +561,115 @@ func (m *Manager) Stop() error {
 	return nil
 }
 
-// startWebSocketConnection manages WebSocket connection
+// startWebSocketConnection manages WebSocket connection. As reconexões são
+// do WebSocketClient (backoff exponencial); este loop registra a máquina a
+// cada conexão e, quando o cliente desiste, reinicia a conexão do zero após
+// WSMaxBackoff.
 func (m *Manager) startWebSocketConnection() {
 	for {
 		select {
 		case <-m.ctx.Done():
 			return
 		default:
-			if err := m.wsClient.Connect(); err != nil {
-				m.logger.Error("Failed to connect WebSocket: %v", err)
-				m.metrics.Errors++
-				m.metrics.LastError = err.Error()
-				m.metrics.LastErrorTime = time.Now()
-				m.metrics.ConnectionStatus = "disconnected"
-
-				time.Sleep(m.config.WSReconnectDelay)
-				continue
+		}
+
+		if err := m.wsClient.Connect(); err != nil {
+			m.logger.Error("Failed to connect WebSocket: %v", err)
+			m.metrics.Errors++
+			m.metrics.LastError = err.Error()
+			m.metrics.LastErrorTime = m.clock.Now()
+			m.metrics.ConnectionStatus = "disconnected"
+
+			m.wsClient.Reconnect()
+			if !m.waitForWebSocket() {
+				return
 			}
+			continue
+		}
 
-			m.metrics.ConnectionStatus = "connected"
-			m.logger.Info("WebSocket connected successfully")
+		m.metrics.ConnectionStatus = "connected"
+		m.logger.Info("WebSocket connected successfully")
+		if m.config.OnConnectionChange != nil {
+			m.config.OnConnectionChange(true)
+		}
 
-			// Registrar máquina no WebSocket - formato simples esperado pelo backend
-			actualMachineID := m.getActualMachineID()
-			registrationData := map[string]interface{}{
-				"machine_id": actualMachineID,
+		// Registrar máquina no WebSocket - formato simples esperado pelo backend
+		actualMachineID := m.getActualMachineID()
+		registrationData := map[string]interface{}{
+			"machine_id": actualMachineID,
+		}
+
+		// Serializar e enviar registro
+		if regBytes, err := json.Marshal(registrationData); err == nil {
+			if err := m.wsClient.writeRaw(regBytes); err != nil {
+				m.logger.Error("Failed to register WebSocket: %v", err)
+			} else {
+				m.logger.Info("WebSocket registration sent for machine: %s", actualMachineID)
 			}
+		}
 
-			// Serializar e enviar registro
-			if regBytes, err := json.Marshal(registrationData); err == nil {
-				if err := m.wsClient.conn.WriteMessage(websocket.TextMessage, regBytes); err != nil {
-					m.logger.Error("Failed to register WebSocket: %v", err)
-				} else {
-					m.logger.Info("WebSocket registration sent for machine: %s", actualMachineID)
-				}
+		// Wait for disconnection
+		for m.wsClient.IsConnected() {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-m.clock.After(5 * time.Second):
+				// Check connection status
 			}
+		}
 
-			// Process WebSocket messages
-			go m.handleWebSocketMessages()
+		m.metrics.ConnectionStatus = "disconnected"
+		m.logger.Warning("WebSocket disconnected")
+		if m.config.OnConnectionChange != nil {
+			m.config.OnConnectionChange(false)
+		}
 
-			// Wait for disconnection
-			for m.wsClient.IsConnected() {
-				select {
-				case <-m.ctx.Done():
-					return
-				case <-time.After(5 * time.Second):
-					// Check connection status
-				}
-			}
+		// O cliente já iniciou a reconexão ao detectar a queda
+		if !m.waitForWebSocket() {
+			return
+		}
+	}
+}
 
-			m.metrics.ConnectionStatus = "disconnected"
-			m.logger.Warning("WebSocket disconnected")
+// waitForWebSocket espera o cliente reconectar ou desistir; na desistência,
+// aguarda WSMaxBackoff antes de o loop reiniciar a conexão. Retorna false se
+// o Manager foi parado.
+func (m *Manager) waitForWebSocket() bool {
+	for !m.wsClient.IsConnected() {
+		select {
+		case <-m.ctx.Done():
+			return false
+		case <-m.wsFailed:
+			select {
+			case <-m.ctx.Done():
+				return false
+			case <-m.clock.After(m.config.WSMaxBackoff):
+			}
+			return true
+		case <-m.clock.After(5 * time.Second):
 		}
 	}
+	return true
+}
+
+// handleWSPermanentFailure registra a desistência da reconexão do WebSocket
+// e avisa o loop de conexão, que reinicia tudo (conexões HTTP ociosas
+// incluídas, que podem estar presas a um endereço antigo do backend)
+func (m *Manager) handleWSPermanentFailure(err error) {
+	m.metrics.WSPermanentFailures++
+	m.metrics.ConnectionStatus = "failed"
+	m.metrics.Errors++
+	if err != nil {
+		m.metrics.LastError = err.Error()
+	}
+	m.metrics.LastErrorTime = m.clock.Now()
+	m.logger.Error("WebSocket reconnection gave up, resetting connection in %v", m.config.WSMaxBackoff)
+
+	_ = m.httpClient.Close()
+	select {
+	case m.wsFailed <- err:
+	default:
+	}
 }
 
 // handleWebSocketMessages processes incoming WebSocket messages
@@ -328,11 +682,23 @@ func (m *Manager) handleWebSocketMessages() {
 			m.logger.Debug("Received command: %s", command.ID)
 			m.metrics.CommandsReceived++
 
+			if m.config.OnCommand != nil {
+				if err := m.config.OnCommand(&command); err != nil {
+					m.logger.Debug("Command %s not admitted: %v", command.ID, err)
+				}
+				continue
+			}
+
 			// Forward to command channel
 			select {
 			case m.commandChan <- command:
 			default:
-				m.logger.Warning("Command channel full, dropping command")
+				m.dropCommand(command)
+			}
+		case cancel := <-m.wsClient.CancelChannel():
+			m.logger.Debug("Received cancel for command: %s", cancel.CommandID)
+			if m.config.OnCommandCancel != nil {
+				m.config.OnCommandCancel(cancel)
 			}
 		case msg := <-m.wsClient.MessageChannel():
 			m.logger.Debug("Received WebSocket message: %s", msg.Type)
@@ -344,6 +710,10 @@ func (m *Manager) handleWebSocketMessages() {
 				// Already handled by WebSocket client
 			case "config_update":
 				m.handleConfigUpdate(msg)
+			case "schedule_update":
+				m.handleScheduleUpdate(msg)
+			case "whitelist_update":
+				m.handleWhitelistUpdate(msg)
 			case "status_request":
 				m.handleStatusRequest(msg)
 			default:
@@ -358,12 +728,16 @@ func (m *Manager) SendHeartbeat() error {
 	m.heartbeatMutex.Lock()
 	defer m.heartbeatMutex.Unlock()
 
+	if !m.allowEndpoint(EndpointHeartbeat) {
+		return ErrCircuitOpen
+	}
+
 	// Usar dados reais do sistema (consistente com inventory)
 	actualMachineID := m.getActualMachineID()
 	actualHostname := m.getActualHostname()
 
 	// Debug detalhado para identificar duplicação
-	m.logger.Debug("SendHeartbeat called for machine: %s [TID: %d]", actualMachineID, time.Now().UnixNano())
+	m.logger.Debug("SendHeartbeat called for machine: %s [TID: %d]", actualMachineID, m.clock.Now().UnixNano())
 
 	// Get system health info
 	healthStatus := m.getSystemHealth()
@@ -371,30 +745,72 @@ func (m *Manager) SendHeartbeat() error {
 	heartbeat := map[string]interface{}{
 		"machine_id":       actualMachineID,
 		"hostname":         actualHostname,
-		"timestamp":        time.Now(),
+		"timestamp":        m.clock.Now(),
 		"status":           "online",
-		"agent_version":    "1.0.0",
-		"uptime_seconds":   int64(time.Since(m.metrics.StartTime).Seconds()),
+		"agent_version":    version.Version,
+		"uptime_seconds":   int64(m.clock.Since(m.metrics.StartTime).Seconds()),
 		"last_inventory":   m.metrics.LastInventoryTime,
 		"system_health":    healthStatus,
 		"pending_commands": len(m.commandChan),
 		"active_tasks":     []string{}, // TODO: Get from task manager
+		"token_id":         m.tokens.Status().ActiveID,
+		"instance_id":      m.config.InstanceID,
+		// Endpoints em uso, para a distribuição entre regiões na frota
+		"active_endpoint":           m.httpClient.Endpoint(),
+		"active_websocket_endpoint": m.wsClient.Endpoint(),
+	}
+	if newMachineID := m.getNewMachineID(); newMachineID != "" {
+		heartbeat["new_machine_id"] = newMachineID
+	}
+	if m.config.Capabilities != nil {
+		heartbeat["capabilities"] = m.config.Capabilities
 	}
 
+	// Campos extras de um envio anterior que falhou são mantidos, exceto
+	// quando o callback fornece um valor mais recente
+	extras := m.pendingExtras
+	if m.config.HeartbeatExtras != nil {
+		for key, value := range m.config.HeartbeatExtras() {
+			if extras == nil {
+				extras = make(map[string]interface{})
+			}
+			extras[key] = value
+		}
+	}
+	for key, value := range extras {
+		heartbeat[key] = value
+	}
+	m.pendingExtras = nil
+
 	// Send via HTTP
 	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
 	defer cancel()
 
-	if err := m.httpClient.POST(ctx, "/heartbeat", heartbeat, nil); err != nil {
+	var response HeartbeatResponse
+	err := m.httpClient.POST(ctx, EndpointHeartbeat, heartbeat, &response)
+	m.recordEndpoint(EndpointHeartbeat, err)
+	if err != nil {
+		m.pendingExtras = extras
 		m.metrics.Errors++
 		m.metrics.LastError = err.Error()
-		m.metrics.LastErrorTime = time.Now()
-		return fmt.Errorf("failed to send heartbeat: %w", err)
+		m.metrics.LastErrorTime = m.clock.Now()
+		if m.config.OnHeartbeatFailure != nil {
+			m.config.OnHeartbeatFailure(err)
+		}
+		return fmt.Errorf("failed to send heartbeat: %w", m.spool(newHeartbeatMessage(heartbeat), err))
 	}
 
 	m.metrics.HeartbeatsSent++
 	m.metrics.HTTPRequests++
-	m.lastHeartbeat = time.Now()
+	m.lastHeartbeat = m.clock.Now()
+	m.metrics.LastHeartbeatTime = m.lastHeartbeat
+
+	if response.AcceptedEncodings != nil {
+		m.applyAcceptedEncodings(response.AcceptedEncodings)
+	}
+	if m.config.OnHeartbeatResponse != nil {
+		m.config.OnHeartbeatResponse(&response)
+	}
 
 	m.logger.Debug("Heartbeat sent successfully")
 	return nil
@@ -402,6 +818,20 @@ func (m *Manager) SendHeartbeat() error {
 
 // SendInventory envia dados de inventário para o backend
 func (m *Manager) SendInventory(data *collector.InventoryData) error {
+	return m.SendInventoryWithSequence(data, 0)
+}
+
+// SendInventoryWithSequence envia o inventário com o número de sequência da
+// máquina; o backend ecoa a maior sequência processada na resposta do heartbeat.
+// Sequência 0 omite o campo.
+func (m *Manager) SendInventoryWithSequence(data *collector.InventoryData, sequence int64) error {
+	return m.SendInventoryWithKey(data, sequence, NewIdempotencyKey())
+}
+
+// SendInventoryWithKey envia o inventário com a chave de idempotência
+// informada (cabeçalho Idempotency-Key e campo idempotency_key). Quem
+// repete o envio do mesmo inventário deve repetir a chave.
+func (m *Manager) SendInventoryWithKey(data *collector.InventoryData, sequence int64, idempotencyKey string) error {
 	m.logger.WithField("machine_id", data.MachineID).Debug("Sending inventory data...")
 
 	// Atualizar dados do sistema para consistência entre heartbeat e inventory
@@ -418,27 +848,46 @@ func (m *Manager) SendInventory(data *collector.InventoryData) error {
 
 	// Create inventory message in the format expected by backend
 	inventoryMsg := map[string]interface{}{
-		"machine_id": data.MachineID,
-		"type":       "inventory",
-		"timestamp":  time.Now(),
-		"data":       data,
-		"checksum":   checksum,
+		"machine_id":  data.MachineID,
+		"type":        "inventory",
+		"timestamp":   m.clock.Now(),
+		"data":        data,
+		"checksum":    checksum,
+		"instance_id": m.config.InstanceID,
+	}
+	if idempotencyKey != "" {
+		inventoryMsg["idempotency_key"] = idempotencyKey
+	}
+	if sequence > 0 {
+		inventoryMsg["sequence"] = sequence
+	}
+
+	// Fora da janela de envio, o inventário espera na fila offline
+	if !m.inventoryWindowOpen() {
+		return m.deferInventory(newInventoryMessage(inventoryMsg))
+	}
+
+	// Circuito aberto: nada é tentado nem guardado, quem chamou decide
+	if !m.allowEndpoint(EndpointInventory) {
+		return ErrCircuitOpen
 	}
 
 	// Send via HTTP
-	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	ctx, cancel := context.WithTimeout(m.ctx, m.uploadTimeout(len(dataBytes)))
 	defer cancel()
 
-	if err := m.httpClient.POST(ctx, "/inventory", inventoryMsg, nil); err != nil {
+	err = m.httpClient.POSTWithHeaders(ctx, EndpointInventory, inventoryMsg, nil, idempotencyHeaders(idempotencyKey))
+	m.recordEndpoint(EndpointInventory, err)
+	if err != nil {
 		m.metrics.Errors++
 		m.metrics.LastError = err.Error()
-		m.metrics.LastErrorTime = time.Now()
-		return fmt.Errorf("failed to send inventory: %w", err)
+		m.metrics.LastErrorTime = m.clock.Now()
+		return fmt.Errorf("failed to send inventory: %w", m.spool(newInventoryMessage(inventoryMsg), err))
 	}
 
 	m.metrics.InventoriesSent++
 	m.metrics.HTTPRequests++
-	m.metrics.LastInventoryTime = time.Now()
+	m.metrics.LastInventoryTime = m.clock.Now()
 
 	m.logger.Debug("Inventory sent successfully")
 	return nil
@@ -447,17 +896,26 @@ func (m *Manager) SendInventory(data *collector.InventoryData) error {
 // SendCommandResult envia resultado de comando para o backend
 func (m *Manager) SendCommandResult(result *CommandResult) error {
 	m.logger.WithField("command_id", result.CommandID).Debug("Sending command result...")
+	result.InstanceID = m.config.InstanceID
+	if result.IdempotencyKey == "" {
+		result.IdempotencyKey = NewIdempotencyKey()
+	}
 
 	// Send via WebSocket if connected, otherwise HTTP
 	if m.wsClient.IsConnected() {
+		data, err := m.wsData(result)
+		if err != nil {
+			return fmt.Errorf("failed to seal command result: %w", err)
+		}
 		message := WebSocketMessage{
 			Type:      "command_result",
 			ID:        result.ID,
-			Timestamp: time.Now(),
-			Data:      result,
+			Timestamp: m.clock.Now(),
+			Data:      data,
 		}
 
-		if err := m.wsClient.SendMessage(message); err != nil {
+		spill := commandResultMessage(result)
+		if err := m.wsClient.SendReliable(message, &spill); err != nil {
 			m.logger.Warning("Failed to send via WebSocket, trying HTTP: %v", err)
 			return m.sendResultViaHTTP(result)
 		}
@@ -472,15 +930,40 @@ func (m *Manager) SendCommandResult(result *CommandResult) error {
 	return nil
 }
 
+// SendCommandProgress envia um trecho parcial da saída de um comando em
+// execução (mensagem command_progress). Só pelo WebSocket e sem fila: um
+// frame perdido não é reenviado, o resultado final traz a saída completa.
+func (m *Manager) SendCommandProgress(progress *CommandResult) error {
+	progress.InstanceID = m.config.InstanceID
+
+	data, err := m.wsData(progress)
+	if err != nil {
+		return fmt.Errorf("failed to seal command progress: %w", err)
+	}
+	message := WebSocketMessage{
+		Type:      "command_progress",
+		ID:        progress.ID,
+		Timestamp: m.clock.Now(),
+		Data:      data,
+	}
+	if err := m.wsClient.writeMessage(message); err != nil {
+		return fmt.Errorf("failed to send command progress: %w", err)
+	}
+
+	m.metrics.ProgressSent++
+	m.metrics.WSMessages++
+	return nil
+}
+
 // sendResultViaHTTP sends command result via HTTP fallback
 func (m *Manager) sendResultViaHTTP(result *CommandResult) error {
 	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
 	defer cancel()
 
-	if err := m.httpClient.POST(ctx, "/commands/result", result, nil); err != nil {
+	if err := m.httpClient.POSTWithHeaders(ctx, "/commands/result", result, nil, idempotencyHeaders(result.IdempotencyKey)); err != nil {
 		m.metrics.Errors++
 		m.metrics.LastError = err.Error()
-		m.metrics.LastErrorTime = time.Now()
+		m.metrics.LastErrorTime = m.clock.Now()
 		return fmt.Errorf("failed to send command result via HTTP: %w", err)
 	}
 
@@ -489,17 +972,88 @@ func (m *Manager) sendResultViaHTTP(result *CommandResult) error {
 	return nil
 }
 
+// SendEvent envia um evento do pipeline de eventos ao backend (WebSocket se
+// conectado, senão HTTP)
+func (m *Manager) SendEvent(event events.Event) error {
+	if m.wsClient.IsConnected() {
+		data, err := m.wsData(event)
+		if err != nil {
+			return fmt.Errorf("failed to seal event: %w", err)
+		}
+		message := WebSocketMessage{
+			Type:      "event",
+			ID:        event.ID,
+			Timestamp: m.clock.Now(),
+			Data:      data,
+		}
+		if err := m.wsClient.SendMessage(message); err == nil {
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	if err := m.httpClient.POST(ctx, "/events", event, nil); err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	return nil
+}
+
+// SendAlert envia o disparo de uma regra de alerta local (mensagem "alert"):
+// pelo WebSocket quando conectado, senão por HTTP; uma falha transitória
+// deixa o alerta na fila offline
+func (m *Manager) SendAlert(alert Alert) error {
+	if m.wsClient.IsConnected() {
+		data, err := m.wsData(alert)
+		if err != nil {
+			return fmt.Errorf("failed to seal alert: %w", err)
+		}
+		message := WebSocketMessage{
+			Type:      "alert",
+			ID:        fmt.Sprintf("alert-%s-%d", alert.RuleID, alert.Timestamp.UnixNano()),
+			Timestamp: m.clock.Now(),
+			Data:      data,
+		}
+		if err := m.wsClient.SendMessage(message); err == nil {
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	if err := m.httpClient.POST(ctx, "/alerts", alert, nil); err != nil {
+		return fmt.Errorf("failed to send alert: %w", m.spool(newAlertMessage(alert), err))
+	}
+	return nil
+}
+
+// ProbeBackend faz um GET /health no backend, sem enviar dados, para testar
+// se ele voltou; o ctx deve limitar as retentativas do cliente HTTP
+func (m *Manager) ProbeBackend(ctx context.Context) error {
+	if err := m.httpClient.GET(ctx, "/health", nil); err != nil {
+		return fmt.Errorf("backend probe failed: %w", err)
+	}
+	return nil
+}
+
 // RegisterMachine registra a máquina no backend
 func (m *Manager) RegisterMachine() error {
 	actualMachineID := m.getActualMachineID()
 	m.logger.WithField("machine_id", actualMachineID).Info("Registering machine...")
 
 	// Create registration request
+	newMachineID := m.getNewMachineID()
 	regRequest := RegistrationRequest{
 		MachineID:    actualMachineID,
-		Token:        m.config.Token,
-		AgentVersion: "1.0.0",
-		Timestamp:    time.Now(),
+		NewMachineID: newMachineID,
+		Token:        m.tokens.Active(),
+		AgentVersion: version.Version,
+		Timestamp:    m.clock.Now(),
+		Capabilities: m.config.Capabilities,
+		InstanceID:   m.config.InstanceID,
+		Encodings:    SupportedEncodings,
 		// TODO: Add system info and hardware info
 	}
 
@@ -508,10 +1062,10 @@ func (m *Manager) RegisterMachine() error {
 	defer cancel()
 
 	var response RegistrationResponse
-	if err := m.httpClient.POST(ctx, "/machines/register", regRequest, &response); err != nil {
+	if err := m.httpClient.POST(ctx, registrationEndpoint, regRequest, &response); err != nil {
 		m.metrics.Errors++
 		m.metrics.LastError = err.Error()
-		m.metrics.LastErrorTime = time.Now()
+		m.metrics.LastErrorTime = m.clock.Now()
 		return fmt.Errorf("failed to register machine: %w", err)
 	}
 
@@ -521,9 +1075,85 @@ func (m *Manager) RegisterMachine() error {
 
 	m.metrics.HTTPRequests++
 	m.logger.Info("Machine registered successfully")
+
+	m.applyAcceptedEncodings(response.AcceptedEncodings)
+	m.applyEnvelopeKey(response.EnvelopePublicKey)
+
+	if response.IdentityLinked && newMachineID != "" && m.config.OnIdentityLinked != nil {
+		m.config.OnIdentityLinked(newMachineID)
+	}
 	return nil
 }
 
+// applyEnvelopeKey adota a chave de envelope anunciada no registro; uma chave
+// que não bate com a impressão digital pinada é ignorada (a atual continua)
+func (m *Manager) applyEnvelopeKey(publicKey string) {
+	if m.config.Envelope == nil || publicKey == "" {
+		return
+	}
+	if err := m.config.Envelope.SetPublicKey(publicKey); err != nil {
+		m.logger.WithFields(map[string]interface{}{
+			"error":       err.Error(),
+			"fingerprint": m.config.Envelope.Fingerprint(),
+		}).Error("Backend announced an envelope key that does not match the pinned fingerprint, ignoring")
+		return
+	}
+	m.logger.WithField("fingerprint", m.config.Envelope.Fingerprint()).Info("Envelope encryption key received from backend")
+}
+
+// wsData retorna o conteúdo de uma mensagem WebSocket, cifrado no modo envelope
+func (m *Manager) wsData(data interface{}) (interface{}, error) {
+	if m.config.Envelope == nil {
+		return data, nil
+	}
+	return m.config.Envelope.SealValue(data)
+}
+
+// EnvelopeStatus descreve o modo envelope para o health do agente
+func (m *Manager) EnvelopeStatus() map[string]interface{} {
+	if m.config.Envelope == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":     true,
+		"algorithm":   EnvelopeAlgorithm,
+		"fingerprint": m.config.Envelope.Fingerprint(),
+		"key_known":   m.config.Envelope.HasKey(),
+	}
+}
+
+// applyAcceptedEncodings negocia a codificação dos corpos com a lista
+// anunciada pelo backend. Sem lista e com EnableCompression, mantém gzip
+// (um 415 derruba para identity em sendRequest).
+func (m *Manager) applyAcceptedEncodings(accepted []string) {
+	encoding := NegotiateEncoding(accepted)
+	if len(accepted) == 0 && m.config.EnableCompression {
+		encoding = EncodingGzip
+	}
+	if m.httpClient.SetEncoding(encoding) {
+		m.logger.WithFields(map[string]interface{}{
+			"encoding": encoding,
+			"accepted": accepted,
+		}).Info("Request body encoding negotiated with backend")
+	}
+}
+
+// Encoding retorna a codificação dos corpos enviados ao backend
+func (m *Manager) Encoding() string {
+	return m.httpClient.Encoding()
+}
+
+// Download baixa rawURL para w pelo cliente HTTP do backend (mesmo TLS e,
+// para URLs do backend, o token); ver HTTPClient.Download
+func (m *Manager) Download(ctx context.Context, rawURL string, w io.Writer, maxBytes int64) (int64, error) {
+	return m.httpClient.Download(ctx, rawURL, w, maxBytes)
+}
+
+// CompressionStats retorna razão de compressão e tempo de codificação por codificação
+func (m *Manager) CompressionStats() map[string]CompressionStats {
+	return m.httpClient.CompressionStats()
+}
+
 // CommandChannel returns the command channel
 func (m *Manager) CommandChannel() <-chan Command {
 	return m.commandChan
@@ -539,27 +1169,84 @@ func (m *Manager) SendResult(result *CommandResult) error {
 	}
 }
 
-// startHeartbeat starts the heartbeat routine
+// startHeartbeat starts the heartbeat routine. Um novo intervalo
+// (SetHeartbeatInterval) recria o ticker; o próximo heartbeat sai um
+// intervalo novo após a troca.
 func (m *Manager) startHeartbeat() {
-	ticker := time.NewTicker(m.config.HeartbeatInterval)
-	defer ticker.Stop()
+	interval := m.HeartbeatInterval()
+	ticker := m.clock.NewTicker(interval)
+	defer func() { ticker.Stop() }()
 
-	m.logger.Debug("Heartbeat routine started with interval: %v", m.config.HeartbeatInterval)
+	m.logger.Debug("Heartbeat routine started with interval: %v", interval)
 
 	for {
 		select {
 		case <-m.ctx.Done():
 			m.logger.Debug("Heartbeat routine stopped by context")
 			return
-		case <-ticker.C:
+		case <-m.heartbeatReset:
+			if next := m.HeartbeatInterval(); next != interval {
+				interval = next
+				ticker.Stop()
+				ticker = m.clock.NewTicker(interval)
+				m.logger.Debug("Heartbeat interval changed to %v", interval)
+			}
+		case <-ticker.C():
 			m.logger.Debug("Heartbeat ticker triggered - calling SendHeartbeat")
-			if err := m.SendHeartbeat(); err != nil {
+			m.checkMissedHeartbeats(m.clock.Now())
+			if err := m.SendHeartbeat(); errors.Is(err, ErrCircuitOpen) {
+				m.logger.Debug("Heartbeat skipped: heartbeat circuit breaker open")
+			} else if err != nil {
 				m.logger.Error("Failed to send heartbeat: %v", err)
 			}
 		}
 	}
 }
 
+// HeartbeatInterval retorna o intervalo de heartbeat vigente
+func (m *Manager) HeartbeatInterval() time.Duration {
+	m.heartbeatMutex.RLock()
+	defer m.heartbeatMutex.RUnlock()
+	return m.heartbeatInterval
+}
+
+// SetHeartbeatInterval troca o intervalo de heartbeat sem reiniciar o
+// Manager (valores <= 0 são ignorados)
+func (m *Manager) SetHeartbeatInterval(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	m.heartbeatMutex.Lock()
+	m.heartbeatInterval = interval
+	m.heartbeatMutex.Unlock()
+
+	select {
+	case m.heartbeatReset <- struct{}{}:
+	default: // já há uma troca pendente; o ticker lerá o valor mais recente
+	}
+}
+
+// checkMissedHeartbeats alerta quando o último heartbeat enviado está atrasado
+// em mais de dois intervalos, exceto se o período foi coberto por suspensão
+func (m *Manager) checkMissedHeartbeats(now time.Time) {
+	m.heartbeatMutex.RLock()
+	last := m.lastHeartbeat
+	interval := m.heartbeatInterval
+	m.heartbeatMutex.RUnlock()
+
+	if last.IsZero() || now.Sub(last) <= 2*interval {
+		return
+	}
+
+	if m.config.SleepCovered != nil && m.config.SleepCovered(last, now) {
+		m.logger.Debug("Heartbeat gap of %v covered by sleep, not alerting", now.Sub(last).Round(time.Second))
+		return
+	}
+
+	m.metrics.MissedHeartbeats++
+	m.logger.Warning("Missed heartbeats: last successful heartbeat %v ago", now.Sub(last).Round(time.Second))
+}
+
 // processCommands processes incoming commands
 func (m *Manager) processCommands() {
 	for {
@@ -574,6 +1261,31 @@ func (m *Manager) processCommands() {
 	}
 }
 
+// dropCommand recusa um comando que não coube no canal do WebSocket ou no
+// CommandChannel: conta o descarte e responde queue_full sem bloquear quem
+// recebeu o comando
+func (m *Manager) dropCommand(command Command) {
+	m.commandsDropped.Add(1)
+	m.logger.Warning("Command channel full, rejecting command %s", command.ID)
+	go m.rejectQueueFull(command)
+}
+
+// rejectQueueFull responde ao backend um comando recusado com
+// CommandChannel cheio (status rejected, código queue_full)
+func (m *Manager) rejectQueueFull(command Command) {
+	result := &CommandResult{
+		ID:        command.ID,
+		CommandID: command.ID,
+		Status:    StatusRejected,
+		ExitCode:  -1,
+		Timestamp: m.clock.Now(),
+	}
+	result.SetError(NewCodedError(ErrCodeCommandQueueFull))
+	if err := m.SendCommandResult(result); err != nil {
+		m.logger.Error("Failed to send queue_full rejection for command %s: %v", command.ID, err)
+	}
+}
+
 // processResults processes command results
 func (m *Manager) processResults() {
 	for {
@@ -590,20 +1302,81 @@ func (m *Manager) processResults() {
 
 // getSystemHealth returns current system health status
 func (m *Manager) getSystemHealth() map[string]interface{} {
-	// TODO: Get real system health data from collector
-	// Por agora, simular alguns dados básicos
-	return map[string]interface{}{
-		"cpu_usage_percent":    25.5, // Simular 25.5% CPU
-		"memory_usage_percent": 68.3, // Simular 68.3% RAM
-		"disk_usage_percent":   45.2, // Simular 45.2% disco
-		"status":               "healthy",
+	if m.config.SystemHealth == nil {
+		return map[string]interface{}{"status": "unknown"}
+	}
+
+	health := m.config.SystemHealth()
+	status := map[string]interface{}{
+		"cpu_usage_percent":    health.CPUUsage,
+		"memory_usage_percent": health.MemoryUsage,
+		"disk_usage_percent":   health.DiskUsage,
+		"status":               health.Status,
 	}
+	if health.MemoryPressure != "" {
+		status["memory_pressure"] = health.MemoryPressure
+	}
+	return status
 }
 
 // handleConfigUpdate handles configuration updates
 func (m *Manager) handleConfigUpdate(msg WebSocketMessage) {
 	m.logger.Info("Received configuration update")
-	// TODO: Implement configuration update
+
+	raw, err := json.Marshal(msg.Data)
+	if err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid configuration update")
+		return
+	}
+	var update ConfigUpdate
+	if err := json.Unmarshal(raw, &update); err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid configuration update")
+		return
+	}
+
+	if m.config.OnConfigUpdate != nil {
+		m.config.OnConfigUpdate(&update)
+	}
+}
+
+// handleScheduleUpdate repassa os agendamentos recebidos do backend
+func (m *Manager) handleScheduleUpdate(msg WebSocketMessage) {
+	m.logger.Info("Received schedule update")
+
+	raw, err := json.Marshal(msg.Data)
+	if err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid schedule update")
+		return
+	}
+	var update ScheduleUpdate
+	if err := json.Unmarshal(raw, &update); err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid schedule update")
+		return
+	}
+
+	if m.config.OnScheduleUpdate != nil {
+		m.config.OnScheduleUpdate(&update)
+	}
+}
+
+// handleWhitelistUpdate repassa a atualização assinada da whitelist
+func (m *Manager) handleWhitelistUpdate(msg WebSocketMessage) {
+	m.logger.Info("Received whitelist update")
+
+	raw, err := json.Marshal(msg.Data)
+	if err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid whitelist update")
+		return
+	}
+	var update WhitelistUpdate
+	if err := json.Unmarshal(raw, &update); err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Invalid whitelist update")
+		return
+	}
+
+	if m.config.OnWhitelistUpdate != nil {
+		m.config.OnWhitelistUpdate(&update)
+	}
 }
 
 // handleStatusRequest handles status requests
@@ -613,18 +1386,38 @@ func (m *Manager) handleStatusRequest(msg WebSocketMessage) {
 	status := StatusUpdate{
 		MachineID: m.getActualMachineID(),
 		Status:    m.metrics.ConnectionStatus,
-		Message:   fmt.Sprintf("Uptime: %v", time.Since(m.metrics.StartTime)),
-		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Uptime: %v", m.clock.Since(m.metrics.StartTime)),
+		Timestamp: m.clock.Now(),
+	}
+
+	data, err := m.wsData(status)
+	if err != nil {
+		m.logger.WithField("error", err.Error()).Warning("Failed to seal status response")
+		return
 	}
 
 	response := WebSocketMessage{
 		Type:      "status_response",
 		ID:        msg.ID,
-		Timestamp: time.Now(),
-		Data:      status,
+		Timestamp: m.clock.Now(),
+		Data:      data,
 	}
 
-	_ = m.wsClient.SendMessage(response)
+	_ = m.wsClient.SendReliable(response, nil)
+}
+
+// spillUnacked guarda na fila offline uma mensagem do WebSocket que ficou
+// sem ack; ela será entregue por HTTP
+func (m *Manager) spillUnacked(message QueuedMessage) {
+	if m.queue == nil {
+		return
+	}
+	if err := m.queue.Enqueue(message); err != nil {
+		m.logger.WithFields(map[string]interface{}{
+			"type":  message.Type,
+			"error": err.Error(),
+		}).Warning("Failed to spool unacknowledged message")
+	}
 }
 
 // GetMetrics returns manager metrics
@@ -633,8 +1426,12 @@ func (m *Manager) GetMetrics() ManagerMetrics {
 	defer m.runningMutex.RUnlock()
 
 	metrics := *m.metrics
+	metrics.CommandsDropped = m.commandsDropped.Load()
 	if m.running {
-		metrics.TotalUptime = time.Since(m.metrics.StartTime)
+		metrics.TotalUptime = m.clock.Since(m.metrics.StartTime)
+	}
+	if m.config.Breakers != nil {
+		metrics.Breakers = m.config.Breakers.Statuses()
 	}
 
 	return metrics
@@ -660,11 +1457,27 @@ func (m *Manager) UpdateSystemData(machineID, hostname string) {
 	if hostname != "" {
 		m.actualHostname = hostname
 	}
-	m.lastSystemUpdate = time.Now()
+	m.lastSystemUpdate = m.clock.Now()
 
 	m.logger.Debug("System data updated: machine_id=%s, hostname=%s", m.actualMachineID, m.actualHostname)
 }
 
+// SetNewMachineID informa o machine_id que substituirá o atual durante uma
+// migração de identidade; enviado no registro e nos heartbeats como
+// new_machine_id. Vazio encerra a migração.
+func (m *Manager) SetNewMachineID(machineID string) {
+	m.systemDataMutex.Lock()
+	defer m.systemDataMutex.Unlock()
+	m.newMachineID = machineID
+}
+
+// getNewMachineID retorna o machine_id em migração (vazio se nenhum)
+func (m *Manager) getNewMachineID() string {
+	m.systemDataMutex.RLock()
+	defer m.systemDataMutex.RUnlock()
+	return m.newMachineID
+}
+
 // getActualMachineID retorna o machine_id real (gerado) ou fallback para config se não disponível
 func (m *Manager) getActualMachineID() string {
 	m.systemDataMutex.RLock()
@@ -690,3 +1503,87 @@ func (m *Manager) getActualHostname() string {
 func (m *Manager) IsConnected() bool {
 	return m.wsClient.IsConnected() || m.httpClient.IsHealthy()
 }
+
+// InstallToken adiciona um token secundário em tempo de execução (ex.: comando
+// rotate_token); é tentado quando o ativo receber 401
+func (m *Manager) InstallToken(token string) bool {
+	return m.tokens.Install(token)
+}
+
+// ActiveToken retorna o token em uso
+func (m *Manager) ActiveToken() string {
+	return m.tokens.Active()
+}
+
+// TokenStatus retorna os IDs (não sensíveis) dos tokens configurados
+func (m *Manager) TokenStatus() TokenStatus {
+	return m.tokens.Status()
+}
+
+// EndpointStatus retorna os endpoints de cada transporte e os ativos
+func (m *Manager) EndpointStatus() map[string]EndpointStatus {
+	return map[string]EndpointStatus{
+		"http":      m.httpClient.EndpointStatus(),
+		"websocket": m.wsClient.EndpointStatus(),
+	}
+}
+
+// Diagnose runs the connectivity diagnostics against the active backend
+// endpoints
+func (m *Manager) Diagnose(ctx context.Context, stepTimeout time.Duration) *DiagnosticReport {
+	return RunDiagnostics(ctx, DiagnosticsConfig{
+		BackendURL:         m.httpClient.Endpoint(),
+		WebSocketURL:       m.wsClient.Endpoint(),
+		Token:              m.tokens.Active(),
+		StepTimeout:        stepTimeout,
+		TLSSkipVerify:      m.config.TLSSkipVerify,
+		TLSFiles:           m.config.TLSFiles,
+		PinnedCertificates: m.config.PinnedCertificates,
+		ProxyURL:           m.config.ProxyURL,
+	})
+}
+
+// SendSnapshotManifest oferece ao backend a lista de snapshots de inventário retidos
+func (m *Manager) SendSnapshotManifest(snapshots []SnapshotInfo) error {
+	manifest := SnapshotManifest{
+		MachineID: m.getActualMachineID(),
+		Snapshots: snapshots,
+		Timestamp: m.clock.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	if err := m.httpClient.POST(ctx, "/inventory/snapshots/manifest", manifest, nil); err != nil {
+		m.metrics.Errors++
+		m.metrics.LastError = err.Error()
+		m.metrics.LastErrorTime = m.clock.Now()
+		return fmt.Errorf("failed to send snapshot manifest: %w", err)
+	}
+
+	m.metrics.HTTPRequests++
+	return nil
+}
+
+// UploadArtifact envia um artefato (snapshot, arquivo coletado, etc.) ao backend
+func (m *Manager) UploadArtifact(artifact *Artifact) error {
+	if artifact.MachineID == "" {
+		artifact.MachineID = m.getActualMachineID()
+	}
+	if artifact.Timestamp.IsZero() {
+		artifact.Timestamp = m.clock.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.config.HTTPTimeout)
+	defer cancel()
+
+	if err := m.httpClient.POST(ctx, "/artifacts", artifact, nil); err != nil {
+		m.metrics.Errors++
+		m.metrics.LastError = err.Error()
+		m.metrics.LastErrorTime = m.clock.Now()
+		return fmt.Errorf("failed to upload artifact: %w", err)
+	}
+
+	m.metrics.HTTPRequests++
+	return nil
+}
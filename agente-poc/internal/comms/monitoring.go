@@ -7,17 +7,35 @@ import (
 	"sync"
 	"time"
 
+	"agente-poc/internal/clock"
+	"agente-poc/internal/collector"
 	"agente-poc/internal/logging"
 )
 
 // Monitor manages communication monitoring and health checks
 type Monitor struct {
 	logger      logging.Logger
+	clock       clock.Clock
 	metrics     *MonitorMetrics
 	healthCheck *HealthCheck
 	alertRules  []AlertRule
 	alertMutex  sync.RWMutex
 
+	// Hooks de MonitorConfig (ver SystemMetrics e OnAlert)
+	systemMetrics func() SystemHealthStatus
+	onAlert       func(alert Alert, actions []AlertAction)
+	endpoints     func() map[string]CircuitBreakerStatus
+
+	// metricsMutex protege metrics; healthMutex protege healthCheck. Os
+	// health checks e alertas trabalham sobre uma cópia de metrics.
+	metricsMutex sync.RWMutex
+	healthMutex  sync.RWMutex
+
+	// Base do cálculo de MessagesPerSecond: total de requests na última
+	// atualização e o início do monitor, usado antes da primeira
+	lastRequestCount int64
+	startedAt        time.Time
+
 	// Monitoring state
 	running      bool
 	runningMutex sync.RWMutex
@@ -70,7 +88,14 @@ type MonitorMetrics struct {
 	// Performance metrics
 	CPUUsage       float64
 	MemoryUsage    float64
+	DiskUsage      float64
 	GoroutineCount int64
+	// MemoryPressure é o nível de pressão do macOS (collector.MemoryPressure*);
+	// quando presente, prevalece sobre MemoryUsage
+	MemoryPressure string
+
+	// HeartbeatFailureStreak são os heartbeats seguidos que falharam
+	HeartbeatFailureStreak int64
 
 	// Timestamps
 	LastUpdated           time.Time
@@ -96,6 +121,8 @@ type ComponentHealth struct {
 	ErrorRate    float64       `json:"error_rate"`
 	Uptime       time.Duration `json:"uptime"`
 	Message      string        `json:"message"`
+	// Critical pesa o componente como http e websocket na saúde geral
+	Critical bool `json:"critical,omitempty"`
 }
 
 // HealthIssue represents a health issue
@@ -107,17 +134,69 @@ type HealthIssue struct {
 	Count       int64     `json:"count"`
 }
 
+// Condições de AlertRule. CPU, memória, disco, taxa de erro e fila são
+// frações de 0 a 1; response_time em segundos; memory_pressure é o nível
+// mínimo (1 warning, 2 critical) e heartbeat_failures o número de heartbeats
+// seguidos com falha.
+const (
+	AlertConditionErrorRate         = "error_rate"
+	AlertConditionResponseTime      = "response_time"
+	AlertConditionQueueUtilization  = "queue_utilization"
+	AlertConditionCPUUsage          = "cpu_usage"
+	AlertConditionMemoryUsage       = "memory_usage"
+	AlertConditionMemoryPressure    = "memory_pressure"
+	AlertConditionDiskUsage         = "disk_usage"
+	AlertConditionHeartbeatFailures = "heartbeat_failures"
+)
+
 // AlertRule defines monitoring alert rules
 type AlertRule struct {
-	ID            string
-	Name          string
-	Condition     string
-	Threshold     float64
-	Duration      time.Duration
-	Severity      string
-	Enabled       bool
+	ID        string
+	Name      string
+	Condition string
+	Threshold float64
+	// Duration é o intervalo mínimo entre dois disparos da regra; enquanto a
+	// condição persistir, o alerta se repete a cada Duration
+	Duration time.Duration
+	// Sustain é por quanto tempo a condição precisa se manter antes do
+	// primeiro disparo (zero dispara na primeira verificação)
+	Sustain  time.Duration
+	Severity string
+	Enabled  bool
+	// Actions são executadas a cada disparo, além do registro em log
+	Actions       []AlertAction
 	LastTriggered time.Time
 	TriggerCount  int64
+
+	// Início da violação em andamento (zero com a condição normal)
+	breachedSince time.Time
+}
+
+// Tipos de AlertAction
+const (
+	AlertActionBackend = "backend" // mensagem de alta prioridade ao backend
+	AlertActionWebhook = "webhook" // POST do alerta em JSON para URL
+	AlertActionCommand = "command" // comando da whitelist pelo executor
+)
+
+// AlertAction é uma ação executada quando a regra dispara
+type AlertAction struct {
+	Type    string   `json:"type"`
+	URL     string   `json:"url,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Alert é um disparo de regra, enviado ao backend e aos webhooks
+type Alert struct {
+	MachineID string    `json:"machine_id"`
+	RuleID    string    `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	Condition string    `json:"condition"`
+	Severity  string    `json:"severity"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // MonitorConfig configuration for monitoring
@@ -126,6 +205,21 @@ type MonitorConfig struct {
 	MetricsInterval time.Duration
 	HealthInterval  time.Duration
 	AlertRules      []AlertRule
+
+	// SystemMetrics, quando presente, é amostrado antes de cada verificação
+	// dos alertas para CPU, memória e disco (percentuais de 0 a 100)
+	SystemMetrics func() SystemHealthStatus
+	// OnAlert recebe cada disparo com as ações da regra, fora do lock dos
+	// alertas; sem callback o disparo é apenas registrado em log. O
+	// MachineID do alerta fica a cargo do callback.
+	OnAlert func(alert Alert, actions []AlertAction)
+	// Endpoints, quando presente, troca o componente "http" dos health checks
+	// por um componente "http:<caminho>" por endpoint, a partir dos circuit
+	// breakers (ver EndpointBreakers.Statuses)
+	Endpoints func() map[string]CircuitBreakerStatus
+
+	// Clock é a fonte de tempo dos alertas (nil = relógio do sistema)
+	Clock clock.Clock
 }
 
 // NewMonitor creates a new communication monitor
@@ -140,14 +234,19 @@ func NewMonitor(config MonitorConfig) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Monitor{
-		logger:  config.Logger,
-		metrics: &MonitorMetrics{},
+		logger:        config.Logger,
+		clock:         clock.OrReal(config.Clock),
+		systemMetrics: config.SystemMetrics,
+		onAlert:       config.OnAlert,
+		endpoints:     config.Endpoints,
+		metrics:       &MonitorMetrics{},
 		healthCheck: &HealthCheck{
 			Status:     "unknown",
 			Components: make(map[string]ComponentHealth),
 			Issues:     make([]HealthIssue, 0),
 		},
 		alertRules: config.AlertRules,
+		startedAt:  time.Now(),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -210,12 +309,20 @@ func (m *Monitor) collectMetrics() {
 
 // updateMetrics updates the current metrics
 func (m *Monitor) updateMetrics() {
-	m.metrics.LastUpdated = time.Now()
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
 
-	// Calculate derived metrics
-	if m.metrics.TotalRequests > 0 {
-		m.metrics.MessagesPerSecond = float64(m.metrics.TotalRequests) / time.Since(m.metrics.LastUpdated).Seconds()
+	// Taxa desde a atualização anterior (ou desde o início do monitor)
+	now := time.Now()
+	since := m.metrics.LastUpdated
+	if since.IsZero() {
+		since = m.startedAt
+	}
+	if elapsed := now.Sub(since).Seconds(); elapsed > 0 {
+		m.metrics.MessagesPerSecond = float64(m.metrics.TotalRequests-m.lastRequestCount) / elapsed
 	}
+	m.lastRequestCount = m.metrics.TotalRequests
+	m.metrics.LastUpdated = now
 
 	if len(m.metrics.ResponseTimes) > 0 {
 		var total time.Duration
@@ -245,42 +352,51 @@ func (m *Monitor) performHealthChecks() {
 
 // checkHealth performs health checks on all components
 func (m *Monitor) checkHealth() {
+	metrics := m.GetMetrics()
+
+	m.healthMutex.Lock()
+	defer m.healthMutex.Unlock()
+
 	m.healthCheck.Timestamp = time.Now()
 	m.healthCheck.Issues = m.healthCheck.Issues[:0]
 	m.healthCheck.Recommendations = m.healthCheck.Recommendations[:0]
 
 	// Check HTTP client health
-	m.checkHTTPHealth()
+	m.checkHTTPHealth(&metrics)
 
 	// Check WebSocket health
-	m.checkWebSocketHealth()
+	m.checkWebSocketHealth(&metrics)
 
 	// Check queue health
-	m.checkQueueHealth()
+	m.checkQueueHealth(&metrics)
 
 	// Check system resources
-	m.checkSystemResources()
+	m.checkSystemResources(&metrics)
 
 	// Calculate overall health
 	m.calculateOverallHealth()
 
 	// Generate recommendations
-	m.generateRecommendations()
+	m.generateRecommendations(&metrics)
 
 	m.logger.Debug("Health check completed: %s (%.1f%%)", m.healthCheck.Status, m.healthCheck.OverallHealth*100)
 }
 
 // checkHTTPHealth checks HTTP client health
-func (m *Monitor) checkHTTPHealth() {
+func (m *Monitor) checkHTTPHealth(metrics *MonitorMetrics) {
+	if m.checkEndpointsHealth() {
+		return
+	}
+
 	health := ComponentHealth{
 		Status:    "healthy",
 		LastCheck: time.Now(),
-		Uptime:    time.Since(m.metrics.LastUpdated),
+		Uptime:    time.Since(metrics.LastUpdated),
 	}
 
 	// Check error rate
-	if m.metrics.TotalRequests > 0 {
-		errorRate := float64(m.metrics.FailedRequests) / float64(m.metrics.TotalRequests)
+	if metrics.TotalRequests > 0 {
+		errorRate := float64(metrics.FailedRequests) / float64(metrics.TotalRequests)
 		health.ErrorRate = errorRate
 
 		if errorRate > 0.1 { // 10% error rate
@@ -294,34 +410,83 @@ func (m *Monitor) checkHTTPHealth() {
 	}
 
 	// Check response time
-	if m.metrics.AverageResponseTime > 5*time.Second {
+	if metrics.AverageResponseTime > 5*time.Second {
 		health.Status = "degraded"
-		health.Message = fmt.Sprintf("Slow response time: %v", m.metrics.AverageResponseTime)
+		health.Message = fmt.Sprintf("Slow response time: %v", metrics.AverageResponseTime)
 		m.addHealthIssue("warning", "http", health.Message)
 	}
 
 	m.healthCheck.Components["http"] = health
 }
 
+// checkEndpointsHealth avalia cada endpoint do backend pelo seu circuit
+// breaker: aberto é unhealthy; half-open ou com falhas seguidas, degraded.
+// Retorna false sem o hook Endpoints.
+func (m *Monitor) checkEndpointsHealth() bool {
+	if m.endpoints == nil {
+		return false
+	}
+	statuses := m.endpoints()
+	if len(statuses) == 0 {
+		return false
+	}
+
+	delete(m.healthCheck.Components, "http")
+	for endpoint, status := range statuses {
+		component := "http:" + endpoint
+		health := ComponentHealth{
+			Status:    "healthy",
+			LastCheck: time.Now(),
+			Critical:  status.Class == EndpointClassCritical,
+		}
+		if !status.LastTransition.IsZero() {
+			health.Uptime = time.Since(status.LastTransition)
+		}
+		if status.Requests > 0 {
+			health.ErrorRate = float64(status.TotalFailures) / float64(status.Requests)
+		}
+
+		switch {
+		case status.State == BreakerOpen:
+			health.Status = "unhealthy"
+			health.Message = fmt.Sprintf("Circuit breaker open after %d failures: %s", status.Failures, status.LastError)
+			severity := "warning"
+			if health.Critical {
+				severity = "critical"
+			}
+			m.addHealthIssue(severity, component, health.Message)
+		case status.State == BreakerHalfOpen:
+			health.Status = "degraded"
+			health.Message = "Circuit breaker half-open"
+		case status.Failures > 0:
+			health.Status = "degraded"
+			health.Message = fmt.Sprintf("%d consecutive failures", status.Failures)
+		}
+
+		m.healthCheck.Components[component] = health
+	}
+	return true
+}
+
 // checkWebSocketHealth checks WebSocket health
-func (m *Monitor) checkWebSocketHealth() {
+func (m *Monitor) checkWebSocketHealth(metrics *MonitorMetrics) {
 	health := ComponentHealth{
 		Status:    "healthy",
 		LastCheck: time.Now(),
-		Uptime:    time.Since(m.metrics.LastUpdated),
+		Uptime:    time.Since(metrics.LastUpdated),
 	}
 
 	// Check connection status
-	if m.metrics.CurrentConnections == 0 {
+	if metrics.CurrentConnections == 0 {
 		health.Status = "unhealthy"
 		health.Message = "No active connections"
 		m.addHealthIssue("critical", "websocket", health.Message)
 	}
 
 	// Check reconnection attempts
-	if m.metrics.ReconnectAttempts > 10 {
+	if metrics.ReconnectAttempts > 10 {
 		health.Status = "degraded"
-		health.Message = fmt.Sprintf("High reconnection attempts: %d", m.metrics.ReconnectAttempts)
+		health.Message = fmt.Sprintf("High reconnection attempts: %d", metrics.ReconnectAttempts)
 		m.addHealthIssue("warning", "websocket", health.Message)
 	}
 
@@ -329,21 +494,21 @@ func (m *Monitor) checkWebSocketHealth() {
 }
 
 // checkQueueHealth checks message queue health
-func (m *Monitor) checkQueueHealth() {
+func (m *Monitor) checkQueueHealth(metrics *MonitorMetrics) {
 	health := ComponentHealth{
 		Status:    "healthy",
 		LastCheck: time.Now(),
-		Uptime:    time.Since(m.metrics.LastUpdated),
+		Uptime:    time.Since(metrics.LastUpdated),
 	}
 
 	// Check queue utilization
-	if m.metrics.QueueUtilization > 0.9 {
+	if metrics.QueueUtilization > 0.9 {
 		health.Status = "unhealthy"
-		health.Message = fmt.Sprintf("Queue nearly full: %.1f%%", m.metrics.QueueUtilization*100)
+		health.Message = fmt.Sprintf("Queue nearly full: %.1f%%", metrics.QueueUtilization*100)
 		m.addHealthIssue("critical", "queue", health.Message)
-	} else if m.metrics.QueueUtilization > 0.7 {
+	} else if metrics.QueueUtilization > 0.7 {
 		health.Status = "degraded"
-		health.Message = fmt.Sprintf("Queue utilization high: %.1f%%", m.metrics.QueueUtilization*100)
+		health.Message = fmt.Sprintf("Queue utilization high: %.1f%%", metrics.QueueUtilization*100)
 		m.addHealthIssue("warning", "queue", health.Message)
 	}
 
@@ -351,27 +516,37 @@ func (m *Monitor) checkQueueHealth() {
 }
 
 // checkSystemResources checks system resource usage
-func (m *Monitor) checkSystemResources() {
+func (m *Monitor) checkSystemResources(metrics *MonitorMetrics) {
 	health := ComponentHealth{
 		Status:    "healthy",
 		LastCheck: time.Now(),
-		Uptime:    time.Since(m.metrics.LastUpdated),
+		Uptime:    time.Since(metrics.LastUpdated),
 	}
 
-	// Check memory usage
-	if m.metrics.MemoryUsage > 0.9 {
+	// Check memory usage (no macOS, pela pressão de memória)
+	if rank := collector.MemoryPressureRank(metrics.MemoryPressure); rank >= 0 {
+		switch rank {
+		case 2:
+			health.Status = "unhealthy"
+			health.Message = "Critical memory pressure"
+			m.addHealthIssue("critical", "system", health.Message)
+		case 1:
+			health.Status = "degraded"
+			health.Message = "Elevated memory pressure"
+		}
+	} else if metrics.MemoryUsage > 0.9 {
 		health.Status = "unhealthy"
-		health.Message = fmt.Sprintf("High memory usage: %.1f%%", m.metrics.MemoryUsage*100)
+		health.Message = fmt.Sprintf("High memory usage: %.1f%%", metrics.MemoryUsage*100)
 		m.addHealthIssue("critical", "system", health.Message)
-	} else if m.metrics.MemoryUsage > 0.7 {
+	} else if metrics.MemoryUsage > 0.7 {
 		health.Status = "degraded"
-		health.Message = fmt.Sprintf("Elevated memory usage: %.1f%%", m.metrics.MemoryUsage*100)
+		health.Message = fmt.Sprintf("Elevated memory usage: %.1f%%", metrics.MemoryUsage*100)
 	}
 
 	// Check CPU usage
-	if m.metrics.CPUUsage > 0.8 {
+	if metrics.CPUUsage > 0.8 {
 		health.Status = "unhealthy"
-		health.Message = fmt.Sprintf("High CPU usage: %.1f%%", m.metrics.CPUUsage*100)
+		health.Message = fmt.Sprintf("High CPU usage: %.1f%%", metrics.CPUUsage*100)
 		m.addHealthIssue("warning", "system", health.Message)
 	}
 
@@ -385,7 +560,7 @@ func (m *Monitor) calculateOverallHealth() {
 
 	for component, health := range m.healthCheck.Components {
 		weight := 1.0
-		if component == "http" || component == "websocket" {
+		if component == "http" || component == "websocket" || health.Critical {
 			weight = 2.0 // Critical components
 		}
 
@@ -422,20 +597,20 @@ func (m *Monitor) calculateOverallHealth() {
 }
 
 // generateRecommendations generates health recommendations
-func (m *Monitor) generateRecommendations() {
-	if m.metrics.FailedRequests > 0 {
+func (m *Monitor) generateRecommendations(metrics *MonitorMetrics) {
+	if metrics.FailedRequests > 0 {
 		m.healthCheck.Recommendations = append(m.healthCheck.Recommendations, "Review network connectivity and backend availability")
 	}
 
-	if m.metrics.AverageResponseTime > 3*time.Second {
+	if metrics.AverageResponseTime > 3*time.Second {
 		m.healthCheck.Recommendations = append(m.healthCheck.Recommendations, "Consider optimizing request timeout settings")
 	}
 
-	if m.metrics.QueueUtilization > 0.5 {
+	if metrics.QueueUtilization > 0.5 {
 		m.healthCheck.Recommendations = append(m.healthCheck.Recommendations, "Monitor queue size and consider increasing processing capacity")
 	}
 
-	if m.metrics.ReconnectAttempts > 5 {
+	if metrics.ReconnectAttempts > 5 {
 		m.healthCheck.Recommendations = append(m.healthCheck.Recommendations, "Investigate WebSocket connection stability")
 	}
 }
@@ -464,69 +639,178 @@ func (m *Monitor) addHealthIssue(severity, component, description string) {
 
 // monitorAlerts monitors and triggers alerts
 func (m *Monitor) monitorAlerts() {
-	ticker := time.NewTicker(15 * time.Second)
+	ticker := m.clock.NewTicker(15 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-m.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			m.checkAlerts()
 		}
 	}
 }
 
-// checkAlerts checks all alert rules
+// firedAlert é um disparo aguardando a entrega, feita fora do lock
+type firedAlert struct {
+	alert   Alert
+	actions []AlertAction
+}
+
+// checkAlerts checks all alert rules. Uma regra dispara quando a condição se
+// mantém por Sustain e não disparou nos últimos Duration.
 func (m *Monitor) checkAlerts() {
-	m.alertMutex.Lock()
-	defer m.alertMutex.Unlock()
+	m.sampleSystem()
+	metrics := m.GetMetrics()
+	now := m.clock.Now()
 
-	for i, rule := range m.alertRules {
+	var fired []firedAlert
+	m.alertMutex.Lock()
+	for i := range m.alertRules {
+		rule := &m.alertRules[i]
 		if !rule.Enabled {
 			continue
 		}
 
-		triggered := m.evaluateAlertRule(rule)
-		if triggered {
-			m.alertRules[i].LastTriggered = time.Now()
-			m.alertRules[i].TriggerCount++
-			m.triggerAlert(rule)
+		value, breached := m.evaluateAlertRule(*rule, &metrics)
+		if !breached {
+			rule.breachedSince = time.Time{}
+			continue
+		}
+		if rule.breachedSince.IsZero() {
+			rule.breachedSince = now
 		}
+		if now.Sub(rule.breachedSince) < rule.Sustain {
+			continue
+		}
+		if !rule.LastTriggered.IsZero() && now.Sub(rule.LastTriggered) < rule.Duration {
+			continue
+		}
+
+		rule.LastTriggered = now
+		rule.TriggerCount++
+		fired = append(fired, firedAlert{
+			alert: Alert{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Condition: rule.Condition,
+				Severity:  rule.Severity,
+				Value:     value,
+				Threshold: rule.Threshold,
+				Timestamp: now,
+			},
+			actions: append([]AlertAction(nil), rule.Actions...),
+		})
 	}
+	m.alertMutex.Unlock()
+
+	for _, f := range fired {
+		m.triggerAlert(f.alert, f.actions)
+	}
+}
+
+// sampleSystem atualiza CPU, memória e disco pelo hook SystemMetrics
+func (m *Monitor) sampleSystem() {
+	if m.systemMetrics == nil {
+		return
+	}
+	sample := m.systemMetrics()
+
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	m.metrics.CPUUsage = sample.CPUUsage / 100
+	m.metrics.MemoryUsage = sample.MemoryUsage / 100
+	m.metrics.DiskUsage = sample.DiskUsage / 100
+	m.metrics.MemoryPressure = sample.MemoryPressure
 }
 
-// evaluateAlertRule evaluates an alert rule
-func (m *Monitor) evaluateAlertRule(rule AlertRule) bool {
+// evaluateAlertRule evaluates an alert rule, retornando o valor atual da
+// condição e se ele viola o limite
+func (m *Monitor) evaluateAlertRule(rule AlertRule, metrics *MonitorMetrics) (float64, bool) {
 	switch rule.Condition {
-	case "error_rate":
-		if m.metrics.TotalRequests > 0 {
-			errorRate := float64(m.metrics.FailedRequests) / float64(m.metrics.TotalRequests)
-			return errorRate > rule.Threshold
+	case AlertConditionErrorRate:
+		if metrics.TotalRequests > 0 {
+			errorRate := float64(metrics.FailedRequests) / float64(metrics.TotalRequests)
+			return errorRate, errorRate > rule.Threshold
 		}
-	case "response_time":
-		return m.metrics.AverageResponseTime.Seconds() > rule.Threshold
-	case "queue_utilization":
-		return m.metrics.QueueUtilization > rule.Threshold
-	case "memory_usage":
-		return m.metrics.MemoryUsage > rule.Threshold
-	case "cpu_usage":
-		return m.metrics.CPUUsage > rule.Threshold
+	case AlertConditionResponseTime:
+		value := metrics.AverageResponseTime.Seconds()
+		return value, value > rule.Threshold
+	case AlertConditionQueueUtilization:
+		return metrics.QueueUtilization, metrics.QueueUtilization > rule.Threshold
+	case AlertConditionMemoryUsage:
+		// Com a pressão de memória do macOS, o percentual usado não é
+		// indicativo; a regra dispara a partir da pressão warning
+		if rank := collector.MemoryPressureRank(metrics.MemoryPressure); rank >= 0 {
+			return metrics.MemoryUsage, rank >= 1
+		}
+		return metrics.MemoryUsage, metrics.MemoryUsage > rule.Threshold
+	case AlertConditionMemoryPressure:
+		// Threshold é o nível mínimo: 1 warning, 2 critical
+		rank := float64(collector.MemoryPressureRank(metrics.MemoryPressure))
+		return rank, rank >= rule.Threshold
+	case AlertConditionCPUUsage:
+		return metrics.CPUUsage, metrics.CPUUsage > rule.Threshold
+	case AlertConditionDiskUsage:
+		return metrics.DiskUsage, metrics.DiskUsage > rule.Threshold
+	case AlertConditionHeartbeatFailures:
+		streak := float64(metrics.HeartbeatFailureStreak)
+		return streak, streak >= rule.Threshold
 	}
 
-	return false
+	return 0, false
 }
 
 // triggerAlert triggers an alert
-func (m *Monitor) triggerAlert(rule AlertRule) {
-	m.logger.Warning("Alert triggered: %s (%s)", rule.Name, rule.Severity)
+func (m *Monitor) triggerAlert(alert Alert, actions []AlertAction) {
+	m.logger.Warning("Alert triggered: %s (%s): %.2f over %.2f", alert.RuleName, alert.Severity, alert.Value, alert.Threshold)
 
-	// In a real implementation, this would send notifications
-	// via email, Slack, PagerDuty, etc.
+	if m.onAlert != nil {
+		m.onAlert(alert, actions)
+	}
+}
+
+// SetAlertRules troca as regras de alerta; regras com o mesmo ID mantêm o
+// último disparo e a violação em andamento, para a troca não furar o
+// intervalo entre disparos
+func (m *Monitor) SetAlertRules(rules []AlertRule) {
+	m.alertMutex.Lock()
+	defer m.alertMutex.Unlock()
+
+	previous := make(map[string]AlertRule, len(m.alertRules))
+	for _, rule := range m.alertRules {
+		previous[rule.ID] = rule
+	}
+	next := append([]AlertRule(nil), rules...)
+	for i := range next {
+		if old, ok := previous[next[i].ID]; ok {
+			next[i].LastTriggered = old.LastTriggered
+			next[i].TriggerCount = old.TriggerCount
+			next[i].breachedSince = old.breachedSince
+		}
+	}
+	m.alertRules = next
+}
+
+// RecordHeartbeat registra o resultado de um heartbeat para a regra
+// heartbeat_failures; um sucesso zera a sequência de falhas
+func (m *Monitor) RecordHeartbeat(success bool) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
+	if success {
+		m.metrics.HeartbeatFailureStreak = 0
+	} else {
+		m.metrics.HeartbeatFailureStreak++
+	}
 }
 
 // RecordRequest records a request for metrics
 func (m *Monitor) RecordRequest(duration time.Duration, success bool) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	m.metrics.TotalRequests++
 	m.metrics.LastSuccessfulRequest = time.Now()
 
@@ -554,6 +838,9 @@ func (m *Monitor) RecordRequest(duration time.Duration, success bool) {
 
 // RecordError records an error for metrics
 func (m *Monitor) RecordError(errorType string) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	m.metrics.TotalErrors++
 	m.metrics.LastError = time.Now()
 
@@ -571,6 +858,9 @@ func (m *Monitor) RecordError(errorType string) {
 
 // RecordConnection records connection metrics
 func (m *Monitor) RecordConnection(success bool) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	m.metrics.TotalConnections++
 	if success {
 		m.metrics.CurrentConnections++
@@ -581,6 +871,9 @@ func (m *Monitor) RecordConnection(success bool) {
 
 // RecordDisconnection records disconnection
 func (m *Monitor) RecordDisconnection() {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	if m.metrics.CurrentConnections > 0 {
 		m.metrics.CurrentConnections--
 	}
@@ -588,41 +881,68 @@ func (m *Monitor) RecordDisconnection() {
 
 // RecordReconnect records reconnection attempt
 func (m *Monitor) RecordReconnect() {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	m.metrics.ReconnectAttempts++
 }
 
 // RecordDataTransfer records data transfer metrics
 func (m *Monitor) RecordDataTransfer(sent, received int64) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+
 	m.metrics.TotalBytesSent += sent
 	m.metrics.TotalBytesReceived += received
 }
 
-// GetMetrics returns current metrics
+// GetMetrics returns a consistent snapshot of the current metrics
 func (m *Monitor) GetMetrics() MonitorMetrics {
-	return *m.metrics
+	m.metricsMutex.RLock()
+	defer m.metricsMutex.RUnlock()
+
+	snapshot := *m.metrics
+	snapshot.ResponseTimes = append([]time.Duration(nil), m.metrics.ResponseTimes...)
+	return snapshot
 }
 
-// GetHealthCheck returns current health check
+// GetHealthCheck returns a snapshot of the current health check
 func (m *Monitor) GetHealthCheck() HealthCheck {
-	return *m.healthCheck
+	m.healthMutex.RLock()
+	defer m.healthMutex.RUnlock()
+
+	snapshot := *m.healthCheck
+	snapshot.Components = make(map[string]ComponentHealth, len(m.healthCheck.Components))
+	for name, component := range m.healthCheck.Components {
+		snapshot.Components[name] = component
+	}
+	snapshot.Issues = append([]HealthIssue(nil), m.healthCheck.Issues...)
+	snapshot.Recommendations = append([]string(nil), m.healthCheck.Recommendations...)
+	return snapshot
 }
 
 // GetMetricsJSON returns metrics as JSON
 func (m *Monitor) GetMetricsJSON() ([]byte, error) {
-	return json.MarshalIndent(m.metrics, "", "  ")
+	metrics := m.GetMetrics()
+	return json.MarshalIndent(&metrics, "", "  ")
 }
 
 // GetHealthJSON returns health check as JSON
 func (m *Monitor) GetHealthJSON() ([]byte, error) {
-	return json.MarshalIndent(m.healthCheck, "", "  ")
+	health := m.GetHealthCheck()
+	return json.MarshalIndent(&health, "", "  ")
 }
 
 // IsHealthy returns if the system is healthy
 func (m *Monitor) IsHealthy() bool {
+	m.healthMutex.RLock()
+	defer m.healthMutex.RUnlock()
 	return m.healthCheck.Status == "healthy"
 }
 
 // GetOverallHealth returns overall health score
 func (m *Monitor) GetOverallHealth() float64 {
+	m.healthMutex.RLock()
+	defer m.healthMutex.RUnlock()
 	return m.healthCheck.OverallHealth
 }
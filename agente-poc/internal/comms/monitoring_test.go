@@ -0,0 +1,182 @@
+package comms
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/collector"
+)
+
+func TestEvaluateMemoryAlertRules(t *testing.T) {
+	m := NewMonitor(MonitorConfig{Logger: testLogger(t)})
+	usage := AlertRule{Condition: AlertConditionMemoryUsage, Threshold: 0.9}
+	pressure := AlertRule{Condition: AlertConditionMemoryPressure, Threshold: 2}
+
+	tests := []struct {
+		used         float64
+		pressure     string
+		wantUsage    bool
+		wantPressure bool
+	}{
+		// Com pressão conhecida, memory_usage dispara a partir de warning
+		{0.97, collector.MemoryPressureNormal, false, false},
+		{0.40, collector.MemoryPressureWarning, true, false},
+		{0.40, collector.MemoryPressureCritical, true, true},
+		// Sem pressão, vale o percentual usado
+		{0.97, "", true, false},
+		{0.50, "", false, false},
+	}
+	for _, tt := range tests {
+		metrics := &MonitorMetrics{MemoryUsage: tt.used, MemoryPressure: tt.pressure}
+		if _, fired := m.evaluateAlertRule(usage, metrics); fired != tt.wantUsage {
+			t.Errorf("memory_usage at %.2f, pressure %q fired = %t", tt.used, tt.pressure, fired)
+		}
+		if _, fired := m.evaluateAlertRule(pressure, metrics); fired != tt.wantPressure {
+			t.Errorf("memory_pressure at %q fired = %t", tt.pressure, fired)
+		}
+	}
+}
+
+func TestCheckSystemResourcesMemoryPressure(t *testing.T) {
+	m := NewMonitor(MonitorConfig{Logger: testLogger(t)})
+	for pressure, want := range map[string]string{
+		collector.MemoryPressureNormal:   "healthy",
+		collector.MemoryPressureWarning:  "degraded",
+		collector.MemoryPressureCritical: "unhealthy",
+	} {
+		m.checkSystemResources(&MonitorMetrics{MemoryUsage: 0.97, MemoryPressure: pressure})
+		if got := m.healthCheck.Components["system"].Status; got != want {
+			t.Errorf("system health with %s pressure = %s, want %s", pressure, got, want)
+		}
+	}
+	m.checkSystemResources(&MonitorMetrics{MemoryUsage: 0.97})
+	if got := m.healthCheck.Components["system"].Status; got != "unhealthy" {
+		t.Fatalf("system health at 97%% without pressure = %s", got)
+	}
+}
+
+// TestMonitorConcurrentRecorders só pega corridas com go test -race: os
+// recorders rodam em paralelo com as leituras de GetMetrics, health e alertas
+func TestMonitorConcurrentRecorders(t *testing.T) {
+	const writers, perWriter = 8, 200
+	m := NewMonitor(MonitorConfig{
+		Logger:        testLogger(t),
+		AlertRules:    []AlertRule{{ID: "errors", Condition: AlertConditionErrorRate, Threshold: 0.5, Enabled: true}},
+		SystemMetrics: func() SystemHealthStatus { return SystemHealthStatus{CPUUsage: 10, MemoryUsage: 20, DiskUsage: 30} },
+	})
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	inconsistent := make(chan MonitorMetrics, 1)
+	for _, read := range []func(){
+		func() {
+			snapshot := m.GetMetrics()
+			if snapshot.SuccessfulRequests+snapshot.FailedRequests != snapshot.TotalRequests || len(snapshot.ResponseTimes) > 100 {
+				select {
+				case inconsistent <- snapshot:
+				default:
+				}
+			}
+		},
+		func() { _, _ = m.GetMetricsJSON() },
+		func() { _, _ = m.GetHealthJSON() },
+		m.updateMetrics,
+		m.checkHealth,
+		m.checkAlerts,
+	} {
+		readers.Add(1)
+		go func(read func()) {
+			defer readers.Done()
+			for {
+				read()
+				runtime.Gosched()
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}(read)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				m.RecordRequest(time.Duration(i)*time.Millisecond, i%2 == 0)
+				m.RecordError("network")
+				m.RecordConnection(true)
+				m.RecordDisconnection()
+				m.RecordReconnect()
+				m.RecordDataTransfer(10, 20)
+				m.RecordHeartbeat(false)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	readers.Wait()
+
+	select {
+	case snapshot := <-inconsistent:
+		t.Fatalf("inconsistent snapshot: %d total, %d successful, %d failed, %d response times",
+			snapshot.TotalRequests, snapshot.SuccessfulRequests, snapshot.FailedRequests, len(snapshot.ResponseTimes))
+	default:
+	}
+
+	const total = writers * perWriter
+	metrics := m.GetMetrics()
+	if metrics.TotalRequests != total || metrics.SuccessfulRequests != total/2 || metrics.NetworkErrors != total ||
+		metrics.TotalConnections != total || metrics.ReconnectAttempts != total || metrics.HeartbeatFailureStreak != total ||
+		metrics.TotalBytesSent != 10*total || metrics.TotalBytesReceived != 20*total {
+		t.Fatalf("metrics after the writers = %+v", metrics)
+	}
+	if metrics.CPUUsage != 0.1 || metrics.DiskUsage != 0.3 {
+		t.Fatalf("system sample = %v cpu, %v disk", metrics.CPUUsage, metrics.DiskUsage)
+	}
+
+	// O snapshot não compartilha ResponseTimes com o monitor
+	metrics.ResponseTimes[0] = time.Hour
+	if m.GetMetrics().ResponseTimes[0] == time.Hour {
+		t.Fatal("snapshot aliases the response times")
+	}
+}
+
+func TestMonitorMessagesPerSecond(t *testing.T) {
+	m := NewMonitor(MonitorConfig{Logger: testLogger(t)})
+
+	// Antes da primeira atualização, a base é o início do monitor
+	m.startedAt = time.Now().Add(-10 * time.Second)
+	for i := 0; i < 20; i++ {
+		m.RecordRequest(time.Millisecond, true)
+	}
+	m.updateMetrics()
+	if rate := m.GetMetrics().MessagesPerSecond; math.Abs(rate-2) > 0.1 {
+		t.Fatalf("rate since start = %.3f, want about 2", rate)
+	}
+
+	// Depois, só os requests desde a atualização anterior
+	m.metricsMutex.Lock()
+	m.metrics.LastUpdated = time.Now().Add(-5 * time.Second)
+	m.metricsMutex.Unlock()
+	for i := 0; i < 5; i++ {
+		m.RecordRequest(time.Millisecond, true)
+	}
+	m.updateMetrics()
+	if rate := m.GetMetrics().MessagesPerSecond; math.Abs(rate-1) > 0.1 {
+		t.Fatalf("rate since the last update = %.3f, want about 1", rate)
+	}
+
+	m.metricsMutex.Lock()
+	m.metrics.LastUpdated = time.Now().Add(-5 * time.Second)
+	m.metricsMutex.Unlock()
+	m.updateMetrics()
+	if rate := m.GetMetrics().MessagesPerSecond; rate != 0 {
+		t.Fatalf("rate without requests = %.3f", rate)
+	}
+}
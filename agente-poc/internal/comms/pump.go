@@ -0,0 +1,277 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Padrões dos pumps do WebSocket
+const (
+	// DefaultWSWriteBuffer é quantos quadros podem aguardar o write pump
+	DefaultWSWriteBuffer = 256
+	// wsWriteWait é o prazo de escrita de um quadro
+	wsWriteWait = 30 * time.Second
+	// wsDrainTimeout é quanto o Disconnect espera o write pump escrever os
+	// quadros já no buffer antes de fechar a conexão
+	wsDrainTimeout = 5 * time.Second
+	// wsMinReadTimeout é o menor prazo sem receber nada antes de a conexão
+	// ser dada como perdida
+	wsMinReadTimeout = 60 * time.Second
+)
+
+// ErrWriteBufferFull indica que o buffer do write pump está cheio; a
+// mensagem não foi enviada nem ficou na fila
+var ErrWriteBufferFull = errors.New("websocket write buffer full")
+
+// wsFrame é um quadro aguardando o write pump
+type wsFrame struct {
+	data []byte
+	// message volta para a fila offline se o quadro for abandonado (conexão
+	// perdida antes da escrita); nil descarta
+	message *WebSocketMessage
+}
+
+// wsSession é uma conexão e seus pumps. O gorilla/websocket aceita um
+// leitor e um escritor por vez: só o read pump chama ReadMessage e só o
+// write pump escreve na conexão; os demais enfileiram quadros em send.
+type wsSession struct {
+	conn *websocket.Conn
+	send chan wsFrame
+
+	// mu protege closed: enqueue trava para leitura, e ao encerrar o write
+	// pump trava para escrita antes de esvaziar send, então nenhum quadro
+	// entra depois disso
+	mu     sync.RWMutex
+	closed bool
+
+	// closing é fechado por stop; drain indica se o write pump deve escrever
+	// os quadros restantes antes de sair (Disconnect) ou abandoná-los (queda)
+	closing  chan struct{}
+	stopOnce sync.Once
+	drain    bool
+
+	// done é fechado quando o write pump termina
+	done chan struct{}
+
+	// Com flushing (sob handoffMu), o write pump entrega a sendQueuedMessages
+	// as mensagens que abandonar, em abandoned, em vez de devolvê-las à fila
+	handoffMu sync.Mutex
+	flushing  bool
+	abandoned []WebSocketMessage
+}
+
+// newWSSession cria a sessão de uma conexão recém-estabelecida
+func newWSSession(conn *websocket.Conn, bufferSize int) *wsSession {
+	if bufferSize <= 0 {
+		bufferSize = DefaultWSWriteBuffer
+	}
+	return &wsSession{
+		conn:    conn,
+		send:    make(chan wsFrame, bufferSize),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// enqueue entrega um quadro ao write pump. Sem wait, retorna
+// ErrWriteBufferFull com o buffer cheio; com wait, espera espaço. Uma
+// sessão encerrada retorna errNotConnected.
+func (s *wsSession) enqueue(frame wsFrame, wait bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return errNotConnected
+	}
+	if wait {
+		select {
+		case s.send <- frame:
+			return nil
+		case <-s.closing:
+			return errNotConnected
+		}
+	}
+	select {
+	case s.send <- frame:
+		return nil
+	case <-s.closing:
+		return errNotConnected
+	default:
+		return ErrWriteBufferFull
+	}
+}
+
+// stop encerra a sessão; só a primeira chamada vale
+func (s *wsSession) stop(drain bool) {
+	s.stopOnce.Do(func() {
+		s.drain = drain
+		close(s.closing)
+	})
+}
+
+// beginFlush passa ao flush as mensagens abandonadas daqui em diante
+func (s *wsSession) beginFlush() {
+	s.handoffMu.Lock()
+	defer s.handoffMu.Unlock()
+	s.flushing = true
+}
+
+// endFlush devolve ao write pump a responsabilidade pelas mensagens
+// abandonadas e retorna as que ele entregou durante o flush
+func (s *wsSession) endFlush() []WebSocketMessage {
+	s.handoffMu.Lock()
+	defer s.handoffMu.Unlock()
+	s.flushing = false
+	abandoned := s.abandoned
+	s.abandoned = nil
+	return abandoned
+}
+
+// handOff guarda as mensagens abandonadas para o flush em andamento;
+// retorna false sem flush, e quem abandonou as devolve à fila
+func (s *wsSession) handOff(messages []WebSocketMessage) bool {
+	s.handoffMu.Lock()
+	defer s.handoffMu.Unlock()
+	if !s.flushing {
+		return false
+	}
+	s.abandoned = append(s.abandoned, messages...)
+	return true
+}
+
+// seal impede novos quadros; enqueues em andamento terminam antes (closing
+// já fechado acorda os que esperam espaço)
+func (s *wsSession) seal() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+// enqueueMessage serializa uma mensagem e a entrega ao write pump da
+// conexão atual. Com requeue, a mensagem volta para a fila offline se a
+// conexão cair antes da escrita.
+func (ws *WebSocketClient) enqueueMessage(message WebSocketMessage, requeue, wait bool) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	frame := wsFrame{data: data}
+	if requeue {
+		frame.message = &message
+	}
+	return ws.enqueueFrame(frame, wait)
+}
+
+// enqueueFrame entrega um quadro ao write pump da conexão atual
+func (ws *WebSocketClient) enqueueFrame(frame wsFrame, wait bool) error {
+	ws.connMutex.RLock()
+	session := ws.session
+	ws.connMutex.RUnlock()
+
+	if session == nil {
+		return errNotConnected
+	}
+	err := session.enqueue(frame, wait)
+	if errors.Is(err, ErrWriteBufferFull) {
+		ws.updateMetrics(func(m *WebSocketMetrics) { m.WriteBufferFull++ })
+	}
+	return err
+}
+
+// writePump é o único escritor da conexão. Um erro de escrita encerra a
+// sessão, abandona os quadros restantes e fecha a conexão (o read pump
+// detecta e inicia a reconexão); stop(true) escreve os do buffer antes de
+// sair.
+func (ws *WebSocketClient) writePump(s *wsSession) {
+	defer close(s.done)
+
+	for {
+		select {
+		case frame := <-s.send:
+			if err := ws.writeFrame(s.conn, frame, time.Now().Add(wsWriteWait)); err != nil {
+				ws.logger.Error("Error writing WebSocket message: %v", err)
+				s.stop(false)
+				s.seal()
+				_ = s.conn.Close()
+				ws.abandonFrames(s, &frame)
+				return
+			}
+		case <-s.closing:
+			s.seal()
+			if s.drain {
+				ws.drainFrames(s)
+			}
+			ws.abandonFrames(s, nil)
+			return
+		}
+	}
+}
+
+// writeFrame escreve um quadro com o prazo informado
+func (ws *WebSocketClient) writeFrame(conn *websocket.Conn, frame wsFrame, deadline time.Time) error {
+	_ = conn.SetWriteDeadline(deadline)
+	if err := conn.WriteMessage(websocket.TextMessage, frame.data); err != nil {
+		ws.updateMetrics(func(m *WebSocketMetrics) { m.MessageErrors++ })
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.MessagesSent++ })
+	return nil
+}
+
+// drainFrames escreve os quadros do buffer até wsDrainTimeout; o primeiro
+// erro interrompe (os restantes são abandonados em seguida)
+func (ws *WebSocketClient) drainFrames(s *wsSession) {
+	deadline := time.Now().Add(wsDrainTimeout)
+	for len(s.send) > 0 {
+		frame := <-s.send
+		if err := ws.writeFrame(s.conn, frame, deadline); err != nil {
+			ws.logger.Warning("Failed to flush WebSocket write buffer on disconnect: %v", err)
+			ws.abandonFrames(s, &frame)
+			return
+		}
+	}
+}
+
+// abandonFrames esvazia o buffer de uma sessão encerrada (e o quadro cuja
+// escrita falhou, se houver): os que têm mensagem voltam, em ordem, para a
+// frente da fila offline, ou vão para o flush em andamento, que as devolve
+// junto com o resto (ver sendQueuedMessages); os demais (acks, pongs,
+// retransmissões, que têm outro caminho) são descartados
+func (ws *WebSocketClient) abandonFrames(s *wsSession, failed *wsFrame) {
+	var frames []wsFrame
+	if failed != nil {
+		frames = append(frames, *failed)
+	}
+	// Selada, a sessão não recebe quadros novos e só este pump lê send
+	for len(s.send) > 0 {
+		frames = append(frames, <-s.send)
+	}
+
+	var requeue []WebSocketMessage
+	for _, frame := range frames {
+		if frame.message != nil {
+			requeue = append(requeue, *frame.message)
+		}
+	}
+	if len(requeue) > 0 && !s.handOff(requeue) {
+		ws.requeueMessages(requeue)
+	}
+	if len(frames) > 0 {
+		ws.logger.Debug("WebSocket write buffer abandoned: %d frame(s), %d requeued", len(frames), len(requeue))
+	}
+}
+
+// readTimeout é o prazo sem receber nada (nem pong) antes de dar a conexão
+// como perdida: dois intervalos de ping, no mínimo wsMinReadTimeout
+func (ws *WebSocketClient) readTimeout() time.Duration {
+	timeout := 2*ws.pingInterval + ws.pongTimeout
+	if timeout < wsMinReadTimeout {
+		timeout = wsMinReadTimeout
+	}
+	return timeout
+}
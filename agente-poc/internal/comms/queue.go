@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"agente-poc/internal/clock"
 	"agente-poc/internal/logging"
 )
 
@@ -19,6 +20,8 @@ type MessageQueue struct {
 	maxSize     int
 	persistPath string
 	metrics     *QueueMetrics
+	clock       clock.Clock
+	sealer      *EnvelopeSealer
 }
 
 // QueuedMessage represents a queued message with metadata
@@ -36,6 +39,14 @@ type QueuedMessage struct {
 	Headers     map[string]string      `json:"headers"`
 	LastError   string                 `json:"last_error,omitempty"`
 	LastAttempt time.Time              `json:"last_attempt,omitempty"`
+	// IdempotencyKey vai no cabeçalho Idempotency-Key de cada reenvio (e no
+	// corpo, campo idempotency_key); inventários e resultados de comando
+	// sempre têm uma (ver ensureIdempotencyKey)
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Envelope substitui Data no modo envelope: a mensagem já entra cifrada
+	// na fila, e o texto claro nunca é gravado em disco
+	Envelope *Envelope `json:"envelope,omitempty"`
 }
 
 // QueueMetrics tracks queue performance metrics
@@ -56,8 +67,11 @@ type QueueMetrics struct {
 // QueueConfig configuration for message queue
 type QueueConfig struct {
 	MaxSize     int
-	PersistPath string
+	PersistPath string // arquivo da fila em disco; vazio mantém a fila só em memória
 	Logger      logging.Logger
+	Clock       clock.Clock // nil = system clock
+	// Sealer cifra Data de cada mensagem ao enfileirar (nil = sem envelope)
+	Sealer *EnvelopeSealer
 }
 
 // NewMessageQueue creates a new message queue
@@ -70,16 +84,14 @@ func NewMessageQueue(config QueueConfig) (*MessageQueue, error) {
 		config.MaxSize = 10000
 	}
 
-	if config.PersistPath == "" {
-		config.PersistPath = "/tmp/agent_queue.json"
-	}
-
 	queue := &MessageQueue{
 		messages:    make([]QueuedMessage, 0),
 		logger:      config.Logger,
 		maxSize:     config.MaxSize,
 		persistPath: config.PersistPath,
 		metrics:     &QueueMetrics{MaxQueueSize: int64(config.MaxSize)},
+		clock:       clock.OrReal(config.Clock),
+		sealer:      config.Sealer,
 	}
 
 	// Try to load existing messages
@@ -93,6 +105,19 @@ func NewMessageQueue(config QueueConfig) (*MessageQueue, error) {
 
 // Enqueue adds a message to the queue
 func (q *MessageQueue) Enqueue(message QueuedMessage) error {
+	// A chave entra no corpo antes da cifra
+	ensureIdempotencyKey(&message)
+
+	// No modo envelope, cifrar antes de a mensagem tocar a fila (e o disco)
+	if q.sealer != nil && message.Data != nil {
+		envelope, err := q.sealer.SealValue(message.Data)
+		if err != nil {
+			return fmt.Errorf("failed to seal queued message: %w", err)
+		}
+		message.Envelope = envelope
+		message.Data = nil
+	}
+
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
@@ -103,14 +128,15 @@ func (q *MessageQueue) Enqueue(message QueuedMessage) error {
 	}
 
 	// Set defaults
+	now := q.clock.Now()
 	if message.ID == "" {
-		message.ID = fmt.Sprintf("msg_%d", time.Now().UnixNano())
+		message.ID = fmt.Sprintf("msg_%d", now.UnixNano())
 	}
 	if message.Timestamp.IsZero() {
-		message.Timestamp = time.Now()
+		message.Timestamp = now
 	}
 	if message.ExpiresAt.IsZero() {
-		message.ExpiresAt = time.Now().Add(24 * time.Hour)
+		message.ExpiresAt = now.Add(24 * time.Hour)
 	}
 	if message.MaxRetries == 0 {
 		message.MaxRetries = 3
@@ -146,6 +172,12 @@ func (q *MessageQueue) Enqueue(message QueuedMessage) error {
 
 // Dequeue removes and returns the highest priority message
 func (q *MessageQueue) Dequeue() (*QueuedMessage, error) {
+	return q.DequeueWhere(nil)
+}
+
+// DequeueWhere retira a mensagem de maior prioridade aceita por eligible
+// (nil aceita todas); as recusadas ficam na fila, na mesma posição
+func (q *MessageQueue) DequeueWhere(eligible func(message *QueuedMessage) bool) (*QueuedMessage, error) {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
@@ -161,11 +193,18 @@ func (q *MessageQueue) Dequeue() (*QueuedMessage, error) {
 	}
 
 	// Get highest priority message
-	message := q.messages[0]
-	q.messages = q.messages[1:]
+	index := 0
+	for eligible != nil && index < len(q.messages) && !eligible(&q.messages[index]) {
+		index++
+	}
+	if index == len(q.messages) {
+		return nil, fmt.Errorf("no eligible messages in queue")
+	}
+	message := q.messages[index]
+	q.messages = append(q.messages[:index], q.messages[index+1:]...)
 
 	q.metrics.QueueSize = int64(len(q.messages))
-	q.metrics.LastProcessTime = time.Now()
+	q.metrics.LastProcessTime = q.clock.Now()
 
 	q.logger.Debug("Message dequeued: %s", message.ID)
 
@@ -191,19 +230,18 @@ func (q *MessageQueue) Requeue(message QueuedMessage, err error) error {
 
 	message.Retries++
 	message.LastError = err.Error()
-	message.LastAttempt = time.Now()
+	message.LastAttempt = q.clock.Now()
 
 	if message.Retries >= message.MaxRetries {
 		q.logger.Warning("Message %s exceeded max retries, dropping", message.ID)
 		q.metrics.FailedMessages++
+		q.persist()
 		return fmt.Errorf("message exceeded max retries")
 	}
 
-	// Calculate backoff delay
-	delay := time.Duration(message.Retries) * time.Second
-	message.Timestamp = time.Now().Add(delay)
-
-	// Insert back with updated timestamp
+	// Volta para a posição original (Timestamp é o momento do enfileiramento),
+	// para que mensagens do mesmo tipo continuem em ordem de criação; a
+	// espera entre tentativas fica com quem drena a fila
 	inserted := false
 	for i, existing := range q.messages {
 		if message.Priority > existing.Priority ||
@@ -223,9 +261,21 @@ func (q *MessageQueue) Requeue(message QueuedMessage, err error) error {
 
 	q.logger.Debug("Message requeued: %s (retry: %d/%d)", message.ID, message.Retries, message.MaxRetries)
 
+	q.persist()
 	return nil
 }
 
+// Drop descarta uma mensagem já retirada da fila que não deve ser reenviada
+// (ex.: recusada pelo backend com 4xx)
+func (q *MessageQueue) Drop(message QueuedMessage, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.metrics.FailedMessages++
+	q.logger.Warning("Message %s dropped: %v", message.ID, err)
+	q.persist()
+}
+
 // MarkProcessed marks a message as successfully processed
 func (q *MessageQueue) MarkProcessed(messageID string) {
 	q.mutex.Lock()
@@ -235,6 +285,11 @@ func (q *MessageQueue) MarkProcessed(messageID string) {
 	q.logger.Debug("Message marked as processed: %s", messageID)
 
 	// Persist updated state
+	q.persist()
+}
+
+// persist grava a fila e contabiliza a falha; chamado com o mutex adquirido
+func (q *MessageQueue) persist() {
 	if err := q.saveToDisk(); err != nil {
 		q.logger.Error("Failed to persist queue to disk: %v", err)
 		q.metrics.PersistErrors++
@@ -268,9 +323,10 @@ func (q *MessageQueue) GetMetrics() QueueMetrics {
 	return *q.metrics
 }
 
-// removeExpiredMessages removes expired messages from the queue
+// removeExpiredMessages removes expired messages from the queue.
+// ExpiresAt is wall-clock time because it survives restarts on disk.
 func (q *MessageQueue) removeExpiredMessages() {
-	now := time.Now()
+	now := q.clock.Now()
 	validMessages := make([]QueuedMessage, 0, len(q.messages))
 
 	for _, message := range q.messages {
@@ -360,6 +416,24 @@ func (q *MessageQueue) loadFromDisk() error {
 		return fmt.Errorf("failed to unmarshal queue data: %w", err)
 	}
 
+	// Normalize messages written by older agent versions: chave de
+	// idempotência ausente e status desconhecidos
+	keyed := false
+	for i := range messages {
+		if ensureIdempotencyKey(&messages[i]) {
+			keyed = true
+		}
+		if messages[i].Type != "command_result" {
+			continue
+		}
+		if raw, ok := messages[i].Data["status"].(string); ok {
+			if status := ParseCommandStatus(raw); string(status) != raw {
+				q.logger.Warning("Unknown command status %q in queued message %s, using %q", raw, messages[i].ID, status)
+				messages[i].Data["status"] = status
+			}
+		}
+	}
+
 	q.messages = messages
 	q.metrics.QueueSize = int64(len(q.messages))
 
@@ -368,22 +442,32 @@ func (q *MessageQueue) loadFromDisk() error {
 	// Remove expired messages
 	q.removeExpiredMessages()
 
+	// Gravar as chaves novas para que um novo reinício não troque de chave
+	if keyed {
+		q.persist()
+	}
+
 	return nil
 }
 
 // CreateHeartbeatMessage creates a heartbeat message for the queue
 func CreateHeartbeatMessage(data HeartbeatData) QueuedMessage {
+	return newHeartbeatMessage(map[string]interface{}{
+		"machine_id":    data.MachineID,
+		"timestamp":     data.Timestamp,
+		"status":        data.Status,
+		"agent_version": data.AgentVersion,
+		"uptime":        data.Uptime,
+		"system_health": data.SystemHealth,
+	})
+}
+
+// newHeartbeatMessage enfileira um corpo de heartbeat já montado
+func newHeartbeatMessage(body map[string]interface{}) QueuedMessage {
 	return QueuedMessage{
-		Type:     "heartbeat",
-		Priority: 5, // Medium priority
-		Data: map[string]interface{}{
-			"machine_id":    data.MachineID,
-			"timestamp":     data.Timestamp,
-			"status":        data.Status,
-			"agent_version": data.AgentVersion,
-			"uptime":        data.Uptime,
-			"system_health": data.SystemHealth,
-		},
+		Type:       "heartbeat",
+		Priority:   5, // Medium priority
+		Data:       body,
 		Endpoint:   "/heartbeat",
 		Method:     "POST",
 		MaxRetries: 3,
@@ -393,18 +477,57 @@ func CreateHeartbeatMessage(data HeartbeatData) QueuedMessage {
 
 // CreateInventoryMessage creates an inventory message for the queue
 func CreateInventoryMessage(data InventoryMessage) QueuedMessage {
+	return newInventoryMessage(map[string]interface{}{
+		"machine_id": data.MachineID,
+		"timestamp":  data.Timestamp,
+		"data":       data.Data,
+		"checksum":   data.Checksum,
+	})
+}
+
+// newInventoryMessage enfileira um corpo de inventário já montado; a chave de
+// idempotência vem do campo idempotency_key do corpo
+func newInventoryMessage(body map[string]interface{}) QueuedMessage {
+	key, _ := body["idempotency_key"].(string)
 	return QueuedMessage{
-		Type:     "inventory",
-		Priority: 8, // High priority
-		Data: map[string]interface{}{
-			"machine_id": data.MachineID,
-			"timestamp":  data.Timestamp,
-			"data":       data.Data,
-			"checksum":   data.Checksum,
-		},
-		Endpoint:   "/inventory",
+		IdempotencyKey: key,
+		Type:           "inventory",
+		Priority:       8, // High priority
+		Data:           body,
+		Endpoint:       "/inventory",
+		Method:         "POST",
+		MaxRetries:     5,
+		ExpiresAt:      time.Now().Add(1 * time.Hour),
+	}
+}
+
+// commandResultMessage enfileira um resultado com todos os campos que ele
+// teria no envio direto (CreateCommandResultMessage leva só os principais)
+func commandResultMessage(result *CommandResult) QueuedMessage {
+	message := CreateCommandResultMessage(*result)
+	if raw, err := json.Marshal(result); err == nil {
+		var body map[string]interface{}
+		if json.Unmarshal(raw, &body) == nil {
+			message.Data = body
+		}
+	}
+	return message
+}
+
+// newAlertMessage enfileira um alerta local com a prioridade dos resultados
+// de comando, para sair logo que o backend voltar
+func newAlertMessage(alert Alert) QueuedMessage {
+	var body map[string]interface{}
+	if raw, err := json.Marshal(alert); err == nil {
+		_ = json.Unmarshal(raw, &body)
+	}
+	return QueuedMessage{
+		Type:       "alert",
+		Priority:   9, // Very high priority
+		Data:       body,
+		Endpoint:   "/alerts",
 		Method:     "POST",
-		MaxRetries: 5,
+		MaxRetries: 3,
 		ExpiresAt:  time.Now().Add(1 * time.Hour),
 	}
 }
@@ -412,17 +535,19 @@ func CreateInventoryMessage(data InventoryMessage) QueuedMessage {
 // CreateCommandResultMessage creates a command result message for the queue
 func CreateCommandResultMessage(result CommandResult) QueuedMessage {
 	return QueuedMessage{
-		Type:     "command_result",
-		Priority: 9, // Very high priority
+		IdempotencyKey: result.IdempotencyKey,
+		Type:           "command_result",
+		Priority:       9, // Very high priority
 		Data: map[string]interface{}{
-			"id":             result.ID,
-			"command_id":     result.CommandID,
-			"status":         result.Status,
-			"output":         result.Output,
-			"error":          result.Error,
-			"exit_code":      result.ExitCode,
-			"execution_time": result.ExecutionTime,
-			"timestamp":      result.Timestamp,
+			"id":              result.ID,
+			"command_id":      result.CommandID,
+			"status":          result.Status,
+			"output":          result.Output,
+			"error":           result.Error,
+			"exit_code":       result.ExitCode,
+			"execution_time":  result.ExecutionTime,
+			"timestamp":       result.Timestamp,
+			"idempotency_key": result.IdempotencyKey,
 		},
 		Endpoint:   "/commands/result",
 		Method:     "POST",
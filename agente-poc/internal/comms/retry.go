@@ -0,0 +1,92 @@
+package comms
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Padrões das retentativas HTTP quando HTTPConfig não define outros
+const (
+	DefaultHTTPMaxRetries    = 3
+	DefaultHTTPRetryDelay    = time.Second
+	DefaultHTTPMaxRetryDelay = 30 * time.Second
+	// DefaultHTTPRetryBudget limita o tempo total de uma requisição com suas
+	// retentativas, para que um backend sobrecarregado não prenda o loop de
+	// heartbeat
+	DefaultHTTPRetryBudget = time.Minute
+)
+
+// parseRetryAfter interpreta o cabeçalho Retry-After nas duas formas da
+// RFC 9110: segundos ("120") ou data HTTP ("Wed, 21 Oct 2015 07:28:00 GMT").
+// Uma data já passada vale zero; ok é false sem cabeçalho ou com valor
+// inválido.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// retryableStatus indica as respostas que valem nova tentativa: 429 (o
+// backend pede para esperar) e erros 5xx
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay é a espera antes da tentativa seguinte à falha attempt (a
+// partir de 0). Em 429 e 503 vale o Retry-After do backend, mesmo acima de
+// maxRetryDelay; sem ele (ou em falhas de rede, resp nil), backoff
+// exponencial com jitter a partir de retryDelay. fromHeader indica se a
+// espera veio do Retry-After.
+func (c *HTTPClient) retryDelay(attempt int, resp *http.Response) (delay time.Duration, fromHeader bool) {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), c.clock.Now()); ok {
+			return delay, true
+		}
+	}
+	return backoffDelay(c.retryBaseDelay, c.maxRetryDelay, attempt, c.jitter()), false
+}
+
+// canRetry indica se cabe mais uma tentativa após a falha attempt: dentro
+// de maxRetries e sem que a espera estoure o orçamento iniciado em start
+func (c *HTTPClient) canRetry(attempt int, start time.Time, delay time.Duration) bool {
+	if attempt >= c.maxRetries {
+		return false
+	}
+	if c.clock.Since(start)+delay > c.retryBudget {
+		c.logger.WithFields(map[string]interface{}{
+			"attempt": attempt + 1,
+			"delay":   delay,
+			"budget":  c.retryBudget,
+		}).Warning("HTTP retry budget exhausted, giving up")
+		return false
+	}
+	return true
+}
+
+// waitRetry espera delay no relógio do cliente, ou até o contexto acabar
+func (c *HTTPClient) waitRetry(ctx context.Context, delay time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.clock.After(delay):
+		return nil
+	}
+}
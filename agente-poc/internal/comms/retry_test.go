@@ -0,0 +1,263 @@
+package comms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// retryServer responde com status[i] na i-ésima requisição (o último se
+// repete) e Retry-After[i] quando não vazio; 200 responde {}
+type retryServer struct {
+	server *httptest.Server
+	hits   atomic.Int64
+}
+
+func newRetryServer(t *testing.T, statuses []int, retryAfter []string) *retryServer {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	backend := &retryServer{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(backend.hits.Add(1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		if i < len(retryAfter) && retryAfter[i] != "" {
+			w.Header().Set("Retry-After", retryAfter[i])
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statuses[i])
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+// newRetryTestClient cria um cliente no relógio falso e sem jitter, para
+// que cada espera seja exata
+func newRetryTestClient(t *testing.T, backend *retryServer, fake *clock.Fake, configure func(*HTTPConfig)) *HTTPClient {
+	t.Helper()
+	config := HTTPConfig{BaseURL: backend.server.URL, RetryDelay: time.Second, Clock: fake, Logger: testLogger(t)}
+	if configure != nil {
+		configure(&config)
+	}
+	client, err := NewHTTPClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.jitter = func() float64 { return 0 }
+	return client
+}
+
+// getAsync faz o GET em segundo plano; o canal recebe o erro ao terminar
+func getAsync(client *HTTPClient) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		var response map[string]interface{}
+		done <- client.GET(context.Background(), "/api/status", &response)
+	}()
+	return done
+}
+
+// advanceRetry confere que a requisição espera exatamente delay: um
+// instante antes ainda espera, e no prazo a próxima tentativa sai
+func advanceRetry(t *testing.T, fake *clock.Fake, backend *retryServer, delay time.Duration) {
+	t.Helper()
+	hits := backend.hits.Load()
+	waitFor(t, "the retry timer", 2*time.Second, func() bool { return fake.Pending() == 1 })
+	fake.Advance(delay - time.Millisecond)
+	if fake.Pending() != 1 {
+		t.Fatalf("retried before %s", delay)
+	}
+	fake.Advance(time.Millisecond)
+	waitFor(t, "the retry", 2*time.Second, func() bool { return backend.hits.Load() == hits+1 })
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "120", want: 2 * time.Minute, wantOK: true},
+		{value: " 0 ", want: 0, wantOK: true},
+		{value: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOK: true},
+		// Data já passada: tentar de novo sem esperar
+		{value: now.Add(-time.Hour).Format(http.TimeFormat), want: 0, wantOK: true},
+		{value: ""},
+		{value: "-5"},
+		{value: "soon"},
+		{value: "2026-03-29T00:31:00Z"},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	fake := newTestClock()
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wait       time.Duration
+	}{
+		{name: "429 seconds", status: http.StatusTooManyRequests, retryAfter: "30", wait: 30 * time.Second},
+		// O Retry-After vale mesmo acima do teto do backoff
+		{name: "429 above the backoff cap", status: http.StatusTooManyRequests, retryAfter: "45", wait: 45 * time.Second},
+		{name: "503 HTTP date", status: http.StatusServiceUnavailable, retryAfter: fake.Now().Add(20 * time.Second).Format(http.TimeFormat), wait: 20 * time.Second},
+		// Sem cabeçalho, o backoff a partir de RetryDelay
+		{name: "429 without header", status: http.StatusTooManyRequests, wait: 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Set(time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC))
+			backend := newRetryServer(t, []int{tt.status, http.StatusOK}, []string{tt.retryAfter})
+			client := newRetryTestClient(t, backend, fake, nil)
+
+			done := getAsync(client)
+			advanceRetry(t, fake, backend, tt.wait)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+
+			metrics := client.GetMetrics()
+			wantRateLimited, wantServer := int64(1), int64(0)
+			if tt.status != http.StatusTooManyRequests {
+				wantRateLimited, wantServer = 0, 1
+			}
+			if metrics.RetryCount != 1 || metrics.RateLimitedRetries != wantRateLimited || metrics.ServerErrorRetries != wantServer || metrics.SuccessRequests != 1 {
+				t.Fatalf("metrics = %+v", metrics)
+			}
+		})
+	}
+}
+
+func TestRetryServerErrorBackoff(t *testing.T) {
+	fake := newTestClock()
+	backend := newRetryServer(t, []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusInternalServerError, http.StatusOK}, nil)
+	client := newRetryTestClient(t, backend, fake, func(c *HTTPConfig) { c.MaxRetryDelay = 1500 * time.Millisecond })
+
+	// Exponencial a partir de RetryDelay, limitado por MaxRetryDelay
+	done := getAsync(client)
+	for _, wait := range []time.Duration{500 * time.Millisecond, 750 * time.Millisecond, 750 * time.Millisecond} {
+		advanceRetry(t, fake, backend, wait)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if metrics := client.GetMetrics(); metrics.ServerErrorRetries != 3 || metrics.RateLimitedRetries != 0 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestRetryLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter []string
+		configure  func(*HTTPConfig)
+		waits      []time.Duration
+		wantHits   int64
+		wantStatus int
+	}{
+		{
+			name:       "max retries from the config",
+			statuses:   []int{http.StatusInternalServerError},
+			configure:  func(c *HTTPConfig) { c.MaxRetries = 2 },
+			waits:      []time.Duration{500 * time.Millisecond, time.Second},
+			wantHits:   3,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "retries disabled",
+			statuses:   []int{http.StatusTooManyRequests},
+			retryAfter: []string{"1"},
+			configure:  func(c *HTTPConfig) { c.MaxRetries = -1 },
+			wantHits:   1,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			// Esperar 1h estouraria o orçamento: desiste na hora
+			name:       "Retry-After beyond the budget",
+			statuses:   []int{http.StatusTooManyRequests},
+			retryAfter: []string{"3600"},
+			configure:  func(c *HTTPConfig) { c.RetryBudget = time.Minute },
+			wantHits:   1,
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "budget spent by earlier waits",
+			statuses:   []int{http.StatusServiceUnavailable},
+			retryAfter: []string{"40", "40"},
+			configure:  func(c *HTTPConfig) { c.RetryBudget = time.Minute; c.MaxRetries = 5 },
+			waits:      []time.Duration{40 * time.Second},
+			wantHits:   2,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "client errors are final",
+			statuses:   []int{http.StatusBadRequest},
+			wantHits:   1,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestClock()
+			backend := newRetryServer(t, tt.statuses, tt.retryAfter)
+			client := newRetryTestClient(t, backend, fake, tt.configure)
+
+			done := getAsync(client)
+			for _, wait := range tt.waits {
+				advanceRetry(t, fake, backend, wait)
+			}
+			err := <-done
+			var statusErr *HTTPStatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Fatalf("error = %v, want HTTP %d", err, tt.wantStatus)
+			}
+			if hits := backend.hits.Load(); hits != tt.wantHits {
+				t.Fatalf("%d requests, want %d", hits, tt.wantHits)
+			}
+			if len(tt.retryAfter) > 0 {
+				want, _ := strconv.Atoi(tt.retryAfter[len(tt.retryAfter)-1])
+				if statusErr.RetryAfter != time.Duration(want)*time.Second {
+					t.Fatalf("RetryAfter = %s", statusErr.RetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryWaitCancelled(t *testing.T) {
+	fake := newTestClock()
+	backend := newRetryServer(t, []int{http.StatusTooManyRequests}, []string{"30"})
+	client := newRetryTestClient(t, backend, fake, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		var response map[string]interface{}
+		done <- client.GET(ctx, "/api/status", &response)
+	}()
+	waitFor(t, "the retry timer", 2*time.Second, func() bool { return fake.Pending() == 1 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v", err)
+	}
+	if hits := backend.hits.Load(); hits != 1 {
+		t.Fatalf("%d requests after cancelling the wait", hits)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -15,6 +16,7 @@ import (
 	"sync"
 	"time"
 
+	"agente-poc/internal/clock"
 	"agente-poc/internal/logging"
 )
 
@@ -26,6 +28,7 @@ type SecurityManager struct {
 	rateLimiter    *RateLimiter
 	inputSanitizer *InputSanitizer
 	config         SecurityConfig
+	clock          clock.Clock
 }
 
 // SecurityConfig configuration for security manager
@@ -39,6 +42,7 @@ type SecurityConfig struct {
 	PinnedCertificates   []string
 	AllowedHosts         []string
 	Logger               logging.Logger
+	Clock                clock.Clock // nil = relógio do sistema
 }
 
 // TokenManager manages authentication tokens
@@ -74,6 +78,7 @@ type RateLimiter struct {
 	windowSize  time.Duration
 	maxRequests int
 	logger      logging.Logger
+	clock       clock.Clock
 }
 
 // RequestTracker tracks requests for rate limiting
@@ -119,6 +124,7 @@ func NewSecurityManager(config SecurityConfig) *SecurityManager {
 	if config.TLSMinVersion == 0 {
 		config.TLSMinVersion = tls.VersionTLS12
 	}
+	config.Clock = clock.OrReal(config.Clock)
 
 	tokenManager := &TokenManager{
 		tokens:         make(map[string]*Token),
@@ -138,6 +144,7 @@ func NewSecurityManager(config SecurityConfig) *SecurityManager {
 		windowSize:  config.RateLimitWindow,
 		maxRequests: config.MaxRequestsPerWindow,
 		logger:      config.Logger,
+		clock:       config.Clock,
 	}
 
 	inputSanitizer := &InputSanitizer{
@@ -151,6 +158,7 @@ func NewSecurityManager(config SecurityConfig) *SecurityManager {
 		rateLimiter:    rateLimiter,
 		inputSanitizer: inputSanitizer,
 		config:         config,
+		clock:          config.Clock,
 	}
 }
 
@@ -163,7 +171,7 @@ func (sm *SecurityManager) GenerateToken(machineID string, scope []string) (*Tok
 	}
 
 	tokenValue := base64.URLEncoding.EncodeToString(tokenBytes)
-	now := time.Now()
+	now := sm.clock.Now()
 
 	token := &Token{
 		Value:        tokenValue,
@@ -192,16 +200,35 @@ func (sm *SecurityManager) ValidateToken(tokenValue string) (*Token, error) {
 		return nil, fmt.Errorf("token not found")
 	}
 
-	if time.Now().After(token.ExpiresAt) {
+	if sm.tokenManager.expired(token, sm.clock.Now()) {
 		sm.tokenManager.mutex.Lock()
 		delete(sm.tokenManager.tokens, tokenValue)
 		sm.tokenManager.mutex.Unlock()
-		return nil, fmt.Errorf("token expired")
+		return nil, ErrTokenExpired
 	}
 
 	return token, nil
 }
 
+// Errors returned when a token can no longer be refreshed
+var (
+	ErrTokenExpired      = errors.New("token expired")
+	ErrTokenRefreshLimit = errors.New("token refresh limit exceeded")
+)
+
+// CheckRefresh applies the RefreshToken rules to a token that is not kept in
+// this manager (e.g. the machine token issued by the backend): it must not
+// be expired and must be under the refresh limit
+func (sm *SecurityManager) CheckRefresh(token *Token) error {
+	if !token.ExpiresAt.IsZero() && sm.clock.Now().After(token.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	if token.RefreshCount >= sm.tokenManager.maxRefresh {
+		return ErrTokenRefreshLimit
+	}
+	return nil
+}
+
 // RefreshToken refreshes an authentication token
 func (sm *SecurityManager) RefreshToken(tokenValue string) (*Token, error) {
 	token, err := sm.ValidateToken(tokenValue)
@@ -209,20 +236,27 @@ func (sm *SecurityManager) RefreshToken(tokenValue string) (*Token, error) {
 		return nil, err
 	}
 
-	if token.RefreshCount >= sm.tokenManager.maxRefresh {
-		return nil, fmt.Errorf("token refresh limit exceeded")
+	if err := sm.CheckRefresh(token); err != nil {
+		return nil, err
 	}
 
 	sm.tokenManager.mutex.Lock()
 	defer sm.tokenManager.mutex.Unlock()
 
 	token.RefreshCount++
-	token.ExpiresAt = time.Now().Add(sm.tokenManager.validityPeriod)
+	token.ExpiresAt = sm.clock.Now().Add(sm.tokenManager.validityPeriod)
 
 	sm.logger.Debug("Token refreshed for machine: %s (refresh count: %d)", token.MachineID, token.RefreshCount)
 	return token, nil
 }
 
+// expired reports whether token is past ExpiresAt or the clock is before the
+// start of its current validity window (wall clock moved backwards), which
+// would otherwise extend the token by the size of the jump
+func (tm *TokenManager) expired(token *Token, now time.Time) bool {
+	return now.After(token.ExpiresAt) || now.Before(token.ExpiresAt.Add(-tm.validityPeriod))
+}
+
 // RevokeToken revokes an authentication token
 func (sm *SecurityManager) RevokeToken(tokenValue string) error {
 	sm.tokenManager.mutex.Lock()
@@ -334,20 +368,20 @@ func (sm *SecurityManager) CheckRateLimit(identifier string) error {
 		sm.rateLimiter.requests[identifier] = tracker
 	}
 
-	now := time.Now()
+	now := sm.rateLimiter.clock.Now()
 	windowStart := now.Add(-sm.rateLimiter.windowSize)
 
-	// Remove old requests
+	// Remove old requests (and ones "in the future" after the clock moved back)
 	validRequests := make([]time.Time, 0)
 	for _, reqTime := range tracker.Requests {
-		if reqTime.After(windowStart) {
+		if reqTime.After(windowStart) && !reqTime.After(now) {
 			validRequests = append(validRequests, reqTime)
 		}
 	}
 	tracker.Requests = validRequests
 
 	// Check if blocked
-	if tracker.Blocked && now.Sub(tracker.BlockedAt) < sm.rateLimiter.windowSize {
+	if tracker.Blocked && !clock.Expired(sm.rateLimiter.clock, tracker.BlockedAt, sm.rateLimiter.windowSize) {
 		return fmt.Errorf("rate limit exceeded, blocked until: %v", tracker.BlockedAt.Add(sm.rateLimiter.windowSize))
 	}
 
@@ -498,11 +532,11 @@ func (sm *SecurityManager) CleanupExpiredTokens() {
 	sm.tokenManager.mutex.Lock()
 	defer sm.tokenManager.mutex.Unlock()
 
-	now := time.Now()
+	now := sm.clock.Now()
 	expiredTokens := make([]string, 0)
 
 	for tokenValue, token := range sm.tokenManager.tokens {
-		if now.After(token.ExpiresAt) {
+		if sm.tokenManager.expired(token, now) {
 			expiredTokens = append(expiredTokens, tokenValue)
 		}
 	}
@@ -546,12 +580,12 @@ func (sm *SecurityManager) IsSecure() bool {
 // StartCleanupRoutine starts the cleanup routine for expired tokens
 func (sm *SecurityManager) StartCleanupRoutine() {
 	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
+		ticker := sm.clock.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
 
 		for {
 			select {
-			case <-ticker.C:
+			case <-ticker.C():
 				sm.CleanupExpiredTokens()
 			}
 		}
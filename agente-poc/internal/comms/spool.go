@@ -0,0 +1,144 @@
+package comms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrSpooled indica que o envio falhou por erro transitório e a mensagem
+// ficou na fila offline; o reenvio é do Manager, não de quem chamou
+var ErrSpooled = errors.New("message spooled for later delivery")
+
+// transientSendError indica se vale guardar a mensagem para reenviar:
+// falhas de rede, timeouts e 5xx/408/429. Recusas 4xx não mudam com o tempo.
+func transientSendError(err error) bool {
+	switch code := HTTPStatusCode(err); {
+	case code == 0:
+		return !errors.Is(err, ErrEnvelopeKeyUnavailable)
+	case code >= 500, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
+
+// spool guarda na fila offline uma mensagem cujo envio falhou e retorna o
+// erro a devolver a quem chamou: sendErr envolvido em ErrSpooled quando a
+// mensagem ficou na fila, sendErr sozinho caso contrário
+func (m *Manager) spool(message QueuedMessage, sendErr error) error {
+	if m.queue == nil || !transientSendError(sendErr) {
+		return sendErr
+	}
+	if err := m.queue.Enqueue(message); err != nil {
+		m.logger.WithFields(map[string]interface{}{
+			"type":  message.Type,
+			"error": err.Error(),
+		}).Warning("Failed to spool message for later delivery")
+		return sendErr
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"type":       message.Type,
+		"queue_size": m.queue.Size(),
+	}).Info("Backend unreachable, message spooled for later delivery")
+	return fmt.Errorf("%w: %v", ErrSpooled, sendErr)
+}
+
+// drainQueue reenvia a fila offline a cada QueueDrainInterval enquanto o
+// Manager estiver conectado
+func (m *Manager) drainQueue() {
+	ticker := m.clock.NewTicker(m.config.QueueDrainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C():
+			if m.IsConnected() {
+				m.replayQueued()
+			}
+		}
+	}
+}
+
+// replayQueued envia as mensagens da fila em ordem de prioridade (e, na
+// mesma prioridade, de criação). Na primeira falha transitória a mensagem
+// volta à fila e a rodada termina, já que o backend provavelmente caiu de novo.
+// Inventórios fora da janela de envio continuam na fila sem contar tentativa.
+func (m *Manager) replayQueued() {
+	for m.queue.Size() > 0 && m.ctx.Err() == nil {
+		message, err := m.queue.DequeueWhere(m.replayable)
+		if err != nil {
+			return // só havia mensagens expiradas ou esperando a janela
+		}
+
+		err = m.replay(message)
+		switch {
+		case err == nil:
+			m.queue.MarkProcessed(message.ID)
+			m.metrics.HTTPRequests++
+			if message.Type == "inventory" {
+				m.metrics.InventoriesSent++
+			}
+		case transientSendError(err):
+			if requeueErr := m.queue.Requeue(*message, err); requeueErr != nil {
+				m.logger.WithFields(map[string]interface{}{
+					"type":  message.Type,
+					"id":    message.ID,
+					"error": err.Error(),
+				}).Warning("Spooled message dropped after repeated failures")
+			}
+			return
+		default:
+			m.queue.Drop(*message, err)
+		}
+	}
+}
+
+// replay envia uma mensagem da fila com os cabeçalhos e a chave de
+// idempotência gravados nela. Mensagens gravadas no modo envelope já estão
+// cifradas e vão como estão.
+func (m *Manager) replay(message *QueuedMessage) error {
+	headers := make(map[string]string, len(message.Headers)+1)
+	for name, value := range message.Headers {
+		headers[name] = value
+	}
+	if message.IdempotencyKey != "" {
+		headers[IdempotencyKeyHeader] = message.IdempotencyKey
+	}
+
+	var payload interface{} = message.Data
+	if message.Envelope != nil {
+		payload = message.Envelope
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.uploadTimeout(len(body)))
+	defer cancel()
+
+	if message.Envelope != nil {
+		return m.httpClient.sendJSON(ctx, message.Method, message.Endpoint, body, nil, headers)
+	}
+	return m.httpClient.sendRequest(ctx, message.Method, message.Endpoint, message.Data, nil, headers)
+}
+
+// OfflineQueue retorna a fila offline, para ser passada em Config.Queue ao
+// Manager que substitui este
+func (m *Manager) OfflineQueue() *MessageQueue {
+	return m.queue
+}
+
+// QueueMetrics retorna o estado da fila offline (zero sem fila)
+func (m *Manager) QueueMetrics() QueueMetrics {
+	if m.queue == nil {
+		return QueueMetrics{}
+	}
+	return m.queue.GetMetrics()
+}
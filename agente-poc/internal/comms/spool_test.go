@@ -0,0 +1,201 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+	"agente-poc/internal/collector"
+)
+
+// outageBackend responde 503 enquanto houver falhas pendentes e registra,
+// na ordem de chegada, as mensagens aceitas
+type outageBackend struct {
+	server   *httptest.Server
+	failures atomic.Int64
+
+	mu       sync.Mutex
+	accepted []string
+}
+
+func newOutageBackend(t *testing.T, failures int64) *outageBackend {
+	t.Helper()
+	backend := &outageBackend{}
+	backend.failures.Store(failures)
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if backend.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Sequence int64  `json:"sequence"`
+			RuleID   string `json:"rule_id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		label := r.URL.Path
+		switch r.URL.Path {
+		case EndpointInventory:
+			label = fmt.Sprintf("inventory-%d", body.Sequence)
+		case "/alerts":
+			label = "alert-" + body.RuleID
+		}
+		backend.mu.Lock()
+		backend.accepted = append(backend.accepted, label)
+		backend.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	return backend
+}
+
+func (b *outageBackend) received() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.accepted...)
+}
+
+// newSpoolTestManager cria um Manager sem retentativas HTTP, para que cada
+// envio falho vá direto para a fila offline em queuePath
+func newSpoolTestManager(t *testing.T, url, queuePath string, fake *clock.Fake) *Manager {
+	t.Helper()
+	m, err := New(&Config{
+		BackendURL:     url,
+		Token:          "test-token",
+		MachineID:      "test-machine",
+		Logger:         testLogger(t),
+		Clock:          fake,
+		HTTPTimeout:    5 * time.Second,
+		HTTPMaxRetries: -1,
+		QueuePath:      queuePath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.cancel)
+	return m
+}
+
+func spoolTestInventory() *collector.InventoryData {
+	return &collector.InventoryData{
+		MachineID: "test-machine",
+		System:    collector.SystemInfo{Hostname: "test-host"},
+	}
+}
+
+// sendDuringOutage envia heartbeat, inventários e um alerta com o backend
+// fora do ar, um a cada 30s; todos precisam ir para a fila (e o heartbeat,
+// que expira em 5 minutos, ainda vale no reenvio)
+func sendDuringOutage(t *testing.T, m *Manager, fake *clock.Fake) {
+	t.Helper()
+	sends := []func() error{
+		m.SendHeartbeat,
+		func() error { return m.SendInventoryWithSequence(spoolTestInventory(), 1) },
+		func() error { return m.SendAlert(Alert{RuleID: "disk", Timestamp: fake.Now()}) },
+		func() error { return m.SendInventoryWithSequence(spoolTestInventory(), 2) },
+		func() error { return m.SendInventoryWithSequence(spoolTestInventory(), 3) },
+	}
+	for i, send := range sends {
+		if err := send(); !errors.Is(err, ErrSpooled) {
+			t.Fatalf("send %d during the outage: %v, want ErrSpooled", i, err)
+		}
+		fake.Advance(30 * time.Second)
+	}
+	if size := m.QueueMetrics().QueueSize; size != int64(len(sends)) {
+		t.Fatalf("queue size = %d, want %d", size, len(sends))
+	}
+}
+
+// Ordem de prioridade da fila: alerta, inventários em ordem de criação, heartbeat
+var spooledDeliveryOrder = []string{"alert-disk", "inventory-1", "inventory-2", "inventory-3", EndpointHeartbeat}
+
+func TestSpoolReplayAfterOutage(t *testing.T) {
+	// 5 envios com o backend fora do ar e mais uma falha no primeiro reenvio
+	backend := newOutageBackend(t, 6)
+	fake := newTestClock()
+	m := newSpoolTestManager(t, backend.server.URL, filepath.Join(t.TempDir(), "queue.json"), fake)
+
+	sendDuringOutage(t, m, fake)
+
+	// A primeira falha devolve a mensagem à fila e encerra a rodada
+	m.replayQueued()
+	if got := backend.received(); len(got) != 0 {
+		t.Fatalf("accepted during the outage: %v", got)
+	}
+	metrics := m.QueueMetrics()
+	if metrics.QueueSize != 5 || metrics.TotalRetries != 1 {
+		t.Fatalf("after the failed replay: %+v", metrics)
+	}
+
+	m.replayQueued()
+	if got := backend.received(); !reflect.DeepEqual(got, spooledDeliveryOrder) {
+		t.Fatalf("delivery order = %v, want %v", got, spooledDeliveryOrder)
+	}
+	if m.QueueMetrics().QueueSize != 0 || m.GetMetrics().InventoriesSent != 3 {
+		t.Fatalf("queue %+v, manager metrics %+v", m.QueueMetrics(), m.GetMetrics())
+	}
+}
+
+func TestSpoolRejectedNotQueued(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("HTTP_PROXY", "")
+	m := newSpoolTestManager(t, server.URL, "", newTestClock())
+
+	// Uma recusa 4xx não muda com o tempo: o erro volta sem ir para a fila
+	err := m.SendInventoryWithSequence(spoolTestInventory(), 1)
+	if err == nil || errors.Is(err, ErrSpooled) || HTTPStatusCode(err) != http.StatusUnprocessableEntity {
+		t.Fatalf("rejected inventory error = %v", err)
+	}
+	if size := m.QueueMetrics().QueueSize; size != 0 {
+		t.Fatalf("rejected inventory spooled, queue size %d", size)
+	}
+}
+
+func TestSpoolDrainedAfterRestart(t *testing.T) {
+	backend := newOutageBackend(t, 5)
+	fake := newTestClock()
+	queuePath := filepath.Join(t.TempDir(), "queue.json")
+
+	sendDuringOutage(t, newSpoolTestManager(t, backend.server.URL, queuePath, fake), fake)
+
+	// O agente reinicia com o backend de volta: a fila é lida do disco e o
+	// dreno periódico entrega tudo na ordem de prioridade
+	m := newSpoolTestManager(t, backend.server.URL, queuePath, fake)
+	if size := m.QueueMetrics().QueueSize; size != 5 {
+		t.Fatalf("queue size after restart = %d", size)
+	}
+	done := make(chan struct{})
+	go func() {
+		m.drainQueue()
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.Pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(m.config.QueueDrainInterval)
+	// QueueSize cai ao retirar a mensagem, antes do envio terminar
+	for len(backend.received()) < len(spooledDeliveryOrder) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	m.cancel()
+	<-done
+
+	if got := backend.received(); !reflect.DeepEqual(got, spooledDeliveryOrder) {
+		t.Fatalf("delivery order after restart = %v, want %v", got, spooledDeliveryOrder)
+	}
+}
@@ -0,0 +1,149 @@
+package comms
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CommandStatus é o status canônico de um CommandResult.
+//
+// Valores documentados (contrato com o backend):
+//
+//	scheduled          comando aceito e agendado para execução futura
+//	running            comando em execução
+//	success            terminou com sucesso
+//	error              terminou com erro (também usado como fallback para valores desconhecidos)
+//	timeout            excedeu o tempo limite
+//	rejected           recusado por validação (whitelist, tipo não suportado, etc.)
+//	rejected_busy      recusado porque o agente está sem capacidade no momento
+//	rejected_oversized recusado por exceder os limites de tamanho de args/options
+//	quota_exceeded     recusado por exceder uma cota
+//	cancelled          cancelado antes de terminar
+//	expired            expirou antes de começar a executar
+//
+// Ao adicionar um status, atualize também allCommandStatuses, a tabela acima
+// e o schema do backend.
+type CommandStatus string
+
+const (
+	StatusScheduled         CommandStatus = "scheduled"
+	StatusRunning           CommandStatus = "running"
+	StatusSuccess           CommandStatus = "success"
+	StatusError             CommandStatus = "error"
+	StatusTimeout           CommandStatus = "timeout"
+	StatusRejected          CommandStatus = "rejected"
+	StatusRejectedBusy      CommandStatus = "rejected_busy"
+	StatusRejectedOversized CommandStatus = "rejected_oversized"
+	StatusQuotaExceeded     CommandStatus = "quota_exceeded"
+	StatusCancelled         CommandStatus = "cancelled"
+	StatusExpired           CommandStatus = "expired"
+)
+
+// StatusFallback é usado quando um status desconhecido é lido (ex.: mensagens
+// antigas da fila persistente)
+const StatusFallback = StatusError
+
+// allCommandStatuses lista o conjunto canônico, na ordem da documentação
+var allCommandStatuses = []CommandStatus{
+	StatusScheduled,
+	StatusRunning,
+	StatusSuccess,
+	StatusError,
+	StatusTimeout,
+	StatusRejected,
+	StatusRejectedBusy,
+	StatusRejectedOversized,
+	StatusQuotaExceeded,
+	StatusCancelled,
+	StatusExpired,
+}
+
+// AllCommandStatuses retorna uma cópia do conjunto canônico de status
+func AllCommandStatuses() []CommandStatus {
+	statuses := make([]CommandStatus, len(allCommandStatuses))
+	copy(statuses, allCommandStatuses)
+	return statuses
+}
+
+// ParseCommandStatus converte uma string em CommandStatus, aplicando o fallback
+// para valores desconhecidos
+func ParseCommandStatus(value string) CommandStatus {
+	status := CommandStatus(value)
+	if status.IsValid() {
+		return status
+	}
+	return StatusFallback
+}
+
+// String retorna a representação string do status
+func (s CommandStatus) String() string {
+	return string(s)
+}
+
+// IsValid verifica se o status pertence ao conjunto canônico
+func (s CommandStatus) IsValid() bool {
+	for _, known := range allCommandStatuses {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal indica se o status encerra o ciclo de vida do comando
+func (s CommandStatus) IsTerminal() bool {
+	switch s {
+	case StatusScheduled, StatusRunning:
+		return false
+	default:
+		return s.IsValid()
+	}
+}
+
+// CanTransitionTo verifica se a transição s→next é permitida.
+// Um status vazio representa um resultado ainda não inicializado.
+func (s CommandStatus) CanTransitionTo(next CommandStatus) bool {
+	if !next.IsValid() {
+		return false
+	}
+
+	switch s {
+	case "":
+		return true
+	case StatusScheduled:
+		return next != StatusScheduled && next != StatusSuccess && next != StatusError && next != StatusTimeout
+	case StatusRunning:
+		return next.IsTerminal() && next != StatusExpired
+	default:
+		// Estados terminais não mudam
+		return false
+	}
+}
+
+// MarshalJSON serializa o status; valores fora do conjunto canônico são
+// convertidos para o fallback
+func (s CommandStatus) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		s = StatusFallback
+	}
+	return json.Marshal(string(s))
+}
+
+// UnmarshalJSON deserializa o status aplicando o fallback para valores desconhecidos
+func (s *CommandStatus) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid command status: %w", err)
+	}
+	*s = ParseCommandStatus(value)
+	return nil
+}
+
+// SetStatus altera o status do resultado respeitando a máquina de estados
+func (r *CommandResult) SetStatus(next CommandStatus) error {
+	if !r.Status.CanTransitionTo(next) {
+		return fmt.Errorf("invalid command status transition: %q -> %q", r.Status, next)
+	}
+	r.Status = next
+	return nil
+}
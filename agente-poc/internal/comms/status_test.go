@@ -0,0 +1,194 @@
+package comms
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCommandStatusesDocumented falha quando um status é declarado sem entrar
+// em allCommandStatuses ou na tabela documentada de CommandStatus
+func TestCommandStatusesDocumented(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "status.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc string
+	declared := make(map[CommandStatus]bool)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if spec.Name.Name == "CommandStatus" {
+					doc = gen.Doc.Text()
+				}
+			case *ast.ValueSpec:
+				ident, ok := spec.Type.(*ast.Ident)
+				if gen.Tok != token.CONST || !ok || ident.Name != "CommandStatus" {
+					continue
+				}
+				for _, value := range spec.Values {
+					lit, ok := value.(*ast.BasicLit)
+					if !ok {
+						continue
+					}
+					unquoted, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					declared[CommandStatus(unquoted)] = true
+				}
+			}
+		}
+	}
+
+	documented := make(map[CommandStatus]bool)
+	for _, line := range strings.Split(doc, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			documented[CommandStatus(fields[0])] = true
+		}
+	}
+
+	if len(declared) == 0 {
+		t.Fatal("no CommandStatus constants found in status.go")
+	}
+	all := AllCommandStatuses()
+	listed := make(map[CommandStatus]bool, len(all))
+	for _, status := range all {
+		if listed[status] {
+			t.Errorf("status %q listed twice in allCommandStatuses", status)
+		}
+		listed[status] = true
+	}
+	for status := range declared {
+		if !listed[status] {
+			t.Errorf("status %q declared but missing from allCommandStatuses", status)
+		}
+		if !documented[status] {
+			t.Errorf("status %q declared but missing from the CommandStatus documentation", status)
+		}
+	}
+	for status := range listed {
+		if !declared[status] {
+			t.Errorf("status %q in allCommandStatuses is not a declared constant", status)
+		}
+	}
+	for status := range documented {
+		if !declared[status] {
+			t.Errorf("documented status %q is not declared", status)
+		}
+	}
+}
+
+func TestCommandStatusTransitions(t *testing.T) {
+	terminal := []CommandStatus{
+		StatusSuccess, StatusError, StatusTimeout, StatusRejected, StatusRejectedBusy,
+		StatusRejectedOversized, StatusQuotaExceeded, StatusCancelled, StatusExpired,
+	}
+	allowed := map[CommandStatus]map[CommandStatus]bool{
+		"": {},
+		StatusScheduled: {
+			StatusRunning: true, StatusRejected: true, StatusRejectedBusy: true,
+			StatusRejectedOversized: true, StatusQuotaExceeded: true, StatusCancelled: true,
+			StatusExpired: true,
+		},
+		StatusRunning: {
+			StatusSuccess: true, StatusError: true, StatusTimeout: true, StatusRejected: true,
+			StatusRejectedBusy: true, StatusRejectedOversized: true, StatusQuotaExceeded: true,
+			StatusCancelled: true,
+		},
+	}
+	for _, status := range AllCommandStatuses() {
+		allowed[""][status] = true
+	}
+	for _, status := range terminal {
+		allowed[status] = map[CommandStatus]bool{}
+	}
+
+	if len(allowed) != len(AllCommandStatuses())+1 {
+		t.Fatalf("transition table covers %d states, want every status plus the empty one", len(allowed))
+	}
+	for from, targets := range allowed {
+		for _, to := range append(AllCommandStatuses(), "bogus") {
+			if got := from.CanTransitionTo(to); got != targets[to] {
+				t.Errorf("%q -> %q = %t, want %t", from, to, got, targets[to])
+			}
+		}
+	}
+}
+
+func TestCommandStatusIsTerminal(t *testing.T) {
+	for _, status := range AllCommandStatuses() {
+		want := status != StatusScheduled && status != StatusRunning
+		if status.IsTerminal() != want {
+			t.Errorf("%q.IsTerminal() = %t", status, status.IsTerminal())
+		}
+	}
+	if CommandStatus("bogus").IsTerminal() {
+		t.Error("unknown status reported as terminal")
+	}
+}
+
+func TestCommandStatusJSON(t *testing.T) {
+	for _, status := range AllCommandStatuses() {
+		data, err := json.Marshal(status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != strconv.Quote(string(status)) {
+			t.Errorf("marshal %q = %s", status, data)
+		}
+		var decoded CommandStatus
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != status {
+			t.Errorf("round trip %q = %q, %v", status, decoded, err)
+		}
+	}
+
+	// Valores antigos ou desconhecidos viram o fallback nos dois sentidos
+	var decoded CommandStatus
+	if err := json.Unmarshal([]byte(`"failed"`), &decoded); err != nil || decoded != StatusFallback {
+		t.Errorf("unknown status decoded as %q, %v", decoded, err)
+	}
+	if data, _ := json.Marshal(CommandStatus("failed")); string(data) != strconv.Quote(string(StatusFallback)) {
+		t.Errorf("unknown status marshalled as %s", data)
+	}
+	if err := json.Unmarshal([]byte(`3`), &decoded); err == nil {
+		t.Error("non-string status accepted")
+	}
+
+	var result CommandResult
+	if err := json.Unmarshal([]byte(`{"id":"1","status":"finished"}`), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != StatusFallback {
+		t.Errorf("queued result with unknown status decoded as %q", result.Status)
+	}
+}
+
+func TestCommandResultSetStatus(t *testing.T) {
+	result := &CommandResult{}
+	for _, next := range []CommandStatus{StatusScheduled, StatusRunning, StatusSuccess} {
+		if err := result.SetStatus(next); err != nil {
+			t.Fatalf("SetStatus(%q): %v", next, err)
+		}
+	}
+	if err := result.SetStatus(StatusError); err == nil {
+		t.Fatal("terminal status changed")
+	}
+	if result.Status != StatusSuccess {
+		t.Fatalf("rejected transition changed the status to %q", result.Status)
+	}
+}
@@ -0,0 +1,150 @@
+package comms
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// minThrottleChunk é o menor pedaço de corpo liberado de uma vez, para taxas
+// muito baixas não virarem uma escrita por byte
+const minThrottleChunk = 512
+
+// Throttle limita a taxa de envio dos corpos HTTP com um token bucket em
+// bytes por segundo. O bucket guarda 100 ms de envio (no mínimo
+// minThrottleChunk), então uploads longos seguem a taxa de perto sem rajada
+// inicial. Taxa 0 desliga o limite; SetRate vale para os envios em andamento.
+// Seguro para uso concorrente: requisições simultâneas dividem a taxa.
+type Throttle struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+	clock  clock.Clock
+}
+
+// NewThrottle cria o limitador com rate bytes/s (0 = sem limite)
+func NewThrottle(rate int64, clk clock.Clock) *Throttle {
+	t := &Throttle{clock: clock.OrReal(clk)}
+	t.SetRate(rate)
+	return t
+}
+
+// SetRate troca a taxa (bytes/s; 0 ou negativo = sem limite)
+func (t *Throttle) SetRate(rate int64) {
+	if rate < 0 {
+		rate = 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rate = rate
+	t.tokens = float64(t.burst())
+	t.last = t.clock.Now()
+}
+
+// Rate retorna a taxa atual em bytes/s (0 = sem limite)
+func (t *Throttle) Rate() int64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate
+}
+
+// burst é a capacidade do bucket; chamado com o mutex adquirido
+func (t *Throttle) burst() int64 {
+	burst := t.rate / 10
+	if burst < minThrottleChunk {
+		burst = minThrottleChunk
+	}
+	return burst
+}
+
+// reserve retira até n bytes do bucket e retorna quantos foram liberados e,
+// se nenhum, quanto esperar. Sem limite, libera n na hora.
+func (t *Throttle) reserve(n int) (int, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rate == 0 {
+		return n, 0
+	}
+
+	now := t.clock.Now()
+	t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+	t.last = now
+	if burst := float64(t.burst()); t.tokens > burst {
+		t.tokens = burst
+	}
+
+	// espera juntar um pedaço inteiro (n ou o bucket cheio) em vez de liberar
+	// o pouco que já pingou, para não fragmentar o envio
+	want := float64(n)
+	if burst := float64(t.burst()); want > burst {
+		want = burst
+	}
+	if t.tokens < want {
+		return 0, time.Duration((want - t.tokens) / float64(t.rate) * float64(time.Second))
+	}
+
+	t.tokens -= want
+	return int(want), 0
+}
+
+// Body retorna o leitor de data limitado pela taxa; a leitura pelo
+// transporte acompanha as escritas no socket, então o limite vale para o
+// envio de fato, não para uma espera antes da requisição
+func (t *Throttle) Body(ctx context.Context, data []byte) io.Reader {
+	return &throttledReader{ctx: ctx, reader: bytes.NewReader(data), throttle: t}
+}
+
+// throttledReader libera o corpo em pedaços conforme o bucket enche
+type throttledReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	throttle *Throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		granted, wait := r.throttle.reserve(len(p))
+		if granted > 0 {
+			return r.reader.Read(p[:granted])
+		}
+		select {
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		case <-r.throttle.clock.After(wait):
+		}
+	}
+}
+
+// UploadRate retorna o limite de upload vigente em bytes/s (0 = sem limite)
+func (m *Manager) UploadRate() int64 {
+	return m.throttle.Rate()
+}
+
+// SetUploadRate troca o limite de upload (bytes/s; 0 = sem limite); vale
+// também para os envios em andamento
+func (m *Manager) SetUploadRate(rate int64) {
+	m.throttle.SetRate(rate)
+}
+
+// uploadTimeout é o timeout de uma requisição com corpo de size bytes: o
+// HTTPTimeout mais o tempo que o corpo leva para sair no limite de upload
+func (m *Manager) uploadTimeout(size int) time.Duration {
+	timeout := m.config.HTTPTimeout
+	if rate := m.throttle.Rate(); rate > 0 {
+		timeout += time.Duration(int64(size) * int64(time.Second) / rate)
+	}
+	return timeout
+}
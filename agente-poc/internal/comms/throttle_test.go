@@ -0,0 +1,123 @@
+package comms
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uploadServer lê o corpo inteiro de cada requisição e registra quanto a
+// leitura levou, do primeiro ao último byte
+type uploadServer struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	received int
+	reading  time.Duration
+}
+
+func newUploadServer(t *testing.T) *uploadServer {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	backend := &uploadServer{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first := make([]byte, 1)
+		n, _ := io.ReadFull(r.Body, first)
+		start := time.Now()
+		rest, _ := io.ReadAll(r.Body)
+		backend.mu.Lock()
+		backend.received = n + len(rest)
+		backend.reading = time.Since(start)
+		backend.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *uploadServer) last() (int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.received, b.reading
+}
+
+func TestThrottleReserve(t *testing.T) {
+	fake := newTestClock()
+	throttle := NewThrottle(1000, fake)
+
+	// O bucket começa cheio com minThrottleChunk e libera no máximo isso
+	if granted, wait := throttle.reserve(4096); granted != minThrottleChunk || wait != 0 {
+		t.Fatalf("first reserve = %d, %s", granted, wait)
+	}
+	granted, wait := throttle.reserve(4096)
+	if granted != 0 || wait != 512*time.Millisecond {
+		t.Fatalf("empty bucket reserve = %d, %s", granted, wait)
+	}
+	fake.Advance(wait)
+	if granted, _ := throttle.reserve(4096); granted != minThrottleChunk {
+		t.Fatalf("reserve after refill = %d", granted)
+	}
+
+	// Taxa 0 libera tudo na hora
+	throttle.SetRate(0)
+	if granted, wait := throttle.reserve(1 << 20); granted != 1<<20 || wait != 0 || throttle.Rate() != 0 {
+		t.Fatalf("unlimited reserve = %d, %s", granted, wait)
+	}
+}
+
+func TestThrottledUploadTakesExpectedTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uploads at a limited rate take about a second")
+	}
+	const size = 100 * 1024
+	const rate = 100 * 1024
+	backend := newUploadServer(t)
+	throttle := NewThrottle(rate, nil)
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:    backend.server.URL,
+		MaxRetries: -1,
+		Timeout:    time.Second,
+		Throttle:   throttle,
+		Logger:     testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := bytes.Repeat([]byte("a"), size)
+
+	// Sem o bucket inicial (rate/10), o resto sai a rate bytes/s: ~0,9 s.
+	// O timeout de 1 s é estendido pelo tempo do corpo na taxa atual.
+	expected := time.Duration(float64(size-rate/10) / rate * float64(time.Second))
+	start := time.Now()
+	if err := client.sendJSON(context.Background(), http.MethodPost, "/upload", body, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+	received, reading := backend.last()
+	if received != size {
+		t.Fatalf("backend received %d bytes, want %d", received, size)
+	}
+	if elapsed < expected*8/10 || elapsed > expected*2 {
+		t.Fatalf("upload took %s, want about %s", elapsed, expected)
+	}
+	// O limite vale para as escritas no socket: o corpo chega aos poucos,
+	// não de uma vez depois de uma espera
+	if reading < expected*7/10 {
+		t.Fatalf("body arrived in %s, want it spread over about %s", reading, expected)
+	}
+
+	// A taxa muda em execução; sem limite, o mesmo corpo sai na hora
+	throttle.SetRate(0)
+	start = time.Now()
+	if err := client.sendJSON(context.Background(), http.MethodPost, "/upload", body, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > expected/3 {
+		t.Fatalf("unlimited upload took %s", elapsed)
+	}
+}
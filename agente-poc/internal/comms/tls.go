@@ -0,0 +1,104 @@
+package comms
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSFiles são os arquivos PEM da autenticação mútua com o backend. Com
+// CACertFile, o certificado do servidor é verificado só contra essa CA, e
+// não contra as raízes do sistema.
+type TLSFiles struct {
+	ClientCertFile string
+	ClientKeyFile  string
+	CACertFile     string
+}
+
+// ErrSkipVerifyWithCA indica TLSSkipVerify junto com um arquivo de CA
+var ErrSkipVerifyWithCA = errors.New("tls skip verify and a CA certificate file are mutually exclusive")
+
+// BuildTLSConfig monta a configuração TLS dos clientes HTTP e WebSocket.
+// Os arquivos são lidos na chamada; para trocar certificados, crie os
+// clientes de novo (o agente recria o Manager na recarga da configuração).
+func BuildTLSConfig(files TLSFiles, skipVerify bool) (*tls.Config, error) {
+	if skipVerify && files.CACertFile != "" {
+		return nil, ErrSkipVerifyWithCA
+	}
+	if (files.ClientCertFile == "") != (files.ClientKeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key must be configured together")
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if files.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(files.ClientCertFile, files.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s: %w", files.ClientCertFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if files.CACertFile != "" {
+		pem, err := os.ReadFile(files.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA certificate file %s", files.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// CertificatePinMatch indica se algum certificado (DER) da cadeia tem o
+// SHA-256, em hex, entre os pins
+func CertificatePinMatch(rawCerts [][]byte, pins []string) bool {
+	for _, raw := range rawCerts {
+		sum := sha256.Sum256(raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		for _, pin := range pins {
+			if strings.EqualFold(strings.TrimSpace(pin), fingerprint) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateCertificatePins confere o formato dos pins: SHA-256 em hex
+func ValidateCertificatePins(pins []string) error {
+	for _, pin := range pins {
+		decoded, err := hex.DecodeString(strings.TrimSpace(pin))
+		if err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("invalid certificate pin %q: expected a hex SHA-256 fingerprint", pin)
+		}
+	}
+	return nil
+}
+
+// PinCertificates exige, além da verificação normal, que a cadeia do
+// servidor traga um certificado com um dos pins. Sem pins não altera config.
+func PinCertificates(config *tls.Config, pins []string) {
+	if len(pins) == 0 {
+		return
+	}
+	pins = append([]string(nil), pins...)
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if !CertificatePinMatch(rawCerts, pins) {
+			return fmt.Errorf("no certificate in the server chain matches the configured pins")
+		}
+		return nil
+	}
+}
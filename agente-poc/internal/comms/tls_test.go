@@ -0,0 +1,323 @@
+package comms
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testPKI é uma CA de teste com os certificados do servidor (127.0.0.1) e
+// do cliente, gravados em PEM num diretório temporário
+type testPKI struct {
+	dir    string
+	caPool *x509.CertPool
+	server tls.Certificate
+	files  TLSFiles
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	pki := &testPKI{dir: t.TempDir(), caPool: x509.NewCertPool()}
+
+	caKey, caDER := issueCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, nil)
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pki.caPool.AddCert(ca)
+
+	serverKey, serverDER := issueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "backend"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	pki.server = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+
+	clientKey, clientDER := issueCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "machine-1"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	pki.files = TLSFiles{
+		ClientCertFile: pki.writePEM(t, "client.pem", "CERTIFICATE", clientDER),
+		ClientKeyFile:  pki.writeKey(t, "client-key.pem", clientKey),
+		CACertFile:     pki.writePEM(t, "ca.pem", "CERTIFICATE", caDER),
+	}
+	return pki
+}
+
+// issueCertificate gera uma chave e o certificado do template, assinado
+// pelo parent (nil = autoassinado)
+func issueCertificate(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, der
+}
+
+func (p *testPKI) writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(p.dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (p *testPKI) writeKey(t *testing.T, name string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.writePEM(t, name, "EC PRIVATE KEY", der)
+}
+
+// newMutualTLSServer inicia um servidor que exige certificado de cliente
+// assinado pela CA; handler nil responde {} em JSON
+func (p *testPKI) newMutualTLSServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	t.Setenv("HTTPS_PROXY", "")
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+		})
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{p.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    p.caPool,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	config, err := BuildTLSConfig(pki.files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 || config.RootCAs == nil || config.InsecureSkipVerify || config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("config = %+v", config)
+	}
+
+	// Sem arquivos: raízes do sistema, sem certificado de cliente
+	if config, err := BuildTLSConfig(TLSFiles{}, true); err != nil || config.RootCAs != nil || len(config.Certificates) != 0 || !config.InsecureSkipVerify {
+		t.Fatalf("without files: %+v, %v", config, err)
+	}
+
+	if _, err := BuildTLSConfig(TLSFiles{CACertFile: pki.files.CACertFile}, true); !errors.Is(err, ErrSkipVerifyWithCA) {
+		t.Fatalf("skip verify with a CA: %v", err)
+	}
+
+	notPEM := filepath.Join(pki.dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, files := range map[string]TLSFiles{
+		"certificate without key": {ClientCertFile: pki.files.ClientCertFile},
+		"key without certificate": {ClientKeyFile: pki.files.ClientKeyFile},
+		"missing certificate":     {ClientCertFile: filepath.Join(pki.dir, "missing.pem"), ClientKeyFile: pki.files.ClientKeyFile},
+		"mismatched key":          {ClientCertFile: pki.files.CACertFile, ClientKeyFile: pki.files.ClientKeyFile},
+		"missing CA":              {CACertFile: filepath.Join(pki.dir, "missing.pem")},
+		"CA without PEM":          {CACertFile: notPEM},
+	} {
+		if _, err := BuildTLSConfig(files, false); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestMutualTLSHTTPClient(t *testing.T) {
+	pki := newTestPKI(t)
+	server := pki.newMutualTLSServer(t, nil)
+
+	tests := []struct {
+		name    string
+		files   TLSFiles
+		wantErr bool
+	}{
+		{name: "client certificate", files: pki.files},
+		{name: "no client certificate", files: TLSFiles{CACertFile: pki.files.CACertFile}, wantErr: true},
+		// Sem a CA, o servidor não é confiável pelas raízes do sistema
+		{name: "system roots", files: TLSFiles{ClientCertFile: pki.files.ClientCertFile, ClientKeyFile: pki.files.ClientKeyFile}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(HTTPConfig{BaseURL: server.URL, TLSFiles: tt.files, MaxRetries: -1, Logger: testLogger(t)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response map[string]interface{}
+			err = client.GET(context.Background(), "/", &response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GET error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMutualTLSWebSocketClient(t *testing.T) {
+	pki := newTestPKI(t)
+	upgrader := websocket.Upgrader{}
+	server := pki.newMutualTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	for _, tt := range []struct {
+		name    string
+		files   TLSFiles
+		wantErr bool
+	}{
+		{name: "client certificate", files: pki.files},
+		{name: "no client certificate", files: TLSFiles{CACertFile: pki.files.CACertFile}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := NewWebSocketClient(WebSocketConfig{
+				URL:           "wss" + strings.TrimPrefix(server.URL, "https"),
+				MachineID:     "machine-1",
+				MaxReconnects: 0,
+				PingInterval:  time.Hour,
+				PongTimeout:   time.Minute,
+				TLSFiles:      tt.files,
+				Logger:        testLogger(t),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ws.Close()
+			if err := ws.Connect(); (err != nil) != tt.wantErr {
+				t.Fatalf("Connect error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewManagerInvalidTLSFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	for name, config := range map[string]*Config{
+		"missing key":        {TLSFiles: TLSFiles{ClientCertFile: pki.files.ClientCertFile, ClientKeyFile: filepath.Join(pki.dir, "missing.pem")}},
+		"skip verify and CA": {TLSSkipVerify: true, TLSFiles: TLSFiles{CACertFile: pki.files.CACertFile}},
+	} {
+		config.BackendURL = "https://127.0.0.1"
+		config.WebSocketURL = "wss://127.0.0.1/ws"
+		config.Logger = testLogger(t)
+		if _, err := New(config); err == nil || !strings.Contains(err.Error(), "invalid TLS configuration") {
+			t.Errorf("%s: New error = %v", name, err)
+		}
+	}
+}
+
+func TestValidateCertificatePins(t *testing.T) {
+	valid := strings.Repeat("0a", sha256.Size)
+	if err := ValidateCertificatePins([]string{valid, strings.ToUpper(valid)}); err != nil {
+		t.Fatalf("valid pins rejected: %v", err)
+	}
+	for _, pin := range []string{"", "zz", valid[:62], valid + "00"} {
+		if err := ValidateCertificatePins([]string{pin}); err == nil {
+			t.Errorf("pin %q accepted", pin)
+		}
+	}
+}
+
+func TestPinCertificatesHTTPClient(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+	match := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{name: "no pins", pins: nil},
+		{name: "matching pin", pins: []string{strings.Repeat("ff", sha256.Size), match}},
+		{name: "no matching pin", pins: []string{strings.Repeat("ff", sha256.Size)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewHTTPClient(HTTPConfig{
+				BaseURL:            server.URL,
+				TLSSkipVerify:      true,
+				PinnedCertificates: tt.pins,
+				MaxRetries:         -1,
+				Logger:             testLogger(t),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var response map[string]interface{}
+			err = client.GET(context.Background(), "/", &response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GET error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package comms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// tokenFingerprintLength é o tamanho (em caracteres hex) do ID de um token
+const tokenFingerprintLength = 12
+
+// TokenFingerprint retorna um identificador não sensível do token (prefixo
+// do SHA-256), usado em logs e heartbeats no lugar do valor
+func TokenFingerprint(token string) string {
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])[:tokenFingerprintLength]
+}
+
+// TokenSet guarda os tokens do backend em ordem de preferência. O ativo é
+// usado primeiro; em 401 os demais são tentados e o que funcionar é promovido.
+// Seguro para uso concorrente.
+type TokenSet struct {
+	mu     sync.RWMutex
+	tokens []string
+	active int
+}
+
+// TokenStatus descreve os tokens configurados sem expor os valores
+type TokenStatus struct {
+	ActiveID string   `json:"active_id"`
+	IDs      []string `json:"ids"`
+}
+
+// NewTokenSet cria o conjunto com os tokens informados (o primeiro é o
+// primário); vazios e duplicados são ignorados
+func NewTokenSet(tokens ...string) *TokenSet {
+	s := &TokenSet{}
+	for _, token := range tokens {
+		s.add(token)
+	}
+	return s
+}
+
+func (s *TokenSet) add(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, existing := range s.tokens {
+		if existing == token {
+			return false
+		}
+	}
+	s.tokens = append(s.tokens, token)
+	return true
+}
+
+// Active retorna o token em uso (vazio se nenhum foi configurado)
+func (s *TokenSet) Active() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.tokens) == 0 {
+		return ""
+	}
+	return s.tokens[s.active]
+}
+
+// Candidates retorna os tokens na ordem de tentativa: o ativo e depois os
+// demais na ordem configurada
+func (s *TokenSet) Candidates() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.tokens) == 0 {
+		return nil
+	}
+
+	candidates := make([]string, 0, len(s.tokens))
+	candidates = append(candidates, s.tokens[s.active])
+	for i, token := range s.tokens {
+		if i != s.active {
+			candidates = append(candidates, token)
+		}
+	}
+	return candidates
+}
+
+// Promote torna token o ativo. Retorna false se já era o ativo ou se não
+// pertence ao conjunto, para que requisições concorrentes que aceitaram o
+// mesmo token promovam (e registrem) apenas uma vez.
+func (s *TokenSet) Promote(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.tokens {
+		if existing == token {
+			if i == s.active {
+				return false
+			}
+			s.active = i
+			return true
+		}
+	}
+	return false
+}
+
+// Install adiciona token como secundário, tentado quando o ativo receber 401.
+// Retorna false se o token já estava no conjunto.
+func (s *TokenSet) Install(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(token)
+}
+
+// Replace troca o token old por token na mesma posição (ex.: token da
+// máquina renovado pelo backend). Retorna false se old não pertence ao
+// conjunto; token vazio é ignorado.
+func (s *TokenSet) Replace(old, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == "" {
+		return false
+	}
+	for i, existing := range s.tokens {
+		if existing == old {
+			s.tokens[i] = token
+			return true
+		}
+	}
+	return false
+}
+
+// Status retorna os IDs dos tokens e o ID do ativo
+func (s *TokenSet) Status() TokenStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := TokenStatus{IDs: make([]string, len(s.tokens))}
+	for i, token := range s.tokens {
+		status.IDs[i] = TokenFingerprint(token)
+	}
+	if len(s.tokens) > 0 {
+		status.ActiveID = status.IDs[s.active]
+	}
+	return status
+}
+
+// TokenRotationSignature calcula a assinatura de um comando rotate_token:
+// HMAC-SHA256 de "<command_id>\n<novo token>" com o token ativo como chave.
+// Só quem conhece o token atual consegue instalar um novo.
+func TokenRotationSignature(activeToken, commandID, newToken string) string {
+	mac := hmac.New(sha256.New, []byte(activeToken))
+	mac.Write([]byte(commandID + "\n" + newToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyTokenRotation confere a assinatura de um comando rotate_token
+func VerifyTokenRotation(activeToken, commandID, newToken, signature string) bool {
+	expected := TokenRotationSignature(activeToken, commandID, newToken)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
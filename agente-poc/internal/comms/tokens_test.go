@@ -0,0 +1,195 @@
+package comms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// tokenBackend aceita apenas os tokens em valid e registra os recebidos
+type tokenBackend struct {
+	mu    sync.Mutex
+	valid map[string]bool
+	seen  []string
+}
+
+func (b *tokenBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	b.mu.Lock()
+	b.seen = append(b.seen, token)
+	valid := b.valid[token]
+	b.mu.Unlock()
+
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid token"}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{}`))
+}
+
+// received retorna e limpa os tokens recebidos
+func (b *tokenBackend) received() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	seen := b.seen
+	b.seen = nil
+	return seen
+}
+
+// newTokenTestClient cria um cliente HTTP com os tokens informados apontando
+// para o backend falso
+func newTokenTestClient(t *testing.T, backend *tokenBackend, tokens *TokenSet) *HTTPClient {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+	server := httptest.NewServer(backend)
+	t.Cleanup(server.Close)
+
+	client, err := NewHTTPClient(HTTPConfig{
+		BaseURL:    server.URL,
+		Tokens:     tokens,
+		MaxRetries: -1,
+		Logger:     testLogger(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestTokenSetCandidates(t *testing.T) {
+	tokens := NewTokenSet("primary", "", "secondary", "primary", "third")
+	if got := strings.Join(tokens.Candidates(), ","); got != "primary,secondary,third" {
+		t.Fatalf("candidates = %s", got)
+	}
+
+	if !tokens.Promote("third") || tokens.Active() != "third" {
+		t.Fatalf("promotion failed, active = %s", tokens.Active())
+	}
+	if got := strings.Join(tokens.Candidates(), ","); got != "third,primary,secondary" {
+		t.Fatalf("candidates after promotion = %s", got)
+	}
+	if tokens.Promote("third") || tokens.Promote("unknown") {
+		t.Fatal("promotion of the active or an unknown token reported as a change")
+	}
+
+	if !tokens.Install("fourth") || tokens.Install("primary") {
+		t.Fatal("Install did not add only the new token")
+	}
+	if !tokens.Replace("primary", "renewed") || tokens.Replace("unknown", "x") || tokens.Replace("renewed", "") {
+		t.Fatal("Replace did not swap only a known token for a non-empty one")
+	}
+	if got := strings.Join(tokens.Candidates(), ","); got != "third,renewed,secondary,fourth" {
+		t.Fatalf("candidates after install and replace = %s", got)
+	}
+
+	if empty := NewTokenSet(); empty.Active() != "" || empty.Candidates() != nil || empty.Status().ActiveID != "" {
+		t.Fatal("empty token set reported a token")
+	}
+}
+
+func TestTokenStatusHidesValues(t *testing.T) {
+	tokens := NewTokenSet("primary-secret", "secondary-secret")
+	tokens.Promote("secondary-secret")
+
+	status := tokens.Status()
+	if len(status.IDs) != 2 || status.ActiveID != status.IDs[1] {
+		t.Fatalf("status = %+v", status)
+	}
+	for _, id := range status.IDs {
+		if len(id) != tokenFingerprintLength || strings.Contains(id, "secret") {
+			t.Fatalf("token ID %q is not a fingerprint", id)
+		}
+	}
+	if TokenFingerprint("primary-secret") != status.IDs[0] || TokenFingerprint("") != "" {
+		t.Fatal("fingerprint is not stable")
+	}
+}
+
+func TestHTTPClientPrimaryTokenExpired(t *testing.T) {
+	backend := &tokenBackend{valid: map[string]bool{"secondary": true}}
+	tokens := NewTokenSet("primary", "secondary")
+	client := newTokenTestClient(t, backend, tokens)
+
+	if err := client.GET(context.Background(), "/status", nil); err != nil {
+		t.Fatalf("request with a valid secondary token failed: %v", err)
+	}
+	if got := strings.Join(backend.received(), ","); got != "primary,secondary" {
+		t.Fatalf("tokens tried = %s", got)
+	}
+	if tokens.Active() != "secondary" {
+		t.Fatalf("active token = %s, want the secondary promoted", tokens.Active())
+	}
+
+	// Depois da promoção, o primário expirado não é mais tentado primeiro
+	if err := client.GET(context.Background(), "/status", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(backend.received(), ","); got != "secondary" {
+		t.Fatalf("tokens tried after promotion = %s", got)
+	}
+}
+
+func TestHTTPClientAllTokensExpired(t *testing.T) {
+	backend := &tokenBackend{valid: map[string]bool{}}
+	tokens := NewTokenSet("primary", "secondary")
+	client := newTokenTestClient(t, backend, tokens)
+
+	err := client.GET(context.Background(), "/status", nil)
+	if HTTPStatusCode(err) != http.StatusUnauthorized {
+		t.Fatalf("error = %v, want the 401", err)
+	}
+	if got := strings.Join(backend.received(), ","); got != "primary,secondary" {
+		t.Fatalf("tokens tried = %s", got)
+	}
+	if tokens.Active() != "primary" {
+		t.Fatalf("active token changed to %s although every token was rejected", tokens.Active())
+	}
+}
+
+func TestTokenPromotionConcurrent(t *testing.T) {
+	tokens := NewTokenSet("primary", "secondary")
+
+	var promoted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tokens.Promote("secondary") {
+				promoted.Add(1)
+			}
+			_ = tokens.Candidates()
+			_ = tokens.Status()
+		}()
+	}
+	wg.Wait()
+	if promoted.Load() != 1 {
+		t.Fatalf("token promoted %d times", promoted.Load())
+	}
+	if tokens.Active() != "secondary" {
+		t.Fatalf("active token = %s", tokens.Active())
+	}
+}
+
+func TestTokenRotationSignature(t *testing.T) {
+	signature := TokenRotationSignature("active", "cmd-1", "new-token")
+	if !VerifyTokenRotation("active", "cmd-1", "new-token", signature) {
+		t.Fatal("valid signature rejected")
+	}
+	for name, args := range map[string][3]string{
+		"other active token": {"old", "cmd-1", "new-token"},
+		"other command":      {"active", "cmd-2", "new-token"},
+		"other new token":    {"active", "cmd-1", "forged"},
+	} {
+		if VerifyTokenRotation(args[0], args[1], args[2], signature) {
+			t.Errorf("%s: signature accepted", name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package comms
 
 import (
 	"agente-poc/internal/collector"
+	"encoding/json"
 	"time"
 )
 
@@ -15,18 +16,57 @@ type Command struct {
 	Timeout      int                    `json:"timeout,omitempty"`
 	Timestamp    time.Time              `json:"timestamp"`
 	RequiresAuth bool                   `json:"requires_auth,omitempty"`
+
+	// Resultado da decodificação estrita (ver DecodeCommand); não trafegam no JSON
+	DecodeError    error    `json:"-"`
+	DecodeWarnings []string `json:"-"`
+
+	// Origin é de onde o comando veio (CommandOrigin*), para o log de
+	// auditoria; vazio vale como local
+	Origin string `json:"-"`
 }
 
+// Origens de um comando
+const (
+	CommandOriginWebSocket = "ws"
+	CommandOriginLocal     = "local" // agendamentos, alertas e o subcomando exec
+)
+
 // CommandResult representa o resultado da execução de um comando
 type CommandResult struct {
-	ID            string    `json:"id"`
-	CommandID     string    `json:"command_id"`
-	Status        string    `json:"status"` // "success", "error", "timeout", "rejected"
-	Output        string    `json:"output,omitempty"`
-	Error         string    `json:"error,omitempty"`
+	ID        string        `json:"id"`
+	CommandID string        `json:"command_id"`
+	Status    CommandStatus `json:"status"` // ver CommandStatus
+	Output    string        `json:"output,omitempty"`
+	// OutputTruncated indica que Output foi cortado no limite do comando;
+	// OriginalOutputBytes é o tamanho total produzido
+	OutputTruncated     bool      `json:"output_truncated,omitempty"`
+	OriginalOutputBytes int       `json:"original_output_bytes,omitempty"`
+	Error               string    `json:"error,omitempty"`
+	ErrorCode           ErrorCode `json:"error_code,omitempty"`
+	// Deprecated: texto antigo em português, mantido por uma versão para o
+	// backend que ainda compara strings; use ErrorCode
+	LegacyError   string    `json:"legacy_error,omitempty"`
 	ExitCode      int       `json:"exit_code,omitempty"`
 	ExecutionTime int64     `json:"execution_time_ms"`
 	Timestamp     time.Time `json:"timestamp"`
+	Warnings      []string  `json:"warnings,omitempty"`
+	// Offset é a posição de Output na saída acumulada; só em command_progress
+	// (Status running), onde Output é o trecho novo desde o frame anterior
+	Offset int `json:"offset,omitempty"`
+	// WorkingDir e Env registram, para auditoria, o diretório e o ambiente
+	// efetivos quando o comando usou options.cwd ou options.env
+	WorkingDir string   `json:"working_dir,omitempty"`
+	Env        []string `json:"env,omitempty"`
+	// ScheduleID identifica o agendamento local que gerou o comando
+	ScheduleID string `json:"schedule_id,omitempty"`
+	// Capabilities acompanha a recusa de um comando não suportado
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+	// InstanceID é preenchido pelo manager no envio
+	InstanceID string `json:"instance_id,omitempty"`
+	// IdempotencyKey é gerada pelo manager no primeiro envio e reaproveitada
+	// nos seguintes (WebSocket, fallback HTTP e retentativas)
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // HeartbeatData representa os dados enviados no heartbeat
@@ -40,6 +80,18 @@ type HeartbeatData struct {
 	SystemHealth    SystemHealthStatus `json:"system_health"`
 	PendingCommands int                `json:"pending_commands"`
 	ActiveTasks     []string           `json:"active_tasks,omitempty"`
+	Capabilities    *Capabilities      `json:"capabilities,omitempty"`
+	NewMachineID    string             `json:"new_machine_id,omitempty"`
+	InstanceID      string             `json:"instance_id,omitempty"`
+}
+
+// HeartbeatResponse representa a resposta do backend ao heartbeat
+type HeartbeatResponse struct {
+	// Maior sequência de inventário totalmente processada pelo backend;
+	// ausente em backends que ainda não confirmam inventários
+	ProcessedInventorySequence *int64 `json:"processed_inventory_sequence,omitempty"`
+	// Codificações aceitas pelo backend; ausente mantém a negociada no registro
+	AcceptedEncodings []string `json:"accepted_encodings,omitempty"`
 }
 
 // SystemHealthStatus representa o status de saúde do sistema
@@ -48,6 +100,9 @@ type SystemHealthStatus struct {
 	MemoryUsage float64 `json:"memory_usage_percent"`
 	DiskUsage   float64 `json:"disk_usage_percent"`
 	Status      string  `json:"status"` // "healthy", "warning", "critical"
+	// MemoryPressure é o nível de pressão de memória do macOS; quando
+	// presente, decide o estado da memória no lugar de MemoryUsage
+	MemoryPressure string `json:"memory_pressure,omitempty"`
 }
 
 // InventoryMessage representa uma mensagem de inventário
@@ -57,6 +112,7 @@ type InventoryMessage struct {
 	Timestamp time.Time               `json:"timestamp"`
 	Data      collector.InventoryData `json:"data"`
 	Checksum  string                  `json:"checksum,omitempty"`
+	Sequence  int64                   `json:"sequence,omitempty"`
 }
 
 // WebSocketMessage representa uma mensagem WebSocket genérica
@@ -68,6 +124,26 @@ type WebSocketMessage struct {
 	Error     string      `json:"error,omitempty"`
 }
 
+// Frames do protocolo de confirmação do WebSocket (ws_message_acks). Cada
+// mensagem recebida com ID, exceto ping, pong e os próprios acks, é
+// confirmada na hora com
+//
+//	{"type": "ack", "id": "<ID recebido>", "timestamp": "...", "data": {"message_type": "command"}}
+//
+// O servidor confirma do mesmo jeito as mensagens confiáveis do agente
+// (command_result e status_response), que ficam pendentes e são
+// retransmitidas a cada reconexão até o ack chegar. Acks não passam pelo
+// modo envelope: não têm conteúdo além do ID.
+const WSMessageTypeAck = "ack"
+
+// AckData é o corpo de um frame ack. MessageType é o tipo da mensagem
+// confirmada e desambigua IDs repetidos entre tipos (command_result e
+// command_progress de um mesmo comando); sem ele, o ack vale para todas as
+// mensagens pendentes com o ID.
+type AckData struct {
+	MessageType string `json:"message_type,omitempty"`
+}
+
 // AuthRequest representa uma requisição de autenticação
 type AuthRequest struct {
 	MachineID string `json:"machine_id"`
@@ -91,6 +167,15 @@ type RegistrationRequest struct {
 	HardwareInfo collector.HardwareInfo `json:"hardware_info"`
 	AgentVersion string                 `json:"agent_version"`
 	Timestamp    time.Time              `json:"timestamp"`
+	Capabilities *Capabilities          `json:"capabilities,omitempty"`
+	// NewMachineID é o machine_id que substituirá MachineID após o backend
+	// confirmar o vínculo (IdentityLinked); vazio fora de uma migração
+	NewMachineID string `json:"new_machine_id,omitempty"`
+	// InstanceID identifica o processo do agente (ver Config.InstanceID)
+	InstanceID string `json:"instance_id,omitempty"`
+	// Encodings são as codificações de corpo suportadas pelo agente, em
+	// ordem de preferência
+	Encodings []string `json:"encodings,omitempty"`
 }
 
 // RegistrationResponse representa a resposta de registro
@@ -99,6 +184,15 @@ type RegistrationResponse struct {
 	Message   string `json:"message,omitempty"`
 	MachineID string `json:"machine_id,omitempty"`
 	Token     string `json:"token,omitempty"`
+	// IdentityLinked confirma que o backend vinculou new_machine_id ao
+	// machine_id atual; o agente passa a usar o novo ID
+	IdentityLinked bool `json:"identity_linked,omitempty"`
+	// AcceptedEncodings são as codificações de corpo aceitas pelo backend;
+	// ausente (backend antigo) faz o agente enviar sem compressão
+	AcceptedEncodings []string `json:"accepted_encodings,omitempty"`
+	// EnvelopePublicKey é a chave X25519 (base64) para o modo envelope; só é
+	// aceita se bater com a impressão digital pinada na configuração
+	EnvelopePublicKey string `json:"envelope_public_key,omitempty"`
 }
 
 // ErrorResponse representa uma resposta de erro
@@ -125,6 +219,13 @@ type LogMessage struct {
 	Data      map[string]interface{} `json:"data,omitempty"`
 }
 
+// CommandCancel pede a interrupção de um comando em execução (mensagem
+// command_cancel); sem command_id nos dados, vale o ID da mensagem
+type CommandCancel struct {
+	CommandID string `json:"command_id"`
+	Reason    string `json:"reason,omitempty"`
+}
+
 // ConfigUpdate representa uma atualização de configuração
 type ConfigUpdate struct {
 	MachineID string                 `json:"machine_id"`
@@ -132,6 +233,25 @@ type ConfigUpdate struct {
 	Timestamp time.Time              `json:"timestamp"`
 }
 
+// ScheduleUpdate substitui os agendamentos definidos pelo backend (mensagem
+// schedule_update); uma lista vazia remove todos. As definições são
+// interpretadas pelo agente.
+type ScheduleUpdate struct {
+	MachineID string          `json:"machine_id,omitempty"`
+	Schedules json.RawMessage `json:"schedules"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// WhitelistUpdate é a mensagem whitelist_update: Payload é o JSON com a
+// versão e os specs, como texto, e Signature a assinatura Ed25519 (base64)
+// desses bytes, conferida pelo executor
+type WhitelistUpdate struct {
+	MachineID string    `json:"machine_id,omitempty"`
+	Payload   string    `json:"payload"`
+	Signature string    `json:"signature"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // FileTransferRequest representa uma requisição de transferência de arquivo
 type FileTransferRequest struct {
 	ID          string `json:"id"`
@@ -175,3 +295,33 @@ type RemoteTaskResponse struct {
 	EndTime   time.Time              `json:"end_time,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
 }
+
+// Artifact representa um arquivo enviado ao backend pelo caminho de upload de artefatos
+type Artifact struct {
+	MachineID   string                 `json:"machine_id"`
+	Kind        string                 `json:"kind"` // ex.: "inventory_snapshot"
+	Name        string                 `json:"name"`
+	ContentType string                 `json:"content_type"`
+	Encoding    string                 `json:"encoding,omitempty"` // ex.: "gzip"
+	Checksum    string                 `json:"checksum,omitempty"`
+	Data        []byte                 `json:"data"` // base64 no JSON
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+}
+
+// SnapshotInfo descreve um snapshot de inventário retido localmente
+type SnapshotInfo struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Checksum  string    `json:"checksum"`
+	SizeBytes int64     `json:"size_bytes"`
+	Sent      bool      `json:"sent"`
+}
+
+// SnapshotManifest oferece ao backend os snapshots retidos, para que ele possa
+// solicitar os perdidos com o comando "request_snapshot"
+type SnapshotManifest struct {
+	MachineID string         `json:"machine_id"`
+	Snapshots []SnapshotInfo `json:"snapshots"`
+	Timestamp time.Time      `json:"timestamp"`
+}
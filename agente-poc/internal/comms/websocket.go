@@ -2,56 +2,101 @@ package comms
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
+	"net/http"
 	"net/url"
 	"sync"
 	"time"
 
 	"agente-poc/internal/logging"
+	"agente-poc/internal/version"
 
 	"github.com/gorilla/websocket"
 )
 
 // WebSocketClient manages WebSocket connections with automatic reconnection
 type WebSocketClient struct {
-	url       string
-	token     string
-	machineID string
-	conn      *websocket.Conn
+	// endpoints é a lista de URLs; a reconexão usa a ativa, que troca depois
+	// de falhas consecutivas (a conexão é longa, o failover de DNS não basta)
+	endpoints  *EndpointSet
+	tokens     *TokenSet
+	machineID  string
+	instanceID string
+	// session é a conexão atual e seus pumps (nil desconectado), sob connMutex
+	session   *wsSession
 	connMutex sync.RWMutex
 	logger    logging.Logger
+	// tlsConfig é usado pelo Dialer em cada conexão (wss://)
+	tlsConfig *tls.Config
+	// proxy escolhe o proxy de cada conexão (ver ProxyFunc)
+	proxy func(*http.Request) (*url.URL, error)
 
 	// System health callback
 	systemHealthCallback func() map[string]interface{}
 
 	// Channels
 	commandChan chan Command
+	cancelChan  chan CommandCancel
 	messageChan chan WebSocketMessage
-	closeChan   chan struct{}
 
-	// Connection state
+	// Connection state. reconnecting pertence ao loop de reconexão: só ele o
+	// desliga, sob connMutex, ao terminar
 	connected    bool
 	reconnecting bool
 
 	// Configuration
 	reconnectDelay time.Duration
-	maxReconnects  int
+	maxBackoff     time.Duration
+	maxReconnects  int // -1 = sem limite
 	pingInterval   time.Duration
 	pongTimeout    time.Duration
+	writeBuffer    int // quadros aguardando o write pump
+
+	// Aceita comandos com tipos incorretos (migração do backend)
+	lenientDecoding bool
+	// Limites de tamanho de args/options aplicados na decodificação
+	commandLimits CommandLimits
+
+	// onPermanentFailure é chamado quando o loop de reconexão esgota as
+	// tentativas, com o último erro
+	onPermanentFailure func(err error)
+
+	// Confirmação de mensagens (ver WSMessageTypeAck): com messageAcks, as
+	// mensagens confiáveis ficam em acks até o servidor confirmar, e as que
+	// saem do buffer cheio vão para spillUnacked (nil descarta). dedup
+	// descarta comandos reentregues, com ou sem acks. generation conta as
+	// conexões, sob connMutex.
+	messageAcks  bool
+	acks         *ackTracker
+	dedup        *dedupWindow
+	spillUnacked func(QueuedMessage)
+	generation   uint64
+
+	// commandDropped recebe os comandos que não couberam em commandChan,
+	// para o Manager responder queue_full (nil só descarta)
+	commandDropped func(Command)
 
 	// Context and cancellation
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	// Metrics
-	metrics *WebSocketMetrics
+	// Metrics; metricsMutex protege metrics e é sempre o último lock
+	// adquirido (pode ser tomado com connMutex já travado)
+	metrics      *WebSocketMetrics
+	metricsMutex sync.Mutex
 
 	// Message queue for offline messages
 	messageQueue []WebSocketMessage
 	queueMutex   sync.Mutex
 	maxQueueSize int
+	// flushMutex serializa os flushes da fila offline: o de uma conexão nova
+	// espera o da anterior devolver o que sobrou
+	flushMutex sync.Mutex
 }
 
 // WebSocketMetrics tracks WebSocket client metrics
@@ -69,45 +114,125 @@ type WebSocketMetrics struct {
 	TotalUptime        time.Duration
 	ConnectionErrors   int64
 	MessageErrors      int64
+
+	// Reconexão: a última espera usada e as vezes em que as tentativas se
+	// esgotaram
+	LastReconnectDelay time.Duration
+	PermanentFailures  int64
+
+	// Confirmação de mensagens: acks enviados e recebidos, mensagens
+	// retransmitidas após reconectar, mensagens sem ack enviadas para a
+	// fila offline (buffer cheio) e comandos reentregues descartados
+	AcksSent          int64
+	AcksReceived      int64
+	Retransmits       int64
+	UnackedSpilled    int64
+	DuplicateCommands int64
+
+	// Envios recusados com o buffer do write pump cheio
+	WriteBufferFull int64
 }
 
 // WebSocketConfig configuration for WebSocket client
 type WebSocketConfig struct {
 	URL                  string
+	FailoverURLs         []string     // tentadas depois de URL, em ordem (ver EndpointSet)
+	Endpoints            *EndpointSet // tem precedência sobre URL e FailoverURLs
 	Token                string
+	Tokens               *TokenSet // tem precedência sobre Token
 	MachineID            string
-	ReconnectDelay       time.Duration
-	MaxReconnects        int
+	ReconnectDelay       time.Duration // espera base do backoff exponencial
+	MaxBackoff           time.Duration // teto da espera (0 = DefaultWSMaxBackoff)
+	MaxReconnects        int           // -1 = tentar para sempre
 	PingInterval         time.Duration
 	PongTimeout          time.Duration
 	MaxQueueSize         int
 	Logger               logging.Logger
 	SystemHealthCallback func() map[string]interface{}
+	LenientDecoding      bool
+	CommandLimits        CommandLimits
+	InstanceID           string // enviado em X-Agent-Instance-ID no handshake
+	TLSSkipVerify        bool
+	TLSFiles                      // certificado de cliente e CA para mTLS (opcionais)
+	PinnedCertificates   []string // SHA-256 (hex) exigidos na cadeia do servidor (ver PinCertificates)
+	ProxyURL             string   // substitui HTTP(S)_PROXY do ambiente (ver ProxyFunc)
+	// OnPermanentFailure é chamado quando a reconexão desiste (MaxReconnects
+	// tentativas sem sucesso)
+	OnPermanentFailure func(err error)
+	// MessageAcks liga o protocolo de confirmação (ver WSMessageTypeAck)
+	MessageAcks bool
+	MaxUnacked  int           // mensagens sem ack em memória (0 = DefaultWSMaxUnacked)
+	DedupWindow time.Duration // janela de comandos reentregues (0 = DefaultWSDedupWindow)
+	WriteBuffer int           // quadros aguardando o write pump (0 = DefaultWSWriteBuffer)
 }
 
-// NewWebSocketClient creates a new WebSocket client
-func NewWebSocketClient(config WebSocketConfig) *WebSocketClient {
+// DefaultWSMaxBackoff é o teto padrão da espera entre reconexões
+const DefaultWSMaxBackoff = 5 * time.Minute
+
+// NewWebSocketClient creates a new WebSocket client. Os arquivos de
+// TLSFiles são carregados e ProxyURL é validado aqui; um erro neles impede
+// a criação.
+func NewWebSocketClient(config WebSocketConfig) (*WebSocketClient, error) {
+	tlsConfig, err := BuildTLSConfig(config.TLSFiles, config.TLSSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	PinCertificates(tlsConfig, config.PinnedCertificates)
+	proxy, err := ProxyFunc(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
+	tokens := config.Tokens
+	if tokens == nil {
+		tokens = NewTokenSet(config.Token)
+	}
+	endpoints := config.Endpoints
+	if endpoints == nil {
+		endpoints = NewEndpointSet(append([]string{config.URL}, config.FailoverURLs...), 0, nil)
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultWSMaxBackoff
+	}
+	if config.MaxBackoff < config.ReconnectDelay {
+		config.MaxBackoff = config.ReconnectDelay
+	}
+
 	return &WebSocketClient{
-		url:                  config.URL,
-		token:                config.Token,
+		endpoints:            endpoints,
+		tokens:               tokens,
 		machineID:            config.MachineID,
+		instanceID:           config.InstanceID,
 		logger:               config.Logger,
+		tlsConfig:            tlsConfig,
+		proxy:                proxy,
 		systemHealthCallback: config.SystemHealthCallback,
+		lenientDecoding:      config.LenientDecoding,
+		commandLimits:        config.CommandLimits,
 		commandChan:          make(chan Command, 100),
+		cancelChan:           make(chan CommandCancel, 100),
 		messageChan:          make(chan WebSocketMessage, 100),
-		closeChan:            make(chan struct{}),
 		reconnectDelay:       config.ReconnectDelay,
+		maxBackoff:           config.MaxBackoff,
 		maxReconnects:        config.MaxReconnects,
+		onPermanentFailure:   config.OnPermanentFailure,
+		messageAcks:          config.MessageAcks,
+		acks:                 newAckTracker(config.MaxUnacked),
+		dedup:                newDedupWindow(config.DedupWindow),
 		pingInterval:         config.PingInterval,
 		pongTimeout:          config.PongTimeout,
+		writeBuffer:          config.WriteBuffer,
 		ctx:                  ctx,
 		cancel:               cancel,
 		metrics:              &WebSocketMetrics{},
 		messageQueue:         make([]WebSocketMessage, 0),
 		maxQueueSize:         config.MaxQueueSize,
-	}
+	}, nil
 }
 
 // Connect establishes WebSocket connection
@@ -119,201 +244,305 @@ func (ws *WebSocketClient) Connect() error {
 		return nil
 	}
 
-	ws.logger.Info("Connecting to WebSocket server: %s", ws.url)
+	endpoint := ws.endpoints.Active()
+	ws.logger.Info("Connecting to WebSocket server: %s", endpoint)
 
 	// Parse URL
-	u, err := url.Parse(ws.url)
+	u, err := url.Parse(endpoint)
 	if err != nil {
 		return fmt.Errorf("invalid WebSocket URL: %w", err)
 	}
 
-	// Create headers
-	headers := make(map[string][]string)
-	if ws.token != "" {
-		headers["Authorization"] = []string{"Bearer " + ws.token}
-	}
-	headers["User-Agent"] = []string{"MacOS-Agent/1.0.0"}
-
 	// Establish connection
 	dialer := websocket.Dialer{
 		HandshakeTimeout: 30 * time.Second,
+		TLSClientConfig:  ws.tlsConfig,
+		Proxy:            ws.proxy,
+	}
+
+	// Em 401 no handshake, tentar os demais tokens e promover o aceito
+	candidates := ws.tokens.Candidates()
+	if len(candidates) == 0 {
+		candidates = []string{""}
 	}
 
-	conn, _, err := dialer.Dial(u.String(), headers)
+	var conn *websocket.Conn
+	var resp *http.Response
+	for i, token := range candidates {
+		headers := make(map[string][]string)
+		if token != "" {
+			headers["Authorization"] = []string{"Bearer " + token}
+		}
+		headers["User-Agent"] = []string{version.UserAgent()}
+		if ws.instanceID != "" {
+			headers["X-Agent-Instance-ID"] = []string{ws.instanceID}
+		}
+
+		conn, resp, err = dialer.Dial(u.String(), headers)
+		if err == nil {
+			if i > 0 && ws.tokens.Promote(token) {
+				ws.logger.Info("WebSocket accepted a fallback token (%s), promoted to active", TokenFingerprint(token))
+			}
+			break
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized || i == len(candidates)-1 {
+			break
+		}
+		ws.logger.Warning("WebSocket token %s rejected (401), trying next configured token", TokenFingerprint(token))
+	}
 	if err != nil {
-		ws.metrics.FailedConnects++
-		ws.metrics.ConnectionErrors++
+		ws.updateMetrics(func(m *WebSocketMetrics) {
+			m.FailedConnects++
+			m.ConnectionErrors++
+		})
+		// Sem resposta ou 5xx no handshake contam para o failover; 401 e
+		// afins vêm de um servidor de pé
+		if (resp == nil && !IsProxyError(err)) || (resp != nil && resp.StatusCode >= 500) {
+			if ws.endpoints.Failed(endpoint) {
+				ws.logger.Debug("WebSocket endpoint %s failing, next attempt uses %s", endpoint, ws.endpoints.Active())
+			}
+		}
 		return fmt.Errorf("failed to connect to WebSocket: %w", err)
 	}
+	ws.endpoints.Succeeded(endpoint)
 
-	ws.conn = conn
+	session := newWSSession(conn, ws.writeBuffer)
+	ws.session = session
 	ws.connected = true
-	ws.reconnecting = false
-	ws.metrics.TotalConnections++
-	ws.metrics.SuccessfulConnects++
-	ws.metrics.LastConnectTime = time.Now()
+	ws.generation++
+	generation := ws.generation
+	ws.updateMetrics(func(m *WebSocketMetrics) {
+		m.TotalConnections++
+		m.SuccessfulConnects++
+		m.LastConnectTime = time.Now()
+	})
 
 	ws.logger.Info("WebSocket connection established")
 
-	// Start handlers
-	go ws.handleMessages()
-	go ws.handlePing()
+	// Um leitor e um escritor por conexão (ver wsSession)
+	go ws.readPump(session)
+	go ws.writePump(session)
+	go ws.handlePing(session)
 
-	// Send queued messages
-	go ws.sendQueuedMessages()
+	// Retransmitir as mensagens sem ack e enviar a fila offline, nessa ordem
+	go func() {
+		ws.retransmitUnacked(generation)
+		ws.sendQueuedMessages()
+	}()
 
 	return nil
 }
 
-// Disconnect closes the WebSocket connection
+// Disconnect closes the WebSocket connection. O write pump escreve os
+// quadros já no buffer (até wsDrainTimeout) antes de a conexão fechar; ao
+// retornar, ele terminou e o que não foi escrito voltou para a fila offline
+// ou foi descartado.
 func (ws *WebSocketClient) Disconnect() error {
-	ws.connMutex.Lock()
-	defer ws.connMutex.Unlock()
-
-	if !ws.connected {
+	session := ws.detachSession(nil)
+	if session == nil {
 		return nil
 	}
 
 	ws.logger.Info("Disconnecting from WebSocket server")
 
-	// Close connection
-	if ws.conn != nil {
-		_ = ws.conn.Close()
-		ws.conn = nil
-	}
-
-	ws.connected = false
-	ws.metrics.LastDisconnectTime = time.Now()
-
-	// Signal close
+	session.stop(true)
 	select {
-	case ws.closeChan <- struct{}{}:
-	default:
+	case <-session.done:
+	case <-time.After(wsDrainTimeout):
+		ws.logger.Warning("WebSocket write buffer not flushed within %s, closing anyway", wsDrainTimeout)
 	}
+	_ = session.conn.Close()
+	<-session.done
 
 	return nil
 }
 
+// detachSession desliga a sessão atual (só se for expected, quando não
+// nil) e a retorna; nil se não havia sessão ou ela já foi trocada
+func (ws *WebSocketClient) detachSession(expected *wsSession) *wsSession {
+	ws.connMutex.Lock()
+	defer ws.connMutex.Unlock()
+
+	session := ws.session
+	if session == nil || (expected != nil && session != expected) {
+		return nil
+	}
+	ws.session = nil
+	ws.connected = false
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.LastDisconnectTime = time.Now() })
+	return session
+}
+
 // Close closes the WebSocket client and cleans up resources
 func (ws *WebSocketClient) Close() error {
 	ws.cancel()
 	return ws.Disconnect()
 }
 
-// handleMessages handles incoming WebSocket messages
-func (ws *WebSocketClient) handleMessages() {
+// readPump é o único leitor da conexão da sessão. Um erro de leitura
+// (inclusive nada recebido dentro de readTimeout, já que um timeout deixa a
+// conexão inutilizável) encerra a sessão e inicia a reconexão; se a sessão
+// já foi desligada (Disconnect), só termina.
+func (ws *WebSocketClient) readPump(s *wsSession) {
 	defer func() {
 		if r := recover(); r != nil {
 			ws.logger.Error("WebSocket message handler panic: %v", r)
 		}
 	}()
 
+	timeout := ws.readTimeout()
 	for {
-		select {
-		case <-ws.ctx.Done():
-			return
-		case <-ws.closeChan:
-			return
-		default:
-			if !ws.isConnected() {
-				time.Sleep(1 * time.Second)
-				continue
-			}
+		_ = s.conn.SetReadDeadline(time.Now().Add(timeout))
 
+		_, messageData, err := s.conn.ReadMessage()
+		if err != nil {
 			ws.connMutex.RLock()
-			conn := ws.conn
-			connected := ws.connected
+			current := ws.session == s
 			ws.connMutex.RUnlock()
-
-			if !connected || conn == nil {
-				time.Sleep(1 * time.Second)
-				continue
+			if !current {
+				return // sessão desligada por Disconnect
 			}
 
-			// Set read deadline - usar um timeout mais longo
-			conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-
-			// Read message
-			_, messageData, err := conn.ReadMessage()
-			if err != nil {
-				// Verificar se é timeout ou erro de conexão
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					ws.logger.Debug("WebSocket read timeout (normal)")
-					continue
-				}
-
-				ws.logger.Error("Error reading WebSocket message: %v", err)
-				ws.metrics.MessageErrors++
-
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					ws.logger.Warning("WebSocket connection closed unexpectedly")
-					ws.handleDisconnect()
-					return
-				}
-
-				// Para outros erros, também desconectar para evitar loops
-				ws.logger.Warning("WebSocket read error, disconnecting")
-				ws.handleDisconnect()
-				return
+			ws.updateMetrics(func(m *WebSocketMetrics) { m.MessageErrors++ })
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				ws.logger.Warning("No WebSocket messages within %s, disconnecting", timeout)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				ws.logger.Warning("WebSocket connection closed unexpectedly: %v", err)
+			} else {
+				ws.logger.Warning("WebSocket read error, disconnecting: %v", err)
 			}
+			ws.handleDisconnect(s)
+			return
+		}
 
-			ws.metrics.MessagesReceived++
+		ws.updateMetrics(func(m *WebSocketMetrics) { m.MessagesReceived++ })
 
-			// Parse message
-			var message WebSocketMessage
-			if err := json.Unmarshal(messageData, &message); err != nil {
-				ws.logger.Error("Error parsing WebSocket message: %v", err)
-				ws.metrics.MessageErrors++
-				continue
-			}
+		// Parse message
+		message, err := parseWebSocketMessage(messageData)
+		if err != nil {
+			ws.logger.Error("Error parsing WebSocket message: %v", err)
+			ws.updateMetrics(func(m *WebSocketMetrics) { m.MessageErrors++ })
+			continue
+		}
+
+		if message.Type == WSMessageTypeAck {
+			ws.handleAck(message)
+			continue
+		}
+		// Comandos só recebem ack depois de aceitos (ver handleCommand)
+		if message.Type != "command" {
+			ws.sendAck(message)
+		}
 
-			// Handle message based on type
-			switch message.Type {
-			case "command":
-				ws.handleCommand(message)
-			case "ping":
-				ws.handlePingMessage(message)
-			case "pong":
-				ws.handlePongMessage(message)
+		// Handle message based on type
+		switch message.Type {
+		case "command":
+			ws.handleCommand(message)
+		case "command_cancel":
+			ws.handleCommandCancel(message)
+		case "ping":
+			ws.handlePingMessage(message)
+		case "pong":
+			ws.handlePongMessage(message)
+		default:
+			// Forward to message channel
+			select {
+			case ws.messageChan <- message:
 			default:
-				// Forward to message channel
-				select {
-				case ws.messageChan <- message:
-				default:
-					ws.logger.Warning("Message channel full, dropping message")
-				}
+				ws.logger.Warning("Message channel full, dropping message")
 			}
 		}
 	}
 }
 
+// parseWebSocketMessage decodifica um frame recebido. Em comandos, Data fica
+// como json.RawMessage para DecodeCommand verificar os limites antes de
+// materializar args e options; nos demais tipos, Data é decodificado como
+// antes.
+func parseWebSocketMessage(frame []byte) (WebSocketMessage, error) {
+	var envelope struct {
+		WebSocketMessage
+		Data json.RawMessage `json:"data,omitempty"`
+	}
+	if err := json.Unmarshal(frame, &envelope); err != nil {
+		return WebSocketMessage{}, err
+	}
+
+	message := envelope.WebSocketMessage
+	if len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return message, nil
+	}
+	if message.Type == "command" {
+		message.Data = envelope.Data
+		return message, nil
+	}
+	if err := json.Unmarshal(envelope.Data, &message.Data); err != nil {
+		return WebSocketMessage{}, err
+	}
+	return message, nil
+}
+
 // handleCommand processes incoming commands
 func (ws *WebSocketClient) handleCommand(message WebSocketMessage) {
 	ws.logger.Debug("Received command: %s", message.Type)
 
-	// Parse command data
-	commandData, ok := message.Data.(map[string]interface{})
-	if !ok {
-		ws.logger.Error("Invalid command data format")
+	// Reentrega (ack perdido ou reconexão): o comando já foi aceito, então
+	// só o ack sai de novo, para o servidor parar de reenviar
+	now := time.Now()
+	if ws.dedup.duplicate(message.ID, now) {
+		ws.logger.Debug("Duplicate command %s dropped", message.ID)
+		ws.updateMetrics(func(m *WebSocketMetrics) { m.DuplicateCommands++ })
+		ws.sendAck(message)
 		return
 	}
 
-	// Convert to Command struct
-	command := Command{
-		ID:        message.ID,
-		Type:      getString(commandData, "type"),
-		Command:   getString(commandData, "command"),
-		Args:      getStringSlice(commandData, "args"),
-		Options:   getMap(commandData, "options"),
-		Timeout:   getInt(commandData, "timeout"),
-		Timestamp: time.Now(),
+	// Decodificação estrita: comandos com campos de tipo errado seguem adiante
+	// com DecodeError para que o agente reporte a rejeição ao backend
+	command, err := DecodeCommand(message.ID, message.Data, ws.lenientDecoding, ws.commandLimits)
+	if err != nil {
+		var oversized *OversizedCommandError
+		if errors.As(err, &oversized) && oversized.Preview != "" {
+			ws.logger.Warning("Oversized command %s: %v (preview: %s)", message.ID, err, oversized.Preview)
+		} else {
+			ws.logger.Warning("Invalid command %s: %v", message.ID, err)
+		}
+		command.DecodeError = err
 	}
+	command.Origin = CommandOriginWebSocket
 
-	// Send to command channel
+	// Só o comando aceito recebe ack e entra na janela de duplicatas; o
+	// descartado fica sem ack, recebe queue_full e pode ser reenviado
 	select {
 	case ws.commandChan <- command:
+		ws.dedup.record(message.ID, now)
+		ws.sendAck(message)
+	default:
+		if ws.commandDropped == nil {
+			ws.logger.Warning("Command channel full, dropping command %s", command.ID)
+			return
+		}
+		ws.commandDropped(command)
+	}
+}
+
+// handleCommandCancel repassa um pedido de cancelamento de comando
+func (ws *WebSocketClient) handleCommandCancel(message WebSocketMessage) {
+	var cancel CommandCancel
+	if raw, err := json.Marshal(message.Data); err == nil {
+		_ = json.Unmarshal(raw, &cancel)
+	}
+	if cancel.CommandID == "" {
+		cancel.CommandID = message.ID
+	}
+	if cancel.CommandID == "" {
+		ws.logger.Warning("Command cancel without command_id ignored")
+		return
+	}
+
+	select {
+	case ws.cancelChan <- cancel:
 	default:
-		ws.logger.Warning("Command channel full, dropping command")
+		ws.logger.Warning("Cancel channel full, dropping cancel for command %s", cancel.CommandID)
 	}
 }
 
@@ -325,7 +554,7 @@ func (ws *WebSocketClient) handlePingMessage(message WebSocketMessage) {
 	pongData := map[string]interface{}{
 		"machine_id":    ws.getMachineID(),
 		"status":        "online",
-		"agent_version": "1.0.0",
+		"agent_version": version.Version,
 		"timestamp":     time.Now(),
 		"ping_id":       message.ID,
 	}
@@ -356,7 +585,7 @@ func (ws *WebSocketClient) handlePingMessage(message WebSocketMessage) {
 // handlePongMessage handles pong messages
 func (ws *WebSocketClient) handlePongMessage(message WebSocketMessage) {
 	ws.logger.Debug("Received structured pong")
-	ws.metrics.PongsReceived++
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.PongsReceived++ })
 
 	// Processar dados estruturados do pong se disponíveis
 	if message.Data != nil {
@@ -372,8 +601,8 @@ func (ws *WebSocketClient) handlePongMessage(message WebSocketMessage) {
 	}
 }
 
-// handlePing sends periodic ping messages
-func (ws *WebSocketClient) handlePing() {
+// handlePing sends periodic ping messages enquanto a sessão estiver ativa
+func (ws *WebSocketClient) handlePing(s *wsSession) {
 	ticker := time.NewTicker(ws.pingInterval)
 	defer ticker.Stop()
 
@@ -381,106 +610,219 @@ func (ws *WebSocketClient) handlePing() {
 		select {
 		case <-ws.ctx.Done():
 			return
-		case <-ws.closeChan:
+		case <-s.closing:
 			return
 		case <-ticker.C:
-			if ws.isConnected() {
-				// Criar ping estruturado com dados de sistema
-				pingData := map[string]interface{}{
-					"machine_id":    ws.getMachineID(),
-					"status":        "online",
-					"agent_version": "1.0.0",
-					"timestamp":     time.Now(),
-					"ping_seq":      time.Now().UnixNano(),
-				}
+			// Criar ping estruturado com dados de sistema
+			pingData := map[string]interface{}{
+				"machine_id":    ws.getMachineID(),
+				"status":        "online",
+				"agent_version": version.Version,
+				"timestamp":     time.Now(),
+				"ping_seq":      time.Now().UnixNano(),
+			}
 
-				// Adicionar dados de sistema health se callback disponível
-				if ws.systemHealthCallback != nil {
-					if systemHealth := ws.systemHealthCallback(); systemHealth != nil {
-						pingData["system_health"] = systemHealth
-					}
+			// Adicionar dados de sistema health se callback disponível
+			if ws.systemHealthCallback != nil {
+				if systemHealth := ws.systemHealthCallback(); systemHealth != nil {
+					pingData["system_health"] = systemHealth
 				}
+			}
 
-				pingMessage := WebSocketMessage{
-					Type:      "ping",
-					ID:        fmt.Sprintf("ping_%d", time.Now().UnixNano()),
-					Timestamp: time.Now(),
-					Data:      pingData,
-				}
+			pingMessage := WebSocketMessage{
+				Type:      "ping",
+				ID:        fmt.Sprintf("ping_%d", time.Now().UnixNano()),
+				Timestamp: time.Now(),
+				Data:      pingData,
+			}
 
-				if err := ws.SendMessage(pingMessage); err != nil {
-					ws.logger.Error("Error sending ping: %v", err)
-				} else {
-					ws.logger.Debug("Structured ping sent with system data")
-					ws.metrics.PingsSent++
-				}
+			if err := ws.SendMessage(pingMessage); err != nil {
+				ws.logger.Error("Error sending ping: %v", err)
+			} else {
+				ws.logger.Debug("Structured ping sent with system data")
+				ws.updateMetrics(func(m *WebSocketMetrics) { m.PingsSent++ })
 			}
 		}
 	}
 }
 
-// handleDisconnect handles connection loss and triggers reconnection
-func (ws *WebSocketClient) handleDisconnect() {
+// handleDisconnect handles connection loss and triggers reconnection. A
+// sessão perdida é encerrada sem escrever o buffer (a conexão caiu); se ela
+// já não é a atual, nada muda.
+func (ws *WebSocketClient) handleDisconnect(s *wsSession) {
+	if ws.detachSession(s) == nil {
+		return
+	}
+	s.stop(false)
+	_ = s.conn.Close()
+
+	ws.Reconnect()
+}
+
+// Reconnect inicia o loop de reconexão, se ainda não houver um em andamento
+func (ws *WebSocketClient) Reconnect() {
 	ws.connMutex.Lock()
-	ws.connected = false
-	ws.connMutex.Unlock()
+	defer ws.connMutex.Unlock()
 
-	if ws.reconnecting {
+	if ws.reconnecting || ws.connected || ws.ctx.Err() != nil {
 		return
 	}
-
 	ws.reconnecting = true
 	ws.logger.Info("Starting reconnection process")
+	go ws.reconnectLoop()
+}
 
-	go func() {
-		for attempt := 0; attempt < ws.maxReconnects; attempt++ {
-			select {
-			case <-ws.ctx.Done():
+// reconnectLoop tenta reconectar com backoff exponencial até conseguir, o
+// cliente ser fechado ou as tentativas se esgotarem. Um Connect externo
+// bem-sucedido também encerra o loop; se essa conexão cair antes de o loop
+// perceber, o backoff recomeça do início.
+func (ws *WebSocketClient) reconnectLoop() {
+	var lastErr error
+	successes := ws.successfulConnects()
+
+	for attempt := 0; ws.maxReconnects < 0 || attempt < ws.maxReconnects; attempt++ {
+		if ws.ctx.Err() != nil {
+			ws.stopReconnecting()
+			return
+		}
+		if ws.finishReconnect() {
+			return
+		}
+
+		if ws.maxReconnects < 0 {
+			ws.logger.Info("Reconnection attempt %d", attempt+1)
+		} else {
+			ws.logger.Info("Reconnection attempt %d/%d", attempt+1, ws.maxReconnects)
+		}
+		endpoint := ws.endpoints.Active()
+		if lastErr = ws.Connect(); lastErr == nil {
+			ws.logger.Info("Reconnection successful")
+			if ws.finishReconnect() {
 				return
-			default:
-				ws.logger.Info("Reconnection attempt %d/%d", attempt+1, ws.maxReconnects)
-
-				if err := ws.Connect(); err != nil {
-					ws.logger.Error("Reconnection attempt %d failed: %v", attempt+1, err)
-					ws.metrics.Reconnects++
-
-					if attempt < ws.maxReconnects-1 {
-						time.Sleep(ws.reconnectDelay * time.Duration(attempt+1))
-					}
-				} else {
-					ws.logger.Info("Reconnection successful")
-					return
-				}
 			}
+			// Caiu de novo antes de o loop terminar
+			successes, attempt = ws.successfulConnects(), -1
+			continue
 		}
 
-		ws.logger.Error("Max reconnection attempts exceeded")
-		ws.reconnecting = false
-	}()
+		ws.logger.Error("Reconnection attempt %d failed: %v", attempt+1, lastErr)
+		delay := backoffDelay(ws.reconnectDelay, ws.maxBackoff, attempt, rand.Float64())
+		if ws.endpoints.Active() != endpoint {
+			// Endpoint trocado: tentar o próximo sem esperar o backoff acumulado
+			delay = backoffDelay(ws.reconnectDelay, ws.maxBackoff, 0, rand.Float64())
+		}
+		ws.updateMetrics(func(m *WebSocketMetrics) {
+			m.Reconnects++
+			m.LastReconnectDelay = delay
+		})
+
+		select {
+		case <-ws.ctx.Done():
+			ws.stopReconnecting()
+			return
+		case <-time.After(delay):
+		}
+
+		if current := ws.successfulConnects(); current != successes {
+			successes, attempt = current, -1
+		}
+	}
+
+	ws.logger.Error("Max reconnection attempts exceeded")
+	ws.connMutex.Lock()
+	ws.reconnecting = false
+	ws.connMutex.Unlock()
+	ws.updateMetrics(func(m *WebSocketMetrics) { m.PermanentFailures++ })
+
+	if ws.onPermanentFailure != nil {
+		ws.onPermanentFailure(lastErr)
+	}
 }
 
-// SendMessage sends a message via WebSocket
-func (ws *WebSocketClient) SendMessage(message WebSocketMessage) error {
-	if !ws.isConnected() {
-		// Queue message if not connected
-		ws.queueMessage(message)
-		return fmt.Errorf("not connected, message queued")
+// finishReconnect encerra o loop de reconexão se já houver conexão; a
+// verificação e o desligamento de reconnecting são atômicos, para que uma
+// queda logo em seguida inicie um novo loop
+func (ws *WebSocketClient) finishReconnect() bool {
+	ws.connMutex.Lock()
+	defer ws.connMutex.Unlock()
+
+	if !ws.connected {
+		return false
+	}
+	ws.reconnecting = false
+	return true
+}
+
+// stopReconnecting desliga reconnecting quando o cliente é fechado
+func (ws *WebSocketClient) stopReconnecting() {
+	ws.connMutex.Lock()
+	ws.reconnecting = false
+	ws.connMutex.Unlock()
+}
+
+// successfulConnects lê o contador de conexões bem-sucedidas
+func (ws *WebSocketClient) successfulConnects() int64 {
+	ws.metricsMutex.Lock()
+	defer ws.metricsMutex.Unlock()
+	return ws.metrics.SuccessfulConnects
+}
+
+// backoffDelay é a espera após a falha da tentativa attempt (a partir de
+// 0): base·2^attempt limitada a max, sorteada entre a metade e o valor
+// cheio (jitter em [0, 1)) para que agentes derrubados juntos não voltem
+// em rajada. Usado na reconexão do WebSocket e nas retentativas HTTP.
+func backoffDelay(base, max time.Duration, attempt int, jitter float64) time.Duration {
+	delay := base
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
 	}
+	half := delay / 2
+	return half + time.Duration(jitter*float64(delay-half))
+}
 
+// DropConnection fecha a conexão atual sem aviso ao servidor, simulando uma
+// queda de rede; o leitor detecta o erro e inicia a reconexão
+func (ws *WebSocketClient) DropConnection() {
 	ws.connMutex.RLock()
-	defer ws.connMutex.RUnlock()
+	session := ws.session
+	ws.connMutex.RUnlock()
+
+	if session != nil {
+		_ = session.conn.Close()
+	}
+}
 
-	// Set write deadline
-	ws.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+// errNotConnected is returned by writeMessage when there is no connection
+var errNotConnected = errors.New("websocket not connected")
 
-	// Send message
-	if err := ws.conn.WriteJSON(message); err != nil {
-		ws.metrics.MessageErrors++
-		return fmt.Errorf("failed to send message: %w", err)
+// SendMessage enfileira uma mensagem para o write pump e retorna sem
+// esperar a escrita. Sem conexão, ela vai para a fila offline (e volta para
+// lá se a conexão cair antes da escrita); com o buffer do write pump cheio,
+// retorna ErrWriteBufferFull.
+func (ws *WebSocketClient) SendMessage(message WebSocketMessage) error {
+	err := ws.enqueueMessage(message, true, false)
+	if errors.Is(err, errNotConnected) {
+		// Queue message if not connected
+		ws.queueMessage(message)
+		return fmt.Errorf("not connected, message queued")
 	}
+	return err
+}
 
-	ws.metrics.MessagesSent++
-	return nil
+// writeMessage enfileira uma mensagem para o write pump sem passar pela
+// fila offline: sem conexão retorna errNotConnected, e a mensagem é
+// descartada se a conexão cair antes da escrita
+func (ws *WebSocketClient) writeMessage(message WebSocketMessage) error {
+	return ws.enqueueMessage(message, false, false)
+}
+
+// writeRaw envia um texto já serializado (ex.: o registro da máquina logo
+// após conectar)
+func (ws *WebSocketClient) writeRaw(data []byte) error {
+	return ws.enqueueFrame(wsFrame{data: data}, false)
 }
 
 // queueMessage adds a message to the offline queue
@@ -496,18 +838,69 @@ func (ws *WebSocketClient) queueMessage(message WebSocketMessage) {
 	ws.messageQueue = append(ws.messageQueue, message)
 }
 
-// sendQueuedMessages sends all queued messages
-func (ws *WebSocketClient) sendQueuedMessages() {
+// requeueMessages devolve mensagens não enviadas à frente da fila offline,
+// antes das enfileiradas nesse meio tempo, mantendo as maxQueueSize mais
+// novas
+func (ws *WebSocketClient) requeueMessages(messages []WebSocketMessage) {
 	ws.queueMutex.Lock()
 	defer ws.queueMutex.Unlock()
 
-	for _, message := range ws.messageQueue {
-		if err := ws.SendMessage(message); err != nil {
-			ws.logger.Error("Failed to send queued message: %v", err)
+	queue := make([]WebSocketMessage, 0, len(messages)+len(ws.messageQueue))
+	queue = append(queue, messages...)
+	queue = append(queue, ws.messageQueue...)
+	if len(queue) > ws.maxQueueSize {
+		queue = queue[len(queue)-ws.maxQueueSize:]
+	}
+	ws.messageQueue = queue
+}
+
+// sendQueuedMessages esvazia a fila offline na sessão atual. A fila é
+// retirada sob o lock e enviada fora dele, então uma queda no meio do flush
+// não trava em queueMessage. Cada mensagem espera espaço no buffer do write
+// pump; o flush para na primeira falha (sessão encerrada).
+//
+// Só o flush devolve mensagens à fila enquanto ele roda: o write pump lhe
+// entrega as que abandonar (ver abandonFrames), e o flush, depois de o pump
+// terminar, devolve essas seguidas das que não chegaram ao buffer, numa
+// única chamada e na ordem original.
+func (ws *WebSocketClient) sendQueuedMessages() {
+	ws.flushMutex.Lock()
+	defer ws.flushMutex.Unlock()
+
+	ws.connMutex.RLock()
+	session := ws.session
+	ws.connMutex.RUnlock()
+	if session == nil {
+		return
+	}
+
+	ws.queueMutex.Lock()
+	pending := ws.messageQueue
+	ws.messageQueue = nil
+	ws.queueMutex.Unlock()
+
+	session.beginFlush()
+	for i, message := range pending {
+		data, err := json.Marshal(message)
+		if err != nil {
+			ws.logger.Warning("Dropping queued message %s that cannot be encoded: %v", message.ID, err)
+			continue
+		}
+		if err := session.enqueue(wsFrame{data: data, message: &message}, true); err != nil {
+			// O pump abandona o buffer ao terminar; esperar garante que o
+			// que ele abandonou já foi entregue ao flush
+			<-session.done
+			requeue := append(session.endFlush(), pending[i:]...)
+			ws.logger.Warning("Failed to send queued message, %d message(s) kept for the next reconnect: %v", len(requeue), err)
+			ws.requeueMessages(requeue)
+			return
 		}
 	}
 
-	ws.messageQueue = ws.messageQueue[:0]
+	// A sessão pode ter caído depois do último quadro entrar no buffer
+	if abandoned := session.endFlush(); len(abandoned) > 0 {
+		ws.requeueMessages(abandoned)
+	}
 }
 
 // CommandChannel returns the command channel
@@ -515,6 +908,11 @@ func (ws *WebSocketClient) CommandChannel() <-chan Command {
 	return ws.commandChan
 }
 
+// CancelChannel returns the command cancel channel
+func (ws *WebSocketClient) CancelChannel() <-chan CommandCancel {
+	return ws.cancelChan
+}
+
 // MessageChannel returns the message channel
 func (ws *WebSocketClient) MessageChannel() <-chan WebSocketMessage {
 	return ws.messageChan
@@ -527,21 +925,42 @@ func (ws *WebSocketClient) isConnected() bool {
 	return ws.connected
 }
 
+// Endpoint retorna a URL do WebSocket em uso (ou da próxima conexão)
+func (ws *WebSocketClient) Endpoint() string {
+	return ws.endpoints.Active()
+}
+
+// EndpointStatus retorna os endpoints configurados e o ativo
+func (ws *WebSocketClient) EndpointStatus() EndpointStatus {
+	return ws.endpoints.Status()
+}
+
 // IsConnected returns connection status
 func (ws *WebSocketClient) IsConnected() bool {
 	return ws.isConnected()
 }
 
-// GetMetrics returns WebSocket metrics
+// GetMetrics returns a consistent snapshot of the WebSocket metrics
 func (ws *WebSocketClient) GetMetrics() WebSocketMetrics {
+	ws.metricsMutex.Lock()
+	defer ws.metricsMutex.Unlock()
 	return *ws.metrics
 }
 
 // ResetMetrics resets WebSocket metrics
 func (ws *WebSocketClient) ResetMetrics() {
+	ws.metricsMutex.Lock()
+	defer ws.metricsMutex.Unlock()
 	ws.metrics = &WebSocketMetrics{}
 }
 
+// updateMetrics aplica updateFunc às métricas sob metricsMutex
+func (ws *WebSocketClient) updateMetrics(updateFunc func(*WebSocketMetrics)) {
+	ws.metricsMutex.Lock()
+	defer ws.metricsMutex.Unlock()
+	updateFunc(ws.metrics)
+}
+
 // UpdateMachineID atualiza o machine_id do WebSocket client
 func (ws *WebSocketClient) UpdateMachineID(machineID string) {
 	if machineID != "" && machineID != ws.machineID {
@@ -554,49 +973,3 @@ func (ws *WebSocketClient) UpdateMachineID(machineID string) {
 func (ws *WebSocketClient) getMachineID() string {
 	return ws.machineID
 }
-
-// Helper functions for parsing command data
-func getString(data map[string]interface{}, key string) string {
-	if val, ok := data[key]; ok {
-		if str, ok := val.(string); ok {
-			return str
-		}
-	}
-	return ""
-}
-
-func getStringSlice(data map[string]interface{}, key string) []string {
-	if val, ok := data[key]; ok {
-		if slice, ok := val.([]interface{}); ok {
-			result := make([]string, len(slice))
-			for i, v := range slice {
-				if str, ok := v.(string); ok {
-					result[i] = str
-				}
-			}
-			return result
-		}
-	}
-	return nil
-}
-
-func getMap(data map[string]interface{}, key string) map[string]interface{} {
-	if val, ok := data[key]; ok {
-		if m, ok := val.(map[string]interface{}); ok {
-			return m
-		}
-	}
-	return nil
-}
-
-func getInt(data map[string]interface{}, key string) int {
-	if val, ok := data[key]; ok {
-		if i, ok := val.(float64); ok {
-			return int(i)
-		}
-		if i, ok := val.(int); ok {
-			return i
-		}
-	}
-	return 0
-}
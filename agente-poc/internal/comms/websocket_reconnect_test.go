@@ -0,0 +1,267 @@
+package comms
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// flakyWSServer recusa o handshake com 503 enquanto houver falhas pendentes
+// e registra o horário de cada tentativa; depois aceita e mantém a conexão
+// aberta até o cliente fechar
+type flakyWSServer struct {
+	server   *httptest.Server
+	failures atomic.Int64
+
+	mu          sync.Mutex
+	attempts    []time.Time
+	connections int
+}
+
+func newFlakyWSServer(t *testing.T, failures int64) *flakyWSServer {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+
+	backend := &flakyWSServer{}
+	backend.failures.Store(failures)
+	upgrader := websocket.Upgrader{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend.mu.Lock()
+		backend.attempts = append(backend.attempts, time.Now())
+		backend.mu.Unlock()
+		if backend.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		backend.mu.Lock()
+		backend.connections++
+		backend.mu.Unlock()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+func (b *flakyWSServer) url() string {
+	return "ws" + strings.TrimPrefix(b.server.URL, "http")
+}
+
+// snapshot retorna as tentativas e as conexões aceitas até agora
+func (b *flakyWSServer) snapshot() ([]time.Time, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]time.Time(nil), b.attempts...), b.connections
+}
+
+// newFlakyTestClient cria um cliente sem pings para o servidor instável
+func newFlakyTestClient(t *testing.T, backend *flakyWSServer, base, max time.Duration, maxReconnects int, onFailure func(error)) *WebSocketClient {
+	t.Helper()
+	ws, err := NewWebSocketClient(WebSocketConfig{
+		URL:                backend.url(),
+		MachineID:          "machine-1",
+		ReconnectDelay:     base,
+		MaxBackoff:         max,
+		MaxReconnects:      maxReconnects,
+		PingInterval:       time.Hour,
+		PongTimeout:        time.Minute,
+		MaxQueueSize:       100,
+		Logger:             testLogger(t),
+		OnPermanentFailure: onFailure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+// isReconnecting lê o estado do loop de reconexão
+func isReconnecting(ws *WebSocketClient) bool {
+	ws.connMutex.RLock()
+	defer ws.connMutex.RUnlock()
+	return ws.reconnecting
+}
+
+// waitFor espera cond ficar verdadeira; falha no prazo
+func waitFor(t *testing.T, what string, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	const base, max = time.Second, 30 * time.Second
+	tests := []struct {
+		attempt int
+		jitter  float64
+		want    time.Duration
+	}{
+		{attempt: 0, jitter: 0, want: 500 * time.Millisecond},
+		{attempt: 0, jitter: 0.5, want: 750 * time.Millisecond},
+		{attempt: 1, jitter: 0, want: time.Second},
+		{attempt: 3, jitter: 0, want: 4 * time.Second},
+		{attempt: 3, jitter: 0.999, want: 8*time.Second - 4*time.Millisecond},
+		// O teto vale antes do jitter, que continua espalhando os agentes
+		{attempt: 5, jitter: 0, want: 15 * time.Second},
+		{attempt: 5, jitter: 0.5, want: 22500 * time.Millisecond},
+		{attempt: 40, jitter: 0, want: 15 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoffDelay(base, max, tt.attempt, tt.jitter); got != tt.want {
+			t.Errorf("backoffDelay(attempt %d, jitter %v) = %s, want %s", tt.attempt, tt.jitter, got, tt.want)
+		}
+	}
+}
+
+func TestReconnectBackoffGrowthAndRecovery(t *testing.T) {
+	const failures = 5
+	const base, max = 20 * time.Millisecond, 160 * time.Millisecond
+	backend := newFlakyWSServer(t, failures)
+	ws := newFlakyTestClient(t, backend, base, max, -1, func(err error) {
+		t.Errorf("permanent failure with unlimited retries: %v", err)
+	})
+
+	ws.Reconnect()
+	waitFor(t, "the reconnection", 10*time.Second, ws.IsConnected)
+	waitFor(t, "the loop to finish", time.Second, func() bool { return !isReconnecting(ws) })
+
+	attempts, connections := backend.snapshot()
+	if len(attempts) != failures+1 || connections != 1 {
+		t.Fatalf("%d attempts and %d connections, want %d and 1", len(attempts), connections, failures+1)
+	}
+	// Cada espera é de pelo menos metade de base·2^n, limitada a max
+	for i := 1; i < len(attempts); i++ {
+		expected := base << (i - 1)
+		if expected > max {
+			expected = max
+		}
+		if gap := attempts[i].Sub(attempts[i-1]); gap < expected/2 {
+			t.Errorf("wait before attempt %d = %s, want at least %s", i+1, gap, expected/2)
+		}
+	}
+
+	metrics := ws.GetMetrics()
+	if metrics.Reconnects != failures || metrics.SuccessfulConnects != 1 || metrics.PermanentFailures != 0 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+	if metrics.LastReconnectDelay < max/2 || metrics.LastReconnectDelay > max {
+		t.Fatalf("last delay = %s, want the capped backoff", metrics.LastReconnectDelay)
+	}
+}
+
+func TestReconnectPermanentFailure(t *testing.T) {
+	backend := newFlakyWSServer(t, 1000)
+	failed := make(chan error, 1)
+	ws := newFlakyTestClient(t, backend, time.Millisecond, 4*time.Millisecond, 3, func(err error) { failed <- err })
+
+	ws.Reconnect()
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Fatal("permanent failure without the last error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnPermanentFailure not called")
+	}
+
+	if attempts, _ := backend.snapshot(); len(attempts) != 3 {
+		t.Fatalf("%d attempts, want MaxReconnects = 3", len(attempts))
+	}
+	if isReconnecting(ws) || ws.GetMetrics().PermanentFailures != 1 {
+		t.Fatalf("after giving up: reconnecting %t, metrics %+v", isReconnecting(ws), ws.GetMetrics())
+	}
+
+	// Depois da desistência, uma nova reconexão pode começar
+	backend.failures.Store(0)
+	ws.Reconnect()
+	waitFor(t, "the reconnection after giving up", 5*time.Second, ws.IsConnected)
+}
+
+func TestExternalConnectDuringReconnect(t *testing.T) {
+	backend := newFlakyWSServer(t, 1)
+	ws := newFlakyTestClient(t, backend, 200*time.Millisecond, 200*time.Millisecond, -1, nil)
+
+	// O loop falha na primeira tentativa e dorme; um Connect externo conecta
+	ws.Reconnect()
+	waitFor(t, "the first attempt", 5*time.Second, func() bool {
+		attempts, _ := backend.snapshot()
+		return len(attempts) == 1
+	})
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// O loop acorda, vê a conexão e termina sem abrir outra
+	waitFor(t, "the loop to finish", 5*time.Second, func() bool { return !isReconnecting(ws) })
+	if _, connections := backend.snapshot(); connections != 1 {
+		t.Fatalf("%d connections, want only the external one", connections)
+	}
+
+	// Uma queda depois disso inicia um loop novo
+	ws.DropConnection()
+	waitFor(t, "the reconnection after the drop", 5*time.Second, func() bool {
+		_, connections := backend.snapshot()
+		return connections == 2 && ws.IsConnected()
+	})
+}
+
+func TestManagerResetsAfterPermanentFailure(t *testing.T) {
+	backend := newFlakyWSServer(t, 1000)
+	fake := newTestClock()
+	m, err := New(&Config{
+		WebSocketURL:     backend.url(),
+		Logger:           testLogger(t),
+		Clock:            fake,
+		WSReconnectDelay: time.Millisecond,
+		WSMaxBackoff:     4 * time.Millisecond,
+		WSMaxReconnects:  2,
+		WSPingInterval:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.wsClient.Close() })
+	done := make(chan struct{})
+	go func() {
+		m.startWebSocketConnection()
+		close(done)
+	}()
+
+	// Conexão inicial e 2 tentativas falham; o Manager recebe a desistência
+	// e espera WSMaxBackoff, junto com o timer de 5s da verificação
+	waitFor(t, "the permanent failure", 5*time.Second, func() bool { return m.wsClient.GetMetrics().PermanentFailures == 1 })
+	waitFor(t, "the reset timer", time.Second, func() bool { return fake.Pending() >= 2 })
+
+	// Passado WSMaxBackoff, o Manager recomeça do zero e conecta
+	backend.failures.Store(0)
+	fake.Advance(m.config.WSMaxBackoff)
+	waitFor(t, "the reconnection after the reset", 5*time.Second, m.wsClient.IsConnected)
+	m.cancel()
+	<-done
+
+	metrics := m.GetMetrics()
+	if metrics.WSPermanentFailures != 1 || metrics.ConnectionStatus != "connected" {
+		t.Fatalf("manager metrics = %+v", metrics)
+	}
+}
@@ -0,0 +1,432 @@
+package comms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTestServer é um backend WebSocket que registra, em ordem de chegada, os
+// IDs das mensagens do tipo "test" recebidas em todas as conexões
+type wsTestServer struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	received    []string
+	perConn     map[int][]string
+	connections int
+
+	// onMessage é chamado após cada mensagem "test", com a conexão (1, 2...)
+	// e o total recebido; bloquear nele para de ler a conexão
+	onMessage func(connection, total int)
+}
+
+func newWSTestServer(t *testing.T) *wsTestServer {
+	t.Helper()
+	t.Setenv("HTTP_PROXY", "")
+
+	backend := &wsTestServer{perConn: make(map[int][]string)}
+	upgrader := websocket.Upgrader{}
+	backend.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		backend.mu.Lock()
+		backend.connections++
+		connection := backend.connections
+		backend.mu.Unlock()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message WebSocketMessage
+			if json.Unmarshal(data, &message) != nil || message.Type != "test" {
+				continue
+			}
+
+			backend.mu.Lock()
+			backend.received = append(backend.received, message.ID)
+			backend.perConn[connection] = append(backend.perConn[connection], message.ID)
+			total := len(backend.received)
+			backend.mu.Unlock()
+			if backend.onMessage != nil {
+				backend.onMessage(connection, total)
+			}
+		}
+	}))
+	t.Cleanup(backend.server.Close)
+	return backend
+}
+
+// url é o endereço ws:// do servidor
+func (b *wsTestServer) url() string {
+	return "ws" + strings.TrimPrefix(b.server.URL, "http")
+}
+
+// receivedIDs retorna uma cópia dos IDs recebidos
+func (b *wsTestServer) receivedIDs() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.received...)
+}
+
+// receivedInOrder retorna os IDs recebidos concatenando as conexões em
+// ordem (1, 2...), cada uma na ordem de chegada
+func (b *wsTestServer) receivedInOrder() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for connection := 1; connection <= b.connections; connection++ {
+		ids = append(ids, b.perConn[connection]...)
+	}
+	return ids
+}
+
+// newTestWebSocketClient cria um cliente apontando para o servidor de teste,
+// com reconexão rápida e sem pings
+func newTestWebSocketClient(t *testing.T, backend *wsTestServer, writeBuffer int) *WebSocketClient {
+	t.Helper()
+	ws, err := NewWebSocketClient(WebSocketConfig{
+		URL:            backend.url(),
+		MachineID:      "machine-1",
+		ReconnectDelay: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		MaxReconnects:  -1,
+		PingInterval:   time.Hour,
+		PongTimeout:    time.Minute,
+		MaxQueueSize:   10000,
+		Logger:         testLogger(t),
+		WriteBuffer:    writeBuffer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+// testMessages monta count mensagens "test" com payload de size bytes
+func testMessages(count, size int) []WebSocketMessage {
+	payload := strings.Repeat("x", size)
+	messages := make([]WebSocketMessage, count)
+	for i := range messages {
+		messages[i] = WebSocketMessage{
+			Type:      "test",
+			ID:        fmt.Sprintf("m-%04d", i),
+			Timestamp: time.Now(),
+			Data:      map[string]interface{}{"payload": payload},
+		}
+	}
+	return messages
+}
+
+// waitReceived espera o servidor receber want mensagens; falha no prazo
+// (flush travado ou mensagem perdida)
+func waitReceived(t *testing.T, backend *wsTestServer, want int, timeout time.Duration) []string {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		ids := backend.receivedIDs()
+		if len(ids) >= want {
+			return ids
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server received %d of %d messages within %s (flush stuck or messages lost)", len(ids), want, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// currentSession retorna a sessão atual do cliente
+func currentSession(ws *WebSocketClient) *wsSession {
+	ws.connMutex.RLock()
+	defer ws.connMutex.RUnlock()
+	return ws.session
+}
+
+// TestSendQueuedMessagesConnectionDropMidFlush derruba a conexão com o
+// flush bloqueado esperando o write pump (mensagens grandes enchem o
+// buffer do socket): o flush não pode travar e nenhuma mensagem pode se
+// perder, chegar duas vezes ou mudar de ordem
+func TestSendQueuedMessagesConnectionDropMidFlush(t *testing.T) {
+	backend := newWSTestServer(t)
+	stalled := make(chan struct{})
+	release := make(chan struct{})
+	var stallOnce sync.Once
+	backend.onMessage = func(connection, total int) {
+		if connection == 1 && total == 10 {
+			stallOnce.Do(func() { close(stalled) })
+			<-release
+		}
+	}
+
+	const count = 300
+	ws := newTestWebSocketClient(t, backend, 1)
+	for _, message := range testMessages(count, 32*1024) {
+		ws.queueMessage(message)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stalled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("flush never reached the server")
+	}
+	// Dá tempo de o write pump bloquear no socket cheio
+	time.Sleep(100 * time.Millisecond)
+	ws.handleDisconnect(currentSession(ws))
+	close(release)
+
+	ids := waitReceived(t, backend, count, 20*time.Second)
+	seen := make(map[string]int, len(ids))
+	for _, id := range ids {
+		seen[id]++
+	}
+	for _, message := range testMessages(count, 0) {
+		if seen[message.ID] != 1 {
+			t.Errorf("message %s received %d times", message.ID, seen[message.ID])
+		}
+	}
+	backend.mu.Lock()
+	connections := backend.connections
+	backend.mu.Unlock()
+	if connections < 2 {
+		t.Errorf("client did not reconnect (%d connections)", connections)
+	}
+
+	// As abandonadas pelo write pump e as que não chegaram ao buffer voltam
+	// juntas, na ordem original
+	for i, id := range backend.receivedInOrder() {
+		if want := fmt.Sprintf("m-%04d", i); id != want {
+			t.Fatalf("message %d across connections is %s, want %s", i, id, want)
+		}
+	}
+}
+
+// TestSendQueuedMessagesHandOff verifica que, com um flush em andamento, só
+// ele devolve mensagens à fila: o write pump lhe entrega as abandonadas, e
+// a fila volta inteira e em ordem depois de o pump terminar
+func TestSendQueuedMessagesHandOff(t *testing.T) {
+	ws := newTestWebSocketClient(t, newWSTestServer(t), 2)
+	session := newWSSession(nil, 2)
+	ws.session = session
+	defer func() { ws.session = nil }()
+
+	messages := testMessages(5, 0)
+	for _, message := range messages {
+		ws.queueMessage(message)
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		ws.sendQueuedMessages()
+		close(flushed)
+	}()
+	// Sem write pump, o flush bloqueia na terceira mensagem
+	deadline := time.Now().Add(2 * time.Second)
+	for len(session.send) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("flush did not fill the write buffer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// O que o write pump faz ao cair
+	session.stop(false)
+	session.seal()
+	ws.abandonFrames(session, nil)
+
+	ws.queueMutex.Lock()
+	queued := len(ws.messageQueue)
+	ws.queueMutex.Unlock()
+	if queued != 0 {
+		t.Fatalf("write pump requeued %d message(s) during a flush", queued)
+	}
+	select {
+	case <-flushed:
+		t.Fatal("flush returned before the write pump finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(session.done)
+	select {
+	case <-flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("flush stuck after the write pump finished")
+	}
+
+	ws.queueMutex.Lock()
+	defer ws.queueMutex.Unlock()
+	if len(ws.messageQueue) != len(messages) {
+		t.Fatalf("queue has %d messages, want %d", len(ws.messageQueue), len(messages))
+	}
+	for i, message := range ws.messageQueue {
+		if message.ID != messages[i].ID {
+			t.Fatalf("queue[%d] = %s, want %s", i, message.ID, messages[i].ID)
+		}
+	}
+}
+
+// TestWritePumpStress envia de 50 goroutines enquanto a conexão cai e volta
+// (rodar com -race): nada trava, e toda mensagem aceita por SendMessage
+// chega exatamente uma vez
+func TestWritePumpStress(t *testing.T) {
+	const (
+		senders    = 50
+		perSender  = 40
+		totalCount = senders * perSender
+	)
+
+	backend := newWSTestServer(t)
+	ws := newTestWebSocketClient(t, backend, 16)
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for sender := 0; sender < senders; sender++ {
+		wg.Add(1)
+		go func(sender int) {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				message := WebSocketMessage{Type: "test", ID: fmt.Sprintf("s%02d-%03d", sender, i), Timestamp: time.Now()}
+				// Buffer cheio: a mensagem não foi aceita, tentar de novo
+				for errors.Is(ws.SendMessage(message), ErrWriteBufferFull) {
+					time.Sleep(time.Millisecond)
+				}
+				// Espaça os envios para as quedas caírem no meio deles
+				time.Sleep(time.Millisecond)
+			}
+		}(sender)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for drops := 0; drops < 3; drops++ {
+		time.Sleep(10 * time.Millisecond)
+		ws.DropConnection()
+	}
+	select {
+	case <-done:
+	case <-time.After(20 * time.Second):
+		t.Fatal("senders stuck")
+	}
+
+	// Mensagens enfileiradas depois do último flush saem na próxima conexão
+	time.Sleep(50 * time.Millisecond)
+	ws.DropConnection()
+
+	ids := waitReceived(t, backend, totalCount, 20*time.Second)
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("message %s received twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != totalCount {
+		t.Fatalf("received %d distinct messages, want %d", len(seen), totalCount)
+	}
+}
+
+// TestWebSocketMetricsConcurrentSnapshots só pega corridas com go test
+// -race: o loop de leitura conta as mensagens do servidor enquanto outros
+// goroutines atualizam e leem as métricas
+func TestWebSocketMetricsConcurrentSnapshots(t *testing.T) {
+	const pushed, writers, perWriter = 500, 4, 250
+	t.Setenv("HTTP_PROXY", "")
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for i := 0; i < pushed; i++ {
+			if conn.WriteJSON(WebSocketMessage{Type: "notice", Timestamp: time.Now()}) != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	ws := newTestWebSocketClient(t, &wsTestServer{server: server}, 0)
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		var last WebSocketMetrics
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ws.MessageChannel():
+			default:
+			}
+			snapshot := ws.GetMetrics()
+			if snapshot.MessagesReceived < last.MessagesReceived || snapshot.PingsSent < last.PingsSent {
+				t.Errorf("metrics went backwards: %+v after %+v", snapshot, last)
+				return
+			}
+			last = snapshot
+		}
+	}()
+
+	if err := ws.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				ws.updateMetrics(func(m *WebSocketMetrics) { m.PingsSent++ })
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ws.GetMetrics().MessagesReceived < pushed {
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d of %d messages", ws.GetMetrics().MessagesReceived, pushed)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	readers.Wait()
+
+	metrics := ws.GetMetrics()
+	if metrics.MessagesReceived != pushed || metrics.PingsSent != writers*perWriter || metrics.SuccessfulConnects != 1 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+	ws.ResetMetrics()
+	if metrics := ws.GetMetrics(); metrics != (WebSocketMetrics{}) {
+		t.Fatalf("metrics after reset = %+v", metrics)
+	}
+}
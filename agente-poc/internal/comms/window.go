@@ -0,0 +1,139 @@
+package comms
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SendWindow é o intervalo diário, em hora local, em que inventários podem
+// ser enviados (ex.: "01:00-05:00"). Um intervalo que cruza a meia-noite
+// ("22:00-06:00") vale de um dia para o outro. nil = sempre aberto.
+type SendWindow struct {
+	start time.Duration // desde a meia-noite
+	end   time.Duration
+	spec  string
+}
+
+// ParseSendWindow interpreta "HH:MM-HH:MM"; vazio retorna nil (sem janela)
+func ParseSendWindow(spec string) (*SendWindow, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(spec, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid send window %q: expected HH:MM-HH:MM", spec)
+	}
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid send window %q: %w", spec, err)
+	}
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid send window %q: %w", spec, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid send window %q: start and end are equal", spec)
+	}
+	return &SendWindow{start: start, end: end, spec: spec}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day (HH:MM)", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String retorna a janela como configurada
+func (w *SendWindow) String() string {
+	if w == nil {
+		return ""
+	}
+	return w.spec
+}
+
+// Open indica se t (convertido para hora local) está dentro da janela
+func (w *SendWindow) Open(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	offset := sinceMidnight(t.Local())
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// NextClose retorna quando a janela aberta em t fecha, ou quando a próxima
+// janela fecha se ela estiver fechada; serve de validade para o que espera
+// por ela. Zero sem janela.
+func (w *SendWindow) NextClose(t time.Time) time.Time {
+	if w == nil {
+		return time.Time{}
+	}
+	local := t.Local()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local)
+	// o próximo horário de fim depois de t, hoje ou amanhã, fecha tanto a
+	// janela aberta quanto a próxima
+	end := midnight.Add(w.end)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+// ErrDeferred indica que o inventário foi guardado na fila offline porque a
+// janela de envio está fechada; sai quando ela abrir. Também é ErrSpooled.
+var ErrDeferred = fmt.Errorf("inventory deferred until the send window opens: %w", ErrSpooled)
+
+// SendWindow retorna a janela de envio dos inventários (nil = sempre aberta)
+func (m *Manager) SendWindow() *SendWindow {
+	m.windowMutex.RLock()
+	defer m.windowMutex.RUnlock()
+	return m.window
+}
+
+// SetSendWindow troca a janela de envio dos inventários (nil remove); os
+// inventários já adiados saem na próxima drenagem da fila com a janela aberta
+func (m *Manager) SetSendWindow(window *SendWindow) {
+	m.windowMutex.Lock()
+	m.window = window
+	m.windowMutex.Unlock()
+}
+
+func (m *Manager) inventoryWindowOpen() bool {
+	return m.SendWindow().Open(m.clock.Now())
+}
+
+// deferInventory guarda um inventário fora da janela na fila offline. Ele
+// vale até a janela seguinte fechar, para não expirar antes de ter a chance
+// de sair.
+func (m *Manager) deferInventory(message QueuedMessage) error {
+	if closes := m.SendWindow().NextClose(m.clock.Now()); closes.After(message.ExpiresAt) {
+		message.ExpiresAt = closes
+	}
+	if err := m.queue.Enqueue(message); err != nil {
+		return fmt.Errorf("failed to defer inventory: %w", err)
+	}
+
+	m.logger.WithFields(map[string]interface{}{
+		"send_window": m.SendWindow().String(),
+		"queue_size":  m.queue.Size(),
+	}).Debug("Inventory outside the send window, deferred")
+	return ErrDeferred
+}
+
+// replayable indica se uma mensagem da fila pode sair agora: inventórios
+// esperam a janela de envio
+func (m *Manager) replayable(message *QueuedMessage) bool {
+	return message.Type != "inventory" || m.inventoryWindowOpen()
+}
@@ -0,0 +1,122 @@
+package comms
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agente-poc/internal/clock"
+)
+
+// localTime monta um horário de 29/03/2026 (ou do dia seguinte, com day 30)
+// no fuso local, o mesmo que a janela usa
+func localTime(day, hour, minute int) time.Time {
+	return time.Date(2026, 3, day, hour, minute, 0, 0, time.Local)
+}
+
+func TestParseSendWindow(t *testing.T) {
+	if window, err := ParseSendWindow(" "); window != nil || err != nil {
+		t.Fatalf("empty window = %v, %v", window, err)
+	}
+	for _, spec := range []string{"01:00", "01:00-05:00-07:00", "25:00-05:00", "1h-5h", "03:00-03:00"} {
+		if _, err := ParseSendWindow(spec); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+
+	night, err := ParseSendWindow("01:00-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight, err := ParseSendWindow("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		window *SendWindow
+		at     time.Time
+		open   bool
+		closes time.Time
+	}{
+		{night, localTime(29, 0, 59), false, localTime(29, 5, 0)},
+		{night, localTime(29, 1, 0), true, localTime(29, 5, 0)},
+		{night, localTime(29, 4, 59), true, localTime(29, 5, 0)},
+		{night, localTime(29, 5, 0), false, localTime(30, 5, 0)},
+		{night, localTime(29, 12, 0), false, localTime(30, 5, 0)},
+		// Cruza a meia-noite
+		{overnight, localTime(29, 23, 0), true, localTime(30, 6, 0)},
+		{overnight, localTime(29, 3, 0), true, localTime(29, 6, 0)},
+		{overnight, localTime(29, 12, 0), false, localTime(30, 6, 0)},
+		// Sem janela, sempre aberta
+		{nil, localTime(29, 12, 0), true, time.Time{}},
+	}
+	for _, tt := range tests {
+		if open := tt.window.Open(tt.at); open != tt.open {
+			t.Errorf("%q open at %s = %t", tt.window.String(), tt.at.Format("15:04"), open)
+		}
+		if closes := tt.window.NextClose(tt.at); !closes.Equal(tt.closes) {
+			t.Errorf("%q next close after %s = %s, want %s", tt.window.String(), tt.at.Format("15:04"), closes, tt.closes)
+		}
+	}
+}
+
+func TestInventoryOutsideWindowIsQueued(t *testing.T) {
+	backend := newOutageBackend(t, 0)
+	fake := clock.NewFake(localTime(29, 12, 0))
+	m := newSpoolTestManager(t, backend.server.URL, filepath.Join(t.TempDir(), "queue.json"), fake)
+	window, err := ParseSendWindow("01:00-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetSendWindow(window)
+
+	// Fora da janela o inventário vai para a fila, sem tentativa de envio
+	err = m.SendInventory(spoolTestInventory())
+	if !errors.Is(err, ErrDeferred) || !errors.Is(err, ErrSpooled) {
+		t.Fatalf("SendInventory() = %v, want ErrDeferred", err)
+	}
+	if got := backend.received(); len(got) != 0 {
+		t.Fatalf("backend received %v outside the window", got)
+	}
+	if size := m.queue.Size(); size != 1 {
+		t.Fatalf("queue size = %d, want 1", size)
+	}
+	// Vale até a próxima janela fechar, para não expirar antes de sair
+	if expires := m.queue.messages[0].ExpiresAt; expires.Before(localTime(30, 5, 0)) {
+		t.Fatalf("deferred inventory expires at %s", expires)
+	}
+
+	// Heartbeats não esperam a janela, e a drenagem da fila deixa o
+	// inventário onde está
+	if err := m.SendHeartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	m.replayQueued()
+	if got := backend.received(); len(got) != 1 || got[0] != EndpointHeartbeat {
+		t.Fatalf("backend received %v, want only the heartbeat", got)
+	}
+	if size := m.queue.Size(); size != 1 {
+		t.Fatalf("queue size after a closed-window drain = %d", size)
+	}
+
+	// Na janela, a fila entrega o inventário
+	fake.Set(localTime(30, 1, 30))
+	m.replayQueued()
+	if got := backend.received(); len(got) != 2 || got[1] != "inventory-0" {
+		t.Fatalf("backend received %v after the window opened", got)
+	}
+	if size := m.queue.Size(); size != 0 {
+		t.Fatalf("queue size = %d after the window opened", size)
+	}
+
+	// Sem janela (recarga), o envio é imediato a qualquer hora
+	fake.Set(localTime(30, 12, 0))
+	m.SetSendWindow(nil)
+	if err := m.SendInventory(spoolTestInventory()); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.received(); len(got) != 3 {
+		t.Fatalf("backend received %v without a window", got)
+	}
+}
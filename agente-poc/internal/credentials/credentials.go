@@ -0,0 +1,200 @@
+// Package credentials guarda o token por máquina emitido pelo backend no
+// enrollment. Os metadados (machine_id, validade, renovações) ficam em um
+// arquivo JSON no data_dir; o token fica no cofre da plataforma: keychain no
+// macOS (CLI security), DPAPI no Windows e, nos demais sistemas, o próprio
+// arquivo com permissão 0600, cifrado quando há uma passphrase.
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// credentialFile é o nome do arquivo de metadados dentro do data_dir
+const credentialFile = "machine_credential.json"
+
+// ErrNotFound indica que a máquina ainda não passou pelo enrollment
+var ErrNotFound = errors.New("machine credential not found")
+
+// Credential é o token por máquina e a validade informada pelo backend
+type Credential struct {
+	MachineID    string
+	Token        string
+	IssuedAt     time.Time
+	ExpiresAt    time.Time // zero = sem expiração informada
+	RefreshCount int
+}
+
+// record é a forma persistida; Secret só é usado pelos cofres que guardam o
+// token no próprio arquivo (DPAPI e arquivo)
+type record struct {
+	Storage      string    `json:"storage"`
+	MachineID    string    `json:"machine_id"`
+	IssuedAt     time.Time `json:"issued_at"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	RefreshCount int       `json:"refresh_count"`
+	Secret       []byte    `json:"secret,omitempty"`
+}
+
+// vault guarda o valor do token no cofre da plataforma. seal retorna o que
+// vai em record.Secret (nil quando o token fica fora do arquivo).
+type vault interface {
+	kind() string
+	seal(token string) ([]byte, error)
+	open(rec *record) (string, error)
+	remove(rec *record) error
+}
+
+// Store lê e grava a credencial da máquina em dir
+type Store struct {
+	path  string
+	vault vault
+}
+
+// NewStore cria o store em dir. passphrase só é usada no cofre de arquivo
+// (Linux e demais sistemas sem keychain ou DPAPI).
+func NewStore(dir, passphrase string) *Store {
+	return &Store{
+		path:  filepath.Join(dir, credentialFile),
+		vault: platformVault(passphrase),
+	}
+}
+
+// NewFileStore cria o store em dir com o cofre de arquivo em qualquer
+// sistema, sem keychain ou DPAPI (testes e data_dir portátil)
+func NewFileStore(dir, passphrase string) *Store {
+	return &Store{
+		path:  filepath.Join(dir, credentialFile),
+		vault: fileVault{passphrase: passphrase},
+	}
+}
+
+// Stored indica se há uma credencial gravada em dir, sem abrir o cofre
+func Stored(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, credentialFile))
+	return err == nil
+}
+
+// Storage descreve o cofre usado nas gravações ("keychain", "dpapi", "file"
+// ou "file+passphrase")
+func (s *Store) Storage() string {
+	return s.vault.kind()
+}
+
+// Load lê a credencial gravada; ErrNotFound se não houver
+func (s *Store) Load() (*Credential, error) {
+	rec, err := s.readRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	// Uma credencial gravada com outro cofre (ex.: passphrase adicionada
+	// depois) é lida com o cofre em que foi gravada
+	v, err := vaultFor(rec.Storage, s.vault)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := v.open(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine token from %s: %w", rec.Storage, err)
+	}
+	return &Credential{
+		MachineID:    rec.MachineID,
+		Token:        token,
+		IssuedAt:     rec.IssuedAt,
+		ExpiresAt:    rec.ExpiresAt,
+		RefreshCount: rec.RefreshCount,
+	}, nil
+}
+
+// Save grava a credencial, substituindo a anterior
+func (s *Store) Save(credential *Credential) error {
+	if credential.Token == "" {
+		return fmt.Errorf("machine token is empty")
+	}
+	secret, err := s.vault.seal(credential.Token)
+	if err != nil {
+		return fmt.Errorf("failed to store machine token in %s: %w", s.vault.kind(), err)
+	}
+	return s.writeRecord(&record{
+		Storage:      s.vault.kind(),
+		MachineID:    credential.MachineID,
+		IssuedAt:     credential.IssuedAt,
+		ExpiresAt:    credential.ExpiresAt,
+		RefreshCount: credential.RefreshCount,
+		Secret:       secret,
+	})
+}
+
+// Delete remove a credencial (o próximo início volta a exigir enrollment)
+func (s *Store) Delete() error {
+	rec, err := s.readRecord()
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err == nil {
+		if v, verr := vaultFor(rec.Storage, s.vault); verr == nil {
+			if err := v.remove(rec); err != nil {
+				return fmt.Errorf("failed to remove machine token from %s: %w", rec.Storage, err)
+			}
+		}
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) readRecord() (*record, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read machine credential: %w", err)
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse machine credential %s: %w", s.path, err)
+	}
+	return &rec, nil
+}
+
+func (s *Store) writeRecord(rec *record) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal machine credential: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write machine credential: %w", err)
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// vaultFor retorna o cofre capaz de ler uma credencial gravada com storage;
+// current é o cofre configurado, que guarda a passphrase
+func vaultFor(storage string, current vault) (vault, error) {
+	if storage == current.kind() {
+		return current, nil
+	}
+	switch storage {
+	case storageFile:
+		return fileVault{}, nil
+	case storageFilePassphrase:
+		if fv, ok := current.(fileVault); ok && fv.passphrase != "" {
+			return fv, nil
+		}
+		return nil, fmt.Errorf("machine credential is encrypted with a passphrase; set credential_passphrase")
+	default:
+		return nil, fmt.Errorf("machine credential was stored in %s, which is not available on this system", storage)
+	}
+}
@@ -0,0 +1,165 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeVault simula um cofre externo (keychain): o token fica fora do arquivo
+type fakeVault struct {
+	tokens  map[string]string
+	removed int
+}
+
+func (v *fakeVault) kind() string { return "fake" }
+
+func (v *fakeVault) seal(token string) ([]byte, error) {
+	v.tokens["machine"] = token
+	return nil, nil
+}
+
+func (v *fakeVault) open(*record) (string, error) {
+	token, ok := v.tokens["machine"]
+	if !ok {
+		return "", errors.New("item not found")
+	}
+	return token, nil
+}
+
+func (v *fakeVault) remove(*record) error {
+	v.removed++
+	delete(v.tokens, "machine")
+	return nil
+}
+
+func testCredential() *Credential {
+	return &Credential{
+		MachineID:    "test-machine",
+		Token:        "machine-token-123",
+		IssuedAt:     time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		ExpiresAt:    time.Date(2026, 2, 4, 9, 0, 0, 0, time.UTC),
+		RefreshCount: 2,
+	}
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, "")
+	if _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("empty store: %v, want ErrNotFound", err)
+	}
+	if Stored(dir) {
+		t.Fatal("Stored before Save")
+	}
+
+	if err := store.Save(testCredential()); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *loaded != *testCredential() {
+		t.Fatalf("loaded %+v, want %+v", loaded, testCredential())
+	}
+	if !Stored(dir) || store.Storage() != "file" {
+		t.Fatalf("Stored %v, storage %q", Stored(dir), store.Storage())
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, credentialFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("credential file mode = %v", info.Mode().Perm())
+		}
+	}
+
+	if err := store.Save(&Credential{MachineID: "test-machine"}); err == nil {
+		t.Fatal("empty token saved")
+	}
+}
+
+func TestFileStorePassphrase(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir, "correct horse")
+	if err := store.Save(testCredential()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, credentialFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("machine-token-123")) || !strings.Contains(string(data), `"file+passphrase"`) {
+		t.Fatalf("credential file = %s", data)
+	}
+
+	loaded, err := store.Load()
+	if err != nil || loaded.Token != "machine-token-123" {
+		t.Fatalf("Load = %+v, %v", loaded, err)
+	}
+
+	// Passphrase errada ou ausente não abre o token
+	if _, err := NewFileStore(dir, "wrong").Load(); err == nil || !strings.Contains(err.Error(), "wrong credential passphrase") {
+		t.Fatalf("wrong passphrase: %v", err)
+	}
+	if _, err := NewFileStore(dir, "").Load(); err == nil || !strings.Contains(err.Error(), "set credential_passphrase") {
+		t.Fatalf("missing passphrase: %v", err)
+	}
+
+	// Gravada sem passphrase, continua legível depois de configurar uma
+	plainDir := t.TempDir()
+	if err := NewFileStore(plainDir, "").Save(testCredential()); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := NewFileStore(plainDir, "correct horse").Load(); err != nil || loaded.Token != "machine-token-123" {
+		t.Fatalf("plain credential with passphrase: %+v, %v", loaded, err)
+	}
+}
+
+func TestStoreExternalVault(t *testing.T) {
+	dir := t.TempDir()
+	keychain := &fakeVault{tokens: make(map[string]string)}
+	store := &Store{path: filepath.Join(dir, credentialFile), vault: keychain}
+
+	if err := store.Save(testCredential()); err != nil {
+		t.Fatal(err)
+	}
+	// O arquivo guarda só os metadados
+	data, err := os.ReadFile(filepath.Join(dir, credentialFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("machine-token-123")) || keychain.tokens["machine"] != "machine-token-123" {
+		t.Fatalf("credential file = %s, vault = %v", data, keychain.tokens)
+	}
+	if loaded, err := store.Load(); err != nil || *loaded != *testCredential() {
+		t.Fatalf("Load = %+v, %v", loaded, err)
+	}
+
+	// Sem o cofre em que foi gravada, a credencial não é lida
+	if _, err := NewFileStore(dir, "").Load(); err == nil || !strings.Contains(err.Error(), "stored in fake") {
+		t.Fatalf("load from file vault: %v", err)
+	}
+
+	if err := store.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if keychain.removed != 1 || Stored(dir) {
+		t.Fatalf("removed %d, stored %v", keychain.removed, Stored(dir))
+	}
+	if _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load after Delete: %v", err)
+	}
+	// Apagar de novo não é erro
+	if err := store.Delete(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,100 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	storageFile           = "file"
+	storageFilePassphrase = "file+passphrase"
+)
+
+// Parâmetros do scrypt que deriva a chave AES-256 da passphrase
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 16
+)
+
+// fileVault guarda o token no próprio arquivo de metadados (0600). Com
+// passphrase, o token é cifrado com AES-256-GCM e uma chave derivada por
+// scrypt; o Secret fica salt || nonce || texto cifrado.
+type fileVault struct {
+	passphrase string
+}
+
+func (v fileVault) kind() string {
+	if v.passphrase != "" {
+		return storageFilePassphrase
+	}
+	return storageFile
+}
+
+func (v fileVault) seal(token string) ([]byte, error) {
+	if v.passphrase == "" {
+		return []byte(token), nil
+	}
+
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := v.cipher(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	secret := append(salt, nonce...)
+	return aead.Seal(secret, nonce, []byte(token), nil), nil
+}
+
+func (v fileVault) open(rec *record) (string, error) {
+	if rec.Storage == storageFile {
+		return string(rec.Secret), nil
+	}
+
+	if len(rec.Secret) < scryptSaltLen {
+		return "", fmt.Errorf("encrypted token is truncated")
+	}
+	salt := rec.Secret[:scryptSaltLen]
+	aead, err := v.cipher(salt)
+	if err != nil {
+		return "", err
+	}
+	rest := rec.Secret[scryptSaltLen:]
+	if len(rest) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted token is truncated")
+	}
+	token, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("wrong credential passphrase or corrupted token")
+	}
+	return string(token), nil
+}
+
+func (v fileVault) remove(*record) error {
+	return nil
+}
+
+// cipher deriva a chave da passphrase com salt
+func (v fileVault) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(v.passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,73 @@
+//go:build darwin
+
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	storageKeychain = "keychain"
+
+	// Item do keychain do sistema que guarda o token
+	keychainService = "agente-poc"
+	keychainAccount = "machine-token"
+)
+
+// platformVault: no macOS o token fica no keychain; a passphrase não é usada
+func platformVault(string) vault {
+	return keychainVault{}
+}
+
+// keychainVault guarda o token no keychain pela CLI security. A gravação usa
+// o modo interativo (comandos pelo stdin) para o token não aparecer na linha
+// de comando de outros processos.
+type keychainVault struct{}
+
+func (keychainVault) kind() string { return storageKeychain }
+
+func (keychainVault) seal(token string) ([]byte, error) {
+	// O modo interativo separa argumentos por espaço e aspas; tokens do
+	// backend são base64/hex, qualquer outro caractere é recusado
+	if strings.ContainsAny(token, " \t\r\n\"'\\") {
+		return nil, fmt.Errorf("machine token contains characters not supported by the keychain")
+	}
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", keychainService, keychainAccount, token)
+	if _, err := security(command, "-i"); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (keychainVault) open(*record) (string, error) {
+	out, err := security("", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+func (keychainVault) remove(*record) error {
+	_, err := security("", "delete-generic-password", "-s", keychainService, "-a", keychainAccount)
+	return err
+}
+
+// security roda /usr/bin/security com stdin e retorna a saída padrão
+func security(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("/usr/bin/security", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("security %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	// No modo interativo os erros saem no stderr com código de saída 0
+	if args[0] == "-i" && stderr.Len() > 0 {
+		return "", fmt.Errorf("security: %s", strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
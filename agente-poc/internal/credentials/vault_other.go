@@ -0,0 +1,9 @@
+//go:build !windows && !darwin
+
+package credentials
+
+// platformVault: sem keychain ou DPAPI, o token fica no arquivo 0600,
+// cifrado quando há passphrase
+func platformVault(passphrase string) vault {
+	return fileVault{passphrase: passphrase}
+}
@@ -0,0 +1,62 @@
+//go:build windows
+
+package credentials
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const storageDPAPI = "dpapi"
+
+// platformVault: no Windows o token é protegido com DPAPI na conta que roda
+// o agente (LocalSystem no serviço); a passphrase não é usada
+func platformVault(string) vault {
+	return dpapiVault{}
+}
+
+// dpapiVault cifra o token com CryptProtectData; o resultado vai no arquivo
+type dpapiVault struct{}
+
+func (dpapiVault) kind() string { return storageDPAPI }
+
+func (dpapiVault) seal(token string) ([]byte, error) {
+	return dpapi([]byte(token), true)
+}
+
+func (dpapiVault) open(rec *record) (string, error) {
+	token, err := dpapi(rec.Secret, false)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+func (dpapiVault) remove(*record) error {
+	return nil
+}
+
+// dpapi cifra (protect) ou decifra data sem interação com o usuário
+func dpapi(data []byte, protect bool) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, windows.ERROR_INVALID_DATA
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+
+	var err error
+	if protect {
+		err = windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	} else {
+		err = windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	result := make([]byte, out.Size)
+	copy(result, unsafe.Slice(out.Data, out.Size))
+	return result, nil
+}
@@ -0,0 +1,257 @@
+// Package events é o pipeline de eventos do agente: alertas, mudanças de
+// identidade, registros de execução de comandos e transições do agente são
+// publicados uma vez e entregues a cada sink (backend, arquivo JSON Lines
+// local), de modo que todos recebem o mesmo conteúdo.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SchemaVersion é a versão do formato Event; incrementar em mudanças
+// incompatíveis e documentar em docs/EVENT_LOG.md
+const SchemaVersion = 1
+
+// Categorias de evento
+const (
+	CategoryAgent    = "agent"    // ciclo de vida e transições do agente
+	CategoryAlert    = "alert"    // condições que pedem atenção (e sua resolução)
+	CategoryCommand  = "command"  // registro de execução de comando
+	CategoryIdentity = "identity" // mudanças de machine_id
+	CategorySecurity = "security" // recusas por verificação de segurança
+)
+
+// Severidades de evento
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+// defaultSinkBuffer é a fila de eventos de cada sink; cheia, o evento é
+// descartado para esse sink e contado em Dropped
+const defaultSinkBuffer = 256
+
+// Event é um evento do agente, como enviado ao backend e gravado no log local
+type Event struct {
+	SchemaVersion int                    `json:"schema_version"`
+	ID            string                 `json:"id"`
+	Timestamp     time.Time              `json:"timestamp"`
+	MachineID     string                 `json:"machine_id"`
+	InstanceID    string                 `json:"instance_id,omitempty"`
+	Category      string                 `json:"category"`
+	Type          string                 `json:"type"`
+	Severity      string                 `json:"severity"`
+	Message       string                 `json:"message"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+// Sink recebe os eventos do pipeline. Write é chamado por uma goroutine
+// dedicada ao sink, em ordem; um sink lento não atrasa os outros.
+type Sink interface {
+	Write(event Event) error
+}
+
+// SinkFunc adapta uma função a Sink
+type SinkFunc func(event Event) error
+
+// Write chama f(event)
+func (f SinkFunc) Write(event Event) error {
+	return f(event)
+}
+
+// SinkStats são os contadores de entrega de um sink
+type SinkStats struct {
+	Written   int64  `json:"written"`
+	Dropped   int64  `json:"dropped"`
+	Failed    int64  `json:"failed"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// sinkWorker entrega os eventos de um sink a partir da sua fila
+type sinkWorker struct {
+	name  string
+	sink  Sink
+	queue chan Event
+
+	written atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+	lastErr atomic.Value // string
+}
+
+// Pipeline distribui eventos para os sinks registrados. Eventos publicados
+// antes de Start ficam na fila de cada sink e são entregues quando os
+// workers começam.
+type Pipeline struct {
+	mu        sync.RWMutex
+	workers   []*sinkWorker
+	started   bool
+	closed    bool
+	wg        sync.WaitGroup
+	machineID atomic.Value // string
+	now       func() time.Time
+}
+
+// NewPipeline cria um pipeline sem sinks; now é a fonte do timestamp dos
+// eventos (nil usa time.Now)
+func NewPipeline(now func() time.Time) *Pipeline {
+	if now == nil {
+		now = time.Now
+	}
+	return &Pipeline{now: now}
+}
+
+// AddSink registra um sink; deve ser chamado antes de Start
+func (p *Pipeline) AddSink(name string, sink Sink) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w := &sinkWorker{name: name, sink: sink, queue: make(chan Event, defaultSinkBuffer)}
+	p.workers = append(p.workers, w)
+	if p.started {
+		p.startWorker(w)
+	}
+}
+
+// Start inicia a entrega aos sinks
+func (p *Pipeline) Start() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started || p.closed {
+		return
+	}
+	p.started = true
+	for _, w := range p.workers {
+		p.startWorker(w)
+	}
+}
+
+// startWorker inicia a goroutine do sink; chamado com mu travado
+func (p *Pipeline) startWorker(w *sinkWorker) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for event := range w.queue {
+			if err := w.sink.Write(event); err != nil {
+				w.failed.Add(1)
+				w.lastErr.Store(err.Error())
+				continue
+			}
+			w.written.Add(1)
+		}
+	}()
+}
+
+// SetMachineID define o machine_id usado nos eventos que não informam um
+func (p *Pipeline) SetMachineID(machineID string) {
+	if p == nil {
+		return
+	}
+	p.machineID.Store(machineID)
+}
+
+// Emit completa o evento (versão, ID, timestamp, machine_id) e o enfileira
+// para cada sink sem bloquear. Seguro em pipeline nil ou fechado.
+func (p *Pipeline) Emit(event Event) {
+	if p == nil {
+		return
+	}
+
+	event.SchemaVersion = SchemaVersion
+	if event.ID == "" {
+		event.ID = newEventID()
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = p.now().UTC()
+	}
+	if event.MachineID == "" {
+		event.MachineID, _ = p.machineID.Load().(string)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return
+	}
+	for _, w := range p.workers {
+		select {
+		case w.queue <- event:
+		default:
+			w.dropped.Add(1)
+		}
+	}
+}
+
+// Close entrega os eventos pendentes e encerra os workers; sinks que
+// implementam io.Closer são fechados em seguida
+func (p *Pipeline) Close() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	if !p.started {
+		p.started = true
+		for _, w := range p.workers {
+			p.startWorker(w)
+		}
+	}
+	for _, w := range p.workers {
+		close(w.queue)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	for _, w := range p.workers {
+		if closer, ok := w.sink.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+}
+
+// Stats retorna os contadores de entrega por sink
+func (p *Pipeline) Stats() map[string]SinkStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]SinkStats, len(p.workers))
+	for _, w := range p.workers {
+		lastErr, _ := w.lastErr.Load().(string)
+		stats[w.name] = SinkStats{
+			Written:   w.written.Load(),
+			Dropped:   w.dropped.Load(),
+			Failed:    w.failed.Load(),
+			LastError: lastErr,
+		}
+	}
+	return stats
+}
+
+// newEventID gera um ID aleatório para deduplicação no backend/SIEM
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileSink grava eventos como JSON Lines (um objeto JSON por linha) para
+// coletores locais (SIEM, osquery). O arquivo é rotacionado ao passar de
+// maxBytes, mantendo maxBackups cópias (path.1 é a mais recente). Se outro
+// processo mover, apagar ou truncar o arquivo, a próxima escrita percebe e
+// reabre o caminho configurado.
+type FileSink struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	info os.FileInfo
+}
+
+// NewFileSink abre (ou cria) o arquivo de eventos. maxBytes <= 0 desativa
+// a rotação.
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create event log directory: %w", err)
+	}
+
+	s := &FileSink{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.openLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write grava o evento como uma linha
+func (s *FileSink) Write(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size, err := s.syncLocked()
+	if err != nil {
+		return err
+	}
+
+	if s.maxBytes > 0 && size > 0 && size+int64(len(line)) > s.maxBytes {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	return nil
+}
+
+// Close fecha o arquivo
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// syncLocked reabre o caminho se o arquivo aberto não é mais o dele (movido
+// ou apagado pela rotação externa) e retorna o tamanho atual. Truncamento
+// não exige reabrir: o arquivo é aberto com O_APPEND.
+func (s *FileSink) syncLocked() (int64, error) {
+	current, err := os.Stat(s.path)
+	if err != nil || s.file == nil || !os.SameFile(current, s.info) {
+		if s.file != nil {
+			s.file.Close()
+			s.file = nil
+		}
+		if err := s.openLocked(); err != nil {
+			return 0, err
+		}
+		current = s.info
+	}
+	return current.Size(), nil
+}
+
+// openLocked abre o caminho configurado para acrescentar linhas
+func (s *FileSink) openLocked() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat event log: %w", err)
+	}
+	s.file = file
+	s.info = info
+	return nil
+}
+
+// rotateLocked desloca path.N-1 → path.N ... path → path.1 e abre um
+// arquivo novo; sem backups o arquivo atual é descartado
+func (s *FileSink) rotateLocked() error {
+	s.file.Close()
+	s.file = nil
+
+	if s.maxBackups <= 0 {
+		os.Remove(s.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxBackups))
+		for i := s.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate event log: %w", err)
+		}
+	}
+	return s.openLocked()
+}
@@ -0,0 +1,297 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// eventSchema é o subconjunto de JSON Schema usado em docs/event-log.schema.json
+type eventSchema struct {
+	Required             []string `json:"required"`
+	AdditionalProperties bool     `json:"additionalProperties"`
+	Properties           map[string]struct {
+		Type    string        `json:"type"`
+		Const   interface{}   `json:"const"`
+		Enum    []interface{} `json:"enum"`
+		Pattern string        `json:"pattern"`
+		Format  string        `json:"format"`
+	} `json:"properties"`
+}
+
+// loadEventSchema lê o schema publicado na documentação
+func loadEventSchema(t *testing.T) eventSchema {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "docs", "event-log.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema eventSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// validate verifica uma linha do log contra o schema
+func (s eventSchema) validate(line []byte) error {
+	var document map[string]interface{}
+	if err := json.Unmarshal(line, &document); err != nil {
+		return fmt.Errorf("not a JSON object: %w", err)
+	}
+	for _, key := range s.Required {
+		if _, ok := document[key]; !ok {
+			return fmt.Errorf("missing required %q", key)
+		}
+	}
+
+	for key, value := range document {
+		property, ok := s.Properties[key]
+		if !ok {
+			if !s.AdditionalProperties {
+				return fmt.Errorf("unexpected property %q", key)
+			}
+			continue
+		}
+		if property.Const != nil && !reflect.DeepEqual(value, property.Const) {
+			return fmt.Errorf("%s = %v, want %v", key, value, property.Const)
+		}
+		if property.Enum != nil {
+			found := false
+			for _, allowed := range property.Enum {
+				found = found || reflect.DeepEqual(value, allowed)
+			}
+			if !found {
+				return fmt.Errorf("%s = %v not in %v", key, value, property.Enum)
+			}
+		}
+		switch property.Type {
+		case "string":
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s is not a string", key)
+			}
+			if property.Pattern != "" && !regexp.MustCompile(property.Pattern).MatchString(text) {
+				return fmt.Errorf("%s = %q does not match %s", key, text, property.Pattern)
+			}
+			if property.Format == "date-time" {
+				if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+					return fmt.Errorf("%s = %q is not a date-time", key, text)
+				}
+			}
+		case "object":
+			if _, ok := value.(map[string]interface{}); !ok {
+				return fmt.Errorf("%s is not an object", key)
+			}
+		}
+	}
+	return nil
+}
+
+// readLines lê as linhas de um arquivo do log
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// testEvent monta um evento completo como o pipeline o entrega
+func testEvent(eventType string) Event {
+	return Event{
+		SchemaVersion: SchemaVersion,
+		ID:            newEventID(),
+		Timestamp:     time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		MachineID:     "test-machine",
+		Category:      CategoryAgent,
+		Type:          eventType,
+		Severity:      SeverityInfo,
+		Message:       "test event",
+	}
+}
+
+func TestEventLogLinesMatchSchema(t *testing.T) {
+	schema := loadEventSchema(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := NewPipeline(func() time.Time { return time.Date(2026, 1, 5, 9, 0, 0, 0, time.FixedZone("BRT", -3*3600)) })
+	pipeline.SetMachineID("test-machine")
+	pipeline.AddSink("file", sink)
+	pipeline.Start()
+
+	categories := []string{CategoryAgent, CategoryAlert, CategoryCommand, CategoryIdentity, CategorySecurity}
+	severities := []string{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical}
+	for i, category := range categories {
+		event := Event{
+			Category: category,
+			Type:     category + "_event",
+			Severity: severities[i%len(severities)],
+			Message:  "event from " + category,
+			Data:     map[string]interface{}{"index": i, "nested": map[string]interface{}{"ok": true}},
+		}
+		if category == CategoryIdentity {
+			event.MachineID = "other-machine"
+			event.InstanceID = "instance-1"
+			event.Data = nil
+		}
+		pipeline.Emit(event)
+	}
+	pipeline.Close()
+
+	lines := readLines(t, path)
+	if len(lines) != len(categories) {
+		t.Fatalf("%d lines written, want %d", len(lines), len(categories))
+	}
+	for i, line := range lines {
+		if err := schema.validate(line); err != nil {
+			t.Errorf("line %d: %v\n%s", i+1, err, line)
+		}
+	}
+
+	// O validador recusa linhas fora do schema
+	for _, invalid := range []string{
+		`{"schema_version":1}`,
+		`{"schema_version":2,"id":"00000000000000000000000000000000","timestamp":"2026-01-05T09:00:00Z","machine_id":"m","category":"agent","type":"x","severity":"info","message":""}`,
+		`{"schema_version":1,"id":"00000000000000000000000000000000","timestamp":"2026-01-05T09:00:00Z","machine_id":"m","category":"other","type":"x","severity":"info","message":""}`,
+		`{"schema_version":1,"id":"00000000000000000000000000000000","timestamp":"2026-01-05T09:00:00Z","machine_id":"m","category":"agent","type":"Bad-Type","severity":"info","message":""}`,
+		`{"schema_version":1,"id":"00000000000000000000000000000000","timestamp":"2026-01-05T09:00:00Z","machine_id":"m","category":"agent","type":"x","severity":"info","message":"","extra":1}`,
+	} {
+		if schema.validate([]byte(invalid)) == nil {
+			t.Errorf("invalid line accepted: %s", invalid)
+		}
+	}
+}
+
+func TestPipelineSinksReceiveIdenticalEvents(t *testing.T) {
+	var first, second []Event
+	pipeline := NewPipeline(nil)
+	pipeline.AddSink("first", SinkFunc(func(event Event) error { first = append(first, event); return nil }))
+	pipeline.AddSink("second", SinkFunc(func(event Event) error { second = append(second, event); return nil }))
+
+	// Eventos antes do Start ficam na fila
+	pipeline.Emit(Event{Category: CategoryAgent, Type: "agent_started", Severity: SeverityInfo})
+	pipeline.Start()
+	pipeline.Emit(Event{Category: CategoryAlert, Type: "backend_lag", Severity: SeverityWarning})
+	pipeline.Close()
+	pipeline.Emit(Event{Category: CategoryAgent, Type: "after_close", Severity: SeverityInfo})
+
+	if len(first) != 2 || !reflect.DeepEqual(first, second) {
+		t.Fatalf("sinks received %v and %v", first, second)
+	}
+	if stats := pipeline.Stats()["first"]; stats.Written != 2 || stats.Dropped != 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	line, _ := json.Marshal(testEvent("rotation_test"))
+	lineSize := int64(len(line) + 1)
+
+	// Cabem duas linhas por arquivo; com 2 backups sobram 3 arquivos
+	sink, err := NewFileSink(path, 2*lineSize, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 7; i++ {
+		if err := sink.Write(testEvent("rotation_test")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]int{path: 1, path + ".1": 2, path + ".2": 2} {
+		if n := len(readLines(t, name)); n != want {
+			t.Errorf("%s has %d lines, want %d", filepath.Base(name), n, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("backup beyond max_backups kept: %v", err)
+	}
+}
+
+func TestFileSinkExternalRotation(t *testing.T) {
+	tests := []struct {
+		name   string
+		rotate func(t *testing.T, path string)
+	}{
+		{
+			name: "moved",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".siem"); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "deleted",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "truncated",
+			rotate: func(t *testing.T, path string) {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.jsonl")
+			sink, err := NewFileSink(path, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+
+			for i := 0; i < 3; i++ {
+				if err := sink.Write(testEvent("before_rotation")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tt.rotate(t, path)
+			if err := sink.Write(testEvent("after_rotation")); err != nil {
+				t.Fatal(err)
+			}
+
+			// A escrita seguinte vai para um arquivo novo no caminho configurado
+			lines := readLines(t, path)
+			if len(lines) != 1 {
+				t.Fatalf("%d lines after external rotation, want 1", len(lines))
+			}
+			var event Event
+			if err := json.Unmarshal(lines[0], &event); err != nil || event.Type != "after_rotation" {
+				t.Fatalf("line after external rotation: %s (%v)", lines[0], err)
+			}
+		})
+	}
+}
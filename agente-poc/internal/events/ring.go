@@ -0,0 +1,69 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRingCapacity é a capacidade do histórico em memória quando a
+// configuração não define outra
+const DefaultRingCapacity = 1000
+
+// Ring guarda os eventos mais recentes em memória, para consulta local
+// (Agent.GetEvents, comando get_events) sem depender do backend ou do log
+// em arquivo. Cheio, o evento mais antigo dá lugar ao novo. É um Sink e
+// pode ser escrito por várias goroutines ao mesmo tempo.
+type Ring struct {
+	mu     sync.Mutex
+	buf    []Event
+	next   int
+	filled bool
+}
+
+// NewRing cria um histórico com a capacidade informada (zero ou negativa
+// usa DefaultRingCapacity)
+func NewRing(capacity int) *Ring {
+	if capacity <= 0 {
+		capacity = DefaultRingCapacity
+	}
+	return &Ring{buf: make([]Event, capacity)}
+}
+
+// Write acrescenta o evento, descartando o mais antigo se cheio
+func (r *Ring) Write(event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf[r.next] = event
+	r.next++
+	if r.next == len(r.buf) {
+		r.next = 0
+		r.filled = true
+	}
+	return nil
+}
+
+// Since retorna, do mais antigo para o mais novo, os eventos com timestamp
+// posterior a since (zero retorna todos). Com limit > 0, apenas os limit
+// mais recentes entre eles. O filtro olha todos os eventos, não só o fim,
+// porque um ajuste de relógio pode deixar timestamps fora de ordem.
+func (r *Ring) Since(since time.Time, limit int) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ordered := r.buf[:r.next]
+	if r.filled {
+		ordered = append(append(make([]Event, 0, len(r.buf)), r.buf[r.next:]...), r.buf[:r.next]...)
+	}
+
+	matched := make([]Event, 0, len(ordered))
+	for _, event := range ordered {
+		if event.Timestamp.After(since) {
+			matched = append(matched, event)
+		}
+	}
+	if limit > 0 && len(matched) > limit {
+		matched = matched[len(matched)-limit:]
+	}
+	return matched
+}
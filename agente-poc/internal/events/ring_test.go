@@ -0,0 +1,149 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ringEvent cria um evento com o tipo e o horário base + minutos
+func ringEvent(eventType string, minutes int) Event {
+	return Event{
+		Timestamp: time.Date(2026, 1, 5, 9, minutes, 0, 0, time.UTC),
+		Category:  CategoryAgent,
+		Severity:  SeverityInfo,
+		Type:      eventType,
+		Message:   eventType,
+	}
+}
+
+// ringTypes lista os tipos, para comparar a ordem
+func ringTypes(events []Event) []string {
+	types := make([]string, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestRingOrderAndEviction(t *testing.T) {
+	r := NewRing(3)
+	if got := r.Since(time.Time{}, 0); len(got) != 0 {
+		t.Fatalf("empty ring = %v", got)
+	}
+
+	for i := 1; i <= 2; i++ {
+		_ = r.Write(ringEvent(fmt.Sprintf("e%d", i), i))
+	}
+	if got := ringTypes(r.Since(time.Time{}, 0)); !reflect.DeepEqual(got, []string{"e1", "e2"}) {
+		t.Fatalf("partial ring = %v", got)
+	}
+
+	// Cheio, o mais antigo sai; a ordem continua do mais antigo ao mais novo
+	for i := 3; i <= 7; i++ {
+		_ = r.Write(ringEvent(fmt.Sprintf("e%d", i), i))
+	}
+	if got := ringTypes(r.Since(time.Time{}, 0)); !reflect.DeepEqual(got, []string{"e5", "e6", "e7"}) {
+		t.Fatalf("after wrapping = %v", got)
+	}
+}
+
+func TestRingSinceAndLimit(t *testing.T) {
+	r := NewRing(10)
+	for i := 1; i <= 5; i++ {
+		_ = r.Write(ringEvent(fmt.Sprintf("e%d", i), i))
+	}
+	base := ringEvent("", 0).Timestamp
+
+	tests := []struct {
+		since time.Time
+		limit int
+		want  []string
+	}{
+		{time.Time{}, 0, []string{"e1", "e2", "e3", "e4", "e5"}},
+		// Estritamente depois de since
+		{base.Add(3 * time.Minute), 0, []string{"e4", "e5"}},
+		{base.Add(5 * time.Minute), 0, []string{}},
+		// limit fica com os mais recentes
+		{time.Time{}, 2, []string{"e4", "e5"}},
+		{base.Add(time.Minute), 10, []string{"e2", "e3", "e4", "e5"}},
+	}
+	for _, tt := range tests {
+		if got := ringTypes(r.Since(tt.since, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Since(%s, %d) = %v, want %v", tt.since.Format(time.Kitchen), tt.limit, got, tt.want)
+		}
+	}
+
+	// Relógio ajustado para trás: o filtro não para no primeiro evento antigo
+	_ = r.Write(ringEvent("late", 0))
+	_ = r.Write(ringEvent("e6", 6))
+	if got := ringTypes(r.Since(base.Add(4*time.Minute), 0)); !reflect.DeepEqual(got, []string{"e5", "e6"}) {
+		t.Fatalf("since after a clock step = %v", got)
+	}
+}
+
+func TestRingDefaultCapacity(t *testing.T) {
+	r := NewRing(0)
+	for i := 0; i < DefaultRingCapacity+5; i++ {
+		_ = r.Write(ringEvent(fmt.Sprintf("e%d", i), 0))
+	}
+	got := r.Since(time.Time{}, 0)
+	if len(got) != DefaultRingCapacity || got[0].Type != "e5" {
+		t.Fatalf("%d events, oldest %s", len(got), got[0].Type)
+	}
+}
+
+func TestRingConcurrentWriters(t *testing.T) {
+	const writers, perWriter = 8, 100
+	r := NewRing(writers * perWriter)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				_ = r.Write(ringEvent(fmt.Sprintf("w%d-%03d", w, i), 1))
+				r.Since(time.Time{}, 5)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Todos chegaram, e cada escritor na ordem em que escreveu
+	got := r.Since(time.Time{}, 0)
+	if len(got) != writers*perWriter {
+		t.Fatalf("%d events, want %d", len(got), writers*perWriter)
+	}
+	last := make(map[string]string)
+	for _, event := range got {
+		writer := event.Type[:2]
+		if event.Type <= last[writer] {
+			t.Fatalf("%s after %s", event.Type, last[writer])
+		}
+		last[writer] = event.Type
+	}
+}
+
+func TestRingEventsSerializable(t *testing.T) {
+	r := NewRing(2)
+	event := ringEvent("command_received", 1)
+	event.MachineID = "machine-1"
+	event.Data = map[string]interface{}{"command_id": "cmd-1", "type": "shell"}
+	_ = r.Write(event)
+
+	data, err := json.Marshal(r.Since(time.Time{}, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Event
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || !reflect.DeepEqual(decoded[0], event) {
+		t.Fatalf("round trip = %+v, want %+v", decoded, event)
+	}
+}
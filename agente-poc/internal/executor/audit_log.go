@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"agente-poc/internal/audit"
+	"agente-poc/internal/comms"
+)
+
+// Limites do comando get_audit_log
+const (
+	defaultAuditLogLimit = 100
+	maxAuditLogLimit     = 1000
+)
+
+// AuditLogOutput é a saída do comando get_audit_log: as entradas mais
+// recentes e os heads da cadeia, para o backend guardar e comparar depois
+type AuditLogOutput struct {
+	Entries []audit.Entry    `json:"entries"`
+	Head    audit.Head       `json:"head"`
+	Files   []audit.FileHead `json:"files"`
+	Anchor  *audit.Head      `json:"anchor,omitempty"` // fim dos arquivos já apagados
+}
+
+// openAudit abre o log de auditoria de Config.AuditLogDir. Uma falha só
+// desativa o log, com aviso: o executor continua atendendo comandos.
+func (e *Executor) openAudit() {
+	if e.config.AuditLogDir == "" {
+		return
+	}
+	log, err := audit.Open(e.config.AuditLogDir, e.config.AuditLogMaxBytes, e.config.AuditLogMaxFiles)
+	if err != nil {
+		e.logger.WithFields(map[string]interface{}{
+			"dir":   e.config.AuditLogDir,
+			"error": err.Error(),
+		}).Warning("Log de auditoria desativado")
+		return
+	}
+	e.audit = log
+}
+
+// RecordAudit grava no log de auditoria um comando e seu resultado. Execute
+// já chama RecordAudit; quem recusa um comando antes do executor (ex.:
+// decodificação inválida) chama diretamente. Sem log configurado, não faz nada.
+func (e *Executor) RecordAudit(command *comms.Command, result *comms.CommandResult) {
+	if e.audit == nil || command == nil || result == nil {
+		return
+	}
+
+	origin := command.Origin
+	if origin == "" {
+		origin = comms.CommandOriginLocal
+	}
+	entry, err := e.audit.Append(audit.Entry{
+		Timestamp: result.Timestamp,
+		CommandID: command.ID,
+		Type:      command.Type,
+		Command:   command.Command,
+		Args:      SanitizeArguments(command.Args),
+		Origin:    origin,
+		Status:    string(result.Status),
+		ExitCode:  result.ExitCode,
+		ErrorCode: string(result.ErrorCode),
+	})
+	if err != nil {
+		e.logger.WithFields(map[string]interface{}{
+			"command_id": command.ID,
+			"error":      err.Error(),
+		}).Error("Falha ao gravar o log de auditoria")
+		return
+	}
+	e.logger.WithFields(map[string]interface{}{
+		"command_id": command.ID,
+		"seq":        entry.Seq,
+	}).Debug("Comando registrado no log de auditoria")
+}
+
+// executeGetAuditLog retorna as entradas recentes do log de auditoria
+// (options.limit, padrão 100, máximo 1000), os heads da cadeia e o âncora
+func (e *Executor) executeGetAuditLog(ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error) {
+	if e.audit == nil {
+		err := comms.NewCodedError(comms.ErrCodeAuditLogDisabled)
+		return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+	}
+
+	limit := defaultAuditLogLimit
+	if value, ok := command.Options["limit"].(float64); ok {
+		if value < 1 || value != float64(int(value)) {
+			err := comms.NewCodedError(comms.ErrCodeInvalidCommandField, "options.limit: expected positive integer")
+			return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+		}
+		limit = min(int(value), maxAuditLogLimit)
+	}
+
+	entries, err := e.audit.Recent(limit)
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	output, err := json.Marshal(AuditLogOutput{
+		Entries: entries,
+		Head:    e.audit.Head(),
+		Files:   e.audit.FileHeads(),
+		Anchor:  e.audit.Anchor(),
+	})
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+
+	return &comms.CommandResult{
+		ID:            command.ID,
+		CommandID:     command.ID,
+		Status:        comms.StatusSuccess,
+		Output:        string(output),
+		ExecutionTime: time.Since(startTime).Milliseconds(),
+		Timestamp:     time.Now(),
+	}, nil
+}
@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// newCancelTestExecutor libera sleep e tail na whitelist, para comandos que
+// ficam rodando até serem cancelados
+func newCancelTestExecutor(t *testing.T, maxConcurrent int) *Executor {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the sleep and tail executables")
+	}
+	return newTestExecutor(t, func(c *Config) {
+		c.MaxConcurrent = maxConcurrent
+		c.DefaultTimeout = time.Minute
+		c.CustomWhitelist = map[string]CommandSpec{
+			"sleep": {Name: "sleep"},
+			"tail":  {Name: "tail"},
+		}
+	})
+}
+
+// startCommand executa o comando em segundo plano e retorna o canal do
+// resultado
+func startCommand(e *Executor, ctx context.Context, command *comms.Command) <-chan *comms.CommandResult {
+	done := make(chan *comms.CommandResult, 1)
+	go func() {
+		result, _ := e.Execute(ctx, command)
+		done <- result
+	}()
+	return done
+}
+
+// waitRunning espera os comandos aparecerem em Running
+func waitRunning(t *testing.T, e *Executor, ids ...string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(e.Running(), ids) {
+		if time.Now().After(deadline) {
+			t.Fatalf("running = %v, want %v", e.Running(), ids)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitResult espera o resultado, que deve chegar logo após o cancelamento
+func waitResult(t *testing.T, done <-chan *comms.CommandResult) *comms.CommandResult {
+	t.Helper()
+	select {
+	case result := <-done:
+		if result == nil {
+			t.Fatal("no result")
+		}
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("command still running after the cancel")
+		return nil
+	}
+}
+
+func sleepCommand(id string) *comms.Command {
+	return &comms.Command{ID: id, Type: "shell", Command: "sleep", Args: []string{"30"}}
+}
+
+func TestCancelRunningCommandKeepsPartialOutput(t *testing.T) {
+	e := newCancelTestExecutor(t, 1)
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, []byte("partial line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// tail -f imprime o arquivo e fica esperando mais linhas
+	done := startCommand(e, context.Background(), &comms.Command{ID: "cmd-tail", Type: "shell", Command: "tail", Args: []string{"-f", path}})
+	waitRunning(t, e, "cmd-tail")
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	if !e.Cancel("cmd-tail", "operator request") {
+		t.Fatal("Cancel returned false for a running command")
+	}
+	result := waitResult(t, done)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("result took %s after the cancel", elapsed)
+	}
+	if result.Status != comms.StatusCancelled || result.ErrorCode != comms.ErrCodeCommandCancelled {
+		t.Fatalf("result = %s / %s", result.Status, result.ErrorCode)
+	}
+	if !strings.Contains(result.Output, "partial line") || !strings.Contains(result.Error, "operator request") {
+		t.Fatalf("output %q, error %q", result.Output, result.Error)
+	}
+	if running := e.Running(); len(running) != 0 {
+		t.Fatalf("still tracked after finishing: %v", running)
+	}
+	if e.Cancel("cmd-tail", "again") {
+		t.Fatal("Cancel returned true for a finished command")
+	}
+
+	// Com MaxConcurrent 1, o próximo comando só roda se a vaga foi liberada
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	next, err := e.Execute(ctx, &comms.Command{ID: "cmd-next", Type: "shell", Command: "sleep", Args: []string{"0"}})
+	if err != nil || next.Status != comms.StatusSuccess {
+		t.Fatalf("command after the cancel: %+v, %v", next, err)
+	}
+}
+
+func TestCancelQueuedCommand(t *testing.T) {
+	e := newCancelTestExecutor(t, 1)
+	running := startCommand(e, context.Background(), sleepCommand("cmd-running"))
+	waitRunning(t, e, "cmd-running")
+	queued := startCommand(e, context.Background(), sleepCommand("cmd-queued"))
+	waitRunning(t, e, "cmd-queued", "cmd-running")
+
+	// Cancelado na fila: sai sem executar e sem ocupar a vaga
+	if !e.Cancel("cmd-queued", "superseded") {
+		t.Fatal("Cancel returned false for a queued command")
+	}
+	result := waitResult(t, queued)
+	if result.Status != comms.StatusCancelled || result.Output != "" || !strings.Contains(result.Error, "superseded") {
+		t.Fatalf("queued result = %+v", result)
+	}
+	if got := e.GetMetrics().RejectedCommands; got != 0 {
+		t.Fatalf("cancelled command counted as rejected (%d)", got)
+	}
+
+	e.Cancel("cmd-running", "test done")
+	if result := waitResult(t, running); result.Status != comms.StatusCancelled {
+		t.Fatalf("running result = %s", result.Status)
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	e := newCancelTestExecutor(t, 2)
+	first := startCommand(e, context.Background(), sleepCommand("cmd-b"))
+	second := startCommand(e, context.Background(), sleepCommand("cmd-a"))
+	waitRunning(t, e, "cmd-a", "cmd-b")
+
+	if ids := e.CancelAll("agent shutting down"); !reflect.DeepEqual(ids, []string{"cmd-a", "cmd-b"}) {
+		t.Fatalf("CancelAll = %v", ids)
+	}
+	for _, done := range []<-chan *comms.CommandResult{first, second} {
+		result := waitResult(t, done)
+		if result.Status != comms.StatusCancelled || !strings.Contains(result.Error, "agent shutting down") {
+			t.Fatalf("result = %s: %s", result.Status, result.Error)
+		}
+	}
+	if ids := e.CancelAll("nothing running"); len(ids) != 0 {
+		t.Fatalf("CancelAll with nothing running = %v", ids)
+	}
+}
+
+func TestCallerContextCancelled(t *testing.T) {
+	e := newCancelTestExecutor(t, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startCommand(e, ctx, sleepCommand("cmd-ctx"))
+	waitRunning(t, e, "cmd-ctx")
+
+	// O contexto de quem chamou (agente parando) também cancela, sem motivo próprio
+	cancel()
+	result := waitResult(t, done)
+	if result.Status != comms.StatusCancelled || result.ErrorCode != comms.ErrCodeCommandCancelled || !strings.Contains(result.Error, "context cancelled") {
+		t.Fatalf("result = %s / %s: %s", result.Status, result.ErrorCode, result.Error)
+	}
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"sort"
+	"testing"
+
+	"agente-poc/internal/comms"
+)
+
+func TestSupportedTypesMatchIsSupported(t *testing.T) {
+	e := newTestExecutor(t, nil)
+
+	types := SupportedTypes()
+	if !sort.StringsAreSorted(types) || len(types) != len(commandHandlers) {
+		t.Fatalf("SupportedTypes = %v", types)
+	}
+
+	// shell só é aceito com um comando da whitelist
+	var shellCommand string
+	for name := range e.GetWhitelist().Commands {
+		if e.GetWhitelist().ValidateCommand(name, nil) == nil {
+			shellCommand = name
+			break
+		}
+	}
+	if shellCommand == "" {
+		t.Fatal("no whitelisted command runs without arguments")
+	}
+
+	for _, commandType := range types {
+		command := &comms.Command{Type: commandType}
+		if commandType == "shell" {
+			command.Command = shellCommand
+		}
+		if !e.IsSupported(command) {
+			t.Errorf("advertised type %q not supported by the executor", commandType)
+		}
+	}
+
+	for _, command := range []*comms.Command{
+		nil,
+		{Type: "bogus"},
+		{Type: "restart_agent"},
+		{Type: "shell", Command: "definitely-not-whitelisted"},
+	} {
+		if e.IsSupported(command) {
+			t.Errorf("IsSupported(%+v) = true", command)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxCollectorScriptStderr limita o stderr guardado para a mensagem de erro
+const maxCollectorScriptStderr = 4 * 1024
+
+// DefaultCollectorScriptDirs são os diretórios aceitos para scripts de
+// coletores customizados quando Config.CollectorScriptDirs não é definido
+func DefaultCollectorScriptDirs() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/Library/Application Support/agente/collectors"}
+	case "windows":
+		return []string{`C:\ProgramData\agente\collectors`}
+	default:
+		return []string{"/usr/local/lib/agente/collectors"}
+	}
+}
+
+// ValidateCollectorScriptArgs recusa argumentos com metacaracteres de shell
+// (o mesmo conjunto recusado em options.env); os argumentos nunca passam por
+// um shell, mas um script que os repasse a um não deve recebê-los
+func ValidateCollectorScriptArgs(args []string) error {
+	for _, arg := range args {
+		if envValueMetaChars.MatchString(arg) {
+			return fmt.Errorf("argument %q contains shell metacharacters", arg)
+		}
+	}
+	return nil
+}
+
+// RunCollectorScript executa o script de um coletor customizado e retorna o
+// stdout. O script precisa estar em Config.CollectorScriptDirs (ou
+// DefaultCollectorScriptDirs), pertencer ao root/administradores junto com
+// os diretórios até a raiz permitida e não ser gravável por outros. Roda com
+// o ambiente restrito dos comandos shell, sem shell, até o prazo de ctx; uma
+// saída acima de MaxOutputSize é recusada em vez de truncada.
+func (e *Executor) RunCollectorScript(ctx context.Context, path string, args []string) ([]byte, error) {
+	if err := ValidateCollectorScriptArgs(args); err != nil {
+		return nil, err
+	}
+	resolved, err := e.resolveCollectorScript(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := newOutputBuffer(e.config.MaxOutputSize)
+	stderr := newOutputBuffer(maxCollectorScriptStderr)
+	cmd := exec.CommandContext(ctx, resolved, args...)
+	cmd.WaitDelay = shellWaitDelay
+	cmd.Env = append([]string(nil), defaultShellEnv...)
+	cmd.Dir = filepath.Dir(resolved)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("script did not finish: %w", ctxErr)
+	}
+	if err != nil {
+		if detail := strings.TrimSpace(string(trimPartialRune(stderr.data))); detail != "" {
+			return nil, fmt.Errorf("script failed: %w: %s", err, detail)
+		}
+		return nil, fmt.Errorf("script failed: %w", err)
+	}
+	if stdout.Truncated() {
+		return nil, fmt.Errorf("script output exceeds %d bytes", stdout.max)
+	}
+	return bytes.TrimSpace(stdout.data), nil
+}
+
+// resolveCollectorScript aceita apenas arquivos regulares com caminho
+// absoluto dentro dos diretórios permitidos, confere o caminho real (sem
+// links) de novo e exige dono confiável no arquivo e em cada diretório até
+// a raiz permitida, para que ninguém além do administrador troque o script
+func (e *Executor) resolveCollectorScript(path string) (string, error) {
+	if path == "" || !filepath.IsAbs(path) {
+		return "", fmt.Errorf("script path must be absolute: %q", path)
+	}
+
+	dirs := e.config.CollectorScriptDirs
+	if len(dirs) == 0 {
+		dirs = DefaultCollectorScriptDirs()
+	}
+
+	cleaned := filepath.Clean(path)
+	if !withinAny(cleaned, dirs) {
+		return "", fmt.Errorf("script %s is outside the allowed directories", path)
+	}
+	resolved, err := filepath.EvalSymlinks(cleaned)
+	if err != nil {
+		return "", err
+	}
+	root := ""
+	for _, dir := range dirs {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if within(resolved, real) {
+			root = real
+			break
+		}
+	}
+	if root == "" {
+		return "", fmt.Errorf("script %s is outside the allowed directories", path)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("script %s is not a regular file", path)
+	}
+
+	for current := resolved; ; current = filepath.Dir(current) {
+		if err := checkTrustedOwner(current); err != nil {
+			return "", err
+		}
+		if current == root || filepath.Dir(current) == current {
+			break
+		}
+	}
+	return resolved, nil
+}
+
+// errUntrustedOwner indica arquivo ou diretório fora do controle do
+// administrador
+var errUntrustedOwner = errors.New("must be owned by root/administrators and not writable by others")
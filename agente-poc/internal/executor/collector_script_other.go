@@ -0,0 +1,26 @@
+//go:build !windows
+
+package executor
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkTrustedOwner exige dono root e nenhuma permissão de escrita para
+// grupo ou outros
+func checkTrustedOwner(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: cannot read owner", path)
+	}
+	if stat.Uid != 0 || info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s %w", path, errUntrustedOwner)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectorScriptDir cria um diretório permitido de scripts com dono e
+// permissões aceitos por checkTrustedOwner
+func collectorScriptDir(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fixture scripts are shell scripts")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("collector scripts must be owned by root")
+	}
+	dir := filepath.Join(t.TempDir(), "collectors")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// installCollectorScript copia testdata/collectors/<name> para dir
+func installCollectorScript(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "collectors", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newCollectorScriptExecutor(t *testing.T, dir string) *Executor {
+	return newTestExecutor(t, func(config *Config) {
+		config.CollectorScriptDirs = []string{dir}
+		config.MaxOutputSize = 4096
+	})
+}
+
+func TestRunCollectorScript(t *testing.T) {
+	dir := collectorScriptDir(t)
+	script := installCollectorScript(t, dir, "asset_tag.sh")
+	e := newCollectorScriptExecutor(t, dir)
+	t.Setenv("AGENTE_TEST_SECRET", "s3cr3t")
+
+	output, err := e.RunCollectorScript(context.Background(), script, []string{"CC-1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ambiente restrito: HOME do defaultShellEnv e nada do agente
+	want := "asset_tag=PAT-00042\ncost_center=CC-1234\nhome=/tmp\nleaked="
+	if string(output) != want {
+		t.Fatalf("output = %q, want %q", output, want)
+	}
+}
+
+func TestRunCollectorScriptTimeout(t *testing.T) {
+	dir := collectorScriptDir(t)
+	script := installCollectorScript(t, dir, "hang.sh")
+	e := newCollectorScriptExecutor(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := e.RunCollectorScript(ctx, script, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "did not finish") {
+		t.Fatalf("err = %v", err)
+	}
+	// O sleep que segura o stdout não prende a coleta além do WaitDelay
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond+shellWaitDelay+time.Second {
+		t.Fatalf("timed out script returned after %s", elapsed)
+	}
+}
+
+func TestRunCollectorScriptFailures(t *testing.T) {
+	dir := collectorScriptDir(t)
+	e := newCollectorScriptExecutor(t, dir)
+	ctx := context.Background()
+
+	_, err := e.RunCollectorScript(ctx, installCollectorScript(t, dir, "failing.sh"), nil)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: asset database unreachable") {
+		t.Fatalf("failing script: %v", err)
+	}
+
+	// Saída acima do limite é recusada, não truncada
+	_, err = e.RunCollectorScript(ctx, installCollectorScript(t, dir, "noisy.sh"), nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeds 4096 bytes") {
+		t.Fatalf("noisy script: %v", err)
+	}
+
+	script := installCollectorScript(t, dir, "asset_tag.sh")
+	if _, err := e.RunCollectorScript(ctx, script, []string{"CC-1; rm -rf /"}); err == nil {
+		t.Fatal("argument with shell metacharacters accepted")
+	}
+}
+
+func TestResolveCollectorScriptRejectsUntrusted(t *testing.T) {
+	dir := collectorScriptDir(t)
+	e := newCollectorScriptExecutor(t, dir)
+	script := installCollectorScript(t, dir, "asset_tag.sh")
+	if _, err := e.resolveCollectorScript(script); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fora do diretório permitido, inclusive por ".." ou por link
+	outside := installCollectorScript(t, filepath.Dir(dir), "asset_tag.sh")
+	link := filepath.Join(dir, "link.sh")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{outside, dir + "/../asset_tag.sh", link, "collectors/asset_tag.sh"} {
+		if _, err := e.resolveCollectorScript(path); err == nil {
+			t.Errorf("%s accepted", path)
+		}
+	}
+
+	// Gravável pelo grupo, no script ou no diretório
+	if err := os.Chmod(script, 0o775); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.resolveCollectorScript(script); !errors.Is(err, errUntrustedOwner) {
+		t.Fatalf("group-writable script: %v", err)
+	}
+	if err := os.Chmod(script, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.resolveCollectorScript(script); !errors.Is(err, errUntrustedOwner) {
+		t.Fatalf("world-writable directory: %v", err)
+	}
+
+	// Dono que não é root
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(script, 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.resolveCollectorScript(script); !errors.Is(err, errUntrustedOwner) {
+		t.Fatalf("script owned by uid 1000: %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package executor
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// checkTrustedOwner exige que o dono seja o grupo Administradores ou o
+// SYSTEM. As ACLs herdadas de C:\ProgramData não são inspecionadas; o
+// diretório dos coletores deve ser criado pelo instalador com escrita
+// restrita aos administradores.
+func checkTrustedOwner(path string) error {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read owner: %w", path, err)
+	}
+	owner, _, err := sd.Owner()
+	if err != nil {
+		return fmt.Errorf("%s: cannot read owner: %w", path, err)
+	}
+	if owner.IsWellKnown(windows.WinBuiltinAdministratorsSid) || owner.IsWellKnown(windows.WinLocalSystemSid) {
+		return nil
+	}
+	return fmt.Errorf("%s %w", path, errUntrustedOwner)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"agente-poc/internal/comms"
 )
 
 // CommandWhitelist define os comandos permitidos e suas restrições
@@ -24,6 +26,11 @@ type CommandSpec struct {
 	ResourceLimits ResourceLimits    `json:"resource_limits,omitempty"`
 	Platform       []string          `json:"platform,omitempty"`
 	UserGroups     []string          `json:"user_groups,omitempty"`
+
+	// AllowedEnvVars são as variáveis aceitas em options.env;
+	// AllowWorkingDir libera options.cwd (dentro de Config.WorkingDirPrefixes)
+	AllowedEnvVars  []string `json:"allowed_env_vars,omitempty"`
+	AllowWorkingDir bool     `json:"allow_working_dir,omitempty"`
 }
 
 // ResourceLimits define limites de recursos para execução
@@ -186,7 +193,7 @@ func GetMacOSWhitelist() *CommandWhitelist {
 			"df": {
 				Name:           "df",
 				Description:    "Mostra uso do sistema de arquivos",
-				AllowedArgs:    []string{"-h", "-k", "-m", "-g", "-T"},
+				AllowedArgs:    []string{"-h", "-k", "-m", "-g", "-T", "."},
 				MaxArgs:        2,
 				TimeoutSeconds: 5,
 				ResourceLimits: ResourceLimits{
@@ -194,6 +201,9 @@ func GetMacOSWhitelist() *CommandWhitelist {
 					MaxOutputBytes: 32 * 1024, // 32KB
 				},
 				Platform: []string{"darwin", "linux"},
+				// "df -h ." com options.cwd mostra o volume de um caminho
+				AllowedEnvVars:  []string{"BLOCKSIZE", "LANG", "LC_ALL"},
+				AllowWorkingDir: true,
 			},
 			"uptime": {
 				Name:           "uptime",
@@ -215,46 +225,87 @@ func GetMacOSWhitelist() *CommandWhitelist {
 func (w *CommandWhitelist) ValidateCommand(command string, args []string) error {
 	spec, exists := w.Commands[command]
 	if !exists {
-		return fmt.Errorf("comando não permitido: %s", command)
+		return comms.NewCodedError(comms.ErrCodeCommandNotAllowed, command)
 	}
 
-	// Validar número de argumentos
-	if spec.MaxArgs > 0 && len(args) > spec.MaxArgs {
-		return fmt.Errorf("muitos argumentos para comando %s: máximo %d, recebido %d",
-			command, spec.MaxArgs, len(args))
+	for _, check := range whitelistChecks {
+		if _, err := check.run(w, command, spec, args); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// whitelistCheck é uma das regras do CommandSpec sobre os argumentos, na
+// ordem aplicada por ValidateCommand; run devolve um resumo da regra para o
+// relatório de Validate
+type whitelistCheck struct {
+	name string
+	run  func(w *CommandWhitelist, command string, spec CommandSpec, args []string) (string, error)
+}
+
+var whitelistChecks = []whitelistCheck{
+	{"max_args", checkMaxArgs},
+	{"forbidden_args", checkForbiddenArgs},
+	{"allowed_args", checkAllowedArgs},
+	{"arg_patterns", checkArgPatterns},
+}
 
-	// Validar argumentos proibidos
+// checkMaxArgs valida o número de argumentos
+func checkMaxArgs(w *CommandWhitelist, command string, spec CommandSpec, args []string) (string, error) {
+	if spec.MaxArgs <= 0 {
+		return "sem limite", nil
+	}
+	if len(args) > spec.MaxArgs {
+		return "", comms.NewCodedError(comms.ErrCodeTooManyArguments, command, spec.MaxArgs, len(args))
+	}
+	return fmt.Sprintf("%d de %d", len(args), spec.MaxArgs), nil
+}
+
+// checkForbiddenArgs recusa argumentos com algum trecho proibido
+func checkForbiddenArgs(w *CommandWhitelist, command string, spec CommandSpec, args []string) (string, error) {
 	for _, arg := range args {
 		for _, forbidden := range spec.ForbiddenArgs {
 			if strings.Contains(arg, forbidden) {
-				return fmt.Errorf("argumento proibido '%s' para comando %s", arg, command)
+				return "", comms.NewCodedError(comms.ErrCodeForbiddenArgument, arg, command)
 			}
 		}
 	}
+	if len(spec.ForbiddenArgs) == 0 {
+		return "nenhum proibido", nil
+	}
+	return fmt.Sprintf("nenhum de %s", strings.Join(spec.ForbiddenArgs, " ")), nil
+}
 
-	// Validar argumentos permitidos (se especificados)
-	if len(spec.AllowedArgs) > 0 {
-		for _, arg := range args {
-			if !w.isArgAllowed(arg, spec.AllowedArgs) {
-				return fmt.Errorf("argumento não permitido '%s' para comando %s", arg, command)
-			}
+// checkAllowedArgs exige que todos os argumentos estejam na lista de
+// permitidos, quando ela existe
+func checkAllowedArgs(w *CommandWhitelist, command string, spec CommandSpec, args []string) (string, error) {
+	if len(spec.AllowedArgs) == 0 {
+		return "qualquer argumento", nil
+	}
+	for _, arg := range args {
+		if !w.isArgAllowed(arg, spec.AllowedArgs) {
+			return "", comms.NewCodedError(comms.ErrCodeArgumentNotAllowed, arg, command)
 		}
 	}
+	return fmt.Sprintf("todos entre %s", strings.Join(spec.AllowedArgs, " ")), nil
+}
 
-	// Validar padrões de argumentos
-	if len(spec.ArgPatterns) > 0 {
-		for i, arg := range args {
-			if pattern, exists := spec.ArgPatterns[fmt.Sprintf("arg%d", i)]; exists {
-				if matched, err := regexp.MatchString(pattern, arg); err != nil || !matched {
-					return fmt.Errorf("argumento %d '%s' não corresponde ao padrão esperado para comando %s",
-						i, arg, command)
-				}
+// checkArgPatterns valida os argumentos com padrão por posição (arg0, arg1...)
+func checkArgPatterns(w *CommandWhitelist, command string, spec CommandSpec, args []string) (string, error) {
+	checked := 0
+	for i, arg := range args {
+		if pattern, exists := spec.ArgPatterns[fmt.Sprintf("arg%d", i)]; exists {
+			if matched, err := regexp.MatchString(pattern, arg); err != nil || !matched {
+				return "", comms.NewCodedError(comms.ErrCodeArgumentPatternMismatch, i, arg, command)
 			}
+			checked++
 		}
 	}
-
-	return nil
+	if checked == 0 {
+		return "nenhum padrão aplicável", nil
+	}
+	return fmt.Sprintf("%d argumento(s) conferido(s)", checked), nil
 }
 
 // isArgAllowed verifica se um argumento está na lista de permitidos
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"agente-poc/internal/comms"
+)
+
+// defaultShellEnv é o ambiente restrito dos comandos shell; options.env só
+// acrescenta ou substitui as variáveis liberadas no CommandSpec
+var defaultShellEnv = []string{
+	"PATH=/usr/bin:/bin:/usr/sbin:/sbin",
+	"HOME=/tmp",
+	"USER=nobody",
+}
+
+// envValueMetaChars são os caracteres recusados em valores de options.env
+var envValueMetaChars = regexp.MustCompile("[;&|<>$`\\\\\"'*?~(){}\\[\\]!\\n\\r\\x00]")
+
+// defaultWorkingDirPrefixes são os diretórios aceitos em options.cwd quando
+// Config.WorkingDirPrefixes não é definido
+func defaultWorkingDirPrefixes() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/Users", "/Volumes", "/Applications", "/tmp", "/private/tmp"}
+	case "windows":
+		return []string{`C:\Users`}
+	default:
+		return []string{"/home", "/mnt", "/media", "/tmp"}
+	}
+}
+
+// commandEnvironment resolve options.cwd e options.env contra o CommandSpec.
+// Sem as opções, dir fica vazio (diretório do agente) e env é o ambiente
+// restrito padrão; audit indica se alguma opção foi aplicada.
+func (e *Executor) commandEnvironment(command *comms.Command, spec CommandSpec) (dir string, env []string, audit bool, err error) {
+	env = append([]string(nil), defaultShellEnv...)
+
+	if cwd, ok := command.Options["cwd"].(string); ok {
+		if !spec.AllowWorkingDir {
+			return "", nil, false, comms.NewCodedError(comms.ErrCodeWorkingDirNotAllowed, command.Command, cwd)
+		}
+		dir, err = e.resolveWorkingDir(cwd)
+		if err != nil {
+			return "", nil, false, comms.NewCodedError(comms.ErrCodeWorkingDirNotAllowed, command.Command, cwd)
+		}
+		audit = true
+	}
+
+	if vars, ok := command.Options["env"].(map[string]interface{}); ok && len(vars) > 0 {
+		names := make([]string, 0, len(vars))
+		for name := range vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !containsString(spec.AllowedEnvVars, name) {
+				return "", nil, false, comms.NewCodedError(comms.ErrCodeEnvVarNotAllowed, command.Command, name)
+			}
+			value, isString := vars[name].(string)
+			if !isString || envValueMetaChars.MatchString(value) {
+				return "", nil, false, comms.NewCodedError(comms.ErrCodeInvalidEnvValue, name)
+			}
+			env = setEnv(env, name, value)
+		}
+		audit = true
+	}
+
+	return dir, env, audit, nil
+}
+
+// resolveWorkingDir aceita apenas diretórios existentes, com caminho
+// absoluto, dentro de um dos prefixos permitidos. Links simbólicos são
+// resolvidos antes da comparação para não escapar dos prefixos.
+func (e *Executor) resolveWorkingDir(cwd string) (string, error) {
+	if !filepath.IsAbs(cwd) {
+		return "", os.ErrInvalid
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(cwd))
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", os.ErrInvalid
+	}
+
+	prefixes := e.config.WorkingDirPrefixes
+	if len(prefixes) == 0 {
+		prefixes = defaultWorkingDirPrefixes()
+	}
+	for _, prefix := range prefixes {
+		if within(resolved, prefix) {
+			return resolved, nil
+		}
+		// /tmp no macOS é link para /private/tmp
+		if real, err := filepath.EvalSymlinks(prefix); err == nil && within(resolved, real) {
+			return resolved, nil
+		}
+	}
+	return "", os.ErrPermission
+}
+
+// within indica se path é prefix ou está abaixo dele
+func within(path, prefix string) bool {
+	prefix = filepath.Clean(prefix)
+	if path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// setEnv define name=value em env, substituindo uma definição anterior
+func setEnv(env []string, name, value string) []string {
+	for i, entry := range env {
+		if strings.HasPrefix(entry, name+"=") {
+			env[i] = name + "=" + value
+			return env
+		}
+	}
+	return append(env, name+"="+value)
+}
+
+// containsString indica se value está em list
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
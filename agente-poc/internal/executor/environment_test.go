@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"agente-poc/internal/comms"
+)
+
+// workingDirFixture cria um diretório permitido e outro fora dos prefixos,
+// com um link simbólico dentro do permitido apontando para fora
+func workingDirFixture(t *testing.T) (allowed, outside string) {
+	t.Helper()
+	root := t.TempDir()
+	allowed = filepath.Join(root, "allowed")
+	outside = filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(allowed, "logs"), outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(allowed, "file.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Resolvidos, para comparar com o diretório efetivo (/tmp no macOS é link)
+	allowed, _ = filepath.EvalSymlinks(allowed)
+	outside, _ = filepath.EvalSymlinks(outside)
+	return allowed, outside
+}
+
+func TestCommandEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX paths and symlinks")
+	}
+	allowed, outside := workingDirFixture(t)
+	e := newTestExecutor(t, func(c *Config) { c.WorkingDirPrefixes = []string{allowed} })
+	spec := CommandSpec{Name: "df", AllowWorkingDir: true, AllowedEnvVars: []string{"LANG", "PATH"}}
+
+	tests := []struct {
+		name     string
+		spec     CommandSpec
+		options  map[string]interface{}
+		wantDir  string
+		wantEnv  []string
+		wantCode comms.ErrorCode
+	}{
+		{name: "no options", spec: spec, wantEnv: defaultShellEnv},
+		{name: "allowed cwd", spec: spec, options: map[string]interface{}{"cwd": allowed}, wantDir: allowed, wantEnv: defaultShellEnv},
+		{name: "allowed subdirectory", spec: spec, options: map[string]interface{}{"cwd": allowed + "/logs/../logs"}, wantDir: filepath.Join(allowed, "logs"), wantEnv: defaultShellEnv},
+		{name: "cwd not allowed by spec", spec: CommandSpec{Name: "df"}, options: map[string]interface{}{"cwd": allowed}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "cwd outside the prefixes", spec: spec, options: map[string]interface{}{"cwd": outside}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "prefix lookalike", spec: spec, options: map[string]interface{}{"cwd": allowed + "-other"}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "symlink escaping the prefix", spec: spec, options: map[string]interface{}{"cwd": filepath.Join(allowed, "escape")}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "relative cwd", spec: spec, options: map[string]interface{}{"cwd": "logs"}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "missing cwd", spec: spec, options: map[string]interface{}{"cwd": filepath.Join(allowed, "missing")}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "file as cwd", spec: spec, options: map[string]interface{}{"cwd": filepath.Join(allowed, "file.txt")}, wantCode: comms.ErrCodeWorkingDirNotAllowed},
+		{
+			name:    "allowed env",
+			spec:    spec,
+			options: map[string]interface{}{"env": map[string]interface{}{"LANG": "C.UTF-8", "PATH": "/usr/local/bin:/usr/bin"}},
+			wantEnv: []string{"PATH=/usr/local/bin:/usr/bin", "HOME=/tmp", "USER=nobody", "LANG=C.UTF-8"},
+		},
+		{name: "env not in the spec", spec: spec, options: map[string]interface{}{"env": map[string]interface{}{"LD_PRELOAD": "/tmp/x.so"}}, wantCode: comms.ErrCodeEnvVarNotAllowed},
+		{name: "env with metacharacters", spec: spec, options: map[string]interface{}{"env": map[string]interface{}{"LANG": "C; rm -rf /"}}, wantCode: comms.ErrCodeInvalidEnvValue},
+		{name: "env with substitution", spec: spec, options: map[string]interface{}{"env": map[string]interface{}{"LANG": "$(id)"}}, wantCode: comms.ErrCodeInvalidEnvValue},
+		{name: "env not a string", spec: spec, options: map[string]interface{}{"env": map[string]interface{}{"LANG": 1}}, wantCode: comms.ErrCodeInvalidEnvValue},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := &comms.Command{Command: "df", Options: tt.options}
+			dir, env, audit, err := e.commandEnvironment(command, tt.spec)
+			if tt.wantCode != "" {
+				var coded *comms.CodedError
+				if !errors.As(err, &coded) || coded.Code != tt.wantCode {
+					t.Fatalf("error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dir != tt.wantDir || !reflect.DeepEqual(env, tt.wantEnv) || audit != (len(tt.options) > 0) {
+				t.Fatalf("dir %q, env %v, audit %t", dir, env, audit)
+			}
+		})
+	}
+
+	// O ambiente padrão não é alterado pelas opções de um comando
+	if !reflect.DeepEqual(defaultShellEnv, []string{"PATH=/usr/bin:/bin:/usr/sbin:/sbin", "HOME=/tmp", "USER=nobody"}) {
+		t.Fatalf("default environment changed: %v", defaultShellEnv)
+	}
+}
+
+func TestShellCommandWorkingDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the pwd and env executables")
+	}
+	allowed, outside := workingDirFixture(t)
+	e := newTestExecutor(t, func(c *Config) {
+		c.WorkingDirPrefixes = []string{allowed}
+		c.CustomWhitelist = map[string]CommandSpec{
+			"pwd": {Name: "pwd", AllowWorkingDir: true},
+			"env": {Name: "env", AllowedEnvVars: []string{"LANG"}},
+		}
+	})
+
+	// Diretório permitido: o comando roda nele e o resultado registra o efetivo
+	result, err := e.Execute(context.Background(), &comms.Command{ID: "cmd-pwd", Type: "shell", Command: "pwd", Options: map[string]interface{}{"cwd": allowed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != comms.StatusSuccess || strings.TrimSpace(result.Output) != allowed {
+		t.Fatalf("pwd in the allowed dir: %s %q", result.Status, result.Output)
+	}
+	if result.WorkingDir != allowed || !reflect.DeepEqual(result.Env, defaultShellEnv) {
+		t.Fatalf("audit fields: working_dir %q, env %v", result.WorkingDir, result.Env)
+	}
+
+	// Fora dos prefixos: recusado sem executar
+	result, err = e.Execute(context.Background(), &comms.Command{ID: "cmd-outside", Type: "shell", Command: "pwd", Options: map[string]interface{}{"cwd": outside}})
+	if err == nil || result.Status != comms.StatusRejected || result.ErrorCode != comms.ErrCodeWorkingDirNotAllowed {
+		t.Fatalf("pwd outside the prefixes: %+v, %v", result, err)
+	}
+	if result.Output != "" || result.WorkingDir != "" {
+		t.Fatalf("rejected command ran or recorded a working dir: %+v", result)
+	}
+
+	result, err = e.Execute(context.Background(), &comms.Command{ID: "cmd-env", Type: "shell", Command: "env", Options: map[string]interface{}{"env": map[string]interface{}{"LANG": "C"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(result.Output, "LANG=C\n") || !strings.Contains(result.Output, "USER=nobody") || result.WorkingDir != "" {
+		t.Fatalf("env output %q, working_dir %q", result.Output, result.WorkingDir)
+	}
+
+	// Sem opções, nada de ambiente no resultado
+	result, err = e.Execute(context.Background(), &comms.Command{ID: "cmd-plain", Type: "shell", Command: "env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Env != nil || strings.Contains(result.Output, "LANG=") {
+		t.Fatalf("plain env: output %q, env %v", result.Output, result.Env)
+	}
+}
@@ -2,13 +2,18 @@ package executor
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"agente-poc/internal/audit"
 	"agente-poc/internal/comms"
 	"agente-poc/internal/logging"
 )
@@ -20,9 +25,48 @@ type Executor struct {
 	whitelist *CommandWhitelist
 	semaphore chan struct{}
 	metrics   *ExecutionMetrics
-	mutex     sync.RWMutex
+	// metricsMutex protege metrics; fica fora de ExecutionMetrics para que
+	// GetMetrics possa retornar cópias por valor
+	metricsMutex sync.RWMutex
+	mutex        sync.RWMutex
+
+	// running guarda o cancelamento de cada execução em andamento, por
+	// command_id (ver Cancel)
+	running      map[string]*runningCommand
+	runningMutex sync.Mutex
+
+	// scriptKeys são as chaves que assinam o comando script; protegidas por
+	// mutex e trocadas em execução por SetScriptKeys
+	scriptKeys []ed25519.PublicKey
+	// scriptIDs são os command_id de scripts aceitos, com a validade de
+	// cada um (ver claimScript); protegidos por mutex
+	scriptIDs map[string]time.Time
+
+	// baseWhitelist é a whitelist embutida mais CustomWhitelist; whitelist,
+	// a vigente, é ela com as atualizações assinadas do backend (ver
+	// whitelist_update.go). Ambas protegidas por mutex e nunca alteradas
+	// depois de publicadas: uma atualização troca o ponteiro.
+	baseWhitelist  *CommandWhitelist
+	whitelistKeys  []ed25519.PublicKey
+	whitelistState whitelistState
+
+	// audit é o log de auditoria; nil quando desativado
+	audit *audit.Log
 }
 
+// runningCommand é uma execução em andamento que pode ser cancelada
+type runningCommand struct {
+	cancel context.CancelCauseFunc
+}
+
+// shellWaitDelay é quanto um comando shell morto por cancelamento ou timeout
+// pode segurar a saída antes de ela ser fechada
+const shellWaitDelay = time.Second
+
+// ErrCommandCancelled é a causa do contexto de uma execução interrompida
+// por Cancel ou CancelAll; o motivo vem junto na mensagem
+var ErrCommandCancelled = errors.New("command cancelled")
+
 // Config contém a configuração do executor
 type Config struct {
 	MaxConcurrent   int                    `json:"max_concurrent"`
@@ -32,6 +76,52 @@ type Config struct {
 	CustomWhitelist map[string]CommandSpec `json:"custom_whitelist,omitempty"`
 	UserGroups      []string               `json:"user_groups,omitempty"`
 	Logger          logging.Logger         `json:"-"`
+
+	// OnProgress recebe os trechos parciais da saída de comandos shell com
+	// options.stream (Status running); sem callback o stream é ignorado
+	OnProgress func(progress *comms.CommandResult) `json:"-"`
+
+	// WorkingDirPrefixes limita options.cwd; vazio usa os diretórios de
+	// usuário e temporários da plataforma (ver defaultWorkingDirPrefixes)
+	WorkingDirPrefixes []string `json:"working_dir_prefixes,omitempty"`
+
+	// fetch_file: diretórios permitidos (vazio = DefaultFetchFileDirs) e
+	// tamanho máximo do arquivo (padrão 5 MB)
+	FetchFileDirs     []string `json:"fetch_file_dirs,omitempty"`
+	FetchFileMaxBytes int64    `json:"fetch_file_max_bytes,omitempty"`
+
+	// http_probe: hosts internos permitidos além de localhost e limite do corpo
+	HTTPProbeAllowedHosts []string `json:"http_probe_allowed_hosts,omitempty"`
+	HTTPProbeMaxBytes     int      `json:"http_probe_max_bytes,omitempty"`
+
+	// ScriptPublicKeys são as chaves Ed25519 (base64) aceitas na assinatura
+	// do comando script; vazio desativa o comando. Recarregáveis com
+	// SetScriptKeys.
+	ScriptPublicKeys []string `json:"script_public_keys,omitempty"`
+
+	// CollectorScriptDirs são os diretórios aceitos para os scripts de
+	// coletores customizados (vazio = DefaultCollectorScriptDirs)
+	CollectorScriptDirs []string `json:"collector_script_dirs,omitempty"`
+
+	// WhitelistPublicKeys são as chaves Ed25519 (base64) aceitas na
+	// assinatura das atualizações da whitelist enviadas pelo backend; vazio
+	// recusa as atualizações. Recarregáveis com SetWhitelistKeys.
+	WhitelistPublicKeys []string `json:"whitelist_public_keys,omitempty"`
+
+	// WhitelistStatePath guarda as atualizações da whitelist aplicadas, para
+	// que sobrevivam ao reinício; vazio mantém as atualizações só em memória
+	WhitelistStatePath string `json:"whitelist_state_path,omitempty"`
+
+	// AuditLogDir recebe o log de auditoria dos comandos (ver RecordAudit);
+	// vazio desativa. AuditLogMaxBytes rotaciona o arquivo (0 = 10 MB) e
+	// AuditLogMaxFiles limita os arquivos rotacionados mantidos (0 = 10).
+	AuditLogDir      string `json:"audit_log_dir,omitempty"`
+	AuditLogMaxBytes int64  `json:"audit_log_max_bytes,omitempty"`
+	AuditLogMaxFiles int    `json:"audit_log_max_files,omitempty"`
+
+	// OnSecurityEvent recebe as recusas relevantes para segurança, como
+	// scripts sem assinatura ou com assinatura inválida
+	OnSecurityEvent func(eventType, message string, fields map[string]interface{}) `json:"-"`
 }
 
 // ExecutionMetrics coleta métricas de execução
@@ -43,7 +133,6 @@ type ExecutionMetrics struct {
 	AverageTime      time.Duration           `json:"average_execution_time"`
 	CommandStats     map[string]CommandStats `json:"command_stats"`
 	LastExecution    time.Time               `json:"last_execution"`
-	mutex            sync.RWMutex
 }
 
 // CommandStats estatísticas por comando
@@ -64,6 +153,8 @@ type ExecutionResult struct {
 	ExecutionTime time.Duration `json:"execution_time"`
 	CommandSpec   CommandSpec   `json:"command_spec"`
 	Sanitized     bool          `json:"sanitized"`
+	WorkingDir    string        `json:"working_dir,omitempty"`
+	Env           []string      `json:"env,omitempty"`
 }
 
 // New cria uma nova instância do executor
@@ -72,11 +163,15 @@ func New(config *Config) (*Executor, error) {
 		config = &Config{
 			MaxConcurrent:  5,
 			DefaultTimeout: 30 * time.Second,
-			MaxOutputSize:  1024 * 1024, // 1MB
+			MaxOutputSize:  defaultMaxOutputSize,
 			EnableMetrics:  true,
 		}
 	}
 
+	if config.MaxOutputSize <= 0 {
+		config.MaxOutputSize = defaultMaxOutputSize
+	}
+
 	if config.Logger == nil {
 		logger, err := logging.NewLogger(nil)
 		if err != nil {
@@ -85,6 +180,15 @@ func New(config *Config) (*Executor, error) {
 		config.Logger = logger
 	}
 
+	scriptKeys, err := ParseScriptPublicKeys(config.ScriptPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+	whitelistKeys, err := ParseWhitelistPublicKeys(config.WhitelistPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	// Obter whitelist baseada na plataforma
 	var whitelist *CommandWhitelist
 	switch runtime.GOOS {
@@ -106,21 +210,69 @@ func New(config *Config) (*Executor, error) {
 	}
 
 	executor := &Executor{
-		config:    config,
-		logger:    config.Logger,
-		whitelist: whitelist,
-		semaphore: make(chan struct{}, config.MaxConcurrent),
+		config:        config,
+		logger:        config.Logger,
+		whitelist:     whitelist,
+		baseWhitelist: whitelist,
+		semaphore:     make(chan struct{}, config.MaxConcurrent),
+		running:       make(map[string]*runningCommand),
+		scriptKeys:    scriptKeys,
+		whitelistKeys: whitelistKeys,
 		metrics: &ExecutionMetrics{
 			CommandStats: make(map[string]CommandStats),
 		},
 	}
 
+	// Atualizações da whitelist aplicadas antes do reinício
+	executor.restoreWhitelist()
+	executor.openAudit()
+
 	executor.logger.WithField("platform", runtime.GOOS).Info("Executor inicializado")
 	return executor, nil
 }
 
-// Execute executa um comando de forma segura
+// commandHandler executa um tipo de comando
+type commandHandler func(e *Executor, ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error)
+
+// commandHandlers registra os tipos de comando do executor. Execute,
+// IsSupported e SupportedTypes (anunciado ao backend) derivam daqui.
+var commandHandlers = map[string]commandHandler{
+	"shell":         (*Executor).executeShellCommand,
+	"info":          (*Executor).executeInfoCommand,
+	"ping":          (*Executor).executePingCommand,
+	"http_probe":    (*Executor).executeHTTPProbe,
+	"fetch_file":    (*Executor).executeFetchFile,
+	"script":        (*Executor).executeScript,
+	"get_audit_log": (*Executor).executeGetAuditLog,
+}
+
+// SupportedTypes retorna os tipos de comando executáveis, em ordem alfabética
+func SupportedTypes() []string {
+	types := make([]string, 0, len(commandHandlers))
+	for commandType := range commandHandlers {
+		types = append(types, commandType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Execute executa um comando de forma segura e o registra no log de
+// auditoria, executado ou recusado
 func (e *Executor) Execute(ctx context.Context, command *comms.Command) (*comms.CommandResult, error) {
+	startTime := time.Now()
+	result, err := e.execute(ctx, command)
+	if command != nil {
+		audited := result
+		if audited == nil {
+			audited = e.createErrorResult(command, comms.StatusError, err, -1, startTime)
+		}
+		e.RecordAudit(command, audited)
+	}
+	return result, err
+}
+
+// execute despacha o comando para o handler do tipo
+func (e *Executor) execute(ctx context.Context, command *comms.Command) (*comms.CommandResult, error) {
 	if command == nil {
 		return nil, fmt.Errorf("comando não pode ser nulo")
 	}
@@ -139,31 +291,35 @@ func (e *Executor) Execute(ctx context.Context, command *comms.Command) (*comms.
 		"args":         command.Args,
 	}).Info("Iniciando execução de comando")
 
+	// Cancelável por command_id desde a espera na fila
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer e.track(command.ID, cancel)()
+
 	// Controle de concorrência
+	semaphore := e.currentSemaphore()
 	select {
-	case e.semaphore <- struct{}{}:
-		defer func() { <-e.semaphore }()
+	case semaphore <- struct{}{}:
+		defer func() { <-semaphore }()
 	case <-ctx.Done():
+		if cause := context.Cause(ctx); errors.Is(cause, ErrCommandCancelled) {
+			return e.createErrorResult(command, comms.StatusCancelled, cancelledError(cause), -1, startTime), nil
+		}
 		e.updateMetrics(func(m *ExecutionMetrics) { m.RejectedCommands++ })
-		return e.createErrorResult(command, "timeout na fila de execução", -1, startTime), ctx.Err()
+		return e.createErrorResult(command, comms.StatusRejectedBusy, comms.NewCodedError(comms.ErrCodeExecutorQueueTimeout), -1, startTime), ctx.Err()
 	}
 
 	// Executar comando baseado no tipo
 	var result *comms.CommandResult
 	var err error
 
-	switch command.Type {
-	case "shell":
-		result, err = e.executeShellCommand(ctx, command, startTime)
-	case "info":
-		result, err = e.executeInfoCommand(ctx, command, startTime)
-	case "ping":
-		result, err = e.executePingCommand(ctx, command, startTime)
-	default:
+	handler, ok := commandHandlers[command.Type]
+	if !ok {
 		e.updateMetrics(func(m *ExecutionMetrics) { m.RejectedCommands++ })
-		return e.createErrorResult(command, "tipo de comando não suportado: "+command.Type, -1, startTime),
-			fmt.Errorf("tipo de comando não suportado: %s", command.Type)
+		err := comms.NewCodedError(comms.ErrCodeUnsupportedCommandType, command.Type)
+		return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
 	}
+	result, err = handler(e, ctx, command, startTime)
 
 	// Atualizar métricas
 	duration := time.Since(startTime)
@@ -180,54 +336,42 @@ func (e *Executor) Execute(ctx context.Context, command *comms.Command) (*comms.
 
 // executeShellCommand executa um comando shell com validação de segurança
 func (e *Executor) executeShellCommand(ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error) {
-	// Validar comando contra whitelist
-	if err := e.whitelist.ValidateCommand(command.Command, command.Args); err != nil {
-		e.logger.WithFields(map[string]interface{}{
-			"command": command.Command,
-			"args":    command.Args,
-			"error":   err.Error(),
-		}).Warning("Comando rejeitado pela whitelist")
-
-		return e.createErrorResult(command, "comando rejeitado: "+err.Error(), -1, startTime), err
-	}
-
-	// Verificação adicional de segurança
-	if !IsCommandSafe(command.Command, command.Args) {
-		e.logger.WithFields(map[string]interface{}{
-			"command": command.Command,
-			"args":    command.Args,
-		}).Warning("Comando rejeitado pela verificação de segurança")
+	// Whitelist, segurança, sanitização e opções de ambiente
+	report, err := e.Validate(command)
+	if err != nil {
+		switch report.FailedStep() {
+		case StepSafety:
+			e.logger.WithFields(map[string]interface{}{
+				"command": command.Command,
+				"args":    command.Args,
+			}).Warning("Comando rejeitado pela verificação de segurança")
+		case StepEnvironment:
+			e.logger.WithFields(map[string]interface{}{
+				"command": command.Command,
+				"cwd":     command.Options["cwd"],
+				"error":   err.Error(),
+			}).Warning("Opções de execução rejeitadas")
+		default:
+			e.logger.WithFields(map[string]interface{}{
+				"command": command.Command,
+				"args":    command.Args,
+				"step":    report.FailedStep(),
+				"error":   err.Error(),
+			}).Warning("Comando rejeitado pela whitelist")
+		}
 
-		return e.createErrorResult(command, "comando considerado inseguro", -1, startTime),
-			fmt.Errorf("comando considerado inseguro")
+		return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
 	}
 
-	// Sanitizar argumentos
-	sanitizedArgs := SanitizeArguments(command.Args)
-	sanitized := !equalSlices(command.Args, sanitizedArgs)
-
-	if sanitized {
+	if report.Sanitized {
 		e.logger.WithFields(map[string]interface{}{
 			"original":  command.Args,
-			"sanitized": sanitizedArgs,
+			"sanitized": report.SanitizedArgs,
 		}).Info("Argumentos sanitizados")
 	}
 
-	// Obter especificações do comando
-	spec, exists := e.whitelist.GetCommandSpec(command.Command)
-	if !exists {
-		return e.createErrorResult(command, "especificações do comando não encontradas", -1, startTime),
-			fmt.Errorf("especificações do comando não encontradas")
-	}
-
-	// Configurar timeout
-	timeout := e.config.DefaultTimeout
-	if spec.TimeoutSeconds > 0 {
-		timeout = time.Duration(spec.TimeoutSeconds) * time.Second
-	}
-	if command.Timeout > 0 {
-		timeout = time.Duration(command.Timeout) * time.Second
-	}
+	spec, sanitizedArgs, dir, env := *report.Spec, report.SanitizedArgs, report.WorkingDir, report.Env
+	timeout := report.Timeout
 
 	// Criar contexto com timeout
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
@@ -238,24 +382,48 @@ func (e *Executor) executeShellCommand(ctx context.Context, command *comms.Comma
 		"command": command.Command,
 		"args":    sanitizedArgs,
 		"timeout": timeout.String(),
+		"cwd":     dir,
 	}).Debug("Executando comando shell")
 
 	cmd := exec.CommandContext(execCtx, command.Command, sanitizedArgs...)
+	// Cancelado ou expirado o contexto, não esperar por filhos que herdaram
+	// a saída do processo morto
+	cmd.WaitDelay = shellWaitDelay
 
-	// Configurar ambiente limitado
-	cmd.Env = []string{
-		"PATH=/usr/bin:/bin:/usr/sbin:/sbin",
-		"HOME=/tmp",
-		"USER=nobody",
-	}
+	// Ambiente limitado, com options.env aplicado por cima
+	cmd.Env = env
+	cmd.Dir = dir
 
-	// Executar e capturar saída
-	output, err := cmd.CombinedOutput()
+	result, err := e.runProcess(ctx, execCtx, command, cmd, e.outputLimit(spec), structuredOutputFormat(sanitizedArgs), startTime)
+	if err != nil {
+		return nil, err
+	}
+	if report.AuditEnv {
+		result.WorkingDir = dir
+		result.Env = env
+	}
+	return result, nil
+}
 
-	// Limitar tamanho da saída
-	outputStr := string(output)
-	if len(outputStr) > e.config.MaxOutputSize {
-		outputStr = outputStr[:e.config.MaxOutputSize] + "\n... (saída truncada)"
+// runProcess executa cmd, já configurado, sob execCtx (com o timeout do
+// comando) e monta o resultado com a saída capturada até limit. Compartilhado
+// pelos comandos shell e script.
+func (e *Executor) runProcess(ctx, execCtx context.Context, command *comms.Command, cmd *exec.Cmd, limit int, format string, startTime time.Time) (*comms.CommandResult, error) {
+	// Executar e capturar saída até o limite, sem acumular o excedente. Com
+	// options.stream, a saída retida também sai em frames command_progress;
+	// o resultado final é o mesmo do modo sem stream.
+	output := newOutputBuffer(limit)
+	var err error
+	if streamRequested(command) && e.config.OnProgress != nil {
+		stream := newOutputStream(output, e.progressEmitter(command, startTime))
+		cmd.Stdout = stream
+		cmd.Stderr = stream
+		err = cmd.Run()
+		stream.Close()
+	} else {
+		cmd.Stdout = output
+		cmd.Stderr = output
+		err = cmd.Run()
 	}
 
 	// Determinar código de saída
@@ -272,16 +440,36 @@ func (e *Executor) executeShellCommand(ctx context.Context, command *comms.Comma
 	result := &comms.CommandResult{
 		ID:            command.ID,
 		CommandID:     command.ID,
-		Status:        "success",
-		Output:        outputStr,
+		Status:        comms.StatusRunning,
 		ExitCode:      exitCode,
 		ExecutionTime: time.Since(startTime).Milliseconds(),
 		Timestamp:     time.Now(),
 	}
+	setOutput(result, output, format)
 
-	if err != nil {
-		result.Status = "error"
-		result.Error = err.Error()
+	finalStatus := comms.StatusSuccess
+	if execCtx.Err() == context.DeadlineExceeded {
+		finalStatus = comms.StatusTimeout
+	} else if ctx.Err() == context.Canceled {
+		finalStatus = comms.StatusCancelled
+	} else if err != nil {
+		finalStatus = comms.StatusError
+	}
+	if transitionErr := result.SetStatus(finalStatus); transitionErr != nil {
+		return nil, transitionErr
+	}
+
+	if finalStatus == comms.StatusCancelled {
+		// A saída capturada até o cancelamento segue no resultado
+		result.SetError(cancelledError(context.Cause(ctx)))
+
+		e.logger.WithFields(map[string]interface{}{
+			"command":     command.Command,
+			"output_size": output.total,
+			"reason":      context.Cause(ctx).Error(),
+		}).Warning("Execução de comando cancelada")
+	} else if err != nil {
+		result.SetError(err)
 
 		e.logger.WithFields(map[string]interface{}{
 			"command":   command.Command,
@@ -293,7 +481,8 @@ func (e *Executor) executeShellCommand(ctx context.Context, command *comms.Comma
 			"command":        command.Command,
 			"exit_code":      exitCode,
 			"execution_time": result.ExecutionTime,
-			"output_size":    len(outputStr),
+			"output_size":    output.total,
+			"truncated":      result.OutputTruncated,
 		}).Info("Comando executado com sucesso")
 	}
 
@@ -313,12 +502,12 @@ func (e *Executor) executeInfoCommand(ctx context.Context, command *comms.Comman
 		"timestamp":    time.Now().Unix(),
 	}
 
-	output := fmt.Sprintf("Informações do sistema coletadas: %+v", info)
+	output := fmt.Sprintf("System information collected: %+v", info)
 
 	return &comms.CommandResult{
 		ID:            command.ID,
 		CommandID:     command.ID,
-		Status:        "success",
+		Status:        comms.StatusSuccess,
 		Output:        output,
 		ExitCode:      0,
 		ExecutionTime: time.Since(startTime).Milliseconds(),
@@ -333,7 +522,7 @@ func (e *Executor) executePingCommand(ctx context.Context, command *comms.Comman
 	return &comms.CommandResult{
 		ID:            command.ID,
 		CommandID:     command.ID,
-		Status:        "success",
+		Status:        comms.StatusSuccess,
 		Output:        "pong",
 		ExitCode:      0,
 		ExecutionTime: time.Since(startTime).Milliseconds(),
@@ -341,23 +530,105 @@ func (e *Executor) executePingCommand(ctx context.Context, command *comms.Comman
 	}, nil
 }
 
-// createErrorResult cria um resultado de erro padronizado
-func (e *Executor) createErrorResult(command *comms.Command, errorMsg string, exitCode int, startTime time.Time) *comms.CommandResult {
-	return &comms.CommandResult{
+// track registra a execução como cancelável e retorna a função que a
+// remove. Um command_id repetido substitui o anterior no registro.
+func (e *Executor) track(commandID string, cancel context.CancelCauseFunc) (untrack func()) {
+	if commandID == "" {
+		return func() {}
+	}
+
+	running := &runningCommand{cancel: cancel}
+	e.runningMutex.Lock()
+	e.running[commandID] = running
+	e.runningMutex.Unlock()
+
+	return func() {
+		e.runningMutex.Lock()
+		defer e.runningMutex.Unlock()
+		if e.running[commandID] == running {
+			delete(e.running, commandID)
+		}
+	}
+}
+
+// Cancel interrompe a execução do comando (na fila ou rodando); o resultado
+// sai com status cancelled e a saída capturada até ali. Retorna false se o
+// comando não está em execução.
+func (e *Executor) Cancel(commandID, reason string) bool {
+	e.runningMutex.Lock()
+	running, ok := e.running[commandID]
+	e.runningMutex.Unlock()
+
+	if !ok {
+		return false
+	}
+	running.cancel(fmt.Errorf("%w: %s", ErrCommandCancelled, reason))
+	return true
+}
+
+// CancelAll interrompe todas as execuções em andamento e retorna seus
+// command_ids
+func (e *Executor) CancelAll(reason string) []string {
+	e.runningMutex.Lock()
+	defer e.runningMutex.Unlock()
+
+	cause := fmt.Errorf("%w: %s", ErrCommandCancelled, reason)
+	ids := make([]string, 0, len(e.running))
+	for id, running := range e.running {
+		running.cancel(cause)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Running retorna os command_ids em execução, em ordem alfabética
+func (e *Executor) Running() []string {
+	e.runningMutex.Lock()
+	defer e.runningMutex.Unlock()
+
+	ids := make([]string, 0, len(e.running))
+	for id := range e.running {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// cancelledError converte a causa do cancelamento no erro command_cancelled;
+// cancelamentos do contexto de quem chamou (ex.: agente parando) não trazem
+// motivo próprio
+func cancelledError(cause error) error {
+	reason := "context cancelled"
+	if errors.Is(cause, ErrCommandCancelled) {
+		reason = strings.TrimPrefix(cause.Error(), ErrCommandCancelled.Error()+": ")
+	}
+	return comms.NewCodedError(comms.ErrCodeCommandCancelled, reason)
+}
+
+// createErrorResult cria um resultado de erro padronizado com o status terminal informado.
+// Error, ErrorCode e LegacyError vêm do catálogo de códigos (ver comms.SetError).
+func (e *Executor) createErrorResult(command *comms.Command, status comms.CommandStatus, err error, exitCode int, startTime time.Time) *comms.CommandResult {
+	if !status.IsTerminal() || status == comms.StatusSuccess {
+		status = comms.StatusError
+	}
+
+	result := &comms.CommandResult{
 		ID:            command.ID,
 		CommandID:     command.ID,
-		Status:        "error",
-		Error:         errorMsg,
+		Status:        status,
 		ExitCode:      exitCode,
 		ExecutionTime: time.Since(startTime).Milliseconds(),
 		Timestamp:     time.Now(),
 	}
+	result.SetError(err)
+	return result
 }
 
 // GetMetrics retorna as métricas de execução
 func (e *Executor) GetMetrics() ExecutionMetrics {
-	e.metrics.mutex.RLock()
-	defer e.metrics.mutex.RUnlock()
+	e.metricsMutex.RLock()
+	defer e.metricsMutex.RUnlock()
 
 	// Fazer uma cópia das métricas
 	metrics := ExecutionMetrics{
@@ -384,23 +655,56 @@ func (e *Executor) IsSupported(command *comms.Command) bool {
 		return false
 	}
 
-	switch command.Type {
-	case "shell":
-		return e.whitelist.ValidateCommand(command.Command, command.Args) == nil
-	case "info", "ping":
-		return true
-	default:
+	if _, ok := commandHandlers[command.Type]; !ok {
 		return false
 	}
+	if command.Type == "shell" {
+		return e.GetWhitelist().ValidateCommand(command.Command, command.Args) == nil
+	}
+	return true
 }
 
 // GetTimeout retorna o timeout configurado
 func (e *Executor) GetTimeout() time.Duration {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	return e.config.DefaultTimeout
 }
 
-// GetWhitelist retorna a whitelist atual
+// SetLimits troca o timeout padrão e o limite de execuções simultâneas
+// (valores <= 0 mantêm o atual). Execuções em andamento terminam contando no
+// limite antigo; as novas passam a disputar o novo.
+func (e *Executor) SetLimits(defaultTimeout time.Duration, maxConcurrent int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if defaultTimeout > 0 {
+		e.config.DefaultTimeout = defaultTimeout
+	}
+	if maxConcurrent > 0 && maxConcurrent != e.config.MaxConcurrent {
+		e.config.MaxConcurrent = maxConcurrent
+		e.semaphore = make(chan struct{}, maxConcurrent)
+	}
+}
+
+// GetMaxConcurrent retorna o limite de execuções simultâneas
+func (e *Executor) GetMaxConcurrent() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.config.MaxConcurrent
+}
+
+// currentSemaphore retorna o semáforo vigente (trocado por SetLimits)
+func (e *Executor) currentSemaphore() chan struct{} {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.semaphore
+}
+
+// GetWhitelist retorna a whitelist vigente; não deve ser alterada
 func (e *Executor) GetWhitelist() *CommandWhitelist {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	return e.whitelist
 }
 
@@ -410,8 +714,8 @@ func (e *Executor) updateMetrics(updateFunc func(*ExecutionMetrics)) {
 		return
 	}
 
-	e.metrics.mutex.Lock()
-	defer e.metrics.mutex.Unlock()
+	e.metricsMutex.Lock()
+	defer e.metricsMutex.Unlock()
 	updateFunc(e.metrics)
 }
 
@@ -421,8 +725,8 @@ func (e *Executor) updateCommandStats(command string, duration time.Duration, su
 		return
 	}
 
-	e.metrics.mutex.Lock()
-	defer e.metrics.mutex.Unlock()
+	e.metricsMutex.Lock()
+	defer e.metricsMutex.Unlock()
 
 	stats, exists := e.metrics.CommandStats[command]
 	if !exists {
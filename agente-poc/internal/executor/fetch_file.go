@@ -0,0 +1,282 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+	"unicode/utf8"
+
+	"agente-poc/internal/comms"
+)
+
+// Limites padrão do comando fetch_file
+const (
+	defaultFetchFileMaxBytes = 5 * 1024 * 1024
+	// fetchFileAttempts é quantas vezes um arquivo alterado durante a
+	// leitura é relido antes de desistir
+	fetchFileAttempts = 3
+)
+
+// FetchFileResponse é o conteúdo do Output de um fetch_file. Content é
+// sempre base64, inclusive para texto; Binary só informa o tipo do conteúdo.
+type FetchFileResponse struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Mode     string    `json:"mode"`
+	ModTime  time.Time `json:"mtime"`
+	SHA256   string    `json:"sha256"`
+	Binary   bool      `json:"binary"`
+	Encoding string    `json:"encoding"`
+	Content  string    `json:"content"`
+}
+
+// DefaultFetchFileDirs são os diretórios de log do sistema aceitos pelo
+// fetch_file quando Config.FetchFileDirs não é definido
+func DefaultFetchFileDirs() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"/var/log", "/Library/Logs"}
+	case "windows":
+		return []string{`C:\Windows\Logs`}
+	default:
+		return []string{"/var/log"}
+	}
+}
+
+// executeFetchFile lê um arquivo dentro de Config.FetchFileDirs e o retorna
+// em base64 com SHA-256, permissões e mtime. O caminho vem de
+// command.Command ou Args[0] e precisa ser absoluto; links simbólicos são
+// resolvidos antes da verificação. Um arquivo que muda durante a leitura é
+// relido até fetchFileAttempts vezes e, se continuar mudando, o comando
+// falha com file_changed_during_read.
+func (e *Executor) executeFetchFile(ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error) {
+	path := command.Command
+	if path == "" && len(command.Args) > 0 {
+		path = command.Args[0]
+	}
+
+	maxBytes := e.config.FetchFileMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultFetchFileMaxBytes
+	}
+
+	var resolved string
+	var data []byte
+	var current os.FileInfo
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return e.createErrorResult(command, comms.StatusTimeout, err, -1, startTime), err
+		}
+
+		// Validado a cada tentativa: o arquivo pode ter sido trocado
+		var info os.FileInfo
+		var err error
+		resolved, info, err = e.resolveFetchPath(path)
+		if err != nil {
+			return e.rejectFetchFile(command, path, err, startTime)
+		}
+		if info.Size() > maxBytes {
+			return e.rejectFetchFile(command, path, comms.NewCodedError(comms.ErrCodeFileTooLarge, resolved, info.Size(), maxBytes), startTime)
+		}
+
+		if attempt == 1 {
+			e.logger.WithFields(map[string]interface{}{
+				"path": resolved,
+				"size": info.Size(),
+			}).Debug("Executando fetch_file")
+		}
+
+		var stable bool
+		data, current, stable, err = readStableFile(resolved, info, maxBytes)
+		if err != nil {
+			var coded *comms.CodedError
+			if errors.As(err, &coded) && coded.Code == comms.ErrCodeFileTooLarge {
+				return e.rejectFetchFile(command, path, err, startTime)
+			}
+			return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+		}
+		if stable {
+			break
+		}
+		if attempt == fetchFileAttempts {
+			err := comms.NewCodedError(comms.ErrCodeFileChangedDuringRead, resolved)
+			return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	response := FetchFileResponse{
+		Path:     resolved,
+		Size:     int64(len(data)),
+		Mode:     fmt.Sprintf("%04o", current.Mode().Perm()),
+		ModTime:  current.ModTime(),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Binary:   bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data),
+		Encoding: "base64",
+		Content:  base64.StdEncoding.EncodeToString(data),
+	}
+
+	output, err := json.Marshal(response)
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+
+	e.logger.WithFields(map[string]interface{}{
+		"path":   resolved,
+		"size":   response.Size,
+		"sha256": response.SHA256,
+	}).Info("Arquivo enviado pelo fetch_file")
+
+	return &comms.CommandResult{
+		ID:            command.ID,
+		CommandID:     command.ID,
+		Status:        comms.StatusSuccess,
+		Output:        string(output),
+		ExitCode:      0,
+		ExecutionTime: time.Since(startTime).Milliseconds(),
+		Timestamp:     time.Now(),
+	}, nil
+}
+
+// resolveFetchPath aceita apenas arquivos regulares com caminho absoluto
+// dentro dos diretórios permitidos. O caminho limpo é verificado antes de
+// qualquer acesso ao disco, para não revelar a existência de arquivos fora
+// da allowlist; o caminho real (sem links) é verificado de novo.
+func (e *Executor) resolveFetchPath(path string) (string, os.FileInfo, error) {
+	notAllowed := comms.NewCodedError(comms.ErrCodePathNotAllowed, path)
+	if path == "" || !filepath.IsAbs(path) {
+		return "", nil, notAllowed
+	}
+
+	dirs := e.config.FetchFileDirs
+	if len(dirs) == 0 {
+		dirs = DefaultFetchFileDirs()
+	}
+
+	cleaned := filepath.Clean(path)
+	if !withinAny(cleaned, dirs) {
+		return "", nil, notAllowed
+	}
+
+	resolved, err := filepath.EvalSymlinks(cleaned)
+	if err != nil {
+		return "", nil, err
+	}
+	if !withinAny(resolved, dirs) {
+		return "", nil, notAllowed
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", nil, comms.NewCodedError(comms.ErrCodeNotRegularFile, path)
+	}
+	return resolved, info, nil
+}
+
+// withinAny indica se path está em um dos diretórios, comparando também com
+// o caminho real de cada um (/var/log no macOS fica em /private/var/log)
+func withinAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if within(path, dir) {
+			return true
+		}
+		if real, err := filepath.EvalSymlinks(dir); err == nil && within(path, real) {
+			return true
+		}
+	}
+	return false
+}
+
+// readStableFile lê o arquivo e confere se ele não mudou durante a leitura:
+// o mesmo arquivo (inode) validado antes, com tamanho e mtime iguais antes e
+// depois, e o SHA-256 do que foi lido igual ao de uma segunda leitura
+func readStableFile(path string, expected os.FileInfo, maxBytes int64) ([]byte, os.FileInfo, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer file.Close()
+
+	before, err := file.Stat()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !os.SameFile(before, expected) {
+		return nil, before, false, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, nil, false, comms.NewCodedError(comms.ErrCodeFileTooLarge, path, int64(len(data)), maxBytes)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !os.SameFile(before, after) || after.Size() != int64(len(data)) || !after.ModTime().Equal(before.ModTime()) {
+		return nil, after, false, nil
+	}
+
+	verify, err := hashFile(path, maxBytes)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(verify, sum[:]) {
+		return nil, after, false, nil
+	}
+	return data, after, true, nil
+}
+
+// hashFile calcula o SHA-256 de até maxBytes+1 bytes do arquivo
+func hashFile(path string, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(file, maxBytes+1)); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+// rejectFetchFile recusa o fetch_file (fora da allowlist, acima do limite ou
+// não é arquivo regular) e conta em RejectedCommands; erros do sistema de
+// arquivos (inexistente, sem permissão) saem como error
+func (e *Executor) rejectFetchFile(command *comms.Command, path string, err error, startTime time.Time) (*comms.CommandResult, error) {
+	logger := e.logger.WithFields(map[string]interface{}{
+		"path":  path,
+		"error": err.Error(),
+	})
+
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) {
+		// Arquivo inexistente ou sem permissão de leitura
+		logger.Warning("Falha ao abrir arquivo do fetch_file")
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+
+	e.updateMetrics(func(m *ExecutionMetrics) { m.RejectedCommands++ })
+	logger.Warning("fetch_file rejeitado")
+	return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+}
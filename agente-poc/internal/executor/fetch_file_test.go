@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"agente-poc/internal/comms"
+)
+
+// fetchFileFixture cria o diretório permitido com um log de texto, um
+// arquivo binário, um subdiretório e um link apontando para fora, além de um
+// segredo fora da allowlist
+func fetchFileFixture(t *testing.T) (logs, secret string) {
+	t.Helper()
+	root := t.TempDir()
+	logs = filepath.Join(root, "logs")
+	if err := os.MkdirAll(filepath.Join(logs, "archive"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	secret = filepath.Join(root, "secret.txt")
+	files := map[string][]byte{
+		filepath.Join(logs, "agent.log"):  []byte("line 1\nline 2\n"),
+		filepath.Join(logs, "core.bin"):   {0x7f, 'E', 'L', 'F', 0x00, 0xff, 0xfe},
+		filepath.Join(logs, "big.log"):    make([]byte, 2048),
+		filepath.Join(logs, "empty.log"):  nil,
+		filepath.Join(root, "secret.txt"): []byte("token"),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0o640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(secret, filepath.Join(logs, "secret.log")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logs, _ = filepath.EvalSymlinks(logs)
+	return logs, secret
+}
+
+func newFetchFileTestExecutor(t *testing.T, dirs []string) *Executor {
+	t.Helper()
+	return newTestExecutor(t, func(c *Config) {
+		c.FetchFileDirs = dirs
+		c.FetchFileMaxBytes = 1024
+	})
+}
+
+func fetchFile(e *Executor, path string) (*comms.CommandResult, error) {
+	return e.Execute(context.Background(), &comms.Command{ID: "cmd-fetch", Type: "fetch_file", Command: path})
+}
+
+func TestFetchFile(t *testing.T) {
+	logs, _ := fetchFileFixture(t)
+	e := newFetchFileTestExecutor(t, []string{logs})
+
+	for _, tt := range []struct {
+		name   string
+		file   string
+		binary bool
+	}{
+		{name: "text", file: "agent.log"},
+		{name: "binary", file: "core.bin", binary: true},
+		{name: "empty", file: "empty.log"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(logs, tt.file)
+			result, err := fetchFile(e, path)
+			if err != nil || result.Status != comms.StatusSuccess {
+				t.Fatalf("result = %+v, %v", result, err)
+			}
+
+			var response FetchFileResponse
+			if err := json.Unmarshal([]byte(result.Output), &response); err != nil {
+				t.Fatal(err)
+			}
+			content, err := base64.StdEncoding.DecodeString(response.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := os.ReadFile(path)
+			info, _ := os.Stat(path)
+			sum := sha256.Sum256(want)
+			if string(content) != string(want) || response.SHA256 != hex.EncodeToString(sum[:]) || response.Size != int64(len(want)) {
+				t.Fatalf("content %q, sha256 %s, size %d", content, response.SHA256, response.Size)
+			}
+			if response.Path != path || response.Binary != tt.binary || response.Encoding != "base64" || !response.ModTime.Equal(info.ModTime()) {
+				t.Fatalf("response = %+v", response)
+			}
+			if runtime.GOOS != "windows" && response.Mode != "0640" {
+				t.Fatalf("mode = %s", response.Mode)
+			}
+		})
+	}
+}
+
+func TestFetchFileRejected(t *testing.T) {
+	logs, secret := fetchFileFixture(t)
+	e := newFetchFileTestExecutor(t, []string{logs})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode comms.ErrorCode
+	}{
+		{name: "traversal", path: logs + "/../../../../etc/passwd", wantCode: comms.ErrCodePathNotAllowed},
+		{name: "traversal to a sibling", path: filepath.Join(logs, "..", "secret.txt"), wantCode: comms.ErrCodePathNotAllowed},
+		{name: "relative traversal", path: "../../etc/passwd", wantCode: comms.ErrCodePathNotAllowed},
+		{name: "relative inside", path: "agent.log", wantCode: comms.ErrCodePathNotAllowed},
+		{name: "outside", path: secret, wantCode: comms.ErrCodePathNotAllowed},
+		{name: "prefix lookalike", path: logs + "-old/agent.log", wantCode: comms.ErrCodePathNotAllowed},
+		{name: "empty path", path: "", wantCode: comms.ErrCodePathNotAllowed},
+		{name: "directory", path: filepath.Join(logs, "archive"), wantCode: comms.ErrCodeNotRegularFile},
+		{name: "too large", path: filepath.Join(logs, "big.log"), wantCode: comms.ErrCodeFileTooLarge},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, struct {
+			name     string
+			path     string
+			wantCode comms.ErrorCode
+		}{name: "symlink escaping the allowlist", path: filepath.Join(logs, "secret.log"), wantCode: comms.ErrCodePathNotAllowed})
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fetchFile(e, tt.path)
+			var coded *comms.CodedError
+			if !errors.As(err, &coded) || coded.Code != tt.wantCode {
+				t.Fatalf("error = %v, want %s", err, tt.wantCode)
+			}
+			if result.Status != comms.StatusRejected || result.ErrorCode != tt.wantCode || result.Output != "" {
+				t.Fatalf("result = %+v", result)
+			}
+			if got := e.GetMetrics().RejectedCommands; got != int64(i+1) {
+				t.Fatalf("RejectedCommands = %d, want %d", got, i+1)
+			}
+		})
+	}
+}
+
+func TestFetchFileMissingIsError(t *testing.T) {
+	logs, _ := fetchFileFixture(t)
+	e := newFetchFileTestExecutor(t, []string{logs})
+
+	// Inexistente dentro da allowlist é erro do sistema de arquivos, não recusa
+	result, err := fetchFile(e, filepath.Join(logs, "missing.log"))
+	if !errors.Is(err, os.ErrNotExist) || result.Status != comms.StatusError {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+	if got := e.GetMetrics().RejectedCommands; got != 0 {
+		t.Fatalf("missing file counted as rejected (%d)", got)
+	}
+}
+
+func TestReadStableFileDetectsReplacement(t *testing.T) {
+	logs, _ := fetchFileFixture(t)
+	path := filepath.Join(logs, "agent.log")
+	validated, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotação entre a validação e a leitura: outro arquivo no mesmo caminho
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("rotated\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, stable, err := readStableFile(path, validated, 1024); err != nil || stable {
+		t.Fatalf("replaced file: stable %t, err %v", stable, err)
+	}
+
+	// Conteúdo que cresceu além do limite depois da validação
+	current, _ := os.Stat(path)
+	if err := os.WriteFile(path, make([]byte, 64), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = readStableFile(path, current, 16)
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeFileTooLarge {
+		t.Fatalf("grown file: %v", err)
+	}
+}
+
+func TestFetchFileChangingDuringRead(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("uses /proc")
+	}
+	// Arquivos do /proc informam tamanho 0 e têm conteúdo: a leitura nunca
+	// confere com o stat, como um arquivo que muda a cada tentativa
+	e := newFetchFileTestExecutor(t, []string{"/proc/self"})
+	result, err := fetchFile(e, "/proc/self/stat")
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeFileChangedDuringRead {
+		t.Fatalf("error = %v", err)
+	}
+	if result.Status != comms.StatusError || result.Output != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := e.GetMetrics().RejectedCommands; got != 0 {
+		t.Fatalf("changed file counted as rejected (%d)", got)
+	}
+}
+
+func TestFetchFileCancelled(t *testing.T) {
+	logs, _ := fetchFileFixture(t)
+	e := newFetchFileTestExecutor(t, []string{logs})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := e.Execute(ctx, &comms.Command{ID: "cmd-fetch", Type: "fetch_file", Command: filepath.Join(logs, "agent.log")})
+	if err == nil || result.Status == comms.StatusSuccess {
+		t.Fatalf("fetch with a cancelled context: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"agente-poc/internal/logging"
+)
+
+// testLogger descarta tudo abaixo de FATAL, para não poluir a saída dos testes
+func testLogger(t *testing.T) logging.Logger {
+	t.Helper()
+	logger, err := logging.NewLogger(&logging.Config{Level: logging.FATAL, Output: "stderr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+// newTestExecutor cria um executor com a configuração padrão, ajustada por
+// configure (pode ser nil)
+func newTestExecutor(t *testing.T, configure func(*Config)) *Executor {
+	t.Helper()
+	config := &Config{
+		MaxConcurrent:  2,
+		DefaultTimeout: 10 * time.Second,
+		EnableMetrics:  true,
+		Logger:         testLogger(t),
+	}
+	if configure != nil {
+		configure(config)
+	}
+	e, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+	"agente-poc/internal/version"
+)
+
+// Limites padrão do comando http_probe
+const (
+	defaultHTTPProbeMaxBytes = 64 * 1024
+	defaultHTTPProbeTimeout  = 10 * time.Second
+	maxHTTPProbeRedirects    = 5
+)
+
+// HTTPProbeResponse é o conteúdo do Output de um http_probe
+type HTTPProbeResponse struct {
+	URL        string              `json:"url"`
+	StatusCode int                 `json:"status_code"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+	BodyBytes  int                 `json:"body_bytes"`
+	Truncated  bool                `json:"truncated"`
+}
+
+// executeHTTPProbe faz um GET em um serviço local (ou host da allowlist) e
+// retorna status, cabeçalhos e corpo truncado.
+// A URL vem de command.Command ou Args[0]; Options["insecure_skip_verify"]
+// aceita certificados auto-assinados apenas em localhost.
+func (e *Executor) executeHTTPProbe(ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error) {
+	rawURL := command.Command
+	if rawURL == "" && len(command.Args) > 0 {
+		rawURL = command.Args[0]
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return e.createErrorResult(command, comms.StatusRejected, comms.NewCodedError(comms.ErrCodeInvalidURL), -1, startTime),
+			fmt.Errorf("URL inválida para http_probe: %q", rawURL)
+	}
+
+	if !e.isHTTPProbeHostAllowed(target.Hostname()) {
+		e.logger.WithField("host", target.Hostname()).Warning("Host rejeitado pelo http_probe")
+		err := comms.NewCodedError(comms.ErrCodeHostNotAllowed, target.Hostname())
+		return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+	}
+
+	insecure, _ := command.Options["insecure_skip_verify"].(bool)
+	if insecure && !isLoopbackHost(target.Hostname()) {
+		err := comms.NewCodedError(comms.ErrCodeInsecureNotLoopback)
+		return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+	}
+
+	timeout := defaultHTTPProbeTimeout
+	if command.Timeout > 0 {
+		timeout = time.Duration(command.Timeout) * time.Second
+	}
+
+	maxBytes := e.config.HTTPProbeMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultHTTPProbeMaxBytes
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           nil, // serviços locais nunca passam por proxy
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPProbeRedirects {
+				return comms.NewCodedError(comms.ErrCodeTooManyRedirects)
+			}
+			if !e.isHTTPProbeHostAllowed(req.URL.Hostname()) {
+				return comms.NewCodedError(comms.ErrCodeRedirectNotAllowed, req.URL.Hostname())
+			}
+			if insecure && !isLoopbackHost(req.URL.Hostname()) {
+				return comms.NewCodedError(comms.ErrCodeInsecureNotLoopback)
+			}
+			return nil
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	e.logger.WithFields(map[string]interface{}{
+		"url":     target.String(),
+		"timeout": timeout.String(),
+	}).Debug("Executando http_probe")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		status := comms.StatusError
+		if ctx.Err() == context.DeadlineExceeded || isTimeout(err) {
+			status = comms.StatusTimeout
+		}
+		return e.createErrorResult(command, status, err, -1, startTime), err
+	}
+	defer resp.Body.Close()
+
+	// Ler um byte além do limite para saber se houve truncamento
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+
+	probe := HTTPProbeResponse{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		BodyBytes:  len(body),
+	}
+	if len(body) > maxBytes {
+		body = body[:maxBytes]
+		probe.Truncated = true
+		probe.BodyBytes = maxBytes
+	}
+	probe.Body = string(body)
+
+	output, err := json.Marshal(probe)
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+
+	return &comms.CommandResult{
+		ID:            command.ID,
+		CommandID:     command.ID,
+		Status:        comms.StatusSuccess,
+		Output:        string(output),
+		ExitCode:      0,
+		ExecutionTime: time.Since(startTime).Milliseconds(),
+		Timestamp:     time.Now(),
+	}, nil
+}
+
+// isHTTPProbeHostAllowed permite loopback e os hosts configurados em HTTPProbeAllowedHosts
+func (e *Executor) isHTTPProbeHostAllowed(host string) bool {
+	if isLoopbackHost(host) {
+		return true
+	}
+
+	for _, allowed := range e.config.HTTPProbeAllowedHosts {
+		if strings.EqualFold(allowed, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackHost verifica se o host é localhost ou um IP de loopback
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isTimeout verifica se o erro de rede é um timeout
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
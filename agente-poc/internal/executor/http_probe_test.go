@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agente-poc/internal/comms"
+)
+
+// probe executa um http_probe da URL com as opções dadas
+func probe(t *testing.T, e *Executor, url string, options map[string]interface{}) (*comms.CommandResult, HTTPProbeResponse) {
+	t.Helper()
+	result, _ := e.Execute(context.Background(), &comms.Command{
+		ID:      "probe-1",
+		Type:    "http_probe",
+		Command: url,
+		Options: options,
+	})
+	if result == nil {
+		t.Fatal("http_probe returned no result")
+	}
+	var response HTTPProbeResponse
+	if result.Status == comms.StatusSuccess {
+		if err := json.Unmarshal([]byte(result.Output), &response); err != nil {
+			t.Fatalf("invalid http_probe output: %v", err)
+		}
+	}
+	return result, response
+}
+
+func TestHTTPProbeAllowlist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Service", "up")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	e := newTestExecutor(t, func(c *Config) { c.HTTPProbeAllowedHosts = []string{"Metrics.Internal"} })
+
+	result, response := probe(t, e, server.URL+"/health", nil)
+	if result.Status != comms.StatusSuccess {
+		t.Fatalf("loopback probe: %s (%s)", result.Status, result.Error)
+	}
+	if response.StatusCode != http.StatusOK || response.Body != "ok" || response.Headers["X-Service"][0] != "up" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	for _, url := range []string{"http://backend.example.com/", "http://10.0.0.1/", "ftp://127.0.0.1/", "not a url"} {
+		result, _ := probe(t, e, url, nil)
+		if result.Status != comms.StatusRejected {
+			t.Errorf("%s: status %s, want rejected", url, result.Status)
+		}
+	}
+	if result, _ := probe(t, e, "http://backend.example.com/", nil); result.ErrorCode != comms.ErrCodeHostNotAllowed {
+		t.Errorf("non-allowlisted host: error code %s", result.ErrorCode)
+	}
+
+	for host, want := range map[string]bool{
+		"localhost":        true,
+		"127.0.0.1":        true,
+		"::1":              true,
+		"metrics.internal": true,
+		"metrics.example":  false,
+		"192.168.1.10":     false,
+	} {
+		if got := e.isHTTPProbeHostAllowed(host); got != want {
+			t.Errorf("isHTTPProbeHostAllowed(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestHTTPProbeSizeCap(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		maxBytes  int
+		wantBytes int
+		truncated bool
+	}{
+		{maxBytes: 10, wantBytes: 10, truncated: true},
+		{maxBytes: 99, wantBytes: 99, truncated: true},
+		{maxBytes: 100, wantBytes: 100, truncated: false},
+		{maxBytes: 0, wantBytes: 100, truncated: false}, // padrão de 64 KB
+	}
+	for _, tt := range tests {
+		e := newTestExecutor(t, func(c *Config) { c.HTTPProbeMaxBytes = tt.maxBytes })
+		result, response := probe(t, e, server.URL, nil)
+		if result.Status != comms.StatusSuccess {
+			t.Fatalf("max %d: %s (%s)", tt.maxBytes, result.Status, result.Error)
+		}
+		if len(response.Body) != tt.wantBytes || response.BodyBytes != tt.wantBytes || response.Truncated != tt.truncated {
+			t.Errorf("max %d: body %d bytes (reported %d), truncated %t", tt.maxBytes, len(response.Body), response.BodyBytes, response.Truncated)
+		}
+	}
+}
+
+func TestHTTPProbeRedirectPolicy(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/local", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("final"))
+	})
+	mux.HandleFunc("/external", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://backend.example.com/steal", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	e := newTestExecutor(t, nil)
+
+	result, response := probe(t, e, server.URL+"/local", nil)
+	if result.Status != comms.StatusSuccess || response.Body != "final" || !strings.HasSuffix(response.URL, "/final") {
+		t.Fatalf("loopback redirect: %s, %+v", result.Status, response)
+	}
+
+	tests := map[string]comms.ErrorCode{
+		"/external": comms.ErrCodeRedirectNotAllowed,
+		"/loop":     comms.ErrCodeTooManyRedirects,
+	}
+	for path, code := range tests {
+		result, _ := probe(t, e, server.URL+path, nil)
+		if result.Status == comms.StatusSuccess || result.ErrorCode != code {
+			t.Errorf("%s: status %s, error code %s, want %s", path, result.Status, result.ErrorCode, code)
+		}
+	}
+}
+
+func TestHTTPProbeInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls"))
+	}))
+	defer server.Close()
+
+	e := newTestExecutor(t, func(c *Config) { c.HTTPProbeAllowedHosts = []string{"backend.example.com"} })
+
+	if result, _ := probe(t, e, server.URL, nil); result.Status == comms.StatusSuccess {
+		t.Fatal("self-signed certificate accepted without insecure_skip_verify")
+	}
+	result, response := probe(t, e, server.URL, map[string]interface{}{"insecure_skip_verify": true})
+	if result.Status != comms.StatusSuccess || response.Body != "tls" {
+		t.Fatalf("insecure loopback probe: %s (%s)", result.Status, result.Error)
+	}
+
+	// Só em loopback, mesmo para um host da allowlist
+	result, _ = probe(t, e, "https://backend.example.com/", map[string]interface{}{"insecure_skip_verify": true})
+	if result.Status != comms.StatusRejected || result.ErrorCode != comms.ErrCodeInsecureNotLoopback {
+		t.Fatalf("insecure allowlisted host: %s, %s", result.Status, result.ErrorCode)
+	}
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"agente-poc/internal/comms"
+)
+
+// defaultMaxOutputSize é o limite global de saída quando Config.MaxOutputSize não é definido
+const defaultMaxOutputSize = 1024 * 1024 // 1MB
+
+// outputBuffer captura até max bytes da saída do processo e descarta o
+// restante, contando o total produzido. Stdout e Stderr usam o mesmo buffer,
+// então exec.Cmd garante uma escrita por vez.
+type outputBuffer struct {
+	max   int
+	data  []byte
+	total int
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{max: max}
+}
+
+// Write nunca falha: o processo continua rodando mesmo após o limite
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := b.max - len(b.data); room > 0 {
+		if len(p) > room {
+			b.data = append(b.data, p[:room]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+	return len(p), nil
+}
+
+// Truncated indica se o processo produziu mais que o limite
+func (b *outputBuffer) Truncated() bool {
+	return b.total > len(b.data)
+}
+
+// trimPartialRune remove um caractere UTF-8 incompleto no fim de data,
+// deixado pelo corte no limite do buffer
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// outputLimit retorna o limite de saída do comando: o da spec, se definido,
+// senão o global
+func (e *Executor) outputLimit(spec CommandSpec) int {
+	if spec.ResourceLimits.MaxOutputBytes > 0 {
+		return spec.ResourceLimits.MaxOutputBytes
+	}
+	return e.config.MaxOutputSize
+}
+
+// structuredOutputFormat identifica comandos cuja saída é um documento
+// (JSON ou XML) que o backend interpreta; vazio para texto livre
+func structuredOutputFormat(args []string) string {
+	for _, arg := range args {
+		switch arg {
+		case "-json", "--json":
+			return "json"
+		case "-xml", "--xml":
+			return "xml"
+		}
+	}
+	return ""
+}
+
+// setOutput preenche Output a partir do buffer, marcando OutputTruncated e
+// OriginalOutputBytes quando o limite foi atingido. Nenhum texto de marcação
+// é adicionado à saída. Saídas estruturadas truncadas seriam documentos
+// inválidos: são omitidas e o motivo vai em Warnings.
+func setOutput(result *comms.CommandResult, buffer *outputBuffer, format string) {
+	if !buffer.Truncated() {
+		result.Output = string(buffer.data)
+		return
+	}
+
+	result.OutputTruncated = true
+	result.OriginalOutputBytes = buffer.total
+
+	if format != "" {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"%s output omitted: %d bytes exceed the %d byte limit and would not parse", format, buffer.total, buffer.max))
+		return
+	}
+
+	result.Output = string(trimPartialRune(buffer.data))
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"agente-poc/internal/comms"
+)
+
+func TestOutputBufferLimit(t *testing.T) {
+	buffer := newOutputBuffer(8)
+	for _, chunk := range []string{"abc", "defgh", "ijk", "lmnop"} {
+		if n, err := buffer.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	if string(buffer.data) != "abcdefgh" || buffer.total != 16 || !buffer.Truncated() {
+		t.Fatalf("buffer kept %q of %d bytes (truncated %t)", buffer.data, buffer.total, buffer.Truncated())
+	}
+
+	exact := newOutputBuffer(3)
+	exact.Write([]byte("abc"))
+	if exact.Truncated() {
+		t.Fatal("output exactly at the limit reported as truncated")
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"ascii", "abc", "abc"},
+		{"complete two-byte rune", "aé", "aé"},
+		{"cut two-byte rune", "a\xc3", "a"},
+		{"complete four-byte rune", "a😀", "a😀"},
+		{"cut four-byte rune", "a\xf0\x9f\x98", "a"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := string(trimPartialRune([]byte(tt.data))); got != tt.want {
+			t.Errorf("%s: trimPartialRune(%q) = %q, want %q", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	write := func(max int, data string) *outputBuffer {
+		buffer := newOutputBuffer(max)
+		buffer.Write([]byte(data))
+		return buffer
+	}
+
+	t.Run("within the limit", func(t *testing.T) {
+		result := &comms.CommandResult{}
+		setOutput(result, write(64, `{"ok":true}`), "json")
+		if result.Output != `{"ok":true}` || result.OutputTruncated || result.OriginalOutputBytes != 0 || len(result.Warnings) != 0 {
+			t.Fatalf("untruncated output changed: %+v", result)
+		}
+	})
+
+	t.Run("text cut on a rune boundary", func(t *testing.T) {
+		result := &comms.CommandResult{}
+		// "ação" ocupa 6 bytes; o limite de 4 corta o "ã" ao meio
+		setOutput(result, write(4, "ação ok"), "")
+		if result.Output != "aç" {
+			t.Fatalf("truncated output %q, want %q", result.Output, "aç")
+		}
+		if !utf8.ValidString(result.Output) {
+			t.Fatalf("truncated output is not valid UTF-8: %q", result.Output)
+		}
+		if !result.OutputTruncated || result.OriginalOutputBytes != len("ação ok") {
+			t.Fatalf("truncation not flagged: %+v", result)
+		}
+		if len(result.Warnings) != 0 {
+			t.Fatalf("text truncation produced warnings: %v", result.Warnings)
+		}
+	})
+
+	t.Run("structured output omitted", func(t *testing.T) {
+		for _, format := range []string{"json", "xml"} {
+			result := &comms.CommandResult{}
+			setOutput(result, write(8, `{"items":[1,2,3,4,5]}`), format)
+			if result.Output != "" {
+				t.Fatalf("%s: partial document sent: %q", format, result.Output)
+			}
+			if !result.OutputTruncated || result.OriginalOutputBytes != 21 {
+				t.Fatalf("%s: truncation not flagged: %+v", format, result)
+			}
+			if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], format+" output omitted") {
+				t.Fatalf("%s: warnings %v", format, result.Warnings)
+			}
+		}
+	})
+}
+
+func TestStructuredOutputFormat(t *testing.T) {
+	tests := map[string][]string{
+		"json": {"SPHardwareDataType", "-json"},
+		"xml":  {"--xml", "SPSoftwareDataType"},
+		"":     {"-a", "json"},
+	}
+	for want, args := range tests {
+		if got := structuredOutputFormat(args); got != want {
+			t.Errorf("structuredOutputFormat(%v) = %q, want %q", args, got, want)
+		}
+	}
+}
+
+func TestOutputLimit(t *testing.T) {
+	e := newTestExecutor(t, func(c *Config) { c.MaxOutputSize = 4096 })
+	if limit := e.outputLimit(CommandSpec{}); limit != 4096 {
+		t.Fatalf("limit without a spec value = %d, want the global 4096", limit)
+	}
+	if limit := e.outputLimit(CommandSpec{ResourceLimits: ResourceLimits{MaxOutputBytes: 100}}); limit != 100 {
+		t.Fatalf("limit with a spec value = %d, want 100", limit)
+	}
+
+	if e := newTestExecutor(t, nil); e.config.MaxOutputSize != defaultMaxOutputSize {
+		t.Fatalf("unset global limit = %d, want %d", e.config.MaxOutputSize, defaultMaxOutputSize)
+	}
+}
+
+func TestShellOutputTruncatedBySpec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses the echo executable")
+	}
+
+	e := newTestExecutor(t, func(c *Config) {
+		c.CustomWhitelist = map[string]CommandSpec{
+			"echo": {Name: "echo", ResourceLimits: ResourceLimits{MaxOutputBytes: 5}},
+		}
+	})
+
+	result, err := e.Execute(context.Background(), &comms.Command{
+		ID:      "cmd-truncated",
+		Type:    "shell",
+		Command: "echo",
+		Args:    []string{"olá mundo"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != comms.StatusSuccess {
+		t.Fatalf("status %s: %s", result.Status, result.Error)
+	}
+	// "olá mundo\n" tem 11 bytes; o corte em 5 fica depois do "á" completo
+	if result.Output != "olá " || !result.OutputTruncated || result.OriginalOutputBytes != 11 {
+		t.Fatalf("output %q, truncated %t, original %d", result.Output, result.OutputTruncated, result.OriginalOutputBytes)
+	}
+}
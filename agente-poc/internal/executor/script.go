@@ -0,0 +1,254 @@
+package executor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// scriptInterpreters são os interpretadores aceitos em options.interpreter
+var scriptInterpreters = []string{"/bin/sh", "/bin/zsh"}
+
+// defaultScriptInterpreter é usado quando options.interpreter não é enviado
+const defaultScriptInterpreter = "/bin/sh"
+
+// scriptMaxValidity é o máximo entre o recebimento de um script e o
+// options.expires_at assinado; limita por quanto tempo um command_id
+// precisa ficar registrado para recusar o replay
+const scriptMaxValidity = time.Hour
+
+// ScriptSigningPayload retorna os bytes assinados com Ed25519 pelo backend
+// para um comando script: o command_id, options.expires_at (RFC 3339, como
+// enviado), o interpretador e o corpo, separados por quebras de linha. Args
+// não são aceitos, para que tudo o que é executado esteja coberto pela
+// assinatura; o ID e a validade impedem que um script capturado seja
+// reenviado com outro ID ou depois de expirado.
+func ScriptSigningPayload(commandID, expiresAt, interpreter, script string) []byte {
+	return []byte(commandID + "\n" + expiresAt + "\n" + interpreter + "\n" + script)
+}
+
+// ParseScriptPublicKeys decodifica as chaves públicas Ed25519 (base64 dos
+// 32 bytes) aceitas na verificação dos scripts
+func ParseScriptPublicKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	return parsePublicKeys("script", encoded)
+}
+
+// parsePublicKeys decodifica chaves públicas Ed25519 em base64; kind só
+// identifica as chaves nas mensagens de erro
+func parsePublicKeys(kind string, encoded []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(encoded))
+	for i, value := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s public key %d encoding: %w", kind, i, err)
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid %s public key %d: expected %d bytes, got %d", kind, i, ed25519.PublicKeySize, len(raw))
+		}
+		keys = append(keys, ed25519.PublicKey(raw))
+	}
+	return keys, nil
+}
+
+// SetScriptKeys troca as chaves aceitas na verificação dos scripts, sem
+// reiniciar o executor. Uma lista inválida mantém as chaves anteriores;
+// uma lista vazia desativa o comando script.
+func (e *Executor) SetScriptKeys(encoded []string) error {
+	keys, err := ParseScriptPublicKeys(encoded)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.scriptKeys = keys
+	e.mutex.Unlock()
+
+	e.logger.WithField("keys", len(keys)).Info("Chaves de assinatura de scripts atualizadas")
+	return nil
+}
+
+// scriptsEnabled indica se há chaves para verificar scripts
+func (e *Executor) scriptsEnabled() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return len(e.scriptKeys) > 0
+}
+
+// verifyScript confere a assinatura com cada chave configurada
+func (e *Executor) verifyScript(commandID, expiresAt, interpreter, script string, signature []byte) bool {
+	e.mutex.RLock()
+	keys := e.scriptKeys
+	e.mutex.RUnlock()
+
+	payload := ScriptSigningPayload(commandID, expiresAt, interpreter, script)
+	for _, key := range keys {
+		if ed25519.Verify(key, payload, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimScript confere a validade assinada e registra o command_id do
+// script. Recusa o que já expirou, o que vale por mais que
+// scriptMaxValidity e o ID já aceito; cada ID fica registrado até a própria
+// validade, quando a expiração passa a recusar o replay.
+func (e *Executor) claimScript(commandID, expiresAt string, now time.Time) *comms.CodedError {
+	expires, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return comms.NewCodedError(comms.ErrCodeScriptExpired, "invalid expires_at "+strconv.Quote(expiresAt))
+	}
+	if !now.Before(expires) {
+		return comms.NewCodedError(comms.ErrCodeScriptExpired, "expired at "+expiresAt)
+	}
+	if expires.Sub(now) > scriptMaxValidity {
+		return comms.NewCodedError(comms.ErrCodeScriptExpired, fmt.Sprintf("expires_at more than %s ahead", scriptMaxValidity))
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for id, until := range e.scriptIDs {
+		if !now.Before(until) {
+			delete(e.scriptIDs, id)
+		}
+	}
+	if _, ok := e.scriptIDs[commandID]; ok {
+		return comms.NewCodedError(comms.ErrCodeScriptReplayed, commandID)
+	}
+	if e.scriptIDs == nil {
+		e.scriptIDs = make(map[string]time.Time)
+	}
+	e.scriptIDs[commandID] = expires
+	return nil
+}
+
+// executeScript executa um script assinado. Options: "script" (corpo),
+// "interpreter" (/bin/sh ou /bin/zsh, padrão /bin/sh), "expires_at" (RFC
+// 3339, no máximo scriptMaxValidity à frente) e "signature" (base64 da
+// assinatura Ed25519 de ScriptSigningPayload). Cada command_id é aceito uma
+// vez só. command.Command é só um
+// rótulo para logs e métricas. O corpo vai para um arquivo temporário 0700,
+// removido ao fim, e roda com o mesmo ambiente restrito, timeout e limite de
+// saída dos comandos shell.
+func (e *Executor) executeScript(ctx context.Context, command *comms.Command, startTime time.Time) (*comms.CommandResult, error) {
+	script, _ := command.Options["script"].(string)
+	if script == "" {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeScriptRequired), "", "", startTime)
+	}
+	sum := sha256.Sum256([]byte(script))
+	digest := hex.EncodeToString(sum[:])
+
+	interpreter, _ := command.Options["interpreter"].(string)
+	if interpreter == "" {
+		interpreter = defaultScriptInterpreter
+	}
+	if !containsString(scriptInterpreters, interpreter) {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeInterpreterNotAllowed, interpreter), interpreter, digest, startTime)
+	}
+
+	if len(command.Args) > 0 {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeTooManyArguments, "script", 0, len(command.Args)), interpreter, digest, startTime)
+	}
+
+	if !e.scriptsEnabled() {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeScriptsDisabled), interpreter, digest, startTime)
+	}
+
+	encoded, _ := command.Options["signature"].(string)
+	if encoded == "" {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeScriptUnsigned), interpreter, digest, startTime)
+	}
+	expiresAt, _ := command.Options["expires_at"].(string)
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !e.verifyScript(command.ID, expiresAt, interpreter, script, signature) {
+		return e.rejectScript(command, comms.NewCodedError(comms.ErrCodeInvalidSignature), interpreter, digest, startTime)
+	}
+	// Só depois da assinatura: um comando forjado não ocupa o ID
+	if err := e.claimScript(command.ID, expiresAt, time.Now()); err != nil {
+		return e.rejectScript(command, err, interpreter, digest, startTime)
+	}
+
+	path, err := writeScriptFile(script)
+	if err != nil {
+		return e.createErrorResult(command, comms.StatusError, err, -1, startTime), err
+	}
+	defer os.Remove(path)
+
+	timeout := e.GetTimeout()
+	if command.Timeout > 0 {
+		timeout = time.Duration(command.Timeout) * time.Second
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	e.logger.WithFields(map[string]interface{}{
+		"command_id":  command.ID,
+		"interpreter": interpreter,
+		"sha256":      digest,
+		"timeout":     timeout.String(),
+	}).Info("Executando script assinado")
+
+	cmd := exec.CommandContext(execCtx, interpreter, path)
+	cmd.WaitDelay = shellWaitDelay
+	cmd.Env = append([]string(nil), defaultShellEnv...)
+
+	return e.runProcess(ctx, execCtx, command, cmd, e.config.MaxOutputSize, "", startTime)
+}
+
+// writeScriptFile grava o corpo em um arquivo temporário com permissão 0700
+func writeScriptFile(script string) (string, error) {
+	file, err := os.CreateTemp("", "agent-script-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("failed to create script file: %w", err)
+	}
+	path := file.Name()
+
+	if err := file.Chmod(0700); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to set script file permissions: %w", err)
+	}
+	if _, err := file.WriteString(script); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write script file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write script file: %w", err)
+	}
+	return path, nil
+}
+
+// rejectScript recusa o script, conta em RejectedCommands e o reporta como
+// evento de segurança
+func (e *Executor) rejectScript(command *comms.Command, err *comms.CodedError, interpreter, digest string, startTime time.Time) (*comms.CommandResult, error) {
+	fields := map[string]interface{}{
+		"command_id": command.ID,
+		"error_code": string(err.Code),
+		"error":      err.Error(),
+	}
+	if interpreter != "" {
+		fields["interpreter"] = interpreter
+	}
+	if digest != "" {
+		fields["sha256"] = digest
+	}
+
+	e.updateMetrics(func(m *ExecutionMetrics) { m.RejectedCommands++ })
+	e.logger.WithFields(fields).Warning("Script rejeitado")
+	if e.config.OnSecurityEvent != nil {
+		e.config.OnSecurityEvent("script_rejected", "Script execution rejected", fields)
+	}
+	return e.createErrorResult(command, comms.StatusRejected, err, -1, startTime), err
+}
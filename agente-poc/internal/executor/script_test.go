@@ -0,0 +1,322 @@
+package executor
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// signingKey é um par Ed25519 de teste
+type signingKey struct {
+	public  string
+	private ed25519.PrivateKey
+}
+
+func newSigningKey(t *testing.T) signingKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingKey{public: base64.StdEncoding.EncodeToString(public), private: private}
+}
+
+// testExpiresAt é a validade dos scripts de teste, dentro de scriptMaxValidity
+var testExpiresAt = time.Now().Add(30 * time.Minute).UTC().Format(time.RFC3339)
+
+// sign assina o script do comando id como o backend faz
+func (k signingKey) sign(id, interpreter, script string) string {
+	return k.signUntil(id, testExpiresAt, interpreter, script)
+}
+
+// signUntil assina o script com outra validade
+func (k signingKey) signUntil(id, expiresAt, interpreter, script string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, ScriptSigningPayload(id, expiresAt, interpreter, script)))
+}
+
+// securityEvents registra os eventos de segurança do executor
+type securityEvents struct {
+	mu     sync.Mutex
+	events []map[string]interface{}
+}
+
+func (s *securityEvents) record(eventType, message string, fields map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := map[string]interface{}{"type": eventType}
+	for key, value := range fields {
+		copied[key] = value
+	}
+	s.events = append(s.events, copied)
+}
+
+func (s *securityEvents) list() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.events...)
+}
+
+func newScriptTestExecutor(t *testing.T, keys ...signingKey) (*Executor, *securityEvents) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("scripts run under /bin/sh")
+	}
+	recorded := &securityEvents{}
+	e := newTestExecutor(t, func(c *Config) {
+		for _, key := range keys {
+			c.ScriptPublicKeys = append(c.ScriptPublicKeys, key.public)
+		}
+		c.OnSecurityEvent = recorded.record
+	})
+	return e, recorded
+}
+
+func scriptCommand(id, script, interpreter, signature string) *comms.Command {
+	options := map[string]interface{}{"script": script, "expires_at": testExpiresAt}
+	if interpreter != "" {
+		options["interpreter"] = interpreter
+	}
+	if signature != "" {
+		options["signature"] = signature
+	}
+	return &comms.Command{ID: id, Type: "script", Command: "remediation", Options: options}
+}
+
+// withExpiry troca options.expires_at (vazio para o tempo zero) e, com uma
+// chave, assina de novo com a validade nova
+func withExpiry(command *comms.Command, expires time.Time, key ...signingKey) *comms.Command {
+	expiresAt := ""
+	if !expires.IsZero() {
+		expiresAt = expires.UTC().Format(time.RFC3339)
+	}
+	command.Options["expires_at"] = expiresAt
+	for _, k := range key {
+		script, _ := command.Options["script"].(string)
+		command.Options["signature"] = k.signUntil(command.ID, expiresAt, defaultScriptInterpreter, script)
+	}
+	return command
+}
+
+func TestScriptValidSignature(t *testing.T) {
+	key := newSigningKey(t)
+	e, recorded := newScriptTestExecutor(t, key)
+
+	// O script imprime o próprio caminho, para conferir que o arquivo some
+	script := "echo \"running $0\"\necho done\n"
+	result, err := e.Execute(context.Background(), scriptCommand("cmd-script", script, "", key.sign("cmd-script", "/bin/sh", script)))
+	if err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+	lines := strings.Split(strings.TrimSpace(result.Output), "\n")
+	if len(lines) != 2 || lines[1] != "done" || !strings.HasPrefix(lines[0], "running ") {
+		t.Fatalf("output = %q", result.Output)
+	}
+	if _, err := os.Stat(strings.TrimPrefix(lines[0], "running ")); !os.IsNotExist(err) {
+		t.Fatalf("script file left behind: %v", err)
+	}
+	if events := recorded.list(); len(events) != 0 {
+		t.Fatalf("security events for a valid script: %v", events)
+	}
+	if metrics := e.GetMetrics(); metrics.RejectedCommands != 0 || metrics.SuccessfulRuns != 1 {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+}
+
+func TestScriptRejected(t *testing.T) {
+	trusted := newSigningKey(t)
+	untrusted := newSigningKey(t)
+	e, recorded := newScriptTestExecutor(t, trusted)
+	const script = "echo ok\n"
+
+	tests := []struct {
+		name     string
+		command  *comms.Command
+		wantCode comms.ErrorCode
+	}{
+		{name: "tampered body", command: scriptCommand("cmd-script", script+"rm -rf /tmp/x\n", "", trusted.sign("cmd-script", "/bin/sh", script)), wantCode: comms.ErrCodeInvalidSignature},
+		{name: "key not in the trust set", command: scriptCommand("cmd-script", script, "", untrusted.sign("cmd-script", "/bin/sh", script)), wantCode: comms.ErrCodeInvalidSignature},
+		// A assinatura cobre o interpretador
+		{name: "interpreter swapped", command: scriptCommand("cmd-script", script, "/bin/zsh", trusted.sign("cmd-script", "/bin/sh", script)), wantCode: comms.ErrCodeInvalidSignature},
+		// A assinatura cobre o command_id e a validade
+		{name: "command ID swapped", command: scriptCommand("cmd-script", script, "", trusted.sign("cmd-other", "/bin/sh", script)), wantCode: comms.ErrCodeInvalidSignature},
+		{name: "expiry extended", command: withExpiry(scriptCommand("cmd-script", script, "", trusted.sign("cmd-script", "/bin/sh", script)), time.Now().Add(50*time.Minute)), wantCode: comms.ErrCodeInvalidSignature},
+		{name: "expired", command: withExpiry(scriptCommand("cmd-script", script, "", ""), time.Now().Add(-time.Minute), trusted), wantCode: comms.ErrCodeScriptExpired},
+		{name: "expiry too far ahead", command: withExpiry(scriptCommand("cmd-script", script, "", ""), time.Now().Add(2*time.Hour), trusted), wantCode: comms.ErrCodeScriptExpired},
+		{name: "no expiry", command: withExpiry(scriptCommand("cmd-script", script, "", ""), time.Time{}, trusted), wantCode: comms.ErrCodeScriptExpired},
+		{name: "signature not base64", command: scriptCommand("cmd-script", script, "", "not base64!"), wantCode: comms.ErrCodeInvalidSignature},
+		{name: "unsigned", command: scriptCommand("cmd-script", script, "", ""), wantCode: comms.ErrCodeScriptUnsigned},
+		{name: "interpreter not allowed", command: scriptCommand("cmd-script", script, "/bin/bash", trusted.sign("cmd-script", "/bin/bash", script)), wantCode: comms.ErrCodeInterpreterNotAllowed},
+		{name: "empty script", command: scriptCommand("cmd-script", "", "", trusted.sign("cmd-script", "/bin/sh", "")), wantCode: comms.ErrCodeScriptRequired},
+		{
+			name:     "arguments",
+			command:  &comms.Command{ID: "cmd-script", Type: "script", Args: []string{"-x"}, Options: map[string]interface{}{"script": script, "expires_at": testExpiresAt, "signature": trusted.sign("cmd-script", "/bin/sh", script)}},
+			wantCode: comms.ErrCodeTooManyArguments,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := e.Execute(context.Background(), tt.command)
+			var coded *comms.CodedError
+			if !errors.As(err, &coded) || coded.Code != tt.wantCode {
+				t.Fatalf("error = %v, want %s", err, tt.wantCode)
+			}
+			if result.Status != comms.StatusRejected || result.Output != "" {
+				t.Fatalf("result = %+v", result)
+			}
+			if got := e.GetMetrics().RejectedCommands; got != int64(i+1) {
+				t.Fatalf("RejectedCommands = %d, want %d", got, i+1)
+			}
+
+			// Cada recusa vira um evento de segurança com o hash do corpo
+			events := recorded.list()
+			if len(events) != i+1 {
+				t.Fatalf("%d security events, want %d", len(events), i+1)
+			}
+			event := events[i]
+			if event["type"] != "script_rejected" || event["error_code"] != string(tt.wantCode) || event["command_id"] != "cmd-script" {
+				t.Fatalf("event = %v", event)
+			}
+			if _, ok := event["sha256"]; ok == (tt.wantCode == comms.ErrCodeScriptRequired) {
+				t.Fatalf("event sha256 = %v", event["sha256"])
+			}
+		})
+	}
+}
+
+func TestScriptReplayRejected(t *testing.T) {
+	key := newSigningKey(t)
+	e, recorded := newScriptTestExecutor(t, key)
+	const script = "echo ok\n"
+
+	command := scriptCommand("cmd-once", script, "", key.sign("cmd-once", "/bin/sh", script))
+	if result, err := e.Execute(context.Background(), command); err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("first run: %+v, %v", result, err)
+	}
+
+	// O mesmo script capturado, reenviado com o mesmo ID, é recusado
+	result, err := e.Execute(context.Background(), scriptCommand("cmd-once", script, "", key.sign("cmd-once", "/bin/sh", script)))
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeScriptReplayed || result.Status != comms.StatusRejected {
+		t.Fatalf("replay: %+v, %v", result, err)
+	}
+	if events := recorded.list(); len(events) != 1 || events[0]["error_code"] != string(comms.ErrCodeScriptReplayed) {
+		t.Fatalf("security events = %v", events)
+	}
+
+	// Uma assinatura inválida não ocupa o ID
+	forged := scriptCommand("cmd-forged", script, "", key.sign("cmd-other", "/bin/sh", script))
+	if _, err := e.Execute(context.Background(), forged); !errors.As(err, &coded) || coded.Code != comms.ErrCodeInvalidSignature {
+		t.Fatalf("forged: %v", err)
+	}
+	if result, err := e.Execute(context.Background(), scriptCommand("cmd-forged", script, "", key.sign("cmd-forged", "/bin/sh", script))); err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("signed run after a forged one: %+v, %v", result, err)
+	}
+}
+
+func TestClaimScriptForgetsExpiredIDs(t *testing.T) {
+	e := newTestExecutor(t, nil)
+	now := time.Now()
+	expiresAt := now.Add(time.Minute).UTC().Format(time.RFC3339)
+
+	if err := e.claimScript("cmd-1", expiresAt, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.claimScript("cmd-1", expiresAt, now.Add(30*time.Second)); err == nil || err.Code != comms.ErrCodeScriptReplayed {
+		t.Fatalf("replay inside the validity: %v", err)
+	}
+	// Depois da validade, a expiração recusa e o ID sai do registro no
+	// próximo script aceito
+	later := now.Add(2 * time.Minute)
+	if err := e.claimScript("cmd-1", expiresAt, later); err == nil || err.Code != comms.ErrCodeScriptExpired {
+		t.Fatalf("replay after the validity: %v", err)
+	}
+	if err := e.claimScript("cmd-2", later.Add(time.Minute).UTC().Format(time.RFC3339), later); err != nil {
+		t.Fatal(err)
+	}
+	if _, kept := e.scriptIDs["cmd-1"]; kept || len(e.scriptIDs) != 1 {
+		t.Fatalf("registered IDs = %v", e.scriptIDs)
+	}
+}
+
+func TestScriptKeysReload(t *testing.T) {
+	first := newSigningKey(t)
+	second := newSigningKey(t)
+	const script = "echo ok\n"
+
+	// Sem chaves, scripts ficam desativados
+	e, _ := newScriptTestExecutor(t)
+	_, err := e.Execute(context.Background(), scriptCommand("cmd-script", script, "", first.sign("cmd-script", "/bin/sh", script)))
+	var coded *comms.CodedError
+	if !errors.As(err, &coded) || coded.Code != comms.ErrCodeScriptsDisabled {
+		t.Fatalf("without keys: %v", err)
+	}
+
+	if err := e.SetScriptKeys([]string{first.public}); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := e.Execute(context.Background(), scriptCommand("cmd-script-1", script, "", first.sign("cmd-script-1", "/bin/sh", script))); err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("after adding the key: %+v, %v", result, err)
+	}
+
+	// Uma lista inválida mantém as chaves anteriores
+	if err := e.SetScriptKeys([]string{second.public, "AAAA"}); err == nil {
+		t.Fatal("invalid key accepted")
+	}
+	if result, _ := e.Execute(context.Background(), scriptCommand("cmd-script-2", script, "", first.sign("cmd-script-2", "/bin/sh", script))); result.Status != comms.StatusSuccess {
+		t.Fatalf("after an invalid reload: %+v", result)
+	}
+
+	// Rotação: a chave antiga deixa de valer
+	if err := e.SetScriptKeys([]string{second.public}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Execute(context.Background(), scriptCommand("cmd-script", script, "", first.sign("cmd-script", "/bin/sh", script))); !errors.As(err, &coded) || coded.Code != comms.ErrCodeInvalidSignature {
+		t.Fatalf("rotated-out key: %v", err)
+	}
+	if result, err := e.Execute(context.Background(), scriptCommand("cmd-script-3", script, "", second.sign("cmd-script-3", "/bin/sh", script))); err != nil || result.Status != comms.StatusSuccess {
+		t.Fatalf("rotated-in key: %+v, %v", result, err)
+	}
+}
+
+func TestWriteScriptFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions")
+	}
+	path, err := writeScriptFile("echo ok\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if info.Mode().Perm() != 0o700 || string(data) != "echo ok\n" {
+		t.Fatalf("script file mode %s, content %q", info.Mode().Perm(), data)
+	}
+}
+
+func TestParseScriptPublicKeys(t *testing.T) {
+	key := newSigningKey(t)
+	if keys, err := ParseScriptPublicKeys([]string{" " + key.public + "\n"}); err != nil || len(keys) != 1 {
+		t.Fatalf("valid key: %v, %v", keys, err)
+	}
+	for _, encoded := range []string{"not base64!", base64.StdEncoding.EncodeToString(make([]byte, 31))} {
+		if _, err := ParseScriptPublicKeys([]string{encoded}); err == nil {
+			t.Errorf("ParseScriptPublicKeys(%q) accepted", encoded)
+		}
+	}
+}
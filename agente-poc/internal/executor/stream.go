@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// streamInterval é o intervalo entre frames command_progress de um comando
+const streamInterval = time.Second
+
+// streamChunkSize antecipa o frame quando há muita saída nova acumulada
+const streamChunkSize = 32 * 1024
+
+// streamRequested indica se o comando pediu a saída incremental
+// (options.stream = true)
+func streamRequested(command *comms.Command) bool {
+	stream, _ := command.Options["stream"].(bool)
+	return stream
+}
+
+// outputStream envolve o outputBuffer e envia, a cada streamInterval ou
+// streamChunkSize, o trecho retido desde o frame anterior. Só o que cabe no
+// buffer é enviado, então os frames somados respeitam o limite de saída.
+type outputStream struct {
+	mu     sync.Mutex
+	buffer *outputBuffer
+	sent   int // bytes de buffer.data já enviados
+
+	// flushMu mantém os frames em ordem entre o ticker e Write
+	flushMu sync.Mutex
+	emit    func(chunk string, offset int)
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newOutputStream cria o stream e inicia o envio periódico; Close encerra
+func newOutputStream(buffer *outputBuffer, emit func(chunk string, offset int)) *outputStream {
+	s := &outputStream{
+		buffer:  buffer,
+		emit:    emit,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write retém a saída no buffer e antecipa o frame se o trecho pendente já
+// passou de streamChunkSize
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	n, err := s.buffer.Write(p)
+	ready := len(s.buffer.data)-s.sent >= streamChunkSize
+	s.mu.Unlock()
+
+	if ready {
+		s.flush()
+	}
+	return n, err
+}
+
+// run envia os frames periódicos até Close
+func (s *outputStream) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush envia o trecho pendente, sem cortar um caractere UTF-8 ao meio (o
+// resto vai no próximo frame)
+func (s *outputStream) flush() {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	pending := trimPartialRune(s.buffer.data[s.sent:])
+	offset := s.sent
+	s.sent += len(pending)
+	s.mu.Unlock()
+
+	if len(pending) > 0 {
+		s.emit(string(pending), offset)
+	}
+}
+
+// Close para o envio periódico e envia o que restou, antes do resultado final
+func (s *outputStream) Close() {
+	close(s.done)
+	<-s.stopped
+	s.flush()
+}
+
+// progressEmitter monta os frames command_progress do comando para
+// Config.OnProgress
+func (e *Executor) progressEmitter(command *comms.Command, startTime time.Time) func(chunk string, offset int) {
+	return func(chunk string, offset int) {
+		e.config.OnProgress(&comms.CommandResult{
+			ID:            command.ID,
+			CommandID:     command.ID,
+			Status:        comms.StatusRunning,
+			Output:        chunk,
+			Offset:        offset,
+			ExecutionTime: time.Since(startTime).Milliseconds(),
+			Timestamp:     time.Now(),
+		})
+	}
+}
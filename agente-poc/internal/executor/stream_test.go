@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"agente-poc/internal/comms"
+)
+
+// progressRecorder guarda os frames command_progress do executor
+type progressRecorder struct {
+	mu     sync.Mutex
+	frames []comms.CommandResult
+}
+
+func (r *progressRecorder) record(progress *comms.CommandResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, *progress)
+}
+
+func (r *progressRecorder) list() []comms.CommandResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]comms.CommandResult(nil), r.frames...)
+}
+
+// fakeCommand grava um executável no PATH do teste. O comando roda com o
+// ambiente padrão do executor, então o script só usa /bin e /usr/bin.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// newStreamTestExecutor cria um executor que conhece o comando fake e
+// registra os frames de progresso
+func newStreamTestExecutor(t *testing.T, spec CommandSpec) (*Executor, *progressRecorder) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	recorder := &progressRecorder{}
+	e := newTestExecutor(t, func(c *Config) {
+		c.CustomWhitelist = map[string]CommandSpec{spec.Name: spec}
+		c.OnProgress = recorder.record
+	})
+	return e, recorder
+}
+
+// joinFrames confere que os frames são contíguos e devolve a saída somada
+func joinFrames(t *testing.T, frames []comms.CommandResult, commandID string) string {
+	t.Helper()
+	var output strings.Builder
+	for i, frame := range frames {
+		if frame.Status != comms.StatusRunning || frame.CommandID != commandID || frame.Output == "" {
+			t.Fatalf("frame %d = %+v", i, frame)
+		}
+		if frame.Offset != output.Len() {
+			t.Fatalf("frame %d at offset %d, want %d", i, frame.Offset, output.Len())
+		}
+		output.WriteString(frame.Output)
+	}
+	return output.String()
+}
+
+func TestStreamedCommandSendsProgress(t *testing.T) {
+	// Uma linha a cada 1,5s: os frames de 1s pegam cada linha separada
+	fakeCommand(t, "slow-report", "echo one\nsleep 1.5\necho two\nsleep 1.5\necho three\n")
+	e, recorder := newStreamTestExecutor(t, CommandSpec{Name: "slow-report", TimeoutSeconds: 10})
+
+	command := &comms.Command{ID: "cmd-stream", Type: "shell", Command: "slow-report", Options: map[string]interface{}{"stream": true}}
+	result, err := e.Execute(context.Background(), command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != comms.StatusSuccess || result.Output != "one\ntwo\nthree\n" || result.Offset != 0 {
+		t.Fatalf("final result = %+v", result)
+	}
+
+	// Execute só volta depois do último frame: todos vieram antes do resultado
+	frames := recorder.list()
+	if len(frames) < 2 {
+		t.Fatalf("%d progress frames, want at least 2", len(frames))
+	}
+	if frames[0].Output != "one\n" || frames[1].Output != "two\n" {
+		t.Fatalf("first frames = %q, %q", frames[0].Output, frames[1].Output)
+	}
+	if joined := joinFrames(t, frames, "cmd-stream"); joined != result.Output {
+		t.Fatalf("frames add up to %q, want %q", joined, result.Output)
+	}
+	for i := 1; i < len(frames); i++ {
+		if frames[i].ExecutionTime < frames[i-1].ExecutionTime {
+			t.Fatalf("frames out of order: %+v", frames)
+		}
+	}
+}
+
+func TestStreamedOutputRespectsLimit(t *testing.T) {
+	// 100 KB de uma vez: os frames saem pelo tamanho, sem esperar o ticker,
+	// e param no limite de saída do spec
+	fakeCommand(t, "big-report", "i=0\nwhile [ $i -lt 1000 ]; do\n  echo 'ação 0123456789012345678901234567890123456789012345678901234567890123456789012345678901234567'\n  i=$((i+1))\ndone\n")
+	const limit = 50_001
+	e, recorder := newStreamTestExecutor(t, CommandSpec{
+		Name:           "big-report",
+		TimeoutSeconds: 10,
+		ResourceLimits: ResourceLimits{MaxOutputBytes: limit},
+	})
+
+	result, err := e.Execute(context.Background(), &comms.Command{ID: "cmd-big", Type: "shell", Command: "big-report", Options: map[string]interface{}{"stream": true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OutputTruncated || result.OriginalOutputBytes <= limit {
+		t.Fatalf("final result truncated = %v, original %d bytes", result.OutputTruncated, result.OriginalOutputBytes)
+	}
+
+	frames := recorder.list()
+	if len(frames) < 2 {
+		t.Fatalf("%d progress frames, want at least 2", len(frames))
+	}
+	joined := joinFrames(t, frames, "cmd-big")
+	if len(joined) > limit || len(joined) < limit-4 {
+		t.Fatalf("frames add up to %d bytes, limit %d", len(joined), limit)
+	}
+	if !strings.HasPrefix(result.Output, joined) {
+		t.Fatal("streamed output differs from the final output")
+	}
+	// Nenhum frame corta o "ç" ou o "ã" ao meio
+	for i, frame := range frames {
+		if !utf8.ValidString(frame.Output) {
+			t.Fatalf("frame %d ends in a partial rune", i)
+		}
+	}
+
+	// Sem stream: nenhum frame e o mesmo resultado final
+	plain, err := e.Execute(context.Background(), &comms.Command{ID: "cmd-big", Type: "shell", Command: "big-report"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.list()) != len(frames) {
+		t.Fatal("command without options.stream sent progress")
+	}
+	plain.ExecutionTime, plain.Timestamp = result.ExecutionTime, result.Timestamp
+	if !reflect.DeepEqual(plain, result) {
+		t.Fatalf("plain result = %+v, streamed %+v", plain, result)
+	}
+}
+
+func TestStreamWithoutProgressCallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	fakeCommand(t, "quick-report", "echo done\n")
+	e := newTestExecutor(t, func(c *Config) {
+		c.CustomWhitelist = map[string]CommandSpec{"quick-report": {Name: "quick-report"}}
+	})
+
+	// Sem OnProgress o pedido de stream é ignorado
+	result, err := e.Execute(context.Background(), &comms.Command{ID: "cmd-quick", Type: "shell", Command: "quick-report", Options: map[string]interface{}{"stream": true}})
+	if err != nil || result.Output != "done\n" {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+}
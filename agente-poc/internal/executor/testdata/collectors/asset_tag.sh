@@ -0,0 +1,7 @@
+#!/bin/sh
+# Coletor de exemplo: patrimônio e centro de custo (primeiro argumento), com
+# o ambiente visto pelo script para conferir a restrição
+echo "asset_tag=PAT-00042"
+echo "cost_center=$1"
+echo "home=$HOME"
+echo "leaked=${AGENTE_TEST_SECRET:-}"
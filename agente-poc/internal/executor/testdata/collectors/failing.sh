@@ -0,0 +1,4 @@
+#!/bin/sh
+# Coletor que falha com a causa no stderr
+echo "asset database unreachable" >&2
+exit 3
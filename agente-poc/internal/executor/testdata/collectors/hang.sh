@@ -0,0 +1,6 @@
+#!/bin/sh
+# Coletor que nunca termina; o filho herda o stdout, como um script que
+# deixa processos para trás
+echo "starting"
+sleep 30
+echo "done"
@@ -0,0 +1,7 @@
+#!/bin/sh
+# Coletor com saída maior que o limite do teste
+i=0
+while [ $i -lt 200 ]; do
+	echo "line $i of a collector that prints far too much"
+	i=$((i + 1))
+done
@@ -0,0 +1,149 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// Etapas da validação de um comando shell fora das regras do CommandSpec
+// (ver whitelistChecks), na ordem em que Validate as aplica
+const (
+	StepWhitelist   = "whitelist"
+	StepSafety      = "safety"
+	StepSanitize    = "sanitize"
+	StepEnvironment = "environment"
+)
+
+// ValidationStep é o resultado de uma etapa da validação
+type ValidationStep struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ValidationReport descreve como um comando shell passou (ou não) pela
+// whitelist, pela verificação de segurança e pela sanitização. As etapas
+// param na primeira recusa, como na execução.
+type ValidationReport struct {
+	Command       string           `json:"command"`
+	Args          []string         `json:"args"`
+	SanitizedArgs []string         `json:"sanitized_args,omitempty"`
+	Sanitized     bool             `json:"sanitized"`
+	Spec          *CommandSpec     `json:"spec,omitempty"`
+	Steps         []ValidationStep `json:"steps"`
+	Allowed       bool             `json:"allowed"`
+	Reason        string           `json:"reason,omitempty"`
+	ErrorCode     comms.ErrorCode  `json:"error_code,omitempty"`
+
+	// Resolvidos só para comandos aceitos: diretório, ambiente e timeout
+	// efetivos; AuditEnv indica se options.cwd ou options.env foram usados
+	WorkingDir string        `json:"working_dir,omitempty"`
+	Env        []string      `json:"env,omitempty"`
+	AuditEnv   bool          `json:"audit_env,omitempty"`
+	Timeout    time.Duration `json:"timeout,omitempty"`
+}
+
+// FailedStep retorna a etapa que recusou o comando ("" se aceito)
+func (r *ValidationReport) FailedStep() string {
+	for _, step := range r.Steps {
+		if !step.Passed {
+			return step.Name
+		}
+	}
+	return ""
+}
+
+// Validate passa um comando shell por todas as verificações da execução,
+// sem executá-lo. O relatório sempre volta preenchido; o erro é a recusa,
+// com o mesmo código que Execute retornaria.
+func (e *Executor) Validate(command *comms.Command) (*ValidationReport, error) {
+	if command == nil {
+		return nil, fmt.Errorf("comando não pode ser nulo")
+	}
+
+	report := &ValidationReport{
+		Command: command.Command,
+		Args:    command.Args,
+	}
+	if command.Type != "shell" {
+		err := comms.NewCodedError(comms.ErrCodeUnsupportedCommandType, command.Type)
+		return report.reject("type", err), err
+	}
+
+	// Whitelist: comando conhecido e regras do spec sobre os argumentos
+	whitelist := e.GetWhitelist()
+	spec, exists := whitelist.GetCommandSpec(command.Command)
+	if !exists {
+		err := comms.NewCodedError(comms.ErrCodeCommandNotAllowed, command.Command)
+		return report.reject(StepWhitelist, err), err
+	}
+	report.Spec = &spec
+	report.pass(StepWhitelist, spec.Description)
+
+	for _, check := range whitelistChecks {
+		detail, err := check.run(whitelist, command.Command, spec, command.Args)
+		if err != nil {
+			return report.reject(check.name, err), err
+		}
+		report.pass(check.name, detail)
+	}
+
+	// Verificação adicional de segurança
+	if !IsCommandSafe(command.Command, command.Args) {
+		err := comms.NewCodedError(comms.ErrCodeUnsafeCommand)
+		return report.reject(StepSafety, err), err
+	}
+	report.pass(StepSafety, "")
+
+	// Sanitização não recusa: só registra se algum argumento mudou
+	report.SanitizedArgs = SanitizeArguments(command.Args)
+	report.Sanitized = !equalSlices(command.Args, report.SanitizedArgs)
+	if report.Sanitized {
+		report.pass(StepSanitize, "argumentos alterados: "+strings.Join(report.SanitizedArgs, " "))
+	} else {
+		report.pass(StepSanitize, "sem alterações")
+	}
+
+	// Diretório e variáveis pedidos em options, só quando o spec libera
+	dir, env, auditEnv, err := e.commandEnvironment(command, spec)
+	if err != nil {
+		return report.reject(StepEnvironment, err), err
+	}
+	report.WorkingDir, report.Env, report.AuditEnv = dir, env, auditEnv
+	if auditEnv {
+		report.pass(StepEnvironment, "options.cwd/options.env aplicados")
+	} else {
+		report.pass(StepEnvironment, "ambiente padrão")
+	}
+
+	report.Timeout = e.GetTimeout()
+	if spec.TimeoutSeconds > 0 {
+		report.Timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	if command.Timeout > 0 {
+		report.Timeout = time.Duration(command.Timeout) * time.Second
+	}
+
+	report.Allowed = true
+	return report, nil
+}
+
+// pass registra uma etapa aceita
+func (r *ValidationReport) pass(name, detail string) {
+	r.Steps = append(r.Steps, ValidationStep{Name: name, Passed: true, Detail: detail})
+}
+
+// reject registra a etapa que recusou o comando e o motivo
+func (r *ValidationReport) reject(name string, err error) *ValidationReport {
+	r.Steps = append(r.Steps, ValidationStep{Name: name, Detail: err.Error()})
+	r.Reason = err.Error()
+	var coded *comms.CodedError
+	if errors.As(err, &coded) {
+		r.ErrorCode = coded.Code
+	}
+	return r
+}
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// newValidateTestExecutor cria um executor com specs próprios, iguais em
+// todas as plataformas
+func newValidateTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+	return newTestExecutor(t, func(c *Config) {
+		c.CustomWhitelist = map[string]CommandSpec{
+			"echo":  {Name: "echo", Description: "Eco de teste", ForbiddenArgs: []string{"--secret"}, MaxArgs: 3, TimeoutSeconds: 5},
+			"uname": {Name: "uname", AllowedArgs: []string{"-a", "-r"}, MaxArgs: 1},
+			"date":  {Name: "date", ArgPatterns: map[string]string{"arg0": `^\+%[A-Za-z]$`}},
+			// Na whitelist não basta: a verificação de segurança ainda recusa
+			"rm": {Name: "rm"},
+		}
+	})
+}
+
+// stepNames lista as etapas do relatório, na ordem
+func stepNames(report *ValidationReport) []string {
+	names := make([]string, len(report.Steps))
+	for i, step := range report.Steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+func TestValidateAllowedCommand(t *testing.T) {
+	e := newValidateTestExecutor(t)
+
+	report, err := e.Validate(&comms.Command{ID: "cmd-1", Type: "shell", Command: "echo", Args: []string{"hello", "world"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Allowed || report.Reason != "" || report.ErrorCode != "" || report.FailedStep() != "" {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Spec == nil || report.Spec.Name != "echo" || report.Spec.Description != "Eco de teste" {
+		t.Fatalf("matched spec = %+v", report.Spec)
+	}
+	want := []string{StepWhitelist, "max_args", "forbidden_args", "allowed_args", "arg_patterns", StepSafety, StepSanitize, StepEnvironment}
+	if got := stepNames(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("steps = %v, want %v", got, want)
+	}
+	for _, step := range report.Steps {
+		if !step.Passed {
+			t.Fatalf("step %s failed: %+v", step.Name, step)
+		}
+	}
+	if report.Sanitized || !reflect.DeepEqual(report.SanitizedArgs, report.Args) {
+		t.Fatalf("sanitized = %v, args %v", report.Sanitized, report.SanitizedArgs)
+	}
+	if report.Steps[1].Detail != "2 de 3" {
+		t.Fatalf("max_args detail = %q", report.Steps[1].Detail)
+	}
+
+	// Timeout: o do spec, a menos que o comando peça outro
+	if report.Timeout != 5*time.Second {
+		t.Fatalf("timeout = %s, want the spec's 5s", report.Timeout)
+	}
+	report, err = e.Validate(&comms.Command{Type: "shell", Command: "echo", Timeout: 2})
+	if err != nil || report.Timeout != 2*time.Second {
+		t.Fatalf("command timeout = %s, %v", report.Timeout, err)
+	}
+	report, err = e.Validate(&comms.Command{Type: "shell", Command: "uname", Args: []string{"-r"}})
+	if err != nil || report.Timeout != e.GetTimeout() {
+		t.Fatalf("default timeout = %s, %v", report.Timeout, err)
+	}
+
+	report, err = e.Validate(&comms.Command{Type: "shell", Command: "date", Args: []string{"+%Y"}})
+	if err != nil || report.Steps[4].Detail != "1 argumento(s) conferido(s)" {
+		t.Fatalf("date +%%Y = %+v, %v", report, err)
+	}
+}
+
+func TestValidateSanitizedCommand(t *testing.T) {
+	e := newValidateTestExecutor(t)
+
+	// $ e \ passam pela verificação de segurança, mas a sanitização os tira;
+	// os espaços das pontas também saem
+	command := &comms.Command{ID: "cmd-2", Type: "shell", Command: "echo", Args: []string{"$HOME", ` C:\temp `, "plain"}}
+	report, err := e.Validate(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Allowed || !report.Sanitized {
+		t.Fatalf("report = %+v", report)
+	}
+	if want := []string{"HOME", "C:temp", "plain"}; !reflect.DeepEqual(report.SanitizedArgs, want) {
+		t.Fatalf("sanitized args = %q, want %q", report.SanitizedArgs, want)
+	}
+	// Os argumentos originais ficam no relatório e no comando
+	if want := []string{"$HOME", ` C:\temp `, "plain"}; !reflect.DeepEqual(report.Args, want) || !reflect.DeepEqual(command.Args, want) {
+		t.Fatalf("original args = %q", report.Args)
+	}
+	sanitize := report.Steps[len(report.Steps)-2]
+	if sanitize.Name != StepSanitize || !sanitize.Passed || !strings.Contains(sanitize.Detail, "HOME C:temp plain") {
+		t.Fatalf("sanitize step = %+v", sanitize)
+	}
+
+	// A execução usa os mesmos argumentos sanitizados
+	if runtime.GOOS == "windows" {
+		return
+	}
+	result, err := e.Execute(context.Background(), command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != comms.StatusSuccess || strings.TrimSpace(result.Output) != "HOME C:temp plain" {
+		t.Fatalf("echo ran with %q (%s)", result.Output, result.Status)
+	}
+}
+
+func TestValidateRejectedCommands(t *testing.T) {
+	e := newValidateTestExecutor(t)
+
+	tests := []struct {
+		name    string
+		command *comms.Command
+		step    string
+		code    comms.ErrorCode
+	}{
+		{name: "not in the whitelist", command: &comms.Command{Type: "shell", Command: "mkfs"}, step: StepWhitelist, code: comms.ErrCodeCommandNotAllowed},
+		{name: "too many arguments", command: &comms.Command{Type: "shell", Command: "echo", Args: []string{"a", "b", "c", "d"}}, step: "max_args", code: comms.ErrCodeTooManyArguments},
+		{name: "forbidden argument", command: &comms.Command{Type: "shell", Command: "echo", Args: []string{"--secret=1"}}, step: "forbidden_args", code: comms.ErrCodeForbiddenArgument},
+		{name: "argument not allowed", command: &comms.Command{Type: "shell", Command: "uname", Args: []string{"-s"}}, step: "allowed_args", code: comms.ErrCodeArgumentNotAllowed},
+		{name: "pattern mismatch", command: &comms.Command{Type: "shell", Command: "date", Args: []string{"-s", "2020-01-01"}}, step: "arg_patterns", code: comms.ErrCodeArgumentPatternMismatch},
+		{name: "command injection", command: &comms.Command{Type: "shell", Command: "echo", Args: []string{"a;reboot"}}, step: StepSafety, code: comms.ErrCodeUnsafeCommand},
+		{name: "dangerous path", command: &comms.Command{Type: "shell", Command: "echo", Args: []string{"/etc/shadow"}}, step: StepSafety, code: comms.ErrCodeUnsafeCommand},
+		{name: "dangerous command in the whitelist", command: &comms.Command{Type: "shell", Command: "rm"}, step: StepSafety, code: comms.ErrCodeUnsafeCommand},
+		{name: "working dir not allowed by the spec", command: &comms.Command{Type: "shell", Command: "echo", Options: map[string]interface{}{"cwd": t.TempDir()}}, step: StepEnvironment, code: comms.ErrCodeWorkingDirNotAllowed},
+		{name: "unsupported type", command: &comms.Command{Type: "script", Command: "echo"}, step: "type", code: comms.ErrCodeUnsupportedCommandType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := e.Validate(tt.command)
+			var coded *comms.CodedError
+			if !errors.As(err, &coded) || coded.Code != tt.code {
+				t.Fatalf("Validate() error = %v, want %s", err, tt.code)
+			}
+			if report == nil || report.Allowed || report.FailedStep() != tt.step || report.ErrorCode != tt.code || report.Reason != err.Error() {
+				t.Fatalf("report = %+v", report)
+			}
+			// As etapas param na recusa, que é a última
+			last := report.Steps[len(report.Steps)-1]
+			if last.Name != tt.step || last.Passed || last.Detail != err.Error() {
+				t.Fatalf("last step = %+v", last)
+			}
+			if report.WorkingDir != "" || report.Env != nil || report.Timeout != 0 {
+				t.Fatalf("rejected command resolved for execution: %+v", report)
+			}
+
+			// Execute recusa com o mesmo código, sem executar
+			if tt.command.Type != "shell" {
+				return
+			}
+			result, execErr := e.Execute(context.Background(), tt.command)
+			if execErr == nil || result.Status != comms.StatusRejected || result.ErrorCode != tt.code {
+				t.Fatalf("Execute() = %+v, %v", result, execErr)
+			}
+		})
+	}
+
+	if _, err := e.Validate(nil); err == nil {
+		t.Fatal("nil command accepted")
+	}
+}
@@ -0,0 +1,339 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// Modos de uma atualização da whitelist
+const (
+	// WhitelistMerge acrescenta ou substitui os specs enviados e remove os
+	// de Remove, mantendo os demais
+	WhitelistMerge = "merge"
+	// WhitelistReplace troca a whitelist inteira pelos specs enviados
+	WhitelistReplace = "replace"
+)
+
+// Recusas de ApplyWhitelistUpdate
+var (
+	ErrWhitelistUpdatesDisabled = errors.New("whitelist updates are disabled: no signing keys configured")
+	ErrWhitelistSignature       = errors.New("invalid whitelist update signature")
+	ErrWhitelistStaleVersion    = errors.New("whitelist update version is not newer than the active one")
+	ErrWhitelistExpired         = errors.New("whitelist update expiry not accepted")
+)
+
+// whitelistUpdateMaxValidity é o máximo entre o recebimento de uma
+// atualização e o expires_at assinado
+const whitelistUpdateMaxValidity = 24 * time.Hour
+
+// whitelistCommandName restringe os nomes de comando enviados pelo backend
+// a um executável sem caminho, para que a lista de comandos perigosos de
+// IsCommandSafe não seja contornada com /bin/rm
+var whitelistCommandName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// WhitelistUpdate é o conteúdo assinado de uma atualização da whitelist.
+// Version só cresce: atualizações com versão igual ou menor que a vigente
+// são recusadas, o que impede reenviar uma atualização antiga. ExpiresAt
+// cobre o caso em que a versão vigente se perdeu com o whitelist.json: uma
+// atualização antiga já terá expirado e não pode ser reenviada.
+type WhitelistUpdate struct {
+	Version   int64                  `json:"version"`
+	ExpiresAt time.Time              `json:"expires_at"`     // obrigatório em atualizações novas
+	Mode      string                 `json:"mode,omitempty"` // merge (padrão) ou replace
+	Commands  map[string]CommandSpec `json:"commands"`
+	Remove    []string               `json:"remove,omitempty"` // só em merge
+}
+
+// SignedWhitelistUpdate é a mensagem whitelist_update do backend: Payload é
+// o JSON de WhitelistUpdate, como texto, e Signature a assinatura Ed25519
+// (base64) exatamente desses bytes
+type SignedWhitelistUpdate struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// WhitelistStatus descreve a whitelist vigente para o heartbeat e o health
+type WhitelistStatus struct {
+	Version   int64     `json:"version"` // 0 = só a whitelist embutida
+	Commands  int       `json:"commands"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
+
+// whitelistState são as atualizações aplicadas desde o último replace, em
+// ordem; é o que vai para WhitelistStatePath. No reinício cada uma tem a
+// assinatura conferida de novo antes de ser reaplicada sobre a base.
+type whitelistState struct {
+	Updates   []SignedWhitelistUpdate `json:"updates"`
+	Version   int64                   `json:"version"`
+	UpdatedAt time.Time               `json:"updated_at"`
+}
+
+// ParseWhitelistPublicKeys decodifica as chaves públicas Ed25519 (base64
+// dos 32 bytes) aceitas nas atualizações da whitelist
+func ParseWhitelistPublicKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	return parsePublicKeys("whitelist", encoded)
+}
+
+// SetWhitelistKeys troca as chaves aceitas nas atualizações da whitelist,
+// sem mexer na whitelist vigente. Uma lista inválida mantém as chaves
+// anteriores; uma lista vazia passa a recusar as atualizações.
+func (e *Executor) SetWhitelistKeys(encoded []string) error {
+	keys, err := ParseWhitelistPublicKeys(encoded)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.whitelistKeys = keys
+	e.mutex.Unlock()
+
+	e.logger.WithField("keys", len(keys)).Info("Chaves de assinatura da whitelist atualizadas")
+	return nil
+}
+
+// WhitelistStatus retorna a versão e o tamanho da whitelist vigente
+func (e *Executor) WhitelistStatus() WhitelistStatus {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return WhitelistStatus{
+		Version:   e.whitelistState.Version,
+		Commands:  len(e.whitelist.Commands),
+		UpdatedAt: e.whitelistState.UpdatedAt,
+	}
+}
+
+// ApplyWhitelistUpdate confere a assinatura de uma atualização enviada pelo
+// backend e a aplica: a nova whitelist é persistida e só então publicada,
+// de uma vez. As verificações de IsCommandSafe continuam valendo e um
+// comando da lista de perigosos nunca entra na whitelist.
+func (e *Executor) ApplyWhitelistUpdate(signed SignedWhitelistUpdate) (WhitelistStatus, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	update, err := e.verifyWhitelistUpdate(signed)
+	if err != nil {
+		return WhitelistStatus{}, err
+	}
+	if err := update.checkExpiry(time.Now()); err != nil {
+		return WhitelistStatus{}, err
+	}
+	if update.Version <= e.whitelistState.Version {
+		return WhitelistStatus{}, fmt.Errorf("%w: got %d, active %d", ErrWhitelistStaleVersion, update.Version, e.whitelistState.Version)
+	}
+
+	state := whitelistState{Version: update.Version, UpdatedAt: time.Now()}
+	if update.Mode == WhitelistReplace {
+		state.Updates = []SignedWhitelistUpdate{signed}
+	} else {
+		state.Updates = append(append([]SignedWhitelistUpdate(nil), e.whitelistState.Updates...), signed)
+	}
+	next := applyWhitelistUpdate(e.whitelist, update)
+
+	if err := e.saveWhitelistState(state); err != nil {
+		return WhitelistStatus{}, err
+	}
+	e.whitelist = next
+	e.whitelistState = state
+
+	e.logger.WithFields(map[string]interface{}{
+		"version":  update.Version,
+		"mode":     update.Mode,
+		"commands": len(next.Commands),
+	}).Info("Whitelist atualizada pelo backend")
+
+	return WhitelistStatus{Version: state.Version, Commands: len(next.Commands), UpdatedAt: state.UpdatedAt}, nil
+}
+
+// verifyWhitelistUpdate confere a assinatura com as chaves configuradas e
+// decodifica e valida o conteúdo. Chamado com e.mutex travado.
+func (e *Executor) verifyWhitelistUpdate(signed SignedWhitelistUpdate) (*WhitelistUpdate, error) {
+	if len(e.whitelistKeys) == 0 {
+		return nil, ErrWhitelistUpdatesDisabled
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, ErrWhitelistSignature
+	}
+	verified := false
+	for _, key := range e.whitelistKeys {
+		if ed25519.Verify(key, []byte(signed.Payload), signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrWhitelistSignature
+	}
+
+	var update WhitelistUpdate
+	decoder := json.NewDecoder(bytes.NewReader([]byte(signed.Payload)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		return nil, fmt.Errorf("invalid whitelist update: %w", err)
+	}
+	if err := update.validate(); err != nil {
+		return nil, fmt.Errorf("invalid whitelist update: %w", err)
+	}
+	return &update, nil
+}
+
+// validate confere modo, versão e specs de uma atualização já assinada
+func (u *WhitelistUpdate) validate() error {
+	if u.Version <= 0 {
+		return fmt.Errorf("version must be positive")
+	}
+	switch u.Mode {
+	case "":
+		u.Mode = WhitelistMerge
+	case WhitelistMerge, WhitelistReplace:
+	default:
+		return fmt.Errorf("unknown mode %q", u.Mode)
+	}
+	if u.Mode == WhitelistReplace && len(u.Remove) > 0 {
+		return fmt.Errorf("remove is only accepted in merge mode")
+	}
+
+	for name, spec := range u.Commands {
+		if !whitelistCommandName.MatchString(name) {
+			return fmt.Errorf("invalid command name %q", name)
+		}
+		if spec.Name != "" && spec.Name != name {
+			return fmt.Errorf("command %s: name %q does not match", name, spec.Name)
+		}
+		if !IsCommandSafe(name, nil) {
+			return fmt.Errorf("command %s is blocked by the built-in safety checks", name)
+		}
+		for key, pattern := range spec.ArgPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("command %s: invalid pattern %s: %w", name, key, err)
+			}
+		}
+		if spec.MaxArgs < 0 || spec.TimeoutSeconds < 0 {
+			return fmt.Errorf("command %s: negative limits", name)
+		}
+	}
+	return nil
+}
+
+// checkExpiry recusa a atualização sem expires_at, expirada ou que vale por
+// mais que whitelistUpdateMaxValidity. Só se aplica às atualizações novas:
+// as restauradas no reinício foram aceitas quando chegaram.
+func (u *WhitelistUpdate) checkExpiry(now time.Time) error {
+	switch {
+	case u.ExpiresAt.IsZero():
+		return fmt.Errorf("%w: expires_at is required", ErrWhitelistExpired)
+	case !now.Before(u.ExpiresAt):
+		return fmt.Errorf("%w: expired at %s", ErrWhitelistExpired, u.ExpiresAt.Format(time.RFC3339))
+	case u.ExpiresAt.Sub(now) > whitelistUpdateMaxValidity:
+		return fmt.Errorf("%w: expires_at more than %s ahead", ErrWhitelistExpired, whitelistUpdateMaxValidity)
+	}
+	return nil
+}
+
+// applyWhitelistUpdate monta a nova whitelist a partir de current, sem
+// alterá-la. Specs restritos a outras plataformas são ignorados, para que
+// uma mesma atualização sirva à frota inteira.
+func applyWhitelistUpdate(current *CommandWhitelist, update *WhitelistUpdate) *CommandWhitelist {
+	next := &CommandWhitelist{Commands: make(map[string]CommandSpec)}
+	if update.Mode != WhitelistReplace {
+		for name, spec := range current.Commands {
+			next.Commands[name] = spec
+		}
+		for _, name := range update.Remove {
+			delete(next.Commands, name)
+		}
+	}
+
+	for name, spec := range update.Commands {
+		if len(spec.Platform) > 0 && !containsString(spec.Platform, runtime.GOOS) {
+			continue
+		}
+		spec.Name = name
+		next.Commands[name] = spec
+	}
+	return next
+}
+
+// restoreWhitelist reaplica, sobre a whitelist base, as atualizações
+// persistidas antes do reinício. Qualquer atualização que não confira mais
+// (arquivo alterado ou chaves trocadas) descarta todas: o executor volta à
+// whitelist base em vez de ficar com parte delas.
+func (e *Executor) restoreWhitelist() {
+	path := e.config.WhitelistStatePath
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			e.logger.WithField("error", err.Error()).Warning("Não foi possível ler a whitelist persistida")
+		}
+		return
+	}
+
+	var state whitelistState
+	if err := json.Unmarshal(data, &state); err != nil {
+		e.logger.WithField("error", err.Error()).Warning("Whitelist persistida inválida, usando a embutida")
+		return
+	}
+
+	whitelist := e.baseWhitelist
+	var version int64
+	for i, signed := range state.Updates {
+		update, err := e.verifyWhitelistUpdate(signed)
+		if err == nil && update.Version <= version {
+			err = ErrWhitelistStaleVersion
+		}
+		if err != nil {
+			e.logger.WithFields(map[string]interface{}{
+				"update": i,
+				"error":  err.Error(),
+			}).Warning("Whitelist persistida não confere, usando a embutida")
+			return
+		}
+		whitelist = applyWhitelistUpdate(whitelist, update)
+		version = update.Version
+	}
+
+	state.Version = version
+	e.whitelist = whitelist
+	e.whitelistState = state
+	if version > 0 {
+		e.logger.WithFields(map[string]interface{}{
+			"version":  version,
+			"commands": len(whitelist.Commands),
+		}).Info("Whitelist restaurada")
+	}
+}
+
+// saveWhitelistState grava as atualizações aplicadas; sem
+// WhitelistStatePath não grava nada
+func (e *Executor) saveWhitelistState(state whitelistState) error {
+	path := e.config.WhitelistStatePath
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create whitelist directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal whitelist: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write whitelist: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
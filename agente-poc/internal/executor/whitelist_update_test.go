@@ -0,0 +1,310 @@
+package executor
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"agente-poc/internal/comms"
+)
+
+// signUpdate serializa e assina a atualização como o backend faz; sem
+// ExpiresAt, ela vale por uma hora
+func (k signingKey) signUpdate(t *testing.T, update WhitelistUpdate) SignedWhitelistUpdate {
+	t.Helper()
+	if update.ExpiresAt.IsZero() {
+		update.ExpiresAt = time.Now().Add(time.Hour)
+	}
+	payload, err := json.Marshal(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k.signPayload(payload)
+}
+
+// signPayload assina o payload já serializado
+func (k signingKey) signPayload(payload []byte) SignedWhitelistUpdate {
+	return SignedWhitelistUpdate{
+		Payload:   string(payload),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, payload)),
+	}
+}
+
+// newWhitelistTestExecutor cria um executor que aceita as chaves
+// informadas e persiste a whitelist em statePath
+func newWhitelistTestExecutor(t *testing.T, statePath string, keys ...signingKey) *Executor {
+	t.Helper()
+	return newTestExecutor(t, func(c *Config) {
+		for _, key := range keys {
+			c.WhitelistPublicKeys = append(c.WhitelistPublicKeys, key.public)
+		}
+		c.WhitelistStatePath = statePath
+	})
+}
+
+// allowed indica se a whitelist vigente aceita o comando sem argumentos
+func allowed(e *Executor, command string) bool {
+	_, err := e.Validate(&comms.Command{Type: "shell", Command: command})
+	return err == nil
+}
+
+func TestApplyWhitelistUpdate(t *testing.T) {
+	key := newSigningKey(t)
+	e := newWhitelistTestExecutor(t, filepath.Join(t.TempDir(), "whitelist.json"), key)
+	base := e.WhitelistStatus()
+	if base.Version != 0 || base.Commands == 0 || allowed(e, "lsblk") {
+		t.Fatalf("base whitelist = %+v", base)
+	}
+
+	// merge acrescenta sem tirar os embutidos; specs de outra plataforma ficam de fora
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "darwin"
+	}
+	status, err := e.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{
+		Version: 1,
+		Commands: map[string]CommandSpec{
+			"lsblk":       {AllowedArgs: []string{"-J"}, MaxArgs: 1, TimeoutSeconds: 10},
+			"hostnamectl": {Platform: []string{other}},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 1 || status.Commands != base.Commands+1 || status.UpdatedAt.IsZero() {
+		t.Fatalf("status after merge = %+v", status)
+	}
+	if e.WhitelistStatus() != status {
+		t.Fatalf("WhitelistStatus() = %+v, want %+v", e.WhitelistStatus(), status)
+	}
+	report, err := e.Validate(&comms.Command{Type: "shell", Command: "lsblk", Args: []string{"-J"}})
+	if err != nil || report.Spec.Name != "lsblk" || report.Timeout.Seconds() != 10 {
+		t.Fatalf("lsblk after the update = %+v, %v", report, err)
+	}
+	if allowed(e, "hostnamectl") {
+		t.Fatal("spec for another platform applied")
+	}
+	if _, err := e.Validate(&comms.Command{Type: "shell", Command: "lsblk", Args: []string{"-O"}}); err == nil {
+		t.Fatal("argument outside the pushed spec accepted")
+	}
+
+	// A mesma atualização de novo (ou uma mais antiga) é recusada
+	if _, err := e.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{Version: 1, Commands: map[string]CommandSpec{"uptime": {}}})); !errors.Is(err, ErrWhitelistStaleVersion) {
+		t.Fatalf("replayed version = %v", err)
+	}
+
+	// merge com remove tira um comando, embutido ou não
+	if _, err := e.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{Version: 2, Remove: []string{"lsblk"}})); err != nil {
+		t.Fatal(err)
+	}
+	if allowed(e, "lsblk") || e.WhitelistStatus().Commands != base.Commands {
+		t.Fatalf("whitelist after remove = %+v", e.WhitelistStatus())
+	}
+
+	// replace deixa só os specs enviados
+	status, err = e.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{Version: 5, Mode: WhitelistReplace, Commands: map[string]CommandSpec{"uptime": {}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != 5 || status.Commands != 1 || !allowed(e, "uptime") || allowed(e, "ps") {
+		t.Fatalf("status after replace = %+v", status)
+	}
+}
+
+func TestWhitelistUpdateRejectsUnsafeContent(t *testing.T) {
+	key := newSigningKey(t)
+	e := newWhitelistTestExecutor(t, "", key)
+
+	// Mesmo assinado, nada contorna as verificações embutidas
+	for name, update := range map[string]WhitelistUpdate{
+		"dangerous command":  {Version: 1, Commands: map[string]CommandSpec{"rm": {}}},
+		"path to a command":  {Version: 1, Commands: map[string]CommandSpec{"/bin/rm": {}}},
+		"mismatched name":    {Version: 1, Commands: map[string]CommandSpec{"uptime": {Name: "bash"}}},
+		"invalid pattern":    {Version: 1, Commands: map[string]CommandSpec{"uptime": {ArgPatterns: map[string]string{"arg0": "("}}}},
+		"zero version":       {Commands: map[string]CommandSpec{"uptime": {}}},
+		"remove in replace":  {Version: 1, Mode: WhitelistReplace, Remove: []string{"ps"}},
+		"unknown mode":       {Version: 1, Mode: "append"},
+		"negative max args":  {Version: 1, Commands: map[string]CommandSpec{"uptime": {MaxArgs: -1}}},
+		"negative timeout":   {Version: 1, Commands: map[string]CommandSpec{"uptime": {TimeoutSeconds: -1}}},
+		"shell as a command": {Version: 1, Commands: map[string]CommandSpec{"sh": {}}},
+	} {
+		if _, err := e.ApplyWhitelistUpdate(key.signUpdate(t, update)); err == nil || !strings.Contains(err.Error(), "invalid whitelist update") {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if status := e.WhitelistStatus(); status.Version != 0 || allowed(e, "rm") {
+		t.Fatalf("whitelist changed by a rejected update: %+v", status)
+	}
+}
+
+func TestWhitelistUpdateBadSignature(t *testing.T) {
+	key, attacker := newSigningKey(t), newSigningKey(t)
+	statePath := filepath.Join(t.TempDir(), "whitelist.json")
+	e := newWhitelistTestExecutor(t, statePath, key)
+	update := WhitelistUpdate{Version: 1, Commands: map[string]CommandSpec{"lsblk": {}}}
+
+	signed := key.signUpdate(t, update)
+	tampered := signed
+	tampered.Payload = strings.Replace(signed.Payload, "lsblk", "lsusb", 1)
+	truncated := signed
+	truncated.Signature = signed.Signature[:20]
+
+	for name, candidate := range map[string]SignedWhitelistUpdate{
+		"another key":        attacker.signUpdate(t, update),
+		"tampered payload":   tampered,
+		"truncated":          truncated,
+		"not base64":         {Payload: signed.Payload, Signature: "%%%"},
+		"missing signature":  {Payload: signed.Payload},
+		"signature of other": {Payload: signed.Payload, Signature: key.signUpdate(t, WhitelistUpdate{Version: 2}).Signature},
+	} {
+		if _, err := e.ApplyWhitelistUpdate(candidate); !errors.Is(err, ErrWhitelistSignature) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if status := e.WhitelistStatus(); status.Version != 0 || allowed(e, "lsblk") || allowed(e, "lsusb") {
+		t.Fatalf("whitelist changed by a bad signature: %+v", status)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Fatalf("rejected update persisted: %v", err)
+	}
+
+	// Sem chaves configuradas, nenhuma atualização é aceita
+	disabled := newWhitelistTestExecutor(t, "")
+	if _, err := disabled.ApplyWhitelistUpdate(signed); !errors.Is(err, ErrWhitelistUpdatesDisabled) {
+		t.Fatalf("update without keys = %v", err)
+	}
+
+	// Chaves trocadas em execução: a antiga deixa de valer
+	if err := e.SetWhitelistKeys([]string{attacker.public}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.ApplyWhitelistUpdate(signed); !errors.Is(err, ErrWhitelistSignature) {
+		t.Fatalf("update signed with the removed key = %v", err)
+	}
+	if err := e.SetWhitelistKeys([]string{"not-a-key"}); err == nil {
+		t.Fatal("invalid key accepted")
+	}
+	if _, err := e.ApplyWhitelistUpdate(attacker.signUpdate(t, update)); err != nil {
+		t.Fatalf("invalid key list replaced the active keys: %v", err)
+	}
+}
+
+func TestWhitelistRestoredAfterRestart(t *testing.T) {
+	key := newSigningKey(t)
+	statePath := filepath.Join(t.TempDir(), "data", "whitelist.json")
+	e := newWhitelistTestExecutor(t, statePath, key)
+	base := e.WhitelistStatus()
+
+	for _, update := range []WhitelistUpdate{
+		{Version: 3, Commands: map[string]CommandSpec{"lsblk": {MaxArgs: 1}, "lsusb": {}}},
+		{Version: 4, Commands: map[string]CommandSpec{"lsusb": {MaxArgs: 2}}, Remove: []string{"lsblk"}},
+	} {
+		if _, err := e.ApplyWhitelistUpdate(key.signUpdate(t, update)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applied := e.WhitelistStatus()
+
+	// Reinício com as mesmas chaves: versão, comandos e horário voltam
+	restarted := newWhitelistTestExecutor(t, statePath, key)
+	status := restarted.WhitelistStatus()
+	if status.Version != 4 || status.Commands != base.Commands+1 || !status.UpdatedAt.Equal(applied.UpdatedAt) {
+		t.Fatalf("restored status = %+v, want %+v", status, applied)
+	}
+	if spec, ok := restarted.GetWhitelist().GetCommandSpec("lsusb"); !ok || spec.MaxArgs != 2 || allowed(restarted, "lsblk") {
+		t.Fatalf("restored whitelist differs from the applied one: lsusb %+v", spec)
+	}
+	// E a versão restaurada continua barrando reenvios
+	if _, err := restarted.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{Version: 4, Commands: map[string]CommandSpec{"uptime": {}}})); !errors.Is(err, ErrWhitelistStaleVersion) {
+		t.Fatalf("replay after restart = %v", err)
+	}
+
+	// Um replace persiste só a si mesmo
+	if _, err := restarted.ApplyWhitelistUpdate(key.signUpdate(t, WhitelistUpdate{Version: 6, Mode: WhitelistReplace, Commands: map[string]CommandSpec{"uptime": {}}})); err != nil {
+		t.Fatal(err)
+	}
+	var state whitelistState
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil || len(state.Updates) != 1 || state.Version != 6 {
+		t.Fatalf("persisted state = %+v, %v", state, err)
+	}
+	if status := newWhitelistTestExecutor(t, statePath, key).WhitelistStatus(); status.Version != 6 || status.Commands != 1 {
+		t.Fatalf("restored after replace = %+v", status)
+	}
+
+	// Sem a chave que assinou, ou com o arquivo adulterado, volta a embutida
+	if status := newWhitelistTestExecutor(t, statePath, newSigningKey(t)).WhitelistStatus(); status != base {
+		t.Fatalf("restored with another key = %+v, want %+v", status, base)
+	}
+	state.Updates[0].Payload = strings.Replace(state.Updates[0].Payload, "uptime", "lsblk", 1)
+	data, err = json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := newWhitelistTestExecutor(t, statePath, key).WhitelistStatus(); status != base {
+		t.Fatalf("restored from a tampered file = %+v, want %+v", status, base)
+	}
+	if err := os.WriteFile(statePath, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := newWhitelistTestExecutor(t, statePath, key).WhitelistStatus(); status != base {
+		t.Fatalf("restored from a corrupted file = %+v", status)
+	}
+}
+
+func TestWhitelistUpdateExpiry(t *testing.T) {
+	key := newSigningKey(t)
+	statePath := filepath.Join(t.TempDir(), "whitelist.json")
+	e := newWhitelistTestExecutor(t, statePath, key)
+	lsblk := map[string]CommandSpec{"lsblk": {}}
+
+	for name, signed := range map[string]SignedWhitelistUpdate{
+		"no expiry":     key.signPayload([]byte(`{"version":1,"commands":{"lsblk":{}}}`)),
+		"expired":       key.signUpdate(t, WhitelistUpdate{Version: 1, ExpiresAt: time.Now().Add(-time.Minute), Commands: lsblk}),
+		"too far ahead": key.signUpdate(t, WhitelistUpdate{Version: 1, ExpiresAt: time.Now().Add(2 * whitelistUpdateMaxValidity), Commands: lsblk}),
+	} {
+		if _, err := e.ApplyWhitelistUpdate(signed); !errors.Is(err, ErrWhitelistExpired) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if status := e.WhitelistStatus(); status.Version != 0 || allowed(e, "lsblk") {
+		t.Fatalf("whitelist changed by an expired update: %+v", status)
+	}
+
+	// Uma atualização aceita continua valendo depois de expirar
+	expiresAt := time.Now().Add(200 * time.Millisecond)
+	signed := key.signUpdate(t, WhitelistUpdate{Version: 1, ExpiresAt: expiresAt, Commands: lsblk})
+	if _, err := e.ApplyWhitelistUpdate(signed); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+	if status := newWhitelistTestExecutor(t, statePath, key).WhitelistStatus(); status.Version != 1 {
+		t.Fatalf("expired update not restored: %+v", status)
+	}
+
+	// Sem o whitelist.json a versão volta a 0, mas a atualização capturada
+	// não pode mais ser reenviada
+	if err := os.Remove(statePath); err != nil {
+		t.Fatal(err)
+	}
+	restarted := newWhitelistTestExecutor(t, statePath, key)
+	if _, err := restarted.ApplyWhitelistUpdate(signed); !errors.Is(err, ErrWhitelistExpired) {
+		t.Fatalf("replay after losing the state = %v", err)
+	}
+	if status := restarted.WhitelistStatus(); status.Version != 0 || allowed(restarted, "lsblk") {
+		t.Fatalf("whitelist after the replay = %+v", status)
+	}
+}
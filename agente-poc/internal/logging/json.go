@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// jsonEntry é o envelope fixo de cada linha no formato json
+type jsonEntry struct {
+	Timestamp string                 `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields"`
+	Caller    string                 `json:"caller,omitempty"`
+}
+
+// jsonLine monta a linha JSON (terminada em \n) da mensagem. Números e
+// booleanos mantêm o tipo; erros saem como a mensagem mais o tipo em
+// "<campo>_type"; valores que o encoding/json não representa saem como %v.
+func (l *StandardLogger) jsonLine(now time.Time, level LogLevel, msg string) string {
+	entry := jsonEntry{
+		Timestamp: now.Format(time.RFC3339Nano),
+		Level:     level.String(),
+		Message:   msg,
+		Fields:    make(map[string]interface{}, len(l.fields)),
+	}
+	for key, value := range l.fields {
+		addJSONField(entry.Fields, key, value)
+	}
+	if l.config.Caller {
+		entry.Caller = callerLocation()
+	}
+
+	line, err := encodeJSONEntry(entry)
+	if err != nil {
+		// Os campos já passaram por addJSONField; sem eles o envelope sempre codifica
+		entry.Message = "log entry could not be encoded: " + err.Error()
+		entry.Fields = map[string]interface{}{}
+		line, _ = encodeJSONEntry(entry)
+	}
+	return line
+}
+
+// encodeJSONEntry codifica a entrada sem escapar <, > e & (não é HTML)
+func encodeJSONEntry(entry jsonEntry) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// addJSONField adiciona value em fields com o tipo adequado ao JSON
+func addJSONField(fields map[string]interface{}, key string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		fields[key] = nil
+	case error:
+		fields[key] = v.Error()
+		fields[key+"_type"] = fmt.Sprintf("%T", v)
+	case time.Duration:
+		// Como no formato text ("1.5s"), não em nanossegundos
+		fields[key] = v.String()
+	case json.Marshaler:
+		fields[key] = jsonOrString(v)
+	case fmt.Stringer:
+		fields[key] = v.String()
+	default:
+		fields[key] = jsonOrString(v)
+	}
+}
+
+// jsonOrString retorna value se o encoding/json o aceita (NaN, canais e
+// funções não) e, senão, a representação %v
+func jsonOrString(value interface{}) interface{} {
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return value
+}
+
+// callerLocation retorna arquivo:linha do primeiro chamador fora do logger
+// (que pode ser chamado direto ou pelas funções globais)
+func callerLocation() string {
+	for skip := 2; ; skip++ {
+		_, file, line, ok := runtime.Caller(skip)
+		if !ok {
+			return ""
+		}
+		if strings.HasSuffix(file, "/internal/logging/logger.go") || strings.HasSuffix(file, "/internal/logging/json.go") {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", trimPath(file), line)
+	}
+}
+
+// trimPath mantém o diretório e o nome do arquivo (ex.: agent/agent.go)
+func trimPath(file string) string {
+	idx := strings.LastIndexByte(file, '/')
+	if idx <= 0 {
+		return file
+	}
+	if prev := strings.LastIndexByte(file[:idx], '/'); prev >= 0 {
+		return file[prev+1:]
+	}
+	return file
+}
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBufferLogger cria um logger no formato informado escrevendo em buf
+func newBufferLogger(t *testing.T, config *Config) (*StandardLogger, *bytes.Buffer) {
+	t.Helper()
+	logger, err := NewLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	l := logger.(*StandardLogger)
+	l.logger.SetOutput(&buf)
+	return l, &buf
+}
+
+// decodeJSONLines decodifica cada linha da saída
+func decodeJSONLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// pathError é um erro de tipo conhecido para o campo <campo>_type
+var pathError error = &fs.PathError{Op: "open", Path: "/etc/agent.json", Err: fs.ErrNotExist}
+
+func TestJSONLogMessagesRoundTrip(t *testing.T) {
+	l, buf := newBufferLogger(t, &Config{Level: DEBUG, Format: "json", Output: "stdout"})
+
+	messages := []string{
+		`plain`,
+		`say "hello"`,
+		"multi\nline\r\nmessage\ttabbed",
+		`back\slash`,
+		"unicode: ação, 日本語, emoji 🚀",
+		`<html> & "entities"`,
+		"control \x00\x1f bytes",
+	}
+	for _, msg := range messages {
+		l.Info("%s", msg)
+	}
+
+	entries := decodeJSONLines(t, buf)
+	if len(entries) != len(messages) {
+		t.Fatalf("%d lines for %d messages:\n%s", len(entries), len(messages), buf.String())
+	}
+	for i, entry := range entries {
+		if entry["message"] != messages[i] {
+			t.Errorf("message %q round-tripped as %q", messages[i], entry["message"])
+		}
+		if entry["level"] != "INFO" {
+			t.Errorf("level %v", entry["level"])
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string)); err != nil {
+			t.Errorf("timestamp %v: %v", entry["timestamp"], err)
+		}
+		if fields, ok := entry["fields"].(map[string]interface{}); !ok || len(fields) != 0 {
+			t.Errorf("fields %v", entry["fields"])
+		}
+		if _, ok := entry["caller"]; ok {
+			t.Errorf("caller present without Config.Caller")
+		}
+	}
+	// Sem escape HTML: a linha continua legível
+	if !strings.Contains(buf.String(), `<html> & \"entities\"`) {
+		t.Errorf("HTML escaped: %s", buf.String())
+	}
+}
+
+func TestJSONLogFieldTypes(t *testing.T) {
+	l, buf := newBufferLogger(t, &Config{Level: DEBUG, Format: "json", Output: "stdout"})
+
+	l.WithFields(map[string]interface{}{
+		"count":    42,
+		"ratio":    0.25,
+		"enabled":  true,
+		"name":     `quoted "name"`,
+		"missing":  nil,
+		"elapsed":  1500 * time.Millisecond,
+		"level":    WARNING,
+		"tags":     []string{"a", "b"},
+		"nan":      math.NaN(),
+		"channel":  make(chan int),
+		"instance": map[string]interface{}{"id": 7},
+	}).WithError(pathError).Warning("with fields")
+
+	entry := decodeJSONLines(t, buf)[0]
+	fields := entry["fields"].(map[string]interface{})
+	want := map[string]interface{}{
+		"count":      float64(42),
+		"ratio":      0.25,
+		"enabled":    true,
+		"name":       `quoted "name"`,
+		"missing":    nil,
+		"elapsed":    "1.5s",
+		"level":      "WARNING",
+		"nan":        "NaN",
+		"error":      "open /etc/agent.json: file does not exist",
+		"error_type": "*fs.PathError",
+	}
+	for key, value := range want {
+		if got, ok := fields[key]; !ok || got != value {
+			t.Errorf("field %s = %#v, want %#v", key, got, value)
+		}
+	}
+	if tags, ok := fields["tags"].([]interface{}); !ok || len(tags) != 2 || tags[0] != "a" {
+		t.Errorf("tags = %#v", fields["tags"])
+	}
+	if instance, ok := fields["instance"].(map[string]interface{}); !ok || instance["id"] != float64(7) {
+		t.Errorf("instance = %#v", fields["instance"])
+	}
+	if channel, ok := fields["channel"].(string); !ok || !strings.HasPrefix(channel, "0x") {
+		t.Errorf("channel = %#v", fields["channel"])
+	}
+	if entry["level"] != "WARNING" {
+		t.Errorf("level %v", entry["level"])
+	}
+}
+
+func TestJSONLogWithError(t *testing.T) {
+	l, buf := newBufferLogger(t, &Config{Level: DEBUG, Format: "json", Output: "stdout"})
+
+	base := l.WithField("component", "comms")
+	base.WithError(errors.New(`backend said "no"`)).Error("send failed")
+	// O logger de origem não ganha o campo
+	base.Info("still clean")
+
+	entries := decodeJSONLines(t, buf)
+	fields := entries[0]["fields"].(map[string]interface{})
+	if fields["error"] != `backend said "no"` || fields["error_type"] != "*errors.errorString" || fields["component"] != "comms" {
+		t.Fatalf("fields = %v", fields)
+	}
+	if fields := entries[1]["fields"].(map[string]interface{}); len(fields) != 1 {
+		t.Fatalf("error leaked into the parent logger: %v", fields)
+	}
+}
+
+func TestJSONLogCaller(t *testing.T) {
+	l, buf := newBufferLogger(t, &Config{Level: DEBUG, Format: "json", Output: "stdout", Caller: true})
+
+	l.WithField("k", "v").Info("direct")
+
+	caller, _ := decodeJSONLines(t, buf)[0]["caller"].(string)
+	if !regexp.MustCompile(`^logging/json_test\.go:\d+$`).MatchString(caller) {
+		t.Fatalf("caller %q", caller)
+	}
+}
+
+func TestTextLogFormatUnchanged(t *testing.T) {
+	l, buf := newBufferLogger(t, &Config{Level: DEBUG, Format: "text", Output: "stdout"})
+
+	l.WithField("attempt", 2).Warning("retry %s", `"now"`)
+
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if !regexp.MustCompile(`^\[\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\] WARNING: retry "now" \[attempt=2\]$`).MatchString(line) {
+		t.Fatalf("text line %q", line)
+	}
+}
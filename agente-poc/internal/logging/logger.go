@@ -2,9 +2,11 @@ package logging
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -48,6 +50,9 @@ type Logger interface {
 	GetLevel() LogLevel
 	WithField(key string, value interface{}) Logger
 	WithFields(fields map[string]interface{}) Logger
+	// WithError adiciona o campo "error"; no formato json sai a mensagem do
+	// erro em "error" e o tipo em "error_type"
+	WithError(err error) Logger
 }
 
 // Config representa a configuração do logger
@@ -56,10 +61,11 @@ type Config struct {
 	Format     string   `json:"format"`      // "text", "json"
 	Output     string   `json:"output"`      // "stdout", "stderr", "file"
 	FilePath   string   `json:"file_path"`   // caminho do arquivo se output = "file"
-	MaxSize    int      `json:"max_size"`    // tamanho máximo do arquivo em MB
-	MaxBackups int      `json:"max_backups"` // número máximo de backups
-	MaxAge     int      `json:"max_age"`     // idade máxima em dias
-	Compress   bool     `json:"compress"`    // compactar arquivos antigos
+	MaxSize    int      `json:"max_size"`    // tamanho máximo do arquivo em MB antes de rotacionar (0 desativa)
+	MaxBackups int      `json:"max_backups"` // número máximo de backups (0 mantém todos)
+	MaxAge     int      `json:"max_age"`     // idade máxima dos backups em dias (0 não remove por idade)
+	Compress   bool     `json:"compress"`    // compactar backups com gzip
+	Caller     bool     `json:"caller"`      // incluir arquivo:linha de quem logou (formato json)
 }
 
 // DefaultConfig retorna a configuração padrão
@@ -77,10 +83,14 @@ func DefaultConfig() *Config {
 
 // StandardLogger implementa a interface Logger
 type StandardLogger struct {
-	level  LogLevel
+	// level é compartilhado com os loggers derivados (WithField), para que
+	// SetLevel em tempo de execução (recarga da configuração) valha para todos
+	level  *atomic.Int32
 	config *Config
 	logger *log.Logger
 	fields map[string]interface{}
+	// sinks também é compartilhado com os derivados (ver AttachSink)
+	sinks *sinkSet
 }
 
 // NewLogger cria um novo logger com a configuração especificada
@@ -89,8 +99,7 @@ func NewLogger(config *Config) (Logger, error) {
 		config = DefaultConfig()
 	}
 
-	var output *os.File
-	var err error
+	var output io.Writer
 
 	switch config.Output {
 	case "stdout":
@@ -101,21 +110,31 @@ func NewLogger(config *Config) (Logger, error) {
 		if config.FilePath == "" {
 			return nil, fmt.Errorf("file_path é obrigatório quando output = file")
 		}
-		output, err = os.OpenFile(config.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		file, err := OpenRotatingFile(config.FilePath, RotateOptions{
+			MaxBytes:   int64(config.MaxSize) * 1024 * 1024,
+			MaxBackups: config.MaxBackups,
+			MaxAge:     time.Duration(config.MaxAge) * 24 * time.Hour,
+			Compress:   config.Compress,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("erro ao abrir arquivo de log: %w", err)
 		}
+		output = file
 	default:
 		output = os.Stdout
 	}
 
 	logger := log.New(output, "", 0)
 
+	level := new(atomic.Int32)
+	level.Store(int32(config.Level))
+
 	return &StandardLogger{
-		level:  config.Level,
+		level:  level,
 		config: config,
 		logger: logger,
 		fields: make(map[string]interface{}),
+		sinks:  &sinkSet{},
 	}, nil
 }
 
@@ -139,12 +158,12 @@ func ParseLogLevel(level string) LogLevel {
 
 // SetLevel define o nível de log
 func (l *StandardLogger) SetLevel(level LogLevel) {
-	l.level = level
+	l.level.Store(int32(level))
 }
 
 // GetLevel retorna o nível atual de log
 func (l *StandardLogger) GetLevel() LogLevel {
-	return l.level
+	return LogLevel(l.level.Load())
 }
 
 // WithField adiciona um campo ao contexto do log
@@ -154,6 +173,7 @@ func (l *StandardLogger) WithField(key string, value interface{}) Logger {
 		config: l.config,
 		logger: l.logger,
 		fields: make(map[string]interface{}),
+		sinks:  l.sinks,
 	}
 
 	// Copiar campos existentes
@@ -174,6 +194,7 @@ func (l *StandardLogger) WithFields(fields map[string]interface{}) Logger {
 		config: l.config,
 		logger: l.logger,
 		fields: make(map[string]interface{}),
+		sinks:  l.sinks,
 	}
 
 	// Copiar campos existentes
@@ -189,30 +210,35 @@ func (l *StandardLogger) WithFields(fields map[string]interface{}) Logger {
 	return newLogger
 }
 
+// WithError adiciona o erro ao contexto do log no campo "error"
+func (l *StandardLogger) WithError(err error) Logger {
+	return l.WithField("error", err)
+}
+
 // Debug registra uma mensagem de debug
 func (l *StandardLogger) Debug(msg string, args ...interface{}) {
-	if l.level <= DEBUG {
+	if l.GetLevel() <= DEBUG {
 		l.log(DEBUG, msg, args...)
 	}
 }
 
 // Info registra uma mensagem de informação
 func (l *StandardLogger) Info(msg string, args ...interface{}) {
-	if l.level <= INFO {
+	if l.GetLevel() <= INFO {
 		l.log(INFO, msg, args...)
 	}
 }
 
 // Warning registra uma mensagem de aviso
 func (l *StandardLogger) Warning(msg string, args ...interface{}) {
-	if l.level <= WARNING {
+	if l.GetLevel() <= WARNING {
 		l.log(WARNING, msg, args...)
 	}
 }
 
 // Error registra uma mensagem de erro
 func (l *StandardLogger) Error(msg string, args ...interface{}) {
-	if l.level <= ERROR {
+	if l.GetLevel() <= ERROR {
 		l.log(ERROR, msg, args...)
 	}
 }
@@ -225,13 +251,23 @@ func (l *StandardLogger) Fatal(msg string, args ...interface{}) {
 
 // log é o método interno para registrar mensagens
 func (l *StandardLogger) log(level LogLevel, msg string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now()
 
 	// Formatar mensagem com argumentos
 	if len(args) > 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
 
+	l.sinks.dispatch(now, level, msg, l.fields)
+
+	// JSON estruturado (json.go)
+	if l.config.Format == "json" {
+		l.logger.Print(l.jsonLine(now, level, msg))
+		return
+	}
+
+	timestamp := now.Format("2006-01-02 15:04:05")
+
 	// Construir campos
 	fieldsStr := ""
 	if len(l.fields) > 0 {
@@ -243,32 +279,11 @@ func (l *StandardLogger) log(level LogLevel, msg string, args ...interface{}) {
 	}
 
 	// Formato da mensagem
-	var logMsg string
-	switch l.config.Format {
-	case "json":
-		logMsg = fmt.Sprintf(`{"timestamp":"%s","level":"%s","message":"%s","fields":%s}`,
-			timestamp, level.String(), msg, l.fieldsToJSON())
-	default:
-		logMsg = fmt.Sprintf("[%s] %s: %s%s", timestamp, level.String(), msg, fieldsStr)
-	}
+	logMsg := fmt.Sprintf("[%s] %s: %s%s", timestamp, level.String(), msg, fieldsStr)
 
 	l.logger.Println(logMsg)
 }
 
-// fieldsToJSON converte campos para JSON
-func (l *StandardLogger) fieldsToJSON() string {
-	if len(l.fields) == 0 {
-		return "{}"
-	}
-
-	var parts []string
-	for k, v := range l.fields {
-		parts = append(parts, fmt.Sprintf(`"%s":"%v"`, k, v))
-	}
-
-	return "{" + strings.Join(parts, ",") + "}"
-}
-
 // Global logger instance
 var globalLogger Logger
 
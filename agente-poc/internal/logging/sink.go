@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Entry é uma linha de log entregue aos sinks; Fields já vem convertido como
+// no formato json (erros como mensagem mais "<campo>_type", durações como
+// texto), pronto para ser serializado
+type Entry struct {
+	Time    time.Time              `json:"timestamp"`
+	Level   LogLevel               `json:"-"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Sink recebe as linhas já aceitas pelo nível do logger. Handle roda na
+// goroutine de quem está logando: deve retornar logo, sem bloquear nem
+// logar de volta no mesmo logger.
+type Sink interface {
+	Handle(entry Entry)
+}
+
+// sinkSet são os sinks de um logger, compartilhados com os loggers
+// derivados (WithField), como o nível
+type sinkSet struct {
+	mu    sync.RWMutex
+	sinks []Sink
+}
+
+// add registra o sink e retorna a função que o remove
+func (s *sinkSet) add(sink Sink) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinks = append(s.sinks, sink)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.remove(sink) })
+	}
+}
+
+// remove retira o sink (a primeira ocorrência)
+func (s *sinkSet) remove(sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.sinks {
+		if existing == sink {
+			s.sinks = append(s.sinks[:i:i], s.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatch entrega a linha aos sinks; sem sinks, nada é montado
+func (s *sinkSet) dispatch(now time.Time, level LogLevel, msg string, fields map[string]interface{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.sinks) == 0 {
+		return
+	}
+
+	entry := Entry{Time: now, Level: level, Message: msg}
+	if len(fields) > 0 {
+		entry.Fields = make(map[string]interface{}, len(fields))
+		for key, value := range fields {
+			addJSONField(entry.Fields, key, value)
+		}
+	}
+	for _, sink := range s.sinks {
+		sink.Handle(entry)
+	}
+}
+
+// AttachSink passa a entregar ao sink as linhas de logger e de todos os
+// loggers derivados dele, inclusive os criados antes da chamada. Retorna a
+// função que desliga o sink; falha se logger não for um StandardLogger.
+func AttachSink(logger Logger, sink Sink) (detach func(), err error) {
+	standard, ok := logger.(*StandardLogger)
+	if !ok {
+		return nil, fmt.Errorf("logger %T does not support sinks", logger)
+	}
+	return standard.sinks.add(sink), nil
+}
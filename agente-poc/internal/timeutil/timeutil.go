@@ -0,0 +1,116 @@
+// Package timeutil interpreta e formata durações de configuração, logs e
+// payloads de forma uniforme. O agent-app tem uma cópia idêntica deste pacote
+// (os dois agentes são módulos Go separados); altere os dois juntos.
+package timeutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseFlexibleDuration aceita um número puro ("90", "1.5"), interpretado na
+// unidade padrão do campo, ou uma duração Go ("90s", "1.5m", "2h30m")
+func ParseFlexibleDuration(value string, unit time.Duration) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(number * float64(unit)), nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: use a number or a value like 90s, 1.5m or 2h30m", value)
+	}
+	return d, nil
+}
+
+// FormatDurationHuman formata d com as duas maiores unidades ("2h 13m",
+// "3d 4h", "45s"); abaixo de um segundo usa milissegundos
+func FormatDurationHuman(d time.Duration) string {
+	if d < 0 {
+		return "-" + FormatDurationHuman(-d)
+	}
+	if d < time.Second {
+		if d == 0 {
+			return "0s"
+		}
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+
+	units := []struct {
+		size   time.Duration
+		suffix string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+
+	parts := make([]string, 0, 2)
+	for _, unit := range units {
+		if d < unit.size && len(parts) == 0 {
+			continue
+		}
+		n := d / unit.size
+		d -= n * unit.size
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", n, unit.suffix))
+		}
+		if len(parts) == 2 || (len(parts) == 1 && n == 0) {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Seconds é uma duração serializada em segundos. No JSON aceita o formato
+// antigo (número de segundos) e strings de ParseFlexibleDuration; é gravada
+// como número inteiro de segundos quando exata, para versões antigas lerem.
+type Seconds time.Duration
+
+// Duration retorna o valor como time.Duration
+func (s Seconds) Duration() time.Duration {
+	return time.Duration(s)
+}
+
+// String formata o valor com FormatDurationHuman
+func (s Seconds) String() string {
+	return FormatDurationHuman(time.Duration(s))
+}
+
+// MarshalJSON grava segundos inteiros, ou a duração Go se fracionária
+func (s Seconds) MarshalJSON() ([]byte, error) {
+	d := time.Duration(s)
+	if d%time.Second == 0 {
+		return []byte(strconv.FormatInt(int64(d/time.Second), 10)), nil
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON aceita número (segundos), string ou null (mantém zero)
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	d, err := ParseFlexibleDuration(text, time.Second)
+	if err != nil {
+		return err
+	}
+	*s = Seconds(d)
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package version guarda a versão do agente, definida no build:
+//
+//	go build -ldflags "-X agente-poc/internal/version.Version=1.2.3" ./cmd/agente
+package version
+
+// Version é a versão do binário; "dev" em builds sem -ldflags
+var Version = "dev"
+
+// UserAgent é o User-Agent das requisições do agente
+func UserAgent() string {
+	return "MacOS-Agent/" + Version
+}